@@ -0,0 +1,51 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// diagnosticsDelay is how long textDocument/didChange waits for typing to
+// pause before linting the document and publishing diagnostics. Requests
+// such as hover or semantic tokens are still answered against the latest
+// text right away.
+var diagnosticsDelay = 150 * time.Millisecond
+
+// debouncer runs at most one pending job per document; scheduling again
+// before the delay has passed replaces the earlier job.
+type debouncer struct {
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+}
+
+var pendingDiagnostics = &debouncer{timers: map[string]*time.Timer{}}
+
+func (d *debouncer) schedule(uri string, delay time.Duration, fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if t, ok := d.timers[uri]; ok {
+		t.Stop()
+	}
+	var t *time.Timer
+	t = time.AfterFunc(delay, func() {
+		d.mu.Lock()
+		if d.timers[uri] != t {
+			d.mu.Unlock()
+			return
+		}
+		delete(d.timers, uri)
+		d.mu.Unlock()
+		guard("lsp debounced job", fn)
+	})
+	d.timers[uri] = t
+}
+
+// cancel drops the pending job for uri, if any.
+func (d *debouncer) cancel(uri string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if t, ok := d.timers[uri]; ok {
+		t.Stop()
+		delete(d.timers, uri)
+	}
+}
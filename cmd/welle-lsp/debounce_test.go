@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"welle/internal/lsp"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestDidChangeDebouncesDiagnostics(t *testing.T) {
+	store = lsp.NewStore()
+	published := make(chan *protocol.PublishDiagnosticsParams, 8)
+	ctx := &glsp.Context{Notify: func(method string, params any) {
+		if method == protocol.ServerTextDocumentPublishDiagnostics {
+			published <- params.(*protocol.PublishDiagnosticsParams)
+		}
+	}}
+
+	old := diagnosticsDelay
+	diagnosticsDelay = 20 * time.Millisecond
+	defer func() { diagnosticsDelay = old }()
+
+	uri := "file:///debounce.wll"
+	for _, text := range []string{"x = (", "x = (1", "x = (1)\nprint(x)\n"} {
+		params := &protocol.DidChangeTextDocumentParams{
+			TextDocument:   protocol.VersionedTextDocumentIdentifier{TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri}},
+			ContentChanges: []any{protocol.TextDocumentContentChangeEventWhole{Text: text}},
+		}
+		if err := textDocumentDidChange(ctx, params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(published) != 0 {
+		t.Fatalf("diagnostics published before the delay")
+	}
+
+	select {
+	case p := <-published:
+		if len(p.Diagnostics) != 0 {
+			t.Fatalf("expected diagnostics for the last text only, got %v", p.Diagnostics)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no diagnostics published")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if len(published) != 0 {
+		t.Fatalf("expected a single publish, got %d more", len(published))
+	}
+}
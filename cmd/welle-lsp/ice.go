@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"welle/internal/ice"
+)
+
+// iceLog writes the reports of internal errors to the server's stderr, which
+// editors keep as its log. The document that trips a bug trips it again on
+// every edit, so each distinct panic is reported once; its WI0001
+// diagnostic is still published every time.
+type iceLog struct {
+	mu   sync.Mutex
+	out  io.Writer
+	seen map[string]bool
+}
+
+var internalErrors = &iceLog{out: os.Stderr, seen: map[string]bool{}}
+
+func (l *iceLog) report(e *ice.Error) {
+	key := e.Phase + "\x00" + fmt.Sprint(e.Value)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.seen[key] {
+		return
+	}
+	l.seen[key] = true
+	fmt.Fprintln(l.out, e.Report())
+}
+
+// guard runs fn, which runs outside any request, reporting a panic in it
+// as an internal error of phase instead of letting it end the server.
+func guard(phase string, fn func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			ice.Report(ice.New(phase, v))
+		}
+	}()
+	fn()
+}
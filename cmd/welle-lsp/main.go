@@ -4,17 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"welle/internal/config"
 	"welle/internal/diag"
-	"welle/internal/lexer"
+	"welle/internal/ice"
 	"welle/internal/lint"
 	"welle/internal/lsp"
-	"welle/internal/parser"
 
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
-	"github.com/tliron/glsp/server"
 )
 
 const (
@@ -25,14 +25,17 @@ const (
 var store = lsp.NewStore()
 var handler protocol.Handler
 var ws *lsp.Workspace
+var lintOpts = lint.DefaultOptions()
 
 func main() {
+	ice.SetReporter(internalErrors.report)
 	handler = protocol.Handler{
 		Initialize:                     initialize,
 		Initialized:                    initialized,
 		TextDocumentDidOpen:            textDocumentDidOpen,
 		TextDocumentDidChange:          textDocumentDidChange,
 		TextDocumentDidSave:            textDocumentDidSave,
+		TextDocumentWillSaveWaitUntil:  textDocumentWillSaveWaitUntil,
 		TextDocumentDidClose:           textDocumentDidClose,
 		TextDocumentCodeAction:         textDocumentCodeAction,
 		TextDocumentFormatting:         textDocumentFormatting,
@@ -44,10 +47,12 @@ func main() {
 		TextDocumentRename:             textDocumentRename,
 		TextDocumentReferences:         textDocumentReferences,
 		TextDocumentSignatureHelp:      textDocumentSignatureHelp,
+		TextDocumentSelectionRange:     textDocumentSelectionRange,
+		WorkspaceDidChangeWatchedFiles: workspaceDidChangeWatchedFiles,
+		WorkspaceSymbol:                workspaceSymbol,
 	}
 
-	server := server.NewServer(&handler, lsName, false)
-	server.RunStdio()
+	newDispatcher(&handler, heavyRequests).serveStdio()
 }
 
 func initialize(ctx *glsp.Context, params *protocol.InitializeParams) (any, error) {
@@ -60,7 +65,12 @@ func initialize(ctx *glsp.Context, params *protocol.InitializeParams) (any, erro
 	if root == "" {
 		root = "."
 	}
+	workspaceRoot = root
+	watchFiles = clientCanWatchFiles(params.Capabilities)
 	ws = lsp.NewWorkspace(root)
+	ws.SetPrelude(loadPrelude(root))
+	lintOpts = loadLintOptions(root)
+	editorCfg = loadEditorConfig(root)
 
 	full := protocol.TextDocumentSyncKindFull
 	legend := protocol.SemanticTokensLegend{
@@ -79,6 +89,8 @@ func initialize(ctx *glsp.Context, params *protocol.InitializeParams) (any, erro
 		TokenModifiers: []string{
 			string(protocol.SemanticTokenModifierDeclaration),
 			string(protocol.SemanticTokenModifierReadonly),
+			string(protocol.SemanticTokenModifierDefaultLibrary),
+			"exported",
 		},
 	}
 	caps := protocol.ServerCapabilities{
@@ -86,9 +98,15 @@ func initialize(ctx *glsp.Context, params *protocol.InitializeParams) (any, erro
 			OpenClose: &protocol.True,
 			Change:    &full,
 			Save:      protocol.SaveOptions{IncludeText: &protocol.False},
+			// Answered with no edits unless [editor] enables something.
+			WillSaveWaitUntil: &protocol.True,
 		},
 		CodeActionProvider: protocol.CodeActionOptions{
-			CodeActionKinds: []protocol.CodeActionKind{protocol.CodeActionKindQuickFix},
+			CodeActionKinds: []protocol.CodeActionKind{
+				protocol.CodeActionKindQuickFix,
+				protocol.CodeActionKindSourceOrganizeImports,
+				lsp.CodeActionKindSourceFixAll,
+			},
 		},
 		SemanticTokensProvider: &protocol.SemanticTokensOptions{
 			Legend: legend,
@@ -101,9 +119,11 @@ func initialize(ctx *glsp.Context, params *protocol.InitializeParams) (any, erro
 		CompletionProvider: &protocol.CompletionOptions{
 			TriggerCharacters: []string{".", "\""},
 		},
-		HoverProvider:      true,
-		RenameProvider:     true,
-		ReferencesProvider: true,
+		HoverProvider:           true,
+		RenameProvider:          true,
+		ReferencesProvider:      true,
+		WorkspaceSymbolProvider: true,
+		SelectionRangeProvider:  true,
 		SignatureHelpProvider: &protocol.SignatureHelpOptions{
 			TriggerCharacters:   []string{"(", ","},
 			RetriggerCharacters: []string{")"},
@@ -123,6 +143,9 @@ func initialize(ctx *glsp.Context, params *protocol.InitializeParams) (any, erro
 }
 
 func initialized(ctx *glsp.Context, params *protocol.InitializedParams) error {
+	if watchFiles {
+		registerFileWatchers(ctx)
+	}
 	return nil
 }
 
@@ -146,12 +169,21 @@ func textDocumentDidChange(ctx *glsp.Context, params *protocol.DidChangeTextDocu
 
 	store.Set(uri, text)
 	updateIndex(uri, text)
-	return publishDiagnostics(ctx, uri, text)
+	if diagnosticsDelay <= 0 {
+		return publishDiagnostics(ctx, uri, text)
+	}
+	pendingDiagnostics.schedule(uri, diagnosticsDelay, func() {
+		if text, ok := store.Get(uri); ok {
+			_ = publishDiagnostics(ctx, uri, text)
+		}
+	})
+	return nil
 }
 
 func textDocumentDidSave(ctx *glsp.Context, params *protocol.DidSaveTextDocumentParams) error {
 	uri := string(params.TextDocument.URI)
 	if text, ok := store.Get(uri); ok {
+		pendingDiagnostics.cancel(uri)
 		return publishDiagnostics(ctx, uri, text)
 	}
 	return nil
@@ -159,6 +191,7 @@ func textDocumentDidSave(ctx *glsp.Context, params *protocol.DidSaveTextDocument
 
 func textDocumentDidClose(ctx *glsp.Context, params *protocol.DidCloseTextDocumentParams) error {
 	uri := string(params.TextDocument.URI)
+	pendingDiagnostics.cancel(uri)
 	store.Delete(uri)
 	if ws != nil {
 		ws.DropURI(uri)
@@ -192,8 +225,13 @@ func textDocumentCodeAction(ctx *glsp.Context, params *protocol.CodeActionParams
 			if action, ok := lsp.MakePrefixUnderscoreAction(uri, text, d.Range); ok {
 				actions = append(actions, action)
 			}
+		case "WL0005":
+			if action, ok := lsp.MakeReplaceOperatorAction(uri, text, d.Range, "or", "??"); ok {
+				actions = append(actions, action)
+			}
 		}
 	}
+	actions = append(actions, sourceCodeActions(uri, text, params.Context.Only)...)
 
 	if len(actions) == 0 {
 		return nil, nil
@@ -277,6 +315,12 @@ func textDocumentDefinition(ctx *glsp.Context, params *protocol.DefinitionParams
 		return nil, nil
 	}
 
+	if locs := lsp.DefinitionAt(uri, text, params.Position); len(locs) > 0 {
+		return locs, nil
+	}
+	if locs := lsp.PreludeDefinitionAt(ws, uri, text, params.Position); len(locs) > 0 {
+		return locs, nil
+	}
 	if loc, ok := ix.Defs[ref.Member]; ok {
 		return []protocol.Location{loc}, nil
 	}
@@ -309,6 +353,15 @@ func textDocumentCompletion(ctx *glsp.Context, params *protocol.CompletionParams
 	return items, nil
 }
 
+func textDocumentSelectionRange(ctx *glsp.Context, params *protocol.SelectionRangeParams) ([]protocol.SelectionRange, error) {
+	uri := string(params.TextDocument.URI)
+	text, ok := store.Get(uri)
+	if !ok {
+		return nil, nil
+	}
+	return lsp.SelectionRangesAt(text, params.Positions), nil
+}
+
 func textDocumentHover(ctx *glsp.Context, params *protocol.HoverParams) (*protocol.Hover, error) {
 	uri := string(params.TextDocument.URI)
 	text, ok := store.Get(uri)
@@ -345,6 +398,10 @@ func textDocumentSignatureHelp(ctx *glsp.Context, params *protocol.SignatureHelp
 	return lsp.SignatureHelpAt(ws, uri, text, params.Position)
 }
 
+func workspaceSymbol(ctx *glsp.Context, params *protocol.WorkspaceSymbolParams) ([]protocol.SymbolInformation, error) {
+	return lsp.WorkspaceSymbols(ws, params.Query), nil
+}
+
 func updateIndex(uri string, text string) {
 	if !strings.HasSuffix(strings.ToLower(uri), ".wll") {
 		if ws != nil {
@@ -366,15 +423,21 @@ func publishDiagnostics(ctx *glsp.Context, uri string, text string) error {
 		return nil
 	}
 
-	lx := lexer.New(text)
-	p := parser.New(lx)
-	prog := p.ParseProgram()
+	pd := lsp.Parse(text)
+	prog := pd.Program
 
-	diags := append([]diag.Diagnostic{}, p.Diagnostics()...)
+	diags := append([]diag.Diagnostic{}, pd.Diagnostics...)
 	if prog != nil {
-		diags = append(diags, lint.Run(prog)...)
+		opts := lintOpts
+		if ws != nil {
+			opts.ModuleDeprecations = ws.ImportDeprecations(lsp.UriToPath(uri))
+		}
+		diags = append(diags, lint.RunWithOptions(prog, opts)...)
+		if len(pd.Errors) == 0 {
+			diags = lsp.AppendCompilerWarnings(diags, prog)
+		}
 	}
-	lspDiags := lsp.ToLspDiagnostics(diags)
+	lspDiags := lsp.ToLspDiagnostics(uri, diag.Dedup(diags))
 
 	ctx.Notify(protocol.ServerTextDocumentPublishDiagnostics, &protocol.PublishDiagnosticsParams{
 		URI:         protocol.DocumentUri(uri),
@@ -383,6 +446,27 @@ func publishDiagnostics(ctx *glsp.Context, uri string, text string) error {
 	return nil
 }
 
+// loadPrelude returns the path of the project prelude named by the
+// workspace's welle.toml, or "".
+func loadPrelude(root string) string {
+	man, err := config.LoadManifest(filepath.Join(root, "welle.toml"))
+	if err != nil || man.Prelude == "" {
+		return ""
+	}
+	if filepath.IsAbs(man.Prelude) {
+		return man.Prelude
+	}
+	return filepath.Join(root, man.Prelude)
+}
+
+func loadLintOptions(root string) lint.Options {
+	man, err := config.LoadManifest(filepath.Join(root, "welle.toml"))
+	if err != nil {
+		return lint.DefaultOptions()
+	}
+	return lint.OptionsFromManifest(man)
+}
+
 func extractFullText(change any) (string, bool) {
 	switch typed := change.(type) {
 	case protocol.TextDocumentContentChangeEventWhole:
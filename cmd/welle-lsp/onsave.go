@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"welle/internal/config"
+	"welle/internal/format"
+	"welle/internal/lsp"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// editorCfg is the `[editor]` section of the workspace's welle.toml.
+var editorCfg config.EditorConfig
+
+func loadEditorConfig(root string) config.EditorConfig {
+	man, err := config.LoadManifest(filepath.Join(root, "welle.toml"))
+	if err != nil {
+		return config.EditorConfig{}
+	}
+	return man.Editor
+}
+
+// textDocumentWillSaveWaitUntil runs the on-save actions enabled in
+// `[editor]`: fixes first, then import organization, then formatting, so
+// the saved file is always formatted. The result is one whole-document
+// edit.
+func textDocumentWillSaveWaitUntil(ctx *glsp.Context, params *protocol.WillSaveTextDocumentParams) ([]protocol.TextEdit, error) {
+	uri := string(params.TextDocument.URI)
+	if !strings.HasSuffix(strings.ToLower(uri), ".wll") {
+		return nil, nil
+	}
+	text, ok := store.Get(uri)
+	if !ok {
+		return nil, nil
+	}
+	out := onSave(text, editorCfg)
+	if out == text {
+		return nil, nil
+	}
+	return []protocol.TextEdit{{Range: lsp.FullDocumentRange(text), NewText: out}}, nil
+}
+
+func onSave(text string, cfg config.EditorConfig) string {
+	if cfg.FixOnSave {
+		text = lsp.FixAll(text, lintOpts)
+	}
+	if cfg.OrganizeImportsOnSave {
+		text = lsp.OrganizeImports(text)
+	}
+	if cfg.FormatOnSave {
+		if formatted, err := format.Format(text, format.Options{}); err == nil {
+			text = formatted
+		}
+	}
+	return text
+}
+
+// sourceCodeActions returns the whole-file actions the client asked for
+// through CodeActionContext.Only (a kind also matches its sub-kinds, so
+// "source" asks for all of them).
+func sourceCodeActions(uri string, text string, only []protocol.CodeActionKind) []protocol.CodeAction {
+	wants := func(kind protocol.CodeActionKind) bool {
+		for _, k := range only {
+			if k == kind || strings.HasPrefix(string(kind), string(k)+".") {
+				return true
+			}
+		}
+		return false
+	}
+	var actions []protocol.CodeAction
+	if wants(protocol.CodeActionKindSourceOrganizeImports) {
+		if a, ok := lsp.SourceAction(uri, text, lsp.OrganizeImports(text), "Organize imports", protocol.CodeActionKindSourceOrganizeImports); ok {
+			actions = append(actions, a)
+		}
+	}
+	if wants(lsp.CodeActionKindSourceFixAll) {
+		if a, ok := lsp.SourceAction(uri, text, lsp.FixAll(text, lintOpts), "Fix all auto-fixable problems", lsp.CodeActionKindSourceFixAll); ok {
+			actions = append(actions, a)
+		}
+	}
+	return actions
+}
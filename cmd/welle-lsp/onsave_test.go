@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"welle/internal/config"
+	"welle/internal/lsp"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestWillSaveWaitUntil(t *testing.T) {
+	store = lsp.NewStore()
+	uri := "file:///test.wll"
+	store.Set(uri, "import \"b\" as b\nimport \"a\" as a\nfunc f(n){return 1}\n")
+	params := &protocol.WillSaveTextDocumentParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}}
+
+	editorCfg = config.EditorConfig{}
+	if edits, _ := textDocumentWillSaveWaitUntil(nil, params); len(edits) != 0 {
+		t.Fatalf("expected no edits without [editor] settings, got %v", edits)
+	}
+
+	editorCfg = config.EditorConfig{FormatOnSave: true, FixOnSave: true, OrganizeImportsOnSave: true}
+	defer func() { editorCfg = config.EditorConfig{} }()
+	edits, err := textDocumentWillSaveWaitUntil(nil, params)
+	if err != nil || len(edits) != 1 {
+		t.Fatalf("expected one edit, got %v (%v)", edits, err)
+	}
+	want := "import \"a\" as a\nimport \"b\" as b\nfunc f(_n) { return 1 }\n"
+	if edits[0].NewText != want {
+		t.Fatalf("unexpected text:\n%s", edits[0].NewText)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"welle/internal/ice"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// heavyRequests are the requests that may read every file in the
+// workspace, with how long each may take. They run in their own goroutine,
+// so the messages after them, including a $/cancelRequest for them, are
+// not held up; everything else is handled in the order it arrives.
+var heavyRequests = map[string]time.Duration{
+	string(protocol.MethodTextDocumentReferences): 10 * time.Second,
+	string(protocol.MethodTextDocumentRename):     10 * time.Second,
+	string(protocol.MethodWorkspaceSymbol):        10 * time.Second,
+}
+
+// LSP error codes jsonrpc2 does not define.
+const (
+	codeRequestCancelled = -32800
+	codeRequestFailed    = -32803
+)
+
+// dispatcher serves the protocol handler over a jsonrpc2 connection. It
+// stands in for glsp's server, whose handler never sees request ids, to
+// answer $/cancelRequest, put heavy requests in the background and keep a
+// panic in one handler from ending the session.
+type dispatcher struct {
+	handler glsp.Handler
+	heavy   map[string]time.Duration
+
+	mu       sync.Mutex
+	inflight map[jsonrpc2.ID]context.CancelFunc
+}
+
+func newDispatcher(h glsp.Handler, heavy map[string]time.Duration) *dispatcher {
+	return &dispatcher{handler: h, heavy: heavy, inflight: map[jsonrpc2.ID]context.CancelFunc{}}
+}
+
+// serveStdio runs the session on stdin and stdout until the client exits.
+func (d *dispatcher) serveStdio() {
+	stream := jsonrpc2.NewBufferedStream(stdio{}, jsonrpc2.VSCodeObjectCodec{})
+	<-jsonrpc2.NewConn(context.Background(), stream, d).DisconnectNotify()
+}
+
+// Handle implements jsonrpc2.Handler. The connection calls it for one
+// message at a time.
+func (d *dispatcher) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	switch req.Method {
+	case string(protocol.MethodCancelRequest):
+		d.cancel(req)
+		return
+	case string(protocol.MethodExit):
+		// The handler may see it, but the session ends either way.
+		_, _ = d.call(ctx, conn, req)
+		_ = conn.Close()
+		return
+	}
+	timeout, heavy := d.heavy[req.Method]
+	if req.Notif || !heavy {
+		result, err := d.call(ctx, conn, req)
+		d.reply(ctx, conn, req, result, err)
+		return
+	}
+
+	rctx, stop := context.WithTimeout(ctx, timeout)
+	d.mu.Lock()
+	d.inflight[req.ID] = stop
+	d.mu.Unlock()
+	go func() {
+		defer func() {
+			d.mu.Lock()
+			delete(d.inflight, req.ID)
+			d.mu.Unlock()
+			stop()
+		}()
+		type answer struct {
+			result any
+			err    error
+		}
+		done := make(chan answer, 1)
+		// The handlers cannot be interrupted; one that is cancelled or
+		// runs out of time finishes in the background and its answer is
+		// dropped.
+		go func() {
+			result, err := d.call(ctx, conn, req)
+			done <- answer{result, err}
+		}()
+		select {
+		case a := <-done:
+			d.reply(ctx, conn, req, a.result, a.err)
+		case <-rctx.Done():
+			if errors.Is(rctx.Err(), context.DeadlineExceeded) {
+				d.reply(ctx, conn, req, nil, &jsonrpc2.Error{
+					Code:    codeRequestFailed,
+					Message: fmt.Sprintf("%s timed out after %s", req.Method, timeout),
+				})
+			} else {
+				d.reply(ctx, conn, req, nil, &jsonrpc2.Error{Code: codeRequestCancelled, Message: "request cancelled"})
+			}
+		}
+	}()
+}
+
+// cancel stops waiting for the request a $/cancelRequest names. Requests
+// that are not running in the background have already been answered.
+func (d *dispatcher) cancel(req *jsonrpc2.Request) {
+	if req.Params == nil {
+		return
+	}
+	// Not protocol.CancelParams, whose id never decodes: jsonrpc2.ID reads
+	// both forms and is the key the request was stored under.
+	var params struct {
+		ID jsonrpc2.ID `json:"id"`
+	}
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return
+	}
+	d.mu.Lock()
+	stop := d.inflight[params.ID]
+	d.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+}
+
+// call runs the handler for req, turning a panic in it into an internal
+// error answer so the session goes on.
+func (d *dispatcher) call(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			e := ice.New("lsp "+req.Method, v)
+			ice.Report(e)
+			result, err = nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: e.Error()}
+		}
+	}()
+	gctx := &glsp.Context{
+		Method: req.Method,
+		Notify: func(method string, params any) {
+			if err := conn.Notify(ctx, method, params); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		},
+		Call: func(method string, params any, result any) {
+			if err := conn.Call(ctx, method, params, result); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		},
+	}
+	if req.Params != nil {
+		gctx.Params = *req.Params
+	}
+	r, validMethod, validParams, err := d.handler.Handle(gctx)
+	switch {
+	case !validMethod:
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeMethodNotFound, Message: "method not supported: " + req.Method}
+	case !validParams:
+		e := &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		if err != nil {
+			e.Message = err.Error()
+		}
+		return nil, e
+	case err != nil:
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: err.Error()}
+	}
+	return r, nil
+}
+
+func (d *dispatcher) reply(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, result any, err error) {
+	if req.Notif {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", req.Method, err)
+		}
+		return
+	}
+	var rerr error
+	if err != nil {
+		var e *jsonrpc2.Error
+		if !errors.As(err, &e) {
+			e = &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: err.Error()}
+		}
+		rerr = conn.ReplyWithError(ctx, req.ID, e)
+	} else {
+		rerr = conn.Reply(ctx, req.ID, result)
+	}
+	if rerr != nil && !errors.Is(rerr, jsonrpc2.ErrClosed) {
+		fmt.Fprintln(os.Stderr, rerr)
+	}
+}
+
+// stdio is the server's side of the connection.
+type stdio struct{}
+
+func (stdio) Read(p []byte) (int, error)  { return os.Stdin.Read(p) }
+func (stdio) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+
+func (stdio) Close() error {
+	if err := os.Stdin.Close(); err != nil {
+		return err
+	}
+	return os.Stdout.Close()
+}
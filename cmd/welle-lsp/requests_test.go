@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"welle/internal/ice"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/tliron/glsp"
+)
+
+type fakeHandler struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newFakeHandler() fakeHandler {
+	return fakeHandler{started: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (h fakeHandler) Handle(ctx *glsp.Context) (any, bool, bool, error) {
+	switch ctx.Method {
+	case "slow":
+		h.started <- struct{}{}
+		<-h.release
+		return "slow", true, true, nil
+	case "fast":
+		return "fast", true, true, nil
+	case "boom":
+		var m map[string]int
+		m["x"] = 1
+	}
+	return nil, false, true, nil
+}
+
+// connect serves h through a dispatcher and returns the client's end.
+func connect(t *testing.T, h glsp.Handler, heavy map[string]time.Duration) *jsonrpc2.Conn {
+	t.Helper()
+	server, client := net.Pipe()
+	ctx := context.Background()
+	jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(server, jsonrpc2.VSCodeObjectCodec{}), newDispatcher(h, heavy))
+	conn := jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}),
+		jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) { return nil, nil }))
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func callCode(conn *jsonrpc2.Conn, method string, opts ...jsonrpc2.CallOption) (string, int64, error) {
+	var result string
+	err := conn.Call(context.Background(), method, nil, &result, opts...)
+	var rpcErr *jsonrpc2.Error
+	if errors.As(err, &rpcErr) {
+		return "", rpcErr.Code, err
+	}
+	return result, 0, err
+}
+
+func TestDispatcherCancelsHeavyRequests(t *testing.T) {
+	h := newFakeHandler()
+	defer close(h.release)
+	conn := connect(t, h, map[string]time.Duration{"slow": time.Minute})
+
+	type outcome struct {
+		code int64
+		err  error
+	}
+	slow := make(chan outcome, 1)
+	go func() {
+		_, code, err := callCode(conn, "slow", jsonrpc2.PickID(jsonrpc2.ID{Num: 7}))
+		slow <- outcome{code, err}
+	}()
+	<-h.started
+	// The slow request does not hold up the ones after it.
+	if got, _, err := callCode(conn, "fast"); err != nil || got != "fast" {
+		t.Fatalf("fast = %q, %v", got, err)
+	}
+	if err := conn.Notify(context.Background(), "$/cancelRequest", map[string]any{"id": 7}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case o := <-slow:
+		if o.code != codeRequestCancelled {
+			t.Fatalf("cancelled request answered with %v, want code %d", o.err, codeRequestCancelled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled request was not answered")
+	}
+}
+
+func TestDispatcherTimesOutHeavyRequests(t *testing.T) {
+	h := newFakeHandler()
+	defer close(h.release)
+	conn := connect(t, h, map[string]time.Duration{"slow": 20 * time.Millisecond})
+
+	_, code, err := callCode(conn, "slow")
+	if code != codeRequestFailed || !strings.Contains(err.Error(), "slow timed out after 20ms") {
+		t.Fatalf("err = %v, want a timeout", err)
+	}
+}
+
+func TestDispatcherIsolatesPanics(t *testing.T) {
+	var reported []*ice.Error
+	defer ice.SetReporter(nil)
+	ice.SetReporter(func(e *ice.Error) { reported = append(reported, e) })
+	conn := connect(t, newFakeHandler(), nil)
+
+	_, code, err := callCode(conn, "boom")
+	if code != jsonrpc2.CodeInternalError || !strings.Contains(err.Error(), "internal error, please report, code WI0001 (lsp boom)") {
+		t.Fatalf("err = %v, want an internal error", err)
+	}
+	if len(reported) != 1 {
+		t.Fatalf("reported %d internal errors, want 1", len(reported))
+	}
+	if got, _, err := callCode(conn, "fast"); err != nil || got != "fast" {
+		t.Fatalf("after the panic, fast = %q, %v", got, err)
+	}
+	if _, code, _ := callCode(conn, "missing"); code != jsonrpc2.CodeMethodNotFound {
+		t.Fatalf("unknown method answered with code %d", code)
+	}
+}
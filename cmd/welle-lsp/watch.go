@@ -0,0 +1,75 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"welle/internal/lsp"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// workspaceRoot is the folder welle.toml is read from.
+var workspaceRoot = "."
+
+// watchFiles is set when the client can register file watchers for us.
+var watchFiles bool
+
+func clientCanWatchFiles(caps protocol.ClientCapabilities) bool {
+	w := caps.Workspace
+	if w == nil || w.DidChangeWatchedFiles == nil || w.DidChangeWatchedFiles.DynamicRegistration == nil {
+		return false
+	}
+	return *w.DidChangeWatchedFiles.DynamicRegistration
+}
+
+// registerFileWatchers asks the client to report changes to welle sources
+// and welle.toml made outside the editor (git checkouts, renames, other
+// tools). The request is sent from its own goroutine: the client answers
+// it on the same connection, which is busy until the current handler
+// returns.
+func registerFileWatchers(ctx *glsp.Context) {
+	params := protocol.RegistrationParams{
+		Registrations: []protocol.Registration{{
+			ID:     "welle-watched-files",
+			Method: string(protocol.MethodWorkspaceDidChangeWatchedFiles),
+			RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{
+				Watchers: []protocol.FileSystemWatcher{
+					{GlobPattern: "**/*.wll"},
+					{GlobPattern: "**/welle.toml"},
+				},
+			},
+		}},
+	}
+	go ctx.Call(protocol.ServerClientRegisterCapability, params, nil)
+}
+
+func workspaceDidChangeWatchedFiles(ctx *glsp.Context, params *protocol.DidChangeWatchedFilesParams) error {
+	reload := false
+	for _, change := range params.Changes {
+		pth := lsp.UriToPath(string(change.URI))
+		switch {
+		case filepath.Base(pth) == "welle.toml":
+			reload = true
+		case strings.HasSuffix(strings.ToLower(pth), ".wll"):
+			ws.InvalidatePath(pth)
+		}
+	}
+	if !reload {
+		return nil
+	}
+
+	ws.SetPrelude(loadPrelude(workspaceRoot))
+	lintOpts = loadLintOptions(workspaceRoot)
+	editorCfg = loadEditorConfig(workspaceRoot)
+	for _, uri := range store.URIs() {
+		if text, ok := store.Get(uri); ok {
+			pendingDiagnostics.cancel(uri)
+			if err := publishDiagnostics(ctx, uri, text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"welle/internal/lint"
+	"welle/internal/lsp"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestDidChangeWatchedFilesReindexesClosedFiles(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.wll")
+	if err := os.WriteFile(lib, []byte("export func old() { return 1 }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store = lsp.NewStore()
+	ws = lsp.NewWorkspace(dir)
+	defer func() { ws = nil }()
+
+	ix, err := ws.IndexPath(lib)
+	if err != nil || ix.Exports["old"].URI == "" {
+		t.Fatalf("expected old() to be indexed, got %v (%v)", ix, err)
+	}
+
+	if err := os.WriteFile(lib, []byte("export func renamed() { return 1 }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	params := &protocol.DidChangeWatchedFilesParams{Changes: []protocol.FileEvent{
+		{URI: protocol.DocumentUri(lsp.PathToURI(lib)), Type: protocol.FileChangeTypeChanged},
+	}}
+	if err := workspaceDidChangeWatchedFiles(&glsp.Context{}, params); err != nil {
+		t.Fatal(err)
+	}
+
+	ix, err = ws.IndexPath(lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ix.Exports["old"]; ok {
+		t.Fatalf("stale index survived the change event")
+	}
+	if _, ok := ix.Exports["renamed"]; !ok {
+		t.Fatalf("expected renamed() after the change event, got %v", ix.Exports)
+	}
+}
+
+func TestDidChangeWatchedFilesReloadsConfig(t *testing.T) {
+	dir := t.TempDir()
+	store = lsp.NewStore()
+	ws = lsp.NewWorkspace(dir)
+	workspaceRoot = dir
+	defer func() { ws, workspaceRoot, lintOpts = nil, ".", lint.DefaultOptions() }()
+
+	uri := lsp.PathToURI(filepath.Join(dir, "main.wll"))
+	store.Set(uri, "func f() {\n  a = 1\n  b = 2\n  return a + b\n}\n")
+	lintOpts = lint.DefaultOptions()
+
+	toml := filepath.Join(dir, "welle.toml")
+	if err := os.WriteFile(toml, []byte("[lint]\nmax_statements = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var published []protocol.Diagnostic
+	ctx := &glsp.Context{Notify: func(method string, params any) {
+		if p, ok := params.(*protocol.PublishDiagnosticsParams); ok && string(p.URI) == uri {
+			published = p.Diagnostics
+		}
+	}}
+	params := &protocol.DidChangeWatchedFilesParams{Changes: []protocol.FileEvent{
+		{URI: protocol.DocumentUri(lsp.PathToURI(toml)), Type: protocol.FileChangeTypeCreated},
+	}}
+	if err := workspaceDidChangeWatchedFiles(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	if lintOpts.MaxStatements != 1 {
+		t.Fatalf("expected welle.toml to be reloaded, got %+v", lintOpts)
+	}
+	if len(published) == 0 {
+		t.Fatalf("expected open documents to be re-linted")
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"welle/internal/module"
+)
+
+func runCache(args []string) {
+	if len(args) != 1 || (args[0] != "clean" && args[0] != "dir") {
+		fmt.Println("usage: welle cache clean | dir")
+		os.Exit(2)
+	}
+	dir, err := module.CacheDir()
+	if err != nil {
+		fmt.Println("cache error:", err)
+		os.Exit(1)
+	}
+	if args[0] == "dir" {
+		fmt.Println(dir)
+		return
+	}
+	if err := (&module.DiskCache{Dir: dir}).Clean(); err != nil {
+		fmt.Println("cache error:", err)
+		os.Exit(1)
+	}
+	fmt.Println("removed", dir)
+}
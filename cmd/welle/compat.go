@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"welle/internal/compiler"
+)
+
+// writeCompatReport lists the constructs that made `welle run` fall back
+// from the VM to the interpreter, one per line with its position when it
+// has one.
+func writeCompatReport(w io.Writer, err *compiler.UnsupportedError) {
+	fmt.Fprintln(w, "compat: running on the interpreter; the VM does not support:")
+	for _, u := range err.Constructs {
+		if u.Line == 0 {
+			fmt.Fprintf(w, "  %s: %s\n", u.File, u.Construct)
+			continue
+		}
+		fmt.Fprintf(w, "  %s:%d:%d: %s\n", u.File, u.Line, u.Col, u.Construct)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunFallsBackOnCompileFailures(t *testing.T) {
+	root := repoRoot(t)
+	dir := t.TempDir()
+	elems := make([]string, 5000)
+	for i := range elems {
+		elems[i] = "1"
+	}
+	files := map[string]string{
+		"big.wll": "xs = [" + strings.Join(elems, ", ") + "]\nprint(len(xs))\n",
+		"rec.wll": "func outer() {\n" +
+			"  func even(n) { if (n == 0) { return true }\n return odd(n - 1) }\n" +
+			"  func odd(n) { if (n == 0) { return false }\n return even(n - 1) }\n" +
+			"  return even(10)\n" +
+			"}\nprint(outer())\n",
+		"unknown.wll": "func never() { return missing }\nprint(1)\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	big := filepath.Join(dir, "big.wll")
+	rec := filepath.Join(dir, "rec.wll")
+	unknown := filepath.Join(dir, "unknown.wll")
+
+	cases := []struct {
+		path, stdout, report string
+	}{
+		{big, "5000", "  " + big + ": <main> needs 5000 value stack slots, more than the VM's 2048\n"},
+		{rec, "true", "  " + rec + ":3:9: forward reference to odd\n"},
+		{unknown, "1", "  " + unknown + ": compile error: unknown identifier: missing\n"},
+	}
+	for _, tc := range cases {
+		out, err := runWelle(root, "run", tc.path)
+		if err != nil || strings.TrimSpace(out) != tc.stdout {
+			t.Fatalf("%s: expected fallback run to print %s, got err=%v output: %s", tc.path, tc.stdout, err, out)
+		}
+		out, err = runWelle(root, "-compat", "run", tc.path)
+		if err != nil || !strings.Contains(out, tc.report) {
+			t.Fatalf("%s: expected compat report %q, got err=%v output: %s", tc.path, tc.report, err, out)
+		}
+	}
+
+	// A larger stack lets the VM run it.
+	out, err := runWelle(root, "-compat", "-max-stack", "8192", "run", big)
+	if err != nil || strings.Contains(out, "compat:") || strings.TrimSpace(out) != "5000" {
+		t.Fatalf("expected a VM run, got err=%v output: %s", err, out)
+	}
+}
+
+func TestRunFallsBackToInterpreter(t *testing.T) {
+	root := repoRoot(t)
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.wll": "import \"./lib.wll\" as lib\nfunc main() { print(helper(lib.go())) }\nfunc helper(x) { return x + 1 }\nmain()\n",
+		"lib.wll":  "export func go() { return twice(3) }\nfunc twice(x) { return x * 2 }\n",
+		"ok.wll":   "print(\"vm\")\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	main := filepath.Join(dir, "main.wll")
+
+	out, err := runWelle(root, "run", main)
+	if err != nil || strings.TrimSpace(out) != "7" {
+		t.Fatalf("expected fallback run to print 7, got err=%v output: %s", err, out)
+	}
+	out, err = runWelle(root, "-compat", "run", main)
+	want := "compat: running on the interpreter; the VM does not support:\n" +
+		"  " + main + ":2:21: forward reference to helper\n" +
+		"  " + filepath.Join(dir, "lib.wll") + ":1:27: forward reference to twice\n"
+	if err != nil || !strings.Contains(out, want) {
+		t.Fatalf("expected compat report %q, got err=%v output: %s", want, err, out)
+	}
+	out, err = runWelle(root, "-vm", "run", main)
+	if err == nil || !strings.Contains(out, "unknown identifier: helper") {
+		t.Fatalf("expected -vm to refuse, got err=%v output: %s", err, out)
+	}
+	out, err = runWelle(root, "-compat", "-stats", "run", filepath.Join(dir, "ok.wll"))
+	if err != nil || strings.Contains(out, "compat:") || !strings.Contains(out, "instructions: ") {
+		t.Fatalf("expected a plain VM run, got err=%v output: %s", err, out)
+	}
+	out, err = runWelle(root, "-vm", "-interp", "run", main)
+	if err == nil || !strings.Contains(out, "-vm and -interp cannot be used together") {
+		t.Fatalf("expected flag conflict, got err=%v output: %s", err, out)
+	}
+}
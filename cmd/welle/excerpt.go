@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"welle/internal/code"
+	"welle/internal/module"
+	"welle/internal/vm"
+)
+
+// printVMError prints a VM run error and, when the VM recorded where it was
+// raised, the source line with the offending expression underlined.
+func printVMError(err error) {
+	fmt.Println("vm error:", err)
+	var rerr *vm.RuntimeError
+	if errors.As(err, &rerr) {
+		fmt.Print(sourceExcerpt(rerr.Err.File, rerr.Err.Span))
+	}
+}
+
+// sourceExcerpt renders line span.Line of file with carets under the span,
+// up to the end of that line. It returns "" when the file or line cannot be
+// read.
+func sourceExcerpt(file string, span code.Span) string {
+	if file == "" || span.IsZero() {
+		return ""
+	}
+	b, err := module.ReadSource(file)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(string(b), "\n")
+	if span.Line > len(lines) {
+		return ""
+	}
+	line := strings.TrimRight(lines[span.Line-1], "\r")
+	start := min(max(span.Col-1, 0), len(line))
+	end := len(line)
+	if span.EndLine == span.Line {
+		end = min(max(span.EndCol-1, start+1), len(line))
+	}
+	// Keep tabs in the padding so the carets line up with the source.
+	var pad strings.Builder
+	for _, r := range line[:start] {
+		if r == '\t' {
+			pad.WriteByte('\t')
+		} else {
+			pad.WriteByte(' ')
+		}
+	}
+	width := max(len([]rune(line[start:end])), 1)
+	gutter := fmt.Sprintf("%d", span.Line)
+	return fmt.Sprintf("%s | %s\n%s | %s%s\n", gutter, line, strings.Repeat(" ", len(gutter)), pad.String(), strings.Repeat("^", width))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+
+	"welle/internal/gfx"
+	"welle/internal/object"
+)
+
+// gfxProgram is a gfx script as the loop drives it, whichever backend runs
+// it: load runs the top level, lookup finds its setup/update/draw hooks,
+// call runs one of them or a timer callback, and shutdown runs the imported
+// modules' __deinit hooks once the window has closed.
+type gfxProgram struct {
+	load     func() error
+	lookup   func(name string) object.Object
+	call     func(fn object.Object, args ...object.Object) error
+	shutdown func() error
+}
+
+func runGfx(p gfxProgram, opts gfx.Options) error {
+	var setupFn, updateFn, drawFn object.Object
+	callFn := func(fn object.Object, args ...object.Object) error {
+		if fn == nil {
+			return nil
+		}
+		return p.call(fn, args...)
+	}
+	err := gfx.Run(gfx.LoopFuncs{
+		Setup: func() error {
+			// Evaluate after gfx backend is active so top-level gfx calls work.
+			if err := p.load(); err != nil {
+				return err
+			}
+			setupFn = p.lookup("setup")
+			updateFn = p.lookup("update")
+			drawFn = p.lookup("draw")
+			return callFn(setupFn)
+		},
+		Update: func(dt float64) error {
+			return callFn(updateFn, &object.Float{Value: dt})
+		},
+		Draw: func() error {
+			return callFn(drawFn)
+		},
+		Call: func(fn any) error {
+			return callFn(fn.(object.Object))
+		},
+	}, opts)
+	if err != nil {
+		return err
+	}
+	return p.shutdown()
+}
+
+// errorResult turns an interpreter result into an error if it is one.
+func errorResult(res object.Object) error {
+	if res != nil && res.Type() == object.ERROR_OBJ {
+		return errors.New(res.Inspect())
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHTTPServerRoutes(t *testing.T) {
+	root := repoRoot(t)
+	script := filepath.Join(t.TempDir(), "server.wll")
+	// The script is its own client: the request is written with std:net
+	// before serve_n pulls it off the server's queue.
+	src := `import "std:net" as net
+import "std:httpserver" as http
+func hello(req) { return http.text("hi " + get(req.query, "name", "?")) }
+func boom(req) { throw "kaput" }
+func echo(req) { return http.response(201, req.body) }
+func hog(req) {
+  xs = []
+  while (true) { xs = push(xs, "xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx") }
+}
+routes = #{"GET /hello": hello, "/boom": boom, "POST /echo": echo, "/hog": hog}
+srv = http.listen_budget("127.0.0.1:0", 5000, 1048576, 100000, 1048576)
+func fetch(raw) {
+  c = net.connect(srv.addr)
+  net.send(c, raw)
+  http.serve_n(srv, routes, 1)
+  status = net.recv_line(c)
+  line = net.recv_line(c)
+  while (line != "") { line = net.recv_line(c) }
+  body = net.recv(c, 100)
+  net.close(c)
+  print(status, body)
+}
+fetch("GET /hello?name=ann HTTP/1.0\n\n")
+fetch("GET /boom HTTP/1.0\n\n")
+fetch("POST /echo HTTP/1.0\nContent-Length: 4\n\nping")
+fetch("GET /missing HTTP/1.0\n\n")
+fetch("GET /hog HTTP/1.0\n\n")
+fetch("GET /hello?name=bo HTTP/1.0\n\n")
+http.close(srv)
+`
+	if err := os.WriteFile(script, []byte(src), 0o644); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+	// A handler that fails, or runs out of its memory budget, gets a 500
+	// that does not say why, and the server goes on.
+	want := "HTTP/1.0 200 OK hi ann\n" +
+		"HTTP/1.0 500 Internal Server Error internal server error\n" +
+		"HTTP/1.0 201 Created ping\n" +
+		"HTTP/1.0 404 Not Found not found\n" +
+		"HTTP/1.0 500 Internal Server Error internal server error\n" +
+		"HTTP/1.0 200 OK hi bo\n"
+	for _, mode := range [][]string{{"-interp", "-allow-net"}, {"-vm", "-allow-net"}} {
+		out, err := runWelle(root, append(mode, "run", script)...)
+		if err != nil || out != want {
+			t.Fatalf("%v: unexpected result err=%v output: %q", mode, err, out)
+		}
+	}
+
+	// Only the VM counts instructions, so a handler that never returns is
+	// cut off there.
+	spin := filepath.Join(t.TempDir(), "spin.wll")
+	src = `import "std:net" as net
+import "std:httpserver" as http
+func spin(req) { while (true) {} }
+func hello(req) { return http.text("hi") }
+routes = #{"/spin": spin, "/hello": hello}
+srv = http.listen_budget("127.0.0.1:0", 5000, 1048576, 100000)
+func fetch(raw) {
+  c = net.connect(srv.addr)
+  net.send(c, raw)
+  http.serve_n(srv, routes, 1)
+  print(net.recv_line(c))
+  net.close(c)
+}
+fetch("GET /spin HTTP/1.0\n\n")
+fetch("GET /hello HTTP/1.0\n\n")
+http.close(srv)
+`
+	if err := os.WriteFile(spin, []byte(src), 0o644); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+	want = "HTTP/1.0 500 Internal Server Error\nHTTP/1.0 200 OK\n"
+	out, err := runWelle(root, "-vm", "-allow-net", "run", spin)
+	if err != nil || out != want {
+		t.Fatalf("unexpected result err=%v output: %q", err, out)
+	}
+}
@@ -60,6 +60,34 @@ func TestMaxMemCLIOverridesManifest(t *testing.T) {
 	}
 }
 
+func TestMaxFramesFromManifestAndCLI(t *testing.T) {
+	root := repoRoot(t)
+	project := t.TempDir()
+
+	manifest := strings.Join([]string{
+		`entry = "main.wll"`,
+		`std_root = ` + quote(filepath.Join(root, "std")),
+		`max_frames = 30`,
+		"",
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(project, "welle.toml"), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	src := "func f(self, n) { if (n == 0) { return 0 } return self(self, n - 1) }\nprint(f(f, 50))\n"
+	if err := os.WriteFile(filepath.Join(project, "main.wll"), []byte(src), 0o644); err != nil {
+		t.Fatalf("write main: %v", err)
+	}
+
+	out, err := runWelle(root, "-vm", "run", project)
+	if err == nil || !strings.Contains(out, "stack overflow: call depth exceeds 30 frames") {
+		t.Fatalf("expected frame limit error, got err=%v output: %s", err, out)
+	}
+	out, err = runWelle(root, "-vm", "-max-frames", "100", "run", project)
+	if err != nil || strings.TrimSpace(out) != "0" {
+		t.Fatalf("expected 0, got err=%v output: %s", err, out)
+	}
+}
+
 func runWelle(root string, args ...string) (string, error) {
 	allArgs := append([]string{"run", "./cmd/welle"}, args...)
 	cmd := exec.Command("go", allArgs...)
@@ -80,3 +108,88 @@ func repoRoot(t *testing.T) string {
 func quote(s string) string {
 	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
 }
+
+func TestModuleLimitsFromManifest(t *testing.T) {
+	root := repoRoot(t)
+	project := t.TempDir()
+
+	manifest := strings.Join([]string{
+		`entry = "main.wll"`,
+		`std_root = ` + quote(filepath.Join(root, "std")),
+		``,
+		`[limits."vendor"]`,
+		`max_steps = 200`,
+		``,
+		`[limits."vendor/big.wll"]`,
+		`max_mem = 300`,
+		"",
+	}, "\n")
+	files := map[string]string{
+		"welle.toml":      manifest,
+		"vendor/busy.wll": "n = 0\nwhile (n < 1000) { n = n + 1 }\n",
+		"vendor/big.wll":  "s = \"\"\nfor (i = 0; i < 50; i = i + 1) { s = s + \"xxxxxxxxxx\" }\n",
+		"main.wll":        "s = \"\"\nfor (i = 0; i < 50; i = i + 1) { s = s + \"xxxxxxxxxx\" }\nprint(\"main ok\")\n",
+		"busy_main.wll":   "import \"vendor/busy.wll\"\nprint(\"unreachable\")\n",
+		"big_main.wll":    "import \"vendor/big.wll\"\nprint(\"unreachable\")\n",
+	}
+	for name, src := range files {
+		path := filepath.Join(project, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	// The limits do not apply to the project's own code.
+	for _, mode := range [][]string{{"-interp", "run"}, {"-vm", "run"}} {
+		out, err := runWelle(root, append(mode, project)...)
+		if err != nil || !strings.Contains(out, "main ok") {
+			t.Fatalf("%v: unexpected result err=%v output: %s", mode, err, out)
+		}
+	}
+
+	out, err := runWelle(root, "-vm", "run", filepath.Join(project, "busy_main.wll"))
+	if err == nil || !strings.Contains(out, "max instruction count exceeded (200)") {
+		t.Fatalf("expected module step limit, got err=%v output: %s", err, out)
+	}
+	for _, mode := range [][]string{{"-interp", "run"}, {"-vm", "run"}} {
+		out, err := runWelle(root, append(mode, filepath.Join(project, "big_main.wll"))...)
+		if err == nil || !strings.Contains(out, "max memory exceeded (300 bytes)") {
+			t.Fatalf("%v: expected module memory limit, got err=%v output: %s", mode, err, out)
+		}
+	}
+}
+
+func TestParseLimitsFromManifestAndCLI(t *testing.T) {
+	root := repoRoot(t)
+	project := t.TempDir()
+
+	manifest := strings.Join([]string{
+		`entry = "main.wll"`,
+		`std_root = ` + quote(filepath.Join(root, "std")),
+		`max_parse_depth = 20`,
+		"",
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(project, "welle.toml"), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	src := "print(" + strings.Repeat("(", 30) + "1" + strings.Repeat(")", 30) + ")\n"
+	if err := os.WriteFile(filepath.Join(project, "main.wll"), []byte(src), 0o644); err != nil {
+		t.Fatalf("write main: %v", err)
+	}
+
+	out, err := runWelle(root, "run", project)
+	if err == nil || !strings.Contains(out, "nesting too deep: more than 20 levels") {
+		t.Fatalf("expected depth limit error, got err=%v output: %s", err, out)
+	}
+	out, err = runWelle(root, "-max-parse-depth", "100", "run", project)
+	if err != nil || strings.TrimSpace(out) != "1" {
+		t.Fatalf("expected 1, got err=%v output: %s", err, out)
+	}
+	out, err = runWelle(root, "-max-source-size", "10", "run", project)
+	if err == nil || !strings.Contains(out, "source too large: 69 bytes, limit is 10") {
+		t.Fatalf("expected size limit error, got err=%v output: %s", err, out)
+	}
+}
@@ -1,30 +1,46 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
+	"welle/internal/ast"
 	"welle/internal/compiler"
 	"welle/internal/config"
 	"welle/internal/diag"
+	"welle/internal/docs"
 	"welle/internal/evaluator"
 	"welle/internal/format"
 	"welle/internal/format/astfmt"
 	"welle/internal/gfx"
+	"welle/internal/heapdump"
+	"welle/internal/ice"
 	"welle/internal/lexer"
+	"welle/internal/limits"
 	"welle/internal/lint"
+	"welle/internal/lsp"
 	"welle/internal/module"
 	"welle/internal/object"
 	"welle/internal/parser"
 	"welle/internal/repl"
+	"welle/internal/rewrite"
+	"welle/internal/runtimeio"
 	"welle/internal/token"
 	"welle/internal/tools"
+	"welle/internal/trace"
+	"welle/internal/upgrade"
+	"welle/internal/vm"
 )
 
 func main() {
@@ -40,6 +56,26 @@ func main() {
 		runLint(os.Args[2:])
 		return
 	}
+	if len(os.Args) > 1 && os.Args[1] == "ast" {
+		runAST(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "doc" {
+		runDoc(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rewrite" {
+		runRewrite(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "upgrade" {
+		runUpgrade(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "query" {
+		runQuery(os.Args[2:])
+		return
+	}
 	if len(os.Args) > 1 && os.Args[1] == "tools" {
 		runTools(os.Args[2:])
 		return
@@ -48,27 +84,51 @@ func main() {
 		runTest(os.Args[2:])
 		return
 	}
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		runCache(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "notebook" {
+		runNotebook(os.Args[2:])
+		return
+	}
 
 	tokensMode := flag.Bool("tokens", false, "print tokens instead of running")
 	astMode := flag.Bool("ast", false, "print AST instead of running")
-	vmMode := flag.Bool("vm", false, "run using bytecode VM")
+	jsonMode := flag.Bool("json", false, "print -tokens/-ast output as JSON")
+	vmMode := flag.Bool("vm", false, "run using bytecode VM, without falling back to the interpreter")
+	interpMode := flag.Bool("interp", false, "run using the tree-walking interpreter")
+	compatMode := flag.Bool("compat", false, "report the constructs that made run fall back to the interpreter")
 	disMode := flag.Bool("dis", false, "dump bytecode instructions and constants")
 	optMode := flag.Bool("O", false, "enable bytecode optimizer")
+	warnMode := flag.Bool("W", false, "print compiler warnings (VM only)")
+	werror := flag.Bool("werror", false, "treat compiler warnings as errors (VM only)")
 	maxRecursion := flag.Int("max-recursion", -1, "max recursion depth (0 = unlimited)")
 	maxSteps := flag.Int64("max-steps", -1, "max VM instruction count (0 = unlimited)")
 	maxMem := flag.Int64("max-mem", -1, "max memory allocation in bytes (0 = unlimited)")
 	maxMemory := flag.Int64("max-memory", -1, "max memory allocation in bytes (0 = unlimited)")
+	maxStack := flag.Int("max-stack", -1, "max VM value stack slots (0 = default 2048)")
+	maxFrames := flag.Int("max-frames", -1, "max VM call frames (0 = default 1024)")
+	maxParseDepth := flag.Int("max-parse-depth", -1, "max nesting of statements and expressions in source (0 = default 1000)")
+	maxSourceSize := flag.Int("max-source-size", -1, "max size of a source file in bytes (0 = default 16 MiB)")
+	releaseMode := flag.Bool("release", false, "skip assert statements (compiled out in VM mode)")
+	featuresFlag := flag.String("features", "", "comma-separated build features to enable, replacing welle.toml's features")
+	allowFS := flag.Bool("allow-fs", false, "let scripts open files on disk (std:sqlite)")
+	allowNet := flag.Bool("allow-net", false, "let scripts open network sockets (std:net, std:httpserver)")
+	allowExec := flag.Bool("allow-exec", false, "let scripts run other programs (std:proc)")
+	traceMode := flag.Bool("trace", false, "trace each statement (or VM instruction) to stderr")
+	traceOut := flag.String("trace-out", "", "write the trace to this file instead of stderr")
+	traceFiles := flag.String("trace-files", "", "only trace code in these comma-separated files")
+	traceLocals := flag.Bool("trace-locals", false, "show each function's parameter values in stack traces")
+	traceFuncs := flag.String("trace-funcs", "", "only trace these comma-separated functions (<main> for top level)")
+	statsMode := flag.Bool("stats", false, "print steps, memory, allocations, module load times and wall time to stderr after the run")
+	heapDump := flag.String("heap-dump", "", "write a JSON snapshot of the objects the program still reaches to this file after the run")
 	flag.Parse()
 
 	cwd, err := os.Getwd()
 	if err != nil {
 		cwd = "."
 	}
-	defaultStdRoot := filepath.Join(cwd, "std")
-	if abs, err := filepath.Abs(defaultStdRoot); err == nil {
-		defaultStdRoot = abs
-	}
-
 	args := flag.Args()
 	if len(args) == 0 {
 		recLimit, stepLimit, memLimit, err := resolveLimits(*maxRecursion, *maxSteps, *maxMem, *maxMemory, nil)
@@ -76,10 +136,23 @@ func main() {
 			fmt.Println("repl error:", err)
 			os.Exit(1)
 		}
-		repl.Start(os.Stdin, os.Stdout, defaultStdRoot, repl.Limits{
+		stackLimit, frameLimit, err := resolveVMSizes(*maxStack, *maxFrames, nil)
+		if err != nil {
+			fmt.Println("repl error:", err)
+			os.Exit(1)
+		}
+		parseLimits, err := resolveParseLimits(*maxParseDepth, *maxSourceSize, nil)
+		if err != nil {
+			fmt.Println("repl error:", err)
+			os.Exit(1)
+		}
+		parser.SetLimits(parseLimits)
+		repl.Start(os.Stdin, os.Stdout, replStdRoot(cwd), repl.Limits{
 			MaxRecursion: recLimit,
 			MaxSteps:     stepLimit,
 			MaxMemory:    memLimit,
+			MaxStack:     stackLimit,
+			MaxFrames:    frameLimit,
 		})
 		return
 	}
@@ -91,9 +164,11 @@ func main() {
 		cmdArgs = args
 	}
 
+	var gfxOpts gfx.Options
 	var entrySpec string
 	var projectRoot string
 	var manifest *config.Manifest
+	var scriptArgs []string
 	switch cmd {
 	case "repl":
 		if *tokensMode || *astMode || *disMode {
@@ -109,21 +184,28 @@ func main() {
 			fmt.Println("repl error:", err)
 			os.Exit(1)
 		}
-		repl.Start(os.Stdin, os.Stdout, defaultStdRoot, repl.Limits{
+		stackLimit, frameLimit, err := resolveVMSizes(*maxStack, *maxFrames, nil)
+		if err != nil {
+			fmt.Println("repl error:", err)
+			os.Exit(1)
+		}
+		parseLimits, err := resolveParseLimits(*maxParseDepth, *maxSourceSize, nil)
+		if err != nil {
+			fmt.Println("repl error:", err)
+			os.Exit(1)
+		}
+		parser.SetLimits(parseLimits)
+		repl.Start(os.Stdin, os.Stdout, replStdRoot(cwd), repl.Limits{
 			MaxRecursion: recLimit,
 			MaxSteps:     stepLimit,
 			MaxMemory:    memLimit,
+			MaxStack:     stackLimit,
+			MaxFrames:    frameLimit,
 		})
 		return
 	case "run":
-		if len(cmdArgs) > 1 {
-			fmt.Println("usage: welle run [pathOrSpec]")
-			os.Exit(1)
-		}
-		target := "."
-		if len(cmdArgs) == 1 {
-			target = cmdArgs[0]
-		}
+		var target string
+		target, scriptArgs = splitScriptArgs(cmdArgs)
 		var err error
 		entrySpec, projectRoot, manifest, err = resolveRunTarget(target)
 		if err != nil {
@@ -131,18 +213,20 @@ func main() {
 			os.Exit(1)
 		}
 	case "gfx":
-		if *tokensMode || *astMode || *disMode || *vmMode {
-			fmt.Println("gfx does not support -tokens, -ast, -dis, or -vm")
+		if *tokensMode || *astMode || *disMode {
+			fmt.Println("gfx does not support -tokens, -ast, or -dis")
 			os.Exit(1)
 		}
-		if len(cmdArgs) > 1 {
-			fmt.Println("usage: welle gfx [pathOrSpec]")
+		if *statsMode || *heapDump != "" {
+			fmt.Println("gfx does not support -stats or -heap-dump")
 			os.Exit(1)
 		}
-		target := "."
-		if len(cmdArgs) == 1 {
-			target = cmdArgs[0]
-		}
+		gfxFlags := flag.NewFlagSet("gfx", flag.ExitOnError)
+		gfxFlags.StringVar(&gfxOpts.RecordPath, "record", "", "record the run to this GIF file, then exit")
+		gfxFlags.Float64Var(&gfxOpts.RecordSeconds, "seconds", 5, "how many seconds of loop time -record captures")
+		gfxFlags.Parse(cmdArgs)
+		var target string
+		target, scriptArgs = splitScriptArgs(gfxFlags.Args())
 		var err error
 		entrySpec, projectRoot, manifest, err = resolveRunTarget(target)
 		if err != nil {
@@ -154,25 +238,29 @@ func main() {
 		os.Exit(1)
 	}
 
-	resolver, err := buildResolver(cwd, projectRoot, manifest)
+	parseLimits, err := resolveParseLimits(*maxParseDepth, *maxSourceSize, manifest)
 	if err != nil {
-		fmt.Println("resolver error:", err)
-		os.Exit(1)
-	}
-	loader := module.NewLoader(resolver)
-	recLimit, stepLimit, memLimit, err := resolveLimits(*maxRecursion, *maxSteps, *maxMem, *maxMemory, manifest)
-	if err != nil {
-		fmt.Println("run error:", err)
+		fmt.Println(cmd+" error:", err)
 		os.Exit(1)
 	}
+	parser.SetLimits(parseLimits)
 
 	entryFrom := filepath.Join(cwd, "__entry.wll")
 
+	// Dumps only need the entry file; skip building the resolver for them
+	// when the target is a path.
 	if *tokensMode || *astMode {
-		entryPath, err := resolver.Resolve(entryFrom, entrySpec)
-		if err != nil {
-			fmt.Println("resolve error:", err)
-			os.Exit(1)
+		entryPath := entrySpec
+		if !filepath.IsAbs(entryPath) || !isFile(entryPath) {
+			resolver, err := buildResolver(cwd, projectRoot, manifest)
+			if err != nil {
+				fmt.Println("resolver error:", err)
+				os.Exit(1)
+			}
+			if entryPath, err = resolver.Resolve(entryFrom, entrySpec); err != nil {
+				fmt.Println("resolve error:", err)
+				os.Exit(1)
+			}
 		}
 		b, err := os.ReadFile(entryPath)
 		if err != nil {
@@ -182,42 +270,117 @@ func main() {
 		src := string(b)
 
 		if *tokensMode {
-			l := lexer.New(src)
-			for {
-				tok := l.NextToken()
-				fmt.Printf("%4d:%-3d  %-10s  %q\n", tok.Line, tok.Col, tok.Type, tok.Literal)
-				if tok.Type == token.EOF {
-					break
-				}
-			}
+			dumpTokens(src, *jsonMode)
 			return
 		}
-
-		l := lexer.New(src)
-		p := parser.New(l)
-		program := p.ParseProgram()
-
-		if len(p.Errors()) > 0 {
-			for _, e := range p.Errors() {
-				fmt.Println("parse error:", e)
-			}
+		if !dumpAST(src, *jsonMode) {
 			os.Exit(1)
 		}
-
-		fmt.Println(program.String())
 		return
 	}
 
+	start := time.Now()
+	resolver, err := buildResolver(cwd, projectRoot, manifest)
+	if err != nil {
+		fmt.Println("resolver error:", err)
+		os.Exit(1)
+	}
+	loader := module.NewLoader(resolver)
+	release := *releaseMode || (manifest != nil && manifest.Release)
+	loader.Release = release
+	features := resolveFeatures(*featuresFlag, manifest, cmd == "gfx")
+	loader.Features = features
+	loader.DiskCache = module.DefaultDiskCache()
+	recLimit, stepLimit, memLimit, err := resolveLimits(*maxRecursion, *maxSteps, *maxMem, *maxMemory, manifest)
+	if err != nil {
+		fmt.Println("run error:", err)
+		os.Exit(1)
+	}
+	stackLimit, frameLimit, err := resolveVMSizes(*maxStack, *maxFrames, manifest)
+	if err != nil {
+		fmt.Println("run error:", err)
+		os.Exit(1)
+	}
+	moduleLimits, err := resolveModuleLimits(projectRoot, manifest)
+	if err != nil {
+		fmt.Println("run error:", err)
+		os.Exit(1)
+	}
+
+	runtimeio.SetArgs(entrySpec, scriptArgs)
+	runtimeio.SetAllowFS(*allowFS)
+	runtimeio.SetAllowNet(*allowNet)
+	runtimeio.SetAllowExec(*allowExec)
+
+	tracer, err := buildTracer(*traceMode, *traceOut, *traceFiles, *traceFuncs)
+	if err != nil {
+		fmt.Println("run error:", err)
+		os.Exit(1)
+	}
+
 	if *disMode {
 		*vmMode = true
 	}
+	if *vmMode && *interpMode {
+		fmt.Println("-vm and -interp cannot be used together")
+		os.Exit(1)
+	}
+	// run defaults to the VM; gfx keeps the interpreter unless -vm is given.
+	useVM := *vmMode || (cmd == "run" && !*interpMode)
 
-	if *vmMode {
-		bc, entryPath, err := loader.LoadBytecode(entryFrom, entrySpec, *optMode)
-		if err != nil {
+	if (*warnMode || *werror) && !useVM {
+		fmt.Println("-W and -werror require the VM")
+		os.Exit(1)
+	}
+
+	var stats *limits.Stats
+	if *statsMode {
+		stats = limits.NewStats()
+		loader.Stats = stats
+	}
+	budget := limits.NewBudget(memLimit)
+	heapdump.Track(*heapDump != "")
+
+	handleInterrupts()
+
+	var bc *compiler.Bytecode
+	var vmEntryPath string
+	warnings := 0
+	if useVM {
+		if *warnMode || *werror {
+			loader.OnWarning = func(path string, d diag.Diagnostic) {
+				warnings++
+				fmt.Fprintln(os.Stderr, d.Format(path))
+			}
+		}
+		var err error
+		if *vmMode {
+			bc, vmEntryPath, err = loader.LoadBytecode(entryFrom, entrySpec, *optMode)
+		} else {
+			loader.MaxStack = stackLimit
+			if loader.MaxStack == 0 {
+				loader.MaxStack = vm.StackSize
+			}
+			bc, vmEntryPath, err = loader.LoadProgram(entryFrom, entrySpec, *optMode)
+		}
+		var unsupported *compiler.UnsupportedError
+		if err != nil && !*vmMode && errors.As(err, &unsupported) {
+			if *compatMode {
+				writeCompatReport(os.Stderr, unsupported)
+			}
+			useVM = false
+		} else if err != nil {
 			fmt.Println("load error:", err)
 			os.Exit(1)
 		}
+	}
+
+	if useVM {
+		entryPath := vmEntryPath
+		if *werror && warnings > 0 {
+			fmt.Printf("load error: %d warning(s) treated as errors\n", warnings)
+			os.Exit(1)
+		}
 		if *disMode {
 			fmt.Print(compiler.FormatConstants(bc.Constants))
 			fmt.Println()
@@ -228,9 +391,53 @@ func main() {
 		m := loader.NewVM(bc, entryPath)
 		m.SetMaxRecursion(recLimit)
 		m.SetMaxSteps(stepLimit)
-		m.SetMaxMemory(memLimit)
-		if err := m.Run(); err != nil {
-			fmt.Println("vm error:", err)
+		m.SetBudget(budget)
+		m.SetMaxStack(stackLimit)
+		m.SetMaxFrames(frameLimit)
+		m.SetModuleLimits(moduleLimits)
+		m.SetTracer(tracer)
+		m.SetTraceLocals(*traceLocals)
+		if cmd == "gfx" {
+			err := runGfx(gfxProgram{
+				load: m.Run,
+				lookup: func(name string) object.Object {
+					v, _ := m.Global(name)
+					return v
+				},
+				call: func(fn object.Object, args ...object.Object) error {
+					res, err := m.Call(fn, args...)
+					if err != nil {
+						return err
+					}
+					return errorResult(res)
+				},
+				shutdown: m.Shutdown,
+			}, gfxOpts)
+			if err != nil {
+				fmt.Println("gfx error:", err)
+				os.Exit(failureStatus())
+			}
+			return
+		}
+		m.SetStats(stats)
+		err = m.Run()
+		if err == nil {
+			err = m.Shutdown()
+		}
+		if stats != nil {
+			stats.Write(os.Stderr, "instructions", budget, time.Since(start))
+		}
+		dumped := *heapDump == "" || writeHeapDump(*heapDump, m.HeapRoots(), budget)
+		if err != nil {
+			printVMError(err)
+			os.Exit(failureStatus())
+		}
+		if !dumped {
+			os.Exit(1)
+		}
+		if *werror && warnings > 0 {
+			// Modules imported at run time are compiled lazily.
+			fmt.Printf("vm error: %d warning(s) treated as errors\n", warnings)
 			os.Exit(1)
 		}
 		return
@@ -246,72 +453,128 @@ func main() {
 		runner := evaluator.NewRunner()
 		runner.SetMaxRecursion(recLimit)
 		runner.SetMaxMemory(memLimit)
+		runner.SetModuleLimits(moduleLimits)
+		runner.SetTracer(tracer)
+		runner.SetTraceLocals(*traceLocals)
+		runner.SetRelease(release)
+		runner.SetFeatures(features)
 		runner.SetResolver(resolver)
 		runner.EnableImports()
 		var env *object.Environment
-		var setupFn object.Object
-		var updateFn object.Object
-		var drawFn object.Object
-		getFn := func(name string) object.Object {
-			if env == nil {
-				return nil
-			}
-			if v, ok := env.Get(name); ok {
-				return v
-			}
-			return nil
-		}
-
-		callFn := func(fn object.Object, args ...object.Object) error {
-			if fn == nil {
-				return nil
-			}
-			res := runner.Call(fn, args...)
-			if res != nil && res.Type() == object.ERROR_OBJ {
-				return errors.New(res.Inspect())
-			}
-			return nil
-		}
-
-		err := gfx.Run(gfx.LoopFuncs{
-			Setup: func() error {
-				// Evaluate after gfx backend is active so top-level gfx calls work.
+		err := runGfx(gfxProgram{
+			load: func() error {
 				var res object.Object
 				env, res = runner.RunFileEnv(entryPath)
-				if res != nil && res.Type() == object.ERROR_OBJ {
-					return errors.New(res.Inspect())
+				return errorResult(res)
+			},
+			lookup: func(name string) object.Object {
+				if v, ok := env.Get(name); ok {
+					return v
 				}
-				setupFn = getFn("setup")
-				updateFn = getFn("update")
-				drawFn = getFn("draw")
-				return callFn(setupFn)
+				return nil
 			},
-			Update: func(dt float64) error {
-				return callFn(updateFn, &object.Float{Value: dt})
+			call: func(fn object.Object, args ...object.Object) error {
+				return errorResult(runner.Call(fn, args...))
 			},
-			Draw: func() error {
-				return callFn(drawFn)
+			shutdown: func() error {
+				return errorResult(runner.Shutdown())
 			},
-		})
+		}, gfxOpts)
 		if err != nil {
 			fmt.Println("gfx error:", err)
-			os.Exit(1)
+			os.Exit(failureStatus())
 		}
 		return
 	}
 
 	runner := evaluator.NewRunner()
 	runner.SetMaxRecursion(recLimit)
-	runner.SetMaxMemory(memLimit)
+	runner.SetBudget(budget)
+	runner.SetStats(stats)
+	runner.SetModuleLimits(moduleLimits)
+	runner.SetTracer(tracer)
+	runner.SetTraceLocals(*traceLocals)
+	runner.SetRelease(release)
+	runner.SetFeatures(features)
 	runner.SetResolver(resolver)
 	runner.EnableImports()
 	res := runner.RunFile(entryPath)
+	if res == nil || res.Type() != object.ERROR_OBJ {
+		res = runner.Shutdown()
+	}
+	if stats != nil {
+		stats.Write(os.Stderr, "statements", budget, time.Since(start))
+	}
+	dumped := *heapDump == "" || writeHeapDump(*heapDump, runner.HeapRoots(), budget)
 	if res != nil && res.Type() == object.ERROR_OBJ {
-		fmt.Println(res.Inspect())
+		if errObj, ok := res.(*object.Error); ok && (errObj.Code == limits.InterruptCode || *traceLocals) && errObj.Stack != "" {
+			fmt.Print(errObj.Stack)
+		} else {
+			fmt.Println(res.Inspect())
+		}
+		os.Exit(failureStatus())
+	}
+	if !dumped {
 		os.Exit(1)
 	}
 }
 
+// writeHeapDump writes a snapshot of what roots reach to path for
+// -heap-dump, after the run whether or not it failed: a run that hit its
+// memory limit is what a dump is usually for.
+func writeHeapDump(path string, roots []heapdump.Root, budget *limits.Budget) bool {
+	out, err := heapdump.Take(roots, budget).JSON()
+	if err == nil {
+		err = os.WriteFile(path, out, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "heap dump error:", err)
+		return false
+	}
+	return true
+}
+
+// handleInterrupts turns the first Ctrl-C into an interrupt that the running
+// program raises at its next safe point, unwinding through finally blocks
+// and defers. A second Ctrl-C exits at once, for a program stuck in a
+// blocking call such as input().
+func handleInterrupts() {
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt)
+	go func() {
+		<-sigs
+		limits.Interrupt()
+		<-sigs
+		os.Exit(130)
+	}()
+}
+
+// failureStatus is the exit status of a failed run: 130, as shells report
+// for SIGINT, when it ended on an interrupt, and 1 otherwise.
+func failureStatus() int {
+	if limits.Interrupted() {
+		return 130
+	}
+	return 1
+}
+
+// splitScriptArgs splits the words after `welle run` into the target (`.`
+// when omitted) and the arguments passed to the script. A leading `--`
+// runs the default target; otherwise `--` after the target is dropped.
+func splitScriptArgs(words []string) (string, []string) {
+	if len(words) == 0 {
+		return ".", nil
+	}
+	if words[0] == "--" {
+		return ".", words[1:]
+	}
+	rest := words[1:]
+	if len(rest) > 0 && rest[0] == "--" {
+		rest = rest[1:]
+	}
+	return words[0], rest
+}
+
 func isPathSpec(spec string) bool {
 	if strings.HasPrefix(spec, "std:") {
 		return false
@@ -400,6 +663,20 @@ func findManifest(start string) (string, *config.Manifest, error) {
 	return "", nil, nil
 }
 
+// replStdRoot locates std for the REPL, which has no project.
+func replStdRoot(cwd string) string {
+	root, err := module.LocateStd("", cwd, filepath.Join(cwd, "std"))
+	if err != nil {
+		root, _ = filepath.Abs(filepath.Join(cwd, "std"))
+	}
+	return root
+}
+
+func isFile(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && !fi.IsDir()
+}
+
 func buildResolver(cwd, projectRoot string, man *config.Manifest) (*module.Resolver, error) {
 	baseRoot := cwd
 	if projectRoot != "" {
@@ -410,14 +687,24 @@ func buildResolver(cwd, projectRoot string, man *config.Manifest) (*module.Resol
 		defaultStdRoot = abs
 	}
 
-	stdRoot := defaultStdRoot
+	stdSetting := ""
 	modulePaths := []string{}
 	if man != nil && projectRoot != "" {
-		var err error
-		stdRoot, modulePaths, err = man.ResolvePaths(projectRoot, defaultStdRoot)
+		root, paths, err := man.ResolvePaths(projectRoot, defaultStdRoot)
 		if err != nil {
 			return nil, err
 		}
+		modulePaths = paths
+		switch {
+		case man.Std != "":
+			stdSetting = man.Std
+		case man.StdRoot != "":
+			stdSetting = root
+		}
+	}
+	stdRoot, err := module.LocateStd(stdSetting, baseRoot, defaultStdRoot)
+	if err != nil {
+		return nil, err
 	}
 
 	extraPaths := append([]string{}, modulePaths...)
@@ -426,7 +713,29 @@ func buildResolver(cwd, projectRoot string, man *config.Manifest) (*module.Resol
 		extraPaths = append(extraPaths, projectRoot)
 	}
 
-	return module.NewResolver(stdRoot, extraPaths), nil
+	res := module.NewResolver(stdRoot, extraPaths)
+	if man != nil && projectRoot != "" && man.Prelude != "" {
+		prelude := man.Prelude
+		if !filepath.IsAbs(prelude) {
+			prelude = filepath.Join(projectRoot, prelude)
+		}
+		if !isFile(prelude) {
+			return nil, fmt.Errorf("prelude %s not found", prelude)
+		}
+		if err := res.SetPrelude(prelude, projectRoot); err != nil {
+			return nil, err
+		}
+	}
+	if man != nil && projectRoot != "" && man.Edition != "" {
+		e, err := parser.ParseEdition(man.Edition)
+		if err != nil {
+			return nil, fmt.Errorf("welle.toml: %w", err)
+		}
+		if err := res.SetEdition(e, projectRoot); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
 }
 
 func resolveLimits(cliRec int, cliSteps int64, cliMem int64, cliMemAlt int64, man *config.Manifest) (int, int64, int64, error) {
@@ -471,6 +780,113 @@ func resolveLimits(cliRec int, cliSteps int64, cliMem int64, cliMemAlt int64, ma
 	return rec, steps, mem, nil
 }
 
+// resolveFeatures picks the enabled build features: the -features flag when
+// given, otherwise the manifest's. welle gfx always enables "gfx".
+func resolveFeatures(cli string, man *config.Manifest, gfx bool) []string {
+	var features []string
+	if cli != "" {
+		for _, name := range strings.Split(cli, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				features = append(features, name)
+			}
+		}
+	} else if man != nil {
+		features = append(features, man.Features...)
+	}
+	if gfx && !slices.Contains(features, "gfx") {
+		features = append(features, "gfx")
+	}
+	return features
+}
+
+// resolveVMSizes picks the VM stack and frame caps from the flags (-1 when
+// unset) or the manifest; 0 means the VM default.
+func resolveVMSizes(cliStack, cliFrames int, man *config.Manifest) (int, int, error) {
+	if cliStack < -1 {
+		return 0, 0, fmt.Errorf("max-stack must be >= 0")
+	}
+	if cliFrames < -1 {
+		return 0, 0, fmt.Errorf("max-frames must be >= 0")
+	}
+	stack := 0
+	if cliStack >= 0 {
+		stack = cliStack
+	} else if man != nil {
+		stack = man.MaxStack
+	}
+	frames := 0
+	if cliFrames >= 0 {
+		frames = cliFrames
+	} else if man != nil {
+		frames = man.MaxFrames
+	}
+	return stack, frames, nil
+}
+
+// resolveParseLimits picks the parser's depth and size limits from the flags
+// (-1 when unset) or the manifest; 0 keeps the parser's default.
+func resolveParseLimits(cliDepth, cliSize int, man *config.Manifest) (parser.Limits, error) {
+	if cliDepth < -1 {
+		return parser.Limits{}, fmt.Errorf("max-parse-depth must be >= 0")
+	}
+	if cliSize < -1 {
+		return parser.Limits{}, fmt.Errorf("max-source-size must be >= 0")
+	}
+	lim := parser.DefaultLimits
+	if cliDepth > 0 {
+		lim.MaxDepth = cliDepth
+	} else if cliDepth < 0 && man != nil && man.MaxParseDepth > 0 {
+		lim.MaxDepth = man.MaxParseDepth
+	}
+	if cliSize > 0 {
+		lim.MaxSourceBytes = cliSize
+	} else if cliSize < 0 && man != nil && man.MaxSourceSize > 0 {
+		lim.MaxSourceBytes = man.MaxSourceSize
+	}
+	return lim, nil
+}
+
+// resolveModuleLimits turns the manifest's [limits."path"] sections into
+// rules keyed by absolute path.
+func resolveModuleLimits(projectRoot string, man *config.Manifest) ([]limits.ModuleLimits, error) {
+	if man == nil {
+		return nil, nil
+	}
+	rules := make([]limits.ModuleLimits, 0, len(man.ModuleLimits))
+	for _, ml := range man.ModuleLimits {
+		p := ml.Path
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(projectRoot, p)
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, limits.ModuleLimits{Path: abs, MaxSteps: ml.MaxSteps, MaxMem: ml.MaxMem})
+	}
+	return rules, nil
+}
+
+// buildTracer returns the tracer for the -trace flags, or nil when none is
+// set. Filters and -trace-out also apply to tracing a program starts itself
+// with trace(true).
+func buildTracer(on bool, out, files, funcs string) (*trace.Tracer, error) {
+	if !on && out == "" && files == "" && funcs == "" {
+		return nil, nil
+	}
+	t := trace.Stderr()
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return nil, fmt.Errorf("trace-out: %w", err)
+		}
+		t = trace.New(f)
+	}
+	t.SetFilters(trace.ParseList(files), trace.ParseList(funcs))
+	t.SetEnabled(on)
+	return t, nil
+}
+
 func runInit(args []string) {
 	fs := flag.NewFlagSet("init", flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
@@ -586,13 +1002,372 @@ func formatWithMode(src []byte, indent string, useAST bool) (string, error) {
 	return format.Format(string(src), format.Options{Indent: indent})
 }
 
+func runAST(args []string) {
+	fs := flag.NewFlagSet("ast", flag.ContinueOnError)
+	jsonMode := fs.Bool("json", false, "print JSON instead of source-like text")
+	tokensMode := fs.Bool("tokens", false, "dump lexer tokens instead of the syntax tree")
+	sexpMode := fs.Bool("sexp", false, "print the tree as a tree-sitter style S-expression")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		fmt.Println("usage: welle ast [-json] [-tokens] [-sexp] <file>")
+		os.Exit(2)
+	}
+	b, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Println("read error:", err)
+		os.Exit(1)
+	}
+	if *tokensMode {
+		dumpTokens(string(b), *jsonMode)
+		return
+	}
+	if *sexpMode {
+		p := parser.New(lexer.New(string(b)))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			for _, e := range p.Errors() {
+				fmt.Println("parse error:", e)
+			}
+			os.Exit(1)
+		}
+		fmt.Println(ast.SExpr(program))
+		return
+	}
+	if !dumpAST(string(b), *jsonMode) {
+		os.Exit(1)
+	}
+}
+
+// jsonToken is the -tokens -json record for one lexer token.
+type jsonToken struct {
+	Type    string `json:"type"`
+	Literal string `json:"literal"`
+	Raw     string `json:"raw,omitempty"`
+	Line    int    `json:"line"`
+	Col     int    `json:"col"`
+}
+
+func dumpTokens(src string, asJSON bool) {
+	l := lexer.New(src)
+	var toks []jsonToken
+	for {
+		tok := l.NextToken()
+		if asJSON {
+			toks = append(toks, jsonToken{Type: string(tok.Type), Literal: tok.Literal, Raw: tok.Raw, Line: tok.Line, Col: tok.Col})
+		} else {
+			fmt.Printf("%4d:%-3d  %-10s  %q\n", tok.Line, tok.Col, tok.Type, tok.Literal)
+		}
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+	if asJSON {
+		out, _ := json.MarshalIndent(toks, "", "  ")
+		fmt.Println(string(out))
+	}
+}
+
+// dumpAST prints the parsed program and reports whether parsing succeeded.
+func dumpAST(src string, asJSON bool) bool {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		for _, e := range p.Errors() {
+			fmt.Println("parse error:", e)
+		}
+		return false
+	}
+	if !asJSON {
+		fmt.Println(program.String())
+		return true
+	}
+	raw, err := ast.MarshalJSON(program)
+	if err != nil {
+		fmt.Println("ast error:", err)
+		return false
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, raw, "", "  "); err != nil {
+		fmt.Println("ast error:", err)
+		return false
+	}
+	fmt.Println(out.String())
+	return true
+}
+
+func runRewrite(args []string) {
+	fs := flag.NewFlagSet("rewrite", flag.ContinueOnError)
+	write := fs.Bool("w", false, "write changes to files instead of printing a diff")
+	if err := fs.Parse(args); err != nil || fs.NArg() < 2 {
+		fmt.Println("usage: welle rewrite [-w] <pattern> <replacement> [file|dir...]")
+		os.Exit(2)
+	}
+	rule, err := rewrite.Compile(fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		fmt.Println("rewrite error:", err)
+		os.Exit(2)
+	}
+	targets := fs.Args()[2:]
+	if len(targets) == 0 {
+		targets = []string{"."}
+	}
+	files, err := collectWelleFiles(targets)
+	if err != nil {
+		fmt.Println("rewrite error:", err)
+		os.Exit(1)
+	}
+	sort.Strings(files)
+
+	hadErrors := false
+	total, changed := 0, 0
+	for _, path := range files {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Println("rewrite error:", err)
+			hadErrors = true
+			continue
+		}
+		out, n, err := rule.Apply(string(b))
+		if err != nil {
+			fmt.Printf("rewrite error: %s: %v\n", path, err)
+			hadErrors = true
+			continue
+		}
+		if n == 0 {
+			continue
+		}
+		total += n
+		changed++
+		if !*write {
+			fmt.Print(rewrite.Diff(path, string(b), out))
+			continue
+		}
+		if err := os.WriteFile(path, []byte(out), 0o644); err != nil {
+			fmt.Println("rewrite error:", err)
+			hadErrors = true
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d rewrite(s) in %d file(s)\n", total, changed)
+	if hadErrors {
+		os.Exit(1)
+	}
+}
+
+func runUpgrade(args []string) {
+	fs := flag.NewFlagSet("upgrade", flag.ContinueOnError)
+	write := fs.Bool("w", false, "write changes to files instead of printing a diff")
+	toFlag := fs.String("to", parser.LatestEdition.String(), "edition to upgrade to")
+	if err := fs.Parse(args); err != nil {
+		fmt.Println("usage: welle upgrade [-w] [--to EDITION] [file|dir...]")
+		os.Exit(2)
+	}
+	to, err := parser.ParseEdition(*toFlag)
+	if err != nil {
+		fmt.Println("upgrade error:", err)
+		os.Exit(2)
+	}
+	targets := fs.Args()
+	if len(targets) == 0 {
+		targets = []string{"."}
+	}
+
+	// Files without a #welle pragma are the project's edition, or written
+	// before editions existed.
+	from := parser.Editions[0]
+	start := targets[0]
+	if isFile(start) {
+		start = filepath.Dir(start)
+	}
+	projectRoot, man, err := findManifest(start)
+	if err != nil {
+		fmt.Println("upgrade error:", err)
+		os.Exit(1)
+	}
+	if man != nil && man.Edition != "" {
+		if from, err = parser.ParseEdition(man.Edition); err != nil {
+			fmt.Println("upgrade error: welle.toml:", err)
+			os.Exit(1)
+		}
+		if to.Before(from) {
+			fmt.Printf("upgrade error: welle.toml is edition %s, newer than %s\n", from, to)
+			os.Exit(1)
+		}
+	}
+
+	files, err := collectWelleFiles(targets)
+	if err != nil {
+		fmt.Println("upgrade error:", err)
+		os.Exit(1)
+	}
+	sort.Strings(files)
+
+	hadErrors := false
+	total, changed := 0, 0
+	apply := func(path, before, after string) {
+		if !*write {
+			fmt.Print(rewrite.Diff(path, before, after))
+			return
+		}
+		if err := os.WriteFile(path, []byte(after), 0o644); err != nil {
+			fmt.Println("upgrade error:", err)
+			hadErrors = true
+		}
+	}
+	for _, path := range files {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Println("upgrade error:", err)
+			hadErrors = true
+			continue
+		}
+		out, changes, err := upgrade.File(string(b), from, to)
+		if err != nil {
+			fmt.Printf("upgrade error: %s: %v\n", path, err)
+			hadErrors = true
+			continue
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		for _, c := range changes {
+			fmt.Fprintf(os.Stderr, "%s: %s (%d)\n", path, c.Why, c.Count)
+			total += c.Count
+		}
+		changed++
+		apply(path, string(b), out)
+	}
+
+	if man != nil && from != to {
+		path := filepath.Join(projectRoot, "welle.toml")
+		if cwd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(cwd, path); err == nil {
+				path = rel
+			}
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Println("upgrade error:", err)
+			os.Exit(1)
+		}
+		if out, ok := upgrade.Manifest(string(b), to); ok {
+			fmt.Fprintf(os.Stderr, "%s: edition %s -> %s (1)\n", path, from, to)
+			total++
+			changed++
+			apply(path, string(b), out)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d change(s) in %d file(s)\n", total, changed)
+	if hadErrors {
+		os.Exit(1)
+	}
+}
+
+func runDoc(args []string) {
+	if len(args) != 1 {
+		fmt.Println("usage: welle doc <builtin> | std:<module> | std:<module>.<name>")
+		os.Exit(2)
+	}
+	text, err := docs.Lookup(args[0])
+	if err != nil {
+		fmt.Println("doc error:", err)
+		os.Exit(1)
+	}
+	fmt.Print(text)
+}
+
+func runQuery(args []string) {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	root := fs.String("root", ".", "project directory to index")
+	usage := func() {
+		fmt.Println("usage: welle query [-root dir] exports | calls | callers <name> | callees <name> | unused")
+		os.Exit(2)
+	}
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		usage()
+	}
+	kind := fs.Arg(0)
+	name := ""
+	switch kind {
+	case "exports", "calls", "unused":
+		if fs.NArg() != 1 {
+			usage()
+		}
+	case "callers", "callees":
+		if fs.NArg() != 2 {
+			usage()
+		}
+		name = fs.Arg(1)
+	default:
+		usage()
+	}
+
+	// Indexing runs the language server's analysis over every file; a bug
+	// it trips is reported as an internal error, not a Go stack.
+	defer func() {
+		if v := recover(); v != nil {
+			ice.Report(ice.New("query", v))
+			os.Exit(1)
+		}
+	}()
+
+	absRoot, err := filepath.Abs(*root)
+	if err != nil {
+		fmt.Println("query error:", err)
+		os.Exit(1)
+	}
+	q, err := lsp.NewProjectQuery(lsp.NewWorkspace(absRoot))
+	if err != nil {
+		fmt.Println("query error:", err)
+		os.Exit(1)
+	}
+	rel := func(path string) string {
+		if r, err := filepath.Rel(absRoot, path); err == nil && !strings.HasPrefix(r, "..") {
+			return filepath.ToSlash(r)
+		}
+		return path
+	}
+	symbol := func(s lsp.QuerySymbol) string {
+		if s.Line == 0 {
+			return s.Path + "." + s.Name
+		}
+		return fmt.Sprintf("%s (%s:%d)", s.Name, rel(s.Path), s.Line)
+	}
+
+	switch kind {
+	case "exports", "unused":
+		syms := q.Exports()
+		if kind == "unused" {
+			syms = q.Unused()
+		}
+		for _, s := range syms {
+			sig := ""
+			if s.Kind == "func" {
+				sig = "(" + strings.Join(s.Params, ", ") + ")"
+			}
+			fmt.Printf("%s:%d:%d: %s %s%s\n", rel(s.Path), s.Line, s.Col, s.Kind, s.Name, sig)
+		}
+	default:
+		for _, c := range q.Calls() {
+			switch {
+			case kind == "callers" && c.Callee.Name != name:
+				continue
+			case kind == "callees" && c.Caller != name:
+				continue
+			}
+			fmt.Printf("%s:%d:%d: %s -> %s\n", rel(c.Path), c.Line, c.Col, c.Caller, symbol(c.Callee))
+		}
+	}
+}
+
 func runLint(args []string) {
-	if len(args) == 0 {
-		fmt.Println("usage: welle lint <file|dir> [more...]")
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fix := fs.Bool("fix", false, "apply safe fixes and write the files back")
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		fmt.Println("usage: welle lint [--fix] <file|dir> [more...]")
 		os.Exit(2)
 	}
 
-	files, err := collectWelleFiles(args)
+	files, err := collectWelleFiles(fs.Args())
 	if err != nil {
 		fmt.Println("lint error:", err)
 		os.Exit(1)
@@ -603,8 +1378,27 @@ func runLint(args []string) {
 	sort.Strings(files)
 
 	hadErrors := false
+	fixes, fixedFiles := 0, 0
 	for _, path := range files {
-		diags, err := lintFile(path)
+		opts, err := lintOptionsFor(path)
+		if err != nil {
+			fmt.Println("lint error:", err)
+			hadErrors = true
+			continue
+		}
+		if *fix {
+			n, err := fixFile(path, opts)
+			if err != nil {
+				fmt.Println("lint error:", err)
+				hadErrors = true
+				continue
+			}
+			if n > 0 {
+				fixes += n
+				fixedFiles++
+			}
+		}
+		diags, err := lintFile(path, opts)
 		if err != nil {
 			fmt.Println("lint error:", err)
 			hadErrors = true
@@ -617,15 +1411,45 @@ func runLint(args []string) {
 			}
 		}
 	}
+	if *fix {
+		fmt.Fprintf(os.Stderr, "%d fix(es) in %d file(s)\n", fixes, fixedFiles)
+	}
 
 	if hadErrors {
 		os.Exit(1)
 	}
 }
 
+// fixFile applies the safe lint fixes to path, prints each one, and writes
+// the file back atomically if anything changed. Unreachable code goes first,
+// so names used only there are reported unused, and the unused-name fixes
+// report positions in the file as it is after that removal.
+func fixFile(path string, opts lint.Options) (int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	text, unreachable := lsp.RemoveUnreachable(string(b), opts)
+	text, unused := lsp.FixUnused(text, opts)
+	if text == string(b) {
+		return 0, nil
+	}
+	if err := writeFileAtomic(path, []byte(text)); err != nil {
+		return 0, err
+	}
+	for _, d := range append(unreachable, unused...) {
+		fmt.Printf("%s:%d:%d: fixed %s: %s\n", path, d.Range.Line, d.Range.Col, d.Code, d.Message)
+	}
+	return len(unused) + len(unreachable), nil
+}
+
 func runTools(args []string) {
+	if len(args) > 0 && args[0] == "gen-vscode" {
+		runGenVSCode(args[1:])
+		return
+	}
 	if len(args) == 0 || args[0] != "install" {
-		fmt.Println("usage: welle tools install [--bin <dir>]")
+		fmt.Println("usage: welle tools install [--bin <dir>] | gen-vscode [--lsp <path>] [--force] <dir>")
 		os.Exit(2)
 	}
 
@@ -644,7 +1468,49 @@ func runTools(args []string) {
 	fmt.Printf("installed: %s, %s\n", filepath.Join(*binDir, "welle"), filepath.Join(*binDir, "welle-lsp"))
 }
 
-func lintFile(path string) ([]diag.Diagnostic, error) {
+func runGenVSCode(args []string) {
+	fs := flag.NewFlagSet("tools gen-vscode", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	lspPath := fs.String("lsp", "", "welle-lsp binary to bundle with the extension")
+	force := fs.Bool("force", false, "write into a non-empty directory")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		fmt.Println("usage: welle tools gen-vscode [--lsp <path>] [--force] <dir>")
+		os.Exit(2)
+	}
+
+	dir := fs.Arg(0)
+	if err := tools.GenVSCode(tools.VSCodeOptions{Dir: dir, LSPPath: *lspPath, Force: *force}); err != nil {
+		fmt.Println("gen-vscode error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("wrote VS Code extension to %s (run npm install there, then sideload it)\n", dir)
+}
+
+// lintOptionsFor applies the `[lint]` section of the nearest welle.toml
+// above path, if any, and resolves path's imports the way a run would so
+// calls to their deprecated functions are flagged.
+func lintOptionsFor(path string) (lint.Options, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return lint.Options{}, err
+	}
+	root, man, err := findManifest(filepath.Dir(abs))
+	if err != nil {
+		return lint.Options{}, err
+	}
+	opts := lint.OptionsFromManifest(man)
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = filepath.Dir(abs)
+	}
+	// Without a resolver (say, std is missing) imports are just not checked.
+	if resolver, err := buildResolver(cwd, root, man); err == nil {
+		opts.ModuleDeprecations = resolver.ImportDeprecations(abs)
+	}
+	return opts, nil
+}
+
+func lintFile(path string, opts lint.Options) ([]diag.Diagnostic, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -654,9 +1520,9 @@ func lintFile(path string) ([]diag.Diagnostic, error) {
 	prog := p.ParseProgram()
 	diags := append([]diag.Diagnostic{}, p.Diagnostics()...)
 	if prog != nil {
-		diags = append(diags, lint.Run(prog)...)
+		diags = append(diags, lint.RunWithOptions(prog, opts)...)
 	}
-	return diags, nil
+	return diag.Dedup(diags), nil
 }
 
 func collectWelleFiles(targets []string) ([]string, error) {
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"welle/internal/code"
+	"welle/internal/lint"
 )
 
 func TestFormatWithMode_ASTToggle(t *testing.T) {
@@ -24,3 +29,110 @@ func TestFormatWithMode_ASTToggle(t *testing.T) {
 		t.Fatalf("ast formatter should preserve comments, got: %q", outAST)
 	}
 }
+
+func TestLintOptionsFromManifest(t *testing.T) {
+	project := t.TempDir()
+	manifest := strings.Join([]string{
+		`entry = "main.wll"`,
+		``,
+		`[lint]`,
+		`max_statements = 1`,
+		"",
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(project, "welle.toml"), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	src := "func f() {\n  print(1)\n  print(2)\n}\nf()\n"
+	path := filepath.Join(project, "main.wll")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatalf("write main: %v", err)
+	}
+
+	opts, err := lintOptionsFor(path)
+	if err != nil {
+		t.Fatalf("lint options: %v", err)
+	}
+	if opts.MaxStatements != 1 || !opts.CheckShadowing {
+		t.Fatalf("unexpected options: %#v", opts)
+	}
+	diags, err := lintFile(path, opts)
+	if err != nil {
+		t.Fatalf("lint: %v", err)
+	}
+	found := false
+	for _, d := range diags {
+		if d.Code == "WL0012" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected WL0012 from [lint] config, got %#v", diags)
+	}
+}
+
+func TestFixFileWritesSafeFixes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.wll")
+	src := "func f(a, b) {\n  return a\n  print(b)\n}\nf(1, 2)\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatalf("write main: %v", err)
+	}
+
+	n, err := fixFile(path, lint.DefaultOptions())
+	if err != nil {
+		t.Fatalf("fix: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 fixes, got %d", n)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read main: %v", err)
+	}
+	if want := "func f(a, _b) {\n  return a\n}\nf(1, 2)\n"; string(got) != want {
+		t.Fatalf("unexpected file:\n%s", got)
+	}
+	if n, err := fixFile(path, lint.DefaultOptions()); err != nil || n != 0 {
+		t.Fatalf("fixing twice should change nothing, got %d, %v", n, err)
+	}
+}
+
+func TestSourceExcerptUnderlinesSpan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.wll")
+	if err := os.WriteFile(path, []byte("a = [1]\n\tprint(a[10])\n"), 0o644); err != nil {
+		t.Fatalf("write main: %v", err)
+	}
+	got := sourceExcerpt(path, code.Span{Line: 2, Col: 10, EndLine: 2, EndCol: 12})
+	want := "2 | \tprint(a[10])\n  | \t        ^^\n"
+	if got != want {
+		t.Fatalf("unexpected excerpt:\n%q\nwant:\n%q", got, want)
+	}
+	if got := sourceExcerpt(path, code.Span{}); got != "" {
+		t.Fatalf("expected no excerpt without a span, got %q", got)
+	}
+}
+
+func TestOptimizedErrorUnderlinesOperands(t *testing.T) {
+	root := repoRoot(t)
+	path := filepath.Join(t.TempDir(), "main.wll")
+	src := "func f(a) {\n  x = \"a\"\n  if (a < 1) { return 0 }\n  x = x + 1\n}\nf(5)\nf(\"s\")\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatalf("write main: %v", err)
+	}
+	// f(5) fails in the fused x += 1 and f("s") would fail in the fused
+	// comparison; both must underline what the unfused code does.
+	for _, args := range [][]string{{"-vm"}, {"-vm", "-O"}} {
+		out, err := runWelle(root, append(args, "run", path)...)
+		if err == nil || !strings.Contains(out, "4 |   x = x + 1\n  |       ^^^^^\n") {
+			t.Fatalf("%v: expected x + 1 underlined, got err=%v output: %s", args, err, out)
+		}
+	}
+	if err := os.WriteFile(path, []byte(strings.Replace(src, "f(5)\n", "", 1)), 0o644); err != nil {
+		t.Fatalf("write main: %v", err)
+	}
+	for _, args := range [][]string{{"-vm"}, {"-vm", "-O"}} {
+		out, err := runWelle(root, append(args, "run", path)...)
+		if err == nil || !strings.Contains(out, "3 |   if (a < 1) { return 0 }\n  |       ^^^^^\n") {
+			t.Fatalf("%v: expected a < 1 underlined, got err=%v output: %s", args, err, out)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"welle/internal/notebook"
+	"welle/internal/repl"
+	"welle/internal/runtimeio"
+)
+
+func runNotebook(args []string) {
+	fs := flag.NewFlagSet("notebook", flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	addr := fs.String("addr", "127.0.0.1:8888", "address to serve the notebook on")
+	maxSteps := fs.Int64("max-steps", -1, "max VM instruction count per cell (0 = unlimited)")
+	allowFS := fs.Bool("allow-fs", false, "let cells open files on disk")
+	allowNet := fs.Bool("allow-net", false, "let cells open network sockets")
+	allowExec := fs.Bool("allow-exec", false, "let cells run other programs")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		fmt.Println("usage: welle notebook [--addr host:port] [--max-steps n] [--allow-fs] [--allow-net] [--allow-exec]")
+		os.Exit(2)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "."
+	}
+	_, man, err := findManifest(cwd)
+	if err != nil {
+		fmt.Println("notebook error:", err)
+		os.Exit(1)
+	}
+	recLimit, stepLimit, memLimit, err := resolveLimits(-1, *maxSteps, -1, -1, man)
+	if err != nil {
+		fmt.Println("notebook error:", err)
+		os.Exit(1)
+	}
+	stackLimit, frameLimit, err := resolveVMSizes(-1, -1, man)
+	if err != nil {
+		fmt.Println("notebook error:", err)
+		os.Exit(1)
+	}
+	runtimeio.SetAllowFS(*allowFS)
+	runtimeio.SetAllowNet(*allowNet)
+	runtimeio.SetAllowExec(*allowExec)
+
+	session := repl.NewSession("", repl.Limits{
+		MaxRecursion: recLimit,
+		MaxSteps:     stepLimit,
+		MaxMemory:    memLimit,
+		MaxStack:     stackLimit,
+		MaxFrames:    frameLimit,
+	})
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Println("notebook error:", err)
+		os.Exit(1)
+	}
+	srv := notebook.New(session)
+	fmt.Printf("welle notebook on http://%s/?token=%s (Ctrl+C to stop)\n", ln.Addr(), srv.Token())
+	if err := http.Serve(ln, srv); err != nil {
+		fmt.Println("notebook error:", err)
+		os.Exit(1)
+	}
+}
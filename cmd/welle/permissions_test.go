@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAllowFSFlag(t *testing.T) {
+	root := repoRoot(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "db.wll")
+	src := "import \"std:sqlite\" as sqlite\n" +
+		"try {\n" +
+		"  db = sqlite.open(" + quote(filepath.Join(dir, "data.db")) + ")\n" +
+		"  sqlite.exec(db, \"CREATE TABLE t (a INTEGER)\", [])\n" +
+		"  sqlite.exec(db, \"INSERT INTO t VALUES (?)\", [21])\n" +
+		"  print(sqlite.query(db, \"SELECT a * 2 AS b FROM t\", [])[0].b)\n" +
+		"} catch (e) { print(e.message) }\n"
+	if err := os.WriteFile(script, []byte(src), 0o644); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+
+	out, err := runWelle(root, "-interp", "run", script)
+	if err != nil || !strings.Contains(out, "needs file system access (run with -allow-fs)") {
+		t.Fatalf("expected capability error, got err=%v output: %s", err, out)
+	}
+	// With the capability granted, the SQLite driver welle links writes
+	// the database file.
+	out, err = runWelle(root, "-vm", "-allow-fs", "run", script)
+	if err != nil || strings.TrimSpace(out) != "42" {
+		t.Fatalf("expected 42 with -allow-fs, got err=%v output: %s", err, out)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "data.db")); err != nil {
+		t.Fatalf("expected the database file: %v", err)
+	}
+}
+
+func TestAllowNetFlag(t *testing.T) {
+	root := repoRoot(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "echo.wll")
+	src := `import "std:net" as net
+srv = net.listen("127.0.0.1:0")
+net.set_timeout(srv, 2000)
+conn = net.connect_timeout(srv.addr, 2000)
+peer = net.accept(srv)
+net.send_line(conn, "ping")
+print(net.recv_line(peer))
+net.close(conn)
+print(net.recv_line(peer))
+net.close(peer)
+net.close(srv)
+`
+	if err := os.WriteFile(script, []byte(src), 0o644); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+
+	out, err := runWelle(root, "run", script)
+	if err == nil || !strings.Contains(out, "network access is disabled (run with -allow-net)") {
+		t.Fatalf("expected capability error, got err=%v output: %s", err, out)
+	}
+	for _, mode := range [][]string{{"-interp", "-allow-net"}, {"-vm", "-allow-net"}} {
+		out, err := runWelle(root, append(mode, "run", script)...)
+		if err != nil || out != "ping\nnil\n" {
+			t.Fatalf("%v: unexpected result err=%v output: %q", mode, err, out)
+		}
+	}
+}
+
+func TestAllowExecFlag(t *testing.T) {
+	root := repoRoot(t)
+	script := filepath.Join(t.TempDir(), "proc.wll")
+	src := `import "std:proc" as proc
+r = proc.run("sh", ["-c", "echo hi; exit 3"], nil)
+print(r.code, r.stdout)
+p = proc.spawn("cat", [], nil)
+proc.write(p, "line\n")
+print(proc.read_line(p))
+print(proc.wait(p))
+`
+	if err := os.WriteFile(script, []byte(src), 0o644); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+
+	out, err := runWelle(root, "run", script)
+	if err == nil || !strings.Contains(out, "running commands is disabled (run with -allow-exec)") {
+		t.Fatalf("expected capability error, got err=%v output: %s", err, out)
+	}
+	for _, mode := range [][]string{{"-interp", "-allow-exec"}, {"-vm", "-allow-exec"}} {
+		out, err := runWelle(root, append(mode, "run", script)...)
+		if err != nil || out != "3 hi\n\nline\n0\n" {
+			t.Fatalf("%v: unexpected result err=%v output: %q", mode, err, out)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReleaseFlagAndManifest(t *testing.T) {
+	root := repoRoot(t)
+	project := t.TempDir()
+	src := "assert 1 > 2, \"boom\"\nprint(\"done\")\n"
+	if err := os.WriteFile(filepath.Join(project, "main.wll"), []byte(src), 0o644); err != nil {
+		t.Fatalf("write main: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(project, "welle.toml"), []byte("entry = \"main.wll\"\n"), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+
+	for _, args := range [][]string{{"-interp", "run", project}, {"-vm", "run", project}} {
+		out, err := runWelle(root, args...)
+		if err == nil || !strings.Contains(out, "assertion failed: 1 > 2: boom") {
+			t.Fatalf("%v: expected assertion failure, got err=%v output: %s", args, err, out)
+		}
+		out, err = runWelle(root, append([]string{"-release"}, args...)...)
+		if err != nil || strings.TrimSpace(out) != "done" {
+			t.Fatalf("%v -release: expected done, got err=%v output: %s", args, err, out)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(project, "welle.toml"), []byte("entry = \"main.wll\"\nrelease = true\n"), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	out, err := runWelle(root, "-vm", "run", project)
+	if err != nil || strings.TrimSpace(out) != "done" {
+		t.Fatalf("manifest release: expected done, got err=%v output: %s", err, out)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunPassesScriptArgs(t *testing.T) {
+	root := repoRoot(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "echo.wll")
+	if err := os.WriteFile(script, []byte("print(args())\n"), 0o644); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+
+	for _, engine := range [][]string{{"-interp"}, {"-vm"}} {
+		out, err := runWelle(root, append(engine, "run", script, "a", "-v", "--", "b")...)
+		if err != nil || strings.TrimSpace(out) != "[a, -v, --, b]" {
+			t.Fatalf("%v: expected args passed through, got err=%v output: %s", engine, err, out)
+		}
+		out, err = runWelle(root, append(engine, "run", script, "--", "x")...)
+		if err != nil || strings.TrimSpace(out) != "[x]" {
+			t.Fatalf("%v: expected leading -- to be dropped, got err=%v output: %s", engine, err, out)
+		}
+	}
+}
+
+func TestInterruptExitStatus(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGINT cannot be sent to a child process on windows")
+	}
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "welle")
+	build := exec.Command("go", "build", "-o", bin, ".")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("build welle: %v\n%s", err, out)
+	}
+	script := filepath.Join(dir, "main.wll")
+	src := "try {\n  print(\"ready\")\n  while (true) { }\n} catch (e) {\n  print(\"caught\")\n} finally {\n  print(\"cleanup\")\n}\n"
+	if err := os.WriteFile(script, []byte(src), 0o644); err != nil {
+		t.Fatalf("write main: %v", err)
+	}
+
+	for _, mode := range [][]string{{"-interp", "run"}, {"-vm", "run"}} {
+		cmd := exec.Command(bin, append(mode, script)...)
+		cmd.Env = append(os.Environ(), "WELLE_HOME="+filepath.Join(dir, "home"))
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		r := bufio.NewReader(stdout)
+		if line, err := r.ReadString('\n'); err != nil || line != "ready\n" {
+			t.Fatalf("%v: expected ready, got %q (%v)", mode, line, err)
+		}
+		if err := cmd.Process.Signal(os.Interrupt); err != nil {
+			t.Fatal(err)
+		}
+		rest, _ := io.ReadAll(r)
+		err = cmd.Wait()
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 130 {
+			t.Fatalf("%v: expected exit status 130, got %v\n%s", mode, err, rest)
+		}
+		out := string(rest)
+		if strings.Contains(out, "caught") || !strings.Contains(out, "cleanup") || !strings.Contains(out, "error: interrupted\nstack trace:") {
+			t.Fatalf("%v: unexpected output:\n%s", mode, out)
+		}
+	}
+}
+
+func TestStatsReport(t *testing.T) {
+	root := repoRoot(t)
+	script := filepath.Join(t.TempDir(), "main.wll")
+	src := "xs = [1, 2]\nname = \"a\" + \"b\"\nprint(name)\n"
+	if err := os.WriteFile(script, []byte(src), 0o644); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+
+	for _, tc := range []struct {
+		mode  []string
+		steps string
+	}{
+		{[]string{"-interp", "-stats", "-max-mem", "100000"}, "statements: 3\n"},
+		{[]string{"-vm", "-stats", "-max-mem", "100000"}, "instructions: "},
+	} {
+		out, err := runWelle(root, append(tc.mode, "run", script)...)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v\noutput: %s", tc.mode, err, out)
+		}
+		for _, want := range []string{"ab\n", "== stats ==\n", "wall time: ", tc.steps, "bytes (", "of 100000 bytes", "  array ", "  string ", "modules: 1\n", script} {
+			if !strings.Contains(out, want) {
+				t.Fatalf("%v: expected %q in output:\n%s", tc.mode, want, out)
+			}
+		}
+	}
+}
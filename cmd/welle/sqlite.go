@@ -0,0 +1,5 @@
+package main
+
+// std:sqlite reaches SQLite through database/sql; this links the pure-Go
+// driver, so the binary needs no C toolchain or system library.
+import _ "modernc.org/sqlite"
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// BenchmarkStartup runs the welle binary on tiny inputs, so the time is
+// dominated by process start, package initialization and command setup:
+//
+//	go test -run '^$' -bench Startup ./cmd/welle
+func BenchmarkStartup(b *testing.B) {
+	dir := b.TempDir()
+	bin := filepath.Join(dir, "welle")
+	build := exec.Command("go", "build", "-o", bin, ".")
+	if out, err := build.CombinedOutput(); err != nil {
+		b.Fatalf("build welle: %v\n%s", err, out)
+	}
+	script := filepath.Join(dir, "main.wll")
+	if err := os.WriteFile(script, []byte("x = 1\nprint(x)\n"), 0o644); err != nil {
+		b.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		args []string
+	}{
+		{"tokens", []string{"-tokens", script}},
+		{"fmt", []string{"fmt", script}},
+		{"interp", []string{"-interp", script}},
+		{"vm", []string{"-vm", script}},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				cmd := exec.Command(bin, tc.args...)
+				cmd.Dir = dir
+				cmd.Env = append(os.Environ(), "WELLE_HOME="+filepath.Join(dir, "home"))
+				if out, err := cmd.CombinedOutput(); err != nil {
+					b.Fatalf("welle %v: %v\n%s", tc.args, err, out)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunWithoutWritableHome(t *testing.T) {
+	root := repoRoot(t)
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "welle")
+	build := exec.Command("go", "build", "-o", bin, ".")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("build welle: %v\n%s", err, out)
+	}
+	script := filepath.Join(dir, "main.wll")
+	if err := os.WriteFile(script, []byte("import \"std:math\" as m\nprint(1, m.add(1, 2))\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bytecode cache may create HOME; the run must not depend on it.
+	home := filepath.Join(dir, "missing", "home")
+	for _, mode := range []string{"-vm", "-interp"} {
+		cmd := exec.Command(bin, mode, "run", script)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "HOME="+home, "WELLE_HOME=", "WELLE_STD=")
+		out, err := cmd.CombinedOutput()
+		if err != nil || string(out) != "1 3\n" {
+			t.Fatalf("%s: expected 1 3, got err=%v output: %s", mode, err, out)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(home, ".welle", "std")); !os.IsNotExist(err) {
+		t.Fatalf("expected the embedded std not to be written out, stat gave %v", err)
+	}
+
+	// examples/math.wll is imported as "math.wll"; std's math must not
+	// shadow it.
+	for _, name := range []string{"use_from.wll", "use_math.wll"} {
+		cmd := exec.Command(bin, "run", name)
+		cmd.Dir = filepath.Join(root, "examples")
+		cmd.Env = append(os.Environ(), "WELLE_HOME="+filepath.Join(dir, "home"))
+		out, _ := cmd.CombinedOutput()
+		if !strings.HasPrefix(string(out), "5\n3\n") {
+			t.Fatalf("%s: expected the local math module, got: %s", name, out)
+		}
+	}
+}
@@ -72,10 +72,15 @@ func runTest(args []string) {
 		os.Exit(1)
 	}
 
+	var features []string
+	if man != nil {
+		features = man.Features
+	}
+
 	passed := 0
 	failed := 0
 	for _, path := range files {
-		ok, reason := runTestFile(path, resolver, *useVM)
+		ok, reason := runTestFile(path, resolver, features, *useVM)
 		if ok {
 			passed++
 			continue
@@ -89,7 +94,7 @@ func runTest(args []string) {
 	}
 }
 
-func runTestFile(path string, resolver *module.Resolver, useVM bool) (bool, string) {
+func runTestFile(path string, resolver *module.Resolver, features []string, useVM bool) (bool, string) {
 	abs, err := filepath.Abs(path)
 	if err != nil {
 		return false, "invalid path"
@@ -103,6 +108,7 @@ func runTestFile(path string, resolver *module.Resolver, useVM bool) (bool, stri
 	stdout, err := spectest.CaptureStdout(func() {
 		if useVM {
 			loader := module.NewLoader(resolver)
+			loader.Features = features
 			bc, entryPath, err := loader.LoadBytecode(abs, abs, false)
 			if err != nil {
 				gotErr = err.Error()
@@ -114,6 +120,7 @@ func runTestFile(path string, resolver *module.Resolver, useVM bool) (bool, stri
 			}
 		} else {
 			runner := evaluator.NewRunner()
+			runner.SetFeatures(features)
 			runner.SetResolver(resolver)
 			runner.EnableImports()
 			res := runner.RunFile(abs)
@@ -89,7 +89,7 @@ func TestRunnerStdoutExpectations(t *testing.T) {
 	}
 	for _, useVM := range []bool{false, true} {
 		for _, path := range paths {
-			ok, reason := runTestFile(path, resolver, useVM)
+			ok, reason := runTestFile(path, resolver, nil, useVM)
 			if !ok {
 				t.Fatalf("runTestFile failed (vm=%v) for %s: %s", useVM, path, reason)
 			}
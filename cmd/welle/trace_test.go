@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTraceFlags(t *testing.T) {
+	root := repoRoot(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.wll")
+	src := "func add(a, b) {\n  return a + b\n}\nx = add(1, 2)\ntrace(false)\nprint(x)\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatalf("write main: %v", err)
+	}
+
+	out, err := runWelle(root, "-interp", "-trace", "run", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\noutput: %s", err, out)
+	}
+	for _, want := range []string{"trace main.wll:4:1 <main>: x = add(1, 2)\n", "trace main.wll:2:3 add: return (a + b)\n", "3\n"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in output: %s", want, out)
+		}
+	}
+	if strings.Contains(out, "print(x)") {
+		t.Fatalf("trace(false) should stop tracing, got: %s", out)
+	}
+
+	traceFile := filepath.Join(dir, "trace.txt")
+	out, err = runWelle(root, "-vm", "-trace", "-trace-out", traceFile, "-trace-funcs", "add", "run", path)
+	if err != nil || strings.TrimSpace(out) != "3" {
+		t.Fatalf("expected 3, got err=%v output: %s", err, out)
+	}
+	b, err := os.ReadFile(traceFile)
+	if err != nil {
+		t.Fatalf("read trace: %v", err)
+	}
+	if !strings.Contains(string(b), "add: 0000 OpGetLocal") || strings.Contains(string(b), "<main>") {
+		t.Fatalf("expected only add's instructions, got: %s", b)
+	}
+}
+
+func TestTraceLocalsMatchAcrossBackends(t *testing.T) {
+	root := repoRoot(t)
+	path := filepath.Join(t.TempDir(), "main.wll")
+	src := "func work(a) {\n  throw \"bad\"\n}\nfunc outer(b) {\n  return work(b * 2)\n}\nouter(3)\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatalf("write main: %v", err)
+	}
+	frames := func(mode string) string {
+		out, err := runWelle(root, mode, "-trace-locals", "run", path)
+		if err == nil {
+			t.Fatalf("%s: expected an error, got output: %s", mode, out)
+		}
+		var b strings.Builder
+		for _, line := range strings.Split(out, "\n") {
+			if strings.HasPrefix(line, "  at ") {
+				b.WriteString(line + "\n")
+			}
+		}
+		return b.String()
+	}
+	// Each position is labelled with the function running there and shows
+	// that function's parameters.
+	want := "  at work (" + path + ":2:3) a = 6\n" +
+		"  at outer (" + path + ":5:14) b = 3\n" +
+		"  at <main> (" + path + ":7:6)\n"
+	for _, mode := range []string{"-interp", "-vm"} {
+		if got := frames(mode); got != want {
+			t.Fatalf("%s: stack trace\n%s\nwant\n%s", mode, got, want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompilerWarningFlags(t *testing.T) {
+	root := repoRoot(t)
+	path := filepath.Join(t.TempDir(), "main.wll")
+	src := "func f() {\n  tmp = 1\n  return 2\n}\nprint(f())\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatalf("write main: %v", err)
+	}
+
+	out, err := runWelle(root, "-vm", "run", path)
+	if err != nil || strings.Contains(out, "WC0001") {
+		t.Fatalf("expected silent run without -W, got err=%v output: %s", err, out)
+	}
+
+	out, err = runWelle(root, "-vm", "-W", "run", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\noutput: %s", err, out)
+	}
+	if !strings.Contains(out, "warning WC0001: local 'tmp' is assigned but never read") || !strings.Contains(out, "2") {
+		t.Fatalf("expected warning and program output, got: %s", out)
+	}
+
+	out, err = runWelle(root, "-vm", "-werror", "run", path)
+	if err == nil {
+		t.Fatalf("expected -werror to fail, got output: %s", out)
+	}
+	if !strings.Contains(out, "1 warning(s) treated as errors") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"strconv"
 	"strings"
 
 	"welle/internal/token"
@@ -24,6 +25,8 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	// Edition is the edition the file's `#welle` pragma names, or "".
+	Edition string
 }
 
 func (p *Program) TokenLiteral() string {
@@ -35,6 +38,9 @@ func (p *Program) TokenLiteral() string {
 
 func (p *Program) String() string {
 	var out bytes.Buffer
+	if p.Edition != "" {
+		out.WriteString("#welle " + p.Edition + "\n")
+	}
 	for _, s := range p.Statements {
 		out.WriteString(s.String())
 		out.WriteString("\n")
@@ -87,7 +93,7 @@ func (as *AssignStatement) String() string {
 type IndexAssignStatement struct {
 	Token token.Token // assignment operator
 	Op    token.Type
-	Left  Expression // *IndexExpression
+	Left  Expression // *IndexExpression or *SliceExpression
 	Value Expression
 }
 
@@ -141,6 +147,22 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// YieldStatement hands Value (nil without one) to whoever is iterating the
+// generator and suspends it until the next value is asked for.
+type YieldStatement struct {
+	Token token.Token // 'yield'
+	Value Expression
+}
+
+func (*YieldStatement) statementNode()          {}
+func (ys *YieldStatement) TokenLiteral() string { return ys.Token.Literal }
+func (ys *YieldStatement) String() string {
+	if ys.Value == nil {
+		return "yield"
+	}
+	return "yield " + ys.Value.String()
+}
+
 type DestructureAssignStatement struct {
 	Token   token.Token // '('
 	OpToken token.Token // assignment operator token
@@ -177,7 +199,10 @@ func (ds *DestructureAssignStatement) String() string {
 }
 
 type DestructureTarget struct {
-	Token token.Token // identifier or '*'
+	Token token.Token // identifier, '*' or the field name
+	// Field is set on a by-name target (field: name), which binds Name to
+	// that field of a named tuple.
+	Field *Identifier
 	Name  *Identifier
 	Star  bool
 }
@@ -189,6 +214,9 @@ func (dt *DestructureTarget) String() string {
 	if dt.Star {
 		return "*" + dt.Name.String()
 	}
+	if dt.Field != nil {
+		return dt.Field.String() + ": " + dt.Name.String()
+	}
 	return dt.Name.String()
 }
 
@@ -208,6 +236,24 @@ func (ds *DeferStatement) String() string {
 	return out.String()
 }
 
+// DelStatement is `del target`, where Target is an *IndexExpression
+// (`del a[i]`, `del d[k]`) or a *SliceExpression (`del a[1:3]`).
+type DelStatement struct {
+	Token  token.Token // 'del'
+	Target Expression
+}
+
+func (*DelStatement) statementNode()          {}
+func (ds *DelStatement) TokenLiteral() string { return ds.Token.Literal }
+func (ds *DelStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("del ")
+	if ds.Target != nil {
+		out.WriteString(ds.Target.String())
+	}
+	return out.String()
+}
+
 type ThrowStatement struct {
 	Token token.Token // 'throw'
 	Value Expression
@@ -224,6 +270,41 @@ func (ts *ThrowStatement) String() string {
 	return out.String()
 }
 
+// AssertStatement is `assert cond` or `assert cond, message`. Message is
+// nil when omitted.
+type AssertStatement struct {
+	Token     token.Token // 'assert'
+	Condition Expression
+	Message   Expression
+}
+
+func (*AssertStatement) statementNode()          {}
+func (as *AssertStatement) TokenLiteral() string { return as.Token.Literal }
+func (as *AssertStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("assert ")
+	if as.Condition != nil {
+		out.WriteString(as.Condition.String())
+	}
+	if as.Message != nil {
+		out.WriteString(", ")
+		out.WriteString(as.Message.String())
+	}
+	return out.String()
+}
+
+// FailureMessage is the error message thrown when the assertion fails,
+// before any user message: the condition as String() prints it, without the
+// parentheses String() puts around a whole operator expression.
+func (as *AssertStatement) FailureMessage() string {
+	cond := as.Condition.String()
+	switch as.Condition.(type) {
+	case *InfixExpression, *PrefixExpression, *ConditionalExpression, *IndexExpression, *SliceExpression:
+		cond = cond[1 : len(cond)-1]
+	}
+	return "assertion failed: " + cond
+}
+
 type BreakStatement struct {
 	Token token.Token // 'break'
 }
@@ -302,6 +383,9 @@ type ExportStatement struct {
 func (*ExportStatement) statementNode()          {}
 func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
 func (es *ExportStatement) String() string {
+	if fs, ok := es.Stmt.(*FuncStatement); ok {
+		return fs.Annotation() + "export " + fs.declString()
+	}
 	return "export " + es.Stmt.String()
 }
 
@@ -329,6 +413,7 @@ type TryStatement struct {
 	TryBlock     *BlockStatement
 	CatchToken   token.Token // 'catch' (optional)
 	CatchName    *Identifier
+	CatchFilter  Expression // codes after ':' in `catch (e: codes)`, or nil
 	CatchBlock   *BlockStatement
 	FinallyToken token.Token // 'finally' (optional)
 	FinallyBlock *BlockStatement
@@ -343,6 +428,10 @@ func (ts *TryStatement) String() string {
 	if ts.CatchBlock != nil {
 		out.WriteString(" catch (")
 		out.WriteString(ts.CatchName.String())
+		if ts.CatchFilter != nil {
+			out.WriteString(": ")
+			out.WriteString(ts.CatchFilter.String())
+		}
 		out.WriteString(") ")
 		out.WriteString(ts.CatchBlock.String())
 	}
@@ -484,32 +573,94 @@ type FuncStatement struct {
 	Token      token.Token // 'func'
 	Name       *Identifier
 	Parameters []*Identifier
+	Defaults   []Expression // parallel to Parameters, nil where there is none; nil if no parameter has one
 	Body       *BlockStatement
+	// Deprecated is the '@' of an @deprecated annotation above the function,
+	// or nil; DeprecatedMsg is the annotation's message, if it has one.
+	Deprecated    *token.Token
+	DeprecatedMsg string
 }
 
 func (*FuncStatement) statementNode()          {}
 func (fs *FuncStatement) TokenLiteral() string { return fs.Token.Literal }
 func (fs *FuncStatement) String() string {
+	return fs.Annotation() + fs.declString()
+}
+
+// Annotation returns the @deprecated line above the function, newline
+// included, or "" when it has none.
+func (fs *FuncStatement) Annotation() string {
+	if fs.Deprecated == nil {
+		return ""
+	}
+	if fs.DeprecatedMsg == "" {
+		return "@deprecated\n"
+	}
+	return "@deprecated(" + strconv.Quote(fs.DeprecatedMsg) + ")\n"
+}
+
+func (fs *FuncStatement) declString() string {
 	var out bytes.Buffer
 	out.WriteString("func ")
 	out.WriteString(fs.Name.String())
 	out.WriteString("(")
-	for i, p := range fs.Parameters {
-		if i > 0 {
-			out.WriteString(", ")
-		}
-		out.WriteString(p.String())
-	}
+	writeParams(&out, fs.Parameters, fs.Defaults)
 	out.WriteString(") ")
 	out.WriteString(fs.Body.String())
 	return out.String()
 }
 
+// ClassStatement declares a class: its fields, in order, and its methods.
+// A method's body sees the instance it was called on as self.
+type ClassStatement struct {
+	Token    token.Token // 'class'
+	Name     *Identifier
+	Fields   []*Identifier
+	Defaults []Expression // parallel to Fields, nil where there is none; nil if no field has one
+	Methods  []*FuncStatement
+}
+
+func (*ClassStatement) statementNode()          {}
+func (cs *ClassStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ClassStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("class ")
+	out.WriteString(cs.Name.String())
+	out.WriteString(" {\n")
+	for i, f := range cs.Fields {
+		out.WriteString("  ")
+		out.WriteString(f.String())
+		if d := ParamDefault(cs.Defaults, i); d != nil {
+			out.WriteString(" = ")
+			out.WriteString(d.String())
+		}
+		out.WriteString("\n")
+	}
+	for _, m := range cs.Methods {
+		out.WriteString("  ")
+		out.WriteString(m.String())
+		out.WriteString("\n")
+	}
+	out.WriteString("}")
+	return out.String()
+}
+
+// Method returns the method of cs called name, or nil.
+func (cs *ClassStatement) Method(name string) *FuncStatement {
+	for _, m := range cs.Methods {
+		if m.Name != nil && m.Name.Value == name {
+			return m
+		}
+	}
+	return nil
+}
+
 /* -------------------- Expressions -------------------- */
 
 type FunctionLiteral struct {
 	Token      token.Token // 'func'
 	Parameters []*Identifier
+	Defaults   []Expression // as in FuncStatement
 	Body       *BlockStatement
 }
 
@@ -518,23 +669,104 @@ func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	out.WriteString("func(")
-	for i, p := range fl.Parameters {
+	writeParams(&out, fl.Parameters, fl.Defaults)
+	out.WriteString(") ")
+	out.WriteString(fl.Body.String())
+	return out.String()
+}
+
+// ParamDefault returns the default value of parameter i, or nil.
+func ParamDefault(defaults []Expression, i int) Expression {
+	if i < len(defaults) {
+		return defaults[i]
+	}
+	return nil
+}
+
+func writeParams(out *bytes.Buffer, params []*Identifier, defaults []Expression) {
+	for i, p := range params {
 		if i > 0 {
 			out.WriteString(", ")
 		}
 		out.WriteString(p.String())
+		if d := ParamDefault(defaults, i); d != nil {
+			out.WriteString(" = ")
+			out.WriteString(d.String())
+		}
 	}
-	out.WriteString(") ")
-	out.WriteString(fl.Body.String())
-	return out.String()
 }
 
 type MatchCase struct {
 	Token  token.Token // 'case'
 	Values []Expression
+	// Guard is the condition after `if` that must also hold, checked once
+	// a value has matched and its pattern has bound its names; nil when
+	// omitted.
+	Guard  Expression
 	Result Expression
 }
 
+// MatchPattern is a case value that destructures the subject instead of
+// comparing it: a tuple, array or dict literal whose identifiers bind the
+// parts they stand for. `_` matches anything without binding it, an array
+// may end its elements with `...rest`, and a dict matches when it has at
+// least the listed keys. Any other element is a value the part must equal.
+type MatchPattern struct {
+	Token token.Token // '(', '[' or '#'
+	Value Expression  // *TupleLiteral, *ListLiteral or *DictLiteral
+}
+
+func (*MatchPattern) expressionNode()         {}
+func (mp *MatchPattern) TokenLiteral() string { return mp.Token.Literal }
+func (mp *MatchPattern) String() string       { return mp.Value.String() }
+
+// Names returns the identifiers mp binds, in source order.
+func (mp *MatchPattern) Names() []*Identifier {
+	var out []*Identifier
+	walkPattern(mp.Value, func(id *Identifier) { out = append(out, id) }, func(Expression) {})
+	return out
+}
+
+// Values returns the parts of mp that are evaluated rather than bound: the
+// values its parts are compared with and its dict keys, in source order.
+func (mp *MatchPattern) Values() []Expression {
+	var out []Expression
+	walkPattern(mp.Value, func(*Identifier) {}, func(e Expression) { out = append(out, e) })
+	return out
+}
+
+func walkPattern(e Expression, bind func(*Identifier), value func(Expression)) {
+	switch n := e.(type) {
+	case *Identifier:
+		if n.Value != "_" {
+			bind(n)
+		}
+	case *TupleLiteral:
+		for _, el := range n.Elements {
+			walkPattern(el, bind, value)
+		}
+	case *ListLiteral:
+		for _, el := range n.Elements {
+			if rest, ok := el.(*SpreadExpression); ok {
+				el = rest.Value
+			}
+			walkPattern(el, bind, value)
+		}
+	case *DictLiteral:
+		for _, p := range n.Pairs {
+			if p.Shorthand != nil {
+				walkPattern(p.Shorthand, bind, value)
+				continue
+			}
+			value(p.Key)
+			walkPattern(p.Value, bind, value)
+		}
+	case nil:
+	default:
+		value(e)
+	}
+}
+
 type MatchExpression struct {
 	Token   token.Token // 'match'
 	Value   Expression
@@ -557,6 +789,10 @@ func (me *MatchExpression) String() string {
 			}
 			out.WriteString(val.String())
 		}
+		if c.Guard != nil {
+			out.WriteString(" if ")
+			out.WriteString(c.Guard.String())
+		}
 		out.WriteString(" { ")
 		out.WriteString(c.Result.String())
 		out.WriteString(" }")
@@ -853,6 +1089,35 @@ func (tl *TupleLiteral) String() string {
 	return out.String()
 }
 
+// NamedTupleLiteral is (x: 1, y: 2): a tuple whose elements can also be
+// read by name.
+type NamedTupleLiteral struct {
+	Token  token.Token // '('
+	Fields []NamedTupleField
+}
+
+type NamedTupleField struct {
+	Name  *Identifier
+	Value Expression
+}
+
+func (*NamedTupleLiteral) expressionNode()         {}
+func (nt *NamedTupleLiteral) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedTupleLiteral) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	for i, f := range nt.Fields {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(f.Name.String())
+		out.WriteString(": ")
+		out.WriteString(f.Value.String())
+	}
+	out.WriteString(")")
+	return out.String()
+}
+
 type ListLiteral struct {
 	Token    token.Token // '['
 	Elements []Expression
@@ -905,6 +1170,27 @@ func (lc *ListComprehension) String() string {
 	return out.String()
 }
 
+// SetLiteral is #[a, b, c].
+type SetLiteral struct {
+	Token    token.Token // '#'
+	Elements []Expression
+}
+
+func (*SetLiteral) expressionNode()         {}
+func (sl *SetLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *SetLiteral) String() string {
+	var out bytes.Buffer
+	out.WriteString("#[")
+	for i, el := range sl.Elements {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(el.String())
+	}
+	out.WriteString("]")
+	return out.String()
+}
+
 type DictLiteral struct {
 	Token token.Token // '#'
 	Pairs []DictPair
@@ -914,6 +1200,9 @@ type DictPair struct {
 	Key       Expression
 	Value     Expression
 	Shorthand *Identifier
+	// Spread is set for a `...other` entry, which copies in the pairs of
+	// another dict; Key and Value are nil.
+	Spread *SpreadExpression
 }
 
 func (*DictLiteral) expressionNode()         {}
@@ -929,6 +1218,10 @@ func (dl *DictLiteral) String() string {
 			out.WriteString(p.Shorthand.String())
 			continue
 		}
+		if p.Spread != nil {
+			out.WriteString(p.Spread.String())
+			continue
+		}
 		out.WriteString(p.Key.String())
 		out.WriteString(": ")
 		out.WriteString(p.Value.String())
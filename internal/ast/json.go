@@ -0,0 +1,123 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+
+	"welle/internal/token"
+)
+
+// MarshalJSON encodes node and everything below it as JSON for external
+// tools. Each node becomes an object whose first key is "node" (the Go type
+// name, e.g. "InfixExpression") followed by "line"/"col" from its primary
+// token and then its fields in declaration order, with lowerCamel keys.
+// Secondary tokens (such as an assignment operator) are encoded as
+// {"type","literal","line","col"} objects; absent optional tokens are null.
+func MarshalJSON(node Node) ([]byte, error) {
+	return json.Marshal(encodeValue(reflect.ValueOf(node)))
+}
+
+// jsonObject keeps keys in insertion order, unlike map[string]any.
+type jsonObject []jsonField
+
+type jsonField struct {
+	key   string
+	value any
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(f.key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+var tokenType = reflect.TypeOf(token.Token{})
+
+func encodeValue(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return encodeValue(v.Elem())
+	case reflect.Struct:
+		if v.Type() == tokenType {
+			return encodeToken(v.Interface().(token.Token))
+		}
+		return encodeStruct(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			return []any{}
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = encodeValue(v.Index(i))
+		}
+		return out
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	return nil
+}
+
+func encodeStruct(v reflect.Value) jsonObject {
+	t := v.Type()
+	obj := jsonObject{{key: "node", value: t.Name()}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		if f.Name == "Token" && f.Type == tokenType {
+			tok := fv.Interface().(token.Token)
+			obj = append(obj, jsonField{"line", tok.Line}, jsonField{"col", tok.Col})
+			continue
+		}
+		obj = append(obj, jsonField{lowerFirst(f.Name), encodeValue(fv)})
+	}
+	return obj
+}
+
+func encodeToken(tok token.Token) any {
+	if tok.Line == 0 && tok.Type == "" {
+		return nil
+	}
+	return jsonObject{
+		{"type", string(tok.Type)},
+		{"literal", tok.Literal},
+		{"line", tok.Line},
+		{"col", tok.Col},
+	}
+}
+
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}
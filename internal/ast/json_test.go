@@ -0,0 +1,44 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"welle/internal/ast"
+	"welle/internal/lexer"
+	"welle/internal/parser"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	p := parser.New(lexer.New("try { x = a[1] } catch (e) { print(e) }\n"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	raw, err := ast.MarshalJSON(prog)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.HasPrefix(string(raw), `{"node":"Program","statements":[{"node":"TryStatement","line":1,"col":1,`) {
+		t.Fatalf("unexpected prefix: %s", raw)
+	}
+
+	var root map[string]any
+	if err := json.Unmarshal(raw, &root); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	try := root["statements"].([]any)[0].(map[string]any)
+	catchTok := try["catchToken"].(map[string]any)
+	if catchTok["literal"] != "catch" || catchTok["col"] != float64(18) {
+		t.Fatalf("unexpected catch token: %v", catchTok)
+	}
+	if try["finallyToken"] != nil || try["finallyBlock"] != nil {
+		t.Fatalf("expected absent finally to be null, got %v / %v", try["finallyToken"], try["finallyBlock"])
+	}
+	assign := try["tryBlock"].(map[string]any)["statements"].([]any)[0].(map[string]any)
+	index := assign["value"].(map[string]any)
+	if index["node"] != "IndexExpression" || index["index"].(map[string]any)["value"] != float64(1) {
+		t.Fatalf("unexpected index expression: %v", index)
+	}
+}
@@ -13,3 +13,76 @@ func AnonymousFuncName(tok token.Token) string {
 	}
 	return "<anon>"
 }
+
+// BuildName is the name build features are read through: BUILD.gfx is true
+// when the run enables the gfx feature, and false otherwise.
+const BuildName = "BUILD"
+
+// BuildFlag returns the feature e reads when it is a BUILD.<feature> flag.
+func BuildFlag(e Expression) (string, bool) {
+	m, ok := e.(*MemberExpression)
+	if !ok || m.Property == nil {
+		return "", false
+	}
+	if id, ok := m.Object.(*Identifier); ok && id.Value == BuildName {
+		return m.Property.Value, true
+	}
+	return "", false
+}
+
+// IsGenerator reports whether a function with this body is a generator:
+// one that yields somewhere outside the functions nested in it.
+func IsGenerator(body *BlockStatement) bool {
+	var found func(n any) bool
+	found = func(n any) bool {
+		switch n.(type) {
+		case *YieldStatement:
+			return true
+		case *FunctionLiteral, *FuncStatement, *ClassStatement:
+			return false
+		}
+		for _, c := range Children(n) {
+			if found(c) {
+				return true
+			}
+		}
+		return false
+	}
+	return body != nil && found(body)
+}
+
+// SelfName is the name a method's body reads its instance through.
+const SelfName = "self"
+
+// InitName is the method a class runs on each new instance, with the
+// arguments the class was called with.
+const InitName = "init"
+
+// MethodParams returns the parameters a class method runs with: self, then
+// the ones it declares, with their defaults.
+func MethodParams(m *FuncStatement) ([]*Identifier, []Expression) {
+	self := &Identifier{Token: token.Token{Type: token.IDENT, Literal: SelfName, Line: m.Token.Line, Col: m.Token.Col}, Value: SelfName}
+	params := append([]*Identifier{self}, m.Parameters...)
+	if m.Defaults == nil {
+		return params, nil
+	}
+	return params, append([]Expression{nil}, m.Defaults...)
+}
+
+// Deprecations maps the names of prog's top-level functions, exported or
+// not, that carry an @deprecated annotation to the annotation's message.
+func Deprecations(prog *Program) map[string]string {
+	out := map[string]string{}
+	if prog == nil {
+		return out
+	}
+	for _, st := range prog.Statements {
+		if exp, ok := st.(*ExportStatement); ok {
+			st = exp.Stmt
+		}
+		if fn, ok := st.(*FuncStatement); ok && fn.Deprecated != nil && fn.Name != nil {
+			out[fn.Name.Value] = fn.DeprecatedMsg
+		}
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package ast
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// SExpr renders node in the S-expression form printed by tree-sitter, so
+// native trees can be compared with those of the grammar in
+// tree-sitter-welle. Each node is written as (type_name child ...), with
+// the Go type name in snake_case and only node children listed, in source
+// order; names, operators and literal values are left out, as tree-sitter
+// leaves out anonymous tokens.
+func SExpr(node Node) string {
+	var b strings.Builder
+	writeSExpr(&b, reflect.ValueOf(node))
+	return b.String()
+}
+
+var (
+	nodeType    = reflect.TypeOf((*Node)(nil)).Elem()
+	astPkg      = reflect.TypeOf(Program{}).PkgPath()
+	templateTyp = reflect.TypeOf(TemplateLiteral{})
+)
+
+func writeSExpr(b *strings.Builder, v reflect.Value) {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	typ := v.Type()
+	b.WriteByte('(')
+	b.WriteString(snakeCase(typ.Name()))
+	// A tagged template's tag comes before the template in the source.
+	if typ == templateTyp {
+		writeChildren(b, v.FieldByName("Tag"))
+	}
+	// A parameter's or field's default comes right after it in the source.
+	defaults := v.FieldByName("Defaults")
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !f.IsExported() || (typ == templateTyp && f.Name == "Tag") || (defaults.IsValid() && f.Name == "Defaults") {
+			continue
+		}
+		if defaults.IsValid() && (f.Name == "Parameters" || f.Name == "Fields") {
+			params := v.Field(i)
+			for j := 0; j < params.Len(); j++ {
+				writeChildren(b, params.Index(j))
+				if j < defaults.Len() {
+					writeChildren(b, defaults.Index(j))
+				}
+			}
+			continue
+		}
+		writeChildren(b, v.Field(i))
+	}
+	b.WriteByte(')')
+}
+
+// writeChildren writes the tree nodes held by a field: Nodes and the
+// helper structs of this package (such as DictPair and CaseClause), alone
+// or in slices.
+func writeChildren(b *strings.Builder, fv reflect.Value) {
+	switch fv.Kind() {
+	case reflect.Slice:
+		for j := 0; j < fv.Len(); j++ {
+			writeChildren(b, fv.Index(j))
+		}
+	case reflect.Interface:
+		if !fv.IsNil() && fv.Type().Implements(nodeType) {
+			b.WriteByte(' ')
+			writeSExpr(b, fv)
+		}
+	case reflect.Pointer:
+		if !fv.IsNil() && fv.Type().Elem().PkgPath() == astPkg {
+			b.WriteByte(' ')
+			writeSExpr(b, fv)
+		}
+	case reflect.Struct:
+		if fv.Type().PkgPath() == astPkg {
+			b.WriteByte(' ')
+			writeSExpr(b, fv)
+		}
+	}
+}
+
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
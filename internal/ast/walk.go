@@ -0,0 +1,74 @@
+package ast
+
+import (
+	"reflect"
+
+	"welle/internal/token"
+)
+
+// Children returns the syntax elements directly below n, in source order:
+// the Nodes in its fields and pointers to the helper structs of this
+// package that group them (such as *DictPair, *MatchCase or *ImportItem).
+// n is a Node or one of those pointers.
+func Children(n any) []any {
+	v := reflect.ValueOf(n)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	v = v.Elem()
+	typ := v.Type()
+	var out []any
+	if typ == templateTyp {
+		out = appendChildren(out, v.FieldByName("Tag"))
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !f.IsExported() || (typ == templateTyp && f.Name == "Tag") {
+			continue
+		}
+		out = appendChildren(out, v.Field(i))
+	}
+	return out
+}
+
+func appendChildren(out []any, fv reflect.Value) []any {
+	switch fv.Kind() {
+	case reflect.Slice:
+		for j := 0; j < fv.Len(); j++ {
+			out = appendChildren(out, fv.Index(j))
+		}
+	case reflect.Interface:
+		if !fv.IsNil() && fv.Type().Implements(nodeType) {
+			out = append(out, fv.Interface())
+		}
+	case reflect.Pointer:
+		if !fv.IsNil() && fv.Type().Elem().PkgPath() == astPkg {
+			out = append(out, fv.Interface())
+		}
+	case reflect.Struct:
+		if fv.Type().PkgPath() == astPkg && fv.CanAddr() {
+			out = append(out, fv.Addr().Interface())
+		}
+	}
+	return out
+}
+
+// Tokens returns the tokens held directly by n (its primary token and any
+// secondary ones such as a closing keyword), skipping unset ones.
+func Tokens(n any) []token.Token {
+	v := reflect.ValueOf(n)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	v = v.Elem()
+	var out []token.Token
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).Type() != tokenType || !v.Type().Field(i).IsExported() {
+			continue
+		}
+		if tok := v.Field(i).Interface().(token.Token); tok.Line > 0 {
+			out = append(out, tok)
+		}
+	}
+	return out
+}
@@ -0,0 +1,2324 @@
+package builtins
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"welle/internal/formatutil"
+	"welle/internal/gfx"
+	"welle/internal/object"
+	"welle/internal/runtimeio"
+	"welle/internal/semantics"
+	"welle/internal/unitext"
+)
+
+func builtinPrint(args ...object.Object) object.Object {
+	for i, a := range args {
+		if i > 0 {
+			_, _ = fmt.Fprint(os.Stdout, " ")
+		}
+		_, _ = fmt.Fprint(os.Stdout, a.Inspect())
+	}
+	_, _ = fmt.Fprintln(os.Stdout)
+	return nilObj
+}
+
+// builtinPP pretty-prints one value, one element per line. opts may set
+// "depth", "length" and "indent" (see object.InspectOptions); 0 lifts the
+// depth or length limit, and indent 0 prints on one line like print.
+func builtinPP(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1 or 2, got %d", len(args))}
+	}
+	opts := object.PrintOptions()
+	opts.Indent = 2
+	if len(args) == 2 {
+		d, ok := args[1].(*object.Dict)
+		if !ok {
+			return &object.Error{Message: "pp() options must be DICT"}
+		}
+		for _, pair := range d.Pairs {
+			name, _ := pair.Key.(*object.String)
+			n, ok := pair.Value.(*object.Integer)
+			if name == nil || !ok || n.Value < 0 {
+				return &object.Error{Message: "pp() options must map \"depth\", \"length\" or \"indent\" to a non-negative INTEGER"}
+			}
+			switch name.Value {
+			case "depth":
+				opts.MaxDepth = int(n.Value)
+			case "length":
+				opts.MaxLen = int(n.Value)
+			case "indent":
+				opts.Indent = int(n.Value)
+			default:
+				return &object.Error{Message: "pp() unknown option: " + name.Value}
+			}
+		}
+	}
+	_, _ = fmt.Fprintln(os.Stdout, object.InspectWith(args[0], opts))
+	return nilObj
+}
+
+// builtinSetPrintOptions changes how print, str and the REPL write values:
+// "float_precision" (digits after the point, 0 for the shortest exact form),
+// "max_items" (elements per container) and "max_depth" (nesting levels),
+// where 0 lifts the limit. Keys left out keep their value. It returns the
+// previous options, so they can be passed back to restore them.
+func builtinSetPrintOptions(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1, got %d", len(args))}
+	}
+	d, ok := args[0].(*object.Dict)
+	if !ok {
+		return &object.Error{Message: "set_print_options() expects DICT"}
+	}
+	prev := object.PrintOptions()
+	opts := prev
+	for _, pair := range d.Pairs {
+		name, _ := pair.Key.(*object.String)
+		n, ok := pair.Value.(*object.Integer)
+		if name == nil || !ok || n.Value < 0 {
+			return &object.Error{Message: "set_print_options() expects a non-negative INTEGER for each option"}
+		}
+		switch name.Value {
+		case "float_precision":
+			opts.FloatPrecision = int(n.Value)
+		case "max_items":
+			opts.MaxLen = int(n.Value)
+		case "max_depth":
+			opts.MaxDepth = int(n.Value)
+		default:
+			return &object.Error{Message: "set_print_options() unknown option: " + name.Value}
+		}
+	}
+	object.SetPrintOptions(opts)
+	out := &object.Dict{Pairs: map[object.HashKey]object.DictPair{}}
+	for _, kv := range []struct {
+		name string
+		val  int
+	}{{"float_precision", prev.FloatPrecision}, {"max_items", prev.MaxLen}, {"max_depth", prev.MaxDepth}} {
+		key := &object.String{Value: kv.name}
+		hk, _ := object.HashKeyOf(key)
+		out.Pairs[hk] = object.DictPair{Key: key, Value: &object.Integer{Value: int64(kv.val)}}
+	}
+	return out
+}
+
+func builtinLen(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1, got %d", len(args))}
+	}
+	switch v := args[0].(type) {
+	case *object.String:
+		return &object.Integer{Value: int64(v.RuneCount())}
+	case *object.Array:
+		return &object.Integer{Value: int64(len(v.Elements))}
+	case *object.Dict:
+		return &object.Integer{Value: int64(len(v.Pairs))}
+	case *object.Set:
+		return &object.Integer{Value: int64(len(v.Items))}
+	case *object.Range:
+		return &object.Integer{Value: v.Len()}
+	default:
+		return &object.Error{Message: "len() not supported for type: " + string(args[0].Type())}
+	}
+}
+
+func builtinStr(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1, got %d", len(args))}
+	}
+	return &object.String{Value: args[0].Inspect()}
+}
+
+func builtinGroupDigits(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1 to 3, got %d", len(args))}
+	}
+	sep := ","
+	group := int64(3)
+	if len(args) >= 2 {
+		s, ok := args[1].(*object.String)
+		if !ok {
+			return &object.Error{Message: "group_digits() sep must be STRING"}
+		}
+		sep = s.Value
+	}
+	if len(args) == 3 {
+		g, ok := args[2].(*object.Integer)
+		if !ok {
+			return &object.Error{Message: "group_digits() group must be INTEGER"}
+		}
+		group = g.Value
+	}
+
+	var out string
+	var err error
+	switch x := args[0].(type) {
+	case *object.Integer:
+		out, err = formatutil.GroupDigitsFromInt(x.Value, sep, int(group))
+	case *object.String:
+		out, err = formatutil.GroupDigitsFromString(x.Value, sep, int(group))
+	default:
+		return &object.Error{Message: "group_digits() x must be INTEGER or STRING"}
+	}
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.String{Value: out}
+}
+
+func builtinFormatFloat(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 2, got %d", len(args))}
+	}
+	dec, ok := args[1].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "format_float() decimals must be INTEGER"}
+	}
+	var x float64
+	switch v := args[0].(type) {
+	case *object.Integer:
+		x = float64(v.Value)
+	case *object.Float:
+		x = v.Value
+	default:
+		return &object.Error{Message: "format_float() x must be NUMBER"}
+	}
+	out, err := formatutil.FormatFloat(x, int(dec.Value))
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.String{Value: out}
+}
+
+func builtinFormatPercent(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 2, got %d", len(args))}
+	}
+	dec, ok := args[1].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "format_percent() decimals must be INTEGER"}
+	}
+	var x float64
+	switch v := args[0].(type) {
+	case *object.Integer:
+		x = float64(v.Value)
+	case *object.Float:
+		x = v.Value
+	default:
+		return &object.Error{Message: "format_percent() x must be NUMBER"}
+	}
+	out, err := formatutil.FormatPercent(x, int(dec.Value))
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.String{Value: out}
+}
+
+func builtinUnicodeNormalize(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 2, got %d", len(args))}
+	}
+	s, ok := args[0].(*object.String)
+	if !ok {
+		return &object.Error{Message: "unicode_normalize() s must be STRING"}
+	}
+	form, ok := args[1].(*object.String)
+	if !ok {
+		return &object.Error{Message: "unicode_normalize() form must be STRING"}
+	}
+	out, ok := unitext.Normalize(s.Value, form.Value)
+	if !ok {
+		return &object.Error{Message: fmt.Sprintf("unicode_normalize() unknown form %q (want \"NFC\" or \"NFD\")", form.Value)}
+	}
+	return &object.String{Value: out}
+}
+
+// builtinChecked returns the builtin name, which applies op and reports
+// whether the result fit in 64 bits.
+func builtinChecked(name, op string) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		a, b, err := semantics.IntPair(name, args)
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+		v, ok := semantics.CheckedInt(op, a, b)
+		return &object.Tuple{Elements: []object.Object{&object.Integer{Value: v}, nativeBool(ok)}}
+	}
+}
+
+func builtinFloorDiv(args ...object.Object) object.Object {
+	a, b, err := semantics.IntPair("floor_div", args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	if b == 0 {
+		return &object.Error{Message: "division by zero"}
+	}
+	return &object.Integer{Value: semantics.FloorDiv(a, b)}
+}
+
+func builtinFloorMod(args ...object.Object) object.Object {
+	a, b, err := semantics.IntPair("floor_mod", args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	if b == 0 {
+		return &object.Error{Message: "modulo by zero"}
+	}
+	return &object.Integer{Value: semantics.FloorMod(a, b)}
+}
+
+func builtinRound(args ...object.Object) object.Object {
+	out, err := semantics.Round(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+// builtinIntegral returns the builtin name, which applies op to a number and
+// converts the result to an INTEGER.
+func builtinIntegral(name string, op func(float64) float64) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		out, err := semantics.IntegralPart(name, op, args)
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+		return out
+	}
+}
+
+func builtinFloatClass(name string, pred func(float64) bool) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		ok, err := semantics.FloatClass(name, pred, args)
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+		return nativeBool(ok)
+	}
+}
+
+func builtinApproxEq(args ...object.Object) object.Object {
+	ok, err := semantics.ApproxEqual(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nativeBool(ok)
+}
+
+func builtinStatsMedian(args ...object.Object) object.Object {
+	out, err := semantics.Median(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinStatsMode(args ...object.Object) object.Object {
+	out, err := semantics.Mode(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinStatsVariance(args ...object.Object) object.Object {
+	v, err := semantics.Variance("variance", args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.Float{Value: v}
+}
+
+func builtinStatsStddev(args ...object.Object) object.Object {
+	v, err := semantics.Variance("stddev", args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.Float{Value: math.Sqrt(v)}
+}
+
+func builtinStatsPercentile(args ...object.Object) object.Object {
+	v, err := semantics.Percentile(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.Float{Value: v}
+}
+
+func builtinStatsHistogram(args ...object.Object) object.Object {
+	counts, edges, err := semantics.Histogram(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	countEls := make([]object.Object, len(counts))
+	for i, c := range counts {
+		countEls[i] = &object.Integer{Value: c}
+	}
+	edgeEls := make([]object.Object, len(edges))
+	for i, e := range edges {
+		edgeEls[i] = &object.Float{Value: e}
+	}
+	return &object.Tuple{Elements: []object.Object{&object.Array{Elements: countEls}, &object.Array{Elements: edgeEls}}}
+}
+
+func builtinSortBy(args ...object.Object) object.Object {
+	return &object.Error{Message: "sort_by() is not directly callable"}
+}
+
+func builtinLocals(args ...object.Object) object.Object {
+	return &object.Error{Message: "locals() is not directly callable"}
+}
+
+func builtinGlobals(args ...object.Object) object.Object {
+	return &object.Error{Message: "globals() is not directly callable"}
+}
+
+func builtinIsMain(args ...object.Object) object.Object {
+	return &object.Error{Message: "is_main() is not directly callable"}
+}
+
+func builtinDir(args ...object.Object) object.Object {
+	out, err := semantics.Dir(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinTrace(args ...object.Object) object.Object {
+	return &object.Error{Message: "trace() is not directly callable"}
+}
+
+func builtinWithTimeout(args ...object.Object) object.Object {
+	return &object.Error{Message: "flow_with_timeout() is not directly callable"}
+}
+
+func builtinHTTPCall(args ...object.Object) object.Object {
+	return &object.Error{Message: "http_call() is not directly callable"}
+}
+
+func builtinSleep(args ...object.Object) object.Object {
+	return &object.Error{Message: "flow_sleep() is not directly callable"}
+}
+
+func builtinArgs(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 0, got %d", len(args))}
+	}
+	words := runtimeio.Args()
+	out := make([]object.Object, len(words))
+	for i, w := range words {
+		out[i] = &object.String{Value: w}
+	}
+	return &object.Array{Elements: out}
+}
+
+func builtinCLIParse(args ...object.Object) object.Object {
+	out, err := semantics.CLIParse(args, runtimeio.ScriptName())
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinCLIHelp(args ...object.Object) object.Object {
+	out, err := semantics.CLIHelp(args, runtimeio.ScriptName())
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.String{Value: out}
+}
+
+func builtinTOMLParse(args ...object.Object) object.Object {
+	out, err := semantics.TOMLParse(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinTOMLStringify(args ...object.Object) object.Object {
+	out, err := semantics.TOMLStringify(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.String{Value: out}
+}
+
+func builtinTemplateRender(args ...object.Object) object.Object {
+	out, err := semantics.TemplateRender(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.String{Value: out}
+}
+
+func builtinYAMLParse(args ...object.Object) object.Object {
+	out, err := semantics.YAMLParse(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinINIParse(args ...object.Object) object.Object {
+	out, err := semantics.INIParse(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinEncodingEncode(args ...object.Object) object.Object {
+	out, err := semantics.EncodingEncode(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.String{Value: out}
+}
+
+func builtinEncodingDecode(args ...object.Object) object.Object {
+	out, err := semantics.EncodingDecode(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.String{Value: out}
+}
+
+func builtinQueryDecode(args ...object.Object) object.Object {
+	out, err := semantics.QueryDecode(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinQueryEncode(args ...object.Object) object.Object {
+	out, err := semantics.QueryEncode(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.String{Value: out}
+}
+
+func builtinSQLiteOpen(args ...object.Object) object.Object {
+	db, err := semantics.SQLiteOpen(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.Integer{Value: db}
+}
+
+func builtinSQLiteClose(args ...object.Object) object.Object {
+	return statusResult(semantics.SQLiteClose(args))
+}
+
+func builtinSQLiteQuery(args ...object.Object) object.Object {
+	rows, err := semantics.SQLiteQuery(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return rows
+}
+
+func builtinSQLiteExec(args ...object.Object) object.Object {
+	out, err := semantics.SQLiteExec(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinSQLiteBegin(args ...object.Object) object.Object {
+	return statusResult(semantics.SQLiteBegin(args))
+}
+
+func builtinSQLiteCommit(args ...object.Object) object.Object {
+	return statusResult(semantics.SQLiteCommit(args))
+}
+
+func builtinSQLiteRollback(args ...object.Object) object.Object {
+	return statusResult(semantics.SQLiteRollback(args))
+}
+
+func builtinNetListen(args ...object.Object) object.Object {
+	out, err := semantics.NetListen(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinNetAccept(args ...object.Object) object.Object {
+	out, err := semantics.NetAccept(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinNetConnect(args ...object.Object) object.Object {
+	out, err := semantics.NetConnect(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinNetSend(args ...object.Object) object.Object {
+	out, err := semantics.NetSend(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinNetRecv(args ...object.Object) object.Object {
+	out, err := semantics.NetRecv(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinNetRecvLine(args ...object.Object) object.Object {
+	out, err := semantics.NetRecvLine(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinNetClose(args ...object.Object) object.Object {
+	return statusResult(semantics.NetClose(args))
+}
+
+func builtinNetSetTimeout(args ...object.Object) object.Object {
+	return statusResult(semantics.NetSetTimeout(args))
+}
+
+func builtinNetUDPBind(args ...object.Object) object.Object {
+	out, err := semantics.NetUDPBind(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinNetUDPSend(args ...object.Object) object.Object {
+	out, err := semantics.NetUDPSend(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinNetUDPRecv(args ...object.Object) object.Object {
+	out, err := semantics.NetUDPRecv(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinHTTPListen(args ...object.Object) object.Object {
+	out, err := semantics.HTTPListen(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinHTTPNext(args ...object.Object) object.Object {
+	out, err := semantics.HTTPNext(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinHTTPRespond(args ...object.Object) object.Object {
+	out, err := semantics.HTTPRespond(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinHTTPClose(args ...object.Object) object.Object {
+	return statusResult(semantics.HTTPClose(args))
+}
+
+func builtinWSAccept(args ...object.Object) object.Object {
+	out, err := semantics.WSAccept(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinWSSend(args ...object.Object) object.Object {
+	return statusResult(semantics.WSSend(args))
+}
+
+func builtinWSRecv(args ...object.Object) object.Object {
+	out, err := semantics.WSRecv(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinWSClose(args ...object.Object) object.Object {
+	return statusResult(semantics.WSClose(args))
+}
+
+func builtinProcRun(args ...object.Object) object.Object {
+	out, err := semantics.ProcRun(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinProcSpawn(args ...object.Object) object.Object {
+	out, err := semantics.ProcSpawn(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinProcWrite(args ...object.Object) object.Object {
+	out, err := semantics.ProcWrite(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinProcCloseStdin(args ...object.Object) object.Object {
+	return statusResult(semantics.ProcCloseStdin(args))
+}
+
+func builtinProcReadLine(args ...object.Object) object.Object {
+	out, err := semantics.ProcReadLine(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinProcReadErrLine(args ...object.Object) object.Object {
+	out, err := semantics.ProcReadErrLine(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinProcWait(args ...object.Object) object.Object {
+	out, err := semantics.ProcWait(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinProcKill(args ...object.Object) object.Object {
+	return statusResult(semantics.ProcKill(args))
+}
+
+func builtinGeomOverlaps(args ...object.Object) object.Object {
+	out, err := semantics.GeomOverlaps(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinGeomIntersection(args ...object.Object) object.Object {
+	out, err := semantics.GeomIntersection(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinGeomContains(args ...object.Object) object.Object {
+	out, err := semantics.GeomContains(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinGeomSegmentHit(args ...object.Object) object.Object {
+	out, err := semantics.GeomSegmentHit(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinGeomSweep(args ...object.Object) object.Object {
+	out, err := semantics.GeomSweep(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func statusResult(err error) object.Object {
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinUnique(args ...object.Object) object.Object {
+	out, err := semantics.Unique(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinChunk(args ...object.Object) object.Object {
+	out, err := semantics.Chunk(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinWindows(args ...object.Object) object.Object {
+	out, err := semantics.Windows(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinFlatten(args ...object.Object) object.Object {
+	out, err := semantics.Flatten(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinGroupBy(args ...object.Object) object.Object {
+	return &object.Error{Message: "group_by() is not directly callable"}
+}
+
+func builtinSeq(args ...object.Object) object.Object {
+	out, err := semantics.NewSeq(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinStopwatch(args ...object.Object) object.Object {
+	out, err := semantics.NewStopwatch(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinTimeIt(args ...object.Object) object.Object {
+	return &object.Error{Message: "time_it() is not directly callable"}
+}
+
+func builtinHeapDump(args ...object.Object) object.Object {
+	return &object.Error{Message: "heap_dump() is not directly callable"}
+}
+
+func builtinSet(args ...object.Object) object.Object {
+	out, err := semantics.NewSetFrom(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return out
+}
+
+func builtinJoin(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 2, got %d", len(args))}
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return &object.Error{Message: "join() first argument must be ARRAY"}
+	}
+	sep, ok := args[1].(*object.String)
+	if !ok {
+		return &object.Error{Message: "join() separator must be STRING"}
+	}
+	parts := make([]string, len(arr.Elements))
+	for i, el := range arr.Elements {
+		s, ok := el.(*object.String)
+		if !ok {
+			return &object.Error{Message: "join() array elements must be STRING"}
+		}
+		parts[i] = s.Value
+	}
+	return &object.String{Value: strings.Join(parts, sep.Value)}
+}
+
+func builtinKeys(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1, got %d", len(args))}
+	}
+	d, ok := args[0].(*object.Dict)
+	if !ok {
+		return &object.Error{Message: "keys() expects DICT"}
+	}
+	pairs := object.SortedDictPairs(d)
+	out := make([]object.Object, 0, len(pairs))
+	for _, pair := range pairs {
+		out = append(out, pair.Key)
+	}
+	return &object.Array{Elements: out}
+}
+
+func builtinValues(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1, got %d", len(args))}
+	}
+	d, ok := args[0].(*object.Dict)
+	if !ok {
+		return &object.Error{Message: "values() expects DICT"}
+	}
+	pairs := object.SortedDictPairs(d)
+	out := make([]object.Object, 0, len(pairs))
+	for _, pair := range pairs {
+		out = append(out, pair.Value)
+	}
+	return &object.Array{Elements: out}
+}
+
+func builtinPush(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 2, got %d", len(args))}
+	}
+	a, ok := args[0].(*object.Array)
+	if !ok {
+		return &object.Error{Message: "push() first argument must be ARRAY"}
+	}
+	return a.Append(args[1])
+}
+
+func builtinCount(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: "count() expects 2 arguments"}
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return &object.Error{Message: "count() expects ARRAY as first argument"}
+	}
+	target := args[1]
+	var count int64
+	for _, el := range arr.Elements {
+		eq, err := semantics.Compare("==", el, target)
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+		if eq {
+			count++
+		}
+	}
+	return &object.Integer{Value: count}
+}
+
+func builtinRemove(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: "remove() expects 2 arguments"}
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return &object.Error{Message: "remove() expects ARRAY as first argument"}
+	}
+	target := args[1]
+	for i, el := range arr.Elements {
+		eq, err := semantics.Compare("==", el, target)
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+		if eq {
+			arr.Own()
+			arr.Elements = append(arr.Elements[:i], arr.Elements[i+1:]...)
+			return nativeBool(true)
+		}
+	}
+	return nativeBool(false)
+}
+
+func builtinGet(args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return &object.Error{Message: "get() expects 2 or 3 arguments"}
+	}
+	d, ok := args[0].(*object.Dict)
+	if !ok {
+		return &object.Error{Message: "get() expects DICT as first argument"}
+	}
+	hk, ok := object.HashKeyOf(args[1])
+	if !ok {
+		return &object.Error{Message: "unusable as dict key: " + string(args[1].Type())}
+	}
+	if pair, exists := d.Pairs[hk]; exists {
+		return pair.Value
+	}
+	if len(args) == 3 {
+		return args[2]
+	}
+	return nilObj
+}
+
+func builtinPop(args ...object.Object) object.Object {
+	switch len(args) {
+	case 1:
+		arr, ok := args[0].(*object.Array)
+		if !ok {
+			return &object.Error{Message: "pop() expects ARRAY as first argument when called with 1 argument"}
+		}
+		if len(arr.Elements) == 0 {
+			return &object.Error{Message: "pop from empty array"}
+		}
+		last := arr.Elements[len(arr.Elements)-1]
+		arr.Elements = arr.Elements[:len(arr.Elements)-1]
+		return last
+	case 2, 3:
+		d, ok := args[0].(*object.Dict)
+		if !ok {
+			return &object.Error{Message: "pop() expects DICT as first argument when called with 2 or 3 arguments"}
+		}
+		if d.Frozen {
+			return &object.Error{Message: semantics.ReadOnlyExportsMessage}
+		}
+		hk, ok := object.HashKeyOf(args[1])
+		if !ok {
+			return &object.Error{Message: "unusable as dict key: " + string(args[1].Type())}
+		}
+		if pair, exists := d.Pairs[hk]; exists {
+			delete(d.Pairs, hk)
+			return pair.Value
+		}
+		if len(args) == 3 {
+			return args[2]
+		}
+		return &object.Error{Message: "key not found"}
+	default:
+		return &object.Error{Message: "pop() expects 1 argument (array) or 2/3 arguments (dict)"}
+	}
+}
+
+func builtinError(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1 to 3, got %d", len(args))}
+	}
+
+	var msg string
+	switch v := args[0].(type) {
+	case *object.String:
+		msg = v.Value
+	default:
+		msg = v.Inspect()
+	}
+
+	errObj := &object.Error{Message: msg, IsValue: true}
+	if len(args) >= 2 {
+		switch codeObj := args[1].(type) {
+		case *object.Integer:
+			errObj.Code = codeObj.Value
+		case *object.String:
+			if codeObj.Value == "" {
+				return &object.Error{Message: "error code must not be empty"}
+			}
+			errObj.Tag = codeObj.Value
+		case *object.Nil:
+		default:
+			return &object.Error{Message: "error code must be integer or string"}
+		}
+	}
+	if len(args) == 3 {
+		data, ok := args[2].(*object.Dict)
+		if !ok {
+			return &object.Error{Message: fmt.Sprintf("error data must be DICT, got %s", args[2].Type())}
+		}
+		errObj.Data = data
+	}
+
+	return errObj
+}
+
+// builtinErrorCode returns the code of an error value, or nil for anything
+// else, so std:errors can inspect caught values of any type.
+func builtinErrorCode(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1, got %d", len(args))}
+	}
+	errObj, ok := args[0].(*object.Error)
+	if !ok {
+		return nilObj
+	}
+	return errObj.CodeValue()
+}
+
+// builtinRange returns a range rather than an array: its elements are made
+// as a loop reaches them, and builtins other than len, str and print get
+// the array it stands for.
+func builtinRange(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 && len(args) != 3 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1, 2, or 3, got %d", len(args))}
+	}
+
+	toInt := func(o object.Object) (*object.Integer, bool) {
+		i, ok := o.(*object.Integer)
+		return i, ok
+	}
+
+	var start, end, step int64
+	step = 1
+
+	if len(args) == 1 {
+		n, ok := toInt(args[0])
+		if !ok {
+			return &object.Error{Message: "range() expects INTEGER arguments"}
+		}
+		start = 0
+		end = n.Value
+	} else if len(args) == 2 {
+		a, ok1 := toInt(args[0])
+		b, ok2 := toInt(args[1])
+		if !ok1 || !ok2 {
+			return &object.Error{Message: "range() expects INTEGER arguments"}
+		}
+		start = a.Value
+		end = b.Value
+	} else {
+		a, ok1 := toInt(args[0])
+		b, ok2 := toInt(args[1])
+		c, ok3 := toInt(args[2])
+		if !ok1 || !ok2 || !ok3 {
+			return &object.Error{Message: "range() expects INTEGER arguments"}
+		}
+		start = a.Value
+		end = b.Value
+		step = c.Value
+		if step == 0 {
+			return &object.Error{Message: "range() step cannot be 0"}
+		}
+	}
+
+	return &object.Range{Start: start, Stop: end, Step: step}
+}
+
+func builtinHasKey(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 2, got %d", len(args))}
+	}
+	d, ok := args[0].(*object.Dict)
+	if !ok {
+		return &object.Error{Message: "hasKey() first argument must be DICT"}
+	}
+	hk, ok := object.HashKeyOf(args[1])
+	if !ok {
+		return &object.Error{Message: "unusable as dict key: " + string(args[1].Type())}
+	}
+	_, exists := d.Pairs[hk]
+	if exists {
+		return nativeBool(true)
+	}
+	return nativeBool(false)
+}
+
+func builtinSort(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1, got %d", len(args))}
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return &object.Error{Message: "sort() expects ARRAY"}
+	}
+	els := make([]object.Object, len(arr.Elements))
+	copy(els, arr.Elements)
+	if len(els) < 2 {
+		return &object.Array{Elements: els}
+	}
+
+	switch els[0].Type() {
+	case object.INTEGER_OBJ:
+		for _, e := range els {
+			if e.Type() != object.INTEGER_OBJ {
+				return &object.Error{Message: "sort() requires all elements to be INTEGER"}
+			}
+		}
+		ints := make([]int64, len(els))
+		for i, e := range els {
+			ints[i] = e.(*object.Integer).Value
+		}
+		sort.Slice(ints, func(i, j int) bool { return ints[i] < ints[j] })
+		out := make([]object.Object, len(ints))
+		for i, v := range ints {
+			out[i] = &object.Integer{Value: v}
+		}
+		return &object.Array{Elements: out}
+
+	case object.STRING_OBJ:
+		for _, e := range els {
+			if e.Type() != object.STRING_OBJ {
+				return &object.Error{Message: "sort() requires all elements to be STRING"}
+			}
+		}
+		// Strings are immutable, so the sorted array shares them with the
+		// input instead of copying each one.
+		sort.SliceStable(els, func(i, j int) bool {
+			return els[i].(*object.String).Value < els[j].(*object.String).Value
+		})
+		return &object.Array{Elements: els}
+	default:
+		return &object.Error{Message: "sort() supports only INTEGER or STRING lists (v0.1)"}
+	}
+}
+
+func builtinMax(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1, got %d", len(args))}
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return &object.Error{Message: "max() expects ARRAY"}
+	}
+	if len(arr.Elements) == 0 {
+		return &object.Error{Message: "max() arg is an empty sequence"}
+	}
+
+	switch first := arr.Elements[0].(type) {
+	case *object.Integer, *object.Float:
+		maxFloat := 0.0
+		maxInt := int64(0)
+		hasFloat := false
+		if v, ok := first.(*object.Float); ok {
+			maxFloat = v.Value
+			hasFloat = true
+		} else {
+			maxInt = first.(*object.Integer).Value
+		}
+		for i := 1; i < len(arr.Elements); i++ {
+			switch v := arr.Elements[i].(type) {
+			case *object.Integer:
+				if hasFloat {
+					val := float64(v.Value)
+					if val > maxFloat {
+						maxFloat = val
+					}
+				} else if v.Value > maxInt {
+					maxInt = v.Value
+				}
+			case *object.Float:
+				if !hasFloat {
+					maxFloat = float64(maxInt)
+					hasFloat = true
+				}
+				if v.Value > maxFloat {
+					maxFloat = v.Value
+				}
+			default:
+				return &object.Error{Message: "max() requires all elements to be NUMBER"}
+			}
+		}
+		if hasFloat {
+			return &object.Float{Value: maxFloat}
+		}
+		return &object.Integer{Value: maxInt}
+
+	case *object.String:
+		maxStr := first.Value
+		for i := 1; i < len(arr.Elements); i++ {
+			v, ok := arr.Elements[i].(*object.String)
+			if !ok {
+				return &object.Error{Message: "max() requires all elements to be STRING"}
+			}
+			if v.Value > maxStr {
+				maxStr = v.Value
+			}
+		}
+		return &object.String{Value: maxStr}
+
+	default:
+		return &object.Error{Message: "max() requires NUMBER or STRING elements"}
+	}
+}
+
+func builtinAbs(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1, got %d", len(args))}
+	}
+	switch v := args[0].(type) {
+	case *object.Integer:
+		if v.Value < 0 {
+			return &object.Integer{Value: -v.Value}
+		}
+		return &object.Integer{Value: v.Value}
+	case *object.Float:
+		if v.Value < 0 {
+			return &object.Float{Value: -v.Value}
+		}
+		return &object.Float{Value: v.Value}
+	default:
+		return &object.Error{Message: "abs() expects NUMBER"}
+	}
+}
+
+func builtinSum(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1, got %d", len(args))}
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return &object.Error{Message: "sum() expects ARRAY"}
+	}
+	var totalInt int64
+	var totalFloat float64
+	hasFloat := false
+	for _, el := range arr.Elements {
+		switch v := el.(type) {
+		case *object.Integer:
+			if hasFloat {
+				totalFloat += float64(v.Value)
+			} else {
+				totalInt += v.Value
+			}
+		case *object.Float:
+			if !hasFloat {
+				totalFloat = float64(totalInt)
+				hasFloat = true
+			}
+			totalFloat += v.Value
+		default:
+			return &object.Error{Message: "sum() requires all elements to be NUMBER"}
+		}
+	}
+	if hasFloat {
+		return &object.Float{Value: totalFloat}
+	}
+	return &object.Integer{Value: totalInt}
+}
+
+func builtinReverse(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1, got %d", len(args))}
+	}
+	switch v := args[0].(type) {
+	case *object.Array:
+		out := make([]object.Object, len(v.Elements))
+		for i := range v.Elements {
+			out[len(v.Elements)-1-i] = v.Elements[i]
+		}
+		return &object.Array{Elements: out}
+	case *object.String:
+		runes := []rune(v.Value)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return &object.String{Value: string(runes)}
+	default:
+		return &object.Error{Message: "reverse() expects ARRAY or STRING"}
+	}
+}
+
+func builtinAny(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1, got %d", len(args))}
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return &object.Error{Message: "any() expects ARRAY"}
+	}
+	for _, el := range arr.Elements {
+		if isTruthy(el) {
+			return nativeBool(true)
+		}
+	}
+	return nativeBool(false)
+}
+
+func builtinAll(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1, got %d", len(args))}
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return &object.Error{Message: "all() expects ARRAY"}
+	}
+	for _, el := range arr.Elements {
+		if !isTruthy(el) {
+			return nativeBool(false)
+		}
+	}
+	return nativeBool(true)
+}
+
+func builtinMap(args ...object.Object) object.Object {
+	return &object.Error{Message: "map() is not directly callable"}
+}
+
+func builtinMean(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1, got %d", len(args))}
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return &object.Error{Message: "mean() expects ARRAY"}
+	}
+	if len(arr.Elements) == 0 {
+		return &object.Error{Message: "mean() arg is an empty sequence"}
+	}
+
+	totalInt := int64(0)
+	totalFloat := float64(0)
+	hasFloat := false
+	for _, el := range arr.Elements {
+		switch v := el.(type) {
+		case *object.Integer:
+			if hasFloat {
+				totalFloat += float64(v.Value)
+			} else {
+				totalInt += v.Value
+			}
+		case *object.Float:
+			if !hasFloat {
+				totalFloat = float64(totalInt)
+				hasFloat = true
+			}
+			totalFloat += v.Value
+		default:
+			return &object.Error{Message: "mean() requires all elements to be NUMBER"}
+		}
+	}
+
+	count := int64(len(arr.Elements))
+	if hasFloat {
+		return &object.Float{Value: totalFloat / float64(count)}
+	}
+	if totalInt%count == 0 {
+		return &object.Integer{Value: totalInt / count}
+	}
+	return &object.Float{Value: float64(totalInt) / float64(count)}
+}
+
+func builtinWriteFile(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 2, got %d", len(args))}
+	}
+	pathObj, ok := args[0].(*object.String)
+	if !ok {
+		return &object.Error{Message: "writeFile() expects STRING path"}
+	}
+	contentObj, ok := args[1].(*object.String)
+	if !ok {
+		return &object.Error{Message: "writeFile() expects STRING content"}
+	}
+	if err := os.WriteFile(pathObj.Value, []byte(contentObj.Value), 0644); err != nil {
+		return &object.Error{Message: "writeFile() failed: " + err.Error()}
+	}
+	return nilObj
+}
+
+func builtinMathFloor(args ...object.Object) object.Object {
+	v, err := builtinFloatArg("math_floor", args...)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.Integer{Value: int64(math.Floor(v))}
+}
+
+func builtinMathSqrt(args ...object.Object) object.Object {
+	v, err := builtinFloatArg("math_sqrt", args...)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.Float{Value: math.Sqrt(v)}
+}
+
+func builtinMathSin(args ...object.Object) object.Object {
+	v, err := builtinFloatArg("math_sin", args...)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.Float{Value: math.Sin(v)}
+}
+
+func builtinMathCos(args ...object.Object) object.Object {
+	v, err := builtinFloatArg("math_cos", args...)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.Float{Value: math.Cos(v)}
+}
+
+func builtinSqrt(args ...object.Object) object.Object {
+	return builtinMathSqrt(args...)
+}
+
+func builtinInput(args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return &object.Error{Message: "input() expects 0 or 1 arguments"}
+	}
+	prompt := ""
+	if len(args) == 1 {
+		str, ok := args[0].(*object.String)
+		if !ok {
+			return &object.Error{Message: "input() expects STRING prompt"}
+		}
+		prompt = str.Value
+	}
+	line, err := runtimeio.Input(prompt)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.String{Value: line}
+}
+
+func builtinGetPass(args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return &object.Error{Message: "getpass() expects 0 or 1 arguments"}
+	}
+	prompt := ""
+	if len(args) == 1 {
+		str, ok := args[0].(*object.String)
+		if !ok {
+			return &object.Error{Message: "getpass() expects STRING prompt"}
+		}
+		prompt = str.Value
+	}
+	line, err := runtimeio.GetPass(prompt)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.String{Value: line}
+}
+
+func builtinFloatArg(name string, args ...object.Object) (float64, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("%s expects 1 argument", name)
+	}
+	switch v := args[0].(type) {
+	case *object.Integer:
+		return float64(v.Value), nil
+	case *object.Float:
+		return v.Value, nil
+	default:
+		return 0, fmt.Errorf("%s expects NUMBER", name)
+	}
+}
+
+func builtinGfxOpen(args ...object.Object) object.Object {
+	if len(args) != 3 {
+		return &object.Error{Message: "gfx_open expects 3 arguments: (width, height, title)"}
+	}
+	w, ok := args[0].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "gfx_open expects INTEGER width"}
+	}
+	h, ok := args[1].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "gfx_open expects INTEGER height"}
+	}
+	title, ok := args[2].(*object.String)
+	if !ok {
+		return &object.Error{Message: "gfx_open expects STRING title"}
+	}
+	if err := gfx.Open(int(w.Value), int(h.Value), title.Value); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxClose(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: "gfx_close expects no arguments"}
+	}
+	if err := gfx.Close(); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxShouldClose(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: "gfx_shouldClose expects no arguments"}
+	}
+	return nativeBool(gfx.ShouldClose())
+}
+
+func builtinGfxBeginFrame(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: "gfx_beginFrame expects no arguments"}
+	}
+	if err := gfx.BeginFrame(); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxEndFrame(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: "gfx_endFrame expects no arguments"}
+	}
+	if err := gfx.EndFrame(); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxClear(args ...object.Object) object.Object {
+	if len(args) != 4 {
+		return &object.Error{Message: "gfx_clear expects 4 arguments: (r, g, b, a)"}
+	}
+	r, ok := gfxNumber(args[0])
+	if !ok {
+		return &object.Error{Message: "gfx_clear expects NUMBER channels"}
+	}
+	g, ok := gfxNumber(args[1])
+	if !ok {
+		return &object.Error{Message: "gfx_clear expects NUMBER channels"}
+	}
+	b, ok := gfxNumber(args[2])
+	if !ok {
+		return &object.Error{Message: "gfx_clear expects NUMBER channels"}
+	}
+	a, ok := gfxNumber(args[3])
+	if !ok {
+		return &object.Error{Message: "gfx_clear expects NUMBER channels"}
+	}
+	if err := gfx.Clear(r, g, b, a); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxRect(args ...object.Object) object.Object {
+	if len(args) != 8 {
+		return &object.Error{Message: "gfx_rect expects 8 arguments: (x, y, w, h, r, g, b, a)"}
+	}
+	x, ok := gfxNumber(args[0])
+	if !ok {
+		return &object.Error{Message: "gfx_rect expects NUMBER position/size"}
+	}
+	y, ok := gfxNumber(args[1])
+	if !ok {
+		return &object.Error{Message: "gfx_rect expects NUMBER position/size"}
+	}
+	w, ok := gfxNumber(args[2])
+	if !ok {
+		return &object.Error{Message: "gfx_rect expects NUMBER position/size"}
+	}
+	h, ok := gfxNumber(args[3])
+	if !ok {
+		return &object.Error{Message: "gfx_rect expects NUMBER position/size"}
+	}
+	r, ok := gfxNumber(args[4])
+	if !ok {
+		return &object.Error{Message: "gfx_rect expects NUMBER channels"}
+	}
+	g, ok := gfxNumber(args[5])
+	if !ok {
+		return &object.Error{Message: "gfx_rect expects NUMBER channels"}
+	}
+	b, ok := gfxNumber(args[6])
+	if !ok {
+		return &object.Error{Message: "gfx_rect expects NUMBER channels"}
+	}
+	a, ok := gfxNumber(args[7])
+	if !ok {
+		return &object.Error{Message: "gfx_rect expects NUMBER channels"}
+	}
+	if err := gfx.Rect(x, y, w, h, r, g, b, a); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxPixel(args ...object.Object) object.Object {
+	if len(args) != 6 {
+		return &object.Error{Message: "gfx_pixel expects 6 arguments: (x, y, r, g, b, a)"}
+	}
+	x, ok := args[0].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "gfx_pixel expects INTEGER x/y"}
+	}
+	y, ok := args[1].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "gfx_pixel expects INTEGER x/y"}
+	}
+	r, ok := args[2].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "gfx_pixel expects INTEGER channels"}
+	}
+	g, ok := args[3].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "gfx_pixel expects INTEGER channels"}
+	}
+	b, ok := args[4].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "gfx_pixel expects INTEGER channels"}
+	}
+	a, ok := args[5].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "gfx_pixel expects INTEGER channels"}
+	}
+	if err := gfx.Pixel(int(x.Value), int(y.Value), int(r.Value), int(g.Value), int(b.Value), int(a.Value)); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxTime(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: "gfx_time expects no arguments"}
+	}
+	v, err := gfx.TimeSeconds()
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.Float{Value: v}
+}
+
+func builtinGfxKeyDown(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: "gfx_keyDown expects 1 argument: (key)"}
+	}
+	key, ok := args[0].(*object.String)
+	if !ok {
+		return &object.Error{Message: "gfx_keyDown expects STRING key"}
+	}
+	v, err := gfx.KeyDown(key.Value)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nativeBool(v)
+}
+
+func builtinGfxMouseX(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: "gfx_mouseX expects no arguments"}
+	}
+	v, err := gfx.MouseX()
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.Integer{Value: int64(v)}
+}
+
+func builtinGfxMouseY(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: "gfx_mouseY expects no arguments"}
+	}
+	v, err := gfx.MouseY()
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.Integer{Value: int64(v)}
+}
+
+func builtinGfxPresent(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: "gfx_present expects 1 argument: (image)"}
+	}
+	img, ok := args[0].(*object.Image)
+	if !ok {
+		return &object.Error{Message: "gfx_present expects IMAGE"}
+	}
+	if err := gfx.PresentRGBA(img.Width, img.Height, img.Data); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxElapsed(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: "gfx_elapsed expects no arguments"}
+	}
+	v, err := gfx.Elapsed()
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.Float{Value: v}
+}
+
+func builtinGfxFrameCount(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: "gfx_frameCount expects no arguments"}
+	}
+	v, err := gfx.FrameCount()
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.Integer{Value: v}
+}
+
+func builtinGfxSetFPS(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: "gfx_setFPS expects 1 argument: (fps)"}
+	}
+	fps, ok := args[0].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "gfx_setFPS expects INTEGER fps"}
+	}
+	if err := gfx.SetFPS(int(fps.Value)); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxEvery(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: "gfx_every expects 2 arguments: (seconds, fn)"}
+	}
+	var seconds float64
+	switch v := args[0].(type) {
+	case *object.Integer:
+		seconds = float64(v.Value)
+	case *object.Float:
+		seconds = v.Value
+	default:
+		return &object.Error{Message: "gfx_every expects NUMBER seconds"}
+	}
+	switch args[1].(type) {
+	case *object.Function, *object.Closure, *object.Builtin, *object.Class, *object.BoundMethod:
+	default:
+		return &object.Error{Message: "gfx_every expects FUNCTION"}
+	}
+	id, err := gfx.Every(seconds, args[1])
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.Integer{Value: int64(id)}
+}
+
+func builtinGfxCancel(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: "gfx_cancel expects 1 argument: (timer)"}
+	}
+	id, ok := args[0].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "gfx_cancel expects INTEGER timer"}
+	}
+	v, err := gfx.Cancel(int(id.Value))
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nativeBool(v)
+}
+
+func builtinGfxFillPath(args ...object.Object) object.Object {
+	return gfxPaint("gfx_fillPath", args, gfx.FillPath)
+}
+
+func builtinGfxStrokePath(args ...object.Object) object.Object {
+	return gfxPaint("gfx_strokePath", args, gfx.StrokePath)
+}
+
+func gfxPaint(name string, args []object.Object, paint func([]gfx.PathOp, float64, float64, float64, float64) error) object.Object {
+	if len(args) != 5 {
+		return &object.Error{Message: name + " expects 5 arguments: (path, r, g, b, a)"}
+	}
+	ops, err := gfx.ParsePath(name, args[0])
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	var ch [4]float64
+	for i := range ch {
+		v, ok := gfxNumber(args[i+1])
+		if !ok {
+			return &object.Error{Message: name + " expects NUMBER channels"}
+		}
+		ch[i] = v
+	}
+	if err := paint(ops, ch[0], ch[1], ch[2], ch[3]); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxGradientPath(args ...object.Object) object.Object {
+	if len(args) != 7 {
+		return &object.Error{Message: "gfx_gradientPath expects 7 arguments: (path, x0, y0, x1, y1, from, to)"}
+	}
+	ops, err := gfx.ParsePath("gfx_gradientPath", args[0])
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	var pts [4]float64
+	for i := range pts {
+		v, ok := gfxNumber(args[i+1])
+		if !ok {
+			return &object.Error{Message: "gfx_gradientPath expects NUMBER endpoints"}
+		}
+		pts[i] = v
+	}
+	var from, to [4]float64
+	from[0], from[1], from[2], from[3], err = gfx.ParseColor("gfx_gradientPath", args[5])
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	to[0], to[1], to[2], to[3], err = gfx.ParseColor("gfx_gradientPath", args[6])
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	if err := gfx.GradientPath(ops, pts[0], pts[1], pts[2], pts[3], from, to); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxLineStyle(args ...object.Object) object.Object {
+	if len(args) != 3 {
+		return &object.Error{Message: "gfx_lineStyle expects 3 arguments: (width, cap, join)"}
+	}
+	width, ok := gfxNumber(args[0])
+	if !ok {
+		return &object.Error{Message: "gfx_lineStyle expects NUMBER width"}
+	}
+	lineCap, ok := args[1].(*object.String)
+	if !ok {
+		return &object.Error{Message: "gfx_lineStyle expects STRING cap"}
+	}
+	join, ok := args[2].(*object.String)
+	if !ok {
+		return &object.Error{Message: "gfx_lineStyle expects STRING join"}
+	}
+	if err := gfx.SetLineStyle(width, lineCap.Value, join.Value); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxPush(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: "gfx_push expects no arguments"}
+	}
+	if err := gfx.Push(); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxPop(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: "gfx_pop expects no arguments"}
+	}
+	if err := gfx.Pop(); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxTranslate(args ...object.Object) object.Object {
+	return gfxTransform("gfx_translate", "(x, y)", args, gfx.Translate)
+}
+
+func builtinGfxRotate(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: "gfx_rotate expects 1 argument: (radians)"}
+	}
+	theta, ok := gfxNumber(args[0])
+	if !ok {
+		return &object.Error{Message: "gfx_rotate expects NUMBER radians"}
+	}
+	if err := gfx.Rotate(theta); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxScale(args ...object.Object) object.Object {
+	return gfxTransform("gfx_scale", "(sx, sy)", args, gfx.Scale)
+}
+
+func gfxTransform(name, params string, args []object.Object, apply func(float64, float64) error) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: name + " expects 2 arguments: " + params}
+	}
+	x, ok := gfxNumber(args[0])
+	if !ok {
+		return &object.Error{Message: name + " expects NUMBER arguments"}
+	}
+	y, ok := gfxNumber(args[1])
+	if !ok {
+		return &object.Error{Message: name + " expects NUMBER arguments"}
+	}
+	if err := apply(x, y); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxCamera(args ...object.Object) object.Object {
+	if len(args) != 3 {
+		return &object.Error{Message: "gfx_camera expects 3 arguments: (x, y, zoom)"}
+	}
+	var v [3]float64
+	for i := range v {
+		n, ok := gfxNumber(args[i])
+		if !ok {
+			return &object.Error{Message: "gfx_camera expects NUMBER arguments"}
+		}
+		v[i] = n
+	}
+	if err := gfx.SetCamera(v[0], v[1], v[2]); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxResetCamera(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: "gfx_resetCamera expects no arguments"}
+	}
+	if err := gfx.ResetCamera(); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxResetTransform(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: "gfx_resetTransform expects no arguments"}
+	}
+	if err := gfx.ResetTransform(); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxScreenToWorld(args ...object.Object) object.Object {
+	return gfxConvert("gfx_screenToWorld", args, gfx.ScreenToWorld)
+}
+
+func builtinGfxWorldToScreen(args ...object.Object) object.Object {
+	return gfxConvert("gfx_worldToScreen", args, gfx.WorldToScreen)
+}
+
+func gfxConvert(name string, args []object.Object, convert func(float64, float64) (float64, float64, error)) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: name + " expects 2 arguments: (x, y)"}
+	}
+	x, ok := gfxNumber(args[0])
+	if !ok {
+		return &object.Error{Message: name + " expects NUMBER x/y"}
+	}
+	y, ok := gfxNumber(args[1])
+	if !ok {
+		return &object.Error{Message: name + " expects NUMBER x/y"}
+	}
+	cx, cy, err := convert(x, y)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.Array{Elements: []object.Object{&object.Float{Value: cx}, &object.Float{Value: cy}}}
+}
+
+func builtinGfxPixelScale(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: "gfx_pixelScale expects 1 argument: (scale)"}
+	}
+	n, ok := args[0].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "gfx_pixelScale expects INTEGER scale"}
+	}
+	if err := gfx.SetPixelScale(int(n.Value)); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxTune(args ...object.Object) object.Object {
+	if len(args) != 4 {
+		return &object.Error{Message: "gfx_tune expects 4 arguments: (name, default, min, max)"}
+	}
+	name, ok := args[0].(*object.String)
+	if !ok {
+		return &object.Error{Message: "gfx_tune expects STRING name"}
+	}
+	var v [3]float64
+	integer := true
+	for i := range v {
+		n, ok := gfxNumber(args[i+1])
+		if !ok {
+			return &object.Error{Message: "gfx_tune expects NUMBER default/min/max"}
+		}
+		if _, isInt := args[i+1].(*object.Integer); !isInt {
+			integer = false
+		}
+		v[i] = n
+	}
+	val, err := gfx.Tune(name.Value, v[0], v[1], v[2], integer)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	if integer {
+		return &object.Integer{Value: int64(val)}
+	}
+	return &object.Float{Value: val}
+}
+
+func builtinGfxScreenshot(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: "gfx_screenshot expects 1 argument: (path)"}
+	}
+	path, ok := args[0].(*object.String)
+	if !ok {
+		return &object.Error{Message: "gfx_screenshot expects STRING path"}
+	}
+	if err := gfx.Screenshot(path.Value); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxFixedStep(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: "gfx_fixedStep expects 1 argument: (seconds)"}
+	}
+	step, ok := gfxNumber(args[0])
+	if !ok {
+		return &object.Error{Message: "gfx_fixedStep expects NUMBER seconds"}
+	}
+	if err := gfx.SetFixedStep(step); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinGfxStepAlpha(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: "gfx_stepAlpha expects no arguments"}
+	}
+	v, err := gfx.StepAlpha()
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.Float{Value: v}
+}
+
+func builtinImageNew(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: "image_new expects 2 arguments: (width, height)"}
+	}
+	w, ok := args[0].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_new expects INTEGER width"}
+	}
+	h, ok := args[1].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_new expects INTEGER height"}
+	}
+	img, err := object.NewImage(int(w.Value), int(h.Value))
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return img
+}
+
+func builtinImageSet(args ...object.Object) object.Object {
+	if len(args) != 7 {
+		return &object.Error{Message: "image_set expects 7 arguments: (image, x, y, r, g, b, a)"}
+	}
+	img, ok := args[0].(*object.Image)
+	if !ok {
+		return &object.Error{Message: "image_set expects IMAGE"}
+	}
+	x, ok := args[1].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_set expects INTEGER x/y"}
+	}
+	y, ok := args[2].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_set expects INTEGER x/y"}
+	}
+	r, ok := args[3].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_set expects INTEGER channels"}
+	}
+	g, ok := args[4].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_set expects INTEGER channels"}
+	}
+	b, ok := args[5].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_set expects INTEGER channels"}
+	}
+	a, ok := args[6].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_set expects INTEGER channels"}
+	}
+	if err := img.SetPixel(int(x.Value), int(y.Value), int(r.Value), int(g.Value), int(b.Value), int(a.Value)); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinImageFill(args ...object.Object) object.Object {
+	if len(args) != 5 {
+		return &object.Error{Message: "image_fill expects 5 arguments: (image, r, g, b, a)"}
+	}
+	img, ok := args[0].(*object.Image)
+	if !ok {
+		return &object.Error{Message: "image_fill expects IMAGE"}
+	}
+	r, ok := args[1].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_fill expects INTEGER channels"}
+	}
+	g, ok := args[2].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_fill expects INTEGER channels"}
+	}
+	b, ok := args[3].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_fill expects INTEGER channels"}
+	}
+	a, ok := args[4].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_fill expects INTEGER channels"}
+	}
+	if err := img.Fill(int(r.Value), int(g.Value), int(b.Value), int(a.Value)); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinImageFillRect(args ...object.Object) object.Object {
+	if len(args) != 9 {
+		return &object.Error{Message: "image_fill_rect expects 9 arguments: (image, x, y, w, h, r, g, b, a)"}
+	}
+	img, ok := args[0].(*object.Image)
+	if !ok {
+		return &object.Error{Message: "image_fill_rect expects IMAGE"}
+	}
+	x, ok := args[1].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_fill_rect expects INTEGER x/y/w/h"}
+	}
+	y, ok := args[2].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_fill_rect expects INTEGER x/y/w/h"}
+	}
+	w, ok := args[3].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_fill_rect expects INTEGER x/y/w/h"}
+	}
+	h, ok := args[4].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_fill_rect expects INTEGER x/y/w/h"}
+	}
+	r, ok := args[5].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_fill_rect expects INTEGER channels"}
+	}
+	g, ok := args[6].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_fill_rect expects INTEGER channels"}
+	}
+	b, ok := args[7].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_fill_rect expects INTEGER channels"}
+	}
+	a, ok := args[8].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "image_fill_rect expects INTEGER channels"}
+	}
+	if err := img.FillRect(int(x.Value), int(y.Value), int(w.Value), int(h.Value), int(r.Value), int(g.Value), int(b.Value), int(a.Value)); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinImageFade(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: "image_fade expects 2 arguments: (image, amount)"}
+	}
+	img, ok := args[0].(*object.Image)
+	if !ok {
+		return &object.Error{Message: "image_fade expects IMAGE"}
+	}
+	amount, ok := gfxNumber(args[1])
+	if !ok {
+		return &object.Error{Message: "image_fade expects NUMBER amount"}
+	}
+	if err := img.Fade(amount); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinImageFadeWhite(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: "image_fade_white expects 2 arguments: (image, amount)"}
+	}
+	img, ok := args[0].(*object.Image)
+	if !ok {
+		return &object.Error{Message: "image_fade_white expects IMAGE"}
+	}
+	amount, ok := gfxNumber(args[1])
+	if !ok {
+		return &object.Error{Message: "image_fade_white expects NUMBER amount"}
+	}
+	if err := img.FadeToWhite(amount); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nilObj
+}
+
+func builtinImageWidth(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: "image_width expects 1 argument: (image)"}
+	}
+	img, ok := args[0].(*object.Image)
+	if !ok {
+		return &object.Error{Message: "image_width expects IMAGE"}
+	}
+	return &object.Integer{Value: int64(img.Width)}
+}
+
+func builtinImageHeight(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: "image_height expects 1 argument: (image)"}
+	}
+	img, ok := args[0].(*object.Image)
+	if !ok {
+		return &object.Error{Message: "image_height expects IMAGE"}
+	}
+	return &object.Integer{Value: int64(img.Height)}
+}
+
+func gfxNumber(o object.Object) (float64, bool) {
+	switch v := o.(type) {
+	case *object.Integer:
+		return float64(v.Value), true
+	case *object.Float:
+		return v.Value, true
+	default:
+		return 0, false
+	}
+}
+
+var nilObj = &object.Nil{}
+
+func nativeBool(b bool) object.Object {
+	return &object.Boolean{Value: b}
+}
+
+func isTruthy(o object.Object) bool {
+	return semantics.IsTruthy(o)
+}
@@ -1,4 +1,4 @@
-package vm
+package builtins
 
 import (
 	"testing"
@@ -27,3 +27,14 @@ func TestBuiltinMeanEmptySequence(t *testing.T) {
 		t.Fatalf("unexpected error message: %q", errObj.Message)
 	}
 }
+
+func TestBuiltinMaxEmptySequence(t *testing.T) {
+	res := builtinMax(&object.Array{Elements: []object.Object{}})
+	errObj, ok := res.(*object.Error)
+	if !ok {
+		t.Fatalf("expected Error, got %T", res)
+	}
+	if errObj.Message != "max() arg is an empty sequence" {
+		t.Fatalf("unexpected error message: %q", errObj.Message)
+	}
+}
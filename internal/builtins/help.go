@@ -0,0 +1,130 @@
+package builtins
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"welle/internal/docs"
+	"welle/internal/object"
+	"welle/std"
+)
+
+// helpNames maps each builtin to the name help() describes it by. It is
+// filled in init because builtinHelp is itself in table.
+var helpNames map[*object.Builtin]string
+
+func init() {
+	helpNames = make(map[*object.Builtin]string, len(table))
+	for _, name := range Names() {
+		b := table[index[name]]
+		if _, seen := helpNames[b]; !seen {
+			helpNames[b] = name
+			continue
+		}
+		// Of several aliases, prefer one that has docs.
+		if _, ok := docs.LookupBuiltin(helpNames[b]); !ok {
+			if _, ok := docs.LookupBuiltin(name); ok {
+				helpNames[b] = name
+			}
+		}
+	}
+}
+
+// builtinHelp prints the signature and documentation of a builtin, a
+// function, or a symbol named by a string as `welle doc` takes it ("map",
+// "std:math", "std:math.add"). A function's docs are the `//` comment
+// lines directly above its declaration.
+func builtinHelp(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1, got %d", len(args))}
+	}
+	var text string
+	switch v := args[0].(type) {
+	case *object.Builtin:
+		name := helpNames[v]
+		b, ok := docs.LookupBuiltin(name)
+		if !ok {
+			b = docs.Builtin{Signature: name + "(...)"}
+		}
+		text = docs.Format(b.Signature, b.FullDoc())
+	case *object.Function:
+		params := make([]string, len(v.Parameters))
+		for i, p := range v.Parameters {
+			params[i] = p.String()
+		}
+		text = funcHelp(v.Name, v.File, withDefaults(params, v.Defaults))
+	case *object.Closure:
+		params := v.Fn.LocalNames
+		if len(params) > v.Fn.NumParameters {
+			params = params[:v.Fn.NumParameters]
+		}
+		params = append([]string(nil), params...)
+		text = funcHelp(v.Fn.Name, v.Fn.File, withDefaults(params, v.Defaults))
+	case *object.String:
+		var err error
+		text, err = docs.Lookup(v.Value)
+		if err != nil {
+			return &object.Error{Message: "help(): " + err.Error()}
+		}
+	default:
+		return &object.Error{Message: "help() expects a function or a name, got " + string(args[0].Type())}
+	}
+	_, _ = fmt.Fprint(os.Stdout, text)
+	return nilObj
+}
+
+// withDefaults writes the default values of the last len(defaults) params
+// after their names, as `greeting = "hello"`.
+func withDefaults(params []string, defaults []object.Object) []string {
+	at := len(params) - len(defaults)
+	for i, d := range defaults {
+		if at+i < 0 {
+			continue
+		}
+		text := d.Inspect()
+		if s, ok := d.(*object.String); ok {
+			text = strconv.Quote(s.Value)
+		}
+		params[at+i] += " = " + text
+	}
+	return params
+}
+
+// funcHelp describes a user function, reading its doc comment from file.
+// Function literals, named "" or "<anon@line:col>", print as func(...).
+func funcHelp(name, file string, params []string) string {
+	if strings.HasPrefix(name, "<") {
+		name = ""
+	}
+	sig := name
+	if sig == "" {
+		sig = "func"
+	}
+	sig += "(" + strings.Join(params, ", ") + ")"
+	doc := ""
+	if name != "" && file != "" {
+		if src, err := readSource(file); err == nil {
+			if e, ok := docs.Func(string(src), name); ok {
+				doc = e.Doc
+			}
+		}
+	}
+	return docs.Format(sig, doc)
+}
+
+// readSource reads file, or the std module it names when it is under the
+// embedded std's directory, which exists only in the binary (see
+// module.ReadSource; that package imports this one).
+func readSource(file string) ([]byte, error) {
+	b, err := os.ReadFile(file)
+	if err != nil && strings.HasPrefix(filepath.Base(filepath.Dir(file)), "embedded-") {
+		if b, serr := fs.ReadFile(std.FS, filepath.Base(file)); serr == nil {
+			return b, nil
+		}
+	}
+	return b, err
+}
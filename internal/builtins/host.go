@@ -0,0 +1,501 @@
+package builtins
+
+import (
+	"fmt"
+	"time"
+
+	"welle/internal/errcode"
+	"welle/internal/heapdump"
+	"welle/internal/httpserver"
+	"welle/internal/limits"
+	"welle/internal/object"
+	"welle/internal/semantics"
+	"welle/internal/trace"
+)
+
+// Host is the running backend, as seen by a builtin that needs more than its
+// arguments: one that calls back into Welle functions, reads the caller's
+// scope, or drives the tracer. The interpreter and the VM each implement it,
+// and embedders such as the gfx loop call Welle through the same methods.
+type Host interface {
+	// Call applies fn to args. ok is false when fn raised an error; the
+	// host keeps it, and the caller must stop and return nil.
+	Call(fn object.Object, args ...object.Object) (res object.Object, ok bool)
+	// Scope returns the bindings visible to the calling code. ok is false
+	// when the host cannot see the caller's scope.
+	Scope() (locals, globals map[string]object.Object, ok bool)
+	// IsMain reports whether the calling code belongs to the entry module
+	// rather than one it imported. ok is false when the host cannot tell.
+	IsMain() (main, ok bool)
+	Tracer() *trace.Tracer
+	SetTracer(t *trace.Tracer)
+	// Deadline is when the innermost running flow_with_timeout must be done,
+	// or zero. The host raises a TIMEOUT error at its next check after it
+	// passes and clears it, so the error is raised once.
+	Deadline() time.Time
+	SetDeadline(t time.Time)
+	// Budget is the memory budget the calling code is charged against, or
+	// nil when the run has none.
+	Budget() *limits.Budget
+	// SetBudget makes b the budget the calling code is charged against
+	// until it is set again.
+	SetBudget(b *limits.Budget)
+	// LimitSteps lets the calling code run at most n more instructions,
+	// unless a tighter limit is already in force, until restore is called;
+	// restore puts the enclosing limit back, less what was run. Hosts that
+	// do not count instructions, such as the interpreter, ignore it.
+	LimitSteps(n int64) (restore func())
+}
+
+// HostFunc implements a builtin on top of a Host. Like a plain builtin it
+// returns a raised *object.Error for bad arguments; it returns nil once a
+// Host.Call has failed.
+type HostFunc func(h Host, args []object.Object) object.Object
+
+// HostFuncs maps the builtins that need a Host to their implementations.
+// Backends consult it before calling a builtin directly, so a hook added
+// here runs the same way under the interpreter and the VM.
+var HostFuncs = map[*object.Builtin]HostFunc{
+	Named("map"):      hostMap,
+	Named("sort"):     hostSort,
+	Named("sort_by"):  hostSortBy,
+	Named("group_by"): hostGroupBy,
+	Named("locals"):   hostLocals,
+	Named("globals"):  hostGlobals,
+	Named("dir"):      hostDir,
+	Named("trace"):    hostTrace,
+	Named("is_main"):  hostIsMain,
+	Named("time_it"):  hostTimeIt,
+
+	Named("heap_dump"): hostHeapDump,
+
+	Named("flow_with_timeout"): hostWithTimeout,
+	Named("flow_sleep"):        hostSleep,
+
+	Named("http_call"): hostHTTPCall,
+}
+
+// CallHost runs b's host implementation on h, charging its result like Call.
+// ok is false when b has none and should go through Call instead. A nil
+// result with ok set means a callback failed and h holds the error.
+func CallHost(h Host, b *object.Builtin, args []object.Object, charge func(int64) *object.Error) (object.Object, bool) {
+	fn, ok := HostFuncs[b]
+	if !ok {
+		return nil, false
+	}
+	args, errObj := expandRanges(b, args, charge)
+	if errObj != nil {
+		return errObj, true
+	}
+	res := fn(h, args)
+	if res == nil {
+		return nil, true
+	}
+	if errObj, ok := res.(*object.Error); ok && !errObj.IsValue {
+		return res, true
+	}
+	if cost := resultCost(b, res); cost > 0 {
+		if errObj := charge(cost); errObj != nil {
+			return errObj, true
+		}
+	}
+	return res, true
+}
+
+// hostMethods holds the receiver methods that call back into Welle, by
+// receiver type. Their entries in the semantics method table only error.
+var hostMethods = map[object.Type]map[string]HostFunc{
+	object.SEQ_OBJ: {"to_list": hostSeqToList},
+}
+
+// CallHostMethod runs recv.name(args...) on h if it is a host method,
+// passing recv as the first argument, and charges its result like CallHost.
+// ok is false when the method is not one and should go through
+// semantics.CallMethod instead.
+func CallHostMethod(h Host, recv object.Object, name string, args []object.Object, charge func(int64) *object.Error) (object.Object, bool) {
+	fn, ok := hostMethods[recv.Type()][name]
+	if !ok {
+		return nil, false
+	}
+	res := fn(h, append([]object.Object{recv}, args...))
+	if res == nil {
+		return nil, true
+	}
+	if errObj, ok := res.(*object.Error); ok && !errObj.IsValue {
+		return res, true
+	}
+	if cost := resultCost(nil, res); cost > 0 {
+		if errObj := charge(cost); errObj != nil {
+			return errObj, true
+		}
+	}
+	return res, true
+}
+
+func isCallable(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Function, *object.Closure, *object.Builtin, *object.Class, *object.BoundMethod:
+		return true
+	}
+	return false
+}
+
+func hostMap(h Host, args []object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 2, got %d", len(args))}
+	}
+	arr, ok := args[1].(*object.Array)
+	if !ok {
+		return &object.Error{Message: "map() second argument must be ARRAY"}
+	}
+	if !isCallable(args[0]) {
+		return &object.Error{Message: "map() first argument must be FUNCTION"}
+	}
+	out := make([]object.Object, len(arr.Elements))
+	for i, el := range arr.Elements {
+		res, ok := h.Call(args[0], el)
+		if !ok {
+			return nil
+		}
+		out[i] = res
+	}
+	return &object.Array{Elements: out}
+}
+
+// hostSort handles sort(array, comparator); the one-argument form needs no
+// host and runs as a plain builtin.
+func hostSort(h Host, args []object.Object) object.Object {
+	if len(args) != 2 {
+		return builtinSort(args...)
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return &object.Error{Message: "sort() expects ARRAY"}
+	}
+	if !isCallable(args[1]) {
+		return &object.Error{Message: "sort() comparator must be FUNCTION"}
+	}
+	stopped := false
+	sorted, err := semantics.StableSort(arr.Elements, func(a, b object.Object) (bool, error) {
+		res, ok := h.Call(args[1], a, b)
+		if !ok {
+			stopped = true
+			return false, semantics.ErrSortStopped
+		}
+		return semantics.ComparatorLess(res)
+	})
+	if stopped {
+		return nil
+	}
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return &object.Array{Elements: sorted}
+}
+
+func hostSortBy(h Host, args []object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 2, got %d", len(args))}
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return &object.Error{Message: "sort_by() expects ARRAY"}
+	}
+	if !isCallable(args[1]) {
+		return &object.Error{Message: "sort_by() key must be FUNCTION"}
+	}
+	pairs := make([]object.Object, len(arr.Elements))
+	for i, el := range arr.Elements {
+		key, ok := h.Call(args[1], el)
+		if !ok {
+			return nil
+		}
+		pairs[i] = &object.Tuple{Elements: []object.Object{key, el}}
+	}
+	sorted, err := semantics.StableSort(pairs, func(a, b object.Object) (bool, error) {
+		return semantics.KeyLess(a.(*object.Tuple).Elements[0], b.(*object.Tuple).Elements[0])
+	})
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	for i, p := range sorted {
+		sorted[i] = p.(*object.Tuple).Elements[1]
+	}
+	return &object.Array{Elements: sorted}
+}
+
+// hostGroupBy handles group_by(array, key): a dict from each key to the
+// elements that produced it, in array order.
+func hostGroupBy(h Host, args []object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 2, got %d", len(args))}
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return &object.Error{Message: "group_by() expects ARRAY"}
+	}
+	if !isCallable(args[1]) {
+		return &object.Error{Message: "group_by() key must be FUNCTION"}
+	}
+	groups := &object.Dict{Pairs: make(map[object.HashKey]object.DictPair)}
+	for _, el := range arr.Elements {
+		key, ok := h.Call(args[1], el)
+		if !ok {
+			return nil
+		}
+		hk, ok := object.HashKeyOf(key)
+		if !ok {
+			return &object.Error{Message: fmt.Sprintf("unusable as dict key: %s", key.Type())}
+		}
+		pair, seen := groups.Pairs[hk]
+		if !seen {
+			pair = object.DictPair{Key: key, Value: &object.Array{}}
+		}
+		group := pair.Value.(*object.Array)
+		group.Elements = append(group.Elements, el)
+		groups.Pairs[hk] = pair
+	}
+	return groups
+}
+
+// hostTimeIt implements time_it(fn, n): fn is called with no arguments n
+// times and timed on the monotonic clock.
+func hostTimeIt(h Host, args []object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 2, got %d", len(args))}
+	}
+	if !isCallable(args[0]) {
+		return &object.Error{Message: "time_it() first argument must be FUNCTION"}
+	}
+	res, ok, err := semantics.TimeIt(args[1], func() bool {
+		_, ok := h.Call(args[0])
+		return ok
+	})
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	if !ok {
+		return nil
+	}
+	return res
+}
+
+// hostSeqToList handles seq.to_list(): the pipeline's elements, pulled one
+// at a time, in a new array.
+func hostSeqToList(h Host, args []object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: fmt.Sprintf("to_list() takes 0 arguments, got %d", len(args)-1)}
+	}
+	cur := semantics.NewSeqCursor(args[0].(*object.Seq))
+	out := []object.Object{}
+	for {
+		val, ok, err := cur.Next(h.Call)
+		if err != nil {
+			return nil
+		}
+		if !ok {
+			return &object.Array{Elements: out}
+		}
+		out = append(out, val)
+	}
+}
+
+func hostLocals(h Host, args []object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 0, got %d", len(args))}
+	}
+	locals, _, ok := h.Scope()
+	if !ok {
+		return builtinLocals(args...)
+	}
+	return semantics.ScopeDict(locals)
+}
+
+func hostGlobals(h Host, args []object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 0, got %d", len(args))}
+	}
+	_, globals, ok := h.Scope()
+	if !ok {
+		return builtinGlobals(args...)
+	}
+	return semantics.ScopeDict(globals)
+}
+
+// hostDir handles dir() without arguments, which lists the caller's locals;
+// dir(value) needs no host.
+func hostDir(h Host, args []object.Object) object.Object {
+	if len(args) != 0 {
+		return builtinDir(args...)
+	}
+	locals, _, ok := h.Scope()
+	if !ok {
+		return builtinDir(args...)
+	}
+	return semantics.SortedNames(locals)
+}
+
+func hostIsMain(h Host, args []object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 0, got %d", len(args))}
+	}
+	main, ok := h.IsMain()
+	if !ok {
+		return builtinIsMain(args...)
+	}
+	return nativeBool(main)
+}
+
+// hostHeapDump implements heap_dump(): a snapshot of what the caller's
+// locals and globals reach, as a dict.
+func hostHeapDump(h Host, args []object.Object) object.Object {
+	if len(args) != 0 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 0, got %d", len(args))}
+	}
+	locals, globals, ok := h.Scope()
+	if !ok {
+		return builtinHeapDump(args...)
+	}
+	var roots []heapdump.Root
+	for name, val := range locals {
+		roots = append(roots, heapdump.Root{Name: name, Value: val})
+	}
+	for name, val := range globals {
+		roots = append(roots, heapdump.Root{Name: name, Value: val})
+	}
+	return heapdump.Take(roots, h.Budget()).Dict()
+}
+
+// hostTrace implements trace(on), returning whether tracing was on. Without
+// a -trace flag the first trace(true) starts tracing to stderr.
+func hostTrace(h Host, args []object.Object) object.Object {
+	on, err := semantics.TraceArg(args)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	t := h.Tracer()
+	if t == nil {
+		if !on {
+			return nativeBool(false)
+		}
+		t = trace.Stderr()
+		h.SetTracer(t)
+	}
+	return nativeBool(t.SetEnabled(on))
+}
+
+// hostWithTimeout implements flow_with_timeout(fn, ms): fn() under a
+// deadline ms from now, or under the enclosing one if that ends first.
+func hostWithTimeout(h Host, args []object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 2, got %d", len(args))}
+	}
+	if !isCallable(args[0]) {
+		return &object.Error{Message: "with_timeout() first argument must be FUNCTION"}
+	}
+	d, ok := millis(args[1])
+	if !ok {
+		return &object.Error{Message: "with_timeout() ms must be a non-negative NUMBER"}
+	}
+	prev := h.Deadline()
+	deadline := time.Now().Add(d)
+	if !prev.IsZero() && prev.Before(deadline) {
+		deadline = prev
+	}
+	h.SetDeadline(deadline)
+	res, ok := h.Call(args[0])
+	h.SetDeadline(prev)
+	if !ok {
+		return nil
+	}
+	return res
+}
+
+// hostHTTPCall implements http_call(req_id, handler, req): handler(req)
+// under the request's step and memory budget. It returns nil without
+// calling handler when the request was already answered or given up on.
+func hostHTTPCall(h Host, args []object.Object) object.Object {
+	if len(args) != 3 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 3, got %d", len(args))}
+	}
+	id, ok := args[0].(*object.Integer)
+	if !ok {
+		return &object.Error{Message: "http_call() expects INTEGER request id"}
+	}
+	if !isCallable(args[1]) {
+		return &object.Error{Message: "http_call() handler must be FUNCTION"}
+	}
+	budget, ok := httpserver.RequestBudget(id.Value)
+	if !ok {
+		return nilObj
+	}
+	if budget.MaxSteps > 0 {
+		defer h.LimitSteps(budget.MaxSteps)()
+	}
+	if budget.MaxMem > 0 {
+		prev := h.Budget()
+		h.SetBudget(prev.Child(budget.MaxMem))
+		defer h.SetBudget(prev)
+	}
+	res, ok := h.Call(args[1], args[2])
+	if !ok {
+		return nil
+	}
+	return res
+}
+
+// sleepSlice bounds how long flow_sleep blocks between checks for an
+// interrupt.
+const sleepSlice = 10 * time.Millisecond
+
+// hostSleep implements flow_sleep(ms). It wakes early for an interrupt,
+// which the backend raises right after, and raises the timeout itself when
+// the host's deadline cuts the sleep short.
+func hostSleep(h Host, args []object.Object) object.Object {
+	if len(args) != 1 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 1, got %d", len(args))}
+	}
+	d, ok := millis(args[0])
+	if !ok {
+		return &object.Error{Message: "sleep() ms must be a non-negative NUMBER"}
+	}
+	end := time.Now().Add(d)
+	deadline := h.Deadline()
+	cut := !deadline.IsZero() && deadline.Before(end)
+	if cut {
+		end = deadline
+	}
+	for !limits.InterruptPending() {
+		left := time.Until(end)
+		if left <= 0 {
+			if cut {
+				h.SetDeadline(time.Time{})
+				return TimeoutError()
+			}
+			break
+		}
+		time.Sleep(min(left, sleepSlice))
+	}
+	return nilObj
+}
+
+func millis(obj object.Object) (time.Duration, bool) {
+	var ms float64
+	switch v := obj.(type) {
+	case *object.Integer:
+		ms = float64(v.Value)
+	case *object.Float:
+		ms = v.Value
+	default:
+		return 0, false
+	}
+	if !(ms >= 0) {
+		return 0, false
+	}
+	return time.Duration(ms * float64(time.Millisecond)), true
+}
+
+// TimeoutError is the error a host raises when its deadline passes.
+func TimeoutError() *object.Error {
+	return &object.Error{Message: errcode.TimeoutMessage, Code: errcode.Timeout}
+}
@@ -0,0 +1,211 @@
+package builtins
+
+import (
+	"testing"
+	"time"
+
+	"welle/internal/errcode"
+	"welle/internal/limits"
+	"welle/internal/object"
+	"welle/internal/trace"
+)
+
+// fakeHost calls Go functions standing in for Welle ones and counts calls.
+type fakeHost struct {
+	locals   map[string]object.Object
+	scope    bool
+	main     bool
+	tracer   *trace.Tracer
+	calls    int
+	deadline time.Time
+}
+
+func (h *fakeHost) Call(fn object.Object, args ...object.Object) (object.Object, bool) {
+	h.calls++
+	res := fn.(*object.Builtin).Fn(args...)
+	if _, ok := res.(*object.Error); ok {
+		return nil, false
+	}
+	return res, true
+}
+
+func (h *fakeHost) Scope() (map[string]object.Object, map[string]object.Object, bool) {
+	return h.locals, h.locals, h.scope
+}
+
+func (h *fakeHost) IsMain() (bool, bool) {
+	return h.main, h.scope
+}
+
+func (h *fakeHost) Tracer() *trace.Tracer     { return h.tracer }
+func (h *fakeHost) SetTracer(t *trace.Tracer) { h.tracer = t }
+func (h *fakeHost) Deadline() time.Time       { return h.deadline }
+func (h *fakeHost) SetDeadline(t time.Time)   { h.deadline = t }
+func (h *fakeHost) Budget() *limits.Budget    { return nil }
+func (h *fakeHost) SetBudget(*limits.Budget)  {}
+func (h *fakeHost) LimitSteps(int64) func()   { return func() {} }
+
+func noCharge(int64) *object.Error { return nil }
+
+func TestCallHostSkipsPlainBuiltins(t *testing.T) {
+	if _, handled := CallHost(&fakeHost{}, Named("len"), nil, noCharge); handled {
+		t.Fatal("len should not have a host implementation")
+	}
+}
+
+func TestCallHostMap(t *testing.T) {
+	h := &fakeHost{}
+	var charged int64
+	arr := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, &object.String{Value: "ab"}}}
+	res, handled := CallHost(h, Named("map"), []object.Object{Named("str"), arr}, func(n int64) *object.Error {
+		charged += n
+		return nil
+	})
+	if !handled || res.Inspect() != "[1, ab]" {
+		t.Fatalf("map(str, ...) = %v, %v", res, handled)
+	}
+	if h.calls != 2 || charged != object.CostArray(2) {
+		t.Fatalf("calls=%d charged=%d", h.calls, charged)
+	}
+
+	// A failing callback stops the map and leaves the error with the host.
+	h = &fakeHost{}
+	arr = &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}}
+	res, handled = CallHost(h, Named("map"), []object.Object{Named("keys"), arr}, noCharge)
+	if !handled || res != nil || h.calls != 1 {
+		t.Fatalf("map(keys, ...) = %v, %v after %d calls", res, handled, h.calls)
+	}
+}
+
+func TestCallHostSortFallsBackWithoutComparator(t *testing.T) {
+	h := &fakeHost{}
+	arr := &object.Array{Elements: []object.Object{&object.Integer{Value: 2}, &object.Integer{Value: 1}}}
+	res, handled := CallHost(h, Named("sort"), []object.Object{arr}, noCharge)
+	if !handled || res.Inspect() != "[1, 2]" || h.calls != 0 {
+		t.Fatalf("sort(arr) = %v, %v after %d calls", res, handled, h.calls)
+	}
+}
+
+func TestCallHostGroupByChargesGroups(t *testing.T) {
+	h := &fakeHost{}
+	var charged int64
+	arr := &object.Array{Elements: []object.Object{
+		&object.String{Value: "a"}, &object.String{Value: "bb"}, &object.String{Value: "c"},
+	}}
+	res, handled := CallHost(h, Named("group_by"), []object.Object{arr, Named("len")}, func(n int64) *object.Error {
+		charged += n
+		return nil
+	})
+	if !handled || res.Inspect() != "#{1: [a, c], 2: [bb]}" {
+		t.Fatalf("group_by(arr, len) = %v, %v", res, handled)
+	}
+	if want := object.CostDict(2) + object.CostArray(2) + object.CostArray(1); h.calls != 3 || charged != want {
+		t.Fatalf("calls=%d charged=%d, want %d", h.calls, charged, want)
+	}
+}
+
+func TestCallHostTimeIt(t *testing.T) {
+	h := &fakeHost{}
+	var charged int64
+	res, handled := CallHost(h, Named("time_it"), []object.Object{Named("stopwatch"), &object.Integer{Value: 3}}, func(n int64) *object.Error {
+		charged += n
+		return nil
+	})
+	tup, ok := res.(*object.Tuple)
+	if !handled || !ok || len(tup.Names) != 3 || tup.Elements[2].Inspect() != "3" {
+		t.Fatalf("time_it(stopwatch, 3) = %v, %v", res, handled)
+	}
+	if want := object.CostTuple(3); h.calls != 3 || charged != want {
+		t.Fatalf("calls=%d charged=%d, want %d", h.calls, charged, want)
+	}
+
+	// A failing run stops the timing and leaves the error with the host.
+	h = &fakeHost{}
+	res, handled = CallHost(h, Named("time_it"), []object.Object{Named("keys"), &object.Integer{Value: 3}}, noCharge)
+	if !handled || res != nil || h.calls != 1 {
+		t.Fatalf("time_it(keys, 3) = %v, %v after %d calls", res, handled, h.calls)
+	}
+
+	res, _ = CallHost(&fakeHost{}, Named("time_it"), []object.Object{Named("stopwatch"), &object.Integer{Value: 0}}, noCharge)
+	if errObj, ok := res.(*object.Error); !ok || errObj.Message != "time_it() runs must be a positive INTEGER" {
+		t.Fatalf("time_it(stopwatch, 0) = %v", res)
+	}
+}
+
+func TestCallHostScope(t *testing.T) {
+	h := &fakeHost{locals: map[string]object.Object{"b": &object.Integer{Value: 2}, "a": &object.Integer{Value: 1}}, scope: true}
+	res, _ := CallHost(h, Named("dir"), nil, noCharge)
+	if res.Inspect() != "[a, b]" {
+		t.Fatalf("dir() = %s", res.Inspect())
+	}
+
+	h.scope = false
+	res, _ = CallHost(h, Named("locals"), nil, noCharge)
+	if errObj, ok := res.(*object.Error); !ok || errObj.Message != "locals() is not directly callable" {
+		t.Fatalf("locals() without a scope = %v", res)
+	}
+	res, _ = CallHost(h, Named("is_main"), nil, noCharge)
+	if errObj, ok := res.(*object.Error); !ok || errObj.Message != "is_main() is not directly callable" {
+		t.Fatalf("is_main() without a scope = %v", res)
+	}
+}
+
+func TestCallHostTraceStartsTracer(t *testing.T) {
+	h := &fakeHost{}
+	res, _ := CallHost(h, Named("trace"), []object.Object{&object.Boolean{Value: false}}, noCharge)
+	if res.Inspect() != "false" || h.tracer != nil {
+		t.Fatalf("trace(false) = %s, tracer %v", res.Inspect(), h.tracer)
+	}
+}
+
+func TestWithTimeoutKeepsEarlierDeadline(t *testing.T) {
+	outer := time.Now().Add(time.Hour)
+	h := &fakeHost{deadline: outer}
+	var seen time.Time
+	probe := &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		seen = h.deadline
+		return &object.Integer{Value: 7}
+	}}
+	res, handled := CallHost(h, Named("flow_with_timeout"), []object.Object{probe, &object.Integer{Value: 50}}, noCharge)
+	if !handled || res.Inspect() != "7" {
+		t.Fatalf("flow_with_timeout = %v, %v", res, handled)
+	}
+	if !seen.Before(outer) || time.Until(seen) > 50*time.Millisecond {
+		t.Fatalf("deadline during call = %v, want within 50ms", seen)
+	}
+	if !h.deadline.Equal(outer) {
+		t.Fatalf("deadline after call = %v, want %v restored", h.deadline, outer)
+	}
+
+	// An enclosing deadline that ends first stays in force.
+	soon := time.Now().Add(time.Millisecond)
+	h.deadline = soon
+	CallHost(h, Named("flow_with_timeout"), []object.Object{probe, &object.Integer{Value: 60_000}}, noCharge)
+	if !seen.Equal(soon) {
+		t.Fatalf("deadline during call = %v, want enclosing %v", seen, soon)
+	}
+}
+
+func TestSleepWakesAtDeadline(t *testing.T) {
+	h := &fakeHost{deadline: time.Now().Add(20 * time.Millisecond)}
+	start := time.Now()
+	res, handled := CallHost(h, Named("flow_sleep"), []object.Object{&object.Integer{Value: 10_000}}, noCharge)
+	if errObj, ok := res.(*object.Error); !handled || !ok || errObj.Code != errcode.Timeout {
+		t.Fatalf("flow_sleep = %v, %v; want a timeout", res, handled)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Fatalf("flow_sleep ignored the deadline, took %v", took)
+	}
+	if !h.deadline.IsZero() {
+		t.Fatal("the deadline should be cleared once it fires")
+	}
+
+	res, _ = CallHost(h, Named("flow_sleep"), []object.Object{&object.Float{Value: 1.5}}, noCharge)
+	if res.Inspect() != "nil" {
+		t.Fatalf("flow_sleep(1.5) = %v, want nil", res)
+	}
+	res, _ = CallHost(h, Named("flow_sleep"), []object.Object{&object.Integer{Value: -1}}, noCharge)
+	if errObj, ok := res.(*object.Error); !ok || errObj.IsValue {
+		t.Fatalf("flow_sleep(-1) = %v, want an error", res)
+	}
+}
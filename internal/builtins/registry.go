@@ -0,0 +1,524 @@
+// Package builtins implements the functions Welle code can call without an
+// import. The interpreter and the VM share one table: the compiler emits
+// indexes into it, both backends call the same *object.Builtin values, and
+// Call charges the memory a result allocates the same way for either, so a
+// builtin cannot behave differently depending on which backend runs it.
+package builtins
+
+import (
+	"math"
+	"sort"
+
+	"welle/internal/object"
+)
+
+// table holds every builtin at the index the compiler emits for it. Indexes
+// are part of the bytecode format: append new builtins at the end.
+var table = []*object.Builtin{
+	{Fn: builtinPrint},                            // index 0
+	{Fn: builtinLen},                              // 1
+	{Fn: builtinStr},                              // 2
+	{Fn: builtinJoin},                             // 3
+	{Fn: builtinKeys},                             // 4
+	{Fn: builtinValues},                           // 5
+	{Fn: builtinPush},                             // 6
+	{Fn: builtinCount},                            // 7
+	{Fn: builtinRemove},                           // 8
+	{Fn: builtinGet},                              // 9
+	{Fn: builtinPop},                              // 10
+	{Fn: builtinError},                            // 11
+	{Fn: builtinRange},                            // 12
+	{Fn: builtinHasKey},                           // 13
+	{Fn: builtinSort},                             // 14
+	{Fn: builtinWriteFile},                        // 15
+	{Fn: builtinMathFloor},                        // 16
+	{Fn: builtinMathSqrt},                         // 17
+	{Fn: builtinMathSin},                          // 18
+	{Fn: builtinMathCos},                          // 19
+	{Fn: builtinGfxOpen},                          // 20
+	{Fn: builtinGfxClose},                         // 21
+	{Fn: builtinGfxShouldClose},                   // 22
+	{Fn: builtinGfxBeginFrame},                    // 23
+	{Fn: builtinGfxEndFrame},                      // 24
+	{Fn: builtinGfxClear},                         // 25
+	{Fn: builtinGfxRect},                          // 26
+	{Fn: builtinGfxPixel},                         // 27
+	{Fn: builtinGfxTime},                          // 28
+	{Fn: builtinGfxKeyDown},                       // 29
+	{Fn: builtinGfxMouseX},                        // 30
+	{Fn: builtinGfxMouseY},                        // 31
+	{Fn: builtinGfxPresent},                       // 32
+	{Fn: builtinImageNew},                         // 33
+	{Fn: builtinImageSet},                         // 34
+	{Fn: builtinImageFill},                        // 35
+	{Fn: builtinImageWidth},                       // 36
+	{Fn: builtinImageHeight},                      // 37
+	{Fn: builtinImageFillRect},                    // 38
+	{Fn: builtinImageFade},                        // 39
+	{Fn: builtinImageFadeWhite},                   // 40
+	{Fn: builtinMax},                              // 41
+	{Fn: builtinAbs},                              // 42
+	{Fn: builtinSum},                              // 43
+	{Fn: builtinReverse},                          // 44
+	{Fn: builtinAny},                              // 45
+	{Fn: builtinAll},                              // 46
+	{Fn: builtinMap},                              // 47
+	{Fn: builtinMean},                             // 48
+	{Fn: builtinSqrt},                             // 49
+	{Fn: builtinInput},                            // 50
+	{Fn: builtinGetPass},                          // 51
+	{Fn: builtinGroupDigits},                      // 52
+	{Fn: builtinFormatFloat},                      // 53
+	{Fn: builtinFormatPercent},                    // 54
+	{Fn: builtinUnicodeNormalize},                 // 55
+	{Fn: builtinChecked("checked_add", "+")},      // 56
+	{Fn: builtinChecked("checked_sub", "-")},      // 57
+	{Fn: builtinChecked("checked_mul", "*")},      // 58
+	{Fn: builtinFloorDiv},                         // 59
+	{Fn: builtinFloorMod},                         // 60
+	{Fn: builtinRound},                            // 61
+	{Fn: builtinIntegral("floor", math.Floor)},    // 62
+	{Fn: builtinIntegral("ceil", math.Ceil)},      // 63
+	{Fn: builtinIntegral("trunc", math.Trunc)},    // 64
+	{Fn: builtinFloatClass("is_nan", math.IsNaN)}, // 65
+	{Fn: builtinFloatClass("is_inf", func(f float64) bool { return math.IsInf(f, 0) })}, // 66
+	{Fn: builtinApproxEq},          // 67
+	{Fn: builtinStatsMedian},       // 68
+	{Fn: builtinStatsMode},         // 69
+	{Fn: builtinStatsVariance},     // 70
+	{Fn: builtinStatsStddev},       // 71
+	{Fn: builtinStatsPercentile},   // 72
+	{Fn: builtinStatsHistogram},    // 73
+	{Fn: builtinSortBy},            // 74
+	{Fn: builtinUnique},            // 75
+	{Fn: builtinLocals},            // 76
+	{Fn: builtinGlobals},           // 77
+	{Fn: builtinDir},               // 78
+	{Fn: builtinTrace},             // 79
+	{Fn: builtinArgs},              // 80
+	{Fn: builtinCLIParse},          // 81
+	{Fn: builtinCLIHelp},           // 82
+	{Fn: builtinTOMLParse},         // 83
+	{Fn: builtinTOMLStringify},     // 84
+	{Fn: builtinYAMLParse},         // 85
+	{Fn: builtinINIParse},          // 86
+	{Fn: builtinSQLiteOpen},        // 87
+	{Fn: builtinSQLiteClose},       // 88
+	{Fn: builtinSQLiteQuery},       // 89
+	{Fn: builtinSQLiteExec},        // 90
+	{Fn: builtinSQLiteBegin},       // 91
+	{Fn: builtinSQLiteCommit},      // 92
+	{Fn: builtinSQLiteRollback},    // 93
+	{Fn: builtinNetListen},         // 94
+	{Fn: builtinNetAccept},         // 95
+	{Fn: builtinNetConnect},        // 96
+	{Fn: builtinNetSend},           // 97
+	{Fn: builtinNetRecv},           // 98
+	{Fn: builtinNetRecvLine},       // 99
+	{Fn: builtinNetClose},          // 100
+	{Fn: builtinNetSetTimeout},     // 101
+	{Fn: builtinNetUDPBind},        // 102
+	{Fn: builtinNetUDPSend},        // 103
+	{Fn: builtinNetUDPRecv},        // 104
+	{Fn: builtinHTTPListen},        // 105
+	{Fn: builtinHTTPNext},          // 106
+	{Fn: builtinHTTPRespond},       // 107
+	{Fn: builtinHTTPClose},         // 108
+	{Fn: builtinWSAccept},          // 109
+	{Fn: builtinWSSend},            // 110
+	{Fn: builtinWSRecv},            // 111
+	{Fn: builtinWSClose},           // 112
+	{Fn: builtinProcRun},           // 113
+	{Fn: builtinProcSpawn},         // 114
+	{Fn: builtinProcWrite},         // 115
+	{Fn: builtinProcCloseStdin},    // 116
+	{Fn: builtinProcReadLine},      // 117
+	{Fn: builtinProcReadErrLine},   // 118
+	{Fn: builtinProcWait},          // 119
+	{Fn: builtinProcKill},          // 120
+	{Fn: builtinGfxElapsed},        // 121
+	{Fn: builtinGfxFrameCount},     // 122
+	{Fn: builtinGfxSetFPS},         // 123
+	{Fn: builtinGfxEvery},          // 124
+	{Fn: builtinGfxCancel},         // 125
+	{Fn: builtinGfxFillPath},       // 126
+	{Fn: builtinGfxStrokePath},     // 127
+	{Fn: builtinGfxGradientPath},   // 128
+	{Fn: builtinGfxLineStyle},      // 129
+	{Fn: builtinGfxPush},           // 130
+	{Fn: builtinGfxPop},            // 131
+	{Fn: builtinGfxTranslate},      // 132
+	{Fn: builtinGfxRotate},         // 133
+	{Fn: builtinGfxScale},          // 134
+	{Fn: builtinGfxCamera},         // 135
+	{Fn: builtinGfxResetCamera},    // 136
+	{Fn: builtinGfxResetTransform}, // 137
+	{Fn: builtinGfxScreenToWorld},  // 138
+	{Fn: builtinGfxWorldToScreen},  // 139
+	{Fn: builtinGfxPixelScale},     // 140
+	{Fn: builtinGeomOverlaps},      // 141
+	{Fn: builtinGeomIntersection},  // 142
+	{Fn: builtinGeomContains},      // 143
+	{Fn: builtinGeomSegmentHit},    // 144
+	{Fn: builtinGeomSweep},         // 145
+	{Fn: builtinGfxTune},           // 146
+	{Fn: builtinGfxScreenshot},     // 147
+	{Fn: builtinGfxFixedStep},      // 148
+	{Fn: builtinGfxStepAlpha},      // 149
+	{Fn: builtinErrorCode},         // 150
+	{Fn: builtinWithTimeout},       // 151
+	{Fn: builtinSleep},             // 152
+	{Fn: builtinPP},                // 153
+	{Fn: builtinSetPrintOptions},   // 154
+	{Fn: builtinHelp},              // 155
+	{Fn: builtinIsMain},            // 156
+	{Fn: builtinChunk},             // 157
+	{Fn: builtinWindows},           // 158
+	{Fn: builtinFlatten},           // 159
+	{Fn: builtinGroupBy},           // 160
+	{Fn: builtinSeq},               // 161
+	{Fn: builtinTemplateRender},    // 162
+	{Fn: builtinSet},               // 163
+	{Fn: builtinEncodingEncode},    // 164
+	{Fn: builtinEncodingDecode},    // 165
+	{Fn: builtinQueryDecode},       // 166
+	{Fn: builtinQueryEncode},       // 167
+	{Fn: builtinStopwatch},         // 168
+	{Fn: builtinTimeIt},            // 169
+	{Fn: builtinHeapDump},          // 170
+	{Fn: builtinHTTPCall},          // 171
+}
+
+var index = map[string]int{
+	"print":              0,
+	"len":                1,
+	"str":                2,
+	"join":               3,
+	"keys":               4,
+	"values":             5,
+	"push":               6,
+	"append":             6,
+	"count":              7,
+	"remove":             8,
+	"get":                9,
+	"pop":                10,
+	"error":              11,
+	"range":              12,
+	"hasKey":             13,
+	"sort":               14,
+	"writeFile":          15,
+	"math_floor":         16,
+	"math_sqrt":          17,
+	"math_sin":           18,
+	"math_cos":           19,
+	"gfx_open":           20,
+	"gfx_close":          21,
+	"gfx_shouldClose":    22,
+	"gfx_beginFrame":     23,
+	"gfx_endFrame":       24,
+	"gfx_clear":          25,
+	"gfx_rect":           26,
+	"gfx_pixel":          27,
+	"gfx_time":           28,
+	"gfx_keyDown":        29,
+	"gfx_mouseX":         30,
+	"gfx_mouseY":         31,
+	"gfx_present":        32,
+	"image_new":          33,
+	"image_set":          34,
+	"image_fill":         35,
+	"image_width":        36,
+	"image_height":       37,
+	"image_fill_rect":    38,
+	"image_fade":         39,
+	"image_fade_white":   40,
+	"max":                41,
+	"abs":                42,
+	"sum":                43,
+	"reverse":            44,
+	"any":                45,
+	"all":                46,
+	"map":                47,
+	"mean":               48,
+	"sqrt":               49,
+	"input":              50,
+	"getpass":            51,
+	"group_digits":       52,
+	"format_float":       53,
+	"format_percent":     54,
+	"unicode_normalize":  55,
+	"checked_add":        56,
+	"checked_sub":        57,
+	"checked_mul":        58,
+	"floor_div":          59,
+	"floor_mod":          60,
+	"round":              61,
+	"floor":              62,
+	"ceil":               63,
+	"trunc":              64,
+	"is_nan":             65,
+	"is_inf":             66,
+	"approx_eq":          67,
+	"stats_median":       68,
+	"stats_mode":         69,
+	"stats_variance":     70,
+	"stats_stddev":       71,
+	"stats_percentile":   72,
+	"stats_histogram":    73,
+	"sort_by":            74,
+	"unique":             75,
+	"reversed":           44,
+	"locals":             76,
+	"globals":            77,
+	"dir":                78,
+	"trace":              79,
+	"args":               80,
+	"cli_parse":          81,
+	"cli_help":           82,
+	"toml_parse":         83,
+	"toml_stringify":     84,
+	"yaml_parse":         85,
+	"ini_parse":          86,
+	"sqlite_open":        87,
+	"sqlite_close":       88,
+	"sqlite_query":       89,
+	"sqlite_exec":        90,
+	"sqlite_begin":       91,
+	"sqlite_commit":      92,
+	"sqlite_rollback":    93,
+	"net_listen":         94,
+	"net_accept":         95,
+	"net_connect":        96,
+	"net_send":           97,
+	"net_recv":           98,
+	"net_recv_line":      99,
+	"net_close":          100,
+	"net_set_timeout":    101,
+	"net_udp_bind":       102,
+	"net_udp_send":       103,
+	"net_udp_recv":       104,
+	"http_listen":        105,
+	"http_next":          106,
+	"http_respond":       107,
+	"http_close":         108,
+	"ws_accept":          109,
+	"ws_send":            110,
+	"ws_recv":            111,
+	"ws_close":           112,
+	"proc_run":           113,
+	"proc_spawn":         114,
+	"proc_write":         115,
+	"proc_close_stdin":   116,
+	"proc_read_line":     117,
+	"proc_read_err_line": 118,
+	"proc_wait":          119,
+	"proc_kill":          120,
+	"gfx_elapsed":        121,
+	"gfx_frameCount":     122,
+	"gfx_setFPS":         123,
+	"gfx_every":          124,
+	"gfx_cancel":         125,
+	"gfx_fillPath":       126,
+	"gfx_strokePath":     127,
+	"gfx_gradientPath":   128,
+	"gfx_lineStyle":      129,
+	"gfx_push":           130,
+	"gfx_pop":            131,
+	"gfx_translate":      132,
+	"gfx_rotate":         133,
+	"gfx_scale":          134,
+	"gfx_camera":         135,
+	"gfx_resetCamera":    136,
+	"gfx_resetTransform": 137,
+	"gfx_screenToWorld":  138,
+	"gfx_worldToScreen":  139,
+	"gfx_pixelScale":     140,
+	"geom_overlaps":      141,
+	"geom_intersection":  142,
+	"geom_contains":      143,
+	"geom_segment_hit":   144,
+	"geom_sweep":         145,
+	"gfx_tune":           146,
+	"gfx_screenshot":     147,
+	"gfx_fixedStep":      148,
+	"gfx_stepAlpha":      149,
+	"error_code":         150,
+	"flow_with_timeout":  151,
+	"flow_sleep":         152,
+	"pp":                 153,
+	"set_print_options":  154,
+	"help":               155,
+	"is_main":            156,
+	"chunk":              157,
+	"windows":            158,
+	"flatten":            159,
+	"group_by":           160,
+	"seq":                161,
+	"template_render":    162,
+	"set":                163,
+	"encoding_encode":    164,
+	"encoding_decode":    165,
+	"query_decode":       166,
+	"query_encode":       167,
+	"stopwatch":          168,
+	"time_it":            169,
+	"heap_dump":          170,
+	"http_call":          171,
+}
+
+// Len returns the number of builtin slots.
+func Len() int { return len(table) }
+
+// At returns the builtin at index i.
+func At(i int) *object.Builtin { return table[i] }
+
+// Index returns the slot of the builtin called name.
+func Index(name string) (int, bool) {
+	i, ok := index[name]
+	return i, ok
+}
+
+// Lookup returns the builtin called name.
+func Lookup(name string) (*object.Builtin, bool) {
+	i, ok := index[name]
+	if !ok {
+		return nil, false
+	}
+	return table[i], true
+}
+
+// Named returns the builtin called name, or nil if there is none.
+func Named(name string) *object.Builtin {
+	b, _ := Lookup(name)
+	return b
+}
+
+// Names returns every builtin name, aliases included, in sorted order.
+func Names() []string {
+	out := make([]string, 0, len(index))
+	for name := range index {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+var imageNew = Named("image_new")
+
+// Call runs b with args and charges the memory its result allocates through
+// charge, which returns an error once the budget is spent. Errors b raises
+// are returned uncharged, since each backend raises them with its own stack;
+// error values from error() are charged like any other result.
+func Call(b *object.Builtin, args []object.Object, charge func(int64) *object.Error) object.Object {
+	if b == imageNew {
+		// Charge an image before allocating its pixels, so an oversized
+		// image_new fails on the budget instead of exhausting the process.
+		if w, h, ok := imageSize(args); ok {
+			if errObj := charge(object.CostImage(w, h)); errObj != nil {
+				return errObj
+			}
+			return b.Fn(args...)
+		}
+	}
+	args, errObj := expandRanges(b, args, charge)
+	if errObj != nil {
+		return errObj
+	}
+	res := b.Fn(args...)
+	if errObj, ok := res.(*object.Error); ok && !errObj.IsValue {
+		return res
+	}
+	if cost := resultCost(b, res); cost > 0 {
+		if errObj := charge(cost); errObj != nil {
+			return errObj
+		}
+	}
+	return res
+}
+
+// rangeArgs are the builtins that take a range as it is. Every other
+// builtin is handed the array a range stands for.
+var rangeArgs = map[*object.Builtin]bool{
+	Named("print"): true,
+	Named("pp"):    true,
+	Named("str"):   true,
+	Named("len"):   true,
+}
+
+// expandRanges returns args with each range replaced by a new array of its
+// elements, charged through charge, unless b is one of rangeArgs. args
+// itself is left alone: a backend may pass a slice of its stack.
+func expandRanges(b *object.Builtin, args []object.Object, charge func(int64) *object.Error) ([]object.Object, *object.Error) {
+	if rangeArgs[b] {
+		return args, nil
+	}
+	var out []object.Object
+	for i, arg := range args {
+		r, ok := arg.(*object.Range)
+		if !ok {
+			continue
+		}
+		if out == nil {
+			out = append([]object.Object(nil), args...)
+		}
+		if errObj := charge(object.CostArray(int(r.Len()))); errObj != nil {
+			return nil, errObj
+		}
+		out[i] = r.Array()
+	}
+	if out == nil {
+		return args, nil
+	}
+	return out, nil
+}
+
+// nestedResults are the builtins whose array or dict result holds arrays
+// they built too: chunk's runs, windows' windows, group_by's groups and
+// query_decode's repeated keys.
+var nestedResults = map[*object.Builtin]bool{
+	Named("chunk"):        true,
+	Named("windows"):      true,
+	Named("group_by"):     true,
+	Named("query_decode"): true,
+}
+
+// resultCost returns the charge for a value b just built. A tuple's
+// elements are fresh too (checked_add's pair, stats_histogram's arrays), as
+// are the elements of a nestedResults builtin's result.
+func resultCost(b *object.Builtin, res object.Object) int64 {
+	cost := object.CostOf(res)
+	switch v := res.(type) {
+	case *object.Tuple:
+		for _, el := range v.Elements {
+			cost += object.CostOf(el)
+		}
+	case *object.Array:
+		if nestedResults[b] {
+			for _, el := range v.Elements {
+				cost += object.CostOf(el)
+			}
+		}
+	case *object.Dict:
+		if nestedResults[b] {
+			for _, pair := range v.Pairs {
+				cost += object.CostOf(pair.Value)
+			}
+		}
+	}
+	return cost
+}
+
+func imageSize(args []object.Object) (int, int, bool) {
+	if len(args) != 2 {
+		return 0, 0, false
+	}
+	w, ok := args[0].(*object.Integer)
+	if !ok {
+		return 0, 0, false
+	}
+	h, ok := args[1].(*object.Integer)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(w.Value), int(h.Value), true
+}
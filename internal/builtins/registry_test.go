@@ -0,0 +1,254 @@
+package builtins
+
+import (
+	"testing"
+
+	"welle/internal/object"
+)
+
+func TestBuiltinNames(t *testing.T) {
+	expected := map[string]bool{
+		"print":              true,
+		"len":                true,
+		"str":                true,
+		"join":               true,
+		"keys":               true,
+		"values":             true,
+		"range":              true,
+		"append":             true,
+		"push":               true,
+		"count":              true,
+		"remove":             true,
+		"get":                true,
+		"pop":                true,
+		"hasKey":             true,
+		"sort":               true,
+		"max":                true,
+		"abs":                true,
+		"sum":                true,
+		"reverse":            true,
+		"any":                true,
+		"all":                true,
+		"map":                true,
+		"mean":               true,
+		"error":              true,
+		"writeFile":          true,
+		"sqrt":               true,
+		"input":              true,
+		"getpass":            true,
+		"math_floor":         true,
+		"math_sqrt":          true,
+		"math_sin":           true,
+		"math_cos":           true,
+		"gfx_open":           true,
+		"gfx_close":          true,
+		"gfx_shouldClose":    true,
+		"gfx_beginFrame":     true,
+		"gfx_endFrame":       true,
+		"gfx_clear":          true,
+		"gfx_rect":           true,
+		"gfx_pixel":          true,
+		"gfx_time":           true,
+		"gfx_keyDown":        true,
+		"gfx_mouseX":         true,
+		"gfx_mouseY":         true,
+		"gfx_present":        true,
+		"image_new":          true,
+		"image_set":          true,
+		"image_fill":         true,
+		"image_fill_rect":    true,
+		"image_fade":         true,
+		"image_fade_white":   true,
+		"image_width":        true,
+		"image_height":       true,
+		"group_digits":       true,
+		"format_float":       true,
+		"format_percent":     true,
+		"unicode_normalize":  true,
+		"checked_add":        true,
+		"checked_sub":        true,
+		"checked_mul":        true,
+		"floor_div":          true,
+		"floor_mod":          true,
+		"round":              true,
+		"floor":              true,
+		"ceil":               true,
+		"trunc":              true,
+		"is_nan":             true,
+		"is_inf":             true,
+		"approx_eq":          true,
+		"stats_median":       true,
+		"stats_mode":         true,
+		"stats_variance":     true,
+		"stats_stddev":       true,
+		"stats_percentile":   true,
+		"stats_histogram":    true,
+		"sort_by":            true,
+		"unique":             true,
+		"reversed":           true,
+		"locals":             true,
+		"globals":            true,
+		"dir":                true,
+		"trace":              true,
+		"args":               true,
+		"cli_parse":          true,
+		"cli_help":           true,
+		"toml_parse":         true,
+		"toml_stringify":     true,
+		"yaml_parse":         true,
+		"ini_parse":          true,
+		"sqlite_open":        true,
+		"sqlite_close":       true,
+		"sqlite_query":       true,
+		"sqlite_exec":        true,
+		"sqlite_begin":       true,
+		"sqlite_commit":      true,
+		"sqlite_rollback":    true,
+		"net_listen":         true,
+		"net_accept":         true,
+		"net_connect":        true,
+		"net_send":           true,
+		"net_recv":           true,
+		"net_recv_line":      true,
+		"net_close":          true,
+		"net_set_timeout":    true,
+		"net_udp_bind":       true,
+		"net_udp_send":       true,
+		"net_udp_recv":       true,
+		"http_listen":        true,
+		"http_next":          true,
+		"http_respond":       true,
+		"http_close":         true,
+		"ws_accept":          true,
+		"ws_send":            true,
+		"ws_recv":            true,
+		"ws_close":           true,
+		"proc_run":           true,
+		"proc_spawn":         true,
+		"proc_write":         true,
+		"proc_close_stdin":   true,
+		"proc_read_line":     true,
+		"proc_read_err_line": true,
+		"proc_wait":          true,
+		"proc_kill":          true,
+		"gfx_elapsed":        true,
+		"gfx_frameCount":     true,
+		"gfx_setFPS":         true,
+		"gfx_every":          true,
+		"gfx_cancel":         true,
+		"gfx_fillPath":       true,
+		"gfx_strokePath":     true,
+		"gfx_gradientPath":   true,
+		"gfx_lineStyle":      true,
+		"gfx_push":           true,
+		"gfx_pop":            true,
+		"gfx_translate":      true,
+		"gfx_rotate":         true,
+		"gfx_scale":          true,
+		"gfx_camera":         true,
+		"gfx_resetCamera":    true,
+		"gfx_resetTransform": true,
+		"gfx_screenToWorld":  true,
+		"gfx_worldToScreen":  true,
+		"gfx_pixelScale":     true,
+		"geom_overlaps":      true,
+		"geom_intersection":  true,
+		"geom_contains":      true,
+		"geom_segment_hit":   true,
+		"geom_sweep":         true,
+		"gfx_tune":           true,
+		"gfx_screenshot":     true,
+		"gfx_fixedStep":      true,
+		"gfx_stepAlpha":      true,
+		"error_code":         true,
+		"flow_with_timeout":  true,
+		"flow_sleep":         true,
+		"pp":                 true,
+		"set_print_options":  true,
+		"help":               true,
+		"is_main":            true,
+		"chunk":              true,
+		"windows":            true,
+		"flatten":            true,
+		"group_by":           true,
+		"seq":                true,
+		"template_render":    true,
+		"set":                true,
+		"encoding_encode":    true,
+		"encoding_decode":    true,
+		"query_decode":       true,
+		"query_encode":       true,
+		"stopwatch":          true,
+		"time_it":            true,
+		"heap_dump":          true,
+		"http_call":          true,
+	}
+
+	if len(index) != len(expected) {
+		t.Fatalf("expected %d builtins, got %d", len(expected), len(index))
+	}
+	for name := range expected {
+		if _, ok := index[name]; !ok {
+			t.Fatalf("missing builtin: %s", name)
+		}
+	}
+	for name := range index {
+		if !expected[name] {
+			t.Fatalf("unexpected builtin: %s", name)
+		}
+	}
+}
+
+func TestIndexCoversTable(t *testing.T) {
+	used := make([]bool, len(table))
+	for name, i := range index {
+		if i < 0 || i >= len(table) {
+			t.Fatalf("%s: index %d out of range", name, i)
+		}
+		used[i] = true
+	}
+	for i, ok := range used {
+		if !ok {
+			t.Fatalf("slot %d has no name", i)
+		}
+	}
+}
+
+func TestCallChargesResult(t *testing.T) {
+	var charged int64
+	charge := func(n int64) *object.Error {
+		charged += n
+		return nil
+	}
+	res := Call(Named("str"), []object.Object{&object.Integer{Value: 42}}, charge)
+	if s, ok := res.(*object.String); !ok || s.Value != "42" {
+		t.Fatalf("str(42) = %v", res)
+	}
+	if charged != object.CostStringBytes(2) {
+		t.Fatalf("charged %d, want %d", charged, object.CostStringBytes(2))
+	}
+
+	charged = 0
+	res = Call(Named("len"), nil, charge)
+	if _, ok := res.(*object.Error); !ok {
+		t.Fatalf("len() = %v, want error", res)
+	}
+	if charged != 0 {
+		t.Fatalf("raised error charged %d", charged)
+	}
+}
+
+func TestCallChargesImageBeforeAllocating(t *testing.T) {
+	limit := &object.Error{Message: "over budget"}
+	var charged int64
+	res := Call(Named("image_new"), []object.Object{&object.Integer{Value: 1 << 20}, &object.Integer{Value: 1 << 20}}, func(n int64) *object.Error {
+		charged = n
+		return limit
+	})
+	if res != limit {
+		t.Fatalf("image_new = %v, want the budget error", res)
+	}
+	if charged != object.CostImage(1<<20, 1<<20) {
+		t.Fatalf("charged %d, want %d", charged, object.CostImage(1<<20, 1<<20))
+	}
+}
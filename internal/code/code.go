@@ -57,12 +57,14 @@ const (
 	OpDeferSpread
 	OpReturnValue
 	OpReturn
+	OpYield // no operands; hands the popped value to the generator's caller and suspends
 
 	OpSetLocal
 	OpDefineLocal
 	OpGetLocal
 
 	OpClosure
+	OpDefaults // operand: default count (1 byte) (expects: closure, defaults...)
 	OpGetFree
 	OpSetFree
 	OpGetFreeCell
@@ -72,14 +74,24 @@ const (
 	OpArray       // operand: elementCount (2 bytes)
 	OpArrayAppend // no operands (expects: array, value)
 	OpTuple       // operand: elementCount (2 bytes)
+	OpNamedTuple  // operands: elementCount (2 bytes), shapeConst (2 bytes)
+	OpClass       // operands: valueCount (2 bytes), shapeConst (2 bytes); field defaults, then method closures
 	OpDict        // operand: pairCount (2 bytes)
+	OpArraySpread // operand: elementCount (2 bytes); Spread elements are expanded
+	OpDictSpread  // operand: pairCount (2 bytes); a Spread key (with a nil value) merges a dict
+	OpSet         // operand: elementCount (2 bytes)
 	OpIndex       // no operands
+	OpIndexChain  // operand: index count (1 byte) (expects: left, index...)
 	OpGetMember   // operand: nameConst (2 bytes)
 	OpSetMember   // operand: nameConst (2 bytes)
 	OpSetIndex
 	OpSlice       // no operands (expects: left, lowOrNull, highOrNull, stepOrNull)
+	OpSetSlice    // no operands (expects: left, lowOrNull, highOrNull, stepOrNull, value)
+	OpDelIndex    // no operands (expects: left, index)
+	OpDelSlice    // no operands (expects: left, lowOrNull, highOrNull, stepOrNull)
 	OpUnpackTuple // operand: elementCount (2 bytes)
 	OpUnpackStar  // operands: elementCount (2 bytes), starIndex (2 bytes)
+	OpUnpackNamed // operands: fieldCount (2 bytes), shapeConst (2 bytes)
 	OpSpread      // no operands (wraps value for spread)
 
 	OpImportModule // operand: constIndex (2 bytes) for path string literal
@@ -96,6 +108,17 @@ const (
 	OpIterInitComp // no operands
 	OpIterNext     // no operands
 	OpIterInitDict // no operands
+
+	OpJumpTable // operand: jump table constIndex (2 bytes)
+
+	OpMatchShape // operands: shape (1 byte), length (2 bytes); pops a value, pushes whether it has that shape
+	OpMatchEqual // no operands; pops a value and a pattern's value, pushes whether they are equal
+	OpCatchMatch // no operands; pops a catch filter and pushes whether it accepts the error under it
+
+	// Superinstructions, emitted only by the optimizer.
+	OpIncLocal       // operands: local (1 byte), integer constIndex (2 bytes)
+	OpGetLocalMember // operands: local (1 byte), nameConst (2 bytes)
+	OpCompareJump    // operands: comparison opcode (1 byte), jump address if false (2 bytes)
 )
 
 type Instructions []byte
@@ -149,10 +172,12 @@ var definitions = map[Opcode]*Definition{
 	OpDeferSpread:      {"OpDeferSpread", []int{1}},
 	OpReturnValue:      {"OpReturnValue", nil},
 	OpReturn:           {"OpReturn", nil},
+	OpYield:            {"OpYield", nil},
 	OpSetLocal:         {"OpSetLocal", []int{1}},
 	OpDefineLocal:      {"OpDefineLocal", []int{1, 2}},
 	OpGetLocal:         {"OpGetLocal", []int{1}},
 	OpClosure:          {"OpClosure", []int{2, 1}},
+	OpDefaults:         {"OpDefaults", []int{1}},
 	OpGetFree:          {"OpGetFree", []int{1}},
 	OpSetFree:          {"OpSetFree", []int{1}},
 	OpGetFreeCell:      {"OpGetFreeCell", []int{1}},
@@ -161,14 +186,24 @@ var definitions = map[Opcode]*Definition{
 	OpArray:            {"OpArray", []int{2}},
 	OpArrayAppend:      {"OpArrayAppend", nil},
 	OpTuple:            {"OpTuple", []int{2}},
+	OpNamedTuple:       {"OpNamedTuple", []int{2, 2}},
+	OpClass:            {"OpClass", []int{2, 2}},
 	OpDict:             {"OpDict", []int{2}},
+	OpArraySpread:      {"OpArraySpread", []int{2}},
+	OpDictSpread:       {"OpDictSpread", []int{2}},
+	OpSet:              {"OpSet", []int{2}},
 	OpIndex:            {"OpIndex", nil},
+	OpIndexChain:       {"OpIndexChain", []int{1}},
 	OpGetMember:        {"OpGetMember", []int{2}},
 	OpSetMember:        {"OpSetMember", []int{2}},
 	OpSetIndex:         {"OpSetIndex", nil},
 	OpSlice:            {"OpSlice", nil},
+	OpSetSlice:         {"OpSetSlice", nil},
+	OpDelIndex:         {"OpDelIndex", nil},
+	OpDelSlice:         {"OpDelSlice", nil},
 	OpUnpackTuple:      {"OpUnpackTuple", []int{2}},
 	OpUnpackStar:       {"OpUnpackStar", []int{2, 2}},
+	OpUnpackNamed:      {"OpUnpackNamed", []int{2, 2}},
 	OpSpread:           {"OpSpread", nil},
 	OpImportModule:     {"OpImportModule", []int{2}},
 	OpImportFrom:       {"OpImportFrom", []int{2, 2}},
@@ -183,6 +218,13 @@ var definitions = map[Opcode]*Definition{
 	OpIterInitComp:     {"OpIterInitComp", nil},
 	OpIterNext:         {"OpIterNext", nil},
 	OpIterInitDict:     {"OpIterInitDict", nil},
+	OpJumpTable:        {"OpJumpTable", []int{2}},
+	OpMatchShape:       {"OpMatchShape", []int{1, 2}},
+	OpMatchEqual:       {"OpMatchEqual", nil},
+	OpCatchMatch:       {"OpCatchMatch", nil},
+	OpIncLocal:         {"OpIncLocal", []int{1, 2}},
+	OpGetLocalMember:   {"OpGetLocalMember", []int{1, 2}},
+	OpCompareJump:      {"OpCompareJump", []int{1, 2}},
 }
 
 func Lookup(op Opcode) (*Definition, bool) {
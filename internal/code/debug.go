@@ -4,4 +4,25 @@ type SourcePos struct {
 	Offset int
 	Line   int
 	Col    int
+
+	// Span covers the expression the instruction evaluates and Operands
+	// the expressions that produced its operands, in the order they were
+	// pushed (a member access lists the property name after the object).
+	// Both are left empty for instructions that have no single expression
+	// behind them.
+	Span     Span
+	Operands []Span
+}
+
+// Span is a source range. End is exclusive; a zero Span is unknown.
+// Closing brackets are not recorded in the AST, so a span ends with the
+// last token of its innermost operand rather than at a trailing `)` or `]`.
+type Span struct {
+	Line    int
+	Col     int
+	EndLine int
+	EndCol  int
 }
+
+// IsZero reports whether s is unknown.
+func (s Span) IsZero() bool { return s.Line == 0 }
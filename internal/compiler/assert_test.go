@@ -0,0 +1,57 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"welle/internal/lexer"
+	"welle/internal/object"
+	"welle/internal/parser"
+)
+
+func TestAssertReleaseCompilesOut(t *testing.T) {
+	src := "func f(a) {\n  b = a * 2\n  assert b > 0, \"b = \" + str(b)\n  return a\n}\nf(1)\n"
+	compile := func(release bool) (*Bytecode, []string) {
+		p := parser.New(lexer.New(src))
+		prog := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("parse errors: %v", p.Errors())
+		}
+		c := New()
+		c.SetRelease(release)
+		if err := c.Compile(prog); err != nil {
+			t.Fatal(err)
+		}
+		var warnings []string
+		for _, w := range c.Warnings() {
+			warnings = append(warnings, w.Format("t.wll"))
+		}
+		return c.Bytecode(), warnings
+	}
+
+	body := func(bc *Bytecode) string {
+		for _, k := range bc.Constants {
+			if fn, ok := k.(*object.CompiledFunction); ok {
+				return fn.Instructions.String()
+			}
+		}
+		t.Fatal("no compiled function in constants")
+		return ""
+	}
+
+	debug, _ := compile(false)
+	if ins := body(debug); !strings.Contains(ins, "OpThrow") {
+		t.Fatalf("expected assert to compile to a throw\n%s", ins)
+	}
+
+	release, warnings := compile(true)
+	if ins := body(release); strings.Contains(ins, "OpThrow") || strings.Contains(ins, "OpJump") {
+		t.Fatalf("expected assert to be compiled out\n%s", ins)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("locals read only by a compiled-out assert should not warn, got %v", warnings)
+	}
+	if err := Verify(release); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+}
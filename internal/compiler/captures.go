@@ -0,0 +1,135 @@
+package compiler
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"welle/internal/ast"
+)
+
+// singleAssignmentNames returns the names a function binds exactly once: a
+// parameter that is never assigned, or a local with one binding site that is
+// neither inside a loop nor in a nested function. Such a local cannot change
+// once a closure has been created after its binding, so closures capture its
+// value instead of sharing a Cell with the function.
+//
+// Names are matched without resolving scopes, so an assignment in a nested
+// function to a name of its own still counts against the outer one; that
+// only costs a Cell.
+func singleAssignmentNames(params []*ast.Identifier, body *ast.BlockStatement) map[string]bool {
+	w := &bindingWalker{sites: map[string]int{}, repeated: map[string]bool{}}
+	for _, p := range params {
+		w.sites[p.Value]++
+	}
+	w.walk(reflect.ValueOf(body), false, false)
+
+	out := map[string]bool{}
+	for name, n := range w.sites {
+		if n == 1 && !w.repeated[name] {
+			out[name] = true
+		}
+	}
+	return out
+}
+
+type bindingWalker struct {
+	sites map[string]int
+	// repeated marks names bound inside a loop or a nested function, where
+	// one site can run many times.
+	repeated map[string]bool
+}
+
+func (w *bindingWalker) bind(id *ast.Identifier, repeated bool) {
+	if id == nil {
+		return
+	}
+	w.sites[id.Value]++
+	if repeated {
+		w.repeated[id.Value] = true
+	}
+}
+
+func (w *bindingWalker) walk(v reflect.Value, inLoop, nested bool) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if !v.IsNil() {
+			w.walk(v.Elem(), inLoop, nested)
+		}
+	case reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		repeated := inLoop || nested
+		switch n := v.Interface().(type) {
+		case *ast.AssignStatement:
+			w.bind(n.Name, repeated)
+		case *ast.AssignExpression:
+			if id, ok := n.Left.(*ast.Identifier); ok {
+				w.bind(id, repeated)
+			}
+		case *ast.DestructureAssignStatement:
+			for _, t := range n.Targets {
+				if t != nil {
+					w.bind(t.Name, repeated)
+				}
+			}
+		case *ast.MatchPattern:
+			for _, id := range n.Names() {
+				w.bind(id, repeated)
+			}
+		case *ast.TryStatement:
+			w.bind(n.CatchName, repeated)
+		case *ast.ImportStatement:
+			if n.Alias != nil {
+				w.bind(n.Alias, repeated)
+			} else if n.Path != nil {
+				base := filepath.Base(n.Path.Value)
+				w.bind(&ast.Identifier{Value: strings.TrimSuffix(base, filepath.Ext(base))}, repeated)
+			}
+		case *ast.FromImportStatement:
+			for _, it := range n.Items {
+				if it.Alias != nil {
+					w.bind(it.Alias, repeated)
+				} else {
+					w.bind(it.Name, repeated)
+				}
+			}
+		case *ast.ForInStatement:
+			w.bind(n.Var, true)
+			w.bind(n.Key, true)
+			w.bind(n.Value, true)
+			inLoop = true
+		case *ast.ListComprehension:
+			w.bind(n.Var, true)
+			inLoop = true
+		case *ast.WhileStatement, *ast.ForStatement:
+			inLoop = true
+		case *ast.FuncStatement:
+			w.bind(n.Name, repeated)
+			nested = true
+		case *ast.FunctionLiteral:
+			nested = true
+		case *ast.ClassStatement:
+			// Methods are not bindings of the enclosing function.
+			w.bind(n.Name, repeated)
+			w.walk(reflect.ValueOf(n.Defaults), inLoop, nested)
+			for _, m := range n.Methods {
+				w.walk(reflect.ValueOf(m.Defaults), inLoop, nested)
+				w.walk(reflect.ValueOf(m.Body), inLoop, true)
+			}
+			return
+		}
+		w.walk(v.Elem(), inLoop, nested)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				w.walk(v.Field(i), inLoop, nested)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			w.walk(v.Index(i), inLoop, nested)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"welle/internal/lexer"
+	"welle/internal/object"
+	"welle/internal/parser"
+)
+
+func TestClosureCaptureKinds(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"parameter", `func f(a) { return func() { return a } }`, "OpGetLocal 0"},
+		{"single assignment", `func f() { x = 1; return func() { return x } }`, "OpGetLocal 0"},
+		{"rebound after capture", `func f() { x = 1; g = func() { return x }; x = 2; return g }`, "OpGetLocalCell 0"},
+		{"assigned by closure", `func f() { x = 0; return func() { x = x + 1; return x } }`, "OpGetLocalCell 0"},
+		{"loop variable", `func f(xs) { fs = []; for x in xs { fs.push(func() { return x }) } return fs }`, "OpGetLocalCell 3"},
+		{"assigned in loop", `func f() { while (true) { y = 1; break } return func() { return y } }`, "OpGetLocalCell 0"},
+		{"parameter reassigned", `func f(a) { a = a + 1; return func() { return a } }`, "OpGetLocalCell 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.New(lexer.New(tt.src))
+			prog := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("parse errors: %v", p.Errors())
+			}
+			c := New()
+			if err := c.Compile(prog); err != nil {
+				t.Fatal(err)
+			}
+			var outer *object.CompiledFunction
+			for _, k := range c.Bytecode().Constants {
+				if fn, ok := k.(*object.CompiledFunction); ok && fn.Name == "f" {
+					outer = fn
+				}
+			}
+			if outer == nil {
+				t.Fatal("function f not found")
+			}
+			dis := outer.Instructions.String()
+			if !strings.Contains(dis, tt.want+"\n") {
+				t.Fatalf("expected %q before the closure\n%s", tt.want, dis)
+			}
+		})
+	}
+}
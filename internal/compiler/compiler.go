@@ -6,7 +6,11 @@ import (
 	"strings"
 
 	"welle/internal/ast"
+	"welle/internal/builtins"
 	"welle/internal/code"
+	"welle/internal/diag"
+	"welle/internal/errcode"
+	"welle/internal/flow"
 	"welle/internal/object"
 	"welle/internal/token"
 )
@@ -15,6 +19,14 @@ type Bytecode struct {
 	Instructions code.Instructions
 	Constants    []object.Object
 	Debug        DebugInfo
+	// NumGlobals is the number of global slots the program defines; the VM
+	// sizes its globals segment from it.
+	NumGlobals int
+	// GlobalNames names each global slot for globals(); see
+	// SymbolTable.SlotNames.
+	GlobalNames []string
+	// Exports maps each name exported at the top level to its global slot.
+	Exports map[string]int
 }
 
 type SourcePos = code.SourcePos
@@ -34,16 +46,37 @@ type compilationScope struct {
 	pos             []SourcePos
 	lastInstruction EmittedInstruction
 	prevInstruction EmittedInstruction
+	locals          []localDef
+	// tries are the try statements enclosing the code being compiled,
+	// innermost last; see leaveTries.
+	tries []tryContext
 }
 
 type loopContext struct {
 	continueTarget int
 	breakJumps     []int
 	continueJumps  []int
+	// tries is how many try statements enclose the loop; break and
+	// continue leave the ones inside it.
+	tries int
 }
 
 type switchContext struct {
 	breakJumps []int
+	tries      int
+}
+
+type tryPhase int
+
+const (
+	inTryBody tryPhase = iota
+	inCatch
+	inFinally
+)
+
+type tryContext struct {
+	phase   tryPhase
+	finally *ast.BlockStatement
 }
 
 type Compiler struct {
@@ -54,74 +87,27 @@ type Compiler struct {
 	file       string
 	curLine    int
 	curCol     int
-	loops      []loopContext
-	switches   []switchContext
-	tempIndex  int
-}
-
-var builtinIndex = map[string]int{
-	"print":  0,
-	"len":    1,
-	"str":    2,
-	"join":   3,
-	"keys":   4,
-	"values": 5,
-	"push":   6,
-	"append": 6,
-
-	"count":  7,
-	"remove": 8,
-	"get":    9,
-	"pop":    10,
-
-	"error":     11,
-	"range":     12,
-	"hasKey":    13,
-	"sort":      14,
-	"writeFile": 15,
-
-	"math_floor": 16,
-	"math_sqrt":  17,
-	"math_sin":   18,
-	"math_cos":   19,
-
-	"gfx_open":        20,
-	"gfx_close":       21,
-	"gfx_shouldClose": 22,
-	"gfx_beginFrame":  23,
-	"gfx_endFrame":    24,
-	"gfx_clear":       25,
-	"gfx_rect":        26,
-	"gfx_pixel":       27,
-	"gfx_time":        28,
-	"gfx_keyDown":     29,
-	"gfx_mouseX":      30,
-	"gfx_mouseY":      31,
-	"gfx_present":     32,
-
-	"image_new":        33,
-	"image_set":        34,
-	"image_fill":       35,
-	"image_width":      36,
-	"image_height":     37,
-	"image_fill_rect":  38,
-	"image_fade":       39,
-	"image_fade_white": 40,
-
-	"max":            41,
-	"abs":            42,
-	"sum":            43,
-	"reverse":        44,
-	"any":            45,
-	"all":            46,
-	"map":            47,
-	"mean":           48,
-	"sqrt":           49,
-	"input":          50,
-	"getpass":        51,
-	"group_digits":   52,
-	"format_float":   53,
-	"format_percent": 54,
+	// curSpan and curOperands are the expression and operand ranges for
+	// the next instruction; see setOperandSpans.
+	curSpan     code.Span
+	curOperands []code.Span
+	spans       map[ast.Node]code.Span
+	loops       []loopContext
+	switches    []switchContext
+	tempIndex   int
+	warnings    []diag.Diagnostic
+	exports     map[string]int
+	release     bool
+	// features are the build features BUILD.<name> reports as enabled;
+	// usesFeatures is set once a BUILD flag has been compiled.
+	features     map[string]bool
+	usesFeatures bool
+
+	// laterGlobals, bodyNames and unsupportedList track constructs only
+	// the interpreter runs; see unsupported.go.
+	laterGlobals    map[string]bool
+	bodyNames       []scopeNames
+	unsupportedList []Unsupported
 }
 
 func New() *Compiler {
@@ -131,7 +117,91 @@ func New() *Compiler {
 		symbols:    NewSymbolTable(),
 		scopes:     []compilationScope{mainScope},
 		scopeIndex: 0,
+		exports:    map[string]int{},
+	}
+}
+
+// SetRelease makes assert statements compile to nothing; their condition and
+// message are not evaluated, but still count as reading the variables they
+// use.
+func (c *Compiler) SetRelease(on bool) {
+	c.release = on
+}
+
+// SetFeatures enables the named build features. A BUILD.<name> flag
+// compiles to a constant, and the branch of an if statement a constant
+// flag condition rules out is not compiled at all.
+func (c *Compiler) SetFeatures(names []string) {
+	c.features = map[string]bool{}
+	for _, name := range names {
+		c.features[name] = true
+	}
+}
+
+// UsesFeatures reports whether the compiled code reads a BUILD flag, so its
+// bytecode depends on the enabled features.
+func (c *Compiler) UsesFeatures() bool {
+	return c.usesFeatures
+}
+
+// buildFlag is ast.BuildFlag for code where BUILD is not a name the
+// program binds: a BUILD variable, parameter or import shadows the flags.
+func (c *Compiler) buildFlag(e ast.Expression) (string, bool) {
+	name, ok := ast.BuildFlag(e)
+	if !ok {
+		return "", false
+	}
+	if _, bound := c.symbols.Resolve(ast.BuildName); bound || c.forwardRef(ast.BuildName) {
+		return "", false
+	}
+	return name, true
+}
+
+// buildCondition evaluates cond when it is made only of BUILD flags, !/not,
+// and/or. ok is false when it depends on anything else.
+func (c *Compiler) buildCondition(cond ast.Expression) (value, ok bool) {
+	if name, ok := c.buildFlag(cond); ok {
+		c.usesFeatures = true
+		return c.features[name], true
+	}
+	switch n := cond.(type) {
+	case *ast.PrefixExpression:
+		if n.Operator == "!" || n.Operator == "not" {
+			v, ok := c.buildCondition(n.Right)
+			return !v, ok
+		}
+	case *ast.InfixExpression:
+		if n.Operator != "and" && n.Operator != "or" {
+			return false, false
+		}
+		l, ok := c.buildCondition(n.Left)
+		if !ok {
+			return false, false
+		}
+		r, ok := c.buildCondition(n.Right)
+		if !ok {
+			return false, false
+		}
+		if n.Operator == "and" {
+			return l && r, true
+		}
+		return l || r, true
+	}
+	return false, false
+}
+
+func (c *Compiler) compileProgram(n *ast.Program) error {
+	c.warnings = append(c.warnings, flow.UseBeforeAssign(n)...)
+	c.laterGlobals = topLevelNames(n.Statements)
+	for _, s := range n.Statements {
+		if err := c.compileStatement(s); err != nil {
+			return err
+		}
 	}
+	if len(c.unsupportedList) > 0 {
+		return &UnsupportedError{Constructs: c.unsupportedList}
+	}
+	return nil
 }
 
 func NewWithFile(file string) *Compiler {
@@ -153,6 +223,10 @@ func (c *Compiler) currentInstructions() code.Instructions {
 }
 
 func (c *Compiler) Bytecode() *Bytecode {
+	globals := c.symbols
+	for globals.Outer != nil {
+		globals = globals.Outer
+	}
 	return &Bytecode{
 		Instructions: c.currentInstructions(),
 		Constants:    c.constants,
@@ -160,6 +234,9 @@ func (c *Compiler) Bytecode() *Bytecode {
 			File: c.file,
 			Pos:  c.scopes[c.scopeIndex].pos,
 		},
+		NumGlobals:  globals.numDefinitions,
+		GlobalNames: globals.SlotNames(),
+		Exports:     c.exports,
 	}
 }
 
@@ -170,9 +247,11 @@ func (c *Compiler) emit(op code.Opcode, operands ...int) int {
 	scope.instructions = append(scope.instructions, ins...)
 	if c.curLine != 0 {
 		scope.pos = append(scope.pos, SourcePos{
-			Offset: pos,
-			Line:   c.curLine,
-			Col:    c.curCol,
+			Offset:   pos,
+			Line:     c.curLine,
+			Col:      c.curCol,
+			Span:     c.curSpan,
+			Operands: c.curOperands,
 		})
 	}
 
@@ -187,13 +266,6 @@ func (c *Compiler) addConstant(obj object.Object) int {
 	return len(c.constants) - 1
 }
 
-func (c *Compiler) removeLastPop() {
-	scope := &c.scopes[c.scopeIndex]
-	lastPos := scope.lastInstruction.Position
-	scope.instructions = scope.instructions[:lastPos]
-	scope.lastInstruction = scope.prevInstruction
-}
-
 func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
 	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
 }
@@ -213,6 +285,7 @@ func (c *Compiler) leaveScope() (code.Instructions, []SourcePos) {
 }
 
 func (c *Compiler) pushLoop(ctx loopContext) {
+	ctx.tries = len(c.scopes[c.scopeIndex].tries)
 	c.loops = append(c.loops, ctx)
 }
 
@@ -230,7 +303,7 @@ func (c *Compiler) currentLoop() *loopContext {
 }
 
 func (c *Compiler) pushSwitch() {
-	c.switches = append(c.switches, switchContext{})
+	c.switches = append(c.switches, switchContext{tries: len(c.scopes[c.scopeIndex].tries)})
 }
 
 func (c *Compiler) popSwitch() switchContext {
@@ -246,6 +319,42 @@ func (c *Compiler) currentSwitch() *switchContext {
 	return &c.switches[len(c.switches)-1]
 }
 
+// leaveTries emits what a return, break or continue at tok needs before it
+// jumps out of the try statements enclosing it past the first depth: the
+// trap of each try block it leaves is popped, and each finally block runs
+// in place, innermost first. A jump out of a finally block itself is left
+// to the interpreter.
+func (c *Compiler) leaveTries(depth int, tok token.Token, jump string) error {
+	tries := c.scopes[c.scopeIndex].tries
+	for i := len(tries) - 1; i >= depth; i-- {
+		t := tries[i]
+		switch t.phase {
+		case inTryBody:
+			c.emit(code.OpEndTry)
+		case inFinally:
+			c.unsupported(tok, jump+" out of a finally block", jump+" out of a finally block is not supported by the VM")
+			return nil
+		}
+		if t.finally == nil {
+			continue
+		}
+		// The finally block runs outside its own try. It is compiled
+		// where it stands as well, so the constructs it reports are only
+		// listed from there.
+		c.emit(code.OpEndFinally)
+		c.scopes[c.scopeIndex].tries = append([]tryContext(nil), tries[:i]...)
+		unsupported := len(c.unsupportedList)
+		err := c.Compile(t.finally)
+		c.unsupportedList = c.unsupportedList[:unsupported]
+		c.scopes[c.scopeIndex].tries = tries
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpRethrowPending)
+	}
+	return nil
+}
+
 func (c *Compiler) newTempSymbol(prefix string) Symbol {
 	name := fmt.Sprintf("__welle_%s_%d", prefix, c.tempIndex)
 	c.tempIndex++
@@ -255,16 +364,23 @@ func (c *Compiler) newTempSymbol(prefix string) Symbol {
 func (c *Compiler) setPosFromToken(tok token.Token) {
 	c.curLine = tok.Line
 	c.curCol = tok.Col
+	c.curSpan = code.Span{}
+	c.curOperands = nil
 }
 
-func (c *Compiler) Compile(node ast.Node) error {
+func (c *Compiler) Compile(node ast.Node) (err error) {
+	// Compiling a child moves the position to the child's token; put the
+	// caller's back afterwards so the instruction the caller emits next is
+	// attributed to the caller rather than to its last operand.
+	line, col, span, operands := c.curLine, c.curCol, c.curSpan, c.curOperands
+	defer func() {
+		c.curLine, c.curCol, c.curSpan, c.curOperands = line, col, span, operands
+	}()
+
 	switch n := node.(type) {
 	case *ast.Program:
-		for _, s := range n.Statements {
-			if err := c.Compile(s); err != nil {
-				return err
-			}
-		}
+		defer c.recoverInternal(n, &err)
+		return c.compileProgram(n)
 
 	case *ast.ExpressionStatement:
 		c.setPosFromToken(n.Token)
@@ -274,112 +390,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpPop)
 
 	case *ast.AssignStatement:
-		posTok := n.Token
-		if n.OpToken.Type != "" {
-			posTok = n.OpToken
-		}
-		c.setPosFromToken(posTok)
-
-		op := n.Op
-		if op == token.WALRUS {
-			if err := c.Compile(n.Value); err != nil {
-				return err
-			}
-
-			sym, ok := c.symbols.ResolveCurrent(n.Name.Value)
-			if !ok {
-				sym = c.symbols.Define(n.Name.Value)
-			}
-			nameIdx := c.addConstant(&object.String{Value: n.Name.Value})
-
-			switch sym.Scope {
-			case GlobalScope:
-				c.emit(code.OpDefineGlobal, sym.Index, nameIdx)
-				c.emit(code.OpGetGlobal, sym.Index)
-			case LocalScope:
-				c.emit(code.OpDefineLocal, sym.Index, nameIdx)
-				c.emit(code.OpGetLocal, sym.Index)
-			default:
-				return fmt.Errorf("unsupported symbol scope for walrus: %s", sym.Scope)
-			}
-			return nil
-		}
-		if op == "" || op == token.ASSIGN {
-			if err := c.Compile(n.Value); err != nil {
-				return err
-			}
-
-			sym, ok := c.symbols.Resolve(n.Name.Value)
-			if !ok {
-				sym = c.symbols.Define(n.Name.Value)
-			}
-
-			switch sym.Scope {
-			case GlobalScope:
-				c.emit(code.OpSetGlobal, sym.Index)
-				c.emit(code.OpGetGlobal, sym.Index)
-			case LocalScope:
-				c.emit(code.OpSetLocal, sym.Index)
-				c.emit(code.OpGetLocal, sym.Index)
-			case FreeScope:
-				c.emit(code.OpSetFree, sym.Index)
-				c.emit(code.OpGetFree, sym.Index)
-			default:
-				return fmt.Errorf("unsupported symbol scope: %s", sym.Scope)
-			}
-			return nil
-		}
-
-		opcode, ok := compoundAssignOpcode(op)
-		if !ok {
-			return fmt.Errorf("unsupported assignment operator: %s", op)
-		}
-
-		sym, ok := c.symbols.Resolve(n.Name.Value)
-		if !ok {
-			return fmt.Errorf("unknown identifier: %s", n.Name.Value)
-		}
-
-		emitGet := func() error {
-			switch sym.Scope {
-			case GlobalScope:
-				c.emit(code.OpGetGlobal, sym.Index)
-			case LocalScope:
-				c.emit(code.OpGetLocal, sym.Index)
-			case FreeScope:
-				c.emit(code.OpGetFree, sym.Index)
-			default:
-				return fmt.Errorf("unsupported symbol scope: %s", sym.Scope)
-			}
-			return nil
-		}
-		emitSet := func() error {
-			switch sym.Scope {
-			case GlobalScope:
-				c.emit(code.OpSetGlobal, sym.Index)
-			case LocalScope:
-				c.emit(code.OpSetLocal, sym.Index)
-			case FreeScope:
-				c.emit(code.OpSetFree, sym.Index)
-			default:
-				return fmt.Errorf("unsupported symbol scope: %s", sym.Scope)
-			}
-			return nil
-		}
-
-		if err := emitGet(); err != nil {
-			return err
-		}
-		if err := c.Compile(n.Value); err != nil {
-			return err
-		}
-		c.emit(opcode)
-		if err := emitSet(); err != nil {
-			return err
-		}
-		if err := emitGet(); err != nil {
-			return err
-		}
+		return c.compileAssign(n, true)
 
 	case *ast.AssignExpression:
 		switch left := n.Left.(type) {
@@ -392,7 +403,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 				Value:   n.Value,
 			}
 			return c.Compile(stmt)
-		case *ast.IndexExpression:
+		case *ast.IndexExpression, *ast.SliceExpression:
 			stmt := &ast.IndexAssignStatement{
 				Token: n.Token,
 				Op:    n.Op,
@@ -432,7 +443,13 @@ func (c *Compiler) Compile(node ast.Node) error {
 				break
 			}
 		}
-		if starIdx >= 0 {
+		if len(n.Targets) > 0 && n.Targets[0].Field != nil {
+			shape := &object.TupleShape{Names: make([]string, len(n.Targets))}
+			for i, t := range n.Targets {
+				shape.Names[i] = t.Field.Value
+			}
+			c.emit(code.OpUnpackNamed, len(n.Targets), c.addConstant(shape))
+		} else if starIdx >= 0 {
 			c.emit(code.OpUnpackStar, len(n.Targets), starIdx)
 		} else {
 			c.emit(code.OpUnpackTuple, len(n.Targets))
@@ -445,7 +462,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 			sym, ok := c.symbols.Resolve(t.Name.Value)
 			if !ok {
-				sym = c.symbols.Define(t.Name.Value)
+				sym = c.define(t.Name.Value, t.Name.Token)
 			}
 			switch sym.Scope {
 			case GlobalScope:
@@ -458,6 +475,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 				return fmt.Errorf("unsupported symbol scope: %s", sym.Scope)
 			}
 		}
+		// The unpack leaves the value under its parts; a statement leaves
+		// nothing, so every path through a block joins at the same height.
+		c.emit(code.OpPop)
 
 	case *ast.ImportStatement:
 		c.setPosFromToken(n.Token)
@@ -472,9 +492,13 @@ func (c *Compiler) Compile(node ast.Node) error {
 			name = strings.TrimSuffix(base, filepath.Ext(base))
 		}
 
+		nameTok := n.Token
+		if n.Alias != nil {
+			nameTok = n.Alias.Token
+		}
 		sym, ok := c.symbols.Resolve(name)
 		if !ok {
-			sym = c.symbols.Define(name)
+			sym = c.define(name, nameTok)
 		}
 
 		switch sym.Scope {
@@ -493,14 +517,14 @@ func (c *Compiler) Compile(node ast.Node) error {
 			nameIdx := c.addConstant(&object.String{Value: it.Name.Value})
 			c.emit(code.OpImportFrom, pathIdx, nameIdx)
 
-			bind := it.Name.Value
+			bind, bindTok := it.Name.Value, it.Name.Token
 			if it.Alias != nil {
-				bind = it.Alias.Value
+				bind, bindTok = it.Alias.Value, it.Alias.Token
 			}
 
 			sym, ok := c.symbols.Resolve(bind)
 			if !ok {
-				sym = c.symbols.Define(bind)
+				sym = c.define(bind, bindTok)
 			}
 
 			switch sym.Scope {
@@ -515,42 +539,49 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 	case *ast.ExportStatement:
 		c.setPosFromToken(n.Token)
+		var name string
 		switch s := n.Stmt.(type) {
 		case *ast.AssignStatement:
-			if err := c.Compile(s); err != nil {
+			if err := c.compileAssign(s, false); err != nil {
 				return err
 			}
-			nameIdx := c.addConstant(&object.String{Value: s.Name.Value})
-			c.emit(code.OpExport, nameIdx)
+			name = s.Name.Value
 
 		case *ast.FuncStatement:
 			if err := c.Compile(s); err != nil {
 				return err
 			}
+			name = s.Name.Value
 
-			sym, ok := c.symbols.Resolve(s.Name.Value)
-			if !ok {
-				return fmt.Errorf("exported function not defined: %s", s.Name.Value)
-			}
-
-			switch sym.Scope {
-			case GlobalScope:
-				c.emit(code.OpGetGlobal, sym.Index)
-			case LocalScope:
-				c.emit(code.OpGetLocal, sym.Index)
-			default:
-				return fmt.Errorf("unsupported symbol scope: %s", sym.Scope)
+		case *ast.ClassStatement:
+			if err := c.Compile(s); err != nil {
+				return err
 			}
-
-			nameIdx := c.addConstant(&object.String{Value: s.Name.Value})
-			c.emit(code.OpExport, nameIdx)
+			name = s.Name.Value
 
 		default:
-			return fmt.Errorf("export supports only assignments and function declarations")
+			return fmt.Errorf("export supports only assignments and function and class declarations")
+		}
+		if err := c.exportSymbol(name); err != nil {
+			return err
 		}
 
 	case *ast.IndexAssignStatement:
 		c.setPosFromToken(n.Token)
+		if se, ok := n.Left.(*ast.SliceExpression); ok {
+			if n.Op != "" && n.Op != token.ASSIGN {
+				return fmt.Errorf("slice assignment supports only '='")
+			}
+			if err := c.compileSliceOperands(se); err != nil {
+				return err
+			}
+			if err := c.Compile(n.Value); err != nil {
+				return err
+			}
+			c.setOperandSpans(n, se.Left, se.Low, se.High, se.Step, n.Value)
+			c.emit(code.OpSetSlice)
+			return nil
+		}
 		idx, ok := n.Left.(*ast.IndexExpression)
 		if !ok {
 			return fmt.Errorf("index assignment expects index expression on left")
@@ -566,6 +597,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 			if err := c.Compile(n.Value); err != nil {
 				return err
 			}
+			c.setOperandSpans(n, idx.Left, idx.Index, n.Value)
 			c.emit(code.OpSetIndex)
 			return nil
 		}
@@ -622,12 +654,15 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if err := emitGetTmp(indexTmp); err != nil {
 			return err
 		}
+		c.setOperandSpans(idx, idx.Left, idx.Index)
 		c.emit(code.OpIndex)
 
 		if err := c.Compile(n.Value); err != nil {
 			return err
 		}
+		c.setOperandSpans(n, idx, n.Value)
 		c.emit(opcode)
+		c.setPosFromToken(n.Token)
 
 		if err := emitSetTmp(valueTmp); err != nil {
 			return err
@@ -641,6 +676,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if err := emitGetTmp(valueTmp); err != nil {
 			return err
 		}
+		c.setOperandSpans(n, idx.Left, idx.Index, n.Value)
 		c.emit(code.OpSetIndex)
 
 	case *ast.MemberAssignStatement:
@@ -654,6 +690,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 				return err
 			}
 			nameIdx := c.addConstant(&object.String{Value: n.Property.Value})
+			c.setOperandSpans(n, n.Object, n.Value)
 			c.emit(code.OpSetMember, nameIdx)
 			return nil
 		}
@@ -700,12 +737,15 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if err := emitGetTmp(objTmp); err != nil {
 			return err
 		}
+		c.setOperandSpans(n, n.Object)
 		c.emit(code.OpGetMember, nameIdx)
 
 		if err := c.Compile(n.Value); err != nil {
 			return err
 		}
+		c.setOperandSpans(n, n.Object, n.Value)
 		c.emit(opcode)
+		c.setPosFromToken(n.Token)
 
 		if err := emitSetTmp(valTmp); err != nil {
 			return err
@@ -716,11 +756,16 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if err := emitGetTmp(valTmp); err != nil {
 			return err
 		}
+		c.setOperandSpans(n, n.Object, n.Value)
 		c.emit(code.OpSetMember, nameIdx)
 
 	case *ast.ReturnStatement:
 		c.setPosFromToken(n.Token)
 		if len(n.ReturnValues) == 0 {
+			if err := c.leaveTries(0, n.Token, "return"); err != nil {
+				return err
+			}
+			c.setPosFromToken(n.Token)
 			c.emit(code.OpReturn)
 			return nil
 		}
@@ -728,15 +773,18 @@ func (c *Compiler) Compile(node ast.Node) error {
 			if err := c.Compile(n.ReturnValues[0]); err != nil {
 				return err
 			}
-			c.emit(code.OpReturnValue)
-			return nil
-		}
-		for _, rv := range n.ReturnValues {
-			if err := c.Compile(rv); err != nil {
-				return err
+		} else {
+			for _, rv := range n.ReturnValues {
+				if err := c.Compile(rv); err != nil {
+					return err
+				}
 			}
+			c.emit(code.OpTuple, len(n.ReturnValues))
 		}
-		c.emit(code.OpTuple, len(n.ReturnValues))
+		if err := c.leaveTries(0, n.Token, "return"); err != nil {
+			return err
+		}
+		c.setPosFromToken(n.Token)
 		c.emit(code.OpReturnValue)
 
 	case *ast.DeferStatement:
@@ -773,6 +821,40 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpDefer, len(ce.Arguments))
 		}
 
+	case *ast.DelStatement:
+		c.setPosFromToken(n.Token)
+		switch t := n.Target.(type) {
+		case *ast.IndexExpression:
+			if err := c.Compile(t.Left); err != nil {
+				return err
+			}
+			if err := c.Compile(t.Index); err != nil {
+				return err
+			}
+			c.setOperandSpans(n, t.Left, t.Index)
+			c.emit(code.OpDelIndex)
+		case *ast.SliceExpression:
+			if err := c.compileSliceOperands(t); err != nil {
+				return err
+			}
+			c.setOperandSpans(n, t.Left, t.Low, t.High, t.Step)
+			c.emit(code.OpDelSlice)
+		default:
+			return fmt.Errorf("del expects an index or slice expression")
+		}
+
+	case *ast.YieldStatement:
+		if c.scopeIndex == 0 {
+			return fmt.Errorf("yield outside function")
+		}
+		c.setPosFromToken(n.Token)
+		if n.Value == nil {
+			c.emit(code.OpNull)
+		} else if err := c.Compile(n.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpYield)
+
 	case *ast.ThrowStatement:
 		c.setPosFromToken(n.Token)
 		if err := c.Compile(n.Value); err != nil {
@@ -780,6 +862,50 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 		c.emit(code.OpThrow)
 
+	case *ast.AssertStatement:
+		if c.release {
+			return c.discard(func() error {
+				if err := c.Compile(n.Condition); err != nil {
+					return err
+				}
+				if n.Message != nil {
+					return c.Compile(n.Message)
+				}
+				return nil
+			})
+		}
+		c.setPosFromToken(n.Token)
+		if err := c.Compile(n.Condition); err != nil {
+			return err
+		}
+		jntPos := c.emit(code.OpJumpNotTruthy, 9999)
+		jmpPos := c.emit(code.OpJump, 9999)
+		c.replaceOperand(jntPos, len(c.currentInstructions()))
+		c.setPosFromToken(n.Token)
+		// The failure is thrown as error(msg, code) so it carries the
+		// catalog code, as in the interpreter.
+		errorIdx, _ := builtins.Index("error")
+		c.emit(code.OpGetBuiltin, errorIdx)
+		msg := n.FailureMessage()
+		if n.Message != nil {
+			msg += ": "
+		}
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: msg}))
+		if n.Message != nil {
+			strIdx, _ := builtins.Index("str")
+			c.emit(code.OpGetBuiltin, strIdx)
+			if err := c.Compile(n.Message); err != nil {
+				return err
+			}
+			c.emit(code.OpCall, 1)
+			c.emit(code.OpAdd)
+		}
+		c.setPosFromToken(n.Token)
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: errcode.AssertionFailed}))
+		c.emit(code.OpCall, 2)
+		c.emit(code.OpThrow)
+		c.replaceOperand(jmpPos, len(c.currentInstructions()))
+
 	case *ast.IntegerLiteral:
 		c.setPosFromToken(n.Token)
 		idx := c.addConstant(&object.Integer{Value: n.Value})
@@ -834,7 +960,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		part0 := c.addConstant(&object.String{Value: n.Parts[0]})
 		c.emit(code.OpConstant, part0)
-		strIdx, ok := builtinIndex["str"]
+		strIdx, ok := builtins.Index("str")
 		if !ok {
 			return fmt.Errorf("missing builtin: str")
 		}
@@ -965,13 +1091,35 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 	case *ast.ListLiteral:
+		c.setPosFromToken(n.Token)
+		hasSpread := false
+		for _, el := range n.Elements {
+			if spread, ok := el.(*ast.SpreadExpression); ok {
+				hasSpread = true
+				if err := c.Compile(spread.Value); err != nil {
+					return err
+				}
+				c.emit(code.OpSpread)
+				continue
+			}
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		if hasSpread {
+			c.emit(code.OpArraySpread, len(n.Elements))
+		} else {
+			c.emit(code.OpArray, len(n.Elements))
+		}
+
+	case *ast.SetLiteral:
 		c.setPosFromToken(n.Token)
 		for _, el := range n.Elements {
 			if err := c.Compile(el); err != nil {
 				return err
 			}
 		}
-		c.emit(code.OpArray, len(n.Elements))
+		c.emit(code.OpSet, len(n.Elements))
 
 	case *ast.TupleLiteral:
 		c.setPosFromToken(n.Token)
@@ -982,9 +1130,32 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 		c.emit(code.OpTuple, len(n.Elements))
 
+	case *ast.NamedTupleLiteral:
+		c.setPosFromToken(n.Token)
+		shape := &object.TupleShape{Names: make([]string, len(n.Fields))}
+		for i, f := range n.Fields {
+			if err := c.Compile(f.Value); err != nil {
+				return err
+			}
+			shape.Names[i] = f.Name.Value
+		}
+		c.emit(code.OpNamedTuple, len(n.Fields), c.addConstant(shape))
+
 	case *ast.DictLiteral:
 		c.setPosFromToken(n.Token)
+		hasSpread := false
 		for _, pair := range n.Pairs {
+			if pair.Spread != nil {
+				// A spread entry takes a pair's two slots: the wrapped
+				// dict and a nil in place of the value.
+				hasSpread = true
+				if err := c.Compile(pair.Spread.Value); err != nil {
+					return err
+				}
+				c.emit(code.OpSpread)
+				c.emit(code.OpNull)
+				continue
+			}
 			if pair.Shorthand != nil {
 				keyIdx := c.addConstant(&object.String{Value: pair.Shorthand.Value})
 				c.emit(code.OpConstant, keyIdx)
@@ -1000,13 +1171,18 @@ func (c *Compiler) Compile(node ast.Node) error {
 				return err
 			}
 		}
-		c.emit(code.OpDict, len(n.Pairs))
+		if hasSpread {
+			c.emit(code.OpDictSpread, len(n.Pairs))
+		} else {
+			c.emit(code.OpDict, len(n.Pairs))
+		}
 
 	case *ast.PrefixExpression:
 		c.setPosFromToken(n.Token)
 		if err := c.Compile(n.Right); err != nil {
 			return err
 		}
+		c.setOperandSpans(n, n.Right)
 		switch n.Operator {
 		case "-":
 			c.emit(code.OpMinus)
@@ -1033,6 +1209,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return err
 		}
 
+		c.setOperandSpans(n, n.Left, n.Right)
 		switch n.Operator {
 		case "+":
 			c.emit(code.OpAdd)
@@ -1120,52 +1297,55 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 	case *ast.IndexExpression:
 		c.setPosFromToken(n.Token)
+		if levels := indexChain(n); len(levels) > 1 {
+			return c.compileIndexChain(levels)
+		}
 		if err := c.Compile(n.Left); err != nil {
 			return err
 		}
 		if err := c.Compile(n.Index); err != nil {
 			return err
 		}
+		c.setOperandSpans(n, n.Left, n.Index)
 		c.emit(code.OpIndex)
 
 	case *ast.MemberExpression:
 		c.setPosFromToken(n.Token)
+		if name, ok := c.buildFlag(n); ok {
+			c.usesFeatures = true
+			if c.features[name] {
+				c.emit(code.OpTrue)
+			} else {
+				c.emit(code.OpFalse)
+			}
+			return nil
+		}
 		if err := c.Compile(n.Object); err != nil {
 			return err
 		}
 		nameIdx := c.addConstant(&object.String{Value: n.Property.Value})
+		c.setOperandSpans(n, n.Object, n.Property)
 		c.emit(code.OpGetMember, nameIdx)
 
 	case *ast.SliceExpression:
 		c.setPosFromToken(n.Token)
-		if err := c.Compile(n.Left); err != nil {
+		if err := c.compileSliceOperands(n); err != nil {
 			return err
 		}
-		if n.Low != nil {
-			if err := c.Compile(n.Low); err != nil {
-				return err
-			}
-		} else {
-			c.emit(code.OpNull)
-		}
-		if n.High != nil {
-			if err := c.Compile(n.High); err != nil {
-				return err
-			}
-		} else {
-			c.emit(code.OpNull)
-		}
-		if n.Step != nil {
-			if err := c.Compile(n.Step); err != nil {
-				return err
-			}
-		} else {
-			c.emit(code.OpNull)
-		}
+		c.setOperandSpans(n, n.Left, n.Low, n.High, n.Step)
 		c.emit(code.OpSlice)
 
 	case *ast.IfStatement:
 		c.setPosFromToken(n.Token)
+		if on, ok := c.buildCondition(n.Condition); ok {
+			switch {
+			case on:
+				return c.Compile(n.Consequence)
+			case n.Alternative != nil:
+				return c.Compile(n.Alternative)
+			}
+			return nil
+		}
 		if err := c.Compile(n.Condition); err != nil {
 			return err
 		}
@@ -1175,8 +1355,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if err := c.Compile(n.Consequence); err != nil {
 			return err
 		}
-		if c.lastInstructionIs(code.OpPop) {
-			c.removeLastPop()
+
+		if n.Alternative == nil {
+			c.replaceOperand(jntPos, len(c.currentInstructions()))
+			return nil
 		}
 
 		jmpPos := c.emit(code.OpJump, 9999)
@@ -1184,15 +1366,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 		afterConsequencePos := len(c.currentInstructions())
 		c.replaceOperand(jntPos, afterConsequencePos)
 
-		if n.Alternative != nil {
-			if err := c.Compile(n.Alternative); err != nil {
-				return err
-			}
-			if c.lastInstructionIs(code.OpPop) {
-				c.removeLastPop()
-			}
-		} else {
-			c.emit(code.OpNull)
+		if err := c.Compile(n.Alternative); err != nil {
+			return err
 		}
 
 		afterAltPos := len(c.currentInstructions())
@@ -1309,6 +1484,12 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 		}
 
+		var values []ast.Expression
+		for _, cs := range n.Cases {
+			values = append(values, cs.Values...)
+		}
+		table := c.emitJumpTable(values, emitGetTmp)
+
 		c.pushSwitch()
 		endJumps := []int{}
 
@@ -1334,6 +1515,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 			for _, j := range matchJumps {
 				c.replaceOperand(j, bodyPos)
 			}
+			setJumpTargets(table, cs.Values, bodyPos)
 
 			if err := c.Compile(cs.Body); err != nil {
 				return err
@@ -1344,6 +1526,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.replaceOperand(jumpNextCase, nextCasePos)
 		}
 
+		if table != nil {
+			table.Default = len(c.currentInstructions())
+		}
 		if n.Default != nil {
 			if err := c.Compile(n.Default); err != nil {
 				return err
@@ -1384,27 +1569,56 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 		}
 
+		var values []ast.Expression
+		for _, cs := range n.Cases {
+			values = append(values, cs.Values...)
+		}
+		table := c.emitJumpTable(values, emitGetTmp)
+
 		endJumps := []int{}
 
 		for _, cs := range n.Cases {
 			for _, v := range cs.Values {
-				emitGetTmp()
-				if err := c.Compile(v); err != nil {
-					return err
+				var fails []int
+				if pat, ok := v.(*ast.MatchPattern); ok {
+					var err error
+					fails, err = c.compilePattern(pat, func() error {
+						emitGetTmp()
+						return nil
+					})
+					if err != nil {
+						return err
+					}
+				} else {
+					emitGetTmp()
+					if err := c.Compile(v); err != nil {
+						return err
+					}
+					c.emit(code.OpEqual)
+					fails = append(fails, c.emit(code.OpJumpNotTruthy, 9999))
+					setJumpTargets(table, []ast.Expression{v}, len(c.currentInstructions()))
+				}
+				if cs.Guard != nil {
+					if err := c.Compile(cs.Guard); err != nil {
+						return err
+					}
+					fails = append(fails, c.emit(code.OpJumpNotTruthy, 9999))
 				}
-				c.emit(code.OpEqual)
-
-				jntPos := c.emit(code.OpJumpNotTruthy, 9999)
 				if err := c.Compile(cs.Result); err != nil {
 					return err
 				}
 				endJumps = append(endJumps, c.emit(code.OpJump, 9999))
 
 				nextCheckPos := len(c.currentInstructions())
-				c.replaceOperand(jntPos, nextCheckPos)
+				for _, pos := range fails {
+					c.replaceOperand(pos, nextCheckPos)
+				}
 			}
 		}
 
+		if table != nil {
+			table.Default = len(c.currentInstructions())
+		}
 		if n.Default != nil {
 			if err := c.Compile(n.Default); err != nil {
 				return err
@@ -1421,7 +1635,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.BlockStatement:
 		c.setPosFromToken(n.Token)
 		for _, s := range n.Statements {
-			if err := c.Compile(s); err != nil {
+			if err := c.compileStatement(s); err != nil {
 				return err
 			}
 		}
@@ -1487,7 +1701,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 			if n.Key != nil && n.Key.Value != "_" {
 				keyVar, ok := c.symbols.Resolve(n.Key.Value)
 				if !ok {
-					keyVar = c.symbols.Define(n.Key.Value)
+					keyVar = c.define(n.Key.Value, n.Key.Token)
 				}
 				switch keyTemp.Scope {
 				case GlobalScope:
@@ -1524,7 +1738,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 				valVar, ok := c.symbols.Resolve(n.Value.Value)
 				if !ok {
-					valVar = c.symbols.Define(n.Value.Value)
+					valVar = c.define(n.Value.Value, n.Value.Token)
 				}
 				switch valVar.Scope {
 				case GlobalScope:
@@ -1540,7 +1754,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		} else {
 			loopVar, ok := c.symbols.Resolve(n.Var.Value)
 			if !ok {
-				loopVar = c.symbols.Define(n.Var.Value)
+				loopVar = c.define(n.Var.Value, n.Var.Token)
 			}
 			switch loopVar.Scope {
 			case GlobalScope:
@@ -1581,13 +1795,22 @@ func (c *Compiler) Compile(node ast.Node) error {
 			finallyPos = c.emit(code.OpTryFinally, 9999, 9999)
 		}
 
+		scope := &c.scopes[c.scopeIndex]
+		scope.tries = append(scope.tries, tryContext{finally: n.FinallyBlock})
+		depth := len(scope.tries)
+		// The phase is set through the scope each time: compiling the
+		// blocks may grow the slice.
+		setPhase := func(p tryPhase) { c.scopes[c.scopeIndex].tries[depth-1].phase = p }
+		defer func() {
+			scope := &c.scopes[c.scopeIndex]
+			scope.tries = scope.tries[:depth-1]
+		}()
+
 		if err := c.Compile(n.TryBlock); err != nil {
 			return err
 		}
-		if c.lastInstructionIs(code.OpPop) {
-			c.removeLastPop()
-		}
 		c.emit(code.OpEndTry)
+		setPhase(inCatch)
 
 		jumpAfterTry := -1
 		if n.CatchBlock != nil || n.FinallyBlock != nil {
@@ -1599,9 +1822,20 @@ func (c *Compiler) Compile(node ast.Node) error {
 			catchPos := len(c.currentInstructions())
 			c.replaceOperands(tryPos, catchPos)
 
+			// The handler starts with the error on the stack; one the
+			// filter does not accept is thrown again from the end.
+			rethrowPos := -1
+			if n.CatchFilter != nil {
+				if err := c.Compile(n.CatchFilter); err != nil {
+					return err
+				}
+				c.emit(code.OpCatchMatch)
+				rethrowPos = c.emit(code.OpJumpNotTruthy, 9999)
+			}
+
 			sym, ok := c.symbols.Resolve(n.CatchName.Value)
 			if !ok {
-				sym = c.symbols.Define(n.CatchName.Value)
+				sym = c.define(n.CatchName.Value, n.CatchName.Token)
 			}
 			switch sym.Scope {
 			case GlobalScope:
@@ -1615,15 +1849,20 @@ func (c *Compiler) Compile(node ast.Node) error {
 			if err := c.Compile(n.CatchBlock); err != nil {
 				return err
 			}
-			if c.lastInstructionIs(code.OpPop) {
-				c.removeLastPop()
-			}
 
-			if n.FinallyBlock != nil {
+			if n.FinallyBlock != nil || rethrowPos != -1 {
 				jumpAfterCatch = c.emit(code.OpJump, 9999)
-			} else {
+			}
+			if rethrowPos != -1 {
+				c.replaceOperands(rethrowPos, len(c.currentInstructions()))
+				c.emit(code.OpThrow)
+			}
+			if n.FinallyBlock == nil {
 				afterCatchPos := len(c.currentInstructions())
 				c.replaceOperands(jumpAfterTry, afterCatchPos)
+				if jumpAfterCatch != -1 {
+					c.replaceOperands(jumpAfterCatch, afterCatchPos)
+				}
 				return nil
 			}
 		} else {
@@ -1644,6 +1883,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 		c.emit(code.OpEndFinally)
+		setPhase(inFinally)
 		if err := c.Compile(n.FinallyBlock); err != nil {
 			return err
 		}
@@ -1660,25 +1900,69 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 		idx := c.addConstant(compiled)
-		for _, fs := range freeSymbols {
-			switch fs.Scope {
-			case LocalScope:
-				c.emit(code.OpGetLocalCell, fs.Index)
-			case FreeScope:
-				c.emit(code.OpGetFreeCell, fs.Index)
-			case GlobalScope:
-				c.emit(code.OpGetGlobal, fs.Index)
-			default:
-				return fmt.Errorf("unsupported free symbol scope: %s", fs.Scope)
-			}
+		if err := c.emitCaptures(freeSymbols); err != nil {
+			return err
 		}
 		c.emit(code.OpClosure, idx, len(freeSymbols))
+		if err := c.compileDefaults(n.Defaults); err != nil {
+			return err
+		}
 
 		sym, ok := c.symbols.Resolve(n.Name.Value)
 		if !ok {
-			sym = c.symbols.Define(n.Name.Value)
+			sym = c.define(n.Name.Value, n.Name.Token)
+		}
+
+		switch sym.Scope {
+		case GlobalScope:
+			c.emit(code.OpSetGlobal, sym.Index)
+		case LocalScope:
+			c.emit(code.OpSetLocal, sym.Index)
+		default:
+			return fmt.Errorf("unsupported symbol scope: %s", sym.Scope)
+		}
+
+	case *ast.ClassStatement:
+		c.setPosFromToken(n.Token)
+		// A global class is defined first so its methods can name it.
+		sym, ok := c.symbols.Resolve(n.Name.Value)
+		if !ok && c.scopeIndex == 0 {
+			sym, ok = c.define(n.Name.Value, n.Name.Token), true
+		}
+
+		shape := &object.ClassShape{Name: n.Name.Value}
+		for i, f := range n.Fields {
+			if d := ast.ParamDefault(n.Defaults, i); d != nil {
+				if err := c.Compile(d); err != nil {
+					return err
+				}
+			} else {
+				c.emit(code.OpNull)
+			}
+			shape.Fields = append(shape.Fields, f.Value)
+		}
+		for _, m := range n.Methods {
+			params, defaults := ast.MethodParams(m)
+			compiled, freeSymbols, err := c.compileFunction(n.Name.Value+"."+m.Name.Value, params, m.Body)
+			if err != nil {
+				return err
+			}
+			idx := c.addConstant(compiled)
+			if err := c.emitCaptures(freeSymbols); err != nil {
+				return err
+			}
+			c.emit(code.OpClosure, idx, len(freeSymbols))
+			if err := c.compileDefaults(defaults); err != nil {
+				return err
+			}
+			shape.Methods = append(shape.Methods, m.Name.Value)
 		}
+		c.setPosFromToken(n.Token)
+		c.emit(code.OpClass, len(shape.Fields)+len(shape.Methods), c.addConstant(shape))
 
+		if !ok {
+			sym = c.define(n.Name.Value, n.Name.Token)
+		}
 		switch sym.Scope {
 		case GlobalScope:
 			c.emit(code.OpSetGlobal, sym.Index)
@@ -1696,19 +1980,13 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 		idx := c.addConstant(compiled)
-		for _, fs := range freeSymbols {
-			switch fs.Scope {
-			case LocalScope:
-				c.emit(code.OpGetLocalCell, fs.Index)
-			case FreeScope:
-				c.emit(code.OpGetFreeCell, fs.Index)
-			case GlobalScope:
-				c.emit(code.OpGetGlobal, fs.Index)
-			default:
-				return fmt.Errorf("unsupported free symbol scope: %s", fs.Scope)
-			}
+		if err := c.emitCaptures(freeSymbols); err != nil {
+			return err
 		}
 		c.emit(code.OpClosure, idx, len(freeSymbols))
+		if err := c.compileDefaults(n.Defaults); err != nil {
+			return err
+		}
 
 	case *ast.CallExpression:
 		c.setPosFromToken(n.Token)
@@ -1736,6 +2014,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 				}
 			}
 			nameIdx := c.addConstant(&object.String{Value: me.Property.Value})
+			c.setOperandSpans(n, callOperands(me.Object, n.Arguments)...)
 			if hasSpread {
 				c.emit(code.OpCallMethodSpread, nameIdx, len(n.Arguments))
 			} else {
@@ -1766,6 +2045,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 				return err
 			}
 		}
+		c.setOperandSpans(n, callOperands(n.Function, n.Arguments)...)
 		if hasSpread {
 			c.emit(code.OpCallSpread, len(n.Arguments))
 		} else {
@@ -1776,6 +2056,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.setPosFromToken(n.Token)
 		sym, ok := c.symbols.Resolve(n.Value)
 		if ok {
+			c.symbols.markRead(sym)
 			switch sym.Scope {
 			case GlobalScope:
 				c.emit(code.OpGetGlobal, sym.Index)
@@ -1789,22 +2070,37 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return nil
 		}
 
-		if idx, ok := builtinIndex[n.Value]; ok {
+		if idx, ok := builtins.Index(n.Value); ok {
 			c.emit(code.OpGetBuiltin, idx)
 			return nil
 		}
 
+		if c.forwardRef(n.Value) {
+			c.unsupported(n.Token, "forward reference to "+n.Value, "unknown identifier: "+n.Value)
+			c.emit(code.OpNull)
+			return nil
+		}
 		return fmt.Errorf("unknown identifier: %s", n.Value)
 
 	case *ast.BreakStatement:
 		c.setPosFromToken(n.Token)
 		if loop := c.currentLoop(); loop != nil {
+			if err := c.leaveTries(loop.tries, n.Token, "break"); err != nil {
+				return err
+			}
+			c.setPosFromToken(n.Token)
 			pos := c.emit(code.OpJump, 9999)
+			loop = c.currentLoop()
 			loop.breakJumps = append(loop.breakJumps, pos)
 			return nil
 		}
 		if sw := c.currentSwitch(); sw != nil {
+			if err := c.leaveTries(sw.tries, n.Token, "break"); err != nil {
+				return err
+			}
+			c.setPosFromToken(n.Token)
 			pos := c.emit(code.OpJump, 9999)
+			sw = c.currentSwitch()
 			sw.breakJumps = append(sw.breakJumps, pos)
 			return nil
 		}
@@ -1816,7 +2112,12 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if loop == nil {
 			return fmt.Errorf("continue used outside of loop")
 		}
+		if err := c.leaveTries(loop.tries, n.Token, "continue"); err != nil {
+			return err
+		}
+		c.setPosFromToken(n.Token)
 		pos := c.emit(code.OpJump, 9999)
+		loop = c.currentLoop()
 		loop.continueJumps = append(loop.continueJumps, pos)
 
 	case *ast.PassStatement:
@@ -1921,6 +2222,21 @@ func (c *Compiler) compileNullish(left, right ast.Expression) error {
 	return nil
 }
 
+// discard runs compile and then drops the code it emitted in the current
+// scope. Symbol table changes such as reads and captures are kept.
+func (c *Compiler) discard(compile func() error) error {
+	saved := c.scopes[c.scopeIndex]
+	if err := compile(); err != nil {
+		return err
+	}
+	scope := &c.scopes[c.scopeIndex]
+	scope.instructions = scope.instructions[:len(saved.instructions)]
+	scope.pos = scope.pos[:len(saved.pos)]
+	scope.lastInstruction = saved.lastInstruction
+	scope.prevInstruction = saved.prevInstruction
+	return nil
+}
+
 func (c *Compiler) replaceOperand(opPos int, operand int) {
 	c.replaceOperands(opPos, operand)
 }
@@ -1934,11 +2250,283 @@ func (c *Compiler) replaceOperands(opPos int, operands ...int) {
 	}
 }
 
+// compileSliceOperands pushes the sliced value and the bounds of se, with
+// nil for each one omitted.
+func (c *Compiler) compileSliceOperands(se *ast.SliceExpression) error {
+	if err := c.Compile(se.Left); err != nil {
+		return err
+	}
+	for _, bound := range []ast.Expression{se.Low, se.High, se.Step} {
+		if bound == nil {
+			c.emit(code.OpNull)
+			continue
+		}
+		if err := c.Compile(bound); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxIndexChain is the most levels one OpIndexChain indexes through.
+const maxIndexChain = 255
+
+// indexChain returns the levels of a chained index like `grid[y][x]` (or
+// `grid[y, x]`) that one OpIndexChain can index through, innermost first.
+// The chain pushes every index before indexing, so each index after the
+// first must be a name or literal, whose evaluation can neither fail nor
+// have effects that indexing errors could be reordered with.
+func indexChain(n *ast.IndexExpression) []*ast.IndexExpression {
+	levels := []*ast.IndexExpression{n}
+	for len(levels) < maxIndexChain && simpleIndex(n.Index) {
+		inner, ok := n.Left.(*ast.IndexExpression)
+		if !ok {
+			break
+		}
+		levels = append(levels, inner)
+		n = inner
+	}
+	for i, j := 0, len(levels)-1; i < j; i, j = i+1, j-1 {
+		levels[i], levels[j] = levels[j], levels[i]
+	}
+	return levels
+}
+
+func simpleIndex(e ast.Expression) bool {
+	switch e.(type) {
+	case *ast.Identifier, *ast.IntegerLiteral, *ast.StringLiteral:
+		return true
+	}
+	return false
+}
+
+// compileIndexChain pushes the value the innermost level indexes and every
+// index, then emits one OpIndexChain instead of an OpIndex per level. The
+// operand ranges are the (left, index) pair of each level, so an error
+// points at the level that failed.
+func (c *Compiler) compileIndexChain(levels []*ast.IndexExpression) error {
+	if err := c.Compile(levels[0].Left); err != nil {
+		return err
+	}
+	operands := make([]ast.Node, 0, 2*len(levels))
+	for _, lvl := range levels {
+		if err := c.Compile(lvl.Index); err != nil {
+			return err
+		}
+		operands = append(operands, lvl.Left, lvl.Index)
+	}
+	outer := levels[len(levels)-1]
+	c.setPosFromToken(outer.Token)
+	c.setOperandSpans(outer, operands...)
+	c.emit(code.OpIndexChain, len(levels))
+	return nil
+}
+
+// compileStatement compiles s in statement position, where assignments
+// must not leave their value behind: inside a loop body every leftover slot
+// would accumulate until the value stack overflows.
+func (c *Compiler) compileStatement(s ast.Statement) error {
+	switch s := s.(type) {
+	case *ast.AssignStatement:
+		return c.compileAssign(s, false)
+	case *ast.IndexAssignStatement, *ast.MemberAssignStatement:
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+		return nil
+	}
+	return c.Compile(s)
+}
+
+// exportSymbol exports the named binding. A global is exported by its slot,
+// which the VM reads once the module has run; any other binding exports its
+// current value with OpExport.
+func (c *Compiler) exportSymbol(name string) error {
+	sym, ok := c.symbols.Resolve(name)
+	if !ok {
+		return fmt.Errorf("exported name not defined: %s", name)
+	}
+	switch sym.Scope {
+	case GlobalScope:
+		c.exports[name] = sym.Index
+		return nil
+	case LocalScope:
+		c.emit(code.OpGetLocal, sym.Index)
+	default:
+		return fmt.Errorf("unsupported symbol scope: %s", sym.Scope)
+	}
+	c.emit(code.OpExport, c.addConstant(&object.String{Value: name}))
+	return nil
+}
+
+// compileAssign compiles a plain, walrus or compound assignment to a name.
+// With keep set the assigned value is left on the stack, as an assignment
+// expression needs; a statement-level assignment leaves nothing.
+func (c *Compiler) compileAssign(n *ast.AssignStatement, keep bool) error {
+	posTok := n.Token
+	if n.OpToken.Type != "" {
+		posTok = n.OpToken
+	}
+	c.setPosFromToken(posTok)
+
+	op := n.Op
+	if op == token.WALRUS {
+		if err := c.Compile(n.Value); err != nil {
+			return err
+		}
+
+		sym, ok := c.symbols.ResolveCurrent(n.Name.Value)
+		if !ok {
+			sym = c.define(n.Name.Value, n.Name.Token)
+		}
+		nameIdx := c.addConstant(&object.String{Value: n.Name.Value})
+
+		switch sym.Scope {
+		case GlobalScope:
+			c.emit(code.OpDefineGlobal, sym.Index, nameIdx)
+		case LocalScope:
+			c.emit(code.OpDefineLocal, sym.Index, nameIdx)
+		default:
+			return fmt.Errorf("unsupported symbol scope for walrus: %s", sym.Scope)
+		}
+		if keep {
+			return c.emitGetSymbol(sym)
+		}
+		return nil
+	}
+	if op == "" || op == token.ASSIGN {
+		if err := c.Compile(n.Value); err != nil {
+			return err
+		}
+
+		sym, ok := c.symbols.Resolve(n.Name.Value)
+		if !ok {
+			sym = c.define(n.Name.Value, n.Name.Token)
+		}
+
+		switch sym.Scope {
+		case GlobalScope:
+			c.emit(code.OpSetGlobal, sym.Index)
+		case LocalScope:
+			c.emit(code.OpSetLocal, sym.Index)
+		case FreeScope:
+			c.emit(code.OpSetFree, sym.Index)
+		default:
+			return fmt.Errorf("unsupported symbol scope: %s", sym.Scope)
+		}
+		if keep {
+			return c.emitGetSymbol(sym)
+		}
+		return nil
+	}
+
+	opcode, ok := compoundAssignOpcode(op)
+	if !ok {
+		return fmt.Errorf("unsupported assignment operator: %s", op)
+	}
+
+	sym, ok := c.symbols.Resolve(n.Name.Value)
+	if !ok {
+		return fmt.Errorf("unknown identifier: %s", n.Name.Value)
+	}
+
+	emitSet := func() error {
+		switch sym.Scope {
+		case GlobalScope:
+			c.emit(code.OpSetGlobal, sym.Index)
+		case LocalScope:
+			c.emit(code.OpSetLocal, sym.Index)
+		case FreeScope:
+			c.emit(code.OpSetFree, sym.Index)
+		default:
+			return fmt.Errorf("unsupported symbol scope: %s", sym.Scope)
+		}
+		return nil
+	}
+
+	if err := c.emitGetSymbol(sym); err != nil {
+		return err
+	}
+	if err := c.Compile(n.Value); err != nil {
+		return err
+	}
+	c.emit(opcode)
+	if err := emitSet(); err != nil {
+		return err
+	}
+	if keep {
+		return c.emitGetSymbol(sym)
+	}
+	return nil
+}
+
+func (c *Compiler) emitGetSymbol(sym Symbol) error {
+	switch sym.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, sym.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, sym.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, sym.Index)
+	default:
+		return fmt.Errorf("unsupported symbol scope: %s", sym.Scope)
+	}
+	return nil
+}
+
+// emitCaptures pushes what a new closure captures for each free symbol: a
+// Cell shared with the enclosing function for locals it may still rebind,
+// the current value for locals bound only once, and the enclosing closure's
+// own capture, whichever form it has, for free symbols.
+func (c *Compiler) emitCaptures(freeSymbols []Symbol) error {
+	for _, fs := range freeSymbols {
+		switch fs.Scope {
+		case LocalScope:
+			if c.symbols.singleAssignment[fs.Name] {
+				c.emit(code.OpGetLocal, fs.Index)
+			} else {
+				c.emit(code.OpGetLocalCell, fs.Index)
+			}
+		case FreeScope:
+			c.emit(code.OpGetFreeCell, fs.Index)
+		case GlobalScope:
+			c.emit(code.OpGetGlobal, fs.Index)
+		default:
+			return fmt.Errorf("unsupported free symbol scope: %s", fs.Scope)
+		}
+	}
+	return nil
+}
+
+// compileDefaults evaluates a function's parameter defaults, left to right,
+// and stores them in the closure OpClosure just pushed.
+func (c *Compiler) compileDefaults(defaults []ast.Expression) error {
+	n := 0
+	for _, d := range defaults {
+		if d == nil {
+			continue
+		}
+		if err := c.Compile(d); err != nil {
+			return err
+		}
+		n++
+	}
+	if n > 0 {
+		c.emit(code.OpDefaults, n)
+	}
+	return nil
+}
+
 func (c *Compiler) compileFunction(name string, params []*ast.Identifier, body *ast.BlockStatement) (*object.CompiledFunction, []Symbol, error) {
 	c.enterScope()
+	c.symbols.singleAssignment = singleAssignmentNames(params, body)
+	c.bodyNames = append(c.bodyNames, scopeNames{scope: c.scopeIndex, names: topLevelNames(body.Statements)})
+	defer func() { c.bodyNames = c.bodyNames[:len(c.bodyNames)-1] }()
 
 	for _, p := range params {
 		c.symbols.Define(p.Value)
+		c.checkBuiltinShadow(p.Value, p.Token)
 	}
 
 	if err := c.Compile(body); err != nil {
@@ -1949,7 +2537,9 @@ func (c *Compiler) compileFunction(name string, params []*ast.Identifier, body *
 		c.emit(code.OpReturn)
 	}
 
+	c.reportUnusedLocals()
 	numLocals := c.symbols.numDefinitions
+	localNames := c.symbols.SlotNames()
 	freeSymbols := c.symbols.FreeSymbols
 	instructions, pos := c.leaveScope()
 	if name == "" {
@@ -1960,8 +2550,10 @@ func (c *Compiler) compileFunction(name string, params []*ast.Identifier, body *
 		Instructions:  instructions,
 		NumLocals:     numLocals,
 		NumParameters: len(params),
+		LocalNames:    localNames,
 		Name:          name,
 		File:          c.file,
 		Pos:           pos,
+		Generator:     ast.IsGenerator(body),
 	}, freeSymbols, nil
 }
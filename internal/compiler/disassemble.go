@@ -27,6 +27,10 @@ func FormatConstants(constants []object.Object) string {
 			}
 			fmt.Fprintf(&b, "%04d COMPILED_FUNCTION %s (locals=%d params=%d ins=%dB)\n",
 				i, name, v.NumLocals, v.NumParameters, len(v.Instructions))
+		case *object.TupleShape:
+			fmt.Fprintf(&b, "%04d TUPLE_SHAPE (%s)\n", i, strings.Join(v.Names, ", "))
+		case *object.ClassShape:
+			fmt.Fprintf(&b, "%04d CLASS_SHAPE %s (%s) methods (%s)\n", i, v.Name, strings.Join(v.Fields, ", "), strings.Join(v.Methods, ", "))
 		default:
 			fmt.Fprintf(&b, "%04d %s %s\n", i, c.Type(), c.Inspect())
 		}
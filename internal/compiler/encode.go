@@ -0,0 +1,176 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"sort"
+
+	"welle/internal/code"
+	"welle/internal/object"
+)
+
+// BytecodeVersion identifies the encoding written by EncodeBytecode. Bump
+// it when the instruction set or the meaning of compiled code changes, so
+// cached modules from older builds are not reused.
+const BytecodeVersion = 13
+
+// wireBytecode and wireConst mirror Bytecode with the constant pool spelled
+// out, since gob cannot encode the object.Object interface directly.
+type wireBytecode struct {
+	Version      int
+	Instructions []byte
+	Constants    []wireConst
+	File         string
+	Pos          []code.SourcePos
+	NumGlobals   int
+	GlobalNames  []string
+	Exports      []wireSlot
+}
+
+// Maps are written as sorted slices so the same bytecode always encodes to
+// the same bytes.
+type wireSlot struct {
+	Name string
+	Slot int
+}
+
+type wireJump struct {
+	Int    int64
+	Str    string
+	Target int
+}
+
+type wireConst struct {
+	Kind        object.Type
+	Int         int64
+	Flt         float64
+	Str         string
+	Bool        bool
+	Fn          *object.CompiledFunction
+	JumpOnStr   bool // the table dispatches on strings rather than ints
+	JumpInts    []wireJump
+	JumpStrs    []wireJump
+	JumpDefault int
+	Names       []string // a tuple or class shape's field names
+	Methods     []string // a class shape's method names
+}
+
+// EncodeBytecode serializes bc. Only the constant kinds the compiler and
+// optimizer produce are supported; anything else is an error.
+func EncodeBytecode(bc *Bytecode) ([]byte, error) {
+	w := wireBytecode{
+		Version:      BytecodeVersion,
+		Instructions: bc.Instructions,
+		File:         bc.Debug.File,
+		Pos:          bc.Debug.Pos,
+		NumGlobals:   bc.NumGlobals,
+		GlobalNames:  bc.GlobalNames,
+	}
+	for name, slot := range bc.Exports {
+		w.Exports = append(w.Exports, wireSlot{Name: name, Slot: slot})
+	}
+	sort.Slice(w.Exports, func(i, j int) bool { return w.Exports[i].Name < w.Exports[j].Name })
+	for i, c := range bc.Constants {
+		wc := wireConst{Kind: c.Type()}
+		switch v := c.(type) {
+		case *object.Integer:
+			wc.Int = v.Value
+		case *object.Float:
+			wc.Flt = v.Value
+		case *object.String:
+			wc.Str = v.Value
+		case *object.Boolean:
+			wc.Bool = v.Value
+		case *object.Nil:
+		case *object.CompiledFunction:
+			wc.Fn = v
+		case *object.JumpTable:
+			for k, target := range v.Ints {
+				wc.JumpInts = append(wc.JumpInts, wireJump{Int: k, Target: target})
+			}
+			for k, target := range v.Strings {
+				wc.JumpStrs = append(wc.JumpStrs, wireJump{Str: k, Target: target})
+			}
+			sort.Slice(wc.JumpInts, func(i, j int) bool { return wc.JumpInts[i].Int < wc.JumpInts[j].Int })
+			sort.Slice(wc.JumpStrs, func(i, j int) bool { return wc.JumpStrs[i].Str < wc.JumpStrs[j].Str })
+			wc.JumpOnStr = v.Strings != nil
+			wc.JumpDefault = v.Default
+		case *object.TupleShape:
+			wc.Names = v.Names
+		case *object.ClassShape:
+			wc.Str, wc.Names, wc.Methods = v.Name, v.Fields, v.Methods
+		default:
+			return nil, fmt.Errorf("constant %d: cannot encode %s", i, c.Type())
+		}
+		w.Constants = append(w.Constants, wc)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&w); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeBytecode is the inverse of EncodeBytecode.
+func DecodeBytecode(b []byte) (*Bytecode, error) {
+	var w wireBytecode
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&w); err != nil {
+		return nil, err
+	}
+	if w.Version != BytecodeVersion {
+		return nil, fmt.Errorf("bytecode version %d, want %d", w.Version, BytecodeVersion)
+	}
+	bc := &Bytecode{
+		Instructions: w.Instructions,
+		Debug:        DebugInfo{File: w.File, Pos: w.Pos},
+		NumGlobals:   w.NumGlobals,
+		GlobalNames:  w.GlobalNames,
+		Exports:      make(map[string]int, len(w.Exports)),
+	}
+	for _, e := range w.Exports {
+		bc.Exports[e.Name] = e.Slot
+	}
+	for i, wc := range w.Constants {
+		var c object.Object
+		switch wc.Kind {
+		case object.INTEGER_OBJ:
+			c = &object.Integer{Value: wc.Int}
+		case object.FLOAT_OBJ:
+			c = &object.Float{Value: wc.Flt}
+		case object.STRING_OBJ:
+			c = &object.String{Value: wc.Str}
+		case object.BOOLEAN_OBJ:
+			c = &object.Boolean{Value: wc.Bool}
+		case object.NIL_OBJ:
+			c = &object.Nil{}
+		case object.COMPILED_FUNCTION_OBJ:
+			if wc.Fn != nil {
+				c = wc.Fn
+			}
+		case object.JUMP_TABLE_OBJ:
+			table := &object.JumpTable{Default: wc.JumpDefault}
+			if wc.JumpOnStr {
+				table.Strings = make(map[string]int, len(wc.JumpStrs))
+				for _, j := range wc.JumpStrs {
+					table.Strings[j.Str] = j.Target
+				}
+			} else {
+				table.Ints = make(map[int64]int, len(wc.JumpInts))
+				for _, j := range wc.JumpInts {
+					table.Ints[j.Int] = j.Target
+				}
+			}
+			c = table
+		case object.TUPLE_SHAPE_OBJ:
+			c = &object.TupleShape{Names: wc.Names}
+		case object.CLASS_SHAPE_OBJ:
+			c = &object.ClassShape{Name: wc.Str, Fields: wc.Names, Methods: wc.Methods}
+		}
+		if c == nil {
+			return nil, fmt.Errorf("constant %d: cannot decode %s", i, wc.Kind)
+		}
+		bc.Constants = append(bc.Constants, c)
+	}
+	return bc, nil
+}
@@ -0,0 +1,71 @@
+package compiler
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"welle/internal/lexer"
+	"welle/internal/parser"
+)
+
+func TestEncodeBytecodeRoundTrip(t *testing.T) {
+	src := `export PI = 3.14
+name = "welle"
+func add(a, b) { return a + b }
+func counter() {
+  n = 0
+  return func() { n += 1; return n }
+}
+switch (len(name)) { case 1 { print(1) } case 2 { print(2) } case 3 { print(3) } case 4 { print(4) } }
+kind = match (name) { case "a" { 1 } case "b" { 2 } case "c" { 3 } case "welle" { 4 } }
+p = (x: 1, y: 2)
+(y: py) = p
+class Pt {
+  x = 1
+  func get() { return self.x }
+}
+print(add(1, 2), counter()(), nil, true, kind, p.x, py, Pt().get())
+`
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	c := NewWithFile("/tmp/roundtrip.wll")
+	if err := c.Compile(prog); err != nil {
+		t.Fatal(err)
+	}
+	bc, err := (&Optimizer{}).Optimize(c.Bytecode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := EncodeBytecode(bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeBytecode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Instructions.String() != bc.Instructions.String() {
+		t.Fatalf("instructions differ:\n%s\nwant:\n%s", got.Instructions, bc.Instructions)
+	}
+	if FormatConstants(got.Constants) != FormatConstants(bc.Constants) {
+		t.Fatalf("constants differ:\n%s\nwant:\n%s", FormatConstants(got.Constants), FormatConstants(bc.Constants))
+	}
+	if got.Debug.File != bc.Debug.File || !reflect.DeepEqual(got.Debug.Pos, bc.Debug.Pos) {
+		t.Fatalf("debug info differs")
+	}
+	if got.NumGlobals != bc.NumGlobals || !reflect.DeepEqual(got.GlobalNames, bc.GlobalNames) || !reflect.DeepEqual(got.Exports, bc.Exports) {
+		t.Fatalf("globals differ: %d %v %v", got.NumGlobals, got.GlobalNames, got.Exports)
+	}
+	if err := Verify(got); err != nil {
+		t.Fatalf("decoded bytecode does not verify: %v", err)
+	}
+	again, err := EncodeBytecode(got)
+	if err != nil || !bytes.Equal(again, b) {
+		t.Fatalf("re-encoding gave different bytes (%v)", err)
+	}
+}
@@ -0,0 +1,74 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"welle/internal/lexer"
+	"welle/internal/object"
+	"welle/internal/parser"
+)
+
+func TestBuildFlagsFoldDeadBranches(t *testing.T) {
+	compile := func(src string, features ...string) (*Compiler, *Bytecode) {
+		p := parser.New(lexer.New(src))
+		prog := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("parse errors: %v", p.Errors())
+		}
+		c := New()
+		c.SetFeatures(features)
+		if err := c.Compile(prog); err != nil {
+			t.Fatal(err)
+		}
+		bc := c.Bytecode()
+		if err := Verify(bc); err != nil {
+			t.Fatalf("verify: %v", err)
+		}
+		return c, bc
+	}
+	strs := func(bc *Bytecode) []string {
+		var out []string
+		for _, k := range bc.Constants {
+			if s, ok := k.(*object.String); ok {
+				out = append(out, s.Value)
+			}
+		}
+		return out
+	}
+
+	src := "if (BUILD.gfx and not BUILD.headless) { x = \"gfx\" } else { x = \"text\" }\n"
+	for _, tt := range []struct {
+		features []string
+		want     string
+	}{
+		{nil, "text"},
+		{[]string{"gfx"}, "gfx"},
+		{[]string{"gfx", "headless"}, "text"},
+	} {
+		c, bc := compile(src, tt.features...)
+		if got := strs(bc); len(got) != 1 || got[0] != tt.want {
+			t.Fatalf("features %v: expected only %q compiled, got constants %v", tt.features, tt.want, got)
+		}
+		if ins := bc.Instructions.String(); strings.Contains(ins, "OpJump") {
+			t.Fatalf("features %v: expected no branch\n%s", tt.features, ins)
+		}
+		if !c.UsesFeatures() {
+			t.Fatalf("features %v: expected UsesFeatures", tt.features)
+		}
+	}
+
+	// A condition that mixes in a runtime value is compiled as usual.
+	_, bc := compile("y = true\nif (BUILD.gfx or y) { x = \"gfx\" } else { x = \"text\" }\n")
+	if got := strs(bc); len(got) != 2 {
+		t.Fatalf("expected both branches compiled, got constants %v", got)
+	}
+	// A BUILD the program binds is an ordinary value.
+	c, bc := compile("BUILD = #{\"gfx\": 1}\nif (BUILD.gfx) { x = \"gfx\" } else { x = \"text\" }\n", "gfx")
+	if ins := bc.Instructions.String(); !strings.Contains(ins, "OpJump") || c.UsesFeatures() {
+		t.Fatalf("expected BUILD read as a variable, UsesFeatures %v\n%s", c.UsesFeatures(), ins)
+	}
+	if c, _ := compile("x = 1\n"); c.UsesFeatures() {
+		t.Fatal("expected UsesFeatures to be false without BUILD flags")
+	}
+}
@@ -0,0 +1,73 @@
+package compiler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"welle/internal/lexer"
+	"welle/internal/object"
+	"welle/internal/parser"
+)
+
+func TestBytecodeGlobalsAndExports(t *testing.T) {
+	src := "a = 1\n" +
+		"export b = 2\n" +
+		"export func f() { c = 3; return c }\n" +
+		"b = b + a\n"
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	c := New()
+	if err := c.Compile(prog); err != nil {
+		t.Fatal(err)
+	}
+	bc := c.Bytecode()
+	if bc.NumGlobals != 3 {
+		t.Fatalf("NumGlobals = %d, want 3", bc.NumGlobals)
+	}
+	if want := map[string]int{"b": 1, "f": 2}; !reflect.DeepEqual(bc.Exports, want) {
+		t.Fatalf("Exports = %v, want %v", bc.Exports, want)
+	}
+	if strings.Contains(bc.Instructions.String(), "OpExport") {
+		t.Fatalf("global exports should not emit OpExport\n%s", bc.Instructions)
+	}
+	if err := Verify(bc); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+}
+
+func TestBytecodeSlotNames(t *testing.T) {
+	src := "a = 1\n" +
+		"func f(x) { y = x + a; return y }\n"
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	c := New()
+	if err := c.Compile(prog); err != nil {
+		t.Fatal(err)
+	}
+	bc := c.Bytecode()
+	if want := []string{"a", "f"}; !reflect.DeepEqual(bc.GlobalNames, want) {
+		t.Fatalf("GlobalNames = %v, want %v", bc.GlobalNames, want)
+	}
+	var fn *object.CompiledFunction
+	for _, k := range bc.Constants {
+		if f, ok := k.(*object.CompiledFunction); ok {
+			fn = f
+		}
+	}
+	if fn == nil {
+		t.Fatal("no compiled function in constants")
+	}
+	if len(fn.LocalNames) != fn.NumLocals {
+		t.Fatalf("LocalNames = %v, want %d slots", fn.LocalNames, fn.NumLocals)
+	}
+	if fn.LocalNames[0] != "x" || fn.LocalNames[1] != "y" {
+		t.Fatalf("LocalNames = %v, want [x y ...]", fn.LocalNames)
+	}
+}
@@ -0,0 +1,49 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"welle/internal/ast"
+	"welle/internal/ice"
+)
+
+// recoverInternal is deferred by Compile for a whole program. It turns a
+// panic, a bug in the compiler, into an internal error returned through
+// err. The reproduction is the fewest top-level statements of prog that
+// still make a new compiler panic the same way.
+func (c *Compiler) recoverInternal(prog *ast.Program, err *error) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	e := ice.New("compiler", v)
+	stmts := ice.Minimize(prog.Statements, func(stmts []ast.Statement) bool {
+		return ice.Panics(v, func() {
+			_ = NewWithFile(c.file).compileProgram(&ast.Program{Statements: stmts, Edition: prog.Edition})
+		})
+	})
+	e.Repro = sourceOf(stmts)
+	ice.Report(e)
+	*err = e
+}
+
+// sourceOf prints stmts back as source. The AST that broke the compiler
+// may not print either; such a statement is left as a comment naming it.
+func sourceOf(stmts []ast.Statement) string {
+	var b strings.Builder
+	for _, s := range stmts {
+		b.WriteString(statementSource(s))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func statementSource(s ast.Statement) (src string) {
+	defer func() {
+		if recover() != nil {
+			src = fmt.Sprintf("/* %T that cannot be printed */", s)
+		}
+	}()
+	return s.String()
+}
@@ -0,0 +1,39 @@
+package compiler
+
+import (
+	"errors"
+	"testing"
+
+	"welle/internal/ast"
+	"welle/internal/ice"
+	"welle/internal/lexer"
+	"welle/internal/parser"
+)
+
+func TestCompileInternalError(t *testing.T) {
+	var reported []*ice.Error
+	defer ice.SetReporter(nil)
+	ice.SetReporter(func(e *ice.Error) { reported = append(reported, e) })
+
+	p := parser.New(lexer.New("a = 1\nprint(b)\nc = 3\n"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	// An AST no parser makes: the argument is a nil identifier.
+	call := prog.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	call.Arguments[0] = (*ast.Identifier)(nil)
+
+	err := NewWithFile("t.wll").Compile(prog)
+	var e *ice.Error
+	if !errors.As(err, &e) || e.Phase != "compiler" {
+		t.Fatalf("err = %v, want an internal error in the compiler", err)
+	}
+	if len(reported) != 1 || reported[0] != e {
+		t.Fatalf("reported = %v, want the returned error", reported)
+	}
+	// Minimized to the one statement, which cannot be printed either.
+	if e.Repro != "/* *ast.ExpressionStatement that cannot be printed */\n" {
+		t.Fatalf("repro = %q", e.Repro)
+	}
+}
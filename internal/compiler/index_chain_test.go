@@ -0,0 +1,52 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"welle/internal/lexer"
+	"welle/internal/parser"
+)
+
+func TestIndexChainSelection(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		chain string
+	}{
+		{"names", `v = g[y][x]`, "OpIndexChain 2"},
+		{"tuple index sugar", `v = g[y, x]`, "OpIndexChain 2"},
+		{"literals", `v = g[0]["a"][1]`, "OpIndexChain 3"},
+		{"negative literal", `v = g[0]["a"][-1]`, "OpIndexChain 2"},
+		{"three levels", `v = g[0][y][x]`, "OpIndexChain 3"},
+		{"computed first index", `v = g[y + 1][x]`, "OpIndexChain 2"},
+		{"computed later index", `v = g[y][x + 1]`, ""},
+		{"call later index", `v = g[y][f()]`, ""},
+		{"single index", `v = g[y]`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.New(lexer.New("g = []\ny = 0\nx = 0\nfunc f() { return 0 }\n" + tt.src))
+			prog := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("parse errors: %v", p.Errors())
+			}
+			c := New()
+			if err := c.Compile(prog); err != nil {
+				t.Fatal(err)
+			}
+			bc := c.Bytecode()
+			dump := bc.Instructions.String()
+			if tt.chain == "" {
+				if strings.Contains(dump, "OpIndexChain") {
+					t.Fatalf("unexpected OpIndexChain\n%s", dump)
+				}
+			} else if !strings.Contains(dump, tt.chain+"\n") {
+				t.Fatalf("expected %s\n%s", tt.chain, dump)
+			}
+			if err := Verify(bc); err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package compiler
+
+import (
+	"welle/internal/ast"
+	"welle/internal/code"
+	"welle/internal/object"
+)
+
+// minJumpTableCases is the fewest case values for which a switch or match
+// gets a jump table; a shorter chain of comparisons is as fast.
+const minJumpTableCases = 4
+
+// maxJumpTableSpread bounds how sparse integer cases may be: their range may
+// be at most this many times the number of values.
+const maxJumpTableSpread = 4
+
+// jumpTableKey returns the key for a constant case value: an integer literal,
+// possibly negated, or a plain string literal.
+func jumpTableKey(e ast.Expression) (any, bool) {
+	switch v := e.(type) {
+	case *ast.IntegerLiteral:
+		return v.Value, true
+	case *ast.PrefixExpression:
+		if lit, ok := v.Right.(*ast.IntegerLiteral); ok && v.Operator == "-" {
+			return -lit.Value, true
+		}
+	case *ast.StringLiteral:
+		return v.Value, true
+	}
+	return nil, false
+}
+
+// newJumpTable returns an empty table for the case values, or nil when a
+// chain of comparisons should be used instead: some value is not a constant,
+// integers and strings are mixed, there are too few values, or integer
+// values are too sparse.
+func newJumpTable(values []ast.Expression) *object.JumpTable {
+	if len(values) < minJumpTableCases {
+		return nil
+	}
+	var ints, strs int
+	var lo, hi int64
+	for _, v := range values {
+		key, ok := jumpTableKey(v)
+		if !ok {
+			return nil
+		}
+		switch k := key.(type) {
+		case int64:
+			if ints == 0 || k < lo {
+				lo = k
+			}
+			if ints == 0 || k > hi {
+				hi = k
+			}
+			ints++
+		case string:
+			strs++
+		}
+	}
+	switch {
+	case strs == 0:
+		if span := uint64(hi - lo); span >= uint64(maxJumpTableSpread*ints) {
+			return nil
+		}
+		return &object.JumpTable{Ints: map[int64]int{}}
+	case ints == 0:
+		return &object.JumpTable{Strings: map[string]int{}}
+	}
+	return nil
+}
+
+// emitJumpTable emits a table dispatch on the value pushed by emitSubject
+// ahead of the comparison chain for the given case values. It returns nil,
+// emitting nothing, when the cases do not qualify. The caller fills in the
+// targets with setJumpTargets and the table's Default.
+func (c *Compiler) emitJumpTable(values []ast.Expression, emitSubject func()) *object.JumpTable {
+	table := newJumpTable(values)
+	if table == nil {
+		return nil
+	}
+	emitSubject()
+	c.emit(code.OpJumpTable, c.addConstant(table))
+	return table
+}
+
+// setJumpTargets points the case values at target. A value already in the
+// table keeps its earlier target, as the first matching case wins.
+func setJumpTargets(table *object.JumpTable, values []ast.Expression, target int) {
+	if table == nil {
+		return
+	}
+	for _, v := range values {
+		key, _ := jumpTableKey(v)
+		switch k := key.(type) {
+		case int64:
+			if _, ok := table.Ints[k]; !ok {
+				table.Ints[k] = target
+			}
+		case string:
+			if _, ok := table.Strings[k]; !ok {
+				table.Strings[k] = target
+			}
+		}
+	}
+}
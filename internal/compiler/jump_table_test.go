@@ -0,0 +1,53 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"welle/internal/lexer"
+	"welle/internal/parser"
+)
+
+func TestJumpTableSelection(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		table bool
+	}{
+		{"dense ints", `switch (x) { case 1 { a() } case 2, 3 { b() } case 4 { c() } }`, true},
+		{"negative ints", `switch (x) { case -2 { a() } case -1 { b() } case 0 { c() } case 1 { d() } }`, true},
+		{"strings", `y = match (x) { case "a" { 1 } case "b" { 2 } case "c" { 3 } case "d" { 4 } }`, true},
+		{"too few cases", `switch (x) { case 1 { a() } case 2 { b() } case 3 { c() } }`, false},
+		{"sparse ints", `switch (x) { case 1 { a() } case 100 { b() } case 1000 { c() } case 10000 { d() } }`, false},
+		{"mixed kinds", `switch (x) { case 1 { a() } case "2" { b() } case 3 { c() } case 4 { d() } }`, false},
+		{"non-constant", `switch (x) { case 1 { a() } case 2 { b() } case 3 { c() } case y { d() } }`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.New(lexer.New("x = 0\ny = 0\nfunc a() {}\nfunc b() {}\nfunc c() {}\nfunc d() {}\n" + tt.src))
+			prog := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("parse errors: %v", p.Errors())
+			}
+			c := New()
+			if err := c.Compile(prog); err != nil {
+				t.Fatal(err)
+			}
+			bc := c.Bytecode()
+			got := strings.Contains(bc.Instructions.String(), "OpJumpTable")
+			if got != tt.table {
+				t.Fatalf("jump table emitted = %v, want %v\n%s", got, tt.table, bc.Instructions)
+			}
+			if err := Verify(bc); err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			opt, err := (&Optimizer{}).Optimize(bc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Verify(opt); err != nil {
+				t.Fatalf("verify after optimize: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package compiler
+
+import (
+	"fmt"
+
+	"welle/internal/ast"
+	"welle/internal/code"
+	"welle/internal/object"
+	"welle/internal/semantics"
+)
+
+// patternCompiler compiles the checks of one match pattern. Each check
+// leaves a bool and jumps to the next case value when it is false; the
+// names are bound only after every check has passed, so a pattern that
+// fails halfway leaves them as they were.
+type patternCompiler struct {
+	c     *Compiler
+	fails []int
+	binds []patternBind
+}
+
+// patternBind is a name and the code that loads the part it binds.
+type patternBind struct {
+	name *ast.Identifier
+	load func() error
+}
+
+// compilePattern emits the checks and bindings of pat against the subject
+// that load pushes, and returns the positions of the jumps taken when it
+// does not match.
+func (c *Compiler) compilePattern(pat *ast.MatchPattern, load func() error) ([]int, error) {
+	pc := &patternCompiler{c: c}
+	if err := pc.part(pat.Value, load, true); err != nil {
+		return nil, err
+	}
+	for _, b := range pc.binds {
+		if err := b.load(); err != nil {
+			return nil, err
+		}
+		sym, ok := c.symbols.Resolve(b.name.Value)
+		if !ok {
+			sym = c.define(b.name.Value, b.name.Token)
+		}
+		switch sym.Scope {
+		case GlobalScope:
+			c.emit(code.OpSetGlobal, sym.Index)
+		case LocalScope:
+			c.emit(code.OpSetLocal, sym.Index)
+		case FreeScope:
+			c.emit(code.OpSetFree, sym.Index)
+		default:
+			return nil, fmt.Errorf("unsupported symbol scope: %s", sym.Scope)
+		}
+	}
+	return pc.fails, nil
+}
+
+func (pc *patternCompiler) check() {
+	pc.fails = append(pc.fails, pc.c.emit(code.OpJumpNotTruthy, 9999))
+}
+
+func (pc *patternCompiler) shape(load func() error, shape, n int) error {
+	if err := load(); err != nil {
+		return err
+	}
+	pc.c.emit(code.OpMatchShape, shape, n)
+	pc.check()
+	return nil
+}
+
+// part emits the checks of the pattern part pat against the value that
+// load pushes. held is false when load indexes into an enclosing value;
+// a nested tuple, array or dict pattern then keeps the part in a temporary
+// rather than indexing again for each of its own parts.
+func (pc *patternCompiler) part(pat ast.Expression, load func() error, held bool) error {
+	c := pc.c
+	switch pat.(type) {
+	case *ast.TupleLiteral, *ast.ListLiteral, *ast.DictLiteral:
+		if !held {
+			if err := load(); err != nil {
+				return err
+			}
+			load = pc.store()
+		}
+	}
+	switch p := pat.(type) {
+	case *ast.Identifier:
+		if p.Value != "_" {
+			pc.binds = append(pc.binds, patternBind{name: p, load: load})
+		}
+		return nil
+
+	case *ast.TupleLiteral:
+		if err := pc.shape(load, semantics.ShapeTuple, len(p.Elements)); err != nil {
+			return err
+		}
+		return pc.elements(p.Elements, load, 0)
+
+	case *ast.ListLiteral:
+		rest := -1
+		for i, el := range p.Elements {
+			if _, ok := el.(*ast.SpreadExpression); ok {
+				rest = i
+			}
+		}
+		if rest < 0 {
+			if err := pc.shape(load, semantics.ShapeArray, len(p.Elements)); err != nil {
+				return err
+			}
+			return pc.elements(p.Elements, load, 0)
+		}
+		if err := pc.shape(load, semantics.ShapeArrayMin, len(p.Elements)-1); err != nil {
+			return err
+		}
+		tail := len(p.Elements) - rest - 1
+		if err := pc.elements(p.Elements[:rest], load, 0); err != nil {
+			return err
+		}
+		// The rest is sliced only when it is bound, after every check.
+		restLoad := func() error {
+			if err := load(); err != nil {
+				return err
+			}
+			c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: int64(rest)}))
+			if tail > 0 {
+				c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: int64(-tail)}))
+			} else {
+				c.emit(code.OpNull)
+			}
+			c.emit(code.OpNull)
+			c.emit(code.OpSlice)
+			return nil
+		}
+		if err := pc.part(p.Elements[rest].(*ast.SpreadExpression).Value, restLoad, false); err != nil {
+			return err
+		}
+		return pc.elements(p.Elements[rest+1:], load, -tail)
+
+	case *ast.DictLiteral:
+		if err := pc.shape(load, semantics.ShapeDict, 0); err != nil {
+			return err
+		}
+		for _, pair := range p.Pairs {
+			sub := pair.Value
+			var key func() error
+			if pair.Shorthand != nil {
+				sub = pair.Shorthand
+				idx := c.addConstant(&object.String{Value: pair.Shorthand.Value})
+				key = func() error {
+					c.emit(code.OpConstant, idx)
+					return nil
+				}
+			} else {
+				if err := c.Compile(pair.Key); err != nil {
+					return err
+				}
+				key = pc.store()
+			}
+			if err := key(); err != nil {
+				return err
+			}
+			if err := load(); err != nil {
+				return err
+			}
+			c.emit(code.OpIn)
+			pc.check()
+			if err := pc.part(sub, index(c, load, key), false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := load(); err != nil {
+		return err
+	}
+	if err := c.Compile(pat); err != nil {
+		return err
+	}
+	c.emit(code.OpMatchEqual)
+	pc.check()
+	return nil
+}
+
+// elements emits the checks of the element patterns pats, the first of
+// which is at index first (negative to count from the end).
+func (pc *patternCompiler) elements(pats []ast.Expression, load func() error, first int) error {
+	for i, el := range pats {
+		idx := pc.c.addConstant(&object.Integer{Value: int64(first + i)})
+		key := func() error {
+			pc.c.emit(code.OpConstant, idx)
+			return nil
+		}
+		if err := pc.part(el, index(pc.c, load, key), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// store pops the top of the stack into a new temporary and returns the
+// code that pushes it again: a nested pattern or a dict key is evaluated
+// once, however many of its parts are checked.
+func (pc *patternCompiler) store() func() error {
+	c := pc.c
+	tmp := c.newTempSymbol("pattern")
+	switch tmp.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, tmp.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, tmp.Index)
+	}
+	return func() error { return c.emitGetSymbol(tmp) }
+}
+
+func index(c *Compiler, container, key func() error) func() error {
+	return func() error {
+		if err := container(); err != nil {
+			return err
+		}
+		if err := key(); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+		return nil
+	}
+}
@@ -2,18 +2,24 @@ package compiler
 
 import (
 	"welle/internal/code"
+	"welle/internal/diag"
 	"welle/internal/object"
 )
 
-type Optimizer struct{}
+type Optimizer struct {
+	// Warnings collects non-fatal findings from the last Optimize call,
+	// such as constant expressions whose integer result overflows.
+	Warnings []diag.Diagnostic
+}
 
 func (o *Optimizer) Optimize(bc *Bytecode) (*Bytecode, error) {
-	if err := optimizeInstructions(&bc.Instructions, &bc.Debug.Pos, &bc.Constants); err != nil {
+	o.Warnings = nil
+	if err := o.optimizeInstructions(&bc.Instructions, &bc.Debug.Pos, &bc.Constants); err != nil {
 		return nil, err
 	}
 	for i := 0; i < len(bc.Constants); i++ {
 		if fn, ok := bc.Constants[i].(*object.CompiledFunction); ok {
-			if err := optimizeInstructions(&fn.Instructions, &fn.Pos, &bc.Constants); err != nil {
+			if err := o.optimizeInstructions(&fn.Instructions, &fn.Pos, &bc.Constants); err != nil {
 				return nil, err
 			}
 		}
@@ -21,12 +27,16 @@ func (o *Optimizer) Optimize(bc *Bytecode) (*Bytecode, error) {
 	return bc, nil
 }
 
-func optimizeInstructions(ins *code.Instructions, pos *[]SourcePos, constants *[]object.Object) error {
+func (o *Optimizer) optimizeInstructions(ins *code.Instructions, pos *[]SourcePos, constants *[]object.Object) error {
+	onOverflow := func(p SourcePos, operator string, result int64) {
+		o.Warnings = append(o.Warnings, overflowWarning(p, operator, result))
+	}
 	var err error
-	*ins, *pos, err = foldConstants(*ins, *pos, constants)
+	*ins, *pos, err = foldConstants(*ins, *pos, constants, onOverflow)
 	if err != nil {
 		return err
 	}
-	*ins, *pos = peephole(*ins, *pos)
+	*ins, *pos = peephole(*ins, *pos, *constants)
+	*ins, *pos = fuseSuperinstructions(*ins, *pos, *constants)
 	return nil
 }
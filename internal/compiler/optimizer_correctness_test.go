@@ -86,6 +86,49 @@ print(c)`,
 f = make(2)
 print(f(3))`,
 		},
+		{
+			name: "switch_jump_table",
+			src: `func pick(x) {
+  out = 0
+  switch (x) {
+    case 1 { out = 1 + 1 }
+    case 2, 3 { out = 2 * 10 }
+    case 4 { out = 3 + 4 * 5 }
+    default { out = 0 - 1 }
+  }
+  return out + match (x) { case 1 { 100 } case 2 { 200 } case 3 { 300 } case 4 { 400 } default { 1 + 1 } }
+}
+for v in [1, 2, 3, 4, 5, 2.0] { print(pick(v)) }`,
+		},
+		{
+			name: "superinstructions",
+			src: `func run(n) {
+  p = #{"x": 2}
+  i = 0
+  f = 0.5
+  s = 0
+  while (i < n) {
+    s += p.x
+    f = f + 1
+    if (i >= 3 and i != 5) { s = s + 10 }
+    i = i + 1
+  }
+  bump = func() { i = i + 1; return i }
+  bump()
+  return (s, f, i)
+}
+print(run(8))`,
+		},
+		{
+			name: "superinstruction_errors",
+			src: `func inc(v) { v = v + 1; return v }
+func member(v) { return v.x }
+func less(a, b) { if (a < b) { return 1 } return 2 }
+try { inc("a") } catch (e) { print(e.message) }
+try { member(nil) } catch (e) { print(e.message) }
+try { less("a", 1) } catch (e) { print(e.message) }
+inc([])`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -179,3 +222,43 @@ func normalizeVMError(msg string) string {
 	}
 	return msg
 }
+
+// BenchmarkLoopDispatch runs a loop whose body is made of the sequences the
+// optimizer fuses; compare the plain and optimized sub-benchmarks.
+func BenchmarkLoopDispatch(b *testing.B) {
+	src := `func run(n) {
+  i = 0
+  total = 0
+  while (i < n) {
+    total += i
+    i = i + 1
+  }
+  return total
+}
+run(100000)`
+	program := parser.New(lexer.New(src)).ParseProgram()
+	for _, optimize := range []bool{false, true} {
+		name := "plain"
+		if optimize {
+			name = "optimized"
+		}
+		b.Run(name, func(b *testing.B) {
+			c := compiler.New()
+			if err := c.Compile(program); err != nil {
+				b.Fatal(err)
+			}
+			bc := c.Bytecode()
+			if optimize {
+				var err error
+				if bc, err = (&compiler.Optimizer{}).Optimize(bc); err != nil {
+					b.Fatal(err)
+				}
+			}
+			for b.Loop() {
+				if err := vm.New(bc).Run(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
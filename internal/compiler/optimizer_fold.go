@@ -5,7 +5,20 @@ import (
 	"welle/internal/object"
 )
 
-func foldConstants(ins code.Instructions, pos []SourcePos, constants *[]object.Object) (code.Instructions, []SourcePos, error) {
+// foldConstants evaluates operators whose operands are constants. Integer
+// results that wrap around are still folded (the VM wraps the same way), but
+// are passed to onOverflow when it is non-nil.
+func foldConstants(ins code.Instructions, pos []SourcePos, constants *[]object.Object, onOverflow func(SourcePos, string, int64)) (code.Instructions, []SourcePos, error) {
+	reportOverflow := func(at int, op code.Opcode, a, b int64) {
+		if onOverflow == nil {
+			return
+		}
+		if operator, result, overflow := intOverflow(op, a, b); overflow {
+			p, _ := posAt(pos, at)
+			onOverflow(p, operator, result)
+		}
+	}
+
 	rewrite := func(at int, op code.Opcode, ins code.Instructions) (code.Instructions, int, bool, error) {
 		if left, leftSize, ok := readConstAt(ins, at, *constants); ok {
 			if right, rightSize, ok := readConstAt(ins, at+leftSize, *constants); ok {
@@ -18,6 +31,11 @@ func foldConstants(ins code.Instructions, pos []SourcePos, constants *[]object.O
 							return nil, 0, false, err
 						}
 						if ok {
+							if li, lok := left.(*object.Integer); lok {
+								if ri, rok := right.(*object.Integer); rok {
+									reportOverflow(opOffset, binOp, li.Value, ri.Value)
+								}
+							}
 							size := leftSize + rightSize + instrSize(ins, opOffset)
 							return constToInstruction(res, constants), size, true, nil
 						}
@@ -33,6 +51,9 @@ func foldConstants(ins code.Instructions, pos []SourcePos, constants *[]object.O
 				if unOp == code.OpMinus || unOp == code.OpBang || unOp == code.OpBitNot {
 					res, ok := foldUnary(unOp, val)
 					if ok {
+						if vi, iok := val.(*object.Integer); iok && unOp == code.OpMinus {
+							reportOverflow(next, unOp, vi.Value, 0)
+						}
 						size := valSize + instrSize(ins, next)
 						return constToInstruction(res, constants), size, true, nil
 					}
@@ -46,7 +67,7 @@ func foldConstants(ins code.Instructions, pos []SourcePos, constants *[]object.O
 	changed := true
 	for changed {
 		var err error
-		ins, pos, changed, err = rebuild(ins, pos, rewrite)
+		ins, pos, changed, err = rebuild(ins, pos, *constants, rewrite)
 		if err != nil {
 			return nil, nil, err
 		}
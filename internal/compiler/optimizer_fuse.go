@@ -0,0 +1,135 @@
+package compiler
+
+import (
+	"welle/internal/code"
+	"welle/internal/object"
+)
+
+// fuseSuperinstructions replaces sequences that dominate loop bodies with a
+// single opcode, so the VM dispatches once instead of two to four times:
+//
+//	OpGetLocal i; OpConstant k; OpAdd; OpSetLocal i  =>  OpIncLocal i k
+//	OpGetLocal i; OpGetMember n                       =>  OpGetLocalMember i n
+//	<comparison>; OpJumpNotTruthy t                   =>  OpCompareJump <comparison> t
+//
+// OpIncLocal is only used when constant k is an integer. A sequence is left
+// alone when a jump or catch handler lands on any instruction after its
+// first, since that offset disappears.
+//
+// The fused instruction keeps the source position of the one it replaces
+// that can fail (OpGetMember, OpAdd or the comparison), so its errors
+// point at the same operands as they do unoptimized.
+func fuseSuperinstructions(ins code.Instructions, pos []SourcePos, constants []object.Object) (code.Instructions, []SourcePos) {
+	targets := jumpTargets(ins, constants)
+	opAt := func(i int) (code.Opcode, bool) {
+		if i >= len(ins) || targets[i] {
+			return 0, false
+		}
+		return code.Opcode(ins[i]), true
+	}
+
+	// fuse returns the instruction replacing the sequence at at, its
+	// length in ins and the offset of the instruction whose position the
+	// replacement takes.
+	fuse := func(at int, op code.Opcode) (code.Instructions, int, int, bool) {
+		switch op {
+		case code.OpGetLocal:
+			local := int(ins[at+1])
+			next := at + instrSize(ins, at)
+			nextOp, ok := opAt(next)
+			if !ok {
+				break
+			}
+			switch nextOp {
+			case code.OpGetMember:
+				name := int(code.ReadUint16(ins[next+1:]))
+				return code.Make(code.OpGetLocalMember, local, name), next + instrSize(ins, next) - at, next, true
+			case code.OpConstant:
+				k := int(code.ReadUint16(ins[next+1:]))
+				if _, ok := constants[k].(*object.Integer); !ok {
+					break
+				}
+				add := next + instrSize(ins, next)
+				if addOp, ok := opAt(add); !ok || addOp != code.OpAdd {
+					break
+				}
+				set := add + instrSize(ins, add)
+				if setOp, ok := opAt(set); !ok || setOp != code.OpSetLocal || int(ins[set+1]) != local {
+					break
+				}
+				return code.Make(code.OpIncLocal, local, k), set + instrSize(ins, set) - at, add, true
+			}
+		case code.OpEqual, code.OpNotEqual, code.OpIs, code.OpGreaterThan,
+			code.OpLessThan, code.OpLessEqual, code.OpGreaterEqual:
+			next := at + instrSize(ins, at)
+			if nextOp, ok := opAt(next); ok && nextOp == code.OpJumpNotTruthy {
+				target := int(code.ReadUint16(ins[next+1:]))
+				return code.Make(code.OpCompareJump, int(op), target), next + instrSize(ins, next) - at, at, true
+			}
+		}
+		return nil, 0, 0, false
+	}
+
+	// Every offset of a fused sequence maps to the replacement, and the
+	// VM looks up the last position recorded there; drop all but the kept
+	// one before rebuild remaps them.
+	drop := map[int]bool{}
+	for i := 0; i < len(ins); {
+		_, size, keep, ok := fuse(i, code.Opcode(ins[i]))
+		if !ok {
+			i += instrSize(ins, i)
+			continue
+		}
+		for j := i; j < i+size; j += instrSize(ins, j) {
+			drop[j] = j != keep
+		}
+		i += size
+	}
+	kept := make([]SourcePos, 0, len(pos))
+	for _, p := range pos {
+		if !drop[p.Offset] {
+			kept = append(kept, p)
+		}
+	}
+
+	rewrite := func(at int, op code.Opcode, _ code.Instructions) (code.Instructions, int, bool, error) {
+		repl, size, _, ok := fuse(at, op)
+		return repl, size, ok, nil
+	}
+	out, newPos, _, _ := rebuild(ins, kept, constants, rewrite)
+	return out, newPos
+}
+
+// jumpTargets marks every offset a jump or exception handler can transfer
+// control to.
+func jumpTargets(ins code.Instructions, constants []object.Object) []bool {
+	targets := make([]bool, len(ins)+1)
+	mark := func(t int) {
+		if t >= 0 && t < len(targets) {
+			targets[t] = true
+		}
+	}
+	for i := 0; i < len(ins); i += instrSize(ins, i) {
+		op := code.Opcode(ins[i])
+		def, ok := code.Lookup(op)
+		if !ok {
+			continue
+		}
+		operands, _ := code.ReadOperands(def, ins[i+1:])
+		switch op {
+		case code.OpJump, code.OpJumpNotTruthy, code.OpJumpIfNil, code.OpTryFinally:
+			for _, t := range operands {
+				mark(t)
+			}
+		case code.OpTry:
+			if operands[0] != noCatchIP {
+				mark(operands[0])
+			}
+		case code.OpCompareJump:
+			mark(operands[1])
+		case code.OpJumpTable:
+			constants[operands[0]].(*object.JumpTable).Targets(func(t *int) { mark(*t) })
+		}
+	}
+	return targets
+}
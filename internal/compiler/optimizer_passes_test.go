@@ -12,7 +12,7 @@ func TestFoldConstantsBinaryInt(t *testing.T) {
 	ins := append(code.Make(code.OpConstant, 0), code.Make(code.OpConstant, 1)...)
 	ins = append(ins, code.Make(code.OpAdd)...)
 
-	out, _, err := foldConstants(ins, nil, &constants)
+	out, _, err := foldConstants(ins, nil, &constants, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -35,7 +35,7 @@ func TestFoldConstantsUnary(t *testing.T) {
 	constants := []object.Object{}
 	ins := append(code.Make(code.OpTrue), code.Make(code.OpBang)...)
 
-	out, _, err := foldConstants(ins, nil, &constants)
+	out, _, err := foldConstants(ins, nil, &constants, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -52,7 +52,7 @@ func TestFoldConstantsDivisionByZeroNotFolded(t *testing.T) {
 	ins := append(code.Make(code.OpConstant, 0), code.Make(code.OpConstant, 1)...)
 	ins = append(ins, code.Make(code.OpDiv)...)
 
-	out, _, err := foldConstants(ins, nil, &constants)
+	out, _, err := foldConstants(ins, nil, &constants, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -63,7 +63,7 @@ func TestFoldConstantsDivisionByZeroNotFolded(t *testing.T) {
 
 func TestPeepholeRemovesNullPop(t *testing.T) {
 	ins := append(code.Make(code.OpNull), code.Make(code.OpPop)...)
-	out, _ := peephole(ins, nil)
+	out, _ := peephole(ins, nil, nil)
 	if len(out) != 0 {
 		t.Fatalf("expected instructions to be removed, got %d bytes", len(out))
 	}
@@ -80,7 +80,7 @@ func TestRebuildRemapsJumpTargets(t *testing.T) {
 		return nil, 0, false, nil
 	}
 
-	out, _, changed, err := rebuild(ins, nil, rewrite)
+	out, _, changed, err := rebuild(ins, nil, nil, rewrite)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -98,3 +98,49 @@ func TestRebuildRemapsJumpTargets(t *testing.T) {
 		t.Fatalf("expected remapped jump target 3, got %d", operand)
 	}
 }
+
+func TestFuseSuperinstructions(t *testing.T) {
+	constants := []object.Object{&object.Integer{Value: 1}, &object.String{Value: "x"}}
+	ins := concat(
+		code.Make(code.OpGetLocal, 0),       // 0
+		code.Make(code.OpConstant, 0),       // 2
+		code.Make(code.OpLessThan),          // 5
+		code.Make(code.OpJumpNotTruthy, 20), // 6
+		code.Make(code.OpGetLocal, 0),       // 9
+		code.Make(code.OpConstant, 0),       // 11
+		code.Make(code.OpAdd),               // 14
+		code.Make(code.OpSetLocal, 0),       // 15
+		code.Make(code.OpJump, 0),           // 17
+		code.Make(code.OpGetLocal, 1),       // 20
+		code.Make(code.OpGetMember, 1),      // 22
+		code.Make(code.OpReturnValue),       // 25
+	)
+	out, _ := fuseSuperinstructions(ins, nil, constants)
+	want := concat(
+		code.Make(code.OpGetLocal, 0),                           // 0
+		code.Make(code.OpConstant, 0),                           // 2
+		code.Make(code.OpCompareJump, int(code.OpLessThan), 16), // 5
+		code.Make(code.OpIncLocal, 0, 0),                        // 9
+		code.Make(code.OpJump, 0),                               // 13
+		code.Make(code.OpGetLocalMember, 1, 1),                  // 16
+		code.Make(code.OpReturnValue),                           // 20
+	)
+	if out.String() != want.String() {
+		t.Fatalf("wrong fusion\ngot:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestFuseSkipsJumpTargets(t *testing.T) {
+	constants := []object.Object{&object.Integer{Value: 1}}
+	ins := concat(
+		code.Make(code.OpGetLocal, 0), // 0
+		code.Make(code.OpConstant, 0), // 2
+		code.Make(code.OpAdd),         // 5
+		code.Make(code.OpSetLocal, 0), // 6
+		code.Make(code.OpJump, 5),     // 8
+	)
+	out, _ := fuseSuperinstructions(ins, nil, constants)
+	if out.String() != ins.String() {
+		t.Fatalf("sequence with an inner jump target was fused:\n%s", out)
+	}
+}
@@ -1,8 +1,11 @@
 package compiler
 
-import "welle/internal/code"
+import (
+	"welle/internal/code"
+	"welle/internal/object"
+)
 
-func peephole(ins code.Instructions, pos []SourcePos) (code.Instructions, []SourcePos) {
+func peephole(ins code.Instructions, pos []SourcePos, constants []object.Object) (code.Instructions, []SourcePos) {
 	rewrite := func(at int, op code.Opcode, ins code.Instructions) (code.Instructions, int, bool, error) {
 		switch op {
 		case code.OpNull:
@@ -29,7 +32,7 @@ func peephole(ins code.Instructions, pos []SourcePos) (code.Instructions, []Sour
 	for {
 		var changed bool
 		var err error
-		ins, pos, changed, err = rebuild(ins, pos, rewrite)
+		ins, pos, changed, err = rebuild(ins, pos, constants, rewrite)
 		if err != nil || !changed {
 			break
 		}
@@ -1,10 +1,15 @@
 package compiler
 
-import "welle/internal/code"
+import (
+	"welle/internal/code"
+	"welle/internal/object"
+)
 
 type rewriteFunc func(at int, op code.Opcode, ins code.Instructions) (code.Instructions, int, bool, error)
 
-func rebuild(ins code.Instructions, pos []SourcePos, rewrite rewriteFunc) (code.Instructions, []SourcePos, bool, error) {
+// rebuild applies rewrite at each instruction and remaps jump targets,
+// including those held in jump-table constants, to the new offsets.
+func rebuild(ins code.Instructions, pos []SourcePos, constants []object.Object, rewrite rewriteFunc) (code.Instructions, []SourcePos, bool, error) {
 	oldToNew := make(map[int]int, len(ins))
 	newIns := make([]byte, 0, len(ins))
 	changed := false
@@ -34,7 +39,9 @@ func rebuild(ins code.Instructions, pos []SourcePos, rewrite rewriteFunc) (code.
 		i += size
 	}
 
-	remapJumps(newIns, oldToNew)
+	// Jumps may target the end of the stream.
+	oldToNew[len(ins)] = len(newIns)
+	remapJumps(newIns, oldToNew, constants)
 	newPos := remapPositions(pos, oldToNew)
 	return newIns, newPos, changed, nil
 }
@@ -58,7 +65,7 @@ func mapOffsets(oldToNew map[int]int, ins code.Instructions, start, size, newOff
 	}
 }
 
-func remapJumps(ins code.Instructions, oldToNew map[int]int) {
+func remapJumps(ins code.Instructions, oldToNew map[int]int, constants []object.Object) {
 	i := 0
 	for i < len(ins) {
 		op := code.Opcode(ins[i])
@@ -72,12 +79,29 @@ func remapJumps(ins code.Instructions, oldToNew map[int]int) {
 		size := 1 + read
 
 		switch op {
-		case code.OpJump, code.OpJumpNotTruthy:
-			oldTarget := operands[0]
-			if newTarget, ok := oldToNew[oldTarget]; ok {
-				fixed := code.Make(op, newTarget)
+		case code.OpJump, code.OpJumpNotTruthy, code.OpJumpIfNil, code.OpTry, code.OpTryFinally:
+			changed := false
+			for k, oldTarget := range operands {
+				if newTarget, ok := oldToNew[oldTarget]; ok {
+					operands[k] = newTarget
+					changed = true
+				}
+			}
+			if changed {
+				fixed := code.Make(op, operands...)
 				copy(ins[i:i+len(fixed)], fixed)
 			}
+		case code.OpJumpTable:
+			constants[operands[0]].(*object.JumpTable).Targets(func(target *int) {
+				if newTarget, ok := oldToNew[*target]; ok {
+					*target = newTarget
+				}
+			})
+		case code.OpCompareJump:
+			// Only the second operand is an address; the first is an opcode.
+			if newTarget, ok := oldToNew[operands[1]]; ok {
+				copy(ins[i:i+size], code.Make(op, operands[0], newTarget))
+			}
 		}
 
 		i += size
@@ -94,11 +118,8 @@ func remapPositions(pos []SourcePos, oldToNew map[int]int) []SourcePos {
 		if !ok {
 			continue
 		}
-		out = append(out, SourcePos{
-			Offset: newOffset,
-			Line:   p.Line,
-			Col:    p.Col,
-		})
+		p.Offset = newOffset
+		out = append(out, p)
 	}
 	return out
 }
@@ -0,0 +1,92 @@
+package compiler
+
+import (
+	"strings"
+
+	"welle/internal/ast"
+	"welle/internal/code"
+	"welle/internal/token"
+)
+
+// setOperandSpans records the range of n and of each of its operands for
+// the next instruction, so a runtime error there can point at the operand
+// at fault (the index that was out of range, the divisor that was zero)
+// instead of only at n's token. Operands are given in push order.
+func (c *Compiler) setOperandSpans(n ast.Node, operands ...ast.Node) {
+	c.curSpan = c.spanOf(n)
+	c.curOperands = nil
+	if len(operands) > 0 {
+		c.curOperands = make([]code.Span, len(operands))
+		for i, op := range operands {
+			c.curOperands[i] = c.spanOf(op)
+		}
+	}
+}
+
+// spanOf returns the range covered by the tokens of n and everything below
+// it. Results are memoized, so nested expressions are walked once.
+func (c *Compiler) spanOf(n ast.Node) code.Span {
+	if n == nil {
+		return code.Span{}
+	}
+	if s, ok := c.spans[n]; ok {
+		return s
+	}
+	s := c.spanOfAny(n)
+	if c.spans == nil {
+		c.spans = map[ast.Node]code.Span{}
+	}
+	c.spans[n] = s
+	return s
+}
+
+func (c *Compiler) spanOfAny(n any) code.Span {
+	var s code.Span
+	grow := func(o code.Span) {
+		if o.IsZero() {
+			return
+		}
+		if s.IsZero() || o.Line < s.Line || (o.Line == s.Line && o.Col < s.Col) {
+			s.Line, s.Col = o.Line, o.Col
+		}
+		if s.EndLine < o.EndLine || (s.EndLine == o.EndLine && s.EndCol < o.EndCol) {
+			s.EndLine, s.EndCol = o.EndLine, o.EndCol
+		}
+	}
+	for _, tok := range ast.Tokens(n) {
+		grow(tokenSpan(tok))
+	}
+	for _, child := range ast.Children(n) {
+		if node, ok := child.(ast.Node); ok {
+			grow(c.spanOf(node))
+		} else {
+			grow(c.spanOfAny(child))
+		}
+	}
+	return s
+}
+
+func tokenSpan(tok token.Token) code.Span {
+	lexeme := tok.Raw
+	if lexeme == "" {
+		lexeme = tok.Literal
+	}
+	if lexeme == "" {
+		lexeme = " "
+	}
+	if i := strings.LastIndexByte(lexeme, '\n'); i >= 0 {
+		return code.Span{Line: tok.Line, Col: tok.Col, EndLine: tok.Line + strings.Count(lexeme, "\n"), EndCol: len(lexeme) - i}
+	}
+	return code.Span{Line: tok.Line, Col: tok.Col, EndLine: tok.Line, EndCol: tok.Col + len(lexeme)}
+}
+
+// callOperands lists the callee (or method receiver) and the arguments of
+// a call, in push order.
+func callOperands(callee ast.Expression, args []ast.Expression) []ast.Node {
+	out := make([]ast.Node, 0, len(args)+1)
+	out = append(out, callee)
+	for _, a := range args {
+		out = append(out, a)
+	}
+	return out
+}
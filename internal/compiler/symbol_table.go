@@ -16,11 +16,17 @@ type SymbolTable struct {
 	Outer          *SymbolTable
 	store          map[string]Symbol
 	numDefinitions int
-	FreeSymbols    []Symbol
+	// names holds the name defined in each slot, "" for temporaries.
+	names       []string
+	FreeSymbols []Symbol
+	reads       map[Symbol]bool
+	// singleAssignment holds the function's locals that closures may
+	// capture by value; see singleAssignmentNames.
+	singleAssignment map[string]bool
 }
 
 func NewSymbolTable() *SymbolTable {
-	return &SymbolTable{store: map[string]Symbol{}}
+	return &SymbolTable{store: map[string]Symbol{}, reads: map[Symbol]bool{}}
 }
 
 func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
@@ -36,6 +42,7 @@ func (st *SymbolTable) Define(name string) Symbol {
 	}
 	sym := Symbol{Name: name, Scope: scope, Index: st.numDefinitions}
 	st.store[name] = sym
+	st.names = append(st.names, name)
 	st.numDefinitions++
 	return sym
 }
@@ -46,10 +53,17 @@ func (st *SymbolTable) DefineTemp(name string) Symbol {
 		scope = LocalScope
 	}
 	sym := Symbol{Name: name, Scope: scope, Index: st.numDefinitions}
+	st.names = append(st.names, "")
 	st.numDefinitions++
 	return sym
 }
 
+// SlotNames returns the name defined in each slot, in slot order, with ""
+// for compiler temporaries. A name defined twice appears at both slots.
+func (st *SymbolTable) SlotNames() []string {
+	return append([]string(nil), st.names...)
+}
+
 func (st *SymbolTable) defineFree(original Symbol) Symbol {
 	st.FreeSymbols = append(st.FreeSymbols, original)
 	sym := Symbol{Name: original.Name, Index: len(st.FreeSymbols) - 1, Scope: FreeScope}
@@ -94,3 +108,14 @@ func (st *SymbolTable) ResolveCurrent(name string) (Symbol, bool) {
 	}
 	return Symbol{}, false
 }
+
+// markRead records that sym was read, following free symbols back to the
+// table that defined them.
+func (st *SymbolTable) markRead(sym Symbol) {
+	t := st
+	for sym.Scope == FreeScope && t.Outer != nil {
+		sym = t.FreeSymbols[sym.Index]
+		t = t.Outer
+	}
+	t.reads[sym] = true
+}
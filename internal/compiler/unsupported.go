@@ -0,0 +1,96 @@
+package compiler
+
+import (
+	"welle/internal/ast"
+	"welle/internal/token"
+)
+
+// Unsupported is one construct in a module that the interpreter runs but
+// the bytecode compiler cannot compile.
+type Unsupported struct {
+	File string
+	Line int
+	Col  int
+	// Construct describes what was found, e.g. "forward reference to helper".
+	Construct string
+	// Message is the compile error the construct produces on its own.
+	Message string
+}
+
+// UnsupportedError is returned by Compile when the only problems in a
+// program are constructs that need the interpreter. It lists all of them,
+// so `welle run` can report every one when it falls back. Its message is
+// that of the first construct, the same error the compiler gave before it
+// collected the rest.
+type UnsupportedError struct {
+	Constructs []Unsupported
+}
+
+func (e *UnsupportedError) Error() string {
+	return e.Constructs[0].Message
+}
+
+// unsupported records a construct the VM cannot run and lets compilation
+// carry on, so the rest of the module is still checked.
+func (c *Compiler) unsupported(tok token.Token, construct, msg string) {
+	c.unsupportedList = append(c.unsupportedList, Unsupported{
+		File:      c.file,
+		Line:      tok.Line,
+		Col:       tok.Col,
+		Construct: construct,
+		Message:   msg,
+	})
+}
+
+// scopeNames are the names a function body binds at its top level, and the
+// compilation scope of that body.
+type scopeNames struct {
+	scope int
+	names map[string]bool
+}
+
+// forwardRef reports whether name, unknown where a function body reads it,
+// is a global the module defines further down, or a local an enclosing
+// function defines further down. The interpreter looks names up when the
+// function runs, so a function may call one defined after it, as two
+// local functions calling each other do; the compiler resolves names in
+// source order and cannot.
+func (c *Compiler) forwardRef(name string) bool {
+	if c.scopeIndex > 0 && c.laterGlobals[name] {
+		return true
+	}
+	for _, b := range c.bodyNames {
+		if c.scopeIndex > b.scope && b.names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// topLevelNames returns the names stmts bind at their own level.
+func topLevelNames(stmts []ast.Statement) map[string]bool {
+	names := map[string]bool{}
+	var add func(s ast.Statement)
+	add = func(s ast.Statement) {
+		switch s := s.(type) {
+		case *ast.FuncStatement:
+			names[s.Name.Value] = true
+		case *ast.ClassStatement:
+			names[s.Name.Value] = true
+		case *ast.AssignStatement:
+			names[s.Name.Value] = true
+		case *ast.ExpressionStatement:
+			if a, ok := s.Expression.(*ast.AssignExpression); ok {
+				if id, ok := a.Left.(*ast.Identifier); ok {
+					names[id.Value] = true
+				}
+			}
+		case *ast.ExportStatement:
+			add(s.Stmt)
+		}
+	}
+	for _, s := range stmts {
+		add(s)
+	}
+	return names
+}
@@ -0,0 +1,96 @@
+package compiler
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"welle/internal/lexer"
+	"welle/internal/parser"
+)
+
+func TestForwardReferencesAreUnsupported(t *testing.T) {
+	src := "func main() { return helper(1) + other }\n" +
+		"func helper(x) { return x }\n" +
+		"other = 2\n"
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	err := NewWithFile("main.wll").Compile(prog)
+	var unsupported *UnsupportedError
+	if !errors.As(err, &unsupported) {
+		t.Fatalf("expected *UnsupportedError, got %v", err)
+	}
+	if err.Error() != "unknown identifier: helper" {
+		t.Fatalf("message = %q", err.Error())
+	}
+	want := []Unsupported{
+		{File: "main.wll", Line: 1, Col: 22, Construct: "forward reference to helper", Message: "unknown identifier: helper"},
+		{File: "main.wll", Line: 1, Col: 34, Construct: "forward reference to other", Message: "unknown identifier: other"},
+	}
+	if !reflect.DeepEqual(unsupported.Constructs, want) {
+		t.Fatalf("constructs = %+v, want %+v", unsupported.Constructs, want)
+	}
+}
+
+func TestUnknownNameIsNotUnsupported(t *testing.T) {
+	for _, src := range []string{
+		"func main() { return missing }\n",
+		"print(later())\nfunc later() { return 1 }\n",
+	} {
+		p := parser.New(lexer.New(src))
+		prog := p.ParseProgram()
+		err := New().Compile(prog)
+		var unsupported *UnsupportedError
+		if err == nil || errors.As(err, &unsupported) {
+			t.Fatalf("%q: expected a plain compile error, got %v", src, err)
+		}
+	}
+}
+
+func TestLocalForwardReferencesAreUnsupported(t *testing.T) {
+	src := "func outer() {\n" +
+		"  func even(n) { if (n == 0) { return true }\n return odd(n - 1) }\n" +
+		"  func odd(n) { if (n == 0) { return false }\n return even(n - 1) }\n" +
+		"  return even(10)\n" +
+		"}\n"
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	err := NewWithFile("main.wll").Compile(prog)
+	var unsupported *UnsupportedError
+	if !errors.As(err, &unsupported) {
+		t.Fatalf("expected *UnsupportedError, got %v", err)
+	}
+	if len(unsupported.Constructs) != 1 || unsupported.Constructs[0].Construct != "forward reference to odd" {
+		t.Fatalf("constructs = %+v", unsupported.Constructs)
+	}
+}
+
+func TestJumpOutOfFinallyIsUnsupported(t *testing.T) {
+	src := "func f() {\n" +
+		"  try { return 1 } finally { return 2 }\n" +
+		"}\n" +
+		"while (true) { try { x = 1 } finally { break } }\n"
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	err := NewWithFile("main.wll").Compile(prog)
+	var unsupported *UnsupportedError
+	if !errors.As(err, &unsupported) {
+		t.Fatalf("expected *UnsupportedError, got %v", err)
+	}
+	want := []Unsupported{
+		{File: "main.wll", Line: 2, Col: 30, Construct: "return out of a finally block", Message: "return out of a finally block is not supported by the VM"},
+		{File: "main.wll", Line: 4, Col: 40, Construct: "break out of a finally block", Message: "break out of a finally block is not supported by the VM"},
+	}
+	if !reflect.DeepEqual(unsupported.Constructs, want) {
+		t.Fatalf("constructs = %+v, want %+v", unsupported.Constructs, want)
+	}
+}
@@ -0,0 +1,456 @@
+package compiler
+
+import (
+	"fmt"
+
+	"welle/internal/builtins"
+	"welle/internal/code"
+	"welle/internal/object"
+)
+
+// noCatchIP is the OpTry operand for a try statement without a catch block.
+const noCatchIP = 0xFFFF
+
+// Verify checks bytecode before the VM runs it: every instruction decodes,
+// jump, jump-table and handler targets land on instruction boundaries,
+// constant, local, free and builtin operands are in range and of the
+// expected type, and no path through a function pops more values than it
+// pushed. It returns the
+// first problem found, naming the function and instruction offset.
+//
+// Every path into an instruction must arrive with the same stack height; a
+// statement that leaves a value behind on one branch would otherwise grow
+// the stack on each loop iteration.
+func Verify(bc *Bytecode) error {
+	v := &verifier{constants: bc.Constants, numFree: map[int]int{}}
+	if err := v.collectClosures(bc.Instructions); err != nil {
+		return fmt.Errorf("<main>: %w", err)
+	}
+	for _, c := range bc.Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok {
+			if err := v.collectClosures(fn.Instructions); err != nil {
+				return fmt.Errorf("%s: %w", fn.Name, err)
+			}
+		}
+	}
+	if err := v.function(bc.Instructions, 0, -1, true); err != nil {
+		return fmt.Errorf("<main>: %w", err)
+	}
+	for i, c := range bc.Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok {
+			if err := v.function(fn.Instructions, fn.NumLocals, v.freeCount(i), false); err != nil {
+				return fmt.Errorf("%s: %w", fn.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// StackHeight returns the most value stack slots one call of a function in
+// bc needs at once, for its locals and operands, and that function's name.
+// bc must have passed Verify.
+func StackHeight(bc *Bytecode) (string, int) {
+	v := &verifier{constants: bc.Constants, numFree: map[int]int{}}
+	v.collectClosures(bc.Instructions)
+	for _, c := range bc.Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok {
+			v.collectClosures(fn.Instructions)
+		}
+	}
+	v.function(bc.Instructions, 0, -1, true)
+	name, most := "<main>", v.height
+	for i, c := range bc.Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok {
+			v.height = 0
+			v.function(fn.Instructions, fn.NumLocals, v.freeCount(i), false)
+			if n := fn.NumLocals + v.height; n > most {
+				name, most = fn.Name, n
+			}
+		}
+	}
+	return name, most
+}
+
+type verifier struct {
+	constants []object.Object
+	// numFree maps a function constant to the free-variable count its
+	// OpClosure sites capture.
+	numFree map[int]int
+	// height is the greatest stack height function has seen.
+	height int
+}
+
+func (v *verifier) freeCount(constIdx int) int {
+	if n, ok := v.numFree[constIdx]; ok {
+		return n
+	}
+	return -1
+}
+
+// decoded is one instruction with its operands.
+type decoded struct {
+	op       code.Opcode
+	operands []int
+	next     int
+}
+
+func decodeAt(ins code.Instructions, ip int) (decoded, error) {
+	op := code.Opcode(ins[ip])
+	def, ok := code.Lookup(op)
+	if !ok {
+		return decoded{}, fmt.Errorf("offset %d: unknown opcode %d", ip, op)
+	}
+	width := 0
+	for _, w := range def.OperandWidths {
+		width += w
+	}
+	if ip+1+width > len(ins) {
+		return decoded{}, fmt.Errorf("offset %d: %s: truncated operands", ip, def.Name)
+	}
+	operands, read := code.ReadOperands(def, ins[ip+1:])
+	return decoded{op: op, operands: operands, next: ip + 1 + read}, nil
+}
+
+// collectClosures records how many free variables each OpClosure captures
+// and checks that every site agrees.
+func (v *verifier) collectClosures(ins code.Instructions) error {
+	for ip := 0; ip < len(ins); {
+		d, err := decodeAt(ins, ip)
+		if err != nil {
+			return err
+		}
+		if d.op == code.OpClosure {
+			idx, n := d.operands[0], d.operands[1]
+			if prev, ok := v.numFree[idx]; ok && prev != n {
+				return fmt.Errorf("offset %d: OpClosure captures %d free variables for constant %d, elsewhere %d", ip, n, idx, prev)
+			}
+			v.numFree[idx] = n
+		}
+		ip = d.next
+	}
+	return nil
+}
+
+// stackEffect returns how many values an instruction pops and pushes on its
+// fall-through path.
+func stackEffect(d decoded) (pop, push int) {
+	switch d.op {
+	case code.OpConstant, code.OpTrue, code.OpFalse, code.OpNull,
+		code.OpGetGlobal, code.OpGetBuiltin, code.OpGetLocal, code.OpGetFree,
+		code.OpGetFreeCell, code.OpGetLocalCell, code.OpCurrentClosure,
+		code.OpImportModule, code.OpImportFrom, code.OpGetLocalMember:
+		return 0, 1
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+		code.OpBitOr, code.OpBitAnd, code.OpBitXor, code.OpShl, code.OpShr,
+		code.OpDictUpdate, code.OpEqual, code.OpNotEqual, code.OpIs,
+		code.OpGreaterThan, code.OpLessThan, code.OpLessEqual, code.OpGreaterEqual,
+		code.OpIn, code.OpIndex, code.OpArrayAppend, code.OpSetMember, code.OpMatchEqual:
+		return 2, 1
+	case code.OpCompareJump:
+		return 2, 0
+	case code.OpCatchMatch:
+		// The error under the filter stays for the catch block.
+		return 2, 2
+	case code.OpMinus, code.OpBang, code.OpBitNot, code.OpGetMember, code.OpSpread,
+		code.OpIterInit, code.OpIterInitComp, code.OpIterInitDict, code.OpMatchShape:
+		return 1, 1
+	case code.OpPop, code.OpSetGlobal, code.OpDefineGlobal, code.OpPrint,
+		code.OpSetLocal, code.OpDefineLocal, code.OpSetFree, code.OpExport,
+		code.OpJumpNotTruthy, code.OpJumpTable, code.OpReturnValue, code.OpThrow,
+		code.OpYield:
+		return 1, 0
+	case code.OpJumpIfNil:
+		return 1, 1
+	case code.OpEndFinally:
+		// The pending error, or nil, stays under the finally block.
+		return 0, 1
+	case code.OpRethrowPending:
+		return 1, 0
+	case code.OpIterNext:
+		return 1, 2
+	case code.OpIndexChain:
+		return d.operands[0] + 1, 1
+	case code.OpSetIndex:
+		return 3, 1
+	case code.OpSlice:
+		return 4, 1
+	case code.OpSetSlice:
+		return 5, 1
+	case code.OpDelIndex:
+		return 2, 0
+	case code.OpDelSlice:
+		return 4, 0
+	case code.OpArray, code.OpArraySpread, code.OpTuple, code.OpNamedTuple, code.OpSet, code.OpClass:
+		return d.operands[0], 1
+	case code.OpDict, code.OpDictSpread:
+		return 2 * d.operands[0], 1
+	case code.OpUnpackTuple, code.OpUnpackStar, code.OpUnpackNamed:
+		return 1, 1 + d.operands[0]
+	case code.OpClosure:
+		return d.operands[1], 1
+	case code.OpDefaults:
+		return d.operands[0] + 1, 1
+	case code.OpCall, code.OpCallSpread:
+		return d.operands[0] + 1, 1
+	case code.OpCallMethod, code.OpCallMethodSpread:
+		return d.operands[1] + 1, 1
+	case code.OpDefer, code.OpDeferSpread:
+		return d.operands[0] + 1, 0
+	}
+	return 0, 0
+}
+
+// checkOperands validates operands that index into tables.
+func (v *verifier) checkOperands(d decoded, ip, numLocals, numFree int) error {
+	def, _ := code.Lookup(d.op)
+	constant := func(idx int) (object.Object, error) {
+		if idx >= len(v.constants) {
+			return nil, fmt.Errorf("offset %d: %s: constant index %d out of range (%d constants)", ip, def.Name, idx, len(v.constants))
+		}
+		return v.constants[idx], nil
+	}
+	name := func(idx int) error {
+		c, err := constant(idx)
+		if err != nil {
+			return err
+		}
+		if _, ok := c.(*object.String); !ok {
+			return fmt.Errorf("offset %d: %s: constant %d is %s, not a name", ip, def.Name, idx, c.Type())
+		}
+		return nil
+	}
+	local := func(idx int) error {
+		if idx >= numLocals {
+			return fmt.Errorf("offset %d: %s: local %d out of range (%d locals)", ip, def.Name, idx, numLocals)
+		}
+		return nil
+	}
+
+	switch d.op {
+	case code.OpConstant:
+		_, err := constant(d.operands[0])
+		return err
+	case code.OpGetMember, code.OpSetMember, code.OpExport, code.OpImportModule,
+		code.OpCallMethod, code.OpCallMethodSpread:
+		return name(d.operands[0])
+	case code.OpImportFrom:
+		if err := name(d.operands[0]); err != nil {
+			return err
+		}
+		return name(d.operands[1])
+	case code.OpDefineGlobal:
+		return name(d.operands[1])
+	case code.OpGetLocal, code.OpSetLocal, code.OpGetLocalCell:
+		return local(d.operands[0])
+	case code.OpGetLocalMember:
+		if err := local(d.operands[0]); err != nil {
+			return err
+		}
+		return name(d.operands[1])
+	case code.OpIncLocal:
+		if err := local(d.operands[0]); err != nil {
+			return err
+		}
+		c, err := constant(d.operands[1])
+		if err != nil {
+			return err
+		}
+		if _, ok := c.(*object.Integer); !ok {
+			return fmt.Errorf("offset %d: OpIncLocal: constant %d is %s, not an integer", ip, d.operands[1], c.Type())
+		}
+	case code.OpCompareJump:
+		switch code.Opcode(d.operands[0]) {
+		case code.OpEqual, code.OpNotEqual, code.OpIs, code.OpGreaterThan,
+			code.OpLessThan, code.OpLessEqual, code.OpGreaterEqual:
+		default:
+			return fmt.Errorf("offset %d: OpCompareJump: opcode %d is not a comparison", ip, d.operands[0])
+		}
+	case code.OpDefineLocal:
+		if err := local(d.operands[0]); err != nil {
+			return err
+		}
+		return name(d.operands[1])
+	case code.OpGetFree, code.OpSetFree, code.OpGetFreeCell:
+		if numFree >= 0 && d.operands[0] >= numFree {
+			return fmt.Errorf("offset %d: %s: free variable %d out of range (%d captured)", ip, def.Name, d.operands[0], numFree)
+		}
+	case code.OpGetBuiltin:
+		if d.operands[0] >= builtins.Len() {
+			return fmt.Errorf("offset %d: OpGetBuiltin: builtin %d out of range (%d builtins)", ip, d.operands[0], builtins.Len())
+		}
+	case code.OpClosure:
+		c, err := constant(d.operands[0])
+		if err != nil {
+			return err
+		}
+		if _, ok := c.(*object.CompiledFunction); !ok {
+			return fmt.Errorf("offset %d: OpClosure: constant %d is %s, not a function", ip, d.operands[0], c.Type())
+		}
+	case code.OpJumpTable:
+		c, err := constant(d.operands[0])
+		if err != nil {
+			return err
+		}
+		if _, ok := c.(*object.JumpTable); !ok {
+			return fmt.Errorf("offset %d: OpJumpTable: constant %d is %s, not a jump table", ip, d.operands[0], c.Type())
+		}
+	case code.OpNamedTuple, code.OpUnpackNamed:
+		c, err := constant(d.operands[1])
+		if err != nil {
+			return err
+		}
+		shape, ok := c.(*object.TupleShape)
+		if !ok {
+			return fmt.Errorf("offset %d: %s: constant %d is %s, not a tuple shape", ip, def.Name, d.operands[1], c.Type())
+		}
+		if len(shape.Names) != d.operands[0] {
+			return fmt.Errorf("offset %d: %s: count %d does not match the shape's %d names", ip, def.Name, d.operands[0], len(shape.Names))
+		}
+	case code.OpClass:
+		c, err := constant(d.operands[1])
+		if err != nil {
+			return err
+		}
+		shape, ok := c.(*object.ClassShape)
+		if !ok {
+			return fmt.Errorf("offset %d: OpClass: constant %d is %s, not a class shape", ip, d.operands[1], c.Type())
+		}
+		if n := len(shape.Fields) + len(shape.Methods); n != d.operands[0] {
+			return fmt.Errorf("offset %d: OpClass: count %d does not match the shape's %d fields and methods", ip, d.operands[0], n)
+		}
+	}
+	return nil
+}
+
+// function verifies one instruction stream. numFree is -1 when the function
+// is never instantiated by an OpClosure in this bytecode. Only the main
+// program may run off the end of its instructions.
+func (v *verifier) function(ins code.Instructions, numLocals, numFree int, main bool) error {
+	boundary := make([]bool, len(ins)+1)
+	for ip := 0; ip < len(ins); {
+		d, err := decodeAt(ins, ip)
+		if err != nil {
+			return err
+		}
+		if err := v.checkOperands(d, ip, numLocals, numFree); err != nil {
+			return err
+		}
+		boundary[ip] = true
+		ip = d.next
+	}
+	boundary[len(ins)] = true
+
+	target := func(ip, to int, def *code.Definition) error {
+		if to > len(ins) || !boundary[to] {
+			return fmt.Errorf("offset %d: %s: target %d is not an instruction boundary", ip, def.Name, to)
+		}
+		if to == len(ins) && !main {
+			return fmt.Errorf("offset %d: %s: target %d jumps past the end of the function", ip, def.Name, to)
+		}
+		return nil
+	}
+
+	// depth[ip] is the stack height on entry to ip, or -1 if unreached.
+	depth := make([]int, len(ins)+1)
+	for i := range depth {
+		depth[i] = -1
+	}
+	var work []int
+	enter := func(from, ip, d int) error {
+		switch depth[ip] {
+		case -1:
+			depth[ip] = d
+			work = append(work, ip)
+		case d:
+		default:
+			return fmt.Errorf("offset %d: stack height %d from offset %d differs from %d on another path", ip, d, from, depth[ip])
+		}
+		return nil
+	}
+	enter(0, 0, 0)
+
+	for len(work) > 0 {
+		ip := work[len(work)-1]
+		work = work[:len(work)-1]
+		if ip == len(ins) {
+			if !main {
+				return fmt.Errorf("offset %d: execution runs past the end of the function", ip)
+			}
+			continue
+		}
+		d, _ := decodeAt(ins, ip)
+		def, _ := code.Lookup(d.op)
+		pop, push := stackEffect(d)
+		h := depth[ip]
+		if h < pop {
+			return fmt.Errorf("offset %d: %s: stack underflow (needs %d values, has %d)", ip, def.Name, pop, h)
+		}
+		h += push - pop
+		v.height = max(v.height, h)
+
+		switch d.op {
+		case code.OpJump:
+			if err := target(ip, d.operands[0], def); err != nil {
+				return err
+			}
+			if err := enter(ip, d.operands[0], h); err != nil {
+				return err
+			}
+			continue
+		case code.OpJumpNotTruthy, code.OpJumpIfNil:
+			if err := target(ip, d.operands[0], def); err != nil {
+				return err
+			}
+			if err := enter(ip, d.operands[0], h); err != nil {
+				return err
+			}
+		case code.OpJumpTable:
+			var err error
+			v.constants[d.operands[0]].(*object.JumpTable).Targets(func(to *int) {
+				if err == nil {
+					err = target(ip, *to, def)
+				}
+				if err == nil {
+					err = enter(ip, *to, h)
+				}
+			})
+			if err != nil {
+				return err
+			}
+		case code.OpCompareJump:
+			if err := target(ip, d.operands[1], def); err != nil {
+				return err
+			}
+			if err := enter(ip, d.operands[1], h); err != nil {
+				return err
+			}
+		case code.OpTry:
+			if d.operands[0] != noCatchIP {
+				if err := target(ip, d.operands[0], def); err != nil {
+					return err
+				}
+				// The handler starts with the error on top of the height
+				// recorded here.
+				if err := enter(ip, d.operands[0], h+1); err != nil {
+					return err
+				}
+			}
+		case code.OpTryFinally:
+			if err := target(ip, d.operands[0], def); err != nil {
+				return err
+			}
+			if err := target(ip, d.operands[1], def); err != nil {
+				return err
+			}
+			if err := enter(ip, d.operands[0], h); err != nil {
+				return err
+			}
+		case code.OpReturnValue, code.OpReturn, code.OpThrow:
+			continue
+		}
+		if err := enter(ip, d.next, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"welle/internal/code"
+	"welle/internal/lexer"
+	"welle/internal/object"
+	"welle/internal/parser"
+)
+
+func concat(parts ...code.Instructions) code.Instructions {
+	var out code.Instructions
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func TestVerifyRejectsBadBytecode(t *testing.T) {
+	fn := &object.CompiledFunction{
+		Name:         "f",
+		NumLocals:    1,
+		Instructions: concat(code.Make(code.OpGetLocal, 1), code.Make(code.OpReturnValue)),
+	}
+	tests := []struct {
+		name      string
+		ins       code.Instructions
+		constants []object.Object
+		want      string
+	}{
+		{
+			name: "jump into operand",
+			ins:  concat(code.Make(code.OpJump, 1), code.Make(code.OpNull)),
+			want: "<main>: offset 0: OpJump: target 1 is not an instruction boundary",
+		},
+		{
+			name: "jump out of range",
+			ins:  code.Make(code.OpJump, 99),
+			want: "target 99 is not an instruction boundary",
+		},
+		{
+			name: "constant out of range",
+			ins:  code.Make(code.OpConstant, 3),
+			want: "OpConstant: constant index 3 out of range (0 constants)",
+		},
+		{
+			name:      "member name not a string",
+			ins:       concat(code.Make(code.OpNull), code.Make(code.OpGetMember, 0)),
+			constants: []object.Object{&object.Integer{Value: 1}},
+			want:      "OpGetMember: constant 0 is INTEGER, not a name",
+		},
+		{
+			name: "stack underflow",
+			ins:  concat(code.Make(code.OpNull), code.Make(code.OpAdd)),
+			want: "offset 1: OpAdd: stack underflow (needs 2 values, has 1)",
+		},
+		{
+			name: "unbalanced branches",
+			ins: concat(
+				code.Make(code.OpTrue),             // 0
+				code.Make(code.OpJumpNotTruthy, 8), // 1
+				code.Make(code.OpNull),             // 4
+				code.Make(code.OpJump, 8),          // 5
+				code.Make(code.OpPop),              // 8
+			),
+			want: "offset 8: stack height 1 from offset 5 differs from 0 on another path",
+		},
+		{
+			name: "unknown opcode",
+			ins:  code.Instructions{250},
+			want: "offset 0: unknown opcode 250",
+		},
+		{
+			name: "truncated operands",
+			ins:  code.Make(code.OpConstant, 0)[:2],
+			want: "offset 0: OpConstant: truncated operands",
+		},
+		{
+			name:      "local out of range in function",
+			ins:       concat(code.Make(code.OpClosure, 0, 0), code.Make(code.OpPop)),
+			constants: []object.Object{fn},
+			want:      "f: offset 0: OpGetLocal: local 1 out of range (1 locals)",
+		},
+		{
+			name: "compare jump with arithmetic opcode",
+			ins:  concat(code.Make(code.OpNull), code.Make(code.OpNull), code.Make(code.OpCompareJump, int(code.OpAdd), 0)),
+			want: "OpCompareJump: opcode 1 is not a comparison",
+		},
+		{
+			name:      "jump table operand not a table",
+			ins:       concat(code.Make(code.OpNull), code.Make(code.OpJumpTable, 0)),
+			constants: []object.Object{&object.Integer{Value: 1}},
+			want:      "OpJumpTable: constant 0 is INTEGER, not a jump table",
+		},
+		{
+			name: "builtin out of range",
+			ins:  code.Make(code.OpGetBuiltin, 255),
+			want: "OpGetBuiltin: builtin 255 out of range",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(&Bytecode{Instructions: tt.ins, Constants: tt.constants})
+			if err == nil {
+				t.Fatalf("expected verification error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyFunctionMustReturn(t *testing.T) {
+	fn := &object.CompiledFunction{Name: "f", Instructions: code.Make(code.OpNull)}
+	bc := &Bytecode{
+		Instructions: concat(code.Make(code.OpClosure, 0, 0), code.Make(code.OpPop)),
+		Constants:    []object.Object{fn},
+	}
+	err := Verify(bc)
+	if err == nil || !strings.Contains(err.Error(), "f: offset 1: execution runs past the end of the function") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Every program in the repository must verify, with and without the
+// optimizer, since the loader refuses bytecode that does not.
+func TestVerifyAcceptsCompiledPrograms(t *testing.T) {
+	root := filepath.Join("..", "..")
+	var files []string
+	for _, dir := range []string{"examples", "std", "tests"} {
+		filepath.Walk(filepath.Join(root, dir), func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && strings.HasSuffix(path, ".wll") {
+				files = append(files, path)
+			}
+			return nil
+		})
+	}
+	if len(files) == 0 {
+		t.Fatal("no programs found")
+	}
+	for _, path := range files {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, optimize := range []bool{false, true} {
+			p := parser.New(lexer.New(string(src)))
+			prog := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				break
+			}
+			c := NewWithFile(path)
+			if err := c.Compile(prog); err != nil {
+				break
+			}
+			bc := c.Bytecode()
+			if optimize {
+				if bc, err = (&Optimizer{}).Optimize(bc); err != nil {
+					t.Fatalf("%s: optimize: %v", path, err)
+				}
+			}
+			if err := Verify(bc); err != nil {
+				t.Errorf("%s (optimize=%v): %v", path, optimize, err)
+			}
+		}
+	}
+}
+
+func TestOptimizerRemapsHandlerTargets(t *testing.T) {
+	src := `x = 1 + 2
+try { throw "boom" } catch (e) { print(e) }
+y = nil ?? 1 + 1
+if (true) { print(1) } else { print(2) }`
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	c := New()
+	if err := c.Compile(prog); err != nil {
+		t.Fatal(err)
+	}
+	bc, err := (&Optimizer{}).Optimize(c.Bytecode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(bc); err != nil {
+		t.Fatalf("optimized bytecode does not verify: %v\n%s", err, bc.Instructions)
+	}
+}
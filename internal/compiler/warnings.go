@@ -0,0 +1,123 @@
+package compiler
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"welle/internal/builtins"
+	"welle/internal/code"
+	"welle/internal/diag"
+	"welle/internal/token"
+)
+
+// localDef records where a function-local name was first defined so it can
+// be reported if nothing reads it before the function is finished.
+type localDef struct {
+	sym Symbol
+	tok token.Token
+}
+
+// Warnings returns non-fatal diagnostics found while compiling, such as
+// reads of locals that may not be assigned yet. They never stop
+// compilation; callers decide whether to print them or treat them as errors.
+func (c *Compiler) Warnings() []diag.Diagnostic {
+	sort.SliceStable(c.warnings, func(i, j int) bool {
+		a, b := c.warnings[i].Range, c.warnings[j].Range
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	return c.warnings
+}
+
+func (c *Compiler) warn(tok token.Token, code, msg string) {
+	length := len([]rune(tok.Literal))
+	if length == 0 {
+		length = 1
+	}
+	c.warnings = append(c.warnings, diag.Diagnostic{
+		Code:     code,
+		Message:  msg,
+		Severity: diag.SeverityWarning,
+		Range:    diag.Range{Line: tok.Line, Col: tok.Col, Length: length},
+	})
+}
+
+// define adds name to the current symbol table and remembers tok for the
+// unused-local and builtin-shadowing warnings.
+func (c *Compiler) define(name string, tok token.Token) Symbol {
+	sym := c.symbols.Define(name)
+	c.checkBuiltinShadow(name, tok)
+	if sym.Scope == LocalScope && name != "_" && name[0] != '_' {
+		scope := &c.scopes[c.scopeIndex]
+		scope.locals = append(scope.locals, localDef{sym: sym, tok: tok})
+	}
+	return sym
+}
+
+func (c *Compiler) checkBuiltinShadow(name string, tok token.Token) {
+	if _, ok := builtins.Index(name); ok {
+		c.warn(tok, "WC0003", fmt.Sprintf("'%s' shadows the builtin of the same name", name))
+	}
+}
+
+func (c *Compiler) reportUnusedLocals() {
+	for _, def := range c.scopes[c.scopeIndex].locals {
+		if !c.symbols.reads[def.sym] {
+			c.warn(def.tok, "WC0001", fmt.Sprintf("local '%s' is assigned but never read", def.sym.Name))
+		}
+	}
+}
+
+// posAt returns the source position recorded for the instruction at offset.
+func posAt(pos []SourcePos, offset int) (SourcePos, bool) {
+	var best SourcePos
+	found := false
+	for _, p := range pos {
+		if p.Offset > offset {
+			break
+		}
+		best = p
+		found = true
+	}
+	return best, found
+}
+
+func overflowWarning(p SourcePos, operator string, result int64) diag.Diagnostic {
+	return diag.Diagnostic{
+		Code:     "WC0002",
+		Message:  fmt.Sprintf("integer overflow in constant expression: '%s' wraps to %d", operator, result),
+		Severity: diag.SeverityWarning,
+		Range:    diag.Range{Line: p.Line, Col: p.Col, Length: 1},
+	}
+}
+
+// intOverflow reports whether applying op to a and b wraps around int64,
+// along with the operator as written in source and the wrapped result.
+func intOverflow(op code.Opcode, a, b int64) (string, int64, bool) {
+	switch op {
+	case code.OpAdd:
+		r := a + b
+		return "+", r, (a > 0 && b > 0 && r < 0) || (a < 0 && b < 0 && r >= 0)
+	case code.OpSub:
+		r := a - b
+		return "-", r, (a >= 0 && b < 0 && r < 0) || (a < 0 && b > 0 && r >= 0)
+	case code.OpMul:
+		r := a * b
+		if a == 0 || b == 0 {
+			return "*", r, false
+		}
+		return "*", r, r/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64)
+	case code.OpShl:
+		if b < 0 || b >= 64 {
+			return "<<", 0, false
+		}
+		r := int64(uint64(a) << uint64(b))
+		return "<<", r, r>>uint64(b) != a
+	case code.OpMinus:
+		return "-", -a, a == math.MinInt64
+	}
+	return "", 0, false
+}
@@ -0,0 +1,93 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"welle/internal/code"
+	"welle/internal/lexer"
+	"welle/internal/parser"
+)
+
+func compileWarnings(t *testing.T, src string, optimize bool) []string {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	c := New()
+	if err := c.Compile(prog); err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	var out []string
+	for _, w := range c.Warnings() {
+		out = append(out, w.Format("t.wll"))
+	}
+	if optimize {
+		opt := &Optimizer{}
+		if _, err := opt.Optimize(c.Bytecode()); err != nil {
+			t.Fatalf("optimize error: %v", err)
+		}
+		for _, w := range opt.Warnings {
+			out = append(out, w.Format("t.wll"))
+		}
+	}
+	return out
+}
+
+func TestCompilerWarnings(t *testing.T) {
+	src := `func f(a) {
+  tmp = a * 2
+  _skip = 1
+  used = a
+  return used
+}
+func g(str) {
+  keep = 1
+  inner = func() { return keep }
+  return inner
+}
+big = 9223372036854775807 + 1
+print(f(1), g(2), big)
+`
+	got := compileWarnings(t, src, true)
+	want := []string{
+		"t.wll:2:3: warning WC0001: local 'tmp' is assigned but never read",
+		"t.wll:7:8: warning WC0003: 'str' shadows the builtin of the same name",
+		"t.wll:12:27: warning WC0002: integer overflow in constant expression: '+' wraps to -9223372036854775808",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected warnings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCompilerWarningsDoNotFail(t *testing.T) {
+	got := compileWarnings(t, "len = 3\nprint(len)\n", false)
+	if len(got) != 1 || !strings.Contains(got[0], "WC0003") {
+		t.Fatalf("expected one WC0003 warning, got %v", got)
+	}
+}
+
+func TestIntOverflow(t *testing.T) {
+	tests := []struct {
+		op       string
+		a, b     int64
+		overflow bool
+	}{
+		{"+", 1, 2, false},
+		{"+", 9223372036854775807, 1, true},
+		{"-", -9223372036854775808, 1, true},
+		{"*", 4611686018427387904, 2, true},
+		{"*", -1, -9223372036854775808, true},
+		{"*", 3, -7, false},
+		{"<<", 1, 62, false},
+		{"<<", 1, 63, true},
+	}
+	ops := map[string]code.Opcode{"+": code.OpAdd, "-": code.OpSub, "*": code.OpMul, "<<": code.OpShl}
+	for _, tt := range tests {
+		if _, _, got := intOverflow(ops[tt.op], tt.a, tt.b); got != tt.overflow {
+			t.Fatalf("%d %s %d: expected overflow=%t", tt.a, tt.op, tt.b, tt.overflow)
+		}
+	}
+}
@@ -12,11 +12,52 @@ import (
 type Manifest struct {
 	Name         string
 	Entry        string
+	Std          string // path or installed version; takes precedence over StdRoot
 	StdRoot      string
 	ModulePaths  []string
+	Prelude      string // module whose exports every project module gets; relative to the project root
 	MaxRecursion int
 	MaxSteps     int64
 	MaxMem       int64
+	MaxStack     int
+	MaxFrames    int
+	// MaxParseDepth and MaxSourceSize override the parser's limits; zero
+	// keeps its defaults.
+	MaxParseDepth int
+	MaxSourceSize int
+	Release       bool
+	Features      []string // build features BUILD.<name> reports as enabled
+	Edition       string   // language edition of project modules without a #welle pragma
+	Lint          LintConfig
+	Editor        EditorConfig
+	// ModuleLimits holds the `[limits."path"]` sections, in file order.
+	ModuleLimits []ModuleLimit
+}
+
+// ModuleLimit is one `[limits."path"]` section of welle.toml: stricter
+// limits for the module at Path, or every module under it when Path is a
+// directory. Path is relative to the project root unless absolute; zero
+// leaves the run's own limit in place.
+type ModuleLimit struct {
+	Path     string
+	MaxSteps int64
+	MaxMem   int64
+}
+
+// LintConfig holds the optional `[lint]` section of welle.toml.
+// Zero thresholds leave the corresponding rule disabled.
+type LintConfig struct {
+	MaxComplexity int
+	MaxNesting    int
+	MaxStatements int
+}
+
+// EditorConfig holds the optional `[editor]` section of welle.toml: what
+// welle-lsp does to a document when the editor saves it.
+type EditorConfig struct {
+	FormatOnSave          bool
+	FixOnSave             bool
+	OrganizeImportsOnSave bool
 }
 
 func LoadManifest(path string) (*Manifest, error) {
@@ -29,12 +70,30 @@ func LoadManifest(path string) (*Manifest, error) {
 	m := &Manifest{}
 	sc := bufio.NewScanner(f)
 	lineNo := 0
+	section := ""
 	for sc.Scan() {
 		lineNo++
 		s := strings.TrimSpace(sc.Text())
 		if s == "" || strings.HasPrefix(s, "#") {
 			continue
 		}
+		if strings.HasPrefix(s, "[") {
+			if !strings.HasSuffix(s, "]") {
+				return nil, fmt.Errorf("%s:%d: invalid section header", path, lineNo)
+			}
+			section = strings.TrimSpace(s[1 : len(s)-1])
+			if name, ok := strings.CutPrefix(section, "limits."); ok {
+				modPath, err := parseString(path, lineNo, strings.TrimSpace(name))
+				if err != nil {
+					return nil, err
+				}
+				if modPath == "" {
+					return nil, fmt.Errorf("%s:%d: limits section needs a module path", path, lineNo)
+				}
+				m.ModuleLimits = append(m.ModuleLimits, ModuleLimit{Path: modPath})
+			}
+			continue
+		}
 
 		parts := strings.SplitN(s, "=", 2)
 		if len(parts) != 2 {
@@ -43,6 +102,28 @@ func LoadManifest(path string) (*Manifest, error) {
 		key := strings.TrimSpace(parts[0])
 		val := strings.TrimSpace(parts[1])
 
+		if section == "lint" {
+			if err := parseLintKey(&m.Lint, path, lineNo, key, val); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if strings.HasPrefix(section, "limits.") {
+			if err := parseModuleLimitKey(&m.ModuleLimits[len(m.ModuleLimits)-1], path, lineNo, key, val); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if section == "editor" {
+			if err := parseEditorKey(&m.Editor, path, lineNo, key, val); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if section != "" {
+			continue
+		}
+
 		switch key {
 		case "name":
 			str, err := parseString(path, lineNo, val)
@@ -56,12 +137,24 @@ func LoadManifest(path string) (*Manifest, error) {
 				return nil, err
 			}
 			m.Entry = str
+		case "std":
+			str, err := parseString(path, lineNo, val)
+			if err != nil {
+				return nil, err
+			}
+			m.Std = str
 		case "std_root":
 			str, err := parseString(path, lineNo, val)
 			if err != nil {
 				return nil, err
 			}
 			m.StdRoot = str
+		case "prelude":
+			str, err := parseString(path, lineNo, val)
+			if err != nil {
+				return nil, err
+			}
+			m.Prelude = str
 		case "module_paths":
 			list, err := parseStringList(path, lineNo, val)
 			if err != nil {
@@ -98,6 +191,48 @@ func LoadManifest(path string) (*Manifest, error) {
 				return nil, fmt.Errorf("%s:%d: max_mem must be >= 0", path, lineNo)
 			}
 			m.MaxMem = n
+		case "max_stack", "max_frames", "max_parse_depth", "max_source_size":
+			n, err := parseInt(path, lineNo, val)
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 {
+				return nil, fmt.Errorf("%s:%d: %s must be >= 0", path, lineNo, key)
+			}
+			if n > int64(^uint(0)>>1) {
+				return nil, fmt.Errorf("%s:%d: %s too large", path, lineNo, key)
+			}
+			switch key {
+			case "max_stack":
+				m.MaxStack = int(n)
+			case "max_frames":
+				m.MaxFrames = int(n)
+			case "max_parse_depth":
+				m.MaxParseDepth = int(n)
+			default:
+				m.MaxSourceSize = int(n)
+			}
+		case "edition":
+			str, err := parseString(path, lineNo, val)
+			if err != nil {
+				return nil, err
+			}
+			m.Edition = str
+		case "features":
+			list, err := parseStringList(path, lineNo, val)
+			if err != nil {
+				return nil, err
+			}
+			m.Features = list
+		case "release":
+			switch val {
+			case "true":
+				m.Release = true
+			case "false":
+				m.Release = false
+			default:
+				return nil, fmt.Errorf("%s:%d: release must be true or false", path, lineNo)
+			}
 		default:
 		}
 	}
@@ -107,6 +242,76 @@ func LoadManifest(path string) (*Manifest, error) {
 	return m, nil
 }
 
+func parseLintKey(c *LintConfig, path string, lineNo int, key, val string) error {
+	var dst *int
+	switch key {
+	case "max_complexity":
+		dst = &c.MaxComplexity
+	case "max_nesting":
+		dst = &c.MaxNesting
+	case "max_statements":
+		dst = &c.MaxStatements
+	default:
+		return nil
+	}
+	n, err := parseInt(path, lineNo, val)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("%s:%d: %s must be >= 0", path, lineNo, key)
+	}
+	if n > int64(^uint(0)>>1) {
+		return fmt.Errorf("%s:%d: %s too large", path, lineNo, key)
+	}
+	*dst = int(n)
+	return nil
+}
+
+func parseModuleLimitKey(c *ModuleLimit, path string, lineNo int, key, val string) error {
+	var dst *int64
+	switch key {
+	case "max_steps":
+		dst = &c.MaxSteps
+	case "max_mem":
+		dst = &c.MaxMem
+	default:
+		return nil
+	}
+	n, err := parseInt(path, lineNo, val)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("%s:%d: %s must be >= 0", path, lineNo, key)
+	}
+	*dst = n
+	return nil
+}
+
+func parseEditorKey(c *EditorConfig, path string, lineNo int, key, val string) error {
+	var dst *bool
+	switch key {
+	case "format_on_save":
+		dst = &c.FormatOnSave
+	case "fix_on_save":
+		dst = &c.FixOnSave
+	case "organize_imports_on_save":
+		dst = &c.OrganizeImportsOnSave
+	default:
+		return nil
+	}
+	switch val {
+	case "true":
+		*dst = true
+	case "false":
+		*dst = false
+	default:
+		return fmt.Errorf("%s:%d: %s must be true or false", path, lineNo, key)
+	}
+	return nil
+}
+
 func (m *Manifest) ResolvePaths(projectRoot, defaultStdRoot string) (string, []string, error) {
 	stdRoot := defaultStdRoot
 	if m != nil && strings.TrimSpace(m.StdRoot) != "" {
@@ -0,0 +1,29 @@
+// Package dataformat reads and writes the config formats exposed by
+// std:toml, std:yaml and std:ini, and the text encodings of std:encoding.
+// Decoders build welle objects directly: tables and mappings become DICTs
+// with STRING keys, sequences become ARRAYs.
+package dataformat
+
+import (
+	"fmt"
+
+	"welle/internal/object"
+)
+
+func newDict() *object.Dict {
+	return &object.Dict{Pairs: map[object.HashKey]object.DictPair{}}
+}
+
+func lookup(d *object.Dict, key string) (object.Object, bool) {
+	pair, ok := d.Pairs[object.StringKey(key)]
+	return pair.Value, ok
+}
+
+func store(d *object.Dict, key string, val object.Object) {
+	d.Pairs[object.StringKey(key)] = object.DictPair{Key: &object.String{Value: key}, Value: val}
+}
+
+// lineError is a decode error tied to a 1-based source line.
+func lineError(format string, line int, msg string, args ...any) error {
+	return fmt.Errorf("%s: line %d: %s", format, line, fmt.Sprintf(msg, args...))
+}
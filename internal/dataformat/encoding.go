@@ -0,0 +1,143 @@
+package dataformat
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"welle/internal/object"
+)
+
+// Encodings lists the text encodings Encode and Decode accept.
+var Encodings = []string{"base64", "hex", "url"}
+
+// Encode encodes text as kind says: standard padded base64, lowercase hex,
+// or URL query escaping (a space becomes "+").
+func Encode(text, kind string) (string, error) {
+	switch kind {
+	case "base64":
+		return base64.StdEncoding.EncodeToString([]byte(text)), nil
+	case "hex":
+		return hex.EncodeToString([]byte(text)), nil
+	case "url":
+		return url.QueryEscape(text), nil
+	}
+	return "", unknownEncoding(kind)
+}
+
+// Decode reverses Encode. Base64 may leave out its padding, hex may use
+// either case, and URL decoding turns "+" back into a space.
+func Decode(text, kind string) (string, error) {
+	switch kind {
+	case "base64":
+		enc := base64.StdEncoding
+		if !strings.HasSuffix(text, "=") && len(text)%4 != 0 {
+			enc = base64.RawStdEncoding
+		}
+		out, err := enc.DecodeString(text)
+		var corrupt base64.CorruptInputError
+		if errors.As(err, &corrupt) {
+			return "", fmt.Errorf("base64: invalid input at byte %d", int64(corrupt))
+		}
+		return string(out), err
+	case "hex":
+		out, err := hex.DecodeString(text)
+		var invalid hex.InvalidByteError
+		switch {
+		case errors.As(err, &invalid):
+			return "", fmt.Errorf("hex: invalid byte %q", rune(invalid))
+		case err != nil:
+			return "", fmt.Errorf("hex: odd length %d", len(text))
+		}
+		return string(out), nil
+	case "url":
+		return unescapeQuery(text)
+	}
+	return "", unknownEncoding(kind)
+}
+
+func unknownEncoding(kind string) error {
+	return fmt.Errorf("unknown encoding %q (want %s)", kind, strings.Join(Encodings, ", "))
+}
+
+func unescapeQuery(s string) (string, error) {
+	out, err := url.QueryUnescape(s)
+	var bad url.EscapeError
+	if errors.As(err, &bad) {
+		return "", fmt.Errorf("url: invalid escape %q", string(bad))
+	}
+	return out, err
+}
+
+// ParseQuery decodes a URL query string such as "a=1&b=x+y" into a dict of
+// strings. A key given more than once maps to an array of its values in
+// order, and a key without "=" maps to "". A leading "?" is ignored.
+func ParseQuery(text string) (*object.Dict, error) {
+	d := newDict()
+	for _, part := range strings.Split(strings.TrimPrefix(text, "?"), "&") {
+		if part == "" {
+			continue
+		}
+		rawKey, rawVal, _ := strings.Cut(part, "=")
+		key, err := unescapeQuery(rawKey)
+		if err != nil {
+			return nil, err
+		}
+		val, err := unescapeQuery(rawVal)
+		if err != nil {
+			return nil, err
+		}
+		str := &object.String{Value: val}
+		prev, _ := lookup(d, key)
+		switch prev := prev.(type) {
+		case nil:
+			store(d, key, str)
+		case *object.Array:
+			prev.Elements = append(prev.Elements, str)
+		default:
+			store(d, key, &object.Array{Elements: []object.Object{prev, str}})
+		}
+	}
+	return d, nil
+}
+
+// StringifyQuery encodes d as a URL query string, keys in dict order. Keys
+// must be strings; an array value repeats its key once per element, and a
+// nil value leaves its key out.
+func StringifyQuery(d *object.Dict) (string, error) {
+	var parts []string
+	for _, pair := range object.SortedDictPairs(d) {
+		key, ok := pair.Key.(*object.String)
+		if !ok {
+			return "", fmt.Errorf("query: keys must be STRING, got %s", pair.Key.Type())
+		}
+		vals := []object.Object{pair.Value}
+		if arr, ok := pair.Value.(*object.Array); ok {
+			vals = arr.Elements
+		}
+		for _, v := range vals {
+			text, ok := queryText(v)
+			if !ok {
+				return "", fmt.Errorf("query: value for %q must be a string, number, bool or array of them, got %s", key.Value, v.Type())
+			}
+			if _, isNil := v.(*object.Nil); isNil {
+				continue
+			}
+			parts = append(parts, url.QueryEscape(key.Value)+"="+url.QueryEscape(text))
+		}
+	}
+	return strings.Join(parts, "&"), nil
+}
+
+func queryText(v object.Object) (string, bool) {
+	switch v := v.(type) {
+	case *object.String:
+		return v.Value, true
+	case *object.Integer, *object.Float, *object.Boolean, *object.Nil:
+		return v.Inspect(), true
+	}
+	return "", false
+}
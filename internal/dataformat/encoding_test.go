@@ -0,0 +1,75 @@
+package dataformat
+
+import (
+	"strings"
+	"testing"
+
+	"welle/internal/object"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		kind, text, encoded string
+	}{
+		{"base64", "hello, world", "aGVsbG8sIHdvcmxk"},
+		{"base64", "hi", "aGk="},
+		{"hex", "Hi!\x00", "48692100"},
+		{"url", "a b&c=d/é", "a+b%26c%3Dd%2F%C3%A9"},
+	}
+	for _, tt := range tests {
+		got, err := Encode(tt.text, tt.kind)
+		if err != nil || got != tt.encoded {
+			t.Fatalf("Encode(%q, %s) = %q, %v; want %q", tt.text, tt.kind, got, err, tt.encoded)
+		}
+		back, err := Decode(got, tt.kind)
+		if err != nil || back != tt.text {
+			t.Fatalf("Decode(%q, %s) = %q, %v; want %q", got, tt.kind, back, err, tt.text)
+		}
+	}
+	if got, err := Decode("aGk", "base64"); err != nil || got != "hi" {
+		t.Fatalf("unpadded base64 = %q, %v", got, err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		kind, text, want string
+	}{
+		{"base64", "a$b=", "base64: invalid input at byte 1"},
+		{"hex", "abc", "hex: odd length 3"},
+		{"hex", "zz", "hex: invalid byte 'z'"},
+		{"url", "%zz", `url: invalid escape "%zz"`},
+		{"rot13", "x", `unknown encoding "rot13"`},
+	}
+	for _, tt := range tests {
+		_, err := Decode(tt.text, tt.kind)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Decode(%q, %s): expected error containing %q, got %v", tt.text, tt.kind, tt.want, err)
+		}
+	}
+}
+
+func TestQueryParseAndStringify(t *testing.T) {
+	d, err := ParseQuery("?tag=a&tag=b&name=J%C3%B6+D&&flag")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := d.Inspect(), `#{"flag": , "name": Jö D, "tag": [a, b]}`; got != want {
+		t.Fatalf("unexpected result:\n got %s\nwant %s", got, want)
+	}
+	out, err := StringifyQuery(d)
+	if err != nil || out != "flag=&name=J%C3%B6+D&tag=a&tag=b" {
+		t.Fatalf("StringifyQuery = %q, %v", out, err)
+	}
+
+	bad := newDict()
+	store(bad, "a", newDict())
+	if _, err := StringifyQuery(bad); err == nil || !strings.Contains(err.Error(), `value for "a"`) {
+		t.Fatalf("expected a value error, got %v", err)
+	}
+	skipped := newDict()
+	store(skipped, "a", &object.Nil{})
+	if out, err := StringifyQuery(skipped); err != nil || out != "" {
+		t.Fatalf("nil value = %q, %v; want it left out", out, err)
+	}
+}
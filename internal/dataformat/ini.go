@@ -0,0 +1,82 @@
+package dataformat
+
+import (
+	"strings"
+
+	"welle/internal/object"
+)
+
+// ParseINI reads an INI file. Keys before the first [section] land at the
+// top level; each section becomes a nested DICT, and a repeated section
+// header adds to the same one. Values are always STRINGs: surrounding
+// quotes are removed, and an unquoted value ends at a " ;" or " #"
+// comment. Lines starting with ; or # are comments.
+func ParseINI(src string) (*object.Dict, error) {
+	root := newDict()
+	cur := root
+	sections := map[string]bool{}
+	for i, raw := range strings.Split(src, "\n") {
+		line := i + 1
+		s := strings.TrimSpace(raw)
+		if i == 0 {
+			s = strings.TrimPrefix(s, "\ufeff")
+		}
+		if s == "" || s[0] == ';' || s[0] == '#' {
+			continue
+		}
+		if s[0] == '[' {
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, lineError("ini", line, "unterminated section header")
+			}
+			if after := strings.TrimSpace(s[end+1:]); after != "" && after[0] != ';' && after[0] != '#' {
+				return nil, lineError("ini", line, "unexpected %q after section header", after)
+			}
+			name := strings.TrimSpace(s[1:end])
+			if name == "" {
+				return nil, lineError("ini", line, "empty section name")
+			}
+			if v, ok := lookup(root, name); ok {
+				if !sections[name] {
+					return nil, lineError("ini", line, "section [%s] clashes with a key of the same name", name)
+				}
+				cur = v.(*object.Dict)
+				continue
+			}
+			cur = newDict()
+			store(root, name, cur)
+			sections[name] = true
+			continue
+		}
+		eq := strings.IndexAny(s, "=:")
+		if eq < 0 {
+			return nil, lineError("ini", line, "expected key = value")
+		}
+		key := strings.TrimSpace(s[:eq])
+		if key == "" {
+			return nil, lineError("ini", line, "missing key before %q", s[eq:eq+1])
+		}
+		if cur == root && sections[key] {
+			return nil, lineError("ini", line, "key %s clashes with section [%s]", key, key)
+		}
+		store(cur, key, &object.String{Value: iniValue(strings.TrimSpace(s[eq+1:]))})
+	}
+	return root, nil
+}
+
+func iniValue(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') {
+		if end := strings.IndexByte(v[1:], v[0]); end >= 0 {
+			after := strings.TrimSpace(v[end+2:])
+			if after == "" || after[0] == ';' || after[0] == '#' {
+				return v[1 : end+1]
+			}
+		}
+	}
+	for i := 1; i < len(v); i++ {
+		if (v[i] == ';' || v[i] == '#') && (v[i-1] == ' ' || v[i-1] == '\t') {
+			return strings.TrimSpace(v[:i])
+		}
+	}
+	return v
+}
@@ -0,0 +1,37 @@
+package dataformat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseINI(t *testing.T) {
+	src := "; global settings\nroot = /srv\n\n[db]\nhost: localhost ; inline comment\nport = 5432\nname = \"my;db\"\nurl = http://x/#frag\n\n[db]\nuser = admin\n"
+	d, err := ParseINI(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `#{"db": #{"host": localhost, "name": my;db, "port": 5432, "url": http://x/#frag, "user": admin}, "root": /srv}`
+	if got := d.Inspect(); got != want {
+		t.Fatalf("unexpected result:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestParseINIErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"[db\n", "ini: line 1: unterminated section header"},
+		{"[]\n", "empty section name"},
+		{"a = 1\njunk\n", "ini: line 2: expected key = value"},
+		{"= 1\n", "missing key"},
+		{"db = 1\n[db]\n", "section [db] clashes with a key of the same name"},
+	}
+	for _, tt := range tests {
+		_, err := ParseINI(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: expected error containing %q, got %v", tt.src, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,812 @@
+package dataformat
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"welle/internal/object"
+)
+
+// ParseTOML decodes a TOML 1.0 document. Dates and times have no welle
+// type, so they are returned as STRINGs holding their original text.
+func ParseTOML(src string) (*object.Dict, error) {
+	p := &tomlParser{
+		src:         src,
+		line:        1,
+		root:        newDict(),
+		explicit:    map[*object.Dict]bool{},
+		inline:      map[*object.Dict]bool{},
+		tableArrays: map[*object.Array]bool{},
+	}
+	p.cur = p.root
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.root, nil
+}
+
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+	root *object.Dict
+	cur  *object.Dict
+	// explicit holds tables opened by a [header], which may not be opened
+	// again; inline holds inline tables, which are closed once written;
+	// tableArrays holds arrays created by [[header]]s.
+	explicit    map[*object.Dict]bool
+	inline      map[*object.Dict]bool
+	tableArrays map[*object.Array]bool
+}
+
+func (p *tomlParser) errorf(msg string, args ...any) error {
+	return lineError("toml", p.line, msg, args...)
+}
+
+func (p *tomlParser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.src[p.pos] != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// newline consumes a line break if one is next.
+func (p *tomlParser) newline() bool {
+	if strings.HasPrefix(p.src[p.pos:], "\r\n") {
+		p.pos += 2
+	} else if p.peek() == '\n' {
+		p.pos++
+	} else {
+		return false
+	}
+	p.line++
+	return true
+}
+
+// skipBlank skips whitespace, comments and line breaks.
+func (p *tomlParser) skipBlank() {
+	for {
+		p.skipSpace()
+		p.skipComment()
+		if !p.newline() {
+			return
+		}
+	}
+}
+
+func (p *tomlParser) parse() error {
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return nil
+		}
+		var err error
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], "[["):
+			err = p.parseTableArrayHeader()
+		case p.peek() == '[':
+			err = p.parseTableHeader()
+		default:
+			err = p.parseKeyValue(p.cur)
+		}
+		if err != nil {
+			return err
+		}
+		p.skipSpace()
+		p.skipComment()
+		if !p.eof() && !p.newline() {
+			return p.errorf("unexpected %q after value", p.src[p.pos:p.pos+1])
+		}
+	}
+}
+
+func (p *tomlParser) parseKey() ([]string, error) {
+	var parts []string
+	for {
+		p.skipSpace()
+		var part string
+		switch c := p.peek(); {
+		case c == '"':
+			s, err := p.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			part = s
+		case c == '\'':
+			s, err := p.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			part = s
+		case isBareKeyChar(c):
+			start := p.pos
+			for isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			part = p.src[start:p.pos]
+		default:
+			return nil, p.errorf("expected a key")
+		}
+		parts = append(parts, part)
+		p.skipSpace()
+		if p.peek() != '.' {
+			return parts, nil
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// walk follows a header path from the root, creating missing tables and
+// stepping into the last element of arrays of tables.
+func (p *tomlParser) walk(path, full []string) (*object.Dict, error) {
+	d := p.root
+	for _, name := range path {
+		v, ok := lookup(d, name)
+		if !ok {
+			t := newDict()
+			store(d, name, t)
+			d = t
+			continue
+		}
+		switch v := v.(type) {
+		case *object.Dict:
+			if p.inline[v] {
+				return nil, p.errorf("cannot extend inline table %s", joinKey(full))
+			}
+			d = v
+		case *object.Array:
+			if !p.tableArrays[v] {
+				return nil, p.errorf("key %s is not a table", joinKey(full))
+			}
+			d = v.Elements[len(v.Elements)-1].(*object.Dict)
+		default:
+			return nil, p.errorf("key %s is not a table", joinKey(full))
+		}
+	}
+	return d, nil
+}
+
+func (p *tomlParser) parseTableHeader() error {
+	p.pos++
+	path, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if p.peek() != ']' {
+		return p.errorf("expected ] to close table header")
+	}
+	p.pos++
+	parent, err := p.walk(path[:len(path)-1], path)
+	if err != nil {
+		return err
+	}
+	name := path[len(path)-1]
+	v, ok := lookup(parent, name)
+	if !ok {
+		t := newDict()
+		store(parent, name, t)
+		p.explicit[t] = true
+		p.cur = t
+		return nil
+	}
+	t, isTable := v.(*object.Dict)
+	if !isTable || p.explicit[t] || p.inline[t] {
+		return p.errorf("table %s is defined twice", joinKey(path))
+	}
+	p.explicit[t] = true
+	p.cur = t
+	return nil
+}
+
+func (p *tomlParser) parseTableArrayHeader() error {
+	p.pos += 2
+	path, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(p.src[p.pos:], "]]") {
+		return p.errorf("expected ]] to close array of tables header")
+	}
+	p.pos += 2
+	parent, err := p.walk(path[:len(path)-1], path)
+	if err != nil {
+		return err
+	}
+	name := path[len(path)-1]
+	t := newDict()
+	p.explicit[t] = true
+	v, ok := lookup(parent, name)
+	if !ok {
+		arr := &object.Array{Elements: []object.Object{t}}
+		p.tableArrays[arr] = true
+		store(parent, name, arr)
+	} else if arr, isArr := v.(*object.Array); isArr && p.tableArrays[arr] {
+		arr.Elements = append(arr.Elements, t)
+	} else {
+		return p.errorf("key %s is already defined and is not an array of tables", joinKey(path))
+	}
+	p.cur = t
+	return nil
+}
+
+func (p *tomlParser) parseKeyValue(into *object.Dict) error {
+	path, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if p.peek() != '=' {
+		return p.errorf("expected = after key %s", joinKey(path))
+	}
+	p.pos++
+	p.skipSpace()
+	val, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	d := into
+	for _, name := range path[:len(path)-1] {
+		v, ok := lookup(d, name)
+		if !ok {
+			t := newDict()
+			store(d, name, t)
+			d = t
+			continue
+		}
+		t, isTable := v.(*object.Dict)
+		if !isTable || p.inline[t] {
+			return p.errorf("key %s is already defined", joinKey(path))
+		}
+		d = t
+	}
+	name := path[len(path)-1]
+	if _, exists := lookup(d, name); exists {
+		return p.errorf("key %s is defined twice", joinKey(path))
+	}
+	store(d, name, val)
+	return nil
+}
+
+func (p *tomlParser) parseValue() (object.Object, error) {
+	rest := p.src[p.pos:]
+	switch c := p.peek(); {
+	case strings.HasPrefix(rest, `"""`):
+		s, err := p.parseMultilineString('"')
+		return &object.String{Value: s}, err
+	case strings.HasPrefix(rest, "'''"):
+		s, err := p.parseMultilineString('\'')
+		return &object.String{Value: s}, err
+	case c == '"':
+		s, err := p.parseBasicString()
+		return &object.String{Value: s}, err
+	case c == '\'':
+		s, err := p.parseLiteralString()
+		return &object.String{Value: s}, err
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	}
+	tok := p.scalarToken()
+	if tok == "" {
+		return nil, p.errorf("missing value")
+	}
+	// A space may separate the date and time of a datetime.
+	if tomlDateRe().MatchString(tok) && p.peek() == ' ' && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1]) {
+		p.pos++
+		tok += " " + p.scalarToken()
+	}
+	v, err := tomlScalar(tok)
+	if err != nil {
+		return nil, p.errorf("%s", err)
+	}
+	return v, nil
+}
+
+func (p *tomlParser) scalarToken() string {
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(" \t\r\n,]}#", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// lazyRegexp compiles expr on first use, so programs that never read
+// TOML or YAML do not pay for it at startup.
+func lazyRegexp(expr string) func() *regexp.Regexp {
+	return sync.OnceValue(func() *regexp.Regexp { return regexp.MustCompile(expr) })
+}
+
+var (
+	tomlDateRe     = lazyRegexp(`^\d{4}-\d{2}-\d{2}$`)
+	tomlDateTimeRe = lazyRegexp(`^(\d{4}-|\d{2}:)`)
+	tomlIntRe      = lazyRegexp(`^[+-]?(0|[1-9][0-9]*)$`)
+	tomlFloatRe    = lazyRegexp(`^[+-]?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+)
+
+var tomlDateLayouts = []string{
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+	"15:04:05.999999999",
+}
+
+func tomlScalar(tok string) (object.Object, error) {
+	switch tok {
+	case "true":
+		return &object.Boolean{Value: true}, nil
+	case "false":
+		return &object.Boolean{Value: false}, nil
+	case "inf", "+inf":
+		return &object.Float{Value: math.Inf(1)}, nil
+	case "-inf":
+		return &object.Float{Value: math.Inf(-1)}, nil
+	case "nan", "+nan", "-nan":
+		return &object.Float{Value: math.NaN()}, nil
+	}
+	if tomlDateTimeRe().MatchString(tok) {
+		norm := strings.NewReplacer(" ", "T", "t", "T", "z", "Z").Replace(tok)
+		for _, layout := range tomlDateLayouts {
+			if _, err := time.Parse(layout, norm); err == nil {
+				return &object.String{Value: tok}, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid date or time %q", tok)
+	}
+	if len(tok) > 2 && tok[0] == '0' && strings.ContainsRune("xob", rune(tok[1])) {
+		base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[tok[1]]
+		digits, ok := stripUnderscores(tok[2:])
+		if ok {
+			if n, err := strconv.ParseInt(digits, base, 64); err == nil {
+				return &object.Integer{Value: n}, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid integer %q", tok)
+	}
+	s, ok := stripUnderscores(tok)
+	if !ok {
+		return nil, fmt.Errorf("invalid value %q", tok)
+	}
+	if tomlIntRe().MatchString(s) {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("integer %s is out of range", tok)
+		}
+		return &object.Integer{Value: n}, nil
+	}
+	if tomlFloatRe().MatchString(s) {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %q", tok)
+		}
+		return &object.Float{Value: f}, nil
+	}
+	return nil, fmt.Errorf("invalid value %q", tok)
+}
+
+// stripUnderscores removes digit separators, each of which must sit between
+// two digits.
+func stripUnderscores(s string) (string, bool) {
+	if !strings.Contains(s, "_") {
+		return s, true
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '_' && (i == 0 || i == len(s)-1 || !isHexDigit(s[i-1]) || !isHexDigit(s[i+1])) {
+			return "", false
+		}
+	}
+	return strings.ReplaceAll(s, "_", ""), true
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.src[p.pos]
+		switch c {
+		case '"':
+			p.pos++
+			return b.String(), nil
+		case '\\':
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++
+	end := strings.IndexAny(p.src[p.pos:], "'\n")
+	if end < 0 || p.src[p.pos+end] == '\n' {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.src[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+// parseMultilineString reads a """ or ”' string. A line break right after
+// the opening delimiter is dropped, and in basic strings a backslash at the
+// end of a line swallows the break and the leading whitespace after it.
+func (p *tomlParser) parseMultilineString(quote byte) (string, error) {
+	p.pos += 3
+	p.newline()
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated multi-line string")
+		}
+		c := p.src[p.pos]
+		switch {
+		case c == quote && strings.HasPrefix(p.src[p.pos:], strings.Repeat(string(quote), 3)):
+			n := 0
+			for p.pos+n < len(p.src) && p.src[p.pos+n] == quote {
+				n++
+			}
+			if n > 5 {
+				return "", p.errorf("too many quotes closing multi-line string")
+			}
+			b.WriteString(strings.Repeat(string(quote), n-3))
+			p.pos += n
+			return b.String(), nil
+		case c == '\n' || c == '\r' && strings.HasPrefix(p.src[p.pos:], "\r\n"):
+			p.newline()
+			b.WriteByte('\n')
+		case c == '\\' && quote == '"':
+			j := p.pos + 1
+			for j < len(p.src) && (p.src[j] == ' ' || p.src[j] == '\t') {
+				j++
+			}
+			if j < len(p.src) && (p.src[j] == '\n' || p.src[j] == '\r') {
+				p.pos = j
+				p.skipBlankLines()
+				continue
+			}
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *tomlParser) skipBlankLines() {
+	for {
+		p.skipSpace()
+		if !p.newline() {
+			return
+		}
+	}
+}
+
+func (p *tomlParser) parseEscape(b *strings.Builder) error {
+	if p.pos+1 >= len(p.src) {
+		return p.errorf("unterminated string")
+	}
+	c := p.src[p.pos+1]
+	p.pos += 2
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case '"':
+		b.WriteByte('"')
+	case '\\':
+		b.WriteByte('\\')
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+size > len(p.src) {
+			return p.errorf("invalid unicode escape")
+		}
+		n, err := strconv.ParseUint(p.src[p.pos:p.pos+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(n)) {
+			return p.errorf("invalid unicode escape \\%c%s", c, p.src[p.pos:p.pos+size])
+		}
+		b.WriteRune(rune(n))
+		p.pos += size
+	default:
+		return p.errorf("invalid escape \\%c", c)
+	}
+	return nil
+}
+
+func (p *tomlParser) parseArray() (object.Object, error) {
+	p.pos++
+	arr := &object.Array{}
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return arr, nil
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		arr.Elements = append(arr.Elements, v)
+		p.skipBlank()
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return arr, nil
+		default:
+			return nil, p.errorf("expected , or ] in array")
+		}
+	}
+}
+
+func (p *tomlParser) parseInlineTable() (object.Object, error) {
+	p.pos++
+	t := newDict()
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.pos++
+		p.inline[t] = true
+		return t, nil
+	}
+	for {
+		if err := p.parseKeyValue(t); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			p.inline[t] = true
+			return t, nil
+		default:
+			return nil, p.errorf("expected , or } in inline table")
+		}
+	}
+}
+
+func joinKey(path []string) string {
+	parts := make([]string, len(path))
+	for i, name := range path {
+		parts[i] = tomlKey(name)
+	}
+	return strings.Join(parts, ".")
+}
+
+// StringifyTOML encodes d as a TOML document: plain keys first, then
+// nested tables as [sections] and arrays of dicts as [[sections]], all in
+// sorted key order. nil has no TOML form and is rejected.
+func StringifyTOML(d *object.Dict) (string, error) {
+	var b strings.Builder
+	if err := writeTOMLTable(&b, nil, d, "", false); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func writeTOMLTable(b *strings.Builder, path []string, d *object.Dict, header string, force bool) error {
+	type entry struct {
+		key string
+		val object.Object
+	}
+	var plain, tables, arrays []entry
+	for _, pair := range object.SortedDictPairs(d) {
+		k, ok := pair.Key.(*object.String)
+		if !ok {
+			return fmt.Errorf("toml: keys must be STRING, got %s", pair.Key.Type())
+		}
+		e := entry{k.Value, pair.Value}
+		switch v := pair.Value.(type) {
+		case *object.Dict:
+			tables = append(tables, e)
+		case *object.Array:
+			if isTableArray(v) {
+				arrays = append(arrays, e)
+			} else {
+				plain = append(plain, e)
+			}
+		default:
+			plain = append(plain, e)
+		}
+	}
+	if header != "" && (force || len(plain) > 0 || len(tables)+len(arrays) == 0) {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(header + "\n")
+	}
+	for _, e := range plain {
+		s, err := tomlValue(e.val, append(path[:len(path):len(path)], e.key))
+		if err != nil {
+			return err
+		}
+		b.WriteString(tomlKey(e.key) + " = " + s + "\n")
+	}
+	for _, e := range tables {
+		sub := append(path[:len(path):len(path)], e.key)
+		if err := writeTOMLTable(b, sub, e.val.(*object.Dict), "["+joinKey(sub)+"]", false); err != nil {
+			return err
+		}
+	}
+	for _, e := range arrays {
+		sub := append(path[:len(path):len(path)], e.key)
+		for _, el := range e.val.(*object.Array).Elements {
+			if err := writeTOMLTable(b, sub, el.(*object.Dict), "[["+joinKey(sub)+"]]", true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isTableArray(arr *object.Array) bool {
+	if len(arr.Elements) == 0 {
+		return false
+	}
+	for _, el := range arr.Elements {
+		if _, ok := el.(*object.Dict); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func tomlValue(v object.Object, path []string) (string, error) {
+	switch v := v.(type) {
+	case *object.String:
+		return tomlQuote(v.Value), nil
+	case *object.Integer:
+		return strconv.FormatInt(v.Value, 10), nil
+	case *object.Float:
+		switch {
+		case math.IsNaN(v.Value):
+			return "nan", nil
+		case math.IsInf(v.Value, 1):
+			return "inf", nil
+		case math.IsInf(v.Value, -1):
+			return "-inf", nil
+		}
+		s := strconv.FormatFloat(v.Value, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s, nil
+	case *object.Boolean:
+		return strconv.FormatBool(v.Value), nil
+	case *object.Array:
+		return tomlList(v.Elements, path)
+	case *object.Tuple:
+		return tomlList(v.Elements, path)
+	case *object.Dict:
+		pairs := object.SortedDictPairs(v)
+		if len(pairs) == 0 {
+			return "{}", nil
+		}
+		parts := make([]string, len(pairs))
+		for i, pair := range pairs {
+			k, ok := pair.Key.(*object.String)
+			if !ok {
+				return "", fmt.Errorf("toml: keys must be STRING, got %s", pair.Key.Type())
+			}
+			s, err := tomlValue(pair.Value, append(path[:len(path):len(path)], k.Value))
+			if err != nil {
+				return "", err
+			}
+			parts[i] = tomlKey(k.Value) + " = " + s
+		}
+		return "{ " + strings.Join(parts, ", ") + " }", nil
+	case *object.Nil:
+		return "", fmt.Errorf("toml: cannot encode nil at %s", joinKey(path))
+	}
+	return "", fmt.Errorf("toml: cannot encode %s at %s", v.Type(), joinKey(path))
+}
+
+func tomlList(els []object.Object, path []string) (string, error) {
+	parts := make([]string, len(els))
+	for i, el := range els {
+		s, err := tomlValue(el, path)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = s
+	}
+	return "[" + strings.Join(parts, ", ") + "]", nil
+}
+
+func tomlKey(k string) string {
+	if k == "" {
+		return `""`
+	}
+	for i := 0; i < len(k); i++ {
+		if !isBareKeyChar(k[i]) {
+			return tomlQuote(k)
+		}
+	}
+	return k
+}
+
+func tomlQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
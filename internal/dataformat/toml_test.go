@@ -0,0 +1,91 @@
+package dataformat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	src := `# project file
+name = "demo"
+version = 0x1F
+ratio = 1_000.5e-3
+tags = [ "a", 'b',
+  """c""", ] # trailing comma
+when = 1979-05-27 07:32:00Z
+point = { x = 1, y.z = -2 }
+site."google.com" = true
+
+[server]
+host = "local\thost"
+ports = [8000, 8001]
+
+[server.tls]
+on = false
+
+[[plugins]]
+name = "fmt"
+
+[[plugins]]
+name = "lint"
+args = '''
+--strict
+'''
+`
+	d, err := ParseTOML(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `#{"name": demo, "plugins": [#{"name": fmt}, #{"args": --strict
+, "name": lint}], "point": #{"x": 1, "y": #{"z": -2}}, "ratio": 1.0005, "server": #{"host": local	host, "ports": [8000, 8001], "tls": #{"on": false}}, "site": #{"google.com": true}, "tags": [a, b, c], "version": 31, "when": 1979-05-27 07:32:00Z}`
+	if got := d.Inspect(); got != want {
+		t.Fatalf("unexpected result:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"a = 1\na = 2\n", "toml: line 2: key a is defined twice"},
+		{"[t]\n[t]\n", "toml: line 2: table t is defined twice"},
+		{"a = \n", "toml: line 1: missing value"},
+		{"a = 01\n", `invalid value "01"`},
+		{"a = 1__0\n", `invalid value "1__0"`},
+		{"a = \"open\n", "unterminated string"},
+		{"a = \"\\q\"\n", `invalid escape \q`},
+		{"a = 1 b = 2\n", `unexpected "b" after value`},
+		{"p = { x = 1 }\n[p]\n", "table p is defined twice"},
+		{"p = { x = 1 }\n[p.q]\n", "cannot extend inline table p.q"},
+		{"a = [1, 2\n", "unterminated array"},
+		{"d = 2024-13-01\n", `invalid date or time "2024-13-01"`},
+		{"n = 9223372036854775808\n", "out of range"},
+	}
+	for _, tt := range tests {
+		_, err := ParseTOML(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: expected error containing %q, got %v", tt.src, tt.want, err)
+		}
+	}
+}
+
+func TestStringifyTOMLRoundTrip(t *testing.T) {
+	src := "title = \"a \\\"q\\\"\"\nn = 3\nf = 2.0\nlist = [1, [2, 3], { k = \"v\" }]\n\n[db]\nuser = \"root\"\n\n[db.pool]\nsize = 4\n\n[[jobs]]\nid = 1\n\n[[jobs]]\nid = 2\n"
+	d, err := ParseTOML(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	out, err := StringifyTOML(d)
+	if err != nil {
+		t.Fatalf("stringify: %v", err)
+	}
+	want := "f = 2.0\nlist = [1, [2, 3], { k = \"v\" }]\nn = 3\ntitle = \"a \\\"q\\\"\"\n\n[db]\nuser = \"root\"\n\n[db.pool]\nsize = 4\n\n[[jobs]]\nid = 1\n\n[[jobs]]\nid = 2\n"
+	if out != want {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	again, err := ParseTOML(out)
+	if err != nil || again.Inspect() != d.Inspect() {
+		t.Fatalf("round trip changed the value: %v\n%s", err, again.Inspect())
+	}
+}
@@ -0,0 +1,682 @@
+package dataformat
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"welle/internal/object"
+)
+
+// ParseYAML reads a single YAML document: block and flow mappings and
+// sequences, plain, quoted and block (| and >) scalars, and comments.
+// Plain scalars resolve by the YAML 1.2 core schema. Mapping keys are
+// always STRINGs. Anchors, aliases, tags and multiple documents are not
+// supported and are reported as errors.
+func ParseYAML(src string) (object.Object, error) {
+	lines, err := yamlSplit(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &yamlParser{lines: lines}
+	p.skip()
+	if p.eof() {
+		return &object.Nil{}, nil
+	}
+	v, err := p.parseBlock(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skip()
+	if !p.eof() {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return v, nil
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string // the line after its indentation
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// yamlSplit breaks src into lines and handles the document markers: a
+// leading "---" (which may carry the root value) and a trailing "...".
+func yamlSplit(src string) ([]yamlLine, error) {
+	var lines []yamlLine
+	started, ended := false, false
+	for i, raw := range strings.Split(strings.TrimSuffix(src, "\n"), "\n") {
+		raw = strings.TrimRight(strings.TrimSuffix(raw, "\r"), " \t")
+		if i == 0 {
+			raw = strings.TrimPrefix(raw, "\ufeff")
+		}
+		text := strings.TrimLeft(raw, " ")
+		ln := yamlLine{num: i + 1, indent: len(raw) - len(text), text: text}
+		content := text != "" && text[0] != '#'
+		if ended {
+			if content {
+				return nil, lineError("yaml", ln.num, "multiple documents are not supported")
+			}
+			continue
+		}
+		if ln.indent == 0 && strings.HasPrefix(text, "%") && !started {
+			continue
+		}
+		if ln.indent == 0 && (text == "---" || strings.HasPrefix(text, "--- ")) {
+			if started {
+				return nil, lineError("yaml", ln.num, "multiple documents are not supported")
+			}
+			started = true
+			ln.text = strings.TrimLeft(text[3:], " ")
+			ln.indent = len(text) - len(ln.text)
+		} else if ln.indent == 0 && text == "..." {
+			ended = true
+			continue
+		}
+		if content {
+			started = true
+		}
+		lines = append(lines, ln)
+	}
+	return lines, nil
+}
+
+func (p *yamlParser) errorf(msg string, args ...any) error {
+	line := 0
+	if p.pos < len(p.lines) {
+		line = p.lines[p.pos].num
+	} else if len(p.lines) > 0 {
+		line = p.lines[len(p.lines)-1].num
+	}
+	return lineError("yaml", line, msg, args...)
+}
+
+func (p *yamlParser) eof() bool { return p.pos >= len(p.lines) }
+
+func isYAMLBlank(ln yamlLine) bool {
+	return ln.text == "" || ln.text[0] == '#'
+}
+
+// skip moves past blank and comment-only lines and reports how many it
+// passed.
+func (p *yamlParser) skip() int {
+	n := 0
+	for !p.eof() && isYAMLBlank(p.lines[p.pos]) {
+		p.pos++
+		n++
+	}
+	return n
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseBlock parses the node starting at the next content line, or returns
+// nil when that line is indented less than minIndent.
+func (p *yamlParser) parseBlock(minIndent int) (object.Object, error) {
+	p.skip()
+	if p.eof() || p.lines[p.pos].indent < minIndent {
+		return &object.Nil{}, nil
+	}
+	ln := p.lines[p.pos]
+	if strings.HasPrefix(ln.text, "\t") {
+		return nil, p.errorf("tabs are not allowed in indentation")
+	}
+	text := stripYAMLComment(ln.text)
+	if isSeqItem(text) {
+		return p.parseSeq(ln.indent)
+	}
+	if _, _, ok, err := p.splitEntry(text); err != nil {
+		return nil, err
+	} else if ok {
+		return p.parseMap(ln.indent)
+	}
+	p.pos++
+	if text[0] == '|' || text[0] == '>' {
+		return p.parseBlockScalar(minIndent-1, text)
+	}
+	return p.parseInline(text, minIndent)
+}
+
+func (p *yamlParser) parseSeq(indent int) (object.Object, error) {
+	arr := &object.Array{}
+	for {
+		p.skip()
+		if p.eof() || p.lines[p.pos].indent < indent {
+			return arr, nil
+		}
+		ln := &p.lines[p.pos]
+		if ln.indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		text := stripYAMLComment(ln.text)
+		if !isSeqItem(text) {
+			return arr, nil
+		}
+		rest := strings.TrimLeft(text[1:], " ")
+		var v object.Object
+		var err error
+		if rest == "" {
+			p.pos++
+			v, err = p.parseBlock(indent + 1)
+		} else {
+			// Re-read the rest of the line as a node at its own column, so
+			// "- a: 1" starts a mapping indented past the dash.
+			offset := len(text) - len(rest)
+			ln.indent += offset
+			ln.text = ln.text[offset:]
+			v, err = p.parseBlock(ln.indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		arr.Elements = append(arr.Elements, v)
+	}
+}
+
+func (p *yamlParser) parseMap(indent int) (object.Object, error) {
+	d := newDict()
+	for {
+		p.skip()
+		if p.eof() || p.lines[p.pos].indent < indent {
+			return d, nil
+		}
+		ln := p.lines[p.pos]
+		if ln.indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		key, rest, ok, err := p.splitEntry(stripYAMLComment(ln.text))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, p.errorf("expected a mapping key")
+		}
+		if _, dup := lookup(d, key); dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		p.pos++
+		v, err := p.parseValue(indent, rest)
+		if err != nil {
+			return nil, err
+		}
+		store(d, key, v)
+	}
+}
+
+// parseValue parses what follows "key:" in a block mapping at indent.
+func (p *yamlParser) parseValue(indent int, rest string) (object.Object, error) {
+	if rest == "" {
+		p.skip()
+		if !p.eof() && p.lines[p.pos].indent == indent && isSeqItem(stripYAMLComment(p.lines[p.pos].text)) {
+			return p.parseSeq(indent)
+		}
+		return p.parseBlock(indent + 1)
+	}
+	if rest[0] == '|' || rest[0] == '>' {
+		return p.parseBlockScalar(indent, rest)
+	}
+	return p.parseInline(rest, indent+1)
+}
+
+// splitEntry splits a "key: value" line. ok is false when text is not a
+// mapping entry.
+func (p *yamlParser) splitEntry(text string) (key, rest string, ok bool, err error) {
+	if text == "" || strings.ContainsRune("[{#|>", rune(text[0])) || isSeqItem(text) {
+		return "", "", false, nil
+	}
+	if strings.HasPrefix(text, "? ") || text == "?" {
+		return "", "", false, p.errorf("complex mapping keys are not supported")
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		s, n, err := p.decodeQuoted(text)
+		if err != nil {
+			return "", "", false, nil
+		}
+		after := strings.TrimLeft(text[n:], " ")
+		if after != ":" && !strings.HasPrefix(after, ": ") {
+			return "", "", false, nil
+		}
+		return s, strings.TrimSpace(after[1:]), true, nil
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			key = strings.TrimRight(text[:i], " ")
+			if key == "" {
+				return "", "", false, nil
+			}
+			if strings.ContainsRune("&*!", rune(key[0])) {
+				return "", "", false, p.errorf("anchors, aliases and tags are not supported")
+			}
+			return key, strings.TrimSpace(text[i+1:]), true, nil
+		}
+	}
+	return "", "", false, nil
+}
+
+// parseInline parses a scalar or flow collection that starts with text
+// and may continue on following lines indented at least minIndent.
+func (p *yamlParser) parseInline(text string, minIndent int) (object.Object, error) {
+	switch text[0] {
+	case '&', '*', '!':
+		return nil, p.errorf("anchors, aliases and tags are not supported")
+	case '[', '{':
+		for !flowClosed(text) {
+			p.skip()
+			if p.eof() || p.lines[p.pos].indent < minIndent {
+				return nil, p.errorf("unterminated flow collection")
+			}
+			text += " " + stripYAMLComment(p.lines[p.pos].text)
+			p.pos++
+		}
+		f := &yamlFlow{p: p, s: text}
+		v, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		f.space()
+		if f.i < len(f.s) {
+			return nil, p.errorf("unexpected %q after flow collection", f.s[f.i:])
+		}
+		return v, nil
+	case '"', '\'':
+		for {
+			s, n, err := p.decodeQuoted(text)
+			if err == nil {
+				if after := strings.TrimSpace(text[n:]); after != "" && after[0] != '#' {
+					return nil, p.errorf("unexpected %q after quoted scalar", after)
+				}
+				return &object.String{Value: s}, nil
+			}
+			blanks := p.skip()
+			if p.eof() || p.lines[p.pos].indent < minIndent {
+				return nil, err
+			}
+			text += foldSep(blanks) + p.lines[p.pos].text
+			p.pos++
+		}
+	}
+	for {
+		save := p.pos
+		blanks := p.skip()
+		if p.eof() || p.lines[p.pos].indent < minIndent || isSeqItem(p.lines[p.pos].text) {
+			p.pos = save
+			break
+		}
+		next := stripYAMLComment(p.lines[p.pos].text)
+		if _, _, entry, _ := p.splitEntry(next); entry {
+			return nil, p.errorf("mapping values are not allowed here")
+		}
+		text += foldSep(blanks) + next
+		p.pos++
+	}
+	return yamlPlain(text), nil
+}
+
+func foldSep(blanks int) string {
+	if blanks == 0 {
+		return " "
+	}
+	return strings.Repeat("\n", blanks)
+}
+
+// parseBlockScalar reads a | (literal) or > (folded) scalar whose header
+// ends the line of a mapping entry at indent.
+func (p *yamlParser) parseBlockScalar(indent int, header string) (object.Object, error) {
+	chomp := byte(0)
+	explicit := 0
+	for _, c := range header[1:] {
+		switch {
+		case (c == '-' || c == '+') && chomp == 0:
+			chomp = byte(c)
+		case c >= '1' && c <= '9' && explicit == 0:
+			explicit = int(c - '0')
+		default:
+			return nil, p.errorf("invalid block scalar header %q", header)
+		}
+	}
+	content := -1
+	if explicit > 0 {
+		content = indent + explicit
+	}
+	var body []string
+	for ; !p.eof(); p.pos++ {
+		ln := p.lines[p.pos]
+		if ln.text == "" {
+			body = append(body, "")
+			continue
+		}
+		if content < 0 {
+			if ln.indent <= indent {
+				break
+			}
+			content = ln.indent
+		}
+		if ln.indent < content {
+			break
+		}
+		body = append(body, strings.Repeat(" ", ln.indent-content)+ln.text)
+	}
+	trailing := 0
+	for len(body) > 0 && body[len(body)-1] == "" {
+		body = body[:len(body)-1]
+		trailing++
+	}
+	var b strings.Builder
+	for i, l := range body {
+		if i > 0 {
+			b.WriteString(blockSep(header[0], body[i-1], l))
+		}
+		b.WriteString(l)
+	}
+	s := b.String()
+	switch {
+	case chomp == '-' || len(body) == 0 && chomp != '+':
+	case chomp == '+':
+		s += strings.Repeat("\n", trailing+1)
+	default:
+		s += "\n"
+	}
+	return &object.String{Value: s}, nil
+}
+
+// blockSep is the text joining two lines of a block scalar. Folded
+// scalars turn the break between two plain lines into a space and drop the
+// break before a run of empty lines, each of which stands for one newline.
+func blockSep(style byte, prev, cur string) string {
+	if style == '|' {
+		return "\n"
+	}
+	plain := func(s string) bool { return s != "" && s[0] != ' ' && s[0] != '\t' }
+	switch {
+	case plain(prev) && plain(cur):
+		return " "
+	case plain(prev) && cur == "":
+		return ""
+	}
+	return "\n"
+}
+
+// stripYAMLComment drops a trailing "# comment", ignoring # inside quoted
+// scalars and # not preceded by whitespace.
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if quoteCanOpen(text[:i]) {
+				quote = c
+			}
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return strings.TrimRight(text[:i], " \t")
+		}
+	}
+	return text
+}
+
+// quoteCanOpen reports whether a quote after before starts a quoted
+// scalar rather than sitting inside a plain one.
+func quoteCanOpen(before string) bool {
+	before = strings.TrimRight(before, " ")
+	return before == "" || strings.ContainsRune(":-,[{?", rune(before[len(before)-1]))
+}
+
+func flowClosed(text string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth <= 0 && quote == 0
+}
+
+// decodeQuoted decodes the quoted scalar at the start of text and returns
+// it with the number of bytes it spans.
+func (p *yamlParser) decodeQuoted(text string) (string, int, error) {
+	q := text[0]
+	var b strings.Builder
+	for i := 1; i < len(text); i++ {
+		c := text[i]
+		if q == '\'' {
+			if c == '\'' {
+				if i+1 < len(text) && text[i+1] == '\'' {
+					b.WriteByte('\'')
+					i++
+					continue
+				}
+				return b.String(), i + 1, nil
+			}
+			b.WriteByte(c)
+			continue
+		}
+		switch c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 >= len(text) {
+				return "", 0, p.errorf("unterminated string")
+			}
+			n, err := p.yamlEscape(&b, text[i+1:])
+			if err != nil {
+				return "", 0, err
+			}
+			i += n
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, p.errorf("unterminated string")
+}
+
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v",
+	'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+	'N': "\u0085", '_': " ", 'L': " ", 'P': " ",
+}
+
+// yamlEscape decodes the escape whose letter starts s and returns how many
+// bytes of s it used.
+func (p *yamlParser) yamlEscape(b *strings.Builder, s string) (int, error) {
+	if out, ok := yamlEscapes[s[0]]; ok {
+		b.WriteString(out)
+		return 1, nil
+	}
+	size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[0]]
+	if size == 0 || len(s) < size+1 {
+		return 0, p.errorf("invalid escape \\%c", s[0])
+	}
+	n, err := strconv.ParseUint(s[1:size+1], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(n)) {
+		return 0, p.errorf("invalid escape \\%s", s[:size+1])
+	}
+	b.WriteRune(rune(n))
+	return size + 1, nil
+}
+
+var (
+	yamlIntRe   = lazyRegexp(`^[-+]?[0-9]+$`)
+	yamlFloatRe = lazyRegexp(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// yamlPlain resolves a plain scalar to nil, a BOOLEAN, a number or a
+// STRING.
+func yamlPlain(s string) object.Object {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return &object.Nil{}
+	case "true", "True", "TRUE":
+		return &object.Boolean{Value: true}
+	case "false", "False", "FALSE":
+		return &object.Boolean{Value: false}
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return &object.Float{Value: math.Inf(1)}
+	case "-.inf", "-.Inf", "-.INF":
+		return &object.Float{Value: math.Inf(-1)}
+	case ".nan", ".NaN", ".NAN":
+		return &object.Float{Value: math.NaN()}
+	}
+	if yamlIntRe().MatchString(s) {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return &object.Integer{Value: n}
+		}
+	}
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'o') {
+		base := 16
+		if s[1] == 'o' {
+			base = 8
+		}
+		if n, err := strconv.ParseInt(s[2:], base, 64); err == nil {
+			return &object.Integer{Value: n}
+		}
+	}
+	if yamlFloatRe().MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return &object.Float{Value: f}
+		}
+	}
+	return &object.String{Value: s}
+}
+
+// yamlFlow parses a flow collection ([a, b] or {k: v}) that has been
+// gathered onto one line.
+type yamlFlow struct {
+	p *yamlParser
+	s string
+	i int
+}
+
+func (f *yamlFlow) space() {
+	for f.i < len(f.s) && (f.s[f.i] == ' ' || f.s[f.i] == '\t') {
+		f.i++
+	}
+}
+
+func (f *yamlFlow) value() (object.Object, error) {
+	f.space()
+	if f.i >= len(f.s) {
+		return nil, f.p.errorf("unterminated flow collection")
+	}
+	switch c := f.s[f.i]; c {
+	case '[':
+		f.i++
+		arr := &object.Array{}
+		for {
+			f.space()
+			if f.i < len(f.s) && f.s[f.i] == ']' {
+				f.i++
+				return arr, nil
+			}
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			arr.Elements = append(arr.Elements, v)
+			if err := f.sep(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.i++
+		d := newDict()
+		for {
+			f.space()
+			if f.i < len(f.s) && f.s[f.i] == '}' {
+				f.i++
+				return d, nil
+			}
+			k, err := f.scalar(true)
+			if err != nil {
+				return nil, err
+			}
+			key := k.Inspect()
+			if s, ok := k.(*object.String); ok {
+				key = s.Value
+			}
+			var v object.Object = &object.Nil{}
+			f.space()
+			if f.i < len(f.s) && f.s[f.i] == ':' {
+				f.i++
+				if v, err = f.value(); err != nil {
+					return nil, err
+				}
+			}
+			if _, dup := lookup(d, key); dup {
+				return nil, f.p.errorf("duplicate key %q", key)
+			}
+			store(d, key, v)
+			if err := f.sep('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '&', '*', '!':
+		return nil, f.p.errorf("anchors, aliases and tags are not supported")
+	}
+	return f.scalar(false)
+}
+
+// sep consumes the comma between flow items, or leaves the closing bracket
+// for the caller.
+func (f *yamlFlow) sep(close byte) error {
+	f.space()
+	if f.i < len(f.s) && f.s[f.i] == ',' {
+		f.i++
+		return nil
+	}
+	if f.i < len(f.s) && f.s[f.i] == close {
+		return nil
+	}
+	return f.p.errorf("expected , or %c in flow collection", close)
+}
+
+// scalar reads a quoted or plain flow scalar. A plain key also stops at
+// ": ", since the colon ends it.
+func (f *yamlFlow) scalar(key bool) (object.Object, error) {
+	if c := f.s[f.i]; c == '"' || c == '\'' {
+		s, n, err := f.p.decodeQuoted(f.s[f.i:])
+		if err != nil {
+			return nil, err
+		}
+		f.i += n
+		return &object.String{Value: s}, nil
+	}
+	start := f.i
+	for f.i < len(f.s) {
+		c := f.s[f.i]
+		if c == ',' || c == ']' || c == '}' {
+			break
+		}
+		if key && c == ':' && (f.i+1 == len(f.s) || strings.ContainsRune(" ,}]", rune(f.s[f.i+1]))) {
+			break
+		}
+		f.i++
+	}
+	return yamlPlain(strings.TrimSpace(f.s[start:f.i])), nil
+}
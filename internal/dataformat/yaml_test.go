@@ -0,0 +1,99 @@
+package dataformat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	src := `%YAML 1.2
+---
+# service config
+name: demo   # trailing comment
+version: 3
+ratio: .5
+debug: ~
+enabled: yes
+tags: [web, "api, v2", {tier: 1}]
+"quoted key": 'it''s'
+servers:
+  - host: a.example
+    port: 80
+  - host: b.example
+    port: 0x1F
+matrix:
+- - 1
+  - 2
+- []
+notes: |
+  line one
+    indented
+  line three
+summary: >-
+  folded
+  text
+
+  next para
+wrapped: plain text
+  continues here
+empty:
+...
+`
+	v, err := ParseYAML(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `#{"debug": nil, "empty": nil, "enabled": yes, "matrix": [[1, 2], []], "name": demo, "notes": line one
+  indented
+line three
+, "quoted key": it's, "ratio": 0.5, "servers": [#{"host": a.example, "port": 80}, #{"host": b.example, "port": 31}], "summary": folded text
+next para, "tags": [web, api, v2, #{"tier": 1}], "version": 3, "wrapped": plain text continues here}`
+	if got := v.Inspect(); got != want {
+		t.Fatalf("unexpected result:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestParseYAMLScalars(t *testing.T) {
+	tests := map[string]string{
+		"":                      "nil",
+		"42":                    "42",
+		"-1.5e3":                "-1500",
+		"true":                  "true",
+		".inf":                  "+Inf",
+		`"a\tb\u00e9"`:          "a\tbé",
+		"- x\n- |+\n  kept\n\n": "[x, kept\n\n]",
+		"--- plain":             "plain",
+	}
+	for src, want := range tests {
+		v, err := ParseYAML(src)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", src, err)
+			continue
+		}
+		if got := v.Inspect(); got != want {
+			t.Errorf("%q: got %q, want %q", src, got, want)
+		}
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"a: 1\na: 2\n", `yaml: line 2: duplicate key "a"`},
+		{"a: 1\n  b: 2\n", "mapping values are not allowed here"},
+		{"a:\n  - 1\n   - 2\n", "unexpected indentation"},
+		{"a: &x 1\n", "anchors, aliases and tags are not supported"},
+		{"a: 1\n---\nb: 2\n", "yaml: line 2: multiple documents are not supported"},
+		{"a: [1, 2\n", "unterminated flow collection"},
+		{"a: \"open\n", "unterminated string"},
+		{"- a\nb: 1\n", "unexpected indentation"},
+	}
+	for _, tt := range tests {
+		_, err := ParseYAML(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: expected error containing %q, got %v", tt.src, tt.want, err)
+		}
+	}
+}
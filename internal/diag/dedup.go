@@ -0,0 +1,87 @@
+package diag
+
+import "sort"
+
+// Dedup merges diagnostics that describe the same problem and sorts the
+// rest by position. Diagnostics come from several passes (parser, linter,
+// compiler) that can each report one mistake:
+//
+//   - a diagnostic with the same code at the same position as an earlier one
+//     is dropped, and its related locations are added to the one kept;
+//   - after the first parse error on a line, later parse errors on that line
+//     are dropped, since they follow from the first;
+//   - a warning or note whose range overlaps an error on the same line is
+//     dropped in favor of the error.
+//
+// ds is not modified.
+func Dedup(ds []Diagnostic) []Diagnostic {
+	sorted := append([]Diagnostic(nil), ds...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Range, sorted[j].Range
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+
+	type key struct {
+		line, col int
+		code      string
+	}
+	kept := map[key]int{}
+	parseErrLines := map[int]bool{}
+	out := make([]Diagnostic, 0, len(sorted))
+	for _, d := range sorted {
+		k := key{d.Range.Line, d.Range.Col, d.Code}
+		if i, ok := kept[k]; ok {
+			out[i].Related = mergeRelated(out[i].Related, d.Related)
+			continue
+		}
+		if d.Code == "WP0001" {
+			if parseErrLines[d.Range.Line] {
+				continue
+			}
+			parseErrLines[d.Range.Line] = true
+		}
+		kept[k] = len(out)
+		out = append(out, d)
+	}
+
+	final := make([]Diagnostic, 0, len(out))
+	for _, d := range out {
+		if d.Severity != SeverityError && overlapsError(d, out) {
+			continue
+		}
+		final = append(final, d)
+	}
+	return final
+}
+
+func overlapsError(d Diagnostic, ds []Diagnostic) bool {
+	for _, e := range ds {
+		if e.Severity == SeverityError && e.Range.Line == d.Range.Line && overlaps(e.Range, d.Range) {
+			return true
+		}
+	}
+	return false
+}
+
+func overlaps(a, b Range) bool {
+	return a.Col < b.Col+max(b.Length, 1) && b.Col < a.Col+max(a.Length, 1)
+}
+
+func mergeRelated(into, more []Related) []Related {
+	for _, r := range more {
+		dup := false
+		for _, have := range into {
+			if have == r {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			into = append(into, r)
+		}
+	}
+	return into
+}
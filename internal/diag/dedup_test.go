@@ -0,0 +1,63 @@
+package diag
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDedupMergesSameCodeAndPosition(t *testing.T) {
+	note := Related{Range: Range{Line: 1, Col: 1, Length: 1}, Message: "declared here"}
+	ds := []Diagnostic{
+		{Code: "WL0001", Severity: SeverityWarning, Range: Range{Line: 2, Col: 3, Length: 1}},
+		{Code: "WL0004", Severity: SeverityWarning, Range: Range{Line: 2, Col: 3, Length: 1}},
+		{Code: "WL0001", Severity: SeverityWarning, Range: Range{Line: 2, Col: 3, Length: 1}, Related: []Related{note}},
+		{Code: "WL0003", Severity: SeverityWarning, Range: Range{Line: 1, Col: 1, Length: 1}},
+	}
+	got := Dedup(ds)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 diagnostics, got %#v", got)
+	}
+	if got[0].Code != "WL0003" || got[1].Code != "WL0001" || got[2].Code != "WL0004" {
+		t.Fatalf("unexpected order: %#v", got)
+	}
+	if len(got[1].Related) != 1 || got[1].Related[0] != note {
+		t.Fatalf("expected related location to be merged, got %#v", got[1].Related)
+	}
+	if ds[0].Related != nil {
+		t.Fatalf("input was modified")
+	}
+}
+
+func TestDedupDropsCascadesAndWarningsUnderErrors(t *testing.T) {
+	ds := []Diagnostic{
+		{Code: "WP0001", Severity: SeverityError, Message: "expected (", Range: Range{Line: 2, Col: 6, Length: 1}},
+		{Code: "WP0001", Severity: SeverityError, Message: "no prefix parse function", Range: Range{Line: 2, Col: 8, Length: 1}},
+		{Code: "WL0001", Severity: SeverityWarning, Range: Range{Line: 2, Col: 4, Length: 3}},
+		{Code: "WL0001", Severity: SeverityWarning, Range: Range{Line: 2, Col: 10, Length: 1}},
+		{Code: "WP0001", Severity: SeverityError, Message: "other line", Range: Range{Line: 3, Col: 1, Length: 1}},
+	}
+	got := Dedup(ds)
+	want := []string{"2:6 WP0001", "2:10 WL0001", "3:1 WP0001"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %#v", want, got)
+	}
+	for i, d := range got {
+		if s := fmt.Sprintf("%d:%d %s", d.Range.Line, d.Range.Col, d.Code); s != want[i] {
+			t.Fatalf("diagnostic %d: expected %s, got %s", i, want[i], s)
+		}
+	}
+}
+
+func TestFormatRelated(t *testing.T) {
+	d := Diagnostic{
+		Code:     "WL0014",
+		Message:  "cannot redeclare 'y' in this scope",
+		Severity: SeverityError,
+		Range:    Range{Line: 4, Col: 3, Length: 1},
+		Related:  []Related{{Range: Range{Line: 3, Col: 3, Length: 1}, Message: "previous declaration here"}},
+	}
+	want := "m.wll:4:3: error WL0014: cannot redeclare 'y' in this scope\nm.wll:3:3: note: previous declaration here"
+	if got := d.Format("m.wll"); got != want {
+		t.Fatalf("unexpected format:\n%s", got)
+	}
+}
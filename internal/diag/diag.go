@@ -27,16 +27,35 @@ type Range struct {
 	Length int // best-effort; can be 1 if unknown
 }
 
+// Related points at another place in the same file that explains a
+// diagnostic, such as the declaration a redeclaration clashes with.
+type Related struct {
+	Range   Range
+	Message string
+}
+
 type Diagnostic struct {
 	Code     string
 	Message  string
 	Severity Severity
 	Range    Range
+	Related  []Related
+	// Deprecated marks a use of a deprecated function, which editors
+	// strike through.
+	Deprecated bool
 }
 
+// Format renders d as `path:line:col: severity CODE: message`, followed by
+// one `path:line:col: note: message` line per related location.
 func (d Diagnostic) Format(path string) string {
+	var out string
 	if d.Code != "" {
-		return fmt.Sprintf("%s:%d:%d: %s %s: %s", path, d.Range.Line, d.Range.Col, d.Severity.String(), d.Code, d.Message)
+		out = fmt.Sprintf("%s:%d:%d: %s %s: %s", path, d.Range.Line, d.Range.Col, d.Severity.String(), d.Code, d.Message)
+	} else {
+		out = fmt.Sprintf("%s:%d:%d: %s: %s", path, d.Range.Line, d.Range.Col, d.Severity.String(), d.Message)
+	}
+	for _, r := range d.Related {
+		out += fmt.Sprintf("\n%s:%d:%d: note: %s", path, r.Range.Line, r.Range.Col, r.Message)
 	}
-	return fmt.Sprintf("%s:%d:%d: %s: %s", path, d.Range.Line, d.Range.Col, d.Severity.String(), d.Message)
+	return out
 }
@@ -0,0 +1,462 @@
+package docs
+
+import "sort"
+
+// Builtin documents one builtin function for help(), `welle doc` and the
+// language server.
+type Builtin struct {
+	Name      string
+	Signature string
+	Doc       string
+	Params    []string
+	// Deprecated, when set, is the hint lint and the language server give
+	// for calls to the builtin, such as "use reverse()".
+	Deprecated string
+}
+
+// FullDoc returns Doc followed by the deprecation note, if any.
+func (b Builtin) FullDoc() string {
+	if b.Deprecated == "" {
+		return b.Doc
+	}
+	return withDeprecation(b.Doc, b.Deprecated)
+}
+
+var builtinDocs = map[string]Builtin{
+	"print": {
+		Name:      "print",
+		Signature: "print(...args) -> nil",
+		Doc:       "Prints Inspect() of each argument.",
+		Params:    []string{"...args"},
+	},
+	"len": {
+		Name:      "len",
+		Signature: "len(x) -> int",
+		Doc:       "Supports string, array, and dict; wrong type or arg count is an error.",
+		Params:    []string{"x"},
+	},
+	"str": {
+		Name:      "str",
+		Signature: "str(x) -> string",
+		Doc:       "Converts a value to string.",
+		Params:    []string{"x"},
+	},
+	"join": {
+		Name:      "join",
+		Signature: "join(array, sep) -> string",
+		Doc:       "Joins an array of strings with a separator.",
+		Params:    []string{"array", "sep"},
+	},
+	"keys": {
+		Name:      "keys",
+		Signature: "keys(dict) -> [key]",
+		Doc:       "Returns keys sorted by internal hash-key string.",
+		Params:    []string{"dict"},
+	},
+	"values": {
+		Name:      "values",
+		Signature: "values(dict) -> [value]",
+		Doc:       "Returns values sorted by the same order as keys().",
+		Params:    []string{"dict"},
+	},
+	"range": {
+		Name:      "range",
+		Signature: "range(n) | range(start, end) | range(start, end, step) -> range",
+		Doc:       "Returns the ints from start to end (exclusive) as a range, which makes each int only as a loop reaches it. Other builtins get the array it stands for; .to_list() makes one.",
+		Params:    []string{"n|start", "end?", "step?"},
+	},
+	"append": {
+		Name:      "append",
+		Signature: "append(array, value) -> [any]",
+		Doc:       "Returns a new array; errors if first arg is not array.",
+		Params:    []string{"array", "value"},
+	},
+	"push": {
+		Name:      "push",
+		Signature: "push(array, value) -> [any]",
+		Doc:       "Alias of append.",
+		Params:    []string{"array", "value"},
+	},
+	"count": {
+		Name:      "count",
+		Signature: "count(array, value) -> int",
+		Doc:       "Counts occurrences using ==; errors if equality comparison errors.",
+		Params:    []string{"array", "value"},
+	},
+	"remove": {
+		Name:      "remove",
+		Signature: "remove(array, value) -> bool",
+		Doc:       "Removes first matching element and returns true/false.",
+		Params:    []string{"array", "value"},
+	},
+	"get": {
+		Name:      "get",
+		Signature: "get(dict, key, default?) -> any",
+		Doc:       "Returns value if present; otherwise default or nil.",
+		Params:    []string{"dict", "key", "default?"},
+	},
+	"pop": {
+		Name:      "pop",
+		Signature: "pop(array) -> any | pop(dict, key, default?) -> any",
+		Doc:       "Array pop removes last element; dict pop removes by key.",
+		Params:    []string{"array|dict", "key?", "default?"},
+	},
+	"hasKey": {
+		Name:      "hasKey",
+		Signature: "hasKey(dict, key) -> bool",
+		Doc:       "Returns true if dict has key.",
+		Params:    []string{"dict", "key"},
+	},
+	"sort": {
+		Name:      "sort",
+		Signature: "sort(array, comparator?) -> [any]",
+		Doc:       "Returns a new sorted array. Without a comparator, supports all-int or all-string arrays only; comparator(a, b) returns a negative int or true when a comes first. Stable.",
+		Params:    []string{"array", "comparator?"},
+	},
+	"sort_by": {
+		Name:      "sort_by",
+		Signature: "sort_by(array, keyFn) -> [any]",
+		Doc:       "Returns a new array stably sorted by keyFn(element); keys must all be numbers or all strings.",
+		Params:    []string{"array", "keyFn"},
+	},
+	"unique": {
+		Name:      "unique",
+		Signature: "unique(array) -> [any]",
+		Doc:       "Returns a new array keeping the first occurrence of each distinct element.",
+		Params:    []string{"array"},
+	},
+	"chunk": {
+		Name:      "chunk",
+		Signature: "chunk(array, n) -> [[any]]",
+		Doc:       "Splits array into runs of n elements; the last run holds the rest.",
+		Params:    []string{"array", "n"},
+	},
+	"windows": {
+		Name:      "windows",
+		Signature: "windows(array, n) -> [[any]]",
+		Doc:       "Returns every run of n consecutive elements, in order; none if array is shorter than n.",
+		Params:    []string{"array", "n"},
+	},
+	"flatten": {
+		Name:      "flatten",
+		Signature: "flatten(array, depth?) -> [any]",
+		Doc:       "Returns a new array with nested arrays spliced in, depth levels deep (default 1).",
+		Params:    []string{"array", "depth"},
+	},
+	"group_by": {
+		Name:      "group_by",
+		Signature: "group_by(array, keyFn) -> dict",
+		Doc:       "Returns a dict from each keyFn(element) to the elements with that key, in array order.",
+		Params:    []string{"array", "keyFn"},
+	},
+	"set": {
+		Name:      "set",
+		Signature: "set(array?) -> set",
+		Doc:       "Returns a new set of array's elements (strings, ints and bools), or an empty set.",
+		Params:    []string{"array"},
+	},
+	"seq": {
+		Name:      "seq",
+		Signature: "seq(array) -> seq",
+		Doc:       "Returns a lazy pipeline over array; chain map(fn), filter(fn) and take(n), then to_list() or iterate it.",
+		Params:    []string{"array"},
+	},
+	"stopwatch": {
+		Name:      "stopwatch",
+		Signature: "stopwatch() -> stopwatch",
+		Doc:       "Starts a monotonic timer; elapsed_ms() is the time since the start, lap() the time since the last lap, both as float ms.",
+		Params:    []string{},
+	},
+	"time_it": {
+		Name:      "time_it",
+		Signature: "time_it(fn, n) -> (best: float, avg: float, runs: int)",
+		Doc:       "Calls fn() n times and returns the fastest and average run in ms.",
+		Params:    []string{"fn", "n"},
+	},
+	"heap_dump": {
+		Name:      "heap_dump",
+		Signature: "heap_dump() -> dict",
+		Doc:       "Snapshots what the caller's variables reach: objects and bytes in total and per type, budget_used and budget_limit, and the largest arrays and dicts with their path and, under --heap-dump, the site that made them.",
+		Params:    []string{},
+	},
+	"max": {
+		Name:      "max",
+		Signature: "max(array) -> number|string",
+		Doc:       "Returns max element; supports all-number (int/float) or all-string arrays.",
+		Params:    []string{"array"},
+	},
+	"abs": {
+		Name:      "abs",
+		Signature: "abs(x) -> number",
+		Doc:       "Absolute value of int or float.",
+		Params:    []string{"x"},
+	},
+	"sum": {
+		Name:      "sum",
+		Signature: "sum(array) -> number",
+		Doc:       "Sums numeric elements; empty array returns 0.",
+		Params:    []string{"array"},
+	},
+	"reverse": {
+		Name:      "reverse",
+		Signature: "reverse(array|string) -> array|string",
+		Doc:       "Returns a new reversed array or string.",
+		Params:    []string{"array|string"},
+	},
+	"locals": {
+		Name:      "locals",
+		Signature: "locals() -> dict",
+		Doc:       "Snapshot of the current function's parameters and variables (the module's globals at top level).",
+		Params:    []string{},
+	},
+	"globals": {
+		Name:      "globals",
+		Signature: "globals() -> dict",
+		Doc:       "Snapshot of the current module's top-level bindings.",
+		Params:    []string{},
+	},
+	"dir": {
+		Name:      "dir",
+		Signature: "dir(module?) -> [string]",
+		Doc:       "Sorted names exported by a module (or keys of a dict); without an argument, the names in locals().",
+		Params:    []string{"module?"},
+	},
+	"trace": {
+		Name:      "trace",
+		Signature: "trace(on) -> bool",
+		Doc:       "Turns execution tracing to stderr (or the -trace-out file) on or off; returns whether it was on.",
+		Params:    []string{"on"},
+	},
+	"args": {
+		Name:      "args",
+		Signature: "args() -> [string]",
+		Doc:       "Command-line arguments passed after the script (`welle run tool.wll a b`).",
+		Params:    []string{},
+	},
+	"reversed": {
+		Name:      "reversed",
+		Signature: "reversed(array|string) -> array|string",
+		Doc:       "Alias of reverse.",
+		Params:    []string{"array|string"},
+	},
+	"any": {
+		Name:      "any",
+		Signature: "any(array) -> bool",
+		Doc:       "True if any element is truthy (only false/nil are falsy).",
+		Params:    []string{"array"},
+	},
+	"all": {
+		Name:      "all",
+		Signature: "all(array) -> bool",
+		Doc:       "True if all elements are truthy; empty array returns true.",
+		Params:    []string{"array"},
+	},
+	"error": {
+		Name:      "error",
+		Signature: "error(message, code?, data?) -> Error",
+		Doc:       "Constructs an error object without throwing; code is an integer or a string, data a dict of details.",
+		Params:    []string{"message", "code?", "data?"},
+	},
+	"writeFile": {
+		Name:      "writeFile",
+		Signature: "writeFile(path, content) -> nil",
+		Doc:       "Writes a string to disk; errors if path/content are not strings or write fails.",
+		Params:    []string{"path", "content"},
+	},
+	"sqrt": {
+		Name:      "sqrt",
+		Signature: "sqrt(x) -> float",
+		Doc:       "Square root; same behavior as math_sqrt.",
+		Params:    []string{"x"},
+	},
+	"input": {
+		Name:      "input",
+		Signature: "input(prompt?) -> string",
+		Doc:       "Reads a line from stdin; errors in non-interactive mode.",
+		Params:    []string{"prompt?"},
+	},
+	"getpass": {
+		Name:      "getpass",
+		Signature: "getpass(prompt?) -> string",
+		Doc:       "Reads a line from stdin without echo when possible; errors in non-interactive mode.",
+		Params:    []string{"prompt?"},
+	},
+	"group_digits": {
+		Name:      "group_digits",
+		Signature: "group_digits(x, sep=\",\", group=3) -> string",
+		Doc:       "Groups integer digits from the right. x may be int or digit string with optional underscores.",
+		Params:    []string{"x", "sep?", "group?"},
+	},
+	"format_float": {
+		Name:      "format_float",
+		Signature: "format_float(x, decimals) -> string",
+		Doc:       "Formats a number with fixed decimals and deterministic rounding.",
+		Params:    []string{"x", "decimals"},
+	},
+	"format_percent": {
+		Name:      "format_percent",
+		Signature: "format_percent(x, decimals) -> string",
+		Doc:       "Formats x*100 with decimals and appends '%'.",
+		Params:    []string{"x", "decimals"},
+	},
+	"checked_add": {
+		Name:      "checked_add",
+		Signature: "checked_add(a, b) -> (int, bool)",
+		Doc:       "Computes a + b; ok is false on 64-bit overflow, with the wrapped value.",
+		Params:    []string{"a", "b"},
+	},
+	"checked_sub": {
+		Name:      "checked_sub",
+		Signature: "checked_sub(a, b) -> (int, bool)",
+		Doc:       "Computes a - b; ok is false on 64-bit overflow, with the wrapped value.",
+		Params:    []string{"a", "b"},
+	},
+	"checked_mul": {
+		Name:      "checked_mul",
+		Signature: "checked_mul(a, b) -> (int, bool)",
+		Doc:       "Computes a * b; ok is false on 64-bit overflow, with the wrapped value.",
+		Params:    []string{"a", "b"},
+	},
+	"floor_div": {
+		Name:      "floor_div",
+		Signature: "floor_div(a, b) -> int",
+		Doc:       "Integer division rounding toward negative infinity; errors when b is 0.",
+		Params:    []string{"a", "b"},
+	},
+	"floor_mod": {
+		Name:      "floor_mod",
+		Signature: "floor_mod(a, b) -> int",
+		Doc:       "Remainder with the sign of b, matching floor_div; errors when b is 0.",
+		Params:    []string{"a", "b"},
+	},
+	"round": {
+		Name:      "round",
+		Signature: "round(x, n?) -> number",
+		Doc:       "Rounds half away from zero; without n returns an int, with n keeps x's type.",
+		Params:    []string{"x", "n?"},
+	},
+	"floor": {
+		Name:      "floor",
+		Signature: "floor(x) -> int",
+		Doc:       "Largest integer <= x; errors on NaN, Inf or out-of-range values.",
+		Params:    []string{"x"},
+	},
+	"ceil": {
+		Name:      "ceil",
+		Signature: "ceil(x) -> int",
+		Doc:       "Smallest integer >= x; errors on NaN, Inf or out-of-range values.",
+		Params:    []string{"x"},
+	},
+	"trunc": {
+		Name:      "trunc",
+		Signature: "trunc(x) -> int",
+		Doc:       "Drops the fractional part of x (rounds toward zero).",
+		Params:    []string{"x"},
+	},
+	"is_nan": {
+		Name:      "is_nan",
+		Signature: "is_nan(x) -> bool",
+		Doc:       "True if x is a float NaN.",
+		Params:    []string{"x"},
+	},
+	"is_inf": {
+		Name:      "is_inf",
+		Signature: "is_inf(x) -> bool",
+		Doc:       "True if x is a float +Inf or -Inf.",
+		Params:    []string{"x"},
+	},
+	"approx_eq": {
+		Name:      "approx_eq",
+		Signature: "approx_eq(a, b, eps) -> bool",
+		Doc:       "True if a and b differ by at most eps; NaN never matches.",
+		Params:    []string{"a", "b", "eps"},
+	},
+	"map": {
+		Name:      "map",
+		Signature: "map(fn, array) -> [any]",
+		Doc:       "Applies fn to each element, left to right, and returns a new array.",
+		Params:    []string{"fn", "array"},
+	},
+	"mean": {
+		Name:      "mean",
+		Signature: "mean(array) -> number",
+		Doc:       "Arithmetic mean of the numeric elements; an int when all inputs are ints and the mean is whole. Empty arrays are an error.",
+		Params:    []string{"array"},
+	},
+	"error_code": {
+		Name:      "error_code",
+		Signature: "error_code(x) -> int",
+		Doc:       "The code of an error value, or nil when x is not an error.",
+		Params:    []string{"x"},
+	},
+	"pp": {
+		Name:      "pp",
+		Signature: "pp(x, opts?) -> nil",
+		Doc:       "Pretty-prints x with one element per line. opts may set depth, length and indent.",
+		Params:    []string{"x", "opts?"},
+	},
+	"set_print_options": {
+		Name:      "set_print_options",
+		Signature: "set_print_options(opts) -> dict",
+		Doc:       "Sets float_precision, max_items and max_depth for print, str and the REPL; returns the previous options.",
+		Params:    []string{"opts"},
+	},
+	"unicode_normalize": {
+		Name:      "unicode_normalize",
+		Signature: "unicode_normalize(s, form) -> string",
+		Doc:       "Returns s in Unicode normalization form \"NFC\" or \"NFD\".",
+		Params:    []string{"s", "form"},
+	},
+	"math_floor": {
+		Name:      "math_floor",
+		Signature: "math_floor(x) -> int",
+		Doc:       "Returns the floor of a number.",
+		Params:    []string{"x"},
+	},
+	"math_sqrt": {
+		Name:      "math_sqrt",
+		Signature: "math_sqrt(x) -> float",
+		Doc:       "Returns the square root.",
+		Params:    []string{"x"},
+	},
+	"math_sin": {
+		Name:      "math_sin",
+		Signature: "math_sin(x) -> float",
+		Doc:       "Returns the sine (radians).",
+		Params:    []string{"x"},
+	},
+	"math_cos": {
+		Name:      "math_cos",
+		Signature: "math_cos(x) -> float",
+		Doc:       "Returns the cosine (radians).",
+		Params:    []string{"x"},
+	},
+	"help": {
+		Name:      "help",
+		Signature: "help(x) -> nil",
+		Doc:       "Prints the signature and documentation of a builtin or function, or of a name such as \"map\", \"std:math\" or \"std:math.add\".",
+		Params:    []string{"x"},
+	},
+	"is_main": {
+		Name:      "is_main",
+		Signature: "is_main() -> bool",
+		Doc:       "Whether the calling code belongs to the entry file rather than a module it imported.",
+		Params:    []string{},
+	},
+}
+
+// LookupBuiltin returns the documentation of the builtin called name.
+func LookupBuiltin(name string) (Builtin, bool) {
+	b, ok := builtinDocs[name]
+	return b, ok
+}
+
+// BuiltinNames lists the documented builtins, sorted.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtinDocs))
+	for name := range builtinDocs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,191 @@
+// Package docs holds the documentation help() and `welle doc` print: the
+// builtin table below and the doc comments of module functions, which are
+// the `//` lines directly above a function's declaration.
+package docs
+
+import (
+	"fmt"
+	"strings"
+
+	"welle/internal/ast"
+	"welle/internal/lexer"
+	"welle/internal/parser"
+	"welle/std"
+)
+
+// Entry is the documentation of one name a module defines.
+type Entry struct {
+	Name      string
+	Signature string
+	Doc       string
+}
+
+// Format writes a signature and its doc as help() and `welle doc` print
+// them: the signature, then the doc indented by four spaces.
+func Format(signature, doc string) string {
+	if doc == "" {
+		doc = "No documentation."
+	}
+	var b strings.Builder
+	b.WriteString(signature + "\n")
+	for _, line := range strings.Split(doc, "\n") {
+		b.WriteString("    " + line + "\n")
+	}
+	return b.String()
+}
+
+// Exports lists the names the module source src exports, in source order.
+func Exports(src string) []Entry {
+	var out []Entry
+	for _, e := range entries(src) {
+		if e.exported {
+			out = append(out, e.Entry)
+		}
+	}
+	return out
+}
+
+// Func returns the entry of the top-level function called name in src.
+func Func(src, name string) (Entry, bool) {
+	for _, e := range entries(src) {
+		if e.Name == name && e.fn {
+			return e.Entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+type entry struct {
+	Entry
+	exported bool
+	fn       bool
+}
+
+// entries reads the top-level declarations of src.
+func entries(src string) []entry {
+	prog := parser.New(lexer.New(src)).ParseProgram()
+	lines := strings.Split(src, "\n")
+	var out []entry
+	var add func(st ast.Statement, line int, exported bool)
+	add = func(st ast.Statement, line int, exported bool) {
+		e := entry{exported: exported}
+		deprecated, hint := false, ""
+		switch n := st.(type) {
+		case *ast.FuncStatement:
+			e.Name, e.fn = n.Name.Value, true
+			e.Signature = signature(n.Name.Value, n.Parameters, n.Defaults)
+			if n.Deprecated != nil {
+				// The doc comment sits above the annotation.
+				deprecated, hint = true, n.DeprecatedMsg
+				line = min(line, n.Deprecated.Line)
+			}
+		case *ast.ClassStatement:
+			e.Name, e.fn = n.Name.Value, true
+			if init := n.Method(ast.InitName); init != nil {
+				e.Signature = signature(n.Name.Value, init.Parameters, init.Defaults)
+			} else {
+				e.Signature = signature(n.Name.Value, n.Fields, n.Defaults)
+			}
+		case *ast.AssignStatement:
+			e.Name, e.Signature = n.Name.Value, n.Name.Value
+			if fl, ok := n.Value.(*ast.FunctionLiteral); ok {
+				e.fn = true
+				e.Signature = signature(n.Name.Value, fl.Parameters, fl.Defaults)
+			}
+		case *ast.ExportStatement:
+			add(n.Stmt, line, true)
+			return
+		default:
+			return
+		}
+		e.Doc = commentAbove(lines, line)
+		if deprecated {
+			e.Doc = withDeprecation(e.Doc, hint)
+		}
+		out = append(out, e)
+	}
+	for _, st := range prog.Statements {
+		toks := ast.Tokens(st)
+		if len(toks) == 0 {
+			continue
+		}
+		add(st, toks[0].Line, false)
+	}
+	return out
+}
+
+func signature(name string, params []*ast.Identifier, defaults []ast.Expression) string {
+	names := make([]string, len(params))
+	for i, p := range params {
+		names[i] = p.String()
+		if d := ast.ParamDefault(defaults, i); d != nil {
+			names[i] += " = " + d.String()
+		}
+	}
+	return name + "(" + strings.Join(names, ", ") + ")"
+}
+
+// withDeprecation appends the note that marks a deprecated function, with
+// its hint if it has one, to doc.
+func withDeprecation(doc, hint string) string {
+	note := "Deprecated."
+	if hint != "" {
+		note = "Deprecated: " + hint
+	}
+	if doc == "" {
+		return note
+	}
+	return doc + "\n" + note
+}
+
+// commentAbove returns the `//` comment block ending on the line before
+// line (1-based), without the slashes.
+func commentAbove(lines []string, line int) string {
+	var doc []string
+	for i := line - 2; i >= 0; i-- {
+		text := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(text, "//") {
+			break
+		}
+		text = strings.TrimPrefix(text, "//")
+		doc = append([]string{strings.TrimPrefix(text, " ")}, doc...)
+	}
+	return strings.Join(doc, "\n")
+}
+
+// Lookup returns the help text for a symbol: a builtin name ("map"), a
+// std module ("std:math", which lists its exports) or one of its exports
+// ("std:math.add").
+func Lookup(symbol string) (string, error) {
+	if !strings.HasPrefix(symbol, "std:") {
+		b, ok := LookupBuiltin(symbol)
+		if !ok {
+			return "", fmt.Errorf("no documentation for %q", symbol)
+		}
+		return Format(b.Signature, b.FullDoc()), nil
+	}
+	mod, member, _ := strings.Cut(strings.TrimPrefix(symbol, "std:"), ".")
+	src, err := std.FS.ReadFile(mod + ".wll")
+	if err != nil {
+		return "", fmt.Errorf("unknown module std:%s", mod)
+	}
+	exports := Exports(string(src))
+	if member == "" {
+		var b strings.Builder
+		b.WriteString("std:" + mod + "\n")
+		for _, e := range exports {
+			summary, _, _ := strings.Cut(e.Doc, "\n")
+			if summary != "" {
+				summary = "  " + summary
+			}
+			b.WriteString("    " + e.Signature + summary + "\n")
+		}
+		return b.String(), nil
+	}
+	for _, e := range exports {
+		if e.Name == member {
+			return Format(e.Signature, e.Doc), nil
+		}
+	}
+	return "", fmt.Errorf("std:%s has no export %q", mod, member)
+}
@@ -0,0 +1,70 @@
+package docs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportsReadDocComments(t *testing.T) {
+	src := "// Not attached.\n" +
+		"\n" +
+		"// Adds a and b.\n" +
+		"//   Indented.\n" +
+		"export func add(a, b) { return a + b }\n" +
+		"func hidden() { return 1 }\n" +
+		"export twice = func(x) { return x * 2 }\n" +
+		"// The answer.\n" +
+		"export answer = 42\n" +
+		"// Adds one.\n" +
+		"@deprecated(\"use add()\")\n" +
+		"export func inc(x) { return x + 1 }\n"
+	want := []Entry{
+		{Name: "add", Signature: "add(a, b)", Doc: "Adds a and b.\n  Indented."},
+		{Name: "twice", Signature: "twice(x)"},
+		{Name: "answer", Signature: "answer", Doc: "The answer."},
+		{Name: "inc", Signature: "inc(x)", Doc: "Adds one.\nDeprecated: use add()"},
+	}
+	if got := Exports(src); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Exports = %+v, want %+v", got, want)
+	}
+	if _, ok := Func(src, "answer"); ok {
+		t.Fatalf("Func found a value that is not a function")
+	}
+	if e, ok := Func(src, "hidden"); !ok || e.Signature != "hidden()" {
+		t.Fatalf("Func(hidden) = %+v, %v", e, ok)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	// No shipped builtin is deprecated; stale stands in for one.
+	builtinDocs["stale"] = Builtin{Name: "stale", Signature: "stale(x) -> any", Doc: "Old spelling of fresh.", Deprecated: "use fresh()"}
+	defer delete(builtinDocs, "stale")
+
+	tests := []struct {
+		symbol string
+		want   string
+		err    string
+	}{
+		{symbol: "map", want: "map(fn, array) -> [any]\n    Applies fn"},
+		{symbol: "reversed", want: "reversed(array|string) -> array|string\n    Alias of reverse.\n"},
+		{symbol: "stale", want: "stale(x) -> any\n    Old spelling of fresh.\n    Deprecated: use fresh()\n"},
+		{symbol: "std:math", want: "std:math\n    add(a, b)  Returns a + b.\n"},
+		{symbol: "std:math.sqrt", want: "sqrt(x)\n    Returns the square root of x as a float.\n"},
+		{symbol: "nope", err: `no documentation for "nope"`},
+		{symbol: "std:nope", err: "unknown module std:nope"},
+		{symbol: "std:math.nope", err: `std:math has no export "nope"`},
+	}
+	for _, tt := range tests {
+		got, err := Lookup(tt.symbol)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("Lookup(%q) error = %v, want %q", tt.symbol, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !strings.HasPrefix(got, tt.want) {
+			t.Errorf("Lookup(%q) = %q, %v; want prefix %q", tt.symbol, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Package errcode is the catalog of codes the runtime puts on the errors it
+// raises, so scripts can branch on the kind of failure in a catch block
+// (`errors.is(e, errors.INDEX_OUT_OF_RANGE)` with std:errors) instead of
+// matching messages. Both backends assign codes from the same table, and
+// std/errors.wll mirrors it for scripts.
+//
+// Errors a script creates itself, with throw or error(), keep the code they
+// were given (0 by default) even when their message looks like one below.
+package errcode
+
+import (
+	"strings"
+
+	"welle/internal/limits"
+)
+
+const (
+	TypeMismatch    int64 = 1001
+	IndexOutOfRange int64 = 1002
+	KeyNotFound     int64 = 1003
+	DivisionByZero  int64 = 1004
+	UnknownMember   int64 = 1005
+	NotCallable     int64 = 1006
+	WrongArgCount   int64 = 1007
+	UnknownName     int64 = 1008
+	AssertionFailed int64 = 1009
+	Redeclared      int64 = 1010
+	Timeout         int64 = 1011
+
+	MemoryLimit          = limits.MemoryErrorCode
+	Interrupted          = limits.InterruptCode
+	StepLimit            = limits.StepsErrorCode
+	RecursionLimit int64 = 8004
+)
+
+// TimeoutMessage is the message of the error raised when a with_timeout
+// deadline (std:flow) passes.
+const TimeoutMessage = "timed out"
+
+// Entry is one catalog code with the name std:errors exports it under.
+type Entry struct {
+	Code int64
+	Name string
+}
+
+// Catalog lists every code in ascending order.
+var Catalog = []Entry{
+	{TypeMismatch, "TYPE_MISMATCH"},
+	{IndexOutOfRange, "INDEX_OUT_OF_RANGE"},
+	{KeyNotFound, "KEY_NOT_FOUND"},
+	{DivisionByZero, "DIVISION_BY_ZERO"},
+	{UnknownMember, "UNKNOWN_MEMBER"},
+	{NotCallable, "NOT_CALLABLE"},
+	{WrongArgCount, "WRONG_ARG_COUNT"},
+	{UnknownName, "UNKNOWN_NAME"},
+	{AssertionFailed, "ASSERTION_FAILED"},
+	{Redeclared, "REDECLARED"},
+	{Timeout, "TIMEOUT"},
+	{MemoryLimit, "MEMORY_LIMIT"},
+	{Interrupted, "INTERRUPTED"},
+	{StepLimit, "STEP_LIMIT"},
+	{RecursionLimit, "RECURSION_LIMIT"},
+}
+
+// prefixes maps the start of a runtime error message to its code. The
+// interpreter, the VM, internal/semantics and the builtins share these
+// messages, which is what keeps the codes the same on both backends.
+var prefixes = []struct {
+	prefix string
+	code   int64
+}{
+	{"index out of range", IndexOutOfRange},
+	{"key not found", KeyNotFound},
+	{"division by zero", DivisionByZero},
+	{"modulo by zero", DivisionByZero},
+	{"unknown member", UnknownMember},
+	{"named tuple has no field", UnknownMember},
+	{"attempted to call non-function", NotCallable},
+	{"wrong number of arguments", WrongArgCount},
+	{"unknown identifier", UnknownName},
+	{"assertion failed", AssertionFailed},
+	{"cannot redeclare", Redeclared},
+	{"max recursion depth exceeded", RecursionLimit},
+	{"type mismatch", TypeMismatch},
+	{"unsupported operand", TypeMismatch},
+	{"invalid operand", TypeMismatch},
+	{"unknown operator", TypeMismatch},
+	{"invalid operator for nil", TypeMismatch},
+	{"cannot compare", TypeMismatch},
+	{"unusable as dict key", TypeMismatch},
+	{"unusable as set element", TypeMismatch},
+	{"indexing not supported", TypeMismatch},
+	{"slicing not supported", TypeMismatch},
+	{"no member access", TypeMismatch},
+	{"member access not supported", TypeMismatch},
+	{"member assignment not supported", TypeMismatch},
+	{"index assignment not supported", TypeMismatch},
+	{"slice assignment not supported", TypeMismatch},
+	{"slice assignment value must be", TypeMismatch},
+	{"del not supported", TypeMismatch},
+	{"cannot iterate", TypeMismatch},
+	{"cannot unpack", TypeMismatch},
+	{"unpack by name expects", TypeMismatch},
+	{"cannot spread", TypeMismatch},
+}
+
+// Classify returns the code for a runtime error message, or 0 when the
+// message is not in the catalog. Index and slice bounds of the wrong type
+// ("array index must be INTEGER, got STRING") count as type mismatches.
+func Classify(msg string) int64 {
+	for _, p := range prefixes {
+		if strings.HasPrefix(msg, p.prefix) {
+			return p.code
+		}
+	}
+	if strings.Contains(msg, " must be INTEGER") {
+		return TypeMismatch
+	}
+	return 0
+}
+
+// Name returns the catalog name of code, or "" if it has none.
+func Name(code int64) string {
+	for _, e := range Catalog {
+		if e.Code == code {
+			return e.Name
+		}
+	}
+	return ""
+}
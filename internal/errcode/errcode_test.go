@@ -0,0 +1,55 @@
+package errcode
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want int64
+	}{
+		{"index out of range: 5", IndexOutOfRange},
+		{"key not found", KeyNotFound},
+		{"division by zero", DivisionByZero},
+		{"modulo by zero", DivisionByZero},
+		{"unsupported operand types: STRING + INTEGER", TypeMismatch},
+		{"array index must be INTEGER, got STRING", TypeMismatch},
+		{"wrong number of arguments: expected 1, got 2", WrongArgCount},
+		{"assertion failed: x > 0", AssertionFailed},
+		{"max recursion depth exceeded (100)", RecursionLimit},
+		{"boom", 0},
+		{"the index out of range", 0},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.msg); got != tt.want {
+			t.Errorf("Classify(%q) = %d, want %d", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestStdErrorsMatchesCatalog(t *testing.T) {
+	src, err := os.ReadFile("../../std/errors.wll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported := map[string]int64{}
+	for _, m := range regexp.MustCompile(`(?m)^export ([A-Z_]+) = (\d+)$`).FindAllStringSubmatch(string(src), -1) {
+		n, _ := strconv.ParseInt(m[2], 10, 64)
+		exported[m[1]] = n
+	}
+	if len(exported) != len(Catalog) {
+		t.Fatalf("std/errors.wll exports %d codes, catalog has %d", len(exported), len(Catalog))
+	}
+	for _, e := range Catalog {
+		if got, ok := exported[e.Name]; !ok || got != e.Code {
+			t.Errorf("std/errors.wll %s = %d (present %v), want %d", e.Name, got, ok, e.Code)
+		}
+		if Name(e.Code) != e.Name {
+			t.Errorf("Name(%d) = %q, want %q", e.Code, Name(e.Code), e.Name)
+		}
+	}
+}
@@ -1,11 +1,23 @@
 package evaluator
 
-import "welle/internal/limits"
+import (
+	"time"
+
+	"welle/internal/limits"
+	"welle/internal/object"
+	"welle/internal/trace"
+)
 
 type RuntimeContext struct {
 	File   string
 	Stack  []stackFrame
 	Budget *limits.Budget
+	Stats  *limits.Stats
+	Tracer *trace.Tracer
+	// Deadline is set while a with_timeout call runs (see evalHost).
+	Deadline time.Time
+	// TraceLocals adds each function's parameter values to stack traces.
+	TraceLocals bool
 }
 
 var ctx = &RuntimeContext{}
@@ -15,4 +27,8 @@ type stackFrame struct {
 	File string
 	Line int
 	Col  int
+	// Fn and Env are the function called and its scope, recorded only
+	// when ctx.TraceLocals is on so traces can show the parameters.
+	Fn  *object.Function
+	Env *object.Environment
 }
@@ -18,7 +18,7 @@ func dictValue(t *testing.T, obj object.Object, key string) object.Object {
 	if !ok {
 		t.Fatalf("invalid key %q", key)
 	}
-	pair, ok := d.Pairs[object.HashKeyString(hk)]
+	pair, ok := d.Pairs[hk]
 	if !ok {
 		t.Fatalf("missing key %q", key)
 	}
@@ -135,3 +135,31 @@ stack`
 		t.Fatalf("expected stack to mention anonymous function name, got %q", strObj.Value)
 	}
 }
+
+func TestInterpreterTraceLocals(t *testing.T) {
+	input := `stack = ""
+func add(a, b) { return a + b }
+func outer(xs) {
+  try { add(xs[0], "s") } catch (e) { stack = e.stack }
+}
+outer([1, 2])
+stack`
+	runner := NewRunner()
+	runner.SetTraceLocals(true)
+	got := testEvalWithRunner(t, input, runner)
+	strObj, ok := got.(*object.String)
+	if !ok {
+		t.Fatalf("expected stack to be string, got %T", got)
+	}
+	for _, want := range []string{`at add (<unknown>:2:27) a = 1, b = "s"`, `at outer (<unknown>:4:12) xs = [1, 2]`, "at <main> (<unknown>:6:6)\n"} {
+		if !strings.Contains(strObj.Value, want) {
+			t.Fatalf("expected %q in stack, got:\n%s", want, strObj.Value)
+		}
+	}
+
+	NewRunner()
+	got = testEval(t, input)
+	if strObj, ok := got.(*object.String); !ok || strings.Contains(strObj.Value, "a = 1") {
+		t.Fatalf("expected no parameters without trace locals, got %v", got)
+	}
+}
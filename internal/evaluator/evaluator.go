@@ -2,13 +2,13 @@ package evaluator
 
 import (
 	"fmt"
-	"math"
 	"path/filepath"
-	"strconv"
 	"strings"
-	"unicode/utf8"
 
 	"welle/internal/ast"
+	"welle/internal/builtins"
+	"welle/internal/errcode"
+	"welle/internal/heapdump"
 	"welle/internal/object"
 	"welle/internal/semantics"
 	"welle/internal/token"
@@ -239,16 +239,37 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 			}
 			return evalIndexAssign(left, base, index, val)
 
+		case *ast.SliceExpression:
+			base, low, high, step, errObj := evalSliceOperands(left, env, r, loopDepth, switchDepth)
+			if errObj != nil {
+				return errObj
+			}
+			val := eval(n.Value, env, r, loopDepth, switchDepth)
+			if isError(val) {
+				return val
+			}
+			return evalSliceAssign(left.Token, base, low, high, step, val)
+
 		case *ast.MemberExpression:
 			obj := eval(left.Object, env, r, loopDepth, switchDepth)
 			if isError(obj) {
 				return obj
 			}
 
+			if setter, ok := obj.(object.MemberSetter); ok {
+				val := eval(n.Value, env, r, loopDepth, switchDepth)
+				if isError(val) || isReturn(val) {
+					return val
+				}
+				return evalMemberSet(n.Token, obj, setter, left.Property.Value, n.Op, val)
+			}
 			d, ok := obj.(*object.Dict)
 			if !ok {
 				return newErrorAt(n.Token, "member assignment not supported on type: "+string(obj.Type()))
 			}
+			if d.Frozen {
+				return newErrorAt(n.Token, semantics.ReadOnlyExportsMessage)
+			}
 			key := &object.String{Value: left.Property.Value}
 			hk, ok := object.HashKeyOf(key)
 			if !ok {
@@ -256,7 +277,7 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 			}
 
 			if n.Op != "" && n.Op != token.ASSIGN {
-				pair, ok := d.Pairs[object.HashKeyString(hk)]
+				pair, ok := d.Pairs[hk]
 				if !ok {
 					return newErrorAt(n.Token, "unknown member: "+left.Property.Value)
 				}
@@ -273,9 +294,9 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 						return res
 					}
 					if d.Pairs == nil {
-						d.Pairs = map[string]object.DictPair{}
+						d.Pairs = map[object.HashKey]object.DictPair{}
 					}
-					d.Pairs[object.HashKeyString(hk)] = object.DictPair{Key: key, Value: res}
+					d.Pairs[hk] = object.DictPair{Key: key, Value: res}
 					return res
 				}
 				opStr, ok := compoundAssignOp(n.Op)
@@ -287,9 +308,9 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 					return newErrorAt(n.Token, err.Error())
 				}
 				if d.Pairs == nil {
-					d.Pairs = map[string]object.DictPair{}
+					d.Pairs = map[object.HashKey]object.DictPair{}
 				}
-				d.Pairs[object.HashKeyString(hk)] = object.DictPair{Key: key, Value: res}
+				d.Pairs[hk] = object.DictPair{Key: key, Value: res}
 				return res
 			}
 
@@ -298,15 +319,14 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 				return val
 			}
 			if d.Pairs == nil {
-				d.Pairs = map[string]object.DictPair{}
+				d.Pairs = map[object.HashKey]object.DictPair{}
 			}
-			keyStr := object.HashKeyString(hk)
-			if _, exists := d.Pairs[keyStr]; !exists {
-				if errObj := chargeMemoryAt(n.Token, object.CostDictEntry()); errObj != nil {
+			if _, exists := d.Pairs[hk]; !exists {
+				if errObj := chargeAllocAt(n.Token, "dict", object.CostDictEntry()); errObj != nil {
 					return errObj
 				}
 			}
-			d.Pairs[keyStr] = object.DictPair{Key: key, Value: val}
+			d.Pairs[hk] = object.DictPair{Key: key, Value: val}
 			return val
 
 		default:
@@ -324,6 +344,9 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 		if isReturn(val) {
 			return val
 		}
+		if len(n.Targets) > 0 && n.Targets[0].Field != nil {
+			return evalDestructureFields(n, val, env)
+		}
 		starIdx := -1
 		for i, t := range n.Targets {
 			if t != nil && t.Star {
@@ -345,6 +368,11 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 			elems = v.Elements
 		case *object.Array:
 			elems = v.Elements
+		case *object.Range:
+			if errObj := chargeAllocAt(n.Token, "array", object.CostArray(int(v.Len()))); errObj != nil {
+				return errObj
+			}
+			elems = v.Array().Elements
 		default:
 			if starIdx >= 0 {
 				return newErrorAt(n.Token, "cannot unpack non-sequence")
@@ -407,7 +435,7 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 		for i := midStart; i < midEnd; i++ {
 			mid = append(mid, elems[i])
 		}
-		if errObj := chargeMemoryAt(n.Token, object.CostArray(len(mid))); errObj != nil {
+		if errObj := chargeAllocAt(n.Token, "array", object.CostArray(len(mid))); errObj != nil {
 			return errObj
 		}
 		midArr := &object.Array{Elements: mid}
@@ -420,6 +448,17 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 		return val
 
 	case *ast.IndexAssignStatement:
+		if se, ok := n.Left.(*ast.SliceExpression); ok {
+			left, low, high, step, errObj := evalSliceOperands(se, env, r, loopDepth, switchDepth)
+			if errObj != nil {
+				return errObj
+			}
+			val := eval(n.Value, env, r, loopDepth, switchDepth)
+			if isError(val) {
+				return val
+			}
+			return evalSliceAssign(se.Token, left, low, high, step, val)
+		}
 		idx, ok := n.Left.(*ast.IndexExpression)
 		if !ok {
 			return newErrorAt(n.Token, "index assignment expects index expression on left")
@@ -475,10 +514,20 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 			return obj
 		}
 
+		if setter, ok := obj.(object.MemberSetter); ok {
+			val := eval(n.Value, env, r, loopDepth, switchDepth)
+			if isError(val) || isReturn(val) {
+				return val
+			}
+			return evalMemberSet(n.Token, obj, setter, n.Property.Value, n.Op, val)
+		}
 		d, ok := obj.(*object.Dict)
 		if !ok {
 			return newErrorAt(n.Token, "member assignment not supported on type: "+string(obj.Type()))
 		}
+		if d.Frozen {
+			return newErrorAt(n.Token, semantics.ReadOnlyExportsMessage)
+		}
 		key := &object.String{Value: n.Property.Value}
 		hk, ok := object.HashKeyOf(key)
 		if !ok {
@@ -486,7 +535,7 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 		}
 
 		if n.Op != "" && n.Op != token.ASSIGN {
-			pair, ok := d.Pairs[object.HashKeyString(hk)]
+			pair, ok := d.Pairs[hk]
 			if !ok {
 				return newErrorAt(n.Token, "unknown member: "+n.Property.Value)
 			}
@@ -503,9 +552,9 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 					return res
 				}
 				if d.Pairs == nil {
-					d.Pairs = map[string]object.DictPair{}
+					d.Pairs = map[object.HashKey]object.DictPair{}
 				}
-				d.Pairs[object.HashKeyString(hk)] = object.DictPair{Key: key, Value: res}
+				d.Pairs[hk] = object.DictPair{Key: key, Value: res}
 				return res
 			}
 			opStr, ok := compoundAssignOp(n.Op)
@@ -517,9 +566,9 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 				return newErrorAt(n.Token, err.Error())
 			}
 			if d.Pairs == nil {
-				d.Pairs = map[string]object.DictPair{}
+				d.Pairs = map[object.HashKey]object.DictPair{}
 			}
-			d.Pairs[object.HashKeyString(hk)] = object.DictPair{Key: key, Value: res}
+			d.Pairs[hk] = object.DictPair{Key: key, Value: res}
 			return res
 		}
 
@@ -528,9 +577,9 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 			return val
 		}
 		if d.Pairs == nil {
-			d.Pairs = map[string]object.DictPair{}
+			d.Pairs = map[object.HashKey]object.DictPair{}
 		}
-		d.Pairs[object.HashKeyString(hk)] = object.DictPair{Key: key, Value: val}
+		d.Pairs[hk] = object.DictPair{Key: key, Value: val}
 		return val
 
 	case *ast.ExportStatement:
@@ -543,11 +592,26 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 			env.MarkExport(s.Name.Value)
 		case *ast.FuncStatement:
 			env.MarkExport(s.Name.Value)
+		case *ast.ClassStatement:
+			env.MarkExport(s.Name.Value)
 		default:
-			return newErrorAt(n.Token, "export supports only function declarations and assignments (v0.1)")
+			return newErrorAt(n.Token, "export supports only function and class declarations and assignments (v0.1)")
 		}
 		return res
 
+	case *ast.YieldStatement:
+		var val object.Object = NIL
+		if n.Value != nil {
+			val = eval(n.Value, env, r, loopDepth, switchDepth)
+			if isError(val) {
+				return val
+			}
+		}
+		if runningGen == nil {
+			return newErrorAt(n.Token, "yield outside generator")
+		}
+		return runningGen.yield(val)
+
 	case *ast.ReturnStatement:
 		switch len(n.ReturnValues) {
 		case 0:
@@ -587,6 +651,31 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 		fr.defers = append(fr.defers, n.Call)
 		return NIL
 
+	case *ast.DelStatement:
+		switch t := n.Target.(type) {
+		case *ast.IndexExpression:
+			left := eval(t.Left, env, r, loopDepth, switchDepth)
+			if isError(left) {
+				return left
+			}
+			index := eval(t.Index, env, r, loopDepth, switchDepth)
+			if isError(index) {
+				return index
+			}
+			if err := semantics.Delete(left, index); err != nil {
+				return newErrorAt(t.Token, err.Error())
+			}
+			return NIL
+		case *ast.SliceExpression:
+			left, low, high, step, errObj := evalSliceOperands(t, env, r, loopDepth, switchDepth)
+			if errObj != nil {
+				return errObj
+			}
+			return evalDelSlice(t.Token, left, low, high, step)
+		default:
+			return newErrorAt(n.Token, "del expects an index or slice expression")
+		}
+
 	case *ast.ThrowStatement:
 		val := eval(n.Value, env, r, loopDepth, switchDepth)
 		if isError(val) {
@@ -594,6 +683,27 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 		}
 		return wrapThrownValue(n.Token, val)
 
+	case *ast.AssertStatement:
+		if r != nil && r.release {
+			return NIL
+		}
+		cond := eval(n.Condition, env, r, loopDepth, switchDepth)
+		if isError(cond) {
+			return cond
+		}
+		if isTruthy(cond) {
+			return NIL
+		}
+		msg := n.FailureMessage()
+		if n.Message != nil {
+			val := eval(n.Message, env, r, loopDepth, switchDepth)
+			if isError(val) {
+				return val
+			}
+			msg += ": " + val.Inspect()
+		}
+		return newErrorCodeAt(n.Token, errcode.AssertionFailed, msg)
+
 	case *ast.BreakStatement:
 		if loopDepth == 0 && switchDepth == 0 {
 			return newErrorAt(n.Token, "break used outside of a loop or switch")
@@ -628,28 +738,41 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 		return evalSwitchStatement(n, env, r, loopDepth, switchDepth)
 
 	case *ast.FuncStatement:
+		defaults, errObj := evalDefaults(n.Defaults, env, r, loopDepth, switchDepth)
+		if errObj != nil {
+			return errObj
+		}
 		fn := &object.Function{
 			Name:       n.Name.Value,
 			File:       ctx.File,
 			Parameters: n.Parameters,
+			Defaults:   defaults,
 			Body:       n.Body,
 			Env:        env,
 		}
-		if errObj := chargeMemoryAt(n.Token, object.CostFunction()); errObj != nil {
+		if errObj := chargeAllocAt(n.Token, "function", object.CostFunction()); errObj != nil {
 			return errObj
 		}
 		env.Set(n.Name.Value, fn)
 		return fn
 
+	case *ast.ClassStatement:
+		return evalClassStatement(n, env, r, loopDepth, switchDepth)
+
 	case *ast.FunctionLiteral:
+		defaults, errObj := evalDefaults(n.Defaults, env, r, loopDepth, switchDepth)
+		if errObj != nil {
+			return errObj
+		}
 		fn := &object.Function{
 			Name:       ast.AnonymousFuncName(n.Token),
 			File:       ctx.File,
 			Parameters: n.Parameters,
+			Defaults:   defaults,
 			Body:       n.Body,
 			Env:        env,
 		}
-		if errObj := chargeMemoryAt(n.Token, object.CostFunction()); errObj != nil {
+		if errObj := chargeAllocAt(n.Token, "function", object.CostFunction()); errObj != nil {
 			return errObj
 		}
 		return fn
@@ -699,7 +822,7 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 
 	case *ast.StringLiteral:
 		out := &object.String{Value: n.Value}
-		if errObj := chargeMemoryAt(n.Token, object.CostStringBytes(len(out.Value))); errObj != nil {
+		if errObj := chargeAllocAt(n.Token, "string", object.CostStringBytes(len(out.Value))); errObj != nil {
 			return errObj
 		}
 		return out
@@ -714,20 +837,23 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 		return NIL
 
 	case *ast.ListLiteral:
-		els := evalExpressions(n.Elements, env, r, loopDepth, switchDepth)
+		els := evalSpreadable(n.Elements, "array literal", env, r, loopDepth, switchDepth)
 		if len(els) == 1 && isError(els[0]) {
 			return els[0]
 		}
-		if errObj := chargeMemoryAt(n.Token, object.CostArray(len(els))); errObj != nil {
+		if errObj := chargeAllocAt(n.Token, "array", object.CostArray(len(els))); errObj != nil {
 			return errObj
 		}
-		return &object.Array{Elements: els}
+		return noteSite(n.Token, &object.Array{Elements: els})
 
 	case *ast.ListComprehension:
 		seq := eval(n.Seq, env, r, loopDepth, switchDepth)
 		if isError(seq) {
 			return seq
 		}
+		if set, ok := seq.(*object.Set); ok {
+			seq = &object.Array{Elements: object.SortedSetItems(set)}
+		}
 		compEnv := object.NewEnclosedEnvironment(env)
 		out := []object.Object{}
 		appendElem := func(el object.Object) object.Object {
@@ -777,7 +903,7 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 			rs := []rune(s.Value)
 			for _, rch := range rs {
 				strObj := &object.String{Value: string(rch)}
-				if errObj := chargeMemoryAt(n.Token, object.CostStringBytes(len(strObj.Value))); errObj != nil {
+				if errObj := chargeAllocAt(n.Token, "string", object.CostStringBytes(len(strObj.Value))); errObj != nil {
 					return errObj
 				}
 				compEnv.Set(n.Var.Value, strObj)
@@ -796,28 +922,131 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 				}
 				appendElem(val)
 			}
+		case *object.Seq:
+			h := &evalHost{tok: n.Token, r: r}
+			cur := semantics.NewSeqCursor(s)
+			for {
+				el, ok, err := cur.Next(h.Call)
+				if err != nil {
+					return h.raised
+				}
+				if !ok {
+					break
+				}
+				compEnv.Set(n.Var.Value, el)
+				if n.Filter != nil {
+					cond := eval(n.Filter, compEnv, r, loopDepth, switchDepth)
+					if isError(cond) {
+						return cond
+					}
+					if !isTruthy(cond) {
+						continue
+					}
+				}
+				val := eval(n.Elem, compEnv, r, loopDepth, switchDepth)
+				if isError(val) {
+					return val
+				}
+				appendElem(val)
+			}
+		case *object.Range:
+			for i := range s.Len() {
+				if errObj := chargeAllocAt(n.Token, "int", object.CostRangeStep()); errObj != nil {
+					return errObj
+				}
+				compEnv.Set(n.Var.Value, &object.Integer{Value: s.At(i)})
+				if n.Filter != nil {
+					cond := eval(n.Filter, compEnv, r, loopDepth, switchDepth)
+					if isError(cond) {
+						return cond
+					}
+					if !isTruthy(cond) {
+						continue
+					}
+				}
+				val := eval(n.Elem, compEnv, r, loopDepth, switchDepth)
+				if isError(val) {
+					return val
+				}
+				appendElem(val)
+			}
+		case *generator:
+			for {
+				el, ok, errObj := s.next(n.Token, r)
+				if errObj != nil {
+					return errObj
+				}
+				if !ok {
+					break
+				}
+				compEnv.Set(n.Var.Value, el)
+				if n.Filter != nil {
+					cond := eval(n.Filter, compEnv, r, loopDepth, switchDepth)
+					if isError(cond) {
+						return cond
+					}
+					if !isTruthy(cond) {
+						continue
+					}
+				}
+				val := eval(n.Elem, compEnv, r, loopDepth, switchDepth)
+				if isError(val) {
+					return val
+				}
+				appendElem(val)
+			}
 		default:
 			return newErrorAt(n.Token, "cannot iterate "+string(seq.Type())+" in comprehension")
 		}
 
-		if errObj := chargeMemoryAt(n.Token, object.CostArray(len(out))); errObj != nil {
+		if errObj := chargeAllocAt(n.Token, "array", object.CostArray(len(out))); errObj != nil {
 			return errObj
 		}
-		return &object.Array{Elements: out}
+		return noteSite(n.Token, &object.Array{Elements: out})
 
 	case *ast.TupleLiteral:
 		els := evalExpressions(n.Elements, env, r, loopDepth, switchDepth)
 		if len(els) == 1 && isError(els[0]) {
 			return els[0]
 		}
-		if errObj := chargeMemoryAt(n.Token, object.CostTuple(len(els))); errObj != nil {
+		if errObj := chargeAllocAt(n.Token, "tuple", object.CostTuple(len(els))); errObj != nil {
 			return errObj
 		}
 		return &object.Tuple{Elements: els}
 
+	case *ast.NamedTupleLiteral:
+		els := make([]object.Object, len(n.Fields))
+		names := make([]string, len(n.Fields))
+		for i, f := range n.Fields {
+			v := eval(f.Value, env, r, loopDepth, switchDepth)
+			if isError(v) {
+				return v
+			}
+			els[i] = v
+			names[i] = f.Name.Value
+		}
+		if errObj := chargeAllocAt(n.Token, "tuple", object.CostTuple(len(els))); errObj != nil {
+			return errObj
+		}
+		return &object.Tuple{Elements: els, Names: names}
+
 	case *ast.DictLiteral:
 		return evalDictLiteral(n, env, r, loopDepth, switchDepth)
 
+	case *ast.SetLiteral:
+		els := evalExpressions(n.Elements, env, r, loopDepth, switchDepth)
+		if len(els) == 1 && isError(els[0]) {
+			return els[0]
+		}
+		set, err := semantics.SetOf(els)
+		if err != nil {
+			return newErrorAt(n.Token, err.Error())
+		}
+		if errObj := chargeAllocAt(n.Token, "set", object.CostSet(len(set.Items))); errObj != nil {
+			return errObj
+		}
+		return set
+
 	case *ast.IndexExpression:
 		left := eval(n.Left, env, r, loopDepth, switchDepth)
 		if isError(left) {
@@ -830,30 +1059,9 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 		return evalIndexExpression(n.Token, left, idx)
 
 	case *ast.SliceExpression:
-		left := eval(n.Left, env, r, loopDepth, switchDepth)
-		if isError(left) {
-			return left
-		}
-		var lowObj object.Object
-		var highObj object.Object
-		var stepObj object.Object
-		if n.Low != nil {
-			lowObj = eval(n.Low, env, r, loopDepth, switchDepth)
-			if isError(lowObj) {
-				return lowObj
-			}
-		}
-		if n.High != nil {
-			highObj = eval(n.High, env, r, loopDepth, switchDepth)
-			if isError(highObj) {
-				return highObj
-			}
-		}
-		if n.Step != nil {
-			stepObj = eval(n.Step, env, r, loopDepth, switchDepth)
-			if isError(stepObj) {
-				return stepObj
-			}
+		left, lowObj, highObj, stepObj, errObj := evalSliceOperands(n, env, r, loopDepth, switchDepth)
+		if errObj != nil {
+			return errObj
 		}
 		return evalSliceExpression(n.Token, left, lowObj, highObj, stepObj)
 
@@ -930,6 +1138,12 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 		return eval(n.Else, env, r, loopDepth, switchDepth)
 
 	case *ast.MemberExpression:
+		// A BUILD the program binds shadows the build flags.
+		if name, ok := ast.BuildFlag(n); ok {
+			if _, bound := env.Get(ast.BuildName); !bound {
+				return nativeBool(r != nil && r.features[name])
+			}
+		}
 		obj := eval(n.Object, env, r, loopDepth, switchDepth)
 		if isError(obj) {
 			return obj
@@ -938,7 +1152,7 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 		if d, ok := obj.(*object.Dict); ok {
 			key := &object.String{Value: n.Property.Value}
 			hk, _ := object.HashKeyOf(key)
-			pair, ok := d.Pairs[object.HashKeyString(hk)]
+			pair, ok := d.Pairs[hk]
 			if !ok {
 				return newErrorAt(n.Token, "unknown member: "+n.Property.Value)
 			}
@@ -964,14 +1178,23 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 			if len(args) == 1 && isError(args[0]) {
 				return args[0]
 			}
+			if inst, ok := recv.(*object.Instance); ok {
+				if i, ok := inst.Class.FieldIndex(me.Property.Value); ok {
+					return applyFunction(n.Token, inst.Fields[i], args, r)
+				}
+				if m, ok := inst.Class.Methods[me.Property.Value]; ok {
+					return callMethod(n.Token, inst, m, args, r)
+				}
+				return newErrorAt(n.Token, inst.Class.Name+" has no method "+me.Property.Value)
+			}
 			if d, ok := recv.(*object.Dict); ok {
 				key := &object.String{Value: me.Property.Value}
 				hk, _ := object.HashKeyOf(key)
-				if pair, exists := d.Pairs[object.HashKeyString(hk)]; exists {
+				if pair, exists := d.Pairs[hk]; exists {
 					return applyFunction(n.Token, pair.Value, args, r)
 				}
 			}
-			return applyMethod(n.Token, recv, me.Property.Value, args)
+			return applyMethod(n.Token, recv, me.Property.Value, args, r)
 		}
 
 		fn := eval(n.Function, env, r, loopDepth, switchDepth)
@@ -982,6 +1205,9 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
+		if b, ok := fn.(*object.Builtin); ok {
+			return applyBuiltin(n.Token, b, args, r, env)
+		}
 		return applyFunction(n.Token, fn, args, r)
 	}
 
@@ -992,6 +1218,11 @@ func eval(node ast.Node, env *object.Environment, r *Runner, loopDepth int, swit
 func evalProgram(p *ast.Program, env *object.Environment, r *Runner, loopDepth int, switchDepth int) object.Object {
 	var result object.Object = NIL
 	for _, stmt := range p.Statements {
+		if errObj := checkPending(statementToken(stmt)); errObj != nil {
+			return errObj
+		}
+		traceStatement(stmt)
+		countStatement()
 		result = eval(stmt, env, r, loopDepth, switchDepth)
 		if rv, ok := result.(*object.ReturnValue); ok {
 			return rv.Value
@@ -1004,8 +1235,15 @@ func evalProgram(p *ast.Program, env *object.Environment, r *Runner, loopDepth i
 }
 
 func evalBlock(b *ast.BlockStatement, env *object.Environment, r *Runner, loopDepth int, switchDepth int) object.Object {
+	// Loop bodies and function bodies are blocks, so checking on entry
+	// reaches every iteration and call.
+	if errObj := checkPending(b.Token); errObj != nil {
+		return errObj
+	}
 	var result object.Object = NIL
 	for _, stmt := range b.Statements {
+		traceStatement(stmt)
+		countStatement()
 		result = eval(stmt, env, r, loopDepth, switchDepth)
 		if result != nil {
 			switch result.Type() {
@@ -1019,12 +1257,25 @@ func evalBlock(b *ast.BlockStatement, env *object.Environment, r *Runner, loopDe
 
 func evalTry(n *ast.TryStatement, env *object.Environment, r *Runner, loopDepth int, switchDepth int) object.Object {
 	res := eval(n.TryBlock, env, r, loopDepth, switchDepth)
-	if isError(res) && n.CatchBlock != nil {
+	handled := isError(res) && n.CatchBlock != nil && !isInterrupt(res)
+	if handled && n.CatchFilter != nil {
+		// An error the filter does not accept goes on to the enclosing
+		// handler, after the finally block; one from the filter replaces it.
+		filter := eval(n.CatchFilter, env, r, loopDepth, switchDepth)
+		if isError(filter) {
+			res, handled = filter, false
+		} else {
+			handled = semantics.CatchMatches(res, filter)
+		}
+	}
+	if handled {
 		catchEnv := object.NewEnclosedEnvironment(env)
 		if errObj, ok := res.(*object.Error); ok {
 			catchEnv.Set(n.CatchName.Value, &object.Error{
 				Message: errObj.Message,
 				Code:    errObj.Code,
+				Tag:     errObj.Tag,
+				Data:    errObj.Data,
 				Stack:   errObj.Stack,
 				IsValue: true,
 			})
@@ -1089,6 +1340,13 @@ func evalForIn(s *ast.ForInStatement, env *object.Environment, r *Runner, loopDe
 	if isError(iterable) {
 		return iterable
 	}
+	if set, ok := iterable.(*object.Set); ok {
+		if s.Destruct {
+			return newErrorAt(s.Token, "for-in destructuring requires dict, got SET")
+		}
+		// A set is iterated in the order dict keys are.
+		iterable = &object.Array{Elements: object.SortedSetItems(set)}
+	}
 
 	switch it := iterable.(type) {
 	case *object.Array:
@@ -1122,7 +1380,7 @@ func evalForIn(s *ast.ForInStatement, env *object.Environment, r *Runner, loopDe
 		rs := []rune(it.Value)
 		for _, rch := range rs {
 			strObj := &object.String{Value: string(rch)}
-			if errObj := chargeMemoryAt(s.Token, object.CostStringBytes(len(strObj.Value))); errObj != nil {
+			if errObj := chargeAllocAt(s.Token, "string", object.CostStringBytes(len(strObj.Value))); errObj != nil {
 				return errObj
 			}
 			env.Set(s.Var.Value, strObj)
@@ -1172,6 +1430,96 @@ func evalForIn(s *ast.ForInStatement, env *object.Environment, r *Runner, loopDe
 		}
 		return result
 
+	case *object.Seq:
+		if s.Destruct {
+			return newErrorAt(s.Token, "for-in destructuring requires dict, got SEQ")
+		}
+		// The pipeline is pulled one element per iteration, so a break
+		// stops it before any further map or filter call.
+		h := &evalHost{tok: s.Token, r: r}
+		cur := semantics.NewSeqCursor(it)
+		var result object.Object = NIL
+		for {
+			el, ok, err := cur.Next(h.Call)
+			if err != nil {
+				return h.raised
+			}
+			if !ok {
+				return result
+			}
+			env.Set(s.Var.Value, el)
+			result = eval(s.Body, env, r, loopDepth+1, switchDepth)
+			if result != nil && result.Type() == object.RETURN_VALUE_OBJ {
+				return result
+			}
+			if isError(result) {
+				return result
+			}
+			if isBreak(result) {
+				return NIL
+			}
+			if isContinue(result) {
+				continue
+			}
+		}
+
+	case *object.Range:
+		if s.Destruct {
+			return newErrorAt(s.Token, "for-in destructuring requires dict, got RANGE")
+		}
+		// Each element is made, and charged, as the loop reaches it, so a
+		// break leaves the rest of the range unbuilt.
+		var result object.Object = NIL
+		for i := range it.Len() {
+			if errObj := chargeAllocAt(s.Token, "int", object.CostRangeStep()); errObj != nil {
+				return errObj
+			}
+			env.Set(s.Var.Value, &object.Integer{Value: it.At(i)})
+			result = eval(s.Body, env, r, loopDepth+1, switchDepth)
+			if result != nil && result.Type() == object.RETURN_VALUE_OBJ {
+				return result
+			}
+			if isError(result) {
+				return result
+			}
+			if isBreak(result) {
+				return NIL
+			}
+			if isContinue(result) {
+				continue
+			}
+		}
+		return result
+
+	case *generator:
+		if s.Destruct {
+			return newErrorAt(s.Token, "for-in destructuring requires dict, got GENERATOR")
+		}
+		var result object.Object = NIL
+		for {
+			el, ok, errObj := it.next(s.Token, r)
+			if errObj != nil {
+				return errObj
+			}
+			if !ok {
+				return result
+			}
+			env.Set(s.Var.Value, el)
+			result = eval(s.Body, env, r, loopDepth+1, switchDepth)
+			if result != nil && result.Type() == object.RETURN_VALUE_OBJ {
+				return result
+			}
+			if isError(result) {
+				return result
+			}
+			if isBreak(result) {
+				return NIL
+			}
+			if isContinue(result) {
+				continue
+			}
+		}
+
 	default:
 		if s.Destruct {
 			return newErrorAt(s.Token, "for-in destructuring requires dict, got "+string(iterable.Type()))
@@ -1290,25 +1638,30 @@ func evalMatchExpression(n *ast.MatchExpression, env *object.Environment, r *Run
 
 	for _, c := range n.Cases {
 		for _, cond := range c.Values {
-			cv := eval(cond, env, r, loopDepth, switchDepth)
-			if isError(cv) {
-				return cv
+			matched, errObj := matchCaseValue(c.Token, cond, val, env, r, loopDepth, switchDepth)
+			if errObj != nil {
+				return errObj
 			}
-
-			eq := evalInfix(c.Token, "==", val, cv)
-			if isError(eq) {
-				return eq
+			if !matched {
+				continue
 			}
-
-			if isTruthy(eq) {
-				result := eval(c.Result, env, r, loopDepth, switchDepth)
-				if isError(result) {
-					return result
+			if c.Guard != nil {
+				guard := eval(c.Guard, env, r, loopDepth, switchDepth)
+				if isError(guard) {
+					return guard
+				}
+				if !isTruthy(guard) {
+					continue
 				}
-				return result
 			}
-		}
-	}
+
+			result := eval(c.Result, env, r, loopDepth, switchDepth)
+			if isError(result) {
+				return result
+			}
+			return result
+		}
+	}
 
 	if n.Default != nil {
 		result := eval(n.Default, env, r, loopDepth, switchDepth)
@@ -1321,6 +1674,145 @@ func evalMatchExpression(n *ast.MatchExpression, env *object.Environment, r *Run
 	return NIL
 }
 
+// matchCaseValue reports whether val matches one value of a match case. A
+// pattern assigns the names it binds, like `=`, but only once all of it has
+// matched; any other value is compared with ==.
+func matchCaseValue(tok token.Token, cond ast.Expression, val object.Object, env *object.Environment, r *Runner, loopDepth int, switchDepth int) (bool, object.Object) {
+	pat, ok := cond.(*ast.MatchPattern)
+	if !ok {
+		cv := eval(cond, env, r, loopDepth, switchDepth)
+		if isError(cv) {
+			return false, cv
+		}
+		eq := evalInfix(tok, "==", val, cv)
+		if isError(eq) {
+			return false, eq
+		}
+		return isTruthy(eq), nil
+	}
+
+	m := &patternMatch{tok: tok, env: env, r: r, loopDepth: loopDepth, switchDepth: switchDepth}
+	matched, errObj := m.match(pat.Value, val)
+	if errObj != nil || !matched {
+		return false, errObj
+	}
+	for _, b := range m.binds {
+		if _, ok := env.Assign(b.name, b.val); !ok {
+			env.Set(b.name, b.val)
+		}
+	}
+	return true, nil
+}
+
+type patternMatch struct {
+	tok         token.Token
+	env         *object.Environment
+	r           *Runner
+	loopDepth   int
+	switchDepth int
+	binds       []patternBind
+}
+
+type patternBind struct {
+	name string
+	val  object.Object
+}
+
+// match reports whether val matches the pattern part pat, collecting what
+// its names bind. Parts are checked in source order and the first mismatch
+// stops the walk, so the values of later parts are not evaluated.
+func (m *patternMatch) match(pat ast.Expression, val object.Object) (bool, object.Object) {
+	switch p := pat.(type) {
+	case *ast.Identifier:
+		if p.Value != "_" {
+			m.binds = append(m.binds, patternBind{p.Value, val})
+		}
+		return true, nil
+
+	case *ast.TupleLiteral:
+		if !semantics.MatchShape(val, semantics.ShapeTuple, len(p.Elements)) {
+			return false, nil
+		}
+		return m.matchAll(p.Elements, val.(*object.Tuple).Elements)
+
+	case *ast.ListLiteral:
+		rest := -1
+		for i, el := range p.Elements {
+			if _, ok := el.(*ast.SpreadExpression); ok {
+				rest = i
+			}
+		}
+		if rest < 0 {
+			if !semantics.MatchShape(val, semantics.ShapeArray, len(p.Elements)) {
+				return false, nil
+			}
+			return m.matchAll(p.Elements, val.(*object.Array).Elements)
+		}
+		if !semantics.MatchShape(val, semantics.ShapeArrayMin, len(p.Elements)-1) {
+			return false, nil
+		}
+		elems := val.(*object.Array).Elements
+		tail := len(p.Elements) - rest - 1
+		if ok, errObj := m.matchAll(p.Elements[:rest], elems[:rest]); errObj != nil || !ok {
+			return false, errObj
+		}
+		mid := append([]object.Object(nil), elems[rest:len(elems)-tail]...)
+		if errObj := chargeAllocAt(m.tok, "array", object.CostArray(len(mid))); errObj != nil {
+			return false, errObj
+		}
+		if ok, errObj := m.match(p.Elements[rest].(*ast.SpreadExpression).Value, &object.Array{Elements: mid}); errObj != nil || !ok {
+			return false, errObj
+		}
+		return m.matchAll(p.Elements[rest+1:], elems[len(elems)-tail:])
+
+	case *ast.DictLiteral:
+		d, ok := val.(*object.Dict)
+		if !ok {
+			return false, nil
+		}
+		for _, pair := range p.Pairs {
+			var key object.Object
+			sub := pair.Value
+			if pair.Shorthand != nil {
+				key = &object.String{Value: pair.Shorthand.Value}
+				sub = pair.Shorthand
+			} else {
+				key = eval(pair.Key, m.env, m.r, m.loopDepth, m.switchDepth)
+				if isError(key) {
+					return false, key
+				}
+			}
+			hk, ok := object.HashKeyOf(key)
+			if !ok {
+				return false, newErrorAt(m.tok, "unusable as dict key: "+string(key.Type()))
+			}
+			entry, ok := d.Pairs[hk]
+			if !ok {
+				return false, nil
+			}
+			if ok, errObj := m.match(sub, entry.Value); errObj != nil || !ok {
+				return false, errObj
+			}
+		}
+		return true, nil
+	}
+
+	want := eval(pat, m.env, m.r, m.loopDepth, m.switchDepth)
+	if isError(want) {
+		return false, want
+	}
+	return semantics.MatchEqual(val, want), nil
+}
+
+func (m *patternMatch) matchAll(pats []ast.Expression, vals []object.Object) (bool, object.Object) {
+	for i, pat := range pats {
+		if ok, errObj := m.match(pat, vals[i]); errObj != nil || !ok {
+			return false, errObj
+		}
+	}
+	return true, nil
+}
+
 func evalFromImport(n *ast.FromImportStatement, env *object.Environment) object.Object {
 	if importHook == nil || importResolver == nil {
 		return newErrorAt(n.Token, "import not available in this mode")
@@ -1343,7 +1835,7 @@ func evalFromImport(n *ast.FromImportStatement, env *object.Environment) object.
 		name := it.Name.Value
 		key := &object.String{Value: name}
 		hk, _ := object.HashKeyOf(key)
-		pair, ok := mod.Pairs[object.HashKeyString(hk)]
+		pair, ok := mod.Pairs[hk]
 		if !ok {
 			return newErrorAt(n.Token, fmt.Sprintf("missing export %q in module %q", name, n.Path.Value))
 		}
@@ -1361,7 +1853,7 @@ func evalIdentifier(i *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(i.Value); ok {
 		return val
 	}
-	if b, ok := builtins[i.Value]; ok {
+	if b, ok := builtins.Lookup(i.Value); ok {
 		return b
 	}
 	return newErrorAt(i.Token, "unknown identifier: "+i.Value)
@@ -1410,7 +1902,7 @@ func evalInfix(tok token.Token, op string, left, right object.Object) object.Obj
 			return newErrorAt(tok, err.Error())
 		}
 		if s, ok := res.(*object.String); ok {
-			if errObj := chargeMemoryAt(tok, object.CostStringBytes(len(s.Value))); errObj != nil {
+			if errObj := chargeAllocAt(tok, "string", object.CostStringBytes(len(s.Value))); errObj != nil {
 				return errObj
 			}
 		}
@@ -1441,12 +1933,12 @@ func evalTemplateLiteral(n *ast.TemplateLiteral, env *object.Environment, r *Run
 
 		parts := make([]object.Object, len(n.Parts))
 		for i, part := range n.Parts {
-			if errObj := chargeMemoryAt(n.Token, object.CostStringBytes(len(part))); errObj != nil {
+			if errObj := chargeAllocAt(n.Token, "string", object.CostStringBytes(len(part))); errObj != nil {
 				return errObj
 			}
 			parts[i] = &object.String{Value: part}
 		}
-		if errObj := chargeMemoryAt(n.Token, object.CostTuple(len(parts))); errObj != nil {
+		if errObj := chargeAllocAt(n.Token, "tuple", object.CostTuple(len(parts))); errObj != nil {
 			return errObj
 		}
 
@@ -1474,7 +1966,7 @@ func evalTemplateLiteral(n *ast.TemplateLiteral, env *object.Environment, r *Run
 		}
 	}
 	out := b.String()
-	if errObj := chargeMemoryAt(n.Token, object.CostStringBytes(len(out))); errObj != nil {
+	if errObj := chargeAllocAt(n.Token, "string", object.CostStringBytes(len(out))); errObj != nil {
 		return errObj
 	}
 	return &object.String{Value: out}
@@ -1492,7 +1984,71 @@ func evalExpressions(exps []ast.Expression, env *object.Environment, r *Runner,
 	return out
 }
 
+// evalClassStatement builds the class n declares and binds its name. Field
+// defaults are evaluated here, once, like parameter defaults, so every
+// instance starts out sharing the same default values.
+func evalClassStatement(n *ast.ClassStatement, env *object.Environment, r *Runner, loopDepth int, switchDepth int) object.Object {
+	fields := make([]string, len(n.Fields))
+	defaults := make([]object.Object, len(n.Fields))
+	for i, f := range n.Fields {
+		fields[i] = f.Value
+		defaults[i] = NIL
+		if d := ast.ParamDefault(n.Defaults, i); d != nil {
+			val := eval(d, env, r, loopDepth, switchDepth)
+			if isError(val) {
+				return val
+			}
+			defaults[i] = val
+		}
+	}
+	methods := make(map[string]object.Object, len(n.Methods))
+	for _, m := range n.Methods {
+		params, defs := ast.MethodParams(m)
+		mdefaults, errObj := evalDefaults(defs, env, r, loopDepth, switchDepth)
+		if errObj != nil {
+			return errObj
+		}
+		methods[m.Name.Value] = &object.Function{
+			Name:       n.Name.Value + "." + m.Name.Value,
+			File:       ctx.File,
+			Parameters: params,
+			Defaults:   mdefaults,
+			Body:       m.Body,
+			Env:        env,
+		}
+	}
+	if errObj := chargeAllocAt(n.Token, "class", object.CostClass(len(fields), len(methods))); errObj != nil {
+		return errObj
+	}
+	cls := object.NewClass(n.Name.Value, fields, defaults, methods)
+	env.Set(n.Name.Value, cls)
+	return cls
+}
+
+// evalDefaults evaluates a function's parameter defaults, left to right,
+// and returns the values of the parameters that have one.
+func evalDefaults(defaults []ast.Expression, env *object.Environment, r *Runner, loopDepth int, switchDepth int) ([]object.Object, object.Object) {
+	var out []object.Object
+	for _, d := range defaults {
+		if d == nil {
+			continue
+		}
+		val := eval(d, env, r, loopDepth, switchDepth)
+		if isError(val) {
+			return nil, val
+		}
+		out = append(out, val)
+	}
+	return out, nil
+}
+
 func evalCallArguments(exps []ast.Expression, env *object.Environment, r *Runner, loopDepth int, switchDepth int) []object.Object {
+	return evalSpreadable(exps, "call arguments", env, r, loopDepth, switchDepth)
+}
+
+// evalSpreadable evaluates exps, expanding each `...seq` in place. in names
+// the construct for the error on a value that cannot be spread.
+func evalSpreadable(exps []ast.Expression, in string, env *object.Environment, r *Runner, loopDepth int, switchDepth int) []object.Object {
 	out := make([]object.Object, 0, len(exps))
 	for _, e := range exps {
 		if spread, ok := e.(*ast.SpreadExpression); ok {
@@ -1505,8 +2061,13 @@ func evalCallArguments(exps []ast.Expression, env *object.Environment, r *Runner
 				out = append(out, v.Elements...)
 			case *object.Array:
 				out = append(out, v.Elements...)
+			case *object.Range:
+				if errObj := chargeAllocAt(spread.Token, "array", object.CostArrayElements(int(v.Len()))); errObj != nil {
+					return []object.Object{errObj}
+				}
+				out = append(out, v.Array().Elements...)
 			default:
-				return []object.Object{newErrorAt(spread.Token, "cannot spread "+string(value.Type())+" in call arguments")}
+				return []object.Object{newErrorAt(spread.Token, "cannot spread "+string(value.Type())+" in "+in)}
 			}
 			continue
 		}
@@ -1521,8 +2082,22 @@ func evalCallArguments(exps []ast.Expression, env *object.Environment, r *Runner
 }
 
 func evalDictLiteral(n *ast.DictLiteral, env *object.Environment, r *Runner, loopDepth int, switchDepth int) object.Object {
-	pairs := make(map[string]object.DictPair, len(n.Pairs))
+	pairs := make(map[object.HashKey]object.DictPair, len(n.Pairs))
 	for _, pair := range n.Pairs {
+		if pair.Spread != nil {
+			v := eval(pair.Spread.Value, env, r, loopDepth, switchDepth)
+			if isError(v) {
+				return v
+			}
+			src, ok := v.(*object.Dict)
+			if !ok {
+				return newErrorAt(pair.Spread.Token, "cannot spread "+string(v.Type())+" in dict literal")
+			}
+			for hk, p := range src.Pairs {
+				pairs[hk] = p
+			}
+			continue
+		}
 		if pair.Shorthand != nil {
 			key := &object.String{Value: pair.Shorthand.Value}
 			hk, _ := object.HashKeyOf(key)
@@ -1532,7 +2107,7 @@ func evalDictLiteral(n *ast.DictLiteral, env *object.Environment, r *Runner, loo
 				return v
 			}
 
-			pairs[object.HashKeyString(hk)] = object.DictPair{Key: key, Value: v}
+			pairs[hk] = object.DictPair{Key: key, Value: v}
 			continue
 		}
 
@@ -1550,12 +2125,12 @@ func evalDictLiteral(n *ast.DictLiteral, env *object.Environment, r *Runner, loo
 			return v
 		}
 
-		pairs[object.HashKeyString(hk)] = object.DictPair{Key: k, Value: v}
+		pairs[hk] = object.DictPair{Key: k, Value: v}
 	}
-	if errObj := chargeMemoryAt(n.Token, object.CostDict(len(pairs))); errObj != nil {
+	if errObj := chargeAllocAt(n.Token, "dict", object.CostDict(len(pairs))); errObj != nil {
 		return errObj
 	}
-	return &object.Dict{Pairs: pairs}
+	return noteSite(n.Token, &object.Dict{Pairs: pairs})
 }
 
 func evalIndexExpression(tok token.Token, left, index object.Object) object.Object {
@@ -1575,6 +2150,14 @@ func evalIndexExpression(tok token.Token, left, index object.Object) object.Obje
 		return arr.Elements[n]
 	}
 
+	if rng, ok := left.(*object.Range); ok {
+		el, err := semantics.RangeIndex(rng, index)
+		if err != nil {
+			return newErrorAt(tok, err.Error())
+		}
+		return el
+	}
+
 	if tup, ok := left.(*object.Tuple); ok {
 		i, ok := index.(*object.Integer)
 		if !ok {
@@ -1596,7 +2179,7 @@ func evalIndexExpression(tok token.Token, left, index object.Object) object.Obje
 		if !ok {
 			return newErrorAt(tok, "unusable as dict key: "+string(index.Type()))
 		}
-		pair, ok := d.Pairs[object.HashKeyString(hk)]
+		pair, ok := d.Pairs[hk]
 		if !ok {
 			return NIL
 		}
@@ -1609,17 +2192,16 @@ func evalIndexExpression(tok token.Token, left, index object.Object) object.Obje
 			return newErrorAt(tok, "string index must be INTEGER, got: "+string(index.Type()))
 		}
 
-		r := []rune(s.Value)
 		n := int(i.Value)
-		l := len(r)
+		l := s.RuneCount()
 		if n < 0 {
 			n = l + n
 		}
 		if n < 0 || n >= l {
 			return newErrorAt(tok, "index out of range")
 		}
-		out := &object.String{Value: string(r[n])}
-		if errObj := chargeMemoryAt(tok, object.CostStringBytes(len(out.Value))); errObj != nil {
+		out := &object.String{Value: s.RuneAt(n)}
+		if errObj := chargeAllocAt(tok, "string", object.CostStringBytes(len(out.Value))); errObj != nil {
 			return errObj
 		}
 		return out
@@ -1643,24 +2225,27 @@ func evalIndexAssign(idx *ast.IndexExpression, left, index, val object.Object) o
 		if pos < 0 || pos >= length {
 			return newErrorAt(idx.Token, "index out of range")
 		}
+		l.Own()
 		l.Elements[int(pos)] = val
 		return val
 
 	case *object.Dict:
+		if l.Frozen {
+			return newErrorAt(idx.Token, semantics.ReadOnlyExportsMessage)
+		}
 		hk, ok := object.HashKeyOf(index)
 		if !ok {
 			return newErrorAt(idx.Token, "unusable as dict key: "+string(index.Type()))
 		}
 		if l.Pairs == nil {
-			l.Pairs = map[string]object.DictPair{}
+			l.Pairs = map[object.HashKey]object.DictPair{}
 		}
-		keyStr := object.HashKeyString(hk)
-		if _, exists := l.Pairs[keyStr]; !exists {
-			if errObj := chargeMemoryAt(idx.Token, object.CostDictEntry()); errObj != nil {
+		if _, exists := l.Pairs[hk]; !exists {
+			if errObj := chargeAllocAt(idx.Token, "dict", object.CostDictEntry()); errObj != nil {
 				return errObj
 			}
 		}
-		l.Pairs[keyStr] = object.DictPair{Key: index, Value: val}
+		l.Pairs[hk] = object.DictPair{Key: index, Value: val}
 		return val
 
 	case *object.String:
@@ -1671,6 +2256,94 @@ func evalIndexAssign(idx *ast.IndexExpression, left, index, val object.Object) o
 	}
 }
 
+// evalSliceOperands evaluates the sliced value and the bounds of se, left to
+// right. An omitted bound is nil.
+func evalSliceOperands(se *ast.SliceExpression, env *object.Environment, r *Runner, loopDepth int, switchDepth int) (left, low, high, step, errObj object.Object) {
+	left = eval(se.Left, env, r, loopDepth, switchDepth)
+	if isError(left) {
+		return nil, nil, nil, nil, left
+	}
+	if se.Low != nil {
+		low = eval(se.Low, env, r, loopDepth, switchDepth)
+		if isError(low) {
+			return nil, nil, nil, nil, low
+		}
+	}
+	if se.High != nil {
+		high = eval(se.High, env, r, loopDepth, switchDepth)
+		if isError(high) {
+			return nil, nil, nil, nil, high
+		}
+	}
+	if se.Step != nil {
+		step = eval(se.Step, env, r, loopDepth, switchDepth)
+		if isError(step) {
+			return nil, nil, nil, nil, step
+		}
+	}
+	return left, low, high, step, nil
+}
+
+// evalDestructureFields binds the by-name targets of (x: px, y: py) = val.
+func evalDestructureFields(n *ast.DestructureAssignStatement, val object.Object, env *object.Environment) object.Object {
+	names := make([]string, len(n.Targets))
+	for i, t := range n.Targets {
+		names[i] = t.Field.Value
+	}
+	fields, err := semantics.UnpackFields(val, names)
+	if err != nil {
+		return newErrorAt(n.Token, err.Error())
+	}
+	for i, t := range n.Targets {
+		if t.Name.Value == "_" {
+			continue
+		}
+		if _, ok := env.Assign(t.Name.Value, fields[i]); ok {
+			continue
+		}
+		env.Set(t.Name.Value, fields[i])
+	}
+	return val
+}
+
+// evalSliceAssign carries out `left[low:high:step] = val`, charging the
+// memory budget for any elements the array gains before it changes.
+func evalSliceAssign(tok token.Token, left, low, high, step, val object.Object) object.Object {
+	arr, ok := left.(*object.Array)
+	if !ok {
+		return newErrorAt(tok, "slice assignment not supported on type: "+string(left.Type()))
+	}
+	lowPtr, highPtr, stepVal, err := semantics.SliceArgs(low, high, step)
+	if err != nil {
+		return newErrorAt(tok, err.Error())
+	}
+	els, err := semantics.AssignSlice(arr.Elements, lowPtr, highPtr, stepVal, val)
+	if err != nil {
+		return newErrorAt(tok, err.Error())
+	}
+	if grown := len(els) - len(arr.Elements); grown > 0 {
+		if errObj := chargeAllocAt(tok, "array", object.CostArrayElements(grown)); errObj != nil {
+			return errObj
+		}
+	}
+	arr.SetElements(els)
+	return val
+}
+
+// evalDelSlice carries out `del left[low:high:step]`.
+func evalDelSlice(tok token.Token, left, low, high, step object.Object) object.Object {
+	arr, ok := left.(*object.Array)
+	if !ok {
+		return newErrorAt(tok, "del not supported on type: "+string(left.Type()))
+	}
+	lowPtr, highPtr, stepVal, err := semantics.SliceArgs(low, high, step)
+	if err != nil {
+		return newErrorAt(tok, err.Error())
+	}
+	arr.SetElements(semantics.DeleteSlice(arr.Elements, lowPtr, highPtr, stepVal))
+	return NIL
+}
+
 func compoundAssignOp(op token.Type) (string, bool) {
 	switch op {
 	case token.PLUS_ASSIGN:
@@ -1697,9 +2370,12 @@ func applyDictUpdate(tok token.Token, left, right object.Object) object.Object {
 	if !ok {
 		return newErrorAt(tok, "|= right operand must be dict")
 	}
+	if ld.Frozen {
+		return newErrorAt(tok, semantics.ReadOnlyExportsMessage)
+	}
 	added := semantics.DictUpdateCount(ld, rd)
 	if added > 0 {
-		if errObj := chargeMemoryAt(tok, object.CostDictEntry()*int64(added)); errObj != nil {
+		if errObj := chargeAllocAt(tok, "dict", object.CostDictEntry()*int64(added)); errObj != nil {
 			return errObj
 		}
 	}
@@ -1707,57 +2383,6 @@ func applyDictUpdate(tok token.Token, left, right object.Object) object.Object {
 	return ld
 }
 
-func clamp(x, lo, hi int64) int64 {
-	if x < lo {
-		return lo
-	}
-	if x > hi {
-		return hi
-	}
-	return x
-}
-
-func normIndex(idx, length int64) int64 {
-	if idx < 0 {
-		return length + idx
-	}
-	return idx
-}
-
-func normSliceBounds(low *int64, high *int64, step int64, length int64) (int64, int64) {
-	if step > 0 {
-		lo := int64(0)
-		hi := length
-		if low != nil {
-			lo = normIndex(*low, length)
-		}
-		if high != nil {
-			hi = normIndex(*high, length)
-		}
-		lo = clamp(lo, 0, length)
-		hi = clamp(hi, 0, length)
-		if lo > hi {
-			lo = hi
-		}
-		return lo, hi
-	}
-
-	lo := length - 1
-	hi := int64(-1)
-	if low != nil {
-		lo = normIndex(*low, length)
-	}
-	if high != nil {
-		hi = normIndex(*high, length)
-	}
-	lo = clamp(lo, -1, length-1)
-	hi = clamp(hi, -1, length-1)
-	if lo < hi {
-		lo = hi
-	}
-	return lo, hi
-}
-
 func evalSliceExpression(tok token.Token, left object.Object, low object.Object, high object.Object, step object.Object) object.Object {
 	var lowPtr *int64
 	var highPtr *int64
@@ -1795,7 +2420,7 @@ func evalSliceExpression(tok token.Token, left object.Object, low object.Object,
 	switch v := left.(type) {
 	case *object.Array:
 		n := int64(len(v.Elements))
-		lo, hi := normSliceBounds(lowPtr, highPtr, stepVal, n)
+		lo, hi := semantics.SliceBounds(lowPtr, highPtr, stepVal, n)
 		out := make([]object.Object, 0)
 		if stepVal > 0 {
 			for i := lo; i < hi; i += stepVal {
@@ -1806,26 +2431,20 @@ func evalSliceExpression(tok token.Token, left object.Object, low object.Object,
 				out = append(out, v.Elements[int(i)])
 			}
 		}
-		if errObj := chargeMemoryAt(tok, object.CostArray(len(out))); errObj != nil {
+		if errObj := chargeAllocAt(tok, "array", object.CostArray(len(out))); errObj != nil {
 			return errObj
 		}
 		return &object.Array{Elements: out}
 	case *object.String:
-		rs := []rune(v.Value)
-		n := int64(len(rs))
-		lo, hi := normSliceBounds(lowPtr, highPtr, stepVal, n)
-		buf := make([]rune, 0)
-		if stepVal > 0 {
-			for i := lo; i < hi; i += stepVal {
-				buf = append(buf, rs[int(i)])
-			}
-		} else {
-			for i := lo; i > hi; i += stepVal {
-				buf = append(buf, rs[int(i)])
-			}
+		lo, hi := semantics.SliceBounds(lowPtr, highPtr, stepVal, int64(v.RuneCount()))
+		out := &object.String{Value: v.SliceStep(int(lo), int(hi), int(stepVal))}
+		if errObj := chargeAllocAt(tok, "string", object.CostStringBytes(len(out.Value))); errObj != nil {
+			return errObj
 		}
-		out := &object.String{Value: string(buf)}
-		if errObj := chargeMemoryAt(tok, object.CostStringBytes(len(out.Value))); errObj != nil {
+		return out
+	case *object.Range:
+		out := semantics.SliceRange(v, lowPtr, highPtr, stepVal)
+		if errObj := chargeAllocAt(tok, "range", object.CostRange()); errObj != nil {
 			return errObj
 		}
 		return out
@@ -1834,76 +2453,25 @@ func evalSliceExpression(tok token.Token, left object.Object, low object.Object,
 	}
 }
 
-func applyMethod(tok token.Token, recv object.Object, name string, args []object.Object) object.Object {
-	if name == "get" && recv.Type() != object.DICT_OBJ {
-		return newErrorAt(tok, "get() receiver must be DICT")
-	}
-	switch recv.Type() {
-	case object.ARRAY_OBJ:
-		switch name {
-		case "append":
-			return builtinAppend(tok, recv, args...)
-		case "count":
-			return builtinArrayCount(tok, recv, args...)
-		case "len":
-			return builtinLen(tok, recv, args...)
-		case "pop":
-			return builtinArrayPop(tok, recv, args...)
-		case "remove":
-			return builtinArrayRemove(tok, recv, args...)
-		default:
-			return newErrorAt(tok, "unknown method for ARRAY: "+name)
-		}
-	case object.DICT_OBJ:
-		switch name {
-		case "count":
-			return builtinDictCount(tok, recv, args...)
-		case "get":
-			return builtinDictGet(tok, recv, args...)
-		case "keys":
-			return builtinKeys(tok, recv, args...)
-		case "pop":
-			return builtinDictPop(tok, recv, args...)
-		case "remove":
-			return builtinDictRemove(tok, recv, args...)
-		case "values":
-			return builtinValues(tok, recv, args...)
-		case "hasKey":
-			return builtinHasKey(tok, recv, args...)
-		default:
-			return newErrorAt(tok, "unknown method for DICT: "+name)
-		}
-	case object.STRING_OBJ:
-		switch name {
-		case "len":
-			return builtinLen(tok, recv, args...)
-		case "strip":
-			return builtinStrip(tok, recv, args...)
-		case "uppercase":
-			return builtinUppercase(tok, recv, args...)
-		case "lowercase":
-			return builtinLowercase(tok, recv, args...)
-		case "capitalize":
-			return builtinCapitalize(tok, recv, args...)
-		case "startswith":
-			return builtinStartsWith(tok, recv, args...)
-		case "endswith":
-			return builtinEndsWith(tok, recv, args...)
-		case "slice":
-			return builtinSlice(tok, recv, args...)
-		default:
-			return newErrorAt(tok, "unknown method for STRING: "+name)
+func applyMethod(tok token.Token, recv object.Object, name string, args []object.Object, r *Runner) object.Object {
+	h := &evalHost{tok: tok, r: r}
+	if res, handled := builtins.CallHostMethod(h, recv, name, args, chargeBuiltin); handled {
+		if res == nil {
+			return h.raised
 		}
-	case object.INTEGER_OBJ, object.FLOAT_OBJ:
-		switch name {
-		case "format":
-			return builtinFormatNumber(tok, recv, args...)
-		default:
-			return newErrorAt(tok, "unknown method for "+string(recv.Type())+": "+name)
+		if errObj, ok := res.(*object.Error); ok && !errObj.IsValue && errObj.Stack == "" {
+			return newErrorAt(tok, errObj.Message)
 		}
+		return noteSite(tok, res)
 	}
-
-	return newErrorAt(tok, "type has no methods: "+string(recv.Type()))
+	res, cost, err := semantics.CallMethod(recv, name, args)
+	if err != nil {
+		return newErrorAt(tok, err.Error())
+	}
+	if errObj := chargeAllocAt(tok, "method", cost); errObj != nil {
+		return errObj
+	}
+	return noteSite(tok, res)
 }
 
 func applyFunction(tok token.Token, fn object.Object, args []object.Object, r *Runner) object.Object {
@@ -1938,13 +2506,17 @@ func applyFunction(tok token.Token, fn object.Object, args []object.Object, r *R
 		}
 		defer func() { ctx.File = prevFile }()
 
-		extended := object.NewEnclosedEnvironment(f.Env)
+		extended := object.NewFunctionEnvironment(f.Env)
 
-		if len(args) != len(f.Parameters) {
-			return newErrorAt(tok, fmt.Sprintf(
-				"wrong number of arguments: expected %d, got %d",
-				len(f.Parameters), len(args),
-			))
+		args, err := semantics.BindArgs(args, len(f.Parameters), f.Defaults)
+		if err != nil {
+			return newErrorAt(tok, err.Error())
+		}
+		if isGeneratorBody(f.Body) {
+			for i, p := range f.Parameters {
+				extended.Set(p.Value, args[i])
+			}
+			return newGenerator(tok, f, extended)
 		}
 
 		pushFrame()
@@ -1958,8 +2530,15 @@ func applyFunction(tok token.Token, fn object.Object, args []object.Object, r *R
 		for i, p := range f.Parameters {
 			extended.Set(p.Value, args[i])
 		}
+		if ctx.TraceLocals {
+			top := &ctx.Stack[len(ctx.Stack)-1]
+			top.Fn, top.Env = f, extended
+		}
 
 		evaluated := eval(f.Body, extended, r, 0, 0)
+		if r != nil && isError(evaluated) && heapdump.Tracking() {
+			r.noteFailedCall(evaluated, fnName, extended)
+		}
 		frame := popFrame()
 		deferFramePopped = true
 		if dres := runDefers(frame, extended); dres != nil {
@@ -1968,436 +2547,120 @@ func applyFunction(tok token.Token, fn object.Object, args []object.Object, r *R
 		return unwrapReturnValue(evaluated)
 
 	case *object.Builtin:
-		if f == builtinMap {
-			return applyBuiltinMap(tok, args, r)
-		}
-		res := f.Fn(args...)
-		if errObj, ok := res.(*object.Error); ok && errObj.Stack == "" {
-			if !errObj.IsValue {
-				if memErr := chargeMemoryAt(tok, object.CostError()); memErr != nil {
-					return memErr
-				}
-			}
-			frames := make([]stackFrame, 0, len(ctx.Stack)+1)
-			frames = append(frames, ctx.Stack...)
-			frames = append(frames, stackFrame{
-				Func: "<main>",
-				File: ctx.File,
-				Line: tok.Line,
-				Col:  tok.Col,
-			})
-			errObj.Stack = formatStackTrace(errObj.Message, frames)
-		}
-		return res
-	}
+		return applyBuiltin(tok, f, args, r, nil)
 
-	return newErrorAt(tok, "attempted to call non-function: "+string(fn.Type()))
-}
+	case *object.Class:
+		return construct(tok, f, args, r)
 
-func applyBuiltinMap(tok token.Token, args []object.Object, r *Runner) object.Object {
-	if len(args) != 2 {
-		return newErrorAt(tok, fmt.Sprintf("wrong number of arguments: expected 2, got %d", len(args)))
-	}
-	fn := args[0]
-	arr, ok := args[1].(*object.Array)
-	if !ok {
-		return newErrorAt(tok, "map() second argument must be ARRAY")
-	}
-	switch fn.(type) {
-	case *object.Function, *object.Builtin:
-	default:
-		return newErrorAt(tok, "map() first argument must be FUNCTION")
+	case *object.BoundMethod:
+		return callMethod(tok, f.Receiver, f.Method, args, r)
 	}
 
-	out := make([]object.Object, len(arr.Elements))
-	for i, el := range arr.Elements {
-		res := applyFunction(tok, fn, []object.Object{el}, r)
-		if isError(res) {
-			return res
-		}
-		out[i] = res
-	}
-	if errObj := chargeMemoryAt(tok, object.CostArray(len(out))); errObj != nil {
-		return errObj
-	}
-	return &object.Array{Elements: out}
-}
-
-func unwrapReturnValue(obj object.Object) object.Object {
-	if rv, ok := obj.(*object.ReturnValue); ok {
-		return rv.Value
-	}
-	return obj
-}
-
-func builtinLen(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 0 {
-		return newErrorAt(tok, fmt.Sprintf("len() takes 0 arguments, got %d", len(args)))
-	}
-	switch v := recv.(type) {
-	case *object.String:
-		return &object.Integer{Value: int64(utf8.RuneCountInString(v.Value))}
-	case *object.Array:
-		return &object.Integer{Value: int64(len(v.Elements))}
-	case *object.Dict:
-		return &object.Integer{Value: int64(len(v.Pairs))}
-	default:
-		return newErrorAt(tok, "len() not supported for type: "+string(recv.Type()))
-	}
+	return newErrorAt(tok, "attempted to call non-function: "+string(fn.Type()))
 }
 
-func builtinAppend(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newErrorAt(tok, fmt.Sprintf("append() takes 1 argument, got %d", len(args)))
-	}
-	arr, ok := recv.(*object.Array)
-	if !ok {
-		return newErrorAt(tok, "append() receiver must be ARRAY")
-	}
-	els := make([]object.Object, 0, len(arr.Elements)+1)
-	els = append(els, arr.Elements...)
-	els = append(els, args[0])
-	if errObj := chargeMemoryAt(tok, object.CostArray(len(els))); errObj != nil {
+// construct makes an instance of c. With an init method, args go to init;
+// without one, they fill the fields in order and the rest keep their
+// defaults.
+func construct(tok token.Token, c *object.Class, args []object.Object, r *Runner) object.Object {
+	if errObj := chargeAllocAt(tok, "instance", object.CostInstance(len(c.Fields))); errObj != nil {
 		return errObj
 	}
-	return &object.Array{Elements: els}
-}
-
-func builtinArrayCount(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newErrorAt(tok, fmt.Sprintf("count() takes 1 argument, got %d", len(args)))
-	}
-	arr, ok := recv.(*object.Array)
-	if !ok {
-		return newErrorAt(tok, "count() receiver must be ARRAY")
-	}
-	target := args[0]
-	var count int64
-	for _, el := range arr.Elements {
-		eq, err := semantics.Compare("==", el, target)
-		if err != nil {
+	inst := c.New()
+	init := c.Init()
+	if init == nil {
+		if err := semantics.CheckArity(len(args), len(c.Fields), len(c.Fields)); err != nil {
 			return newErrorAt(tok, err.Error())
 		}
-		if eq {
-			count++
-		}
-	}
-	return &object.Integer{Value: count}
-}
-
-func builtinArrayPop(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 0 {
-		return newErrorAt(tok, fmt.Sprintf("pop() takes 0 arguments, got %d", len(args)))
-	}
-	arr, ok := recv.(*object.Array)
-	if !ok {
-		return newErrorAt(tok, "pop() receiver must be ARRAY")
+		copy(inst.Fields, args)
+		return inst
 	}
-	if len(arr.Elements) == 0 {
-		return newErrorAt(tok, "pop from empty array")
+	if res := callMethod(tok, inst, init, args, r); isError(res) {
+		return res
 	}
-	last := arr.Elements[len(arr.Elements)-1]
-	arr.Elements = arr.Elements[:len(arr.Elements)-1]
-	return last
+	return inst
 }
 
-func builtinArrayRemove(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newErrorAt(tok, fmt.Sprintf("remove() takes 1 argument, got %d", len(args)))
-	}
-	arr, ok := recv.(*object.Array)
-	if !ok {
-		return newErrorAt(tok, "remove() receiver must be ARRAY")
-	}
-	target := args[0]
-	for i, el := range arr.Elements {
-		eq, err := semantics.Compare("==", el, target)
-		if err != nil {
+// callMethod calls method with recv as self. Arity is checked here so the
+// error counts only the arguments the caller wrote.
+func callMethod(tok token.Token, recv *object.Instance, method object.Object, args []object.Object, r *Runner) object.Object {
+	if fn, ok := method.(*object.Function); ok {
+		if err := semantics.CheckArity(len(args), len(fn.Parameters)-1, len(fn.Defaults)); err != nil {
 			return newErrorAt(tok, err.Error())
 		}
-		if eq {
-			arr.Elements = append(arr.Elements[:i], arr.Elements[i+1:]...)
-			return TRUE
-		}
 	}
-	return FALSE
+	return applyFunction(tok, method, append([]object.Object{recv}, args...), r)
 }
 
-func builtinKeys(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 0 {
-		return newErrorAt(tok, fmt.Sprintf("keys() takes 0 arguments, got %d", len(args)))
-	}
-	d, ok := recv.(*object.Dict)
-	if !ok {
-		return newErrorAt(tok, "keys() receiver must be DICT")
-	}
-	pairs := object.SortedDictPairs(d)
-	els := make([]object.Object, 0, len(pairs))
-	for _, pair := range pairs {
-		els = append(els, pair.Key)
-	}
-	if errObj := chargeMemoryAt(tok, object.CostArray(len(els))); errObj != nil {
-		return errObj
-	}
-	return &object.Array{Elements: els}
-}
-
-func builtinDictCount(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 0 {
-		return newErrorAt(tok, fmt.Sprintf("count() takes 0 arguments, got %d", len(args)))
-	}
-	d, ok := recv.(*object.Dict)
-	if !ok {
-		return newErrorAt(tok, "count() receiver must be DICT")
-	}
-	return &object.Integer{Value: int64(len(d.Pairs))}
-}
-
-func builtinDictGet(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 1 && len(args) != 2 {
-		return newErrorAt(tok, fmt.Sprintf("get() takes 1 or 2 arguments, got %d", len(args)))
-	}
-	d, ok := recv.(*object.Dict)
-	if !ok {
-		return newErrorAt(tok, "get() receiver must be DICT")
-	}
-	hk, ok := object.HashKeyOf(args[0])
-	if !ok {
-		return newErrorAt(tok, "unusable as dict key: "+string(args[0].Type()))
-	}
-	if pair, exists := d.Pairs[object.HashKeyString(hk)]; exists {
-		return pair.Value
-	}
-	if len(args) == 2 {
-		return args[1]
-	}
-	return NIL
-}
-
-func builtinDictPop(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 1 && len(args) != 2 {
-		return newErrorAt(tok, fmt.Sprintf("pop() takes 1 or 2 arguments, got %d", len(args)))
-	}
-	d, ok := recv.(*object.Dict)
-	if !ok {
-		return newErrorAt(tok, "pop() receiver must be DICT")
-	}
-	hk, ok := object.HashKeyOf(args[0])
-	if !ok {
-		return newErrorAt(tok, "unusable as dict key: "+string(args[0].Type()))
-	}
-	key := object.HashKeyString(hk)
-	if pair, exists := d.Pairs[key]; exists {
-		delete(d.Pairs, key)
-		return pair.Value
-	}
-	if len(args) == 2 {
-		return args[1]
-	}
-	return newErrorAt(tok, "key not found")
-}
-
-func builtinDictRemove(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newErrorAt(tok, fmt.Sprintf("remove() takes 1 argument, got %d", len(args)))
-	}
-	d, ok := recv.(*object.Dict)
-	if !ok {
-		return newErrorAt(tok, "remove() receiver must be DICT")
-	}
-	hk, ok := object.HashKeyOf(args[0])
-	if !ok {
-		return newErrorAt(tok, "unusable as dict key: "+string(args[0].Type()))
-	}
-	key := object.HashKeyString(hk)
-	if _, exists := d.Pairs[key]; !exists {
-		return newErrorAt(tok, "key not found")
-	}
-	delete(d.Pairs, key)
-	return NIL
-}
-
-func builtinValues(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 0 {
-		return newErrorAt(tok, fmt.Sprintf("values() takes 0 arguments, got %d", len(args)))
-	}
-	d, ok := recv.(*object.Dict)
-	if !ok {
-		return newErrorAt(tok, "values() receiver must be DICT")
-	}
-	pairs := object.SortedDictPairs(d)
-	els := make([]object.Object, 0, len(pairs))
-	for _, pair := range pairs {
-		els = append(els, pair.Value)
-	}
-	if errObj := chargeMemoryAt(tok, object.CostArray(len(els))); errObj != nil {
-		return errObj
-	}
-	return &object.Array{Elements: els}
-}
-
-func builtinHasKey(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newErrorAt(tok, fmt.Sprintf("hasKey() takes 1 argument, got %d", len(args)))
-	}
-	d, ok := recv.(*object.Dict)
-	if !ok {
-		return newErrorAt(tok, "hasKey() receiver must be DICT")
-	}
-	hk, ok := object.HashKeyOf(args[0])
-	if !ok {
-		return newErrorAt(tok, "unusable as dict key: "+string(args[0].Type()))
-	}
-	_, exists := d.Pairs[object.HashKeyString(hk)]
-	return nativeBool(exists)
-}
-
-func builtinStrip(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 0 {
-		return newErrorAt(tok, fmt.Sprintf("strip() takes 0 arguments, got %d", len(args)))
-	}
-	s := recv.(*object.String)
-	out := &object.String{Value: strings.TrimSpace(s.Value)}
-	if errObj := chargeMemoryAt(tok, object.CostStringBytes(len(out.Value))); errObj != nil {
-		return errObj
-	}
-	return out
-}
-
-func builtinUppercase(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 0 {
-		return newErrorAt(tok, fmt.Sprintf("uppercase() takes 0 arguments, got %d", len(args)))
-	}
-	s := recv.(*object.String)
-	out := &object.String{Value: strings.ToUpper(s.Value)}
-	if errObj := chargeMemoryAt(tok, object.CostStringBytes(len(out.Value))); errObj != nil {
-		return errObj
-	}
-	return out
-}
-
-func builtinLowercase(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 0 {
-		return newErrorAt(tok, fmt.Sprintf("lowercase() takes 0 arguments, got %d", len(args)))
-	}
-	s := recv.(*object.String)
-	out := &object.String{Value: strings.ToLower(s.Value)}
-	if errObj := chargeMemoryAt(tok, object.CostStringBytes(len(out.Value))); errObj != nil {
-		return errObj
-	}
-	return out
-}
-
-func builtinCapitalize(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 0 {
-		return newErrorAt(tok, fmt.Sprintf("capitalize() takes 0 arguments, got %d", len(args)))
-	}
-	s := recv.(*object.String)
-	if s.Value == "" {
-		return s
-	}
-	rs := []rune(s.Value)
-	first := strings.ToUpper(string(rs[0]))
-	rest := ""
-	if len(rs) > 1 {
-		rest = strings.ToLower(string(rs[1:]))
-	}
-	out := &object.String{Value: first + rest}
-	if errObj := chargeMemoryAt(tok, object.CostStringBytes(len(out.Value))); errObj != nil {
-		return errObj
-	}
-	return out
-}
-
-func builtinStartsWith(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newErrorAt(tok, fmt.Sprintf("startswith() takes 1 argument, got %d", len(args)))
-	}
-	prefix, ok := args[0].(*object.String)
-	if !ok {
-		return newErrorAt(tok, "startswith() prefix must be STRING")
-	}
-	s := recv.(*object.String)
-	return nativeBool(strings.HasPrefix(s.Value, prefix.Value))
-}
-
-func builtinEndsWith(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newErrorAt(tok, fmt.Sprintf("endswith() takes 1 argument, got %d", len(args)))
-	}
-	suffix, ok := args[0].(*object.String)
-	if !ok {
-		return newErrorAt(tok, "endswith() suffix must be STRING")
-	}
-	s := recv.(*object.String)
-	return nativeBool(strings.HasSuffix(s.Value, suffix.Value))
-}
-
-func builtinSlice(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) > 2 {
-		return newErrorAt(tok, fmt.Sprintf("slice() takes 0, 1, or 2 arguments, got %d", len(args)))
-	}
-	var low object.Object
-	var high object.Object
-	if len(args) >= 1 {
-		low = args[0]
-	}
-	if len(args) == 2 {
-		high = args[1]
-	}
-	return evalSliceExpression(tok, recv, low, high, nil)
-}
-
-func builtinFormatNumber(tok token.Token, recv object.Object, args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newErrorAt(tok, fmt.Sprintf("format() takes 1 argument, got %d", len(args)))
-	}
-	decObj, ok := args[0].(*object.Integer)
-	if !ok {
-		return newErrorAt(tok, "format() decimals must be INTEGER")
-	}
-	if decObj.Value < 0 {
-		return newErrorAt(tok, "format() decimals must be >= 0")
-	}
-	decimals := int(decObj.Value)
-
-	switch v := recv.(type) {
-	case *object.Integer:
-		out := &object.String{Value: formatIntFixed(v.Value, decimals)}
-		if errObj := chargeMemoryAt(tok, object.CostStringBytes(len(out.Value))); errObj != nil {
-			return errObj
+// evalMemberSet assigns the member name of obj through setter. A compound
+// op reads the current value first, so setter must also be a MemberGetter.
+func evalMemberSet(tok token.Token, obj object.Object, setter object.MemberSetter, name string, op token.Type, val object.Object) object.Object {
+	if op != "" && op != token.ASSIGN {
+		getter, ok := obj.(object.MemberGetter)
+		if !ok {
+			return newErrorAt(tok, "unknown member: "+name)
 		}
-		return out
-	case *object.Float:
-		out := &object.String{Value: formatFloatFixed(v.Value, decimals)}
-		if errObj := chargeMemoryAt(tok, object.CostStringBytes(len(out.Value))); errObj != nil {
-			return errObj
+		cur, ok := getter.GetMember(name)
+		if !ok {
+			return newErrorAt(tok, "unknown member: "+name)
 		}
-		return out
-	default:
-		return newErrorAt(tok, "format() receiver must be NUMBER")
+		if op == token.BITOR_ASSIGN {
+			val = applyDictUpdate(tok, cur, val)
+			if isError(val) {
+				return val
+			}
+		} else {
+			opStr, ok := compoundAssignOp(op)
+			if !ok {
+				return newErrorAt(tok, "unknown assignment operator: "+string(op))
+			}
+			res, err := semantics.BinaryOp(opStr, cur, val)
+			if err != nil {
+				return newErrorAt(tok, err.Error())
+			}
+			val = res
+		}
+	}
+	if err := setter.SetMember(name, val); err != nil {
+		return newErrorAt(tok, err.Error())
 	}
+	return val
 }
 
-func formatIntFixed(value int64, decimals int) string {
-	if decimals == 0 {
-		return strconv.FormatInt(value, 10)
-	}
-	sign := ""
-	if value < 0 {
-		sign = "-"
-		value = -value
+// applyBuiltin calls b, through its host implementation when it has one.
+// env is the calling scope for locals() and friends, or nil when b is
+// called indirectly and has no caller scope.
+func applyBuiltin(tok token.Token, b *object.Builtin, args []object.Object, r *Runner, env *object.Environment) object.Object {
+	h := &evalHost{tok: tok, r: r, env: env}
+	res, handled := builtins.CallHost(h, b, args, chargeBuiltin)
+	if !handled {
+		res = builtins.Call(b, args, chargeBuiltin)
+	} else if res == nil {
+		return h.raised
+	}
+	if errObj, ok := res.(*object.Error); ok && errObj.Stack == "" {
+		if !errObj.IsValue {
+			if memErr := chargeAllocAt(tok, "error", object.CostError()); memErr != nil {
+				return memErr
+			}
+			if errObj.Code == 0 {
+				errObj.Code = errcode.Classify(errObj.Message)
+			}
+		}
+		errObj.Stack = formatStackTrace(errObj.Message, stackAt(tok))
 	}
-	return sign + strconv.FormatInt(value, 10) + "." + strings.Repeat("0", decimals)
+	return noteSite(tok, res)
 }
 
-func formatFloatFixed(value float64, decimals int) string {
-	if decimals == 0 {
-		return strconv.FormatFloat(math.Round(value), 'f', 0, 64)
+// applyBuiltinSortWith implements sort(array, comparator). The comparator is
+// called as comparator(a, b) and the first error it raises stops the sort.
+// applyBuiltinSortBy implements sort_by(array, keyFn): keyFn is called once
+// per element and the elements are stably sorted by key.
+func unwrapReturnValue(obj object.Object) object.Object {
+	if rv, ok := obj.(*object.ReturnValue); ok {
+		return rv.Value
 	}
-	scale := math.Pow10(decimals)
-	rounded := math.Round(value*scale) / scale
-	return strconv.FormatFloat(rounded, 'f', decimals, 64)
+	return obj
 }
 
 func nativeBool(b bool) object.Object {
@@ -2424,32 +2687,32 @@ func isContinue(obj object.Object) bool {
 }
 
 func newError(msg string) object.Object {
-	if errObj := chargeMemory(object.CostError()); errObj != nil {
+	if errObj := chargeAlloc("error", object.CostError()); errObj != nil {
 		return errObj
 	}
 	e := &object.Error{
 		Message: msg,
+		Code:    errcode.Classify(msg),
 	}
 	e.Stack = formatStackTrace(msg, ctx.Stack)
 	return e
 }
 
+// newErrorAt returns a runtime error raised at tok, with the catalog code
+// its message maps to.
 func newErrorAt(tok token.Token, msg string) object.Object {
-	if errObj := chargeMemoryAt(tok, object.CostError()); errObj != nil {
+	return newErrorCodeAt(tok, errcode.Classify(msg), msg)
+}
+
+func newErrorCodeAt(tok token.Token, code int64, msg string) object.Object {
+	if errObj := chargeAllocAt(tok, "error", object.CostError()); errObj != nil {
 		return errObj
 	}
 	e := &object.Error{
 		Message: msg,
+		Code:    code,
 	}
-	frames := make([]stackFrame, 0, len(ctx.Stack)+1)
-	frames = append(frames, ctx.Stack...)
-	frames = append(frames, stackFrame{
-		Func: "<main>",
-		File: ctx.File,
-		Line: tok.Line,
-		Col:  tok.Col,
-	})
-	e.Stack = formatStackTrace(msg, frames)
+	e.Stack = formatStackTrace(msg, stackAt(tok))
 	return e
 }
 
@@ -2457,34 +2720,28 @@ func wrapThrownValue(tok token.Token, val object.Object) object.Object {
 	if errObj, ok := val.(*object.Error); ok {
 		out := errObj
 		if errObj.IsValue {
-			if memErr := chargeMemoryAt(tok, object.CostError()); memErr != nil {
+			if memErr := chargeAllocAt(tok, "error", object.CostError()); memErr != nil {
 				return memErr
 			}
 			out = &object.Error{
 				Message: errObj.Message,
 				Code:    errObj.Code,
+				Tag:     errObj.Tag,
+				Data:    errObj.Data,
 				Stack:   errObj.Stack,
 			}
 		}
 		if out.Stack == "" {
-			frames := make([]stackFrame, 0, len(ctx.Stack)+1)
-			frames = append(frames, ctx.Stack...)
-			frames = append(frames, stackFrame{
-				Func: "<main>",
-				File: ctx.File,
-				Line: tok.Line,
-				Col:  tok.Col,
-			})
-			out.Stack = formatStackTrace(out.Message, frames)
+			out.Stack = formatStackTrace(out.Message, stackAt(tok))
 		}
 		return out
 	}
 
 	switch v := val.(type) {
 	case *object.String:
-		return newErrorAt(tok, v.Value)
+		return newErrorCodeAt(tok, 0, v.Value)
 	default:
-		return newErrorAt(tok, val.Inspect())
+		return newErrorCodeAt(tok, 0, val.Inspect())
 	}
 }
 
@@ -2498,11 +2755,29 @@ func isError(obj object.Object) bool {
 	return true
 }
 
+// stackAt returns the call stack with tok, the position reached in the
+// innermost function, on top.
+func stackAt(tok token.Token) []stackFrame {
+	frames := make([]stackFrame, 0, len(ctx.Stack)+1)
+	frames = append(frames, ctx.Stack...)
+	return append(frames, stackFrame{File: ctx.File, Line: tok.Line, Col: tok.Col})
+}
+
+// formatStackTrace prints frames innermost first. A frame of ctx.Stack is a
+// call: where it was made and the function it called. So each position is
+// labelled with the function called by the frame below it, which is the
+// one running there, and the outermost position with <main>.
 func formatStackTrace(message string, frames []stackFrame) string {
 	out := "error: " + message + "\nstack trace:\n"
 	for i := len(frames) - 1; i >= 0; i-- {
 		f := frames[i]
-		name := f.Func
+		name := "<main>"
+		var fn *object.Function
+		var env *object.Environment
+		if i > 0 {
+			running := frames[i-1]
+			name, fn, env = running.Func, running.Fn, running.Env
+		}
 		if name == "" {
 			name = "<anon>"
 		}
@@ -2510,7 +2785,17 @@ func formatStackTrace(message string, frames []stackFrame) string {
 		if file == "" {
 			file = "<unknown>"
 		}
-		out += fmt.Sprintf("  at %s (%s:%d:%d)\n", name, file, f.Line, f.Col)
+		out += fmt.Sprintf("  at %s (%s:%d:%d)", name, file, f.Line, f.Col)
+		if fn != nil && len(fn.Parameters) > 0 {
+			names := make([]string, len(fn.Parameters))
+			values := make([]object.Object, len(fn.Parameters))
+			for i, p := range fn.Parameters {
+				names[i] = p.Value
+				values[i], _ = env.Get(p.Value)
+			}
+			out += " " + semantics.FormatParams(names, values)
+		}
+		out += "\n"
 	}
 	return out
 }
@@ -147,10 +147,14 @@ func TestRangeBuiltin(t *testing.T) {
 
 	for i, tt := range tests {
 		got := testEval(t, tt.input)
-		arr, ok := got.(*object.Array)
+		rng, ok := got.(*object.Range)
 		if !ok {
-			t.Fatalf("tests[%d] - expected *object.Array, got %T (%v)", i, got, got)
+			t.Fatalf("tests[%d] - expected *object.Range, got %T (%v)", i, got, got)
 		}
+		if rng.Len() != int64(len(tt.want)) {
+			t.Fatalf("tests[%d] - expected Len() %d, got %d", i, len(tt.want), rng.Len())
+		}
+		arr := rng.Array()
 		if len(arr.Elements) != len(tt.want) {
 			t.Fatalf("tests[%d] - expected len %d, got %d", i, len(tt.want), len(arr.Elements))
 		}
@@ -0,0 +1,199 @@
+package evaluator
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"welle/internal/ast"
+	"welle/internal/object"
+	"welle/internal/token"
+)
+
+// generator is what a call to a generator function returns. Its body runs
+// on a goroutine of its own, a step at a time: next hands control to the
+// body and waits until it yields or finishes, so only one of them runs at
+// once and the evaluator's global state needs no locking.
+//
+// The goroutine only sees the coroutine, never the generator, so a
+// generator the program can no longer reach (one a loop broke out of and
+// nothing else holds) is collected, and its cleanup stops the goroutine
+// suspended behind it.
+type generator struct {
+	*coroutine
+}
+
+// coroutine is the state a generator's body shares with its goroutine.
+type coroutine struct {
+	fn  *object.Function
+	env *object.Environment
+	// resume wakes the suspended body; false tells it to stop instead.
+	resume chan bool
+	out    chan genStep
+	// frame holds the body's defers while it is suspended.
+	frame   callFrame
+	started bool
+	running bool
+	done    bool
+}
+
+// genStep is what the body hands back to next: a yielded value, or the
+// end of the body with the error it failed with, if any.
+type genStep struct {
+	val  object.Object
+	done bool
+}
+
+func (*generator) Type() object.Type { return object.GENERATOR_OBJ }
+func (g *generator) Inspect() string { return "<generator " + g.fn.Name + ">" }
+
+// goroutineCost is charged to the memory budget when a body's goroutine
+// starts, for its stack and channels, which the generator's own charge
+// does not cover.
+const goroutineCost = 8 << 10
+
+var (
+	// runningGen is the coroutine whose body is running, which a yield
+	// statement suspends.
+	runningGen *coroutine
+	// suspendedGens holds the coroutines whose bodies are waiting to be
+	// resumed. Cleanups of collected generators run on goroutines of their
+	// own, so it is guarded by gensMu.
+	suspendedGens = map[*coroutine]bool{}
+	gensMu        sync.Mutex
+	// generatorBodies caches ast.IsGenerator for each function body.
+	generatorBodies = map[*ast.BlockStatement]bool{}
+)
+
+func isGeneratorBody(body *ast.BlockStatement) bool {
+	gen, ok := generatorBodies[body]
+	if !ok {
+		gen = ast.IsGenerator(body)
+		generatorBodies[body] = gen
+	}
+	return gen
+}
+
+func newGenerator(tok token.Token, fn *object.Function, env *object.Environment) object.Object {
+	if errObj := chargeAllocAt(tok, "generator", object.CostGenerator(len(fn.Parameters))); errObj != nil {
+		return errObj
+	}
+	co := &coroutine{fn: fn, env: env, resume: make(chan bool), out: make(chan genStep)}
+	g := &generator{co}
+	runtime.AddCleanup(g, (*coroutine).stop, co)
+	return g
+}
+
+// next runs the body up to its next yield and returns the value yielded,
+// with ok false once the body has finished. errObj is the error the body
+// failed with; tok is where the generator is being iterated.
+func (g *coroutine) next(tok token.Token, r *Runner) (val object.Object, ok bool, errObj object.Object) {
+	if g.done {
+		return nil, false, nil
+	}
+	if g.running {
+		return nil, false, newErrorAt(tok, "generator is already running")
+	}
+	if r != nil && r.maxRecursion > 0 {
+		if r.recursion+1 > r.maxRecursion {
+			return nil, false, newErrorAt(tok, fmt.Sprintf("max recursion depth exceeded (%d)", r.maxRecursion))
+		}
+		r.recursion++
+		defer func() { r.recursion-- }()
+	}
+	ctx.Stack = append(ctx.Stack, stackFrame{Func: g.fn.Name, File: ctx.File, Line: tok.Line, Col: tok.Col})
+	if ctx.TraceLocals {
+		top := &ctx.Stack[len(ctx.Stack)-1]
+		top.Fn, top.Env = g.fn, g.env
+	}
+	if !g.started {
+		if errObj := chargeMemoryAt(tok, goroutineCost); errObj != nil {
+			ctx.Stack = ctx.Stack[:len(ctx.Stack)-1]
+			return nil, false, errObj
+		}
+	}
+	prevFile, prevGen := ctx.File, runningGen
+	if g.fn.File != "" {
+		ctx.File = g.fn.File
+	}
+	runningGen, g.running = g, true
+	if g.started {
+		gensMu.Lock()
+		delete(suspendedGens, g)
+		gensMu.Unlock()
+		g.resume <- true
+	} else {
+		g.started = true
+		go g.run(r)
+	}
+	step := <-g.out
+	runningGen, g.running = prevGen, false
+	ctx.File = prevFile
+	ctx.Stack = ctx.Stack[:len(ctx.Stack)-1]
+	if step.done {
+		g.done = true
+		return nil, false, step.val
+	}
+	gensMu.Lock()
+	suspendedGens[g] = true
+	gensMu.Unlock()
+	return step.val, true, nil
+}
+
+// run is the body's goroutine.
+func (g *coroutine) run(r *Runner) {
+	pushFrame()
+	evaluated := eval(g.fn.Body, g.env, r, 0, 0)
+	frame := popFrame()
+	if dres := runDefers(frame, g.env); dres != nil {
+		evaluated = dres
+	}
+	var errObj object.Object
+	if isError(evaluated) {
+		errObj = evaluated
+	}
+	g.out <- genStep{val: errObj, done: true}
+}
+
+// yield hands val to next and waits to be resumed. The body's defer frame,
+// the top one while it runs, is set aside in between.
+func (g *coroutine) yield(val object.Object) object.Object {
+	g.frame = popFrame()
+	g.out <- genStep{val: val}
+	if !<-g.resume {
+		// Nothing between the body and this yield defers Go code that
+		// touches shared state, so the goroutine can simply end.
+		runtime.Goexit()
+	}
+	callStack = append(callStack, g.frame)
+	g.frame = callFrame{}
+	return NIL
+}
+
+// stop ends the body's goroutine if it is suspended. It is the cleanup of
+// a collected generator, so it may run on any goroutine, and more than once.
+func (g *coroutine) stop() {
+	gensMu.Lock()
+	suspended := suspendedGens[g]
+	delete(suspendedGens, g)
+	gensMu.Unlock()
+	if suspended {
+		g.resume <- false
+	}
+}
+
+// stopSuspendedGenerators ends the goroutines of the generators that were
+// left suspended. Their remaining defers and finally blocks do not run, as
+// on the VM.
+func stopSuspendedGenerators() {
+	gensMu.Lock()
+	gens := make([]*coroutine, 0, len(suspendedGens))
+	for g := range suspendedGens {
+		gens = append(gens, g)
+	}
+	clear(suspendedGens)
+	gensMu.Unlock()
+	for _, g := range gens {
+		g.resume <- false
+	}
+}
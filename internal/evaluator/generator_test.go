@@ -0,0 +1,75 @@
+package evaluator
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"welle/internal/object"
+)
+
+func TestAbandonedGeneratorsAreStopped(t *testing.T) {
+	input := `func count() {
+  i = 0
+  while (true) {
+    yield i
+    i = i + 1
+  }
+}
+func first(n) {
+  for (x in count()) {
+    if (x == n) { return x }
+  }
+}
+kept = count()
+for (x in kept) { if (x == 1) { break } }
+total = 0
+for (k = 0; k < 500; k = k + 1) {
+  for (x in count()) {
+    if (x == 2) { break }
+  }
+  total = total + first(1)
+  try { [10 / (1 - x) for x in count()] } catch (e) { }
+}
+for (x in kept) { total = total + x; break }
+total`
+
+	before := runtime.NumGoroutine()
+	got := testEvalWithRunner(t, input, NewRunner())
+	if n, ok := got.(*object.Integer); !ok || n.Value != 502 {
+		t.Fatalf("expected 502, got %v", got)
+	}
+	// Every generator but kept is unreachable once its loop is left; their
+	// goroutines end as the collector runs their cleanups.
+	left := 0
+	for range 100 {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+		if left = runtime.NumGoroutine() - before; left <= 1 {
+			break
+		}
+	}
+	if left > 1 {
+		t.Fatalf("%d generator goroutines still running", left)
+	}
+	stopSuspendedGenerators()
+}
+
+func TestGeneratorGoroutinesChargeMemory(t *testing.T) {
+	input := `func one() { yield 1 }
+started = 0
+try {
+  for (k = 0; k < 100; k = k + 1) {
+    for (x in one()) { started = started + 1; break }
+  }
+} catch (e) { e.message }`
+
+	runner := NewRunner()
+	runner.SetMaxMemory(200_000)
+	got := testEvalWithRunner(t, input, runner)
+	if s, ok := got.(*object.String); !ok || !strings.HasPrefix(s.Value, "max memory exceeded") {
+		t.Fatalf("expected a memory error, got %v", got)
+	}
+	stopSuspendedGenerators()
+}
@@ -0,0 +1,39 @@
+package evaluator
+
+import (
+	"time"
+
+	"welle/internal/errcode"
+	"welle/internal/limits"
+	"welle/internal/object"
+	"welle/internal/token"
+)
+
+// interruptErrorAt builds the error a pending interrupt raises at tok. catch
+// blocks let it through (see evalTry); finally blocks and defers still run.
+func interruptErrorAt(tok token.Token) object.Object {
+	res := newErrorAt(tok, limits.InterruptMessage)
+	if errObj, ok := res.(*object.Error); ok && errObj.Code == 0 {
+		errObj.Code = limits.InterruptCode
+	}
+	return res
+}
+
+func isInterrupt(obj object.Object) bool {
+	errObj, ok := obj.(*object.Error)
+	return ok && errObj.Code == limits.InterruptCode
+}
+
+// checkPending returns the error a pending interrupt or a passed with_timeout
+// deadline raises at tok, or nil. A deadline is cleared once it fires, so
+// catch and finally blocks inside the timed call run normally.
+func checkPending(tok token.Token) object.Object {
+	if limits.TakeInterrupt() {
+		return interruptErrorAt(tok)
+	}
+	if ctx.Deadline.IsZero() || time.Now().Before(ctx.Deadline) {
+		return nil
+	}
+	ctx.Deadline = time.Time{}
+	return newErrorCodeAt(tok, errcode.Timeout, errcode.TimeoutMessage)
+}
@@ -3,8 +3,10 @@ package evaluator
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"welle/internal/lexer"
+	"welle/internal/limits"
 	"welle/internal/object"
 	"welle/internal/parser"
 )
@@ -69,3 +71,40 @@ func testEvalWithRunner(t *testing.T, input string, runner *Runner) object.Objec
 	env := object.NewEnvironment()
 	return eval(prog, env, runner, 0, 0)
 }
+
+func TestInterruptSkipsCatchAndRunsFinally(t *testing.T) {
+	input := `state = 0
+func f() {
+  defer func() { state = state + 100 }()
+  try {
+    while (true) { state = 1 }
+  } catch (e) {
+    state = 2
+  } finally {
+    state = state + 10
+  }
+}
+f()`
+	p := parser.New(lexer.New(input))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		limits.Interrupt()
+	}()
+	env := object.NewEnvironment()
+	res := eval(prog, env, NewRunner(), 0, 0)
+	errObj, ok := res.(*object.Error)
+	if !ok || errObj.Code != limits.InterruptCode || errObj.Message != "interrupted" {
+		t.Fatalf("expected interrupt error, got %v", res)
+	}
+	if !strings.Contains(errObj.Stack, "at f (") {
+		t.Fatalf("expected stack through f, got %q", errObj.Stack)
+	}
+	state, _ := env.Get("state")
+	if state.Inspect() != "111" {
+		t.Fatalf("state = %s, want 111", state.Inspect())
+	}
+}
@@ -1,6 +1,7 @@
 package evaluator
 
 import (
+	"welle/internal/heapdump"
 	"welle/internal/limits"
 	"welle/internal/object"
 	"welle/internal/token"
@@ -15,15 +16,7 @@ func memoryError(limit int64) *object.Error {
 
 func memoryErrorAt(tok token.Token, limit int64) object.Object {
 	errObj := memoryError(limit)
-	frames := make([]stackFrame, 0, len(ctx.Stack)+1)
-	frames = append(frames, ctx.Stack...)
-	frames = append(frames, stackFrame{
-		Func: "<main>",
-		File: ctx.File,
-		Line: tok.Line,
-		Col:  tok.Col,
-	})
-	errObj.Stack = formatStackTrace(errObj.Message, frames)
+	errObj.Stack = formatStackTrace(errObj.Message, stackAt(tok))
 	return errObj
 }
 
@@ -52,3 +45,24 @@ func chargeMemory(n int64) object.Object {
 	}
 	return nil
 }
+
+// chargeAllocAt charges n for one allocation of the given kind at tok,
+// counting it when the run records stats.
+func chargeAllocAt(tok token.Token, kind string, n int64) object.Object {
+	ctx.Stats.CountAlloc(kind)
+	return chargeMemoryAt(tok, n)
+}
+
+func chargeAlloc(kind string, n int64) object.Object {
+	ctx.Stats.CountAlloc(kind)
+	return chargeMemory(n)
+}
+
+// noteSite records tok as where obj was made, for a heap dump that tracks
+// sites, and returns obj.
+func noteSite(tok token.Token, obj object.Object) object.Object {
+	if heapdump.Tracking() {
+		heapdump.Note(obj, ctx.File, tok.Line, tok.Col)
+	}
+	return obj
+}
@@ -5,15 +5,17 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"welle/internal/ast"
 	"welle/internal/compiler"
-	"welle/internal/lexer"
+	"welle/internal/heapdump"
 	"welle/internal/limits"
 	"welle/internal/module"
 	"welle/internal/object"
-	"welle/internal/parser"
+	"welle/internal/semantics"
 	"welle/internal/token"
+	"welle/internal/trace"
 	"welle/internal/vm"
 )
 
@@ -29,10 +31,28 @@ type Runner struct {
 	recursion    int
 	maxMemory    int64
 	budget       *limits.Budget
+	moduleLimits []limits.ModuleLimits
+	release      bool
+	features     map[string]bool
+	// deinits holds the __deinit hooks of the modules imported so far, in
+	// the order they finished loading.
+	deinits []object.Object
+	// entryEnv is the top-level environment of the entry file once it
+	// runs, and entryErr the error it stopped on, if any.
+	entryEnv *object.Environment
+	entryErr object.Object
+	// failedErr is the last error a heap dump saw unwind out of calls, and
+	// failedRoots the locals of those calls, innermost first.
+	failedErr   object.Object
+	failedRoots []heapdump.Root
 }
 
 func NewRunner() *Runner {
 	ctx.Budget = nil
+	ctx.Stats = nil
+	ctx.Tracer = nil
+	ctx.Deadline = time.Time{}
+	ctx.TraceLocals = false
 	return &Runner{
 		Env:       object.NewEnvironment(),
 		modules:   map[string]*object.Dict{},
@@ -62,6 +82,46 @@ func (r *Runner) SetBudget(b *limits.Budget) {
 	ctx.Budget = b
 }
 
+// SetModuleLimits installs stricter limits for the modules this run imports.
+// The interpreter has no step limit, so only MaxMem applies: such a module
+// runs on a child of the run's budget.
+func (r *Runner) SetModuleLimits(rules []limits.ModuleLimits) {
+	r.moduleLimits = rules
+}
+
+// SetRelease makes assert statements do nothing, as -release compiles them
+// out for the VM.
+func (r *Runner) SetRelease(on bool) {
+	r.release = on
+}
+
+// SetFeatures enables the named build features, which BUILD.<name> reports
+// as true.
+func (r *Runner) SetFeatures(names []string) {
+	r.features = map[string]bool{}
+	for _, name := range names {
+		r.features[name] = true
+	}
+}
+
+// SetStats makes this run and the modules it imports record what they use
+// into s.
+func (r *Runner) SetStats(s *limits.Stats) {
+	ctx.Stats = s
+}
+
+// SetTracer installs the execution tracer for this run and the modules it
+// imports; trace() toggles it.
+func (r *Runner) SetTracer(t *trace.Tracer) {
+	ctx.Tracer = t
+}
+
+// SetTraceLocals makes stack traces show the parameter values of each
+// function on them.
+func (r *Runner) SetTraceLocals(on bool) {
+	ctx.TraceLocals = on
+}
+
 func (r *Runner) Eval(node ast.Node) object.Object {
 	return eval(node, r.Env, r, 0, 0)
 }
@@ -72,11 +132,11 @@ func (r *Runner) EnableImports() {
 		if err != nil {
 			cwd = "."
 		}
-		stdRoot, err := filepath.Abs(filepath.Join(cwd, "std"))
+		stdRoot, err := module.LocateStd("", cwd, filepath.Join(cwd, "std"))
 		if err != nil {
 			stdRoot = filepath.Join(cwd, "std")
 		}
-		r.resolver = module.NewResolver(stdRoot, nil)
+		r.resolver = module.NewResolver(stdRoot, []string{cwd})
 	}
 	if r.loader == nil {
 		r.loader = module.NewLoader(r.resolver)
@@ -135,7 +195,8 @@ func (r *Runner) RunFile(path string) object.Object {
 	ctx.File = abs
 	defer func() { ctx.File = prevFile }()
 
-	b, err := os.ReadFile(abs)
+	loadStart := time.Now()
+	b, err := module.ReadSource(abs)
 	if err != nil {
 		return &object.Error{Message: "import/run: cannot read file: " + abs}
 	}
@@ -144,26 +205,45 @@ func (r *Runner) RunFile(path string) object.Object {
 	r.baseDir = filepath.Dir(abs)
 	defer func() { r.baseDir = prev }()
 
-	l := lexer.New(string(b))
-	p := parser.New(l)
+	p := r.resolver.NewParser(abs, string(b))
 	program := p.ParseProgram()
 	if len(p.Errors()) > 0 {
 		return &object.Error{Message: fmt.Sprintf("parse error in %s: %s", abs, p.Errors()[0])}
 	}
+	if err := r.resolver.ApplyPrelude(abs, string(b), program); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	ctx.Stats.AddLoad(abs, time.Since(loadStart))
+
+	// The entry file is the bottom of the load stack; only imports get
+	// module limits.
+	if rule, ok := limits.ForModule(r.moduleLimits, abs); ok && rule.MaxMem > 0 && len(r.loadStack) > 1 {
+		prevBudget := ctx.Budget
+		ctx.Budget = prevBudget.Child(rule.MaxMem)
+		defer func() { ctx.Budget = prevBudget }()
+	}
 
 	if err := module.CheckDuplicateExports(program, abs); err != nil {
 		return &object.Error{Message: err.Error()}
 	}
 
 	modEnv := object.NewEnvironment()
+	if len(r.loadStack) > 1 {
+		modEnv.MarkImported()
+	} else {
+		r.entryEnv = modEnv
+	}
 	res := eval(program, modEnv, r, 0, 0)
 	if res != nil && res.Type() == object.ERROR_OBJ {
+		if modEnv == r.entryEnv {
+			r.entryErr = res
+		}
 		return res
 	}
 
 	snap := modEnv.Snapshot()
 	exports := modEnv.ExportedNames()
-	mod := &object.Dict{Pairs: map[string]object.DictPair{}}
+	mod := &object.Dict{Pairs: map[object.HashKey]object.DictPair{}}
 	for k, v := range snap {
 		if k == object.ExportSetName {
 			continue
@@ -174,14 +254,69 @@ func (r *Runner) RunFile(path string) object.Object {
 		if exports[k] {
 			key := &object.String{Value: k}
 			hk, _ := object.HashKeyOf(key)
-			mod.Pairs[object.HashKeyString(hk)] = object.DictPair{Key: key, Value: v}
+			mod.Pairs[hk] = object.DictPair{Key: key, Value: v}
 		}
 	}
 
+	mod.Frozen = true
+	if len(r.loadStack) > 1 {
+		if hook := semantics.ModuleHook(mod, semantics.InitHook); hook != nil {
+			if res := r.Call(hook); isError(res) {
+				return res
+			}
+		}
+		if hook := semantics.ModuleHook(mod, semantics.DeinitHook); hook != nil {
+			r.deinits = append(r.deinits, hook)
+		}
+	}
 	r.modules[abs] = mod
 	return mod
 }
 
+// HeapRoots returns what a heap dump of the run starts from: the entry
+// file's globals and, when it stopped on an error, the locals of the calls
+// the error unwound through, named fn.local.
+func (r *Runner) HeapRoots() []heapdump.Root {
+	if r.entryEnv == nil {
+		return nil
+	}
+	var roots []heapdump.Root
+	for name, val := range r.entryEnv.Snapshot() {
+		roots = append(roots, heapdump.Root{Name: name, Value: val})
+	}
+	if r.entryErr != nil && r.entryErr == r.failedErr {
+		roots = append(roots, r.failedRoots...)
+	}
+	return roots
+}
+
+// noteFailedCall records the locals of a call of fn that errObj is
+// unwinding out of, for HeapRoots.
+func (r *Runner) noteFailedCall(errObj object.Object, fn string, env *object.Environment) {
+	if errObj != r.failedErr {
+		r.failedErr, r.failedRoots = errObj, nil
+	}
+	for name, val := range env.Locals() {
+		r.failedRoots = append(r.failedRoots, heapdump.Root{Name: fn + "." + name, Value: val})
+	}
+}
+
+// Shutdown runs the __deinit hooks of the modules the program imported,
+// once the entry file has run, in the reverse of the order they finished
+// loading. It stops at the first hook that fails and returns its error.
+// Generators left suspended are stopped first.
+func (r *Runner) Shutdown() object.Object {
+	stopSuspendedGenerators()
+	deinits := r.deinits
+	r.deinits = nil
+	for i := len(deinits) - 1; i >= 0; i-- {
+		if res := r.Call(deinits[i]); isError(res) {
+			return res
+		}
+	}
+	return nil
+}
+
 func (r *Runner) RunFileEnv(path string) (*object.Environment, object.Object) {
 	abs, err := filepath.Abs(path)
 	if err != nil {
@@ -192,7 +327,7 @@ func (r *Runner) RunFileEnv(path string) (*object.Environment, object.Object) {
 	ctx.File = abs
 	defer func() { ctx.File = prevFile }()
 
-	b, err := os.ReadFile(abs)
+	b, err := module.ReadSource(abs)
 	if err != nil {
 		return nil, &object.Error{Message: "import/run: cannot read file: " + abs}
 	}
@@ -201,12 +336,14 @@ func (r *Runner) RunFileEnv(path string) (*object.Environment, object.Object) {
 	r.baseDir = filepath.Dir(abs)
 	defer func() { r.baseDir = prev }()
 
-	l := lexer.New(string(b))
-	p := parser.New(l)
+	p := r.resolver.NewParser(abs, string(b))
 	program := p.ParseProgram()
 	if len(p.Errors()) > 0 {
 		return nil, &object.Error{Message: fmt.Sprintf("parse error in %s: %s", abs, p.Errors()[0])}
 	}
+	if err := r.resolver.ApplyPrelude(abs, string(b), program); err != nil {
+		return nil, &object.Error{Message: err.Error()}
+	}
 
 	if err := module.CheckDuplicateExports(program, abs); err != nil {
 		return nil, &object.Error{Message: err.Error()}
@@ -258,9 +395,11 @@ func (r *Runner) LoadModuleVM(path string) (*object.Dict, error) {
 		return r.loader.LoadBytecode(fromPath, spec, false)
 	}
 	mvm := vm.NewWithImporter(bc, absPath, importer)
+	mvm.MarkImported()
 	if r.budget != nil {
 		mvm.SetBudget(r.budget)
 	}
+	mvm.SetStats(ctx.Stats)
 	mvm.SetModuleCache(r.modules)
 	if err := mvm.Run(); err != nil {
 		return nil, fmt.Errorf("vm error in %s: %v", absPath, err)
@@ -0,0 +1,8 @@
+package evaluator
+
+// countStatement records one evaluated statement when the run records stats.
+func countStatement() {
+	if ctx.Stats != nil {
+		ctx.Stats.Steps++
+	}
+}
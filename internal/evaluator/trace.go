@@ -0,0 +1,78 @@
+package evaluator
+
+import (
+	"welle/internal/ast"
+	"welle/internal/token"
+)
+
+// traceStatement logs stmt to the run's tracer before it is evaluated.
+func traceStatement(stmt ast.Statement) {
+	t := ctx.Tracer
+	if !t.Enabled() {
+		return
+	}
+	fn := "<main>"
+	if len(ctx.Stack) > 0 {
+		fn = ctx.Stack[len(ctx.Stack)-1].Func
+	}
+	tok := statementToken(stmt)
+	t.Statement(ctx.File, fn, tok.Line, tok.Col, stmt.String())
+}
+
+func statementToken(stmt ast.Statement) token.Token {
+	switch s := stmt.(type) {
+	case *ast.ExpressionStatement:
+		return s.Token
+	case *ast.AssignStatement:
+		return s.Token
+	case *ast.IndexAssignStatement:
+		return s.Token
+	case *ast.MemberAssignStatement:
+		return s.Token
+	case *ast.ReturnStatement:
+		return s.Token
+	case *ast.YieldStatement:
+		return s.Token
+	case *ast.DestructureAssignStatement:
+		return s.Token
+	case *ast.DeferStatement:
+		return s.Token
+	case *ast.DelStatement:
+		return s.Token
+	case *ast.ThrowStatement:
+		return s.Token
+	case *ast.AssertStatement:
+		return s.Token
+	case *ast.BreakStatement:
+		return s.Token
+	case *ast.ContinueStatement:
+		return s.Token
+	case *ast.PassStatement:
+		return s.Token
+	case *ast.ImportStatement:
+		return s.Token
+	case *ast.FromImportStatement:
+		return s.Token
+	case *ast.ExportStatement:
+		return s.Token
+	case *ast.BlockStatement:
+		return s.Token
+	case *ast.TryStatement:
+		return s.Token
+	case *ast.IfStatement:
+		return s.Token
+	case *ast.WhileStatement:
+		return s.Token
+	case *ast.ForStatement:
+		return s.Token
+	case *ast.ForInStatement:
+		return s.Token
+	case *ast.SwitchStatement:
+		return s.Token
+	case *ast.FuncStatement:
+		return s.Token
+	case *ast.ClassStatement:
+		return s.Token
+	}
+	return token.Token{}
+}
@@ -0,0 +1,452 @@
+package flow
+
+import (
+	"welle/internal/ast"
+	"welle/internal/token"
+)
+
+// event is a single read or write of a name, in evaluation order.
+type event struct {
+	def  bool
+	name string
+	tok  token.Token
+}
+
+type node struct {
+	id     int
+	events []event
+	preds  []*node
+}
+
+type graph struct {
+	nodes []*node
+	entry *node
+}
+
+// funcBody is a function (or the top-level program) whose body is analyzed
+// with its own graph. Nested functions are queued rather than inlined since
+// their bodies run at call time, not where they are defined.
+type funcBody struct {
+	params []*ast.Identifier
+	body   []ast.Statement
+	outer  map[string]bool
+}
+
+type builder struct {
+	g      *graph
+	cur    *node
+	breaks []*node
+	conts  []*node
+	hidden map[string]int
+	nested []funcBody
+}
+
+func newBuilder() *builder {
+	b := &builder{g: &graph{}, hidden: map[string]int{}}
+	b.g.entry = b.newNode()
+	b.cur = b.g.entry
+	return b
+}
+
+func (b *builder) newNode(preds ...*node) *node {
+	n := &node{id: len(b.g.nodes)}
+	for _, p := range preds {
+		if p != nil {
+			n.preds = append(n.preds, p)
+		}
+	}
+	b.g.nodes = append(b.g.nodes, n)
+	return n
+}
+
+// jump ends the current path: anything that follows is unreachable until a
+// new node with predecessors is started.
+func (b *builder) jump(target *node) {
+	if target != nil {
+		target.preds = append(target.preds, b.cur)
+	}
+	b.cur = b.newNode()
+}
+
+func (b *builder) use(id *ast.Identifier) {
+	if id == nil || b.hidden[id.Value] > 0 {
+		return
+	}
+	b.cur.events = append(b.cur.events, event{name: id.Value, tok: id.Token})
+}
+
+func (b *builder) define(id *ast.Identifier) {
+	if id == nil || id.Value == "_" || b.hidden[id.Value] > 0 {
+		return
+	}
+	b.cur.events = append(b.cur.events, event{def: true, name: id.Value, tok: id.Token})
+}
+
+func (b *builder) block(bs *ast.BlockStatement) {
+	if bs == nil {
+		return
+	}
+	b.stmts(bs.Statements)
+}
+
+func (b *builder) stmts(list []ast.Statement) {
+	for _, st := range list {
+		b.stmt(st)
+	}
+}
+
+func (b *builder) stmt(st ast.Statement) {
+	switch n := st.(type) {
+	case nil:
+		return
+	case *ast.BlockStatement:
+		b.block(n)
+	case *ast.ExpressionStatement:
+		b.expr(n.Expression)
+	case *ast.AssignStatement:
+		b.assign(n.Name, n.Op, n.Value)
+	case *ast.IndexAssignStatement:
+		b.expr(n.Left)
+		b.expr(n.Value)
+	case *ast.MemberAssignStatement:
+		b.expr(n.Object)
+		b.expr(n.Value)
+	case *ast.DestructureAssignStatement:
+		b.expr(n.Value)
+		for _, t := range n.Targets {
+			if t != nil {
+				b.define(t.Name)
+			}
+		}
+	case *ast.ReturnStatement:
+		for _, v := range n.ReturnValues {
+			b.expr(v)
+		}
+		b.jump(nil)
+	case *ast.DelStatement:
+		b.expr(n.Target)
+	case *ast.YieldStatement:
+		b.expr(n.Value)
+	case *ast.ThrowStatement:
+		b.expr(n.Value)
+		b.jump(nil)
+	case *ast.AssertStatement:
+		b.expr(n.Condition)
+		b.expr(n.Message)
+	case *ast.BreakStatement:
+		if len(b.breaks) > 0 {
+			b.jump(b.breaks[len(b.breaks)-1])
+		}
+	case *ast.ContinueStatement:
+		if len(b.conts) > 0 {
+			b.jump(b.conts[len(b.conts)-1])
+		}
+	case *ast.DeferStatement:
+		// Deferred calls run at function exit; their reads are not modeled.
+		return
+	case *ast.ImportStatement:
+		b.define(n.Alias)
+	case *ast.FromImportStatement:
+		for _, it := range n.Items {
+			if it.Alias != nil {
+				b.define(it.Alias)
+			} else {
+				b.define(it.Name)
+			}
+		}
+	case *ast.ExportStatement:
+		b.stmt(n.Stmt)
+	case *ast.FuncStatement:
+		for _, d := range n.Defaults {
+			b.expr(d)
+		}
+		b.define(n.Name)
+		if n.Body != nil {
+			b.nested = append(b.nested, funcBody{params: n.Parameters, body: n.Body.Statements})
+		}
+	case *ast.ClassStatement:
+		for _, d := range n.Defaults {
+			b.expr(d)
+		}
+		for _, m := range n.Methods {
+			for _, d := range m.Defaults {
+				b.expr(d)
+			}
+		}
+		b.define(n.Name)
+		for _, m := range n.Methods {
+			if m.Body != nil {
+				params, _ := ast.MethodParams(m)
+				b.nested = append(b.nested, funcBody{params: params, body: m.Body.Statements})
+			}
+		}
+	case *ast.IfStatement:
+		b.expr(n.Condition)
+		split := b.cur
+		b.cur = b.newNode(split)
+		b.stmt(n.Consequence)
+		thenEnd := b.cur
+		b.cur = b.newNode(split)
+		b.stmt(n.Alternative)
+		b.cur = b.newNode(thenEnd, b.cur)
+	case *ast.WhileStatement:
+		header := b.newNode(b.cur)
+		b.cur = header
+		b.expr(n.Condition)
+		condEnd := b.cur
+		exit := b.newNode()
+		if lit, ok := n.Condition.(*ast.BooleanLiteral); !ok || !lit.Value {
+			// `while (true)` is only left through break/return/throw.
+			exit.preds = append(exit.preds, condEnd)
+		}
+		b.cur = b.newNode(condEnd)
+		b.loop(exit, header, func() { b.block(n.Body) })
+		header.preds = append(header.preds, b.cur)
+		b.cur = exit
+	case *ast.ForStatement:
+		b.stmt(n.Init)
+		header := b.newNode(b.cur)
+		b.cur = header
+		var exit *node
+		if n.Cond != nil {
+			b.expr(n.Cond)
+			exit = b.newNode(b.cur)
+		} else {
+			exit = b.newNode()
+		}
+		post := b.newNode()
+		b.cur = b.newNode(b.cur)
+		b.loop(exit, post, func() { b.block(n.Body) })
+		post.preds = append(post.preds, b.cur)
+		b.cur = post
+		b.stmt(n.Post)
+		header.preds = append(header.preds, b.cur)
+		b.cur = exit
+	case *ast.ForInStatement:
+		b.expr(n.Iterable)
+		header := b.newNode(b.cur)
+		exit := b.newNode(header)
+		b.cur = b.newNode(header)
+		if n.Destruct {
+			b.define(n.Key)
+			b.define(n.Value)
+		} else {
+			b.define(n.Var)
+		}
+		b.loop(exit, header, func() { b.block(n.Body) })
+		header.preds = append(header.preds, b.cur)
+		b.cur = exit
+	case *ast.SwitchStatement:
+		b.expr(n.Value)
+		exit := b.newNode()
+		b.breaks = append(b.breaks, exit)
+		for _, c := range n.Cases {
+			if c == nil {
+				continue
+			}
+			for _, v := range c.Values {
+				b.expr(v)
+			}
+			chain := b.cur
+			b.cur = b.newNode(chain)
+			b.block(c.Body)
+			exit.preds = append(exit.preds, b.cur)
+			b.cur = b.newNode(chain)
+		}
+		b.block(n.Default)
+		exit.preds = append(exit.preds, b.cur)
+		b.breaks = b.breaks[:len(b.breaks)-1]
+		b.cur = exit
+	case *ast.TryStatement:
+		entry := b.cur
+		b.cur = b.newNode(entry)
+		b.block(n.TryBlock)
+		tryEnd := b.cur
+		after := tryEnd
+		if n.CatchBlock != nil {
+			// An error may be raised anywhere in the try block; the state at
+			// its start is the most conservative view of that.
+			b.cur = b.newNode(entry, tryEnd)
+			b.expr(n.CatchFilter)
+			b.define(n.CatchName)
+			b.block(n.CatchBlock)
+			after = b.newNode(tryEnd, b.cur)
+		}
+		b.cur = after
+		if n.FinallyBlock != nil {
+			b.cur = b.newNode(after)
+			b.block(n.FinallyBlock)
+		}
+	}
+}
+
+func (b *builder) loop(exit, cont *node, body func()) {
+	b.breaks = append(b.breaks, exit)
+	b.conts = append(b.conts, cont)
+	body()
+	b.breaks = b.breaks[:len(b.breaks)-1]
+	b.conts = b.conts[:len(b.conts)-1]
+}
+
+func (b *builder) assign(name *ast.Identifier, op token.Type, value ast.Expression) {
+	if op != "" && op != token.ASSIGN && op != token.WALRUS {
+		b.use(name)
+	}
+	b.expr(value)
+	b.define(name)
+}
+
+// branch evaluates fn on a path that may be skipped, then joins it back.
+func (b *builder) branch(fn func()) {
+	split := b.cur
+	b.cur = b.newNode(split)
+	fn()
+	b.cur = b.newNode(split, b.cur)
+}
+
+func (b *builder) expr(e ast.Expression) {
+	switch n := e.(type) {
+	case nil:
+		return
+	case *ast.Identifier:
+		b.use(n)
+	case *ast.PrefixExpression:
+		b.expr(n.Right)
+	case *ast.InfixExpression:
+		b.expr(n.Left)
+		if n.Operator == "and" || n.Operator == "or" || n.Operator == "??" {
+			b.branch(func() { b.expr(n.Right) })
+			return
+		}
+		b.expr(n.Right)
+	case *ast.ConditionalExpression:
+		b.ternary(n.Cond, n.Then, n.Else)
+	case *ast.CondExpr:
+		b.ternary(n.Cond, n.Then, n.Else)
+	case *ast.AssignExpression:
+		switch left := n.Left.(type) {
+		case *ast.Identifier:
+			b.assign(left, n.Op, n.Value)
+		case *ast.IndexExpression:
+			b.expr(left.Left)
+			b.expr(left.Index)
+			b.expr(n.Value)
+		case *ast.MemberExpression:
+			b.expr(left.Object)
+			b.expr(n.Value)
+		default:
+			b.expr(n.Value)
+		}
+	case *ast.CallExpression:
+		b.expr(n.Function)
+		for _, a := range n.Arguments {
+			b.expr(a)
+		}
+	case *ast.SpreadExpression:
+		b.expr(n.Value)
+	case *ast.MemberExpression:
+		b.expr(n.Object)
+	case *ast.IndexExpression:
+		b.expr(n.Left)
+		b.expr(n.Index)
+	case *ast.SliceExpression:
+		b.expr(n.Left)
+		b.expr(n.Low)
+		b.expr(n.High)
+		b.expr(n.Step)
+	case *ast.ListLiteral:
+		for _, el := range n.Elements {
+			b.expr(el)
+		}
+	case *ast.SetLiteral:
+		for _, el := range n.Elements {
+			b.expr(el)
+		}
+	case *ast.TupleLiteral:
+		for _, el := range n.Elements {
+			b.expr(el)
+		}
+	case *ast.NamedTupleLiteral:
+		for _, f := range n.Fields {
+			b.expr(f.Value)
+		}
+	case *ast.DictLiteral:
+		for _, p := range n.Pairs {
+			if p.Spread != nil {
+				b.expr(p.Spread)
+				continue
+			}
+			if p.Shorthand != nil {
+				b.use(p.Shorthand)
+				continue
+			}
+			b.expr(p.Key)
+			b.expr(p.Value)
+		}
+	case *ast.ListComprehension:
+		b.expr(n.Seq)
+		if n.Var != nil {
+			b.hidden[n.Var.Value]++
+		}
+		b.branch(func() {
+			b.expr(n.Filter)
+			b.expr(n.Elem)
+		})
+		if n.Var != nil {
+			b.hidden[n.Var.Value]--
+		}
+	case *ast.MatchExpression:
+		b.expr(n.Value)
+		var ends []*node
+		for _, c := range n.Cases {
+			if c == nil {
+				continue
+			}
+			for _, v := range c.Values {
+				b.expr(v)
+			}
+			chain := b.cur
+			b.cur = b.newNode(chain)
+			for _, v := range c.Values {
+				if pat, ok := v.(*ast.MatchPattern); ok {
+					for _, id := range pat.Names() {
+						b.define(id)
+					}
+				}
+			}
+			b.expr(c.Guard)
+			b.expr(c.Result)
+			ends = append(ends, b.cur)
+			b.cur = b.newNode(chain)
+		}
+		b.expr(n.Default)
+		b.cur = b.newNode(append(ends, b.cur)...)
+	case *ast.MatchPattern:
+		for _, v := range n.Values() {
+			b.expr(v)
+		}
+	case *ast.TemplateLiteral:
+		b.expr(n.Tag)
+		for _, ex := range n.Exprs {
+			b.expr(ex)
+		}
+	case *ast.FunctionLiteral:
+		for _, d := range n.Defaults {
+			b.expr(d)
+		}
+		if n.Body != nil {
+			b.nested = append(b.nested, funcBody{params: n.Parameters, body: n.Body.Statements})
+		}
+	}
+}
+
+func (b *builder) ternary(cond, then, els ast.Expression) {
+	b.expr(cond)
+	split := b.cur
+	b.cur = b.newNode(split)
+	b.expr(then)
+	thenEnd := b.cur
+	b.cur = b.newNode(split)
+	b.expr(els)
+	b.cur = b.newNode(thenEnd, b.cur)
+}
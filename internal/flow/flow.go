@@ -0,0 +1,178 @@
+// Package flow builds control-flow graphs over function bodies and runs
+// small dataflow analyses on them. It is shared by the linter and the
+// bytecode compiler so both report the same findings.
+package flow
+
+import (
+	"fmt"
+	"sort"
+
+	"welle/internal/ast"
+	"welle/internal/diag"
+)
+
+// UseBeforeAssign reports reads of local names that are not assigned on
+// every control-flow path leading to them. Names that resolve to an
+// enclosing scope, builtins, and anything never assigned in the analyzed
+// scope are left alone.
+func UseBeforeAssign(program *ast.Program) []diag.Diagnostic {
+	if program == nil {
+		return nil
+	}
+	var out []diag.Diagnostic
+	queue := []funcBody{{body: program.Statements, outer: map[string]bool{}}}
+	for len(queue) > 0 {
+		fb := queue[0]
+		queue = queue[1:]
+
+		b := newBuilder()
+		for _, p := range fb.params {
+			b.define(p)
+		}
+		b.stmts(fb.body)
+
+		locals := map[string]bool{}
+		for _, n := range b.g.nodes {
+			for _, ev := range n.events {
+				if ev.def {
+					locals[ev.name] = true
+				}
+			}
+		}
+		tracked := map[string]int{}
+		for name := range locals {
+			if !fb.outer[name] {
+				tracked[name] = len(tracked)
+			}
+		}
+		out = append(out, checkGraph(b.g, tracked)...)
+
+		inner := make(map[string]bool, len(fb.outer)+len(locals))
+		for name := range fb.outer {
+			inner[name] = true
+		}
+		for name := range locals {
+			inner[name] = true
+		}
+		for _, nf := range b.nested {
+			nf.outer = inner
+			queue = append(queue, nf)
+		}
+	}
+	return out
+}
+
+type bitset []bool
+
+func checkGraph(g *graph, tracked map[string]int) []diag.Diagnostic {
+	if len(tracked) == 0 {
+		return nil
+	}
+	size := len(tracked)
+
+	// must[n]: names assigned on every path reaching the end of n.
+	// may[n]:  names assigned on at least one such path.
+	must := make([]bitset, len(g.nodes))
+	may := make([]bitset, len(g.nodes))
+	for i := range g.nodes {
+		must[i] = make(bitset, size)
+		may[i] = make(bitset, size)
+		if g.nodes[i] != g.entry {
+			for j := range must[i] {
+				must[i][j] = true
+			}
+		}
+	}
+
+	in := func(n *node) (bitset, bitset) {
+		m := make(bitset, size)
+		y := make(bitset, size)
+		if n == g.entry {
+			return m, y
+		}
+		for j := range m {
+			m[j] = true
+		}
+		for _, p := range n.preds {
+			for j := range m {
+				m[j] = m[j] && must[p.id][j]
+				y[j] = y[j] || may[p.id][j]
+			}
+		}
+		return m, y
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, n := range g.nodes {
+			m, y := in(n)
+			for _, ev := range n.events {
+				if idx, ok := tracked[ev.name]; ok && ev.def {
+					m[idx] = true
+					y[idx] = true
+				}
+			}
+			for j := 0; j < size; j++ {
+				if m[j] != must[n.id][j] || y[j] != may[n.id][j] {
+					changed = true
+				}
+			}
+			must[n.id] = m
+			may[n.id] = y
+		}
+	}
+
+	type hit struct {
+		ev    event
+		maybe bool
+	}
+	var hits []hit
+	for _, n := range g.nodes {
+		m, y := in(n)
+		for _, ev := range n.events {
+			idx, ok := tracked[ev.name]
+			if !ok {
+				continue
+			}
+			if ev.def {
+				m[idx] = true
+				y[idx] = true
+				continue
+			}
+			if !m[idx] {
+				hits = append(hits, hit{ev: ev, maybe: y[idx]})
+			}
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		a, b := hits[i].ev.tok, hits[j].ev.tok
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+
+	var out []diag.Diagnostic
+	reported := map[string]bool{}
+	for _, h := range hits {
+		if reported[h.ev.name] {
+			continue
+		}
+		reported[h.ev.name] = true
+		msg := fmt.Sprintf("'%s' is used before assignment", h.ev.name)
+		if h.maybe {
+			msg = fmt.Sprintf("'%s' may be used before assignment", h.ev.name)
+		}
+		out = append(out, diag.Diagnostic{
+			Code:     "WL0013",
+			Message:  msg,
+			Severity: diag.SeverityWarning,
+			Range: diag.Range{
+				Line:   h.ev.tok.Line,
+				Col:    h.ev.tok.Col,
+				Length: len([]rune(h.ev.name)),
+			},
+		})
+	}
+	return out
+}
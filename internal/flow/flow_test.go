@@ -0,0 +1,94 @@
+package flow
+
+import (
+	"testing"
+
+	"welle/internal/lexer"
+	"welle/internal/parser"
+)
+
+func analyze(t *testing.T, src string) []string {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	var out []string
+	for _, d := range UseBeforeAssign(prog) {
+		out = append(out, d.Message)
+	}
+	return out
+}
+
+func TestUseBeforeAssign(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "if without else",
+			src:  "func f(c) {\n  if (c) { x = 1 }\n  return x\n}\n",
+			want: []string{"'x' may be used before assignment"},
+		},
+		{
+			name: "if with else",
+			src:  "func f(c) {\n  if (c) { x = 1 } else { x = 2 }\n  return x\n}\n",
+		},
+		{
+			name: "read before write",
+			src:  "func f() {\n  print(y)\n  y = 1\n  return y\n}\n",
+			want: []string{"'y' is used before assignment"},
+		},
+		{
+			name: "branch returns early",
+			src:  "func f(c) {\n  if (c) { x = 1 } else { return 0 }\n  return x\n}\n",
+		},
+		{
+			name: "loop body may not run",
+			src:  "func f(xs) {\n  for (v in xs) { last = v }\n  return last\n}\n",
+			want: []string{"'last' may be used before assignment"},
+		},
+		{
+			name: "while true with break",
+			src:  "func f() {\n  while (true) { x = 1\n break }\n  return x\n}\n",
+		},
+		{
+			name: "short circuit assignment",
+			src:  "func f(c) {\n  ok = c and (v := 1)\n  return v\n}\n",
+			want: []string{"'v' may be used before assignment"},
+		},
+		{
+			name: "outer names are not tracked",
+			src:  "count = 0\nfunc f() {\n  count = count + 1\n  return count\n}\n",
+		},
+		{
+			name: "catch path",
+			src:  "func f() {\n  try { x = g() } catch (e) { print(e) }\n  return x\n}\n",
+			want: []string{"'x' may be used before assignment"},
+		},
+		{
+			name: "top level",
+			src:  "if (true) { y = 1 }\nprint(y)\n",
+			want: []string{"'y' may be used before assignment"},
+		},
+		{
+			name: "comprehension variable",
+			src:  "func f(xs) {\n  return [v * 2 for v in xs]\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := analyze(t, tt.src)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
@@ -103,6 +103,9 @@ func (s *scopeIndex) addScopesForStatement(parent *blockScope, stmt ast.Statemen
 		if st.TryBlock != nil {
 			s.addBlockScope(parent, st.TryBlock)
 		}
+		if st.CatchFilter != nil {
+			s.addScopesForExpression(parent, st.CatchFilter)
+		}
 		if st.CatchBlock != nil {
 			s.addBlockScope(parent, st.CatchBlock)
 		}
@@ -110,6 +113,9 @@ func (s *scopeIndex) addScopesForStatement(parent *blockScope, stmt ast.Statemen
 			s.addBlockScope(parent, st.FinallyBlock)
 		}
 	case *ast.FuncStatement:
+		for _, d := range st.Defaults {
+			s.addScopesForExpression(parent, d)
+		}
 		if st.Body != nil {
 			s.addBlockScope(parent, st.Body)
 		}
@@ -131,8 +137,15 @@ func (s *scopeIndex) addScopesForStatement(parent *blockScope, stmt ast.Statemen
 		s.addScopesForExpression(parent, st.Value)
 	case *ast.DeferStatement:
 		s.addScopesForExpression(parent, st.Call)
+	case *ast.DelStatement:
+		s.addScopesForExpression(parent, st.Target)
+	case *ast.YieldStatement:
+		s.addScopesForExpression(parent, st.Value)
 	case *ast.ThrowStatement:
 		s.addScopesForExpression(parent, st.Value)
+	case *ast.AssertStatement:
+		s.addScopesForExpression(parent, st.Condition)
+		s.addScopesForExpression(parent, st.Message)
 	case *ast.ExportStatement:
 		if st.Stmt != nil {
 			s.addScopesForStatement(parent, st.Stmt)
@@ -145,6 +158,9 @@ func (s *scopeIndex) addScopesForStatement(parent *blockScope, stmt ast.Statemen
 func (s *scopeIndex) addScopesForExpression(parent *blockScope, expr ast.Expression) {
 	switch e := expr.(type) {
 	case *ast.FunctionLiteral:
+		for _, d := range e.Defaults {
+			s.addScopesForExpression(parent, d)
+		}
 		if e.Body != nil {
 			s.addBlockScope(parent, e.Body)
 		}
@@ -185,6 +201,10 @@ func (s *scopeIndex) addScopesForExpression(parent *blockScope, expr ast.Express
 		for _, el := range e.Elements {
 			s.addScopesForExpression(parent, el)
 		}
+	case *ast.SetLiteral:
+		for _, el := range e.Elements {
+			s.addScopesForExpression(parent, el)
+		}
 	case *ast.ListComprehension:
 		s.addScopesForExpression(parent, e.Seq)
 		s.addScopesForExpression(parent, e.Filter)
@@ -193,8 +213,16 @@ func (s *scopeIndex) addScopesForExpression(parent *blockScope, expr ast.Express
 		for _, el := range e.Elements {
 			s.addScopesForExpression(parent, el)
 		}
+	case *ast.NamedTupleLiteral:
+		for _, f := range e.Fields {
+			s.addScopesForExpression(parent, f.Value)
+		}
 	case *ast.DictLiteral:
 		for _, p := range e.Pairs {
+			if p.Spread != nil {
+				s.addScopesForExpression(parent, p.Spread)
+				continue
+			}
 			if p.Shorthand != nil {
 				s.addScopesForExpression(parent, p.Shorthand)
 				continue
@@ -204,6 +232,8 @@ func (s *scopeIndex) addScopesForExpression(parent *blockScope, expr ast.Express
 		}
 	case *ast.MatchExpression:
 		s.addMatchScope(parent, e)
+	case *ast.MatchPattern:
+		s.addScopesForExpression(parent, e.Value)
 	case *ast.TemplateLiteral:
 		if e.Tag != nil {
 			s.addScopesForExpression(parent, e.Tag)
@@ -256,6 +286,9 @@ func (s *scopeIndex) addMatchScope(parent *blockScope, expr *ast.MatchExpression
 		for _, v := range c.Values {
 			s.addScopesForExpression(scope, v)
 		}
+		if c.Guard != nil {
+			s.addScopesForExpression(scope, c.Guard)
+		}
 		s.addScopesForExpression(scope, c.Result)
 	}
 	if expr.Default != nil {
@@ -81,6 +81,13 @@ func (p *Printer) hasBlankLineBetween(a, b int) bool {
 }
 
 func (p *Printer) printProgram(program *ast.Program) {
+	if program.Edition != "" {
+		p.write("#welle " + program.Edition)
+		p.newline()
+		if len(program.Statements) > 0 {
+			p.newline()
+		}
+	}
 	p.printStatementList(program.Statements, p.index.root)
 }
 
@@ -272,6 +279,12 @@ func (p *Printer) printStatement(stmt ast.Statement, trailing []Comment) {
 				p.formatExpr(v, precLowest)
 			}
 		}
+	case *ast.YieldStatement:
+		p.write("yield")
+		if s.Value != nil {
+			p.write(" ")
+			p.formatExpr(s.Value, precLowest)
+		}
 	case *ast.DestructureAssignStatement:
 		p.write("(")
 		for i, t := range s.Targets {
@@ -282,6 +295,9 @@ func (p *Printer) printStatement(stmt ast.Statement, trailing []Comment) {
 				if t.Star {
 					p.write("*")
 				}
+				if t.Field != nil {
+					p.write(t.Field.Value + ": ")
+				}
 				if t.Name != nil {
 					p.write(t.Name.Value)
 				}
@@ -294,9 +310,19 @@ func (p *Printer) printStatement(stmt ast.Statement, trailing []Comment) {
 	case *ast.DeferStatement:
 		p.write("defer ")
 		p.formatExpr(s.Call, precLowest)
+	case *ast.DelStatement:
+		p.write("del ")
+		p.formatExpr(s.Target, precLowest)
 	case *ast.ThrowStatement:
 		p.write("throw ")
 		p.formatExpr(s.Value, precLowest)
+	case *ast.AssertStatement:
+		p.write("assert ")
+		p.formatExpr(s.Condition, precLowest)
+		if s.Message != nil {
+			p.write(", ")
+			p.formatExpr(s.Message, precLowest)
+		}
 	case *ast.BreakStatement:
 		p.write("break")
 	case *ast.ContinueStatement:
@@ -487,6 +513,10 @@ func (p *Printer) printStatement(stmt ast.Statement, trailing []Comment) {
 			if s.CatchName != nil {
 				p.write(s.CatchName.Value)
 			}
+			if s.CatchFilter != nil {
+				p.write(": ")
+				p.formatExpr(s.CatchFilter, precLowest)
+			}
 			p.write(") ")
 			if !p.printBlockWithHeaderComments(s.CatchBlock, headerCatch) && len(headerCatch) > 0 {
 				footer = append(footer, headerCatch...)
@@ -542,6 +572,9 @@ func (p *Printer) printStatementInline(stmt ast.Statement) {
 				if t.Star {
 					p.write("*")
 				}
+				if t.Field != nil {
+					p.write(t.Field.Value + ": ")
+				}
 				if t.Name != nil {
 					p.write(t.Name.Value)
 				}
@@ -562,12 +595,28 @@ func (p *Printer) printStatementInline(stmt ast.Statement) {
 				p.formatExpr(v, precLowest)
 			}
 		}
+	case *ast.YieldStatement:
+		p.write("yield")
+		if s.Value != nil {
+			p.write(" ")
+			p.formatExpr(s.Value, precLowest)
+		}
 	case *ast.DeferStatement:
 		p.write("defer ")
 		p.formatExpr(s.Call, precLowest)
+	case *ast.DelStatement:
+		p.write("del ")
+		p.formatExpr(s.Target, precLowest)
 	case *ast.ThrowStatement:
 		p.write("throw ")
 		p.formatExpr(s.Value, precLowest)
+	case *ast.AssertStatement:
+		p.write("assert ")
+		p.formatExpr(s.Condition, precLowest)
+		if s.Message != nil {
+			p.write(", ")
+			p.formatExpr(s.Message, precLowest)
+		}
 	case *ast.BreakStatement:
 		p.write("break")
 	case *ast.ContinueStatement:
@@ -704,9 +753,12 @@ func isSimpleStatement(stmt ast.Statement) bool {
 		*ast.IndexAssignStatement,
 		*ast.MemberAssignStatement,
 		*ast.ReturnStatement,
+		*ast.YieldStatement,
 		*ast.DestructureAssignStatement,
 		*ast.DeferStatement,
+		*ast.DelStatement,
 		*ast.ThrowStatement,
+		*ast.AssertStatement,
 		*ast.BreakStatement,
 		*ast.ContinueStatement,
 		*ast.PassStatement,
@@ -829,6 +881,16 @@ func (p *Printer) formatExpr(expr ast.Expression, parentPrec int) {
 			p.write(",")
 		}
 		p.write(")")
+	case *ast.NamedTupleLiteral:
+		p.write("(")
+		for i, f := range e.Fields {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.write(f.Name.Value + ": ")
+			p.formatExpr(f.Value, precLowest)
+		}
+		p.write(")")
 	case *ast.ListLiteral:
 		p.write("[")
 		for i, el := range e.Elements {
@@ -838,6 +900,15 @@ func (p *Printer) formatExpr(expr ast.Expression, parentPrec int) {
 			p.formatExpr(el, precLowest)
 		}
 		p.write("]")
+	case *ast.SetLiteral:
+		p.write("#[")
+		for i, el := range e.Elements {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.formatExpr(el, precLowest)
+		}
+		p.write("]")
 	case *ast.ListComprehension:
 		p.write("[")
 		p.formatExpr(e.Elem, precLowest)
@@ -862,6 +933,10 @@ func (p *Printer) formatExpr(expr ast.Expression, parentPrec int) {
 				p.write(pair.Shorthand.Value)
 				continue
 			}
+			if pair.Spread != nil {
+				p.formatExpr(pair.Spread, precLowest)
+				continue
+			}
 			p.formatExpr(pair.Key, precLowest)
 			p.write(": ")
 			p.formatExpr(pair.Value, precLowest)
@@ -979,11 +1054,17 @@ func (p *Printer) formatExpr(expr ast.Expression, parentPrec int) {
 				p.write(", ")
 			}
 			p.write(pident.Value)
+			if d := ast.ParamDefault(e.Defaults, i); d != nil {
+				p.write(" = ")
+				p.formatExpr(d, precLowest)
+			}
 		}
 		p.write(") ")
 		p.printBlock(e.Body)
 	case *ast.MatchExpression:
 		p.printMatchExpression(e)
+	case *ast.MatchPattern:
+		p.formatExpr(e.Value, parentPrec)
 	default:
 		p.write("/* unsupported */")
 	}
@@ -1250,7 +1331,13 @@ func (p *Printer) printMatchCase(item matchCaseItem, trailing []Comment) {
 			if i > 0 {
 				p.write(", ")
 			}
-			p.formatExpr(v, precLowest)
+			// `x if c else y` needs parentheses here, where `if` starts
+			// the guard.
+			p.formatExpr(v, precOr)
+		}
+		if item.clause.Guard != nil {
+			p.write(" if ")
+			p.formatExpr(item.clause.Guard, precLowest)
 		}
 		p.write(" { ")
 		p.formatExpr(item.clause.Result, precLowest)
@@ -1282,12 +1369,18 @@ func startLineStatement(stmt ast.Statement) int {
 		return s.Token.Line
 	case *ast.ReturnStatement:
 		return s.Token.Line
+	case *ast.YieldStatement:
+		return s.Token.Line
 	case *ast.DestructureAssignStatement:
 		return s.Token.Line
 	case *ast.DeferStatement:
 		return s.Token.Line
+	case *ast.DelStatement:
+		return s.Token.Line
 	case *ast.ThrowStatement:
 		return s.Token.Line
+	case *ast.AssertStatement:
+		return s.Token.Line
 	case *ast.BreakStatement:
 		return s.Token.Line
 	case *ast.ContinueStatement:
@@ -1374,12 +1467,24 @@ func endLineStatement(stmt ast.Statement) int {
 			return endLineExpr(s.ReturnValues[len(s.ReturnValues)-1])
 		}
 		return s.Token.Line
+	case *ast.YieldStatement:
+		if s.Value != nil {
+			return endLineExpr(s.Value)
+		}
+		return s.Token.Line
 	case *ast.DestructureAssignStatement:
 		return endLineExpr(s.Value)
 	case *ast.DeferStatement:
 		return endLineExpr(s.Call)
+	case *ast.DelStatement:
+		return endLineExpr(s.Target)
 	case *ast.ThrowStatement:
 		return endLineExpr(s.Value)
+	case *ast.AssertStatement:
+		if s.Message != nil {
+			return endLineExpr(s.Message)
+		}
+		return endLineExpr(s.Condition)
 	case *ast.BreakStatement:
 		return s.Token.Line
 	case *ast.ContinueStatement:
@@ -1469,16 +1574,22 @@ func startLineExpr(expr ast.Expression) int {
 		return startLineExpr(e.Left)
 	case *ast.ListLiteral:
 		return e.Token.Line
+	case *ast.SetLiteral:
+		return e.Token.Line
 	case *ast.ListComprehension:
 		return e.Token.Line
 	case *ast.TupleLiteral:
 		return e.Token.Line
+	case *ast.NamedTupleLiteral:
+		return e.Token.Line
 	case *ast.DictLiteral:
 		return e.Token.Line
 	case *ast.FunctionLiteral:
 		return e.Token.Line
 	case *ast.MatchExpression:
 		return e.Token.Line
+	case *ast.MatchPattern:
+		return e.Token.Line
 	default:
 		return 1
 	}
@@ -1488,6 +1599,8 @@ func endLineExpr(expr ast.Expression) int {
 	switch e := expr.(type) {
 	case *ast.Identifier:
 		return e.Token.Line
+	case *ast.MatchPattern:
+		return endLineExpr(e.Value)
 	case *ast.IntegerLiteral:
 		return e.Token.Line
 	case *ast.FloatLiteral:
@@ -1538,6 +1651,11 @@ func endLineExpr(expr ast.Expression) int {
 			return endLineExpr(e.Elements[len(e.Elements)-1])
 		}
 		return e.Token.Line
+	case *ast.SetLiteral:
+		if len(e.Elements) > 0 {
+			return endLineExpr(e.Elements[len(e.Elements)-1])
+		}
+		return e.Token.Line
 	case *ast.ListComprehension:
 		if e.Filter != nil {
 			return endLineExpr(e.Filter)
@@ -1551,9 +1669,17 @@ func endLineExpr(expr ast.Expression) int {
 			return endLineExpr(e.Elements[len(e.Elements)-1])
 		}
 		return e.Token.Line
+	case *ast.NamedTupleLiteral:
+		if len(e.Fields) > 0 {
+			return endLineExpr(e.Fields[len(e.Fields)-1].Value)
+		}
+		return e.Token.Line
 	case *ast.DictLiteral:
 		if len(e.Pairs) > 0 {
 			last := e.Pairs[len(e.Pairs)-1]
+			if last.Spread != nil {
+				return endLineExpr(last.Spread)
+			}
 			if last.Shorthand != nil {
 				return endLineExpr(last.Shorthand)
 			}
@@ -398,7 +398,7 @@ func Format(src string, opt Options) (string, error) {
 			prevUnaryTilde = false
 
 		case token.CASE, token.DEFAULT, token.ELSE, token.TRY, token.CATCH, token.FINALLY,
-			token.THROW, token.DEFER, token.RETURN, token.BREAK, token.CONTINUE, token.PASS,
+			token.THROW, token.ASSERT, token.DEFER, token.DEL, token.RETURN, token.BREAK, token.CONTINUE, token.PASS,
 			token.IMPORT, token.FROM, token.AS, token.EXPORT, token.NOT:
 			trimTrailingSpace()
 			if !atLineStart {
@@ -429,6 +429,7 @@ func Format(src string, opt Options) (string, error) {
 					prev.Type != token.DOT &&
 					prev.Type != token.ELLIPSIS &&
 					prev.Type != token.LBRACKET &&
+					prev.Type != token.AT &&
 					prev.Type != token.COMMA &&
 					prev.Type != token.COLON &&
 					!(prev.Type == token.MINUS && prevUnaryMinus) &&
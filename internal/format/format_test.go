@@ -191,6 +191,19 @@ func TestFormat_IsOperatorAndTemplate(t *testing.T) {
 	}
 }
 
+func TestFormat_DeprecatedAnnotation(t *testing.T) {
+	input := "@ deprecated( \"use g()\" )\nexport  func f() { return 1 }\n"
+	want := "@deprecated(\"use g()\")\nexport func f() { return 1 }\n"
+
+	formatted, err := Format(input, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if formatted != want {
+		t.Fatalf("unexpected formatting:\nwant: %q\ngot:  %q", want, formatted)
+	}
+}
+
 func TestFormat_DictUpdateAssign(t *testing.T) {
 	input := "d|=other\n"
 	want := "d |= other\n"
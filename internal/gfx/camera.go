@@ -0,0 +1,136 @@
+package gfx
+
+import (
+	"errors"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// camera is a 2D view onto the world: (x, y) is the world point shown at
+// the center of the screen and zoom is how many screen pixels one world
+// unit covers.
+type camera struct {
+	x, y, zoom float64
+	on         bool
+}
+
+// view maps world coordinates to screen coordinates for a screen of the
+// given size. With no camera set it is the identity.
+func (c camera) view(width, height int) affine {
+	if !c.on {
+		return identity
+	}
+	return translation(float64(width)/2, float64(height)/2).
+		then(scaling(c.zoom, c.zoom)).
+		then(translation(-c.x, -c.y))
+}
+
+// invert returns the inverse transform; ok is false when m is singular.
+func (m affine) invert() (affine, bool) {
+	det := m.a*m.d - m.b*m.c
+	if det == 0 || math.IsNaN(det) {
+		return affine{}, false
+	}
+	return affine{
+		a: m.d / det,
+		b: -m.b / det,
+		c: -m.c / det,
+		d: m.a / det,
+		e: (m.c*m.f - m.d*m.e) / det,
+		f: (m.b*m.e - m.a*m.f) / det,
+	}, true
+}
+
+// SetCamera centers the view on world point (x, y) at the given zoom. The
+// current transform is reset to the new view, so drawing that follows in
+// the same frame already uses it.
+func SetCamera(x, y, zoom float64) error {
+	s, err := getState()
+	if err != nil {
+		return err
+	}
+	if !(zoom > 0) || math.IsInf(zoom, 0) {
+		return errors.New("gfx_camera expects a positive zoom")
+	}
+	s.mu.Lock()
+	s.cam = camera{x: x, y: y, zoom: zoom, on: true}
+	s.transform = s.cam.view(s.width, s.height)
+	s.mu.Unlock()
+	return nil
+}
+
+// ResetCamera turns the camera off: world and screen coordinates coincide
+// again.
+func ResetCamera() error {
+	s, err := getState()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.cam = camera{}
+	s.transform = identity
+	s.mu.Unlock()
+	return nil
+}
+
+// ResetTransform sets the current transform to screen space, ignoring the
+// camera until the next frame; wrap it in Push/Pop to draw an overlay.
+func ResetTransform() error {
+	s, err := getState()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.transform = identity
+	s.mu.Unlock()
+	return nil
+}
+
+// ScreenToWorld converts a screen position, such as the mouse, to world
+// coordinates under the camera.
+func ScreenToWorld(x, y float64) (float64, float64, error) {
+	s, err := getState()
+	if err != nil {
+		return 0, 0, err
+	}
+	s.mu.Lock()
+	view := s.cam.view(s.width, s.height)
+	s.mu.Unlock()
+	inv, _ := view.invert()
+	p := inv.apply(x, y)
+	return p.x, p.y, nil
+}
+
+// WorldToScreen converts a world position to screen coordinates under the
+// camera.
+func WorldToScreen(x, y float64) (float64, float64, error) {
+	s, err := getState()
+	if err != nil {
+		return 0, 0, err
+	}
+	s.mu.Lock()
+	view := s.cam.view(s.width, s.height)
+	s.mu.Unlock()
+	p := view.apply(x, y)
+	return p.x, p.y, nil
+}
+
+// SetPixelScale shows every logical pixel as an n×n block: the window
+// grows to n times the size passed to Open while scripts keep drawing,
+// and reading the mouse, in the logical resolution.
+func SetPixelScale(n int) error {
+	s, err := getState()
+	if err != nil {
+		return err
+	}
+	if n <= 0 {
+		return errors.New("gfx_pixelScale expects a positive integer scale")
+	}
+	s.mu.Lock()
+	s.pixelScale = n
+	w, h := s.width*n, s.height*n
+	s.mu.Unlock()
+	ebiten.SetWindowSize(w, h)
+	return nil
+}
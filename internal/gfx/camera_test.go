@@ -0,0 +1,34 @@
+package gfx
+
+import "testing"
+
+func TestCameraViewRoundTrips(t *testing.T) {
+	if v := (camera{}).view(320, 240); v != identity {
+		t.Fatalf("camera off: view = %+v, want identity", v)
+	}
+	c := camera{x: 100, y: 50, zoom: 2, on: true}
+	view := c.view(320, 240)
+	if p := view.apply(100, 50); !near(p, 160, 120) {
+		t.Fatalf("camera target drawn at %v, want the screen center", p)
+	}
+	if p := view.apply(110, 40); !near(p, 180, 100) {
+		t.Fatalf("apply(110, 40) = %v, want (180, 100)", p)
+	}
+	inv, ok := view.invert()
+	if !ok {
+		t.Fatal("view is not invertible")
+	}
+	if p := inv.apply(180, 100); !near(p, 110, 40) {
+		t.Fatalf("screen (180, 100) maps to %v, want world (110, 40)", p)
+	}
+
+	m := translation(3, -4).then(rotation(0.7)).then(scaling(2, 0.5))
+	inv, _ = m.invert()
+	q := m.apply(5, 6)
+	if p := inv.apply(q.x, q.y); !near(p, 5, 6) {
+		t.Fatalf("inverse round trip gave %v, want (5, 6)", p)
+	}
+	if _, ok := scaling(0, 1).invert(); ok {
+		t.Fatal("a zero scale should not be invertible")
+	}
+}
@@ -0,0 +1,178 @@
+package gfx
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+	"os"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"welle/internal/runtimeio"
+)
+
+// recordFPS is the frame rate of recorded GIFs; GIF delays are in
+// hundredths of a second, so 25 fps is exactly 4 per frame.
+const recordFPS = 25
+
+// Options configures a gfx run.
+type Options struct {
+	// RecordPath, when set, records the first RecordSeconds of loop time
+	// to this GIF file and then ends the run.
+	RecordPath    string
+	RecordSeconds float64
+}
+
+// quantizer maps colors to the Plan 9 palette, caching each lookup since
+// frames tend to reuse a handful of colors.
+type quantizer struct {
+	cache map[color.RGBA]uint8
+}
+
+func (q *quantizer) paletted(frame *image.RGBA) *image.Paletted {
+	if q.cache == nil {
+		q.cache = map[color.RGBA]uint8{}
+	}
+	b := frame.Bounds()
+	out := image.NewPaletted(b, palette.Plan9)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := frame.Pix[(y-b.Min.Y)*frame.Stride:]
+		for x := 0; x < b.Dx(); x++ {
+			c := color.RGBA{R: row[4*x], G: row[4*x+1], B: row[4*x+2], A: 255}
+			idx, ok := q.cache[c]
+			if !ok {
+				idx = uint8(color.Palette(palette.Plan9).Index(c))
+				q.cache[c] = idx
+			}
+			out.Pix[(y-b.Min.Y)*out.Stride+x] = idx
+		}
+	}
+	return out
+}
+
+// recorder samples frames at recordFPS of loop time until it has covered
+// seconds.
+type recorder struct {
+	path    string
+	seconds float64
+	next    float64
+	q       quantizer
+	anim    gif.GIF
+	done    bool
+}
+
+func newRecorder(path string, seconds float64) (*recorder, error) {
+	if !(seconds > 0) || math.IsInf(seconds, 0) {
+		return nil, fmt.Errorf("gfx: recording length must be a positive number of seconds, got %v", seconds)
+	}
+	return &recorder{path: path, seconds: seconds}, nil
+}
+
+// wants reports whether the frame drawn at loop time now should be
+// captured.
+func (r *recorder) wants(now float64) bool {
+	return !r.done && now >= r.next
+}
+
+// add captures a frame drawn at loop time now and reports whether the
+// recording is complete.
+func (r *recorder) add(now float64, frame *image.RGBA) bool {
+	r.anim.Image = append(r.anim.Image, r.q.paletted(frame))
+	r.anim.Delay = append(r.anim.Delay, 100/recordFPS)
+	for r.next <= now {
+		r.next += 1.0 / recordFPS
+	}
+	return r.next >= r.seconds
+}
+
+func (r *recorder) encode(w io.Writer) error {
+	if len(r.anim.Image) == 0 {
+		return errors.New("gfx: no frames were recorded")
+	}
+	return gif.EncodeAll(w, &r.anim)
+}
+
+// finish writes the recording once.
+func (r *recorder) finish() error {
+	if r.done {
+		return nil
+	}
+	r.done = true
+	f, err := os.Create(r.path)
+	if err != nil {
+		return fmt.Errorf("gfx: %v", err)
+	}
+	if err := r.encode(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("gfx: %v", err)
+	}
+	return nil
+}
+
+func writePNG(path string, frame *image.RGBA) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("gfx: %v", err)
+	}
+	if err := png.Encode(f, frame); err != nil {
+		f.Close()
+		return fmt.Errorf("gfx: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("gfx: %v", err)
+	}
+	return nil
+}
+
+// capture grabs the finished frame for a pending screenshot or the
+// recording. Failures are kept and reported by the next Update.
+func (s *state) capture(screen *ebiten.Image) {
+	wantShot := s.shotPath != ""
+	wantRec := s.rec != nil && s.rec.wants(s.sched.elapsed)
+	if !wantShot && !wantRec {
+		return
+	}
+	w, h := screen.Size()
+	frame := image.NewRGBA(image.Rect(0, 0, w, h))
+	screen.ReadPixels(frame.Pix)
+	if wantShot {
+		if err := writePNG(s.shotPath, frame); err != nil && s.captureErr == nil {
+			s.captureErr = err
+		}
+		s.shotPath = ""
+	}
+	if wantRec && s.rec.add(s.sched.elapsed, frame) {
+		if err := s.rec.finish(); err != nil && s.captureErr == nil {
+			s.captureErr = err
+		}
+		s.shouldClose = true
+	}
+}
+
+// Screenshot saves the next rendered frame as a PNG file at path, without
+// the tuning overlay. Writing files needs the file system capability.
+func Screenshot(path string) error {
+	s, err := getState()
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return errors.New("gfx_screenshot expects a file path")
+	}
+	if !runtimeio.AllowFS() {
+		return fmt.Errorf("gfx: writing screenshot %q needs file system access (run with -allow-fs)", path)
+	}
+	s.mu.Lock()
+	s.shotPath = path
+	s.mu.Unlock()
+	return nil
+}
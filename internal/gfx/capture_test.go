@@ -0,0 +1,67 @@
+package gfx
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+func solidFrame(c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func TestRecorderSamplesLoopTime(t *testing.T) {
+	if _, err := newRecorder("x.gif", 0); err == nil {
+		t.Fatal("expected an error for a zero-length recording")
+	}
+	r, err := newRecorder("x.gif", 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := solidFrame(color.RGBA{R: 255, A: 255})
+	blue := solidFrame(color.RGBA{B: 255, A: 255})
+	// Frames drawn at 60 fps: only those at or past each 1/25 s mark are
+	// kept, and the recording completes once 0.1 s is covered.
+	var kept int
+	done := false
+	for i := 0; i < 12 && !done; i++ {
+		now := float64(i) / 60
+		if !r.wants(now) {
+			continue
+		}
+		frame := red
+		if kept%2 == 1 {
+			frame = blue
+		}
+		kept++
+		done = r.add(now, frame)
+	}
+	if !done || kept != 3 {
+		t.Fatalf("kept %d frames, done %v; want 3 frames then done", kept, done)
+	}
+
+	var buf bytes.Buffer
+	if err := r.encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 3 || anim.Delay[0] != 4 {
+		t.Fatalf("decoded %d frames with delay %v", len(anim.Image), anim.Delay)
+	}
+	if got := color.RGBAModel.Convert(anim.Image[1].At(2, 1)).(color.RGBA); got.B < 200 || got.R > 50 {
+		t.Fatalf("second frame pixel = %v, want blue", got)
+	}
+
+	if err := (&recorder{}).encode(&buf); err == nil {
+		t.Fatal("expected an error encoding an empty recording")
+	}
+}
@@ -18,6 +18,8 @@ type LoopFuncs struct {
 	Setup  func() error
 	Update func(dt float64) error
 	Draw   func() error
+	// Call runs a callback registered with Every.
+	Call func(fn any) error
 }
 
 type state struct {
@@ -33,6 +35,17 @@ type state struct {
 	start       time.Time
 	lastTime    time.Time
 	shouldClose bool
+	sched       scheduler
+	transform   affine
+	saved       []savedState
+	line        lineStyle
+	cam         camera
+	pixelScale  int
+	tune        tuner
+	stepper     stepper
+	shotPath    string
+	rec         *recorder
+	captureErr  error
 }
 
 type command interface {
@@ -81,12 +94,23 @@ var (
 	cur     *state
 )
 
-func Run(loop LoopFuncs) error {
+func Run(loop LoopFuncs, opts Options) error {
 	s := &state{
-		width:  640,
-		height: 480,
-		title:  "Welle",
-		clear:  color.RGBA{A: 255},
+		width:      640,
+		height:     480,
+		title:      "Welle",
+		clear:      color.RGBA{A: 255},
+		transform:  identity,
+		line:       defaultLineStyle,
+		pixelScale: 1,
+		tune:       tuner{dragging: -1},
+	}
+	if opts.RecordPath != "" {
+		rec, err := newRecorder(opts.RecordPath, opts.RecordSeconds)
+		if err != nil {
+			return err
+		}
+		s.rec = rec
 	}
 	stateMu.Lock()
 	cur = s
@@ -106,8 +130,8 @@ func Run(loop LoopFuncs) error {
 	s.mu.Lock()
 	s.start = time.Now()
 	s.lastTime = s.start
-	width := s.width
-	height := s.height
+	width := s.width * s.pixelScale
+	height := s.height * s.pixelScale
 	title := s.title
 	s.mu.Unlock()
 
@@ -115,7 +139,17 @@ func Run(loop LoopFuncs) error {
 	ebiten.SetWindowTitle(title)
 
 	game := &ebitenGame{loop: loop, state: s}
-	return ebiten.RunGame(game)
+	err := ebiten.RunGame(game)
+	// A run that ends early, e.g. by closing the window, still saves what
+	// was recorded.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rec != nil {
+		if ferr := s.rec.finish(); err == nil {
+			err = ferr
+		}
+	}
+	return err
 }
 
 type ebitenGame struct {
@@ -126,17 +160,27 @@ type ebitenGame struct {
 func (g *ebitenGame) Update() error {
 	s := g.state
 	s.mu.Lock()
+	if err := s.captureErr; err != nil {
+		s.mu.Unlock()
+		return err
+	}
 	now := time.Now()
-	dt := now.Sub(s.lastTime).Seconds()
+	steps, dt := s.stepper.advance(now.Sub(s.lastTime).Seconds())
 	s.lastTime = now
+	s.tune.key(ebiten.IsKeyPressed(TuneKey))
+	mx, my := ebiten.CursorPosition()
+	s.tune.pointer(mx, my, ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft))
 	s.mu.Unlock()
 
-	if g.loop.Update != nil {
-		if err := g.loop.Update(dt); err != nil {
+	for i := 0; i < steps; i++ {
+		if err := g.step(dt); err != nil {
 			return err
 		}
 	}
 	if g.loop.Draw != nil {
+		s.mu.Lock()
+		s.resetView()
+		s.mu.Unlock()
 		if err := g.loop.Draw(); err != nil {
 			return err
 		}
@@ -151,17 +195,42 @@ func (g *ebitenGame) Update() error {
 	return nil
 }
 
-func (g *ebitenGame) Draw(screen *ebiten.Image) {
+// step runs one update of dt seconds followed by the timers it makes due.
+func (g *ebitenGame) step(dt float64) error {
 	s := g.state
 	s.mu.Lock()
-	clear := s.clear
-	cmds := append([]command(nil), s.commands...)
+	s.sched.tick(dt)
+	s.mu.Unlock()
+	if g.loop.Update != nil {
+		if err := g.loop.Update(dt); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	fired := s.sched.due()
 	s.mu.Unlock()
+	if g.loop.Call != nil {
+		for _, fn := range fired {
+			if err := g.loop.Call(fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
 
-	screen.Fill(clear)
-	for _, cmd := range cmds {
+func (g *ebitenGame) Draw(screen *ebiten.Image) {
+	s := g.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	screen.Fill(s.clear)
+	for _, cmd := range s.commands {
 		cmd.draw(screen)
 	}
+	s.capture(screen)
+	if s.tune.visible {
+		s.tune.draw(screen)
+	}
 }
 
 func (g *ebitenGame) Layout(outsideWidth, outsideHeight int) (int, int) {
@@ -185,8 +254,9 @@ func Open(width, height int, title string) error {
 	if title != "" {
 		s.title = title
 	}
+	scale := s.pixelScale
 	s.mu.Unlock()
-	ebiten.SetWindowSize(width, height)
+	ebiten.SetWindowSize(width*scale, height*scale)
 	if title != "" {
 		ebiten.SetWindowTitle(title)
 	}
@@ -222,10 +292,19 @@ func BeginFrame() error {
 	s.mu.Lock()
 	s.commands = s.commands[:0]
 	s.clear = color.RGBA{A: 255}
+	s.resetView()
+	s.line = defaultLineStyle
 	s.mu.Unlock()
 	return nil
 }
 
+// resetView starts a frame's drawing in camera space with an empty push
+// stack.
+func (s *state) resetView() {
+	s.transform = s.cam.view(s.width, s.height)
+	s.saved = s.saved[:0]
+}
+
 func EndFrame() error {
 	_, err := getState()
 	return err
@@ -256,6 +335,19 @@ func Rect(x, y, w, h float64, r, g, b, a float64) error {
 		return err
 	}
 	s.mu.Lock()
+	if s.transform != identity {
+		// Under a transform the rect is no longer axis-aligned.
+		rect := []PathOp{
+			{Kind: "M", Args: []float64{x, y}},
+			{Kind: "L", Args: []float64{x + w, y}},
+			{Kind: "L", Args: []float64{x + w, y + h}},
+			{Kind: "L", Args: []float64{x, y + h}},
+			{Kind: "Z"},
+		}
+		s.addShape(shapeCmd{subpaths: flatten(rect, s.transform), fill: true, c0: c})
+		s.mu.Unlock()
+		return nil
+	}
 	s.commands = append(s.commands, rectCmd{
 		x: float32(x),
 		y: float32(y),
@@ -277,6 +369,10 @@ func Pixel(x, y int, r, g, b, a int) error {
 		return err
 	}
 	s.mu.Lock()
+	if s.transform != identity {
+		p := s.transform.apply(float64(x), float64(y))
+		x, y = int(math.Floor(p.x)), int(math.Floor(p.y))
+	}
 	s.commands = append(s.commands, pixelCmd{x: x, y: y, c: c})
 	s.mu.Unlock()
 	return nil
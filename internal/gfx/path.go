@@ -0,0 +1,230 @@
+package gfx
+
+import (
+	"fmt"
+	"math"
+
+	"welle/internal/object"
+)
+
+// Coordinates are pixels with the origin at the top-left corner and y
+// growing downward, so positive angles turn clockwise on screen. Angles are
+// in radians measured from the positive x axis.
+
+// PathOp is one path command: "M" move, "L" line, "Q" quadratic curve,
+// "C" cubic curve, "A" arc or "Z" close.
+type PathOp struct {
+	Kind string
+	Args []float64
+}
+
+var pathArity = map[string]int{"M": 2, "L": 2, "Q": 4, "C": 6, "A": 5, "Z": 0}
+
+type point struct{ x, y float64 }
+
+// affine maps (x, y) to (a*x + c*y + e, b*x + d*y + f).
+type affine struct{ a, b, c, d, e, f float64 }
+
+var identity = affine{a: 1, d: 1}
+
+func (m affine) apply(x, y float64) point {
+	return point{m.a*x + m.c*y + m.e, m.b*x + m.d*y + m.f}
+}
+
+// then returns the transform that applies n first and m second, so local
+// transforms compose the way push/translate/rotate nest.
+func (m affine) then(n affine) affine {
+	return affine{
+		a: m.a*n.a + m.c*n.b,
+		b: m.b*n.a + m.d*n.b,
+		c: m.a*n.c + m.c*n.d,
+		d: m.b*n.c + m.d*n.d,
+		e: m.a*n.e + m.c*n.f + m.e,
+		f: m.b*n.e + m.d*n.f + m.f,
+	}
+}
+
+// scaleFactor is how much the transform stretches lengths on average, used
+// to scale stroke widths and curve detail.
+func (m affine) scaleFactor() float64 {
+	return math.Sqrt(math.Abs(m.a*m.d - m.b*m.c))
+}
+
+func translation(x, y float64) affine { return affine{a: 1, d: 1, e: x, f: y} }
+
+func rotation(theta float64) affine {
+	s, c := math.Sincos(theta)
+	return affine{a: c, b: s, c: -s, d: c}
+}
+
+func scaling(sx, sy float64) affine { return affine{a: sx, d: sy} }
+
+// subpath is a flattened run of points in screen space.
+type subpath struct {
+	points []point
+	closed bool
+}
+
+// segmentsFor picks how many line segments approximate a curve of the
+// given on-screen length.
+func segmentsFor(length float64) int {
+	n := int(math.Ceil(length / 4))
+	if n < 4 {
+		return 4
+	}
+	if n > 256 {
+		return 256
+	}
+	return n
+}
+
+// flatten turns path commands into polylines in screen space. Curves and
+// arcs are transformed by their control points and sampled, so they stay
+// correct under any transform, including non-uniform scaling.
+func flatten(ops []PathOp, m affine) []subpath {
+	var out []subpath
+	var cur *subpath
+	var pen, start point // in path space
+	k := m.scaleFactor()
+	lineTo := func(p point) {
+		if cur == nil {
+			out = append(out, subpath{points: []point{m.apply(pen.x, pen.y)}})
+			cur = &out[len(out)-1]
+		}
+		cur.points = append(cur.points, m.apply(p.x, p.y))
+		pen = p
+	}
+	dist := func(a, b point) float64 { return math.Hypot(b.x-a.x, b.y-a.y) }
+	for _, op := range ops {
+		a := op.Args
+		switch op.Kind {
+		case "M":
+			pen = point{a[0], a[1]}
+			start = pen
+			out = append(out, subpath{points: []point{m.apply(pen.x, pen.y)}})
+			cur = &out[len(out)-1]
+		case "L":
+			lineTo(point{a[0], a[1]})
+		case "Q":
+			p0, p1, p2 := pen, point{a[0], a[1]}, point{a[2], a[3]}
+			n := segmentsFor((dist(p0, p1) + dist(p1, p2)) * k)
+			for i := 1; i <= n; i++ {
+				t := float64(i) / float64(n)
+				u := 1 - t
+				lineTo(point{
+					u*u*p0.x + 2*u*t*p1.x + t*t*p2.x,
+					u*u*p0.y + 2*u*t*p1.y + t*t*p2.y,
+				})
+			}
+		case "C":
+			p0, p1, p2, p3 := pen, point{a[0], a[1]}, point{a[2], a[3]}, point{a[4], a[5]}
+			n := segmentsFor((dist(p0, p1) + dist(p1, p2) + dist(p2, p3)) * k)
+			for i := 1; i <= n; i++ {
+				t := float64(i) / float64(n)
+				u := 1 - t
+				lineTo(point{
+					u*u*u*p0.x + 3*u*u*t*p1.x + 3*u*t*t*p2.x + t*t*t*p3.x,
+					u*u*u*p0.y + 3*u*u*t*p1.y + 3*u*t*t*p2.y + t*t*t*p3.y,
+				})
+			}
+		case "A":
+			cx, cy, r, from, to := a[0], a[1], a[2], a[3], a[4]
+			first := point{cx + r*math.Cos(from), cy + r*math.Sin(from)}
+			// An arc joins the current point with a line, as in canvas
+			// APIs; with no current subpath it starts one.
+			if cur == nil {
+				pen = first
+				start = first
+				out = append(out, subpath{points: []point{m.apply(first.x, first.y)}})
+				cur = &out[len(out)-1]
+			} else {
+				lineTo(first)
+			}
+			n := segmentsFor(math.Abs(to-from) * r * k)
+			for i := 1; i <= n; i++ {
+				theta := from + (to-from)*float64(i)/float64(n)
+				lineTo(point{cx + r*math.Cos(theta), cy + r*math.Sin(theta)})
+			}
+		case "Z":
+			if cur != nil {
+				cur.closed = true
+				cur = nil
+				pen = start
+			}
+		}
+	}
+	return out
+}
+
+// ParsePath converts a welle path, an ARRAY of command arrays such as
+// ["M", x, y] or ["C", x1, y1, x2, y2, x, y], into path commands.
+func ParsePath(name string, obj object.Object) ([]PathOp, error) {
+	arr, ok := obj.(*object.Array)
+	if !ok {
+		return nil, fmt.Errorf("%s expects ARRAY path", name)
+	}
+	ops := make([]PathOp, 0, len(arr.Elements))
+	for i, el := range arr.Elements {
+		cmd, ok := el.(*object.Array)
+		if !ok || len(cmd.Elements) == 0 {
+			return nil, fmt.Errorf("%s: path command %d must be a non-empty ARRAY", name, i)
+		}
+		kind, ok := cmd.Elements[0].(*object.String)
+		if !ok {
+			return nil, fmt.Errorf("%s: path command %d must start with a STRING", name, i)
+		}
+		arity, known := pathArity[kind.Value]
+		if !known {
+			return nil, fmt.Errorf("%s: unknown path command %q", name, kind.Value)
+		}
+		if len(cmd.Elements)-1 != arity {
+			return nil, fmt.Errorf("%s: path command %q takes %d numbers, got %d", name, kind.Value, arity, len(cmd.Elements)-1)
+		}
+		op := PathOp{Kind: kind.Value, Args: make([]float64, arity)}
+		for j, v := range cmd.Elements[1:] {
+			f, ok := number(v)
+			if !ok {
+				return nil, fmt.Errorf("%s: path command %q expects numbers", name, kind.Value)
+			}
+			op.Args[j] = f
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// ParseColor reads a color dict #{"r", "g", "b"} with an optional "a"
+// (default 255), as built by std:color.
+func ParseColor(name string, obj object.Object) (r, g, b, a float64, err error) {
+	d, ok := obj.(*object.Dict)
+	if !ok {
+		return 0, 0, 0, 0, fmt.Errorf("%s expects a color DICT with r, g, b", name)
+	}
+	channels := [4]float64{0, 0, 0, 255}
+	for i, key := range []string{"r", "g", "b", "a"} {
+		hk, _ := object.HashKeyOf(&object.String{Value: key})
+		pair, ok := d.Pairs[hk]
+		if !ok {
+			if key == "a" {
+				continue
+			}
+			return 0, 0, 0, 0, fmt.Errorf("%s expects a color DICT with r, g, b", name)
+		}
+		f, ok := number(pair.Value)
+		if !ok {
+			return 0, 0, 0, 0, fmt.Errorf("%s: color channel %q must be a number", name, key)
+		}
+		channels[i] = f
+	}
+	return channels[0], channels[1], channels[2], channels[3], nil
+}
+
+func number(obj object.Object) (float64, bool) {
+	switch v := obj.(type) {
+	case *object.Integer:
+		return float64(v.Value), true
+	case *object.Float:
+		return v.Value, true
+	}
+	return 0, false
+}
@@ -0,0 +1,118 @@
+package gfx
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"welle/internal/object"
+)
+
+func near(p point, x, y float64) bool {
+	return math.Abs(p.x-x) < 1e-9 && math.Abs(p.y-y) < 1e-9
+}
+
+func TestTransformsNestLikeAStack(t *testing.T) {
+	// translate(10, 0) then rotate(pi/2): the local x axis points down the
+	// screen, starting at (10, 0).
+	m := identity.then(translation(10, 0)).then(rotation(math.Pi / 2))
+	if p := m.apply(1, 0); !near(p, 10, 1) {
+		t.Fatalf("apply(1, 0) = %v, want (10, 1)", p)
+	}
+	m = m.then(scaling(2, 3))
+	if p := m.apply(1, 1); !near(p, 7, 2) {
+		t.Fatalf("apply(1, 1) = %v, want (7, 2)", p)
+	}
+	if k := m.scaleFactor(); math.Abs(k-math.Sqrt(6)) > 1e-9 {
+		t.Fatalf("scaleFactor = %v, want sqrt(6)", k)
+	}
+}
+
+func TestFlattenPolygonAndCurves(t *testing.T) {
+	ops := []PathOp{
+		{Kind: "M", Args: []float64{0, 0}},
+		{Kind: "L", Args: []float64{10, 0}},
+		{Kind: "L", Args: []float64{10, 10}},
+		{Kind: "Z"},
+		{Kind: "L", Args: []float64{0, 10}},
+		{Kind: "M", Args: []float64{20, 0}},
+		{Kind: "Q", Args: []float64{30, 0, 30, 10}},
+		{Kind: "C", Args: []float64{30, 20, 20, 20, 20, 30}},
+	}
+	subs := flatten(ops, translation(1, 2))
+	if len(subs) != 3 {
+		t.Fatalf("got %d subpaths, want 3", len(subs))
+	}
+	if !subs[0].closed || len(subs[0].points) != 3 || !near(subs[0].points[1], 11, 2) {
+		t.Fatalf("triangle = %+v", subs[0])
+	}
+	// After Z the pen is back at the subpath start, so the next line
+	// starts a new subpath there.
+	if subs[1].closed || !near(subs[1].points[0], 1, 2) || !near(subs[1].points[1], 1, 12) {
+		t.Fatalf("line after close = %+v", subs[1])
+	}
+	curve := subs[2].points
+	if !near(curve[0], 21, 2) || !near(curve[len(curve)-1], 21, 32) {
+		t.Fatalf("curve runs %v..%v", curve[0], curve[len(curve)-1])
+	}
+	if len(curve) < 9 {
+		t.Fatalf("curve flattened to only %d points", len(curve))
+	}
+}
+
+func TestFlattenArcJoinsCurrentPoint(t *testing.T) {
+	subs := flatten([]PathOp{{Kind: "A", Args: []float64{0, 0, 10, 0, math.Pi / 2}}}, identity)
+	if len(subs) != 1 {
+		t.Fatalf("got %d subpaths, want 1", len(subs))
+	}
+	pts := subs[0].points
+	// y grows downward, so a positive sweep from angle 0 ends below the
+	// center.
+	if !near(pts[0], 10, 0) || !near(pts[len(pts)-1], 0, 10) {
+		t.Fatalf("arc runs %v..%v", pts[0], pts[len(pts)-1])
+	}
+
+	subs = flatten([]PathOp{
+		{Kind: "M", Args: []float64{0, 0}},
+		{Kind: "A", Args: []float64{0, 0, 5, 0, math.Pi}},
+	}, identity)
+	if len(subs) != 1 || !near(subs[0].points[1], 5, 0) {
+		t.Fatalf("arc after move = %+v", subs)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	cmd := func(kind string, nums ...int64) *object.Array {
+		els := []object.Object{&object.String{Value: kind}}
+		for _, n := range nums {
+			els = append(els, &object.Integer{Value: n})
+		}
+		return &object.Array{Elements: els}
+	}
+	ops, err := ParsePath("gfx_fillPath", &object.Array{Elements: []object.Object{
+		cmd("M", 1, 2),
+		&object.Array{Elements: []object.Object{&object.String{Value: "L"}, &object.Float{Value: 3.5}, &object.Integer{Value: 4}}},
+		cmd("Z"),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 3 || ops[1].Kind != "L" || ops[1].Args[0] != 3.5 || ops[2].Kind != "Z" {
+		t.Fatalf("ops = %+v", ops)
+	}
+
+	for _, tc := range []struct {
+		path object.Object
+		want string
+	}{
+		{&object.Integer{Value: 1}, "expects ARRAY path"},
+		{&object.Array{Elements: []object.Object{cmd("X", 1)}}, `unknown path command "X"`},
+		{&object.Array{Elements: []object.Object{cmd("L", 1)}}, `"L" takes 2 numbers, got 1`},
+		{&object.Array{Elements: []object.Object{&object.Array{}}}, "must be a non-empty ARRAY"},
+	} {
+		_, err := ParsePath("gfx_fillPath", tc.path)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("ParsePath(%s) error = %v, want %q", tc.path.Inspect(), err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package gfx
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+var (
+	whiteImage    = ebiten.NewImage(3, 3)
+	whiteSubImage = whiteImage.SubImage(image.Rect(1, 1, 2, 2)).(*ebiten.Image)
+)
+
+func init() {
+	pix := make([]byte, 4*3*3)
+	for i := range pix {
+		pix[i] = 0xff
+	}
+	whiteImage.WritePixels(pix)
+}
+
+// lineStyle is the stroke setting that StrokePath uses.
+type lineStyle struct {
+	width float64
+	cap   vector.LineCap
+	join  vector.LineJoin
+}
+
+var defaultLineStyle = lineStyle{width: 1}
+
+var lineCaps = map[string]vector.LineCap{
+	"butt":   vector.LineCapButt,
+	"round":  vector.LineCapRound,
+	"square": vector.LineCapSquare,
+}
+
+var lineJoins = map[string]vector.LineJoin{
+	"miter": vector.LineJoinMiter,
+	"bevel": vector.LineJoinBevel,
+	"round": vector.LineJoinRound,
+}
+
+// shapeCmd draws flattened subpaths, filled or stroked, in one color or a
+// linear gradient from c0 at g0 to c1 at g1.
+type shapeCmd struct {
+	subpaths []subpath
+	fill     bool
+	stroke   vector.StrokeOptions
+	c0, c1   color.RGBA
+	g0, g1   point
+	gradient bool
+}
+
+func (s shapeCmd) draw(dst *ebiten.Image) {
+	var p vector.Path
+	for _, sp := range s.subpaths {
+		for i, pt := range sp.points {
+			if i == 0 {
+				p.MoveTo(float32(pt.x), float32(pt.y))
+			} else {
+				p.LineTo(float32(pt.x), float32(pt.y))
+			}
+		}
+		if sp.closed {
+			p.Close()
+		}
+	}
+	var vs []ebiten.Vertex
+	var is []uint16
+	op := &ebiten.DrawTrianglesOptions{AntiAlias: true}
+	if s.fill {
+		vs, is = p.AppendVerticesAndIndicesForFilling(nil, nil)
+		op.FillRule = ebiten.EvenOdd
+	} else {
+		vs, is = p.AppendVerticesAndIndicesForStroke(nil, nil, &s.stroke)
+	}
+	dx, dy := s.g1.x-s.g0.x, s.g1.y-s.g0.y
+	span := dx*dx + dy*dy
+	for i := range vs {
+		c := s.c0
+		if s.gradient && span > 0 {
+			t := ((float64(vs[i].DstX)-s.g0.x)*dx + (float64(vs[i].DstY)-s.g0.y)*dy) / span
+			c = lerpRGBA(s.c0, s.c1, math.Max(0, math.Min(1, t)))
+		}
+		vs[i].SrcX, vs[i].SrcY = 1, 1
+		vs[i].ColorR = float32(c.R) / 255
+		vs[i].ColorG = float32(c.G) / 255
+		vs[i].ColorB = float32(c.B) / 255
+		vs[i].ColorA = float32(c.A) / 255
+	}
+	dst.DrawTriangles(vs, is, whiteSubImage, op)
+}
+
+func lerpRGBA(a, b color.RGBA, t float64) color.RGBA {
+	mix := func(x, y uint8) uint8 { return uint8(math.Round(float64(x) + (float64(y)-float64(x))*t)) }
+	return color.RGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: mix(a.A, b.A)}
+}
+
+func (s *state) addShape(cmd shapeCmd) {
+	s.commands = append(s.commands, cmd)
+}
+
+// FillPath fills a path using the even-odd rule, so overlapping subpaths
+// cut holes. Open subpaths are closed implicitly.
+func FillPath(ops []PathOp, r, g, b, a float64) error {
+	s, err := getState()
+	if err != nil {
+		return err
+	}
+	c, err := rgbaFromNumbers(r, g, b, a)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addShape(shapeCmd{subpaths: flatten(ops, s.transform), fill: true, c0: c})
+	return nil
+}
+
+// StrokePath outlines a path with the current line style. The width is
+// scaled along with the current transform.
+func StrokePath(ops []PathOp, r, g, b, a float64) error {
+	s, err := getState()
+	if err != nil {
+		return err
+	}
+	c, err := rgbaFromNumbers(r, g, b, a)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stroke := vector.StrokeOptions{
+		Width:      float32(s.line.width * s.transform.scaleFactor()),
+		LineCap:    s.line.cap,
+		LineJoin:   s.line.join,
+		MiterLimit: 10,
+	}
+	s.addShape(shapeCmd{subpaths: flatten(ops, s.transform), stroke: stroke, c0: c})
+	return nil
+}
+
+// GradientPath fills a path with a linear gradient running from color c0
+// at (x0, y0) to c1 at (x1, y1), both in the current coordinate system.
+// Colors are interpolated between the vertices of the filled shape.
+func GradientPath(ops []PathOp, x0, y0, x1, y1 float64, c0, c1 [4]float64) error {
+	s, err := getState()
+	if err != nil {
+		return err
+	}
+	from, err := rgbaFromNumbers(c0[0], c0[1], c0[2], c0[3])
+	if err != nil {
+		return err
+	}
+	to, err := rgbaFromNumbers(c1[0], c1[1], c1[2], c1[3])
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addShape(shapeCmd{
+		subpaths: flatten(ops, s.transform),
+		fill:     true,
+		c0:       from,
+		c1:       to,
+		g0:       s.transform.apply(x0, y0),
+		g1:       s.transform.apply(x1, y1),
+		gradient: true,
+	})
+	return nil
+}
+
+// SetLineStyle sets the stroke width, cap ("butt", "round", "square") and
+// join ("miter", "bevel", "round") for later strokes.
+func SetLineStyle(width float64, capName, joinName string) error {
+	s, err := getState()
+	if err != nil {
+		return err
+	}
+	if !(width > 0) || math.IsInf(width, 0) {
+		return errors.New("gfx_lineStyle expects a positive width")
+	}
+	lc, ok := lineCaps[capName]
+	if !ok {
+		return errors.New("gfx_lineStyle: unknown cap " + capName + " (use butt, round or square)")
+	}
+	lj, ok := lineJoins[joinName]
+	if !ok {
+		return errors.New("gfx_lineStyle: unknown join " + joinName + " (use miter, bevel or round)")
+	}
+	s.mu.Lock()
+	s.line = lineStyle{width: width, cap: lc, join: lj}
+	s.mu.Unlock()
+	return nil
+}
+
+// Push saves the current transform and line style.
+func Push() error {
+	s, err := getState()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.saved = append(s.saved, savedState{transform: s.transform, line: s.line})
+	s.mu.Unlock()
+	return nil
+}
+
+// Pop restores the transform and line style saved by the matching Push.
+func Pop() error {
+	s, err := getState()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.saved) == 0 {
+		return errors.New("gfx_pop without a matching gfx_push")
+	}
+	top := s.saved[len(s.saved)-1]
+	s.saved = s.saved[:len(s.saved)-1]
+	s.transform, s.line = top.transform, top.line
+	return nil
+}
+
+// Translate moves the origin to (x, y) in the current coordinate system.
+func Translate(x, y float64) error {
+	return applyTransform(translation(x, y))
+}
+
+// Rotate turns later drawing by theta radians (clockwise on screen).
+func Rotate(theta float64) error {
+	return applyTransform(rotation(theta))
+}
+
+// Scale stretches later drawing by sx horizontally and sy vertically.
+func Scale(sx, sy float64) error {
+	return applyTransform(scaling(sx, sy))
+}
+
+func applyTransform(m affine) error {
+	s, err := getState()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.transform = s.transform.then(m)
+	s.mu.Unlock()
+	return nil
+}
+
+type savedState struct {
+	transform affine
+	line      lineStyle
+}
@@ -0,0 +1,198 @@
+package gfx
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// timer fires its callback every interval seconds of loop time.
+type timer struct {
+	id       int
+	interval float64
+	due      float64
+	fn       any
+}
+
+// scheduler tracks loop time and the timers registered with Every. Time
+// only advances through the dt values the update loop sees, so timers stay
+// in step with update(dt) rather than the wall clock.
+type scheduler struct {
+	elapsed float64
+	frames  int64
+	nextID  int
+	timers  []*timer
+}
+
+func (s *scheduler) add(interval float64, fn any) int {
+	s.nextID++
+	s.timers = append(s.timers, &timer{id: s.nextID, interval: interval, due: s.elapsed + interval, fn: fn})
+	return s.nextID
+}
+
+func (s *scheduler) cancel(id int) bool {
+	for i, t := range s.timers {
+		if t.id == id {
+			s.timers = append(s.timers[:i], s.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// tick starts an update tick that moves loop time on by dt.
+func (s *scheduler) tick(dt float64) {
+	s.frames++
+	s.elapsed += dt
+}
+
+// due returns the callbacks whose time has come, in firing order. A timer
+// that fell behind fires once per missed interval.
+func (s *scheduler) due() []any {
+	var fired []any
+	for {
+		var next *timer
+		for _, t := range s.timers {
+			if t.due <= s.elapsed && (next == nil || t.due < next.due) {
+				next = t
+			}
+		}
+		if next == nil {
+			return fired
+		}
+		fired = append(fired, next.fn)
+		next.due += next.interval
+	}
+}
+
+// maxStepsPerFrame bounds how many fixed updates one frame may run, so a
+// stall (or a breakpoint) does not make the loop spend ever longer catching
+// up; the backlog beyond it is dropped.
+const maxStepsPerFrame = 8
+
+// stepper turns wall-clock frame times into update steps. With step zero
+// each frame is one update of the measured dt; otherwise time accumulates
+// and is paid out in whole steps of exactly step seconds.
+type stepper struct {
+	step float64
+	acc  float64
+}
+
+// advance adds a frame's wall time and returns how many updates to run and
+// the dt to pass to each.
+func (p *stepper) advance(wall float64) (int, float64) {
+	if p.step == 0 {
+		return 1, wall
+	}
+	p.acc += wall
+	n := int(p.acc / p.step)
+	if n > maxStepsPerFrame {
+		n = maxStepsPerFrame
+		p.acc = 0
+		return n, p.step
+	}
+	p.acc -= float64(n) * p.step
+	return n, p.step
+}
+
+// alpha is how far the loop is between the last fixed update and the
+// next one, in 0..1; 1 in variable-step mode.
+func (p *stepper) alpha() float64 {
+	if p.step == 0 {
+		return 1
+	}
+	return p.acc / p.step
+}
+
+// Elapsed returns the seconds of loop time so far: the sum of every dt
+// passed to update, including the current one.
+func Elapsed() (float64, error) {
+	s, err := getState()
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sched.elapsed, nil
+}
+
+// FrameCount returns how many update ticks have started, counting the
+// current one.
+func FrameCount() (int64, error) {
+	s, err := getState()
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sched.frames, nil
+}
+
+// SetFPS sets how many update ticks run per second (60 by default).
+func SetFPS(fps int) error {
+	if _, err := getState(); err != nil {
+		return err
+	}
+	if fps <= 0 {
+		return errors.New("gfx_setFPS expects a positive rate")
+	}
+	ebiten.SetTPS(fps)
+	return nil
+}
+
+// SetFixedStep switches update to a fixed timestep of step seconds, run as
+// many times per frame as the elapsed wall time calls for, or back to one
+// update per frame with the measured dt when step is 0.
+func SetFixedStep(step float64) error {
+	s, err := getState()
+	if err != nil {
+		return err
+	}
+	if step < 0 || math.IsNaN(step) || math.IsInf(step, 0) {
+		return fmt.Errorf("gfx_fixedStep expects a non-negative number of seconds, got %v", step)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stepper = stepper{step: step}
+	return nil
+}
+
+// StepAlpha returns the fraction of a fixed step accumulated since the last
+// update, for interpolating what draw shows.
+func StepAlpha() (float64, error) {
+	s, err := getState()
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stepper.alpha(), nil
+}
+
+// Every registers fn to be called every interval seconds of loop time,
+// after update, and returns the timer's id.
+func Every(interval float64, fn any) (int, error) {
+	s, err := getState()
+	if err != nil {
+		return 0, err
+	}
+	if !(interval > 0) || math.IsInf(interval, 0) {
+		return 0, fmt.Errorf("gfx_every expects a positive interval, got %v", interval)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sched.add(interval, fn), nil
+}
+
+// Cancel stops a timer; it reports whether the timer existed.
+func Cancel(id int) (bool, error) {
+	s, err := getState()
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sched.cancel(id), nil
+}
@@ -0,0 +1,80 @@
+package gfx
+
+import "testing"
+
+func TestSchedulerFiresOnLoopTime(t *testing.T) {
+	var s scheduler
+	a := s.add(0.5, "a")
+	s.add(0.2, "b")
+
+	var got []any
+	for _, dt := range []float64{0.1, 0.15, 0.3, 0.03} {
+		s.tick(dt)
+		got = append(got, s.due()...)
+	}
+	// Loop time goes 0.1, 0.25, 0.55, 0.58: "b" is due at 0.2 and 0.4 and
+	// "a" at 0.5, so the third tick fires "b" then "a".
+	want := []any{"b", "b", "a"}
+	if len(got) != len(want) {
+		t.Fatalf("fired %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("fired %v, want %v", got, want)
+		}
+	}
+	if s.frames != 4 {
+		t.Fatalf("frames = %d, want 4", s.frames)
+	}
+
+	if !s.cancel(a) || s.cancel(a) {
+		t.Fatalf("cancel should succeed exactly once")
+	}
+	s.tick(1)
+	if fired := s.due(); len(fired) != 5 {
+		t.Fatalf("expected five catch-up firings of b, got %v", fired)
+	}
+}
+
+func TestStepperVariableMode(t *testing.T) {
+	var p stepper
+	if n, dt := p.advance(0.03); n != 1 || dt != 0.03 {
+		t.Fatalf("advance = (%d, %v), want (1, 0.03)", n, dt)
+	}
+	if a := p.alpha(); a != 1 {
+		t.Fatalf("alpha = %v, want 1", a)
+	}
+}
+
+func TestStepperFixedMode(t *testing.T) {
+	p := stepper{step: 0.25}
+	var steps []int
+	for _, wall := range []float64{0.125, 0.25, 0.5, 0.125} {
+		n, dt := p.advance(wall)
+		if dt != 0.25 {
+			t.Fatalf("dt = %v, want the fixed step", dt)
+		}
+		steps = append(steps, n)
+	}
+	// Accumulated time goes 0.125, 0.375, 0.625, 0.25 before paying out,
+	// each step taking its 0.25 away.
+	want := []int{0, 1, 2, 1}
+	for i := range want {
+		if steps[i] != want[i] {
+			t.Fatalf("steps = %v, want %v", steps, want)
+		}
+	}
+	if a := p.alpha(); a != 0 {
+		t.Fatalf("alpha = %v, want 0", a)
+	}
+	p.advance(0.125)
+	if a := p.alpha(); a != 0.5 {
+		t.Fatalf("alpha = %v, want 0.5", a)
+	}
+
+	// A long stall runs at most maxStepsPerFrame updates and drops the rest
+	// rather than spiralling.
+	if n, _ := p.advance(10); n != maxStepsPerFrame || p.acc != 0 {
+		t.Fatalf("stall gave %d steps with %v left over", n, p.acc)
+	}
+}
@@ -0,0 +1,153 @@
+package gfx
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// TuneKey toggles the tuning overlay.
+const TuneKey = ebiten.KeyF1
+
+// Overlay layout in screen pixels. Each row shows "name value" on the left
+// and a slider on the right.
+const (
+	tunePanelX  = 8
+	tunePanelY  = 8
+	tunePanelW  = 236
+	tuneRowH    = 20
+	tuneTrackX  = tunePanelX + 130
+	tuneTrackW  = 96
+	tuneKnobW   = 6
+	tuneNameMax = 14 // longer names are cut short in the overlay
+)
+
+type tunable struct {
+	name     string
+	value    float64
+	min, max float64
+	integer  bool
+}
+
+func (t *tunable) set(v float64) {
+	v = math.Max(t.min, math.Min(t.max, v))
+	if t.integer {
+		v = math.Round(v)
+	}
+	t.value = v
+}
+
+func (t *tunable) label() string {
+	name := t.name
+	if len(name) > tuneNameMax {
+		name = name[:tuneNameMax-1] + "~"
+	}
+	if t.integer {
+		return fmt.Sprintf("%s %d", name, int64(t.value))
+	}
+	return name + " " + strconv.FormatFloat(t.value, 'g', 4, 64)
+}
+
+// tuner holds the values registered with Tune and the overlay's input
+// state: whether it is shown, whether the toggle key was down last tick
+// and which slider, if any, is being dragged.
+type tuner struct {
+	items    []*tunable
+	byName   map[string]*tunable
+	visible  bool
+	keyDown  bool
+	dragging int
+}
+
+// register returns the value of the named tunable, creating it from def
+// on first use. Later calls return the current value and ignore the
+// other arguments, so tune() can be called every frame.
+func (t *tuner) register(name string, def, lo, hi float64, integer bool) (float64, error) {
+	if it, ok := t.byName[name]; ok {
+		return it.value, nil
+	}
+	if !(lo < hi) || math.IsInf(lo, 0) || math.IsInf(hi, 0) {
+		return 0, fmt.Errorf("gfx_tune(%q) expects min < max", name)
+	}
+	if t.byName == nil {
+		t.byName = map[string]*tunable{}
+	}
+	it := &tunable{name: name, min: lo, max: hi, integer: integer}
+	it.set(def)
+	t.items = append(t.items, it)
+	t.byName[name] = it
+	return it.value, nil
+}
+
+// key feeds the toggle key's state for one tick; a press shows or hides
+// the overlay.
+func (t *tuner) key(down bool) {
+	if down && !t.keyDown {
+		t.visible = !t.visible
+		t.dragging = -1
+	}
+	t.keyDown = down
+}
+
+// pointer feeds the mouse for one tick. Pressing on a row grabs its slider
+// and the value follows the mouse until the button is released.
+func (t *tuner) pointer(x, y int, down bool) {
+	if !t.visible || !down {
+		t.dragging = -1
+		return
+	}
+	if t.dragging < 0 {
+		row := (y - tunePanelY) / tuneRowH
+		if y < tunePanelY || row >= len(t.items) || x < tuneTrackX-tuneKnobW || x > tuneTrackX+tuneTrackW+tuneKnobW {
+			return
+		}
+		t.dragging = row
+	}
+	it := t.items[t.dragging]
+	frac := float64(x-tuneTrackX) / tuneTrackW
+	it.set(it.min + frac*(it.max-it.min))
+}
+
+// knobX is where the knob of a slider sits for its current value.
+func (it *tunable) knobX() float64 {
+	return tuneTrackX + (it.value-it.min)/(it.max-it.min)*tuneTrackW
+}
+
+func (t *tuner) draw(dst *ebiten.Image) {
+	h := float32(len(t.items)*tuneRowH + 4)
+	vector.DrawFilledRect(dst, tunePanelX-4, tunePanelY-2, tunePanelW, h, color.RGBA{A: 190}, false)
+	if len(t.items) == 0 {
+		ebitenutil.DebugPrintAt(dst, "no gfx.tune() values", tunePanelX, tunePanelY)
+		return
+	}
+	for i, it := range t.items {
+		y := tunePanelY + i*tuneRowH
+		ebitenutil.DebugPrintAt(dst, it.label(), tunePanelX, y)
+		mid := float32(y + tuneRowH/2)
+		vector.DrawFilledRect(dst, tuneTrackX, mid-1, tuneTrackW, 2, color.RGBA{R: 120, G: 120, B: 140, A: 255}, false)
+		knob := color.RGBA{R: 220, G: 220, B: 230, A: 255}
+		if i == t.dragging {
+			knob = color.RGBA{R: 255, G: 200, B: 80, A: 255}
+		}
+		vector.DrawFilledRect(dst, float32(it.knobX())-tuneKnobW/2, mid-6, tuneKnobW, 12, knob, false)
+	}
+}
+
+// Tune returns the current value of a named tweakable constant, shown as
+// a slider in the overlay toggled with F1. The first call registers it
+// with value def in [lo, hi]; integer makes the slider step by whole
+// numbers.
+func Tune(name string, def, lo, hi float64, integer bool) (float64, error) {
+	s, err := getState()
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tune.register(name, def, lo, hi, integer)
+}
@@ -0,0 +1,57 @@
+package gfx
+
+import "testing"
+
+func TestTunerRegisterAndDrag(t *testing.T) {
+	tu := tuner{dragging: -1}
+	v, err := tu.register("speed", 50, 0, 100, false)
+	if err != nil || v != 50 {
+		t.Fatalf("register = %v, %v", v, err)
+	}
+	if v, _ := tu.register("speed", 10, -5, 5, false); v != 50 {
+		t.Fatalf("a second register returned %v, want the current 50", v)
+	}
+	if v, _ := tu.register("count", 7.6, 0, 5, true); v != 5 {
+		t.Fatalf("default outside the range gave %v, want it clamped to 5", v)
+	}
+	if _, err := tu.register("bad", 1, 3, 3, false); err == nil {
+		t.Fatal("expected an error for an empty range")
+	}
+
+	// Hidden overlays ignore the mouse.
+	row0 := tunePanelY + tuneRowH/2
+	tu.pointer(tuneTrackX, row0, true)
+	if tu.items[0].value != 50 {
+		t.Fatal("the hidden overlay changed a value")
+	}
+
+	tu.key(true)
+	tu.key(true) // held, not a second press
+	if !tu.visible {
+		t.Fatal("the toggle key did not show the overlay")
+	}
+	tu.pointer(tuneTrackX+tuneTrackW/4, row0, true)
+	if tu.dragging != 0 || tu.items[0].value != 25 {
+		t.Fatalf("press on row 0: dragging %d, value %v", tu.dragging, tu.items[0].value)
+	}
+	// The drag sticks to its slider when the mouse leaves the row, and
+	// clamps at the ends.
+	tu.pointer(tuneTrackX+tuneTrackW*2, row0+3*tuneRowH, true)
+	if tu.items[0].value != 100 || tu.items[1].value != 5 {
+		t.Fatalf("after dragging past the end: %v, %v", tu.items[0].value, tu.items[1].value)
+	}
+	tu.pointer(0, 0, false)
+	tu.pointer(tuneTrackX+tuneTrackW/2, row0+tuneRowH, true)
+	if tu.dragging != 1 || tu.items[1].value != 3 {
+		t.Fatalf("integer slider: dragging %d, value %v", tu.dragging, tu.items[1].value)
+	}
+	if x := tu.items[1].knobX(); x != tuneTrackX+tuneTrackW*3/5.0 {
+		t.Fatalf("knobX = %v", x)
+	}
+
+	tu.key(false)
+	tu.key(true)
+	if tu.visible || tu.dragging != -1 {
+		t.Fatal("a second press should hide the overlay and drop the drag")
+	}
+}
@@ -0,0 +1,293 @@
+// Package heapdump takes snapshots of the values a Welle program can still
+// reach: how many objects of each type there are, what they cost against
+// the memory budget, and which arrays and dicts are the largest, with the
+// path to each from a variable and, when sites are tracked, where it was
+// made. `welle run --heap-dump` and the heap_dump() builtin both use it.
+package heapdump
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"welle/internal/limits"
+	"welle/internal/object"
+	"welle/internal/semantics"
+)
+
+// MaxLargest is how many arrays and dicts a snapshot lists.
+const MaxLargest = 10
+
+// Root is a named value the walk starts from: a variable, or a stack slot.
+type Root struct {
+	Name  string
+	Value object.Object
+}
+
+// Snapshot is what Take found.
+type Snapshot struct {
+	Objects     int64       `json:"objects"`
+	Bytes       int64       `json:"bytes"`
+	BudgetUsed  int64       `json:"budget_used"`
+	BudgetLimit int64       `json:"budget_limit"`
+	Types       []TypeStat  `json:"types"`
+	Largest     []Container `json:"largest"`
+}
+
+// TypeStat counts the reachable objects of one type and what they cost.
+type TypeStat struct {
+	Type  string `json:"type"`
+	Count int64  `json:"count"`
+	Bytes int64  `json:"bytes"`
+}
+
+// Container is one of the largest arrays or dicts. Path is the shortest way
+// to it from a root, such as `cache["users"][3]`; Site is "" when its site
+// was not tracked.
+type Container struct {
+	Type  string `json:"type"`
+	Len   int    `json:"len"`
+	Bytes int64  `json:"bytes"`
+	Path  string `json:"path"`
+	Site  string `json:"site,omitempty"`
+}
+
+type walker struct {
+	seen    map[object.Object]bool
+	envs    map[*object.Environment]bool
+	modules map[*object.Module]bool
+	queue   []item
+	types   map[object.Type]*TypeStat
+	largest []Container
+	snap    *Snapshot
+}
+
+type item struct {
+	obj  object.Object
+	path string
+}
+
+// Take walks everything reachable from roots, breadth first so each object
+// is reported under its shortest path, and reads the budget's usage. The
+// budget counts every allocation the run has made, so it is usually more
+// than the bytes still reachable.
+func Take(roots []Root, budget *limits.Budget) *Snapshot {
+	w := &walker{
+		seen:    map[object.Object]bool{},
+		envs:    map[*object.Environment]bool{},
+		modules: map[*object.Module]bool{},
+		types:   map[object.Type]*TypeStat{},
+		snap:    &Snapshot{BudgetUsed: budget.Used(), BudgetLimit: budget.Limit()},
+	}
+	sort.SliceStable(roots, func(i, j int) bool { return roots[i].Name < roots[j].Name })
+	for _, r := range roots {
+		w.push(r.Value, r.Name)
+	}
+	for len(w.queue) > 0 {
+		it := w.queue[0]
+		w.queue = w.queue[1:]
+		w.visit(it.obj, it.path)
+	}
+
+	for _, ts := range w.types {
+		w.snap.Types = append(w.snap.Types, *ts)
+	}
+	sort.Slice(w.snap.Types, func(i, j int) bool {
+		a, b := w.snap.Types[i], w.snap.Types[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Type < b.Type
+	})
+	sort.SliceStable(w.largest, func(i, j int) bool { return w.largest[i].Bytes > w.largest[j].Bytes })
+	w.snap.Largest = append([]Container{}, w.largest[:min(len(w.largest), MaxLargest)]...)
+	if w.snap.Types == nil {
+		w.snap.Types = []TypeStat{}
+	}
+	return w.snap
+}
+
+func (w *walker) push(obj object.Object, path string) {
+	if obj == nil || w.seen[obj] {
+		return
+	}
+	w.seen[obj] = true
+	w.queue = append(w.queue, item{obj, path})
+}
+
+func (w *walker) visit(obj object.Object, path string) {
+	cost := object.CostOf(obj)
+	w.snap.Objects++
+	w.snap.Bytes += cost
+	ts := w.types[obj.Type()]
+	if ts == nil {
+		ts = &TypeStat{Type: string(obj.Type())}
+		w.types[obj.Type()] = ts
+	}
+	ts.Count++
+	ts.Bytes += cost
+
+	switch v := obj.(type) {
+	case *object.Array:
+		w.container(obj, len(v.Elements), cost, path)
+		for i, el := range v.Elements {
+			w.push(el, path+"["+strconv.Itoa(i)+"]")
+		}
+	case *object.Tuple:
+		for i, el := range v.Elements {
+			w.push(el, path+"["+strconv.Itoa(i)+"]")
+		}
+	case *object.Dict:
+		w.container(obj, len(v.Pairs), cost, path)
+		for _, pair := range object.SortedDictPairs(v) {
+			w.push(pair.Key, path+".<key>")
+			w.push(pair.Value, path+"["+keyLabel(pair.Key)+"]")
+		}
+	case *object.Set:
+		for _, item := range object.SortedSetItems(v) {
+			w.push(item, path+".<item>")
+		}
+	case *object.Cell:
+		w.push(v.Value, path)
+	case *object.Instance:
+		for i, field := range v.Fields {
+			w.push(field, path+"."+v.Class.Fields[i])
+		}
+		w.push(v.Class, path+".<class>")
+	case *object.Class:
+		for i, def := range v.Defaults {
+			w.push(def, path+"."+v.Fields[i])
+		}
+		for _, name := range sortedNames(v.Methods) {
+			w.push(v.Methods[name], path+"."+name)
+		}
+	case *object.BoundMethod:
+		w.push(v.Receiver, path+".<self>")
+		w.push(v.Method, path+".<method>")
+	case *object.Seq:
+		if v.Source != nil {
+			w.push(v.Source, path+".<source>")
+		}
+	case *object.Closure:
+		for i, free := range v.Free {
+			w.push(free, fmt.Sprintf("%s.<captured>[%d]", path, i))
+		}
+		for i, def := range v.Defaults {
+			w.push(def, fmt.Sprintf("%s.<default>[%d]", path, i))
+		}
+		if v.Module != nil && !w.modules[v.Module] {
+			w.modules[v.Module] = true
+			globals := semantics.SlotBindings(v.Module.GlobalNames, v.Module.Globals)
+			for _, name := range sortedNames(globals) {
+				w.push(globals[name], path+".<module>."+name)
+			}
+		}
+	case *object.Function:
+		for i, def := range v.Defaults {
+			w.push(def, fmt.Sprintf("%s.<default>[%d]", path, i))
+		}
+		if v.Env != nil && !w.envs[v.Env] {
+			w.envs[v.Env] = true
+			captured := v.Env.Locals()
+			for _, name := range sortedNames(captured) {
+				w.push(captured[name], path+".<captured>."+name)
+			}
+			globals := v.Env.Globals()
+			for _, name := range sortedNames(globals) {
+				w.push(globals[name], path+".<module>."+name)
+			}
+		}
+	}
+}
+
+func (w *walker) container(obj object.Object, n int, cost int64, path string) {
+	c := Container{Type: string(obj.Type()), Len: n, Bytes: cost, Path: path}
+	if site, ok := SiteOf(obj); ok {
+		c.Site = site.String()
+	}
+	w.largest = append(w.largest, c)
+	if len(w.largest) >= 4*MaxLargest {
+		sort.SliceStable(w.largest, func(i, j int) bool { return w.largest[i].Bytes > w.largest[j].Bytes })
+		w.largest = w.largest[:MaxLargest]
+	}
+}
+
+// keyLabel writes a dict key the way it would appear in source.
+func keyLabel(key object.Object) string {
+	if s, ok := key.(*object.String); ok {
+		return strconv.Quote(s.Value)
+	}
+	return key.Inspect()
+}
+
+func sortedNames(m map[string]object.Object) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// JSON encodes s for `welle run --heap-dump`.
+func (s *Snapshot) JSON() ([]byte, error) {
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// Dict returns s as the dict heap_dump() returns, with the same keys as the
+// JSON form and nil for an untracked site.
+func (s *Snapshot) Dict() *object.Dict {
+	types := make([]object.Object, len(s.Types))
+	for i, ts := range s.Types {
+		types[i] = dict(
+			"type", str(ts.Type),
+			"count", integer(ts.Count),
+			"bytes", integer(ts.Bytes),
+		)
+	}
+	largest := make([]object.Object, len(s.Largest))
+	for i, c := range s.Largest {
+		var site object.Object = nilObj
+		if c.Site != "" {
+			site = str(c.Site)
+		}
+		largest[i] = dict(
+			"type", str(c.Type),
+			"len", integer(int64(c.Len)),
+			"bytes", integer(c.Bytes),
+			"path", str(c.Path),
+			"site", site,
+		)
+	}
+	return dict(
+		"objects", integer(s.Objects),
+		"bytes", integer(s.Bytes),
+		"budget_used", integer(s.BudgetUsed),
+		"budget_limit", integer(s.BudgetLimit),
+		"types", &object.Array{Elements: types},
+		"largest", &object.Array{Elements: largest},
+	)
+}
+
+func dict(kv ...any) *object.Dict {
+	d := &object.Dict{Pairs: make(map[object.HashKey]object.DictPair, len(kv)/2)}
+	for i := 0; i < len(kv); i += 2 {
+		key := &object.String{Value: kv[i].(string)}
+		d.Pairs[key.HashKey()] = object.DictPair{Key: key, Value: kv[i+1].(object.Object)}
+	}
+	return d
+}
+
+var nilObj = &object.Nil{}
+
+func str(s string) object.Object { return &object.String{Value: s} }
+
+func integer(n int64) object.Object { return &object.Integer{Value: n} }
@@ -0,0 +1,118 @@
+package heapdump
+
+import (
+	"encoding/json"
+	"runtime"
+	"testing"
+
+	"welle/internal/limits"
+	"welle/internal/object"
+)
+
+func dictOf(kv ...object.Object) *object.Dict {
+	d := &object.Dict{Pairs: map[object.HashKey]object.DictPair{}}
+	for i := 0; i < len(kv); i += 2 {
+		hk, _ := object.HashKeyOf(kv[i])
+		d.Pairs[hk] = object.DictPair{Key: kv[i], Value: kv[i+1]}
+	}
+	return d
+}
+
+func TestTakeCountsReachableObjectsOnce(t *testing.T) {
+	shared := str("shared")
+	rows := &object.Array{Elements: []object.Object{shared, shared, &object.Integer{Value: 1}}}
+	cache := dictOf(str("rows"), rows)
+	budget := limits.NewBudget(1000)
+	if err := budget.Charge(300); err != nil {
+		t.Fatal(err)
+	}
+
+	snap := Take([]Root{{Name: "cache", Value: cache}, {Name: "alias", Value: rows}}, budget)
+	// cache, rows, "rows", "shared" and 1.
+	if snap.Objects != 5 {
+		t.Fatalf("objects = %d, want 5", snap.Objects)
+	}
+	want := object.CostDict(1) + object.CostArray(3) + object.CostStringBytes(4) + object.CostStringBytes(6)
+	if snap.Bytes != want {
+		t.Fatalf("bytes = %d, want %d", snap.Bytes, want)
+	}
+	if snap.BudgetUsed != 300 || snap.BudgetLimit != 1000 {
+		t.Fatalf("budget = %d/%d, want 300/1000", snap.BudgetUsed, snap.BudgetLimit)
+	}
+	if len(snap.Largest) != 2 || snap.Largest[1].Path != "alias" || snap.Largest[1].Len != 3 {
+		t.Fatalf("largest = %+v, want rows under its shorter path, alias", snap.Largest)
+	}
+	if snap.Types[0].Type != "STRING" || snap.Types[0].Count != 2 {
+		t.Fatalf("types = %+v, want the two strings first", snap.Types)
+	}
+}
+
+func TestTakeFollowsClosuresAndInstances(t *testing.T) {
+	big := &object.Array{Elements: make([]object.Object, 8)}
+	for i := range big.Elements {
+		big.Elements[i] = &object.Integer{Value: int64(i)}
+	}
+	cls := object.NewClass("Box", []string{"items"}, []object.Object{&object.Nil{}}, map[string]object.Object{})
+	inst := cls.New()
+	inst.Fields[0] = dictOf(str("big"), big)
+	cl := &object.Closure{Fn: &object.CompiledFunction{Name: "f"}, Free: []object.Object{&object.Cell{Value: inst}}}
+
+	snap := Take([]Root{{Name: "f", Value: cl}}, nil)
+	if got := snap.Largest[0].Path; got != `f.<captured>[0].items["big"]` {
+		t.Fatalf("path = %q", got)
+	}
+	if snap.BudgetLimit != 0 {
+		t.Fatalf("budget limit = %d without a budget", snap.BudgetLimit)
+	}
+}
+
+func TestSites(t *testing.T) {
+	Track(true)
+	defer Track(false)
+	arr := &object.Array{}
+	Note(arr, "main.wll", 3, 7)
+	Note(arr, "main.wll", 9, 1)
+	Note(str("not a container"), "main.wll", 1, 1)
+
+	if site, ok := SiteOf(arr); !ok || site.String() != "main.wll:3:7" {
+		t.Fatalf("SiteOf = %v, %v; want the first site", site, ok)
+	}
+	snap := Take([]Root{{Name: "a", Value: arr}}, nil)
+	if snap.Largest[0].Site != "main.wll:3:7" {
+		t.Fatalf("largest = %+v", snap.Largest)
+	}
+	runtime.KeepAlive(arr)
+
+	Track(false)
+	if _, ok := SiteOf(arr); ok {
+		t.Fatal("Track(false) should forget sites")
+	}
+	Note(arr, "main.wll", 3, 7)
+	if _, ok := SiteOf(arr); ok {
+		t.Fatal("Note should do nothing while tracking is off")
+	}
+}
+
+func TestSnapshotEncodings(t *testing.T) {
+	snap := Take([]Root{{Name: "xs", Value: &object.Array{}}}, nil)
+	out, err := snap.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back map[string]any
+	if err := json.Unmarshal(out, &back); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"objects", "bytes", "budget_used", "budget_limit", "types", "largest"} {
+		if _, ok := back[key]; !ok {
+			t.Errorf("JSON has no %q", key)
+		}
+		if _, ok := snap.Dict().Pairs[object.StringKey(key)]; !ok {
+			t.Errorf("dict has no %q", key)
+		}
+	}
+	largest := back["largest"].([]any)[0].(map[string]any)
+	if _, ok := largest["site"]; ok {
+		t.Errorf("untracked site should be omitted from JSON: %v", largest)
+	}
+}
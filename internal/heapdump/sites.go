@@ -0,0 +1,107 @@
+package heapdump
+
+import (
+	"fmt"
+	"weak"
+
+	"welle/internal/object"
+)
+
+// Site is where an array or dict was made: the literal, comprehension,
+// builtin call or method call that returned it.
+type Site struct {
+	File string
+	Line int
+	Col  int
+}
+
+func (s Site) String() string {
+	return fmt.Sprintf("%s:%d:%d", s.File, s.Line, s.Col)
+}
+
+// minSites is how many sites are kept before dead entries are pruned.
+const minSites = 1024
+
+var (
+	tracking   bool
+	arraySites = map[weak.Pointer[object.Array]]Site{}
+	dictSites  = map[weak.Pointer[object.Dict]]Site{}
+	pruneAt    = minSites
+)
+
+// Track turns site tracking on or off (-heap-dump turns it on). Sites are
+// only recorded while it is on, so a snapshot taken without it has none.
+func Track(on bool) {
+	tracking = on
+	if !on {
+		clear(arraySites)
+		clear(dictSites)
+		pruneAt = minSites
+	}
+}
+
+// Tracking reports whether the backends should call Note.
+func Tracking() bool {
+	return tracking
+}
+
+// Note records file:line:col as where obj was made, if obj is an array or
+// dict that has no site yet. The first site wins, so a builtin that returns
+// its argument does not move it.
+func Note(obj object.Object, file string, line, col int) {
+	if !tracking {
+		return
+	}
+	site := Site{File: file, Line: line, Col: col}
+	switch v := obj.(type) {
+	case *object.Array:
+		if note(arraySites, v, site) {
+			prune()
+		}
+	case *object.Dict:
+		if note(dictSites, v, site) {
+			prune()
+		}
+	}
+}
+
+func note[T any](sites map[weak.Pointer[T]]Site, obj *T, site Site) bool {
+	p := weak.Make(obj)
+	if _, ok := sites[p]; ok {
+		return false
+	}
+	sites[p] = site
+	return true
+}
+
+// prune drops the sites of collected objects once the tables have doubled
+// since the last time, so a long run does not keep one entry per temporary.
+func prune() {
+	if len(arraySites)+len(dictSites) < pruneAt {
+		return
+	}
+	for p := range arraySites {
+		if p.Value() == nil {
+			delete(arraySites, p)
+		}
+	}
+	for p := range dictSites {
+		if p.Value() == nil {
+			delete(dictSites, p)
+		}
+	}
+	pruneAt = max(minSites, 2*(len(arraySites)+len(dictSites)))
+}
+
+// SiteOf returns where obj was made, if it was noted.
+func SiteOf(obj object.Object) (Site, bool) {
+	var site Site
+	var ok bool
+	switch v := obj.(type) {
+	case *object.Array:
+		site, ok = arraySites[weak.Make(v)]
+	case *object.Dict:
+		site, ok = dictSites[weak.Make(v)]
+	}
+	return site, ok
+}
@@ -0,0 +1,267 @@
+// Package httpserver backs std:httpserver. A server accepts HTTP requests
+// on its own goroutines and queues them; the script pulls them one at a
+// time with Next and answers with Respond, so handlers always run on the
+// interpreter's thread. Each request carries a budget: a body size cap
+// checked before it is queued, a deadline after which the server answers
+// 503 on the script's behalf, and the steps and memory its handler may use,
+// which the backend enforces while the handler runs (see RequestBudget).
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"welle/internal/runtimeio"
+)
+
+// Request is a queued HTTP request as the script sees it.
+type Request struct {
+	ID        int64
+	Method    string
+	Path      string
+	Query     map[string]string
+	Headers   map[string]string
+	Body      string
+	Remote    string
+	WebSocket bool
+}
+
+// Budget bounds a single request.
+type Budget struct {
+	// Timeout is how long the script has to answer; zero waits forever.
+	Timeout time.Duration
+	// MaxBody is the largest accepted request body in bytes; zero means no
+	// limit.
+	MaxBody int64
+	// MaxSteps and MaxMem bound the instructions and the bytes the
+	// request's handler may use; zero means only the run's own limits.
+	MaxSteps int64
+	MaxMem   int64
+}
+
+type server struct {
+	ln     net.Listener
+	srv    *http.Server
+	queue  chan *pending
+	budget Budget
+}
+
+type reply struct {
+	status  int
+	headers map[string]string
+	body    string
+}
+
+type pending struct {
+	srv  *server
+	req  *Request
+	w    http.ResponseWriter
+	r    *http.Request
+	done chan reply
+	// upgraded is closed instead of sending on done when the request has
+	// been taken over as a websocket.
+	upgraded chan struct{}
+}
+
+var (
+	mu       sync.Mutex
+	servers        = map[int64]*server{}
+	inflight       = map[int64]*pending{}
+	nextID   int64 = 1
+)
+
+func newID() int64 {
+	id := nextID
+	nextID++
+	return id
+}
+
+// Listen starts a server on addr and returns its handle and bound address.
+func Listen(addr string, budget Budget) (int64, string, error) {
+	if !runtimeio.AllowNet() {
+		return 0, "", errors.New("httpserver: network access is disabled (run with -allow-net)")
+	}
+	if budget.Timeout < 0 || budget.MaxBody < 0 || budget.MaxSteps < 0 || budget.MaxMem < 0 {
+		return 0, "", errors.New("httpserver: budget limits must not be negative")
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return 0, "", fmt.Errorf("httpserver: %v", err)
+	}
+	s := &server{ln: ln, queue: make(chan *pending), budget: budget}
+	s.srv = &http.Server{Handler: http.HandlerFunc(s.handle)}
+	go s.srv.Serve(ln)
+	mu.Lock()
+	defer mu.Unlock()
+	id := newID()
+	servers[id] = s
+	return id, ln.Addr().String(), nil
+}
+
+func (s *server) handle(w http.ResponseWriter, r *http.Request) {
+	body := r.Body
+	if s.budget.MaxBody > 0 {
+		body = http.MaxBytesReader(w, r.Body, s.budget.MaxBody)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			http.Error(w, "request body exceeds its budget", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "cannot read request body", http.StatusBadRequest)
+		return
+	}
+
+	p := &pending{srv: s, w: w, r: r, done: make(chan reply, 1), upgraded: make(chan struct{})}
+	p.req = &Request{
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Query:     map[string]string{},
+		Headers:   map[string]string{},
+		Body:      string(data),
+		Remote:    r.RemoteAddr,
+		WebSocket: isUpgrade(r),
+	}
+	for k, v := range r.URL.Query() {
+		p.req.Query[k] = v[0]
+	}
+	for k, v := range r.Header {
+		p.req.Headers[strings.ToLower(k)] = v[0]
+	}
+	mu.Lock()
+	p.req.ID = newID()
+	inflight[p.req.ID] = p
+	mu.Unlock()
+	defer take(p.req.ID)
+
+	var deadline <-chan time.Time
+	if s.budget.Timeout > 0 {
+		timer := time.NewTimer(s.budget.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	select {
+	case s.queue <- p:
+	case <-deadline:
+		http.Error(w, "server busy", http.StatusServiceUnavailable)
+		return
+	case <-r.Context().Done():
+		return
+	}
+	select {
+	case rep := <-p.done:
+		p.write(rep)
+	case <-p.upgraded:
+	case <-deadline:
+		if take(p.req.ID) != nil {
+			http.Error(w, "handler exceeded its time budget", http.StatusServiceUnavailable)
+			return
+		}
+		// The script claimed the request just as the deadline passed.
+		select {
+		case rep := <-p.done:
+			p.write(rep)
+		case <-p.upgraded:
+		}
+	case <-r.Context().Done():
+	}
+}
+
+func (p *pending) write(rep reply) {
+	for k, v := range rep.headers {
+		p.w.Header().Set(k, v)
+	}
+	p.w.WriteHeader(rep.status)
+	io.WriteString(p.w, rep.body)
+}
+
+func lookupServer(id int64) (*server, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	s, ok := servers[id]
+	if !ok {
+		return nil, fmt.Errorf("httpserver: server %d is not open", id)
+	}
+	return s, nil
+}
+
+// Next waits up to wait for the next request; zero waits forever. It
+// returns nil when the wait runs out.
+func Next(id int64, wait time.Duration) (*Request, error) {
+	s, err := lookupServer(id)
+	if err != nil {
+		return nil, err
+	}
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case p := <-s.queue:
+		return p.req, nil
+	case <-timeout:
+		return nil, nil
+	}
+}
+
+// RequestBudget returns the budget of a request still waiting for its
+// answer. ok is false once it was answered, upgraded or given up on.
+func RequestBudget(reqID int64) (Budget, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	p, ok := inflight[reqID]
+	if !ok {
+		return Budget{}, false
+	}
+	return p.srv.budget, true
+}
+
+// take removes a request from the in-flight set so it is answered once.
+func take(reqID int64) *pending {
+	mu.Lock()
+	defer mu.Unlock()
+	p, ok := inflight[reqID]
+	if ok {
+		delete(inflight, reqID)
+	}
+	return p
+}
+
+// Respond answers a request. It reports false when the request was
+// already answered, upgraded, or given up on after its deadline.
+func Respond(reqID int64, status int, headers map[string]string, body string) (bool, error) {
+	if status < 100 || status > 999 {
+		return false, fmt.Errorf("httpserver: invalid status %d", status)
+	}
+	p := take(reqID)
+	if p == nil {
+		return false, nil
+	}
+	p.done <- reply{status: status, headers: headers, body: body}
+	return true, nil
+}
+
+// Close stops a server. Requests still waiting are dropped.
+func Close(id int64) error {
+	mu.Lock()
+	s, ok := servers[id]
+	delete(servers, id)
+	mu.Unlock()
+	if !ok {
+		return fmt.Errorf("httpserver: server %d is not open", id)
+	}
+	if err := s.srv.Close(); err != nil {
+		return fmt.Errorf("httpserver: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package httpserver
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"welle/internal/runtimeio"
+)
+
+func startServer(t *testing.T, budget Budget) (int64, string) {
+	t.Helper()
+	runtimeio.SetAllowNet(true)
+	t.Cleanup(func() { runtimeio.SetAllowNet(false) })
+	id, addr, err := Listen("127.0.0.1:0", budget)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { Close(id) })
+	return id, addr
+}
+
+type result struct {
+	status int
+	body   string
+	err    error
+}
+
+func get(url string, body string) <-chan result {
+	out := make(chan result, 1)
+	go func() {
+		method := http.MethodGet
+		if body != "" {
+			method = http.MethodPost
+		}
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			out <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		out <- result{status: resp.StatusCode, body: string(data), err: err}
+	}()
+	return out
+}
+
+func TestNextAndRespond(t *testing.T) {
+	id, addr := startServer(t, Budget{Timeout: 2 * time.Second})
+	if req, err := Next(id, 10*time.Millisecond); req != nil || err != nil {
+		t.Fatalf("expected an idle wait to give nil, got %v %v", req, err)
+	}
+
+	done := get("http://"+addr+"/greet?name=ann", "")
+	req, err := Next(id, 2*time.Second)
+	if err != nil || req == nil {
+		t.Fatalf("next: %v %v", req, err)
+	}
+	if req.Method != "GET" || req.Path != "/greet" || req.Query["name"] != "ann" || req.WebSocket {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	if sent, err := Respond(req.ID, 201, map[string]string{"X-Test": "1"}, "hi ann"); !sent || err != nil {
+		t.Fatalf("respond: %v %v", sent, err)
+	}
+	if sent, _ := Respond(req.ID, 200, nil, "again"); sent {
+		t.Fatalf("expected a second answer to be refused")
+	}
+	if res := <-done; res.err != nil || res.status != 201 || res.body != "hi ann" {
+		t.Fatalf("unexpected response %+v", res)
+	}
+}
+
+func TestBudgets(t *testing.T) {
+	budget := Budget{Timeout: 50 * time.Millisecond, MaxBody: 4, MaxSteps: 1000, MaxMem: 1 << 20}
+	id, addr := startServer(t, budget)
+
+	if res := <-get("http://"+addr+"/", "too long"); res.status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %+v", res)
+	}
+
+	done := get("http://"+addr+"/slow", "")
+	req, err := Next(id, 2*time.Second)
+	if err != nil || req == nil {
+		t.Fatalf("next: %v %v", req, err)
+	}
+	if got, ok := RequestBudget(req.ID); !ok || got != budget {
+		t.Fatalf("expected the server's budget for a waiting request, got %+v %v", got, ok)
+	}
+	res := <-done
+	if res.status != http.StatusServiceUnavailable || !strings.Contains(res.body, "time budget") {
+		t.Fatalf("expected 503 after the deadline, got %+v", res)
+	}
+	if sent, err := Respond(req.ID, 200, nil, "late"); sent || err != nil {
+		t.Fatalf("expected a late answer to be refused, got %v %v", sent, err)
+	}
+	if _, ok := RequestBudget(req.ID); ok {
+		t.Fatalf("expected no budget for a request given up on")
+	}
+
+	for _, b := range []Budget{{MaxSteps: -1}, {MaxMem: -1}} {
+		if _, _, err := Listen("127.0.0.1:0", b); err == nil {
+			t.Fatalf("expected %+v to be rejected", b)
+		}
+	}
+}
+
+func writeClientFrame(t *testing.T, w io.Writer, op byte, payload string) {
+	t.Helper()
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | op, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i := range len(payload) {
+		frame = append(frame, payload[i]^mask[i%4])
+	}
+	if _, err := w.Write(frame); err != nil {
+		t.Fatalf("write frame: %v", err)
+	}
+}
+
+func readServerFrame(t *testing.T, r *bufio.Reader) (byte, string) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	n := int(head[1] & 0x7F)
+	if n == 126 {
+		var ext [2]byte
+		io.ReadFull(r, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, n)
+	io.ReadFull(r, payload)
+	return head[0] & 0x0F, string(payload)
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	id, addr := startServer(t, Budget{Timeout: 2 * time.Second, MaxBody: 64})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: x\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+
+	req, err := Next(id, 2*time.Second)
+	if err != nil || req == nil || !req.WebSocket {
+		t.Fatalf("expected a websocket request, got %+v %v", req, err)
+	}
+	ws, err := Upgrade(req.ID)
+	if err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	r := bufio.NewReader(conn)
+	status, _ := r.ReadString('\n')
+	if !strings.Contains(status, "101") {
+		t.Fatalf("expected 101, got %q", status)
+	}
+	for {
+		line, _ := r.ReadString('\n')
+		if line == "\r\n" {
+			break
+		}
+		// Known answer from RFC 6455, section 1.3.
+		if strings.HasPrefix(line, "Sec-WebSocket-Accept:") && !strings.Contains(line, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=") {
+			t.Fatalf("bad accept header %q", line)
+		}
+	}
+
+	writeClientFrame(t, conn, opPing, "p")
+	writeClientFrame(t, conn, opText, "hello")
+	msg, closed, err := WSRecv(ws)
+	if err != nil || closed || msg != "hello" {
+		t.Fatalf("recv = %q closed=%v err=%v", msg, closed, err)
+	}
+	if op, payload := readServerFrame(t, r); op != opPong || payload != "p" {
+		t.Fatalf("expected pong, got op %d %q", op, payload)
+	}
+	if err := WSSend(ws, "echo: "+msg); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if op, payload := readServerFrame(t, r); op != opText || payload != "echo: hello" {
+		t.Fatalf("expected echo, got op %d %q", op, payload)
+	}
+
+	writeClientFrame(t, conn, opClose, "")
+	if _, closed, err := WSRecv(ws); !closed || err != nil {
+		t.Fatalf("expected close, got closed=%v err=%v", closed, err)
+	}
+	if err := WSClose(ws); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+}
+
+func TestCapability(t *testing.T) {
+	runtimeio.SetAllowNet(false)
+	if _, _, err := Listen("127.0.0.1:0", Budget{}); err == nil || !strings.Contains(err.Error(), "-allow-net") {
+		t.Fatalf("expected capability error, got %v", err)
+	}
+}
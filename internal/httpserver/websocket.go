@@ -0,0 +1,249 @@
+package httpserver
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// websocketGUID is the fixed key suffix from RFC 6455, section 1.3.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+type wsConn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	maxSize int64
+	wmu     sync.Mutex
+	closed  bool
+}
+
+var sockets = map[int64]*wsConn{}
+
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isUpgrade(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		headerHas(r.Header, "Connection", "upgrade") &&
+		headerHas(r.Header, "Upgrade", "websocket")
+}
+
+// acceptKey computes the Sec-WebSocket-Accept value for a client key.
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Upgrade completes the websocket handshake for a queued request and
+// returns the socket's handle. Messages larger than the server's body
+// budget are refused.
+func Upgrade(reqID int64) (int64, error) {
+	mu.Lock()
+	p, ok := inflight[reqID]
+	mu.Unlock()
+	if !ok {
+		return 0, fmt.Errorf("httpserver: request %d was already answered or timed out", reqID)
+	}
+	if !p.req.WebSocket {
+		return 0, fmt.Errorf("httpserver: request %d is not a websocket upgrade", reqID)
+	}
+	key := p.r.Header.Get("Sec-WebSocket-Key")
+	if key == "" || p.r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return 0, errors.New("httpserver: unsupported websocket handshake")
+	}
+	hj, ok := p.w.(http.Hijacker)
+	if !ok {
+		return 0, errors.New("httpserver: connection cannot be upgraded")
+	}
+	if take(reqID) == nil {
+		return 0, fmt.Errorf("httpserver: request %d was already answered or timed out", reqID)
+	}
+	conn, rw, err := hj.Hijack()
+	close(p.upgraded)
+	if err != nil {
+		return 0, fmt.Errorf("httpserver: %v", err)
+	}
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := io.WriteString(conn, resp); err != nil {
+		conn.Close()
+		return 0, fmt.Errorf("httpserver: %v", err)
+	}
+
+	mu.Lock()
+	id := newID()
+	sockets[id] = &wsConn{conn: conn, r: rw.Reader, maxSize: p.srv.budget.MaxBody}
+	mu.Unlock()
+	return id, nil
+}
+
+func lookupSocket(id int64) (*wsConn, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	ws, ok := sockets[id]
+	if !ok {
+		return nil, fmt.Errorf("httpserver: websocket %d is not open", id)
+	}
+	return ws, nil
+}
+
+// WSSend sends one text message.
+func WSSend(id int64, msg string) error {
+	ws, err := lookupSocket(id)
+	if err != nil {
+		return err
+	}
+	if err := ws.writeFrame(opText, []byte(msg)); err != nil {
+		return fmt.Errorf("httpserver: %v", err)
+	}
+	return nil
+}
+
+// WSRecv waits for the next text or binary message. Pings are answered
+// while waiting. closed is true once the peer has closed the socket.
+func WSRecv(id int64) (msg string, closed bool, err error) {
+	ws, err := lookupSocket(id)
+	if err != nil {
+		return "", false, err
+	}
+	var buf []byte
+	for {
+		fin, op, payload, err := ws.readFrame()
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return "", true, nil
+			}
+			return "", false, fmt.Errorf("httpserver: %v", err)
+		}
+		switch op {
+		case opPing:
+			if err := ws.writeFrame(opPong, payload); err != nil {
+				return "", false, fmt.Errorf("httpserver: %v", err)
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			ws.writeFrame(opClose, payload)
+			return "", true, nil
+		case opText, opBinary, opContinuation:
+			buf = append(buf, payload...)
+			if ws.maxSize > 0 && int64(len(buf)) > ws.maxSize {
+				ws.writeFrame(opClose, []byte{0x03, 0xF1}) // 1009: message too big
+				return "", false, errors.New("httpserver: websocket message exceeds its budget")
+			}
+		default:
+			return "", false, fmt.Errorf("httpserver: unknown websocket opcode %d", op)
+		}
+		if fin {
+			return string(buf), false, nil
+		}
+	}
+}
+
+// WSClose sends a close frame and closes the connection.
+func WSClose(id int64) error {
+	mu.Lock()
+	ws, ok := sockets[id]
+	delete(sockets, id)
+	mu.Unlock()
+	if !ok {
+		return fmt.Errorf("httpserver: websocket %d is not open", id)
+	}
+	ws.writeFrame(opClose, []byte{0x03, 0xE8}) // 1000: normal closure
+	return ws.conn.Close()
+}
+
+func (ws *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(ws.r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = head[0]&0x80 != 0
+	op = head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if ws.maxSize > 0 && n > uint64(ws.maxSize) {
+		return false, 0, nil, errors.New("websocket message exceeds its budget")
+	}
+	if !masked {
+		return false, 0, nil, errors.New("client websocket frames must be masked")
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.r, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(ws.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// writeFrame sends one unmasked, unfragmented frame, as servers do.
+func (ws *wsConn) writeFrame(op byte, payload []byte) error {
+	ws.wmu.Lock()
+	defer ws.wmu.Unlock()
+	if ws.closed {
+		return errors.New("websocket is closed")
+	}
+	if op == opClose {
+		ws.closed = true
+	}
+	frame := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	_, err := ws.conn.Write(append(frame, payload...))
+	return err
+}
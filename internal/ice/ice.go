@@ -0,0 +1,150 @@
+// Package ice handles internal compiler errors: panics inside the toolchain
+// itself. The parser, the compiler and the VM recover at their entry points
+// and turn a panic into an *Error, so a bug one file trips is reported as a
+// diagnostic instead of crashing welle, or welle-lsp with every open
+// document in it. The report carries the Go stack and, where the failing
+// step can safely be run again, the smallest part of the input found to
+// still cause the panic.
+package ice
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"welle/internal/diag"
+)
+
+// Code is the diagnostic code of an internal error.
+const Code = "WI0001"
+
+// Error is a panic recovered at an entry point.
+type Error struct {
+	// Phase is the part of the toolchain that panicked: "parser",
+	// "compiler", "vm", or "lsp METHOD" for a language server handler.
+	Phase string
+	// Value is what was panicked with.
+	Value any
+	// Stack is the Go stack of the panic.
+	Stack string
+	// Repro is the minimized input that still panics, or "" when the
+	// phase cannot be run again.
+	Repro string
+	// Context says where in the program the panic happened, when known.
+	Context string
+}
+
+// New returns the error for the panic v in phase. Call it from the deferred
+// function that recovered v, so the stack is the panic's.
+func New(phase string, v any) *Error {
+	return &Error{Phase: phase, Value: v, Stack: string(debug.Stack())}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("internal error, please report, code %s (%s): %v", Code, e.Phase, e.Value)
+}
+
+// Diagnostic returns e as an error at line and col.
+func (e *Error) Diagnostic(line, col int) diag.Diagnostic {
+	return diag.Diagnostic{
+		Code:     Code,
+		Message:  e.Error(),
+		Severity: diag.SeverityError,
+		Range:    diag.Range{Line: line, Col: col, Length: 1},
+	}
+}
+
+// Report renders e for a bug report.
+func (e *Error) Report() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "welle internal error %s\n\n", Code)
+	fmt.Fprintf(&b, "phase: %s\npanic: %v\ngo:    %s %s/%s\n", e.Phase, e.Value, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if e.Context != "" {
+		fmt.Fprintf(&b, "\n== context ==\n%s\n", strings.TrimRight(e.Context, "\n"))
+	}
+	if e.Repro != "" {
+		fmt.Fprintf(&b, "\n== reproduction ==\n%s\n", strings.TrimRight(e.Repro, "\n"))
+	}
+	fmt.Fprintf(&b, "\n== stack ==\n%s", e.Stack)
+	return b.String()
+}
+
+var reporter = writeReport
+
+// SetReporter sets what is done with each internal error as it is
+// recovered. By default the report is written to a file in the temporary
+// directory, whose path is printed on stderr; a nil fn restores that.
+func SetReporter(fn func(*Error)) {
+	if fn == nil {
+		fn = writeReport
+	}
+	reporter = fn
+}
+
+// Report hands e to the reporter.
+func Report(e *Error) {
+	reporter(e)
+}
+
+func writeReport(e *Error) {
+	f, err := os.CreateTemp("", "welle-ice-*.txt")
+	if err == nil {
+		_, err = f.WriteString(e.Report())
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "welle: %s\n%s", e.Error(), e.Report())
+		return
+	}
+	fmt.Fprintf(os.Stderr, "welle: %s\nwelle: the report is in %s; please attach it to a bug report\n", e.Error(), f.Name())
+}
+
+// Panics reports whether f panics with the same message as v, the test a
+// candidate reproduction has to pass.
+func Panics(v any, f func()) (same bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			same = fmt.Sprint(r) == fmt.Sprint(v)
+		}
+	}()
+	f()
+	return false
+}
+
+// maxTries bounds how many candidates Minimize tries, so a large input is
+// cut down as far as it gets in reasonable time.
+const maxTries = 500
+
+// Minimize returns the smallest sublist of items it finds for which fails
+// is still true, removing ever smaller chunks the way delta debugging does.
+// fails is expected to hold for items itself.
+func Minimize[T any](items []T, fails func([]T) bool) []T {
+	tries := 0
+	for n := 2; len(items) > 1; {
+		size := (len(items) + n - 1) / n
+		removed := false
+		for start := 0; start < len(items); start += size {
+			end := min(start+size, len(items))
+			cand := append(append([]T{}, items[:start]...), items[end:]...)
+			if tries++; tries > maxTries {
+				return items
+			}
+			if fails(cand) {
+				items, removed = cand, true
+				n = max(n-1, 2)
+				break
+			}
+		}
+		if !removed {
+			if size == 1 {
+				break
+			}
+			n = min(2*n, len(items))
+		}
+	}
+	return items
+}
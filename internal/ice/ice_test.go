@@ -0,0 +1,67 @@
+package ice
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMinimize(t *testing.T) {
+	var lines []string
+	for i := range 40 {
+		lines = append(lines, strings.Repeat("x", i))
+	}
+	lines[7], lines[31] = "a", "b"
+	needsBoth := func(ls []string) bool {
+		return slices.Contains(ls, "a") && slices.Contains(ls, "b")
+	}
+	if got := Minimize(lines, needsBoth); !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("Minimize = %q, want [a b]", got)
+	}
+	never := func([]string) bool { return false }
+	if got := Minimize(lines, never); len(got) != len(lines) {
+		t.Fatalf("Minimize kept %d of %d lines when nothing reproduces", len(got), len(lines))
+	}
+}
+
+func TestReport(t *testing.T) {
+	var reported []*Error
+	defer SetReporter(nil)
+	SetReporter(func(e *Error) { reported = append(reported, e) })
+
+	func() {
+		defer func() {
+			Report(New("vm", recover()))
+		}()
+		var m map[string]int
+		m["x"] = 1
+	}()
+	if len(reported) != 1 {
+		t.Fatalf("reported %d errors, want 1", len(reported))
+	}
+	e := reported[0]
+	if want := "internal error, please report, code WI0001 (vm): assignment to entry in nil map"; e.Error() != want {
+		t.Fatalf("message = %q, want %q", e.Error(), want)
+	}
+	report := e.Report()
+	for _, part := range []string{"phase: vm", "== stack ==", "TestReport"} {
+		if !strings.Contains(report, part) {
+			t.Errorf("report has no %q:\n%s", part, report)
+		}
+	}
+	if strings.Contains(report, "== reproduction ==") {
+		t.Errorf("report without a reproduction shows one:\n%s", report)
+	}
+}
+
+func TestPanics(t *testing.T) {
+	if !Panics("boom", func() { panic("boom") }) {
+		t.Error("same panic should match")
+	}
+	if Panics("boom", func() { panic("other") }) {
+		t.Error("a different panic should not match")
+	}
+	if Panics("boom", func() {}) {
+		t.Error("no panic should not match")
+	}
+}
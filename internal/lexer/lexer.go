@@ -27,6 +27,16 @@ func New(input string) *Lexer {
 	return l
 }
 
+// Input returns the source the lexer reads.
+func (l *Lexer) Input() string {
+	return l.input
+}
+
+// Len returns the size of the input in bytes.
+func (l *Lexer) Len() int {
+	return len(l.input)
+}
+
 func (l *Lexer) NextToken() token.Token {
 	// Skip spaces/tabs and comments, but NOT newlines.
 	for {
@@ -83,6 +93,10 @@ func (l *Lexer) NextToken() token.Token {
 		tok := l.newToken(token.RBRACE, "}", startLine, startCol)
 		l.readChar()
 		return tok
+	case '@':
+		tok := l.newToken(token.AT, "@", startLine, startCol)
+		l.readChar()
+		return tok
 	case '#':
 		tok := l.newToken(token.HASH, "#", startLine, startCol)
 		l.readChar()
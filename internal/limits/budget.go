@@ -7,6 +7,8 @@ const MemoryErrorCode int64 = 8001
 type Budget struct {
 	limit int64
 	used  int64
+	// parent, when set, is charged for everything charged here.
+	parent *Budget
 }
 
 func NewBudget(limit int64) *Budget {
@@ -16,6 +18,16 @@ func NewBudget(limit int64) *Budget {
 	return &Budget{limit: limit}
 }
 
+// Child returns a budget capped at limit (0 = no cap of its own) whose
+// charges also count against b, so what runs on it can spend no more than
+// either allows.
+func (b *Budget) Child(limit int64) *Budget {
+	if limit < 0 {
+		limit = 0
+	}
+	return &Budget{limit: limit, parent: b}
+}
+
 func (b *Budget) Limit() int64 {
 	if b == nil {
 		return 0
@@ -42,16 +54,18 @@ func (e MaxMemoryError) Error() string {
 	return MaxMemoryMessage(e.Limit)
 }
 
+// Charge adds n bytes to the budget, failing once it would pass the limit.
+// An unlimited budget still tracks what was used.
 func (b *Budget) Charge(n int64) error {
-	if b == nil || b.limit == 0 {
-		return nil
-	}
-	if n <= 0 {
+	if b == nil || n <= 0 {
 		return nil
 	}
-	if b.used+n > b.limit {
+	if b.limit > 0 && b.used+n > b.limit {
 		return MaxMemoryError{Limit: b.limit}
 	}
+	if err := b.parent.Charge(n); err != nil {
+		return err
+	}
 	b.used += n
 	return nil
 }
@@ -21,3 +21,53 @@ func TestBudgetUnlimited(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestBudgetUnlimitedTracksUsed(t *testing.T) {
+	b := NewBudget(0)
+	_ = b.Charge(40)
+	_ = b.Charge(2)
+	if b.Used() != 42 {
+		t.Fatalf("used = %d, want 42", b.Used())
+	}
+}
+
+func TestChildBudgetChargesParent(t *testing.T) {
+	parent := NewBudget(100)
+	child := parent.Child(30)
+	if err := child.Charge(20); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if parent.Used() != 20 {
+		t.Fatalf("parent used = %d, want 20", parent.Used())
+	}
+	err := child.Charge(20)
+	if memErr, ok := err.(MaxMemoryError); !ok || memErr.Limit != 30 {
+		t.Fatalf("expected child limit error, got %v", err)
+	}
+	if err := parent.Charge(75); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = child.Charge(10)
+	if memErr, ok := err.(MaxMemoryError); !ok || memErr.Limit != 100 {
+		t.Fatalf("expected parent limit error, got %v", err)
+	}
+	if child.Used() != 20 {
+		t.Fatalf("child used = %d, want 20", child.Used())
+	}
+}
+
+func TestForModule(t *testing.T) {
+	rules := []ModuleLimits{
+		{Path: "/p/vendor", MaxSteps: 100},
+		{Path: "/p/vendor/json.wll", MaxSteps: 10},
+	}
+	if r, ok := ForModule(rules, "/p/vendor/json.wll"); !ok || r.MaxSteps != 10 {
+		t.Fatalf("expected file rule, got %+v %v", r, ok)
+	}
+	if r, ok := ForModule(rules, "/p/vendor/csv/csv.wll"); !ok || r.MaxSteps != 100 {
+		t.Fatalf("expected directory rule, got %+v %v", r, ok)
+	}
+	if _, ok := ForModule(rules, "/p/vendored.wll"); ok {
+		t.Fatal("rule should not match a sibling with the same prefix")
+	}
+}
@@ -0,0 +1,43 @@
+package limits
+
+import "sync/atomic"
+
+// InterruptCode is the error code of the error raised when a run is
+// interrupted (Ctrl-C). Unlike other errors it cannot be caught: it unwinds
+// through finally blocks and defers and ends the run.
+const InterruptCode int64 = 8002
+
+const InterruptMessage = "interrupted"
+
+var (
+	interruptPending atomic.Bool
+	interruptSeen    atomic.Bool
+)
+
+// Interrupt asks the running program to stop at its next safe point. It is
+// safe to call from a signal-handling goroutine.
+func Interrupt() {
+	interruptPending.Store(true)
+}
+
+// TakeInterrupt reports whether an interrupt is pending and clears it, so
+// the backend raises it once and the cleanup code it unwinds through runs
+// undisturbed.
+func TakeInterrupt() bool {
+	if !interruptPending.Load() || !interruptPending.CompareAndSwap(true, false) {
+		return false
+	}
+	interruptSeen.Store(true)
+	return true
+}
+
+// InterruptPending reports whether an interrupt is waiting to be raised,
+// without taking it. Builtins that block use it to return early.
+func InterruptPending() bool {
+	return interruptPending.Load()
+}
+
+// Interrupted reports whether an interrupt has been raised in this process.
+func Interrupted() bool {
+	return interruptSeen.Load()
+}
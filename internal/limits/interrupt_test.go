@@ -0,0 +1,19 @@
+package limits
+
+import "testing"
+
+func TestTakeInterruptConsumesPending(t *testing.T) {
+	if TakeInterrupt() {
+		t.Fatal("no interrupt pending yet")
+	}
+	Interrupt()
+	if !TakeInterrupt() {
+		t.Fatal("expected pending interrupt")
+	}
+	if TakeInterrupt() {
+		t.Fatal("interrupt should be raised once")
+	}
+	if !Interrupted() {
+		t.Fatal("Interrupted should stay set after TakeInterrupt")
+	}
+}
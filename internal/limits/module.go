@@ -0,0 +1,39 @@
+package limits
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// ModuleLimits are stricter limits for the module at Path, or for every
+// module under it when Path is a directory. Zero leaves the run's own limit
+// in place.
+type ModuleLimits struct {
+	Path     string
+	MaxSteps int64
+	MaxMem   int64
+}
+
+// ForModule returns the rule for the module at path: of the rules whose Path
+// is path or a directory holding it, the one with the longest Path.
+func ForModule(rules []ModuleLimits, path string) (ModuleLimits, bool) {
+	var best ModuleLimits
+	found := false
+	for _, rule := range rules {
+		if path != rule.Path && !strings.HasPrefix(path, rule.Path+string(filepath.Separator)) {
+			continue
+		}
+		if !found || len(rule.Path) > len(best.Path) {
+			best, found = rule, true
+		}
+	}
+	return best, found
+}
+
+// Stricter returns the tighter of two limits where 0 means unlimited.
+func Stricter(a, b int64) int64 {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
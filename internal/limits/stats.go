@@ -0,0 +1,73 @@
+package limits
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Stats collects what a run used, for `welle run --stats`: the steps it
+// executed, the allocations it charged by kind, and how long each module
+// took to load. A nil *Stats records nothing.
+type Stats struct {
+	Steps  int64
+	Allocs map[string]int64
+	Loads  []ModuleLoad
+}
+
+// ModuleLoad is the time spent reading and compiling (or parsing) one module.
+type ModuleLoad struct {
+	Path string
+	Time time.Duration
+}
+
+func NewStats() *Stats {
+	return &Stats{Allocs: map[string]int64{}}
+}
+
+// CountAlloc records one allocation of the given kind ("string", "array",
+// ...).
+func (s *Stats) CountAlloc(kind string) {
+	if s == nil {
+		return
+	}
+	s.Allocs[kind]++
+}
+
+// AddLoad records that loading path took d.
+func (s *Stats) AddLoad(path string, d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.Loads = append(s.Loads, ModuleLoad{Path: path, Time: d})
+}
+
+// Write prints the report. stepName names what Steps counts ("instructions"
+// for the VM, "statements" for the interpreter); budget supplies memory use
+// and may be nil.
+func (s *Stats) Write(w io.Writer, stepName string, budget *Budget, wall time.Duration) {
+	fmt.Fprintln(w, "== stats ==")
+	fmt.Fprintf(w, "wall time: %s\n", wall.Round(time.Microsecond))
+	fmt.Fprintf(w, "%s: %d\n", stepName, s.Steps)
+	if limit := budget.Limit(); limit > 0 {
+		fmt.Fprintf(w, "memory: %d of %d bytes (%.1f%%)\n", budget.Used(), limit, 100*float64(budget.Used())/float64(limit))
+	} else {
+		fmt.Fprintf(w, "memory: %d bytes (unlimited)\n", budget.Used())
+	}
+	kinds := make([]string, 0, len(s.Allocs))
+	var total int64
+	for kind, n := range s.Allocs {
+		kinds = append(kinds, kind)
+		total += n
+	}
+	sort.Strings(kinds)
+	fmt.Fprintf(w, "allocations: %d\n", total)
+	for _, kind := range kinds {
+		fmt.Fprintf(w, "  %-10s %d\n", kind, s.Allocs[kind])
+	}
+	fmt.Fprintf(w, "modules: %d\n", len(s.Loads))
+	for _, load := range s.Loads {
+		fmt.Fprintf(w, "  %-10s %s\n", load.Time.Round(time.Microsecond), load.Path)
+	}
+}
@@ -0,0 +1,16 @@
+package limits
+
+// StepsErrorCode is the error code of the error raised when a run passes its
+// instruction limit.
+const StepsErrorCode int64 = 8003
+
+// StepGrace is how many more instructions a run that passed its instruction
+// limit of max gets to handle the error and clean up: a tenth of the limit,
+// but at least 100. A run that spends its grace as well ends, and that second
+// error cannot be caught.
+func StepGrace(max int64) int64 {
+	if g := max / 10; g > 100 {
+		return g
+	}
+	return 100
+}
@@ -0,0 +1,241 @@
+package lint
+
+import (
+	"fmt"
+
+	"welle/internal/ast"
+	"welle/internal/token"
+)
+
+// funcMetrics accumulates size/shape measurements for a single function
+// body. Nested function literals are measured on their own and do not
+// contribute to the enclosing function.
+type funcMetrics struct {
+	complexity int
+	depth      int
+	maxDepth   int
+	statements int
+}
+
+func (r *Runner) checkFunctionMetrics(name string, tok token.Token, body *ast.BlockStatement) {
+	if r.opts.MaxComplexity <= 0 && r.opts.MaxNesting <= 0 && r.opts.MaxStatements <= 0 {
+		return
+	}
+	if name == "" {
+		name = "anonymous function"
+	} else {
+		name = "function '" + name + "'"
+	}
+	m := &funcMetrics{complexity: 1}
+	m.block(body)
+
+	if r.opts.MaxComplexity > 0 && m.complexity > r.opts.MaxComplexity {
+		r.warn(tok, "WL0010", fmt.Sprintf("%s has cyclomatic complexity %d (max %d)", name, m.complexity, r.opts.MaxComplexity))
+	}
+	if r.opts.MaxNesting > 0 && m.maxDepth > r.opts.MaxNesting {
+		r.warn(tok, "WL0011", fmt.Sprintf("%s has nesting depth %d (max %d)", name, m.maxDepth, r.opts.MaxNesting))
+	}
+	if r.opts.MaxStatements > 0 && m.statements > r.opts.MaxStatements {
+		r.warn(tok, "WL0012", fmt.Sprintf("%s has %d statements (max %d)", name, m.statements, r.opts.MaxStatements))
+	}
+}
+
+func (m *funcMetrics) block(b *ast.BlockStatement) {
+	if b == nil {
+		return
+	}
+	for _, st := range b.Statements {
+		m.stmt(st)
+	}
+}
+
+func (m *funcMetrics) nested(fn func()) {
+	m.depth++
+	if m.depth > m.maxDepth {
+		m.maxDepth = m.depth
+	}
+	fn()
+	m.depth--
+}
+
+func (m *funcMetrics) stmt(st ast.Statement) {
+	if st == nil {
+		return
+	}
+	if _, ok := st.(*ast.BlockStatement); !ok {
+		m.statements++
+	}
+	switch n := st.(type) {
+	case *ast.BlockStatement:
+		m.block(n)
+	case *ast.ExpressionStatement:
+		m.expr(n.Expression)
+	case *ast.AssignStatement:
+		m.expr(n.Value)
+	case *ast.IndexAssignStatement:
+		m.expr(n.Left)
+		m.expr(n.Value)
+	case *ast.MemberAssignStatement:
+		m.expr(n.Object)
+		m.expr(n.Value)
+	case *ast.DestructureAssignStatement:
+		m.expr(n.Value)
+	case *ast.ReturnStatement:
+		for _, v := range n.ReturnValues {
+			m.expr(v)
+		}
+	case *ast.DeferStatement:
+		m.expr(n.Call)
+	case *ast.DelStatement:
+		m.expr(n.Target)
+	case *ast.YieldStatement:
+		m.expr(n.Value)
+	case *ast.ThrowStatement:
+		m.expr(n.Value)
+	case *ast.AssertStatement:
+		m.expr(n.Condition)
+		m.expr(n.Message)
+	case *ast.ExportStatement:
+		m.stmt(n.Stmt)
+	case *ast.IfStatement:
+		m.complexity++
+		m.expr(n.Condition)
+		m.nested(func() {
+			m.stmt(n.Consequence)
+			if alt, ok := n.Alternative.(*ast.IfStatement); ok {
+				// `else if` chains read as one level, not a staircase.
+				m.depth--
+				m.stmt(alt)
+				m.depth++
+				return
+			}
+			m.stmt(n.Alternative)
+		})
+	case *ast.WhileStatement:
+		m.complexity++
+		m.expr(n.Condition)
+		m.nested(func() { m.block(n.Body) })
+	case *ast.ForStatement:
+		m.complexity++
+		m.expr(n.Cond)
+		m.nested(func() { m.block(n.Body) })
+	case *ast.ForInStatement:
+		m.complexity++
+		m.expr(n.Iterable)
+		m.nested(func() { m.block(n.Body) })
+	case *ast.SwitchStatement:
+		m.expr(n.Value)
+		m.nested(func() {
+			for _, c := range n.Cases {
+				if c == nil {
+					continue
+				}
+				m.complexity++
+				m.block(c.Body)
+			}
+			m.block(n.Default)
+		})
+	case *ast.TryStatement:
+		m.nested(func() {
+			m.block(n.TryBlock)
+			if n.CatchBlock != nil {
+				m.complexity++
+				m.expr(n.CatchFilter)
+				m.block(n.CatchBlock)
+			}
+			m.block(n.FinallyBlock)
+		})
+	}
+}
+
+func (m *funcMetrics) expr(e ast.Expression) {
+	switch n := e.(type) {
+	case nil:
+		return
+	case *ast.InfixExpression:
+		if n.Operator == "and" || n.Operator == "or" || n.Operator == "??" {
+			m.complexity++
+		}
+		m.expr(n.Left)
+		m.expr(n.Right)
+	case *ast.PrefixExpression:
+		m.expr(n.Right)
+	case *ast.ConditionalExpression:
+		m.complexity++
+		m.expr(n.Cond)
+		m.expr(n.Then)
+		m.expr(n.Else)
+	case *ast.CondExpr:
+		m.complexity++
+		m.expr(n.Cond)
+		m.expr(n.Then)
+		m.expr(n.Else)
+	case *ast.AssignExpression:
+		m.expr(n.Value)
+	case *ast.CallExpression:
+		m.expr(n.Function)
+		for _, a := range n.Arguments {
+			m.expr(a)
+		}
+	case *ast.SpreadExpression:
+		m.expr(n.Value)
+	case *ast.MemberExpression:
+		m.expr(n.Object)
+	case *ast.IndexExpression:
+		m.expr(n.Left)
+		m.expr(n.Index)
+	case *ast.SliceExpression:
+		m.expr(n.Left)
+		m.expr(n.Low)
+		m.expr(n.High)
+		m.expr(n.Step)
+	case *ast.ListLiteral:
+		for _, el := range n.Elements {
+			m.expr(el)
+		}
+	case *ast.SetLiteral:
+		for _, el := range n.Elements {
+			m.expr(el)
+		}
+	case *ast.TupleLiteral:
+		for _, el := range n.Elements {
+			m.expr(el)
+		}
+	case *ast.NamedTupleLiteral:
+		for _, f := range n.Fields {
+			m.expr(f.Value)
+		}
+	case *ast.ListComprehension:
+		m.complexity++
+		if n.Filter != nil {
+			m.complexity++
+		}
+		m.expr(n.Seq)
+		m.expr(n.Filter)
+		m.expr(n.Elem)
+	case *ast.DictLiteral:
+		for _, p := range n.Pairs {
+			if p.Spread != nil {
+				m.expr(p.Spread)
+				continue
+			}
+			m.expr(p.Key)
+			m.expr(p.Value)
+		}
+	case *ast.MatchExpression:
+		m.expr(n.Value)
+		for _, c := range n.Cases {
+			if c == nil {
+				continue
+			}
+			m.complexity++
+			m.expr(c.Guard)
+			m.expr(c.Result)
+		}
+		m.expr(n.Default)
+	case *ast.TemplateLiteral:
+		for _, ex := range n.Exprs {
+			m.expr(ex)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package lint
+
+import (
+	"fmt"
+
+	"welle/internal/ast"
+	"welle/internal/object"
+	"welle/internal/semantics"
+	"welle/internal/token"
+)
+
+// constValue folds e to a runtime value when it is built only from literals.
+// Operators go through the shared semantics package so the result matches
+// what the interpreter and VM would compute.
+func constValue(e ast.Expression) (object.Object, bool) {
+	switch n := e.(type) {
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: n.Value}, true
+	case *ast.FloatLiteral:
+		return &object.Float{Value: n.Value}, true
+	case *ast.StringLiteral:
+		return &object.String{Value: n.Value}, true
+	case *ast.BooleanLiteral:
+		return &object.Boolean{Value: n.Value}, true
+	case *ast.NilLiteral:
+		return &object.Nil{}, true
+	case *ast.PrefixExpression:
+		right, ok := constValue(n.Right)
+		if !ok {
+			return nil, false
+		}
+		switch n.Operator {
+		case "not", "!":
+			return &object.Boolean{Value: !semantics.IsTruthy(right)}, true
+		case "-":
+			switch v := right.(type) {
+			case *object.Integer:
+				return &object.Integer{Value: -v.Value}, true
+			case *object.Float:
+				return &object.Float{Value: -v.Value}, true
+			}
+		case "~":
+			if out, err := semantics.BitwiseUnary(n.Operator, right); err == nil {
+				return out, true
+			}
+		}
+		return nil, false
+	case *ast.InfixExpression:
+		left, ok := constValue(n.Left)
+		if !ok {
+			return nil, false
+		}
+		right, ok := constValue(n.Right)
+		if !ok {
+			return nil, false
+		}
+		switch n.Operator {
+		case "and":
+			return &object.Boolean{Value: semantics.IsTruthy(left) && semantics.IsTruthy(right)}, true
+		case "or":
+			return &object.Boolean{Value: semantics.IsTruthy(left) || semantics.IsTruthy(right)}, true
+		case "??":
+			if left.Type() == object.NIL_OBJ {
+				return right, true
+			}
+			return left, true
+		case "==", "!=", "<", "<=", ">", ">=", "is":
+			b, err := semantics.Compare(n.Operator, left, right)
+			if err != nil {
+				return nil, false
+			}
+			return &object.Boolean{Value: b}, true
+		default:
+			out, err := semantics.BinaryOp(n.Operator, left, right)
+			if err != nil {
+				return nil, false
+			}
+			return out, true
+		}
+	}
+	return nil, false
+}
+
+func isComparisonOp(op string) bool {
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+// checkConstComparison flags literal comparisons that are guaranteed to
+// raise a runtime error (e.g. `1 == "1"`, `"a" < "b"`).
+func (r *Runner) checkConstComparison(n *ast.InfixExpression) {
+	if !isComparisonOp(n.Operator) {
+		return
+	}
+	left, ok := constValue(n.Left)
+	if !ok {
+		return
+	}
+	right, ok := constValue(n.Right)
+	if !ok {
+		return
+	}
+	if _, err := semantics.Compare(n.Operator, left, right); err != nil {
+		r.warn(n.Token, "WL0008", fmt.Sprintf("comparison always fails at runtime: %s", err))
+	}
+}
+
+func (r *Runner) checkConstCondition(tok token.Token, cond ast.Expression) {
+	v, ok := constValue(cond)
+	if !ok {
+		return
+	}
+	r.warn(tok, "WL0006", fmt.Sprintf("condition is always %t", semantics.IsTruthy(v)))
+}
+
+func (r *Runner) checkWhileCondition(n *ast.WhileStatement) {
+	v, ok := constValue(n.Condition)
+	if !ok {
+		return
+	}
+	if !semantics.IsTruthy(v) {
+		r.warn(n.Token, "WL0006", "condition is always false")
+		return
+	}
+	if _, literal := n.Condition.(*ast.BooleanLiteral); !literal {
+		r.warn(n.Token, "WL0006", "condition is always true")
+	}
+	if !blockExitsLoop(n.Body) {
+		r.warn(n.Token, "WL0007", "infinite loop: condition is always true and the body never breaks, returns, or throws")
+	}
+}
+
+// blockExitsLoop reports whether b contains a statement that leaves the
+// enclosing loop: a `break` at this loop level, or any `return`/`throw`.
+// Breaks inside nested loops and switches only leave those constructs.
+func blockExitsLoop(b *ast.BlockStatement) bool {
+	return stmtsExitLoop(b, true)
+}
+
+func stmtsExitLoop(b *ast.BlockStatement, breakExits bool) bool {
+	if b == nil {
+		return false
+	}
+	for _, st := range b.Statements {
+		if stmtExitsLoop(st, breakExits) {
+			return true
+		}
+	}
+	return false
+}
+
+func stmtExitsLoop(st ast.Statement, breakExits bool) bool {
+	switch n := st.(type) {
+	case *ast.BreakStatement:
+		return breakExits
+	case *ast.ReturnStatement, *ast.ThrowStatement:
+		return true
+	case *ast.BlockStatement:
+		return stmtsExitLoop(n, breakExits)
+	case *ast.IfStatement:
+		return (n.Consequence != nil && stmtExitsLoop(n.Consequence, breakExits)) ||
+			(n.Alternative != nil && stmtExitsLoop(n.Alternative, breakExits))
+	case *ast.TryStatement:
+		return stmtsExitLoop(n.TryBlock, breakExits) ||
+			stmtsExitLoop(n.CatchBlock, breakExits) ||
+			stmtsExitLoop(n.FinallyBlock, breakExits)
+	case *ast.WhileStatement:
+		return stmtsExitLoop(n.Body, false)
+	case *ast.ForStatement:
+		return stmtsExitLoop(n.Body, false)
+	case *ast.ForInStatement:
+		return stmtsExitLoop(n.Body, false)
+	case *ast.SwitchStatement:
+		for _, c := range n.Cases {
+			if c != nil && stmtsExitLoop(c.Body, false) {
+				return true
+			}
+		}
+		return stmtsExitLoop(n.Default, false)
+	}
+	return false
+}
+
+// checkSwitchCases flags switch/match cases that can never be selected:
+// repeated constant values, and constant values that differ from a constant
+// subject.
+func (r *Runner) checkSwitchCases(subject ast.Expression, cases [][]ast.Expression) {
+	subj, subjConst := constValue(subject)
+	var seen []object.Object
+	for _, values := range cases {
+		for _, v := range values {
+			cv, ok := constValue(v)
+			if !ok {
+				continue
+			}
+			tok := firstTokenOfExpr(v)
+			if subjConst {
+				if eq, err := semantics.Compare("==", subj, cv); err == nil && !eq {
+					r.warn(tok, "WL0009", fmt.Sprintf("unreachable case: %s never equals the switch value", v.String()))
+					continue
+				}
+			}
+			dup := false
+			for _, prev := range seen {
+				if eq, err := semantics.Compare("==", prev, cv); err == nil && eq {
+					dup = true
+					break
+				}
+			}
+			if dup {
+				r.warn(tok, "WL0009", fmt.Sprintf("unreachable case: %s is already handled by an earlier case", v.String()))
+				continue
+			}
+			seen = append(seen, cv)
+		}
+	}
+}
+
+func firstTokenOfExpr(e ast.Expression) token.Token {
+	switch n := e.(type) {
+	case *ast.InfixExpression:
+		return firstTokenOfExpr(n.Left)
+	case *ast.IntegerLiteral:
+		return n.Token
+	case *ast.FloatLiteral:
+		return n.Token
+	case *ast.StringLiteral:
+		return n.Token
+	case *ast.BooleanLiteral:
+		return n.Token
+	case *ast.NilLiteral:
+		return n.Token
+	case *ast.PrefixExpression:
+		return n.Token
+	case *ast.Identifier:
+		return n.Token
+	default:
+		return token.Token{Line: 1, Col: 1, Literal: ""}
+	}
+}
@@ -0,0 +1,81 @@
+package lint
+
+import (
+	"welle/internal/ast"
+	"welle/internal/docs"
+	"welle/internal/token"
+)
+
+// lookupBuiltin finds a builtin's docs; tests replace it to deprecate one.
+var lookupBuiltin = docs.LookupBuiltin
+
+// checkDeprecatedCall flags a call to a function marked @deprecated, in
+// this file or in a module it imports, and to a builtin the registry marks
+// deprecated.
+func (r *Runner) checkDeprecatedCall(call *ast.CallExpression) {
+	switch fn := call.Function.(type) {
+	case *ast.Identifier:
+		if sm := r.sc.lookup(fn.Value); sm != nil {
+			if sm.deprecated {
+				r.warnDeprecated(fn.Token, fn.Value, sm.hint)
+			}
+			return
+		}
+		// Not declared yet: a top-level function defined further down, or
+		// a builtin.
+		if decl, ok := r.topFuncs[fn.Value]; ok {
+			if decl.Deprecated != nil {
+				r.warnDeprecated(fn.Token, fn.Value, decl.DeprecatedMsg)
+			}
+			return
+		}
+		if b, ok := lookupBuiltin(fn.Value); ok && b.Deprecated != "" {
+			r.warnDeprecated(fn.Token, fn.Value, b.Deprecated)
+		}
+	case *ast.MemberExpression:
+		mod, ok := fn.Object.(*ast.Identifier)
+		if !ok || fn.Property == nil {
+			return
+		}
+		sm := r.sc.lookup(mod.Value)
+		if sm == nil || sm.exports == nil {
+			return
+		}
+		if hint, ok := sm.exports[fn.Property.Value]; ok {
+			r.warnDeprecated(fn.Property.Token, mod.Value+"."+fn.Property.Value, hint)
+		}
+	}
+}
+
+func (r *Runner) warnDeprecated(tok token.Token, name, hint string) {
+	msg := name + " is deprecated"
+	if hint != "" {
+		msg += ": " + hint
+	}
+	r.warn(tok, "WL0015", msg)
+	r.diags[len(r.diags)-1].Deprecated = true
+}
+
+// moduleDeprecations returns the deprecated functions of the module spec
+// names, or nil when they are unknown.
+func (r *Runner) moduleDeprecations(spec string) map[string]string {
+	if r.opts.ModuleDeprecations == nil {
+		return nil
+	}
+	return r.opts.ModuleDeprecations(spec)
+}
+
+// topLevelFuncs maps the names of prog's top-level functions to their
+// declarations, so calls that come before a declaration can be checked.
+func topLevelFuncs(prog *ast.Program) map[string]*ast.FuncStatement {
+	out := map[string]*ast.FuncStatement{}
+	for _, st := range prog.Statements {
+		if exp, ok := st.(*ast.ExportStatement); ok {
+			st = exp.Stmt
+		}
+		if fn, ok := st.(*ast.FuncStatement); ok && fn.Name != nil {
+			out[fn.Name.Value] = fn
+		}
+	}
+	return out
+}
@@ -2,17 +2,42 @@ package lint
 
 import (
 	"welle/internal/ast"
+	"welle/internal/config"
 	"welle/internal/diag"
+	"welle/internal/flow"
 )
 
 type Options struct {
 	CheckShadowing bool
+
+	// Opt-in function size thresholds; zero disables the rule.
+	MaxComplexity int
+	MaxNesting    int
+	MaxStatements int
+
+	// ModuleDeprecations returns the functions marked @deprecated in the
+	// module an import spec of the linted file names, with their messages.
+	// When nil, calls into imported modules are not checked.
+	ModuleDeprecations func(spec string) map[string]string
 }
 
 func DefaultOptions() Options {
 	return Options{CheckShadowing: true}
 }
 
+// OptionsFromManifest returns the default options with any `[lint]`
+// thresholds from welle.toml applied. A nil manifest yields the defaults.
+func OptionsFromManifest(m *config.Manifest) Options {
+	opts := DefaultOptions()
+	if m == nil {
+		return opts
+	}
+	opts.MaxComplexity = m.Lint.MaxComplexity
+	opts.MaxNesting = m.Lint.MaxNesting
+	opts.MaxStatements = m.Lint.MaxStatements
+	return opts
+}
+
 type Linter struct {
 	opts Options
 }
@@ -39,5 +64,6 @@ func (l *Linter) Run(program *ast.Program) []diag.Diagnostic {
 	}
 	r := &Runner{sc: newScope(nil), opts: l.opts}
 	r.walkProgram(program)
+	r.diags = append(r.diags, flow.UseBeforeAssign(program)...)
 	return r.diags
 }
@@ -0,0 +1,271 @@
+package lint
+
+import (
+	"fmt"
+	"testing"
+
+	"welle/internal/diag"
+	"welle/internal/docs"
+	"welle/internal/lexer"
+	"welle/internal/parser"
+)
+
+func lintSource(t *testing.T, src string) []diag.Diagnostic {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	return Run(prog)
+}
+
+func diagsWithCode(ds []diag.Diagnostic, code string) []diag.Diagnostic {
+	var out []diag.Diagnostic
+	for _, d := range ds {
+		if d.Code == code {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func TestLogicalValueSelection(t *testing.T) {
+	src := `name = nil
+x = name or "guest"
+y = name and 1
+print(x, y)
+`
+	ds := diagsWithCode(lintSource(t, src), "WL0005")
+	if len(ds) != 2 {
+		t.Fatalf("expected 2 WL0005 diagnostics, got %d: %#v", len(ds), ds)
+	}
+	if ds[0].Range.Line != 2 || ds[0].Range.Col != 10 || ds[0].Range.Length != 2 {
+		t.Fatalf("unexpected range for 'or': %#v", ds[0].Range)
+	}
+	if ds[1].Range.Line != 3 || ds[1].Range.Col != 10 || ds[1].Range.Length != 3 {
+		t.Fatalf("unexpected range for 'and': %#v", ds[1].Range)
+	}
+}
+
+func TestLogicalValueSelectionBooleanOperands(t *testing.T) {
+	src := `a = true
+b = false
+if (a or b) { print(1) }
+c = a and not b
+print(c or false)
+`
+	if ds := diagsWithCode(lintSource(t, src), "WL0005"); len(ds) != 0 {
+		t.Fatalf("expected no WL0005 diagnostics, got %#v", ds)
+	}
+}
+
+func TestLogicalValueSelectionIdentifiers(t *testing.T) {
+	src := `func pick(a, fallback) {
+  return a or fallback
+}
+name = nil
+guest = "guest"
+x = name or guest
+ok = pick(1, 2) > 0
+done = ok and x == "guest"
+y = ok or done
+print(x, y)
+`
+	ds := diagsWithCode(lintSource(t, src), "WL0005")
+	if len(ds) != 2 {
+		t.Fatalf("expected 2 WL0005 diagnostics, got %d: %#v", len(ds), ds)
+	}
+	if ds[0].Range.Line != 2 || ds[0].Range.Col != 12 || ds[0].Range.Length != 2 {
+		t.Fatalf("unexpected range for the returned 'or': %#v", ds[0].Range)
+	}
+	if ds[1].Range.Line != 6 || ds[1].Range.Col != 10 {
+		t.Fatalf("unexpected range for the assigned 'or': %#v", ds[1].Range)
+	}
+}
+
+func TestConstantConditions(t *testing.T) {
+	src := `if (1 == 1) { print("a") }
+while (false) { print("b") }
+while (true) { print("c") }
+x = 1
+while (true) {
+  switch (x) {
+    case 1 { break }
+  }
+  if (x > 2) { break }
+  x += 1
+}
+`
+	ds := lintSource(t, src)
+	cond := diagsWithCode(ds, "WL0006")
+	if len(cond) != 2 || cond[0].Range.Line != 1 || cond[1].Range.Line != 2 {
+		t.Fatalf("unexpected WL0006 diagnostics: %#v", cond)
+	}
+	loops := diagsWithCode(ds, "WL0007")
+	if len(loops) != 1 || loops[0].Range.Line != 3 {
+		t.Fatalf("unexpected WL0007 diagnostics: %#v", loops)
+	}
+}
+
+func TestConstantComparisonTypeMismatch(t *testing.T) {
+	src := `a = 1 == "1"
+b = "a" < "b"
+c = 1 == 1.0
+d = nil == 1
+print(a, b, c, d)
+`
+	ds := diagsWithCode(lintSource(t, src), "WL0008")
+	if len(ds) != 2 || ds[0].Range.Line != 1 || ds[1].Range.Line != 2 {
+		t.Fatalf("unexpected WL0008 diagnostics: %#v", ds)
+	}
+}
+
+func TestUnreachableCases(t *testing.T) {
+	src := `x = 2
+switch (x) {
+  case 1, 2 { print("a") }
+  case 2 { print("b") }
+}
+y = match (3) {
+  case 3 { "three" }
+  case 4 { "four" }
+  default { "other" }
+}
+print(y)
+`
+	ds := diagsWithCode(lintSource(t, src), "WL0009")
+	if len(ds) != 2 || ds[0].Range.Line != 4 || ds[1].Range.Line != 8 {
+		t.Fatalf("unexpected WL0009 diagnostics: %#v", ds)
+	}
+}
+
+func TestFunctionMetricThresholds(t *testing.T) {
+	src := `func busy(a, b) {
+  if (a) {
+    while (b) {
+      if (a and b) { return 1 }
+      b = false
+    }
+  } else if (b) {
+    return 2
+  }
+  return 3
+}
+`
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+
+	if ds := Run(prog); len(diagsWithCode(ds, "WL0010")) != 0 {
+		t.Fatalf("metric rules should be off by default, got %#v", ds)
+	}
+
+	opts := DefaultOptions()
+	opts.MaxComplexity = 4
+	opts.MaxNesting = 2
+	opts.MaxStatements = 5
+	ds := RunWithOptions(prog, opts)
+	cases := []struct {
+		code string
+		msg  string
+	}{
+		{"WL0010", "function 'busy' has cyclomatic complexity 6 (max 4)"},
+		{"WL0011", "function 'busy' has nesting depth 3 (max 2)"},
+		{"WL0012", "function 'busy' has 8 statements (max 5)"},
+	}
+	for _, tc := range cases {
+		got := diagsWithCode(ds, tc.code)
+		if len(got) != 1 || got[0].Message != tc.msg {
+			t.Fatalf("%s: expected %q, got %#v", tc.code, tc.msg, got)
+		}
+		if got[0].Range.Line != 1 || got[0].Range.Col != 6 {
+			t.Fatalf("%s: unexpected range %#v", tc.code, got[0].Range)
+		}
+	}
+}
+
+func TestUseBeforeAssignIsReported(t *testing.T) {
+	ds := lintSource(t, "func f(c) {\n  if (c) { x = 1 }\n  return x\n}\nf(true)\n")
+	got := diagsWithCode(ds, "WL0013")
+	if len(got) != 1 || got[0].Range.Line != 3 {
+		t.Fatalf("expected one WL0013 on line 3, got %v", got)
+	}
+}
+
+func TestRedeclareInSameScope(t *testing.T) {
+	src := `func f(a) {
+  b := a
+  b := 2
+  a := 3
+  if (a) {
+    b := 4
+    print(b)
+  }
+  return b
+}
+print(f(1))
+`
+	ds := diagsWithCode(lintSource(t, src), "WL0014")
+	if len(ds) != 2 {
+		t.Fatalf("expected 2 WL0014 diagnostics, got %d: %#v", len(ds), ds)
+	}
+	if ds[0].Severity != diag.SeverityError || ds[0].Range.Line != 3 {
+		t.Fatalf("unexpected first diagnostic: %#v", ds[0])
+	}
+	want := []diag.Related{{Range: diag.Range{Line: 2, Col: 3, Length: 1}, Message: "previous declaration here"}}
+	if len(ds[0].Related) != 1 || ds[0].Related[0] != want[0] {
+		t.Fatalf("unexpected related locations: %#v", ds[0].Related)
+	}
+	if ds[1].Range.Line != 4 || ds[1].Related[0].Range.Line != 1 {
+		t.Fatalf("expected the parameter as the previous declaration: %#v", ds[1])
+	}
+}
+
+func TestDeprecatedCalls(t *testing.T) {
+	src := `func caller() { return early() }
+@deprecated("use fresh()")
+func early() { return 1 }
+@deprecated
+func bare() { return 2 }
+import "./lib.wll" as lib
+from "./lib.wll" import old as legacy, fine
+func shadow(stale) { return stale([1]) }
+print(caller(), bare(), stale([1, 2]), reversed([1]), lib.old(), lib.fine(), legacy(), fine(), shadow(nil))
+`
+	// No shipped builtin is deprecated; stale stands in for one.
+	defer func(prev func(string) (docs.Builtin, bool)) { lookupBuiltin = prev }(lookupBuiltin)
+	lookupBuiltin = func(name string) (docs.Builtin, bool) {
+		if name == "stale" {
+			return docs.Builtin{Name: "stale", Deprecated: "use fresh()"}, true
+		}
+		return docs.LookupBuiltin(name)
+	}
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	opts := DefaultOptions()
+	opts.ModuleDeprecations = func(spec string) map[string]string {
+		if spec != "./lib.wll" {
+			t.Fatalf("unexpected spec %q", spec)
+		}
+		return map[string]string{"old": "use fine()"}
+	}
+	ds := diagsWithCode(RunWithOptions(prog, opts), "WL0015")
+	want := []string{
+		"1:24 early is deprecated: use fresh()",
+		"9:17 bare is deprecated",
+		"9:25 stale is deprecated: use fresh()",
+		"9:59 lib.old is deprecated: use fine()",
+		"9:78 legacy is deprecated: use fine()",
+	}
+	if len(ds) != len(want) {
+		t.Fatalf("expected %d WL0015 diagnostics, got %#v", len(want), ds)
+	}
+	for i, d := range ds {
+		if got := fmt.Sprintf("%d:%d %s", d.Range.Line, d.Range.Col, d.Message); got != want[i] || !d.Deprecated {
+			t.Fatalf("diagnostic %d: expected %q (deprecated), got %q (deprecated %t)", i, want[i], got, d.Deprecated)
+		}
+	}
+}
@@ -22,6 +22,15 @@ type sym struct {
 	tok  token.Token
 	used bool
 	kind symKind
+	// deprecated is set when the name is a function marked @deprecated,
+	// with the annotation's message in hint; exports holds the deprecated
+	// functions of the module an import binds.
+	deprecated bool
+	hint       string
+	exports    map[string]string
+	// boolean is set while the variable's last assignment looks boolean
+	// (see looksBoolean).
+	boolean bool
 }
 
 type scope struct {
@@ -50,21 +59,28 @@ type Runner struct {
 	diags []diag.Diagnostic
 	sc    *scope
 	opts  Options
+	// topFuncs holds the program's top-level functions by name.
+	topFuncs map[string]*ast.FuncStatement
 }
 
-func (r *Runner) warn(tok token.Token, code string, msg string) {
+func (r *Runner) warn(tok token.Token, code string, msg string, related ...diag.Related) {
+	r.report(diag.SeverityWarning, tok, code, msg, related...)
+}
+
+func (r *Runner) report(sev diag.Severity, tok token.Token, code string, msg string, related ...diag.Related) {
 	r.diags = append(r.diags, diag.Diagnostic{
 		Code:     code,
 		Message:  msg,
-		Severity: diag.SeverityWarning,
-		Range: diag.Range{
-			Line:   tok.Line,
-			Col:    tok.Col,
-			Length: tokLength(tok),
-		},
+		Severity: sev,
+		Range:    tokRange(tok),
+		Related:  related,
 	})
 }
 
+func tokRange(tok token.Token) diag.Range {
+	return diag.Range{Line: tok.Line, Col: tok.Col, Length: tokLength(tok)}
+}
+
 func tokLength(tok token.Token) int {
 	if tok.Literal == "" {
 		return 1
@@ -97,12 +113,26 @@ func (r *Runner) declare(name string, tok token.Token, k symKind) {
 	if name == "" {
 		return
 	}
-	if r.opts.CheckShadowing && r.sc.parent != nil && r.sc.parent.lookup(name) != nil && r.sc.lookupHere(name) == nil {
-		r.warn(tok, "WL0004", fmt.Sprintf("variable '%s' shadows outer variable", name))
+	if r.opts.CheckShadowing && r.sc.parent != nil && r.sc.lookupHere(name) == nil {
+		if outer := r.sc.parent.lookup(name); outer != nil {
+			r.warn(tok, "WL0004", fmt.Sprintf("variable '%s' shadows outer variable", name),
+				diag.Related{Range: tokRange(outer.tok), Message: fmt.Sprintf("outer '%s' declared here", name)})
+		}
 	}
 	r.sc.syms[name] = &sym{name: name, tok: tok, kind: k}
 }
 
+// redeclare reports `name := ...` when name is already declared in the
+// current scope, which fails at runtime.
+func (r *Runner) redeclare(name string, tok token.Token) {
+	prev := r.sc.lookupHere(name)
+	if prev == nil || name == "_" {
+		return
+	}
+	r.report(diag.SeverityError, tok, "WL0014", fmt.Sprintf("cannot redeclare '%s' in this scope", name),
+		diag.Related{Range: tokRange(prev.tok), Message: "previous declaration here"})
+}
+
 func (r *Runner) use(name string) {
 	if name == "" {
 		return
@@ -113,6 +143,7 @@ func (r *Runner) use(name string) {
 }
 
 func (r *Runner) walkProgram(p *ast.Program) {
+	r.topFuncs = topLevelFuncs(p)
 	for _, st := range p.Statements {
 		r.walkStmt(st)
 	}
@@ -162,10 +193,16 @@ func firstTokenOfStmt(st ast.Statement) token.Token {
 		return n.Token
 	case *ast.ReturnStatement:
 		return n.Token
+	case *ast.YieldStatement:
+		return n.Token
 	case *ast.DeferStatement:
 		return n.Token
+	case *ast.DelStatement:
+		return n.Token
 	case *ast.ThrowStatement:
 		return n.Token
+	case *ast.AssertStatement:
+		return n.Token
 	case *ast.BreakStatement:
 		return n.Token
 	case *ast.ContinueStatement:
@@ -194,6 +231,8 @@ func firstTokenOfStmt(st ast.Statement) token.Token {
 		return n.Token
 	case *ast.FuncStatement:
 		return n.Token
+	case *ast.ClassStatement:
+		return n.Token
 	default:
 		return token.Token{Line: 1, Col: 1, Literal: ""}
 	}
@@ -208,8 +247,16 @@ func (r *Runner) walkStmt(st ast.Statement) {
 		r.walkBlock(n)
 
 	case *ast.FuncStatement:
+		for _, d := range n.Defaults {
+			r.walkExpr(d)
+		}
 		if n.Name != nil {
 			r.declare(n.Name.Value, n.Name.Token, kindFunc)
+			if n.Deprecated != nil {
+				sm := r.sc.lookupHere(n.Name.Value)
+				sm.deprecated, sm.hint = true, n.DeprecatedMsg
+			}
+			r.checkFunctionMetrics(n.Name.Value, n.Name.Token, n.Body)
 		}
 		r.push()
 		for _, p := range n.Parameters {
@@ -220,10 +267,46 @@ func (r *Runner) walkStmt(st ast.Statement) {
 		r.walkBlockWithScope(n.Body)
 		r.pop()
 
+	case *ast.ClassStatement:
+		for _, d := range n.Defaults {
+			r.walkExpr(d)
+		}
+		if n.Name != nil {
+			r.declare(n.Name.Value, n.Name.Token, kindFunc)
+		}
+		for _, m := range n.Methods {
+			for _, d := range m.Defaults {
+				r.walkExpr(d)
+			}
+			r.checkFunctionMetrics(n.Name.Value+"."+m.Name.Value, m.Name.Token, m.Body)
+			// self is implicit, so a method that never reads it is fine.
+			r.push()
+			for _, p := range m.Parameters {
+				if p != nil {
+					r.declare(p.Value, p.Token, kindParam)
+				}
+			}
+			r.walkBlockWithScope(m.Body)
+			r.pop()
+		}
+
 	case *ast.AssignStatement:
+		plain := n.Op == token.ASSIGN || n.Op == token.WALRUS
+		boolean := plain && r.looksBoolean(n.Value)
+		if plain {
+			r.checkSelectedValue(n.Value)
+		}
+		if n.Name != nil && n.Op == token.WALRUS {
+			r.redeclare(n.Name.Value, n.Name.Token)
+		}
 		if n.Name != nil && r.sc.lookupHere(n.Name.Value) == nil {
 			r.declare(n.Name.Value, n.Name.Token, kindVar)
 		}
+		if n.Name != nil {
+			if sm := r.sc.lookup(n.Name.Value); sm != nil {
+				sm.boolean = boolean
+			}
+		}
 		r.walkExpr(n.Value)
 
 	case *ast.IndexAssignStatement:
@@ -247,15 +330,26 @@ func (r *Runner) walkStmt(st ast.Statement) {
 
 	case *ast.ReturnStatement:
 		for _, rv := range n.ReturnValues {
+			r.checkSelectedValue(rv)
 			r.walkExpr(rv)
 		}
 
 	case *ast.DeferStatement:
 		r.walkExpr(n.Call)
 
+	case *ast.DelStatement:
+		r.walkExpr(n.Target)
+
+	case *ast.YieldStatement:
+		r.walkExpr(n.Value)
+
 	case *ast.ThrowStatement:
 		r.walkExpr(n.Value)
 
+	case *ast.AssertStatement:
+		r.walkExpr(n.Condition)
+		r.walkExpr(n.Message)
+
 	case *ast.ExpressionStatement:
 		r.walkExpr(n.Expression)
 
@@ -263,6 +357,7 @@ func (r *Runner) walkStmt(st ast.Statement) {
 		return
 
 	case *ast.IfStatement:
+		r.checkConstCondition(n.Token, n.Condition)
 		r.walkExpr(n.Condition)
 		if n.Consequence != nil {
 			r.walkStmt(n.Consequence)
@@ -272,6 +367,7 @@ func (r *Runner) walkStmt(st ast.Statement) {
 		}
 
 	case *ast.WhileStatement:
+		r.checkWhileCondition(n)
 		r.walkExpr(n.Condition)
 		r.walkBlock(n.Body)
 
@@ -307,6 +403,13 @@ func (r *Runner) walkStmt(st ast.Statement) {
 
 	case *ast.SwitchStatement:
 		r.walkExpr(n.Value)
+		caseValues := make([][]ast.Expression, 0, len(n.Cases))
+		for _, c := range n.Cases {
+			if c != nil {
+				caseValues = append(caseValues, c.Values)
+			}
+		}
+		r.checkSwitchCases(n.Value, caseValues)
 		for _, c := range n.Cases {
 			if c == nil {
 				continue
@@ -323,6 +426,7 @@ func (r *Runner) walkStmt(st ast.Statement) {
 	case *ast.TryStatement:
 		r.walkBlock(n.TryBlock)
 		if n.CatchBlock != nil {
+			r.walkExpr(n.CatchFilter)
 			r.push()
 			if n.CatchName != nil {
 				r.declare(n.CatchName.Value, n.CatchName.Token, kindVar)
@@ -337,16 +441,28 @@ func (r *Runner) walkStmt(st ast.Statement) {
 	case *ast.ImportStatement:
 		if n.Alias != nil {
 			r.declare(n.Alias.Value, n.Alias.Token, kindImport)
+			if n.Path != nil {
+				r.sc.lookupHere(n.Alias.Value).exports = r.moduleDeprecations(n.Path.Value)
+			}
 		}
 
 	case *ast.FromImportStatement:
+		var deps map[string]string
+		if n.Path != nil {
+			deps = r.moduleDeprecations(n.Path.Value)
+		}
 		for _, it := range n.Items {
-			if it.Alias != nil {
-				r.declare(it.Alias.Value, it.Alias.Token, kindImport)
+			if it.Name == nil {
 				continue
 			}
-			if it.Name != nil {
-				r.declare(it.Name.Value, it.Name.Token, kindImport)
+			bound := it.Name
+			if it.Alias != nil {
+				bound = it.Alias
+			}
+			r.declare(bound.Value, bound.Token, kindImport)
+			if hint, ok := deps[it.Name.Value]; ok {
+				sm := r.sc.lookupHere(bound.Value)
+				sm.deprecated, sm.hint = true, hint
 			}
 		}
 
@@ -368,15 +484,19 @@ func (r *Runner) walkExpr(e ast.Expression) {
 		r.use(n.Value)
 
 	case *ast.InfixExpression:
+		r.checkLogicalValue(n)
+		r.checkConstComparison(n)
 		r.walkExpr(n.Left)
 		r.walkExpr(n.Right)
 
 	case *ast.ConditionalExpression:
+		r.checkConstCondition(n.Token, n.Cond)
 		r.walkExpr(n.Cond)
 		r.walkExpr(n.Then)
 		r.walkExpr(n.Else)
 
 	case *ast.CondExpr:
+		r.checkConstCondition(n.Token, n.Cond)
 		r.walkExpr(n.Cond)
 		r.walkExpr(n.Then)
 		r.walkExpr(n.Else)
@@ -385,6 +505,7 @@ func (r *Runner) walkExpr(e ast.Expression) {
 		r.walkExpr(n.Right)
 
 	case *ast.CallExpression:
+		r.checkDeprecatedCall(n)
 		r.walkExpr(n.Function)
 		for _, a := range n.Arguments {
 			r.walkExpr(a)
@@ -410,6 +531,10 @@ func (r *Runner) walkExpr(e ast.Expression) {
 		for _, el := range n.Elements {
 			r.walkExpr(el)
 		}
+	case *ast.SetLiteral:
+		for _, el := range n.Elements {
+			r.walkExpr(el)
+		}
 
 	case *ast.ListComprehension:
 		r.walkExpr(n.Seq)
@@ -421,8 +546,17 @@ func (r *Runner) walkExpr(e ast.Expression) {
 		r.walkExpr(n.Elem)
 		r.pop()
 
+	case *ast.NamedTupleLiteral:
+		for _, f := range n.Fields {
+			r.walkExpr(f.Value)
+		}
+
 	case *ast.DictLiteral:
 		for _, p := range n.Pairs {
+			if p.Spread != nil {
+				r.walkExpr(p.Spread)
+				continue
+			}
 			if p.Shorthand != nil {
 				r.walkExpr(p.Shorthand)
 				continue
@@ -433,18 +567,44 @@ func (r *Runner) walkExpr(e ast.Expression) {
 
 	case *ast.MatchExpression:
 		r.walkExpr(n.Value)
+		caseValues := make([][]ast.Expression, 0, len(n.Cases))
+		for _, c := range n.Cases {
+			// A guarded case can fall through to a later one with the
+			// same value.
+			if c != nil && c.Guard == nil {
+				caseValues = append(caseValues, c.Values)
+			}
+		}
+		r.checkSwitchCases(n.Value, caseValues)
 		for _, c := range n.Cases {
 			if c == nil {
 				continue
 			}
 			for _, v := range c.Values {
-				r.walkExpr(v)
+				pat, ok := v.(*ast.MatchPattern)
+				if !ok {
+					r.walkExpr(v)
+					continue
+				}
+				for _, id := range pat.Names() {
+					if r.sc.lookupHere(id.Value) == nil {
+						r.declare(id.Value, id.Token, kindVar)
+					}
+				}
+				for _, pv := range pat.Values() {
+					r.walkExpr(pv)
+				}
 			}
+			r.walkExpr(c.Guard)
 			r.walkExpr(c.Result)
 		}
 		r.walkExpr(n.Default)
 
 	case *ast.FunctionLiteral:
+		for _, d := range n.Defaults {
+			r.walkExpr(d)
+		}
+		r.checkFunctionMetrics("", n.Token, n.Body)
 		r.push()
 		for _, p := range n.Parameters {
 			if p != nil {
@@ -466,6 +626,9 @@ func (r *Runner) walkExpr(e ast.Expression) {
 	case *ast.AssignExpression:
 		switch left := n.Left.(type) {
 		case *ast.Identifier:
+			if n.Op == token.WALRUS {
+				r.redeclare(left.Value, left.Token)
+			}
 			if r.sc.lookupHere(left.Value) == nil {
 				r.declare(left.Value, left.Token, kindVar)
 			}
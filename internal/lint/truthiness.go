@@ -0,0 +1,86 @@
+package lint
+
+import (
+	"fmt"
+
+	"welle/internal/ast"
+)
+
+// checkLogicalValue flags `a or <value>` / `a and <value>` where the right
+// operand is a non-boolean literal. `and`/`or` always produce booleans, so
+// these read like Python-style value selection but never return the operand.
+func (r *Runner) checkLogicalValue(n *ast.InfixExpression) {
+	if !isLogical(n) || !isValueLiteral(n.Right) {
+		return
+	}
+	r.warnLogicalValue(n)
+}
+
+// checkSelectedValue flags `x = a or b` and `return a or b` when an operand
+// does not look boolean, as a parameter or a variable last assigned a
+// non-boolean does. Calls are assumed to return booleans. Chains with a
+// literal operand are left to checkLogicalValue.
+func (r *Runner) checkSelectedValue(e ast.Expression) {
+	n, ok := e.(*ast.InfixExpression)
+	if !ok || !isLogical(n) || hasValueLiteral(n) {
+		return
+	}
+	if r.looksBoolean(n.Left) && r.looksBoolean(n.Right) {
+		return
+	}
+	r.warnLogicalValue(n)
+}
+
+func (r *Runner) warnLogicalValue(n *ast.InfixExpression) {
+	hint := "use `??` or a conditional expression to select a value"
+	if n.Operator == "and" {
+		hint = "use a conditional expression to select a value"
+	}
+	r.warn(n.Token, "WL0005", fmt.Sprintf("'%s' returns a boolean, not an operand; %s", n.Operator, hint))
+}
+
+func isLogical(e ast.Expression) bool {
+	n, ok := e.(*ast.InfixExpression)
+	return ok && (n.Operator == "and" || n.Operator == "or")
+}
+
+func hasValueLiteral(e ast.Expression) bool {
+	if !isLogical(e) {
+		return false
+	}
+	n := e.(*ast.InfixExpression)
+	return isValueLiteral(n.Right) || hasValueLiteral(n.Left) || hasValueLiteral(n.Right)
+}
+
+// looksBoolean reports whether e reads as a boolean: a boolean literal, a
+// negation, a comparison, a call, or a variable last assigned one of those.
+func (r *Runner) looksBoolean(e ast.Expression) bool {
+	switch n := e.(type) {
+	case *ast.BooleanLiteral, *ast.CallExpression:
+		return true
+	case *ast.PrefixExpression:
+		return n.Operator == "not" || n.Operator == "!"
+	case *ast.InfixExpression:
+		switch n.Operator {
+		case "==", "!=", "<", "<=", ">", ">=", "in", "is":
+			return true
+		case "and", "or":
+			return r.looksBoolean(n.Left) && r.looksBoolean(n.Right)
+		}
+	case *ast.Identifier:
+		if sm := r.sc.lookup(n.Value); sm != nil {
+			return sm.boolean
+		}
+	}
+	return false
+}
+
+func isValueLiteral(e ast.Expression) bool {
+	switch e.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.TemplateLiteral,
+		*ast.NilLiteral, *ast.ListLiteral, *ast.SetLiteral, *ast.DictLiteral, *ast.TupleLiteral,
+		*ast.NamedTupleLiteral:
+		return true
+	}
+	return false
+}
@@ -5,7 +5,6 @@ import (
 
 	"welle/internal/ast"
 	"welle/internal/lexer"
-	"welle/internal/parser"
 	"welle/internal/token"
 )
 
@@ -20,6 +19,7 @@ const (
 	SymBuiltin
 	SymKeyword
 	SymModuleMember
+	SymField
 )
 
 type Binding struct {
@@ -56,6 +56,9 @@ type Analysis struct {
 	Root    *Scope
 	Refs    []*Reference
 	Defs    []*Binding
+	// Free holds the identifiers that resolve to nothing in the file: not a
+	// binding and not a builtin. A project prelude may define them.
+	Free []*ast.Identifier
 }
 
 type blockRange struct {
@@ -68,14 +71,16 @@ type posKey struct {
 	Col  int
 }
 
+// Analyze resolves the names in text. Results are cached per text (see
+// Parse), so callers must treat the returned Analysis as read-only.
 func Analyze(text string) (*Analysis, error) {
-	lx := lexer.New(text)
-	p := parser.New(lx)
-	prog := p.ParseProgram()
+	return Parse(text).analysis(), nil
+}
 
+func analyze(text string, prog *ast.Program) *Analysis {
 	an := &Analysis{Program: prog, Text: text}
 	if prog == nil {
-		return an, nil
+		return an
 	}
 
 	blockRanges := buildBlockRanges(text, prog)
@@ -126,6 +131,15 @@ func Analyze(text string) (*Analysis, error) {
 		an.Refs = append(an.Refs, &Reference{Ident: id, Kind: SymModuleMember, ModuleAlias: alias, ModulePath: modulePath, Member: member, Name: member})
 	}
 
+	// Names used before their declaration (typically a function body
+	// calling a function defined further down) are resolved once the whole
+	// file has been walked.
+	type pendingRef struct {
+		sc *Scope
+		id *ast.Identifier
+	}
+	var pending []pendingRef
+
 	var walkStmt func(sc *Scope, st ast.Statement)
 	var walkExpr func(sc *Scope, e ast.Expression)
 
@@ -146,6 +160,9 @@ func Analyze(text string) (*Analysis, error) {
 					b.Params = paramsFromIdents(n.Parameters)
 				}
 			}
+			for _, d := range n.Defaults {
+				walkExpr(sc, d)
+			}
 			if n.Body != nil {
 				r := blockRanges[n.Body]
 				child := &Scope{Parent: sc, Start: r.Start, End: r.End, Bindings: map[string]*Binding{}}
@@ -162,6 +179,38 @@ func Analyze(text string) (*Analysis, error) {
 				}
 			}
 
+		case *ast.ClassStatement:
+			if n.Name != nil {
+				b := declare(sc, identText(n.Name), SymFunc, n.Name)
+				if b != nil {
+					b.Params = paramsFromIdents(classParams(n))
+				}
+			}
+			for _, d := range n.Defaults {
+				walkExpr(sc, d)
+			}
+			for _, m := range n.Methods {
+				for _, d := range m.Defaults {
+					walkExpr(sc, d)
+				}
+				if m.Body == nil {
+					continue
+				}
+				r := blockRanges[m.Body]
+				child := &Scope{Parent: sc, Start: r.Start, End: r.End, Bindings: map[string]*Binding{}}
+				sc.Children = append(sc.Children, child)
+				for _, p := range m.Parameters {
+					if p == nil {
+						continue
+					}
+					declare(child, identText(p), SymParam, p)
+					addRef(p, child.Bindings[identText(p)])
+				}
+				for _, st := range m.Body.Statements {
+					walkStmt(child, st)
+				}
+			}
+
 		case *ast.AssignStatement:
 			if n.Name != nil {
 				name := identText(n.Name)
@@ -218,9 +267,19 @@ func Analyze(text string) (*Analysis, error) {
 		case *ast.DeferStatement:
 			walkExpr(sc, n.Call)
 
+		case *ast.DelStatement:
+			walkExpr(sc, n.Target)
+
+		case *ast.YieldStatement:
+			walkExpr(sc, n.Value)
+
 		case *ast.ThrowStatement:
 			walkExpr(sc, n.Value)
 
+		case *ast.AssertStatement:
+			walkExpr(sc, n.Condition)
+			walkExpr(sc, n.Message)
+
 		case *ast.ExpressionStatement:
 			walkExpr(sc, n.Expression)
 
@@ -346,6 +405,9 @@ func Analyze(text string) (*Analysis, error) {
 			if n.TryBlock != nil {
 				walkStmt(sc, n.TryBlock)
 			}
+			if n.CatchFilter != nil {
+				walkExpr(sc, n.CatchFilter)
+			}
 			if n.CatchBlock != nil {
 				r := blockRanges[n.CatchBlock]
 				child := &Scope{Parent: sc, Start: r.Start, End: r.End, Bindings: map[string]*Binding{}}
@@ -378,6 +440,7 @@ func Analyze(text string) (*Analysis, error) {
 				addBuiltinRef(n, name)
 				return
 			}
+			pending = append(pending, pendingRef{sc: sc, id: n})
 
 		case *ast.CallExpression:
 			walkExpr(sc, n.Function)
@@ -437,6 +500,10 @@ func Analyze(text string) (*Analysis, error) {
 			for _, el := range n.Elements {
 				walkExpr(sc, el)
 			}
+		case *ast.SetLiteral:
+			for _, el := range n.Elements {
+				walkExpr(sc, el)
+			}
 
 		case *ast.ListComprehension:
 			walkExpr(sc, n.Seq)
@@ -452,8 +519,17 @@ func Analyze(text string) (*Analysis, error) {
 			}
 			walkExpr(comp, n.Elem)
 
+		case *ast.NamedTupleLiteral:
+			for _, f := range n.Fields {
+				walkExpr(sc, f.Value)
+			}
+
 		case *ast.DictLiteral:
 			for _, p := range n.Pairs {
+				if p.Spread != nil {
+					walkExpr(sc, p.Spread)
+					continue
+				}
 				if p.Shorthand != nil {
 					walkExpr(sc, p.Shorthand)
 					continue
@@ -466,7 +542,27 @@ func Analyze(text string) (*Analysis, error) {
 			walkExpr(sc, n.Value)
 			for _, c := range n.Cases {
 				for _, val := range c.Values {
-					walkExpr(sc, val)
+					pat, ok := val.(*ast.MatchPattern)
+					if !ok {
+						walkExpr(sc, val)
+						continue
+					}
+					for _, id := range pat.Names() {
+						name := identText(id)
+						b := sc.Bindings[name]
+						if b == nil {
+							b = declare(sc, name, SymVar, id)
+						}
+						if b != nil {
+							addRef(id, b)
+						}
+					}
+					for _, pv := range pat.Values() {
+						walkExpr(sc, pv)
+					}
+				}
+				if c.Guard != nil {
+					walkExpr(sc, c.Guard)
 				}
 				walkExpr(sc, c.Result)
 			}
@@ -475,6 +571,9 @@ func Analyze(text string) (*Analysis, error) {
 			}
 
 		case *ast.FunctionLiteral:
+			for _, d := range n.Defaults {
+				walkExpr(sc, d)
+			}
 			if n.Body != nil {
 				r := blockRanges[n.Body]
 				child := &Scope{Parent: sc, Start: r.Start, End: r.End, Bindings: map[string]*Binding{}}
@@ -534,8 +633,15 @@ func Analyze(text string) (*Analysis, error) {
 	for _, st := range prog.Statements {
 		walkStmt(root, st)
 	}
+	for _, p := range pending {
+		if b := resolve(p.sc, identText(p.id)); b != nil {
+			addRef(p.id, b)
+		} else {
+			an.Free = append(an.Free, p.id)
+		}
+	}
 
-	return an, nil
+	return an
 }
 
 func buildBlockRanges(text string, prog *ast.Program) map[*ast.BlockStatement]blockRange {
@@ -587,13 +693,30 @@ func collectBlocks(node ast.Node, fn func(*ast.BlockStatement)) {
 			collectBlocks(st, fn)
 		}
 	case *ast.BlockStatement:
+		// A try with no catch or no finally leaves a typed nil here.
+		if n == nil {
+			return
+		}
 		fn(n)
 		for _, st := range n.Statements {
 			collectBlocks(st, fn)
 		}
 	case *ast.FuncStatement:
+		for _, d := range n.Defaults {
+			collectBlocks(d, fn)
+		}
 		collectBlocks(n.Body, fn)
+	case *ast.ClassStatement:
+		for _, d := range n.Defaults {
+			collectBlocks(d, fn)
+		}
+		for _, m := range n.Methods {
+			collectBlocks(m, fn)
+		}
 	case *ast.FunctionLiteral:
+		for _, d := range n.Defaults {
+			collectBlocks(d, fn)
+		}
 		collectBlocks(n.Body, fn)
 	case *ast.IfStatement:
 		collectBlocks(n.Consequence, fn)
@@ -608,6 +731,7 @@ func collectBlocks(node ast.Node, fn func(*ast.BlockStatement)) {
 		collectBlocks(n.Body, fn)
 	case *ast.TryStatement:
 		collectBlocks(n.TryBlock, fn)
+		collectBlocks(n.CatchFilter, fn)
 		collectBlocks(n.CatchBlock, fn)
 		collectBlocks(n.FinallyBlock, fn)
 	case *ast.SwitchStatement:
@@ -631,6 +755,7 @@ func collectBlocks(node ast.Node, fn func(*ast.BlockStatement)) {
 			for _, v := range c.Values {
 				collectBlocks(v, fn)
 			}
+			collectBlocks(c.Guard, fn)
 			collectBlocks(c.Result, fn)
 		}
 		collectBlocks(n.Default, fn)
@@ -638,12 +763,26 @@ func collectBlocks(node ast.Node, fn func(*ast.BlockStatement)) {
 		for _, el := range n.Elements {
 			collectBlocks(el, fn)
 		}
+	case *ast.SetLiteral:
+		for _, el := range n.Elements {
+			collectBlocks(el, fn)
+		}
 	case *ast.ListComprehension:
 		collectBlocks(n.Seq, fn)
 		collectBlocks(n.Filter, fn)
 		collectBlocks(n.Elem, fn)
+	case *ast.NamedTupleLiteral:
+		for _, f := range n.Fields {
+			collectBlocks(f.Value, fn)
+		}
+	case *ast.MatchPattern:
+		collectBlocks(n.Value, fn)
 	case *ast.DictLiteral:
 		for _, p := range n.Pairs {
+			if p.Spread != nil {
+				collectBlocks(p.Spread, fn)
+				continue
+			}
 			if p.Shorthand != nil {
 				collectBlocks(p.Shorthand, fn)
 				continue
@@ -695,9 +834,25 @@ func collectBlocks(node ast.Node, fn func(*ast.BlockStatement)) {
 		}
 	case *ast.DeferStatement:
 		collectBlocks(n.Call, fn)
+	case *ast.DelStatement:
+		collectBlocks(n.Target, fn)
+	case *ast.YieldStatement:
+		collectBlocks(n.Value, fn)
 	case *ast.ThrowStatement:
 		collectBlocks(n.Value, fn)
+	case *ast.AssertStatement:
+		collectBlocks(n.Condition, fn)
+		collectBlocks(n.Message, fn)
+	}
+}
+
+// classParams returns what calling class n takes: the parameters of its
+// init method, or else its fields.
+func classParams(n *ast.ClassStatement) []*ast.Identifier {
+	if init := n.Method(ast.InitName); init != nil {
+		return init.Parameters
 	}
+	return n.Fields
 }
 
 func paramsFromIdents(ids []*ast.Identifier) []string {
@@ -763,6 +918,21 @@ func (a *Analysis) FindOccurrence(pos Pos) (*Reference, *Binding) {
 	return nil, nil
 }
 
+// FreeAt returns the free identifier at pos, if any.
+func (a *Analysis) FreeAt(pos Pos) *ast.Identifier {
+	if a == nil {
+		return nil
+	}
+	for _, id := range a.Free {
+		start := Pos{Line: id.Token.Line, Col: id.Token.Col}
+		end := Pos{Line: id.Token.Line, Col: id.Token.Col + max(1, len(identText(id)))}
+		if posWithin(pos, start, end) {
+			return id
+		}
+	}
+	return nil
+}
+
 func (a *Analysis) ResolveAt(pos Pos, name string) (*Binding, error) {
 	sc := a.ScopeAt(pos)
 	for s := sc; s != nil; s = s.Parent {
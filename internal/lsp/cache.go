@@ -0,0 +1,99 @@
+package lsp
+
+import (
+	"sync"
+
+	"welle/internal/ast"
+	"welle/internal/diag"
+	"welle/internal/lexer"
+	"welle/internal/parser"
+)
+
+// Parsed is the parse of one document version. Diagnostics, semantic
+// tokens, symbols, hover and the other features all start from the same
+// text, so they share one Parsed (and the name resolution built on it)
+// instead of lexing and parsing again per request.
+type Parsed struct {
+	Text        string
+	Program     *ast.Program
+	Diagnostics []diag.Diagnostic
+	Errors      []string
+
+	anOnce sync.Once
+	an     *Analysis
+
+	semOnce sync.Once
+	sem     []SemTok
+}
+
+func newParsed(text string) *Parsed {
+	p := parser.New(lexer.New(text))
+	prog := p.ParseProgram()
+	return &Parsed{Text: text, Program: prog, Diagnostics: p.Diagnostics(), Errors: p.Errors()}
+}
+
+func (pd *Parsed) analysis() *Analysis {
+	pd.anOnce.Do(func() { pd.an = analyze(pd.Text, pd.Program) })
+	return pd.an
+}
+
+func (pd *Parsed) semanticTokens() []SemTok {
+	pd.semOnce.Do(func() { pd.sem = semanticTokens(pd.Text, pd.Program) })
+	return pd.sem
+}
+
+// parseCacheSize bounds how many texts are kept: enough for the documents
+// being edited and the versions in flight between a change and the
+// requests that follow it.
+const parseCacheSize = 16
+
+type textCache struct {
+	mu    sync.Mutex
+	byKey map[string]*Parsed
+	order []string // least recently used first
+}
+
+var parseCache = &textCache{byKey: map[string]*Parsed{}}
+
+// Parse returns the parse of text, reusing the previous result for the same
+// text. The AST and everything derived from it are shared between callers
+// and must not be modified.
+func Parse(text string) *Parsed {
+	if pd := parseCache.get(text); pd != nil {
+		return pd
+	}
+	return parseCache.add(newParsed(text))
+}
+
+func (c *textCache) get(text string) *Parsed {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pd, ok := c.byKey[text]
+	if !ok {
+		return nil
+	}
+	for i, k := range c.order {
+		if len(k) == len(text) && k == text {
+			c.order = append(append(c.order[:i:i], c.order[i+1:]...), k)
+			break
+		}
+	}
+	return pd
+}
+
+// add stores pd unless another caller parsed the same text first, and
+// returns the entry that is kept.
+func (c *textCache) add(pd *Parsed) *Parsed {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if prev, ok := c.byKey[pd.Text]; ok {
+		return prev
+	}
+	c.byKey[pd.Text] = pd
+	c.order = append(c.order, pd.Text)
+	if len(c.order) > parseCacheSize {
+		delete(c.byKey, c.order[0])
+		c.order = c.order[1:]
+	}
+	return pd
+}
@@ -0,0 +1,80 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestParseIsSharedPerText(t *testing.T) {
+	text := "x = 1\nprint(x)\n"
+	a, b := Parse(text), Parse(text)
+	if a != b {
+		t.Fatalf("expected the same parse for the same text")
+	}
+	an1, _ := Analyze(text)
+	an2, _ := Analyze(text)
+	if an1 != an2 || an1.Program != a.Program {
+		t.Fatalf("expected analysis to reuse the cached parse")
+	}
+	if Parse(text+"print(1)\n") == a {
+		t.Fatalf("expected a new parse for changed text")
+	}
+}
+
+func TestParseCacheIsBounded(t *testing.T) {
+	for i := 0; i < parseCacheSize*2; i++ {
+		Parse(fmt.Sprintf("x = %d\n", i))
+	}
+	parseCache.mu.Lock()
+	defer parseCache.mu.Unlock()
+	if len(parseCache.byKey) > parseCacheSize || len(parseCache.order) != len(parseCache.byKey) {
+		t.Fatalf("cache holds %d entries (%d ordered), limit %d", len(parseCache.byKey), len(parseCache.order), parseCacheSize)
+	}
+}
+
+// largeSource returns a file of roughly n functions, five lines each.
+func largeSource(n int) string {
+	var b strings.Builder
+	b.WriteString("import \"std:math\" as math\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "func f%d(a, b) {\n  total = a + b * %d\n  items = #{\"n\": total, \"i\": %d}\n  return math.max(items.n, f%d(a, b))\n}\n", i, i, i, i/2)
+	}
+	return b.String()
+}
+
+func BenchmarkParseLargeFile(b *testing.B) {
+	text := largeSource(1000)
+	b.SetBytes(int64(len(text)))
+	for i := 0; i < b.N; i++ {
+		newParsed(text)
+	}
+}
+
+func BenchmarkAnalyzeLargeFile(b *testing.B) {
+	text := largeSource(1000)
+	for i := 0; i < b.N; i++ {
+		newParsed(text).analysis()
+	}
+}
+
+func BenchmarkSemanticTokensLargeFile(b *testing.B) {
+	text := largeSource(1000)
+	for i := 0; i < b.N; i++ {
+		newParsed(text).semanticTokens()
+	}
+}
+
+// BenchmarkHoverLargeFileCached measures a request against a document
+// version that has already been parsed and analyzed.
+func BenchmarkHoverLargeFileCached(b *testing.B) {
+	text := largeSource(1000)
+	pos := protocol.Position{Line: 2, Character: 3}
+	Analyze(text)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = HoverAt(nil, "file:///large.wll", text, pos)
+	}
+}
@@ -109,3 +109,29 @@ func MakePrefixUnderscoreAction(uri string, text string, r protocol.Range) (prot
 		Edit:  &edit,
 	}, true
 }
+
+func MakeReplaceOperatorAction(uri string, text string, r protocol.Range, from string, to string) (protocol.CodeAction, bool) {
+	start := indexFromPos(text, r.Start)
+	end := indexFromPos(text, r.End)
+	if end <= start || text[start:end] != from {
+		return protocol.CodeAction{}, false
+	}
+
+	edit := protocol.WorkspaceEdit{
+		Changes: map[protocol.DocumentUri][]protocol.TextEdit{
+			protocol.DocumentUri(uri): {
+				{
+					Range:   r,
+					NewText: to,
+				},
+			},
+		},
+	}
+
+	kind := protocol.CodeActionKindQuickFix
+	return protocol.CodeAction{
+		Title: "Replace '" + from + "' with '" + to + "'",
+		Kind:  &kind,
+		Edit:  &edit,
+	}, true
+}
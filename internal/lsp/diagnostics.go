@@ -1,7 +1,12 @@
 package lsp
 
 import (
+	"errors"
+
+	"welle/internal/ast"
+	"welle/internal/compiler"
 	"welle/internal/diag"
+	"welle/internal/ice"
 
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -19,16 +24,22 @@ func toLspPosition(line1, col1 int) protocol.Position {
 	return protocol.Position{Line: line, Character: char}
 }
 
-func ToLspDiagnostics(ds []diag.Diagnostic) []protocol.Diagnostic {
+func toLspRange(r diag.Range) protocol.Range {
+	start := toLspPosition(r.Line, r.Col)
+	end := start
+	if r.Length > 0 {
+		end.Character = start.Character + uint32(r.Length)
+	} else {
+		end.Character = start.Character + 1
+	}
+	return protocol.Range{Start: start, End: end}
+}
+
+// ToLspDiagnostics converts ds for the document at uri; related locations
+// become relatedInformation pointing into the same document.
+func ToLspDiagnostics(uri string, ds []diag.Diagnostic) []protocol.Diagnostic {
 	out := make([]protocol.Diagnostic, 0, len(ds))
 	for _, d := range ds {
-		start := toLspPosition(d.Range.Line, d.Range.Col)
-		end := start
-		if d.Range.Length > 0 {
-			end.Character = start.Character + uint32(d.Range.Length)
-		} else {
-			end.Character = start.Character + 1
-		}
 
 		severity := protocol.DiagnosticSeverityError
 		switch d.Severity {
@@ -39,7 +50,7 @@ func ToLspDiagnostics(ds []diag.Diagnostic) []protocol.Diagnostic {
 		}
 
 		pd := protocol.Diagnostic{
-			Range:    protocol.Range{Start: start, End: end},
+			Range:    toLspRange(d.Range),
 			Severity: &severity,
 			Source:   ptrString("welle"),
 			Message:  d.Message,
@@ -48,9 +59,47 @@ func ToLspDiagnostics(ds []diag.Diagnostic) []protocol.Diagnostic {
 			code := protocol.IntegerOrString{Value: d.Code}
 			pd.Code = &code
 		}
+		if d.Deprecated {
+			pd.Tags = []protocol.DiagnosticTag{protocol.DiagnosticTagDeprecated}
+		}
+		for _, r := range d.Related {
+			pd.RelatedInformation = append(pd.RelatedInformation, protocol.DiagnosticRelatedInformation{
+				Location: protocol.Location{URI: protocol.DocumentUri(uri), Range: toLspRange(r.Range)},
+				Message:  r.Message,
+			})
+		}
 		out = append(out, pd)
 	}
 	return out
 }
 
 func ptrString(s string) *string { return &s }
+
+// AppendCompilerWarnings compiles prog and adds the compiler's warnings to
+// ds. Compile errors are ignored (the interpreter may still run the file),
+// except an internal error, and warnings at a position that already has a
+// diagnostic are dropped so linter and compiler findings about the same
+// name are not shown twice.
+func AppendCompilerWarnings(ds []diag.Diagnostic, prog *ast.Program) []diag.Diagnostic {
+	if prog == nil {
+		return ds
+	}
+	c := compiler.New()
+	var internal *ice.Error
+	if err := c.Compile(prog); errors.As(err, &internal) {
+		ds = append(ds, internal.Diagnostic(1, 1))
+	}
+	seen := make(map[diag.Range]bool, len(ds))
+	for _, d := range ds {
+		seen[diag.Range{Line: d.Range.Line, Col: d.Range.Col}] = true
+	}
+	for _, w := range c.Warnings() {
+		key := diag.Range{Line: w.Range.Line, Col: w.Range.Col}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ds = append(ds, w)
+	}
+	return ds
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"welle/internal/ast"
+	"welle/internal/docs"
 	"welle/internal/lexer"
 	"welle/internal/token"
 
@@ -27,7 +28,7 @@ func CompletionItems(ws *Workspace, uri string, text string, pos protocol.Positi
 	}
 	ctx := completionContext(text, posByte)
 	if ctx.Alias != "" {
-		return completionForModule(ws, uri, an, posByte, ctx.Alias, ctx.Prefix)
+		return completionForMember(ws, uri, an, posByte, ctx.Alias, ctx.Prefix)
 	}
 	if ctx.InString {
 		return completionForStdModules(ws, ctx.Prefix)
@@ -47,7 +48,19 @@ func CompletionItems(ws *Workspace, uri string, text string, pos protocol.Positi
 		}
 	}
 
-	for name := range builtinDocs {
+	if absPath := UriToPath(uri); absPath != "" {
+		absPath, _ = filepath.Abs(absPath)
+		if _, names, ok := ws.PreludeExports(absPath, text); ok {
+			for name := range names {
+				if !seen[name] {
+					seen[name] = true
+					items = append(items, completionCandidate{name: name, kind: SymImport})
+				}
+			}
+		}
+	}
+
+	for _, name := range docs.BuiltinNames() {
 		if !seen[name] {
 			seen[name] = true
 			items = append(items, completionCandidate{name: name, kind: SymBuiltin})
@@ -83,7 +96,11 @@ func HoverAt(ws *Workspace, uri string, text string, pos protocol.Position) (*pr
 	if member := MemberAt(text, pos); member.Ok {
 		method = methodInfo(member.Member)
 	}
-	if ref == nil && def == nil && method == nil {
+	preludeKey, fromPrelude := SymbolKey{}, false
+	if ref == nil && def == nil {
+		preludeKey, fromPrelude = preludeKeyAt(ws, uri, text, an, posByte)
+	}
+	if ref == nil && def == nil && method == nil && !fromPrelude {
 		return nil, nil
 	}
 
@@ -93,6 +110,13 @@ func HoverAt(ws *Workspace, uri string, text string, pos protocol.Position) (*pr
 	var name string
 
 	switch {
+	case fromPrelude:
+		name = preludeKey.Name
+		kindLabel = "prelude"
+		signature, doc = moduleSignatureAndDoc(ws, uri, preludeKey.ModulePath, preludeKey.Name)
+		if signature == "" {
+			signature = preludeKey.Name
+		}
 	case ref != nil && ref.Kind == SymModuleMember:
 		name = ref.Member
 		kindLabel = "module member"
@@ -106,7 +130,7 @@ func HoverAt(ws *Workspace, uri string, text string, pos protocol.Position) (*pr
 		kindLabel = "builtin"
 		if info != nil {
 			signature = info.Signature
-			doc = info.Doc
+			doc = info.FullDoc()
 		}
 	case ref != nil && ref.Binding != nil:
 		name = ref.Binding.Name
@@ -154,6 +178,43 @@ func HoverAt(ws *Workspace, uri string, text string, pos protocol.Position) (*pr
 	return &protocol.Hover{Contents: contents}, nil
 }
 
+// DefinitionAt returns the binding site of the identifier at pos when it
+// resolves to a binding in this file: a top-level definition, a local,
+// a parameter, or a for-in, catch or comprehension variable. Module members
+// are left to the caller, which knows the workspace index.
+func DefinitionAt(uri string, text string, pos protocol.Position) []protocol.Location {
+	an, _ := Analyze(text)
+	posByte, ok := positionToByte(text, pos)
+	if !ok {
+		return nil
+	}
+	ref, def := an.FindOccurrence(posByte)
+	if ref != nil {
+		def = ref.Binding
+	}
+	if def == nil || def.Decl == nil {
+		return nil
+	}
+	r := rangeFromPosLenUTF16(text, def.Decl.Token.Line, def.Decl.Token.Col, identText(def.Decl))
+	return []protocol.Location{{URI: protocol.DocumentUri(uri), Range: r}}
+}
+
+// PreludeDefinitionAt returns where the project prelude defines the free
+// identifier at pos, when the file gets a prelude that exports it.
+func PreludeDefinitionAt(ws *Workspace, uri string, text string, pos protocol.Position) []protocol.Location {
+	an, _ := Analyze(text)
+	posByte, ok := positionToByte(text, pos)
+	if !ok {
+		return nil
+	}
+	key, ok := preludeKeyAt(ws, uri, text, an, posByte)
+	if !ok {
+		return nil
+	}
+	_, names, _ := ws.PreludeExports(UriToPath(uri), text)
+	return []protocol.Location{names[key.Name]}
+}
+
 func RenameAt(ws *Workspace, uri string, text string, pos protocol.Position, newName string) (*protocol.WorkspaceEdit, error) {
 	if token.LookupIdent(newName) != token.IDENT {
 		return nil, fmt.Errorf("cannot rename to keyword")
@@ -170,6 +231,9 @@ func RenameAt(ws *Workspace, uri string, text string, pos protocol.Position, new
 	ref, def := an.FindOccurrence(posByte)
 
 	if ref == nil && def == nil {
+		if key, ok := preludeKeyAt(ws, uri, text, an, posByte); ok {
+			return renameExport(ws, key, newName)
+		}
 		return nil, nil
 	}
 	if ref != nil && ref.Kind == SymBuiltin {
@@ -180,32 +244,7 @@ func RenameAt(ws *Workspace, uri string, text string, pos protocol.Position, new
 		if err != nil {
 			return nil, err
 		}
-		ix, err := BuildWorkspaceIndex(ws)
-		if err != nil {
-			return nil, err
-		}
-		occ := ix.ByKey[key]
-		if len(occ) == 0 {
-			return nil, nil
-		}
-		changes := map[protocol.DocumentUri][]protocol.TextEdit{}
-		seen := map[string]bool{}
-		for _, o := range occ {
-			if o.Kind == OccurrenceAliasUse {
-				continue
-			}
-			keyStr := fmt.Sprintf("%s:%d:%d:%d:%d", o.URI, o.Range.Start.Line, o.Range.Start.Character, o.Range.End.Line, o.Range.End.Character)
-			if seen[keyStr] {
-				continue
-			}
-			seen[keyStr] = true
-			uriDoc := protocol.DocumentUri(o.URI)
-			changes[uriDoc] = append(changes[uriDoc], protocol.TextEdit{Range: o.Range, NewText: newName})
-		}
-		if len(changes) == 0 {
-			return nil, nil
-		}
-		return &protocol.WorkspaceEdit{Changes: changes}, nil
+		return renameExport(ws, key, newName)
 	} else if err != nil {
 		return nil, err
 	}
@@ -271,6 +310,9 @@ func ReferencesAt(ws *Workspace, uri string, text string, pos protocol.Position,
 	}
 	ref, def := an.FindOccurrence(posByte)
 	if ref == nil && def == nil {
+		if key, ok := preludeKeyAt(ws, uri, text, an, posByte); ok {
+			return exportReferences(ws, key, includeDecl)
+		}
 		return nil, nil
 	}
 
@@ -278,28 +320,7 @@ func ReferencesAt(ws *Workspace, uri string, text string, pos protocol.Position,
 		if err != nil {
 			return nil, err
 		}
-		ix, err := BuildWorkspaceIndex(ws)
-		if err != nil {
-			return nil, err
-		}
-		occ := ix.ByKey[key]
-		if len(occ) == 0 {
-			return nil, nil
-		}
-		locs := []protocol.Location{}
-		seen := map[string]bool{}
-		for _, o := range occ {
-			if !includeDecl && o.Kind == OccurrenceDecl {
-				continue
-			}
-			keyStr := fmt.Sprintf("%s:%d:%d:%d:%d", o.URI, o.Range.Start.Line, o.Range.Start.Character, o.Range.End.Line, o.Range.End.Character)
-			if seen[keyStr] {
-				continue
-			}
-			seen[keyStr] = true
-			locs = append(locs, protocol.Location{URI: protocol.DocumentUri(o.URI), Range: o.Range})
-		}
-		return locs, nil
+		return exportReferences(ws, key, includeDecl)
 	} else if err != nil {
 		return nil, err
 	}
@@ -455,15 +476,18 @@ func completionContext(text string, pos Pos) CompletionContext {
 
 func memberCompletionAlias(text string, pos Pos) (string, string) {
 	lx := lexer.New(text)
-	var prev2, prev1 token.Token
+	var prev3, prev2, prev1 token.Token
 	for {
 		tok := lx.NextToken()
 		if tok.Type == token.EOF {
 			return "", ""
 		}
-		if tok.Line > pos.Line || (tok.Line == pos.Line && tok.Col > pos.Col) {
+		// A token starting at the cursor (such as the `)` in `f(cfg.|)`)
+		// comes after it.
+		if tok.Line > pos.Line || (tok.Line == pos.Line && tok.Col >= pos.Col) {
 			break
 		}
+		prev3 = prev2
 		prev2 = prev1
 		prev1 = tok
 	}
@@ -471,8 +495,8 @@ func memberCompletionAlias(text string, pos Pos) (string, string) {
 	if prev1.Type == token.DOT && prev2.Type == token.IDENT {
 		return prev2.Literal, ""
 	}
-	if prev1.Type == token.IDENT && prev2.Type == token.DOT {
-		return prev2.Literal, prev1.Literal
+	if prev1.Type == token.IDENT && prev2.Type == token.DOT && prev3.Type == token.IDENT {
+		return prev3.Literal, prev1.Literal
 	}
 	return "", ""
 }
@@ -495,12 +519,31 @@ func positionInStringToken(text string, pos Pos) bool {
 	}
 }
 
-func completionForModule(ws *Workspace, uri string, an *Analysis, pos Pos, alias string, prefix string) []protocol.CompletionItem {
-	if ws == nil || an == nil {
+// completionForMember completes `alias.`: the exports of an imported
+// module, or the keys of a variable holding a dict.
+func completionForMember(ws *Workspace, uri string, an *Analysis, pos Pos, alias string, prefix string) []protocol.CompletionItem {
+	if an == nil {
 		return nil
 	}
 	b, _ := an.ResolveAt(pos, alias)
-	if b == nil || b.Kind != SymNamespace {
+	if b == nil {
+		return nil
+	}
+	if b.Kind == SymNamespace {
+		return completionForModule(ws, uri, b, prefix)
+	}
+	items := []completionCandidate{}
+	for _, key := range dictKeysFor(an, b) {
+		if prefix != "" && !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		items = append(items, completionCandidate{name: key, kind: SymField})
+	}
+	return buildCompletionItems(items)
+}
+
+func completionForModule(ws *Workspace, uri string, b *Binding, prefix string) []protocol.CompletionItem {
+	if ws == nil {
 		return nil
 	}
 
@@ -569,7 +612,7 @@ func buildCompletionItems(items []completionCandidate) []protocol.CompletionItem
 
 func completionWeight(kind SymbolKind) int {
 	switch kind {
-	case SymVar, SymFunc:
+	case SymVar, SymFunc, SymField:
 		return 0
 	case SymParam:
 		return 1
@@ -598,11 +641,89 @@ func completionItemKind(kind SymbolKind) protocol.CompletionItemKind {
 		return protocol.CompletionItemKindFunction
 	case SymKeyword:
 		return protocol.CompletionItemKindKeyword
+	case SymField:
+		return protocol.CompletionItemKindField
 	default:
 		return protocol.CompletionItemKindVariable
 	}
 }
 
+func renameExport(ws *Workspace, key SymbolKey, newName string) (*protocol.WorkspaceEdit, error) {
+	ix, err := BuildWorkspaceIndex(ws)
+	if err != nil {
+		return nil, err
+	}
+	occ := ix.ByKey[key]
+	if len(occ) == 0 {
+		return nil, nil
+	}
+	changes := map[protocol.DocumentUri][]protocol.TextEdit{}
+	seen := map[string]bool{}
+	for _, o := range occ {
+		if o.Kind == OccurrenceAliasUse {
+			continue
+		}
+		keyStr := fmt.Sprintf("%s:%d:%d:%d:%d", o.URI, o.Range.Start.Line, o.Range.Start.Character, o.Range.End.Line, o.Range.End.Character)
+		if seen[keyStr] {
+			continue
+		}
+		seen[keyStr] = true
+		uriDoc := protocol.DocumentUri(o.URI)
+		changes[uriDoc] = append(changes[uriDoc], protocol.TextEdit{Range: o.Range, NewText: newName})
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return &protocol.WorkspaceEdit{Changes: changes}, nil
+}
+
+func exportReferences(ws *Workspace, key SymbolKey, includeDecl bool) ([]protocol.Location, error) {
+	ix, err := BuildWorkspaceIndex(ws)
+	if err != nil {
+		return nil, err
+	}
+	occ := ix.ByKey[key]
+	if len(occ) == 0 {
+		return nil, nil
+	}
+	locs := []protocol.Location{}
+	seen := map[string]bool{}
+	for _, o := range occ {
+		if !includeDecl && o.Kind == OccurrenceDecl {
+			continue
+		}
+		keyStr := fmt.Sprintf("%s:%d:%d:%d:%d", o.URI, o.Range.Start.Line, o.Range.Start.Character, o.Range.End.Line, o.Range.End.Character)
+		if seen[keyStr] {
+			continue
+		}
+		seen[keyStr] = true
+		locs = append(locs, protocol.Location{URI: protocol.DocumentUri(o.URI), Range: o.Range})
+	}
+	return locs, nil
+}
+
+// preludeKeyAt returns the symbol key of the free identifier at pos when
+// the project prelude exports it.
+func preludeKeyAt(ws *Workspace, uri string, text string, an *Analysis, pos Pos) (SymbolKey, bool) {
+	id := an.FreeAt(pos)
+	if id == nil {
+		return SymbolKey{}, false
+	}
+	absPath := UriToPath(uri)
+	if absPath == "" {
+		return SymbolKey{}, false
+	}
+	absPath, _ = filepath.Abs(absPath)
+	prelude, names, ok := ws.PreludeExports(absPath, text)
+	if !ok {
+		return SymbolKey{}, false
+	}
+	if _, ok := names[identText(id)]; !ok {
+		return SymbolKey{}, false
+	}
+	return SymbolKey{Kind: SymKeyExport, ModulePath: prelude, Name: identText(id)}, true
+}
+
 func exportKeyForTarget(ws *Workspace, uri string, an *Analysis, ref *Reference, def *Binding, strict bool) (SymbolKey, bool, error) {
 	if ws == nil || an == nil {
 		return SymbolKey{}, false, nil
@@ -704,8 +825,8 @@ func kindLabelFor(kind SymbolKind) string {
 func tokenKeywords() []string {
 	return []string{
 		"func", "return", "break", "continue", "if", "else", "while", "for", "in", "true", "false", "nil", "null",
-		"and", "or", "not", "import", "from", "as", "try", "catch", "finally", "throw", "defer", "export",
-		"switch", "match", "case", "default",
+		"and", "or", "not", "import", "from", "as", "try", "catch", "finally", "throw", "assert", "defer", "del", "export",
+		"switch", "match", "case", "default", "class", "yield",
 	}
 }
 
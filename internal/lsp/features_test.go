@@ -7,6 +7,11 @@ import (
 	"testing"
 	"unicode/utf16"
 
+	"welle/internal/diag"
+	"welle/internal/lexer"
+	"welle/internal/lint"
+	"welle/internal/parser"
+
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
@@ -49,6 +54,51 @@ math.|
 	}
 }
 
+func TestCompletionDictKeys(t *testing.T) {
+	ws := testWorkspace(t)
+	text := `cfg = #{"host": "localhost", "port": 8080, "not a name": 1}
+cfg.debug = true
+func setup() {
+  cfg["retries"] = 3
+}
+print(cfg.p|)
+`
+	clean, pos := extractPos(t, text)
+	items := CompletionItems(ws, "file:///test.wll", clean, pos)
+	if len(items) != 1 || items[0].Label != "port" {
+		t.Fatalf("expected only port for prefix p, got %v", items)
+	}
+
+	clean, pos = extractPos(t, strings.Replace(text, "cfg.p|", "cfg.|", 1))
+	items = CompletionItems(ws, "file:///test.wll", clean, pos)
+	for _, key := range []string{"host", "port", "debug", "retries"} {
+		if indexOfCompletion(items, key) == -1 {
+			t.Fatalf("expected dict key completion %q, got %v", key, items)
+		}
+	}
+	if indexOfCompletion(items, "not a name") != -1 {
+		t.Fatalf("keys that are not identifiers cannot follow a dot")
+	}
+	if items[0].Kind == nil || *items[0].Kind != protocol.CompletionItemKindField {
+		t.Fatalf("expected field completion kind")
+	}
+}
+
+func TestCompletionDictKeysTryWithoutFinally(t *testing.T) {
+	ws := testWorkspace(t)
+	text := `cfg = #{"host": "localhost"}
+try { cfg.port = 8080 } catch (e) { print(e) }
+print(cfg.|)
+`
+	clean, pos := extractPos(t, text)
+	items := CompletionItems(ws, "file:///test.wll", clean, pos)
+	for _, key := range []string{"host", "port"} {
+		if indexOfCompletion(items, key) == -1 {
+			t.Fatalf("expected dict key completion %q, got %v", key, items)
+		}
+	}
+}
+
 func TestCompletionUnicodeIdentifier(t *testing.T) {
 	ws := testWorkspace(t)
 	text := `func f() {
@@ -103,6 +153,37 @@ print(|len([1,2,3]))
 	}
 }
 
+func TestDefinitionScopedBindings(t *testing.T) {
+	text := `x = 1
+func f(n) {
+  x := n
+  for item in [1, 2] {
+    print(item, x, n)
+  }
+}
+`
+	cases := []struct {
+		cursor    string
+		line, col uint32
+	}{
+		{"print(|item", 3, 6}, // for-in variable
+		{"item, |x", 2, 2},    // walrus local shadows the top-level x
+		{"x, |n)", 1, 7},      // parameter
+		{"|x = 1", 0, 0},      // a declaration resolves to itself
+	}
+	for _, tc := range cases {
+		clean, pos := extractPos(t, strings.Replace(text, strings.ReplaceAll(tc.cursor, "|", ""), tc.cursor, 1))
+		locs := DefinitionAt("file:///test.wll", clean, pos)
+		if len(locs) != 1 {
+			t.Fatalf("%s: expected one location, got %v", tc.cursor, locs)
+		}
+		start := locs[0].Range.Start
+		if start.Line != tc.line || start.Character != tc.col {
+			t.Fatalf("%s: expected %d:%d, got %d:%d", tc.cursor, tc.line, tc.col, start.Line, start.Character)
+		}
+	}
+}
+
 func TestRenameLocalNestedBlocks(t *testing.T) {
 	ws := testWorkspace(t)
 	text := `func f() {
@@ -175,6 +256,24 @@ func TestReferencesLocal(t *testing.T) {
 	}
 }
 
+func TestReferencesTryWithoutFinally(t *testing.T) {
+	ws := testWorkspace(t)
+	text := `func f() {
+  x = 1
+  try { print(x) } catch (e) { print(x) }
+  return x
+}
+`
+	pos := protocol.Position{Line: 1, Character: 2}
+	locs, err := ReferencesAt(ws, "file:///test.wll", text, pos, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(locs) != 4 {
+		t.Fatalf("expected 4 references including decl, got %d", len(locs))
+	}
+}
+
 func TestReferencesWalrusLocal(t *testing.T) {
 	ws := testWorkspace(t)
 	text := `func f() {
@@ -298,3 +397,44 @@ func testWorkspace(t *testing.T) *Workspace {
 	root := filepath.Dir(filepath.Dir(wd))
 	return NewWorkspace(root)
 }
+
+func TestReplaceOperatorAction(t *testing.T) {
+	text := "x = name or \"guest\"\n"
+	r := protocol.Range{
+		Start: protocol.Position{Line: 0, Character: 9},
+		End:   protocol.Position{Line: 0, Character: 11},
+	}
+	action, ok := MakeReplaceOperatorAction("file:///test.wll", text, r, "or", "??")
+	if !ok {
+		t.Fatalf("expected replace action")
+	}
+	edits := action.Edit.Changes[protocol.DocumentUri("file:///test.wll")]
+	if len(edits) != 1 || edits[0].NewText != "??" {
+		t.Fatalf("unexpected edits: %#v", edits)
+	}
+	if _, ok := MakeReplaceOperatorAction("file:///test.wll", text, r, "and", "??"); ok {
+		t.Fatalf("expected no action when range does not match operator")
+	}
+}
+
+func TestAppendCompilerWarnings(t *testing.T) {
+	src := "func f() {\n  tmp = 1\n  len = 2\n  return len\n}\n"
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	ds := lint.Run(prog)
+	ds = AppendCompilerWarnings(ds, prog)
+
+	byCode := map[string][]diag.Diagnostic{}
+	for _, d := range ds {
+		byCode[d.Code] = append(byCode[d.Code], d)
+	}
+	if len(byCode["WL0001"]) != 1 {
+		t.Fatalf("expected linter WL0001 for tmp, got %v", ds)
+	}
+	if len(byCode["WC0001"]) != 0 {
+		t.Fatalf("expected WC0001 to be folded into WL0001, got %v", ds)
+	}
+	if len(byCode["WC0003"]) != 1 || byCode["WC0003"][0].Range.Line != 3 {
+		t.Fatalf("expected WC0003 for len on line 3, got %v", ds)
+	}
+}
@@ -67,6 +67,11 @@ func BuildIndex(uri string, prog *ast.Program) *DocIndex {
 				return
 			}
 			addSymbol(n.Name.Value, n.Name.Token, protocol.SymbolKindFunction)
+		case *ast.ClassStatement:
+			if n.Name == nil {
+				return
+			}
+			addSymbol(n.Name.Value, n.Name.Token, protocol.SymbolKindClass)
 		case *ast.ImportStatement:
 			if n.Alias == nil {
 				return
@@ -101,6 +106,11 @@ func BuildIndex(uri string, prog *ast.Program) *DocIndex {
 					return
 				}
 				addExport(inner.Name.Value, inner.Name.Token, protocol.SymbolKindFunction)
+			case *ast.ClassStatement:
+				if inner.Name == nil {
+					return
+				}
+				addExport(inner.Name.Value, inner.Name.Token, protocol.SymbolKindClass)
 			case *ast.AssignStatement:
 				if inner.Name == nil {
 					return
@@ -50,6 +50,18 @@ var methodDocs = map[string]MethodInfo{
 		Doc:       "Returns a substring using the same rules as s[low:high].",
 		Params:    []string{"low?", "high?"},
 	},
+	"casefold": {
+		Name:      "casefold",
+		Signature: "casefold() -> string",
+		Doc:       "Applies full Unicode case folding for caseless comparison (\"Straße\" folds to \"strasse\").",
+		Params:    []string{},
+	},
+	"graphemes": {
+		Name:      "graphemes",
+		Signature: "graphemes() -> [string]",
+		Doc:       "Splits the string into user-perceived characters (extended grapheme clusters).",
+		Params:    []string{},
+	},
 }
 
 func methodInfo(name string) *MethodInfo {
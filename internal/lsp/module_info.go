@@ -1,11 +1,11 @@
 package lsp
 
 import (
-	"os"
 	"path/filepath"
 
 	"welle/internal/ast"
 	"welle/internal/lexer"
+	"welle/internal/module"
 	"welle/internal/parser"
 )
 
@@ -32,7 +32,7 @@ func LoadModuleInfo(ws *Workspace, fromURI string, spec string) (*ModuleInfo, er
 		return nil, err
 	}
 	resolvedAbs, _ := filepath.Abs(resolved)
-	b, err := os.ReadFile(resolvedAbs)
+	b, err := module.ReadSource(resolvedAbs)
 	if err != nil {
 		return nil, err
 	}
@@ -56,6 +56,15 @@ func LoadModuleInfo(ws *Workspace, fromURI string, spec string) (*ModuleInfo, er
 				Params: paramsFromIdents(n.Parameters),
 				Kind:   SymFunc,
 			}
+		case *ast.ClassStatement:
+			if n.Name == nil {
+				return
+			}
+			info.Exports[n.Name.Value] = ModuleExport{
+				Name:   n.Name.Value,
+				Params: paramsFromIdents(classParams(n)),
+				Kind:   SymFunc,
+			}
 		case *ast.AssignStatement:
 			if n.Name == nil {
 				return
@@ -40,6 +40,10 @@ func posLessEq(a, b Pos) bool {
 	return a.Col <= b.Col
 }
 
+func posLess(a, b Pos) bool {
+	return !posLessEq(b, a)
+}
+
 func posWithin(p Pos, start Pos, end Pos) bool {
 	return posLessEq(start, p) && posLessEq(p, end)
 }
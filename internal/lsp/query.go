@@ -0,0 +1,224 @@
+package lsp
+
+import (
+	"reflect"
+	"sort"
+
+	"welle/internal/ast"
+)
+
+// QuerySymbol is a top-level declaration found by a ProjectQuery. Line and
+// Col are 1-based; they are 0 for symbols declared outside the workspace
+// (for example std modules).
+type QuerySymbol struct {
+	Path   string
+	Name   string
+	Kind   string // "func" or "var"
+	Params []string
+	Line   int
+	Col    int
+}
+
+// CallEdge is one call site whose callee resolves to a known symbol.
+type CallEdge struct {
+	Path   string
+	Line   int
+	Col    int
+	Caller string // enclosing function, "<module>" at top level
+	Callee QuerySymbol
+}
+
+// ProjectQuery answers whole-project questions (exports, call graph,
+// unused symbols) from the same name resolution the editor features use,
+// so it can run outside an LSP session.
+type ProjectQuery struct {
+	docs  []workspaceDoc
+	index map[SymbolKey][]Occurrence
+	decls map[SymbolKey]QuerySymbol
+}
+
+func NewProjectQuery(ws *Workspace) (*ProjectQuery, error) {
+	docs, err := resolveWorkspaceDocs(ws)
+	if err != nil {
+		return nil, err
+	}
+	q := &ProjectQuery{
+		docs:  docs,
+		index: map[SymbolKey][]Occurrence{},
+		decls: map[SymbolKey]QuerySymbol{},
+	}
+	for _, doc := range docs {
+		for key, occs := range doc.syms.occ {
+			q.index[key] = append(q.index[key], occs...)
+		}
+		for key, b := range doc.syms.decls {
+			if sym, ok := topLevelSymbol(doc, b); ok {
+				q.decls[key] = sym
+			}
+		}
+	}
+	return q, nil
+}
+
+func topLevelSymbol(doc workspaceDoc, b *Binding) (QuerySymbol, bool) {
+	an := doc.syms.analysis
+	if an == nil || b.Scope != an.Root || b.Decl == nil {
+		return QuerySymbol{}, false
+	}
+	kind := ""
+	switch b.Kind {
+	case SymFunc:
+		kind = "func"
+	case SymVar:
+		kind = "var"
+	default:
+		return QuerySymbol{}, false
+	}
+	return QuerySymbol{
+		Path:   doc.path,
+		Name:   b.Name,
+		Kind:   kind,
+		Params: b.Params,
+		Line:   b.Decl.Token.Line,
+		Col:    b.Decl.Token.Col,
+	}, true
+}
+
+// Exports lists exported symbols of every workspace module.
+func (q *ProjectQuery) Exports() []QuerySymbol {
+	var out []QuerySymbol
+	for key, sym := range q.decls {
+		if key.Kind == SymKeyExport {
+			out = append(out, sym)
+		}
+	}
+	sortSymbols(out)
+	return out
+}
+
+// Unused lists top-level functions and variables that are never referenced
+// or imported anywhere in the workspace. Exports count as used once any
+// module imports or references them.
+func (q *ProjectQuery) Unused() []QuerySymbol {
+	var out []QuerySymbol
+	for key, sym := range q.decls {
+		used := false
+		for _, occ := range q.index[key] {
+			if occ.Kind != OccurrenceDecl {
+				used = true
+				break
+			}
+		}
+		if !used {
+			out = append(out, sym)
+		}
+	}
+	sortSymbols(out)
+	return out
+}
+
+// Calls lists every call site whose callee is a named function or variable
+// declared at the top level of a module (workspace or imported). Calls to
+// builtins, parameters, and computed callees are skipped.
+func (q *ProjectQuery) Calls() []CallEdge {
+	var out []CallEdge
+	for _, doc := range q.docs {
+		an := doc.syms.analysis
+		if an == nil {
+			continue
+		}
+		walkCalls(reflect.ValueOf(an.Program), "<module>", func(caller string, call *ast.CallExpression) {
+			var ident *ast.Identifier
+			switch fn := call.Function.(type) {
+			case *ast.Identifier:
+				ident = fn
+			case *ast.MemberExpression:
+				ident = fn.Property
+			}
+			if ident == nil {
+				return
+			}
+			key, ok := doc.syms.keys[ident]
+			if !ok {
+				return
+			}
+			callee, ok := q.decls[key]
+			if !ok {
+				if key.Kind != SymKeyExport {
+					return
+				}
+				callee = QuerySymbol{Path: key.ModulePath, Name: key.Name}
+			}
+			out = append(out, CallEdge{
+				Path:   doc.path,
+				Line:   ident.Token.Line,
+				Col:    ident.Token.Col,
+				Caller: caller,
+				Callee: callee,
+			})
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	return out
+}
+
+// walkCalls visits every call expression below v, passing the name of the
+// innermost enclosing function.
+func walkCalls(v reflect.Value, caller string, fn func(caller string, call *ast.CallExpression)) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if !v.IsNil() {
+			walkCalls(v.Elem(), caller, fn)
+		}
+	case reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		switch n := v.Interface().(type) {
+		case *ast.FuncStatement:
+			if n.Name != nil {
+				caller = n.Name.Value
+			}
+		case *ast.FunctionLiteral:
+			caller = ast.AnonymousFuncName(n.Token)
+		case *ast.CallExpression:
+			fn(caller, n)
+		case *ast.TemplateLiteral:
+			// Interpolations carry positions relative to the template.
+			return
+		}
+		walkCalls(v.Elem(), caller, fn)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				walkCalls(v.Field(i), caller, fn)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			walkCalls(v.Index(i), caller, fn)
+		}
+	}
+}
+
+func sortSymbols(syms []QuerySymbol) {
+	sort.Slice(syms, func(i, j int) bool {
+		a, b := syms[i], syms[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+}
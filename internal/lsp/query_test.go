@@ -0,0 +1,97 @@
+package lsp
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestProjectQuery(t *testing.T) {
+	root := t.TempDir()
+	writeWorkspaceFiles(t, root, map[string]string{
+		"mod.wll": "export func greet(name) { return helper(name) }\n" +
+			"export func farewell() { return 0 }\n" +
+			"func helper(x) { return x }\n" +
+			"func orphan() { return 1 }\n",
+		"main.wll": "from \"./mod\" import greet\n" +
+			"import \"./mod\" as m\n" +
+			"func run() { return m.greet(\"a\") }\n" +
+			"greet(\"b\")\n" +
+			"run()\n",
+	})
+	q, err := NewProjectQuery(NewWorkspace(root))
+	if err != nil {
+		t.Fatalf("NewProjectQuery: %v", err)
+	}
+
+	var exports []string
+	for _, s := range q.Exports() {
+		exports = append(exports, filepath.Base(s.Path)+":"+s.Name)
+	}
+	if want := []string{"mod.wll:greet", "mod.wll:farewell"}; !equalStrings(exports, want) {
+		t.Fatalf("exports = %v, want %v", exports, want)
+	}
+	if got := q.Exports()[0].Params; len(got) != 1 || got[0] != "name" {
+		t.Fatalf("greet params = %v", got)
+	}
+
+	var calls []string
+	for _, c := range q.Calls() {
+		calls = append(calls, filepath.Base(c.Path)+":"+c.Caller+"->"+c.Callee.Name)
+	}
+	want := []string{
+		"main.wll:run->greet",
+		"main.wll:<module>->greet",
+		"main.wll:<module>->run",
+		"mod.wll:greet->helper",
+	}
+	if !equalStrings(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+
+	var unused []string
+	for _, s := range q.Unused() {
+		unused = append(unused, s.Name)
+	}
+	if want := []string{"farewell", "orphan"}; !equalStrings(unused, want) {
+		t.Fatalf("unused = %v, want %v", unused, want)
+	}
+}
+
+func TestProjectQueryTryWithoutFinally(t *testing.T) {
+	root := t.TempDir()
+	writeWorkspaceFiles(t, root, map[string]string{
+		"main.wll": "func risky() { return 1 }\n" +
+			"func unused() { return 2 }\n" +
+			"try { x = risky() } catch (e) { print(e) }\n",
+	})
+	q, err := NewProjectQuery(NewWorkspace(root))
+	if err != nil {
+		t.Fatalf("NewProjectQuery: %v", err)
+	}
+	var unused []string
+	for _, s := range q.Unused() {
+		unused = append(unused, s.Name)
+	}
+	if want := []string{"unused"}; !equalStrings(unused, want) {
+		t.Fatalf("unused = %v, want %v", unused, want)
+	}
+	var calls []string
+	for _, c := range q.Calls() {
+		calls = append(calls, c.Caller+"->"+c.Callee.Name)
+	}
+	if want := []string{"<module>->risky"}; !equalStrings(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
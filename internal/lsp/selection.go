@@ -0,0 +1,188 @@
+package lsp
+
+import (
+	"sort"
+	"strings"
+
+	"welle/internal/ast"
+	"welle/internal/lexer"
+	"welle/internal/token"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// SelectionRangesAt answers textDocument/selectionRange: for each position,
+// the chain of syntax elements around it (identifier, expression,
+// statement, block, function, ...) from the innermost outwards. Positions
+// outside any statement get an empty range at the position itself.
+func SelectionRangesAt(text string, positions []protocol.Position) []protocol.SelectionRange {
+	prog := Parse(text).Program
+	sp := newSpanner(text)
+	out := make([]protocol.SelectionRange, 0, len(positions))
+	for _, pos := range positions {
+		sel := protocol.SelectionRange{Range: protocol.Range{Start: pos, End: pos}}
+		posByte, ok := positionToByte(text, pos)
+		if !ok || prog == nil {
+			out = append(out, sel)
+			continue
+		}
+		var cur *protocol.SelectionRange
+		for _, s := range sp.chainAt(prog, posByte) {
+			r := protocol.Range{Start: sp.toProtocol(s.start), End: sp.toProtocol(s.end)}
+			if cur != nil && cur.Range == r {
+				continue
+			}
+			cur = &protocol.SelectionRange{Range: r, Parent: cur}
+		}
+		if cur != nil {
+			sel = *cur
+		}
+		out = append(out, sel)
+	}
+	return out
+}
+
+// span is a half-open source range in byte columns.
+type span struct {
+	start, end Pos
+}
+
+type lexTok struct {
+	typ        token.Type
+	start, end Pos
+}
+
+// spanner computes where syntax elements start and end. The AST only
+// records the tokens a node holds itself, so a node's span covers those and
+// its children's spans, widened to take in any bracket left unbalanced
+// (the `)` of a call, the `}` of a block, parentheses around an operand).
+type spanner struct {
+	lines []string
+	toks  []lexTok
+	memo  map[any]span
+}
+
+func newSpanner(text string) *spanner {
+	sp := &spanner{lines: splitLines(text), memo: map[any]span{}}
+	lx := lexer.New(text)
+	for {
+		tok := lx.NextToken()
+		if tok.Type == token.EOF {
+			break
+		}
+		if tok.Type == token.NEWLINE {
+			continue
+		}
+		sp.toks = append(sp.toks, lexTok{typ: tok.Type, start: Pos{Line: tok.Line, Col: tok.Col}, end: tokenEnd(tok)})
+	}
+	return sp
+}
+
+func tokenEnd(tok token.Token) Pos {
+	lexeme := tok.Raw
+	if lexeme == "" {
+		lexeme = tok.Literal
+	}
+	if lexeme == "" {
+		lexeme = " "
+	}
+	if i := strings.LastIndexByte(lexeme, '\n'); i >= 0 {
+		return Pos{Line: tok.Line + strings.Count(lexeme, "\n"), Col: len(lexeme) - i}
+	}
+	return Pos{Line: tok.Line, Col: tok.Col + len(lexeme)}
+}
+
+func (sp *spanner) spanOf(n any) (span, bool) {
+	if s, ok := sp.memo[n]; ok {
+		return s, s.end != (Pos{})
+	}
+	var s span
+	grow := func(start, end Pos) {
+		if s.end == (Pos{}) || posLess(start, s.start) {
+			s.start = start
+		}
+		if s.end == (Pos{}) || posLess(s.end, end) {
+			s.end = end
+		}
+	}
+	for _, tok := range ast.Tokens(n) {
+		grow(Pos{Line: tok.Line, Col: tok.Col}, tokenEnd(tok))
+	}
+	for _, c := range ast.Children(n) {
+		if cs, ok := sp.spanOf(c); ok {
+			grow(cs.start, cs.end)
+		}
+	}
+	if s.end != (Pos{}) {
+		s = sp.balance(s)
+	}
+	sp.memo[n] = s
+	return s, s.end != (Pos{})
+}
+
+// balance widens s until the brackets inside it pair up.
+func (sp *spanner) balance(s span) span {
+	i := sort.Search(len(sp.toks), func(k int) bool { return !posLess(sp.toks[k].start, s.start) })
+	j := sort.Search(len(sp.toks), func(k int) bool { return !posLess(sp.toks[k].start, s.end) })
+	if i >= j {
+		return s
+	}
+	open, closeBefore := 0, 0
+	for k := i; k < j; k++ {
+		switch sp.toks[k].typ {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			open++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if open > 0 {
+				open--
+			} else {
+				closeBefore++
+			}
+		}
+	}
+	for ; open > 0 && j < len(sp.toks); j++ {
+		switch sp.toks[j].typ {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			open++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			open--
+		}
+		s.end = sp.toks[j].end
+	}
+	for ; closeBefore > 0 && i > 0; i-- {
+		switch sp.toks[i-1].typ {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			closeBefore--
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			closeBefore++
+		}
+		s.start = sp.toks[i-1].start
+	}
+	return s
+}
+
+// chainAt lists the spans of the elements containing pos, outermost first.
+func (sp *spanner) chainAt(prog *ast.Program, pos Pos) []span {
+	var chain []span
+	var n any = prog
+	for n != nil {
+		var next any
+		for _, c := range ast.Children(n) {
+			cs, ok := sp.spanOf(c)
+			if ok && posLessEq(cs.start, pos) && posLessEq(pos, cs.end) {
+				chain = append(chain, cs)
+				next = c
+				break
+			}
+		}
+		n = next
+	}
+	return chain
+}
+
+func (sp *spanner) toProtocol(p Pos) protocol.Position {
+	if p.Line <= 0 || p.Line > len(sp.lines) {
+		return protocol.Position{}
+	}
+	return protocol.Position{Line: uint32(p.Line - 1), Character: byteColToUTF16(sp.lines[p.Line-1], p.Col)}
+}
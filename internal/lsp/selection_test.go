@@ -0,0 +1,86 @@
+package lsp
+
+import (
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestSelectionRangesExpandOutwards(t *testing.T) {
+	text := `func f(a) {
+  if (a > 1) {
+    x = g((a + 1) * 2, [a])
+  }
+}
+`
+	// The cursor is on the `a` in `(a + 1)`.
+	got := SelectionRangesAt(text, []protocol.Position{{Line: 2, Character: 11}})
+	if len(got) != 1 {
+		t.Fatalf("expected one selection range, got %d", len(got))
+	}
+
+	want := []string{
+		"a",
+		"a + 1",
+		"(a + 1) * 2",
+		"g((a + 1) * 2, [a])",
+		"x = g((a + 1) * 2, [a])",
+		"{\n    x = g((a + 1) * 2, [a])\n  }",
+		"if (a > 1) {\n    x = g((a + 1) * 2, [a])\n  }",
+	}
+	sel := &got[0]
+	for i, w := range want {
+		if sel == nil {
+			t.Fatalf("chain ended after %d ranges", i)
+		}
+		if s := textInRange(text, sel.Range); s != w {
+			t.Fatalf("range %d: expected %q, got %q", i, w, s)
+		}
+		sel = sel.Parent
+	}
+	// Then the function body and the whole function.
+	for sel.Parent != nil {
+		sel = sel.Parent
+	}
+	if s := textInRange(text, sel.Range); s != text[:len(text)-1] {
+		t.Fatalf("outermost range should be the function, got %q", s)
+	}
+}
+
+func TestSelectionRangesEveryRangeContainsItsChild(t *testing.T) {
+	text := "d = #{\"k\": [1, 2]}\nfor (k, v) in d { print(t\"${k}=${v}\") }\n"
+	for line := uint32(0); line < 2; line++ {
+		for ch := uint32(0); ch < 40; ch++ {
+			got := SelectionRangesAt(text, []protocol.Position{{Line: line, Character: ch}})
+			for sel := &got[0]; sel.Parent != nil; sel = sel.Parent {
+				if !rangeContains(sel.Parent.Range, sel.Range) || sel.Parent.Range == sel.Range {
+					t.Fatalf("%d:%d: parent %v does not strictly contain %v", line, ch, sel.Parent.Range, sel.Range)
+				}
+			}
+		}
+	}
+}
+
+func textInRange(text string, r protocol.Range) string {
+	start, ok1 := positionToByte(text, r.Start)
+	end, ok2 := positionToByte(text, r.End)
+	if !ok1 || !ok2 {
+		return ""
+	}
+	lines := splitLines(text)
+	if start.Line == end.Line {
+		return lines[start.Line-1][start.Col-1 : end.Col-1]
+	}
+	out := lines[start.Line-1][start.Col-1:]
+	for l := start.Line + 1; l < end.Line; l++ {
+		out += "\n" + lines[l-1]
+	}
+	return out + "\n" + lines[end.Line-1][:end.Col-1]
+}
+
+func rangeContains(outer, inner protocol.Range) bool {
+	before := func(a, b protocol.Position) bool {
+		return a.Line < b.Line || (a.Line == b.Line && a.Character <= b.Character)
+	}
+	return before(outer.Start, inner.Start) && before(inner.End, outer.End)
+}
@@ -16,9 +16,12 @@ const (
 	ttComment   = 9
 )
 
+// semantic token modifier bits (must match legend order in server)
 const (
-	modDecl     = 1 << 0
-	modReadonly = 1 << 1
+	modDecl           = 1 << 0
+	modReadonly       = 1 << 1
+	modDefaultLibrary = 1 << 2
+	modExported       = 1 << 3
 )
 
 type SemTok struct {
@@ -34,7 +37,7 @@ func Classify(tok token.Token) (int, bool) {
 	// keywords
 	case token.FUNC, token.RETURN, token.IF, token.ELSE, token.WHILE, token.FOR,
 		token.SWITCH, token.CASE, token.DEFAULT, token.MATCH,
-		token.TRY, token.CATCH, token.FINALLY, token.THROW, token.DEFER,
+		token.TRY, token.CATCH, token.FINALLY, token.THROW, token.ASSERT, token.DEFER, token.DEL,
 		token.BREAK, token.CONTINUE, token.PASS, token.IMPORT, token.EXPORT,
 		token.TRUE, token.FALSE, token.NIL, token.AND, token.OR, token.NOT,
 		token.FROM, token.AS:
@@ -21,6 +21,19 @@ type scope struct {
 	locals     map[string]bool
 	funcs      map[string]bool
 	namespaces map[string]bool
+	// mods holds the modifiers a binding's uses inherit from its
+	// declaration (readonly, exported).
+	mods map[string]int
+}
+
+func newScope() scope {
+	return scope{
+		params:     map[string]bool{},
+		locals:     map[string]bool{},
+		funcs:      map[string]bool{},
+		namespaces: map[string]bool{},
+		mods:       map[string]int{},
+	}
 }
 
 var builtinFunctions = map[string]bool{
@@ -53,6 +66,32 @@ var builtinFunctions = map[string]bool{
 	"group_digits":   true,
 	"format_float":   true,
 	"format_percent": true,
+	"checked_add":    true,
+	"checked_sub":    true,
+	"checked_mul":    true,
+	"floor_div":      true,
+	"floor_mod":      true,
+	"round":          true,
+	"floor":          true,
+	"ceil":           true,
+	"trunc":          true,
+	"is_nan":         true,
+	"is_inf":         true,
+	"approx_eq":      true,
+	"sort_by":        true,
+	"unique":         true,
+	"reversed":       true,
+	"locals":         true,
+	"globals":        true,
+	"dir":            true,
+	"trace":          true,
+	"args":           true,
+}
+
+// isBuiltinName reports whether name is a builtin function rather than a
+// user binding.
+func isBuiltinName(name string) bool {
+	return builtinFunctions[name] || builtinInfo(name) != nil
 }
 
 func identText(id *ast.Identifier) string {
@@ -91,16 +130,9 @@ func CollectSemantic(program *ast.Program) map[Key]Classified {
 		return out
 	}
 
-	scopes := []scope{{params: map[string]bool{}, locals: map[string]bool{}, funcs: map[string]bool{}, namespaces: map[string]bool{}}}
+	scopes := []scope{newScope()}
 
-	push := func() {
-		scopes = append(scopes, scope{
-			params:     map[string]bool{},
-			locals:     map[string]bool{},
-			funcs:      map[string]bool{},
-			namespaces: map[string]bool{},
-		})
-	}
+	push := func() { scopes = append(scopes, newScope()) }
 	pop := func() { scopes = scopes[:len(scopes)-1] }
 	cur := func() *scope { return &scopes[len(scopes)-1] }
 
@@ -129,25 +161,53 @@ func CollectSemantic(program *ast.Program) map[Key]Classified {
 		bindFunc
 	)
 
-	resolveBinding := func(name string) (bindingKind, bool) {
+	resolveBinding := func(name string) (bindingKind, int, bool) {
 		if name == "" {
-			return bindNone, false
+			return bindNone, 0, false
 		}
 		for i := len(scopes) - 1; i >= 0; i-- {
+			mods := scopes[i].mods[name]
 			if scopes[i].locals[name] {
-				return bindLocal, true
+				return bindLocal, mods, true
 			}
 			if scopes[i].params[name] {
-				return bindParam, true
+				return bindParam, mods, true
 			}
 			if scopes[i].funcs[name] {
-				return bindFunc, true
+				return bindFunc, mods, true
 			}
 			if scopes[i].namespaces[name] {
-				return bindNamespace, true
+				return bindNamespace, mods, true
 			}
 		}
-		return bindNone, false
+		return bindNone, 0, false
+	}
+
+	// declareLocal binds id as a variable in the current scope. ALL_CAPS
+	// names are constants by convention and marked readonly.
+	declareLocal := func(id *ast.Identifier) {
+		name := identText(id)
+		cur().locals[name] = true
+		mods := 0
+		if isAllCapsIdent(name) {
+			mods |= modReadonly
+		}
+		cur().mods[name] = mods
+		markIdent(id, ttVariable, modDecl|mods)
+	}
+
+	// markUse colors a reference to a resolved binding.
+	markUse := func(id *ast.Identifier, kind bindingKind, mods int) {
+		switch kind {
+		case bindLocal:
+			markIdent(id, ttVariable, mods)
+		case bindParam:
+			markIdent(id, ttParameter, mods)
+		case bindNamespace:
+			markIdent(id, ttNamespace, mods)
+		case bindFunc:
+			markIdent(id, ttFunction, mods)
+		}
 	}
 
 	var walkStmt func(s ast.Statement)
@@ -156,10 +216,13 @@ func CollectSemantic(program *ast.Program) map[Key]Classified {
 	walkStmt = func(s ast.Statement) {
 		switch n := s.(type) {
 		case *ast.FuncStatement:
-			markIdent(n.Name, ttFunction, modDecl)
 			if n.Name != nil {
 				cur().funcs[identText(n.Name)] = true
 			}
+			markIdent(n.Name, ttFunction, modDecl)
+			for _, d := range n.Defaults {
+				walkExpr(d)
+			}
 
 			push()
 			for _, p := range n.Parameters {
@@ -176,31 +239,45 @@ func CollectSemantic(program *ast.Program) map[Key]Classified {
 			}
 			pop()
 
+		case *ast.ClassStatement:
+			if n.Name != nil {
+				cur().funcs[identText(n.Name)] = true
+			}
+			markIdent(n.Name, ttType, modDecl)
+			for _, d := range n.Defaults {
+				walkExpr(d)
+			}
+			for _, m := range n.Methods {
+				markIdent(m.Name, ttFunction, modDecl)
+				for _, d := range m.Defaults {
+					walkExpr(d)
+				}
+				push()
+				for _, p := range m.Parameters {
+					if pName := identText(p); pName != "" {
+						cur().params[pName] = true
+					}
+					markIdent(p, ttParameter, modDecl)
+				}
+				if m.Body != nil {
+					for _, st := range m.Body.Statements {
+						walkStmt(st)
+					}
+				}
+				pop()
+			}
+
 		case *ast.AssignStatement:
 			if n.Name != nil {
 				name := identText(n.Name)
 				if n.Op == token.WALRUS {
-					cur().locals[name] = true
-					mods := modDecl
-					if isAllCapsIdent(name) {
-						mods |= modReadonly
-					}
-					markIdent(n.Name, ttVariable, mods)
+					declareLocal(n.Name)
 				} else {
-					switch kind, ok := resolveBinding(name); {
-					case ok && kind == bindParam:
-						markIdent(n.Name, ttParameter, 0)
-					case ok && kind == bindLocal:
-						markIdent(n.Name, ttVariable, 0)
-					case ok && kind == bindNamespace:
-						markIdent(n.Name, ttNamespace, 0)
+					switch kind, mods, ok := resolveBinding(name); {
+					case ok && kind != bindFunc:
+						markUse(n.Name, kind, mods)
 					default:
-						cur().locals[name] = true
-						mods := modDecl
-						if isAllCapsIdent(name) {
-							mods |= modReadonly
-						}
-						markIdent(n.Name, ttVariable, mods)
+						declareLocal(n.Name)
 					}
 				}
 			}
@@ -226,27 +303,32 @@ func CollectSemantic(program *ast.Program) map[Key]Classified {
 		case *ast.FromImportStatement:
 			for _, it := range n.Items {
 				if it.Alias != nil {
-					name := identText(it.Alias)
-					cur().locals[name] = true
-					mods := modDecl
-					if isAllCapsIdent(name) {
-						mods |= modReadonly
-					}
-					markIdent(it.Alias, ttVariable, mods)
+					declareLocal(it.Alias)
 				} else if it.Name != nil {
-					name := identText(it.Name)
-					cur().locals[name] = true
-					mods := modDecl
-					if isAllCapsIdent(name) {
-						mods |= modReadonly
-					}
-					markIdent(it.Name, ttVariable, mods)
+					declareLocal(it.Name)
 				}
 			}
 
 		case *ast.ExportStatement:
 			if n.Stmt != nil {
 				walkStmt(n.Stmt)
+				var id *ast.Identifier
+				switch inner := n.Stmt.(type) {
+				case *ast.FuncStatement:
+					id = inner.Name
+				case *ast.ClassStatement:
+					id = inner.Name
+				case *ast.AssignStatement:
+					id = inner.Name
+				}
+				if name := identText(id); name != "" {
+					cur().mods[name] |= modExported
+					k := Key{Line: id.Token.Line, Col: id.Token.Col, Len: len(name)}
+					if cls, ok := out[k]; ok {
+						cls.Mods |= modExported
+						out[k] = cls
+					}
+				}
 			}
 
 		case *ast.ReturnStatement:
@@ -257,9 +339,19 @@ func CollectSemantic(program *ast.Program) map[Key]Classified {
 		case *ast.DeferStatement:
 			walkExpr(n.Call)
 
+		case *ast.DelStatement:
+			walkExpr(n.Target)
+
+		case *ast.YieldStatement:
+			walkExpr(n.Value)
+
 		case *ast.ThrowStatement:
 			walkExpr(n.Value)
 
+		case *ast.AssertStatement:
+			walkExpr(n.Condition)
+			walkExpr(n.Message)
+
 		case *ast.ExpressionStatement:
 			walkExpr(n.Expression)
 
@@ -268,13 +360,7 @@ func CollectSemantic(program *ast.Program) map[Key]Classified {
 				if t == nil || t.Name == nil {
 					continue
 				}
-				name := identText(t.Name)
-				cur().locals[name] = true
-				mods := modDecl
-				if isAllCapsIdent(name) {
-					mods |= modReadonly
-				}
-				markIdent(t.Name, ttVariable, mods)
+				declareLocal(t.Name)
 			}
 			walkExpr(n.Value)
 
@@ -289,16 +375,13 @@ func CollectSemantic(program *ast.Program) map[Key]Classified {
 			if n.TryBlock != nil {
 				walkStmt(n.TryBlock)
 			}
+			if n.CatchFilter != nil {
+				walkExpr(n.CatchFilter)
+			}
 			if n.CatchBlock != nil {
 				push()
 				if n.CatchName != nil {
-					name := identText(n.CatchName)
-					cur().locals[name] = true
-					mods := modDecl
-					if isAllCapsIdent(name) {
-						mods |= modReadonly
-					}
-					markIdent(n.CatchName, ttVariable, mods)
+					declareLocal(n.CatchName)
 				}
 				walkStmt(n.CatchBlock)
 				pop()
@@ -338,31 +421,13 @@ func CollectSemantic(program *ast.Program) map[Key]Classified {
 			push()
 			if n.Destruct {
 				if n.Key != nil && n.Key.Value != "_" {
-					name := identText(n.Key)
-					cur().locals[name] = true
-					mods := modDecl
-					if isAllCapsIdent(name) {
-						mods |= modReadonly
-					}
-					markIdent(n.Key, ttVariable, mods)
+					declareLocal(n.Key)
 				}
 				if n.Value != nil && n.Value.Value != "_" {
-					name := identText(n.Value)
-					cur().locals[name] = true
-					mods := modDecl
-					if isAllCapsIdent(name) {
-						mods |= modReadonly
-					}
-					markIdent(n.Value, ttVariable, mods)
+					declareLocal(n.Value)
 				}
 			} else if n.Var != nil {
-				name := identText(n.Var)
-				cur().locals[name] = true
-				mods := modDecl
-				if isAllCapsIdent(name) {
-					mods |= modReadonly
-				}
-				markIdent(n.Var, ttVariable, mods)
+				declareLocal(n.Var)
 			}
 			walkExpr(n.Iterable)
 			walkStmt(n.Body)
@@ -388,25 +453,12 @@ func CollectSemantic(program *ast.Program) map[Key]Classified {
 		switch n := e.(type) {
 		case *ast.Identifier:
 			name := identText(n)
-			if kind, ok := resolveBinding(name); ok {
-				switch kind {
-				case bindLocal:
-					mods := 0
-					if isAllCapsIdent(name) {
-						mods |= modReadonly
-					}
-					markIdent(n, ttVariable, mods)
-				case bindParam:
-					markIdent(n, ttParameter, 0)
-				case bindNamespace:
-					markIdent(n, ttNamespace, 0)
-				case bindFunc:
-					markIdent(n, ttFunction, 0)
-				}
+			if kind, mods, ok := resolveBinding(name); ok {
+				markUse(n, kind, mods)
 				return
 			}
-			if builtinFunctions[name] {
-				markIdent(n, ttFunction, 0)
+			if isBuiltinName(name) {
+				markIdent(n, ttFunction, modDefaultLibrary)
 				return
 			}
 			markIdent(n, ttVariable, 0)
@@ -414,21 +466,10 @@ func CollectSemantic(program *ast.Program) map[Key]Classified {
 		case *ast.CallExpression:
 			if id, ok := n.Function.(*ast.Identifier); ok {
 				name := identText(id)
-				if kind, ok := resolveBinding(name); ok {
-					switch kind {
-					case bindLocal:
-						mods := 0
-						if isAllCapsIdent(name) {
-							mods |= modReadonly
-						}
-						markIdent(id, ttVariable, mods)
-					case bindParam:
-						markIdent(id, ttParameter, 0)
-					case bindNamespace:
-						markIdent(id, ttNamespace, 0)
-					case bindFunc:
-						markIdent(id, ttFunction, 0)
-					}
+				if kind, mods, ok := resolveBinding(name); ok {
+					markUse(id, kind, mods)
+				} else if isBuiltinName(name) {
+					markIdent(id, ttFunction, modDefaultLibrary)
 				} else {
 					markIdent(id, ttFunction, 0)
 				}
@@ -441,7 +482,7 @@ func CollectSemantic(program *ast.Program) map[Key]Classified {
 
 		case *ast.MemberExpression:
 			if id, ok := n.Object.(*ast.Identifier); ok {
-				if kind, ok := resolveBinding(identText(id)); ok && kind == bindNamespace {
+				if kind, _, ok := resolveBinding(identText(id)); ok && kind == bindNamespace {
 					markIdent(id, ttNamespace, 0)
 				} else {
 					walkExpr(n.Object)
@@ -490,18 +531,16 @@ func CollectSemantic(program *ast.Program) map[Key]Classified {
 			for _, el := range n.Elements {
 				walkExpr(el)
 			}
+		case *ast.SetLiteral:
+			for _, el := range n.Elements {
+				walkExpr(el)
+			}
 
 		case *ast.ListComprehension:
 			walkExpr(n.Seq)
 			push()
 			if n.Var != nil {
-				name := identText(n.Var)
-				cur().locals[name] = true
-				mods := modDecl
-				if isAllCapsIdent(name) {
-					mods |= modReadonly
-				}
-				markIdent(n.Var, ttVariable, mods)
+				declareLocal(n.Var)
 			}
 			if n.Filter != nil {
 				walkExpr(n.Filter)
@@ -509,8 +548,17 @@ func CollectSemantic(program *ast.Program) map[Key]Classified {
 			walkExpr(n.Elem)
 			pop()
 
+		case *ast.NamedTupleLiteral:
+			for _, f := range n.Fields {
+				walkExpr(f.Value)
+			}
+
 		case *ast.DictLiteral:
 			for _, p := range n.Pairs {
+				if p.Spread != nil {
+					walkExpr(p.Spread)
+					continue
+				}
 				if p.Shorthand != nil {
 					walkExpr(p.Shorthand)
 					continue
@@ -523,7 +571,20 @@ func CollectSemantic(program *ast.Program) map[Key]Classified {
 			walkExpr(n.Value)
 			for _, c := range n.Cases {
 				for _, val := range c.Values {
-					walkExpr(val)
+					pat, ok := val.(*ast.MatchPattern)
+					if !ok {
+						walkExpr(val)
+						continue
+					}
+					for _, id := range pat.Names() {
+						declareLocal(id)
+					}
+					for _, pv := range pat.Values() {
+						walkExpr(pv)
+					}
+				}
+				if c.Guard != nil {
+					walkExpr(c.Guard)
 				}
 				walkExpr(c.Result)
 			}
@@ -532,6 +593,9 @@ func CollectSemantic(program *ast.Program) map[Key]Classified {
 			}
 
 		case *ast.FunctionLiteral:
+			for _, d := range n.Defaults {
+				walkExpr(d)
+			}
 			push()
 			for _, p := range n.Parameters {
 				pName := identText(p)
@@ -560,27 +624,13 @@ func CollectSemantic(program *ast.Program) map[Key]Classified {
 			case *ast.Identifier:
 				name := identText(left)
 				if n.Op == token.WALRUS {
-					cur().locals[name] = true
-					mods := modDecl
-					if isAllCapsIdent(name) {
-						mods |= modReadonly
-					}
-					markIdent(left, ttVariable, mods)
+					declareLocal(left)
 				} else {
-					switch kind, ok := resolveBinding(name); {
-					case ok && kind == bindParam:
-						markIdent(left, ttParameter, 0)
-					case ok && kind == bindLocal:
-						markIdent(left, ttVariable, 0)
-					case ok && kind == bindNamespace:
-						markIdent(left, ttNamespace, 0)
+					switch kind, mods, ok := resolveBinding(name); {
+					case ok && kind != bindFunc:
+						markUse(left, kind, mods)
 					default:
-						cur().locals[name] = true
-						mods := modDecl
-						if isAllCapsIdent(name) {
-							mods |= modReadonly
-						}
-						markIdent(left, ttVariable, mods)
+						declareLocal(left)
 					}
 				}
 			case *ast.IndexExpression:
@@ -3,15 +3,18 @@ package lsp
 import (
 	"strings"
 
+	"welle/internal/ast"
 	"welle/internal/lexer"
-	"welle/internal/parser"
 	"welle/internal/token"
 )
 
 // SemanticTokensForText returns unencoded semantic tokens for the given source text.
+// The result is cached with the parse and must not be modified.
 func SemanticTokensForText(text string) []SemTok {
-	p := parser.New(lexer.New(text))
-	prog := p.ParseProgram()
+	return Parse(text).semanticTokens()
+}
+
+func semanticTokens(text string, prog *ast.Program) []SemTok {
 	classified := CollectSemantic(prog)
 
 	type posKey struct {
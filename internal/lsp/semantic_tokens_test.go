@@ -102,3 +102,37 @@ func hasToken(toks []SemTok, line, col, typ, mods int) bool {
 	}
 	return false
 }
+
+func TestSemanticTokensModifiers(t *testing.T) {
+	text := `MAX := 3
+export func clamp(x) {
+  return min(x, MAX)
+}
+export limit = len("abc")
+print(clamp(limit))
+`
+
+	toks := SemanticTokensForText(text)
+
+	if !hasToken(toks, 1, 1, ttVariable, modDecl|modReadonly) {
+		t.Fatalf("expected readonly constant declaration at 1:1")
+	}
+	if !hasToken(toks, 3, 17, ttVariable, modReadonly) {
+		t.Fatalf("expected readonly constant usage at 3:17")
+	}
+	if !hasToken(toks, 2, 13, ttFunction, modDecl|modExported) {
+		t.Fatalf("expected exported function declaration at 2:13")
+	}
+	if !hasToken(toks, 5, 8, ttVariable, modDecl|modExported) {
+		t.Fatalf("expected exported variable declaration at 5:8")
+	}
+	if !hasToken(toks, 5, 16, ttFunction, modDefaultLibrary) {
+		t.Fatalf("expected builtin token at 5:16")
+	}
+	if !hasToken(toks, 6, 1, ttFunction, modDefaultLibrary) {
+		t.Fatalf("expected builtin token at 6:1")
+	}
+	if !hasToken(toks, 6, 7, ttFunction, modExported) || !hasToken(toks, 6, 13, ttVariable, modExported) {
+		t.Fatalf("expected exported usages at 6:7 and 6:13")
+	}
+}
@@ -0,0 +1,99 @@
+package lsp
+
+import (
+	"sort"
+
+	"welle/internal/ast"
+)
+
+// dictKeysFor returns the keys a variable is known to hold when it is used
+// as a dict: the string keys of dict literals assigned to it, plus keys set
+// later with `b.key = v` or `b["key"] = v`. The pass is flow-insensitive:
+// every assignment to the binding anywhere in the file counts. Only keys
+// that can follow a `.` are returned.
+func dictKeysFor(an *Analysis, b *Binding) []string {
+	if an == nil || an.Program == nil || b == nil {
+		return nil
+	}
+	idents := map[*ast.Identifier]bool{}
+	if b.Decl != nil {
+		idents[b.Decl] = true
+	}
+	for _, ref := range an.Refs {
+		if ref.Binding == b && ref.Ident != nil {
+			idents[ref.Ident] = true
+		}
+	}
+	isB := func(e ast.Expression) bool {
+		id, ok := e.(*ast.Identifier)
+		return ok && idents[id]
+	}
+
+	keys := map[string]bool{}
+	addLiteral := func(e ast.Expression) {
+		dl, ok := e.(*ast.DictLiteral)
+		if !ok {
+			return
+		}
+		for _, p := range dl.Pairs {
+			if p.Shorthand != nil {
+				keys[identText(p.Shorthand)] = true
+			} else if s, ok := p.Key.(*ast.StringLiteral); ok {
+				keys[s.Value] = true
+			}
+		}
+	}
+	visit := func(st ast.Statement) {
+		if ex, ok := st.(*ast.ExportStatement); ok {
+			st = ex.Stmt
+		}
+		switch n := st.(type) {
+		case *ast.AssignStatement:
+			if n.Name != nil && idents[n.Name] {
+				addLiteral(n.Value)
+			}
+		case *ast.MemberAssignStatement:
+			if isB(n.Object) && n.Property != nil {
+				keys[identText(n.Property)] = true
+			}
+		case *ast.IndexAssignStatement:
+			if ie, ok := n.Left.(*ast.IndexExpression); ok && isB(ie.Left) {
+				if s, ok := ie.Index.(*ast.StringLiteral); ok {
+					keys[s.Value] = true
+				}
+			}
+		}
+	}
+	for _, st := range an.Program.Statements {
+		visit(st)
+	}
+	collectBlocks(an.Program, func(block *ast.BlockStatement) {
+		for _, st := range block.Statements {
+			visit(st)
+		}
+	})
+
+	out := make([]string, 0, len(keys))
+	for k := range keys {
+		if isIdentName(k) {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func isIdentName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -123,8 +123,21 @@ func collectCalls(node ast.Node, fn func(*ast.CallExpression)) {
 			collectCalls(a, fn)
 		}
 	case *ast.FuncStatement:
+		for _, d := range n.Defaults {
+			collectCalls(d, fn)
+		}
 		collectCalls(n.Body, fn)
+	case *ast.ClassStatement:
+		for _, d := range n.Defaults {
+			collectCalls(d, fn)
+		}
+		for _, m := range n.Methods {
+			collectCalls(m, fn)
+		}
 	case *ast.FunctionLiteral:
+		for _, d := range n.Defaults {
+			collectCalls(d, fn)
+		}
 		collectCalls(n.Body, fn)
 	case *ast.BlockStatement:
 		for _, st := range n.Statements {
@@ -149,8 +162,15 @@ func collectCalls(node ast.Node, fn func(*ast.CallExpression)) {
 		}
 	case *ast.DeferStatement:
 		collectCalls(n.Call, fn)
+	case *ast.DelStatement:
+		collectCalls(n.Target, fn)
+	case *ast.YieldStatement:
+		collectCalls(n.Value, fn)
 	case *ast.ThrowStatement:
 		collectCalls(n.Value, fn)
+	case *ast.AssertStatement:
+		collectCalls(n.Condition, fn)
+		collectCalls(n.Message, fn)
 	case *ast.IfStatement:
 		collectCalls(n.Condition, fn)
 		collectCalls(n.Consequence, fn)
@@ -183,6 +203,7 @@ func collectCalls(node ast.Node, fn func(*ast.CallExpression)) {
 			for _, v := range c.Values {
 				collectCalls(v, fn)
 			}
+			collectCalls(c.Guard, fn)
 			collectCalls(c.Result, fn)
 		}
 		collectCalls(n.Default, fn)
@@ -213,12 +234,26 @@ func collectCalls(node ast.Node, fn func(*ast.CallExpression)) {
 		for _, el := range n.Elements {
 			collectCalls(el, fn)
 		}
+	case *ast.SetLiteral:
+		for _, el := range n.Elements {
+			collectCalls(el, fn)
+		}
 	case *ast.ListComprehension:
 		collectCalls(n.Seq, fn)
 		collectCalls(n.Filter, fn)
 		collectCalls(n.Elem, fn)
+	case *ast.NamedTupleLiteral:
+		for _, f := range n.Fields {
+			collectCalls(f.Value, fn)
+		}
+	case *ast.MatchPattern:
+		collectCalls(n.Value, fn)
 	case *ast.DictLiteral:
 		for _, p := range n.Pairs {
+			if p.Spread != nil {
+				collectCalls(p.Spread, fn)
+				continue
+			}
 			if p.Shorthand != nil {
 				collectCalls(p.Shorthand, fn)
 				continue
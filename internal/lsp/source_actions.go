@@ -0,0 +1,282 @@
+package lsp
+
+import (
+	"sort"
+	"strings"
+
+	"welle/internal/ast"
+	"welle/internal/diag"
+	"welle/internal/lexer"
+	"welle/internal/lint"
+	"welle/internal/token"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// CodeActionKindSourceFixAll is the kind of the "fix all" source action.
+// LSP 3.16 has no constant for it; editors match it against
+// "source.fixAll" in their on-save settings.
+const CodeActionKindSourceFixAll = protocol.CodeActionKind("source.fixAll.welle")
+
+// FixAll applies the lint fixes that cannot change what the program does:
+// unused variables and parameters (WL0001, WL0002) get a `_` prefix.
+// Files with parse errors are returned unchanged.
+func FixAll(text string, opts lint.Options) string {
+	out, _ := FixUnused(text, opts)
+	return out
+}
+
+// FixUnused is FixAll, also returning the diagnostics it fixed.
+func FixUnused(text string, opts lint.Options) (string, []diag.Diagnostic) {
+	pd := Parse(text)
+	prog := pd.Program
+	if prog == nil || len(pd.Errors) > 0 {
+		return text, nil
+	}
+	names := identNames(text)
+	lines := splitLines(text)
+
+	type insert struct{ line, col int }
+	var inserts []insert
+	var fixed []diag.Diagnostic
+	seen := map[insert]bool{}
+	for _, d := range lint.RunWithOptions(prog, opts) {
+		if d.Code != "WL0001" && d.Code != "WL0002" {
+			continue
+		}
+		at := insert{d.Range.Line, d.Range.Col}
+		if seen[at] || at.line <= 0 || at.line > len(lines) {
+			continue
+		}
+		name := identAt(lines[at.line-1], at.col)
+		// Skip names already marked, and renames that would collide.
+		if name == "" || strings.HasPrefix(name, "_") || names["_"+name] {
+			continue
+		}
+		seen[at] = true
+		inserts = append(inserts, at)
+		fixed = append(fixed, d)
+	}
+	if len(inserts) == 0 {
+		return text, nil
+	}
+	sort.Slice(inserts, func(i, j int) bool {
+		if inserts[i].line != inserts[j].line {
+			return inserts[i].line > inserts[j].line
+		}
+		return inserts[i].col > inserts[j].col
+	})
+	for _, in := range inserts {
+		l := lines[in.line-1]
+		lines[in.line-1] = l[:in.col-1] + "_" + l[in.col-1:]
+	}
+	return strings.Join(lines, "\n"), fixed
+}
+
+// RemoveUnreachable deletes the code after a return or throw (WL0003) when
+// it fills whole lines up to the closing brace of its block, and returns
+// the diagnostics it fixed. It is not part of FixAll: fixing on save while a
+// function is being edited would delete the code below an early return.
+// Files with parse errors are returned unchanged.
+func RemoveUnreachable(text string, opts lint.Options) (string, []diag.Diagnostic) {
+	pd := Parse(text)
+	prog := pd.Program
+	if prog == nil || len(pd.Errors) > 0 {
+		return text, nil
+	}
+	var toks []token.Token
+	lx := lexer.New(text)
+	for {
+		tok := lx.NextToken()
+		if tok.Type == token.EOF {
+			break
+		}
+		toks = append(toks, tok)
+	}
+	lines := splitLines(text)
+
+	// Unreachable statements run to the end of their block, so each block
+	// loses the lines from its first one up to its closing brace.
+	from := map[int]int{}
+	var fixed []diag.Diagnostic
+	for _, d := range lint.RunWithOptions(prog, opts) {
+		if d.Code != "WL0003" || d.Range.Line <= 0 || d.Range.Line > len(lines) {
+			continue
+		}
+		if strings.TrimSpace(lines[d.Range.Line-1][:min(d.Range.Col-1, len(lines[d.Range.Line-1]))]) != "" {
+			continue
+		}
+		closeLine := closingBraceLine(toks, d.Range.Line, d.Range.Col)
+		if closeLine <= d.Range.Line || closeLine > len(lines) {
+			continue
+		}
+		if !strings.HasPrefix(strings.TrimSpace(lines[closeLine-1]), "}") {
+			continue
+		}
+		if start, ok := from[closeLine]; !ok || d.Range.Line < start {
+			from[closeLine] = d.Range.Line
+		}
+		fixed = append(fixed, d)
+	}
+	if len(fixed) == 0 {
+		return text, nil
+	}
+	drop := map[int]bool{}
+	for closeLine, start := range from {
+		for line := start; line < closeLine; line++ {
+			drop[line] = true
+		}
+	}
+	out := make([]string, 0, len(lines))
+	for i, l := range lines {
+		if !drop[i+1] {
+			out = append(out, l)
+		}
+	}
+	return strings.Join(out, "\n"), fixed
+}
+
+// closingBraceLine returns the line of the `}` that closes the block holding
+// the token at line:col, or 0 if there is none.
+func closingBraceLine(toks []token.Token, line, col int) int {
+	depth := 0
+	for _, tok := range toks {
+		if tok.Line < line || (tok.Line == line && tok.Col < col) {
+			continue
+		}
+		switch tok.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth == 0 {
+				return tok.Line
+			}
+			depth--
+		}
+	}
+	return 0
+}
+
+func identNames(text string) map[string]bool {
+	names := map[string]bool{}
+	lx := lexer.New(text)
+	for {
+		tok := lx.NextToken()
+		if tok.Type == token.EOF {
+			return names
+		}
+		if tok.Type == token.IDENT {
+			names[tok.Literal] = true
+		}
+	}
+}
+
+// identAt returns the identifier starting at the 1-based byte column col.
+func identAt(line string, col int) string {
+	if col <= 0 || col > len(line) {
+		return ""
+	}
+	rest := line[col-1:]
+	end := 0
+	for end < len(rest) {
+		c := rest[end]
+		if c == '_' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (end > 0 && c >= '0' && c <= '9') {
+			end++
+			continue
+		}
+		break
+	}
+	return rest[:end]
+}
+
+// OrganizeImports sorts each run of consecutive top-level import lines by
+// module path (`import` before `from` for the same path) and drops exact
+// duplicates. Blank lines and other statements end a run, so hand-made
+// groups are kept. Files with parse errors are returned unchanged.
+func OrganizeImports(text string) string {
+	pd := Parse(text)
+	prog := pd.Program
+	if prog == nil || len(pd.Errors) > 0 {
+		return text
+	}
+
+	type importLine struct {
+		path string
+		from bool
+	}
+	imports := map[int]importLine{}
+	perLine := map[int]int{}
+	for _, st := range prog.Statements {
+		line := statementLine(st)
+		perLine[line]++
+		switch n := st.(type) {
+		case *ast.ImportStatement:
+			if n.Path != nil {
+				imports[line] = importLine{path: n.Path.Value}
+			}
+		case *ast.FromImportStatement:
+			if n.Path != nil {
+				imports[line] = importLine{path: n.Path.Value, from: true}
+			}
+		}
+	}
+
+	lines := splitLines(text)
+	isImport := func(line int) bool {
+		_, ok := imports[line]
+		return ok && perLine[line] == 1 && line <= len(lines)
+	}
+	out := make([]string, 0, len(lines))
+	for line := 1; line <= len(lines); line++ {
+		if !isImport(line) {
+			out = append(out, lines[line-1])
+			continue
+		}
+		group := []int{line}
+		for isImport(line + 1) {
+			line++
+			group = append(group, line)
+		}
+		sort.SliceStable(group, func(i, j int) bool {
+			a, b := imports[group[i]], imports[group[j]]
+			if a.path != b.path {
+				return a.path < b.path
+			}
+			if a.from != b.from {
+				return !a.from
+			}
+			return lines[group[i]-1] < lines[group[j]-1]
+		})
+		for i, l := range group {
+			if i > 0 && lines[l-1] == lines[group[i-1]-1] {
+				continue
+			}
+			out = append(out, lines[l-1])
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func statementLine(st ast.Statement) int {
+	toks := ast.Tokens(st)
+	if len(toks) == 0 {
+		return 0
+	}
+	return toks[0].Line
+}
+
+// SourceAction wraps a whole-document rewrite as a code action of the given
+// kind, or reports false when the rewrite changes nothing.
+func SourceAction(uri string, text string, newText string, title string, kind protocol.CodeActionKind) (protocol.CodeAction, bool) {
+	if newText == text {
+		return protocol.CodeAction{}, false
+	}
+	edit := protocol.WorkspaceEdit{
+		Changes: map[protocol.DocumentUri][]protocol.TextEdit{
+			protocol.DocumentUri(uri): {
+				{Range: FullDocumentRange(text), NewText: newText},
+			},
+		},
+	}
+	return protocol.CodeAction{Title: title, Kind: &kind, Edit: &edit}, true
+}
@@ -0,0 +1,98 @@
+package lsp
+
+import (
+	"testing"
+
+	"welle/internal/lint"
+)
+
+func TestOrganizeImportsSortsEachGroup(t *testing.T) {
+	text := `// deps
+import "std:strings" as strings
+from "std:math" import add
+import "std:math" as math
+import "std:strings" as strings
+
+import "./b" as b
+import "./a" as a
+x = 1
+`
+	want := `// deps
+import "std:math" as math
+from "std:math" import add
+import "std:strings" as strings
+
+import "./a" as a
+import "./b" as b
+x = 1
+`
+	if got := OrganizeImports(text); got != want {
+		t.Fatalf("unexpected result:\n%s", got)
+	}
+	if got := OrganizeImports(want); got != want {
+		t.Fatalf("organizing twice should change nothing:\n%s", got)
+	}
+}
+
+func TestOrganizeImportsLeavesBrokenFiles(t *testing.T) {
+	text := "import \"b\" as b\nimport \"a\" as a\nx = 1 +* 2\n"
+	if got := OrganizeImports(text); got != text {
+		t.Fatalf("expected no change on parse errors, got:\n%s", got)
+	}
+}
+
+func TestFixAllPrefixesUnused(t *testing.T) {
+	text := `func f(a, b) {
+  c = 1
+  return a
+}
+func g(x) {
+  _x = 1
+  return _x
+}
+`
+	want := `func f(a, _b) {
+  _c = 1
+  return a
+}
+func g(x) {
+  _x = 1
+  return _x
+}
+`
+	if got := FixAll(text, lint.DefaultOptions()); got != want {
+		t.Fatalf("unexpected result:\n%s", got)
+	}
+}
+
+func TestRemoveUnreachableDropsLinesToBlockEnd(t *testing.T) {
+	text := `func f(a) {
+  if (a) {
+    return 1
+    print("never")
+    a = 2
+  }
+  return 0
+  print("gone")
+}
+func g() { return 1; print("same line") }
+`
+	want := `func f(a) {
+  if (a) {
+    return 1
+  }
+  return 0
+}
+func g() { return 1; print("same line") }
+`
+	got, fixed := RemoveUnreachable(text, lint.DefaultOptions())
+	if got != want {
+		t.Fatalf("unexpected result:\n%s", got)
+	}
+	if len(fixed) != 3 {
+		t.Fatalf("expected 3 fixed diagnostics, got %d: %v", len(fixed), fixed)
+	}
+	if again, more := RemoveUnreachable(got, lint.DefaultOptions()); again != got || len(more) != 0 {
+		t.Fatalf("fixing twice should change nothing:\n%s", again)
+	}
+}
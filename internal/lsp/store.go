@@ -1,6 +1,9 @@
 package lsp
 
-import "sync"
+import (
+	"sort"
+	"sync"
+)
 
 type Store struct {
 	mu   sync.RWMutex
@@ -29,3 +32,15 @@ func (s *Store) Delete(uri string) {
 	defer s.mu.Unlock()
 	delete(s.docs, uri)
 }
+
+// URIs lists the open documents in sorted order.
+func (s *Store) URIs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]string, 0, len(s.docs))
+	for uri := range s.docs {
+		out = append(out, uri)
+	}
+	sort.Strings(out)
+	return out
+}
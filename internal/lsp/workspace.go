@@ -6,9 +6,12 @@ import (
 	"strings"
 	"sync"
 
+	"welle/internal/ast"
 	"welle/internal/lexer"
 	"welle/internal/module"
 	"welle/internal/parser"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
 type Workspace struct {
@@ -29,6 +32,9 @@ type Workspace struct {
 func NewWorkspace(rootPath string) *Workspace {
 	rootAbs, _ := filepath.Abs(rootPath)
 	stdRoot := filepath.Join(rootAbs, "std")
+	if root, err := module.LocateStd("", rootAbs, stdRoot); err == nil {
+		stdRoot = root
+	}
 
 	return &Workspace{
 		rootPath:      rootAbs,
@@ -42,9 +48,7 @@ func NewWorkspace(rootPath string) *Workspace {
 }
 
 func (w *Workspace) UpdateOpenDoc(uri string, text string) (*DocIndex, error) {
-	lx := lexer.New(text)
-	p := parser.New(lx)
-	prog := p.ParseProgram()
+	prog := Parse(text).Program
 
 	ix := BuildIndex(uri, prog)
 	w.mu.Lock()
@@ -82,7 +86,7 @@ func (w *Workspace) IndexPath(absPath string) (*DocIndex, error) {
 	}
 	w.mu.RUnlock()
 
-	b, err := os.ReadFile(absPath)
+	b, err := module.ReadSource(absPath)
 	if err != nil {
 		return nil, err
 	}
@@ -103,6 +107,60 @@ func (w *Workspace) ResolveImport(fromFilePath string, spec string) (string, err
 	return w.resolver.Resolve(fromFilePath, spec)
 }
 
+// ImportDeprecations returns a lookup for lint.Options.ModuleDeprecations
+// for the module at fromPath: the functions marked @deprecated in the module
+// an import spec resolves to, read from the editor's text when it is open.
+func (w *Workspace) ImportDeprecations(fromPath string) func(spec string) map[string]string {
+	return func(spec string) map[string]string {
+		path, err := w.ResolveImport(fromPath, spec)
+		if err != nil {
+			return nil
+		}
+		w.mu.RLock()
+		text, open := w.docTextByPath[path]
+		w.mu.RUnlock()
+		if !open {
+			b, err := module.ReadSource(path)
+			if err != nil {
+				return nil
+			}
+			text = string(b)
+		}
+		return ast.Deprecations(Parse(text).Program)
+	}
+}
+
+// SetPrelude makes the module at path, usually the `prelude` of welle.toml,
+// the workspace's project prelude; "" turns it off.
+func (w *Workspace) SetPrelude(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.resolver.SetPrelude(path, w.rootPath); err != nil {
+		w.resolver.SetPrelude("", "")
+	}
+}
+
+// PreludeExports returns the path of the prelude the module at absPath,
+// whose text is text, gets and where each of its exports is defined. ok is
+// false when the module does not get one.
+func (w *Workspace) PreludeExports(absPath, text string) (string, map[string]protocol.Location, bool) {
+	if w == nil {
+		return "", nil, false
+	}
+	w.mu.RLock()
+	applies := w.resolver.PreludeApplies(absPath, text)
+	prelude := w.resolver.Prelude
+	w.mu.RUnlock()
+	if !applies {
+		return "", nil, false
+	}
+	ix, err := w.IndexPath(prelude)
+	if err != nil {
+		return "", nil, false
+	}
+	return prelude, ix.Exports, true
+}
+
 func (w *Workspace) GetIndexForURI(uri string) *DocIndex {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
@@ -121,15 +179,29 @@ func (w *Workspace) DropURI(uri string) {
 	}
 }
 
+// InvalidatePath forgets the index built from the file at path on disk, so
+// the next lookup reads it again. Open documents keep theirs: the editor's
+// buffer, not the file, is their source of truth.
+func (w *Workspace) InvalidatePath(path string) {
+	if w == nil || path == "" {
+		return
+	}
+	pthAbs, _ := filepath.Abs(path)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, open := w.docTextByPath[pthAbs]; open {
+		return
+	}
+	delete(w.byPath, pthAbs)
+}
+
 func (w *Workspace) StdModules() []string {
 	if w == nil {
 		return nil
 	}
-	entries, err := os.ReadDir(w.stdRoot)
-	if err != nil {
-		return nil
-	}
+	entries, _ := os.ReadDir(w.stdRoot)
 	out := make([]string, 0, len(entries))
+	seen := map[string]bool{}
 	for _, e := range entries {
 		if e.IsDir() {
 			continue
@@ -139,6 +211,13 @@ func (w *Workspace) StdModules() []string {
 			continue
 		}
 		out = append(out, name)
+		seen[name] = true
+	}
+	// The resolver falls back to the embedded std for anything missing.
+	for _, name := range module.EmbeddedStdModules() {
+		if !seen[name] {
+			out = append(out, name)
+		}
 	}
 	return out
 }
@@ -1,8 +1,10 @@
 package lsp
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	protocol "github.com/tliron/glsp/protocol_3_16"
@@ -167,3 +169,77 @@ func updateWorkspaceDocs(t *testing.T, ws *Workspace, paths map[string]string) {
 		}
 	}
 }
+
+func TestPreludeNamesResolveAcrossWorkspace(t *testing.T) {
+	root := t.TempDir()
+	paths := writeWorkspaceFiles(t, root, map[string]string{
+		"prelude.wll": "export func greet(name) { return name }\n",
+		"main.wll":    "x = greet(\"a\")\n",
+		"shadow.wll":  "func greet() { return 0 }\ny = greet()\n",
+		"raw.wll":     "// welle:no-prelude\nz = greet()\n",
+	})
+	ws := NewWorkspace(root)
+	ws.SetPrelude(paths["prelude.wll"])
+	updateWorkspaceDocs(t, ws, paths)
+	mainURI := PathToURI(paths["main.wll"])
+
+	clean, pos := extractPos(t, "x = gr|eet(\"a\")\n")
+	locs := PreludeDefinitionAt(ws, mainURI, clean, pos)
+	if len(locs) != 1 || string(locs[0].URI) != PathToURI(paths["prelude.wll"]) || locs[0].Range.Start.Character != 12 {
+		t.Fatalf("definition = %v", locs)
+	}
+
+	hover, err := HoverAt(ws, mainURI, clean, pos)
+	if err != nil || hover == nil {
+		t.Fatalf("expected hover, err=%v", err)
+	}
+	if got := hover.Contents.(protocol.MarkupContent).Value; got != "prelude: greet\ngreet(name)" {
+		t.Fatalf("hover = %q", got)
+	}
+
+	refs, err := ReferencesAt(ws, mainURI, clean, pos, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, loc := range refs {
+		seen[string(loc.URI)] = true
+	}
+	if len(refs) != 2 || !seen[mainURI] || !seen[PathToURI(paths["prelude.wll"])] {
+		t.Fatalf("references = %v", refs)
+	}
+
+	items := CompletionItems(ws, mainURI, "x = \n", protocol.Position{Line: 0, Character: 4})
+	found := false
+	for _, it := range items {
+		found = found || it.Label == "greet"
+	}
+	if !found {
+		t.Fatal("expected greet among completions")
+	}
+}
+
+func TestWorkspaceSymbols(t *testing.T) {
+	root := t.TempDir()
+	paths := writeWorkspaceFiles(t, root, map[string]string{
+		"users.wll": "export func getUser(id) { return id }\nclass Group {}\n",
+		"main.wll":  "import \"./users\" as users\nfrom \"./users\" import getUser\nlimit = 3\n",
+	})
+	ws := NewWorkspace(root)
+	// An unsaved edit wins over the file on disk.
+	if _, err := ws.UpdateOpenDoc(PathToURI(paths["main.wll"]), "import \"./users\" as users\ntotal = 1\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, s := range WorkspaceSymbols(ws, "gu") {
+		got = append(got, fmt.Sprintf("%s %s:%d", s.Name, *s.ContainerName, s.Location.Range.Start.Line))
+	}
+	want := []string{"getUser users:0", "Group users:1"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("symbols = %v, want %v", got, want)
+	}
+	if all := WorkspaceSymbols(ws, ""); len(all) != 3 {
+		t.Fatalf("empty query found %d symbols, want getUser, Group and total", len(all))
+	}
+}
@@ -2,10 +2,10 @@ package lsp
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 
 	"welle/internal/ast"
+	"welle/internal/module"
 
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -59,14 +59,38 @@ type importNameOccurrence struct {
 
 func BuildWorkspaceIndex(ws *Workspace) (*WorkspaceIndex, error) {
 	idx := &WorkspaceIndex{ByKey: map[SymbolKey][]Occurrence{}}
+	docs, err := resolveWorkspaceDocs(ws)
+	for _, doc := range docs {
+		for key, occs := range doc.syms.occ {
+			idx.ByKey[key] = append(idx.ByKey[key], occs...)
+		}
+	}
+	return idx, err
+}
+
+type workspaceDoc struct {
+	path string // absolute
+	uri  string
+	text string
+	syms *docSymbols
+}
+
+// resolveWorkspaceDocs analyzes every workspace file plus any open
+// documents outside it, preferring unsaved editor text over disk.
+func resolveWorkspaceDocs(ws *Workspace) ([]workspaceDoc, error) {
 	if ws == nil {
-		return idx, nil
+		return nil, nil
 	}
 	files, err := ws.WorkspaceFiles()
 	if err != nil {
-		return idx, err
+		return nil, err
 	}
 	seen := map[string]bool{}
+	for _, pth := range files {
+		if abs, err := filepath.Abs(pth); err == nil {
+			seen[abs] = true
+		}
+	}
 	addFile := func(path string) {
 		if path == "" || seen[path] {
 			return
@@ -78,31 +102,45 @@ func BuildWorkspaceIndex(ws *Workspace) (*WorkspaceIndex, error) {
 		addFile(pth)
 	}
 
+	var docs []workspaceDoc
 	for _, pth := range files {
 		absPath, _ := filepath.Abs(pth)
 		uri := PathToURI(absPath)
 		text, ok := ws.TextForPath(absPath)
 		if !ok {
-			b, err := os.ReadFile(absPath)
+			b, err := module.ReadSource(absPath)
 			if err != nil {
 				continue
 			}
 			text = string(b)
 		}
-		docOcc := buildDocOccurrences(ws, uri, absPath, text)
-		for key, occs := range docOcc {
-			idx.ByKey[key] = append(idx.ByKey[key], occs...)
-		}
+		docs = append(docs, workspaceDoc{
+			path: absPath,
+			uri:  uri,
+			text: text,
+			syms: resolveDocSymbols(ws, uri, absPath, text),
+		})
 	}
-	return idx, nil
+	return docs, nil
+}
+
+// docSymbols is the per-file result of resolving names to workspace-wide
+// symbol keys.
+type docSymbols struct {
+	analysis *Analysis
+	occ      map[SymbolKey][]Occurrence
+	keys     map[*ast.Identifier]SymbolKey // declarations and references
+	decls    map[SymbolKey]*Binding
 }
 
-func buildDocOccurrences(ws *Workspace, uri string, absPath string, text string) map[SymbolKey][]Occurrence {
+func resolveDocSymbols(ws *Workspace, uri string, absPath string, text string) *docSymbols {
 	out := map[SymbolKey][]Occurrence{}
+	ds := &docSymbols{occ: out, keys: map[*ast.Identifier]SymbolKey{}, decls: map[SymbolKey]*Binding{}}
 	an, _ := Analyze(text)
 	if an == nil || an.Program == nil {
-		return out
+		return ds
 	}
+	ds.analysis = an
 
 	exports := exportedNames(an.Program)
 	importInfo, importNames := collectFromImportInfo(an.Program)
@@ -177,6 +215,10 @@ func buildDocOccurrences(ws *Workspace, uri string, absPath string, text string)
 		}
 		bindingKeys[b] = key
 		if b.Decl != nil {
+			ds.keys[b.Decl] = key
+			if _, ok := ds.decls[key]; !ok {
+				ds.decls[key] = b
+			}
 			r := rangeFromPosLenUTF16(text, b.Decl.Token.Line, b.Decl.Token.Col, identText(b.Decl))
 			addOcc(key, Occurrence{URI: uri, Range: r, Kind: OccurrenceDecl})
 		}
@@ -193,6 +235,7 @@ func buildDocOccurrences(ws *Workspace, uri string, absPath string, text string)
 				continue
 			}
 			key := SymbolKey{Kind: SymKeyExport, ModulePath: resolved, Name: r.Member}
+			ds.keys[r.Ident] = key
 			rng := rangeFromPosLenUTF16(text, r.Ident.Token.Line, r.Ident.Token.Col, identText(r.Ident))
 			addOcc(key, Occurrence{URI: uri, Range: rng, Kind: OccurrenceRef})
 		case SymBuiltin, SymKeyword:
@@ -217,12 +260,26 @@ func buildDocOccurrences(ws *Workspace, uri string, absPath string, text string)
 					kind = OccurrenceAliasUse
 				}
 			}
+			ds.keys[r.Ident] = key
 			rng := rangeFromPosLenUTF16(text, r.Ident.Token.Line, r.Ident.Token.Col, identText(r.Ident))
 			addOcc(key, Occurrence{URI: uri, Range: rng, Kind: kind})
 		}
 	}
 
-	return out
+	if prelude, names, ok := ws.PreludeExports(absPath, text); ok {
+		for _, id := range an.Free {
+			name := identText(id)
+			if _, exported := names[name]; !exported {
+				continue
+			}
+			key := SymbolKey{Kind: SymKeyExport, ModulePath: prelude, Name: name}
+			ds.keys[id] = key
+			rng := rangeFromPosLenUTF16(text, id.Token.Line, id.Token.Col, name)
+			addOcc(key, Occurrence{URI: uri, Range: rng, Kind: OccurrenceRef})
+		}
+	}
+
+	return ds
 }
 
 func keyForBinding(uri string, modulePath string, exports map[string]bool, root *Scope, b *Binding) (SymbolKey, bool) {
@@ -270,6 +327,10 @@ func exportedNames(prog *ast.Program) map[string]bool {
 			if inner.Name != nil {
 				out[identText(inner.Name)] = true
 			}
+		case *ast.ClassStatement:
+			if inner.Name != nil {
+				out[identText(inner.Name)] = true
+			}
 		case *ast.AssignStatement:
 			if inner.Name != nil {
 				out[identText(inner.Name)] = true
@@ -0,0 +1,80 @@
+package lsp
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// WorkspaceSymbols returns the top-level functions, classes and variables
+// of every .wll file in the workspace and every open document whose name
+// matches query, for workspace/symbol. A name matches when it contains the
+// letters of query in order, ignoring case, so "gu" finds getUser; an empty
+// query matches everything. Imports are left out: they name another file's
+// symbol, which is listed under that file.
+func WorkspaceSymbols(ws *Workspace, query string) []protocol.SymbolInformation {
+	if ws == nil {
+		return nil
+	}
+	files, err := ws.WorkspaceFiles()
+	if err != nil {
+		files = nil
+	}
+	files = append(files, ws.OpenDocPaths()...)
+
+	out := []protocol.SymbolInformation{}
+	seen := map[string]bool{}
+	for _, path := range files {
+		abs, _ := filepath.Abs(path)
+		if seen[abs] {
+			continue
+		}
+		seen[abs] = true
+		ix, err := ws.IndexPath(abs)
+		if err != nil {
+			continue
+		}
+		container := strings.TrimSuffix(filepath.Base(abs), ".wll")
+		for _, sym := range ix.Symbols {
+			if sym.Kind == protocol.SymbolKindNamespace || !fuzzyMatch(sym.Name, query) {
+				continue
+			}
+			out = append(out, protocol.SymbolInformation{
+				Name:          sym.Name,
+				Kind:          sym.Kind,
+				Location:      protocol.Location{URI: protocol.DocumentUri(PathToURI(abs)), Range: sym.SelectionRange},
+				ContainerName: &container,
+			})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if la, lb := strings.ToLower(a.Name), strings.ToLower(b.Name); la != lb {
+			return la < lb
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Location.URI != b.Location.URI {
+			return a.Location.URI < b.Location.URI
+		}
+		return a.Location.Range.Start.Line < b.Location.Range.Start.Line
+	})
+	return out
+}
+
+// fuzzyMatch reports whether name contains the letters of query in order,
+// ignoring case.
+func fuzzyMatch(name, query string) bool {
+	name, query = strings.ToLower(name), strings.ToLower(query)
+	for _, r := range query {
+		i := strings.IndexRune(name, r)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(string(r)):]
+	}
+	return true
+}
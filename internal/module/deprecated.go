@@ -0,0 +1,27 @@
+package module
+
+import "welle/internal/ast"
+
+// ImportDeprecations returns a lookup for lint.Options.ModuleDeprecations:
+// for an import spec of the module at fromFile, the functions marked
+// @deprecated in the module it resolves to. Modules that cannot be found or
+// parsed have none.
+func (r *Resolver) ImportDeprecations(fromFile string) func(spec string) map[string]string {
+	byPath := map[string]map[string]string{}
+	return func(spec string) map[string]string {
+		path, err := r.Resolve(fromFile, spec)
+		if err != nil {
+			return nil
+		}
+		if deps, ok := byPath[path]; ok {
+			return deps
+		}
+		var deps map[string]string
+		if src, err := ReadSource(path); err == nil {
+			p := r.NewParser(path, string(src))
+			deps = ast.Deprecations(p.ParseProgram())
+		}
+		byPath[path] = deps
+		return deps
+	}
+}
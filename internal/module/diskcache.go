@@ -0,0 +1,158 @@
+package module
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+
+	"welle/internal/compiler"
+	"welle/internal/diag"
+	"welle/internal/parser"
+)
+
+// CacheEnv names the environment variable that turns the bytecode cache
+// off when set to "off".
+const CacheEnv = "WELLE_CACHE"
+
+// DiskCache keeps compiled module bytecode between runs. Entries are keyed
+// by the module's path and source, the compile options, the bytecode
+// format and the welle build that compiled them, so an edited file or a new
+// toolchain simply misses.
+type DiskCache struct {
+	Dir string
+}
+
+type cacheEntry struct {
+	Bytecode []byte
+	Warnings []diag.Diagnostic
+}
+
+// CacheDir returns where DefaultDiskCache keeps its entries.
+func CacheDir() (string, error) {
+	home, err := Home()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "cache", "bytecode"), nil
+}
+
+// DefaultDiskCache returns the cache under the welle home directory, or
+// nil when it is disabled or there is no home directory.
+func DefaultDiskCache() *DiskCache {
+	if os.Getenv(CacheEnv) == "off" {
+		return nil
+	}
+	dir, err := CacheDir()
+	if err != nil {
+		return nil
+	}
+	return &DiskCache{Dir: dir}
+}
+
+func (c *DiskCache) key(path string, src []byte, optimize, release bool, features []string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%t\x00%t\x00", compiler.BytecodeVersion, toolchainID(), path, optimize, release)
+	sorted := slices.Sorted(slices.Values(features))
+	fmt.Fprintf(h, "%s\x00", strings.Join(sorted, ","))
+	// A source is only reused under the parser limits it was parsed with,
+	// so lowering them rejects it again rather than loading the cache.
+	lim := parser.CurrentLimits()
+	fmt.Fprintf(h, "%d\x00%d\x00", lim.MaxDepth, lim.MaxSourceBytes)
+	h.Write(src)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *DiskCache) file(key string) string {
+	return filepath.Join(c.Dir, key[:2], key+".wbc")
+}
+
+// Load returns the cached bytecode and compiler warnings for key. A missing
+// or unreadable entry is a miss.
+func (c *DiskCache) Load(key string) (*compiler.Bytecode, []diag.Diagnostic, bool) {
+	b, err := os.ReadFile(c.file(key))
+	if err != nil {
+		return nil, nil, false
+	}
+	var e cacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&e); err != nil {
+		return nil, nil, false
+	}
+	bc, err := compiler.DecodeBytecode(e.Bytecode)
+	if err != nil {
+		return nil, nil, false
+	}
+	return bc, e.Warnings, true
+}
+
+// Store saves bc under key. Failures are ignored: the cache only ever
+// saves work.
+func (c *DiskCache) Store(key string, bc *compiler.Bytecode, warnings []diag.Diagnostic) {
+	enc, err := compiler.EncodeBytecode(bc)
+	if err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(cacheEntry{Bytecode: enc, Warnings: warnings}); err != nil {
+		return
+	}
+	dst := c.file(key)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".wbc-*")
+	if err != nil {
+		return
+	}
+	_, werr := tmp.Write(buf.Bytes())
+	cerr := tmp.Close()
+	if werr != nil || cerr != nil || os.Rename(tmp.Name(), dst) != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// Clean removes every cached entry.
+func (c *DiskCache) Clean() error {
+	return os.RemoveAll(c.Dir)
+}
+
+var toolchain struct {
+	once sync.Once
+	id   string
+}
+
+// toolchainID identifies the running welle build: its VCS revision when it
+// was built from a clean checkout, otherwise the executable's size and
+// modification time.
+func toolchainID() string {
+	toolchain.once.Do(func() {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			rev, modified := "", false
+			for _, s := range info.Settings {
+				switch s.Key {
+				case "vcs.revision":
+					rev = s.Value
+				case "vcs.modified":
+					modified = s.Value == "true"
+				}
+			}
+			if rev != "" && !modified {
+				toolchain.id = rev
+				return
+			}
+		}
+		if exe, err := os.Executable(); err == nil {
+			if fi, err := os.Stat(exe); err == nil {
+				toolchain.id = fmt.Sprintf("%d-%d", fi.Size(), fi.ModTime().UnixNano())
+			}
+		}
+	})
+	return toolchain.id
+}
@@ -0,0 +1,45 @@
+package module
+
+import (
+	"path/filepath"
+
+	"welle/internal/lexer"
+	"welle/internal/parser"
+)
+
+// SetEdition makes e the edition of the modules under root that have no
+// #welle pragma of their own, as with `edition` in welle.toml. Modules in
+// std always get the latest edition. The zero Edition turns it off.
+func (r *Resolver) SetEdition(e parser.Edition, root string) error {
+	if e == (parser.Edition{}) {
+		r.Edition, r.EditionRoot = parser.Edition{}, ""
+		return nil
+	}
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	r.Edition, r.EditionRoot = e, rootAbs
+	return nil
+}
+
+// EditionFor returns the project edition of the module at path, or the zero
+// Edition when the project sets none or the module is outside it.
+func (r *Resolver) EditionFor(path string) parser.Edition {
+	if r == nil || r.EditionRoot == "" || path == "" {
+		return parser.Edition{}
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil || !within(abs, r.EditionRoot) || (r.StdRoot != "" && within(abs, r.StdRoot)) {
+		return parser.Edition{}
+	}
+	return r.Edition
+}
+
+// NewParser returns a parser for src, the source of the module at path,
+// that applies the module's project edition.
+func (r *Resolver) NewParser(path, src string) *parser.Parser {
+	p := parser.New(lexer.New(src))
+	p.SetEdition(r.EditionFor(path))
+	return p
+}
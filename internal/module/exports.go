@@ -44,6 +44,10 @@ func exportName(exp *ast.ExportStatement) (string, token.Token, bool) {
 		if s.Name != nil {
 			return s.Name.Value, s.Name.Token, true
 		}
+	case *ast.ClassStatement:
+		if s.Name != nil {
+			return s.Name.Value, s.Name.Token, true
+		}
 	}
 	return "", token.Token{}, false
 }
@@ -1,12 +1,18 @@
 package module
 
 import (
+	"errors"
 	"fmt"
-	"os"
 	"strings"
+	"time"
 
+	"welle/internal/ast"
+	"welle/internal/code"
 	"welle/internal/compiler"
+	"welle/internal/diag"
 	"welle/internal/lexer"
+	"welle/internal/limits"
+	"welle/internal/object"
 	"welle/internal/parser"
 	"welle/internal/vm"
 )
@@ -16,6 +22,53 @@ type Loader struct {
 	Cache     map[string]*compiler.Bytecode // key: abs path
 	loadStack []string
 	loadIndex map[string]int
+
+	// OnWarning, when set, receives compiler warnings for every module the
+	// loader compiles, including ones imported while the VM is running.
+	OnWarning func(path string, d diag.Diagnostic)
+
+	// Release compiles assert statements to nothing.
+	Release bool
+
+	// Features are the build features BUILD.<name> reports as enabled.
+	Features []string
+
+	// DiskCache, when set, reuses bytecode compiled by earlier runs.
+	DiskCache *DiskCache
+
+	// Stats, when set, records how long each module took to load.
+	Stats *limits.Stats
+
+	// MaxStack, when set, is the VM's value stack size: LoadProgram sends
+	// a program with a function needing more than that to the interpreter.
+	MaxStack int
+}
+
+// BuildError is a module that parsed but that the compiler rejected, or
+// whose bytecode failed optimization or verification.
+type BuildError struct {
+	Path string
+	// Stage is "compile", "optimize" or "verify".
+	Stage string
+	Err   error
+}
+
+func (e *BuildError) Error() string {
+	return fmt.Sprintf("%s in %s: %v", e.stageName(), e.Path, e.Err)
+}
+
+func (e *BuildError) Unwrap() error { return e.Err }
+
+// Short describes the error without the module's path.
+func (e *BuildError) Short() string {
+	return e.stageName() + ": " + e.Err.Error()
+}
+
+func (e *BuildError) stageName() string {
+	if e.Stage == "verify" {
+		return "bytecode verification failed"
+	}
+	return e.Stage + " error"
 }
 
 func NewLoader(res *Resolver) *Loader {
@@ -52,16 +105,59 @@ func (l *Loader) LoadBytecode(fromFile, spec string, optimize bool) (*compiler.B
 		}
 	}()
 
-	src, err := os.ReadFile(path)
+	start := time.Now()
+	src, err := ReadSource(path)
 	if err != nil {
 		return nil, "", err
 	}
 
-	lex := lexer.New(string(src))
-	p := parser.New(lex)
-	prog := p.ParseProgram()
-	if len(p.Errors()) > 0 {
-		return nil, "", fmt.Errorf("parse error in %s:\n%v", path, p.Errors())
+	if !optimize {
+		if bc, warnings, ok := loadPrecompiled(path, src, l.Release, len(l.Features) > 0); ok {
+			l.warn(path, warnings)
+			l.Stats.AddLoad(path, time.Since(start))
+			l.Cache[path] = bc
+			return bc, path, nil
+		}
+	}
+
+	// A module that gets the prelude is parsed before the cache lookup: the
+	// import it gets follows the prelude's exports, so it is part of the key.
+	var prog *ast.Program
+	var prelude *ast.FromImportStatement
+	if l.Resolver.PreludeApplies(path, string(src)) {
+		if prog, err = parseModule(l.Resolver, path, src); err != nil {
+			return nil, "", err
+		}
+		if prelude, err = l.Resolver.PreludeImport(path, string(src), prog); err != nil {
+			return nil, "", err
+		}
+	}
+
+	cacheKey := ""
+	if l.DiskCache != nil {
+		keySrc := src
+		if prelude != nil {
+			keySrc = append([]byte(prelude.String()+"\n"), src...)
+		}
+		if e := l.Resolver.EditionFor(path); e != (parser.Edition{}) {
+			keySrc = append([]byte("#welle "+e.String()+"\n"), keySrc...)
+		}
+		cacheKey = l.DiskCache.key(path, keySrc, optimize, l.Release, l.Features)
+		if bc, warnings, ok := l.DiskCache.Load(cacheKey); ok && compiler.Verify(bc) == nil {
+			l.warn(path, warnings)
+			l.Stats.AddLoad(path, time.Since(start))
+			l.Cache[path] = bc
+			return bc, path, nil
+		}
+	}
+
+	if prog == nil {
+		if prog, err = parseModule(l.Resolver, path, src); err != nil {
+			return nil, "", err
+		}
+	}
+	if prelude != nil {
+		prog.Statements = append([]ast.Statement{prelude}, prog.Statements...)
 	}
 
 	if err := CheckDuplicateExports(prog, path); err != nil {
@@ -69,9 +165,12 @@ func (l *Loader) LoadBytecode(fromFile, spec string, optimize bool) (*compiler.B
 	}
 
 	c := compiler.NewWithFile(path)
+	c.SetRelease(l.Release)
+	c.SetFeatures(l.Features)
 	if err := c.Compile(prog); err != nil {
-		return nil, "", fmt.Errorf("compile error in %s: %v", path, err)
+		return nil, "", &BuildError{Path: path, Stage: "compile", Err: err}
 	}
+	warnings := c.Warnings()
 	bc := c.Bytecode()
 
 	if optimize {
@@ -79,14 +178,161 @@ func (l *Loader) LoadBytecode(fromFile, spec string, optimize bool) (*compiler.B
 		var err error
 		bc, err = opt.Optimize(bc)
 		if err != nil {
-			return nil, "", fmt.Errorf("optimize error in %s: %v", path, err)
+			return nil, "", &BuildError{Path: path, Stage: "optimize", Err: err}
 		}
+		warnings = append(warnings, opt.Warnings...)
+	}
+	l.warn(path, warnings)
+	if err := compiler.Verify(bc); err != nil {
+		return nil, "", &BuildError{Path: path, Stage: "verify", Err: err}
+	}
+	if l.DiskCache != nil {
+		l.DiskCache.Store(cacheKey, bc, warnings)
 	}
 
+	l.Stats.AddLoad(path, time.Since(start))
 	l.Cache[path] = bc
 	return bc, path, nil
 }
 
+func parseModule(r *Resolver, path string, src []byte) (*ast.Program, error) {
+	p := r.NewParser(path, string(src))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, fmt.Errorf("parse error in %s:\n%v", path, p.Errors())
+	}
+	return prog, nil
+}
+
+// LoadProgram is LoadBytecode for a program that may still fall back to the
+// interpreter: it also compiles every module the program imports, directly
+// or through other modules. If any of them (or the entry) needs the
+// interpreter, because it uses a construct the compiler does not support,
+// fails to compile or verify, or needs more value stack than MaxStack, it
+// returns a *compiler.UnsupportedError listing all of them. Parse and
+// resolve errors in imported modules are left to be raised when the import
+// runs, since it may never run.
+func (l *Loader) LoadProgram(fromFile, spec string, optimize bool) (*compiler.Bytecode, string, error) {
+	path, err := l.Resolver.Resolve(fromFile, spec)
+	if err != nil {
+		return nil, "", err
+	}
+	all := &compiler.UnsupportedError{}
+	// needsInterpreter adds what keeps the module at path off the VM to
+	// all, and reports whether err was such a problem.
+	needsInterpreter := func(path string, bc *compiler.Bytecode, err error) bool {
+		var unsupported *compiler.UnsupportedError
+		var build *BuildError
+		switch {
+		case errors.As(err, &unsupported):
+			all.Constructs = append(all.Constructs, unsupported.Constructs...)
+		case errors.As(err, &build):
+			all.Constructs = append(all.Constructs, compiler.Unsupported{File: path, Construct: build.Short(), Message: build.Error()})
+		case err != nil:
+			return false
+		case l.MaxStack > 0:
+			if fn, n := compiler.StackHeight(bc); n > l.MaxStack {
+				msg := fmt.Sprintf("%s needs %d value stack slots, more than the VM's %d", fn, n, l.MaxStack)
+				all.Constructs = append(all.Constructs, compiler.Unsupported{File: path, Construct: msg, Message: msg})
+			}
+		}
+		return true
+	}
+
+	bc, _, err := l.LoadBytecode(fromFile, spec, optimize)
+	if !needsInterpreter(path, bc, err) {
+		return nil, "", err
+	}
+
+	seen := map[string]bool{path: true}
+	var visit func(path string, bc *compiler.Bytecode)
+	visit = func(path string, bc *compiler.Bytecode) {
+		for _, dep := range moduleImports(path, bc) {
+			depPath, err := l.Resolver.Resolve(path, dep)
+			if err != nil || seen[depPath] {
+				continue
+			}
+			seen[depPath] = true
+			depBC, _, err := l.LoadBytecode(path, dep, false)
+			if needsInterpreter(depPath, depBC, err) {
+				visit(depPath, depBC)
+			}
+		}
+	}
+	visit(path, bc)
+	if len(all.Constructs) > 0 {
+		return nil, "", all
+	}
+	return bc, path, nil
+}
+
+// moduleImports returns the module specs the module at path imports, read
+// from bc or, when it did not compile, from its source.
+func moduleImports(path string, bc *compiler.Bytecode) []string {
+	if bc != nil {
+		return importSpecs(bc)
+	}
+	src, err := ReadSource(path)
+	if err != nil {
+		return nil
+	}
+	var specs []string
+	var walk func(n any)
+	walk = func(n any) {
+		switch n := n.(type) {
+		case *ast.ImportStatement:
+			specs = append(specs, n.Path.Value)
+		case *ast.FromImportStatement:
+			specs = append(specs, n.Path.Value)
+		}
+		for _, child := range ast.Children(n) {
+			walk(child)
+		}
+	}
+	walk(parser.New(lexer.New(string(src))).ParseProgram())
+	return specs
+}
+
+// importSpecs returns the module specs bc imports, in its top-level code
+// and in the functions it defines, in the order they appear.
+func importSpecs(bc *compiler.Bytecode) []string {
+	var specs []string
+	seen := map[string]bool{}
+	scan := func(ins code.Instructions) {
+		for ip := 0; ip < len(ins); {
+			def, ok := code.Lookup(code.Opcode(ins[ip]))
+			if !ok {
+				return
+			}
+			operands, n := code.ReadOperands(def, ins[ip+1:])
+			op := code.Opcode(ins[ip])
+			if op == code.OpImportModule || op == code.OpImportFrom {
+				if s, ok := bc.Constants[operands[0]].(*object.String); ok && !seen[s.Value] {
+					seen[s.Value] = true
+					specs = append(specs, s.Value)
+				}
+			}
+			ip += 1 + n
+		}
+	}
+	scan(bc.Instructions)
+	for _, c := range bc.Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok {
+			scan(fn.Instructions)
+		}
+	}
+	return specs
+}
+
+func (l *Loader) warn(path string, ds []diag.Diagnostic) {
+	if l.OnWarning == nil {
+		return
+	}
+	for _, d := range ds {
+		l.OnWarning(path, d)
+	}
+}
+
 // Create a VM that can import using this loader.
 func (l *Loader) NewVM(entry *compiler.Bytecode, entryPath string) *vm.VM {
 	importer := func(fromPath, spec string) (*compiler.Bytecode, string, error) {
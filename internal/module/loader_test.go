@@ -7,6 +7,8 @@ import (
 	"testing"
 
 	"os"
+
+	"welle/internal/diag"
 )
 
 func TestResolveMissingModuleError(t *testing.T) {
@@ -49,3 +51,69 @@ func TestDuplicateExportError(t *testing.T) {
 		t.Fatalf("expected locations in error, got: %s", err.Error())
 	}
 }
+
+func TestLoaderReportsCompileWarnings(t *testing.T) {
+	tmp := t.TempDir()
+	modPath := filepath.Join(tmp, "warn.wll")
+	src := "func f(c) {\n  if (c) { x = 1 }\n  return x\n}\n"
+	if err := os.WriteFile(modPath, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loader := NewLoader(NewResolver(tmp, nil))
+	var got []string
+	loader.OnWarning = func(path string, d diag.Diagnostic) {
+		got = append(got, d.Format(filepath.Base(path)))
+	}
+	if _, _, err := loader.LoadBytecode(modPath, modPath, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "warn.wll:3:10: warning WL0013: 'x' may be used before assignment"
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("expected [%s], got %v", want, got)
+	}
+}
+
+func TestLoaderDiskCache(t *testing.T) {
+	tmp := t.TempDir()
+	modPath := filepath.Join(tmp, "warn.wll")
+	src := "func f(c) {\n  if (c) { x = 1 }\n  return x\n}\n"
+	if err := os.WriteFile(modPath, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cache := &DiskCache{Dir: filepath.Join(tmp, "cache")}
+
+	load := func() (string, int) {
+		loader := NewLoader(NewResolver(tmp, nil))
+		loader.DiskCache = cache
+		warnings := 0
+		loader.OnWarning = func(string, diag.Diagnostic) { warnings++ }
+		bc, _, err := loader.LoadBytecode(modPath, modPath, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return bc.Instructions.String(), warnings
+	}
+
+	first, warnings := load()
+	entries, _ := filepath.Glob(filepath.Join(cache.Dir, "*", "*.wbc"))
+	if len(entries) != 1 || warnings != 1 {
+		t.Fatalf("expected one cache entry and one warning, got %d and %d", len(entries), warnings)
+	}
+	second, warnings := load()
+	if second != first || warnings != 1 {
+		t.Fatalf("cached load differs (warnings %d):\n%s\nwant:\n%s", warnings, second, first)
+	}
+
+	if err := os.WriteFile(modPath, []byte("x = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if third, _ := load(); third == first {
+		t.Fatalf("edited module was served from the cache")
+	}
+	if err := cache.Clean(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cache.Dir); !os.IsNotExist(err) {
+		t.Fatalf("expected the cache directory to be removed")
+	}
+}
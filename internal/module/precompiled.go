@@ -0,0 +1,116 @@
+package module
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"welle/internal/compiler"
+	"welle/internal/diag"
+	"welle/internal/lexer"
+	"welle/internal/object"
+	"welle/internal/parser"
+	"welle/std"
+)
+
+// precompiledEntry is one std module compiled ahead of time by
+// `go generate ./std` and embedded in the binary.
+type precompiledEntry struct {
+	Source      string // sha256 of the source it was compiled from
+	File        string // path recorded in the bytecode, replaced on load
+	ReleaseSafe bool   // -release would compile it the same way
+	FeatureFree bool   // it reads no BUILD flag, so features do not change it
+	Bytecode    []byte
+	Warnings    []diag.Diagnostic
+}
+
+// PrecompileStdModule compiles the std module file (such as "math.wll")
+// into the form embedded under std/compiled.
+func PrecompileStdModule(file string, src []byte) ([]byte, error) {
+	stdFile := "std/" + file
+	featureFree := true
+	compile := func(release bool) ([]byte, []diag.Diagnostic, error) {
+		p := parser.New(lexer.New(string(src)))
+		prog := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			return nil, nil, fmt.Errorf("parse error in %s:\n%v", file, p.Errors())
+		}
+		c := compiler.NewWithFile(stdFile)
+		c.SetRelease(release)
+		if err := c.Compile(prog); err != nil {
+			return nil, nil, fmt.Errorf("compile error in %s: %v", file, err)
+		}
+		featureFree = featureFree && !c.UsesFeatures()
+		bc := c.Bytecode()
+		if err := compiler.Verify(bc); err != nil {
+			return nil, nil, fmt.Errorf("bytecode verification failed in %s: %v", file, err)
+		}
+		enc, err := compiler.EncodeBytecode(bc)
+		return enc, c.Warnings(), err
+	}
+	enc, warnings, err := compile(false)
+	if err != nil {
+		return nil, err
+	}
+	rel, _, err := compile(true)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(src)
+	e := precompiledEntry{
+		Source:      hex.EncodeToString(sum[:]),
+		File:        stdFile,
+		ReleaseSafe: bytes.Equal(enc, rel),
+		FeatureFree: featureFree,
+		Bytecode:    enc,
+		Warnings:    warnings,
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&e); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// loadPrecompiled returns the embedded bytecode for the module at path when
+// one was compiled from exactly src, so std imports skip lexing, parsing
+// and compiling. Entries are only decoded when first imported. An entry
+// that reads a BUILD flag is only used when no features are enabled.
+func loadPrecompiled(path string, src []byte, release, features bool) (*compiler.Bytecode, []diag.Diagnostic, bool) {
+	name := filepath.Base(path)
+	b, err := fs.ReadFile(std.Compiled, "compiled/"+strings.TrimSuffix(name, ".wll")+".wbc")
+	if err != nil {
+		return nil, nil, false
+	}
+	var e precompiledEntry
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&e); err != nil {
+		return nil, nil, false
+	}
+	sum := sha256.Sum256(src)
+	if e.Source != hex.EncodeToString(sum[:]) || (release && !e.ReleaseSafe) || (features && !e.FeatureFree) {
+		return nil, nil, false
+	}
+	bc, err := compiler.DecodeBytecode(e.Bytecode)
+	if err != nil {
+		return nil, nil, false
+	}
+	relocate(bc, e.File, path)
+	return bc, e.Warnings, true
+}
+
+// relocate points the file names recorded in bc at path.
+func relocate(bc *compiler.Bytecode, from, path string) {
+	if bc.Debug.File == from {
+		bc.Debug.File = path
+	}
+	for _, c := range bc.Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok && fn.File == from {
+			fn.File = path
+		}
+	}
+}
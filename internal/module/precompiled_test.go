@@ -0,0 +1,86 @@
+package module
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"welle/internal/compiler"
+	"welle/std"
+)
+
+// TestPrecompiledStdIsCurrent fails when std/compiled no longer matches the
+// std sources or the compiler; run `go generate ./std` to refresh it.
+func TestPrecompiledStdIsCurrent(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "std", "*.wll"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no std sources found (%v)", err)
+	}
+	for _, path := range files {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := filepath.Base(path)
+		want, err := PrecompileStdModule(name, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := fs.ReadFile(std.Compiled, "compiled/"+strings.TrimSuffix(name, ".wll")+".wbc")
+		// gob numbers types per process, so compare what the entries hold
+		// rather than their bytes.
+		if err != nil || !reflect.DeepEqual(decodeEntry(t, got), decodeEntry(t, want)) {
+			t.Errorf("std/compiled is stale for %s; run go generate ./std", name)
+		}
+	}
+}
+
+func decodeEntry(t *testing.T, b []byte) any {
+	t.Helper()
+	var e precompiledEntry
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&e); err != nil {
+		return err.Error()
+	}
+	bc, err := compiler.DecodeBytecode(e.Bytecode)
+	if err != nil {
+		return err.Error()
+	}
+	e.Bytecode = nil
+	return []any{e, bc}
+}
+
+func TestLoaderUsesPrecompiledStd(t *testing.T) {
+	stdRoot, err := filepath.Abs(filepath.Join("..", "..", "std"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.ReadFile(filepath.Join(stdRoot, "math.wll"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(stdRoot, "math.wll")
+	bc, _, ok := loadPrecompiled(path, src, false, false)
+	if !ok {
+		t.Fatalf("expected precompiled bytecode for math.wll")
+	}
+	if bc.Debug.File != path {
+		t.Fatalf("expected file %s, got %s", path, bc.Debug.File)
+	}
+	if _, _, ok := loadPrecompiled(path, append(src, '\n'), false, false); ok {
+		t.Fatalf("precompiled bytecode used for edited source")
+	}
+
+	loader := NewLoader(NewResolver(stdRoot, nil))
+	got, _, err := loader.LoadBytecode(filepath.Join(t.TempDir(), "main.wll"), "std:math", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Instructions.String() != bc.Instructions.String() {
+		t.Fatalf("loader did not return the precompiled module")
+	}
+}
@@ -0,0 +1,191 @@
+package module
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"welle/internal/ast"
+	"welle/internal/lexer"
+	"welle/internal/parser"
+	"welle/internal/token"
+)
+
+// NoPreludeDirective, in a // comment above a module's first line of code,
+// keeps the project prelude out of that module.
+const NoPreludeDirective = "welle:no-prelude"
+
+// SetPrelude makes the module at path the project prelude: its exports are
+// imported into every module under root that does not opt out. Modules in
+// std, and the prelude itself, never get it. An empty path turns it off.
+func (r *Resolver) SetPrelude(path, root string) error {
+	if path == "" {
+		r.Prelude, r.PreludeRoot = "", ""
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	r.Prelude, r.PreludeRoot = abs, rootAbs
+	return nil
+}
+
+// PreludeApplies reports whether the module at path, whose source is src,
+// gets the project prelude.
+func (r *Resolver) PreludeApplies(path, src string) bool {
+	if r == nil || r.Prelude == "" || path == "" {
+		return false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil || abs == r.Prelude {
+		return false
+	}
+	if !within(abs, r.PreludeRoot) || (r.StdRoot != "" && within(abs, r.StdRoot)) {
+		return false
+	}
+	return !optsOutOfPrelude(src)
+}
+
+// PreludeImport returns the statement that imports the prelude's exports
+// into the module at path, or nil when the module does not get the prelude
+// or has nothing to take from it. Names the module binds at top level are
+// left out, so its own definitions shadow the prelude's.
+func (r *Resolver) PreludeImport(path, src string, prog *ast.Program) (*ast.FromImportStatement, error) {
+	if !r.PreludeApplies(path, src) {
+		return nil, nil
+	}
+	names, err := PreludeExports(r.Prelude)
+	if err != nil {
+		return nil, err
+	}
+	own := topLevelNames(prog)
+	tok := token.Token{Type: token.FROM, Literal: "from", Line: 1, Col: 1}
+	stmt := &ast.FromImportStatement{
+		Token: tok,
+		Path:  &ast.StringLiteral{Token: tok, Value: r.Prelude},
+	}
+	for _, name := range names {
+		if own[name] {
+			continue
+		}
+		stmt.Items = append(stmt.Items, ast.ImportItem{Name: &ast.Identifier{Token: tok, Value: name}})
+	}
+	if len(stmt.Items) == 0 {
+		return nil, nil
+	}
+	return stmt, nil
+}
+
+// ApplyPrelude puts the module's PreludeImport, if any, at the start of prog.
+func (r *Resolver) ApplyPrelude(path, src string, prog *ast.Program) error {
+	stmt, err := r.PreludeImport(path, src, prog)
+	if err != nil || stmt == nil {
+		return err
+	}
+	prog.Statements = append([]ast.Statement{stmt}, prog.Statements...)
+	return nil
+}
+
+// PreludeExports returns the names the prelude at path exports, in the
+// order they appear.
+func PreludeExports(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("prelude: %w", err)
+	}
+	p := parser.New(lexer.New(string(b)))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, fmt.Errorf("prelude: parse error in %s:\n%v", path, p.Errors())
+	}
+	var names []string
+	for _, stmt := range prog.Statements {
+		if exp, ok := stmt.(*ast.ExportStatement); ok {
+			if name, _, ok := exportName(exp); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// optsOutOfPrelude reports whether the // comments that open src include
+// NoPreludeDirective.
+func optsOutOfPrelude(src string) bool {
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		comment, ok := strings.CutPrefix(line, "//")
+		if !ok {
+			return false
+		}
+		if strings.TrimSpace(comment) == NoPreludeDirective {
+			return true
+		}
+	}
+	return false
+}
+
+// topLevelNames returns the names prog's top-level statements bind.
+func topLevelNames(prog *ast.Program) map[string]bool {
+	names := map[string]bool{}
+	if prog == nil {
+		return names
+	}
+	var add func(stmt ast.Statement)
+	add = func(stmt ast.Statement) {
+		switch s := stmt.(type) {
+		case *ast.ExportStatement:
+			add(s.Stmt)
+		case *ast.AssignStatement:
+			if s.Name != nil {
+				names[s.Name.Value] = true
+			}
+		case *ast.FuncStatement:
+			if s.Name != nil {
+				names[s.Name.Value] = true
+			}
+		case *ast.ClassStatement:
+			if s.Name != nil {
+				names[s.Name.Value] = true
+			}
+		case *ast.DestructureAssignStatement:
+			for _, t := range s.Targets {
+				if t != nil && t.Name != nil {
+					names[t.Name.Value] = true
+				}
+			}
+		case *ast.ImportStatement:
+			if s.Alias != nil {
+				names[s.Alias.Value] = true
+			}
+		case *ast.FromImportStatement:
+			for _, it := range s.Items {
+				if it.Alias != nil {
+					names[it.Alias.Value] = true
+				} else if it.Name != nil {
+					names[it.Name.Value] = true
+				}
+			}
+		}
+	}
+	for _, stmt := range prog.Statements {
+		add(stmt)
+	}
+	return names
+}
+
+func within(path, dir string) bool {
+	if dir == "" {
+		return false
+	}
+	return path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator))
+}
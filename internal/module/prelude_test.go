@@ -0,0 +1,97 @@
+package module
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"welle/internal/lexer"
+	"welle/internal/parser"
+)
+
+func TestPreludeImport(t *testing.T) {
+	root := t.TempDir()
+	stdRoot := filepath.Join(root, "std")
+	files := map[string]string{
+		"prelude.wll":  "export a = 1\nexport func b() { return 2 }\nhidden = 3\nexport c = 4\n",
+		"main.wll":     "func b() { return 0 }\nprint(a)\n",
+		"raw.wll":      "// a script\n//   welle:no-prelude\nprint(1)\n",
+		"late.wll":     "print(1)\n// welle:no-prelude\n",
+		"std/util.wll": "export x = 1\n",
+	}
+	for rel, src := range files {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res := NewResolver(stdRoot, nil)
+	prelude := filepath.Join(root, "prelude.wll")
+	if err := res.SetPrelude(prelude, root); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{filepath.Join(root, "main.wll"), "from \"" + prelude + "\" import a, c"},
+		{filepath.Join(root, "late.wll"), "from \"" + prelude + "\" import a, b, c"},
+		{filepath.Join(root, "raw.wll"), ""},
+		{prelude, ""},
+		{filepath.Join(stdRoot, "util.wll"), ""},
+		{filepath.Join(t.TempDir(), "elsewhere.wll"), ""},
+	}
+	for _, tt := range tests {
+		src, _ := os.ReadFile(tt.path)
+		prog := parser.New(lexer.New(string(src))).ParseProgram()
+		stmt, err := res.PreludeImport(tt.path, string(src), prog)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		got := ""
+		if stmt != nil {
+			got = stmt.String()
+		}
+		if got != tt.want {
+			t.Fatalf("%s: got %q, want %q", filepath.Base(tt.path), got, tt.want)
+		}
+	}
+}
+
+func TestLoaderCacheKeyFollowsPrelude(t *testing.T) {
+	root := t.TempDir()
+	prelude := filepath.Join(root, "prelude.wll")
+	mainPath := filepath.Join(root, "main.wll")
+	if err := os.WriteFile(prelude, []byte("export a = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mainPath, []byte("export x = a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res := NewResolver(filepath.Join(root, "std"), nil)
+	if err := res.SetPrelude(prelude, root); err != nil {
+		t.Fatal(err)
+	}
+	cache := &DiskCache{Dir: t.TempDir()}
+
+	load := func() error {
+		l := NewLoader(res)
+		l.DiskCache = cache
+		_, _, err := l.LoadBytecode(mainPath, mainPath, false)
+		return err
+	}
+	if err := load(); err != nil {
+		t.Fatal(err)
+	}
+	// Without the prelude's export, a stale cache entry would still load.
+	if err := os.WriteFile(prelude, []byte("export b = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := load(); err == nil {
+		t.Fatal("expected unknown identifier error once the prelude stops exporting a")
+	}
+}
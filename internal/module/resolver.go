@@ -5,11 +5,21 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"welle/internal/parser"
 )
 
 type Resolver struct {
 	StdRoot string
 	Paths   []string
+	// Prelude is the absolute path of the project prelude, or "", and
+	// PreludeRoot the project root it applies under; see SetPrelude.
+	Prelude     string
+	PreludeRoot string
+	// Edition is the project's language edition, for the modules under
+	// EditionRoot; see SetEdition.
+	Edition     parser.Edition
+	EditionRoot string
 }
 
 type ResolveError struct {
@@ -56,6 +66,13 @@ func (r *Resolver) Resolve(fromFile string, spec string) (string, error) {
 			p, _ = filepath.Abs(p)
 			return p, nil
 		}
+		// Modules missing from an on-disk std come from the embedded one.
+		if embedded, ok := embeddedStdModule(addExt(name)); ok {
+			if embedded != p {
+				addAttempt(embedded)
+			}
+			return embedded, nil
+		}
 		return "", &ResolveError{Spec: spec, FromFile: fromFile, Attempts: attempts}
 	}
 
@@ -74,20 +91,37 @@ func (r *Resolver) Resolve(fromFile string, spec string) (string, error) {
 		return "", &ResolveError{Spec: spec, FromFile: fromFile, Attempts: attempts}
 	}
 
-	p := filepath.Join(r.StdRoot, addExt(spec))
-	p = addAttempt(p)
-	if ok, _ := exists(p); ok {
-		p, _ = filepath.Abs(p)
-		return p, nil
+	// A bare name is the user's own module before it is a std one: the
+	// importing file's directory, then the search paths, then std.
+	roots := []string{}
+	if strings.TrimSpace(fromFile) != "" {
+		roots = append(roots, filepath.Dir(fromFile))
 	}
-	for _, root := range r.Paths {
-		pp := filepath.Join(root, addExt(spec))
+	roots = append(roots, r.Paths...)
+	if r.StdRoot != "" {
+		roots = append(roots, r.StdRoot)
+	}
+	seen := map[string]bool{}
+	for _, root := range roots {
+		pp, _ := filepath.Abs(filepath.Join(root, addExt(spec)))
+		if seen[pp] {
+			continue
+		}
+		seen[pp] = true
 		addAttempt(pp)
+		if _, ok := embeddedStdName(pp); ok {
+			return pp, nil
+		}
 		if ok, _ := exists(pp); ok {
-			pp, _ = filepath.Abs(pp)
 			return pp, nil
 		}
 	}
+	if embedded, ok := embeddedStdModule(addExt(spec)); ok {
+		if !seen[embedded] {
+			addAttempt(embedded)
+		}
+		return embedded, nil
+	}
 
 	return "", &ResolveError{Spec: spec, FromFile: fromFile, Attempts: attempts}
 }
@@ -0,0 +1,210 @@
+package module
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"welle/std"
+)
+
+// StdEnv names the environment variable that selects the std library,
+// taking precedence over welle.toml.
+const StdEnv = "WELLE_STD"
+
+// HomeEnv names the environment variable that overrides ~/.welle.
+const HomeEnv = "WELLE_HOME"
+
+// StdEmbedded is the std setting that selects the library built into the
+// binary.
+const StdEmbedded = "embedded"
+
+// isStdVersion reports whether s names an installed std version, such as
+// "1.2" or "v1".
+func isStdVersion(s string) bool {
+	s = strings.TrimPrefix(s, "v")
+	if s == "" {
+		return false
+	}
+	for _, part := range strings.Split(s, ".") {
+		if part == "" || strings.Trim(part, "0123456789") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Home returns the per-user welle directory: $WELLE_HOME, or .welle in the
+// user's home directory.
+func Home() (string, error) {
+	if h := os.Getenv(HomeEnv); h != "" {
+		return filepath.Abs(h)
+	}
+	h, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(h, ".welle"), nil
+}
+
+// LocateStd picks the directory std: modules are loaded from. The first of
+// these that is set wins:
+//
+//   - $WELLE_STD
+//   - setting, the `std` (or `std_root`) value from welle.toml
+//   - defaultRoot, when it is an existing directory
+//   - the std library embedded in the binary
+//
+// A setting is a path (relative ones are taken from base), a version such
+// as "1.2" naming an install under <home>/std/1.2, or "embedded".
+func LocateStd(setting, base, defaultRoot string) (string, error) {
+	if env := os.Getenv(StdEnv); env != "" {
+		cwd, _ := os.Getwd()
+		root, err := stdFromSetting(env, cwd)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", StdEnv, err)
+		}
+		return root, nil
+	}
+	if setting != "" {
+		return stdFromSetting(setting, base)
+	}
+	if defaultRoot != "" {
+		if fi, err := os.Stat(defaultRoot); err == nil && fi.IsDir() {
+			return filepath.Abs(defaultRoot)
+		}
+	}
+	return EmbeddedStd()
+}
+
+func stdFromSetting(setting, base string) (string, error) {
+	switch {
+	case setting == StdEmbedded:
+		return EmbeddedStd()
+	case isStdVersion(setting):
+		home, err := Home()
+		if err != nil {
+			return "", err
+		}
+		root := filepath.Join(home, "std", setting)
+		if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+			return "", fmt.Errorf("std version %s is not installed (expected %s)", setting, root)
+		}
+		return root, nil
+	}
+	root := setting
+	if !filepath.IsAbs(root) {
+		root = filepath.Join(base, root)
+	}
+	return filepath.Abs(root)
+}
+
+// EmbeddedStd returns the directory the std library embedded in the binary
+// is served under, <home>/std/embedded-<hash>. Nothing is written there:
+// ReadSource reads modules under it from the binary, and the directory only
+// gives them stable file paths for error messages and tracebacks.
+func EmbeddedStd() (string, error) {
+	home, err := Home()
+	if err != nil {
+		home = filepath.Join(os.TempDir(), "welle")
+	}
+	_, sum, err := embeddedStdFiles()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "std", StdEmbedded+"-"+sum), nil
+}
+
+// ReadSource reads the module at path, from the binary when path is in the
+// embedded std.
+func ReadSource(path string) ([]byte, error) {
+	if name, ok := embeddedStdName(path); ok {
+		return fs.ReadFile(std.FS, name)
+	}
+	return os.ReadFile(path)
+}
+
+// EmbeddedStdModules lists the file names of the modules embedded in the
+// binary, such as "math.wll".
+func EmbeddedStdModules() []string {
+	names, _, err := embeddedStdFiles()
+	if err != nil {
+		return nil
+	}
+	return names
+}
+
+// embeddedStdModule returns the path of file in the embedded std, if it has
+// such a module.
+func embeddedStdModule(file string) (string, bool) {
+	if file != filepath.Base(file) {
+		return "", false
+	}
+	if _, err := fs.Stat(std.FS, file); err != nil {
+		return "", false
+	}
+	root, err := EmbeddedStd()
+	if err != nil {
+		return "", false
+	}
+	return filepath.Join(root, file), true
+}
+
+// embeddedStdName returns the name of the embedded module path stands for.
+func embeddedStdName(path string) (string, bool) {
+	root, err := EmbeddedStd()
+	if err != nil || filepath.Dir(path) != root {
+		return "", false
+	}
+	name := filepath.Base(path)
+	if _, err := fs.Stat(std.FS, name); err != nil {
+		return "", false
+	}
+	return name, true
+}
+
+var embeddedStd struct {
+	once  sync.Once
+	names []string
+	sum   string
+	err   error
+}
+
+// embeddedStdFiles lists the embedded modules and a short hash of their
+// contents, which names the directory they are served under.
+func embeddedStdFiles() ([]string, string, error) {
+	embeddedStd.once.Do(func() {
+		embeddedStd.names, embeddedStd.sum, embeddedStd.err = hashEmbeddedStd()
+	})
+	return embeddedStd.names, embeddedStd.sum, embeddedStd.err
+}
+
+func hashEmbeddedStd() ([]string, string, error) {
+	entries, err := fs.ReadDir(std.FS, ".")
+	if err != nil {
+		return nil, "", err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		b, err := fs.ReadFile(std.FS, name)
+		if err != nil {
+			return nil, "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(b))
+		h.Write(b)
+	}
+	return names, hex.EncodeToString(h.Sum(nil))[:12], nil
+}
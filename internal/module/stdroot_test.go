@@ -0,0 +1,153 @@
+package module
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocateStdPrecedence(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv(HomeEnv, filepath.Join(tmp, "home"))
+	t.Setenv(StdEnv, "")
+
+	local := filepath.Join(tmp, "project", "std")
+	if err := os.MkdirAll(local, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	installed := filepath.Join(tmp, "home", "std", "1.2")
+	if err := os.MkdirAll(installed, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	project := filepath.Dir(local)
+
+	cases := []struct {
+		name, env, setting, want string
+	}{
+		{"default dir", "", "", local},
+		{"manifest path", "", "vendor/std", filepath.Join(project, "vendor", "std")},
+		{"manifest version", "", "1.2", installed},
+		{"env wins", installed, "vendor/std", installed},
+	}
+	for _, tc := range cases {
+		t.Setenv(StdEnv, tc.env)
+		got, err := LocateStd(tc.setting, project, local)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+
+	t.Setenv(StdEnv, "")
+	if _, err := LocateStd("9.9", project, local); err == nil || !strings.Contains(err.Error(), "not installed") {
+		t.Fatalf("expected a missing version error, got %v", err)
+	}
+}
+
+func TestLocateStdFallsBackToEmbedded(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv(HomeEnv, tmp)
+	t.Setenv(StdEnv, "")
+
+	root, err := LocateStd("", tmp, filepath.Join(tmp, "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(root, filepath.Join(tmp, "std", StdEmbedded+"-")) {
+		t.Fatalf("expected the embedded std under WELLE_HOME, got %s", root)
+	}
+	if _, err := os.Stat(root); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing written for the embedded std, stat gave %v", err)
+	}
+	got, err := ReadSource(filepath.Join(root, "math.wll"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join("..", "..", "std", "math.wll"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("embedded math.wll differs from std/math.wll")
+	}
+
+	again, err := EmbeddedStd()
+	if err != nil || again != root {
+		t.Fatalf("expected the same embedded std root, got %s (%v)", again, err)
+	}
+	res, err := NewResolver(root, nil).Resolve(filepath.Join(tmp, "main.wll"), "std:math")
+	if err != nil || res != filepath.Join(root, "math.wll") {
+		t.Fatalf("unexpected resolve: %s (%v)", res, err)
+	}
+}
+
+func TestResolverPrefersOnDiskStdModules(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv(HomeEnv, filepath.Join(tmp, "home"))
+
+	stdRoot := filepath.Join(tmp, "std")
+	if err := os.MkdirAll(stdRoot, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(stdRoot, "math.wll"), []byte("export PI = 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res := NewResolver(stdRoot, nil)
+	from := filepath.Join(tmp, "main.wll")
+
+	got, err := res.Resolve(from, "std:math")
+	if err != nil || got != filepath.Join(stdRoot, "math.wll") {
+		t.Fatalf("expected the on-disk math.wll, got %s (%v)", got, err)
+	}
+	got, err = res.Resolve(from, "std:strings")
+	if err != nil || !strings.HasPrefix(got, filepath.Join(tmp, "home", "std", StdEmbedded+"-")) {
+		t.Fatalf("expected strings.wll from the embedded std, got %s (%v)", got, err)
+	}
+	if _, err := res.Resolve(from, "std:nope"); err == nil {
+		t.Fatalf("expected an error for a module in neither std")
+	}
+}
+
+func TestResolverPrefersUserModulesToStd(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv(HomeEnv, filepath.Join(tmp, "home"))
+	root, err := EmbeddedStd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(tmp, "app")
+	lib := filepath.Join(tmp, "lib")
+	for _, d := range []string{dir, lib} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "math.wll"), []byte("export PI = 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(lib, "strings.wll"), []byte("export X = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res := NewResolver(root, []string{lib})
+	from := filepath.Join(dir, "main.wll")
+
+	cases := []struct{ spec, want string }{
+		{"math", filepath.Join(dir, "math.wll")},
+		{"strings", filepath.Join(lib, "strings.wll")},
+		{"std:math", filepath.Join(root, "math.wll")},
+		{"yaml", filepath.Join(root, "yaml.wll")},
+	}
+	for _, tc := range cases {
+		got, err := res.Resolve(from, tc.spec)
+		if err != nil || got != tc.want {
+			t.Fatalf("%s: got %s (%v), want %s", tc.spec, got, err, tc.want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "home")); !os.IsNotExist(err) {
+		t.Fatalf("expected resolving to write nothing under WELLE_HOME, stat gave %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+// Package netio backs std:net: TCP listeners and connections and UDP
+// sockets, kept in a registry and referred to by integer handle. Every call
+// blocks; a socket's timeout (none by default) bounds each blocking
+// operation on it.
+package netio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"welle/internal/runtimeio"
+)
+
+// ErrTimeout is returned when a blocking call outlasts the socket's
+// timeout.
+var ErrTimeout = errors.New("net: timed out")
+
+type socketKind int
+
+const (
+	kindListener socketKind = iota
+	kindTCP
+	kindUDP
+)
+
+type socket struct {
+	kind    socketKind
+	ln      net.Listener
+	conn    net.Conn
+	r       *bufio.Reader
+	pc      net.PacketConn
+	timeout time.Duration
+}
+
+func (s *socket) closer() io.Closer {
+	switch s.kind {
+	case kindListener:
+		return s.ln
+	case kindUDP:
+		return s.pc
+	}
+	return s.conn
+}
+
+var (
+	mu     sync.Mutex
+	socks        = map[int64]*socket{}
+	nextID int64 = 1
+)
+
+func checkAllowed() error {
+	if !runtimeio.AllowNet() {
+		return errors.New("net: network access is disabled (run with -allow-net)")
+	}
+	return nil
+}
+
+func register(s *socket) int64 {
+	mu.Lock()
+	defer mu.Unlock()
+	id := nextID
+	nextID++
+	socks[id] = s
+	return id
+}
+
+func lookup(id int64, want socketKind, op string) (*socket, error) {
+	mu.Lock()
+	s, ok := socks[id]
+	mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("net: socket %d is not open", id)
+	}
+	if s.kind != want {
+		names := map[socketKind]string{kindListener: "a TCP listener", kindTCP: "a TCP connection", kindUDP: "a UDP socket"}
+		return nil, fmt.Errorf("net: %s needs %s, socket %d is %s", op, names[want], id, names[s.kind])
+	}
+	return s, nil
+}
+
+func (s *socket) deadline() time.Time {
+	if s.timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(s.timeout)
+}
+
+func netError(err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return ErrTimeout
+	}
+	return fmt.Errorf("net: %v", err)
+}
+
+// Listen starts a TCP listener on addr ("host:port"; port 0 picks a free
+// one) and returns its handle and bound address.
+func Listen(addr string) (int64, string, error) {
+	if err := checkAllowed(); err != nil {
+		return 0, "", err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return 0, "", netError(err)
+	}
+	return register(&socket{kind: kindListener, ln: ln}), ln.Addr().String(), nil
+}
+
+// Accept waits for the next connection on a listener. The connection
+// starts with the listener's timeout.
+func Accept(id int64) (int64, net.Addr, net.Addr, error) {
+	s, err := lookup(id, kindListener, "accept")
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if tl, ok := s.ln.(*net.TCPListener); ok {
+		tl.SetDeadline(s.deadline())
+	}
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return 0, nil, nil, netError(err)
+	}
+	c := &socket{kind: kindTCP, conn: conn, r: bufio.NewReader(conn), timeout: s.timeout}
+	return register(c), conn.LocalAddr(), conn.RemoteAddr(), nil
+}
+
+// Connect dials a TCP address. A positive timeout bounds the dial and
+// becomes the connection's timeout.
+func Connect(addr string, timeout time.Duration) (int64, net.Addr, net.Addr, error) {
+	if err := checkAllowed(); err != nil {
+		return 0, nil, nil, err
+	}
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return 0, nil, nil, netError(err)
+	}
+	c := &socket{kind: kindTCP, conn: conn, r: bufio.NewReader(conn), timeout: timeout}
+	return register(c), conn.LocalAddr(), conn.RemoteAddr(), nil
+}
+
+// Send writes all of data to a TCP connection.
+func Send(id int64, data string) (int, error) {
+	s, err := lookup(id, kindTCP, "send")
+	if err != nil {
+		return 0, err
+	}
+	s.conn.SetWriteDeadline(s.deadline())
+	n, err := io.WriteString(s.conn, data)
+	if err != nil {
+		return n, netError(err)
+	}
+	return n, nil
+}
+
+// Recv reads up to max bytes from a TCP connection; eof is true once the
+// peer has closed it and nothing is left.
+func Recv(id int64, max int) (data string, eof bool, err error) {
+	if max <= 0 {
+		return "", false, fmt.Errorf("net: recv size must be positive, got %d", max)
+	}
+	s, err := lookup(id, kindTCP, "recv")
+	if err != nil {
+		return "", false, err
+	}
+	s.conn.SetReadDeadline(s.deadline())
+	buf := make([]byte, max)
+	n, err := s.r.Read(buf)
+	if n > 0 {
+		return string(buf[:n]), false, nil
+	}
+	if errors.Is(err, io.EOF) {
+		return "", true, nil
+	}
+	if err != nil {
+		return "", false, netError(err)
+	}
+	return "", false, nil
+}
+
+// RecvLine reads one line from a TCP connection and strips its "\n" or
+// "\r\n". A final line without a newline is still returned; eof is true
+// only when nothing was left.
+func RecvLine(id int64) (line string, eof bool, err error) {
+	s, err := lookup(id, kindTCP, "recv_line")
+	if err != nil {
+		return "", false, err
+	}
+	s.conn.SetReadDeadline(s.deadline())
+	line, err = s.r.ReadString('\n')
+	if errors.Is(err, io.EOF) {
+		if line == "" {
+			return "", true, nil
+		}
+		return line, false, nil
+	}
+	if err != nil {
+		return "", false, netError(err)
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), false, nil
+}
+
+// UDPBind opens a UDP socket on addr and returns its handle and bound
+// address.
+func UDPBind(addr string) (int64, string, error) {
+	if err := checkAllowed(); err != nil {
+		return 0, "", err
+	}
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return 0, "", netError(err)
+	}
+	return register(&socket{kind: kindUDP, pc: pc}), pc.LocalAddr().String(), nil
+}
+
+// UDPSend sends one datagram to addr.
+func UDPSend(id int64, addr, data string) (int, error) {
+	s, err := lookup(id, kindUDP, "udp_send")
+	if err != nil {
+		return 0, err
+	}
+	to, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return 0, netError(err)
+	}
+	s.pc.SetWriteDeadline(s.deadline())
+	n, err := s.pc.WriteTo([]byte(data), to)
+	if err != nil {
+		return n, netError(err)
+	}
+	return n, nil
+}
+
+// UDPRecv waits for one datagram of up to max bytes; longer ones are
+// truncated.
+func UDPRecv(id int64, max int) (string, net.Addr, error) {
+	if max <= 0 {
+		return "", nil, fmt.Errorf("net: recv size must be positive, got %d", max)
+	}
+	s, err := lookup(id, kindUDP, "udp_recv")
+	if err != nil {
+		return "", nil, err
+	}
+	s.pc.SetReadDeadline(s.deadline())
+	buf := make([]byte, max)
+	n, from, err := s.pc.ReadFrom(buf)
+	if err != nil {
+		return "", nil, netError(err)
+	}
+	return string(buf[:n]), from, nil
+}
+
+// SetTimeout sets how long each blocking call on the socket may wait; zero
+// waits forever.
+func SetTimeout(id int64, timeout time.Duration) error {
+	if timeout < 0 {
+		return fmt.Errorf("net: timeout must not be negative")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	s, ok := socks[id]
+	if !ok {
+		return fmt.Errorf("net: socket %d is not open", id)
+	}
+	s.timeout = timeout
+	return nil
+}
+
+// Close closes any kind of socket.
+func Close(id int64) error {
+	mu.Lock()
+	s, ok := socks[id]
+	delete(socks, id)
+	mu.Unlock()
+	if !ok {
+		return fmt.Errorf("net: socket %d is not open", id)
+	}
+	if err := s.closer().Close(); err != nil {
+		return netError(err)
+	}
+	return nil
+}
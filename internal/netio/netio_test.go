@@ -0,0 +1,119 @@
+package netio
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"welle/internal/runtimeio"
+)
+
+func allowNet(t *testing.T) {
+	runtimeio.SetAllowNet(true)
+	t.Cleanup(func() { runtimeio.SetAllowNet(false) })
+}
+
+func TestTCPLinesAndEOF(t *testing.T) {
+	allowNet(t)
+	ln, addr, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer Close(ln)
+	SetTimeout(ln, 2*time.Second)
+
+	client, _, remote, err := Connect(addr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if remote.String() != addr {
+		t.Fatalf("remote = %s, want %s", remote, addr)
+	}
+	server, _, _, err := Accept(ln)
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	defer Close(server)
+
+	if _, err := Send(client, "one\r\ntwo\nrest"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	Close(client)
+	for _, want := range []string{"one", "two", "rest"} {
+		line, eof, err := RecvLine(server)
+		if err != nil || eof || line != want {
+			t.Fatalf("recv_line = %q eof=%v err=%v, want %q", line, eof, err, want)
+		}
+	}
+	if _, eof, err := RecvLine(server); !eof || err != nil {
+		t.Fatalf("expected eof, got eof=%v err=%v", eof, err)
+	}
+	if _, eof, err := Recv(server, 16); !eof || err != nil {
+		t.Fatalf("expected eof from recv, got eof=%v err=%v", eof, err)
+	}
+}
+
+func TestTimeoutAndKinds(t *testing.T) {
+	allowNet(t)
+	ln, addr, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer Close(ln)
+	if err := SetTimeout(ln, 20*time.Millisecond); err != nil {
+		t.Fatalf("set_timeout: %v", err)
+	}
+	if _, _, _, err := Accept(ln); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected accept timeout, got %v", err)
+	}
+
+	client, _, _, err := Connect(addr, time.Second)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer Close(client)
+	SetTimeout(client, 20*time.Millisecond)
+	if _, _, err := Recv(client, 8); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected recv timeout, got %v", err)
+	}
+	if _, err := Send(ln, "x"); err == nil || !strings.Contains(err.Error(), "send needs a TCP connection") {
+		t.Fatalf("expected kind error, got %v", err)
+	}
+	if err := Close(999999); err == nil || !strings.Contains(err.Error(), "is not open") {
+		t.Fatalf("expected closed handle error, got %v", err)
+	}
+}
+
+func TestUDP(t *testing.T) {
+	allowNet(t)
+	a, aAddr, err := UDPBind("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	defer Close(a)
+	b, bAddr, err := UDPBind("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	defer Close(b)
+	SetTimeout(b, 2*time.Second)
+
+	if _, err := UDPSend(a, bAddr, "hello"); err != nil {
+		t.Fatalf("udp_send: %v", err)
+	}
+	data, from, err := UDPRecv(b, 3)
+	if err != nil || data != "hel" || from.String() != aAddr {
+		t.Fatalf("udp_recv = %q from %v err=%v", data, from, err)
+	}
+}
+
+func TestCapability(t *testing.T) {
+	runtimeio.SetAllowNet(false)
+	if _, _, err := Listen("127.0.0.1:0"); err == nil || !strings.Contains(err.Error(), "-allow-net") {
+		t.Fatalf("expected capability error, got %v", err)
+	}
+	if _, _, _, err := Connect("127.0.0.1:1", 0); err == nil || !strings.Contains(err.Error(), "-allow-net") {
+		t.Fatalf("expected capability error, got %v", err)
+	}
+}
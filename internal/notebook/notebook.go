@@ -0,0 +1,286 @@
+// Package notebook serves a local, browser-based notebook for welle. Cells
+// run one at a time against a single repl.Session, so globals, functions
+// and imports carry over from cell to cell just like in the line REPL.
+//
+// The browser page talks to two endpoints: GET / returns the page and POST
+// /run takes {"source": "..."} and answers with a Result. A cell's value is
+// sent back in the richest form that fits: an ARRAY of DICTs becomes a
+// table, an IMAGE (from image_new and friends) a PNG, anything else its
+// printed form.
+//
+// Cells run code on the user's machine, so every request must carry the
+// session token (see Server.Token), name a loopback or IP-literal Host, and
+// come from the notebook's own origin; /run only takes application/json.
+// Together these keep other web pages, including ones reached through DNS
+// rebinding, from running cells.
+package notebook
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"welle/internal/object"
+	"welle/internal/repl"
+)
+
+// maxCellBytes caps the size of a POST /run body.
+const maxCellBytes = 1 << 20
+
+// Value is the rendered value of a cell. Kind is "text", "table" or
+// "image"; only the fields for that kind are set.
+type Value struct {
+	Kind    string     `json:"kind"`
+	Text    string     `json:"text,omitempty"`
+	Columns []string   `json:"columns,omitempty"`
+	Rows    [][]string `json:"rows,omitempty"`
+	PNG     string     `json:"png,omitempty"` // base64
+}
+
+// Result is the answer to one cell: what it printed, then either its value
+// (absent for statements and nil) or the error that stopped it.
+type Result struct {
+	Stdout string `json:"stdout"`
+	Value  *Value `json:"value,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// TokenHeader is the request header the page sends the session token in.
+const TokenHeader = "X-Welle-Token"
+
+// Server is an http.Handler running cells against one session.
+type Server struct {
+	mu      sync.Mutex
+	session *repl.Session
+	token   string
+}
+
+// New returns a server for session with a fresh random token.
+func New(session *repl.Session) *Server {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return &Server{session: session, token: hex.EncodeToString(b)}
+}
+
+// Token is the secret a client must present: as ?token= when loading the
+// page, and in the X-Welle-Token header on /run, which the page does
+// itself. It is printed at startup as part of the notebook's URL.
+func (s *Server) Token() string {
+	return s.token
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !localHost(r.Host) {
+		http.Error(w, "forbidden host", http.StatusForbidden)
+		return
+	}
+	if o := r.Header.Get("Origin"); o != "" && !sameOrigin(o, r.Host) {
+		http.Error(w, "forbidden origin", http.StatusForbidden)
+		return
+	}
+	switch r.URL.Path {
+	case "/":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !s.validToken(r.URL.Query().Get("token")) {
+			http.Error(w, "missing or wrong token; open the URL welle notebook printed", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, page)
+	case "/run":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !s.validToken(r.Header.Get(TokenHeader)) {
+			http.Error(w, "missing or wrong token", http.StatusForbidden)
+			return
+		}
+		// Forms cannot send application/json across origins without a
+		// preflight, which this server never grants.
+		if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+			http.Error(w, "cells must be sent as application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+		var cell struct {
+			Source string `json:"source"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCellBytes)).Decode(&cell); err != nil {
+			http.Error(w, "bad cell: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Run(cell.Source))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) validToken(t string) bool {
+	return subtle.ConstantTimeCompare([]byte(t), []byte(s.token)) == 1
+}
+
+// localHost reports whether a Host header names this machine in a way a
+// DNS rebinding attack cannot produce: localhost or an IP address.
+func localHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.EqualFold(host, "localhost") || net.ParseIP(host) != nil
+}
+
+// sameOrigin reports whether origin is the notebook's own http origin.
+func sameOrigin(origin, host string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && u.Scheme == "http" && strings.EqualFold(u.Host, host)
+}
+
+// Run executes one cell. Cells never run concurrently: they share the
+// session and print through the process's stdout, which is redirected
+// into the Result while the cell runs.
+func (s *Server) Run(src string) Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res Result
+	var val object.Object
+	var evalErr error
+	out, err := captureStdout(func() {
+		val, evalErr = s.session.Eval(src)
+	})
+	res.Stdout = out
+	if err == nil {
+		err = evalErr
+	}
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	if val != nil && val.Type() != object.NIL_OBJ {
+		res.Value = render(val)
+	}
+	return res
+}
+
+// captureStdout runs fn with os.Stdout pointing at a pipe and returns what
+// was written to it.
+func captureStdout(fn func()) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		io.Copy(&buf, r)
+		close(done)
+	}()
+	saved := os.Stdout
+	os.Stdout = w
+	func() {
+		defer func() {
+			os.Stdout = saved
+			w.Close()
+		}()
+		fn()
+	}()
+	<-done
+	r.Close()
+	return buf.String(), nil
+}
+
+func render(val object.Object) *Value {
+	switch v := val.(type) {
+	case *object.Image:
+		if enc, ok := encodePNG(v); ok {
+			return &Value{Kind: "image", PNG: enc}
+		}
+	case *object.Array:
+		if cols, rows, ok := table(v); ok {
+			return &Value{Kind: "table", Columns: cols, Rows: rows}
+		}
+	}
+	return &Value{Kind: "text", Text: val.Inspect()}
+}
+
+// table lays out a non-empty ARRAY of DICTs as rows, with one column per
+// key found in any of them, in name order. Missing fields are left blank.
+func table(arr *object.Array) ([]string, [][]string, bool) {
+	if len(arr.Elements) == 0 {
+		return nil, nil, false
+	}
+	index := map[string]int{}
+	var cols []string
+	for _, el := range arr.Elements {
+		d, ok := el.(*object.Dict)
+		if !ok {
+			return nil, nil, false
+		}
+		for _, pair := range d.Pairs {
+			name := columnName(pair.Key)
+			if _, seen := index[name]; !seen {
+				index[name] = 0
+				cols = append(cols, name)
+			}
+		}
+	}
+	sort.Strings(cols)
+	for i, c := range cols {
+		index[c] = i
+	}
+	rows := make([][]string, len(arr.Elements))
+	for i, el := range arr.Elements {
+		row := make([]string, len(cols))
+		for _, pair := range el.(*object.Dict).Pairs {
+			row[index[columnName(pair.Key)]] = cellText(pair.Value)
+		}
+		rows[i] = row
+	}
+	return cols, rows, true
+}
+
+func columnName(key object.Object) string {
+	if s, ok := key.(*object.String); ok {
+		return s.Value
+	}
+	return key.Inspect()
+}
+
+// cellText shows strings without quotes, as a table reader expects.
+func cellText(val object.Object) string {
+	if s, ok := val.(*object.String); ok {
+		return s.Value
+	}
+	return val.Inspect()
+}
+
+func encodePNG(img *object.Image) (string, bool) {
+	rgba := &image.NRGBA{
+		Pix:    img.Data,
+		Stride: img.Width * 4,
+		Rect:   image.Rect(0, 0, img.Width, img.Height),
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, rgba); err != nil {
+		return "", false
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), true
+}
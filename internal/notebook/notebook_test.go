@@ -0,0 +1,149 @@
+package notebook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"welle/internal/repl"
+)
+
+func newServer(t *testing.T) (*httptest.Server, *Server) {
+	t.Helper()
+	nb := New(repl.NewSession("", repl.Limits{}))
+	srv := httptest.NewServer(nb)
+	t.Cleanup(srv.Close)
+	return srv, nb
+}
+
+func runRequest(srv *httptest.Server, token, src string) *http.Request {
+	body, _ := json.Marshal(map[string]string{"source": src})
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/run", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TokenHeader, token)
+	return req
+}
+
+func post(t *testing.T, srv *httptest.Server, nb *Server, src string) Result {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(runRequest(srv, nb.Token(), src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	var res Result
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestCellsShareSession(t *testing.T) {
+	srv, nb := newServer(t)
+
+	if res := post(t, srv, nb, "x = 40\nprint(\"set\")"); res.Stdout != "set\n" || res.Value != nil || res.Error != "" {
+		t.Fatalf("first cell = %+v", res)
+	}
+	res := post(t, srv, nb, "x + 2")
+	if res.Value == nil || res.Value.Kind != "text" || res.Value.Text != "42" {
+		t.Fatalf("second cell = %+v", res)
+	}
+	if res := post(t, srv, nb, "x = 1 +* 2"); !strings.HasPrefix(res.Error, "parse error:") {
+		t.Fatalf("bad cell = %+v", res)
+	}
+	if res := post(t, srv, nb, "x"); res.Value == nil || res.Value.Text != "40" {
+		t.Fatalf("session lost after error: %+v", res)
+	}
+}
+
+func TestRichValues(t *testing.T) {
+	srv, nb := newServer(t)
+
+	res := post(t, srv, nb, `[#{"name": "a", "n": 1}, #{"name": "b", "ok": true}]`)
+	v := res.Value
+	if v == nil || v.Kind != "table" {
+		t.Fatalf("table cell = %+v", res)
+	}
+	if strings.Join(v.Columns, ",") != "n,name,ok" {
+		t.Fatalf("columns = %v", v.Columns)
+	}
+	if strings.Join(v.Rows[0], ",") != "1,a," || strings.Join(v.Rows[1], ",") != ",b,true" {
+		t.Fatalf("rows = %v", v.Rows)
+	}
+
+	res = post(t, srv, nb, "img = image_new(2, 3)\nimage_fill(img, 255, 0, 0, 255)\nimg")
+	if res.Value == nil || res.Value.Kind != "image" || res.Value.PNG == "" {
+		t.Fatalf("image cell = %+v", res)
+	}
+
+	res = post(t, srv, nb, "[1, #{}]")
+	if res.Value == nil || res.Value.Kind != "text" || res.Value.Text != "[1, #{}]" {
+		t.Fatalf("mixed array cell = %+v", res)
+	}
+}
+
+func TestPage(t *testing.T) {
+	srv, nb := newServer(t)
+	if resp, err := http.Get(srv.URL + "/"); err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("page without a token should be rejected")
+	}
+	resp, err := http.Get(srv.URL + "/?token=" + nb.Token())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Fatalf("page: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if resp, err := http.Get(srv.URL + "/run"); err != nil || resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("GET /run should be rejected")
+	}
+}
+
+func TestRunRejectsForeignRequests(t *testing.T) {
+	srv, nb := newServer(t)
+
+	cases := []struct {
+		name string
+		edit func(*http.Request)
+		want int
+	}{
+		{"no token", func(r *http.Request) { r.Header.Del(TokenHeader) }, http.StatusForbidden},
+		{"wrong token", func(r *http.Request) { r.Header.Set(TokenHeader, "guess") }, http.StatusForbidden},
+		{"form body", func(r *http.Request) { r.Header.Set("Content-Type", "text/plain") }, http.StatusUnsupportedMediaType},
+		{"rebound host", func(r *http.Request) { r.Host = "attacker.example:8888" }, http.StatusForbidden},
+		{"other origin", func(r *http.Request) { r.Header.Set("Origin", "http://attacker.example") }, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		req := runRequest(srv, nb.Token(), "print(\"ran\")")
+		tc.edit(req)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("%s: status %d, want %d", tc.name, resp.StatusCode, tc.want)
+		}
+	}
+
+	req := runRequest(srv, nb.Token(), "1")
+	req.Host = "localhost" + strings.TrimPrefix(srv.URL, "http://127.0.0.1")
+	req.Header.Set("Origin", "http://"+req.Host)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("same-origin request: status %d", resp.StatusCode)
+	}
+	if New(nil).Token() == nb.Token() {
+		t.Fatalf("expected a fresh token per server")
+	}
+}
@@ -0,0 +1,100 @@
+package notebook
+
+// page is the whole notebook UI. Cells live only in the browser; Shift+Enter
+// runs the focused cell and moves on, adding a new one at the end.
+const page = `<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>welle notebook</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; color: #222; }
+.cell { margin-bottom: 1.2em; }
+textarea { width: 100%; box-sizing: border-box; font: 14px monospace; padding: .5em; border: 1px solid #bbb; resize: vertical; }
+.out { font: 14px monospace; white-space: pre-wrap; margin: .3em 0 0 .5em; }
+.err { color: #b00; }
+table { border-collapse: collapse; font: 13px monospace; margin: .3em 0 0 .5em; }
+th, td { border: 1px solid #ccc; padding: .2em .6em; text-align: left; }
+th { background: #f2f2f2; }
+img { image-rendering: pixelated; margin: .3em 0 0 .5em; border: 1px solid #ccc; }
+</style>
+</head>
+<body>
+<h3>welle notebook</h3>
+<p>Shift+Enter runs a cell. Cells share one environment, in the order they are run.</p>
+<div id="cells"></div>
+<script>
+const token = new URLSearchParams(location.search).get("token") || "";
+const cells = document.getElementById("cells");
+
+function el(tag, cls, text) {
+  const e = document.createElement(tag);
+  if (cls) e.className = cls;
+  if (text !== undefined) e.textContent = text;
+  return e;
+}
+
+function addCell() {
+  const cell = el("div", "cell");
+  const src = el("textarea");
+  src.rows = 3;
+  const out = el("div");
+  src.addEventListener("keydown", ev => {
+    if (ev.key === "Enter" && ev.shiftKey) {
+      ev.preventDefault();
+      run(cell, src, out);
+    }
+  });
+  cell.append(src, out);
+  cells.append(cell);
+  src.focus();
+  return cell;
+}
+
+function show(out, res) {
+  out.replaceChildren();
+  if (res.stdout) out.append(el("div", "out", res.stdout));
+  if (res.error) { out.append(el("div", "out err", res.error)); return; }
+  const v = res.value;
+  if (!v) return;
+  if (v.kind === "image") {
+    const img = el("img");
+    img.src = "data:image/png;base64," + v.png;
+    out.append(img);
+  } else if (v.kind === "table") {
+    const t = el("table");
+    const head = el("tr");
+    for (const c of v.columns) head.append(el("th", "", c));
+    t.append(head);
+    for (const r of v.rows) {
+      const tr = el("tr");
+      for (const c of r) tr.append(el("td", "", c));
+      t.append(tr);
+    }
+    out.append(t);
+  } else {
+    out.append(el("div", "out", v.text));
+  }
+}
+
+async function run(cell, src, out) {
+  try {
+    const resp = await fetch("/run", {
+      method: "POST",
+      headers: { "Content-Type": "application/json", "X-Welle-Token": token },
+      body: JSON.stringify({ source: src.value }),
+    });
+    if (!resp.ok) throw new Error(await resp.text());
+    show(out, await resp.json());
+  } catch (e) {
+    show(out, { error: String(e) });
+  }
+  const next = cell.nextElementSibling;
+  if (next) next.querySelector("textarea").focus(); else addCell();
+}
+
+addCell();
+</script>
+</body>
+</html>
+`
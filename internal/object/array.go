@@ -0,0 +1,54 @@
+package object
+
+// arrayShare tracks the arrays built on one backing by Append.
+type arrayShare struct {
+	// used is the length of the longest array on the backing; slots past it
+	// are free for the next append.
+	used int
+	// refs counts the arrays on the backing. It never drops when an array
+	// becomes garbage, so Own may copy when it did not strictly need to.
+	refs int
+}
+
+// Append returns a new array holding a's elements followed by v, leaving a
+// unchanged. Appending to the longest array on a backing fills its spare
+// capacity in place, so a chain like `xs = append(xs, v)` is amortized
+// O(1) per call instead of copying every time. The arrays then share a
+// backing until one of them is written in place; see Own.
+func (a *Array) Append(v Object) *Array {
+	n := len(a.Elements)
+	if a.share != nil && a.share.used == n && n < cap(a.Elements) {
+		els := a.Elements[:n+1]
+		els[n] = v
+		a.share.used++
+		a.share.refs++
+		return &Array{Elements: els, share: a.share}
+	}
+	els := make([]Object, n+1, max(2*n, 4))
+	copy(els, a.Elements)
+	els[n] = v
+	return &Array{Elements: els, share: &arrayShare{used: n + 1, refs: 1}}
+}
+
+// Own gives a a backing of its own, copying its elements if Append may have
+// shared the current one. Call it before writing a's elements in place.
+func (a *Array) Own() {
+	if a.share == nil {
+		return
+	}
+	if a.share.refs > 1 {
+		a.share.refs--
+		a.Elements = append([]Object(nil), a.Elements...)
+	}
+	a.share = nil
+}
+
+// SetElements replaces a's elements with els, a slice no other array holds,
+// as slice assignment and deletion do when they rebuild an array.
+func (a *Array) SetElements(els []Object) {
+	if a.share != nil {
+		a.share.refs--
+		a.share = nil
+	}
+	a.Elements = els
+}
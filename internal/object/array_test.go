@@ -0,0 +1,60 @@
+package object
+
+import "testing"
+
+func ints(a *Array) []int64 {
+	out := make([]int64, len(a.Elements))
+	for i, el := range a.Elements {
+		out[i] = el.(*Integer).Value
+	}
+	return out
+}
+
+func TestArrayAppendReusesBacking(t *testing.T) {
+	a := &Array{}
+	for i := int64(0); i < 100; i++ {
+		a = a.Append(&Integer{Value: i})
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		b := a
+		for i := int64(0); i < 8; i++ {
+			b = b.Append(&Integer{Value: i})
+		}
+	})
+	// a is no longer the longest array on its backing after the first run,
+	// so its first append copies (backing and share); every later append
+	// allocates only the Array and the Integer.
+	if allocs > 8*2+2 {
+		t.Fatalf("append chain allocated %.0f times per run", allocs)
+	}
+}
+
+func TestArrayAppendKeepsValueSemantics(t *testing.T) {
+	a := &Array{}
+	a = a.Append(&Integer{Value: 1})
+	a = a.Append(&Integer{Value: 2})
+	b := a.Append(&Integer{Value: 3})
+	c := a.Append(&Integer{Value: 4})
+	if got := ints(b); len(got) != 3 || got[2] != 3 {
+		t.Fatalf("b = %v, want [1 2 3]", got)
+	}
+	if got := ints(c); len(got) != 3 || got[2] != 4 {
+		t.Fatalf("c = %v, want [1 2 4]", got)
+	}
+
+	b.Own()
+	b.Elements[0] = &Integer{Value: 9}
+	if got := ints(a); got[0] != 1 || len(got) != 2 {
+		t.Fatalf("a = %v after writing b, want [1 2]", got)
+	}
+
+	d := a.Append(&Integer{Value: 5})
+	d.Elements = d.Elements[:len(d.Elements)-1]
+	e := d.Append(&Integer{Value: 6})
+	if got := ints(a.Append(&Integer{Value: 7})); got[2] != 7 {
+		t.Fatalf("append after pop clobbered a sibling: %v", got)
+	}
+	if got := ints(e); got[2] != 6 {
+		t.Fatalf("e = %v, want [1 2 6]", got)
+	}
+}
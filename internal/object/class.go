@@ -0,0 +1,100 @@
+package object
+
+import (
+	"fmt"
+
+	"welle/internal/ast"
+)
+
+// Class is the value a class statement binds. Calling it makes an Instance.
+// Each method is a function whose first parameter is self.
+type Class struct {
+	Name   string
+	Fields []string
+	// Defaults holds the starting value of each field, evaluated when the
+	// class statement ran: NIL for a field declared without one.
+	Defaults []Object
+	Methods  map[string]Object
+	index    map[string]int
+}
+
+// NewClass returns the class called name with the given fields, their
+// defaults and its methods.
+func NewClass(name string, fields []string, defaults []Object, methods map[string]Object) *Class {
+	c := &Class{Name: name, Fields: fields, Defaults: defaults, Methods: methods, index: make(map[string]int, len(fields))}
+	for i, f := range fields {
+		c.index[f] = i
+	}
+	return c
+}
+
+func (*Class) Type() Type { return CLASS_OBJ }
+func (c *Class) Inspect() string {
+	return "<class " + c.Name + ">"
+}
+
+// FieldIndex returns the position of the field called name.
+func (c *Class) FieldIndex(name string) (int, bool) {
+	i, ok := c.index[name]
+	return i, ok
+}
+
+// Init returns the class's init method, or nil when it has none.
+func (c *Class) Init() Object {
+	return c.Methods[ast.InitName]
+}
+
+// New returns an instance of c with every field at its default.
+func (c *Class) New() *Instance {
+	fields := make([]Object, len(c.Defaults))
+	copy(fields, c.Defaults)
+	return &Instance{Class: c, Fields: fields}
+}
+
+// Instance is an object made by calling a Class. Fields holds one value per
+// field of the class, in declaration order.
+type Instance struct {
+	Class  *Class
+	Fields []Object
+}
+
+func (*Instance) Type() Type { return INSTANCE_OBJ }
+func (in *Instance) Inspect() string {
+	return InspectWith(in, printOptions)
+}
+
+// GetMember returns the field called name or, when there is none, the
+// method called name bound to in.
+func (in *Instance) GetMember(name string) (Object, bool) {
+	if i, ok := in.Class.FieldIndex(name); ok {
+		return in.Fields[i], true
+	}
+	if m, ok := in.Class.Methods[name]; ok {
+		return &BoundMethod{Receiver: in, Name: name, Method: m}, true
+	}
+	return nil, false
+}
+
+// SetMember assigns the field called name. Instances only have the fields
+// their class declares.
+func (in *Instance) SetMember(name string, value Object) error {
+	i, ok := in.Class.FieldIndex(name)
+	if !ok {
+		return fmt.Errorf("%s has no field %s", in.Class.Name, name)
+	}
+	in.Fields[i] = value
+	return nil
+}
+
+// BoundMethod is a method read off an instance without calling it, as in
+// f = p.move. Calling it calls Method with Receiver as self.
+type BoundMethod struct {
+	Receiver *Instance
+	Name     string
+	Method   Object
+}
+
+func (*BoundMethod) Type() Type { return BOUND_METHOD_OBJ }
+func (b *BoundMethod) Inspect() string {
+	return "<method " + b.Receiver.Class.Name + "." + b.Name + ">"
+}
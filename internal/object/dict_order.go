@@ -25,8 +25,20 @@ func SortedDictPairs(d *Dict) []DictPair {
 	if d == nil || len(d.Pairs) == 0 {
 		return nil
 	}
-	entries := make([]dictSortEntry, 0, len(d.Pairs))
+	pairs := make([]DictPair, 0, len(d.Pairs))
 	for _, pair := range d.Pairs {
+		pairs = append(pairs, pair)
+	}
+	return sortPairs(pairs)
+}
+
+// sortPairs sorts pairs by key in the order SortedDictPairs describes.
+func sortPairs(pairs []DictPair) []DictPair {
+	if len(pairs) == 0 {
+		return nil
+	}
+	entries := make([]dictSortEntry, 0, len(pairs))
+	for _, pair := range pairs {
 		e := dictSortEntry{pair: pair}
 		switch k := pair.Key.(type) {
 		case *Boolean:
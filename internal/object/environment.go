@@ -3,6 +3,10 @@ package object
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+	// function marks the environment of a function call; Locals stops there.
+	function bool
+	// imported marks the top-level environment of an imported module.
+	imported bool
 }
 
 const ExportSetName = "__welle_exports__"
@@ -17,6 +21,14 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	return env
 }
 
+// NewFunctionEnvironment returns the environment for one call of a function
+// defined in outer.
+func NewFunctionEnvironment(outer *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.function = true
+	return env
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
 	if !ok && e.outer != nil {
@@ -56,15 +68,57 @@ func (e *Environment) Snapshot() map[string]Object {
 	return out
 }
 
+// Locals returns the bindings of the innermost function call enclosing e, or
+// of the module when e is not inside a call, together with the catch and
+// comprehension scopes in between. Inner bindings shadow outer ones.
+func (e *Environment) Locals() map[string]Object {
+	var scopes []*Environment
+	for env := e; env != nil; env = env.outer {
+		scopes = append(scopes, env)
+		if env.function {
+			break
+		}
+	}
+	out := map[string]Object{}
+	for i := len(scopes) - 1; i >= 0; i-- {
+		for k, v := range scopes[i].store {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// Globals returns the bindings of the module e belongs to.
+func (e *Environment) Globals() map[string]Object {
+	root := e
+	for root.outer != nil {
+		root = root.outer
+	}
+	return root.Snapshot()
+}
+
+// MarkImported records that e is the top-level environment of an imported
+// module rather than of the entry file.
+func (e *Environment) MarkImported() { e.imported = true }
+
+// IsMain reports whether e belongs to the entry file.
+func (e *Environment) IsMain() bool {
+	root := e
+	for root.outer != nil {
+		root = root.outer
+	}
+	return !root.imported
+}
+
 func (e *Environment) MarkExport(name string) {
 	set, ok := e.store[ExportSetName].(*Dict)
 	if !ok {
-		set = &Dict{Pairs: map[string]DictPair{}}
+		set = &Dict{Pairs: map[HashKey]DictPair{}}
 		e.store[ExportSetName] = set
 	}
 	key := &String{Value: name}
 	hk, _ := HashKeyOf(key)
-	set.Pairs[HashKeyString(hk)] = DictPair{Key: key, Value: &Boolean{Value: true}}
+	set.Pairs[hk] = DictPair{Key: key, Value: &Boolean{Value: true}}
 }
 
 func (e *Environment) ExportedNames() map[string]bool {
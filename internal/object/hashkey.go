@@ -1,10 +1,12 @@
 package object
 
-import "fmt"
-
+// HashKey is a dict key as Dict.Pairs stores it. A string is keyed by its
+// text, so making one allocates nothing and two different strings never
+// share a key; integers and booleans are keyed by their value.
 type HashKey struct {
 	Type  Type
 	Value uint64
+	Str   string
 }
 
 type Hashable interface {
@@ -12,16 +14,13 @@ type Hashable interface {
 }
 
 func (s *String) HashKey() HashKey {
-	const (
-		offset64 = 14695981039346656037
-		prime64  = 1099511628211
-	)
-	var h uint64 = offset64
-	for i := 0; i < len(s.Value); i++ {
-		h ^= uint64(s.Value[i])
-		h *= prime64
-	}
-	return HashKey{Type: STRING_OBJ, Value: h}
+	return StringKey(s.Value)
+}
+
+// StringKey returns the key of the STRING s, for lookups by a Go string
+// without making a String first.
+func StringKey(s string) HashKey {
+	return HashKey{Type: STRING_OBJ, Str: s}
 }
 
 func (i *Integer) HashKey() HashKey {
@@ -42,7 +41,3 @@ func HashKeyOf(o Object) (HashKey, bool) {
 	}
 	return h.HashKey(), true
 }
-
-func HashKeyString(k HashKey) string {
-	return fmt.Sprintf("%s:%d", k.Type, k.Value)
-}
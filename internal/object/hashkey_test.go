@@ -0,0 +1,44 @@
+package object
+
+import "testing"
+
+func TestHashKeysAreExact(t *testing.T) {
+	keys := []Object{
+		&String{Value: "1"},
+		&String{Value: "true"},
+		&String{Value: ""},
+		&String{Value: "STRING:1"},
+		&Integer{Value: 1},
+		&Integer{Value: 0},
+		&Boolean{Value: true},
+		&Boolean{Value: false},
+	}
+	seen := map[HashKey]Object{}
+	for _, k := range keys {
+		hk, ok := HashKeyOf(k)
+		if !ok {
+			t.Fatalf("%s %s is not hashable", k.Type(), k.Inspect())
+		}
+		if prev, dup := seen[hk]; dup {
+			t.Fatalf("%s %s and %s %s share a key", prev.Type(), prev.Inspect(), k.Type(), k.Inspect())
+		}
+		seen[hk] = k
+	}
+	if StringKey("a") != (&String{Value: "a"}).HashKey() {
+		t.Fatal("StringKey differs from String.HashKey")
+	}
+}
+
+func TestStringKeyLookupDoesNotAllocate(t *testing.T) {
+	d := &Dict{Pairs: map[HashKey]DictPair{}}
+	d.Pairs[StringKey("name")] = DictPair{Key: &String{Value: "name"}, Value: &Integer{Value: 1}}
+	key := &String{Value: "name"}
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, ok := d.Pairs[key.HashKey()]; !ok {
+			t.Fatal("missing key")
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("lookup allocated %v times", allocs)
+	}
+}
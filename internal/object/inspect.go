@@ -0,0 +1,180 @@
+package object
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// InspectOptions bound how much of a nested value Inspect writes. Limits
+// of 0 mean no limit.
+type InspectOptions struct {
+	// MaxDepth is how many levels of nested arrays, tuples and dicts are
+	// written; a non-empty container below that is written as [...],
+	// (...) or #{...}.
+	MaxDepth int
+	// MaxLen is how many elements of each container are written before
+	// the rest is replaced by "...".
+	MaxLen int
+	// Indent, when positive, writes each element on its own line, indented
+	// by this many spaces per level.
+	Indent int
+	// FloatPrecision, when positive, rounds floats to that many digits after
+	// the point, dropping trailing zeros. 0 writes the shortest form that
+	// reads back as the same float.
+	FloatPrecision int
+}
+
+// DefaultInspectOptions are the limits print, str and the REPL start with.
+var DefaultInspectOptions = InspectOptions{MaxDepth: 64, MaxLen: 10_000}
+
+// printOptions are the options Inspect uses; set_print_options changes them
+// for the rest of the process.
+var printOptions = DefaultInspectOptions
+
+// PrintOptions returns the options Inspect currently uses.
+func PrintOptions() InspectOptions { return printOptions }
+
+// SetPrintOptions replaces the options Inspect uses. Indent is ignored:
+// Inspect always writes one line.
+func SetPrintOptions(opts InspectOptions) {
+	opts.Indent = 0
+	printOptions = opts
+}
+
+// InspectWith writes obj like Inspect, within opts. A container that holds
+// itself, directly or through others, is written as [...], (...) or #{...}
+// where it recurs, so self-referential values print in finite space.
+func InspectWith(obj Object, opts InspectOptions) string {
+	p := inspector{opts: opts}
+	p.write(obj, 0)
+	return p.out.String()
+}
+
+type inspector struct {
+	opts   InspectOptions
+	out    strings.Builder
+	active map[Object]bool
+}
+
+func (p *inspector) write(obj Object, depth int) {
+	switch v := obj.(type) {
+	case *Array:
+		p.container(v, "[", "]", len(v.Elements), depth, func(i int) {
+			p.write(v.Elements[i], depth+1)
+		})
+	case *Tuple:
+		p.container(v, "(", ")", len(v.Elements), depth, func(i int) {
+			if v.Names != nil {
+				p.out.WriteString(v.Names[i] + ": ")
+				p.write(v.Elements[i], depth+1)
+				return
+			}
+			p.write(v.Elements[i], depth+1)
+			if len(v.Elements) == 1 {
+				p.out.WriteString(",")
+			}
+		})
+	case *Dict:
+		pairs := SortedDictPairs(v)
+		p.container(v, "#{", "}", len(pairs), depth, func(i int) {
+			if ks, ok := pairs[i].Key.(*String); ok {
+				p.out.WriteString(`"` + ks.Value + `": `)
+			} else {
+				p.write(pairs[i].Key, depth+1)
+				p.out.WriteString(": ")
+			}
+			p.write(pairs[i].Value, depth+1)
+		})
+	case *Set:
+		items := SortedSetItems(v)
+		p.container(v, "#[", "]", len(items), depth, func(i int) {
+			if s, ok := items[i].(*String); ok {
+				p.out.WriteString(`"` + s.Value + `"`)
+				return
+			}
+			p.write(items[i], depth+1)
+		})
+	case *Instance:
+		p.container(v, v.Class.Name+"(", ")", len(v.Fields), depth, func(i int) {
+			p.out.WriteString(v.Class.Fields[i] + ": ")
+			p.write(v.Fields[i], depth+1)
+		})
+	case *Float:
+		p.out.WriteString(formatFloatPrecision(v.Value, p.opts.FloatPrecision))
+	default:
+		p.out.WriteString(obj.Inspect())
+	}
+}
+
+// formatFloatPrecision is FormatFloat rounded to prec digits after the
+// point. A value that rounds to zero, and one FormatFloat writes with an
+// exponent, keeps prec significant digits instead.
+func formatFloatPrecision(f float64, prec int) string {
+	short := FormatFloat(f)
+	if prec <= 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return short
+	}
+	if strings.ContainsRune(short, 'e') {
+		return strconv.FormatFloat(f, 'g', prec, 64)
+	}
+	out := strconv.FormatFloat(f, 'f', prec, 64)
+	out = strings.TrimRight(out, "0")
+	out = strings.TrimSuffix(out, ".")
+	if (out == "0" || out == "-0") && f != 0 {
+		return strconv.FormatFloat(f, 'g', prec, 64)
+	}
+	return out
+}
+
+// container writes n elements between open and close, calling elem for
+// each one it keeps.
+func (p *inspector) container(obj Object, open, close string, n, depth int, elem func(i int)) {
+	if n == 0 {
+		p.out.WriteString(open + close)
+		return
+	}
+	if p.active[obj] || (p.opts.MaxDepth > 0 && depth >= p.opts.MaxDepth) {
+		p.out.WriteString(open + "..." + close)
+		return
+	}
+	if p.active == nil {
+		p.active = map[Object]bool{}
+	}
+	p.active[obj] = true
+	defer delete(p.active, obj)
+
+	shown := n
+	if p.opts.MaxLen > 0 && shown > p.opts.MaxLen {
+		shown = p.opts.MaxLen
+	}
+	p.out.WriteString(open)
+	for i := 0; i < shown; i++ {
+		if i > 0 {
+			p.out.WriteString(",")
+			if p.opts.Indent <= 0 {
+				p.out.WriteString(" ")
+			}
+		}
+		p.newline(depth + 1)
+		elem(i)
+	}
+	if shown < n {
+		p.out.WriteString(",")
+		if p.opts.Indent <= 0 {
+			p.out.WriteString(" ")
+		}
+		p.newline(depth + 1)
+		p.out.WriteString("...")
+	}
+	p.newline(depth)
+	p.out.WriteString(close)
+}
+
+func (p *inspector) newline(depth int) {
+	if p.opts.Indent <= 0 {
+		return
+	}
+	p.out.WriteString("\n")
+	p.out.WriteString(strings.Repeat(" ", depth*p.opts.Indent))
+}
@@ -0,0 +1,69 @@
+package object
+
+import "testing"
+
+func TestInspectCycles(t *testing.T) {
+	a := &Array{Elements: []Object{&Integer{Value: 1}, nil}}
+	a.Elements[1] = a
+	if got := a.Inspect(); got != "[1, [...]]" {
+		t.Fatalf("self-referential array = %s", got)
+	}
+
+	d := &Dict{Pairs: map[HashKey]DictPair{}}
+	key := &String{Value: "self"}
+	hk, _ := HashKeyOf(key)
+	d.Pairs[hk] = DictPair{Key: key, Value: &Tuple{Elements: []Object{d}}}
+	if got := d.Inspect(); got != `#{"self": (#{...},)}` {
+		t.Fatalf("dict cycle through a tuple = %s", got)
+	}
+
+	// A value seen twice without a cycle is written in full both times.
+	inner := &Array{Elements: []Object{&Integer{Value: 2}}}
+	shared := &Array{Elements: []Object{inner, inner}}
+	if got := shared.Inspect(); got != "[[2], [2]]" {
+		t.Fatalf("shared element = %s", got)
+	}
+}
+
+func TestInspectWithLimits(t *testing.T) {
+	nested := &Array{Elements: []Object{&Integer{Value: 1}, &Array{Elements: []Object{&Array{Elements: []Object{&Integer{Value: 3}}}, &Array{}}}}}
+	if got := InspectWith(nested, InspectOptions{MaxDepth: 2}); got != "[1, [[...], []]]" {
+		t.Fatalf("depth 2 = %s", got)
+	}
+	long := &Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}, &Integer{Value: 3}}}
+	if got := InspectWith(long, InspectOptions{MaxLen: 2}); got != "[1, 2, ...]" {
+		t.Fatalf("length 2 = %s", got)
+	}
+	want := "[\n  1,\n  [\n    [\n      3\n    ],\n    []\n  ]\n]"
+	if got := InspectWith(nested, InspectOptions{Indent: 2}); got != want {
+		t.Fatalf("indent 2 = %q, want %q", got, want)
+	}
+}
+
+func TestFormatFloatPrecision(t *testing.T) {
+	tenth, fifth := 0.1, 0.2
+	tests := []struct {
+		f    float64
+		prec int
+		want string
+	}{
+		{tenth + fifth, 0, "0.30000000000000004"},
+		{tenth + fifth, 6, "0.3"},
+		{2.0 / 3, 3, "0.667"},
+		{1.5, 3, "1.5"},
+		{2, 3, "2"},
+		{-0.0001, 3, "-0.0001"},
+		{1e22, 3, "1e+22"},
+		{1e20, 0, "100000000000000000000"},
+		{123456789.123, 0, "123456789.123"},
+		{1e21, 0, "1e+21"},
+		{0.0001, 0, "0.0001"},
+		{0.00001, 0, "1e-05"},
+		{123456.789, 1, "123456.8"},
+	}
+	for _, tt := range tests {
+		if got := formatFloatPrecision(tt.f, tt.prec); got != tt.want {
+			t.Errorf("formatFloatPrecision(%v, %d) = %q, want %q", tt.f, tt.prec, got, tt.want)
+		}
+	}
+}
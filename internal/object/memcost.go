@@ -12,6 +12,13 @@ const (
 	memFunctionHead int64 = 64
 	memClosureHead  int64 = 32
 	memCellHead     int64 = 16
+	memSeqHead      int64 = 32
+	memSeqStage     int64 = 32
+	memStopwatch    int64 = 56
+	memClassHead    int64 = 64
+	memInstanceHead int64 = 32
+	memGenerator    int64 = 64
+	memRangeHead    int64 = 24
 	memImagePixel   int64 = 4
 )
 
@@ -84,3 +91,91 @@ func CostClosure(numFree int) int64 {
 func CostCell() int64 {
 	return memCellHead
 }
+
+func CostSet(n int) int64 {
+	if n < 0 {
+		return memDictHead
+	}
+	return memDictHead + int64(n)*memDictEntry
+}
+
+func CostSeq(stages int) int64 {
+	if stages < 0 {
+		return memSeqHead
+	}
+	return memSeqHead + int64(stages)*memSeqStage
+}
+
+func CostStopwatch() int64 {
+	return memStopwatch
+}
+
+func CostClass(fields, methods int) int64 {
+	if fields < 0 || methods < 0 {
+		return memClassHead
+	}
+	return memClassHead + int64(fields)*memPtrSize + int64(fields+methods)*memDictEntry
+}
+
+func CostInstance(fields int) int64 {
+	if fields < 0 {
+		return memInstanceHead
+	}
+	return memInstanceHead + int64(fields)*memPtrSize
+}
+
+// CostGenerator is the charge for a suspended call with slots stack slots.
+func CostGenerator(slots int) int64 {
+	if slots < 0 {
+		return memGenerator
+	}
+	return memGenerator + int64(slots)*memPtrSize
+}
+
+func CostRange() int64 {
+	return memRangeHead
+}
+
+// CostRangeStep is the charge for each element a range hands out while it
+// is iterated, what one element of the array it stands for would cost.
+func CostRangeStep() int64 {
+	return memPtrSize
+}
+
+// CostOf returns the charge for obj itself: the header and direct storage of
+// strings, containers, images, errors, closures and cells. Elements held by a
+// container are charged when they are created, not here.
+func CostOf(obj Object) int64 {
+	switch v := obj.(type) {
+	case *String:
+		return CostStringBytes(len(v.Value))
+	case *Array:
+		return CostArray(len(v.Elements))
+	case *Tuple:
+		return CostTuple(len(v.Elements))
+	case *Dict:
+		return CostDict(len(v.Pairs))
+	case *Set:
+		return CostSet(len(v.Items))
+	case *Image:
+		return CostImage(v.Width, v.Height)
+	case *Error:
+		return CostError()
+	case *Closure:
+		return CostClosure(len(v.Free))
+	case *Cell:
+		return CostCell()
+	case *Seq:
+		return CostSeq(len(v.Stages))
+	case *Stopwatch:
+		return CostStopwatch()
+	case *Class:
+		return CostClass(len(v.Fields), len(v.Methods))
+	case *Instance:
+		return CostInstance(len(v.Fields))
+	case *Range:
+		return CostRange()
+	default:
+		return 0
+	}
+}
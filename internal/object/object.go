@@ -3,6 +3,7 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 
@@ -25,6 +26,8 @@ const (
 	COMPILED_FUNCTION_OBJ Type = "COMPILED_FUNCTION"
 	CLOSURE_OBJ           Type = "CLOSURE"
 	CELL_OBJ              Type = "CELL"
+	JUMP_TABLE_OBJ        Type = "JUMP_TABLE"
+	TUPLE_SHAPE_OBJ       Type = "TUPLE_SHAPE"
 	ARRAY_OBJ             Type = "ARRAY"
 	TUPLE_OBJ             Type = "TUPLE"
 	DICT_OBJ              Type = "DICT"
@@ -32,6 +35,15 @@ const (
 	SPREAD_OBJ            Type = "SPREAD"
 	ERROR_OBJ             Type = "ERROR"
 	IMAGE_OBJ             Type = "IMAGE"
+	SEQ_OBJ               Type = "SEQ"
+	SET_OBJ               Type = "SET"
+	STOPWATCH_OBJ         Type = "STOPWATCH"
+	CLASS_SHAPE_OBJ       Type = "CLASS_SHAPE"
+	CLASS_OBJ             Type = "CLASS"
+	INSTANCE_OBJ          Type = "INSTANCE"
+	BOUND_METHOD_OBJ      Type = "BOUND_METHOD"
+	GENERATOR_OBJ         Type = "GENERATOR"
+	RANGE_OBJ             Type = "RANGE"
 )
 
 type Object interface {
@@ -46,12 +58,26 @@ func (i *Integer) Inspect() string { return itoa(i.Value) }
 
 type Float struct{ Value float64 }
 
-func (*Float) Type() Type { return FLOAT_OBJ }
-func (f *Float) Inspect() string {
-	return strconv.FormatFloat(f.Value, 'g', -1, 64)
+func (*Float) Type() Type        { return FLOAT_OBJ }
+func (f *Float) Inspect() string { return formatFloatPrecision(f.Value, printOptions.FloatPrecision) }
+
+// FormatFloat is the display form of a FLOAT shared by print, str, string
+// interpolation and both engines: the shortest decimal that parses back to
+// the same float64 (0.1 + 0.2 prints 0.30000000000000004), in exponent form
+// below 1e-4 and from 1e21 up, and +Inf, -Inf or NaN for the special values.
+func FormatFloat(f float64) string {
+	if abs := math.Abs(f); abs == 0 || (abs >= 1e-4 && abs < 1e21) {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
 }
 
-type String struct{ Value string }
+type String struct {
+	Value string
+	// runes indexes Value by code point once it has been indexed or
+	// sliced; see runeIndex.
+	runes runeIndex
+}
 
 func (*String) Type() Type        { return STRING_OBJ }
 func (s *String) Inspect() string { return s.Value }
@@ -90,8 +116,11 @@ type Function struct {
 	Name       string
 	File       string
 	Parameters []*ast.Identifier
-	Body       *ast.BlockStatement
-	Env        *Environment
+	// Defaults holds the values of the last len(Defaults) parameters,
+	// evaluated when the function was defined.
+	Defaults []Object
+	Body     *ast.BlockStatement
+	Env      *Environment
 }
 
 func (*Function) Type() Type { return FUNCTION_OBJ }
@@ -112,9 +141,14 @@ type CompiledFunction struct {
 	Instructions  code.Instructions
 	NumLocals     int
 	NumParameters int
-	Name          string
-	File          string
-	Pos           []code.SourcePos
+	// LocalNames names each local slot, "" for compiler temporaries.
+	LocalNames []string
+	Name       string
+	File       string
+	Pos        []code.SourcePos
+	// Generator is set when the body yields: a call returns a generator
+	// that runs the body as it is iterated.
+	Generator bool
 }
 
 func (*CompiledFunction) Type() Type { return COMPILED_FUNCTION_OBJ }
@@ -123,8 +157,48 @@ func (*CompiledFunction) Inspect() string {
 }
 
 type Closure struct {
-	Fn   *CompiledFunction
-	Free []*Cell
+	Fn *CompiledFunction
+	// Free holds one entry per captured variable: a *Cell shared with the
+	// defining function when the variable may be rebound, otherwise a copy
+	// of its value.
+	Free []Object
+	// Defaults holds the values of the function's last len(Defaults)
+	// parameters, evaluated when the closure was made.
+	Defaults []Object
+	// Module is the module that created the closure.
+	Module *Module
+}
+
+// Module is what a module's code runs against: its constant pool and its
+// segment of global slots. Every closure a module creates refers to it, so
+// an imported function keeps using its own module's constants and globals
+// wherever it is called from.
+type Module struct {
+	Constants []Object
+	Globals   []Object
+	// GlobalNames names each global slot, "" for compiler temporaries.
+	GlobalNames []string
+	// Imported is set on modules other than the entry file; is_main()
+	// reports false in their code.
+	Imported bool
+}
+
+// Global returns the value in global slot idx, or nil if it was never set.
+func (m *Module) Global(idx int) Object {
+	if idx >= len(m.Globals) {
+		return nil
+	}
+	return m.Globals[idx]
+}
+
+// SetGlobal stores val in global slot idx, growing the segment if needed.
+func (m *Module) SetGlobal(idx int, val Object) {
+	if idx >= len(m.Globals) {
+		globals := make([]Object, max(idx+1, 2*len(m.Globals)))
+		copy(globals, m.Globals)
+		m.Globals = globals
+	}
+	m.Globals[idx] = val
 }
 
 func (*Closure) Type() Type { return CLOSURE_OBJ }
@@ -141,6 +215,58 @@ func (*Cell) Inspect() string {
 	return "<cell>"
 }
 
+// JumpTable is a compiler-generated constant for OpJumpTable. It maps the
+// constant case values of a switch or match to instruction offsets; only one
+// of Ints and Strings is set.
+type JumpTable struct {
+	Ints    map[int64]int
+	Strings map[string]int
+	// Default is the target for a value of the key type that matches no case.
+	Default int
+}
+
+func (*JumpTable) Type() Type { return JUMP_TABLE_OBJ }
+func (*JumpTable) Inspect() string {
+	return "<jump table>"
+}
+
+// Lookup returns the jump target for v. ok is false when v is not of the
+// table's key type, since comparing it may still match a case or fail.
+func (t *JumpTable) Lookup(v Object) (target int, ok bool) {
+	switch v := v.(type) {
+	case *Integer:
+		if t.Ints == nil {
+			return 0, false
+		}
+		if ip, hit := t.Ints[v.Value]; hit {
+			return ip, true
+		}
+	case *String:
+		if t.Strings == nil {
+			return 0, false
+		}
+		if ip, hit := t.Strings[v.Value]; hit {
+			return ip, true
+		}
+	default:
+		return 0, false
+	}
+	return t.Default, true
+}
+
+// Targets calls fn with a pointer to every offset in the table.
+func (t *JumpTable) Targets(fn func(*int)) {
+	for k, ip := range t.Ints {
+		fn(&ip)
+		t.Ints[k] = ip
+	}
+	for k, ip := range t.Strings {
+		fn(&ip)
+		t.Strings[k] = ip
+	}
+	fn(&t.Default)
+}
+
 type BuiltinFunction func(args ...Object) Object
 
 type Builtin struct{ Fn BuiltinFunction }
@@ -150,41 +276,60 @@ func (*Builtin) Inspect() string { return "<builtin>" }
 
 type Array struct {
 	Elements []Object
+	// share is set on arrays made by Append, whose backing may be shared
+	// with other arrays; see Own.
+	share *arrayShare
 }
 
 func (*Array) Type() Type { return ARRAY_OBJ }
 func (a *Array) Inspect() string {
-	var out bytes.Buffer
-	out.WriteString("[")
-	for i, el := range a.Elements {
-		if i > 0 {
-			out.WriteString(", ")
-		}
-		out.WriteString(el.Inspect())
-	}
-	out.WriteString("]")
-	return out.String()
+	return InspectWith(a, printOptions)
 }
 
 type Tuple struct {
 	Elements []Object
+	// Names is set on a named tuple, (x: 1, y: 2), and holds the field name
+	// of each element. Tuples built from the same literal share it.
+	Names []string
 }
 
 func (*Tuple) Type() Type { return TUPLE_OBJ }
 func (t *Tuple) Inspect() string {
-	var out bytes.Buffer
-	out.WriteString("(")
-	for i, el := range t.Elements {
-		if i > 0 {
-			out.WriteString(", ")
+	return InspectWith(t, printOptions)
+}
+
+// GetMember returns the element of a named tuple called name.
+func (t *Tuple) GetMember(name string) (Object, bool) {
+	for i, n := range t.Names {
+		if n == name {
+			return t.Elements[i], true
 		}
-		out.WriteString(el.Inspect())
-	}
-	if len(t.Elements) == 1 {
-		out.WriteString(",")
 	}
-	out.WriteString(")")
-	return out.String()
+	return nil, false
+}
+
+// TupleShape is a compiler-generated constant for OpNamedTuple: the field
+// names of a named tuple literal.
+type TupleShape struct {
+	Names []string
+}
+
+func (*TupleShape) Type() Type { return TUPLE_SHAPE_OBJ }
+func (*TupleShape) Inspect() string {
+	return "<tuple shape>"
+}
+
+// ClassShape is a compiler-generated constant for OpClass: the name, field
+// names and method names of a class statement.
+type ClassShape struct {
+	Name    string
+	Fields  []string
+	Methods []string
+}
+
+func (*ClassShape) Type() Type { return CLASS_SHAPE_OBJ }
+func (*ClassShape) Inspect() string {
+	return "<class shape>"
 }
 
 type DictPair struct {
@@ -193,28 +338,16 @@ type DictPair struct {
 }
 
 type Dict struct {
-	Pairs map[string]DictPair
+	Pairs map[HashKey]DictPair
+	// Frozen is set on a module's export table once the module has run, so
+	// one importer cannot change what the others see. Assigning into a
+	// frozen dict or removing from it is an error.
+	Frozen bool
 }
 
 func (*Dict) Type() Type { return DICT_OBJ }
 func (d *Dict) Inspect() string {
-	var out bytes.Buffer
-	out.WriteString("#{")
-	pairs := SortedDictPairs(d)
-	for i, pair := range pairs {
-		if i > 0 {
-			out.WriteString(", ")
-		}
-		if ks, ok := pair.Key.(*String); ok {
-			out.WriteString(`"` + ks.Value + `": `)
-		} else {
-			out.WriteString(pair.Key.Inspect())
-			out.WriteString(": ")
-		}
-		out.WriteString(pair.Value.Inspect())
-	}
-	out.WriteString("}")
-	return out.String()
+	return InspectWith(d, printOptions)
 }
 
 type Spread struct {
@@ -232,51 +365,58 @@ func (s *Spread) Inspect() string {
 }
 
 // tiny helper to avoid fmt import
-func itoa(n int64) string {
-	// simple base10
-	if n == 0 {
-		return "0"
-	}
-	neg := n < 0
-	if neg {
-		n = -n
-	}
-	var buf [32]byte
-	i := len(buf)
-	for n > 0 {
-		i--
-		buf[i] = byte('0' + (n % 10))
-		n /= 10
-	}
-	if neg {
-		i--
-		buf[i] = '-'
-	}
-	return string(buf[i:])
-}
+func itoa(n int64) string { return strconv.FormatInt(n, 10) }
 
 type Error struct {
 	Message string
 	Code    int64
+	// Tag is the code when a script gives a string one, such as
+	// error("denied", "E_AUTH"); Code is 0 then.
+	Tag string
+	// Data is the dict given to error() with details for handlers, or nil.
+	Data    *Dict
 	Stack   string
 	IsValue bool
+	// File and Span locate where the error was raised, as precisely as
+	// the VM can tell: the operand at fault when it knows which one,
+	// otherwise the whole expression. Span is zero when unknown (e.g. in
+	// the interpreter).
+	File string
+	Span code.Span
 }
 
 func (*Error) Type() Type { return ERROR_OBJ }
 
 func (e *Error) Inspect() string {
+	if e.Tag != "" {
+		return fmt.Sprintf("error(%s): %s", e.Tag, e.Message)
+	}
 	if e.Code != 0 {
 		return fmt.Sprintf("error(%d): %s", e.Code, e.Message)
 	}
 	return "error: " + e.Message
 }
 
+// CodeValue returns the code as a script sees it: the tag when there is
+// one, otherwise the integer code.
+func (e *Error) CodeValue() Object {
+	if e.Tag != "" {
+		return &String{Value: e.Tag}
+	}
+	return &Integer{Value: e.Code}
+}
+
 func (e *Error) GetMember(name string) (Object, bool) {
 	switch name {
 	case "message":
 		return &String{Value: e.Message}, true
 	case "code":
-		return &Integer{Value: e.Code}, true
+		return e.CodeValue(), true
+	case "data":
+		if e.Data == nil {
+			return &Dict{Pairs: map[HashKey]DictPair{}}, true
+		}
+		return e.Data, true
 	case "stack":
 		return &String{Value: e.Stack}, true
 	default:
@@ -0,0 +1,82 @@
+package object
+
+import (
+	"fmt"
+	"math"
+)
+
+// Range is what range() returns: the integers from Start up to, or down to,
+// Stop (exclusive) in steps of Step, which is never 0. Its elements are
+// worked out as they are needed, so iterating range(10_000_000) does not
+// build an array; indexing, len and `in` need no elements at all.
+type Range struct {
+	Start, Stop, Step int64
+}
+
+func (*Range) Type() Type { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("range(%d, %d)", r.Start, r.Stop)
+	}
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.Stop, r.Step)
+}
+
+// Len returns the number of elements of r, capped at math.MaxInt64.
+func (r *Range) Len() int64 {
+	var span, step uint64
+	switch {
+	case r.Step > 0 && r.Start < r.Stop:
+		span, step = uint64(r.Stop)-uint64(r.Start), uint64(r.Step)
+	case r.Step < 0 && r.Start > r.Stop:
+		span, step = uint64(r.Start)-uint64(r.Stop), -uint64(r.Step)
+	default:
+		return 0
+	}
+	n := (span-1)/step + 1
+	if n > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(n)
+}
+
+// At returns element i of r, which must be in [0, Len()).
+func (r *Range) At(i int64) int64 {
+	return r.Start + i*r.Step
+}
+
+// Contains reports whether n is an element of r.
+func (r *Range) Contains(n int64) bool {
+	if r.Step > 0 && (n < r.Start || n >= r.Stop) || r.Step < 0 && (n > r.Start || n <= r.Stop) {
+		return false
+	}
+	if r.Step > 0 {
+		return (uint64(n)-uint64(r.Start))%uint64(r.Step) == 0
+	}
+	return (uint64(r.Start)-uint64(n))%-uint64(r.Step) == 0
+}
+
+// Equal reports whether r and o have the same elements in the same order.
+func (r *Range) Equal(o *Range) bool {
+	n := r.Len()
+	switch {
+	case n != o.Len():
+		return false
+	case n == 0:
+		return true
+	case n == 1:
+		return r.Start == o.Start
+	default:
+		return r.Start == o.Start && r.Step == o.Step
+	}
+}
+
+// Array returns the elements of r as a new array. Charge CostArray(Len())
+// first: a large range makes a large array.
+func (r *Range) Array() *Array {
+	n := r.Len()
+	els := make([]Object, n)
+	for i := range n {
+		els[i] = &Integer{Value: r.At(i)}
+	}
+	return &Array{Elements: els}
+}
@@ -0,0 +1,71 @@
+package object
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestRangeLenAndAt(t *testing.T) {
+	tests := []struct {
+		r    Range
+		want []int64
+	}{
+		{Range{0, 5, 1}, []int64{0, 1, 2, 3, 4}},
+		{Range{10, 0, -3}, []int64{10, 7, 4, 1}},
+		{Range{2, 9, 3}, []int64{2, 5, 8}},
+		{Range{5, 5, 1}, nil},
+		{Range{5, 0, 1}, nil},
+		{Range{0, 5, -1}, nil},
+	}
+	for _, tt := range tests {
+		got := ints(tt.r.Array())
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.r.Inspect(), got, tt.want)
+		}
+		if n := tt.r.Len(); n != int64(len(tt.want)) {
+			t.Errorf("%s.Len() = %d, want %d", tt.r.Inspect(), n, len(tt.want))
+		}
+		for _, el := range tt.want {
+			if !tt.r.Contains(el) {
+				t.Errorf("%s does not contain %d", tt.r.Inspect(), el)
+			}
+		}
+	}
+}
+
+func TestRangeExtremes(t *testing.T) {
+	r := Range{math.MinInt64, math.MaxInt64, 1}
+	if n := r.Len(); n != math.MaxInt64 {
+		t.Fatalf("Len() = %d, want capped at MaxInt64", n)
+	}
+	r = Range{math.MinInt64, math.MaxInt64, math.MaxInt64}
+	if n := r.Len(); n != 3 {
+		t.Fatalf("Len() = %d, want 3", n)
+	}
+	if !r.Contains(math.MaxInt64-1) || r.Contains(0) {
+		t.Fatalf("Contains is wrong across the whole int range")
+	}
+	r = Range{math.MaxInt64, math.MinInt64, -2}
+	if !r.Contains(math.MaxInt64-2) || r.Contains(math.MaxInt64-1) || r.Contains(math.MinInt64) {
+		t.Fatalf("Contains is wrong for a descending range")
+	}
+}
+
+func TestRangeEqual(t *testing.T) {
+	tests := []struct {
+		a, b Range
+		want bool
+	}{
+		{Range{0, 5, 2}, Range{0, 6, 2}, true},
+		{Range{0, 0, 1}, Range{3, 1, 1}, true},
+		{Range{4, 5, 1}, Range{4, 0, -7}, true},
+		{Range{0, 3, 1}, Range{1, 4, 1}, false},
+		{Range{0, 4, 1}, Range{0, 4, 2}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Equal(&tt.b); got != tt.want {
+			t.Errorf("%s == %s: got %v, want %v", tt.a.Inspect(), tt.b.Inspect(), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package object
+
+// SeqOp is the kind of one stage of a Seq pipeline.
+type SeqOp int
+
+const (
+	SeqMap SeqOp = iota
+	SeqFilter
+	SeqTake
+)
+
+// SeqStage is one step of a Seq: map and filter apply Fn to each element,
+// take lets the first N elements through.
+type SeqStage struct {
+	Op SeqOp
+	Fn Object
+	N  int64
+}
+
+// Seq is the lazy pipeline built by seq(array).map(f).filter(g).take(n).
+// It only describes the work: elements are pulled from Source one at a
+// time, through every stage, when the pipeline is iterated or collected.
+// Adding a stage returns a new Seq, so a pipeline can be shared and reused.
+type Seq struct {
+	Source *Array
+	Stages []SeqStage
+}
+
+func (*Seq) Type() Type { return SEQ_OBJ }
+func (s *Seq) Inspect() string {
+	return "<seq>"
+}
+
+// With returns a copy of s with stage appended.
+func (s *Seq) With(stage SeqStage) *Seq {
+	stages := make([]SeqStage, len(s.Stages), len(s.Stages)+1)
+	copy(stages, s.Stages)
+	return &Seq{Source: s.Source, Stages: append(stages, stage)}
+}
@@ -0,0 +1,42 @@
+package object
+
+// Set is an unordered collection of distinct hashable values (strings,
+// integers and booleans), keyed like dict keys. It is written #[1, 2, 3],
+// in the same deterministic order as dict keys.
+type Set struct {
+	Items map[HashKey]Object
+}
+
+func NewSet(n int) *Set {
+	return &Set{Items: make(map[HashKey]Object, n)}
+}
+
+func (*Set) Type() Type { return SET_OBJ }
+func (s *Set) Inspect() string {
+	return InspectWith(s, printOptions)
+}
+
+// Add puts val in s. ok is false, and s unchanged, when val is not
+// hashable.
+func (s *Set) Add(val Object) bool {
+	hk, ok := HashKeyOf(val)
+	if !ok {
+		return false
+	}
+	s.Items[hk] = val
+	return true
+}
+
+// SortedSetItems returns the items of s in the order dict keys are sorted.
+func SortedSetItems(s *Set) []Object {
+	pairs := make([]DictPair, 0, len(s.Items))
+	for _, item := range s.Items {
+		pairs = append(pairs, DictPair{Key: item})
+	}
+	pairs = sortPairs(pairs)
+	out := make([]Object, len(pairs))
+	for i, pair := range pairs {
+		out[i] = pair.Key
+	}
+	return out
+}
@@ -0,0 +1,16 @@
+package object
+
+import "time"
+
+// Stopwatch is the timer returned by stopwatch(). Both times carry Go's
+// monotonic clock reading, so readings are unaffected by wall clock changes.
+type Stopwatch struct {
+	Start time.Time
+	// Lap is when the current lap began: Start, or the last lap() call.
+	Lap time.Time
+}
+
+func (*Stopwatch) Type() Type { return STOPWATCH_OBJ }
+func (*Stopwatch) Inspect() string {
+	return "<stopwatch>"
+}
@@ -0,0 +1,90 @@
+package object
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// runeIndex caches where each code point of a string starts, so repeated
+// indexing and slicing do not decode the string into runes every time.
+type runeIndex struct {
+	state runeIndexState
+	// offsets holds the byte offset of every code point plus len(Value);
+	// it is only built for valid non-ASCII strings.
+	offsets []int32
+}
+
+type runeIndexState uint8
+
+const (
+	runesUnindexed runeIndexState = iota
+	runesASCII
+	runesOffsets
+	// runesInvalid marks strings that are not valid UTF-8; they are decoded
+	// each time so invalid bytes still read as U+FFFD.
+	runesInvalid
+)
+
+func (s *String) index() *runeIndex {
+	idx := &s.runes
+	if idx.state != runesUnindexed {
+		return idx
+	}
+	switch n := utf8.RuneCountInString(s.Value); {
+	case !utf8.ValidString(s.Value):
+		idx.state = runesInvalid
+	case n == len(s.Value):
+		idx.state = runesASCII
+	default:
+		offsets := make([]int32, 0, n+1)
+		for i := range s.Value {
+			offsets = append(offsets, int32(i))
+		}
+		idx.offsets = append(offsets, int32(len(s.Value)))
+		idx.state = runesOffsets
+	}
+	return idx
+}
+
+// RuneCount returns the number of code points in s.
+func (s *String) RuneCount() int {
+	switch s.runes.state {
+	case runesASCII:
+		return len(s.Value)
+	case runesOffsets:
+		return len(s.runes.offsets) - 1
+	}
+	return utf8.RuneCountInString(s.Value)
+}
+
+// Substring returns the code points of s from index lo up to, not
+// including, hi. The indexes must be in range.
+func (s *String) Substring(lo, hi int) string {
+	idx := s.index()
+	switch idx.state {
+	case runesASCII:
+		return s.Value[lo:hi]
+	case runesOffsets:
+		return s.Value[idx.offsets[lo]:idx.offsets[hi]]
+	}
+	return string([]rune(s.Value)[lo:hi])
+}
+
+// RuneAt returns the code point at index i as a string.
+func (s *String) RuneAt(i int) string {
+	return s.Substring(i, i+1)
+}
+
+// SliceStep returns the code points from lo towards hi, exclusive, taking
+// every step-th one; step may be negative. The bounds must already be
+// clamped to the string.
+func (s *String) SliceStep(lo, hi, step int) string {
+	if step == 1 {
+		return s.Substring(lo, hi)
+	}
+	var b strings.Builder
+	for i := lo; (step > 0 && i < hi) || (step < 0 && i > hi); i += step {
+		b.WriteString(s.RuneAt(i))
+	}
+	return b.String()
+}
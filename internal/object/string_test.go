@@ -0,0 +1,34 @@
+package object
+
+import "testing"
+
+func TestStringRuneIndex(t *testing.T) {
+	tests := []struct {
+		value string
+		count int
+		at2   string
+		rev   string // SliceStep(count-1, -1, -1)
+	}{
+		{"hello", 5, "l", "olleh"},
+		{"héllo世", 6, "l", "世olléh"},
+		{"ab\xffc", 4, "�", "c�ba"},
+	}
+	for _, tt := range tests {
+		s := &String{Value: tt.value}
+		if got := s.RuneCount(); got != tt.count {
+			t.Errorf("%+q: RuneCount() = %d, want %d", tt.value, got, tt.count)
+		}
+		if got := s.RuneAt(2); got != tt.at2 {
+			t.Errorf("%+q: RuneAt(2) = %+q, want %+q", tt.value, got, tt.at2)
+		}
+		if got := s.RuneCount(); got != tt.count {
+			t.Errorf("%+q: RuneCount() after indexing = %d, want %d", tt.value, got, tt.count)
+		}
+		if got := s.SliceStep(tt.count-1, -1, -1); got != tt.rev {
+			t.Errorf("%+q: reversed = %+q, want %+q", tt.value, got, tt.rev)
+		}
+		if got := s.Substring(1, tt.count); got != s.SliceStep(1, tt.count, 1) {
+			t.Errorf("%+q: Substring and SliceStep disagree: %+q", tt.value, got)
+		}
+	}
+}
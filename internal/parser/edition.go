@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"welle/internal/ast"
+	"welle/internal/token"
+)
+
+// Edition is a version of the language's syntax. A file picks one with a
+// `#welle 0.2` line above its code, or gets the `edition` of welle.toml;
+// syntax newer than that edition is rejected.
+type Edition struct {
+	Major, Minor int
+}
+
+// Editions lists the editions this parser knows, oldest first.
+var Editions = []Edition{{0, 1}, {0, 2}}
+
+// LatestEdition is the edition of files that do not pick one.
+var LatestEdition = Editions[len(Editions)-1]
+
+// PragmaName is the word after `#` in an edition pragma.
+const PragmaName = "welle"
+
+func (e Edition) String() string { return fmt.Sprintf("%d.%d", e.Major, e.Minor) }
+
+// Before reports whether e is older than o.
+func (e Edition) Before(o Edition) bool {
+	return e.Major < o.Major || (e.Major == o.Major && e.Minor < o.Minor)
+}
+
+// ParseEdition parses s, such as "0.2", and checks that this parser knows
+// that edition.
+func ParseEdition(s string) (Edition, error) {
+	majorStr, minorStr, ok := strings.Cut(s, ".")
+	major, err1 := strconv.Atoi(majorStr)
+	minor, err2 := strconv.Atoi(minorStr)
+	if !ok || err1 != nil || err2 != nil || major < 0 || minor < 0 {
+		return Edition{}, fmt.Errorf("invalid edition %q (expected MAJOR.MINOR, such as %s)", s, LatestEdition)
+	}
+	e := Edition{major, minor}
+	if LatestEdition.Before(e) {
+		return Edition{}, fmt.Errorf("edition %s is newer than this toolchain supports (latest is %s); upgrade welle", e, LatestEdition)
+	}
+	for _, known := range Editions {
+		if known == e {
+			return e, nil
+		}
+	}
+	return Edition{}, fmt.Errorf("unknown edition %s (this toolchain supports %s to %s)", e, Editions[0], LatestEdition)
+}
+
+// SetEdition sets the edition of the file when it has no pragma of its own.
+// Call it before ParseProgram.
+func (p *Parser) SetEdition(e Edition) {
+	p.edition = e
+}
+
+// requireEdition reports an error at tok when the file's edition is older
+// than need, which the feature named what was added in.
+func (p *Parser) requireEdition(tok token.Token, need Edition, what string) {
+	if p.edition != (Edition{}) && p.edition.Before(need) {
+		p.errorAt(tok, fmt.Sprintf("%s: this feature requires edition %s, but the file is edition %s", what, need, p.edition))
+	}
+}
+
+// isPragma reports whether the current token starts an edition pragma.
+func (p *Parser) isPragma() bool {
+	return p.curToken.Type == token.HASH && p.peekToken.Type == token.IDENT &&
+		p.peekToken.Literal == PragmaName && p.peekToken.Line == p.curToken.Line
+}
+
+// parsePragma parses `#welle X.Y` into program. first is false once code
+// has been parsed; the pragma has to come before it.
+func (p *Parser) parsePragma(program *ast.Program, first bool) {
+	hash := p.curToken
+	p.nextToken() // welle
+	switch {
+	case !first:
+		p.errorAt(hash, "#welle pragma must come before any code")
+	case program.Edition != "":
+		p.errorAt(hash, "duplicate #welle pragma")
+	}
+	if p.peekToken.Line != hash.Line || (p.peekToken.Type != token.FLOAT && p.peekToken.Type != token.INT) {
+		p.errorAt(p.peekToken, fmt.Sprintf("expected an edition after #welle, such as %s", LatestEdition))
+		return
+	}
+	p.nextToken()
+	e, err := ParseEdition(p.curToken.Literal)
+	if err != nil {
+		p.errorAt(p.curToken, err.Error())
+		e = LatestEdition
+	}
+	if first && program.Edition == "" {
+		program.Edition = p.curToken.Literal
+		p.edition = e
+	}
+	if !p.peekIsTerminator() {
+		p.errorAt(p.peekToken, "expected end of line after #welle pragma")
+	}
+}
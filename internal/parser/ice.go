@@ -0,0 +1,28 @@
+package parser
+
+import (
+	"strings"
+
+	"welle/internal/ast"
+	"welle/internal/ice"
+	"welle/internal/lexer"
+)
+
+// internalError reports the panic v, a bug in the parser, as an internal
+// error at the current token and halts the parser; the statements parsed
+// before it are kept. The reproduction is the fewest lines of the source
+// that still make a parser with the same settings panic the same way.
+func (p *Parser) internalError(v any) {
+	e := ice.New("parser", v)
+	lines := strings.SplitAfter(p.l.Input(), "\n")
+	e.Repro = strings.Join(ice.Minimize(lines, func(lines []string) bool {
+		return ice.Panics(v, func() {
+			q := New(lexer.New(strings.Join(lines, "")))
+			q.edition, q.limits = p.edition, p.limits
+			q.parseProgram(&ast.Program{})
+		})
+	}), "")
+	ice.Report(e)
+	d := e.Diagnostic(p.curToken.Line, p.curToken.Col)
+	p.halt(p.curToken, d.Code, d.Message)
+}
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"fmt"
+
+	"welle/internal/diag"
+	"welle/internal/token"
+)
+
+// Limits bounds what a parser accepts, so hostile or generated input is
+// reported as an error instead of exhausting the Go stack. Zero means no
+// limit.
+type Limits struct {
+	// MaxDepth is how deeply statements and expressions may nest: each one
+	// being parsed inside another is one level, so `((x))` is three.
+	MaxDepth int
+	// MaxSourceBytes is the size of the largest source a parser accepts.
+	MaxSourceBytes int
+}
+
+// DefaultLimits are the limits of a parser when nothing sets others. The
+// AST a parser returns is walked recursively by the evaluator, compiler and
+// tools, so MaxDepth bounds their recursion too.
+var DefaultLimits = Limits{MaxDepth: 1000, MaxSourceBytes: 16 << 20}
+
+var current = DefaultLimits
+
+// SetLimits sets the limits of the parsers made after it (-max-parse-depth,
+// -max-source-size).
+func SetLimits(l Limits) {
+	current = l
+}
+
+// CurrentLimits returns the limits new parsers get.
+func CurrentLimits() Limits {
+	return current
+}
+
+// Diagnostic codes of the limits, next to WP0001 for syntax errors.
+const (
+	CodeTooDeep  = "WP0002"
+	CodeTooLarge = "WP0003"
+)
+
+// enter counts one more level of nesting and reports whether parsing may go
+// on. Past MaxDepth it reports the error once and halts the parser. Every
+// call is undone by a deferred leave, whatever it returns.
+func (p *Parser) enter() bool {
+	p.depth++
+	if p.halted {
+		return false
+	}
+	if p.limits.MaxDepth > 0 && p.depth > p.limits.MaxDepth {
+		p.halt(p.curToken, CodeTooDeep, fmt.Sprintf("nesting too deep: more than %d levels", p.limits.MaxDepth))
+		return false
+	}
+	return true
+}
+
+func (p *Parser) leave() {
+	p.depth--
+}
+
+// checkSize reports whether the source fits MaxSourceBytes, halting the
+// parser before it reads any further when it does not.
+func (p *Parser) checkSize() bool {
+	if p.limits.MaxSourceBytes > 0 && p.l.Len() > p.limits.MaxSourceBytes {
+		p.halt(token.Token{Line: 1, Col: 1}, CodeTooLarge,
+			fmt.Sprintf("source too large: %d bytes, limit is %d", p.l.Len(), p.limits.MaxSourceBytes))
+		return false
+	}
+	return true
+}
+
+// halt reports msg at tok with code and stops the parser: from here on it
+// only sees EOF, and the errors the unwinding would cause are dropped.
+func (p *Parser) halt(tok token.Token, code, msg string) {
+	p.diags = append(p.diags, diag.Diagnostic{
+		Code:     code,
+		Message:  msg,
+		Severity: diag.SeverityError,
+		Range:    diag.Range{Line: tok.Line, Col: tok.Col, Length: 1},
+	})
+	p.errors = append(p.errors, msg)
+	p.halted = true
+	eof := token.Token{Type: token.EOF, Line: tok.Line, Col: tok.Col}
+	p.curToken, p.peekToken = eof, eof
+}
@@ -26,6 +26,21 @@ type Parser struct {
 
 	prefixParseFns map[token.Type]prefixParseFn
 	infixParseFns  map[token.Type]infixParseFn
+
+	// exporting is set while parsing the statement after `export`, where a
+	// function may take a keyword as its name (std:errors exports `is`).
+	exporting bool
+	// funcDepth counts the function bodies being parsed; yield is only
+	// allowed inside one.
+	funcDepth int
+	// edition is the file's edition, from its pragma or SetEdition; the
+	// zero Edition allows everything this parser knows.
+	edition Edition
+	// limits are copied from CurrentLimits by New; depth is the current
+	// nesting, and halted is set once a limit stops the parse.
+	limits Limits
+	depth  int
+	halted bool
 }
 
 /* -------------------- precedence -------------------- */
@@ -97,6 +112,7 @@ func New(l *lexer.Lexer) *Parser {
 		diags:          []diag.Diagnostic{},
 		prefixParseFns: map[token.Type]prefixParseFn{},
 		infixParseFns:  map[token.Type]infixParseFn{},
+		limits:         current,
 	}
 
 	// read two tokens, so cur and peek are set
@@ -154,14 +170,31 @@ func (p *Parser) Errors() []string               { return p.errors }
 
 /* -------------------- program -------------------- */
 
-func (p *Parser) ParseProgram() *ast.Program {
-	program := &ast.Program{Statements: []ast.Statement{}}
+func (p *Parser) ParseProgram() (program *ast.Program) {
+	program = &ast.Program{Statements: []ast.Statement{}}
+	if !p.checkSize() {
+		return program
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			p.internalError(v)
+		}
+	}()
+	p.parseProgram(program)
+	return program
+}
 
+func (p *Parser) parseProgram(program *ast.Program) {
 	for p.curToken.Type != token.EOF {
 		if p.isSeparator(p.curToken.Type) {
 			p.nextToken()
 			continue
 		}
+		if p.isPragma() {
+			p.parsePragma(program, len(program.Statements) == 0)
+			p.nextToken()
+			continue
+		}
 
 		stmt := p.parseStatement()
 		if stmt != nil {
@@ -170,22 +203,34 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 		p.nextToken()
 	}
-
-	return program
 }
 
 /* -------------------- statements -------------------- */
 
 func (p *Parser) parseStatement() ast.Statement {
+	defer p.leave()
+	if !p.enter() {
+		return nil
+	}
 	switch p.curToken.Type {
 	case token.FUNC:
 		return p.parseFuncStatement()
+	case token.CLASS:
+		return p.parseClassStatement()
+	case token.AT:
+		return p.parseAnnotatedStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.YIELD:
+		return p.parseYieldStatement()
 	case token.DEFER:
 		return p.parseDeferStatement()
+	case token.DEL:
+		return p.parseDelStatement()
 	case token.THROW:
 		return p.parseThrowStatement()
+	case token.ASSERT:
+		return p.parseAssertStatement()
 	case token.BREAK:
 		return &ast.BreakStatement{Token: p.curToken}
 	case token.CONTINUE:
@@ -220,7 +265,13 @@ func (p *Parser) parseStatement() ast.Statement {
 func (p *Parser) parseFuncStatement() ast.Statement {
 	stmt := &ast.FuncStatement{Token: p.curToken}
 
-	if !p.expectPeek(token.IDENT) {
+	exported := p.exporting
+	p.exporting = false
+	if exported {
+		if !p.expectPeekName() {
+			return nil
+		}
+	} else if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
@@ -228,13 +279,125 @@ func (p *Parser) parseFuncStatement() ast.Statement {
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
-	stmt.Parameters = p.parseFunctionParameters()
+	stmt.Parameters, stmt.Defaults = p.parseFunctionParameters()
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
-	stmt.Body = p.parseBlockStatement()
+	stmt.Body = p.parseFunctionBody()
+
+	return stmt
+}
+
+func (p *Parser) parseClassStatement() ast.Statement {
+	stmt := &ast.ClassStatement{Token: p.curToken}
+	p.requireEdition(p.curToken, Edition{0, 2}, "class declarations")
+
+	exported := p.exporting
+	p.exporting = false
+	if exported {
+		if !p.expectPeekName() {
+			return nil
+		}
+	} else if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	p.nextToken()
+
+	seen := map[string]bool{}
+	member := func(id *ast.Identifier) bool {
+		if seen[id.Value] {
+			p.errorAt(id.Token, fmt.Sprintf("duplicate member %s in class %s", id.Value, stmt.Name.Value))
+			return false
+		}
+		seen[id.Value] = true
+		return true
+	}
+	for p.curToken.Type != token.RBRACE && p.curToken.Type != token.EOF {
+		switch {
+		case p.isSeparator(p.curToken.Type):
+		case p.curToken.Type == token.FUNC:
+			m, _ := p.parseFuncStatement().(*ast.FuncStatement)
+			if m == nil {
+				return nil
+			}
+			if member(m.Name) {
+				stmt.Methods = append(stmt.Methods, m)
+			}
+		case p.curToken.Type == token.IDENT:
+			field := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			var def ast.Expression
+			if p.peekToken.Type == token.ASSIGN {
+				p.nextToken() // '='
+				p.nextToken()
+				def = p.parseExpression(LOWEST)
+			}
+			if !member(field) {
+				break
+			}
+			stmt.Fields = append(stmt.Fields, field)
+			if def != nil && stmt.Defaults == nil {
+				stmt.Defaults = make([]ast.Expression, len(stmt.Fields)-1, len(stmt.Fields))
+			}
+			if stmt.Defaults != nil {
+				stmt.Defaults = append(stmt.Defaults, def)
+			}
+		default:
+			p.errorAt(p.curToken, fmt.Sprintf("expected a field or method in class %s, got %s", stmt.Name.Value, p.curToken.Type))
+			return nil
+		}
+		p.nextToken()
+	}
+	if p.curToken.Type == token.EOF {
+		p.errorAt(p.curToken, "unterminated class body")
+	}
+
+	return stmt
+}
+
+// parseAnnotatedStatement parses `@deprecated` or `@deprecated("message")`
+// and the function statement, exported or not, on the lines after it.
+func (p *Parser) parseAnnotatedStatement() ast.Statement {
+	at := p.curToken
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	if p.curToken.Literal != "deprecated" {
+		p.errorAt(p.curToken, fmt.Sprintf("unknown annotation @%s", p.curToken.Literal))
+		return nil
+	}
+	msg := ""
+	if p.peekToken.Type == token.LPAREN {
+		p.nextToken()
+		if !p.expectPeek(token.STRING) {
+			return nil
+		}
+		msg = p.curToken.Literal
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+	}
+	p.skipSeparatorsPeek()
+	p.nextToken()
 
+	stmt := p.parseStatement()
+	fn, ok := stmt.(*ast.FuncStatement)
+	if exp, isExport := stmt.(*ast.ExportStatement); isExport {
+		fn, ok = exp.Stmt.(*ast.FuncStatement)
+	}
+	if !ok || fn == nil {
+		p.errorAt(at, "@deprecated must come before a function")
+		return stmt
+	}
+	if fn.Deprecated != nil {
+		p.errorAt(at, fmt.Sprintf("duplicate @deprecated on %s", fn.Name.Value))
+	}
+	fn.Deprecated, fn.DeprecatedMsg = &at, msg
 	return stmt
 }
 
@@ -244,23 +407,31 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
-	lit.Parameters = p.parseFunctionParameters()
+	lit.Parameters, lit.Defaults = p.parseFunctionParameters()
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
-	lit.Body = p.parseBlockStatement()
+	lit.Body = p.parseFunctionBody()
 
 	return lit
 }
 
+func (p *Parser) parseFunctionBody() *ast.BlockStatement {
+	p.funcDepth++
+	defer func() { p.funcDepth-- }()
+	return p.parseBlockStatement()
+}
+
 func (p *Parser) parseExportStatement() ast.Statement {
 	stmt := &ast.ExportStatement{Token: p.curToken}
 
 	// Move to the statement after 'export'
 	p.nextToken()
 
+	p.exporting = true
 	inner := p.parseStatement()
+	p.exporting = false
 	if inner == nil {
 		p.errorAt(p.curToken, "expected statement after export")
 		return nil
@@ -299,6 +470,21 @@ func (p *Parser) parseTryStatement() ast.Statement {
 		}
 		stmt.CatchName = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
+		// (e: codes)
+		if p.peekToken.Type == token.COLON {
+			p.nextToken()
+			p.requireEdition(p.curToken, Edition{0, 2}, "catch filters")
+			p.nextToken()
+			if p.curToken.Type == token.RPAREN {
+				p.errorAt(p.curToken, "expected error codes after ':' in catch")
+				return nil
+			}
+			stmt.CatchFilter = p.parseExpression(LOWEST)
+			if stmt.CatchFilter == nil {
+				return nil
+			}
+		}
+
 		if !p.expectPeek(token.RPAREN) {
 			return nil
 		}
@@ -332,6 +518,21 @@ func (p *Parser) parseTryStatement() ast.Statement {
 	return stmt
 }
 
+func (p *Parser) parseDelStatement() ast.Statement {
+	stmt := &ast.DelStatement{Token: p.curToken}
+
+	p.nextToken()
+	stmt.Target = p.parseExpression(LOWEST)
+
+	switch stmt.Target.(type) {
+	case *ast.IndexExpression, *ast.SliceExpression:
+	default:
+		p.errorAt(stmt.Token, "del expects an index or slice expression")
+		return nil
+	}
+	return stmt
+}
+
 func (p *Parser) parseThrowStatement() ast.Statement {
 	stmt := &ast.ThrowStatement{Token: p.curToken}
 
@@ -341,6 +542,21 @@ func (p *Parser) parseThrowStatement() ast.Statement {
 	return stmt
 }
 
+func (p *Parser) parseAssertStatement() ast.Statement {
+	stmt := &ast.AssertStatement{Token: p.curToken}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if p.peekToken.Type == token.COMMA {
+		p.nextToken()
+		p.nextToken()
+		stmt.Message = p.parseExpression(LOWEST)
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseDeferStatement() ast.Statement {
 	stmt := &ast.DeferStatement{Token: p.curToken}
 
@@ -354,29 +570,47 @@ func (p *Parser) parseDeferStatement() ast.Statement {
 	return stmt
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// parseFunctionParameters parses a parameter list and any default values,
+// `(a, b = 1)`. Once a parameter has a default, every later one needs one.
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, []ast.Expression) {
 	params := []*ast.Identifier{}
+	var defaults []ast.Expression
 
 	// curToken is '('
 	if p.peekToken.Type == token.RPAREN {
 		p.nextToken() // consume ')'
-		return params
+		return params, nil
 	}
 
-	p.nextToken() // first param
-	params = append(params, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
-
-	for p.peekToken.Type == token.COMMA {
-		p.nextToken() // consume ','
-		p.nextToken() // next ident
-		params = append(params, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+	for {
+		p.nextToken() // param
+		param := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		params = append(params, param)
+		var def ast.Expression
+		if p.peekToken.Type == token.ASSIGN {
+			p.nextToken() // '='
+			p.nextToken()
+			def = p.parseExpression(LOWEST)
+		} else if defaults != nil {
+			p.errorAt(param.Token, fmt.Sprintf("parameter %s without a default follows one with a default", param.Value))
+		}
+		if def != nil && defaults == nil {
+			defaults = make([]ast.Expression, len(params)-1, len(params))
+		}
+		if defaults != nil {
+			defaults = append(defaults, def)
+		}
+		if p.peekToken.Type != token.COMMA {
+			break
+		}
+		p.nextToken() // ','
 	}
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return nil, nil
 	}
 
-	return params
+	return params, defaults
 }
 
 func (p *Parser) parseReturnStatement() ast.Statement {
@@ -395,6 +629,20 @@ func (p *Parser) parseReturnStatement() ast.Statement {
 	return stmt
 }
 
+func (p *Parser) parseYieldStatement() ast.Statement {
+	stmt := &ast.YieldStatement{Token: p.curToken}
+	p.requireEdition(p.curToken, Edition{0, 2}, "yield")
+	if p.funcDepth == 0 {
+		p.errorAt(p.curToken, "yield outside function")
+	}
+	if p.peekIsTerminator() {
+		return stmt
+	}
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	return stmt
+}
+
 func (p *Parser) parseImportStatement() ast.Statement {
 	stmt := &ast.ImportStatement{Token: p.curToken}
 
@@ -543,7 +791,7 @@ func (p *Parser) parseExpressionStatement() ast.Statement {
 				Name:    left,
 				Value:   ae.Value,
 			}
-		case *ast.IndexExpression:
+		case *ast.IndexExpression, *ast.SliceExpression:
 			return &ast.IndexAssignStatement{Token: ae.Token, Op: ae.Op, Left: left, Value: ae.Value}
 		case *ast.MemberExpression:
 			return &ast.MemberAssignStatement{
@@ -607,15 +855,21 @@ func (p *Parser) peekDestructureAssign() bool {
 
 	seenStar := false
 	seenComma := false
+	seenField := false
 	seenTarget := false
+	var tok token.Token
+	pending := false
 	for {
-		tok := next()
+		if !pending {
+			tok = next()
+		}
+		pending = false
 		if tok.Type == token.RPAREN {
 			if !seenTarget {
 				return false
 			}
 			tok = next()
-			return isAssignOperator(tok.Type) && (seenStar || seenComma)
+			return isAssignOperator(tok.Type) && (seenStar || seenComma || seenField)
 		}
 		if tok.Type == token.COMMA {
 			seenComma = true
@@ -632,6 +886,16 @@ func (p *Parser) peekDestructureAssign() bool {
 			return false
 		}
 		seenTarget = true
+		// A by-name target is field: name.
+		tok = next()
+		pending = true
+		if tok.Type == token.COLON {
+			if tok = next(); tok.Type != token.IDENT {
+				return false
+			}
+			seenField = true
+			pending = false
+		}
 	}
 }
 
@@ -643,10 +907,12 @@ func (p *Parser) parseDestructureAssignStatement() ast.Statement {
 	}
 
 	seenStar := false
+	byName, positional := false, false
 	p.nextToken()
 	for {
 		switch p.curToken.Type {
 		case token.STAR:
+			positional = true
 			if seenStar {
 				p.errorAt(p.curToken, "destructuring assignment allows only one starred target")
 				return nil
@@ -660,11 +926,27 @@ func (p *Parser) parseDestructureAssignStatement() ast.Statement {
 			stmt.Targets = append(stmt.Targets, &ast.DestructureTarget{Token: starTok, Name: ident, Star: true})
 		case token.IDENT:
 			ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-			stmt.Targets = append(stmt.Targets, &ast.DestructureTarget{Token: p.curToken, Name: ident})
+			target := &ast.DestructureTarget{Token: p.curToken, Name: ident}
+			if p.peekToken.Type == token.COLON {
+				p.nextToken()
+				if !p.expectPeek(token.IDENT) {
+					return nil
+				}
+				target.Field = ident
+				target.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+				byName = true
+			} else {
+				positional = true
+			}
+			stmt.Targets = append(stmt.Targets, target)
 		default:
 			p.errorAt(p.curToken, "destructuring assignment targets must be identifiers or '_'")
 			return nil
 		}
+		if byName && positional {
+			p.errorAt(stmt.Targets[len(stmt.Targets)-1].Token, "destructuring assignment cannot mix field: name targets with positional ones")
+			return nil
+		}
 
 		if p.peekToken.Type != token.COMMA {
 			if !p.expectPeek(token.RPAREN) {
@@ -733,7 +1015,9 @@ func (p *Parser) parseIfStatement() ast.Statement {
 		p.skipSeparatorsPeek()
 		if p.peekToken.Type == token.IF {
 			p.nextToken() // move to IF
-			elseIfStmt := p.parseIfStatement()
+			// Through parseStatement, so a long else-if chain counts
+			// toward the nesting limit.
+			elseIfStmt := p.parseStatement()
 			if elseIfStmt == nil {
 				return nil
 			}
@@ -1113,6 +1397,73 @@ func (p *Parser) parseSwitchStatement() ast.Statement {
 	return stmt
 }
 
+// parseCaseValue parses one value of a match case. An `if` after it starts
+// the case's guard, so conditional expressions, and the operators that bind
+// more loosely, need parentheses here; a tuple, array or dict literal is a
+// pattern.
+func (p *Parser) parseCaseValue() ast.Expression {
+	value := p.parseExpression(TERNARYPREC)
+	var tok token.Token
+	switch v := value.(type) {
+	case *ast.TupleLiteral:
+		tok = v.Token
+	case *ast.ListLiteral:
+		tok = v.Token
+	case *ast.DictLiteral:
+		tok = v.Token
+	default:
+		return value
+	}
+
+	pat := &ast.MatchPattern{Token: tok, Value: value}
+	p.checkPattern(value)
+	seen := map[string]bool{}
+	for _, id := range pat.Names() {
+		if seen[id.Value] {
+			p.errorAt(id.Token, fmt.Sprintf("duplicate name %s in match pattern", id.Value))
+		}
+		seen[id.Value] = true
+	}
+	if len(seen) > 0 {
+		p.requireEdition(tok, Edition{0, 2}, "match patterns")
+	}
+	return pat
+}
+
+// checkPattern reports the parts of a pattern that cannot be matched.
+func (p *Parser) checkPattern(e ast.Expression) {
+	switch n := e.(type) {
+	case *ast.TupleLiteral:
+		for _, el := range n.Elements {
+			p.checkPattern(el)
+		}
+	case *ast.ListLiteral:
+		rest := false
+		for _, el := range n.Elements {
+			spread, ok := el.(*ast.SpreadExpression)
+			if !ok {
+				p.checkPattern(el)
+				continue
+			}
+			if rest {
+				p.errorAt(spread.Token, "a pattern can have only one ...rest")
+			}
+			rest = true
+			if _, ok := spread.Value.(*ast.Identifier); !ok {
+				p.errorAt(spread.Token, "...rest in a pattern must be a name")
+			}
+		}
+	case *ast.DictLiteral:
+		for _, pair := range n.Pairs {
+			if pair.Spread != nil {
+				p.errorAt(pair.Spread.Token, "a dict pattern cannot spread; it ignores the keys it does not list")
+				continue
+			}
+			p.checkPattern(pair.Value)
+		}
+	}
+}
+
 func (p *Parser) parseMatchExpression() ast.Expression {
 	exp := &ast.MatchExpression{Token: p.curToken}
 
@@ -1143,14 +1494,24 @@ func (p *Parser) parseMatchExpression() ast.Expression {
 
 			p.nextToken()
 			values := []ast.Expression{}
-			values = append(values, p.parseExpression(LOWEST))
+			values = append(values, p.parseCaseValue())
 			for p.peekToken.Type == token.COMMA {
 				p.nextToken()
 				p.nextToken()
-				values = append(values, p.parseExpression(LOWEST))
+				values = append(values, p.parseCaseValue())
 			}
 			cc.Values = values
 
+			if p.peekToken.Type == token.IF {
+				p.nextToken()
+				p.requireEdition(p.curToken, Edition{0, 2}, "match guards")
+				p.nextToken()
+				cc.Guard = p.parseExpression(LOWEST)
+				if cc.Guard == nil {
+					return nil
+				}
+			}
+
 			if !p.expectPeek(token.LBRACE) {
 				return nil
 			}
@@ -1210,7 +1571,6 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 			p.nextToken()
 			continue
 		}
-
 		stmt := p.parseStatement()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
@@ -1228,6 +1588,13 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 /* -------------------- expressions (Pratt) -------------------- */
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	// The operators applied in the loop below build a left-leaning chain
+	// without nesting the parse, so the chain counts as one level however
+	// long it is; a right operand nests through its own parseExpression.
+	defer p.leave()
+	if !p.enter() {
+		return nil
+	}
 	// stop on statement terminators / block end
 	if p.isTerminator(p.curToken.Type) {
 		return nil
@@ -1273,6 +1640,11 @@ func (p *Parser) parseAssignmentExpression(left ast.Expression) ast.Expression {
 	} else {
 		switch left.(type) {
 		case *ast.Identifier, *ast.IndexExpression, *ast.MemberExpression:
+		case *ast.SliceExpression:
+			if p.curToken.Type != token.ASSIGN {
+				p.errorAt(p.curToken, "slice assignment supports only '='")
+				return nil
+			}
 		default:
 			p.errorAt(p.curToken, "invalid assignment target")
 			return nil
@@ -1358,6 +1730,9 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 	}
 
 	p.nextToken()
+	if p.curToken.Type == token.IDENT && p.peekToken.Type == token.COLON {
+		return p.parseNamedTupleLiteral(tok)
+	}
 	first := p.parseExpression(LOWEST)
 	if first == nil {
 		return nil
@@ -1391,6 +1766,45 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 	return lit
 }
 
+// parseNamedTupleLiteral parses the fields of (x: 1, y: 2) from the first
+// name on. Every element must be named, and each name used once.
+func (p *Parser) parseNamedTupleLiteral(tok token.Token) ast.Expression {
+	lit := &ast.NamedTupleLiteral{Token: tok}
+	seen := map[string]bool{}
+	for {
+		if p.curToken.Type != token.IDENT || p.peekToken.Type != token.COLON {
+			p.errorAt(p.curToken, "named tuple elements must all be written as name: value")
+			return nil
+		}
+		name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if seen[name.Value] {
+			p.errorAt(p.curToken, "duplicate named tuple field: "+name.Value)
+			return nil
+		}
+		seen[name.Value] = true
+		p.nextToken() // ':'
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		if value == nil {
+			return nil
+		}
+		lit.Fields = append(lit.Fields, ast.NamedTupleField{Name: name, Value: value})
+
+		if p.peekToken.Type != token.COMMA {
+			break
+		}
+		p.nextToken() // consume ','
+		if p.peekToken.Type == token.RPAREN {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	return lit
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	exp := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -1515,14 +1929,27 @@ func (p *Parser) parseTaggedTemplate(tag ast.Expression) ast.Expression {
 func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
 	exp := &ast.MemberExpression{Token: p.curToken, Object: left}
 
-	if !p.expectPeek(token.IDENT) {
+	if !p.expectPeekName() {
 		return nil
 	}
 	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	return exp
 }
 
+// expectPeekName is expectPeek(token.IDENT) that also takes a keyword,
+// retyped as an identifier, for places where a name cannot be mistaken for
+// syntax: member names and exported functions.
+func (p *Parser) expectPeekName() bool {
+	if p.peekToken.Type != token.IDENT && token.IsKeyword(p.peekToken.Literal) {
+		p.peekToken.Type = token.IDENT
+	}
+	return p.expectPeek(token.IDENT)
+}
+
 func (p *Parser) parseDictLiteral() ast.Expression {
+	if p.peekToken.Type == token.LBRACKET {
+		return p.parseSetLiteral()
+	}
 	lit := &ast.DictLiteral{Token: p.curToken, Pairs: []ast.DictPair{}}
 
 	if !p.expectPeek(token.LBRACE) {
@@ -1559,7 +1986,24 @@ func (p *Parser) parseDictLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseSetLiteral() ast.Expression {
+	lit := &ast.SetLiteral{Token: p.curToken}
+	p.nextToken() // '['
+	lit.Elements = p.parseExpressionList(token.RBRACKET)
+	if lit.Elements == nil {
+		return nil
+	}
+	return lit
+}
+
 func (p *Parser) parseDictPair() *ast.DictPair {
+	if p.curToken.Type == token.ELLIPSIS {
+		spread, ok := p.parseSpreadable().(*ast.SpreadExpression)
+		if !ok {
+			return nil
+		}
+		return &ast.DictPair{Spread: spread}
+	}
 	if p.curToken.Type == token.IDENT && (p.peekToken.Type == token.COMMA || p.peekToken.Type == token.RBRACE) {
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 		return &ast.DictPair{Shorthand: ident}
@@ -1589,12 +2033,16 @@ func (p *Parser) parseListLiteral() ast.Expression {
 	}
 
 	p.nextToken()
-	first := p.parseExpression(LOWEST)
+	first := p.parseSpreadable()
 	if first == nil {
 		return nil
 	}
 
 	if p.peekToken.Type == token.FOR {
+		if _, ok := first.(*ast.SpreadExpression); ok {
+			p.errorAt(p.peekToken, "list comprehension element cannot be spread")
+			return nil
+		}
 		p.nextToken() // consume 'for'
 		if !p.expectPeek(token.IDENT) {
 			return nil
@@ -1641,7 +2089,7 @@ func (p *Parser) parseListLiteral() ast.Expression {
 	for p.peekToken.Type == token.COMMA {
 		p.nextToken()
 		p.nextToken()
-		elem := p.parseExpression(LOWEST)
+		elem := p.parseSpreadable()
 		if elem == nil {
 			return nil
 		}
@@ -1729,11 +2177,19 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 		return &ast.SliceExpression{Token: tok, Left: left, Low: first, High: high, Step: step}
 	}
 
+	// `grid[y, x]` is sugar for `grid[y][x]`.
+	index := &ast.IndexExpression{Token: tok, Left: left, Index: first}
+	for p.peekToken.Type == token.COMMA {
+		p.nextToken()
+		p.nextToken()
+		index = &ast.IndexExpression{Token: tok, Left: index, Index: p.parseExpression(LOWEST)}
+	}
+
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
 
-	return &ast.IndexExpression{Token: tok, Left: left, Index: first}
+	return index
 }
 
 func (p *Parser) parseCallArguments() []ast.Expression {
@@ -1746,12 +2202,12 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 	}
 
 	p.nextToken() // first arg
-	args = append(args, p.parseCallArgument())
+	args = append(args, p.parseSpreadable())
 
 	for p.peekToken.Type == token.COMMA {
 		p.nextToken() // consume ','
 		p.nextToken() // next arg
-		args = append(args, p.parseCallArgument())
+		args = append(args, p.parseSpreadable())
 	}
 
 	if !p.expectPeek(token.RPAREN) {
@@ -1761,7 +2217,9 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 	return args
 }
 
-func (p *Parser) parseCallArgument() ast.Expression {
+// parseSpreadable parses an expression that may be prefixed with '...', as
+// call arguments, array elements and dict entries may be.
+func (p *Parser) parseSpreadable() ast.Expression {
 	if p.curToken.Type != token.ELLIPSIS {
 		return p.parseExpression(LOWEST)
 	}
@@ -1825,7 +2283,13 @@ func (p *Parser) parseTemplateInterpolation(tok token.Token, exprRaw string) (as
 		return nil, false
 	}
 	sub := New(lexer.New(exprRaw))
+	sub.limits, sub.depth = p.limits, p.depth
 	program := sub.ParseProgram()
+	if sub.halted {
+		last := sub.diags[len(sub.diags)-1]
+		p.halt(tok, last.Code, last.Message)
+		return nil, false
+	}
 	if len(sub.Errors()) > 0 {
 		p.errorAt(tok, "invalid template interpolation: "+sub.Errors()[0])
 		return nil, false
@@ -1946,6 +2410,9 @@ func findTemplateExprEnd(raw string, start int) (int, bool) {
 /* -------------------- helpers -------------------- */
 
 func (p *Parser) nextToken() {
+	if p.halted {
+		return
+	}
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
 }
@@ -1978,6 +2445,9 @@ func (p *Parser) expectPeekNoSkip(t token.Type) bool {
 }
 
 func (p *Parser) errorAt(tok token.Token, msg string) {
+	if p.halted {
+		return
+	}
 	length := 1
 	if tok.Literal != "" {
 		length = len([]rune(tok.Literal))
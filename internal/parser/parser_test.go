@@ -1,9 +1,11 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 
 	"welle/internal/ast"
+	"welle/internal/ice"
 	"welle/internal/lexer"
 	"welle/internal/token"
 )
@@ -121,6 +123,44 @@ while (true) { pass }
 	}
 }
 
+func TestParseAssertStatement(t *testing.T) {
+	input := `assert x > 0
+assert ok(x), "bad " + str(x)
+`
+
+	l := lexer.New(input)
+	p := New(l)
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	if len(prog.Statements) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(prog.Statements))
+	}
+
+	first, ok := prog.Statements[0].(*ast.AssertStatement)
+	if !ok {
+		t.Fatalf("expected AssertStatement, got %T", prog.Statements[0])
+	}
+	if first.Message != nil {
+		t.Fatalf("expected no message, got %s", first.Message)
+	}
+	if got := first.FailureMessage(); got != "assertion failed: x > 0" {
+		t.Fatalf("FailureMessage() = %q", got)
+	}
+
+	second, ok := prog.Statements[1].(*ast.AssertStatement)
+	if !ok {
+		t.Fatalf("expected AssertStatement, got %T", prog.Statements[1])
+	}
+	if second.Message == nil || second.Message.String() != `("bad " + str(x))` {
+		t.Fatalf("unexpected message: %v", second.Message)
+	}
+	if got := second.FailureMessage(); got != "assertion failed: ok(x)" {
+		t.Fatalf("FailureMessage() = %q", got)
+	}
+}
+
 func TestParseCForStatement_NoErrors(t *testing.T) {
 	input := `sum = 0
 for (i = 0; i < 3; i = i + 1) {
@@ -705,6 +745,171 @@ print(f(1, 2))`
 	}
 }
 
+func TestParseParameterDefaults(t *testing.T) {
+	p := New(lexer.New(`func greet(name, greeting = "hello", n = 1 + 1) { return greeting }
+f = func(x = [1]) { return x }`))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	fs, ok := prog.Statements[0].(*ast.FuncStatement)
+	if !ok {
+		t.Fatalf("stmt[0] - expected *ast.FuncStatement, got %T", prog.Statements[0])
+	}
+	if len(fs.Parameters) != 3 || len(fs.Defaults) != 3 || fs.Defaults[0] != nil {
+		t.Fatalf("unexpected parameters %v, defaults %v", fs.Parameters, fs.Defaults)
+	}
+	if got := fs.String(); got != "func greet(name, greeting = \"hello\", n = (1 + 1)) {\n  return greeting\n}" {
+		t.Fatalf("unexpected func: %q", got)
+	}
+	lit := prog.Statements[1].(*ast.AssignStatement).Value.(*ast.FunctionLiteral)
+	if len(lit.Defaults) != 1 || lit.Defaults[0].String() != "[1]" {
+		t.Fatalf("unexpected defaults %v", lit.Defaults)
+	}
+
+	p = New(lexer.New("func f(a = 1, b) { return b }"))
+	p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 1 || errs[0] != "parameter b without a default follows one with a default" {
+		t.Fatalf("expected a default-order error, got %v", errs)
+	}
+}
+
+func TestParseClassStatement(t *testing.T) {
+	p := New(lexer.New(`class Point {
+  x = 0
+  y
+
+  func move(dx, dy = 0) { self.x += dx }
+}`))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	cs, ok := prog.Statements[0].(*ast.ClassStatement)
+	if !ok {
+		t.Fatalf("stmt[0] - expected *ast.ClassStatement, got %T", prog.Statements[0])
+	}
+	if len(cs.Fields) != 2 || len(cs.Defaults) != 2 || cs.Defaults[1] != nil || len(cs.Methods) != 1 {
+		t.Fatalf("unexpected fields %v, defaults %v, methods %d", cs.Fields, cs.Defaults, len(cs.Methods))
+	}
+	if want := "class Point {\n  x = 0\n  y\n  func move(dx, dy = 0) {\n  self.x += dx\n}\n}"; cs.String() != want {
+		t.Fatalf("unexpected class: %q", cs.String())
+	}
+
+	for src, want := range map[string]string{
+		"class A { x\n func x() { return 1 } }": "duplicate member x in class A",
+		"class A { 1 }":                         "expected a field or method in class A, got INT",
+		"class A { x":                           "unterminated class body",
+	} {
+		p := New(lexer.New(src))
+		p.ParseProgram()
+		if errs := p.Errors(); len(errs) == 0 || errs[0] != want {
+			t.Fatalf("%q: expected %q, got %v", src, want, errs)
+		}
+	}
+}
+
+func TestParseYieldStatement(t *testing.T) {
+	p := New(lexer.New("func gen() {\n  yield 1 + 2\n  yield\n  f = func() { return 1 }\n}\nfunc plain() { g = func() { yield 1 } }"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	gen := prog.Statements[0].(*ast.FuncStatement)
+	body := gen.Body.Statements
+	if y, ok := body[0].(*ast.YieldStatement); !ok || y.String() != "yield (1 + 2)" {
+		t.Fatalf("stmt[0] - expected yield (1 + 2), got %T %q", body[0], body[0].String())
+	}
+	if y, ok := body[1].(*ast.YieldStatement); !ok || y.Value != nil {
+		t.Fatalf("stmt[1] - expected a bare yield, got %T %q", body[1], body[1].String())
+	}
+	if !ast.IsGenerator(gen.Body) {
+		t.Fatal("expected gen to be a generator")
+	}
+	if ast.IsGenerator(prog.Statements[1].(*ast.FuncStatement).Body) {
+		t.Fatal("expected plain not to be a generator: only its nested function yields")
+	}
+
+	p = New(lexer.New("yield 1"))
+	p.ParseProgram()
+	if errs := p.Errors(); len(errs) == 0 || errs[0] != "yield outside function" {
+		t.Fatalf("expected yield outside function, got %v", errs)
+	}
+}
+
+func TestParseEditionPragma(t *testing.T) {
+	p := New(lexer.New("// header\n#welle 0.2\n\nclass P { x }\n"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	if prog.Edition != "0.2" || len(prog.Statements) != 1 {
+		t.Fatalf("expected edition 0.2 and one statement, got %q and %d", prog.Edition, len(prog.Statements))
+	}
+	if !strings.HasPrefix(prog.String(), "#welle 0.2\n") {
+		t.Fatalf("expected the pragma in String(), got %q", prog.String())
+	}
+
+	tests := []struct {
+		input   string
+		edition Edition
+		want    []string
+	}{
+		{"#welle 0.1\nclass P { x }\nfunc g() { yield 1 }", Edition{}, []string{
+			"class declarations: this feature requires edition 0.2, but the file is edition 0.1",
+			"yield: this feature requires edition 0.2, but the file is edition 0.1",
+		}},
+		{"class P { x }", Edition{0, 1}, []string{"class declarations: this feature requires edition 0.2, but the file is edition 0.1"}},
+		{"#welle 0.2\nclass P { x }", Edition{0, 1}, nil},
+		{"#welle 0.3", Edition{}, []string{"edition 0.3 is newer than this toolchain supports (latest is 0.2); upgrade welle"}},
+		{"#welle 0.0", Edition{}, []string{"unknown edition 0.0 (this toolchain supports 0.1 to 0.2)"}},
+		{"#welle\nx = 1", Edition{}, []string{"expected an edition after #welle, such as 0.2"}},
+		{"x = 1\n#welle 0.2", Edition{}, []string{"#welle pragma must come before any code"}},
+		{"#welle 0.2\n#welle 0.1", Edition{}, []string{"duplicate #welle pragma"}},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.SetEdition(tt.edition)
+		p.ParseProgram()
+		if got := p.Errors(); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Fatalf("%q: expected errors %q, got %q", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestParseDeprecatedAnnotation(t *testing.T) {
+	p := New(lexer.New("@deprecated(\"use g()\")\nexport func f() { return 1 }\n@deprecated func h() {}"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	f := prog.Statements[0].(*ast.ExportStatement).Stmt.(*ast.FuncStatement)
+	if f.Deprecated == nil || f.Deprecated.Line != 1 || f.DeprecatedMsg != "use g()" {
+		t.Fatalf("expected f deprecated on line 1 with a message, got %v %q", f.Deprecated, f.DeprecatedMsg)
+	}
+	if got := prog.Statements[0].String(); got != "@deprecated(\"use g()\")\nexport func f() {\n  return 1\n}" {
+		t.Fatalf("unexpected String(): %q", got)
+	}
+	h := prog.Statements[1].(*ast.FuncStatement)
+	if h.Deprecated == nil || h.DeprecatedMsg != "" {
+		t.Fatalf("expected h deprecated without a message, got %v %q", h.Deprecated, h.DeprecatedMsg)
+	}
+
+	for input, want := range map[string]string{
+		"@deprecated\nx = 1":                  "@deprecated must come before a function",
+		"@inline func f() {}":                 "unknown annotation @inline",
+		"@deprecated(1) func f() {}":          "expected next token to be STRING, got INT instead",
+		"@deprecated @deprecated func f() {}": "duplicate @deprecated on f",
+	} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if errs := p.Errors(); len(errs) == 0 || errs[0] != want {
+			t.Fatalf("%q: expected %q, got %v", input, want, errs)
+		}
+	}
+}
+
 func TestParseTupleLiteral(t *testing.T) {
 	input := "(1, 2)\n(1)\n(1,)\n()"
 
@@ -982,6 +1187,175 @@ func TestParseWalrusInvalidTarget(t *testing.T) {
 	}
 }
 
+func TestParseSliceAssignAndDel(t *testing.T) {
+	input := "a[1:3] = [9]\ndel a[0]\ndel a[::2]"
+
+	l := lexer.New(input)
+	p := New(l)
+	prog := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		for _, e := range p.Errors() {
+			t.Error(e)
+		}
+		t.Fatalf("parser had %d errors", len(p.Errors()))
+	}
+	if len(prog.Statements) != 3 {
+		t.Fatalf("expected 3 statements, got %d", len(prog.Statements))
+	}
+
+	assign, ok := prog.Statements[0].(*ast.IndexAssignStatement)
+	if !ok {
+		t.Fatalf("expected index assign statement, got %T", prog.Statements[0])
+	}
+	if _, ok := assign.Left.(*ast.SliceExpression); !ok {
+		t.Fatalf("expected slice target, got %T", assign.Left)
+	}
+	del, ok := prog.Statements[1].(*ast.DelStatement)
+	if !ok {
+		t.Fatalf("expected del statement, got %T", prog.Statements[1])
+	}
+	if _, ok := del.Target.(*ast.IndexExpression); !ok {
+		t.Fatalf("expected index target, got %T", del.Target)
+	}
+	del, ok = prog.Statements[2].(*ast.DelStatement)
+	if !ok {
+		t.Fatalf("expected del statement, got %T", prog.Statements[2])
+	}
+	if _, ok := del.Target.(*ast.SliceExpression); !ok {
+		t.Fatalf("expected slice target, got %T", del.Target)
+	}
+}
+
+func TestParseMultiIndexLowersToNestedIndex(t *testing.T) {
+	p := New(lexer.New("grid[y, x + 1]"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	if got, want := prog.String(), "((grid[y])[(x + 1)])\n"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestParseSliceAssignInvalid(t *testing.T) {
+	for _, input := range []string{"a[1:2] += [1]", "del a", "del f(x)"} {
+		p := New(lexer.New(input))
+		_ = p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: expected parser errors", input)
+		}
+	}
+}
+
+func TestParseNamedTuple(t *testing.T) {
+	input := "p = (x: 1, y: a + 1)\n(x: px, y: _) = p"
+
+	l := lexer.New(input)
+	p := New(l)
+	prog := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		for _, e := range p.Errors() {
+			t.Error(e)
+		}
+		t.Fatalf("parser had %d errors", len(p.Errors()))
+	}
+	if len(prog.Statements) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(prog.Statements))
+	}
+
+	assign, ok := prog.Statements[0].(*ast.AssignStatement)
+	if !ok {
+		t.Fatalf("expected assign statement, got %T", prog.Statements[0])
+	}
+	lit, ok := assign.Value.(*ast.NamedTupleLiteral)
+	if !ok {
+		t.Fatalf("expected named tuple literal, got %T", assign.Value)
+	}
+	if len(lit.Fields) != 2 || lit.Fields[0].Name.Value != "x" || lit.Fields[1].Name.Value != "y" {
+		t.Fatalf("unexpected fields: %s", lit.String())
+	}
+	ds, ok := prog.Statements[1].(*ast.DestructureAssignStatement)
+	if !ok {
+		t.Fatalf("expected destructure statement, got %T", prog.Statements[1])
+	}
+	if got := ds.String(); got != "(x: px, y: _) = p" {
+		t.Fatalf("unexpected destructure: %q", got)
+	}
+}
+
+func TestParseNamedTupleInvalid(t *testing.T) {
+	for _, input := range []string{"(x: 1, 2)", "(x: 1, x: 2)", "(x: a, b) = p", "(*x: a) = p"} {
+		p := New(lexer.New(input))
+		_ = p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: expected parser errors", input)
+		}
+	}
+}
+
+func TestParseLiteralSpreads(t *testing.T) {
+	input := "a = [1, ...rest, 5]\nd = #{...defaults, \"x\": 1}"
+
+	l := lexer.New(input)
+	p := New(l)
+	prog := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		for _, e := range p.Errors() {
+			t.Error(e)
+		}
+		t.Fatalf("parser had %d errors", len(p.Errors()))
+	}
+
+	list, ok := prog.Statements[0].(*ast.AssignStatement).Value.(*ast.ListLiteral)
+	if !ok {
+		t.Fatalf("expected list literal, got %T", prog.Statements[0].(*ast.AssignStatement).Value)
+	}
+	if _, ok := list.Elements[1].(*ast.SpreadExpression); !ok {
+		t.Fatalf("expected spread element, got %T", list.Elements[1])
+	}
+	dict, ok := prog.Statements[1].(*ast.AssignStatement).Value.(*ast.DictLiteral)
+	if !ok {
+		t.Fatalf("expected dict literal, got %T", prog.Statements[1].(*ast.AssignStatement).Value)
+	}
+	if dict.Pairs[0].Spread == nil || dict.Pairs[0].Key != nil {
+		t.Fatalf("expected spread entry, got %+v", dict.Pairs[0])
+	}
+	if got := dict.String(); got != `#{...defaults, "x": 1}` {
+		t.Fatalf("unexpected dict: %q", got)
+	}
+}
+
+func TestParseSetLiteral(t *testing.T) {
+	p := New(lexer.New("s = #[1, \"a\", x]\ne = #[]"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	set, ok := prog.Statements[0].(*ast.AssignStatement).Value.(*ast.SetLiteral)
+	if !ok {
+		t.Fatalf("expected set literal, got %T", prog.Statements[0].(*ast.AssignStatement).Value)
+	}
+	if got := set.String(); got != `#[1, "a", x]` {
+		t.Fatalf("unexpected set: %q", got)
+	}
+	empty, ok := prog.Statements[1].(*ast.AssignStatement).Value.(*ast.SetLiteral)
+	if !ok || len(empty.Elements) != 0 {
+		t.Fatalf("expected empty set literal, got %#v", prog.Statements[1].(*ast.AssignStatement).Value)
+	}
+}
+
+func TestParseSpreadInComprehensionInvalid(t *testing.T) {
+	p := New(lexer.New("[...a for a in b]"))
+	_ = p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Fatal("expected parser errors")
+	}
+}
+
 func TestParseDictLiteralShorthand(t *testing.T) {
 	input := "person = #{name, age, \"role\": role}"
 
@@ -1018,6 +1392,93 @@ func TestParseDictLiteralShorthand(t *testing.T) {
 	}
 }
 
+func TestParseMatchPatterns(t *testing.T) {
+	input := "r = match (v) { case 1, (x, [y, ...rest]) if x > y { x } case #{\"k\": 2, name} { name } case (a if b else c) { 0 } }"
+	p := New(lexer.New(input))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	m := prog.Statements[0].(*ast.AssignStatement).Value.(*ast.MatchExpression)
+	first := m.Cases[0]
+	if _, ok := first.Values[0].(*ast.IntegerLiteral); !ok {
+		t.Fatalf("expected 1 to stay a value, got %T", first.Values[0])
+	}
+	pat, ok := first.Values[1].(*ast.MatchPattern)
+	if !ok {
+		t.Fatalf("expected a pattern, got %T", first.Values[1])
+	}
+	var names []string
+	for _, id := range pat.Names() {
+		names = append(names, id.Value)
+	}
+	if strings.Join(names, " ") != "x y rest" {
+		t.Fatalf("expected names x y rest, got %v", names)
+	}
+	if first.Guard == nil || first.Guard.String() != "(x > y)" {
+		t.Fatalf("expected guard (x > y), got %v", first.Guard)
+	}
+	dict := m.Cases[1].Values[0].(*ast.MatchPattern)
+	if vals := dict.Values(); len(vals) != 2 || vals[0].String() != "\"k\"" || vals[1].String() != "2" {
+		t.Fatalf("expected the key and 2 as values, got %v", vals)
+	}
+	if _, ok := m.Cases[2].Values[0].(*ast.CondExpr); !ok || m.Cases[2].Guard != nil {
+		t.Fatalf("expected a parenthesized conditional value, got %T", m.Cases[2].Values[0])
+	}
+	if got := m.String(); !strings.Contains(got, "case 1, (x, [y, ...rest]) if (x > y) { x }") {
+		t.Fatalf("unexpected String(): %q", got)
+	}
+
+	for input, want := range map[string]string{
+		"match (v) { case (x, x) { 0 } }":              "duplicate name x in match pattern",
+		"match (v) { case [...a, ...b] { 0 } }":        "a pattern can have only one ...rest",
+		"match (v) { case [...f()] { 0 } }":            "...rest in a pattern must be a name",
+		"match (v) { case #{...d} { 0 } }":             "a dict pattern cannot spread; it ignores the keys it does not list",
+		"#welle 0.1\nmatch (v) { case (x, 1) { x } }":  "match patterns: this feature requires edition 0.2, but the file is edition 0.1",
+		"#welle 0.1\nmatch (v) { case 1 if ok { 0 } }": "match guards: this feature requires edition 0.2, but the file is edition 0.1",
+	} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if errs := p.Errors(); len(errs) != 1 || errs[0] != want {
+			t.Errorf("%q: expected %q, got %v", input, want, errs)
+		}
+	}
+	p = New(lexer.New("#welle 0.1\nmatch (v) { case (1, 2) { 0 } }"))
+	p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("a pattern without names should parse in edition 0.1, got %v", p.Errors())
+	}
+}
+
+func TestParseCatchFilter(t *testing.T) {
+	p := New(lexer.New("try { f() } catch (e: [\"E_AUTH\", 1002]) { g(e) } finally { h() }"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	ts := prog.Statements[0].(*ast.TryStatement)
+	if ts.CatchName.Value != "e" {
+		t.Fatalf("expected catch name e, got %q", ts.CatchName.Value)
+	}
+	if _, ok := ts.CatchFilter.(*ast.ListLiteral); !ok {
+		t.Fatalf("expected a list filter, got %T", ts.CatchFilter)
+	}
+	if got := ts.String(); !strings.Contains(got, `catch (e: ["E_AUTH", 1002])`) {
+		t.Fatalf("unexpected String(): %q", got)
+	}
+
+	for input, want := range map[string]string{
+		"try { f() } catch (e:) { }":               "expected error codes after ':' in catch",
+		"#welle 0.1\ntry { f() } catch (e: 1) { }": "catch filters: this feature requires edition 0.2, but the file is edition 0.1",
+	} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if errs := p.Errors(); len(errs) == 0 || errs[0] != want {
+			t.Errorf("%q: expected %q, got %v", input, want, errs)
+		}
+	}
+}
+
 func TestParsePrefixBang(t *testing.T) {
 	input := "x = !a"
 
@@ -1377,3 +1838,121 @@ func TestParseIfSingleStatementErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestParseKeywordNames(t *testing.T) {
+	input := "" +
+		"export func is(e, kind) { return e }\n" +
+		"x = errors.is(e, 1)\n" +
+		"d.in = 2\n"
+
+	l := lexer.New(input)
+	p := New(l)
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("unexpected parser errors: %v", p.Errors())
+	}
+	if len(prog.Statements) != 3 {
+		t.Fatalf("expected 3 statements, got %d", len(prog.Statements))
+	}
+	exp, ok := prog.Statements[0].(*ast.ExportStatement)
+	if !ok {
+		t.Fatalf("stmt[0] - expected *ast.ExportStatement, got %T", prog.Statements[0])
+	}
+	fn, ok := exp.Stmt.(*ast.FuncStatement)
+	if !ok || fn.Name.Value != "is" {
+		t.Fatalf("stmt[0] - expected func named is, got %s", exp.Stmt.String())
+	}
+
+	// Keywords stay reserved for functions that are not exported.
+	for _, input := range []string{"func is(a) { return a }\n", "is = 1\n"} {
+		p := New(lexer.New(input))
+		_ = p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Fatalf("expected parser errors for input: %q", input)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	defer SetLimits(CurrentLimits())
+	SetLimits(Limits{MaxDepth: 50, MaxSourceBytes: 4096})
+
+	tests := []struct {
+		name  string
+		input string
+		code  string
+	}{
+		{"fits", "x = " + strings.Repeat("(", 40) + "1" + strings.Repeat(")", 40) + "\n", ""},
+		{"parens", "x = " + strings.Repeat("(", 1000) + "1" + strings.Repeat(")", 1000) + "\n", CodeTooDeep},
+		{"prefix", "x = " + strings.Repeat("-", 60) + "1\n", CodeTooDeep},
+		{"operator chain", "x = 1" + strings.Repeat(" + 1", 60) + "\n", ""},
+		{"nested operands", "x = 1" + strings.Repeat(" + (1", 60) + strings.Repeat(")", 60) + "\n", CodeTooDeep},
+		{"blocks", strings.Repeat("while (true) {\n", 60) + strings.Repeat("}\n", 60), CodeTooDeep},
+		{"else if chain", "if (a) { }" + strings.Repeat(" else if (a) { }", 60) + "\n", CodeTooDeep},
+		{"template", `x = t"${` + strings.Repeat("(", 60) + "1" + strings.Repeat(")", 60) + `}"` + "\n", CodeTooDeep},
+		{"size", "x = 1\n" + strings.Repeat(" ", 5000), CodeTooLarge},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		prog := p.ParseProgram()
+		diags := p.Diagnostics()
+		if tt.code == "" {
+			if len(diags) != 0 {
+				t.Fatalf("%s: unexpected errors: %v", tt.name, p.Errors())
+			}
+			continue
+		}
+		// The limit stops the parse, so it is the only error: nothing
+		// cascades from the constructs it left unclosed.
+		if len(diags) != 1 || diags[0].Code != tt.code {
+			t.Fatalf("%s: expected one %s error, got %v", tt.name, tt.code, diags)
+		}
+		if tt.code == CodeTooLarge && len(prog.Statements) != 0 {
+			t.Fatalf("%s: expected no statements, got %d", tt.name, len(prog.Statements))
+		}
+	}
+
+	// A long flat chain is not nesting, even under the default limits.
+	SetLimits(DefaultLimits)
+	p := New(lexer.New("x = 1" + strings.Repeat(" + 1", 1500) + "\n"))
+	if p.ParseProgram(); len(p.Errors()) != 0 {
+		t.Fatalf("flat chain: unexpected errors: %v", p.Errors())
+	}
+	SetLimits(Limits{MaxDepth: 50, MaxSourceBytes: 4096})
+
+	p = New(lexer.New("x = " + strings.Repeat("[", 60) + strings.Repeat("]", 60) + "\n"))
+	p.ParseProgram()
+	if want := "nesting too deep: more than 50 levels"; len(p.Errors()) != 1 || p.Errors()[0] != want {
+		t.Fatalf("expected %q, got %v", want, p.Errors())
+	}
+}
+
+func TestParseInternalError(t *testing.T) {
+	var reported []*ice.Error
+	defer ice.SetReporter(nil)
+	ice.SetReporter(func(e *ice.Error) { reported = append(reported, e) })
+
+	p := New(lexer.New("a = 1\nb = boom + 2\nc = 3\n"))
+	ident := p.prefixParseFns[token.IDENT]
+	p.registerPrefix(token.IDENT, func() ast.Expression {
+		if p.curToken.Literal == "boom" {
+			panic("parser bug")
+		}
+		return ident()
+	})
+	program := p.ParseProgram()
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("statements = %d, want the one before the panic", len(program.Statements))
+	}
+	diags := p.Diagnostics()
+	if len(diags) != 1 || diags[0].Code != ice.Code || diags[0].Range.Line != 2 {
+		t.Fatalf("diagnostics = %+v, want one %s on line 2", diags, ice.Code)
+	}
+	if !strings.Contains(diags[0].Message, "internal error, please report") || !strings.Contains(diags[0].Message, "parser bug") {
+		t.Fatalf("message = %q", diags[0].Message)
+	}
+	if len(reported) != 1 || reported[0].Phase != "parser" || !strings.Contains(reported[0].Repro, "boom") {
+		t.Fatalf("reported = %+v, want the parser panic with its source", reported)
+	}
+}
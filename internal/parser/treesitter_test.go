@@ -0,0 +1,193 @@
+package parser
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"welle/internal/ast"
+	"welle/internal/lexer"
+)
+
+var (
+	corpusHeader = regexp.MustCompile(`(?m)^={3,}\n(.+)\n={3,}\n`)
+	corpusSplit  = regexp.MustCompile(`(?m)^-{3,}\n`)
+	specFence    = regexp.MustCompile("(?m)^([ \t]*)```welle\n")
+
+	// tree-sitter prints ranges, field names and comments; the native
+	// tree has none of them.
+	tsRange   = regexp.MustCompile(` \[\d+, \d+\] - \[\d+, \d+\]`)
+	tsField   = regexp.MustCompile(`[a-z_]+: `)
+	tsComment = regexp.MustCompile(` ?\(comment\)`)
+
+	sexprNode   = regexp.MustCompile(`\(([a-z_]+)`)
+	grammarRule = regexp.MustCompile(`(?m)^\s+([a-z][a-z_]*): \((?:\$|_)\) =>`)
+)
+
+// TestTreeSitterCorpus checks the tree-sitter grammar's test corpus against
+// the native parser: every case must parse cleanly and print the expected
+// tree through ast.SExpr. `tree-sitter test` checks the same files against
+// the grammar.
+func TestTreeSitterCorpus(t *testing.T) {
+	files, err := filepath.Glob("../../tree-sitter-welle/test/corpus/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no corpus files found")
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := string(data)
+		headers := corpusHeader.FindAllStringSubmatchIndex(text, -1)
+		for i, h := range headers {
+			name := text[h[2]:h[3]]
+			end := len(text)
+			if i+1 < len(headers) {
+				end = headers[i+1][0]
+			}
+			parts := corpusSplit.Split(text[h[1]:end], 2)
+			if len(parts) != 2 {
+				t.Fatalf("%s: case %q has no --- separator", file, name)
+			}
+			t.Run(filepath.Base(file)+"/"+name, func(t *testing.T) {
+				p := New(lexer.New(strings.TrimSpace(parts[0]) + "\n"))
+				program := p.ParseProgram()
+				if errs := p.Errors(); len(errs) > 0 {
+					t.Fatalf("parse errors: %v", errs)
+				}
+				want := normalizeSExpr(parts[1])
+				if got := ast.SExpr(program); got != want {
+					t.Fatalf("tree mismatch\nwant: %s\ngot:  %s", want, got)
+				}
+			})
+		}
+	}
+}
+
+// specExamples returns the welle code blocks of docs/spec.md, keyed by the
+// line their fence opens on.
+func specExamples(t *testing.T) map[int]string {
+	t.Helper()
+	data, err := os.ReadFile("../../docs/spec.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	out := map[int]string{}
+	for _, m := range specFence.FindAllStringSubmatchIndex(text, -1) {
+		indent := text[m[2]:m[3]]
+		closing := "\n" + indent + "```"
+		end := strings.Index(text[m[1]-1:], closing)
+		if end < 0 {
+			t.Fatalf("spec.md: unterminated code block at offset %d", m[0])
+		}
+		var b strings.Builder
+		for _, line := range strings.SplitAfter(text[m[1]:m[1]-1+end+1], "\n") {
+			b.WriteString(strings.TrimPrefix(line, indent))
+		}
+		out[strings.Count(text[:m[0]], "\n")+1] = b.String()
+	}
+	return out
+}
+
+// treeSitter returns the tree-sitter CLI, installed globally or by npm in
+// tree-sitter-welle, or "" when there is none or the parser has not been
+// generated.
+func treeSitter(dir string) string {
+	if _, err := os.Stat(filepath.Join(dir, "src", "parser.c")); err != nil {
+		return ""
+	}
+	if path, err := exec.LookPath("tree-sitter"); err == nil {
+		return path
+	}
+	local := filepath.Join(dir, "node_modules", ".bin", "tree-sitter")
+	if _, err := os.Stat(local); err == nil {
+		return local
+	}
+	return ""
+}
+
+// TestTreeSitterSpecConformance parses every example in docs/spec.md that
+// the native parser accepts with the tree-sitter grammar too, and checks
+// both print the same tree. It needs the generated grammar (see
+// tree-sitter-welle/README.md) and is skipped without it, after checking
+// that grammar.js has a rule for every node the examples produce.
+func TestTreeSitterSpecConformance(t *testing.T) {
+	examples := specExamples(t)
+	if len(examples) == 0 {
+		t.Fatal("no welle examples found in docs/spec.md")
+	}
+	grammar, err := filepath.Abs("../../tree-sitter-welle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := os.ReadFile(filepath.Join(grammar, "grammar.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := map[string]bool{}
+	for _, m := range grammarRule.FindAllStringSubmatch(string(source), -1) {
+		rules[m[1]] = true
+	}
+	cli := treeSitter(grammar)
+	dir := t.TempDir()
+	accepted := 0
+	for line, src := range examples {
+		p := New(lexer.New(src))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			// Examples of errors and of fragments are not programs.
+			continue
+		}
+		accepted++
+		// Without the CLI this still catches a construct the grammar has
+		// no rule for.
+		missing := map[string]bool{}
+		for _, m := range sexprNode.FindAllStringSubmatch(ast.SExpr(program), -1) {
+			if !rules[m[1]] && !missing[m[1]] {
+				missing[m[1]] = true
+				t.Errorf("spec.md:%d: grammar.js has no %s rule", line, m[1])
+			}
+		}
+		if cli == "" {
+			continue
+		}
+		file := filepath.Join(dir, "example.wll")
+		if err := os.WriteFile(file, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command(cli, "parse", file)
+		cmd.Dir = grammar
+		var stdout bytes.Buffer
+		cmd.Stdout = &stdout
+		// tree-sitter exits non-zero when the tree has errors; the
+		// comparison below reports those.
+		_ = cmd.Run()
+		got := stdout.String()
+		got = tsRange.ReplaceAllString(got, "")
+		got = tsField.ReplaceAllString(got, "")
+		got = tsComment.ReplaceAllString(got, "")
+		if want := normalizeSExpr(ast.SExpr(program)); normalizeSExpr(got) != want {
+			t.Errorf("spec.md:%d: tree-sitter disagrees with the native parser\nwant: %s\ngot:  %s\nsource:\n%s", line, want, normalizeSExpr(got), src)
+		}
+	}
+	if accepted == 0 {
+		t.Fatal("the native parser rejected every spec example")
+	}
+	if cli == "" {
+		t.Skipf("%d spec examples parse natively; tree-sitter or the generated grammar is missing, run `npx tree-sitter generate` in tree-sitter-welle to compare them", accepted)
+	}
+}
+
+func normalizeSExpr(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.ReplaceAll(s, " )", ")")
+}
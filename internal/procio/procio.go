@@ -0,0 +1,240 @@
+// Package procio backs std:proc: running commands to completion and
+// spawning them with pipes that the script reads and writes line by line.
+// Spawned processes are kept in a registry and referred to by integer
+// handle.
+package procio
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"welle/internal/runtimeio"
+)
+
+// Options configures how a command is started.
+type Options struct {
+	// Dir is the working directory; empty means the current one.
+	Dir string
+	// Env holds variables added to (or replacing those in) the inherited
+	// environment.
+	Env map[string]string
+	// Stdin is fed to Run's command; spawned commands are written to with
+	// Write instead.
+	Stdin string
+	// Timeout bounds Run; zero waits forever.
+	Timeout time.Duration
+}
+
+// Result is what Run reports about a finished command.
+type Result struct {
+	Code   int
+	Stdout string
+	Stderr string
+}
+
+type process struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *bufio.Reader
+}
+
+var (
+	mu     sync.Mutex
+	procs        = map[int64]*process{}
+	nextID int64 = 1
+)
+
+func checkAllowed() error {
+	if !runtimeio.AllowExec() {
+		return errors.New("proc: running commands is disabled (run with -allow-exec)")
+	}
+	return nil
+}
+
+func command(ctx context.Context, name string, args []string, opts Options) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = opts.Dir
+	if len(opts.Env) > 0 {
+		keys := make([]string, 0, len(opts.Env))
+		for k := range opts.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		env := os.Environ()
+		for _, k := range keys {
+			env = append(env, k+"="+opts.Env[k])
+		}
+		cmd.Env = env
+	}
+	return cmd
+}
+
+// exitCode is the command's exit status, or -1 when it was killed by a
+// signal.
+func exitCode(err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, fmt.Errorf("proc: %v", err)
+}
+
+// Run starts name with args, waits for it and returns its exit code and
+// output. A non-zero exit is not an error.
+func Run(name string, args []string, opts Options) (Result, error) {
+	if err := checkAllowed(); err != nil {
+		return Result{}, err
+	}
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	cmd := command(ctx, name, args, opts)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = strings.NewReader(opts.Stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return Result{}, fmt.Errorf("proc: %s timed out after %v", name, opts.Timeout)
+	}
+	code, err := exitCode(err)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Code: code, Stdout: stdout.String(), Stderr: stderr.String()}, nil
+}
+
+// Spawn starts name with args and pipes to its stdin, stdout and stderr,
+// returning the process handle and pid.
+func Spawn(name string, args []string, opts Options) (int64, int, error) {
+	if err := checkAllowed(); err != nil {
+		return 0, 0, err
+	}
+	cmd := command(context.Background(), name, args, opts)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return 0, 0, fmt.Errorf("proc: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, 0, fmt.Errorf("proc: %v", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return 0, 0, fmt.Errorf("proc: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, 0, fmt.Errorf("proc: %v", err)
+	}
+	p := &process{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout), stderr: bufio.NewReader(stderr)}
+	mu.Lock()
+	defer mu.Unlock()
+	id := nextID
+	nextID++
+	procs[id] = p
+	return id, cmd.Process.Pid, nil
+}
+
+func lookup(id int64) (*process, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	p, ok := procs[id]
+	if !ok {
+		return nil, fmt.Errorf("proc: process %d is not running", id)
+	}
+	return p, nil
+}
+
+// Write sends data to a spawned process's stdin.
+func Write(id int64, data string) (int, error) {
+	p, err := lookup(id)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.WriteString(p.stdin, data)
+	if err != nil {
+		return n, fmt.Errorf("proc: %v", err)
+	}
+	return n, nil
+}
+
+// CloseStdin closes a spawned process's stdin so it sees end of input.
+func CloseStdin(id int64) error {
+	p, err := lookup(id)
+	if err != nil {
+		return err
+	}
+	if err := p.stdin.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return fmt.Errorf("proc: %v", err)
+	}
+	return nil
+}
+
+// ReadLine reads the next line of a spawned process's stdout, or of its
+// stderr when fromStderr is set, without the trailing newline. eof is true
+// once the stream is exhausted.
+func ReadLine(id int64, fromStderr bool) (line string, eof bool, err error) {
+	p, err := lookup(id)
+	if err != nil {
+		return "", false, err
+	}
+	r := p.stdout
+	if fromStderr {
+		r = p.stderr
+	}
+	line, err = r.ReadString('\n')
+	if errors.Is(err, io.EOF) || errors.Is(err, os.ErrClosed) {
+		return line, line == "", nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("proc: %v", err)
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), false, nil
+}
+
+// Wait closes stdin, waits for a spawned process to exit and releases its
+// handle. Output the script has not read is discarded.
+func Wait(id int64) (int, error) {
+	p, err := lookup(id)
+	if err != nil {
+		return 0, err
+	}
+	mu.Lock()
+	delete(procs, id)
+	mu.Unlock()
+	p.stdin.Close()
+	// Wait closes the pipes, so drain them first or a child blocked on a
+	// full pipe would never exit.
+	go io.Copy(io.Discard, p.stderr)
+	io.Copy(io.Discard, p.stdout)
+	return exitCode(p.cmd.Wait())
+}
+
+// Kill stops a spawned process. Wait still has to be called to reap it.
+func Kill(id int64) error {
+	p, err := lookup(id)
+	if err != nil {
+		return err
+	}
+	if err := p.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("proc: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package procio
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"welle/internal/runtimeio"
+)
+
+func allowExec(t *testing.T) {
+	runtimeio.SetAllowExec(true)
+	t.Cleanup(func() { runtimeio.SetAllowExec(false) })
+}
+
+func TestRun(t *testing.T) {
+	allowExec(t)
+	res, err := Run("sh", []string{"-c", "read x; echo \"in=$x env=$V\"; echo oops >&2; exit 4"}, Options{Stdin: "hi\n", Env: map[string]string{"V": "1"}})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Code != 4 || res.Stdout != "in=hi env=1\n" || res.Stderr != "oops\n" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	dir := t.TempDir()
+	if res, err := Run("pwd", nil, Options{Dir: dir}); err != nil || !strings.HasSuffix(strings.TrimSpace(res.Stdout), dir) {
+		t.Fatalf("pwd in %s = %+v, %v", dir, res, err)
+	}
+	if _, err := Run("sleep", []string{"5"}, Options{Timeout: 20 * time.Millisecond}); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	if _, err := Run("welle-no-such-command", nil, Options{}); err == nil || !strings.HasPrefix(err.Error(), "proc: ") {
+		t.Fatalf("expected start error, got %v", err)
+	}
+}
+
+func TestSpawnStreams(t *testing.T) {
+	allowExec(t)
+	id, pid, err := Spawn("sh", []string{"-c", "while read l; do echo \"got $l\"; done; echo done >&2; exit 2"}, Options{})
+	if err != nil || pid <= 0 {
+		t.Fatalf("spawn: %v (pid %d)", err, pid)
+	}
+	if _, err := Write(id, "one\n"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if line, eof, err := ReadLine(id, false); line != "got one" || eof || err != nil {
+		t.Fatalf("read_line = %q eof=%v err=%v", line, eof, err)
+	}
+	if err := CloseStdin(id); err != nil {
+		t.Fatalf("close stdin: %v", err)
+	}
+	if _, eof, _ := ReadLine(id, false); !eof {
+		t.Fatalf("expected stdout eof after stdin closed")
+	}
+	if line, _, _ := ReadLine(id, true); line != "done" {
+		t.Fatalf("stderr line = %q", line)
+	}
+	if code, err := Wait(id); code != 2 || err != nil {
+		t.Fatalf("wait = %d, %v", code, err)
+	}
+	if _, err := Write(id, "x"); err == nil || !strings.Contains(err.Error(), "is not running") {
+		t.Fatalf("expected released handle, got %v", err)
+	}
+}
+
+func TestKill(t *testing.T) {
+	allowExec(t)
+	id, _, err := Spawn("sleep", []string{"5"}, Options{})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	if err := Kill(id); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+	if code, err := Wait(id); code != -1 || err != nil {
+		t.Fatalf("wait after kill = %d, %v", code, err)
+	}
+}
+
+func TestCapability(t *testing.T) {
+	runtimeio.SetAllowExec(false)
+	if _, err := Run("true", nil, Options{}); err == nil || !strings.Contains(err.Error(), "-allow-exec") {
+		t.Fatalf("expected capability error, got %v", err)
+	}
+	if _, _, err := Spawn("true", nil, Options{}); err == nil || !strings.Contains(err.Error(), "-allow-exec") {
+		t.Fatalf("expected capability error, got %v", err)
+	}
+}
@@ -2,33 +2,50 @@ package repl
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"welle/internal/ast"
 	"welle/internal/compiler"
 	"welle/internal/lexer"
 	"welle/internal/module"
 	"welle/internal/object"
 	"welle/internal/parser"
-	"welle/internal/vm"
 )
 
 const (
 	prompt1 = "welle> "
 	prompt2 = "....> "
+
+	entryPath = "<repl>"
 )
 
 type Limits struct {
 	MaxRecursion int
 	MaxSteps     int64
 	MaxMemory    int64
+	MaxStack     int
+	MaxFrames    int
 }
 
-func Start(in io.Reader, out io.Writer, stdRoot string, limits Limits) {
-	scanner := bufio.NewScanner(in)
+// Session is a persistent VM environment: each Eval sees the globals and
+// loaded modules left by the previous ones. The line REPL and the notebook
+// server both run their input through it.
+type Session struct {
+	limits      Limits
+	loader      *module.Loader
+	symbols     *compiler.SymbolTable
+	globals     []object.Object
+	moduleCache map[string]*object.Dict
+}
+
+// NewSession starts an empty environment resolving std:* from stdRoot
+// (located as by `welle run` when empty) and relative imports from the working directory.
+func NewSession(stdRoot string, limits Limits) *Session {
 	cwd, err := os.Getwd()
 	if err != nil {
 		cwd = "."
@@ -36,13 +53,99 @@ func Start(in io.Reader, out io.Writer, stdRoot string, limits Limits) {
 	stdPath := stdRoot
 	if stdPath == "" {
 		stdPath = filepath.Join(cwd, "std")
+		if root, err := module.LocateStd("", cwd, stdPath); err == nil {
+			stdPath = root
+		}
 	}
 	resolver := module.NewResolver(stdPath, []string{cwd})
-	loader := module.NewLoader(resolver)
-	symbols := compiler.NewSymbolTable()
-	globals := make([]object.Object, vm.GlobalsSize)
-	moduleCache := map[string]*object.Dict{}
-	entryPath := "<repl>"
+	return &Session{
+		limits:      limits,
+		loader:      module.NewLoader(resolver),
+		symbols:     compiler.NewSymbolTable(),
+		moduleCache: map[string]*object.Dict{},
+	}
+}
+
+// Eval runs src in the session. The result is the value of a trailing
+// expression statement, or nil when src ends with any other statement.
+// A result other than nil becomes the global `_`, and the one before it
+// `__`, as in Python's REPL. Parse and compile errors are reported with a "parse error:" or
+// "compile error:" prefix; runtime errors as the VM reports them. Globals
+// assigned before a runtime error are kept.
+func (s *Session) Eval(src string) (object.Object, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		lines := make([]string, len(errs))
+		for i, e := range errs {
+			lines[i] = "parse error: " + e
+		}
+		return nil, errors.New(strings.Join(lines, "\n"))
+	}
+
+	c := compiler.NewWithFileAndSymbols(entryPath, s.symbols)
+	if err := c.Compile(program); err != nil {
+		return nil, fmt.Errorf("compile error: %s", err)
+	}
+	bc := c.Bytecode()
+	if err := compiler.Verify(bc); err != nil {
+		return nil, fmt.Errorf("compile error: %s", err)
+	}
+	m := s.loader.NewVM(bc, entryPath)
+	m.SetMaxRecursion(s.limits.MaxRecursion)
+	m.SetMaxSteps(s.limits.MaxSteps)
+	m.SetMaxMemory(s.limits.MaxMemory)
+	m.SetMaxStack(s.limits.MaxStack)
+	m.SetMaxFrames(s.limits.MaxFrames)
+	m.SetGlobals(s.globals)
+	m.SetModuleCache(s.moduleCache)
+	err := m.Run()
+	s.globals = m.Globals()
+	if err != nil {
+		return nil, err
+	}
+	// Only a trailing expression has a value; statements such as
+	// `a[0] = 1` also pop one but do not produce a result.
+	if !endsWithExpression(program) {
+		return nil, nil
+	}
+	res := m.LastPoppedStackElem()
+	if res != nil && res.Type() != object.NIL_OBJ {
+		if prev, ok := s.global("_"); ok {
+			s.setGlobal("__", prev)
+		}
+		s.setGlobal("_", res)
+	}
+	return res, nil
+}
+
+func (s *Session) global(name string) (object.Object, bool) {
+	sym, ok := s.symbols.ResolveCurrent(name)
+	if !ok || sym.Index >= len(s.globals) || s.globals[sym.Index] == nil {
+		return nil, false
+	}
+	return s.globals[sym.Index], true
+}
+
+// setGlobal assigns a session global, defining it for the inputs that
+// follow if no input has yet.
+func (s *Session) setGlobal(name string, val object.Object) {
+	sym, ok := s.symbols.ResolveCurrent(name)
+	if !ok {
+		sym = s.symbols.Define(name)
+	}
+	if sym.Index >= len(s.globals) {
+		globals := make([]object.Object, sym.Index+1)
+		copy(globals, s.globals)
+		s.globals = globals
+	}
+	s.globals[sym.Index] = val
+}
+
+func Start(in io.Reader, out io.Writer, stdRoot string, limits Limits) {
+	scanner := bufio.NewScanner(in)
+	session := NewSession(stdRoot, limits)
 
 	fmt.Fprint(out, "Welle REPL (Ctrl+D to exit)\n")
 
@@ -90,38 +193,25 @@ func Start(in io.Reader, out io.Writer, stdRoot string, limits Limits) {
 		src := buf.String()
 		buf.Reset()
 
-		l := lexer.New(src)
-		p := parser.New(l)
-		program := p.ParseProgram()
-
-		if len(p.Errors()) > 0 {
-			printParserErrors(out, p.Errors())
-			continue
-		}
-
-		c := compiler.NewWithFileAndSymbols(entryPath, symbols)
-		if err := c.Compile(program); err != nil {
-			fmt.Fprintf(out, "compile error: %s\n", err)
-			continue
-		}
-		bc := c.Bytecode()
-		m := loader.NewVM(bc, entryPath)
-		m.SetMaxRecursion(limits.MaxRecursion)
-		m.SetMaxSteps(limits.MaxSteps)
-		m.SetMaxMemory(limits.MaxMemory)
-		m.SetGlobals(globals)
-		m.SetModuleCache(moduleCache)
-		if err := m.Run(); err != nil {
+		result, err := session.Eval(src)
+		if err != nil {
 			fmt.Fprintln(out, err)
 			continue
 		}
-		result := m.LastPoppedStackElem()
 		if result != nil && result.Type() != object.NIL_OBJ {
 			fmt.Fprintln(out, result.Inspect())
 		}
 	}
 }
 
+func endsWithExpression(program *ast.Program) bool {
+	if len(program.Statements) == 0 {
+		return false
+	}
+	_, ok := program.Statements[len(program.Statements)-1].(*ast.ExpressionStatement)
+	return ok
+}
+
 func updateBalance(line string, braces, parens int, inString, escaped, inBlockComment bool) (int, int, bool, bool, bool) {
 	for i := 0; i < len(line); i++ {
 		ch := line[i]
@@ -178,9 +268,3 @@ func updateBalance(line string, braces, parens int, inString, escaped, inBlockCo
 	}
 	return braces, parens, inString, escaped, inBlockComment
 }
-
-func printParserErrors(out io.Writer, errs []string) {
-	for _, e := range errs {
-		fmt.Fprintf(out, "parse error: %s\n", e)
-	}
-}
@@ -0,0 +1,48 @@
+package repl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStartEchoesAndBindsLastResult(t *testing.T) {
+	in := strings.Join([]string{
+		"1 + 2",
+		"x = 10",
+		"_ * x",
+		"nil",
+		"[_, __]",
+		"func f() {",
+		"  return _",
+		"}",
+		"f()",
+	}, "\n") + "\n"
+	var out strings.Builder
+	Start(strings.NewReader(in), &out, "", Limits{})
+
+	echo := strings.NewReplacer(prompt1, "\n", prompt2, "\n").Replace(out.String())
+	var got []string
+	for _, line := range strings.Split(echo, "\n") {
+		if line != "" && !strings.HasPrefix(line, "Welle REPL") {
+			got = append(got, line)
+		}
+	}
+	want := []string{"3", "30", "[30, 3]", "[30, 3]"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("echo = %q, want %q", got, want)
+	}
+}
+
+func TestUnderscoreUndefinedBeforeFirstResult(t *testing.T) {
+	s := NewSession("", Limits{})
+	if _, err := s.Eval("_\n"); err == nil || !strings.Contains(err.Error(), "_") {
+		t.Fatalf("expected an unknown identifier error for _, got %v", err)
+	}
+	if _, err := s.Eval("x = 1\nx\n"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := s.Eval("_\n")
+	if err != nil || res.Inspect() != "1" {
+		t.Fatalf("_ = %v, %v; want 1", res, err)
+	}
+}
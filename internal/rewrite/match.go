@@ -0,0 +1,94 @@
+package rewrite
+
+import (
+	"reflect"
+	"strings"
+
+	"welle/internal/ast"
+	"welle/internal/token"
+)
+
+// bindings maps metavariable names (without `$`) to matched expressions.
+type bindings map[string]ast.Expression
+
+var (
+	tokenType    = reflect.TypeOf(token.Token{})
+	templateType = reflect.TypeOf(ast.TemplateLiteral{})
+)
+
+func (r *Rule) match(e ast.Expression) (bindings, bool) {
+	b := bindings{}
+	if !matchValue(reflect.ValueOf(r.pattern), reflect.ValueOf(e), b) {
+		return nil, false
+	}
+	return b, true
+}
+
+// matchValue compares pattern and subject field by field, skipping tokens
+// (positions and original spelling). With b == nil metavariables are not
+// special, which is how repeated uses of one `$name` are checked.
+func matchValue(pat, subj reflect.Value, b bindings) bool {
+	if b != nil && (pat.Kind() == reflect.Pointer || pat.Kind() == reflect.Interface) && !pat.IsNil() {
+		if id, ok := pat.Interface().(*ast.Identifier); ok && strings.HasPrefix(id.Value, metaPrefix) {
+			return bind(strings.TrimPrefix(id.Value, metaPrefix), subj, b)
+		}
+	}
+	if pat.Kind() != subj.Kind() {
+		return false
+	}
+	switch pat.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if pat.IsNil() || subj.IsNil() {
+			return pat.IsNil() == subj.IsNil()
+		}
+		if pat.Elem().Type() != subj.Elem().Type() {
+			return false
+		}
+		return matchValue(pat.Elem(), subj.Elem(), b)
+	case reflect.Struct:
+		if pat.Type() == tokenType {
+			return true
+		}
+		for i := 0; i < pat.NumField(); i++ {
+			if !pat.Type().Field(i).IsExported() {
+				continue
+			}
+			if !matchValue(pat.Field(i), subj.Field(i), b) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		if pat.Len() != subj.Len() {
+			return false
+		}
+		for i := 0; i < pat.Len(); i++ {
+			if !matchValue(pat.Index(i), subj.Index(i), b) {
+				return false
+			}
+		}
+		return true
+	}
+	return pat.Equal(subj)
+}
+
+func bind(name string, subj reflect.Value, b bindings) bool {
+	if subj.Kind() == reflect.Interface {
+		subj = subj.Elem()
+	}
+	if !subj.IsValid() || (subj.Kind() == reflect.Pointer && subj.IsNil()) {
+		return false
+	}
+	e, ok := subj.Interface().(ast.Expression)
+	if !ok {
+		return false
+	}
+	if name == "_" {
+		return true
+	}
+	if prev, ok := b[name]; ok {
+		return matchValue(reflect.ValueOf(prev), reflect.ValueOf(e), nil)
+	}
+	b[name] = e
+	return true
+}
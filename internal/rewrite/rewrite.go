@@ -0,0 +1,254 @@
+// Package rewrite applies pattern-based expression rewrites to Welle
+// source. A pattern is an ordinary expression in which `$name` stands for
+// any subexpression; the replacement is an expression template that may
+// reuse the same names. Matching is structural (positions, spelling of
+// numbers and grouping parentheses are ignored) and edits are applied to
+// the original text, so code outside a match keeps its formatting.
+package rewrite
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"welle/internal/ast"
+	"welle/internal/lexer"
+	"welle/internal/parser"
+)
+
+// metaPrefix is what `$name` becomes before a pattern is parsed; the lexer
+// has no `$` token outside templates.
+const metaPrefix = "__rewrite_"
+
+var metaVar = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+type Rule struct {
+	pattern  ast.Expression
+	template string // replacement with `$name` spelled as metaPrefix+name
+	holes    []hole
+	replRoot ast.Expression
+}
+
+// hole is one metavariable occurrence in the template.
+type hole struct {
+	start, end int
+	name       string
+	operand    bool // needs parentheses around a non-atomic binding
+}
+
+// Compile parses pattern and replacement. Every `$name` used in the
+// replacement must appear in the pattern; `$_` matches anything without
+// binding and may not be used in the replacement.
+func Compile(pattern, replacement string) (*Rule, error) {
+	pat, err := parseExpr(metaVar.ReplaceAllString(pattern, metaPrefix+"$1"))
+	if err != nil {
+		return nil, fmt.Errorf("pattern: %w", err)
+	}
+	template := metaVar.ReplaceAllString(replacement, metaPrefix+"$1")
+	repl, err := parseExpr(template)
+	if err != nil {
+		return nil, fmt.Errorf("replacement: %w", err)
+	}
+	bound := map[string]bool{}
+	for _, m := range metaVar.FindAllStringSubmatch(pattern, -1) {
+		bound[m[1]] = true
+	}
+	for _, m := range metaVar.FindAllStringSubmatch(replacement, -1) {
+		if m[1] == "_" {
+			return nil, fmt.Errorf("replacement: $_ cannot be used in a replacement")
+		}
+		if !bound[m[1]] {
+			return nil, fmt.Errorf("replacement: $%s does not appear in the pattern", m[1])
+		}
+	}
+	r := &Rule{pattern: pat, template: template, replRoot: repl}
+	lines := lineStarts(template)
+	walk(reflect.ValueOf(repl), nil, func(e ast.Expression, parent ast.Node) bool {
+		id, ok := e.(*ast.Identifier)
+		if !ok || !strings.HasPrefix(id.Value, metaPrefix) {
+			return true
+		}
+		start := lines[id.Token.Line] + id.Token.Col - 1
+		r.holes = append(r.holes, hole{
+			start:   start,
+			end:     start + len(id.Value),
+			name:    strings.TrimPrefix(id.Value, metaPrefix),
+			operand: parent != nil && isOperand(parent, e),
+		})
+		return false
+	})
+	sort.Slice(r.holes, func(i, j int) bool { return r.holes[i].start < r.holes[j].start })
+	return r, nil
+}
+
+// walk calls fn for every expression below v in source order, passing the
+// closest enclosing node. Returning false skips the expression's children.
+// Template interpolations are not visited: they are parsed from a substring
+// and their positions do not map onto the file.
+func walk(v reflect.Value, parent ast.Node, fn func(e ast.Expression, parent ast.Node) bool) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if !v.IsNil() {
+			walk(v.Elem(), parent, fn)
+		}
+	case reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		if tl, ok := v.Interface().(*ast.TemplateLiteral); ok {
+			if fn(tl, parent) {
+				walk(reflect.ValueOf(tl.Tag), tl, fn)
+			}
+			return
+		}
+		if e, ok := v.Interface().(ast.Expression); ok && !fn(e, parent) {
+			return
+		}
+		if n, ok := v.Interface().(ast.Node); ok {
+			parent = n
+		}
+		walk(v.Elem(), parent, fn)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				walk(v.Field(i), parent, fn)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			walk(v.Index(i), parent, fn)
+		}
+	}
+}
+
+func parseExpr(src string) (ast.Expression, error) {
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, fmt.Errorf("%s", errs[0])
+	}
+	if len(prog.Statements) != 1 {
+		return nil, fmt.Errorf("expected a single expression")
+	}
+	es, ok := prog.Statements[0].(*ast.ExpressionStatement)
+	if !ok || es.Expression == nil {
+		return nil, fmt.Errorf("expected an expression")
+	}
+	return es.Expression, nil
+}
+
+type edit struct {
+	start, end int
+	text       string
+}
+
+// Apply rewrites every non-overlapping match in src, outermost first, and
+// returns the new source with the number of rewrites. The result is parsed
+// again so a rewrite that breaks the file is reported instead of written.
+func (r *Rule) Apply(src string) (string, int, error) {
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return "", 0, fmt.Errorf("parse error: %s", errs[0])
+	}
+	text := newSource(src)
+
+	var edits []edit
+	walk(reflect.ValueOf(prog), nil, func(e ast.Expression, parent ast.Node) bool {
+		b, ok := r.match(e)
+		if !ok {
+			return true
+		}
+		ed, ok := r.edit(text, e, parent, b)
+		if !ok {
+			return true
+		}
+		edits = append(edits, ed)
+		return false
+	})
+	if len(edits) == 0 {
+		return src, 0, nil
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var out strings.Builder
+	last := 0
+	count := 0
+	for _, ed := range edits {
+		if ed.start < last {
+			continue
+		}
+		out.WriteString(src[last:ed.start])
+		out.WriteString(ed.text)
+		last = ed.end
+		count++
+	}
+	out.WriteString(src[last:])
+	result := out.String()
+
+	check := parser.New(lexer.New(result))
+	check.ParseProgram()
+	if errs := check.Errors(); len(errs) > 0 {
+		return "", 0, fmt.Errorf("rewrite produced invalid code: %s", errs[0])
+	}
+	return result, count, nil
+}
+
+func (r *Rule) edit(text *source, e ast.Expression, parent ast.Node, b bindings) (edit, bool) {
+	start, end, ok := text.span(e)
+	if !ok {
+		return edit{}, false
+	}
+	var out strings.Builder
+	last := 0
+	for _, h := range r.holes {
+		out.WriteString(r.template[last:h.start])
+		bound := b[h.name]
+		if s, e, ok := text.span(bound); ok {
+			if h.operand && !isAtomic(bound) {
+				out.WriteString("(" + text.src[s:e] + ")")
+			} else {
+				out.WriteString(text.src[s:e])
+			}
+		} else {
+			out.WriteString(bound.String())
+		}
+		last = h.end
+	}
+	out.WriteString(r.template[last:])
+	repl := out.String()
+	if !isAtomic(r.replRoot) && isOperand(parent, e) && !text.grouped(start, end) {
+		repl = "(" + repl + ")"
+	}
+	return edit{start: start, end: end, text: repl}, true
+}
+
+// isAtomic reports whether e can be used as an operand without parentheses.
+func isAtomic(e ast.Node) bool {
+	switch e.(type) {
+	case *ast.InfixExpression, *ast.PrefixExpression, *ast.ConditionalExpression,
+		*ast.CondExpr, *ast.AssignExpression, *ast.FunctionLiteral:
+		return false
+	}
+	return true
+}
+
+// isOperand reports whether e sits where a bare binary expression would
+// bind differently, such as an operand or the receiver of a call.
+func isOperand(parent ast.Node, e ast.Expression) bool {
+	switch p := parent.(type) {
+	case *ast.InfixExpression, *ast.PrefixExpression:
+		return true
+	case *ast.MemberExpression:
+		return p.Object == e
+	case *ast.IndexExpression:
+		return p.Left == e
+	case *ast.SliceExpression:
+		return p.Left == e
+	case *ast.CallExpression:
+		return p.Function == e
+	}
+	return false
+}
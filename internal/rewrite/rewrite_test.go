@@ -0,0 +1,123 @@
+package rewrite
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		pattern     string
+		replacement string
+		src         string
+		want        string
+		count       int
+	}{
+		{
+			name:        "keeps surrounding text",
+			pattern:     "len($x) == 0",
+			replacement: "$x.is_empty()",
+			src:         "if (len(xs) == 0) {  // none\n  print( len(ys[0])==0 )\n}\n",
+			want:        "if (xs.is_empty()) {  // none\n  print( ys[0].is_empty() )\n}\n",
+			count:       2,
+		},
+		{
+			name:        "grouping parentheses are part of the match",
+			pattern:     "len($x) == 0",
+			replacement: "$x.is_empty()",
+			src:         "ok = (len(a + b)) == 0\n",
+			want:        "ok = (a + b).is_empty()\n",
+			count:       1,
+		},
+		{
+			name:        "replacement is parenthesized as an operand",
+			pattern:     "$a ?? $b",
+			replacement: "$a if $a != nil else $b",
+			src:         "x = (p ?? q) + 1\ny = p ?? q\n",
+			want:        "x = (p if p != nil else q) + 1\ny = p if p != nil else q\n",
+			count:       2,
+		},
+		{
+			name:        "repeated metavariable must match the same expression",
+			pattern:     "$x == $x",
+			replacement: "true",
+			src:         "a = f(1) == f(1)\nb = f(1) == f(2)\n",
+			want:        "a = true\nb = f(1) == f(2)\n",
+			count:       1,
+		},
+		{
+			name:        "outermost match wins",
+			pattern:     "not not $x",
+			replacement: "bool($x)",
+			src:         "v = not not not not y\n",
+			want:        "v = bool(not not y)\n",
+			count:       1,
+		},
+		{
+			name:        "no match",
+			pattern:     "len($x) == 0",
+			replacement: "$x.is_empty()",
+			src:         "n = len(xs) == 1\n",
+			want:        "n = len(xs) == 1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Compile(tt.pattern, tt.replacement)
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			got, n, err := rule.Apply(tt.src)
+			if err != nil {
+				t.Fatalf("apply: %v", err)
+			}
+			if got != tt.want || n != tt.count {
+				t.Fatalf("got %d rewrite(s):\n%s\nwant %d:\n%s", n, got, tt.count, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		pattern, replacement, want string
+	}{
+		{"len(($x)", "$x", "pattern:"},
+		{"len($x)", "$y", "$y does not appear in the pattern"},
+		{"f($_)", "$_", "$_ cannot be used"},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.pattern, tt.replacement)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Compile(%q, %q): expected error containing %q, got %v", tt.pattern, tt.replacement, tt.want, err)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nJ\n"
+	want := `--- m.wll
++++ m.wll
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -7,4 +7,4 @@
+ g
+ h
+ i
+-j
++J
+`
+	if got := Diff("m.wll", before, after); got != want {
+		t.Fatalf("unexpected diff:\n%s", got)
+	}
+	if Diff("m.wll", before, before) != "" {
+		t.Fatal("expected empty diff for equal input")
+	}
+}
@@ -0,0 +1,138 @@
+package rewrite
+
+import (
+	"reflect"
+	"strings"
+
+	"welle/internal/ast"
+	"welle/internal/lexer"
+	"welle/internal/token"
+)
+
+// source maps AST token positions back to byte ranges of the original text.
+type source struct {
+	src    string
+	toks   []token.Token
+	starts []int // byte offset of each token
+	index  map[[2]int]int
+}
+
+// lineStarts returns the byte offset of each 1-based line (index 0 unused).
+func lineStarts(src string) []int {
+	starts := []int{0, 0}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+func newSource(src string) *source {
+	s := &source{src: src, index: map[[2]int]int{}}
+	lineStarts := lineStarts(src)
+	l := lexer.New(src)
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			break
+		}
+		if tok.Line <= 0 || tok.Line >= len(lineStarts) {
+			continue
+		}
+		s.index[[2]int{tok.Line, tok.Col}] = len(s.toks)
+		s.toks = append(s.toks, tok)
+		s.starts = append(s.starts, lineStarts[tok.Line]+tok.Col-1)
+	}
+	return s
+}
+
+func (s *source) end(i int) int {
+	lexeme := s.toks[i].Raw
+	if lexeme == "" {
+		lexeme = s.toks[i].Literal
+	}
+	return s.starts[i] + len(lexeme)
+}
+
+// span returns the byte range covered by e: from its first to its last
+// token, widened until brackets inside the range are balanced so closing
+// `)`/`]`/`}` and grouping parentheses are included.
+func (s *source) span(e ast.Expression) (int, int, bool) {
+	first, last := -1, -1
+	var collect func(v reflect.Value)
+	collect = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Interface, reflect.Pointer:
+			if !v.IsNil() {
+				collect(v.Elem())
+			}
+		case reflect.Struct:
+			if v.Type() == tokenType {
+				tok := v.Interface().(token.Token)
+				if i, ok := s.index[[2]int{tok.Line, tok.Col}]; ok {
+					if first < 0 || i < first {
+						first = i
+					}
+					if i > last {
+						last = i
+					}
+				}
+				return
+			}
+			if v.Type() == templateType {
+				collect(v.FieldByName("Token"))
+				collect(v.FieldByName("Tag"))
+				return
+			}
+			for i := 0; i < v.NumField(); i++ {
+				if v.Type().Field(i).IsExported() {
+					collect(v.Field(i))
+				}
+			}
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				collect(v.Index(i))
+			}
+		}
+	}
+	collect(reflect.ValueOf(e))
+	if first < 0 {
+		return 0, 0, false
+	}
+
+	depth, minDepth := 0, 0
+	for i := first; i <= last; i++ {
+		depth += bracketDelta(s.toks[i].Type)
+		if depth < minDepth {
+			minDepth = depth
+		}
+	}
+	for need := -minDepth; need > 0 && first > 0; {
+		first--
+		need -= bracketDelta(s.toks[first].Type)
+	}
+	for need := depth - minDepth; need > 0 && last < len(s.toks)-1; {
+		last++
+		need += bracketDelta(s.toks[last].Type)
+	}
+	return s.starts[first], s.end(last), true
+}
+
+func bracketDelta(t token.Type) int {
+	switch t {
+	case token.LPAREN, token.LBRACKET, token.LBRACE:
+		return 1
+	case token.RPAREN, token.RBRACKET, token.RBRACE:
+		return -1
+	}
+	return 0
+}
+
+// grouped reports whether the byte range [start, end) is directly wrapped
+// in parentheses, ignoring whitespace.
+func (s *source) grouped(start, end int) bool {
+	before := strings.TrimRight(s.src[:start], " \t\r\n")
+	after := strings.TrimLeft(s.src[end:], " \t\r\n")
+	return strings.HasSuffix(before, "(") && strings.HasPrefix(after, ")")
+}
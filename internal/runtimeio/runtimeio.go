@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"golang.org/x/term"
@@ -16,6 +17,67 @@ var (
 	ErrGetpassUnavailable = errors.New("getpass is not available in non-interactive mode")
 )
 
+var (
+	scriptPath string
+	scriptArgs []string
+	allowFS    bool
+	allowNet   bool
+	allowExec  bool
+)
+
+// SetAllowFS grants or revokes the file system capability (-allow-fs),
+// which builtins that open files on disk check before doing so.
+func SetAllowFS(on bool) {
+	allowFS = on
+}
+
+// AllowFS reports whether scripts may open files on disk.
+func AllowFS() bool {
+	return allowFS
+}
+
+// SetAllowNet grants or revokes the network capability (-allow-net).
+func SetAllowNet(on bool) {
+	allowNet = on
+}
+
+// AllowNet reports whether scripts may open sockets.
+func AllowNet() bool {
+	return allowNet
+}
+
+// SetAllowExec grants or revokes the capability to run other programs
+// (-allow-exec).
+func SetAllowExec(on bool) {
+	allowExec = on
+}
+
+// AllowExec reports whether scripts may start processes.
+func AllowExec() bool {
+	return allowExec
+}
+
+// SetArgs records the script being run and the command-line arguments that
+// follow it, which the args() builtin returns.
+func SetArgs(script string, args []string) {
+	scriptPath = script
+	scriptArgs = append([]string(nil), args...)
+}
+
+// Args returns the script's command-line arguments.
+func Args() []string {
+	return scriptArgs
+}
+
+// ScriptName returns the base name of the script being run, or "" when no
+// script was recorded (the REPL, tests).
+func ScriptName() string {
+	if scriptPath == "" {
+		return ""
+	}
+	return filepath.Base(scriptPath)
+}
+
 func IsInteractive() bool {
 	return term.IsTerminal(int(os.Stdin.Fd()))
 }
@@ -0,0 +1,61 @@
+package semantics
+
+import (
+	"fmt"
+	"math"
+
+	"welle/internal/object"
+)
+
+// CheckedInt applies "+", "-" or "*" to a and b. ok is false when the exact
+// result does not fit in 64 bits; the result is then the wrapped value the
+// operator itself produces.
+func CheckedInt(op string, a, b int64) (result int64, ok bool) {
+	switch op {
+	case "+":
+		result = a + b
+		ok = (b >= 0) == (result >= a)
+	case "-":
+		result = a - b
+		ok = (b >= 0) == (result <= a)
+	case "*":
+		result = a * b
+		ok = a == 0 || (result/a == b && !(a == -1 && b == math.MinInt64))
+	}
+	return result, ok
+}
+
+// FloorDiv divides a by b rounding toward negative infinity, where "/"
+// truncates toward zero: FloorDiv(-7, 2) is -4. Like "/", math.MinInt64
+// divided by -1 wraps to math.MinInt64. b must not be zero.
+func FloorDiv(a, b int64) int64 {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+// FloorMod returns the remainder matching FloorDiv, which has the sign of
+// b where "%" gives the sign of a: FloorMod(-7, 3) is 2. b must not be zero.
+func FloorMod(a, b int64) int64 {
+	r := a % b
+	if r != 0 && (r < 0) != (b < 0) {
+		r += b
+	}
+	return r
+}
+
+// IntPair returns the two INTEGER arguments of the builtin name, or an error
+// naming it.
+func IntPair(name string, args []object.Object) (int64, int64, error) {
+	if len(args) != 2 {
+		return 0, 0, fmt.Errorf("wrong number of arguments: expected 2, got %d", len(args))
+	}
+	a, aok := args[0].(*object.Integer)
+	b, bok := args[1].(*object.Integer)
+	if !aok || !bok {
+		return 0, 0, fmt.Errorf("%s() expects INTEGER arguments", name)
+	}
+	return a.Value, b.Value, nil
+}
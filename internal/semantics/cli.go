@@ -0,0 +1,571 @@
+package semantics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"welle/internal/object"
+)
+
+// cliOption is one flag or positional argument of a std:cli spec.
+type cliOption struct {
+	name     string
+	key      string
+	short    string
+	typ      string
+	help     string
+	metavar  string
+	def      object.Object
+	required bool
+	variadic bool
+	choices  []string
+}
+
+type cliSpec struct {
+	name        string
+	about       string
+	flags       []*cliOption
+	positionals []*cliOption
+}
+
+// CLIParse implements cli_parse(spec, argv), the parser behind std:cli.
+// name is the program name used when the spec has none.
+func CLIParse(args []object.Object, name string) (*object.Dict, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	spec, err := parseCLISpec(args[0], name)
+	if err != nil {
+		return nil, err
+	}
+	argv, ok := args[1].(*object.Array)
+	if !ok {
+		return nil, fmt.Errorf("cli: argv must be ARRAY, got %s", args[1].Type())
+	}
+	words := make([]string, len(argv.Elements))
+	for i, el := range argv.Elements {
+		s, ok := el.(*object.String)
+		if !ok {
+			return nil, fmt.Errorf("cli: argv must contain only STRING, got %s", el.Type())
+		}
+		words[i] = s.Value
+	}
+	return spec.parse(words)
+}
+
+// CLIHelp implements cli_help(spec): the usage and option summary printed
+// for -h/--help.
+func CLIHelp(args []object.Object, name string) (string, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return "", err
+	}
+	spec, err := parseCLISpec(args[0], name)
+	if err != nil {
+		return "", err
+	}
+	return spec.helpText(), nil
+}
+
+func parseCLISpec(obj object.Object, name string) (*cliSpec, error) {
+	d, ok := obj.(*object.Dict)
+	if !ok {
+		return nil, fmt.Errorf("cli: spec must be DICT, got %s", obj.Type())
+	}
+	if name == "" {
+		name = "script"
+	}
+	spec := &cliSpec{name: name}
+	if v := dictField(d, "name"); v != nil {
+		s, ok := v.(*object.String)
+		if !ok || s.Value == "" {
+			return nil, fmt.Errorf("cli: spec name must be a non-empty STRING")
+		}
+		spec.name = s.Value
+	}
+	if v := dictField(d, "about"); v != nil {
+		s, ok := v.(*object.String)
+		if !ok {
+			return nil, fmt.Errorf("cli: spec about must be STRING")
+		}
+		spec.about = s.Value
+	}
+	keys := map[string]string{"help": "--help"}
+	shorts := map[string]bool{"h": true}
+	flags, err := cliOptionList(d, "flags")
+	if err != nil {
+		return nil, err
+	}
+	for _, el := range flags {
+		opt, err := parseCLIOption(el, true)
+		if err != nil {
+			return nil, err
+		}
+		if prev, dup := keys[opt.key]; dup {
+			return nil, fmt.Errorf("cli: flag --%s clashes with %s", opt.name, prev)
+		}
+		keys[opt.key] = "--" + opt.name
+		if opt.short != "" {
+			if shorts[opt.short] {
+				return nil, fmt.Errorf("cli: short flag -%s is used twice", opt.short)
+			}
+			shorts[opt.short] = true
+		}
+		spec.flags = append(spec.flags, opt)
+	}
+	positionals, err := cliOptionList(d, "positionals")
+	if err != nil {
+		return nil, err
+	}
+	optional := false
+	for i, el := range positionals {
+		opt, err := parseCLIOption(el, false)
+		if err != nil {
+			return nil, err
+		}
+		if prev, dup := keys[opt.key]; dup {
+			return nil, fmt.Errorf("cli: argument <%s> clashes with %s", opt.name, prev)
+		}
+		keys[opt.key] = "<" + opt.name + ">"
+		if opt.variadic && i != len(positionals)-1 {
+			return nil, fmt.Errorf("cli: variadic argument <%s> must come last", opt.name)
+		}
+		if opt.required && optional {
+			return nil, fmt.Errorf("cli: required argument <%s> follows an optional one", opt.name)
+		}
+		optional = optional || !opt.required
+		spec.positionals = append(spec.positionals, opt)
+	}
+	return spec, nil
+}
+
+func cliOptionList(d *object.Dict, field string) ([]object.Object, error) {
+	v := dictField(d, field)
+	if v == nil {
+		return nil, nil
+	}
+	arr, ok := v.(*object.Array)
+	if !ok {
+		return nil, fmt.Errorf("cli: spec %s must be ARRAY", field)
+	}
+	return arr.Elements, nil
+}
+
+func parseCLIOption(obj object.Object, isFlag bool) (*cliOption, error) {
+	kind := "positional"
+	if isFlag {
+		kind = "flag"
+	}
+	d, ok := obj.(*object.Dict)
+	if !ok {
+		return nil, fmt.Errorf("cli: each %s must be DICT, got %s", kind, obj.Type())
+	}
+	str := func(field string) (string, error) {
+		v := dictField(d, field)
+		if v == nil {
+			return "", nil
+		}
+		s, ok := v.(*object.String)
+		if !ok {
+			return "", fmt.Errorf("cli: %s %s must be STRING", kind, field)
+		}
+		return s.Value, nil
+	}
+	flag := func(field string, def bool) (bool, error) {
+		v := dictField(d, field)
+		if v == nil {
+			return def, nil
+		}
+		b, ok := v.(*object.Boolean)
+		if !ok {
+			return false, fmt.Errorf("cli: %s %s must be BOOLEAN", kind, field)
+		}
+		return b.Value, nil
+	}
+	opt := &cliOption{}
+	var err error
+	if opt.name, err = str("name"); err != nil {
+		return nil, err
+	}
+	if opt.name == "" || strings.HasPrefix(opt.name, "-") || strings.ContainsAny(opt.name, " =") {
+		return nil, fmt.Errorf("cli: %s name %q is not valid", kind, opt.name)
+	}
+	opt.key = strings.ReplaceAll(opt.name, "-", "_")
+	if opt.typ, err = str("type"); err != nil {
+		return nil, err
+	}
+	if opt.typ == "" {
+		opt.typ = "string"
+	}
+	switch opt.typ {
+	case "string", "int", "float":
+	case "bool", "list":
+		if !isFlag {
+			return nil, fmt.Errorf("cli: positional <%s> cannot have type %s", opt.name, opt.typ)
+		}
+	default:
+		return nil, fmt.Errorf("cli: %s %s has unknown type %q (expected string, int, float, bool or list)", kind, opt.name, opt.typ)
+	}
+	if opt.help, err = str("help"); err != nil {
+		return nil, err
+	}
+	if opt.metavar, err = str("metavar"); err != nil {
+		return nil, err
+	}
+	if isFlag {
+		if opt.short, err = str("short"); err != nil {
+			return nil, err
+		}
+		if opt.short != "" && (utf8.RuneCountInString(opt.short) != 1 || opt.short == "-" || (opt.short[0] >= '0' && opt.short[0] <= '9')) {
+			return nil, fmt.Errorf("cli: flag --%s short name %q must be a single non-digit character", opt.name, opt.short)
+		}
+		if opt.short == "h" {
+			return nil, fmt.Errorf("cli: flag --%s cannot use -h, which is reserved for help", opt.name)
+		}
+	} else if opt.variadic, err = flag("variadic", false); err != nil {
+		return nil, err
+	}
+	// Positionals are required unless they say otherwise; flags are not.
+	if opt.required, err = flag("required", !isFlag && !opt.variadic); err != nil {
+		return nil, err
+	}
+	if v := dictField(d, "choices"); v != nil {
+		arr, ok := v.(*object.Array)
+		if !ok {
+			return nil, fmt.Errorf("cli: %s %s choices must be ARRAY", kind, opt.name)
+		}
+		for _, c := range arr.Elements {
+			opt.choices = append(opt.choices, c.Inspect())
+		}
+	}
+	opt.def = dictField(d, "default")
+	if opt.def == nil {
+		switch {
+		case opt.typ == "bool":
+			opt.def = &object.Boolean{Value: false}
+		case opt.typ == "list", opt.variadic:
+			opt.def = &object.Array{Elements: []object.Object{}}
+		default:
+			opt.def = &object.Nil{}
+		}
+	}
+	return opt, nil
+}
+
+// dictField returns the value stored under the string key field, or nil.
+func dictField(d *object.Dict, field string) object.Object {
+	hk, _ := object.HashKeyOf(&object.String{Value: field})
+	pair, ok := d.Pairs[hk]
+	if !ok {
+		return nil
+	}
+	if _, isNil := pair.Value.(*object.Nil); isNil {
+		return nil
+	}
+	return pair.Value
+}
+
+func (s *cliSpec) parse(argv []string) (*object.Dict, error) {
+	values := map[string]object.Object{}
+	seen := map[string]bool{}
+	byLong := map[string]*cliOption{}
+	byShort := map[string]*cliOption{}
+	for _, f := range s.flags {
+		byLong[f.name] = f
+		if f.short != "" {
+			byShort[f.short] = f
+		}
+	}
+	help := false
+	pos := 0
+	onlyPositionals := false
+
+	set := func(opt *cliOption, label, raw string) error {
+		val, err := opt.coerce(label, raw)
+		if err != nil {
+			return err
+		}
+		if opt.typ == "list" || opt.variadic {
+			prev, _ := values[opt.key].(*object.Array)
+			if prev == nil {
+				prev = &object.Array{}
+			}
+			prev.Elements = append(prev.Elements, val)
+			val = prev
+		}
+		values[opt.key] = val
+		seen[opt.key] = true
+		return nil
+	}
+
+	for i := 0; i < len(argv); i++ {
+		arg := argv[i]
+		switch {
+		case onlyPositionals || arg == "-" || !strings.HasPrefix(arg, "-") || isNegativeNumber(arg):
+			if pos >= len(s.positionals) {
+				return nil, fmt.Errorf("cli: unexpected argument %q", arg)
+			}
+			opt := s.positionals[pos]
+			if err := set(opt, "<"+opt.name+">", arg); err != nil {
+				return nil, err
+			}
+			if !opt.variadic {
+				pos++
+			}
+		case arg == "--":
+			onlyPositionals = true
+		case strings.HasPrefix(arg, "--"):
+			name, raw, hasValue := strings.Cut(arg[2:], "=")
+			if name == "help" && !hasValue {
+				help = true
+				continue
+			}
+			opt := byLong[name]
+			if opt == nil {
+				return nil, fmt.Errorf("cli: unknown flag --%s", name)
+			}
+			label := "--" + name
+			if opt.typ == "bool" {
+				if !hasValue {
+					raw = "true"
+				}
+			} else if !hasValue {
+				if i+1 >= len(argv) {
+					return nil, fmt.Errorf("cli: flag %s needs a value", label)
+				}
+				i++
+				raw = argv[i]
+			}
+			if err := set(opt, label, raw); err != nil {
+				return nil, err
+			}
+		default:
+			// A cluster of short flags: -v, -vq, -n5 or -n 5.
+			rest := arg[1:]
+			for rest != "" {
+				r, size := utf8.DecodeRuneInString(rest)
+				short := string(r)
+				rest = rest[size:]
+				if short == "h" {
+					help = true
+					continue
+				}
+				opt := byShort[short]
+				if opt == nil {
+					return nil, fmt.Errorf("cli: unknown flag -%s", short)
+				}
+				label := "-" + short
+				if opt.typ == "bool" {
+					if err := set(opt, label, "true"); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				raw := rest
+				if raw == "" {
+					if i+1 >= len(argv) {
+						return nil, fmt.Errorf("cli: flag %s needs a value", label)
+					}
+					i++
+					raw = argv[i]
+				}
+				if err := set(opt, label, raw); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+	}
+
+	if !help {
+		for _, f := range s.flags {
+			if f.required && !seen[f.key] {
+				return nil, fmt.Errorf("cli: missing required flag --%s", f.name)
+			}
+		}
+		for _, p := range s.positionals {
+			if p.required && !seen[p.key] {
+				return nil, fmt.Errorf("cli: missing argument <%s>", p.name)
+			}
+		}
+	}
+
+	out := &object.Dict{Pairs: map[object.HashKey]object.DictPair{}}
+	put := func(key string, val object.Object) {
+		k := &object.String{Value: key}
+		hk, _ := object.HashKeyOf(k)
+		out.Pairs[hk] = object.DictPair{Key: k, Value: val}
+	}
+	for _, opt := range append(append([]*cliOption{}, s.flags...), s.positionals...) {
+		if v, ok := values[opt.key]; ok {
+			put(opt.key, v)
+		} else {
+			put(opt.key, opt.def)
+		}
+	}
+	put("help", &object.Boolean{Value: help})
+	return out, nil
+}
+
+func isNegativeNumber(s string) bool {
+	if len(s) < 2 || s[0] != '-' || s[1] < '0' || s[1] > '9' {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// coerce converts a command-line word to the option's type.
+func (o *cliOption) coerce(label, raw string) (object.Object, error) {
+	if len(o.choices) > 0 {
+		ok := false
+		for _, c := range o.choices {
+			if c == raw {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("cli: %s must be one of %s, got %q", label, strings.Join(o.choices, ", "), raw)
+		}
+	}
+	switch o.typ {
+	case "int":
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cli: %s expects an integer, got %q", label, raw)
+		}
+		return &object.Integer{Value: n}, nil
+	case "float":
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cli: %s expects a number, got %q", label, raw)
+		}
+		return &object.Float{Value: f}, nil
+	case "bool":
+		switch raw {
+		case "true":
+			return &object.Boolean{Value: true}, nil
+		case "false":
+			return &object.Boolean{Value: false}, nil
+		}
+		return nil, fmt.Errorf("cli: %s expects true or false, got %q", label, raw)
+	}
+	return &object.String{Value: raw}, nil
+}
+
+func (o *cliOption) valueName() string {
+	if o.metavar != "" {
+		return o.metavar
+	}
+	switch o.typ {
+	case "int", "float":
+		return o.typ
+	}
+	if len(o.choices) > 0 {
+		return o.name
+	}
+	return "value"
+}
+
+// notes is the help column for the option: its help text followed by its
+// choices, default and whether a flag is required or repeatable.
+func (o *cliOption) notes(isFlag bool) string {
+	var notes []string
+	if len(o.choices) > 0 {
+		notes = append(notes, "one of: "+strings.Join(o.choices, ", "))
+	}
+	switch d := o.def.(type) {
+	case *object.Nil:
+	case *object.Boolean:
+		if d.Value {
+			notes = append(notes, "default: true")
+		}
+	case *object.Array:
+		if len(d.Elements) > 0 {
+			notes = append(notes, "default: "+d.Inspect())
+		}
+	default:
+		notes = append(notes, "default: "+d.Inspect())
+	}
+	if o.typ == "list" {
+		notes = append(notes, "repeatable")
+	}
+	if isFlag && o.required {
+		notes = append(notes, "required")
+	}
+	if len(notes) == 0 {
+		return o.help
+	}
+	text := "(" + strings.Join(notes, "; ") + ")"
+	if o.help == "" {
+		return text
+	}
+	return o.help + " " + text
+}
+
+func (s *cliSpec) helpText() string {
+	var b strings.Builder
+	b.WriteString("usage: " + s.name)
+	if len(s.flags) > 0 {
+		b.WriteString(" [options]")
+	} else {
+		b.WriteString(" [-h]")
+	}
+	for _, p := range s.positionals {
+		switch {
+		case p.variadic && p.required:
+			b.WriteString(" <" + p.name + ">...")
+		case p.variadic:
+			b.WriteString(" [" + p.name + "...]")
+		case p.required:
+			b.WriteString(" <" + p.name + ">")
+		default:
+			b.WriteString(" [" + p.name + "]")
+		}
+	}
+	b.WriteString("\n")
+	if s.about != "" {
+		b.WriteString("\n" + s.about + "\n")
+	}
+
+	type row struct{ left, right string }
+	var args, opts []row
+	for _, p := range s.positionals {
+		args = append(args, row{p.name, p.notes(false)})
+	}
+	opts = append(opts, row{"-h, --help", "show this help"})
+	for _, f := range s.flags {
+		left := "    --" + f.name
+		if f.short != "" {
+			left = "-" + f.short + ", --" + f.name
+		}
+		if f.typ != "bool" {
+			left += " <" + f.valueName() + ">"
+		}
+		opts = append(opts, row{left, f.notes(true)})
+	}
+	width := 0
+	for _, r := range append(append([]row{}, args...), opts...) {
+		if n := utf8.RuneCountInString(r.left); n > width {
+			width = n
+		}
+	}
+	section := func(title string, rows []row) {
+		if len(rows) == 0 {
+			return
+		}
+		b.WriteString("\n" + title + ":\n")
+		for _, r := range rows {
+			line := "  " + r.left
+			if r.right != "" {
+				line += strings.Repeat(" ", width-utf8.RuneCountInString(r.left)+2) + r.right
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	section("arguments", args)
+	section("options", opts)
+	return b.String()
+}
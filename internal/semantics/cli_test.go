@@ -0,0 +1,93 @@
+package semantics
+
+import (
+	"strings"
+	"testing"
+
+	"welle/internal/object"
+)
+
+// cliValue converts a Go literal into the object the spec parser expects.
+func cliValue(v any) object.Object {
+	switch v := v.(type) {
+	case string:
+		return &object.String{Value: v}
+	case int:
+		return &object.Integer{Value: int64(v)}
+	case bool:
+		return &object.Boolean{Value: v}
+	case []any:
+		els := make([]object.Object, len(v))
+		for i, el := range v {
+			els[i] = cliValue(el)
+		}
+		return &object.Array{Elements: els}
+	case map[string]any:
+		d := &object.Dict{Pairs: map[object.HashKey]object.DictPair{}}
+		for key, val := range v {
+			k := &object.String{Value: key}
+			hk, _ := object.HashKeyOf(k)
+			d.Pairs[hk] = object.DictPair{Key: k, Value: cliValue(val)}
+		}
+		return d
+	}
+	panic("cliValue: unsupported type")
+}
+
+func TestCLISpecErrors(t *testing.T) {
+	tests := []struct {
+		spec map[string]any
+		want string
+	}{
+		{map[string]any{"flags": "x"}, "cli: spec flags must be ARRAY"},
+		{map[string]any{"flags": []any{map[string]any{"name": "a b"}}}, `cli: flag name "a b" is not valid`},
+		{map[string]any{"flags": []any{map[string]any{"name": "n", "type": "date"}}}, `unknown type "date"`},
+		{map[string]any{"flags": []any{map[string]any{"name": "x", "short": "h"}}}, "reserved for help"},
+		{map[string]any{"flags": []any{map[string]any{"name": "x", "short": "ab"}}}, "must be a single non-digit character"},
+		{map[string]any{"flags": []any{map[string]any{"name": "a", "short": "x"}, map[string]any{"name": "b", "short": "x"}}}, "short flag -x is used twice"},
+		{map[string]any{"flags": []any{map[string]any{"name": "dry-run"}, map[string]any{"name": "dry_run"}}}, "clashes with"},
+		{map[string]any{"positionals": []any{map[string]any{"name": "rest", "variadic": true}, map[string]any{"name": "last"}}}, "must come last"},
+		{map[string]any{"positionals": []any{map[string]any{"name": "a", "required": false}, map[string]any{"name": "b"}}}, "follows an optional one"},
+		{map[string]any{"positionals": []any{map[string]any{"name": "n", "type": "bool"}}}, "cannot have type bool"},
+	}
+	for _, tt := range tests {
+		_, err := CLIHelp([]object.Object{cliValue(tt.spec)}, "prog")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("spec %v: expected error containing %q, got %v", tt.spec, tt.want, err)
+		}
+	}
+}
+
+func TestCLIParseArgv(t *testing.T) {
+	spec := cliValue(map[string]any{"flags": []any{map[string]any{"name": "out", "short": "o", "required": true}}})
+	argv := cliValue([]any{"-o", "x.txt"})
+
+	got, err := CLIParse([]object.Object{spec, argv}, "prog")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := dictField(got, "out")
+	if s, ok := out.(*object.String); !ok || s.Value != "x.txt" {
+		t.Fatalf("expected out to be x.txt, got %v", out)
+	}
+
+	if _, err := CLIParse([]object.Object{spec, cliValue([]any{})}, "prog"); err == nil || err.Error() != "cli: missing required flag --out" {
+		t.Fatalf("expected missing flag error, got %v", err)
+	}
+	if _, err := CLIParse([]object.Object{spec, cliValue([]any{"-h"})}, "prog"); err != nil {
+		t.Fatalf("-h should skip required checks, got %v", err)
+	}
+	if _, err := CLIParse([]object.Object{spec, cliValue([]any{1})}, "prog"); err == nil || !strings.Contains(err.Error(), "only STRING") {
+		t.Fatalf("expected argv type error, got %v", err)
+	}
+}
+
+func TestCLIHelpUsesProgramName(t *testing.T) {
+	help, err := CLIHelp([]object.Object{cliValue(map[string]any{})}, "tool.wll")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(help, "usage: tool.wll [-h]\n") {
+		t.Fatalf("unexpected help:\n%s", help)
+	}
+}
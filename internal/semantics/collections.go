@@ -0,0 +1,116 @@
+package semantics
+
+import (
+	"fmt"
+
+	"welle/internal/object"
+)
+
+// Chunk implements chunk(array, n): the elements of array in runs of n, the
+// last run holding whatever is left.
+func Chunk(args []object.Object) (*object.Array, error) {
+	els, size, err := arrayAndSize("chunk", args)
+	if err != nil {
+		return nil, err
+	}
+	n := int(min(size, int64(max(len(els), 1))))
+	// One copy backs every chunk. Each is capped at its own length, so
+	// appending to one reallocates instead of writing into the next.
+	buf := make([]object.Object, len(els))
+	copy(buf, els)
+	out := make([]object.Object, 0, (len(buf)+n-1)/n)
+	for lo := 0; lo < len(buf); lo += n {
+		hi := min(lo+n, len(buf))
+		out = append(out, &object.Array{Elements: buf[lo:hi:hi]})
+	}
+	return &object.Array{Elements: out}, nil
+}
+
+// Windows implements windows(array, n): every run of n consecutive elements,
+// in order. An array shorter than n has none.
+func Windows(args []object.Object) (*object.Array, error) {
+	els, size, err := arrayAndSize("windows", args)
+	if err != nil {
+		return nil, err
+	}
+	if size > int64(len(els)) {
+		return &object.Array{Elements: []object.Object{}}, nil
+	}
+	n := int(size)
+	out := make([]object.Object, len(els)-n+1)
+	for i := range out {
+		w := make([]object.Object, n)
+		copy(w, els[i:i+n])
+		out[i] = &object.Array{Elements: w}
+	}
+	return &object.Array{Elements: out}, nil
+}
+
+// Flatten implements flatten(array, depth?): array with nested arrays
+// spliced in, depth levels deep (1 by default).
+func Flatten(args []object.Object) (*object.Array, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, fmt.Errorf("wrong number of arguments: expected 1 or 2, got %d", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, fmt.Errorf("flatten() expects ARRAY")
+	}
+	depth := int64(1)
+	if len(args) == 2 {
+		d, ok := args[1].(*object.Integer)
+		if !ok {
+			return nil, fmt.Errorf("flatten() depth must be INTEGER")
+		}
+		if d.Value < 0 {
+			return nil, fmt.Errorf("flatten() depth must not be negative")
+		}
+		depth = d.Value
+	}
+	out := make([]object.Object, 0, len(arr.Elements))
+	out = flattenInto(out, arr.Elements, depth, map[*object.Array]bool{arr: true})
+	if out == nil {
+		return nil, fmt.Errorf("flatten() array contains itself")
+	}
+	return &object.Array{Elements: out}, nil
+}
+
+// flattenInto appends els to out, splicing arrays depth levels down. active
+// holds the arrays being spliced; meeting one again returns nil.
+func flattenInto(out, els []object.Object, depth int64, active map[*object.Array]bool) []object.Object {
+	for _, el := range els {
+		inner, ok := el.(*object.Array)
+		if !ok || depth == 0 {
+			out = append(out, el)
+			continue
+		}
+		if active[inner] {
+			return nil
+		}
+		active[inner] = true
+		if out = flattenInto(out, inner.Elements, depth-1, active); out == nil {
+			return nil
+		}
+		delete(active, inner)
+	}
+	return out
+}
+
+// arrayAndSize checks the (array, n) arguments of chunk and windows.
+func arrayAndSize(name string, args []object.Object) ([]object.Object, int64, error) {
+	if len(args) != 2 {
+		return nil, 0, fmt.Errorf("wrong number of arguments: expected 2, got %d", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, 0, fmt.Errorf("%s() expects ARRAY", name)
+	}
+	n, ok := args[1].(*object.Integer)
+	if !ok {
+		return nil, 0, fmt.Errorf("%s() size must be INTEGER", name)
+	}
+	if n.Value <= 0 {
+		return nil, 0, fmt.Errorf("%s() size must be positive", name)
+	}
+	return arr.Elements, n.Value, nil
+}
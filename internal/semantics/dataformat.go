@@ -0,0 +1,131 @@
+package semantics
+
+import (
+	"fmt"
+
+	"welle/internal/dataformat"
+	"welle/internal/object"
+	"welle/internal/template"
+)
+
+// TOMLParse implements toml_parse(text), the decoder behind std:toml.
+func TOMLParse(args []object.Object) (*object.Dict, error) {
+	text, err := textArg("toml_parse", args)
+	if err != nil {
+		return nil, err
+	}
+	return dataformat.ParseTOML(text)
+}
+
+// TOMLStringify implements toml_stringify(dict).
+func TOMLStringify(args []object.Object) (string, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return "", err
+	}
+	d, ok := args[0].(*object.Dict)
+	if !ok {
+		return "", fmt.Errorf("toml_stringify() expects DICT, got %s", args[0].Type())
+	}
+	return dataformat.StringifyTOML(d)
+}
+
+// YAMLParse implements yaml_parse(text), the reader behind std:yaml.
+func YAMLParse(args []object.Object) (object.Object, error) {
+	text, err := textArg("yaml_parse", args)
+	if err != nil {
+		return nil, err
+	}
+	return dataformat.ParseYAML(text)
+}
+
+// INIParse implements ini_parse(text), the reader behind std:ini.
+func INIParse(args []object.Object) (*object.Dict, error) {
+	text, err := textArg("ini_parse", args)
+	if err != nil {
+		return nil, err
+	}
+	return dataformat.ParseINI(text)
+}
+
+// EncodingEncode implements encoding_encode(text, kind), behind the
+// encoders of std:encoding.
+func EncodingEncode(args []object.Object) (string, error) {
+	text, kind, err := textAndKind("encoding_encode", args)
+	if err != nil {
+		return "", err
+	}
+	return dataformat.Encode(text, kind)
+}
+
+// EncodingDecode implements encoding_decode(text, kind).
+func EncodingDecode(args []object.Object) (string, error) {
+	text, kind, err := textAndKind("encoding_decode", args)
+	if err != nil {
+		return "", err
+	}
+	return dataformat.Decode(text, kind)
+}
+
+// QueryDecode implements query_decode(text), behind std:encoding.
+func QueryDecode(args []object.Object) (*object.Dict, error) {
+	text, err := textArg("query_decode", args)
+	if err != nil {
+		return nil, err
+	}
+	return dataformat.ParseQuery(text)
+}
+
+// QueryEncode implements query_encode(dict).
+func QueryEncode(args []object.Object) (string, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return "", err
+	}
+	d, ok := args[0].(*object.Dict)
+	if !ok {
+		return "", fmt.Errorf("query_encode() expects DICT, got %s", args[0].Type())
+	}
+	return dataformat.StringifyQuery(d)
+}
+
+func textAndKind(name string, args []object.Object) (string, string, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return "", "", err
+	}
+	text, ok := args[0].(*object.String)
+	if !ok {
+		return "", "", fmt.Errorf("%s() expects STRING, got %s", name, args[0].Type())
+	}
+	kind, ok := args[1].(*object.String)
+	if !ok {
+		return "", "", fmt.Errorf("%s() encoding must be STRING, got %s", name, args[1].Type())
+	}
+	return text.Value, kind.Value, nil
+}
+
+func textArg(name string, args []object.Object) (string, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return "", err
+	}
+	s, ok := args[0].(*object.String)
+	if !ok {
+		return "", fmt.Errorf("%s() expects STRING, got %s", name, args[0].Type())
+	}
+	return s.Value, nil
+}
+
+// TemplateRender implements template_render(text, data, mode), the renderer
+// behind std:template.
+func TemplateRender(args []object.Object) (string, error) {
+	if err := checkArgs(args, 3, 3); err != nil {
+		return "", err
+	}
+	text, ok := args[0].(*object.String)
+	if !ok {
+		return "", fmt.Errorf("template_render() expects STRING, got %s", args[0].Type())
+	}
+	mode, ok := args[2].(*object.String)
+	if !ok {
+		return "", fmt.Errorf("template_render() mode must be STRING, got %s", args[2].Type())
+	}
+	return template.Render(text.Value, args[1], mode.Value)
+}
@@ -0,0 +1,68 @@
+package semantics_test
+
+import (
+	"testing"
+
+	"welle/internal/compiler"
+	"welle/internal/evaluator"
+	"welle/internal/lexer"
+	"welle/internal/object"
+	"welle/internal/parser"
+	"welle/internal/vm"
+)
+
+// dictBenchPrograms are dict-heavy programs shaped like the spec cases:
+// counting words, records read and written by field, and integer keys.
+var dictBenchPrograms = map[string]string{
+	"word_count": `words = ["the", "quick", "brown", "fox", "jumps", "over", "the", "lazy", "dog", "the", "end"]
+counts = #{}
+for (i in range(500)) {
+  for (w in words) {
+    counts[w] = counts.get(w, 0) + 1
+  }
+}
+export out = counts["the"]
+`,
+	"records": `total = 0
+for (i in range(2000)) {
+  rec = #{"name": "p", "x": i, "y": 2 * i}
+  rec.x += rec.y
+  rec["z"] = rec.x + rec["y"]
+  if ("z" in rec) { total += rec.z }
+}
+export out = total
+`,
+	"int_keys": `squares = #{}
+for (i in range(2000)) { squares[i] = i * i }
+total = 0
+for (i in range(2000)) { total += squares[i] }
+export out = total
+`,
+}
+
+func BenchmarkDictPrograms(b *testing.B) {
+	for name, src := range dictBenchPrograms {
+		program := parser.New(lexer.New(src)).ParseProgram()
+		b.Run(name+"/interpreter", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if res := evaluator.Eval(program, object.NewEnvironment()); res != nil && res.Type() == object.ERROR_OBJ {
+					b.Fatal(res.Inspect())
+				}
+			}
+		})
+		b.Run(name+"/vm", func(b *testing.B) {
+			c := compiler.New()
+			if err := c.Compile(program); err != nil {
+				b.Fatal(err)
+			}
+			bc := c.Bytecode()
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := vm.New(bc).Run(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
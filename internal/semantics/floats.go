@@ -0,0 +1,144 @@
+package semantics
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"welle/internal/object"
+)
+
+// Round implements round(x, n?). Without n, x is rounded half away from zero
+// to an INTEGER; with n, a FLOAT x is rounded to n decimal places (n may be
+// negative) and stays a FLOAT, while an INTEGER x is rounded to a multiple of
+// 10^-n and stays an INTEGER.
+func Round(args []object.Object) (object.Object, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, fmt.Errorf("wrong number of arguments: expected 1 or 2, got %d", len(args))
+	}
+	if !isNumeric(args[0]) {
+		return nil, fmt.Errorf("round() expects NUMBER")
+	}
+	if len(args) == 1 {
+		if i, ok := args[0].(*object.Integer); ok {
+			return i, nil
+		}
+		return floatToInt("round", math.Round(toFloat(args[0])))
+	}
+	nObj, ok := args[1].(*object.Integer)
+	if !ok {
+		return nil, fmt.Errorf("round() expects INTEGER digits")
+	}
+	n := nObj.Value
+	if i, ok := args[0].(*object.Integer); ok {
+		if n >= 0 {
+			return i, nil
+		}
+		if n < -18 {
+			return &object.Integer{Value: 0}, nil
+		}
+		scale := int64(math.Pow10(int(-n)))
+		q := i.Value / scale
+		if r := i.Value % scale; r >= scale/2 {
+			q++
+		} else if r <= -scale/2 && r != 0 {
+			q--
+		}
+		if q > math.MaxInt64/scale || q < math.MinInt64/scale {
+			return nil, fmt.Errorf("round(): result is out of INTEGER range")
+		}
+		return &object.Integer{Value: q * scale}, nil
+	}
+	x := toFloat(args[0])
+	if math.IsNaN(x) || math.IsInf(x, 0) || n > 308 {
+		return &object.Float{Value: x}, nil
+	}
+	if n < -308 {
+		return &object.Float{Value: math.Copysign(0, x)}, nil
+	}
+	if n >= 0 {
+		return &object.Float{Value: roundPlaces(x, int(n))}, nil
+	}
+	scale := math.Pow10(int(-n))
+	return &object.Float{Value: math.Round(x/scale) * scale}, nil
+}
+
+// IntegralPart implements floor, ceil and trunc: op is applied to the numeric
+// argument and the result is returned as an INTEGER. NaN, infinities and
+// values outside the INTEGER range are errors.
+func IntegralPart(name string, op func(float64) float64, args []object.Object) (object.Object, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments: expected 1, got %d", len(args))
+	}
+	switch v := args[0].(type) {
+	case *object.Integer:
+		return v, nil
+	case *object.Float:
+		return floatToInt(name, op(v.Value))
+	}
+	return nil, fmt.Errorf("%s() expects NUMBER", name)
+}
+
+func floatToInt(name string, f float64) (object.Object, error) {
+	// float64(math.MaxInt64) rounds up to 2^63, which is out of range.
+	if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return nil, fmt.Errorf("%s(): %s is out of INTEGER range", name, object.FormatFloat(f))
+	}
+	return &object.Integer{Value: int64(f)}, nil
+}
+
+// FloatClass implements is_nan and is_inf: pred is applied to the numeric
+// argument. INTEGER arguments are never NaN or infinite.
+func FloatClass(name string, pred func(float64) bool, args []object.Object) (bool, error) {
+	if len(args) != 1 {
+		return false, fmt.Errorf("wrong number of arguments: expected 1, got %d", len(args))
+	}
+	switch v := args[0].(type) {
+	case *object.Integer:
+		return false, nil
+	case *object.Float:
+		return pred(v.Value), nil
+	}
+	return false, fmt.Errorf("%s() expects NUMBER", name)
+}
+
+// ApproxEqual implements approx_eq(a, b, eps): a and b are equal when they
+// differ by at most eps. Equal infinities compare equal; NaN equals nothing.
+func ApproxEqual(args []object.Object) (bool, error) {
+	if len(args) != 3 {
+		return false, fmt.Errorf("wrong number of arguments: expected 3, got %d", len(args))
+	}
+	for _, arg := range args {
+		if !isNumeric(arg) {
+			return false, fmt.Errorf("approx_eq() expects NUMBER arguments")
+		}
+	}
+	a, b, eps := toFloat(args[0]), toFloat(args[1]), toFloat(args[2])
+	if eps < 0 || math.IsNaN(eps) {
+		return false, fmt.Errorf("approx_eq() expects a non-negative eps")
+	}
+	if a == b {
+		return true, nil
+	}
+	return math.Abs(a-b) <= eps, nil
+}
+
+// roundPlaces rounds x half away from zero to n >= 0 decimal places. It
+// works on the exact binary value of x, so round(2.675, 2) is 2.67 because
+// 2.675 is stored just below it; scaling in float64 first would round
+// 267.49999... up to 267.5 on the way.
+func roundPlaces(x float64, n int) float64 {
+	const prec = 1200 // holds x * 10^308 exactly
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+	v := new(big.Float).SetPrec(prec).SetFloat64(x)
+	v.Mul(v, new(big.Float).SetPrec(prec).SetInt(pow))
+	q, _ := v.Int(nil)
+	frac := v.Sub(v, new(big.Float).SetPrec(prec).SetInt(q))
+	if frac.Cmp(big.NewFloat(0.5)) >= 0 {
+		q.Add(q, big.NewInt(1))
+	} else if frac.Cmp(big.NewFloat(-0.5)) <= 0 {
+		q.Sub(q, big.NewInt(1))
+	}
+	r, _ := new(big.Rat).SetFrac(q, pow).Float64()
+	return math.Copysign(r, x)
+}
@@ -0,0 +1,339 @@
+package semantics
+
+import (
+	"fmt"
+	"math"
+
+	"welle/internal/object"
+)
+
+// std:geom shapes are plain values: a rect is #{"x", "y", "w", "h"} with
+// (x, y) its top-left corner, a circle is #{"x", "y", "r"} around its
+// center, a point is [x, y] and a polygon is an ARRAY of points, all in
+// the gfx convention of y growing downward.
+
+type vec struct{ x, y float64 }
+
+type geomShape struct {
+	circle     bool
+	x, y, w, h float64
+	r          float64
+}
+
+func geomNumber(name, what string, obj object.Object) (float64, error) {
+	if obj == nil || !isNumeric(obj) {
+		return 0, fmt.Errorf("%s() expects NUMBER %s", name, what)
+	}
+	f := toFloat(obj)
+	if math.IsNaN(f) {
+		return 0, fmt.Errorf("%s() expects NUMBER %s, got NaN", name, what)
+	}
+	return f, nil
+}
+
+func geomPoint(name string, obj object.Object) (vec, error) {
+	arr, ok := obj.(*object.Array)
+	if !ok || len(arr.Elements) != 2 {
+		return vec{}, fmt.Errorf("%s() expects point [x, y], got %s", name, obj.Inspect())
+	}
+	x, err := geomNumber(name, "point coordinates", arr.Elements[0])
+	if err != nil {
+		return vec{}, err
+	}
+	y, err := geomNumber(name, "point coordinates", arr.Elements[1])
+	if err != nil {
+		return vec{}, err
+	}
+	return vec{x, y}, nil
+}
+
+func geomPolygon(name string, arr *object.Array) ([]vec, error) {
+	if len(arr.Elements) < 3 {
+		return nil, fmt.Errorf("%s() expects a polygon of at least 3 points", name)
+	}
+	pts := make([]vec, len(arr.Elements))
+	for i, el := range arr.Elements {
+		p, err := geomPoint(name, el)
+		if err != nil {
+			return nil, err
+		}
+		pts[i] = p
+	}
+	return pts, nil
+}
+
+// geomShapeArg reads a rect or circle DICT; a "r" field makes it a circle.
+func geomShapeArg(name string, obj object.Object) (geomShape, error) {
+	d, ok := obj.(*object.Dict)
+	if !ok {
+		return geomShape{}, fmt.Errorf("%s() expects rect #{x, y, w, h} or circle #{x, y, r}, got %s", name, obj.Type())
+	}
+	var s geomShape
+	var err error
+	if s.x, err = geomNumber(name, "x", dictField(d, "x")); err != nil {
+		return s, err
+	}
+	if s.y, err = geomNumber(name, "y", dictField(d, "y")); err != nil {
+		return s, err
+	}
+	if r := dictField(d, "r"); r != nil {
+		s.circle = true
+		if s.r, err = geomNumber(name, "radius r", r); err != nil {
+			return s, err
+		}
+		if s.r < 0 {
+			return s, fmt.Errorf("%s() circle radius must not be negative", name)
+		}
+		return s, nil
+	}
+	if s.w, err = geomNumber(name, "width w", dictField(d, "w")); err != nil {
+		return s, err
+	}
+	if s.h, err = geomNumber(name, "height h", dictField(d, "h")); err != nil {
+		return s, err
+	}
+	if s.w < 0 || s.h < 0 {
+		return s, fmt.Errorf("%s() rect size must not be negative", name)
+	}
+	return s, nil
+}
+
+func geomRectArg(name string, obj object.Object) (geomShape, error) {
+	s, err := geomShapeArg(name, obj)
+	if err == nil && s.circle {
+		err = fmt.Errorf("%s() expects a rect #{x, y, w, h}, got a circle", name)
+	}
+	return s, err
+}
+
+func rectDict(x, y, w, h float64) *object.Dict {
+	d := &object.Dict{Pairs: map[object.HashKey]object.DictPair{}}
+	setDictField(d, "x", &object.Float{Value: x})
+	setDictField(d, "y", &object.Float{Value: y})
+	setDictField(d, "w", &object.Float{Value: w})
+	setDictField(d, "h", &object.Float{Value: h})
+	return d
+}
+
+func pointArray(p vec) *object.Array {
+	return &object.Array{Elements: []object.Object{&object.Float{Value: p.x}, &object.Float{Value: p.y}}}
+}
+
+// rectCircle reports whether a rect and a circle overlap by clamping the
+// circle's center into the rect.
+func rectCircle(r, c geomShape) bool {
+	nx := math.Max(r.x, math.Min(c.x, r.x+r.w))
+	ny := math.Max(r.y, math.Min(c.y, r.y+r.h))
+	dx, dy := c.x-nx, c.y-ny
+	return dx*dx+dy*dy < c.r*c.r
+}
+
+// GeomOverlaps implements geom_overlaps(a, b) for any mix of rects and
+// circles. Shapes that only touch along an edge do not overlap.
+func GeomOverlaps(args []object.Object) (object.Object, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	a, err := geomShapeArg("geom_overlaps", args[0])
+	if err != nil {
+		return nil, err
+	}
+	b, err := geomShapeArg("geom_overlaps", args[1])
+	if err != nil {
+		return nil, err
+	}
+	var hit bool
+	switch {
+	case a.circle && b.circle:
+		dx, dy, rr := a.x-b.x, a.y-b.y, a.r+b.r
+		hit = dx*dx+dy*dy < rr*rr
+	case a.circle:
+		hit = rectCircle(b, a)
+	case b.circle:
+		hit = rectCircle(a, b)
+	default:
+		hit = a.x < b.x+b.w && b.x < a.x+a.w && a.y < b.y+b.h && b.y < a.y+a.h
+	}
+	return &object.Boolean{Value: hit}, nil
+}
+
+// GeomIntersection implements geom_intersection(a, b): the rect where two
+// rects overlap, or nil.
+func GeomIntersection(args []object.Object) (object.Object, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	a, err := geomRectArg("geom_intersection", args[0])
+	if err != nil {
+		return nil, err
+	}
+	b, err := geomRectArg("geom_intersection", args[1])
+	if err != nil {
+		return nil, err
+	}
+	x0, y0 := math.Max(a.x, b.x), math.Max(a.y, b.y)
+	x1, y1 := math.Min(a.x+a.w, b.x+b.w), math.Min(a.y+a.h, b.y+b.h)
+	if x1 <= x0 || y1 <= y0 {
+		return &object.Nil{}, nil
+	}
+	return rectDict(x0, y0, x1-x0, y1-y0), nil
+}
+
+// GeomContains implements geom_contains(shape, point) for rects, circles
+// and polygons. Rects include their top and left edges but not their
+// bottom and right ones, so tiled rects never share a point; polygons use
+// the even-odd rule.
+func GeomContains(args []object.Object) (object.Object, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	p, err := geomPoint("geom_contains", args[1])
+	if err != nil {
+		return nil, err
+	}
+	if arr, ok := args[0].(*object.Array); ok {
+		poly, err := geomPolygon("geom_contains", arr)
+		if err != nil {
+			return nil, err
+		}
+		return &object.Boolean{Value: polygonContains(poly, p)}, nil
+	}
+	s, err := geomShapeArg("geom_contains", args[0])
+	if err != nil {
+		return nil, err
+	}
+	var in bool
+	if s.circle {
+		dx, dy := p.x-s.x, p.y-s.y
+		in = dx*dx+dy*dy <= s.r*s.r
+	} else {
+		in = p.x >= s.x && p.x < s.x+s.w && p.y >= s.y && p.y < s.y+s.h
+	}
+	return &object.Boolean{Value: in}, nil
+}
+
+func polygonContains(poly []vec, p vec) bool {
+	in := false
+	j := len(poly) - 1
+	for i := range poly {
+		a, b := poly[i], poly[j]
+		if (a.y > p.y) != (b.y > p.y) && p.x < (b.x-a.x)*(p.y-a.y)/(b.y-a.y)+a.x {
+			in = !in
+		}
+		j = i
+	}
+	return in
+}
+
+// GeomSegmentHit implements geom_segment_hit(a0, a1, b0, b1): the point
+// where segment a0-a1 crosses segment b0-b1, or nil. Parallel segments,
+// including overlapping collinear ones, report no single crossing.
+func GeomSegmentHit(args []object.Object) (object.Object, error) {
+	if err := checkArgs(args, 4, 4); err != nil {
+		return nil, err
+	}
+	var pts [4]vec
+	for i := range pts {
+		p, err := geomPoint("geom_segment_hit", args[i])
+		if err != nil {
+			return nil, err
+		}
+		pts[i] = p
+	}
+	p, ok := segmentHit(pts[0], pts[1], pts[2], pts[3])
+	if !ok {
+		return &object.Nil{}, nil
+	}
+	return pointArray(p), nil
+}
+
+func segmentHit(a0, a1, b0, b1 vec) (vec, bool) {
+	r := vec{a1.x - a0.x, a1.y - a0.y}
+	s := vec{b1.x - b0.x, b1.y - b0.y}
+	den := r.x*s.y - r.y*s.x
+	if den == 0 {
+		return vec{}, false
+	}
+	qp := vec{b0.x - a0.x, b0.y - a0.y}
+	t := (qp.x*s.y - qp.y*s.x) / den
+	u := (qp.x*r.y - qp.y*r.x) / den
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return vec{}, false
+	}
+	return vec{a0.x + t*r.x, a0.y + t*r.y}, true
+}
+
+// GeomSweep implements geom_sweep(rect, dx, dy, obstacle): moving rect by
+// (dx, dy), the first moment it touches obstacle. The result is nil for a
+// miss, or #{"t", "x", "y", "nx", "ny"} where t in 0..1 is the fraction of
+// the move made before contact, (x, y) is rect's position then and
+// (nx, ny) is the obstacle's surface normal at the contact. Rects that
+// already overlap hit at t = 0 with a zero normal.
+func GeomSweep(args []object.Object) (object.Object, error) {
+	if err := checkArgs(args, 4, 4); err != nil {
+		return nil, err
+	}
+	a, err := geomRectArg("geom_sweep", args[0])
+	if err != nil {
+		return nil, err
+	}
+	dx, err := geomNumber("geom_sweep", "dx", args[1])
+	if err != nil {
+		return nil, err
+	}
+	dy, err := geomNumber("geom_sweep", "dy", args[2])
+	if err != nil {
+		return nil, err
+	}
+	b, err := geomRectArg("geom_sweep", args[3])
+	if err != nil {
+		return nil, err
+	}
+	t, n, ok := sweepAABB(a, b, dx, dy)
+	if !ok {
+		return &object.Nil{}, nil
+	}
+	d := &object.Dict{Pairs: map[object.HashKey]object.DictPair{}}
+	for _, f := range []struct {
+		key string
+		val float64
+	}{{"t", t}, {"x", a.x + dx*t}, {"y", a.y + dy*t}, {"nx", n.x}, {"ny", n.y}} {
+		setDictField(d, f.key, &object.Float{Value: f.val})
+	}
+	return d, nil
+}
+
+// sweepAABB is the slab test for a moving box against a still one: each
+// axis gives the time span during which the boxes overlap on it, and the
+// boxes collide where the spans of both axes overlap.
+func sweepAABB(a, b geomShape, dx, dy float64) (float64, vec, bool) {
+	if a.x < b.x+b.w && b.x < a.x+a.w && a.y < b.y+b.h && b.y < a.y+a.h {
+		return 0, vec{}, true
+	}
+	axis := func(pos, size, d, lo, hi float64) (enter, exit float64) {
+		if d == 0 {
+			if pos < hi && lo < pos+size {
+				return math.Inf(-1), math.Inf(1)
+			}
+			return math.Inf(1), math.Inf(-1)
+		}
+		t0, t1 := (lo-(pos+size))/d, (hi-pos)/d
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		return t0, t1
+	}
+	xEnter, xExit := axis(a.x, a.w, dx, b.x, b.x+b.w)
+	yEnter, yExit := axis(a.y, a.h, dy, b.y, b.y+b.h)
+	enter, exit := math.Max(xEnter, yEnter), math.Min(xExit, yExit)
+	if enter >= exit || enter < 0 || enter > 1 {
+		return 0, vec{}, false
+	}
+	var n vec
+	if xEnter > yEnter {
+		n.x = -math.Copysign(1, dx)
+	} else {
+		n.y = -math.Copysign(1, dy)
+	}
+	return enter, n, true
+}
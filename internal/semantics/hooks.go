@@ -0,0 +1,22 @@
+package semantics
+
+import "welle/internal/object"
+
+// Names of the lifecycle hooks an imported module may export. InitHook runs
+// once the module's top level has run; DeinitHook runs when the program
+// shuts down, after the entry file's defers, in the reverse of the order
+// modules finished loading, so a module is torn down before the modules it
+// imported.
+const (
+	InitHook   = "__init"
+	DeinitHook = "__deinit"
+)
+
+// ModuleHook returns the value mod exports as name, or nil.
+func ModuleHook(mod *object.Dict, name string) object.Object {
+	pair, ok := mod.Pairs[object.StringKey(name)]
+	if !ok {
+		return nil
+	}
+	return pair.Value
+}
@@ -0,0 +1,194 @@
+package semantics
+
+import (
+	"fmt"
+
+	"welle/internal/httpserver"
+	"welle/internal/object"
+)
+
+// HTTPListen implements http_listen(addr, timeout_ms, max_body, max_steps,
+// max_mem) -> #{"handle", "addr"}. The rest set each request's budget; 0
+// turns a limit off.
+func HTTPListen(args []object.Object) (object.Object, error) {
+	if err := checkArgs(args, 5, 5); err != nil {
+		return nil, err
+	}
+	addr, ok := args[0].(*object.String)
+	if !ok {
+		return nil, fmt.Errorf("http_listen() expects STRING address, got %s", args[0].Type())
+	}
+	timeout, err := millis("http_listen", args[1])
+	if err != nil {
+		return nil, err
+	}
+	maxBody, ok := args[2].(*object.Integer)
+	if !ok {
+		return nil, fmt.Errorf("http_listen() expects INTEGER max_body, got %s", args[2].Type())
+	}
+	maxSteps, ok := args[3].(*object.Integer)
+	if !ok {
+		return nil, fmt.Errorf("http_listen() expects INTEGER max_steps, got %s", args[3].Type())
+	}
+	maxMem, ok := args[4].(*object.Integer)
+	if !ok {
+		return nil, fmt.Errorf("http_listen() expects INTEGER max_mem, got %s", args[4].Type())
+	}
+	budget := httpserver.Budget{Timeout: timeout, MaxBody: maxBody.Value, MaxSteps: maxSteps.Value, MaxMem: maxMem.Value}
+	id, bound, err := httpserver.Listen(addr.Value, budget)
+	if err != nil {
+		return nil, err
+	}
+	return socketDict(id, map[string]string{"addr": bound}), nil
+}
+
+// HTTPNext implements http_next(server, wait_ms): the next request as a
+// dict, or nil when wait_ms passes first (0 waits forever).
+func HTTPNext(args []object.Object) (object.Object, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	id, err := handleArg("http_next", "server handle", args[0])
+	if err != nil {
+		return nil, err
+	}
+	wait, err := millis("http_next", args[1])
+	if err != nil {
+		return nil, err
+	}
+	req, err := httpserver.Next(id, wait)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil {
+		return &object.Nil{}, nil
+	}
+	d := stringDict(map[string]string{
+		"method": req.Method,
+		"path":   req.Path,
+		"body":   req.Body,
+		"remote": req.Remote,
+	})
+	setDictField(d, "id", &object.Integer{Value: req.ID})
+	setDictField(d, "query", stringDict(req.Query))
+	setDictField(d, "headers", stringDict(req.Headers))
+	setDictField(d, "websocket", &object.Boolean{Value: req.WebSocket})
+	return d, nil
+}
+
+// HTTPRespond implements http_respond(req_id, status, headers, body). It
+// returns false when the request was already answered or ran out of time.
+func HTTPRespond(args []object.Object) (object.Object, error) {
+	if err := checkArgs(args, 4, 4); err != nil {
+		return nil, err
+	}
+	id, err := handleArg("http_respond", "request id", args[0])
+	if err != nil {
+		return nil, err
+	}
+	status, ok := args[1].(*object.Integer)
+	if !ok {
+		return nil, fmt.Errorf("http_respond() expects INTEGER status, got %s", args[1].Type())
+	}
+	hdrs, ok := args[2].(*object.Dict)
+	if !ok {
+		return nil, fmt.Errorf("http_respond() expects DICT headers, got %s", args[2].Type())
+	}
+	headers := map[string]string{}
+	for _, pair := range hdrs.Pairs {
+		k, kok := pair.Key.(*object.String)
+		v, vok := pair.Value.(*object.String)
+		if !kok || !vok {
+			return nil, fmt.Errorf("http_respond() headers must map STRING to STRING")
+		}
+		headers[k.Value] = v.Value
+	}
+	body, ok := args[3].(*object.String)
+	if !ok {
+		return nil, fmt.Errorf("http_respond() expects STRING body, got %s", args[3].Type())
+	}
+	sent, err := httpserver.Respond(id, int(status.Value), headers, body.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &object.Boolean{Value: sent}, nil
+}
+
+// HTTPClose implements http_close(server).
+func HTTPClose(args []object.Object) error {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return err
+	}
+	id, err := handleArg("http_close", "server handle", args[0])
+	if err != nil {
+		return err
+	}
+	return httpserver.Close(id)
+}
+
+// WSAccept implements ws_accept(req_id), which upgrades a websocket
+// request and returns the socket handle.
+func WSAccept(args []object.Object) (object.Object, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	id, err := handleArg("ws_accept", "request id", args[0])
+	if err != nil {
+		return nil, err
+	}
+	ws, err := httpserver.Upgrade(id)
+	if err != nil {
+		return nil, err
+	}
+	return &object.Integer{Value: ws}, nil
+}
+
+// WSSend implements ws_send(ws, msg).
+func WSSend(args []object.Object) error {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return err
+	}
+	id, err := handleArg("ws_send", "websocket handle", args[0])
+	if err != nil {
+		return err
+	}
+	msg, ok := args[1].(*object.String)
+	if !ok {
+		return fmt.Errorf("ws_send() expects STRING message, got %s", args[1].Type())
+	}
+	return httpserver.WSSend(id, msg.Value)
+}
+
+// WSRecv implements ws_recv(ws): the next message, or nil once the peer
+// has closed the socket.
+func WSRecv(args []object.Object) (object.Object, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	id, err := handleArg("ws_recv", "websocket handle", args[0])
+	if err != nil {
+		return nil, err
+	}
+	msg, closed, err := httpserver.WSRecv(id)
+	return streamResult(msg, closed, err)
+}
+
+// WSClose implements ws_close(ws).
+func WSClose(args []object.Object) error {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return err
+	}
+	id, err := handleArg("ws_close", "websocket handle", args[0])
+	if err != nil {
+		return err
+	}
+	return httpserver.WSClose(id)
+}
+
+func handleArg(name, what string, obj object.Object) (int64, error) {
+	h, ok := obj.(*object.Integer)
+	if !ok {
+		return 0, fmt.Errorf("%s() expects INTEGER %s, got %s", name, what, obj.Type())
+	}
+	return h.Value, nil
+}
@@ -0,0 +1,70 @@
+package semantics
+
+import "welle/internal/object"
+
+// Shapes a match pattern checks before looking inside a value, the kind
+// operand of OpMatchShape.
+const (
+	ShapeTuple    = iota // a tuple of exactly n elements
+	ShapeArray           // an array of exactly n elements
+	ShapeArrayMin        // an array of at least n elements, for a pattern with ...rest
+	ShapeDict            // a dict
+)
+
+// MatchShape reports whether val has the given shape.
+func MatchShape(val object.Object, shape, n int) bool {
+	switch shape {
+	case ShapeTuple:
+		t, ok := val.(*object.Tuple)
+		return ok && len(t.Elements) == n
+	case ShapeArray:
+		a, ok := val.(*object.Array)
+		return ok && len(a.Elements) == n
+	case ShapeArrayMin:
+		a, ok := val.(*object.Array)
+		return ok && len(a.Elements) >= n
+	case ShapeDict:
+		_, ok := val.(*object.Dict)
+		return ok
+	}
+	return false
+}
+
+// MatchEqual reports whether the part val of a matched value equals the
+// pattern's want. Unlike ==, values that cannot be compared, such as 1 and
+// "1", simply do not match.
+func MatchEqual(val, want object.Object) bool {
+	eq, err := Compare("==", val, want)
+	return err == nil && eq
+}
+
+// CatchMatches reports whether a `catch (e: filter)` clause handles err:
+// when err is an error whose code equals filter, or one of filter's
+// elements when it is an array, tuple or set. Other thrown values have no
+// code and are never handled by a filtered catch.
+func CatchMatches(err, filter object.Object) bool {
+	errObj, ok := err.(*object.Error)
+	if !ok {
+		return false
+	}
+	code := errObj.CodeValue()
+	var codes []object.Object
+	switch f := filter.(type) {
+	case *object.Array:
+		codes = f.Elements
+	case *object.Tuple:
+		codes = f.Elements
+	case *object.Set:
+		hk, ok := object.HashKeyOf(code)
+		_, in := f.Items[hk]
+		return ok && in
+	default:
+		return MatchEqual(code, filter)
+	}
+	for _, want := range codes {
+		if MatchEqual(code, want) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,488 @@
+package semantics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"welle/internal/object"
+	"welle/internal/unitext"
+)
+
+// method is one entry of the receiver-method table. cost reports the memory
+// a successful call allocated so the backend can charge it; it is nil for
+// methods that return existing values or scalars.
+type method struct {
+	call func(recv object.Object, args []object.Object) (object.Object, error)
+	cost func(res object.Object) int64
+}
+
+var methods = map[object.Type]map[string]method{
+	object.ARRAY_OBJ: {
+		"append": {methodAppend, object.CostOf},
+		"count":  {methodArrayCount, nil},
+		"insert": {methodArrayInsert, costInsert},
+		"len":    {methodLen, nil},
+		"pop":    {methodArrayPop, nil},
+		"remove": {methodArrayRemove, nil},
+	},
+	object.DICT_OBJ: {
+		"count":  {methodDictCount, nil},
+		"get":    {methodDictGet, nil},
+		"keys":   {methodKeys, object.CostOf},
+		"pop":    {methodDictPop, nil},
+		"remove": {methodDictRemove, nil},
+		"values": {methodValues, object.CostOf},
+		"hasKey": {methodHasKey, nil},
+	},
+	object.STRING_OBJ: {
+		"len":        {methodLen, nil},
+		"strip":      {methodStrip, object.CostOf},
+		"uppercase":  {methodUppercase, object.CostOf},
+		"lowercase":  {methodLowercase, object.CostOf},
+		"capitalize": {methodCapitalize, object.CostOf},
+		"startswith": {methodStartsWith, nil},
+		"endswith":   {methodEndsWith, nil},
+		"slice":      {methodSlice, object.CostOf},
+		"casefold":   {methodCasefold, object.CostOf},
+		"graphemes":  {methodGraphemes, costGraphemes},
+	},
+	object.SET_OBJ: {
+		"add":        {methodSetAdd, costSetAdd},
+		"difference": {methodSetDifference, object.CostOf},
+		"has":        {methodSetHas, nil},
+		"intersect":  {methodSetIntersect, object.CostOf},
+		"len":        {methodLen, nil},
+		"remove":     {methodSetRemove, nil},
+		"to_list":    {methodSetToList, object.CostOf},
+		"union":      {methodSetUnion, object.CostOf},
+	},
+	object.SEQ_OBJ: {
+		"filter":  {methodSeqFilter, object.CostOf},
+		"map":     {methodSeqMap, object.CostOf},
+		"take":    {methodSeqTake, object.CostOf},
+		"to_list": {methodSeqToList, nil},
+	},
+	object.RANGE_OBJ: {
+		"len":     {methodLen, nil},
+		"to_list": {methodRangeToList, object.CostOf},
+	},
+	object.STOPWATCH_OBJ: {
+		"elapsed_ms": {methodStopwatchElapsed, nil},
+		"lap":        {methodStopwatchLap, nil},
+	},
+	object.INTEGER_OBJ: {
+		"format": {methodFormatNumber, object.CostOf},
+	},
+	object.FLOAT_OBJ: {
+		"format": {methodFormatNumber, object.CostOf},
+	},
+}
+
+// Methods lists the receiver methods of values of type t, sorted by name.
+func Methods(t object.Type) []string {
+	names := make([]string, 0, len(methods[t]))
+	for name := range methods[t] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CallMethod applies the receiver method recv.name(args...) and returns its
+// result along with the memory it allocated. Both backends dispatch through
+// here after checking for a callable dict member of the same name.
+func CallMethod(recv object.Object, name string, args []object.Object) (object.Object, int64, error) {
+	if name == "get" && recv.Type() != object.DICT_OBJ {
+		return nil, 0, fmt.Errorf("get() receiver must be DICT")
+	}
+	table, ok := methods[recv.Type()]
+	if !ok {
+		return nil, 0, fmt.Errorf("type has no methods: %s", recv.Type())
+	}
+	m, ok := table[name]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown method for %s: %s", recv.Type(), name)
+	}
+	res, err := m.call(recv, args)
+	if err != nil {
+		return nil, 0, err
+	}
+	var cost int64
+	if m.cost != nil {
+		cost = m.cost(res)
+	}
+	return res, cost, nil
+}
+
+func costGraphemes(res object.Object) int64 {
+	arr := res.(*object.Array)
+	cost := object.CostOf(arr)
+	for _, el := range arr.Elements {
+		cost += object.CostOf(el)
+	}
+	return cost
+}
+
+func arityError(name string, want string, got int) error {
+	return fmt.Errorf("%s() takes %s, got %d", name, want, got)
+}
+
+func methodLen(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 0 {
+		return nil, arityError("len", "0 arguments", len(args))
+	}
+	switch v := recv.(type) {
+	case *object.String:
+		return &object.Integer{Value: int64(v.RuneCount())}, nil
+	case *object.Array:
+		return &object.Integer{Value: int64(len(v.Elements))}, nil
+	case *object.Dict:
+		return &object.Integer{Value: int64(len(v.Pairs))}, nil
+	case *object.Set:
+		return &object.Integer{Value: int64(len(v.Items))}, nil
+	case *object.Range:
+		return &object.Integer{Value: v.Len()}, nil
+	default:
+		return nil, fmt.Errorf("len() not supported for type: %s", recv.Type())
+	}
+}
+
+func methodRangeToList(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 0 {
+		return nil, arityError("to_list", "0 arguments", len(args))
+	}
+	return recv.(*object.Range).Array(), nil
+}
+
+func methodAppend(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 1 {
+		return nil, arityError("append", "1 argument", len(args))
+	}
+	return recv.(*object.Array).Append(args[0]), nil
+}
+
+func methodArrayCount(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 1 {
+		return nil, arityError("count", "1 argument", len(args))
+	}
+	var count int64
+	for _, el := range recv.(*object.Array).Elements {
+		eq, err := Compare("==", el, args[0])
+		if err != nil {
+			return nil, err
+		}
+		if eq {
+			count++
+		}
+	}
+	return &object.Integer{Value: count}, nil
+}
+
+// methodArrayInsert puts a value before the element at an index, shifting
+// the rest up. As with slices, a negative index counts from the end and one
+// past either end is clamped to it.
+func methodArrayInsert(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 2 {
+		return nil, arityError("insert", "2 arguments", len(args))
+	}
+	i, ok := args[0].(*object.Integer)
+	if !ok {
+		return nil, fmt.Errorf("insert() index must be INTEGER, got: %s", args[0].Type())
+	}
+	arr := recv.(*object.Array)
+	n := int64(len(arr.Elements))
+	pos := i.Value
+	if pos < 0 {
+		pos = max(pos+n, 0)
+	}
+	pos = min(pos, n)
+	arr.Own()
+	arr.Elements = append(arr.Elements, nil)
+	copy(arr.Elements[pos+1:], arr.Elements[pos:])
+	arr.Elements[pos] = args[1]
+	return &object.Nil{}, nil
+}
+
+func costInsert(object.Object) int64 {
+	return object.CostArrayElements(1)
+}
+
+func methodArrayPop(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 0 {
+		return nil, arityError("pop", "0 arguments", len(args))
+	}
+	arr := recv.(*object.Array)
+	if len(arr.Elements) == 0 {
+		return nil, fmt.Errorf("pop from empty array")
+	}
+	last := arr.Elements[len(arr.Elements)-1]
+	arr.Elements = arr.Elements[:len(arr.Elements)-1]
+	return last, nil
+}
+
+func methodArrayRemove(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 1 {
+		return nil, arityError("remove", "1 argument", len(args))
+	}
+	arr := recv.(*object.Array)
+	for i, el := range arr.Elements {
+		eq, err := Compare("==", el, args[0])
+		if err != nil {
+			return nil, err
+		}
+		if eq {
+			arr.Own()
+			arr.Elements = append(arr.Elements[:i], arr.Elements[i+1:]...)
+			return &object.Boolean{Value: true}, nil
+		}
+	}
+	return &object.Boolean{Value: false}, nil
+}
+
+// dictKey resolves the key argument of a dict method to its pair-map key.
+func dictKey(key object.Object) (object.HashKey, error) {
+	hk, ok := object.HashKeyOf(key)
+	if !ok {
+		return object.HashKey{}, fmt.Errorf("unusable as dict key: %s", key.Type())
+	}
+	return hk, nil
+}
+
+func methodDictCount(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 0 {
+		return nil, arityError("count", "0 arguments", len(args))
+	}
+	return &object.Integer{Value: int64(len(recv.(*object.Dict).Pairs))}, nil
+}
+
+func methodDictGet(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, arityError("get", "1 or 2 arguments", len(args))
+	}
+	key, err := dictKey(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if pair, exists := recv.(*object.Dict).Pairs[key]; exists {
+		return pair.Value, nil
+	}
+	if len(args) == 2 {
+		return args[1], nil
+	}
+	return &object.Nil{}, nil
+}
+
+func methodKeys(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 0 {
+		return nil, arityError("keys", "0 arguments", len(args))
+	}
+	pairs := object.SortedDictPairs(recv.(*object.Dict))
+	els := make([]object.Object, 0, len(pairs))
+	for _, pair := range pairs {
+		els = append(els, pair.Key)
+	}
+	return &object.Array{Elements: els}, nil
+}
+
+func methodDictPop(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, arityError("pop", "1 or 2 arguments", len(args))
+	}
+	key, err := dictKey(args[0])
+	if err != nil {
+		return nil, err
+	}
+	d := recv.(*object.Dict)
+	if d.Frozen {
+		return nil, fmt.Errorf(ReadOnlyExportsMessage)
+	}
+	if pair, exists := d.Pairs[key]; exists {
+		delete(d.Pairs, key)
+		return pair.Value, nil
+	}
+	if len(args) == 2 {
+		return args[1], nil
+	}
+	return nil, fmt.Errorf("key not found")
+}
+
+func methodDictRemove(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 1 {
+		return nil, arityError("remove", "1 argument", len(args))
+	}
+	key, err := dictKey(args[0])
+	if err != nil {
+		return nil, err
+	}
+	d := recv.(*object.Dict)
+	if d.Frozen {
+		return nil, fmt.Errorf(ReadOnlyExportsMessage)
+	}
+	if _, exists := d.Pairs[key]; !exists {
+		return nil, fmt.Errorf("key not found")
+	}
+	delete(d.Pairs, key)
+	return &object.Nil{}, nil
+}
+
+func methodValues(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 0 {
+		return nil, arityError("values", "0 arguments", len(args))
+	}
+	pairs := object.SortedDictPairs(recv.(*object.Dict))
+	els := make([]object.Object, 0, len(pairs))
+	for _, pair := range pairs {
+		els = append(els, pair.Value)
+	}
+	return &object.Array{Elements: els}, nil
+}
+
+func methodHasKey(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 1 {
+		return nil, arityError("hasKey", "1 argument", len(args))
+	}
+	key, err := dictKey(args[0])
+	if err != nil {
+		return nil, err
+	}
+	_, exists := recv.(*object.Dict).Pairs[key]
+	return &object.Boolean{Value: exists}, nil
+}
+
+// stringMethod adapts a string-to-string transform into a zero-argument method.
+func stringMethod(name string, fn func(string) string) func(object.Object, []object.Object) (object.Object, error) {
+	return func(recv object.Object, args []object.Object) (object.Object, error) {
+		if len(args) != 0 {
+			return nil, arityError(name, "0 arguments", len(args))
+		}
+		return &object.String{Value: fn(recv.(*object.String).Value)}, nil
+	}
+}
+
+var (
+	methodStrip     = stringMethod("strip", strings.TrimSpace)
+	methodUppercase = stringMethod("uppercase", strings.ToUpper)
+	methodLowercase = stringMethod("lowercase", strings.ToLower)
+	methodCasefold  = stringMethod("casefold", unitext.Casefold)
+)
+
+func methodCapitalize(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 0 {
+		return nil, arityError("capitalize", "0 arguments", len(args))
+	}
+	s := recv.(*object.String)
+	if s.Value == "" {
+		return &object.String{Value: ""}, nil
+	}
+	rs := []rune(s.Value)
+	first := strings.ToUpper(string(rs[0]))
+	rest := ""
+	if len(rs) > 1 {
+		rest = strings.ToLower(string(rs[1:]))
+	}
+	return &object.String{Value: first + rest}, nil
+}
+
+func methodStartsWith(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 1 {
+		return nil, arityError("startswith", "1 argument", len(args))
+	}
+	prefix, ok := args[0].(*object.String)
+	if !ok {
+		return nil, fmt.Errorf("startswith() prefix must be STRING")
+	}
+	return &object.Boolean{Value: strings.HasPrefix(recv.(*object.String).Value, prefix.Value)}, nil
+}
+
+func methodEndsWith(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 1 {
+		return nil, arityError("endswith", "1 argument", len(args))
+	}
+	suffix, ok := args[0].(*object.String)
+	if !ok {
+		return nil, fmt.Errorf("endswith() suffix must be STRING")
+	}
+	return &object.Boolean{Value: strings.HasSuffix(recv.(*object.String).Value, suffix.Value)}, nil
+}
+
+func methodSlice(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) > 2 {
+		return nil, arityError("slice", "0, 1, or 2 arguments", len(args))
+	}
+	var lowPtr, highPtr *int64
+	if len(args) >= 1 {
+		i, ok := args[0].(*object.Integer)
+		if !ok {
+			return nil, fmt.Errorf("slice low must be INTEGER, got: %s", args[0].Type())
+		}
+		lowPtr = &i.Value
+	}
+	if len(args) == 2 {
+		i, ok := args[1].(*object.Integer)
+		if !ok {
+			return nil, fmt.Errorf("slice high must be INTEGER, got: %s", args[1].Type())
+		}
+		highPtr = &i.Value
+	}
+	s := recv.(*object.String)
+	lo, hi := SliceBounds(lowPtr, highPtr, 1, int64(s.RuneCount()))
+	return &object.String{Value: s.SliceStep(int(lo), int(hi), 1)}, nil
+}
+
+func methodGraphemes(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 0 {
+		return nil, arityError("graphemes", "0 arguments", len(args))
+	}
+	clusters := unitext.Graphemes(recv.(*object.String).Value)
+	els := make([]object.Object, len(clusters))
+	for i, g := range clusters {
+		els[i] = &object.String{Value: g}
+	}
+	return &object.Array{Elements: els}, nil
+}
+
+func methodFormatNumber(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 1 {
+		return nil, arityError("format", "1 argument", len(args))
+	}
+	decObj, ok := args[0].(*object.Integer)
+	if !ok {
+		return nil, fmt.Errorf("format() decimals must be INTEGER")
+	}
+	if decObj.Value < 0 {
+		return nil, fmt.Errorf("format() decimals must be >= 0")
+	}
+	decimals := int(decObj.Value)
+	switch v := recv.(type) {
+	case *object.Integer:
+		return &object.String{Value: formatIntFixed(v.Value, decimals)}, nil
+	case *object.Float:
+		return &object.String{Value: formatFloatFixed(v.Value, decimals)}, nil
+	default:
+		return nil, fmt.Errorf("format() receiver must be NUMBER")
+	}
+}
+
+func formatIntFixed(value int64, decimals int) string {
+	if decimals == 0 {
+		return strconv.FormatInt(value, 10)
+	}
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+	return sign + strconv.FormatInt(value, 10) + "." + strings.Repeat("0", decimals)
+}
+
+func formatFloatFixed(value float64, decimals int) string {
+	if decimals == 0 {
+		return strconv.FormatFloat(math.Round(value), 'f', 0, 64)
+	}
+	scale := math.Pow10(decimals)
+	rounded := math.Round(value*scale) / scale
+	return strconv.FormatFloat(rounded, 'f', decimals, 64)
+}
@@ -0,0 +1,227 @@
+package semantics
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"welle/internal/netio"
+	"welle/internal/object"
+)
+
+// The net_* builtins behind std:net. Sockets are returned as dicts whose
+// "handle" entry the other calls take; reads return nil at end of stream.
+
+// NetListen implements net_listen(addr) -> #{"handle", "addr"}.
+func NetListen(args []object.Object) (object.Object, error) {
+	addr, err := textArg("net_listen", args)
+	if err != nil {
+		return nil, err
+	}
+	id, bound, err := netio.Listen(addr)
+	if err != nil {
+		return nil, err
+	}
+	return socketDict(id, map[string]string{"addr": bound}), nil
+}
+
+// NetAccept implements net_accept(listener) -> #{"handle", "local", "remote"}.
+func NetAccept(args []object.Object) (object.Object, error) {
+	id, err := netHandleArgs("net_accept", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	conn, local, remote, err := netio.Accept(id)
+	if err != nil {
+		return nil, err
+	}
+	return connDict(conn, local, remote), nil
+}
+
+// NetConnect implements net_connect(addr, timeout_ms); 0 means no timeout.
+func NetConnect(args []object.Object) (object.Object, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	addr, ok := args[0].(*object.String)
+	if !ok {
+		return nil, fmt.Errorf("net_connect() expects STRING address, got %s", args[0].Type())
+	}
+	timeout, err := millis("net_connect", args[1])
+	if err != nil {
+		return nil, err
+	}
+	conn, local, remote, err := netio.Connect(addr.Value, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return connDict(conn, local, remote), nil
+}
+
+// NetSend implements net_send(conn, data) and returns the bytes written.
+func NetSend(args []object.Object) (object.Object, error) {
+	id, err := netHandleArgs("net_send", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := args[1].(*object.String)
+	if !ok {
+		return nil, fmt.Errorf("net_send() expects STRING data, got %s", args[1].Type())
+	}
+	n, err := netio.Send(id, data.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &object.Integer{Value: int64(n)}, nil
+}
+
+// NetRecv implements net_recv(conn, max).
+func NetRecv(args []object.Object) (object.Object, error) {
+	id, err := netHandleArgs("net_recv", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	max, ok := args[1].(*object.Integer)
+	if !ok {
+		return nil, fmt.Errorf("net_recv() expects INTEGER size, got %s", args[1].Type())
+	}
+	data, eof, err := netio.Recv(id, int(max.Value))
+	return streamResult(data, eof, err)
+}
+
+// NetRecvLine implements net_recv_line(conn).
+func NetRecvLine(args []object.Object) (object.Object, error) {
+	id, err := netHandleArgs("net_recv_line", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	line, eof, err := netio.RecvLine(id)
+	return streamResult(line, eof, err)
+}
+
+// NetUDPBind implements net_udp_bind(addr) -> #{"handle", "addr"}.
+func NetUDPBind(args []object.Object) (object.Object, error) {
+	addr, err := textArg("net_udp_bind", args)
+	if err != nil {
+		return nil, err
+	}
+	id, bound, err := netio.UDPBind(addr)
+	if err != nil {
+		return nil, err
+	}
+	return socketDict(id, map[string]string{"addr": bound}), nil
+}
+
+// NetUDPSend implements net_udp_send(sock, addr, data).
+func NetUDPSend(args []object.Object) (object.Object, error) {
+	id, err := netHandleArgs("net_udp_send", args, 3)
+	if err != nil {
+		return nil, err
+	}
+	addr, ok1 := args[1].(*object.String)
+	data, ok2 := args[2].(*object.String)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("net_udp_send() expects STRING address and data")
+	}
+	n, err := netio.UDPSend(id, addr.Value, data.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &object.Integer{Value: int64(n)}, nil
+}
+
+// NetUDPRecv implements net_udp_recv(sock, max) -> #{"data", "from"}.
+func NetUDPRecv(args []object.Object) (object.Object, error) {
+	id, err := netHandleArgs("net_udp_recv", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	max, ok := args[1].(*object.Integer)
+	if !ok {
+		return nil, fmt.Errorf("net_udp_recv() expects INTEGER size, got %s", args[1].Type())
+	}
+	data, from, err := netio.UDPRecv(id, int(max.Value))
+	if err != nil {
+		return nil, err
+	}
+	return stringDict(map[string]string{"data": data, "from": from.String()}), nil
+}
+
+// NetSetTimeout implements net_set_timeout(sock, timeout_ms).
+func NetSetTimeout(args []object.Object) error {
+	id, err := netHandleArgs("net_set_timeout", args, 2)
+	if err != nil {
+		return err
+	}
+	timeout, err := millis("net_set_timeout", args[1])
+	if err != nil {
+		return err
+	}
+	return netio.SetTimeout(id, timeout)
+}
+
+// NetClose implements net_close(sock).
+func NetClose(args []object.Object) error {
+	id, err := netHandleArgs("net_close", args, 1)
+	if err != nil {
+		return err
+	}
+	return netio.Close(id)
+}
+
+func netHandleArgs(name string, args []object.Object, n int) (int64, error) {
+	if err := checkArgs(args, n, n); err != nil {
+		return 0, err
+	}
+	return handleArg(name, "socket handle", args[0])
+}
+
+func millis(name string, obj object.Object) (time.Duration, error) {
+	var ms float64
+	switch v := obj.(type) {
+	case *object.Integer:
+		ms = float64(v.Value)
+	case *object.Float:
+		ms = v.Value
+	default:
+		return 0, fmt.Errorf("%s() expects NUMBER timeout in milliseconds, got %s", name, obj.Type())
+	}
+	if ms < 0 {
+		return 0, fmt.Errorf("%s() timeout must not be negative", name)
+	}
+	return time.Duration(ms * float64(time.Millisecond)), nil
+}
+
+func streamResult(data string, eof bool, err error) (object.Object, error) {
+	if err != nil {
+		return nil, err
+	}
+	if eof {
+		return &object.Nil{}, nil
+	}
+	return &object.String{Value: data}, nil
+}
+
+func connDict(id int64, local, remote net.Addr) *object.Dict {
+	return socketDict(id, map[string]string{"local": local.String(), "remote": remote.String()})
+}
+
+func socketDict(id int64, fields map[string]string) *object.Dict {
+	d := stringDict(fields)
+	setDictField(d, "handle", &object.Integer{Value: id})
+	return d
+}
+
+func stringDict(fields map[string]string) *object.Dict {
+	d := &object.Dict{Pairs: map[object.HashKey]object.DictPair{}}
+	for k, v := range fields {
+		setDictField(d, k, &object.String{Value: v})
+	}
+	return d
+}
+
+func setDictField(d *object.Dict, key string, val object.Object) {
+	k := &object.String{Value: key}
+	hk, _ := object.HashKeyOf(k)
+	d.Pairs[hk] = object.DictPair{Key: k, Value: val}
+}
@@ -0,0 +1,44 @@
+package semantics
+
+import (
+	"fmt"
+
+	"welle/internal/object"
+)
+
+// CheckArity reports whether n arguments can call a function with
+// numParams parameters, the last numDefaults of which have default values.
+func CheckArity(n, numParams, numDefaults int) error {
+	min := numParams - numDefaults
+	if n >= min && n <= numParams {
+		return nil
+	}
+	switch {
+	case numDefaults == 0:
+		return fmt.Errorf("wrong number of arguments: expected %d, got %d", numParams, n)
+	case numDefaults == 1:
+		return fmt.Errorf("wrong number of arguments: expected %d or %d, got %d", min, numParams, n)
+	default:
+		return fmt.Errorf("wrong number of arguments: expected %d to %d, got %d", min, numParams, n)
+	}
+}
+
+// MissingDefaults returns the default values that fill in the parameters
+// after the first n, for a call CheckArity accepted.
+func MissingDefaults(n, numParams int, defaults []object.Object) []object.Object {
+	return defaults[len(defaults)-(numParams-n):]
+}
+
+// BindArgs checks args against a function with numParams parameters and
+// returns them with any missing trailing ones filled in from defaults.
+func BindArgs(args []object.Object, numParams int, defaults []object.Object) ([]object.Object, error) {
+	if err := CheckArity(len(args), numParams, len(defaults)); err != nil {
+		return nil, err
+	}
+	if len(args) == numParams {
+		return args, nil
+	}
+	out := make([]object.Object, 0, numParams)
+	out = append(out, args...)
+	return append(out, MissingDefaults(len(args), numParams, defaults)...), nil
+}
@@ -0,0 +1,187 @@
+package semantics_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"welle/internal/builtins"
+	"welle/internal/object"
+	"welle/internal/semantics"
+)
+
+// matrixArgs are the argument lists the parity matrix calls every builtin
+// and method with: the usual good shapes and enough bad ones to reach each
+// argument check. They are source text so each call gets fresh values.
+var matrixArgs = []string{
+	``,
+	`nil`,
+	`1`,
+	`-2`,
+	`2.5`,
+	`"ab"`,
+	`true`,
+	`[3, 1, 2]`,
+	`#{"a": 1}`,
+	`(1, 2)`,
+	`func(x) { return x }`,
+	`1, 2`,
+	`"ab", "b"`,
+	`"ab", 1`,
+	`[3, 1, 2], 1`,
+	`[3, 1, 2], func(x) { return x }`,
+	`#{"a": 1}, "a"`,
+	`#{"a": 1}, "b", 2`,
+	`1, 2, 3`,
+	`"ab", 0, 1`,
+}
+
+// matrixReceivers are the receivers method calls are made on, by type.
+var matrixReceivers = map[object.Type]string{
+	object.ARRAY_OBJ:    `[3, 1, 2]`,
+	object.DICT_OBJ:     `#{"a": 1}`,
+	object.STRING_OBJ:   `"ab"`,
+	object.INTEGER_OBJ:  `(-3)`,
+	object.FLOAT_OBJ:    `2.5`,
+	object.SEQ_OBJ:      `seq([3, 1, 2])`,
+	object.SET_OBJ:      `#[3, 1, 2]`,
+	object.TUPLE_OBJ:    `(3, 1, 2)`,
+	object.RANGE_OBJ:    `range(3)`,
+	object.CLASS_OBJ:    `Point`,
+	object.INSTANCE_OBJ: `Point(3)`,
+}
+
+// matrixClass is declared ahead of the method calls for the class and
+// instance receivers.
+const matrixClass = `class Point {
+  x = 0
+  func move(d) {
+    self.x += d
+    return self
+  }
+}
+`
+
+// matrixMethods lists the methods called on receivers of type typ: the
+// builtin ones, or for classes and instances what Point declares.
+func matrixMethods(typ object.Type) []string {
+	var names []string
+	switch typ {
+	case object.CLASS_OBJ:
+		names = []string{"move"}
+	case object.INSTANCE_OBJ:
+		names = []string{"move", "x"}
+	default:
+		names = semantics.Methods(typ)
+	}
+	return append(names, "nope")
+}
+
+// matrixSkip lists builtins the matrix does not call: they wait on stdin,
+// change state the rest of the test run depends on, or describe the
+// running program, which the two backends lay out differently.
+var matrixSkip = map[string]bool{
+	"input":             true,
+	"getpass":           true,
+	"trace":             true,
+	"set_print_options": true,
+	"locals":            true,
+	"globals":           true,
+	"dir":               true,
+}
+
+// matrixScript runs prelude, then calls each of calls in its own try block
+// and exports what each returned or raised, in order, as `out`.
+func matrixScript(prelude string, calls []string) string {
+	var b strings.Builder
+	b.WriteString(prelude)
+	b.WriteString("r = []\n")
+	for _, call := range calls {
+		fmt.Fprintf(&b, "try { r = push(r, %s) } catch (e) { r = push(r, e) }\n", call)
+	}
+	b.WriteString("export out = r\n")
+	return b.String()
+}
+
+// sameFunctions rewrites the one difference the backends are meant to
+// have: a function is a FUNCTION that prints as its source on the
+// interpreter and a CLOSURE that prints as "closure" on the VM.
+var sameFunctions = strings.NewReplacer(
+	"CLOSURE", "FUNCTION",
+	"func(x) {\n  return x\n}", "closure",
+)
+
+// matrixValue describes one result for comparison.
+func matrixValue(v object.Object) string {
+	if e, ok := v.(*object.Error); ok {
+		return sameFunctions.Replace(fmt.Sprintf("error(%d): %s", e.Code, e.Message))
+	}
+	return sameFunctions.Replace(string(v.Type()) + " " + v.Inspect())
+}
+
+// diffMatrix runs calls after prelude on both backends and reports every
+// call whose result differs.
+func diffMatrix(t *testing.T, label, prelude string, calls []string) {
+	t.Helper()
+	src := matrixScript(prelude, calls)
+	intRes, intOut, err := captureRun(func() runResult { return runInterpreter(src) })
+	if err != nil {
+		t.Fatalf("%s: interpreter capture error: %v", label, err)
+	}
+	vmRes, vmOut, err := captureRun(func() runResult { return runVM(src) })
+	if err != nil {
+		t.Fatalf("%s: vm capture error: %v", label, err)
+	}
+	if intRes.errMsg != "" || vmRes.errMsg != "" {
+		t.Errorf("%s: script failed: interpreter %q, vm %q", label, intRes.errMsg, vmRes.errMsg)
+		return
+	}
+	if sameFunctions.Replace(intOut) != sameFunctions.Replace(vmOut) {
+		t.Errorf("%s: stdout mismatch: interpreter %q, vm %q", label, intOut, vmOut)
+	}
+	intVal, _ := exportValue(intRes.exports, "out")
+	vmVal, _ := exportValue(vmRes.exports, "out")
+	intArr, ok1 := intVal.(*object.Array)
+	vmArr, ok2 := vmVal.(*object.Array)
+	if !ok1 || !ok2 || len(intArr.Elements) != len(calls) || len(vmArr.Elements) != len(calls) {
+		t.Errorf("%s: missing results: interpreter %v, vm %v", label, intVal, vmVal)
+		return
+	}
+	for i, call := range calls {
+		a, b := matrixValue(intArr.Elements[i]), matrixValue(vmArr.Elements[i])
+		if a != b {
+			t.Errorf("%s: interpreter %s, vm %s", call, a, b)
+		}
+	}
+}
+
+// TestParityMatrixBuiltins calls every builtin with each of matrixArgs on
+// both backends and compares what comes back: the value, or the code and
+// message of the error raised.
+func TestParityMatrixBuiltins(t *testing.T) {
+	t.Chdir(t.TempDir())
+	for _, name := range builtins.Names() {
+		if matrixSkip[name] {
+			continue
+		}
+		calls := make([]string, len(matrixArgs))
+		for i, args := range matrixArgs {
+			calls[i] = name + "(" + args + ")"
+		}
+		diffMatrix(t, name, "", calls)
+	}
+}
+
+// TestParityMatrixMethods does the same for every receiver method, plus a
+// method each type does not have, including types with no methods at all.
+func TestParityMatrixMethods(t *testing.T) {
+	for typ, recv := range matrixReceivers {
+		for _, name := range matrixMethods(typ) {
+			calls := make([]string, len(matrixArgs))
+			for i, args := range matrixArgs {
+				calls[i] = recv + "." + name + "(" + args + ")"
+			}
+			diffMatrix(t, string(typ)+"."+name, matrixClass, calls)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package semantics
+
+import (
+	"fmt"
+
+	"welle/internal/object"
+	"welle/internal/procio"
+)
+
+// ProcRun implements proc_run(cmd, args, opts) ->
+// #{"code", "stdout", "stderr"}.
+func ProcRun(args []object.Object) (object.Object, error) {
+	name, argv, opts, err := procCommandArgs("proc_run", args)
+	if err != nil {
+		return nil, err
+	}
+	res, err := procio.Run(name, argv, opts)
+	if err != nil {
+		return nil, err
+	}
+	d := stringDict(map[string]string{"stdout": res.Stdout, "stderr": res.Stderr})
+	setDictField(d, "code", &object.Integer{Value: int64(res.Code)})
+	return d, nil
+}
+
+// ProcSpawn implements proc_spawn(cmd, args, opts) -> #{"handle", "pid"}.
+func ProcSpawn(args []object.Object) (object.Object, error) {
+	name, argv, opts, err := procCommandArgs("proc_spawn", args)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Stdin != "" || opts.Timeout != 0 {
+		return nil, fmt.Errorf("proc_spawn() does not take the stdin or timeout_ms options")
+	}
+	id, pid, err := procio.Spawn(name, argv, opts)
+	if err != nil {
+		return nil, err
+	}
+	d := &object.Dict{Pairs: map[object.HashKey]object.DictPair{}}
+	setDictField(d, "handle", &object.Integer{Value: id})
+	setDictField(d, "pid", &object.Integer{Value: int64(pid)})
+	return d, nil
+}
+
+// ProcWrite implements proc_write(proc, data) and returns the bytes written.
+func ProcWrite(args []object.Object) (object.Object, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	id, err := handleArg("proc_write", "process handle", args[0])
+	if err != nil {
+		return nil, err
+	}
+	data, ok := args[1].(*object.String)
+	if !ok {
+		return nil, fmt.Errorf("proc_write() expects STRING data, got %s", args[1].Type())
+	}
+	n, err := procio.Write(id, data.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &object.Integer{Value: int64(n)}, nil
+}
+
+// ProcCloseStdin implements proc_close_stdin(proc).
+func ProcCloseStdin(args []object.Object) error {
+	id, err := procHandle("proc_close_stdin", args)
+	if err != nil {
+		return err
+	}
+	return procio.CloseStdin(id)
+}
+
+// ProcReadLine implements proc_read_line(proc): the next stdout line, or
+// nil at end of output.
+func ProcReadLine(args []object.Object) (object.Object, error) {
+	return procReadLine("proc_read_line", args, false)
+}
+
+// ProcReadErrLine implements proc_read_err_line(proc), the stderr
+// counterpart of proc_read_line.
+func ProcReadErrLine(args []object.Object) (object.Object, error) {
+	return procReadLine("proc_read_err_line", args, true)
+}
+
+// ProcWait implements proc_wait(proc), which returns the exit code.
+func ProcWait(args []object.Object) (object.Object, error) {
+	id, err := procHandle("proc_wait", args)
+	if err != nil {
+		return nil, err
+	}
+	code, err := procio.Wait(id)
+	if err != nil {
+		return nil, err
+	}
+	return &object.Integer{Value: int64(code)}, nil
+}
+
+// ProcKill implements proc_kill(proc).
+func ProcKill(args []object.Object) error {
+	id, err := procHandle("proc_kill", args)
+	if err != nil {
+		return err
+	}
+	return procio.Kill(id)
+}
+
+func procReadLine(name string, args []object.Object, fromStderr bool) (object.Object, error) {
+	id, err := procHandle(name, args)
+	if err != nil {
+		return nil, err
+	}
+	line, eof, err := procio.ReadLine(id, fromStderr)
+	return streamResult(line, eof, err)
+}
+
+func procHandle(name string, args []object.Object) (int64, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return 0, err
+	}
+	return handleArg(name, "process handle", args[0])
+}
+
+func procCommandArgs(name string, args []object.Object) (string, []string, procio.Options, error) {
+	var opts procio.Options
+	if err := checkArgs(args, 3, 3); err != nil {
+		return "", nil, opts, err
+	}
+	cmd, ok := args[0].(*object.String)
+	if !ok {
+		return "", nil, opts, fmt.Errorf("%s() expects STRING command, got %s", name, args[0].Type())
+	}
+	arr, ok := args[1].(*object.Array)
+	if !ok {
+		return "", nil, opts, fmt.Errorf("%s() expects ARRAY args, got %s", name, args[1].Type())
+	}
+	argv := make([]string, len(arr.Elements))
+	for i, el := range arr.Elements {
+		s, ok := el.(*object.String)
+		if !ok {
+			return "", nil, opts, fmt.Errorf("%s() args must be STRINGs, got %s", name, el.Type())
+		}
+		argv[i] = s.Value
+	}
+	switch o := args[2].(type) {
+	case *object.Nil:
+	case *object.Dict:
+		parsed, err := procOptions(name, o)
+		if err != nil {
+			return "", nil, opts, err
+		}
+		opts = parsed
+	default:
+		return "", nil, opts, fmt.Errorf("%s() expects DICT options, got %s", name, args[2].Type())
+	}
+	return cmd.Value, argv, opts, nil
+}
+
+func procOptions(name string, d *object.Dict) (procio.Options, error) {
+	var opts procio.Options
+	for _, pair := range object.SortedDictPairs(d) {
+		k, ok := pair.Key.(*object.String)
+		if !ok {
+			return opts, fmt.Errorf("%s() option names must be STRINGs", name)
+		}
+		switch k.Value {
+		case "cwd", "stdin":
+			s, ok := pair.Value.(*object.String)
+			if !ok {
+				return opts, fmt.Errorf("%s() option %q must be STRING, got %s", name, k.Value, pair.Value.Type())
+			}
+			if k.Value == "cwd" {
+				opts.Dir = s.Value
+			} else {
+				opts.Stdin = s.Value
+			}
+		case "env":
+			env, ok := pair.Value.(*object.Dict)
+			if !ok {
+				return opts, fmt.Errorf("%s() option \"env\" must be DICT, got %s", name, pair.Value.Type())
+			}
+			opts.Env = map[string]string{}
+			for _, e := range env.Pairs {
+				ek, kok := e.Key.(*object.String)
+				ev, vok := e.Value.(*object.String)
+				if !kok || !vok {
+					return opts, fmt.Errorf("%s() option \"env\" must map STRING to STRING", name)
+				}
+				opts.Env[ek.Value] = ev.Value
+			}
+		case "timeout_ms":
+			timeout, err := millis(name, pair.Value)
+			if err != nil {
+				return opts, err
+			}
+			opts.Timeout = timeout
+		default:
+			return opts, fmt.Errorf("%s() has no option %q", name, k.Value)
+		}
+	}
+	return opts, nil
+}
@@ -0,0 +1,39 @@
+package semantics
+
+import (
+	"fmt"
+
+	"welle/internal/object"
+)
+
+// RangeIndex implements r[idx]. A negative index counts from the end, as
+// it does for arrays.
+func RangeIndex(r *object.Range, idx object.Object) (object.Object, error) {
+	i, ok := idx.(*object.Integer)
+	if !ok {
+		return nil, fmt.Errorf("range index must be INTEGER, got: %s", idx.Type())
+	}
+	n, l := i.Value, r.Len()
+	if n < 0 {
+		n = l + n
+	}
+	if n < 0 || n >= l {
+		return nil, fmt.Errorf("index out of range")
+	}
+	return &object.Integer{Value: r.At(n)}, nil
+}
+
+// SliceRange implements r[low:high:step]. The elements it selects are
+// evenly spaced too, so the result is another range.
+func SliceRange(r *object.Range, lowPtr *int64, highPtr *int64, stepVal int64) *object.Range {
+	lo, hi := SliceBounds(lowPtr, highPtr, stepVal, r.Len())
+	var n int64
+	switch {
+	case stepVal > 0 && lo < hi:
+		n = (hi-lo-1)/stepVal + 1
+	case stepVal < 0 && lo > hi:
+		n = (lo-hi-1)/-stepVal + 1
+	}
+	start, step := r.At(lo), r.Step*stepVal
+	return &object.Range{Start: start, Stop: start + n*step, Step: step}
+}
@@ -0,0 +1,89 @@
+package semantics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"welle/internal/object"
+)
+
+// hiddenName reports whether a binding is an implementation detail that
+// locals(), globals() and dir() leave out: compiler temporaries and the
+// "__welle_" names the runtimes keep next to user bindings.
+func hiddenName(name string) bool {
+	return name == "" || strings.HasPrefix(name, "__welle_")
+}
+
+// ScopeDict returns a snapshot of bindings as a dict from name to value for
+// locals() and globals(). Unset bindings (nil) and hidden names are skipped.
+// The dict is a copy: changing it does not rebind anything.
+func ScopeDict(bindings map[string]object.Object) *object.Dict {
+	d := &object.Dict{Pairs: make(map[object.HashKey]object.DictPair, len(bindings))}
+	for name, val := range bindings {
+		if val == nil || hiddenName(name) {
+			continue
+		}
+		key := &object.String{Value: name}
+		hk, _ := object.HashKeyOf(key)
+		d.Pairs[hk] = object.DictPair{Key: key, Value: val}
+	}
+	return d
+}
+
+// SlotBindings pairs slot names with slot values, as the VM stores locals
+// and globals. A name defined in several slots keeps the last one that has
+// a value; cells holding captured variables are unwrapped.
+func SlotBindings(names []string, slots []object.Object) map[string]object.Object {
+	out := make(map[string]object.Object, len(names))
+	for i, name := range names {
+		if i >= len(slots) || slots[i] == nil {
+			continue
+		}
+		val := slots[i]
+		if cell, ok := val.(*object.Cell); ok {
+			if cell.Value == nil {
+				continue
+			}
+			val = cell.Value
+		}
+		out[name] = val
+	}
+	return out
+}
+
+// Dir implements dir(value) for a module or dict: its string keys, sorted.
+// dir() with no arguments lists the names in scope; the engines handle it
+// with SortedNames.
+func Dir(args []object.Object) (*object.Array, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments: expected 0 or 1, got %d", len(args))
+	}
+	d, ok := args[0].(*object.Dict)
+	if !ok {
+		return nil, fmt.Errorf("dir() expects a module or DICT, got %s", args[0].Type())
+	}
+	names := make(map[string]object.Object, len(d.Pairs))
+	for _, pair := range d.Pairs {
+		if s, ok := pair.Key.(*object.String); ok {
+			names[s.Value] = pair.Value
+		}
+	}
+	return SortedNames(names), nil
+}
+
+// SortedNames returns the visible names of bindings as a sorted array.
+func SortedNames(bindings map[string]object.Object) *object.Array {
+	names := make([]string, 0, len(bindings))
+	for name, val := range bindings {
+		if val != nil && !hiddenName(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	out := make([]object.Object, len(names))
+	for i, name := range names {
+		out[i] = &object.String{Value: name}
+	}
+	return &object.Array{Elements: out}
+}
@@ -291,7 +291,7 @@ func Compare(op string, left, right object.Object) (bool, error) {
 		if rt, ok := right.(*object.Tuple); ok {
 			switch op {
 			case "==", "!=":
-				if len(lt.Elements) != len(rt.Elements) {
+				if len(lt.Elements) != len(rt.Elements) || !sameNames(lt.Names, rt.Names) {
 					return op == "!=", nil
 				}
 				for i := range lt.Elements {
@@ -310,6 +310,60 @@ func Compare(op string, left, right object.Object) (bool, error) {
 		}
 	}
 
+	if li, ok := left.(*object.Instance); ok {
+		if ri, ok := right.(*object.Instance); ok {
+			switch op {
+			case "==", "!=":
+				if li == ri {
+					return op == "==", nil
+				}
+				if li.Class != ri.Class {
+					return op == "!=", nil
+				}
+				for i := range li.Fields {
+					// A field == cannot compare, such as an array, is
+					// equal only to the same object.
+					eq, err := Compare("==", li.Fields[i], ri.Fields[i])
+					if err != nil {
+						eq = Identity(li.Fields[i], ri.Fields[i])
+					}
+					if !eq {
+						return op == "!=", nil
+					}
+				}
+				return op == "==", nil
+			default:
+				return false, fmt.Errorf("unknown operator for %s instances: %s", li.Class.Name, op)
+			}
+		}
+	}
+
+	if lr, ok := left.(*object.Range); ok {
+		if rr, ok := right.(*object.Range); ok {
+			switch op {
+			case "==":
+				return lr.Equal(rr), nil
+			case "!=":
+				return !lr.Equal(rr), nil
+			default:
+				return false, fmt.Errorf("unknown operator for ranges: %s", op)
+			}
+		}
+	}
+
+	if ls, ok := left.(*object.Set); ok {
+		if rs, ok := right.(*object.Set); ok {
+			switch op {
+			case "==":
+				return setsEqual(ls, rs), nil
+			case "!=":
+				return !setsEqual(ls, rs), nil
+			default:
+				return false, fmt.Errorf("unknown operator for sets: %s", op)
+			}
+		}
+	}
+
 	if left.Type() != right.Type() {
 		return false, fmt.Errorf("type mismatch: %s %s %s", left.Type(), op, right.Type())
 	}
@@ -344,6 +398,9 @@ func Identity(left, right object.Object) bool {
 	case *object.Dict:
 		r, ok := right.(*object.Dict)
 		return ok && l == r
+	case *object.Set:
+		r, ok := right.(*object.Set)
+		return ok && l == r
 	case *object.Tuple:
 		r, ok := right.(*object.Tuple)
 		return ok && l == r
@@ -386,6 +443,9 @@ func InOp(left, right object.Object) (bool, error) {
 			}
 		}
 		return false, nil
+	case *object.Range:
+		n, ok := left.(*object.Integer)
+		return ok && r.Contains(n.Value), nil
 	case *object.String:
 		ls, ok := left.(*object.String)
 		if !ok {
@@ -397,7 +457,14 @@ func InOp(left, right object.Object) (bool, error) {
 		if !ok {
 			return false, fmt.Errorf("unusable as dict key: %s", left.Type())
 		}
-		_, exists := r.Pairs[object.HashKeyString(hk)]
+		_, exists := r.Pairs[hk]
+		return exists, nil
+	case *object.Set:
+		hk, ok := object.HashKeyOf(left)
+		if !ok {
+			return false, setElementError(left)
+		}
+		_, exists := r.Items[hk]
 		return exists, nil
 	default:
 		return false, fmt.Errorf("cannot use 'in' with %s", right.Type())
@@ -421,13 +488,17 @@ func DictUpdateCount(dst, src *object.Dict) int {
 	return count
 }
 
+// ReadOnlyExportsMessage is the error for writes to a frozen dict, which is
+// always a module's export table.
+const ReadOnlyExportsMessage = "module exports are read-only"
+
 // DictUpdate merges src into dst in-place and returns the number of new entries added.
 func DictUpdate(dst, src *object.Dict) int {
 	if dst == nil || src == nil || len(src.Pairs) == 0 {
 		return 0
 	}
 	if dst.Pairs == nil {
-		dst.Pairs = make(map[string]object.DictPair, len(src.Pairs))
+		dst.Pairs = make(map[object.HashKey]object.DictPair, len(src.Pairs))
 	}
 	added := 0
 	for k, pair := range src.Pairs {
@@ -12,6 +12,7 @@ import (
 	"welle/internal/lexer"
 	"welle/internal/object"
 	"welle/internal/parser"
+	"welle/internal/semantics"
 	"welle/internal/vm"
 )
 
@@ -94,7 +95,7 @@ func vmErrorMessage(err error) string {
 func snapshotExports(env *object.Environment) *object.Dict {
 	snap := env.Snapshot()
 	exports := env.ExportedNames()
-	out := &object.Dict{Pairs: map[string]object.DictPair{}}
+	out := &object.Dict{Pairs: map[object.HashKey]object.DictPair{}}
 	for k, v := range snap {
 		if k == object.ExportSetName {
 			continue
@@ -104,7 +105,7 @@ func snapshotExports(env *object.Environment) *object.Dict {
 		}
 		key := &object.String{Value: k}
 		hk, _ := object.HashKeyOf(key)
-		out.Pairs[object.HashKeyString(hk)] = object.DictPair{Key: key, Value: v}
+		out.Pairs[hk] = object.DictPair{Key: key, Value: v}
 	}
 	return out
 }
@@ -115,7 +116,7 @@ func exportValue(exports *object.Dict, name string) (object.Object, bool) {
 	if !ok {
 		return nil, false
 	}
-	pair, ok := exports.Pairs[object.HashKeyString(hk)]
+	pair, ok := exports.Pairs[hk]
 	if !ok {
 		return nil, false
 	}
@@ -347,6 +348,13 @@ func TestSemanticsParity_Errors(t *testing.T) {
 		{`export x = 1 % 0`, "modulo by zero"},
 		{`export x = nil + nil`, "invalid operator for nil: +"},
 		{`export x = func(a) { return a }(...1)`, "cannot spread INTEGER in call arguments"},
+		{`export x = len(1, 2)`, "wrong number of arguments: expected 1, got 2"},
+		{`export x = len(1)`, "len() not supported for type: INTEGER"},
+		{`export x = keys([])`, "keys() expects DICT"},
+		{`export x = push(1, 2)`, "push() first argument must be ARRAY"},
+		{`export x = range("a")`, "range() expects INTEGER arguments"},
+		{`export x = sort([1, "a"])`, "sort() requires all elements to be INTEGER"},
+		{`export x = hasKey([], 1)`, "hasKey() first argument must be DICT"},
 	}
 	for i, tt := range tests {
 		intRes, intOut, err := captureRun(func() runResult { return runInterpreter(tt.input) })
@@ -368,3 +376,110 @@ func TestSemanticsParity_Errors(t *testing.T) {
 		}
 	}
 }
+
+func TestSemanticsParity_PrintErrorValue(t *testing.T) {
+	assertParity(t, `e = error("boom")
+export r = print(e, 1)`, map[string]struct {
+		typ     object.Type
+		inspect string
+	}{
+		"r": {object.NIL_OBJ, "nil"},
+	})
+}
+
+func TestSemanticsParity_Methods(t *testing.T) {
+	tests := []struct {
+		recv    object.Type
+		method  string
+		input   string
+		inspect string
+		wantErr string
+	}{
+		{object.ARRAY_OBJ, "append", `export r = [1, 2].append(3)`, "[1, 2, 3]", ""},
+		{object.ARRAY_OBJ, "count", `export r = [1, 2, 1].count(1)`, "2", ""},
+		{object.ARRAY_OBJ, "insert", `a = [1, 2]
+a.insert(-1, 3)
+export r = a`, "[1, 3, 2]", ""},
+		{object.ARRAY_OBJ, "insert", `export r = [1].insert("a", 2)`, "", "insert() index must be INTEGER, got: STRING"},
+		{object.ARRAY_OBJ, "len", `export r = [1, 2].len()`, "2", ""},
+		{object.ARRAY_OBJ, "pop", `a = [1, 2]
+a.pop()
+export r = a`, "[1]", ""},
+		{object.ARRAY_OBJ, "pop", `export r = [].pop()`, "", "pop from empty array"},
+		{object.ARRAY_OBJ, "remove", `a = [1, 2, 1]
+a.remove(1)
+export r = a`, "[2, 1]", ""},
+		{object.DICT_OBJ, "count", `export r = #{"a": 1}.count()`, "1", ""},
+		{object.DICT_OBJ, "get", `export r = #{"a": 1}.get("b", 2)`, "2", ""},
+		{object.DICT_OBJ, "keys", `export r = #{"b": 1, "a": 2}.keys()`, "[a, b]", ""},
+		{object.DICT_OBJ, "pop", `export r = #{"a": 1}.pop("b")`, "", "key not found"},
+		{object.DICT_OBJ, "remove", `d = #{"a": 1, "b": 2}
+d.remove("a")
+export r = d`, `#{"b": 2}`, ""},
+		{object.DICT_OBJ, "values", `export r = #{"b": 1, "a": 2}.values()`, "[2, 1]", ""},
+		{object.DICT_OBJ, "hasKey", `export r = #{"a": 1}.hasKey([])`, "", "unusable as dict key: ARRAY"},
+		{object.STRING_OBJ, "len", `export r = "héllo".len()`, "5", ""},
+		{object.STRING_OBJ, "strip", `export r = "  hi ".strip()`, "hi", ""},
+		{object.STRING_OBJ, "uppercase", `export r = "hi".uppercase()`, "HI", ""},
+		{object.STRING_OBJ, "lowercase", `export r = "HI".lowercase()`, "hi", ""},
+		{object.STRING_OBJ, "capitalize", `export r = "hELLO".capitalize()`, "Hello", ""},
+		{object.STRING_OBJ, "startswith", `export r = "hello".startswith(1)`, "", "startswith() prefix must be STRING"},
+		{object.STRING_OBJ, "endswith", `export r = "hello".endswith("lo")`, "true", ""},
+		{object.STRING_OBJ, "slice", `export r = "hello".slice(1, -1)`, "ell", ""},
+		{object.STRING_OBJ, "slice", `export r = "hello".slice("a")`, "", "slice low must be INTEGER, got: STRING"},
+		{object.STRING_OBJ, "casefold", `export r = "Straße".casefold()`, "strasse", ""},
+		{object.STRING_OBJ, "graphemes", `export r = "ab".graphemes()`, "[a, b]", ""},
+		{object.INTEGER_OBJ, "format", `export r = (-3).format(2)`, "-3.00", ""},
+		{object.FLOAT_OBJ, "format", `export r = 2.345.format(1)`, "2.3", ""},
+		{object.FLOAT_OBJ, "format", `export r = 2.5.format(-1)`, "", "format() decimals must be >= 0"},
+		{object.BOOLEAN_OBJ, "len", `export r = true.len()`, "", "type has no methods: BOOLEAN"},
+		{object.STRING_OBJ, "nope", `export r = "a".nope()`, "", "unknown method for STRING: nope"},
+		{object.ARRAY_OBJ, "get", `export r = [1].get(0)`, "", "get() receiver must be DICT"},
+		{object.ARRAY_OBJ, "len", `export r = [].len(1)`, "", "len() takes 0 arguments, got 1"},
+	}
+
+	covered := map[object.Type]map[string]bool{}
+	for i, tt := range tests {
+		if covered[tt.recv] == nil {
+			covered[tt.recv] = map[string]bool{}
+		}
+		covered[tt.recv][tt.method] = true
+
+		intRes, intOut, err := captureRun(func() runResult { return runInterpreter(tt.input) })
+		if err != nil {
+			t.Fatalf("tests[%d] interpreter capture error: %v", i, err)
+		}
+		vmRes, vmOut, err := captureRun(func() runResult { return runVM(tt.input) })
+		if err != nil {
+			t.Fatalf("tests[%d] vm capture error: %v", i, err)
+		}
+		if intOut != vmOut {
+			t.Fatalf("tests[%d] stdout mismatch: interpreter %q, vm %q", i, intOut, vmOut)
+		}
+		if intRes.errMsg != vmRes.errMsg {
+			t.Fatalf("tests[%d] error mismatch: interpreter %q, vm %q", i, intRes.errMsg, vmRes.errMsg)
+		}
+		if intRes.errMsg != tt.wantErr {
+			t.Fatalf("tests[%d] expected error %q, got %q", i, tt.wantErr, intRes.errMsg)
+		}
+		if tt.wantErr != "" {
+			continue
+		}
+		intVal, _ := exportValue(intRes.exports, "r")
+		vmVal, _ := exportValue(vmRes.exports, "r")
+		if intVal == nil || vmVal == nil {
+			t.Fatalf("tests[%d] missing export r", i)
+		}
+		if intVal.Inspect() != tt.inspect || vmVal.Inspect() != tt.inspect {
+			t.Fatalf("tests[%d] expected %q, got interpreter %q, vm %q", i, tt.inspect, intVal.Inspect(), vmVal.Inspect())
+		}
+	}
+
+	for _, typ := range []object.Type{object.ARRAY_OBJ, object.DICT_OBJ, object.STRING_OBJ, object.INTEGER_OBJ, object.FLOAT_OBJ} {
+		for _, name := range semantics.Methods(typ) {
+			if !covered[typ][name] {
+				t.Errorf("method %s.%s has no parity case", typ, name)
+			}
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package semantics
 
 import (
+	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	"welle/internal/object"
 )
@@ -275,3 +278,355 @@ func TestBitwiseErrors(t *testing.T) {
 		t.Fatalf("expected unary error %q, got %q", "unsupported operand type for ~: FLOAT", err.Error())
 	}
 }
+
+func TestCheckedInt(t *testing.T) {
+	tests := []struct {
+		op   string
+		a, b int64
+		want int64
+		ok   bool
+	}{
+		{"+", 1, 2, 3, true},
+		{"+", math.MaxInt64, 1, math.MinInt64, false},
+		{"+", math.MinInt64, -1, math.MaxInt64, false},
+		{"-", math.MinInt64, 1, math.MaxInt64, false},
+		{"-", 0, math.MinInt64, math.MinInt64, false},
+		{"-", -1, math.MinInt64, math.MaxInt64, true},
+		{"*", 3, -4, -12, true},
+		{"*", math.MaxInt64, 2, -2, false},
+		{"*", -1, math.MinInt64, math.MinInt64, false},
+		{"*", math.MinInt64, -1, math.MinInt64, false},
+		{"*", math.MinInt64, 1, math.MinInt64, true},
+		{"*", 0, math.MinInt64, 0, true},
+	}
+	for i, tt := range tests {
+		got, ok := CheckedInt(tt.op, tt.a, tt.b)
+		if got != tt.want || ok != tt.ok {
+			t.Fatalf("tests[%d] %d %s %d: expected (%d, %v), got (%d, %v)", i, tt.a, tt.op, tt.b, tt.want, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestFloorDivMod(t *testing.T) {
+	tests := []struct {
+		a, b     int64
+		div, mod int64
+	}{
+		{7, 2, 3, 1},
+		{-7, 2, -4, 1},
+		{7, -2, -4, -1},
+		{-7, -2, 3, -1},
+		{-6, 3, -2, 0},
+		{-7, 3, -3, 2},
+		{math.MinInt64, -1, math.MinInt64, 0},
+	}
+	for i, tt := range tests {
+		if got := FloorDiv(tt.a, tt.b); got != tt.div {
+			t.Fatalf("tests[%d] FloorDiv(%d, %d): expected %d, got %d", i, tt.a, tt.b, tt.div, got)
+		}
+		if got := FloorMod(tt.a, tt.b); got != tt.mod {
+			t.Fatalf("tests[%d] FloorMod(%d, %d): expected %d, got %d", i, tt.a, tt.b, tt.mod, got)
+		}
+	}
+}
+
+func TestRoundAndIntegralPart(t *testing.T) {
+	i := func(v int64) object.Object { return &object.Integer{Value: v} }
+	f := func(v float64) object.Object { return &object.Float{Value: v} }
+	tests := []struct {
+		args []object.Object
+		want string
+	}{
+		{[]object.Object{f(2.5)}, "3"},
+		{[]object.Object{f(-2.5)}, "-3"},
+		{[]object.Object{i(7)}, "7"},
+		{[]object.Object{f(3.14159), i(2)}, "3.14"},
+		{[]object.Object{f(2.675), i(2)}, "2.67"},
+		{[]object.Object{f(-2.675), i(2)}, "-2.67"},
+		{[]object.Object{f(1.005), i(2)}, "1"},
+		{[]object.Object{f(0.125), i(2)}, "0.13"},
+		{[]object.Object{f(-0.125), i(2)}, "-0.13"},
+		{[]object.Object{f(1.5), i(0)}, "2"},
+		{[]object.Object{f(1e300), i(5)}, "1e+300"},
+		{[]object.Object{f(5e-324), i(308)}, "0"},
+		{[]object.Object{f(1234.5), i(-2)}, "1200"},
+		{[]object.Object{i(1250), i(-2)}, "1300"},
+		{[]object.Object{i(-1249), i(-2)}, "-1200"},
+		{[]object.Object{i(42), i(3)}, "42"},
+	}
+	for n, tt := range tests {
+		got, err := Round(tt.args)
+		if err != nil {
+			t.Fatalf("tests[%d] unexpected error: %v", n, err)
+		}
+		if got.Inspect() != tt.want {
+			t.Fatalf("tests[%d] expected %s, got %s", n, tt.want, got.Inspect())
+		}
+	}
+	if _, err := Round([]object.Object{f(1e19)}); err == nil {
+		t.Fatalf("expected out of range error")
+	}
+	if _, err := Round([]object.Object{i(math.MaxInt64), i(-1)}); err == nil {
+		t.Fatalf("expected out of range error")
+	}
+
+	got, err := IntegralPart("ceil", math.Ceil, []object.Object{f(-0.5)})
+	if err != nil || got.Inspect() != "0" {
+		t.Fatalf("ceil(-0.5): got %v, %v", got, err)
+	}
+	if _, err := IntegralPart("floor", math.Floor, []object.Object{f(math.NaN())}); err == nil {
+		t.Fatalf("expected error for floor(NaN)")
+	}
+}
+
+func TestApproxEqual(t *testing.T) {
+	f := func(v float64) object.Object { return &object.Float{Value: v} }
+	tests := []struct {
+		a, b, eps float64
+		want      bool
+	}{
+		{0.1 + 0.2, 0.3, 1e-9, true},
+		{1, 1.1, 0.05, false},
+		{math.Inf(1), math.Inf(1), 0, true},
+		{math.Inf(1), math.Inf(-1), 1, false},
+		{math.NaN(), math.NaN(), 1, false},
+	}
+	for n, tt := range tests {
+		got, err := ApproxEqual([]object.Object{f(tt.a), f(tt.b), f(tt.eps)})
+		if err != nil || got != tt.want {
+			t.Fatalf("tests[%d] expected %v, got %v (%v)", n, tt.want, got, err)
+		}
+	}
+	if _, err := ApproxEqual([]object.Object{f(1), f(1), f(-1)}); err == nil {
+		t.Fatalf("expected error for negative eps")
+	}
+}
+
+func TestStats(t *testing.T) {
+	arr := func(vals ...object.Object) []object.Object {
+		return []object.Object{&object.Array{Elements: vals}}
+	}
+	i := func(v int64) object.Object { return &object.Integer{Value: v} }
+	f := func(v float64) object.Object { return &object.Float{Value: v} }
+
+	medians := []struct {
+		args []object.Object
+		want string
+	}{
+		{arr(i(3), i(1), i(2)), "2"},
+		{arr(i(4), i(1), i(3), i(2)), "2.5"},
+		{arr(i(-3), i(5)), "1"},
+		{arr(f(1.5), i(1)), "1.25"},
+	}
+	for n, tt := range medians {
+		got, err := Median(tt.args)
+		if err != nil || got.Inspect() != tt.want {
+			t.Fatalf("median tests[%d] expected %s, got %v (%v)", n, tt.want, got, err)
+		}
+	}
+	if got, err := Mode(arr(i(2), i(1), i(1), i(2), i(3))); err != nil || got.Inspect() != "2" {
+		t.Fatalf("mode: expected first of the tied values, got %v (%v)", got, err)
+	}
+	if v, err := Variance("variance", arr(i(2), i(4), i(4), i(4), i(5), i(5), i(7), i(9))); err != nil || v != 4 {
+		t.Fatalf("variance: expected 4, got %v (%v)", v, err)
+	}
+	if _, err := Variance("variance", append(arr(i(1)), &object.Boolean{Value: true})); err == nil {
+		t.Fatalf("expected error for a sample of one value")
+	}
+	if v, err := Percentile(append(arr(i(1), i(2), i(3), i(4)), i(90))); err != nil || math.Abs(v-3.7) > 1e-12 {
+		t.Fatalf("percentile: expected 3.7, got %v (%v)", v, err)
+	}
+
+	counts, edges, err := Histogram(append(arr(i(1), i(2), i(2), i(3), i(9), i(10)), i(3)))
+	if err != nil {
+		t.Fatalf("histogram: %v", err)
+	}
+	if fmt.Sprint(counts) != "[4 0 2]" || fmt.Sprint(edges) != "[1 4 7 10]" {
+		t.Fatalf("histogram: got %v %v", counts, edges)
+	}
+
+	errs := []struct {
+		err  error
+		want string
+	}{
+		{func() error { _, err := Median(arr()); return err }(), "median() arg is an empty sequence"},
+		{func() error { _, err := Mode(arr(&object.String{Value: "a"})); return err }(), "mode() requires all elements to be NUMBER"},
+		{func() error { _, err := Percentile(append(arr(i(1)), i(101))); return err }(), "percentile() p must be between 0 and 100"},
+		{func() error { _, _, err := Histogram(append(arr(i(1)), i(0))); return err }(), "histogram() expects a positive INTEGER bin count"},
+	}
+	for n, tt := range errs {
+		if tt.err == nil || tt.err.Error() != tt.want {
+			t.Fatalf("errs[%d] expected %q, got %v", n, tt.want, tt.err)
+		}
+	}
+}
+
+func TestStableSort(t *testing.T) {
+	pair := func(k int64, tag string) object.Object {
+		return &object.Tuple{Elements: []object.Object{&object.Integer{Value: k}, &object.String{Value: tag}}}
+	}
+	els := []object.Object{pair(2, "a"), pair(1, "b"), pair(2, "c"), pair(1, "d"), pair(0, "e"), pair(2, "f")}
+	byKey := func(a, b object.Object) (bool, error) {
+		return KeyLess(a.(*object.Tuple).Elements[0], b.(*object.Tuple).Elements[0])
+	}
+	got, err := StableSort(els, byKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s := (&object.Array{Elements: got}).Inspect(); s != "[(0, e), (1, b), (1, d), (2, a), (2, c), (2, f)]" {
+		t.Fatalf("unstable or wrong order: %s", s)
+	}
+	if s := (&object.Array{Elements: els}).Inspect(); s != "[(2, a), (1, b), (2, c), (1, d), (0, e), (2, f)]" {
+		t.Fatalf("input was modified: %s", s)
+	}
+
+	calls := 0
+	_, err = StableSort(els, func(a, b object.Object) (bool, error) {
+		calls++
+		return false, ErrSortStopped
+	})
+	if err != ErrSortStopped || calls != 1 {
+		t.Fatalf("expected the sort to stop at the first error, got %v after %d calls", err, calls)
+	}
+}
+
+func TestUnique(t *testing.T) {
+	els := []object.Object{
+		&object.Integer{Value: 3}, &object.Integer{Value: 1}, &object.Float{Value: 3},
+		&object.String{Value: "3"}, &object.Integer{Value: 1}, &object.Nil{}, &object.Nil{},
+		&object.Tuple{Elements: []object.Object{&object.Integer{Value: 1}}},
+		&object.Tuple{Elements: []object.Object{&object.Integer{Value: 1}}},
+	}
+	got, err := Unique([]object.Object{&object.Array{Elements: els}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Inspect() != "[3, 1, 3, nil, (1,)]" {
+		t.Fatalf("unexpected result: %s", got.Inspect())
+	}
+	arrs := []object.Object{&object.Array{}, &object.Array{}}
+	if _, err := Unique([]object.Object{&object.Array{Elements: arrs}}); err == nil {
+		t.Fatalf("expected an error comparing arrays")
+	}
+}
+
+func TestSeqCursorPullsLazily(t *testing.T) {
+	els := make([]object.Object, 100)
+	for i := range els {
+		els[i] = &object.Integer{Value: int64(i)}
+	}
+	s, err := NewSeq([]object.Object{&object.Array{Elements: els}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	double := &object.Builtin{}
+	odd := &object.Builtin{}
+	s = s.With(object.SeqStage{Op: object.SeqFilter, Fn: odd})
+	s = s.With(object.SeqStage{Op: object.SeqMap, Fn: double})
+	s = s.With(object.SeqStage{Op: object.SeqTake, N: 2})
+
+	calls := 0
+	call := func(fn object.Object, args ...object.Object) (object.Object, bool) {
+		calls++
+		n := args[0].(*object.Integer).Value
+		if fn == odd {
+			return &object.Boolean{Value: n%2 == 1}, true
+		}
+		return &object.Integer{Value: n * 2}, true
+	}
+	cur := NewSeqCursor(s)
+	var got []object.Object
+	for {
+		val, ok, err := cur.Next(call)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !ok {
+			break
+		}
+		got = append(got, val)
+	}
+	if s := (&object.Array{Elements: got}).Inspect(); s != "[2, 6]" {
+		t.Fatalf("unexpected result: %s", s)
+	}
+	// 0..3 are filtered and 1 and 3 mapped; nothing past 3 is read.
+	if calls != 6 {
+		t.Fatalf("expected 6 calls, got %d", calls)
+	}
+
+	cur = NewSeqCursor(s)
+	if _, _, err := cur.Next(func(object.Object, ...object.Object) (object.Object, bool) { return nil, false }); err != ErrSeqStopped {
+		t.Fatalf("expected ErrSeqStopped, got %v", err)
+	}
+}
+
+func TestStopwatchAndTimeIt(t *testing.T) {
+	base := time.Now()
+	var ticks []time.Duration
+	defer func(prev func() time.Time) { now = prev }(now)
+	now = func() time.Time {
+		d := ticks[0]
+		ticks = ticks[1:]
+		return base.Add(d)
+	}
+
+	ticks = []time.Duration{0, 5 * time.Millisecond, 7 * time.Millisecond, 7500 * time.Microsecond}
+	sw, err := NewStopwatch(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, name := range []string{"lap", "lap", "elapsed_ms"} {
+		res, _, err := CallMethod(sw, name, nil)
+		if err != nil {
+			t.Fatalf("%s(): %v", name, err)
+		}
+		got = append(got, res.Inspect())
+	}
+	if fmt.Sprint(got) != "[5 2 7.5]" {
+		t.Fatalf("lap, lap, elapsed_ms = %v", got)
+	}
+
+	ticks = []time.Duration{0, 4 * time.Millisecond, 10 * time.Millisecond, 12 * time.Millisecond}
+	runs := 0
+	res, ok, err := TimeIt(&object.Integer{Value: 2}, func() bool { runs++; return true })
+	if err != nil || !ok || runs != 2 || res.Inspect() != "(best: 2, avg: 3, runs: 2)" {
+		t.Fatalf("TimeIt = %v, %v, %v after %d runs", res, ok, err, runs)
+	}
+}
+
+func TestGeomSweepAndSegments(t *testing.T) {
+	box := geomShape{x: 0, y: 0, w: 10, h: 10}
+	wall := geomShape{x: 15, y: 2, w: 5, h: 20}
+	for _, tc := range []struct {
+		dx, dy float64
+		hit    bool
+		t      float64
+		n      vec
+	}{
+		{dx: 20, hit: true, t: 0.25, n: vec{-1, 0}},
+		{dx: 4},
+		{dx: 20, dy: -40},
+		{dx: 10, dy: 10, hit: true, t: 0.5, n: vec{-1, 0}},
+		{dx: -20},
+	} {
+		got, n, ok := sweepAABB(box, wall, tc.dx, tc.dy)
+		if ok != tc.hit || (ok && (got != tc.t || n != tc.n)) {
+			t.Errorf("sweep by (%v, %v) = %v %v %v, want %v %v %v", tc.dx, tc.dy, got, n, ok, tc.t, tc.n, tc.hit)
+		}
+	}
+	if got, n, ok := sweepAABB(box, geomShape{x: 5, y: 5, w: 1, h: 1}, 3, 3); !ok || got != 0 || n != (vec{}) {
+		t.Errorf("overlapping boxes: %v %v %v, want an immediate hit", got, n, ok)
+	}
+
+	if p, ok := segmentHit(vec{0, 0}, vec{4, 4}, vec{0, 4}, vec{4, 0}); !ok || p != (vec{2, 2}) {
+		t.Errorf("crossing diagonals: %v %v", p, ok)
+	}
+	if _, ok := segmentHit(vec{0, 0}, vec{1, 0}, vec{0, 1}, vec{1, 1}); ok {
+		t.Error("parallel segments should not hit")
+	}
+	square := []vec{{0, 0}, {4, 0}, {4, 4}, {0, 4}}
+	if !polygonContains(square, vec{2, 2}) || polygonContains(square, vec{5, 2}) {
+		t.Error("point in square")
+	}
+}
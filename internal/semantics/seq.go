@@ -0,0 +1,123 @@
+package semantics
+
+import (
+	"errors"
+	"fmt"
+
+	"welle/internal/object"
+)
+
+// ErrSeqStopped is returned by SeqCursor.Next when a stage's callback failed
+// and has already raised its own error.
+var ErrSeqStopped = errors.New("seq stopped")
+
+// NewSeq implements seq(array): a pipeline with no stages over array.
+func NewSeq(args []object.Object) (*object.Seq, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments: expected 1, got %d", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, fmt.Errorf("seq() expects ARRAY")
+	}
+	return &object.Seq{Source: arr}, nil
+}
+
+func methodSeqMap(recv object.Object, args []object.Object) (object.Object, error) {
+	return seqFnStage(recv, "map", object.SeqMap, args)
+}
+
+func methodSeqFilter(recv object.Object, args []object.Object) (object.Object, error) {
+	return seqFnStage(recv, "filter", object.SeqFilter, args)
+}
+
+func seqFnStage(recv object.Object, name string, op object.SeqOp, args []object.Object) (object.Object, error) {
+	if len(args) != 1 {
+		return nil, arityError(name, "1 argument", len(args))
+	}
+	switch args[0].(type) {
+	case *object.Function, *object.Closure, *object.Builtin, *object.Class, *object.BoundMethod:
+	default:
+		return nil, fmt.Errorf("%s() expects FUNCTION, got: %s", name, args[0].Type())
+	}
+	return recv.(*object.Seq).With(object.SeqStage{Op: op, Fn: args[0]}), nil
+}
+
+func methodSeqTake(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 1 {
+		return nil, arityError("take", "1 argument", len(args))
+	}
+	n, ok := args[0].(*object.Integer)
+	if !ok {
+		return nil, fmt.Errorf("take() count must be INTEGER, got: %s", args[0].Type())
+	}
+	if n.Value < 0 {
+		return nil, fmt.Errorf("take() count must not be negative")
+	}
+	return recv.(*object.Seq).With(object.SeqStage{Op: object.SeqTake, N: n.Value}), nil
+}
+
+// methodSeqToList stands in for to_list() in the method table; the backends
+// run it through builtins.CallHostMethod, since it calls back into Welle.
+func methodSeqToList(recv object.Object, args []object.Object) (object.Object, error) {
+	return nil, fmt.Errorf("to_list() is not directly callable")
+}
+
+// SeqCursor walks a Seq one element at a time. Each element goes through
+// every stage before the next one is read from the source, so no stage
+// builds an intermediate array.
+type SeqCursor struct {
+	seq   *object.Seq
+	next  int
+	taken []int64
+}
+
+func NewSeqCursor(s *object.Seq) *SeqCursor {
+	return &SeqCursor{seq: s, taken: make([]int64, len(s.Stages))}
+}
+
+// Next returns the pipeline's next element, or ok false once it is done.
+// call applies a map or filter function to an element; when it fails, Next
+// returns ErrSeqStopped. The source array is read as it is at each step.
+func (c *SeqCursor) Next(call func(fn object.Object, args ...object.Object) (object.Object, bool)) (object.Object, bool, error) {
+	for {
+		// A take stage that has let all its elements through ends the
+		// pipeline before another element is read.
+		for i, st := range c.seq.Stages {
+			if st.Op == object.SeqTake && c.taken[i] >= st.N {
+				return nil, false, nil
+			}
+		}
+		src := c.seq.Source.Elements
+		if c.next >= len(src) {
+			return nil, false, nil
+		}
+		val := src[c.next]
+		c.next++
+		kept := true
+		for i, st := range c.seq.Stages {
+			switch st.Op {
+			case object.SeqMap:
+				res, ok := call(st.Fn, val)
+				if !ok {
+					return nil, false, ErrSeqStopped
+				}
+				val = res
+			case object.SeqFilter:
+				res, ok := call(st.Fn, val)
+				if !ok {
+					return nil, false, ErrSeqStopped
+				}
+				kept = IsTruthy(res)
+			case object.SeqTake:
+				c.taken[i]++
+			}
+			if !kept {
+				break
+			}
+		}
+		if kept {
+			return val, true, nil
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package semantics
+
+import (
+	"fmt"
+
+	"welle/internal/object"
+)
+
+// SetOf builds the set of els, for #[...] literals and set(array).
+func SetOf(els []object.Object) (*object.Set, error) {
+	s := object.NewSet(len(els))
+	for _, el := range els {
+		if !s.Add(el) {
+			return nil, setElementError(el)
+		}
+	}
+	return s, nil
+}
+
+// NewSetFrom implements set(array?): an empty set, or the set of array's
+// elements.
+func NewSetFrom(args []object.Object) (*object.Set, error) {
+	if err := checkArgs(args, 0, 1); err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return object.NewSet(0), nil
+	}
+	switch v := args[0].(type) {
+	case *object.Array:
+		return SetOf(v.Elements)
+	case *object.Set:
+		return SetOf(object.SortedSetItems(v))
+	default:
+		return nil, fmt.Errorf("set() expects ARRAY or SET, got %s", args[0].Type())
+	}
+}
+
+func setElementError(el object.Object) error {
+	return fmt.Errorf("unusable as set element: %s", el.Type())
+}
+
+// setsEqual reports whether a and b hold the same items.
+func setsEqual(a, b *object.Set) bool {
+	if len(a.Items) != len(b.Items) {
+		return false
+	}
+	for hk := range a.Items {
+		if _, ok := b.Items[hk]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func methodSetAdd(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 1 {
+		return nil, arityError("add", "1 argument", len(args))
+	}
+	if !recv.(*object.Set).Add(args[0]) {
+		return nil, setElementError(args[0])
+	}
+	return &object.Nil{}, nil
+}
+
+func costSetAdd(object.Object) int64 {
+	return object.CostDictEntry()
+}
+
+func methodSetRemove(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 1 {
+		return nil, arityError("remove", "1 argument", len(args))
+	}
+	hk, ok := object.HashKeyOf(args[0])
+	if !ok {
+		return nil, setElementError(args[0])
+	}
+	s := recv.(*object.Set)
+	_, found := s.Items[hk]
+	delete(s.Items, hk)
+	return &object.Boolean{Value: found}, nil
+}
+
+func methodSetHas(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 1 {
+		return nil, arityError("has", "1 argument", len(args))
+	}
+	found, err := InOp(args[0], recv)
+	if err != nil {
+		return nil, err
+	}
+	return &object.Boolean{Value: found}, nil
+}
+
+func methodSetToList(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 0 {
+		return nil, arityError("to_list", "0 arguments", len(args))
+	}
+	return &object.Array{Elements: object.SortedSetItems(recv.(*object.Set))}, nil
+}
+
+func methodSetUnion(recv object.Object, args []object.Object) (object.Object, error) {
+	a, b, err := setOperands("union", recv, args)
+	if err != nil {
+		return nil, err
+	}
+	out := object.NewSet(len(a.Items) + len(b.Items))
+	for hk, item := range a.Items {
+		out.Items[hk] = item
+	}
+	for hk, item := range b.Items {
+		out.Items[hk] = item
+	}
+	return out, nil
+}
+
+func methodSetIntersect(recv object.Object, args []object.Object) (object.Object, error) {
+	a, b, err := setOperands("intersect", recv, args)
+	if err != nil {
+		return nil, err
+	}
+	out := object.NewSet(0)
+	for hk, item := range a.Items {
+		if _, ok := b.Items[hk]; ok {
+			out.Items[hk] = item
+		}
+	}
+	return out, nil
+}
+
+func methodSetDifference(recv object.Object, args []object.Object) (object.Object, error) {
+	a, b, err := setOperands("difference", recv, args)
+	if err != nil {
+		return nil, err
+	}
+	out := object.NewSet(0)
+	for hk, item := range a.Items {
+		if _, ok := b.Items[hk]; !ok {
+			out.Items[hk] = item
+		}
+	}
+	return out, nil
+}
+
+// setOperands checks the argument of a method combining two sets.
+func setOperands(name string, recv object.Object, args []object.Object) (*object.Set, *object.Set, error) {
+	if len(args) != 1 {
+		return nil, nil, arityError(name, "1 argument", len(args))
+	}
+	other, ok := args[0].(*object.Set)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s() expects SET, got: %s", name, args[0].Type())
+	}
+	return recv.(*object.Set), other, nil
+}
@@ -0,0 +1,198 @@
+package semantics
+
+import (
+	"fmt"
+
+	"welle/internal/object"
+)
+
+// SliceBounds normalizes optional slice bounds against a sequence of the given
+// length, returning the clamped start and stop for iteration with step.
+func SliceBounds(lowPtr *int64, highPtr *int64, stepVal int64, length int64) (int64, int64) {
+	norm := func(x, length int64) int64 {
+		if x < 0 {
+			return length + x
+		}
+		return x
+	}
+	clamp := func(x, lo, hi int64) int64 {
+		if x < lo {
+			return lo
+		}
+		if x > hi {
+			return hi
+		}
+		return x
+	}
+	if stepVal > 0 {
+		lo := int64(0)
+		hi := length
+		if lowPtr != nil {
+			lo = norm(*lowPtr, length)
+		}
+		if highPtr != nil {
+			hi = norm(*highPtr, length)
+		}
+		lo = clamp(lo, 0, length)
+		hi = clamp(hi, 0, length)
+		if lo > hi {
+			lo = hi
+		}
+		return lo, hi
+	}
+	lo := length - 1
+	hi := int64(-1)
+	if lowPtr != nil {
+		lo = norm(*lowPtr, length)
+	}
+	if highPtr != nil {
+		hi = norm(*highPtr, length)
+	}
+	lo = clamp(lo, -1, length-1)
+	hi = clamp(hi, -1, length-1)
+	if lo < hi {
+		lo = hi
+	}
+	return lo, hi
+}
+
+// SliceArgs checks the evaluated bounds of a slice and converts them to the
+// form SliceBounds takes. A nil or NIL bound was omitted; the step defaults
+// to 1.
+func SliceArgs(low, high, step object.Object) (lowPtr *int64, highPtr *int64, stepVal int64, err error) {
+	bound := func(o object.Object, label string) (*int64, error) {
+		if o == nil || o.Type() == object.NIL_OBJ {
+			return nil, nil
+		}
+		i, ok := o.(*object.Integer)
+		if !ok {
+			return nil, fmt.Errorf("slice %s must be INTEGER, got: %s", label, o.Type())
+		}
+		v := i.Value
+		return &v, nil
+	}
+	if lowPtr, err = bound(low, "low"); err != nil {
+		return nil, nil, 0, err
+	}
+	if highPtr, err = bound(high, "high"); err != nil {
+		return nil, nil, 0, err
+	}
+	s, err := bound(step, "step")
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	stepVal = 1
+	if s != nil {
+		if *s == 0 {
+			return nil, nil, 0, fmt.Errorf("slice step cannot be 0")
+		}
+		stepVal = *s
+	}
+	return lowPtr, highPtr, stepVal, nil
+}
+
+// sliceIndices lists the positions a slice selects from a sequence of length
+// n, in the order it visits them.
+func sliceIndices(lowPtr *int64, highPtr *int64, stepVal int64, n int) []int {
+	lo, hi := SliceBounds(lowPtr, highPtr, stepVal, int64(n))
+	var out []int
+	if stepVal > 0 {
+		for i := lo; i < hi; i += stepVal {
+			out = append(out, int(i))
+		}
+	} else {
+		for i := lo; i > hi; i += stepVal {
+			out = append(out, int(i))
+		}
+	}
+	return out
+}
+
+// AssignSlice returns the elements of an array after the slice assignment
+// `a[low:high:step] = value`, leaving elements unchanged. value must be an
+// ARRAY or TUPLE. With step 1 the selected run is replaced whole, so the
+// array grows or shrinks to fit value; a run that starts past the end, or
+// ends before it starts, is empty and value is inserted at its start. Any
+// other step writes value one for one over the elements the slice selects,
+// so it must have exactly that many.
+func AssignSlice(elements []object.Object, lowPtr *int64, highPtr *int64, stepVal int64, value object.Object) ([]object.Object, error) {
+	var vals []object.Object
+	switch v := value.(type) {
+	case *object.Array:
+		vals = v.Elements
+	case *object.Tuple:
+		vals = v.Elements
+	default:
+		return nil, fmt.Errorf("slice assignment value must be ARRAY or TUPLE, got: %s", value.Type())
+	}
+	if stepVal == 1 {
+		lo, hi := SliceBounds(lowPtr, highPtr, stepVal, int64(len(elements)))
+		out := make([]object.Object, 0, len(elements)-int(hi-lo)+len(vals))
+		out = append(out, elements[:lo]...)
+		out = append(out, vals...)
+		return append(out, elements[hi:]...), nil
+	}
+	idx := sliceIndices(lowPtr, highPtr, stepVal, len(elements))
+	if len(vals) != len(idx) {
+		return nil, fmt.Errorf("slice assignment with step %d expects %d elements, got %d", stepVal, len(idx), len(vals))
+	}
+	out := append([]object.Object(nil), elements...)
+	for k, i := range idx {
+		out[i] = vals[k]
+	}
+	return out, nil
+}
+
+// DeleteSlice returns elements without the ones `a[low:high:step]` selects,
+// leaving elements unchanged.
+func DeleteSlice(elements []object.Object, lowPtr *int64, highPtr *int64, stepVal int64) []object.Object {
+	drop := make(map[int]bool)
+	for _, i := range sliceIndices(lowPtr, highPtr, stepVal, len(elements)) {
+		drop[i] = true
+	}
+	out := make([]object.Object, 0, len(elements)-len(drop))
+	for i, el := range elements {
+		if !drop[i] {
+			out = append(out, el)
+		}
+	}
+	return out
+}
+
+// Delete carries out `del left[index]`: it removes one element of an array,
+// shifting the ones after it down, or one key of a dict.
+func Delete(left, index object.Object) error {
+	switch l := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return fmt.Errorf("array index must be INTEGER, got: %s", index.Type())
+		}
+		n := int64(len(l.Elements))
+		pos := i.Value
+		if pos < 0 {
+			pos += n
+		}
+		if pos < 0 || pos >= n {
+			return fmt.Errorf("index out of range")
+		}
+		l.Own()
+		l.Elements = append(l.Elements[:pos], l.Elements[pos+1:]...)
+		return nil
+	case *object.Dict:
+		if l.Frozen {
+			return fmt.Errorf(ReadOnlyExportsMessage)
+		}
+		hk, err := dictKey(index)
+		if err != nil {
+			return err
+		}
+		if _, ok := l.Pairs[hk]; !ok {
+			return fmt.Errorf("key not found: %s", index.Inspect())
+		}
+		delete(l.Pairs, hk)
+		return nil
+	default:
+		return fmt.Errorf("del not supported on type: %s", left.Type())
+	}
+}
@@ -0,0 +1,145 @@
+package semantics
+
+import (
+	"errors"
+	"fmt"
+
+	"welle/internal/object"
+)
+
+// ErrSortStopped is returned by a less function passed to StableSort to stop
+// the sort after it has already raised its own error, such as one thrown by a
+// user comparator.
+var ErrSortStopped = errors.New("sort stopped")
+
+// StableSort returns a sorted copy of els. less reports whether a must come
+// before b; elements it considers equal keep their original order. The
+// first error from less stops the sort and is returned.
+func StableSort(els []object.Object, less func(a, b object.Object) (bool, error)) ([]object.Object, error) {
+	out := make([]object.Object, len(els))
+	copy(out, els)
+	if len(out) < 2 {
+		return out, nil
+	}
+	buf := make([]object.Object, len(out))
+	if err := mergeSort(out, buf, less); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// mergeSort sorts s in place using buf as scratch space. It takes an
+// element from the right run only when it is strictly less than the left
+// one, which keeps the sort stable.
+func mergeSort(s, buf []object.Object, less func(a, b object.Object) (bool, error)) error {
+	if len(s) < 2 {
+		return nil
+	}
+	mid := len(s) / 2
+	if err := mergeSort(s[:mid], buf[:mid], less); err != nil {
+		return err
+	}
+	if err := mergeSort(s[mid:], buf[mid:], less); err != nil {
+		return err
+	}
+	// Already in order: nothing to merge.
+	if ok, err := less(s[mid], s[mid-1]); err != nil || !ok {
+		return err
+	}
+	copy(buf, s)
+	i, j, k := 0, mid, 0
+	for i < mid && j < len(s) {
+		before, err := less(buf[j], buf[i])
+		if err != nil {
+			return err
+		}
+		if before {
+			s[k] = buf[j]
+			j++
+		} else {
+			s[k] = buf[i]
+			i++
+		}
+		k++
+	}
+	copy(s[k:], buf[i:mid])
+	copy(s[k+mid-i:], buf[j:len(s)])
+	return nil
+}
+
+// ComparatorLess interprets what a sort() comparator returned for (a, b):
+// a negative INTEGER or true means a sorts before b.
+func ComparatorLess(res object.Object) (bool, error) {
+	switch v := res.(type) {
+	case *object.Integer:
+		return v.Value < 0, nil
+	case *object.Boolean:
+		return v.Value, nil
+	}
+	return false, fmt.Errorf("sort() comparator must return INTEGER or BOOLEAN, got %s", res.Type())
+}
+
+// KeyLess orders sort_by() keys with the "<" operator, so keys must all be
+// numbers or all be strings.
+func KeyLess(a, b object.Object) (bool, error) {
+	less, err := Compare("<", a, b)
+	if err != nil {
+		return false, fmt.Errorf("sort_by() keys are not comparable: %v", err)
+	}
+	return less, nil
+}
+
+// Unique implements unique(array): a new array holding the first occurrence
+// of each distinct element. Numbers compare by value (1 and 1.0 are the same
+// element), other values of the same type with "==", and values of different
+// types are always distinct.
+func Unique(args []object.Object) (*object.Array, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments: expected 1, got %d", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, fmt.Errorf("unique() expects ARRAY")
+	}
+	out := make([]object.Object, 0, len(arr.Elements))
+	// Integers, strings and booleans are bucketed by hash key so only
+	// colliding keys and non-hashable elements (floats, tuples, ...) are
+	// compared one by one.
+	seen := make(map[object.HashKey][]object.Object)
+	var others []object.Object
+	for _, el := range arr.Elements {
+		hk, hashable := object.HashKeyOf(el)
+		candidates := out
+		if hashable {
+			candidates = append(seen[hk][:len(seen[hk]):len(seen[hk])], others...)
+		}
+		dup := false
+		for _, prev := range candidates {
+			eq, err := sameElement(prev, el)
+			if err != nil {
+				return nil, fmt.Errorf("unique(): %v", err)
+			}
+			if eq {
+				dup = true
+				break
+			}
+		}
+		if dup {
+			continue
+		}
+		if hashable {
+			seen[hk] = append(seen[hk], el)
+		} else {
+			others = append(others, el)
+		}
+		out = append(out, el)
+	}
+	return &object.Array{Elements: out}, nil
+}
+
+func sameElement(a, b object.Object) (bool, error) {
+	if a.Type() != b.Type() && !(isNumeric(a) && isNumeric(b)) {
+		return false, nil
+	}
+	return Compare("==", a, b)
+}
@@ -0,0 +1,102 @@
+package semantics
+
+import (
+	"fmt"
+
+	"welle/internal/object"
+	"welle/internal/sqlite"
+)
+
+// SQLiteOpen implements sqlite_open(path), which returns the handle the
+// other sqlite_* builtins take.
+func SQLiteOpen(args []object.Object) (int64, error) {
+	path, err := textArg("sqlite_open", args)
+	if err != nil {
+		return 0, err
+	}
+	return sqlite.Open(path)
+}
+
+// SQLiteClose implements sqlite_close(db).
+func SQLiteClose(args []object.Object) error {
+	return sqliteHandleCall("sqlite_close", args, sqlite.Close)
+}
+
+// SQLiteBegin implements sqlite_begin(db).
+func SQLiteBegin(args []object.Object) error {
+	return sqliteHandleCall("sqlite_begin", args, sqlite.Begin)
+}
+
+// SQLiteCommit implements sqlite_commit(db).
+func SQLiteCommit(args []object.Object) error {
+	return sqliteHandleCall("sqlite_commit", args, sqlite.Commit)
+}
+
+// SQLiteRollback implements sqlite_rollback(db).
+func SQLiteRollback(args []object.Object) error {
+	return sqliteHandleCall("sqlite_rollback", args, sqlite.Rollback)
+}
+
+// SQLiteQuery implements sqlite_query(db, sql, params): an ARRAY of row
+// DICTs.
+func SQLiteQuery(args []object.Object) (*object.Array, error) {
+	db, query, err := sqliteStatementArgs("sqlite_query", args)
+	if err != nil {
+		return nil, err
+	}
+	return sqlite.Query(db, query, args[2])
+}
+
+// SQLiteExec implements sqlite_exec(db, sql, params), which returns
+// #{"changes": n, "last_id": id}.
+func SQLiteExec(args []object.Object) (*object.Dict, error) {
+	db, query, err := sqliteStatementArgs("sqlite_exec", args)
+	if err != nil {
+		return nil, err
+	}
+	changes, lastID, err := sqlite.Exec(db, query, args[2])
+	if err != nil {
+		return nil, err
+	}
+	out := &object.Dict{Pairs: map[object.HashKey]object.DictPair{}}
+	for key, n := range map[string]int64{"changes": changes, "last_id": lastID} {
+		k := &object.String{Value: key}
+		hk, _ := object.HashKeyOf(k)
+		out.Pairs[hk] = object.DictPair{Key: k, Value: &object.Integer{Value: n}}
+	}
+	return out, nil
+}
+
+func sqliteHandleCall(name string, args []object.Object, fn func(int64) error) error {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return err
+	}
+	db, err := sqliteHandle(name, args[0])
+	if err != nil {
+		return err
+	}
+	return fn(db)
+}
+
+func sqliteStatementArgs(name string, args []object.Object) (int64, string, error) {
+	if err := checkArgs(args, 3, 3); err != nil {
+		return 0, "", err
+	}
+	db, err := sqliteHandle(name, args[0])
+	if err != nil {
+		return 0, "", err
+	}
+	query, ok := args[1].(*object.String)
+	if !ok {
+		return 0, "", fmt.Errorf("%s() expects STRING sql, got %s", name, args[1].Type())
+	}
+	return db, query.Value, nil
+}
+
+func sqliteHandle(name string, obj object.Object) (int64, error) {
+	h, ok := obj.(*object.Integer)
+	if !ok {
+		return 0, fmt.Errorf("%s() expects INTEGER database handle, got %s", name, obj.Type())
+	}
+	return h.Value, nil
+}
@@ -0,0 +1,222 @@
+package semantics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"welle/internal/object"
+)
+
+// numbers returns the elements of the ARRAY argument of the std:stats
+// function name. Like mean(), an empty array and a non-NUMBER element are
+// errors.
+func numbers(name string, arg object.Object) ([]object.Object, error) {
+	arr, ok := arg.(*object.Array)
+	if !ok {
+		return nil, fmt.Errorf("%s() expects ARRAY", name)
+	}
+	if len(arr.Elements) == 0 {
+		return nil, fmt.Errorf("%s() arg is an empty sequence", name)
+	}
+	for _, el := range arr.Elements {
+		if !isNumeric(el) {
+			return nil, fmt.Errorf("%s() requires all elements to be NUMBER", name)
+		}
+	}
+	return arr.Elements, nil
+}
+
+func sortedFloats(els []object.Object) []float64 {
+	out := make([]float64, len(els))
+	for i, el := range els {
+		out[i] = toFloat(el)
+	}
+	sort.Float64s(out)
+	return out
+}
+
+func checkArgs(args []object.Object, min, max int) error {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return fmt.Errorf("wrong number of arguments: expected %d, got %d", min, len(args))
+		}
+		return fmt.Errorf("wrong number of arguments: expected %d or %d, got %d", min, max, len(args))
+	}
+	return nil
+}
+
+// Median implements stats.median(xs). An odd-length array gives its middle
+// element; an even-length one gives the mean of the two middle elements,
+// which is an INTEGER only when both are INTEGERs with an even sum.
+func Median(args []object.Object) (object.Object, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	els, err := numbers("median", args[0])
+	if err != nil {
+		return nil, err
+	}
+	sorted := make([]object.Object, len(els))
+	copy(sorted, els)
+	sort.SliceStable(sorted, func(i, j int) bool { return toFloat(sorted[i]) < toFloat(sorted[j]) })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid], nil
+	}
+	a, aok := sorted[mid-1].(*object.Integer)
+	b, bok := sorted[mid].(*object.Integer)
+	if aok && bok {
+		// Halve each side first so the sum cannot overflow.
+		if (a.Value+b.Value)%2 == 0 {
+			return &object.Integer{Value: a.Value/2 + b.Value/2 + (a.Value%2+b.Value%2)/2}, nil
+		}
+	}
+	return &object.Float{Value: (toFloat(sorted[mid-1]) + toFloat(sorted[mid])) / 2}, nil
+}
+
+// Mode implements stats.mode(xs): the most frequent value, comparing
+// numerically so 1 and 1.0 are the same value. Ties go to the value seen
+// first.
+func Mode(args []object.Object) (object.Object, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	els, err := numbers("mode", args[0])
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[float64]int, len(els))
+	most := 0
+	for _, el := range els {
+		f := toFloat(el)
+		counts[f]++
+		if counts[f] > most {
+			most = counts[f]
+		}
+	}
+	for _, el := range els {
+		if counts[toFloat(el)] == most {
+			return el, nil
+		}
+	}
+	return els[0], nil
+}
+
+// Variance implements stats.variance(xs, sample?). The population variance
+// divides by n; with sample set it divides by n - 1 and needs at least two
+// values.
+func Variance(name string, args []object.Object) (float64, error) {
+	if err := checkArgs(args, 1, 2); err != nil {
+		return 0, err
+	}
+	els, err := numbers(name, args[0])
+	if err != nil {
+		return 0, err
+	}
+	sample := false
+	if len(args) == 2 {
+		b, ok := args[1].(*object.Boolean)
+		if !ok {
+			return 0, fmt.Errorf("%s() expects BOOLEAN sample flag", name)
+		}
+		sample = b.Value
+	}
+	n := float64(len(els))
+	if sample {
+		if len(els) < 2 {
+			return 0, fmt.Errorf("%s() needs at least 2 values for a sample", name)
+		}
+		n--
+	}
+	mean := 0.0
+	for _, el := range els {
+		mean += toFloat(el)
+	}
+	mean /= float64(len(els))
+	ss := 0.0
+	for _, el := range els {
+		d := toFloat(el) - mean
+		ss += d * d
+	}
+	return ss / n, nil
+}
+
+// Percentile implements stats.percentile(xs, p) for p in [0, 100], using
+// linear interpolation between the closest ranks. The result is a FLOAT.
+func Percentile(args []object.Object) (float64, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return 0, err
+	}
+	els, err := numbers("percentile", args[0])
+	if err != nil {
+		return 0, err
+	}
+	if !isNumeric(args[1]) {
+		return 0, fmt.Errorf("percentile() expects NUMBER p")
+	}
+	p := toFloat(args[1])
+	if !(p >= 0 && p <= 100) {
+		return 0, fmt.Errorf("percentile() p must be between 0 and 100")
+	}
+	sorted := sortedFloats(els)
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo)), nil
+}
+
+// Histogram implements stats.histogram(xs, bins, lo?, hi?). The range
+// [lo, hi] defaults to the smallest and largest values and is split into
+// bins equal-width buckets; the last bucket includes hi and values outside
+// the range are not counted. It returns the bins counts and the bins + 1
+// bucket edges.
+func Histogram(args []object.Object) (counts []int64, edges []float64, err error) {
+	if len(args) != 2 && len(args) != 4 {
+		return nil, nil, fmt.Errorf("wrong number of arguments: expected 2 or 4, got %d", len(args))
+	}
+	els, err := numbers("histogram", args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	binsObj, ok := args[1].(*object.Integer)
+	if !ok || binsObj.Value <= 0 {
+		return nil, nil, fmt.Errorf("histogram() expects a positive INTEGER bin count")
+	}
+	if binsObj.Value > 1<<20 {
+		return nil, nil, fmt.Errorf("histogram() bin count is too large")
+	}
+	bins := int(binsObj.Value)
+	sorted := sortedFloats(els)
+	lo, hi := sorted[0], sorted[len(sorted)-1]
+	if len(args) == 4 {
+		if !isNumeric(args[2]) || !isNumeric(args[3]) {
+			return nil, nil, fmt.Errorf("histogram() expects NUMBER range bounds")
+		}
+		lo, hi = toFloat(args[2]), toFloat(args[3])
+		if !(lo < hi) {
+			return nil, nil, fmt.Errorf("histogram() range must have lo < hi")
+		}
+	}
+	edges = make([]float64, bins+1)
+	width := (hi - lo) / float64(bins)
+	for i := range edges {
+		edges[i] = lo + width*float64(i)
+	}
+	edges[bins] = hi
+	counts = make([]int64, bins)
+	for _, v := range sorted {
+		if v < lo || v > hi {
+			continue
+		}
+		i := bins - 1
+		if width > 0 {
+			i = int((v - lo) / width)
+			if i >= bins {
+				i = bins - 1
+			}
+		}
+		counts[i]++
+	}
+	return counts, edges, nil
+}
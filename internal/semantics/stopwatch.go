@@ -0,0 +1,78 @@
+package semantics
+
+import (
+	"fmt"
+	"time"
+
+	"welle/internal/object"
+)
+
+// now reads the clock behind stopwatch() and time_it(). time.Now includes a
+// monotonic reading, so differences between two calls never go backwards.
+var now = time.Now
+
+// NewStopwatch implements stopwatch(): a running stopwatch started now.
+func NewStopwatch(args []object.Object) (*object.Stopwatch, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments: expected 0, got %d", len(args))
+	}
+	t := now()
+	return &object.Stopwatch{Start: t, Lap: t}, nil
+}
+
+func methodStopwatchElapsed(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("elapsed_ms() takes 0 arguments, got %d", len(args))
+	}
+	sw := recv.(*object.Stopwatch)
+	return &object.Float{Value: millisOf(now().Sub(sw.Start))}, nil
+}
+
+// methodStopwatchLap returns the time since the last lap (or the start) and
+// begins a new lap.
+func methodStopwatchLap(recv object.Object, args []object.Object) (object.Object, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("lap() takes 0 arguments, got %d", len(args))
+	}
+	sw := recv.(*object.Stopwatch)
+	t := now()
+	lap := t.Sub(sw.Lap)
+	sw.Lap = t
+	return &object.Float{Value: millisOf(lap)}, nil
+}
+
+var timeItNames = []string{"best", "avg", "runs"}
+
+// TimeIt implements time_it(fn, n) once the backend has checked fn: it
+// runs call n times and returns the named tuple (best: ms, avg: ms, runs: n).
+// ok is false as soon as a run fails.
+func TimeIt(n object.Object, call func() bool) (res object.Object, ok bool, err error) {
+	runs, isInt := n.(*object.Integer)
+	if !isInt || runs.Value < 1 {
+		return nil, false, fmt.Errorf("time_it() runs must be a positive INTEGER")
+	}
+	var best, total time.Duration
+	for i := int64(0); i < runs.Value; i++ {
+		start := now()
+		if !call() {
+			return nil, false, nil
+		}
+		d := now().Sub(start)
+		if i == 0 || d < best {
+			best = d
+		}
+		total += d
+	}
+	return &object.Tuple{
+		Elements: []object.Object{
+			&object.Float{Value: millisOf(best)},
+			&object.Float{Value: millisOf(total) / float64(runs.Value)},
+			&object.Integer{Value: runs.Value},
+		},
+		Names: timeItNames,
+	}, true, nil
+}
+
+func millisOf(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
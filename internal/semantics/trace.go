@@ -0,0 +1,56 @@
+package semantics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"welle/internal/object"
+)
+
+// TraceArg validates trace(on) and returns on.
+func TraceArg(args []object.Object) (bool, error) {
+	if len(args) != 1 {
+		return false, fmt.Errorf("wrong number of arguments: expected 1, got %d", len(args))
+	}
+	on, ok := args[0].(*object.Boolean)
+	if !ok {
+		return false, fmt.Errorf("trace() expects BOOLEAN, got %s", args[0].Type())
+	}
+	return on.Value, nil
+}
+
+// maxParamText is how much of each parameter value FormatParams writes.
+const maxParamText = 40
+
+// FormatParams writes a frame's parameters for a stack trace with
+// -trace-locals, as `a = 1, b = "s"`. Strings are quoted and every value
+// is kept to one line of at most maxParamText characters, so deep or long
+// arguments do not bury the trace.
+func FormatParams(names []string, values []object.Object) string {
+	parts := make([]string, 0, len(names))
+	for i, name := range names {
+		if i >= len(values) || name == "" {
+			break
+		}
+		parts = append(parts, name+" = "+paramText(values[i]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func paramText(v object.Object) string {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		s = "nil"
+	case *object.String:
+		s = strconv.Quote(v.Value)
+	default:
+		s = object.InspectWith(v, object.InspectOptions{MaxDepth: 2, MaxLen: 5})
+		s = strings.Join(strings.Fields(s), " ")
+	}
+	if r := []rune(s); len(r) > maxParamText {
+		s = string(r[:maxParamText-3]) + "..."
+	}
+	return s
+}
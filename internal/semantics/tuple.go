@@ -0,0 +1,46 @@
+package semantics
+
+import (
+	"fmt"
+
+	"welle/internal/object"
+)
+
+// sameNames reports whether two tuples have the same field names, in the
+// same order. Plain tuples have none.
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// UnpackFields returns the fields of the named tuple val called names, in
+// that order, for (x: px, y: py) = val.
+func UnpackFields(val object.Object, names []string) ([]object.Object, error) {
+	t, ok := val.(*object.Tuple)
+	if !ok || t.Names == nil {
+		return nil, fmt.Errorf("unpack by name expects named tuple, got %s", describeUnpacked(val))
+	}
+	out := make([]object.Object, len(names))
+	for i, name := range names {
+		v, ok := t.GetMember(name)
+		if !ok {
+			return nil, fmt.Errorf("named tuple has no field: %s", name)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func describeUnpacked(val object.Object) string {
+	if t, ok := val.(*object.Tuple); ok && t.Names == nil {
+		return "plain TUPLE"
+	}
+	return string(val.Type())
+}
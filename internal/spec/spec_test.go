@@ -4,15 +4,23 @@ import (
 	"testing"
 
 	"welle/internal/spectest"
+
+	// The SQLite driver cmd/welle links, for the std:sqlite cases.
+	_ "modernc.org/sqlite"
 )
 
 type specCase struct {
-	name      string
-	source    string
-	files     map[string]string
-	entry     string
-	maxMemory int64
-	expect    map[spectest.Mode]spectest.Expectation
+	name         string
+	source       string
+	files        map[string]string
+	entry        string
+	prelude      string
+	features     []string
+	edition      string
+	maxMemory    int64
+	maxSteps     int64
+	maxRecursion int
+	expect       map[spectest.Mode]spectest.Expectation
 }
 
 func TestSpecBaseline(t *testing.T) {
@@ -489,6 +497,62 @@ func TestSpecBaseline(t *testing.T) {
 				Stdout: "0\n1\n3\n",
 			}),
 		},
+		{
+			name: "switch_constant_cases",
+			source: "func name(x) {\n" +
+				"  switch (x) {\n" +
+				"    case 1 { return \"one\" }\n" +
+				"    case 2, 3 { return \"two\" }\n" +
+				"    case -4 { return \"minus\" }\n" +
+				"    case 5 { return \"five\" }\n" +
+				"    case 2 { return \"dup\" }\n" +
+				"    default { return \"other\" }\n" +
+				"  }\n" +
+				"}\n" +
+				"for v in [1, 3, -4, 5, 6, 1.0] { print(name(v)) }\n" +
+				"print(name(\"1\"))\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout:      "one\ntwo\nminus\nfive\nother\none\n",
+				ErrContains: "type mismatch: STRING == INTEGER",
+			}),
+		},
+		{
+			name: "match_constant_cases",
+			source: "func kind(s) {\n" +
+				"  return match (s) { case \"a\" { 1 } case \"b\" { 2 } case \"c\", \"d\" { 3 } default { 0 } }\n" +
+				"}\n" +
+				"print(kind(\"a\"), kind(\"d\"), kind(\"z\"))\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "1 3 0\n",
+			}),
+		},
+		{
+			name: "match_patterns",
+			source: "func describe(v) {\n" +
+				"  return match (v) {\n" +
+				"    case (0, 0) { \"origin\" }\n" +
+				"    case (x, 0), (0, x) { \"axis \" + str(x) }\n" +
+				"    case (x, y) if x > y { \"below \" + str(x - y) }\n" +
+				"    case (_, y) { \"above \" + str(y) }\n" +
+				"    case [] { \"empty\" }\n" +
+				"    case [only] { \"one \" + str(only) }\n" +
+				"    case [first, ...mid, last] { str(first) + str(mid) + str(last) }\n" +
+				"    case #{\"type\": \"user\", name} { \"user \" + name }\n" +
+				"    case #{\"at\": (px, [py, _])} { str(px) + \"/\" + str(py) }\n" +
+				"    default { \"other\" }\n" +
+				"  }\n" +
+				"}\n" +
+				"for (v in [(0, 0), (3, 0), (0, 4), (5, 2), (1, 2), [], [7], [1, 2], [1, 2, 3, 4]]) { print(describe(v)) }\n" +
+				"print(describe(#{\"type\": \"user\", \"name\": \"ana\", \"age\": 30}))\n" +
+				"print(describe(#{\"at\": (1, [2, 3])}), describe(#{\"at\": (1, [2])}), describe(\"0\"))\n" +
+				"x = \"kept\"\n" +
+				"print(match ((1, 2)) { case (x, 3) { x } default { x } })\n" +
+				"print(match ([1, 2]) { case [x, ...rest] if x > 5 { 0 } case [x, ...rest] { rest } }, x)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "origin\naxis 3\naxis 4\nbelow 3\nabove 2\nempty\none 7\n1[]2\n1[2, 3]4\n" +
+					"user ana\n1/2 other other\nkept\n[2] 1\n",
+			}),
+		},
 		{
 			name: "pass_statement_noop",
 			source: "x = 1\n" +
@@ -1044,6 +1108,21 @@ func TestSpecBaseline(t *testing.T) {
 				Stdout: "1\n1\n2\n",
 			}),
 		},
+		{
+			name: "closure_capture_rebinding",
+			source: "func make(n) {\n" +
+				"  base = n * 10\n" +
+				"  late = 1\n" +
+				"  get = func() { return [n, base, late] }\n" +
+				"  late = 2\n" +
+				"  return func() { return func() { return get() } }\n" +
+				"}\n" +
+				"print(make(1)()())\n" +
+				"print(make(2)()())\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "[1, 10, 2]\n[2, 20, 2]\n",
+			}),
+		},
 		{
 			name:   "func_literal_immediate_invocation",
 			source: "print((func(x) { return x * 2 })(21))\n",
@@ -1068,6 +1147,75 @@ func TestSpecBaseline(t *testing.T) {
 				Stdout: "123\n",
 			}),
 		},
+		{
+			name: "catch_filters",
+			source: "import \"std:errors\" as errors\n" +
+				"func fetch(kind) {\n" +
+				"  if (kind == 1) { throw error(\"no such user\", \"E_NOUSER\", #{\"id\": 7}) }\n" +
+				"  if (kind == 2) { return [1][5] }\n" +
+				"  throw \"plain\"\n" +
+				"}\n" +
+				"for k in [1, 2, 3] {\n" +
+				"  try {\n" +
+				"    try {\n" +
+				"      try { fetch(k) } catch (e: \"E_NOUSER\") { print(\"user\", e.code, e.data[\"id\"], e) } finally { print(\"finally\", k) }\n" +
+				"    } catch (e: [errors.INDEX_OUT_OF_RANGE, errors.KEY_NOT_FOUND]) { print(\"range\", errors.name(e), e.data) }\n" +
+				"  } catch (e) { print(\"other\", e.message, e.code) }\n" +
+				"}\n" +
+				"try { throw error(\"odd\", 5) } catch (e: (1, 3, 5)) { print(\"odd\", error_code(e)) }\n" +
+				"try { throw error(\"x\", \"E_X\") } catch (e: [][0]) { print(\"unreachable\") }\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "user E_NOUSER 7 error(E_NOUSER): no such user\n" +
+					"finally 1\n" +
+					"finally 2\n" +
+					"range INDEX_OUT_OF_RANGE #{}\n" +
+					"finally 3\n" +
+					"other plain 0\n" +
+					"odd 5\n",
+				ErrContains: "index out of range",
+			}),
+		},
+		{
+			name: "finally_runs_before_outer_catch",
+			source: "order = 0\n" +
+				"try {\n" +
+				"  try { throw \"a\" } catch (e) { order = order * 10 + 1; throw \"b\" } finally { order = order * 10 + 2 }\n" +
+				"} catch (e) { order = order * 10 + 3; print(e.message) }\n" +
+				"print(order)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "b\n123\n",
+			}),
+		},
+		{
+			name: "destructure_inside_try",
+			source: "p = (1, 2)\n" +
+				"try {\n" +
+				"  (a, b) = p\n" +
+				"} catch (e) {\n" +
+				"  print(\"unreachable\")\n" +
+				"}\n" +
+				"print(a + b)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "3\n",
+			}),
+		},
+		{
+			name: "destructure_swap_in_loop",
+			source: "func fib(n) {\n" +
+				"  a = 0\n" +
+				"  b = 1\n" +
+				"  i = 0\n" +
+				"  while (i < n) {\n" +
+				"    (a, b) = (b, a + b)\n" +
+				"    i = i + 1\n" +
+				"  }\n" +
+				"  return a\n" +
+				"}\n" +
+				"print(fib(10), fib(30))\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "55 832040\n",
+			}),
+		},
 		{
 			name: "finally_always_runs",
 			source: "order = 0\n" +
@@ -1090,6 +1238,72 @@ func TestSpecBaseline(t *testing.T) {
 				Stdout: "213\n",
 			}),
 		},
+		{
+			name: "return_from_try_leaves_no_handler",
+			source: "func f() { try { return 1 } catch (e) { print(\"stale\") } }\n" +
+				"func g() { try { return 2 } finally { print(\"g finally\") } }\n" +
+				"print(f(), g())\n" +
+				"try { throw \"inner\" } catch (e) { print(\"caught\", e.message) }\n" +
+				"throw \"bad\"\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout:      "g finally\n1 2\ncaught inner\n",
+				ErrContains: "bad",
+			}),
+		},
+		{
+			name: "finally_runs_on_return_break_continue",
+			source: "func a() { try { return 1 } finally { print(\"fin a\") } }\n" +
+				"func b() { try { throw \"x\" } catch (e) { return 2 } finally { print(\"fin b\") } }\n" +
+				"func c() { try { try { return 3 } finally { print(\"inner\") } } finally { print(\"outer\") } }\n" +
+				"func d() { defer print(\"defer d\"); for (i in [1]) { try { return 4 } finally { print(\"fin d\") } } }\n" +
+				"func k() { try { return 5 } finally { throw \"from finally\" } }\n" +
+				"print(a(), b(), c(), d())\n" +
+				"try { k() } catch (e) { print(\"caught\", e.message) }\n" +
+				"for (i in [1, 2]) { try { if (i == 1) { continue }\n print(\"body\", i) } finally { print(\"fin\", i) } }\n" +
+				"while (true) { try { break } finally { print(\"fin while\") } }\n" +
+				"switch (1) { case 1 { try { break } finally { print(\"fin switch\") } } }\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "fin a\nfin b\ninner\nouter\nfin d\ndefer d\n1 2 3 4\n" +
+					"caught from finally\n" +
+					"fin 1\nbody 2\nfin 2\n" +
+					"fin while\nfin switch\n",
+			}),
+		},
+		{
+			name: "break_from_try_leaves_no_handler",
+			source: "while (true) { try { break } catch (e) { print(\"stale\", e.message) } }\n" +
+				"x = [1][3]\n",
+			maxSteps: 100000,
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				ErrContains: "index out of range",
+			}),
+		},
+		{
+			name: "continue_from_try_leaves_no_handler",
+			source: "func f() {\n" +
+				"  for (i in [1, 2]) { try { if (i == 1) { continue } } catch (e) { print(\"stale\", e.message) } }\n" +
+				"  throw \"later\"\n" +
+				"}\n" +
+				"try { f() } catch (e) { print(\"caught\", e.message) }\n",
+			maxSteps: 100000,
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "caught later\n",
+			}),
+		},
+		{
+			name: "finally_inside_finally_keeps_pending_error",
+			source: "func cleanup() { try { print(\"closing\") } finally { print(\"closed\") }\n print(\"cleanup done\") }\n" +
+				"try {\n" +
+				"  try { throw \"a\" } finally {\n" +
+				"    cleanup()\n" +
+				"    try { x = 1 } finally { print(\"inner\") }\n" +
+				"    print(\"after\")\n" +
+				"  }\n" +
+				"} catch (e) { print(\"caught\", e.message) }\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "closing\nclosed\ncleanup done\ninner\nafter\ncaught a\n",
+			}),
+		},
 		{
 			name: "module_import_std_and_aliasing",
 			source: "import \"std:math\" as math\n" +
@@ -1100,6 +1314,245 @@ func TestSpecBaseline(t *testing.T) {
 				Stdout: "5\n9\n",
 			}),
 		},
+		{
+			name: "module_exports_are_read_only",
+			files: map[string]string{
+				"mod.wll": "export x = 42\n" +
+					"export config = #{\"debug\": false}\n" +
+					"export func get() { return x }\n",
+				"other.wll": "import \"./mod.wll\" as m\n" +
+					"export func seen() { return m.x }\n",
+			},
+			source: "import \"./mod.wll\" as m\n" +
+				"import \"./other.wll\" as o\n" +
+				"try { m.x = 5 } catch (e) { print(e.message) }\n" +
+				"try { m[\"x\"] = 5 } catch (e) { print(e.message) }\n" +
+				"try { m.x += 1 } catch (e) { print(e.message) }\n" +
+				"try { m.extra = 1 } catch (e) { print(e.message) }\n" +
+				"try { m |= #{\"x\": 5} } catch (e) { print(e.message) }\n" +
+				"try { pop(m, \"x\") } catch (e) { print(e.message) }\n" +
+				"try { m.remove(\"x\") } catch (e) { print(e.message) }\n" +
+				"print(m.x, m.get(), o.seen(), keys(m))\n" +
+				"m.config.debug = true\n" +
+				"print(m.config)\n" +
+				"copy = #{}\n" +
+				"copy |= m\n" +
+				"copy.x = 2\n" +
+				"print(copy.x, m.x)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "module exports are read-only\n" +
+					"module exports are read-only\n" +
+					"module exports are read-only\n" +
+					"module exports are read-only\n" +
+					"module exports are read-only\n" +
+					"module exports are read-only\n" +
+					"module exports are read-only\n" +
+					"42 42 42 [config, get, x]\n" +
+					"#{\"debug\": true}\n" +
+					"2 42\n",
+			}),
+		},
+		{
+			name:    "project_prelude",
+			prelude: "prelude.wll",
+			files: map[string]string{
+				"prelude.wll": "export VERSION = \"1.0\"\n" +
+					"export func double(x) { return x * 2 }\n" +
+					"export func shout(s) { return s.uppercase() + \"!\" }\n",
+				"lib/util.wll": "export func quad(x) { return double(double(x)) }\n",
+				"raw.wll": "// welle:no-prelude\n" +
+					"export func names() { return dir() }\n" +
+					"export top = dir()\n",
+			},
+			source: "import \"./lib/util.wll\" as util\n" +
+				"import \"./raw.wll\" as raw\n" +
+				"func shout(s) { return s + \"?\" }\n" +
+				"print(VERSION, double(4), util.quad(3), shout(\"hey\"))\n" +
+				"print(\"double\" in raw.top, \"names\" in raw.top)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "1.0 8 12 hey?\n" +
+					"false true\n",
+			}),
+		},
+		{
+			name:     "build_features",
+			features: []string{"debug"},
+			files: map[string]string{
+				"log.wll": "export func log(msg) {\n" +
+					"  if (BUILD.debug) { return \"debug: \" + msg }\n" +
+					"  return msg\n" +
+					"}\n",
+			},
+			source: "import \"./log.wll\" as l\n" +
+				"if (BUILD.gfx and not BUILD.debug) {\n" +
+				"  import \"./missing.wll\" as gfx\n" +
+				"} else {\n" +
+				"  print(\"headless\")\n" +
+				"}\n" +
+				"print(BUILD.debug, BUILD.gfx, l.log(\"hi\"))\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "headless\n" +
+					"true false debug: hi\n",
+			}),
+		},
+		{
+			name:     "build_shadowed_by_binding",
+			features: []string{"gfx"},
+			source: "func param(BUILD) { return BUILD.gfx }\n" +
+				"print(BUILD.gfx, param(#{\"gfx\": \"dict\"}))\n" +
+				"BUILD := #{\"version\": 3}\n" +
+				"print(BUILD.version)\n" +
+				"if (BUILD.gfx) { print(\"flag\") }\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "true dict\n" +
+					"3\n",
+				ErrContains: "unknown member: gfx",
+			}),
+		},
+		{
+			name:    "edition_from_manifest",
+			edition: "0.1",
+			files: map[string]string{
+				"new.wll": "#welle 0.2\n" +
+					"export class Point { x }\n",
+				"old.wll": "export class Point { x }\n",
+			},
+			source: "import \"./new.wll\" as n\n" +
+				"print(n.Point(2).x)\n" +
+				"import \"./old.wll\" as o\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout:      "2\n",
+				ErrContains: "class declarations: this feature requires edition 0.2, but the file is edition 0.1",
+			}),
+		},
+		{
+			name: "classes_and_methods",
+			files: map[string]string{
+				"shapes.wll": "export class Point {\n" +
+					"  x = 0\n" +
+					"  y = 0\n" +
+					"  func add(o) { return Point(self.x + o.x, self.y + o.y) }\n" +
+					"  func move(dx, dy = 0) {\n" +
+					"    self.x += dx\n" +
+					"    self.y += dy\n" +
+					"    return self\n" +
+					"  }\n" +
+					"}\n",
+			},
+			source: "from \"./shapes.wll\" import Point\n" +
+				"class Counter {\n" +
+				"  count\n" +
+				"  step = 1\n" +
+				"  func init(start, step = 1) {\n" +
+				"    self.count = start\n" +
+				"    self.step = step\n" +
+				"    return 99\n" +
+				"  }\n" +
+				"  func tick() { self.count += self.step\n return self.count }\n" +
+				"}\n" +
+				"p = Point(1, 2).add(Point(3))\n" +
+				"print(p, Point())\n" +
+				"print(p.move(1).x, p)\n" +
+				"print(p == Point(5, 2), p != Point(5, 2), p == Point(5, 3))\n" +
+				"c = Counter(10, 5)\n" +
+				"tick = c.tick\n" +
+				"print(tick(), tick(), c.count, Counter(1).step)\n" +
+				"print(map(func(q) { return q.x }, [Point(7), Point(8)]), Point, tick)\n" +
+				"try { p.z = 1 } catch (e) { print(e.message) }\n" +
+				"try { p.jump() } catch (e) { print(e.message) }\n" +
+				"try { Point(1, 2, 3) } catch (e) { print(e.message) }\n" +
+				"p.move()\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "Point(x: 4, y: 2) Point(x: 0, y: 0)\n" +
+					"5 Point(x: 5, y: 2)\n" +
+					"true false false\n" +
+					"15 20 20 1\n" +
+					"[7, 8] <class Point> <method Counter.tick>\n" +
+					"Point has no field z\n" +
+					"Point has no method jump\n" +
+					"wrong number of arguments: expected 0 to 2, got 3\n",
+				ErrContains: "wrong number of arguments: expected 1 or 2, got 0",
+			}),
+		},
+		{
+			name: "instance_equality_with_array_fields",
+			source: "class Bag { name; items }\n" +
+				"shared = [1, 2]\n" +
+				"a = Bag(\"a\", shared)\n" +
+				"print(a == a, a != a)\n" +
+				"print(a == Bag(\"a\", shared), a == Bag(\"a\", [1, 2]), a != Bag(\"a\", [1, 2]))\n" +
+				"print(Bag(\"a\", #{}) == Bag(\"a\", #{}), Bag(1, nil) == Bag(\"1\", nil))\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "true false\n" +
+					"true false true\n" +
+					"false false\n",
+			}),
+		},
+		{
+			name: "generators",
+			source: "func count(n, step = 1) {\n" +
+				"  i = 0\n" +
+				"  while (i < n) {\n" +
+				"    yield i\n" +
+				"    i += step\n" +
+				"  }\n" +
+				"}\n" +
+				"func naturals() {\n" +
+				"  defer print(\"never\")\n" +
+				"  n = 1\n" +
+				"  while (true) {\n" +
+				"    yield n\n" +
+				"    n += 1\n" +
+				"  }\n" +
+				"}\n" +
+				"func guarded() {\n" +
+				"  defer print(\"deferred\")\n" +
+				"  try {\n" +
+				"    yield 1\n" +
+				"    throw \"boom\"\n" +
+				"  } catch (e) {\n" +
+				"    yield e.message\n" +
+				"  } finally {\n" +
+				"    print(\"finally\")\n" +
+				"  }\n" +
+				"  return 5\n" +
+				"  yield 6\n" +
+				"}\n" +
+				"func chain(a, b) {\n" +
+				"  for x in a { yield x }\n" +
+				"  for x in b { yield x }\n" +
+				"}\n" +
+				"func take(src, n) {\n" +
+				"  for x in src {\n" +
+				"    yield x\n" +
+				"    n -= 1\n" +
+				"    if (n == 0) { return }\n" +
+				"  }\n" +
+				"}\n" +
+				"func bad() {\n" +
+				"  yield \"ok\"\n" +
+				"  throw \"oops\"\n" +
+				"}\n" +
+				"print([x * x for x in count(10, 3) if x > 0])\n" +
+				"g = naturals()\n" +
+				"for x in g { if (x == 2) { break } }\n" +
+				"print(g, [x for x in chain(take(g, 3), count(2))])\n" +
+				"for x in guarded() { print(x) }\n" +
+				"try { for x in bad() { print(x) } } catch (e) { print(e.message) }\n" +
+				"for x in bad() { print(x) }\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "[9, 36, 81]\n" +
+					"<generator naturals> [3, 4, 5, 0, 1]\n" +
+					"1\n" +
+					"boom\n" +
+					"finally\n" +
+					"deferred\n" +
+					"ok\n" +
+					"oops\n" +
+					"ok\n",
+				ErrContains: "oops",
+			}),
+		},
 		{
 			name: "module_exports_and_from_import",
 			files: map[string]string{
@@ -1114,6 +1567,26 @@ func TestSpecBaseline(t *testing.T) {
 				Stdout: "42\n3\n",
 			}),
 		},
+		{
+			name: "module_globals_are_isolated",
+			files: map[string]string{
+				"a.wll": "count = 0\n" +
+					"export total = 1\n" +
+					"total = total + 1\n" +
+					"export func bump() { count = count + 1; return count }\n",
+				"b.wll": "count = 100\n" +
+					"export func bump() { count = count + 1; return count }\n",
+			},
+			source: "from \"./a.wll\" import total, bump\n" +
+				"from \"./b.wll\" import bump as bump_b\n" +
+				"count = 7\n" +
+				"print(total)\n" +
+				"print(bump(), bump_b(), bump())\n" +
+				"print(count)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "2\n1 101 2\n7\n",
+			}),
+		},
 		{
 			name: "from_import_missing_export_errors",
 			files: map[string]string{
@@ -1282,6 +1755,72 @@ func TestSpecBaseline(t *testing.T) {
 				ErrContains: "max memory exceeded (10 bytes)",
 			}),
 		},
+		{
+			name: "runtime_max_mem_caught_keeps_remaining_budget",
+			source: "try {\n" +
+				"  s = \"xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx\"\n" +
+				"} catch (e) {\n" +
+				"  print(e.code)\n" +
+				"}\n" +
+				"print(\"ok\")\n",
+			maxMemory: 300,
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "8001\nok\n",
+			}),
+		},
+		{
+			name: "runtime_max_recursion_caught",
+			source: "func f(self, n) { return self(self, n + 1) }\n" +
+				"try { f(f, 0) } catch (e) { print(e.message) }\n" +
+				"print(\"after\")\n",
+			maxRecursion: 50,
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "max recursion depth exceeded (50)\nafter\n",
+			}),
+		},
+		{
+			name: "runtime_max_steps_caught_with_grace",
+			source: "try { while (true) { } } catch (e) { print(e.code) }\n" +
+				"print(\"after\")\n",
+			maxSteps: 1000,
+			expect: spectest.Expect(spectest.ModeVM, spectest.Expectation{
+				Stdout: "8003\nafter\n",
+			}),
+		},
+		{
+			name: "runtime_max_steps_grace_exhausted",
+			source: "try { while (true) { } } catch (e) { print(\"caught\")\n while (true) { } }\n" +
+				"print(\"unreachable\")\n",
+			maxSteps: 1000,
+			expect: spectest.Expect(spectest.ModeVM, spectest.Expectation{
+				Stdout:      "caught\n",
+				ErrContains: "max instruction count exceeded (1000)",
+			}),
+		},
+		{
+			name: "runtime_max_steps_finally_runs",
+			source: "try { while (true) { } } finally { print(\"cleanup\") }\n" +
+				"print(\"unreachable\")\n",
+			maxSteps: 1000,
+			expect: spectest.Expect(spectest.ModeVM, spectest.Expectation{
+				Stdout:      "cleanup\n",
+				ErrContains: "max instruction count exceeded (1000)",
+			}),
+		},
+		{
+			// The interpreter does not count instructions, so max_steps
+			// leaves it alone: the loop finishes there and is stopped and
+			// caught on the VM.
+			name: "runtime_max_steps_vm_only",
+			source: "i = 0\n" +
+				"try { while (i < 5000) { i += 1 } } catch (e) { print(e.code) }\n" +
+				"print(i == 5000)\n",
+			maxSteps: 1000,
+			expect: map[spectest.Mode]spectest.Expectation{
+				spectest.ModeInterpreter: {Stdout: "true\n"},
+				spectest.ModeVM:          {Stdout: "8003\nfalse\n"},
+			},
+		},
 		{
 			name:      "runtime_max_mem_ok",
 			source:    "print(\"ok\")\n",
@@ -1350,6 +1889,964 @@ func TestSpecBaseline(t *testing.T) {
 				ErrContains: "unusable as dict key: ARRAY",
 			}),
 		},
+		{
+			name: "array_append_value_semantics",
+			source: "a = []\n" +
+				"for (i in range(5)) { a = append(a, i) }\n" +
+				"b = a.append(5)\n" +
+				"c = push(a, 6)\n" +
+				"b[0] = 10\n" +
+				"c.remove(1)\n" +
+				"d = a.append(7)\n" +
+				"d.pop()\n" +
+				"e = d.append(8)\n" +
+				"f = a.append(9)\n" +
+				"print(a, b, c)\n" +
+				"print(d, e, f)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "[0, 1, 2, 3, 4] [10, 1, 2, 3, 4, 5] [0, 2, 3, 4, 6]\n" +
+					"[0, 1, 2, 3, 4] [0, 1, 2, 3, 4, 8] [0, 1, 2, 3, 4, 9]\n",
+			}),
+		},
+		{
+			name: "integer_division_and_checked_arithmetic",
+			source: "print(-7 / 2, -7 % 3, 7 % -3)\n" +
+				"print(floor_div(-7, 2), floor_mod(-7, 3), floor_mod(7, -3))\n" +
+				"max = 9223372036854775807\n" +
+				"print(checked_add(1, 2), checked_add(max, 1))\n" +
+				"(v, ok) = checked_mul(max, 2)\n" +
+				"print(v, ok, checked_sub(-max - 1, 1)[1])\n" +
+				"z = 0.0\n" +
+				"try { 1.5 / z } catch (e) { print(e.message) }\n" +
+				"floor_mod(1, 0)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "-3 -1 1\n" +
+					"-4 2 -2\n" +
+					"(3, true) (-9223372036854775808, false)\n" +
+					"-2 false false\n" +
+					"division by zero\n",
+				ErrContains: "modulo by zero",
+			}),
+		},
+		{
+			name: "float_formatting_and_rounding",
+			source: "x = 0.1 + 0.2\n" +
+				"print(x, str(x), [x, 2.0], t\"${x}\", 1e21, 0.00001, 1e20, 123456789.123)\n" +
+				"print(round(2.5), round(-2.5), round(3.14159, 2), round(1250, -2))\n" +
+				"print(round(2.675, 2), round(1.005, 2), round(0.125, 2))\n" +
+				"print(floor(-2.5), ceil(-2.5), trunc(-2.5), floor(7))\n" +
+				"inf = 1e308 * 10\n" +
+				"print(inf, -inf, is_inf(inf), is_nan(inf - inf), is_nan(x))\n" +
+				"print(approx_eq(x, 0.3, 1e-9), x == 0.3)\n" +
+				"trunc(inf)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "0.30000000000000004 0.30000000000000004 [0.30000000000000004, 2] 0.30000000000000004 1e+21 1e-05 100000000000000000000 123456789.123\n" +
+					"3 -3 3.14 1300\n" +
+					"2.67 1 0.13\n" +
+					"-3 -2 -2 7\n" +
+					"+Inf -Inf true true false\n" +
+					"true false\n",
+				ErrContains: "trunc(): +Inf is out of INTEGER range",
+			}),
+		},
+		{
+			name: "std_stats",
+			source: "import \"std:stats\" as stats\n" +
+				"xs = [4, 1, 3, 2]\n" +
+				"print(stats.median(xs), stats.median([5, 1, 3]), stats.mode([2, 1, 1, 2, 3]))\n" +
+				"data = [2, 4, 4, 4, 5, 5, 7, 9]\n" +
+				"print(stats.variance(data), stats.stddev(data), stats.sample_variance([1, 2, 3, 4]))\n" +
+				"print(stats.percentile(xs, 50), stats.percentile(xs, 100))\n" +
+				"(counts, edges) = stats.histogram([1, 2, 2, 3, 9, 10], 3)\n" +
+				"print(counts, edges, stats.histogram_range([1, 2, 3, 20], 2, 0, 4))\n" +
+				"try { stats.median([]) } catch (e) { print(e.message) }\n" +
+				"stats.stddev([1, \"a\"])\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "2.5 3 2\n" +
+					"4 2 1.6666666666666667\n" +
+					"2.5 4\n" +
+					"[4, 0, 2] [1, 4, 7, 10] ([1, 2], [0, 2, 4])\n" +
+					"median() arg is an empty sequence\n",
+				ErrContains: "stddev() requires all elements to be NUMBER",
+			}),
+		},
+		{
+			name: "std_errors_codes",
+			source: "import \"std:errors\" as errors\n" +
+				"func kind(f) {\n" +
+				"  try { f() } catch (e) { return str(e.code) + \" \" + errors.name(e) }\n" +
+				"}\n" +
+				"print(kind(func() { return [1, 2][5] }))\n" +
+				"print(kind(func() { return 1 / 0 }), kind(func() { return 1 % 0 }))\n" +
+				"print(kind(func() { return pop(#{}, \"k\") }))\n" +
+				"print(kind(func() { return \"a\" + 1 }), kind(func() { return [1][\"x\"] }))\n" +
+				"print(kind(func() { return len(1, 2) }))\n" +
+				"print(kind(func() { assert 1 > 2 }))\n" +
+				"print(kind(func() { throw \"index out of range\" }), kind(func() { throw error(\"x\", 7) }))\n" +
+				"try { [][0] } catch (e) {\n" +
+				"  print(errors.is(e, errors.INDEX_OUT_OF_RANGE), errors.is(e, errors.KEY_NOT_FOUND))\n" +
+				"  print(errors.is_any(e, [errors.KEY_NOT_FOUND, errors.INDEX_OUT_OF_RANGE]), errors.code(e))\n" +
+				"}\n" +
+				"print(errors.code(\"not an error\"), errors.is(nil, 0), errors.name(1))\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "1002 INDEX_OUT_OF_RANGE\n" +
+					"1004 DIVISION_BY_ZERO 1004 DIVISION_BY_ZERO\n" +
+					"1003 KEY_NOT_FOUND\n" +
+					"1001 TYPE_MISMATCH 1001 TYPE_MISMATCH\n" +
+					"1007 WRONG_ARG_COUNT\n" +
+					"1009 ASSERTION_FAILED\n" +
+					"0  7 \n" +
+					"true false\n" +
+					"true 1002\n" +
+					"nil false \n",
+			}),
+		},
+		{
+			name: "std_flow_retry_and_timeout",
+			source: "import \"std:flow\" as flow\n" +
+				"import \"std:errors\" as errors\n" +
+				"n = 0\n" +
+				"func flaky() {\n" +
+				"  n = n + 1\n" +
+				"  if (n < 3) { throw error(\"busy\", 42) }\n" +
+				"  return n\n" +
+				"}\n" +
+				"print(flow.retry(flaky, 5, 1))\n" +
+				"n = 0\n" +
+				"try { flow.retry(flaky, 2, 1) } catch (e) { print(e.message, e.code, n) }\n" +
+				"n = 0\n" +
+				"try { flow.retry_on(flaky, 5, 1, [errors.TIMEOUT]) } catch (e) { print(e.message, n) }\n" +
+				"func spin() { while (true) { } }\n" +
+				"try { flow.with_timeout(spin, 10) } catch (e) { print(e.message, errors.name(e)) }\n" +
+				"try { flow.with_timeout(func() { flow.sleep(60000) }, 10) } catch (e) { print(errors.is(e, errors.TIMEOUT)) }\n" +
+				"print(flow.with_timeout(func() { return \"fast\" }, 60000))\n" +
+				"try { flow.with_timeout(func() { return flow.with_timeout(spin, 60000) }, 10) } catch (e) { print(\"outer\", e.code) }\n" +
+				"try { flow.retry(func() { return 1 }, 0, 1) } catch (e) { print(e.message) }\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "3\n" +
+					"busy 42 2\n" +
+					"busy 1\n" +
+					"timed out TIMEOUT\n" +
+					"true\n" +
+					"fast\n" +
+					"outer 1011\n" +
+					"retry: attempts must be at least 1\n",
+			}),
+		},
+		{
+			name: "std_flow_retry_then_throw",
+			source: "import \"std:flow\" as flow\n" +
+				"print(flow.retry(func() { return \"ok\" }, 3, 1))\n" +
+				"print(flow.retry_on(func() { return 2 }, 3, 1, nil))\n" +
+				"try { throw error(\"later\", \"E_LATER\") } catch (e) { print(e.message, e.code) }\n" +
+				"throw \"uncaught\"\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout:      "ok\n2\nlater E_LATER\n",
+				ErrContains: "uncaught",
+			}),
+		},
+		{
+			name: "print_cycles_and_pp",
+			source: "a = [1, nil]\n" +
+				"a[1] = a\n" +
+				"d = #{\"name\": \"x\"}\n" +
+				"d.self = d\n" +
+				"d.items = [d, (1,)]\n" +
+				"print(a, d)\n" +
+				"print(str(a) == \"[1, [...]]\")\n" +
+				"pp(#{\"xs\": [1, 2], \"t\": ()})\n" +
+				"pp([1, [2, [3]]], #{\"depth\": 2, \"indent\": 0})\n" +
+				"pp(range(5).to_list(), #{\"length\": 2, \"indent\": 0})\n" +
+				"pp(d, #{\"indent\": 0})\n" +
+				"pp(1, #{\"width\": 2})\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "[1, [...]] #{\"items\": [#{...}, (1,)], \"name\": x, \"self\": #{...}}\n" +
+					"true\n" +
+					"#{\n  \"t\": (),\n  \"xs\": [\n    1,\n    2\n  ]\n}\n" +
+					"[1, [2, [...]]]\n" +
+					"[0, 1, ...]\n" +
+					"#{\"items\": [#{...}, (1,)], \"name\": x, \"self\": #{...}}\n",
+				ErrContains: "pp() unknown option: width",
+			}),
+		},
+		{
+			name: "set_print_options",
+			source: "x = 2.0 / 3\n" +
+				"print(x, [x, 1.5], range(5).to_list())\n" +
+				"prev = set_print_options(#{\"float_precision\": 3, \"max_items\": 2})\n" +
+				"print(x, [x, 1.5], range(5).to_list(), str(0.1 + 0.2), #{\"a\": 1, \"b\": 2, \"c\": 3})\n" +
+				"print(prev)\n" +
+				"set_print_options(#{\"max_depth\": 1})\n" +
+				"print([1, [2]], x)\n" +
+				"set_print_options(prev)\n" +
+				"print(x, [[1]])\n" +
+				"set_print_options(#{\"digits\": 2})\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "0.6666666666666666 [0.6666666666666666, 1.5] [0, 1, 2, 3, 4]\n" +
+					"0.667 [0.667, 1.5] [0, 1, ...] 0.3 #{\"a\": 1, \"b\": 2, ...}\n" +
+					"#{\"float_precision\": 0, \"max_depth\": 64, ...}\n" +
+					"[1, [...]] 0.667\n" +
+					"0.6666666666666666 [[1]]\n",
+				ErrContains: "set_print_options() unknown option: digits",
+			}),
+		},
+		{
+			name: "help",
+			source: "// Adds one to n.\n" +
+				"// Works on floats too.\n" +
+				"func inc(n) { return n + 1 }\n" +
+				"help(inc)\n" +
+				"help(len)\n" +
+				"help(\"std:math.floor\")\n" +
+				"help(func(a, b) { return a })\n" +
+				"help(1)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "inc(n)\n    Adds one to n.\n    Works on floats too.\n" +
+					"len(x) -> int\n    Supports string, array, and dict; wrong type or arg count is an error.\n" +
+					"floor(x)\n    Rounds x down to the nearest integer.\n" +
+					"func(a, b)\n    No documentation.\n",
+				ErrContains: "help() expects a function or a name, got INTEGER",
+			}),
+		},
+		{
+			name: "is_main",
+			files: map[string]string{
+				"lib.wll": "export func where() { return is_main() }\n" +
+					"export here = is_main()\n" +
+					"if (is_main()) { print(\"lib demo\") }\n",
+			},
+			source: "import \"./lib.wll\" as lib\n" +
+				"f = func() { return is_main() }\n" +
+				"print(is_main(), f(), lib.where(), lib.here)\n" +
+				"if (is_main()) { print(\"main\") }\n" +
+				"is_main(1)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout:      "true true false false\nmain\n",
+				ErrContains: "wrong number of arguments: expected 0, got 1",
+			}),
+		},
+		{
+			name: "module_init_and_deinit_hooks",
+			files: map[string]string{
+				"b.wll": "export func __init() { print(\"init b\") }\n" +
+					"export func __deinit() { print(\"deinit b\") }\n" +
+					"export x = 1\n",
+				"a.wll": "import \"./b.wll\" as b\n" +
+					"print(\"load a\")\n" +
+					"export func __init() { print(\"init a\", b.x) }\n" +
+					"export func __deinit() { print(\"deinit a\") }\n",
+				"c.wll": "import \"./b.wll\" as b\n" +
+					"export func __deinit() { print(\"deinit c\") }\n",
+			},
+			source: "import \"./a.wll\" as a\n" +
+				"import \"./b.wll\" as b\n" +
+				"import \"./c.wll\" as c\n" +
+				"func main() {\n" +
+				"  defer print(\"main defer\")\n" +
+				"  print(\"main\")\n" +
+				"}\n" +
+				"main()\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "init b\nload a\ninit a 1\nmain\nmain defer\ndeinit c\ndeinit a\ndeinit b\n",
+			}),
+		},
+		{
+			name: "module_init_error",
+			files: map[string]string{
+				"lib.wll": "export func __init() { throw error(\"init failed\") }\n",
+			},
+			source: "import \"./lib.wll\" as lib\n" +
+				"print(\"main\")\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				ErrContains: "init failed",
+			}),
+		},
+		{
+			name: "module_deinit_error",
+			files: map[string]string{
+				"ok.wll":  "export func __deinit() { print(\"deinit ok\") }\n",
+				"bad.wll": "export func __deinit() { throw error(\"deinit failed\") }\n",
+			},
+			source: "import \"./ok.wll\" as ok\n" +
+				"import \"./bad.wll\" as bad\n" +
+				"print(\"main\")\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout:      "main\n",
+				ErrContains: "deinit failed",
+			}),
+		},
+		{
+			name: "module_deinit_after_top_level_defers",
+			files: map[string]string{
+				"lib.wll": "export func __deinit() { print(\"deinit lib\") }\n",
+			},
+			source: "import \"./lib.wll\" as lib\n" +
+				"defer print(\"main defer\")\n" +
+				"print(\"main\")\n",
+			expect: spectest.Expect(spectest.ModeVM, spectest.Expectation{
+				Stdout: "main\nmain defer\ndeinit lib\n",
+			}),
+		},
+		{
+			name: "slice_assignment_grows_and_shrinks",
+			source: "a = [1, 2, 3, 4, 5]\n" +
+				"a[1:3] = [9, 9, 9]\n" +
+				"print(a)\n" +
+				"a[1:4] = []\n" +
+				"print(a)\n" +
+				"a[10:] = (7, 8)\n" +
+				"print(a)\n" +
+				"a[:0] = [0]\n" +
+				"print(a)\n" +
+				"a[::2] = [-1, -2, -3]\n" +
+				"print(a)\n" +
+				"b = a\n" +
+				"b[0:2] = b\n" +
+				"print(a)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "[1, 9, 9, 9, 4, 5]\n[1, 4, 5]\n[1, 4, 5, 7, 8]\n[0, 1, 4, 5, 7, 8]\n[-1, 1, -2, 5, -3, 8]\n" +
+					"[-1, 1, -2, 5, -3, 8, -2, 5, -3, 8]\n",
+			}),
+		},
+		{
+			name: "slice_assignment_errors",
+			source: "a = [1, 2, 3]\n" +
+				"try { a[::2] = [1] } catch (e) { print(e.message) }\n" +
+				"try { a[0:1] = 5 } catch (e) { print(e.message) }\n" +
+				"s = \"abc\"\n" +
+				"try { s[0:1] = [\"x\"] } catch (e) { print(e.message) }\n" +
+				"print(a)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "slice assignment with step 2 expects 2 elements, got 1\n" +
+					"slice assignment value must be ARRAY or TUPLE, got: INTEGER\n" +
+					"slice assignment not supported on type: STRING\n" +
+					"[1, 2, 3]\n",
+			}),
+		},
+		{
+			name: "del_and_insert",
+			source: "a = [1, 2, 3, 4, 5, 6]\n" +
+				"del a[0]\n" +
+				"del a[-1]\n" +
+				"print(a)\n" +
+				"del a[::2]\n" +
+				"print(a)\n" +
+				"try { del a[10] } catch (e) { print(e.message) }\n" +
+				"d = #{\"a\": 1, \"b\": 2}\n" +
+				"del d[\"a\"]\n" +
+				"print(d)\n" +
+				"try { del d[\"a\"] } catch (e) { print(e.message) }\n" +
+				"b = [1, 2, 3]\n" +
+				"b.insert(0, \"x\")\n" +
+				"b.insert(-1, \"y\")\n" +
+				"b.insert(99, \"z\")\n" +
+				"b.insert(-99, \"w\")\n" +
+				"print(b)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "[2, 3, 4, 5]\n[3, 5]\nindex out of range\n#{\"b\": 2}\nkey not found: a\n[w, x, 1, 2, y, 3, z]\n",
+			}),
+		},
+		{
+			name: "slice_assignment_charges_memory",
+			source: "a = [1, 2, 3, 4]\n" +
+				"for (i in range(20)) { a[len(a):] = a }\n",
+			maxMemory: 4096,
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				ErrContains: "max memory exceeded (4096 bytes)",
+			}),
+		},
+		{
+			name: "multi_index_sugar",
+			source: "grid = [[1, 2, 3], [4, 5, 6]]\n" +
+				"y = 1\n" +
+				"x = 2\n" +
+				"print(grid[y, x], grid[y][x], grid[0, -1])\n" +
+				"grid[0, 1] = 20\n" +
+				"grid[1, 0] += 40\n" +
+				"print(grid)\n" +
+				"d = #{\"a\": #{\"b\": [7, 8]}}\n" +
+				"print(d[\"a\", \"b\", 1])\n" +
+				"del grid[1, 2]\n" +
+				"print(grid[1])\n" +
+				"try { print(grid[0, x, 0]) } catch (e) { print(e.code) }\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "6 6 3\n[[1, 20, 3], [44, 5, 6]]\n8\n[44, 5]\n1001\n",
+			}),
+		},
+		{
+			name: "named_tuples",
+			source: "p = (x: 1, y: 2)\n" +
+				"print(p, p.x + p.y, p[1])\n" +
+				"print(p == (x: 1, y: 2), p == (y: 2, x: 1), p == (1, 2))\n" +
+				"(y: b, x: a) = p\n" +
+				"print(a, b)\n" +
+				"(x: only) = p\n" +
+				"print(only)\n" +
+				"print((name: \"n\",))\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "(x: 1, y: 2) 3 2\ntrue false false\n1 2\n1\n(name: n)\n",
+			}),
+		},
+		{
+			name: "named_tuple_errors",
+			source: "p = (x: 1, y: 2)\n" +
+				"try { print(p.z) } catch (e) { print(e.message) }\n" +
+				"func missing() { (z: c) = p }\n" +
+				"func plain() { (x: c) = (1, 2) }\n" +
+				"try { missing() } catch (e) { print(e.message) }\n" +
+				"try { plain() } catch (e) { print(e.message) }\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "unknown member on TUPLE: z\nnamed tuple has no field: z\nunpack by name expects named tuple, got plain TUPLE\n",
+			}),
+		},
+		{
+			name: "literal_spreads",
+			source: "rest = [2, 3]\n" +
+				"print([1, ...rest, 4, ...(5, 6), ...[]])\n" +
+				"defaults = #{\"x\": 0, \"y\": 0}\n" +
+				"print(#{...defaults, \"x\": 1})\n" +
+				"print(#{\"x\": 1, ...defaults})\n" +
+				"print(defaults, rest)\n" +
+				"try { print([...5]) } catch (e) { print(e.message) }\n" +
+				"try { print(#{...rest}) } catch (e) { print(e.message) }\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "[1, 2, 3, 4, 5, 6]\n#{\"x\": 1, \"y\": 0}\n#{\"x\": 0, \"y\": 0}\n#{\"x\": 0, \"y\": 0} [2, 3]\n" +
+					"cannot spread INTEGER in array literal\ncannot spread ARRAY in dict literal\n",
+			}),
+		},
+		{
+			name: "literal_spreads_charge_memory",
+			source: "a = [1, 2, 3, 4]\n" +
+				"for (i in range(20)) { a = [...a, ...a] }\n",
+			maxMemory: 4096,
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				ErrContains: "max memory exceeded (4096 bytes)",
+			}),
+		},
+		{
+			name: "collection_chunks_and_groups",
+			source: "xs = [1, 2, 3, 4, 5]\n" +
+				"print(chunk(xs, 2), chunk(xs, 9), windows(xs, 3), windows(xs, 6))\n" +
+				"print(flatten([1, [2, [3, [4]]], []]), flatten([1, [2, [3, [4]]]], 2), flatten([[1]], 0))\n" +
+				"print(group_by([\"apple\", \"avocado\", \"banana\", \"cherry\", \"blueberry\"], func(s) { return s[0] }))\n" +
+				"a = [1, 2]\n" +
+				"a[0] = a\n" +
+				"try { flatten(a, 5) } catch (e) { print(e.message) }\n" +
+				"try { chunk(xs, 0) } catch (e) { print(e.message) }\n" +
+				"group_by(xs, func(x) { return [x] })\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "[[1, 2], [3, 4], [5]] [[1, 2, 3, 4, 5]] [[1, 2, 3], [2, 3, 4], [3, 4, 5]] []\n" +
+					"[1, 2, [3, [4]]] [1, 2, 3, [4]] [[1]]\n" +
+					"#{\"a\": [apple, avocado], \"b\": [banana, blueberry], \"c\": [cherry]}\n" +
+					"flatten() array contains itself\n" +
+					"chunk() size must be positive\n",
+				ErrContains: "unusable as dict key: ARRAY",
+			}),
+		},
+		{
+			name:      "collection_chunks_charge_memory",
+			source:    "w = windows(range(200), 100)\n",
+			maxMemory: 16384,
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				ErrContains: "max memory exceeded (16384 bytes)",
+			}),
+		},
+		{
+			name: "range_objects",
+			source: "r = range(10, 0, -3)\n" +
+				"print(r, range(5), len(r), r[0], r[-1], r.to_list())\n" +
+				"print(range(10)[2:8:2], range(10)[::-1].to_list(), 7 in r, 8 in r)\n" +
+				"print(range(0, 5, 2) == range(0, 6, 2), range(3) != range(1, 4))\n" +
+				"print(sum(range(101)), [...range(3), 9], [x * x for x in range(4) if x != 2])\n" +
+				"total = 0\n" +
+				"for (i in range(1000000000)) { if (i == 3) { break }\n total += i }\n" +
+				"print(total)\n" +
+				"range(3)[0] = 1\n",
+			maxMemory: 4096,
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "range(10, 0, -3) range(0, 5) 4 10 1 [10, 7, 4, 1]\n" +
+					"range(2, 8, 2) [9, 8, 7, 6, 5, 4, 3, 2, 1, 0] true false\n" +
+					"true true\n" +
+					"5050 [0, 1, 2, 9] [0, 1, 9]\n" +
+					"3\n",
+				ErrContains: "index assignment not supported on",
+			}),
+		},
+		{
+			name:      "range_loops_charge_per_step",
+			source:    "n = 0\nfor (i in range(100000)) { n += 1 }\n",
+			maxMemory: 4096,
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				ErrContains: "max memory exceeded (4096 bytes)",
+			}),
+		},
+		{
+			name: "heap_dump_builtin",
+			source: "cache = #{\"rows\": [[1, 2], [3, 4], [5, 6]]}\n" +
+				"func inner() {\n  big = [1, 2, 3, 4, 5, 6, 7, 8, 9]\n  return heap_dump()\n}\n" +
+				"d = inner()\n" +
+				"top = d[\"largest\"][0]\n" +
+				"print(top[\"type\"], top[\"len\"], top[\"path\"], top[\"site\"])\n" +
+				"print(d[\"largest\"][1][\"path\"], d[\"largest\"][2][\"path\"])\n" +
+				"print(d[\"budget_limit\"], d[\"budget_used\"] >= d[\"bytes\"], d[\"types\"][0][\"type\"])\n" +
+				"heap_dump(1)\n",
+			maxMemory: 100000,
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout:      "ARRAY 9 big nil\ncache cache[\"rows\"]\n100000 true ARRAY\n",
+				ErrContains: "wrong number of arguments: expected 0, got 1",
+			}),
+		},
+		{
+			name: "seq_pipelines",
+			source: "calls = 0\n" +
+				"sq = func(x) { calls += 1\n return x * x }\n" +
+				"p = seq(range(100000)).map(sq).filter(func(x) { return x % 2 == 1 }).take(3)\n" +
+				"print(p, p.to_list(), calls)\n" +
+				"for (x in p) { print(\"x\", x) }\n" +
+				"print([x + 1 for x in seq([1, 2, 3]).map(sq)], calls)\n" +
+				"base = seq([1, 2, 3, 4])\n" +
+				"print(base.take(2).to_list(), base.filter(func(x) { return x > 2 }).to_list(), base.to_list(), seq([1]).take(0).to_list())\n" +
+				"for (x in seq([1, 2, 3, 4]).map(sq)) { if (x > 4) { break }\n print(\"loop\", x) }\n" +
+				"print(calls)\n" +
+				"try { seq([1, 2]).map(func(x) { throw error(\"boom\") }).to_list() } catch (e) { print(\"caught\", e.message) }\n" +
+				"try { seq([1]).take(-1) } catch (e) { print(e.message) }\n" +
+				"seq([1]).map(3)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "<seq> [1, 9, 25] 6\n" +
+					"x 1\n" +
+					"x 9\n" +
+					"x 25\n" +
+					"[2, 5, 10] 15\n" +
+					"[1, 2] [3, 4] [1, 2, 3, 4] []\n" +
+					"loop 1\n" +
+					"loop 4\n" +
+					"18\n" +
+					"caught boom\n" +
+					"take() count must not be negative\n",
+				ErrContains: "map() expects FUNCTION, got: INTEGER",
+			}),
+		},
+		{
+			name: "set_literals_and_methods",
+			source: "s = #[3, 1, 2, 1]\n" +
+				"print(s, len(s), 2 in s, 5 in s, #[] == set(), #[1, 2] == #[2, 1], #[1] != #[\"1\"])\n" +
+				"print(s.add(4), s.add(1), s.remove(3), s.remove(42), s.has(4), s)\n" +
+				"print(s.union(#[\"a\"]), s.intersect(#[2, 4, 10]), s.difference(#[2]), set([2, 2, \"x\"]).to_list())\n" +
+				"for (x in #[2, 1]) { print(\"x\", x) }\n" +
+				"print([x * 2 for x in #[1, 2]], #[\"a\", \"a\"])\n" +
+				"try { s.union([1]) } catch (e) { print(e.message) }\n" +
+				"try { set(5) } catch (e) { print(e.message) }\n" +
+				"#[[1]]\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "#[1, 2, 3] 3 true false true true true\n" +
+					"nil nil true false true #[1, 2, 4]\n" +
+					"#[1, 2, 4, \"a\"] #[2, 4] #[1, 4] [2, x]\n" +
+					"x 1\n" +
+					"x 2\n" +
+					"[2, 4] #[\"a\"]\n" +
+					"union() expects SET, got: ARRAY\n" +
+					"set() expects ARRAY or SET, got INTEGER\n",
+				ErrContains: "unusable as set element: ARRAY",
+			}),
+		},
+		{
+			name:      "set_add_charges_memory",
+			source:    "s = #[]\ni = 0\nwhile (i < 1000) { s.add(i)\n i += 1 }\n",
+			maxMemory: 16384,
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				ErrContains: "max memory exceeded (16384 bytes)",
+			}),
+		},
+		{
+			name: "function_parameter_defaults",
+			source: "func greet(name, greeting = \"hello\", punct = \"!\") { return greeting + \", \" + name + punct }\n" +
+				"print(greet(\"ann\"), greet(\"bob\", \"hi\"), greet(\"cy\", \"yo\", \"?\"))\n" +
+				"base = 10\n" +
+				"add = func(x, n = base * 2) { return x + n }\n" +
+				"base = 99\n" +
+				"print(add(1), add(1, 1), map(add, [1, 2]), add(...[5]))\n" +
+				"func tally(k, seen = #{}) { seen[k] = true\n return len(seen) }\n" +
+				"print(tally(\"a\"), tally(\"b\"), tally(\"c\", #{}))\n" +
+				"func g() { defer print(greet(\"deferred\")) }\n" +
+				"g()\n" +
+				"try { greet() } catch (e) { print(e.message) }\n" +
+				"add(1, 2, 3)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "hello, ann! hi, bob! yo, cy?\n" +
+					"21 2 [21, 22] 25\n" +
+					"1 2 1\n" +
+					"hello, deferred!\n" +
+					"wrong number of arguments: expected 1 to 3, got 0\n",
+				ErrContains: "wrong number of arguments: expected 1 or 2, got 3",
+			}),
+		},
+		{
+			name: "stopwatch_and_time_it",
+			source: "sw = stopwatch()\n" +
+				"lap = sw.lap()\n" +
+				"print(sw, lap >= 0, sw.elapsed_ms() >= lap)\n" +
+				"calls = [0]\n" +
+				"func work() { calls[0] = calls[0] + 1 }\n" +
+				"r = time_it(work, 4)\n" +
+				"print(r.runs, calls[0], r.best >= 0, r.best <= r.avg)\n" +
+				"try { time_it(work, 0) } catch (e) { print(e.message) }\n" +
+				"try { sw.lap(1) } catch (e) { print(e.message) }\n" +
+				"time_it(func() { throw \"boom\" }, 3)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "<stopwatch> true true\n" +
+					"4 4 true true\n" +
+					"time_it() runs must be a positive INTEGER\n" +
+					"lap() takes 0 arguments, got 1\n",
+				ErrContains: "boom",
+			}),
+		},
+		{
+			name: "sort_comparators_and_helpers",
+			source: "people = [(\"bob\", 30), (\"amy\", 25), (\"cat\", 30), (\"dan\", 25)]\n" +
+				"print(sort_by(people, func(p) { return p[1] }))\n" +
+				"print(sort(people, func(a, b) { return a[1] > b[1] }))\n" +
+				"xs = [3, 1, 2]\n" +
+				"print(sort(xs, func(a, b) { return b - a }), xs, sort_by([\"ccc\", \"a\", \"bb\"], len))\n" +
+				"print(unique([3, 1, 3, 1.0, \"a\", \"a\", nil, nil]), reversed([1, 2, 3]))\n" +
+				"try { sort(xs, func(a, b) { throw error(\"boom\") }) } catch (e) { print(\"caught\", e.message) }\n" +
+				"try { map(func(x) { throw error(\"bang\") }, xs) } catch (e) { print(\"caught\", e.message) }\n" +
+				"sort(xs, func(a, b) { return nil })\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "[(amy, 25), (dan, 25), (bob, 30), (cat, 30)]\n" +
+					"[(bob, 30), (cat, 30), (amy, 25), (dan, 25)]\n" +
+					"[3, 2, 1] [3, 1, 2] [a, bb, ccc]\n" +
+					"[3, 1, a, nil] [3, 2, 1]\n" +
+					"caught boom\n" +
+					"caught bang\n",
+				ErrContains: "sort() comparator must return INTEGER or BOOLEAN, got NIL",
+			}),
+		},
+		{
+			name: "std_scene",
+			source: "import \"std:scene\" as scene\n" +
+				"w = scene.new()\n" +
+				"p = scene.tag(scene.velocity(scene.position(scene.spawn(w, #{\"name\": \"player\"}), 0, 0), 10, 0), \"hero\")\n" +
+				"b = scene.spawn(w, #{\"name\": \"bullet\", \"x\": 5, \"y\": 5, \"z\": -1})\n" +
+				"scene.on_update(b, func(e, dt, world) { e[\"x\"] = e[\"x\"] + 1\n if (e[\"x\"] > 6) { scene.destroy(world, e) } })\n" +
+				"show = func(e, world) { print(\"draw\", e[\"name\"], e[\"x\"]) }\n" +
+				"scene.on_draw(b, show)\n" +
+				"scene.on_draw(p, show)\n" +
+				"scene.add_system(w, func(world, dt) { print(\"system\", scene.size(world)) })\n" +
+				"for (i in range(0, 2)) {\n scene.update(w, 0.5)\n scene.draw(w)\n}\n" +
+				"print(scene.size(w), len(scene.tagged(w, \"hero\")), len(scene.query(w, [\"vx\", \"x\"])), scene.find(w, 2))\n" +
+				"print(scene.has_tag(scene.untag(p, \"hero\"), \"hero\"), len(scene.query(w, [\"color\"])))\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "system 2\n" +
+					"draw bullet 6\n" +
+					"draw player 5\n" +
+					"system 2\n" +
+					"draw player 10\n" +
+					"1 1 1 nil\n" +
+					"false 0\n",
+			}),
+		},
+		{
+			name: "std_geom",
+			source: "import \"std:geom\" as geom\n" +
+				"a = geom.rect(0, 0, 10, 10)\n" +
+				"print(geom.overlaps(a, geom.rect(10, 0, 5, 5)), geom.overlaps(a, geom.circle(12, 5, 3)))\n" +
+				"print(geom.intersection(a, geom.rect(5, 5, 10, 10)), geom.intersection(a, geom.rect(20, 0, 1, 1)))\n" +
+				"print(geom.contains([[0, 0], [10, 0], [0, 10]], [2, 2]), geom.contains(a, [10, 5]))\n" +
+				"print(geom.segment_hit([0, 0], [10, 10], [0, 10], [10, 0]))\n" +
+				"print(geom.move_and_collide(a, 20, 20, [geom.rect(15, 2, 5, 5), geom.rect(0, 12, 40, 4)]))\n" +
+				"geom.overlaps(a, geom.rect(0, 0, -1, 1))\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "false true\n" +
+					"#{\"h\": 5, \"w\": 5, \"x\": 5, \"y\": 5} nil\n" +
+					"true false\n" +
+					"[5, 5]\n" +
+					"#{\"nx\": 0, \"ny\": -1, \"t\": 0.1, \"x\": 2, \"y\": 2}\n",
+				ErrContains: "geom_overlaps() rect size must not be negative",
+			}),
+		},
+		{
+			name: "std_proc",
+			source: "import \"std:proc\" as proc\n" +
+				"try { proc.run(\"echo\", [\"hi\"], nil) } catch (e) { print(e.message) }\n" +
+				"try { proc.spawn(\"cat\", [], nil) } catch (e) { print(e.message) }\n" +
+				"try { proc_run(\"echo\", [1], nil) } catch (e) { print(e.message) }\n" +
+				"try { proc_run(\"echo\", [], #{\"shell\": true}) } catch (e) { print(e.message) }\n" +
+				"try { proc_wait(42) } catch (e) { print(e.message) }\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "proc: running commands is disabled (run with -allow-exec)\n" +
+					"proc: running commands is disabled (run with -allow-exec)\n" +
+					"proc_run() args must be STRINGs, got INTEGER\n" +
+					"proc_run() has no option \"shell\"\n" +
+					"proc: process 42 is not running\n",
+			}),
+		},
+		{
+			name: "std_httpserver",
+			source: "import \"std:httpserver\" as http\n" +
+				"try { http.listen(\"127.0.0.1:0\") } catch (e) { print(e.message) }\n" +
+				"try { http_respond(1, 200, #{\"X\": 1}, \"\") } catch (e) { print(e.message) }\n" +
+				"print(http_respond(123456, 200, #{}, \"\"))\n" +
+				"try { ws_accept(123456) } catch (e) { print(e.message) }\n" +
+				"try { http_call(\"1\", print, 1) } catch (e) { print(e.message) }\n" +
+				"print(http_call(123456, func(req) { print(\"ran\") }, 1))\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "httpserver: network access is disabled (run with -allow-net)\n" +
+					"http_respond() headers must map STRING to STRING\n" +
+					"false\n" +
+					"httpserver: request 123456 was already answered or timed out\n" +
+					"http_call() expects INTEGER request id\n" +
+					"nil\n",
+			}),
+		},
+		{
+			name: "std_net",
+			source: "import \"std:net\" as net\n" +
+				"try { net.listen(\"127.0.0.1:0\") } catch (e) { print(e.message) }\n" +
+				"try { net.udp_bind(\"127.0.0.1:0\") } catch (e) { print(e.message) }\n" +
+				"try { net_recv(1, \"x\") } catch (e) { print(e.message) }\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "net: network access is disabled (run with -allow-net)\n" +
+					"net: network access is disabled (run with -allow-net)\n" +
+					"net_recv() expects INTEGER size, got STRING\n",
+			}),
+		},
+		{
+			name: "std_sqlite",
+			source: "import \"std:sqlite\" as sqlite\n" +
+				"db = sqlite.open(\":memory:\")\n" +
+				"sqlite.exec(db, \"CREATE TABLE people (name, age)\", [])\n" +
+				"r = sqlite.exec(db, \"INSERT INTO people VALUES (?, ?)\", [\"ann\", 31])\n" +
+				"print(r.changes, r.last_id)\n" +
+				"sqlite.exec(db, \"INSERT INTO people VALUES (:name, :age)\", #{\"name\": \"bob\", \"age\": nil})\n" +
+				"for (row in sqlite.query(db, \"SELECT * FROM people\", [])) { print(row.name, row.age) }\n" +
+				"func add_cy(db) {\n" +
+				"  sqlite.exec(db, \"INSERT INTO people VALUES (?, ?)\", [\"cy\", 7])\n" +
+				"  return \"added\"\n" +
+				"}\n" +
+				"func add_dee(db) {\n" +
+				"  sqlite.exec(db, \"INSERT INTO people VALUES (?, ?)\", [\"dee\", 9])\n" +
+				"  throw \"changed my mind\"\n" +
+				"}\n" +
+				"print(sqlite.transaction(db, add_cy))\n" +
+				"try { sqlite.transaction(db, add_dee) } catch (e) { print(\"caught\", e.message) }\n" +
+				"print(len(sqlite.query(db, \"SELECT * FROM people\", [])))\n" +
+				"try { sqlite.exec(db, \"INSERT INTO people VALUES (?)\", [[1]]) } catch (e) { print(e.message) }\n" +
+				"try { sqlite.open(\"data.db\") } catch (e) { print(e.message) }\n" +
+				"sqlite.close(db)\n" +
+				"try { sqlite.query(db, \"SELECT * FROM people\", []) } catch (e) { print(e.message == \"sqlite: database \" + str(db.handle) + \" is not open\") }\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "1 1\n" +
+					"ann 31\n" +
+					"bob nil\n" +
+					"added\n" +
+					"caught changed my mind\n" +
+					"3\n" +
+					"sqlite: cannot bind ARRAY\n" +
+					"sqlite: opening \"data.db\" needs file system access (run with -allow-fs)\n" +
+					"true\n",
+			}),
+		},
+		{
+			name: "std_config_formats",
+			source: "import \"std:toml\" as toml\n" +
+				"import \"std:yaml\" as yaml\n" +
+				"import \"std:ini\" as ini\n" +
+				"conf = toml.parse(\"title = 'demo'\\nlimits = { cpu = 2.5 }\\n[[users]]\\nname = \\\"ann\\\"\\n[[users]]\\nname = \\\"bob\\\"\\n\")\n" +
+				"print(conf.title, conf.limits.cpu, len(conf.users), conf.users[1].name)\n" +
+				"conf.title = \"new\"\n" +
+				"print(toml.stringify(conf))\n" +
+				"doc = yaml.parse(\"name: app\\nports:\\n  - 80\\n  - 443\\nenv: {debug: true, level: ~}\\n\")\n" +
+				"print(doc.name, doc.ports, doc.env.debug, doc.env.level)\n" +
+				"cfg = ini.parse(\"top = 1\\n[db]\\nhost = localhost ; dev\\n\")\n" +
+				"print(cfg.top, cfg.db.host)\n" +
+				"func fails(f, text) {\n" +
+				"  try { f(text) } catch (e) { return e.message }\n" +
+				"  return \"ok\"\n" +
+				"}\n" +
+				"print(fails(toml.parse, \"a = 1\\na = 2\"))\n" +
+				"print(fails(yaml.parse, \"a: &x 1\"))\n" +
+				"print(fails(ini.parse, \"junk\"))\n" +
+				"print(fails(toml.stringify, #{\"a\": nil}))\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "demo 2.5 2 bob\n" +
+					"title = \"new\"\n" +
+					"\n" +
+					"[limits]\n" +
+					"cpu = 2.5\n" +
+					"\n" +
+					"[[users]]\n" +
+					"name = \"ann\"\n" +
+					"\n" +
+					"[[users]]\n" +
+					"name = \"bob\"\n" +
+					"\n" +
+					"app [80, 443] true nil\n" +
+					"1 localhost\n" +
+					"toml: line 2: key a is defined twice\n" +
+					"yaml: line 1: anchors, aliases and tags are not supported\n" +
+					"ini: line 1: expected key = value\n" +
+					"toml: cannot encode nil at a\n",
+			}),
+		},
+		{
+			name: "std_template",
+			source: "import \"std:template\" as template\n" +
+				"page = \"<h1>{{title}}</h1>\\n{{#items}}\\n- {{name}}{{#done}} (done){{/done}}\\n{{/items}}\\n{{^items}}\\nnothing to do\\n{{/items}}\\n\"\n" +
+				"print(template.render(page, #{\"title\": \"Tom & Jerry\", \"items\": [#{\"name\": \"a\", \"done\": true}, #{\"name\": \"b\"}]}))\n" +
+				"print(template.render(page, #{\"title\": \"x\", \"items\": []}))\n" +
+				"print(template.render_as(\"{{a}} {{{a}}} {{p.x}} {{#xs}}[{{.}}]{{/xs}}{{missing}}\", #{\"a\": \"<\\\"q\\\">\", \"p\": (x: 1, y: 2), \"xs\": [1, nil]}, \"json\"))\n" +
+				"try { template.render(\"{{#a}}\\n{{/b}}\", #{}) } catch (e) { print(e.message) }\n" +
+				"template.render_as(\"x\", #{}, \"xml\")\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "<h1>Tom &amp; Jerry</h1>\n" +
+					"- a (done)\n" +
+					"- b\n" +
+					"\n" +
+					"<h1>x</h1>\n" +
+					"nothing to do\n" +
+					"\n" +
+					"<\\\"q\\\"> <\"q\"> 1 [1][]\n" +
+					"template: line 2: {{/b}} closes {{#a}} from line 1\n",
+				ErrContains: "template: unknown escape mode \"xml\" (want html, json, none)",
+			}),
+		},
+		{
+			name: "std_encoding",
+			source: "import \"std:encoding\" as enc\n" +
+				"print(enc.b64_encode(\"hello, world\"), enc.b64_decode(\"aGk\"), enc.hex_encode(\"Hi!\"), enc.hex_decode(\"48692A\"))\n" +
+				"print(enc.url_encode(\"a b&c=d/é\"), enc.url_decode(\"a+b%26c\"))\n" +
+				"print(enc.query_parse(\"?tag=a&tag=b&name=J%C3%B6+D&flag\"))\n" +
+				"print(enc.query_stringify(#{\"q\": \"x y\", \"page\": 2, \"tag\": [\"a\", \"b\"], \"skip\": nil}))\n" +
+				"try { enc.hex_decode(\"abc\") } catch (e) { print(e.message) }\n" +
+				"enc.b64_decode(\"a$b=\")\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "aGVsbG8sIHdvcmxk hi 486921 Hi*\n" +
+					"a+b%26c%3Dd%2F%C3%A9 a b&c\n" +
+					"#{\"flag\": , \"name\": Jö D, \"tag\": [a, b]}\n" +
+					"page=2&q=x+y&tag=a&tag=b\n" +
+					"hex: odd length 3\n",
+				ErrContains: "base64: invalid input at byte 1",
+			}),
+		},
+		{
+			name: "std_cli",
+			source: "import \"std:cli\" as cli\n" +
+				"flags = [#{\"name\": \"verbose\", \"short\": \"v\", \"type\": \"bool\"}, #{\"name\": \"count\", \"short\": \"n\", \"type\": \"int\", \"default\": 1}]\n" +
+				"flags = flags.append(#{\"name\": \"tag\", \"short\": \"t\", \"type\": \"list\"})\n" +
+				"flags = flags.append(#{\"name\": \"dry-run\", \"type\": \"bool\"})\n" +
+				"flags = flags.append(#{\"name\": \"mode\", \"choices\": [\"fast\", \"safe\"], \"default\": \"safe\"})\n" +
+				"spec = #{\"name\": \"copy\", \"flags\": flags, \"positionals\": [#{\"name\": \"src\"}, #{\"name\": \"dest\", \"variadic\": true}]}\n" +
+				"print(args())\n" +
+				"o = cli.parse_args(spec, [\"-vn3\", \"--tag\", \"a\", \"-tb\", \"--dry-run\", \"in\", \"x\", \"--\", \"-y\"])\n" +
+				"print(o.verbose, o.count, o.tag, o.dry_run, o.mode, o.src, o.dest, o.help)\n" +
+				"o = cli.parse_args(spec, [\"--count=-2\", \"--verbose=false\", \"-5\"])\n" +
+				"print(o.verbose, o.count, o.tag, o.src, o.dest)\n" +
+				"print(cli.parse_args(spec, [\"-h\"]).help)\n" +
+				"func fails(argv) {\n" +
+				"  try { cli.parse_args(spec, argv) } catch (e) { return e.message }\n" +
+				"  return \"ok\"\n" +
+				"}\n" +
+				"print(fails([]))\n" +
+				"print(fails([\"-q\", \"a\"]))\n" +
+				"print(fails([\"a\", \"--count\"]))\n" +
+				"print(fails([\"a\", \"-n\", \"two\"]))\n" +
+				"print(fails([\"a\", \"--mode\", \"slow\"]))\n" +
+				"print(cli.help(spec))\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "[]\n" +
+					"true 3 [a, b] true safe in [x, -y] false\n" +
+					"false -2 [] -5 []\n" +
+					"true\n" +
+					"cli: missing argument <src>\n" +
+					"cli: unknown flag -q\n" +
+					"cli: flag --count needs a value\n" +
+					"cli: -n expects an integer, got \"two\"\n" +
+					"cli: --mode must be one of fast, safe, got \"slow\"\n" +
+					"usage: copy [options] <src> [dest...]\n" +
+					"\n" +
+					"arguments:\n" +
+					"  src\n" +
+					"  dest\n" +
+					"\n" +
+					"options:\n" +
+					"  -h, --help         show this help\n" +
+					"  -v, --verbose\n" +
+					"  -n, --count <int>  (default: 1)\n" +
+					"  -t, --tag <value>  (repeatable)\n" +
+					"      --dry-run\n" +
+					"      --mode <mode>  (one of: fast, safe; default: safe)\n" +
+					"\n",
+			}),
+		},
+		{
+			name: "assert_statement",
+			source: "func check(x) {\n" +
+				"  assert x > 0, \"got \" + str(x)\n" +
+				"  assert not (x == 5)\n" +
+				"  return x\n" +
+				"}\n" +
+				"print(check(1))\n" +
+				"try { check(-1) } catch (e) { print(e.message) }\n" +
+				"try { check(5) } catch (e) { print(e.message) }\n" +
+				"try { assert [1][0] == 2, [1] } catch (e) { print(e.message) }\n" +
+				"assert len(\"ab\") == 3\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "1\n" +
+					"assertion failed: x > 0: got -1\n" +
+					"assertion failed: not(x == 5)\n" +
+					"assertion failed: ([1][0]) == 2: [1]\n",
+				ErrContains: "assertion failed: len(\"ab\") == 3",
+			}),
+		},
+		{
+			name: "scope_inspection_builtins",
+			source: "import \"std:stats\" as stats\n" +
+				"total = 10\n" +
+				"func names(d) { return sort(keys(d)) }\n" +
+				"func f(a, b) {\n" +
+				"  c = a + b\n" +
+				"  g = func() { return c + total }\n" +
+				"  print(names(locals()), locals()[\"c\"], dir())\n" +
+				"  return g\n" +
+				"}\n" +
+				"f(1, 2)\n" +
+				"print(names(globals()), dir()[0])\n" +
+				"snap = globals()\n" +
+				"snap[\"total\"] = 99\n" +
+				"print(total, dir(stats)[0:2])\n" +
+				"dir(1)\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "[a, b, c, g] 3 [a, b, c, g]\n" +
+					"[f, names, stats, total] f\n" +
+					"10 [histogram, histogram_range]\n",
+				ErrContains: "dir() expects a module or DICT, got INTEGER",
+			}),
+		},
+		{
+			name: "string_unicode_methods",
+			source: "s = \"cafe\u0301 \U0001f1eb\U0001f1f7!\"\n" +
+				"print(len(s), len(s.graphemes()))\n" +
+				"print(s[4] == \"\u0301\", s[-1], s[-3:-1] == \"\U0001f1eb\U0001f1f7\")\n" +
+				"print(s.graphemes()[3] == \"e\u0301\")\n" +
+				"print(\"Stra\u00dfe\".casefold(), \"\u00c9T\u00c9\".casefold() == \"\u00e9t\u00e9\")\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout: "9 7\ntrue ! true\ntrue\nstrasse true\n",
+			}),
+		},
+		{
+			name: "std_unicode_normalization",
+			source: "import \"std:unicode\" as u\n" +
+				"a = \"caf\u00e9\"\n" +
+				"b = \"cafe\u0301\"\n" +
+				"print(a == b, u.equal(a, b), u.nfc(b) == a, len(u.nfd(a)))\n" +
+				"print(u.equal_fold(\"CAFE\u0301\", a), u.equal_fold(\"STRASSE\", \"stra\u00dfe\"))\n" +
+				"print(u.grapheme_len(b))\n" +
+				"u.normalize(a, \"NFKC\")\n",
+			expect: spectest.ExpectBoth(spectest.Expectation{
+				Stdout:      "false true true 5\ntrue true\n4\n",
+				ErrContains: "unknown form \"NFKC\"",
+			}),
+		},
 		{
 			name:   "array_pop_empty_error",
 			source: "a = []\n" + "a.pop()\n",
@@ -1745,11 +3242,16 @@ func TestSpecBaseline(t *testing.T) {
 				exp := exp
 				t.Run(string(mode), func(t *testing.T) {
 					res := spectest.Run(t, spectest.Options{
-						Mode:      mode,
-						Source:    tc.source,
-						Files:     tc.files,
-						Entry:     tc.entry,
-						MaxMemory: tc.maxMemory,
+						Mode:         mode,
+						Source:       tc.source,
+						Files:        tc.files,
+						Entry:        tc.entry,
+						Prelude:      tc.prelude,
+						Features:     tc.features,
+						Edition:      tc.edition,
+						MaxMemory:    tc.maxMemory,
+						MaxSteps:     tc.maxSteps,
+						MaxRecursion: tc.maxRecursion,
 					})
 					spectest.Assert(t, res, exp)
 				})
@@ -10,7 +10,6 @@ import (
 	"welle/internal/ast"
 	"welle/internal/compiler"
 	"welle/internal/evaluator"
-	"welle/internal/lexer"
 	"welle/internal/module"
 	"welle/internal/object"
 	"welle/internal/parser"
@@ -24,11 +23,22 @@ const (
 )
 
 type Options struct {
-	Mode      Mode
-	Source    string
-	Files     map[string]string
-	Entry     string
-	MaxMemory int64
+	Mode   Mode
+	Source string
+	Files  map[string]string
+	Entry  string
+	// Prelude names a file in Files that every other module gets as its
+	// project prelude, as with `prelude` in welle.toml.
+	Prelude string
+	// Features are the build features BUILD.<name> reports as enabled, as
+	// with `features` in welle.toml.
+	Features []string
+	// Edition is the edition of modules without a #welle pragma, as with
+	// `edition` in welle.toml; "" leaves them on the latest.
+	Edition      string
+	MaxMemory    int64
+	MaxSteps     int64
+	MaxRecursion int
 }
 
 type Expectation struct {
@@ -91,6 +101,9 @@ func Assert(t *testing.T, res Result, exp Expectation) {
 }
 
 func runWithOptions(t *testing.T, opts Options, entryPath, tempDir string) Result {
+	// set_print_options lasts for the process; start each case from the
+	// defaults.
+	object.SetPrintOptions(object.DefaultInspectOptions)
 	switch opts.Mode {
 	case ModeInterpreter:
 		return runInterpreter(t, entryPath, tempDir, opts)
@@ -105,7 +118,8 @@ func runWithOptions(t *testing.T, opts Options, entryPath, tempDir string) Resul
 func runInterpreter(t *testing.T, entryPath, tempDir string, opts Options) Result {
 	res := Result{}
 
-	_, parseErr := parseFile(entryPath)
+	resolver := newResolver(t, tempDir, opts)
+	_, parseErr := parseFile(resolver, entryPath)
 	if parseErr != "" {
 		res.ErrCode = "WP0001"
 		res.ErrMsg = parseErr
@@ -114,11 +128,15 @@ func runInterpreter(t *testing.T, entryPath, tempDir string, opts Options) Resul
 
 	runner := evaluator.NewRunner()
 	runner.SetMaxMemory(opts.MaxMemory)
-	resolver := module.NewResolver(stdRoot(t), []string{tempDir})
+	runner.SetMaxRecursion(opts.MaxRecursion)
+	runner.SetFeatures(opts.Features)
 	runner.SetResolver(resolver)
 	runner.EnableImports()
 
 	obj := runner.RunFile(entryPath)
+	if _, ok := obj.(*object.Error); !ok {
+		obj = runner.Shutdown()
+	}
 	if errObj, ok := obj.(*object.Error); ok {
 		res.ErrMsg = errObj.Message
 	}
@@ -129,37 +147,69 @@ func runInterpreter(t *testing.T, entryPath, tempDir string, opts Options) Resul
 func runVM(t *testing.T, entryPath, tempDir string, opts Options) Result {
 	res := Result{}
 
-	program, parseErr := parseFile(entryPath)
+	resolver := newResolver(t, tempDir, opts)
+	program, parseErr := parseFile(resolver, entryPath)
 	if parseErr != "" {
 		res.ErrCode = "WP0001"
 		res.ErrMsg = parseErr
 		return res
 	}
 
+	src, _ := os.ReadFile(entryPath)
+	if err := resolver.ApplyPrelude(entryPath, string(src), program); err != nil {
+		res.ErrMsg = err.Error()
+		return res
+	}
 	c := compiler.NewWithFile(entryPath)
+	c.SetFeatures(opts.Features)
 	if err := c.Compile(program); err != nil {
 		res.ErrMsg = err.Error()
 		return res
 	}
 	bc := c.Bytecode()
 
-	resolver := module.NewResolver(stdRoot(t), []string{tempDir})
 	loader := module.NewLoader(resolver)
+	loader.Features = opts.Features
 	m := loader.NewVM(bc, entryPath)
 	m.SetMaxMemory(opts.MaxMemory)
-	if err := m.Run(); err != nil {
+	m.SetMaxSteps(opts.MaxSteps)
+	m.SetMaxRecursion(opts.MaxRecursion)
+	err := m.Run()
+	if err == nil {
+		err = m.Shutdown()
+	}
+	if err != nil {
 		res.ErrMsg = err.Error()
 	}
 	return res
 }
 
-func parseFile(path string) (*ast.Program, string) {
+func newResolver(t *testing.T, tempDir string, opts Options) *module.Resolver {
+	t.Helper()
+	resolver := module.NewResolver(stdRoot(t), []string{tempDir})
+	if opts.Prelude != "" {
+		if err := resolver.SetPrelude(filepath.Join(tempDir, opts.Prelude), tempDir); err != nil {
+			t.Fatalf("failed to set prelude: %v", err)
+		}
+	}
+	if opts.Edition != "" {
+		e, err := parser.ParseEdition(opts.Edition)
+		if err != nil {
+			t.Fatalf("bad edition: %v", err)
+		}
+		if err := resolver.SetEdition(e, tempDir); err != nil {
+			t.Fatalf("failed to set edition: %v", err)
+		}
+	}
+	return resolver
+}
+
+func parseFile(resolver *module.Resolver, path string) (*ast.Program, string) {
 	b, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err.Error()
 	}
-	l := lexer.New(string(b))
-	p := parser.New(l)
+	p := resolver.NewParser(path, string(b))
 	program := p.ParseProgram()
 	if len(p.Errors()) > 0 {
 		return nil, p.Errors()[0]
@@ -0,0 +1,66 @@
+package sqlite
+
+import "strings"
+
+// fileStatement reports the first statement in query that reaches the file
+// system, "ATTACH" or "VACUUM INTO", or "" if there is none. database/sql
+// gives no access to the driver's authorizer or sqlite3_limit, so in-memory
+// databases opened without -allow-fs check statements here instead. The
+// scan skips comments, string literals and quoted identifiers, and looks at
+// the first keyword of each statement, where SQLite's grammar puts ATTACH.
+func fileStatement(query string) string {
+	first := ""
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ';':
+			first = ""
+			i++
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return ""
+			}
+			i += end + 1
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return ""
+			}
+			i += end + 4
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			end := strings.IndexByte(query[i+1:], closing)
+			if end < 0 {
+				return ""
+			}
+			i += end + 2
+		case isWordByte(c) && (c < '0' || c > '9'):
+			j := i
+			for j < len(query) && isWordByte(query[j]) {
+				j++
+			}
+			word := strings.ToUpper(query[i:j])
+			i = j
+			if first == "" {
+				first = word
+				if word == "ATTACH" {
+					return "ATTACH"
+				}
+			} else if first == "VACUUM" && word == "INTO" {
+				return "VACUUM INTO"
+			}
+		default:
+			i++
+		}
+	}
+	return ""
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
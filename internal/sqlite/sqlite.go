@@ -0,0 +1,317 @@
+// Package sqlite backs std:sqlite. It reaches SQLite through database/sql,
+// using whichever driver the binary links in under the name "sqlite" or
+// "sqlite3": cmd/welle links modernc.org/sqlite, and programs embedding
+// welle bring their own. Open databases are kept in a registry and scripts
+// refer to them by integer handle.
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"welle/internal/object"
+	"welle/internal/runtimeio"
+)
+
+// ErrNoDriver is returned by Open when no SQLite driver is registered.
+var ErrNoDriver = errors.New("sqlite: no SQLite driver is linked into this build")
+
+type conn struct {
+	db *sql.DB
+	tx *sql.Tx
+	// noFiles is set on in-memory databases opened without -allow-fs,
+	// which may not attach or write database files.
+	noFiles bool
+}
+
+// check rejects a statement that would reach the file system from a
+// database without the capability.
+func (c *conn) check(query string) error {
+	if !c.noFiles {
+		return nil
+	}
+	if stmt := fileStatement(query); stmt != "" {
+		return fmt.Errorf("sqlite: %s needs file system access (run with -allow-fs)", stmt)
+	}
+	return nil
+}
+
+// querier is the open transaction if there is one, otherwise the database.
+func (c *conn) querier() interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	Exec(query string, args ...any) (sql.Result, error)
+} {
+	if c.tx != nil {
+		return c.tx
+	}
+	return c.db
+}
+
+var (
+	mu     sync.Mutex
+	conns        = map[int64]*conn{}
+	nextID int64 = 1
+)
+
+func driverName() (string, bool) {
+	for _, name := range sql.Drivers() {
+		if name == "sqlite" || name == "sqlite3" {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// inMemory reports whether path names a private in-memory database. Any
+// other file: URI, even one starting file::memory:, may name a file.
+func inMemory(path string) bool {
+	return path == ":memory:" || path == "file::memory:"
+}
+
+// Open opens the database at path and returns its handle. In-memory
+// databases are always allowed, but without the file system capability
+// they cannot ATTACH other databases; anything else needs the capability.
+func Open(path string) (int64, error) {
+	if !inMemory(path) && !runtimeio.AllowFS() {
+		return 0, fmt.Errorf("sqlite: opening %q needs file system access (run with -allow-fs)", path)
+	}
+	name, ok := driverName()
+	if !ok {
+		return 0, ErrNoDriver
+	}
+	db, err := sql.Open(name, path)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite: %v", err)
+	}
+	// One connection keeps an in-memory database alive between calls and
+	// keeps every statement inside an open transaction.
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return 0, fmt.Errorf("sqlite: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	id := nextID
+	nextID++
+	conns[id] = &conn{db: db, noFiles: !runtimeio.AllowFS()}
+	return id, nil
+}
+
+func lookupConn(id int64) (*conn, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	c, ok := conns[id]
+	if !ok {
+		return nil, fmt.Errorf("sqlite: database %d is not open", id)
+	}
+	return c, nil
+}
+
+// Close rolls back any open transaction and closes the database.
+func Close(id int64) error {
+	c, err := lookupConn(id)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	delete(conns, id)
+	mu.Unlock()
+	if c.tx != nil {
+		c.tx.Rollback()
+	}
+	if err := c.db.Close(); err != nil {
+		return fmt.Errorf("sqlite: %v", err)
+	}
+	return nil
+}
+
+// Query runs a statement that returns rows and gives one DICT per row,
+// keyed by column name.
+func Query(id int64, query string, params object.Object) (*object.Array, error) {
+	c, err := lookupConn(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.check(query); err != nil {
+		return nil, err
+	}
+	args, err := bindParams(params)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.querier().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: %v", err)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: %v", err)
+	}
+	out := &object.Array{}
+	vals := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("sqlite: %v", err)
+		}
+		row := &object.Dict{Pairs: map[object.HashKey]object.DictPair{}}
+		for i, col := range cols {
+			k := &object.String{Value: col}
+			hk, _ := object.HashKeyOf(k)
+			row.Pairs[hk] = object.DictPair{Key: k, Value: toObject(vals[i])}
+		}
+		out.Elements = append(out.Elements, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite: %v", err)
+	}
+	return out, nil
+}
+
+// Exec runs a statement that returns no rows and reports the affected row
+// count and last inserted row id.
+func Exec(id int64, query string, params object.Object) (changes, lastID int64, err error) {
+	c, err := lookupConn(id)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := c.check(query); err != nil {
+		return 0, 0, err
+	}
+	args, err := bindParams(params)
+	if err != nil {
+		return 0, 0, err
+	}
+	res, err := c.querier().Exec(query, args...)
+	if err != nil {
+		return 0, 0, fmt.Errorf("sqlite: %v", err)
+	}
+	changes, _ = res.RowsAffected()
+	lastID, _ = res.LastInsertId()
+	return changes, lastID, nil
+}
+
+// Begin starts a transaction; statements run inside it until Commit or
+// Rollback. Transactions do not nest.
+func Begin(id int64) error {
+	c, err := lookupConn(id)
+	if err != nil {
+		return err
+	}
+	if c.tx != nil {
+		return errors.New("sqlite: a transaction is already open")
+	}
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("sqlite: %v", err)
+	}
+	c.tx = tx
+	return nil
+}
+
+// Commit ends the open transaction and keeps its changes.
+func Commit(id int64) error {
+	return endTx(id, (*sql.Tx).Commit)
+}
+
+// Rollback ends the open transaction and discards its changes.
+func Rollback(id int64) error {
+	return endTx(id, (*sql.Tx).Rollback)
+}
+
+func endTx(id int64, end func(*sql.Tx) error) error {
+	c, err := lookupConn(id)
+	if err != nil {
+		return err
+	}
+	if c.tx == nil {
+		return errors.New("sqlite: no transaction is open")
+	}
+	tx := c.tx
+	c.tx = nil
+	if err := end(tx); err != nil {
+		return fmt.Errorf("sqlite: %v", err)
+	}
+	return nil
+}
+
+// bindParams turns an ARRAY into positional (?) arguments and a DICT into
+// named (:name, @name, $name) ones. nil binds nothing.
+func bindParams(params object.Object) ([]any, error) {
+	switch p := params.(type) {
+	case nil, *object.Nil:
+		return nil, nil
+	case *object.Array:
+		args := make([]any, len(p.Elements))
+		for i, el := range p.Elements {
+			v, err := toSQL(el)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		return args, nil
+	case *object.Dict:
+		var args []any
+		for _, pair := range object.SortedDictPairs(p) {
+			k, ok := pair.Key.(*object.String)
+			if !ok {
+				return nil, fmt.Errorf("sqlite: named parameters must have STRING keys, got %s", pair.Key.Type())
+			}
+			v, err := toSQL(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, sql.Named(strings.TrimLeft(k.Value, ":@$"), v))
+		}
+		return args, nil
+	}
+	return nil, fmt.Errorf("sqlite: params must be ARRAY or DICT, got %s", params.Type())
+}
+
+func toSQL(v object.Object) (any, error) {
+	switch v := v.(type) {
+	case *object.Integer:
+		return v.Value, nil
+	case *object.Float:
+		return v.Value, nil
+	case *object.String:
+		return v.Value, nil
+	case *object.Boolean:
+		return v.Value, nil
+	case *object.Nil:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("sqlite: cannot bind %s", v.Type())
+}
+
+// toObject converts a scanned column value. BLOBs become STRINGs of their
+// bytes and times are formatted as RFC 3339.
+func toObject(v any) object.Object {
+	switch v := v.(type) {
+	case nil:
+		return &object.Nil{}
+	case int64:
+		return &object.Integer{Value: v}
+	case float64:
+		return &object.Float{Value: v}
+	case bool:
+		return &object.Boolean{Value: v}
+	case string:
+		return &object.String{Value: v}
+	case []byte:
+		return &object.String{Value: string(v)}
+	case time.Time:
+		return &object.String{Value: v.Format(time.RFC3339Nano)}
+	}
+	return &object.String{Value: fmt.Sprint(v)}
+}
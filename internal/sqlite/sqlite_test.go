@@ -0,0 +1,165 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"welle/internal/object"
+	"welle/internal/runtimeio"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestQueryExecAndTransactions(t *testing.T) {
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer Close(db)
+
+	if _, _, err := Exec(db, "CREATE TABLE people (name, age)", nil); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	params := &object.Array{Elements: []object.Object{&object.String{Value: "ann"}, &object.Integer{Value: 31}}}
+	if n, id, err := Exec(db, "INSERT INTO people VALUES (?, ?)", params); err != nil || n != 1 || id != 1 {
+		t.Fatalf("insert: changes=%d id=%d err=%v", n, id, err)
+	}
+	named := &object.Dict{Pairs: map[object.HashKey]object.DictPair{}}
+	for k, v := range map[string]object.Object{":name": &object.String{Value: "bob"}, "age": &object.Nil{}} {
+		key := &object.String{Value: k}
+		hk, _ := object.HashKeyOf(key)
+		named.Pairs[hk] = object.DictPair{Key: key, Value: v}
+	}
+	if _, _, err := Exec(db, "INSERT INTO people VALUES (:name, :age)", named); err != nil {
+		t.Fatalf("named insert: %v", err)
+	}
+
+	rows, err := Query(db, "SELECT * FROM people", &object.Array{})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if got := rows.Inspect(); got != `[#{"age": 31, "name": ann}, #{"age": nil, "name": bob}]` {
+		t.Fatalf("unexpected rows: %s", got)
+	}
+
+	if err := Begin(db); err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if err := Begin(db); err == nil || !strings.Contains(err.Error(), "already open") {
+		t.Fatalf("expected nested begin to fail, got %v", err)
+	}
+	if _, _, err := Exec(db, "INSERT INTO people VALUES (?, ?)", params); err != nil {
+		t.Fatalf("insert in tx: %v", err)
+	}
+	if err := Rollback(db); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	rows, _ = Query(db, "SELECT * FROM people WHERE name = ?", &object.Array{Elements: []object.Object{&object.String{Value: "ann"}}})
+	if len(rows.Elements) != 1 {
+		t.Fatalf("rollback kept the insert: %s", rows.Inspect())
+	}
+	if err := Commit(db); err == nil || err.Error() != "sqlite: no transaction is open" {
+		t.Fatalf("expected commit without begin to fail, got %v", err)
+	}
+}
+
+func TestBindErrorsAndCapability(t *testing.T) {
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	bad := &object.Array{Elements: []object.Object{&object.Array{}}}
+	if _, _, err := Exec(db, "CREATE TABLE t (a)", bad); err == nil || err.Error() != "sqlite: cannot bind ARRAY" {
+		t.Fatalf("expected bind error, got %v", err)
+	}
+	if _, err := Query(db, "SELECT * FROM missing", nil); err == nil || !strings.HasPrefix(err.Error(), "sqlite: ") || !strings.Contains(err.Error(), "no such table") {
+		t.Fatalf("expected driver error, got %v", err)
+	}
+	if err := Close(db); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := Query(db, "SELECT * FROM t", nil); err == nil || !strings.Contains(err.Error(), "is not open") {
+		t.Fatalf("expected closed handle error, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "data.db")
+	runtimeio.SetAllowFS(false)
+	if _, err := Open(path); err == nil || !strings.Contains(err.Error(), "-allow-fs") {
+		t.Fatalf("expected capability error, got %v", err)
+	}
+	runtimeio.SetAllowFS(true)
+	defer runtimeio.SetAllowFS(false)
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("open with -allow-fs: %v", err)
+	}
+	if _, _, err := Exec(db, "CREATE TABLE kv (k TEXT PRIMARY KEY, v REAL)", nil); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, _, err := Exec(db, "INSERT INTO kv VALUES ('pi', 3.5)", nil); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	Close(db)
+
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer Close(db)
+	rows, err := Query(db, "SELECT k, v, typeof(v) AS t FROM kv", nil)
+	if err != nil || rows.Inspect() != `[#{"k": pi, "t": real, "v": 3.5}]` {
+		t.Fatalf("expected the row to persist, got %v (%v)", rows, err)
+	}
+	if _, _, err := Exec(db, "INSERT INTO kv VALUES ('pi', 1)", nil); err == nil || !strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		t.Fatalf("expected a constraint error, got %v", err)
+	}
+}
+
+func TestInMemoryCannotReachFilesWithoutCapability(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "zz.db")
+	runtimeio.SetAllowFS(false)
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer Close(db)
+
+	for _, query := range []string{
+		"ATTACH DATABASE '" + target + "' AS z",
+		"  /* c */ attach '" + target + "' as z",
+		"SELECT 1; -- ';\nATTACH '" + target + "' AS z",
+		"VACUUM INTO '" + target + "'",
+	} {
+		if _, _, err := Exec(db, query, nil); err == nil || !strings.Contains(err.Error(), "-allow-fs") {
+			t.Fatalf("%q: expected capability error, got %v", query, err)
+		}
+		if _, err := Query(db, query, nil); err == nil || !strings.Contains(err.Error(), "-allow-fs") {
+			t.Fatalf("%q: expected capability error from query, got %v", query, err)
+		}
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*")); len(matches) != 0 {
+		t.Fatalf("expected no files, got %v", matches)
+	}
+	if _, _, err := Exec(db, "CREATE TABLE t (\"attach\", note); INSERT INTO t VALUES (1, 'vacuum into x')", nil); err != nil {
+		t.Fatalf("expected ordinary statements to run, got %v", err)
+	}
+
+	for _, path := range []string{"file::memory:x", "file:" + target, "file::memory:?vfs=unix"} {
+		if _, err := Open(path); err == nil || !strings.Contains(err.Error(), "-allow-fs") {
+			t.Fatalf("%q: expected capability error, got %v", path, err)
+		}
+	}
+
+	runtimeio.SetAllowFS(true)
+	defer runtimeio.SetAllowFS(false)
+	full, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open with -allow-fs: %v", err)
+	}
+	defer Close(full)
+	if _, _, err := Exec(full, "ATTACH DATABASE '"+target+"' AS z", nil); err != nil {
+		t.Fatalf("attach with -allow-fs: %v", err)
+	}
+}
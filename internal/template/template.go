@@ -0,0 +1,322 @@
+// Package template renders the mustache-style templates behind
+// std:template. A template is text with tags:
+//
+//	{{name}}               the value of name, escaped
+//	{{{name}}} {{&name}}   the value of name, unescaped
+//	{{#name}}...{{/name}}  the block once per element of an array, once
+//	                       with a truthy value on top of the context, or
+//	                       not at all for a falsy value or empty array
+//	{{^name}}...{{/name}}  the block only when {{#name}} would skip it
+//	{{! comment }}         nothing
+//
+// Names are looked up in the innermost context first: the elements and
+// values pushed by enclosing sections, then the data passed to Render. A
+// dotted name (a.b) looks up its first part that way and the rest inside
+// it; "." is the innermost context itself. A missing name renders as
+// nothing. A section, inverted section or comment tag alone on its line
+// removes the whole line, so block tags do not leave blank lines behind.
+package template
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"welle/internal/object"
+)
+
+// Modes lists the escaping modes Render accepts: html escapes &, <, >, "
+// and ', json escapes for the inside of a JSON string literal, and none
+// writes values as they are.
+var Modes = []string{"html", "json", "none"}
+
+type nodeKind int
+
+const (
+	textNode nodeKind = iota
+	valueNode
+	sectionNode
+)
+
+type node struct {
+	kind     nodeKind
+	text     string // textNode: the text; otherwise the tag's name
+	raw      bool   // valueNode: written without escaping
+	inverted bool   // sectionNode: {{^name}}
+	children []node
+}
+
+// Render renders src with data as the outermost context, escaping
+// interpolated values as mode says.
+func Render(src string, data object.Object, mode string) (string, error) {
+	escape, ok := escapers[mode]
+	if !ok {
+		return "", fmt.Errorf("template: unknown escape mode %q (want %s)", mode, strings.Join(Modes, ", "))
+	}
+	nodes, err := parse(src)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	render(&b, nodes, []object.Object{data}, escape)
+	return b.String(), nil
+}
+
+var escapers = map[string]func(string) string{
+	"html": html.EscapeString,
+	"json": escapeJSON,
+	"none": func(s string) string { return s },
+}
+
+// section is a section being parsed: its opening tag and what has been
+// read inside it so far.
+type section struct {
+	open  node
+	line  int
+	nodes []node
+}
+
+func parse(src string) ([]node, error) {
+	stack := []*section{{}}
+	top := func() *section { return stack[len(stack)-1] }
+	pos, line, counted := 0, 1, 0
+	for {
+		rel := strings.Index(src[pos:], "{{")
+		if rel < 0 {
+			top().nodes = appendText(top().nodes, src[pos:])
+			break
+		}
+		start := pos + rel
+		line += strings.Count(src[counted:start], "\n")
+		counted = start
+		tag, end, err := readTag(src, start)
+		if err != nil {
+			return nil, lineError(line, err.Error())
+		}
+		text := src[pos:start]
+		next := end
+		if tag != "" && strings.ContainsRune("#^/!", rune(tag[0])) {
+			if lo, hi, ok := standalone(src, pos, start, end); ok {
+				text = src[pos:lo]
+				next = hi
+			}
+		}
+		top().nodes = appendText(top().nodes, text)
+		pos = next
+
+		if tag == "" {
+			return nil, lineError(line, "empty tag")
+		}
+		name := strings.TrimSpace(tag[1:])
+		switch tag[0] {
+		case '!':
+		case '#', '^':
+			if name == "" {
+				return nil, lineError(line, "section without a name")
+			}
+			open := node{kind: sectionNode, text: name, inverted: tag[0] == '^'}
+			stack = append(stack, &section{open: open, line: line})
+		case '/':
+			if len(stack) == 1 {
+				return nil, lineError(line, fmt.Sprintf("{{/%s}} closes no section", name))
+			}
+			s := top()
+			if name != s.open.text {
+				return nil, lineError(line, fmt.Sprintf("{{/%s}} closes {{%s%s}} from line %d", name, sectionSigil(s.open), s.open.text, s.line))
+			}
+			stack = stack[:len(stack)-1]
+			s.open.children = s.nodes
+			top().nodes = append(top().nodes, s.open)
+		case '{', '&':
+			if name == "" {
+				return nil, lineError(line, "empty tag")
+			}
+			top().nodes = append(top().nodes, node{kind: valueNode, text: name, raw: true})
+		case '>', '=':
+			return nil, lineError(line, fmt.Sprintf("unsupported tag {{%c", tag[0]))
+		default:
+			top().nodes = append(top().nodes, node{kind: valueNode, text: strings.TrimSpace(tag)})
+		}
+	}
+	if len(stack) > 1 {
+		s := top()
+		return nil, lineError(s.line, fmt.Sprintf("unclosed section {{%s%s}}", sectionSigil(s.open), s.open.text))
+	}
+	return stack[0].nodes, nil
+}
+
+// readTag reads the tag starting at src[start], which is "{{". It returns
+// the text between the braces, with a triple-brace tag as "{name", and the
+// index just past the tag.
+func readTag(src string, start int) (string, int, error) {
+	body := start + 2
+	if strings.HasPrefix(src[body:], "{") {
+		rel := strings.Index(src[body:], "}}}")
+		if rel < 0 {
+			return "", 0, fmt.Errorf("unclosed tag {{{")
+		}
+		return src[body : body+rel], body + rel + 3, nil
+	}
+	rel := strings.Index(src[body:], "}}")
+	if rel < 0 {
+		return "", 0, fmt.Errorf("unclosed tag {{")
+	}
+	return strings.TrimSpace(src[body : body+rel]), body + rel + 2, nil
+}
+
+// standalone reports whether the tag at src[start:end] is alone on its
+// line, with only spaces and tabs around it and no other tag since pos. If
+// so, src[lo:hi] is the line to drop, from its indentation through its
+// newline.
+func standalone(src string, pos, start, end int) (lo, hi int, ok bool) {
+	lo = strings.LastIndexByte(src[:start], '\n') + 1
+	if lo < pos || strings.TrimLeft(src[lo:start], " \t") != "" {
+		return 0, 0, false
+	}
+	rest := src[end:]
+	nl := strings.IndexByte(rest, '\n')
+	if nl < 0 {
+		nl = len(rest)
+	} else {
+		nl++
+	}
+	if strings.TrimRight(rest[:nl], " \t\r\n") != "" {
+		return 0, 0, false
+	}
+	return lo, end + nl, true
+}
+
+func appendText(nodes []node, text string) []node {
+	if text == "" {
+		return nodes
+	}
+	return append(nodes, node{kind: textNode, text: text})
+}
+
+func sectionSigil(n node) string {
+	if n.inverted {
+		return "^"
+	}
+	return "#"
+}
+
+func lineError(line int, msg string) error {
+	return fmt.Errorf("template: line %d: %s", line, msg)
+}
+
+func render(b *strings.Builder, nodes []node, ctx []object.Object, escape func(string) string) {
+	for _, n := range nodes {
+		switch n.kind {
+		case textNode:
+			b.WriteString(n.text)
+		case valueNode:
+			s := text(lookup(ctx, n.text))
+			if !n.raw {
+				s = escape(s)
+			}
+			b.WriteString(s)
+		case sectionNode:
+			val := lookup(ctx, n.text)
+			arr, isArr := val.(*object.Array)
+			shown := truthy(val) && (!isArr || len(arr.Elements) > 0)
+			switch {
+			case n.inverted:
+				if !shown {
+					render(b, n.children, ctx, escape)
+				}
+			case !shown:
+			case isArr:
+				for _, el := range arr.Elements {
+					render(b, n.children, append(ctx, el), escape)
+				}
+			default:
+				render(b, n.children, append(ctx, val), escape)
+			}
+		}
+	}
+}
+
+// truthy is Welle's truthiness: only nil and false are false. A missing
+// name (nil here) is false too.
+func truthy(val object.Object) bool {
+	switch v := val.(type) {
+	case nil, *object.Nil:
+		return false
+	case *object.Boolean:
+		return v.Value
+	}
+	return true
+}
+
+// lookup resolves name in ctx, innermost first, or returns nil.
+func lookup(ctx []object.Object, name string) object.Object {
+	if name == "." {
+		return ctx[len(ctx)-1]
+	}
+	parts := strings.Split(name, ".")
+	for i := len(ctx) - 1; i >= 0; i-- {
+		val, ok := member(ctx[i], parts[0])
+		if !ok {
+			continue
+		}
+		for _, part := range parts[1:] {
+			if val, ok = member(val, part); !ok {
+				return nil
+			}
+		}
+		return val
+	}
+	return nil
+}
+
+// member returns the value called name in a dict (a string key) or in a
+// value with members, such as a named tuple.
+func member(val object.Object, name string) (object.Object, bool) {
+	switch v := val.(type) {
+	case *object.Dict:
+		pair, ok := v.Pairs[object.StringKey(name)]
+		return pair.Value, ok
+	case object.MemberGetter:
+		return v.GetMember(name)
+	}
+	return nil, false
+}
+
+// text is how a value is written: a string as itself, nil as nothing and
+// anything else as print shows it.
+func text(val object.Object) string {
+	switch v := val.(type) {
+	case nil, *object.Nil:
+		return ""
+	case *object.String:
+		return v.Value
+	default:
+		return v.Inspect()
+	}
+}
+
+func escapeJSON(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == utf8.RuneError {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
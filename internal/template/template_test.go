@@ -0,0 +1,94 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"welle/internal/object"
+)
+
+func dict(pairs ...any) *object.Dict {
+	d := &object.Dict{Pairs: map[object.HashKey]object.DictPair{}}
+	for i := 0; i < len(pairs); i += 2 {
+		key := &object.String{Value: pairs[i].(string)}
+		d.Pairs[object.StringKey(key.Value)] = object.DictPair{Key: key, Value: pairs[i+1].(object.Object)}
+	}
+	return d
+}
+
+func str(s string) object.Object { return &object.String{Value: s} }
+
+func TestRender(t *testing.T) {
+	items := &object.Array{Elements: []object.Object{
+		dict("name", str("a"), "done", &object.Boolean{Value: true}),
+		dict("name", str("b")),
+	}}
+	data := dict(
+		"title", str("Tom & <Jerry>"),
+		"items", items,
+		"none", &object.Array{},
+		"user", dict("name", str("amy"), "age", &object.Integer{Value: 30}),
+		"point", &object.Tuple{Elements: []object.Object{&object.Integer{Value: 1}}, Names: []string{"x"}},
+	)
+	tests := []struct {
+		src  string
+		mode string
+		want string
+	}{
+		{"Hello {{title}}!", "html", "Hello Tom &amp; &lt;Jerry&gt;!"},
+		{"{{{title}}}|{{& title}}", "html", "Tom & <Jerry>|Tom & <Jerry>"},
+		{"{{title}}", "none", "Tom & <Jerry>"},
+		{"{{user.name}} is {{user.age}}, x={{point.x}}, {{user.nope}}{{nope.name}}.", "html", "amy is 30, x=1, ."},
+		{"{{#items}}{{name}}{{#done}}!{{/done}},{{/items}}", "html", "a!,b,"},
+		{"{{#user}}{{name}} in {{title}}{{/user}}", "none", "amy in Tom & <Jerry>"},
+		{"{{#none}}x{{/none}}{{^none}}empty{{/none}}{{^user}}no{{/user}}", "html", "empty"},
+		{"a\n  {{#items}}\n  - {{name}}\n  {{/items}}\nb\n", "html", "a\n  - a\n  - b\nb\n"},
+		{"{{! note }}\nx {{! inline }}y\n", "html", "x y\n"},
+		{"{{#items}}{{/items}}\n", "html", "\n"},
+	}
+	for _, tt := range tests {
+		got, err := Render(tt.src, data, tt.mode)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestRenderEscapeJSON(t *testing.T) {
+	got, err := Render("{{s}}", dict("s", str("\"a\\b\"\n\x01é")), "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := `\"a\\b\"\n\u0001é`; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"{{#a}}\nx\n", "template: line 1: unclosed section {{#a}}"},
+		{"{{#a}}\n{{^b}}{{/a}}", "template: line 2: {{/a}} closes {{^b}} from line 2"},
+		{"x\n{{/a}}", "template: line 2: {{/a}} closes no section"},
+		{"{{name", "unclosed tag {{"},
+		{"{{{name}}", "unclosed tag {{{"},
+		{"{{}}", "empty tag"},
+		{"{{#}}", "section without a name"},
+		{"{{> part}}", "unsupported tag {{>"},
+	}
+	for _, tt := range tests {
+		_, err := Render(tt.src, dict(), "html")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: expected error containing %q, got %v", tt.src, tt.want, err)
+		}
+	}
+	if _, err := Render("x", dict(), "xml"); err == nil || !strings.Contains(err.Error(), `unknown escape mode "xml"`) {
+		t.Errorf("expected an unknown mode error, got %v", err)
+	}
+}
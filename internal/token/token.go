@@ -51,13 +51,17 @@ const (
 	CATCH    Type = "CATCH"
 	FINALLY  Type = "FINALLY"
 	THROW    Type = "THROW"
+	ASSERT   Type = "ASSERT"
 	DEFER    Type = "DEFER"
+	DEL      Type = "DEL"
 	EXPORT   Type = "EXPORT"
 	SWITCH   Type = "SWITCH"
 	MATCH    Type = "MATCH"
 	CASE     Type = "CASE"
 	DEFAULT  Type = "DEFAULT"
 	PASS     Type = "PASS"
+	CLASS    Type = "CLASS"
+	YIELD    Type = "YIELD"
 
 	// Operators
 	ASSIGN   Type = "="
@@ -92,6 +96,7 @@ const (
 	GE Type = ">="
 
 	// Delimiters
+	AT       Type = "@"
 	HASH     Type = "#"
 	COMMA    Type = ","
 	COLON    Type = ":"
@@ -130,13 +135,17 @@ var keywords = map[string]Type{
 	"catch":    CATCH,
 	"finally":  FINALLY,
 	"throw":    THROW,
+	"assert":   ASSERT,
 	"defer":    DEFER,
+	"del":      DEL,
 	"export":   EXPORT,
 	"switch":   SWITCH,
 	"match":    MATCH,
 	"case":     CASE,
 	"default":  DEFAULT,
 	"pass":     PASS,
+	"class":    CLASS,
+	"yield":    YIELD,
 }
 
 func LookupIdent(ident string) Type {
@@ -145,3 +154,9 @@ func LookupIdent(ident string) Type {
 	}
 	return IDENT
 }
+
+// IsKeyword reports whether ident is a reserved word.
+func IsKeyword(ident string) bool {
+	_, ok := keywords[ident]
+	return ok
+}
@@ -0,0 +1,47 @@
+// Command genstd precompiles the std library into std/compiled. It runs
+// from the std directory through `go generate ./std`.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"welle/internal/module"
+)
+
+func main() {
+	files, err := filepath.Glob("*.wll")
+	if err != nil {
+		fail(err)
+	}
+	stale, err := filepath.Glob(filepath.Join("compiled", "*.wbc"))
+	if err != nil {
+		fail(err)
+	}
+	for _, f := range stale {
+		if err := os.Remove(f); err != nil {
+			fail(err)
+		}
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fail(err)
+		}
+		out, err := module.PrecompileStdModule(file, src)
+		if err != nil {
+			fail(err)
+		}
+		dst := filepath.Join("compiled", strings.TrimSuffix(file, ".wll")+".wbc")
+		if err := os.WriteFile(dst, out, 0o644); err != nil {
+			fail(err)
+		}
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "genstd:", err)
+	os.Exit(1)
+}
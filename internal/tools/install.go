@@ -11,6 +11,8 @@ type InstallOptions struct {
 	BinDir string
 }
 
+// Install builds welle and welle-lsp into opts.BinDir. Both binaries embed
+// the std library, so they keep working when copied out of the checkout.
 func Install(opts InstallOptions) error {
 	if opts.BinDir == "" {
 		opts.BinDir = "bin"
@@ -0,0 +1,141 @@
+package tools
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// vscodeFiles mirrors the sources of the vscode-welle extension at the repo
+// root (a test keeps the two in step), so the generator works from an
+// installed welle binary.
+//
+//go:embed vscode
+var vscodeFiles embed.FS
+
+type VSCodeOptions struct {
+	// Dir receives the extension. It must not exist or be empty unless
+	// Force is set.
+	Dir string
+	// LSPPath, when set, is a welle-lsp binary copied into the extension as
+	// its bundled server.
+	LSPPath string
+	Force   bool
+}
+
+// GenVSCode writes an unpacked VS Code extension: language configuration,
+// TextMate grammar, the client that starts welle-lsp and a "welle" debug
+// type that runs programs with welle run.
+func GenVSCode(opts VSCodeOptions) error {
+	if opts.Dir == "" {
+		return fmt.Errorf("gen-vscode needs a target directory")
+	}
+	if !opts.Force {
+		entries, err := os.ReadDir(opts.Dir)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if len(entries) > 0 {
+			return fmt.Errorf("%s is not empty (use --force to overwrite)", opts.Dir)
+		}
+	}
+
+	err := fs.WalkDir(vscodeFiles, "vscode", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := vscodeFiles.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel := p[len("vscode/"):]
+		if rel == "package.json" {
+			if data, err = sideloadManifest(data); err != nil {
+				return err
+			}
+		}
+		return writeFile(filepath.Join(opts.Dir, filepath.FromSlash(rel)), data, 0o644)
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(opts.Dir, "README.md"), []byte(vscodeReadme), 0o644); err != nil {
+		return err
+	}
+	if opts.LSPPath != "" {
+		name := "welle-lsp"
+		if runtime.GOOS == "windows" {
+			name += ".exe"
+		}
+		if err := copyFile(opts.LSPPath, filepath.Join(opts.Dir, "server", name), 0o755); err != nil {
+			return fmt.Errorf("bundle welle-lsp: %w", err)
+		}
+	}
+	return nil
+}
+
+// sideloadManifest drops the parts of package.json that only make sense
+// inside the welle repository: the build scripts, which cd into it, and the
+// icon, which is not copied.
+func sideloadManifest(data []byte) ([]byte, error) {
+	var manifest map[string]any
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("package.json: %w", err)
+	}
+	delete(manifest, "scripts")
+	delete(manifest, "icon")
+	out, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+func writeFile(p string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, perm)
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+const vscodeReadme = `# Welle (VS Code extension)
+
+Generated by ` + "`welle tools gen-vscode`" + `.
+
+Install the client library, then load the folder as an extension:
+
+    npm install
+    ln -s "$PWD" ~/.vscode/extensions/welle   # or: npx @vscode/vsce package
+
+The language server is looked up in this order: ./server/welle-lsp (bundled
+with --lsp), the welle.lspPath setting, <workspace>/bin/welle-lsp, then PATH.
+
+Press F5 in a .wll file to run it with ` + "`welle run`" + ` (the welle.path setting
+picks the binary). Output goes to the Debug Console; breakpoints and
+stepping are not supported.
+`
@@ -0,0 +1,129 @@
+"use strict";
+
+const cp = require("child_process");
+const path = require("path");
+const vscode = require("vscode");
+
+/**
+ * A minimal inline debug adapter: "launch" runs the program with
+ * `welle run` and streams its output to the Debug Console. Breakpoints and
+ * stepping are not supported; the session ends when the program exits.
+ */
+class WelleRunAdapter {
+  constructor(output) {
+    this.output = output;
+    this.seq = 1;
+    this.child = null;
+    this.emitter = new vscode.EventEmitter();
+    this.onDidSendMessage = this.emitter.event;
+  }
+
+  send(msg) {
+    msg.seq = this.seq++;
+    this.emitter.fire(msg);
+  }
+
+  respond(req, body) {
+    this.send({ type: "response", request_seq: req.seq, command: req.command, success: true, body: body || {} });
+  }
+
+  fail(req, message) {
+    this.send({ type: "response", request_seq: req.seq, command: req.command, success: false, message });
+  }
+
+  event(event, body) {
+    this.send({ type: "event", event, body: body || {} });
+  }
+
+  print(category, text) {
+    this.event("output", { category, output: text });
+  }
+
+  handleMessage(req) {
+    switch (req.command) {
+      case "initialize":
+        this.respond(req, { supportsConfigurationDoneRequest: true, supportsTerminateRequest: true });
+        this.event("initialized");
+        return;
+      case "launch":
+        this.launch(req);
+        return;
+      case "threads":
+        this.respond(req, { threads: [{ id: 1, name: "main" }] });
+        return;
+      case "terminate":
+      case "disconnect":
+        if (this.child) this.child.kill();
+        this.respond(req);
+        return;
+      default:
+        this.respond(req);
+    }
+  }
+
+  launch(req) {
+    const args = req.arguments || {};
+    if (!args.program) {
+      this.fail(req, "welle: launch needs a \"program\" to run");
+      return;
+    }
+    const welle = resolveWelle(this.output);
+    const argv = (args.flags || []).concat(["run", args.program, "--"], args.args || []);
+    const cwd = args.cwd || path.dirname(args.program);
+    this.print("console", `${welle} ${argv.join(" ")}\n`);
+    this.child = cp.spawn(welle, argv, { cwd, env: process.env });
+    this.child.stdout.on("data", (d) => this.print("stdout", d.toString()));
+    this.child.stderr.on("data", (d) => this.print("stderr", d.toString()));
+    this.child.on("error", (err) => {
+      this.print("stderr", `welle: ${err.message}\n`);
+      this.event("terminated");
+    });
+    this.child.on("exit", (code) => {
+      this.event("exited", { exitCode: code === null ? 1 : code });
+      this.event("terminated");
+    });
+    this.respond(req);
+  }
+
+  dispose() {
+    if (this.child) this.child.kill();
+    this.emitter.dispose();
+  }
+}
+
+/** The welle binary: the welle.path setting, else "welle" from PATH. */
+function resolveWelle(output) {
+  const configured = vscode.workspace.getConfiguration("welle").get("path");
+  if (configured && typeof configured === "string" && configured.trim() !== "") {
+    return configured.trim();
+  }
+  output.appendLine("[welle] welle.path not set; running welle from PATH");
+  return "welle";
+}
+
+function registerDebugger(context, output) {
+  context.subscriptions.push(
+    vscode.debug.registerDebugAdapterDescriptorFactory("welle", {
+      createDebugAdapterDescriptor() {
+        return new vscode.DebugAdapterInlineImplementation(new WelleRunAdapter(output));
+      },
+    }),
+    vscode.debug.registerDebugConfigurationProvider("welle", {
+      // F5 with no launch.json runs the active .wll file.
+      resolveDebugConfiguration(folder, config) {
+        if (!config.type && !config.request && !config.name) {
+          const doc = vscode.window.activeTextEditor?.document;
+          if (doc && doc.languageId === "welle") {
+            config.type = "welle";
+            config.request = "launch";
+            config.name = "Run Welle file";
+            config.program = doc.uri.fsPath;
+          }
+        }
+        return config;
+      },
+    })
+  );
+}
+
+module.exports = { registerDebugger };
@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVSCodeFilesMatchExtensionSources(t *testing.T) {
+	err := fs.WalkDir(vscodeFiles, "vscode", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		embedded, _ := vscodeFiles.ReadFile(p)
+		src, err := os.ReadFile(filepath.Join("..", "..", "vscode-welle", filepath.FromSlash(strings.TrimPrefix(p, "vscode/"))))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(embedded, src) {
+			t.Errorf("internal/tools/%s is out of date with vscode-welle; copy it over", p)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenVSCode(t *testing.T) {
+	dir := t.TempDir()
+	lsp := filepath.Join(dir, "fake-lsp")
+	if err := os.WriteFile(lsp, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "ext")
+	if err := GenVSCode(VSCodeOptions{Dir: out, LSPPath: lsp}); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"language-configuration.json", "syntaxes/welle.tmLanguage.json", "client/extension.js", "client/debug.js", "README.md"} {
+		if _, err := os.Stat(filepath.Join(out, f)); err != nil {
+			t.Errorf("missing %s: %v", f, err)
+		}
+	}
+	if matches, _ := filepath.Glob(filepath.Join(out, "server", "welle-lsp*")); len(matches) != 1 {
+		t.Errorf("bundled server not copied: %v", matches)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, "package.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest map[string]any
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := manifest["scripts"]; ok {
+		t.Errorf("package.json keeps the in-repo build scripts")
+	}
+	contributes := manifest["contributes"].(map[string]any)
+	if _, ok := contributes["debuggers"]; !ok {
+		t.Errorf("package.json lacks the debugger contribution")
+	}
+
+	if err := GenVSCode(VSCodeOptions{Dir: out}); err == nil || !strings.Contains(err.Error(), "not empty") {
+		t.Errorf("second run without --force: %v", err)
+	}
+	if err := GenVSCode(VSCodeOptions{Dir: out, Force: true}); err != nil {
+		t.Errorf("--force: %v", err)
+	}
+}
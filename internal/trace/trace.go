@@ -0,0 +1,127 @@
+package trace
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxText is how much of a statement's source the evaluator trace prints.
+const maxText = 60
+
+// Tracer writes an execution trace: one line per statement for the
+// evaluator, one per instruction for the VM. It is shared by a run and the
+// modules it imports, and toggled at run time by the trace() builtin.
+type Tracer struct {
+	w     io.Writer
+	on    bool
+	files []string
+	funcs []string
+}
+
+// New returns a disabled tracer that writes to w.
+func New(w io.Writer) *Tracer {
+	return &Tracer{w: w}
+}
+
+// Stderr returns the tracer trace(true) starts when the run has none.
+func Stderr() *Tracer {
+	return New(os.Stderr)
+}
+
+// SetEnabled turns tracing on or off and reports whether it was on.
+func (t *Tracer) SetEnabled(on bool) bool {
+	prev := t.on
+	t.on = on
+	return prev
+}
+
+func (t *Tracer) Enabled() bool {
+	return t != nil && t.on
+}
+
+// SetFilters limits the trace to code in the given files and functions.
+// A file filter matches a path that equals it or ends with "/" + filter, so
+// "main.wll" and "lib/util.wll" both work; a function filter matches the
+// function's name, with "<main>" for top-level code. Empty lists match
+// everything.
+func (t *Tracer) SetFilters(files, funcs []string) {
+	t.files = cleanList(files)
+	t.funcs = cleanList(funcs)
+}
+
+// ParseList splits a comma-separated filter flag.
+func ParseList(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	return cleanList(strings.Split(s, ","))
+}
+
+func cleanList(in []string) []string {
+	var out []string
+	for _, s := range in {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, filepath.ToSlash(s))
+		}
+	}
+	return out
+}
+
+// Match reports whether code at file in function fn passes the filters.
+func (t *Tracer) Match(file, fn string) bool {
+	if len(t.files) > 0 {
+		path := filepath.ToSlash(file)
+		ok := false
+		for _, f := range t.files {
+			if path == f || strings.HasSuffix(path, "/"+f) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(t.funcs) > 0 {
+		for _, f := range t.funcs {
+			if fn == f {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Statement records a statement about to run in the evaluator. text is the
+// statement's source; only its first line is printed, cut to maxText runes.
+func (t *Tracer) Statement(file, fn string, line, col int, text string) {
+	if !t.Enabled() || !t.Match(file, fn) {
+		return
+	}
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i] + " ..."
+	}
+	if r := []rune(text); len(r) > maxText {
+		text = string(r[:maxText]) + "..."
+	}
+	fmt.Fprintf(t.w, "trace %s:%d:%d %s: %s\n", displayFile(file), line, col, fn, text)
+}
+
+// Op records an instruction about to run in the VM.
+func (t *Tracer) Op(file, fn string, line, col, ip int, op string) {
+	if !t.Enabled() || !t.Match(file, fn) {
+		return
+	}
+	fmt.Fprintf(t.w, "trace %s:%d:%d %s: %04d %s\n", displayFile(file), line, col, fn, ip, op)
+}
+
+func displayFile(file string) string {
+	if file == "" {
+		return "<unknown>"
+	}
+	return filepath.Base(file)
+}
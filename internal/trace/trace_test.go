@@ -0,0 +1,56 @@
+package trace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTracerFilters(t *testing.T) {
+	tr := New(&bytes.Buffer{})
+	tr.SetFilters(ParseList(" main.wll, lib/util.wll ,"), nil)
+	cases := map[string]bool{
+		"/src/main.wll":       true,
+		"main.wll":            true,
+		"/src/lib/util.wll":   true,
+		"/src/util.wll":       false,
+		"/src/notmain.wll":    false,
+		"/src/main.wll/x.wll": false,
+	}
+	for file, want := range cases {
+		if got := tr.Match(file, "f"); got != want {
+			t.Errorf("Match(%q) = %v, want %v", file, got, want)
+		}
+	}
+
+	tr.SetFilters(nil, []string{"<main>"})
+	if !tr.Match("a.wll", "<main>") || tr.Match("a.wll", "helper") {
+		t.Fatalf("function filter should only match <main>")
+	}
+}
+
+func TestTracerOutput(t *testing.T) {
+	var buf bytes.Buffer
+	tr := New(&buf)
+	tr.Statement("/src/main.wll", "<main>", 1, 1, "x = 1")
+	if buf.Len() != 0 {
+		t.Fatalf("disabled tracer wrote %q", buf.String())
+	}
+	if prev := tr.SetEnabled(true); prev {
+		t.Fatalf("SetEnabled should report the tracer was off")
+	}
+	tr.Statement("/src/main.wll", "<main>", 1, 1, "if x {\n  y = 2\n}")
+	tr.Statement("/src/main.wll", "f", 2, 3, strings.Repeat("a", 70))
+	tr.Op("", "<anon>", 0, 0, 7, "OpAdd")
+	want := "trace main.wll:1:1 <main>: if x { ...\n" +
+		"trace main.wll:2:3 f: " + strings.Repeat("a", 60) + "...\n" +
+		"trace <unknown>:0:0 <anon>: 0007 OpAdd\n"
+	if buf.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	var nilTracer *Tracer
+	if nilTracer.Enabled() {
+		t.Fatalf("nil tracer should be disabled")
+	}
+}
@@ -0,0 +1,27 @@
+package unitext
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Casefold returns the full Unicode case folding of s, for caseless
+// matching: "Straße" and "STRASSE" fold to the same string.
+func Casefold(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if f, ok := foldExceptions()[r]; ok {
+			b.WriteString(f)
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// EqualFold reports whether a and b are equal after case folding and
+// canonical decomposition.
+func EqualFold(a, b string) bool {
+	return a == b || NFD(Casefold(NFD(a))) == NFD(Casefold(NFD(b)))
+}
@@ -0,0 +1,178 @@
+//go:build ignore
+
+// gen_tables writes tables.go from the Unicode Character Database files
+// UnicodeData.txt, CompositionExclusions.txt and CaseFolding.txt:
+//
+//	go run gen_tables.go -ucd path/to/ucd -version 14.0.0
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func main() {
+	dir := flag.String("ucd", ".", "directory holding the UCD files")
+	version := flag.String("version", "", "Unicode version of the UCD files")
+	out := flag.String("o", "tables.go", "output file")
+	flag.Parse()
+
+	classes := map[rune]uint8{}
+	decomps := map[rune][]rune{}
+	eachLine(filepath.Join(*dir, "UnicodeData.txt"), func(f []string) {
+		r := hexRune(f[0])
+		if c, _ := strconv.Atoi(f[3]); c != 0 {
+			classes[r] = uint8(c)
+		}
+		if f[5] != "" && !strings.HasPrefix(f[5], "<") {
+			decomps[r] = hexRunes(f[5])
+		}
+	})
+
+	excluded := map[rune]bool{}
+	eachLine(filepath.Join(*dir, "CompositionExclusions.txt"), func(f []string) {
+		excluded[hexRune(f[0])] = true
+	})
+
+	folds := map[rune][]rune{}
+	eachLine(filepath.Join(*dir, "CaseFolding.txt"), func(f []string) {
+		if status := strings.TrimSpace(f[1]); status == "C" || status == "F" {
+			folds[hexRune(f[0])] = hexRunes(f[2])
+		}
+	})
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gen_tables.go from Unicode %s; DO NOT EDIT.\n\npackage unitext\n\nimport \"sync\"\n\n", *version)
+
+	buf.WriteString("var combiningClasses = []classRange{\n")
+	var lo, hi rune = -1, -1
+	var cur uint8
+	flush := func() {
+		if lo >= 0 {
+			fmt.Fprintf(&buf, "\t{0x%04X, 0x%04X, %d},\n", lo, hi, cur)
+		}
+	}
+	for _, r := range sortedKeys(classes) {
+		if c := classes[r]; r == hi+1 && c == cur {
+			hi = r
+			continue
+		}
+		flush()
+		lo, hi, cur = r, r, classes[r]
+	}
+	flush()
+	buf.WriteString("}\n\n")
+
+	// The maps are built on first use rather than at program start.
+	buf.WriteString("var decompositions = sync.OnceValue(func() map[rune]string {\nreturn map[rune]string{\n")
+	for _, r := range sortedKeys(decomps) {
+		fmt.Fprintf(&buf, "\t0x%04X: %s,\n", r, quote(decomps[r]))
+	}
+	buf.WriteString("}\n})\n\n")
+
+	// A pair composes unless its composite is excluded, decomposes to a
+	// single code point, or starts with a non-starter (Full_Composition_Exclusion).
+	buf.WriteString("var compositions = sync.OnceValue(func() map[[2]rune]rune {\nreturn map[[2]rune]rune{\n")
+	for _, r := range sortedKeys(decomps) {
+		d := decomps[r]
+		if len(d) != 2 || excluded[r] || classes[r] != 0 || classes[d[0]] != 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "\t{0x%04X, 0x%04X}: 0x%04X,\n", d[0], d[1], r)
+	}
+	buf.WriteString("}\n})\n\n")
+
+	// Only foldings that differ from unicode.ToLower are listed.
+	buf.WriteString("var foldExceptions = sync.OnceValue(func() map[rune]string {\nreturn map[rune]string{\n")
+	for r := rune(0); r <= unicode.MaxRune; r++ {
+		want := []rune{r}
+		if f, ok := folds[r]; ok {
+			want = f
+		}
+		if len(want) == 1 && want[0] == unicode.ToLower(r) {
+			continue
+		}
+		fmt.Fprintf(&buf, "\t0x%04X: %s,\n", r, quote(want))
+	}
+	buf.WriteString("}\n})\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func eachLine(path string, fn func(fields []string)) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		fn(fields)
+	}
+	if err := sc.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func hexRune(s string) rune {
+	v, err := strconv.ParseUint(strings.TrimSpace(s), 16, 32)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return rune(v)
+}
+
+func hexRunes(s string) []rune {
+	var out []rune
+	for _, f := range strings.Fields(s) {
+		out = append(out, hexRune(f))
+	}
+	return out
+}
+
+func quote(rs []rune) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range rs {
+		if r < 0x10000 {
+			fmt.Fprintf(&b, `\u%04X`, r)
+		} else {
+			fmt.Fprintf(&b, `\U%08X`, r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func sortedKeys[V any](m map[rune]V) []rune {
+	keys := make([]rune, 0, len(m))
+	for r := range m {
+		keys = append(keys, r)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
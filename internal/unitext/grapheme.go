@@ -0,0 +1,152 @@
+package unitext
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// gbProp is a code point's Grapheme_Cluster_Break property (UAX #29).
+type gbProp uint8
+
+const (
+	gbOther gbProp = iota
+	gbCR
+	gbLF
+	gbControl
+	gbExtend
+	gbZWJ
+	gbRegional
+	gbSpacingMark
+	gbL
+	gbV
+	gbT
+	gbLV
+	gbLVT
+	gbPictographic
+)
+
+// pictographic approximates Extended_Pictographic, which the unicode
+// package has no table for, with the blocks emoji are drawn from.
+var pictographic = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x00A9, Hi: 0x00AE, Stride: 5},
+		{Lo: 0x203C, Hi: 0x2049, Stride: 13},
+		{Lo: 0x2122, Hi: 0x2139, Stride: 23},
+		{Lo: 0x2194, Hi: 0x21AA, Stride: 1},
+		{Lo: 0x231A, Hi: 0x23FF, Stride: 1},
+		{Lo: 0x24C2, Hi: 0x24C2, Stride: 1},
+		{Lo: 0x25AA, Hi: 0x27BF, Stride: 1},
+		{Lo: 0x2934, Hi: 0x2935, Stride: 1},
+		{Lo: 0x2B05, Hi: 0x2B55, Stride: 1},
+		{Lo: 0x3030, Hi: 0x303D, Stride: 13},
+		{Lo: 0x3297, Hi: 0x3299, Stride: 2},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1F000, Hi: 0x1FAFF, Stride: 1},
+	},
+}
+
+func graphemeProp(r rune) gbProp {
+	switch {
+	case r == '\r':
+		return gbCR
+	case r == '\n':
+		return gbLF
+	case r == 0x200D:
+		return gbZWJ
+	case r == 0x200C, r >= 0x1F3FB && r <= 0x1F3FF, r >= 0xE0020 && r <= 0xE007F:
+		return gbExtend
+	case r >= 0x1F1E6 && r <= 0x1F1FF:
+		return gbRegional
+	case r >= hangulLBase && r <= 0x115F, r >= 0xA960 && r <= 0xA97C:
+		return gbL
+	case r >= 0x1160 && r <= 0x11A7, r >= 0xD7B0 && r <= 0xD7C6:
+		return gbV
+	case r >= 0x11A8 && r <= 0x11FF, r >= 0xD7CB && r <= 0xD7FB:
+		return gbT
+	case r >= hangulBase && r < hangulBase+hangulCount:
+		if (r-hangulBase)%28 == 0 {
+			return gbLV
+		}
+		return gbLVT
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Other_Grapheme_Extend):
+		return gbExtend
+	case unicode.Is(unicode.Mc, r):
+		return gbSpacingMark
+	case unicode.In(r, unicode.Cc, unicode.Cf, unicode.Zl, unicode.Zp):
+		return gbControl
+	case unicode.Is(pictographic, r):
+		return gbPictographic
+	}
+	return gbOther
+}
+
+// Graphemes splits s into extended grapheme clusters, the units a reader
+// sees as one character: "e" plus a combining accent, a flag made of two
+// regional indicators, or an emoji joined with ZWJ are one cluster each.
+func Graphemes(s string) []string {
+	var out []string
+	for len(s) > 0 {
+		n := nextBoundary(s)
+		out = append(out, s[:n])
+		s = s[n:]
+	}
+	return out
+}
+
+// nextBoundary returns the byte length of the cluster at the start of s.
+func nextBoundary(s string) int {
+	r, size := utf8.DecodeRuneInString(s)
+	prev := graphemeProp(r)
+	// Pictographic Extend* ZWJ sequences and regional indicator pairs need
+	// state beyond the previous code point.
+	inPictographic := prev == gbPictographic
+	regionals := 0
+	if prev == gbRegional {
+		regionals = 1
+	}
+	i := size
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		next := graphemeProp(r)
+		if breaksBetween(prev, next, inPictographic, regionals) {
+			break
+		}
+		switch {
+		case next == gbPictographic:
+			inPictographic = true
+		case next != gbExtend && next != gbZWJ:
+			inPictographic = false
+		}
+		if next == gbRegional {
+			regionals++
+		} else {
+			regionals = 0
+		}
+		prev = next
+		i += size
+	}
+	return i
+}
+
+func breaksBetween(prev, next gbProp, inPictographic bool, regionals int) bool {
+	switch {
+	case prev == gbCR && next == gbLF:
+		return false
+	case prev == gbCR, prev == gbLF, prev == gbControl, next == gbCR, next == gbLF, next == gbControl:
+		return true
+	case prev == gbL && (next == gbL || next == gbV || next == gbLV || next == gbLVT):
+		return false
+	case (prev == gbLV || prev == gbV) && (next == gbV || next == gbT):
+		return false
+	case (prev == gbLVT || prev == gbT) && next == gbT:
+		return false
+	case next == gbExtend, next == gbZWJ, next == gbSpacingMark:
+		return false
+	case prev == gbZWJ && next == gbPictographic && inPictographic:
+		return false
+	case prev == gbRegional && next == gbRegional:
+		return regionals%2 == 0
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+// Package unitext implements the Unicode text operations behind the string
+// methods and std:unicode: canonical normalization, case folding and
+// grapheme cluster segmentation. The tables are generated from the Unicode
+// Character Database by gen_tables.go.
+package unitext
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+type classRange struct {
+	lo, hi rune
+	class  uint8
+}
+
+// Hangul syllables decompose and compose algorithmically (Unicode §3.12).
+const (
+	hangulBase  = 0xAC00
+	hangulLBase = 0x1100
+	hangulVBase = 0x1161
+	hangulTBase = 0x11A7
+	hangulLEnd  = hangulLBase + 19
+	hangulVEnd  = hangulVBase + 21
+	hangulTEnd  = hangulTBase + 28
+	hangulCount = 19 * 21 * 28
+)
+
+func combiningClass(r rune) uint8 {
+	i := sort.Search(len(combiningClasses), func(i int) bool { return combiningClasses[i].hi >= r })
+	if i < len(combiningClasses) && combiningClasses[i].lo <= r {
+		return combiningClasses[i].class
+	}
+	return 0
+}
+
+// NFD returns s in canonical decomposition form.
+func NFD(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	return string(decompose(s))
+}
+
+// NFC returns s in canonical composition form.
+func NFC(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	return compose(decompose(s))
+}
+
+// Normalize returns s in the named normalization form, "NFC" or "NFD". It
+// reports false for any other form.
+func Normalize(s, form string) (string, bool) {
+	switch form {
+	case "NFC":
+		return NFC(s), true
+	case "NFD":
+		return NFD(s), true
+	}
+	return "", false
+}
+
+// CanonicallyEqual reports whether a and b are canonically equivalent, such
+// as "é" written precomposed and as "e" plus a combining acute accent.
+func CanonicallyEqual(a, b string) bool {
+	return a == b || NFD(a) == NFD(b)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// decompose fully decomposes s and puts each run of combining marks in
+// canonical order.
+func decompose(s string) []rune {
+	out := make([]rune, 0, len(s))
+	for _, r := range s {
+		out = appendDecomposed(out, r)
+	}
+	// Canonical ordering: a stable sort of each run of non-starters by class.
+	for i := 0; i < len(out); {
+		if combiningClass(out[i]) == 0 {
+			i++
+			continue
+		}
+		j := i
+		for j < len(out) && combiningClass(out[j]) != 0 {
+			j++
+		}
+		run := out[i:j]
+		sort.SliceStable(run, func(a, b int) bool { return combiningClass(run[a]) < combiningClass(run[b]) })
+		i = j
+	}
+	return out
+}
+
+func appendDecomposed(out []rune, r rune) []rune {
+	if r >= hangulBase && r < hangulBase+hangulCount {
+		idx := r - hangulBase
+		out = append(out, hangulLBase+idx/(21*28), hangulVBase+(idx%(21*28))/28)
+		if t := idx % 28; t != 0 {
+			out = append(out, hangulTBase+t)
+		}
+		return out
+	}
+	d, ok := decompositions()[r]
+	if !ok {
+		return append(out, r)
+	}
+	for _, dr := range d {
+		out = appendDecomposed(out, dr)
+	}
+	return out
+}
+
+// compose applies the canonical composition algorithm to decomposed runes.
+func compose(rs []rune) string {
+	if len(rs) == 0 {
+		return ""
+	}
+	out := make([]rune, 0, len(rs))
+	starter := -1
+	var lastClass uint8
+	for _, r := range rs {
+		class := combiningClass(r)
+		if starter >= 0 && (lastClass < class || lastClass == 0 && len(out)-1 == starter) {
+			if c, ok := composePair(out[starter], r); ok {
+				out[starter] = c
+				continue
+			}
+		}
+		if class == 0 {
+			starter = len(out)
+		}
+		lastClass = class
+		out = append(out, r)
+	}
+	var b strings.Builder
+	b.Grow(len(out))
+	for _, r := range out {
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func composePair(a, b rune) (rune, bool) {
+	if a >= hangulLBase && a < hangulLEnd && b >= hangulVBase && b < hangulVEnd {
+		return hangulBase + ((a-hangulLBase)*21+(b-hangulVBase))*28, true
+	}
+	if a >= hangulBase && a < hangulBase+hangulCount && (a-hangulBase)%28 == 0 && b > hangulTBase && b < hangulTEnd {
+		return a + (b - hangulTBase), true
+	}
+	c, ok := compositions()[[2]rune{a, b}]
+	return c, ok
+}
@@ -0,0 +1,80 @@
+package unitext
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, nfd, nfc string
+	}{
+		{"abc", "abc", "abc"},
+		{"\u00e9", "e\u0301", "\u00e9"},
+		{"e\u0301", "e\u0301", "\u00e9"},
+		{"\u212b", "A\u030a", "\u00c5"},                    // singleton decomposition
+		{"a\u0301\u0323", "a\u0323\u0301", "\u1ea1\u0301"}, // canonical reordering
+		{"\ud55c", "\u1112\u1161\u11ab", "\ud55c"},         // Hangul LVT
+		{"\u1100\u1161", "\u1100\u1161", "\uac00"},         // Hangul L+V
+		{"\u0958", "\u0915\u093c", "\u0915\u093c"},         // composition exclusion
+	}
+	for _, tt := range tests {
+		if got := NFD(tt.in); got != tt.nfd {
+			t.Errorf("NFD(%+q) = %+q, want %+q", tt.in, got, tt.nfd)
+		}
+		if got := NFC(tt.in); got != tt.nfc {
+			t.Errorf("NFC(%+q) = %+q, want %+q", tt.in, got, tt.nfc)
+		}
+	}
+	if !CanonicallyEqual("caf\u00e9", "cafe\u0301") {
+		t.Error("precomposed and decomposed e-acute should be canonically equal")
+	}
+	if CanonicallyEqual("cafe", "caf\u00e9") {
+		t.Error("e and e-acute should differ")
+	}
+}
+
+func TestCasefold(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Hello", "hello"},
+		{"Stra\u00dfe", "strasse"},
+		{"\u1e9e", "ss"},
+		{"\u03a3\u0391\u03c2", "\u03c3\u03b1\u03c3"},
+		{"\u0130", "i\u0307"},
+		{"\ufb01", "fi"},
+	}
+	for _, tt := range tests {
+		if got := Casefold(tt.in); got != tt.want {
+			t.Errorf("Casefold(%+q) = %+q, want %+q", tt.in, got, tt.want)
+		}
+	}
+	if !EqualFold("STRASSE", "Stra\u00dfe") || !EqualFold("CAFE\u0301", "caf\u00e9") {
+		t.Error("EqualFold should ignore case and normalization")
+	}
+	if EqualFold("strasse", "strase") {
+		t.Error("EqualFold matched different strings")
+	}
+}
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"abc", []string{"a", "b", "c"}},
+		{"e\u0301x", []string{"e\u0301", "x"}},
+		{"a\r\nb", []string{"a", "\r\n", "b"}},
+		{"\U0001f1fa\U0001f1f8\U0001f1eb\U0001f1f7\U0001f1e9", []string{"\U0001f1fa\U0001f1f8", "\U0001f1eb\U0001f1f7", "\U0001f1e9"}},
+		{"\U0001f468\u200d\U0001f469\u200d\U0001f467!", []string{"\U0001f468\u200d\U0001f469\u200d\U0001f467", "!"}},
+		{"\U0001f44d\U0001f3fd", []string{"\U0001f44d\U0001f3fd"}},
+		{"\u1112\u1161\u11ab\u1100", []string{"\u1112\u1161\u11ab", "\u1100"}},
+		{"\u0915\u093f", []string{"\u0915\u093f"}}, // spacing mark
+		{"a\u200d\U0001f469", []string{"a\u200d", "\U0001f469"}},
+	}
+	for _, tt := range tests {
+		if got := Graphemes(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Graphemes(%+q) = %+q, want %+q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Package upgrade moves Welle source to a newer edition. Each edition that
+// renames or removes something ships a Migration: structural rewrites, in
+// the form `welle rewrite` takes, that turn code written for the previous
+// edition into code for this one. Upgrading a file applies the migrations
+// between its edition and the target in order, then moves its #welle
+// pragma to the target.
+package upgrade
+
+import (
+	"fmt"
+	"regexp"
+
+	"welle/internal/lexer"
+	"welle/internal/parser"
+	"welle/internal/rewrite"
+)
+
+// Rewrite is one pattern rewrite of a migration, with the reason shown
+// for each file it changes.
+type Rewrite struct {
+	Pattern     string
+	Replacement string
+	Why         string
+}
+
+// Migration holds the rewrites that bring code up to edition To.
+type Migration struct {
+	To       parser.Edition
+	Rewrites []Rewrite
+}
+
+// Migrations lists every migration, oldest first. No edition has renamed
+// or removed anything yet, so upgrading only moves the edition.
+var Migrations []Migration
+
+// Change is one kind of edit made to a file and how many times it was made.
+type Change struct {
+	Why   string
+	Count int
+}
+
+var (
+	pragmaLine   = regexp.MustCompile(`(?m)^([ \t]*#[ \t]*` + parser.PragmaName + `[ \t]+)[0-9.]+`)
+	editionEntry = regexp.MustCompile(`(?m)^([ \t]*edition[ \t]*=[ \t]*)"[^"\n]*"`)
+)
+
+// File upgrades src to edition to. A file without a #welle pragma is taken
+// to be edition from, which is the project's edition or the oldest one.
+// It returns the new source and the changes made, in the order applied.
+func File(src string, from, to parser.Edition) (string, []Change, error) {
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return "", nil, fmt.Errorf("parse error: %s", errs[0])
+	}
+	if prog.Edition != "" {
+		e, err := parser.ParseEdition(prog.Edition)
+		if err != nil {
+			return "", nil, err
+		}
+		from = e
+	}
+	if to.Before(from) {
+		return "", nil, fmt.Errorf("file is edition %s, newer than %s", from, to)
+	}
+
+	var changes []Change
+	for _, m := range Migrations {
+		if !from.Before(m.To) || to.Before(m.To) {
+			continue
+		}
+		for _, rw := range m.Rewrites {
+			rule, err := rewrite.Compile(rw.Pattern, rw.Replacement)
+			if err != nil {
+				return "", nil, fmt.Errorf("migration to %s: %w", m.To, err)
+			}
+			out, n, err := rule.Apply(src)
+			if err != nil {
+				return "", nil, err
+			}
+			if n > 0 {
+				src = out
+				changes = append(changes, Change{Why: rw.Why, Count: n})
+			}
+		}
+	}
+
+	if prog.Edition != "" && from != to {
+		src = replaceFirst(pragmaLine, src, "${1}"+to.String())
+		changes = append(changes, Change{Why: fmt.Sprintf("#%s %s -> %s", parser.PragmaName, from, to), Count: 1})
+	}
+	return src, changes, nil
+}
+
+// Manifest sets the edition key of welle.toml text src to to. ok is false
+// when src has no edition key, which is left for the project to add.
+func Manifest(src string, to parser.Edition) (out string, ok bool) {
+	if !editionEntry.MatchString(src) {
+		return src, false
+	}
+	return replaceFirst(editionEntry, src, `${1}"`+to.String()+`"`), true
+}
+
+func replaceFirst(re *regexp.Regexp, src, repl string) string {
+	loc := re.FindStringSubmatchIndex(src)
+	if loc == nil {
+		return src
+	}
+	var out []byte
+	out = re.ExpandString(out, repl, src, loc)
+	return src[:loc[0]] + string(out) + src[loc[1]:]
+}
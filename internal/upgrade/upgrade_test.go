@@ -0,0 +1,133 @@
+package upgrade
+
+import (
+	"strings"
+	"testing"
+
+	"welle/internal/parser"
+	"welle/internal/rewrite"
+)
+
+var (
+	edition01 = parser.Edition{Major: 0, Minor: 1}
+	edition02 = parser.Edition{Major: 0, Minor: 2}
+)
+
+// testMigrations stand in for the migrations of a future edition; none has
+// shipped one yet.
+var testMigrations = []Migration{
+	{
+		To: edition02,
+		Rewrites: []Rewrite{
+			{"old_len($x)", "len($x)", "old_len() is deprecated; use len()"},
+		},
+	},
+}
+
+func TestFile(t *testing.T) {
+	defer func(prev []Migration) { Migrations = prev }(Migrations)
+	Migrations = testMigrations
+
+	tests := []struct {
+		name     string
+		src      string
+		from, to parser.Edition
+		want     string
+		changes  []Change
+	}{
+		{
+			name:    "rewrites deprecated calls",
+			src:     "xs = [1, 2]\nprint(old_len(xs), old_len(\"ab\") + 1)\n",
+			from:    edition01,
+			to:      edition02,
+			want:    "xs = [1, 2]\nprint(len(xs), len(\"ab\") + 1)\n",
+			changes: []Change{{Why: "old_len() is deprecated; use len()", Count: 2}},
+		},
+		{
+			name: "pragma edition wins and is moved",
+			src:  "#welle 0.1\n\nprint(old_len([1]))\n",
+			from: edition02,
+			to:   edition02,
+			want: "#welle 0.2\n\nprint(len([1]))\n",
+			changes: []Change{
+				{Why: "old_len() is deprecated; use len()", Count: 1},
+				{Why: "#welle 0.1 -> 0.2", Count: 1},
+			},
+		},
+		{
+			name: "migrations up to from are skipped",
+			src:  "print(old_len([1]))\n",
+			from: edition02,
+			to:   edition02,
+			want: "print(old_len([1]))\n",
+		},
+		{
+			name: "migrations past to are skipped",
+			src:  "#welle 0.1\nprint(old_len([1]))\n",
+			from: edition01,
+			to:   edition01,
+			want: "#welle 0.1\nprint(old_len([1]))\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changes, err := File(tt.src, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if len(changes) != len(tt.changes) {
+				t.Fatalf("changes = %v, want %v", changes, tt.changes)
+			}
+			for i := range changes {
+				if changes[i] != tt.changes[i] {
+					t.Errorf("change %d = %v, want %v", i, changes[i], tt.changes[i])
+				}
+			}
+		})
+	}
+}
+
+func TestShippedUpgradeOnlyMovesEdition(t *testing.T) {
+	got, changes, err := File("#welle 0.1\nprint(reversed([1]))\n", edition01, edition02)
+	if err != nil || got != "#welle 0.2\nprint(reversed([1]))\n" || len(changes) != 1 {
+		t.Fatalf("File = %q, %v, %v", got, changes, err)
+	}
+}
+
+func TestFileErrors(t *testing.T) {
+	if _, _, err := File("#welle 0.2\nprint(1)\n", edition01, edition01); err == nil || !strings.Contains(err.Error(), "file is edition 0.2, newer than 0.1") {
+		t.Fatalf("expected a downgrade error, got %v", err)
+	}
+	if _, _, err := File("print((1)\n", edition01, edition02); err == nil || !strings.HasPrefix(err.Error(), "parse error:") {
+		t.Fatalf("expected a parse error, got %v", err)
+	}
+}
+
+func TestMigrationsCompileInOrder(t *testing.T) {
+	prev := parser.Editions[0]
+	for _, m := range Migrations {
+		if !prev.Before(m.To) || parser.LatestEdition.Before(m.To) {
+			t.Errorf("migration to %s is out of order or past the latest edition", m.To)
+		}
+		prev = m.To
+		for _, rw := range m.Rewrites {
+			if _, err := rewrite.Compile(rw.Pattern, rw.Replacement); err != nil {
+				t.Errorf("migration to %s: %s: %v", m.To, rw.Pattern, err)
+			}
+		}
+	}
+}
+
+func TestManifest(t *testing.T) {
+	src := "name = \"demo\"\nedition = \"0.1\"\n\n[lint]\nshadow = true\n"
+	got, ok := Manifest(src, edition02)
+	if !ok || got != "name = \"demo\"\nedition = \"0.2\"\n\n[lint]\nshadow = true\n" {
+		t.Fatalf("Manifest = %q, %v", got, ok)
+	}
+	if _, ok := Manifest("name = \"demo\"\n", edition02); ok {
+		t.Fatalf("expected no edition key to leave the manifest alone")
+	}
+}
@@ -0,0 +1,93 @@
+package vm
+
+import (
+	"welle/internal/object"
+	"welle/internal/semantics"
+)
+
+// construct pushes a new instance of c. With an init method the instance
+// comes back from init's frame; without one, args fill the fields in order.
+func (m *VM) construct(c *object.Class, args []object.Object) error {
+	inst, errObj := m.newInstance(c, args)
+	if errObj != nil {
+		return m.raiseObj(errObj)
+	}
+	if init := c.Init(); init != nil {
+		return m.callBound(inst, init, args, inst)
+	}
+	return m.tryPush(inst)
+}
+
+// applyConstruct is construct for applyFunction: it runs init to completion
+// and returns the instance.
+func (m *VM) applyConstruct(c *object.Class, args []object.Object) (object.Object, error) {
+	inst, errObj := m.newInstance(c, args)
+	if errObj != nil {
+		return nil, m.raiseObj(errObj)
+	}
+	init := c.Init()
+	if init == nil {
+		return inst, nil
+	}
+	if errObj := checkMethodArity(init, len(args)); errObj != nil {
+		return nil, m.raiseObj(errObj)
+	}
+	res, err := m.applyFunction(init, append([]object.Object{inst}, args...))
+	if err != nil || res == nil {
+		return nil, err
+	}
+	return inst, nil
+}
+
+// newInstance charges for and makes an instance of c. Without an init
+// method it also fills the fields from args.
+func (m *VM) newInstance(c *object.Class, args []object.Object) (*object.Instance, *object.Error) {
+	if errObj := m.chargeAlloc("instance", object.CostInstance(len(c.Fields))); errObj != nil {
+		return nil, errObj
+	}
+	inst := c.New()
+	if c.Init() == nil {
+		if err := semantics.CheckArity(len(args), len(c.Fields), len(c.Fields)); err != nil {
+			return nil, &object.Error{Message: err.Error()}
+		}
+		copy(inst.Fields, args)
+	}
+	return inst, nil
+}
+
+// callBound calls method with recv as self. ctor is as for callClosure.
+func (m *VM) callBound(recv *object.Instance, method object.Object, args []object.Object, ctor object.Object) error {
+	if errObj := checkMethodArity(method, len(args)); errObj != nil {
+		return m.raiseObj(errObj)
+	}
+	full := append([]object.Object{recv}, args...)
+	if cl, ok := method.(*object.Closure); ok {
+		return m.callClosure(cl, full, ctor)
+	}
+	return m.callWithArgs(method, full)
+}
+
+// callInstanceMember handles inst.name(args): a field is called as it is,
+// a method gets inst as self.
+func (m *VM) callInstanceMember(inst *object.Instance, name string, args []object.Object) error {
+	if i, ok := inst.Class.FieldIndex(name); ok {
+		return m.callWithArgs(inst.Fields[i], args)
+	}
+	if method, ok := inst.Class.Methods[name]; ok {
+		return m.callBound(inst, method, args, nil)
+	}
+	return m.raiseObj(&object.Error{Message: inst.Class.Name + " has no method " + name})
+}
+
+// checkMethodArity checks a call of method with n arguments, not counting
+// self, so the error matches what the caller wrote.
+func checkMethodArity(method object.Object, n int) *object.Error {
+	cl, ok := method.(*object.Closure)
+	if !ok {
+		return nil
+	}
+	if err := semantics.CheckArity(n, cl.Fn.NumParameters-1, len(cl.Defaults)); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nil
+}
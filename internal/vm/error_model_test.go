@@ -1,10 +1,16 @@
 package vm
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
 
+	"welle/internal/code"
+	"welle/internal/compiler"
+	"welle/internal/lexer"
 	"welle/internal/object"
+	"welle/internal/parser"
 )
 
 func TestVMErrorMembersOnThrow(t *testing.T) {
@@ -165,3 +171,110 @@ outer()`
 		t.Fatalf("expected stack to mention anonymous function name, got %q", stackObj.Value)
 	}
 }
+
+func TestVMRuntimeErrorSpans(t *testing.T) {
+	tests := []struct {
+		input string
+		want  code.Span
+	}{
+		{"a = [1, 2]\ni = 5\nx = 1 + a[i]", code.Span{Line: 3, Col: 11, EndLine: 3, EndCol: 12}},
+		{"func f(a, b) {\n  return a / b\n}\nf(1, 0)", code.Span{Line: 2, Col: 14, EndLine: 2, EndCol: 15}},
+		{"d = #{\"a\": 1}\nd.missing", code.Span{Line: 2, Col: 3, EndLine: 2, EndCol: 10}},
+		{"n = 5\nn(1)", code.Span{Line: 2, Col: 1, EndLine: 2, EndCol: 2}},
+		{"s = \"a\" + 1", code.Span{Line: 1, Col: 5, EndLine: 1, EndCol: 12}},
+		{"g = [[1, 2]]\ny = 0\nx = 7\nv = g[y][x]", code.Span{Line: 4, Col: 10, EndLine: 4, EndCol: 11}},
+		{"g = [[1, 2]]\nx = 0\nv = g[x][x][0]", code.Span{Line: 3, Col: 5, EndLine: 3, EndCol: 11}},
+	}
+	for _, tt := range tests {
+		_, err := runVM(tt.input)
+		var rerr *RuntimeError
+		if !errors.As(err, &rerr) {
+			t.Fatalf("%q: expected a RuntimeError, got %v", tt.input, err)
+		}
+		if rerr.Err.Span != tt.want || rerr.Err.File != "test.wll" {
+			t.Fatalf("%q: expected span %+v in test.wll, got %+v in %q", tt.input, tt.want, rerr.Err.Span, rerr.Err.File)
+		}
+		pos := fmt.Sprintf("(test.wll:%d:%d)", tt.want.Line, tt.want.Col)
+		if !strings.Contains(err.Error(), pos) {
+			t.Fatalf("%q: expected the trace to point at %s, got:\n%s", tt.input, pos, err)
+		}
+	}
+}
+
+// TestVMRuntimeErrorSpansOptimized checks that the fused instructions -O
+// emits blame the same range as the instructions they replace.
+func TestVMRuntimeErrorSpansOptimized(t *testing.T) {
+	tests := []struct {
+		input string
+		fused string
+		want  code.Span
+	}{
+		{"func f() {\n  x = \"a\"\n  x = x + 1\n}\nf()", "OpIncLocal", code.Span{Line: 3, Col: 7, EndLine: 3, EndCol: 12}},
+		{"func f(a) {\n  if (a < 1) { return 1 }\n  return 0\n}\nf(\"s\")", "OpCompareJump", code.Span{Line: 2, Col: 7, EndLine: 2, EndCol: 12}},
+	}
+	for _, tt := range tests {
+		for _, optimize := range []bool{false, true} {
+			program := parser.New(lexer.New(tt.input)).ParseProgram()
+			c := compiler.NewWithFile("test.wll")
+			if err := c.Compile(program); err != nil {
+				t.Fatal(err)
+			}
+			bc := c.Bytecode()
+			if optimize {
+				var err error
+				if bc, err = (&compiler.Optimizer{}).Optimize(bc); err != nil {
+					t.Fatal(err)
+				}
+				var dis string
+				for _, k := range bc.Constants {
+					if fn, ok := k.(*object.CompiledFunction); ok {
+						dis += fn.Instructions.String()
+					}
+				}
+				if !strings.Contains(dis, tt.fused) {
+					t.Fatalf("%q: expected %s in:\n%s", tt.input, tt.fused, dis)
+				}
+			}
+			err := New(bc).Run()
+			var rerr *RuntimeError
+			if !errors.As(err, &rerr) {
+				t.Fatalf("%q: expected a RuntimeError, got %v", tt.input, err)
+			}
+			if rerr.Err.Span != tt.want {
+				t.Fatalf("%q optimize=%v: expected span %+v, got %+v", tt.input, optimize, tt.want, rerr.Err.Span)
+			}
+			pos := fmt.Sprintf("(test.wll:%d:%d)", tt.want.Line, tt.want.Col)
+			if !strings.Contains(err.Error(), pos) {
+				t.Fatalf("%q optimize=%v: expected the trace to point at %s, got:\n%s", tt.input, optimize, pos, err)
+			}
+		}
+	}
+}
+
+func TestVMTraceLocals(t *testing.T) {
+	input := `func add(a, b) { return a + b }
+func outer(xs) {
+  f = func() { return xs }
+  return add(xs[0], "s")
+}
+outer([1, 2])`
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	c := compiler.NewWithFile("test.wll")
+	if err := c.Compile(program); err != nil {
+		t.Fatal(err)
+	}
+	for _, on := range []bool{true, false} {
+		m := New(c.Bytecode())
+		m.SetTraceLocals(on)
+		err := m.Run()
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		for _, want := range []string{`at add (test.wll:1:25) a = 1, b = "s"`, `at outer (test.wll:4:13) xs = [1, 2]`} {
+			if on != strings.Contains(err.Error(), want) {
+				t.Fatalf("trace locals %v: expected %q present=%v, got:\n%s", on, want, on, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package vm
+
+import "welle/internal/object"
+
+// RuntimeError is what Run returns when a raised error is not caught. Its
+// message is the error's stack trace; Err.File and Err.Span say where it
+// was raised, for callers that show the source.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string { return e.Err.Stack }
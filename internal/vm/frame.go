@@ -12,6 +12,11 @@ type Frame struct {
 	ip          int
 	basePointer int
 	defers      []deferredCall
+	// ctor is the instance an init frame returns in place of init's own
+	// result, or nil.
+	ctor object.Object
+	// gen is the generator whose body the frame runs, or nil.
+	gen *vmGenerator
 }
 
 func NewFrame(cl *object.Closure, basePointer int) *Frame {
@@ -0,0 +1,131 @@
+package vm
+
+import (
+	"fmt"
+
+	"welle/internal/object"
+)
+
+// vmGenerator is what a call to a generator function returns. Each resume
+// pushes its frame and runs the body up to the next yield; in between, the
+// frame's stack slots and try handlers are kept here.
+type vmGenerator struct {
+	cl    *object.Closure
+	frame *Frame
+	// stack holds the frame's locals and operand stack while it is
+	// suspended. The sp of each saved handler is relative to the frame.
+	stack    []object.Object
+	traps    []trap
+	finallys []fin
+	// running is set while the body runs, yielded once it has suspended
+	// again, and done once it has returned or raised.
+	running bool
+	yielded bool
+	done    bool
+}
+
+func (*vmGenerator) Type() object.Type { return object.GENERATOR_OBJ }
+func (g *vmGenerator) Inspect() string { return "<generator " + g.cl.Fn.Name + ">" }
+
+// newGenerator makes the generator for a call of cl with args bound.
+func (m *VM) newGenerator(cl *object.Closure, args []object.Object) (*vmGenerator, *object.Error) {
+	stack := make([]object.Object, max(cl.Fn.NumLocals, len(args)))
+	copy(stack, args)
+	if errObj := m.chargeAlloc("generator", object.CostGenerator(len(stack))); errObj != nil {
+		return nil, errObj
+	}
+	g := &vmGenerator{cl: cl, stack: stack}
+	g.frame = NewFrame(cl, 0)
+	g.frame.gen = g
+	return g, nil
+}
+
+// resume runs g up to its next yield and returns the value yielded, with ok
+// false once the body has finished. failed is set when the body raised an
+// error, which m has already dispatched; err is set if that ends the run.
+func (m *VM) resume(g *vmGenerator) (val object.Object, ok bool, failed bool, err error) {
+	if g.done {
+		return nilObj, false, false, nil
+	}
+	if g.running {
+		return nil, false, true, m.raiseObj(&object.Error{Message: "generator is already running"})
+	}
+	if m.maxRecursion > 0 && m.framesIndex >= m.maxRecursion+1 {
+		return nil, false, true, m.raiseObj(&object.Error{Message: fmt.Sprintf("max recursion depth exceeded (%d)", m.maxRecursion)})
+	}
+	if errObj := m.callOverflow(1 + len(g.stack)); errObj != nil {
+		return nil, false, true, m.raiseObj(errObj)
+	}
+
+	startSP := m.sp
+	m.stack[m.sp] = g.cl
+	base := m.sp + 1
+	m.sp = base + copy(m.stack[base:], g.stack)
+	g.frame.basePointer = base
+	stopFrames := m.framesIndex
+	traps, finallys := len(m.traps), len(m.finallys)
+	m.pushFrame(g.frame)
+	for _, t := range g.traps {
+		t.sp += base
+		t.frameIdx = m.framesIndex
+		m.traps = append(m.traps, t)
+	}
+	for _, f := range g.finallys {
+		f.sp += base
+		f.frameIdx = m.framesIndex
+		f.traps += traps
+		m.finallys = append(m.finallys, f)
+	}
+
+	g.running, g.yielded = true, false
+	err = m.run(stopFrames)
+	g.running = false
+	if g.yielded {
+		return m.pop(), true, false, nil
+	}
+	g.done, g.stack, g.traps, g.finallys = true, nil, nil, nil
+	if err != nil {
+		return nil, false, true, err
+	}
+	// As in applyFunction: an error that escaped the body has unwound to a
+	// handler outside it.
+	if len(m.traps) < traps || len(m.finallys) < finallys {
+		return nil, false, true, nil
+	}
+	if m.sp == startSP+1 {
+		m.pop()
+	}
+	return nilObj, false, false, nil
+}
+
+// suspend saves the state of g's frame, which is the current one, and pops
+// it, leaving sp where the frame's closure was.
+func (m *VM) suspend(g *vmGenerator) {
+	f := g.frame
+	g.stack = append(g.stack[:0], m.stack[f.basePointer:m.sp]...)
+
+	t := len(m.traps)
+	for t > 0 && m.traps[t-1].frameIdx == m.framesIndex {
+		t--
+	}
+	g.traps = append(g.traps[:0], m.traps[t:]...)
+	for i := range g.traps {
+		g.traps[i].sp -= f.basePointer
+	}
+	m.traps = m.traps[:t]
+
+	n := len(m.finallys)
+	for n > 0 && m.finallys[n-1].frameIdx == m.framesIndex {
+		n--
+	}
+	g.finallys = append(g.finallys[:0], m.finallys[n:]...)
+	for i := range g.finallys {
+		g.finallys[i].sp -= f.basePointer
+		g.finallys[i].traps -= t
+	}
+	m.finallys = m.finallys[:n]
+
+	m.popFrame()
+	m.sp = f.basePointer - 1
+	g.yielded = true
+}
@@ -1,6 +1,15 @@
 package vm
 
-import "testing"
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"welle/internal/compiler"
+	"welle/internal/lexer"
+	"welle/internal/object"
+	"welle/internal/parser"
+)
 
 func TestVMGfxBuiltinsHeadless(t *testing.T) {
 	input := `gfx_time()`
@@ -10,3 +19,53 @@ func TestVMGfxBuiltinsHeadless(t *testing.T) {
 		t.Fatal("expected error, got nil")
 	}
 }
+
+// TestVMCallAfterRun drives hooks the way `welle gfx -vm` does: run the top
+// level, then look functions up by name and call them from Go.
+func TestVMCallAfterRun(t *testing.T) {
+	input := `frames = 0
+func update(dt) {
+  frames += 1
+  return frames * dt
+}
+func draw() { throw "boom" }`
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	c := compiler.NewWithFile("game.wll")
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	m := New(c.Bytecode())
+	if err := m.Run(); err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+
+	update, ok := m.Global("update")
+	if !ok {
+		t.Fatal("expected global update")
+	}
+	for i := 1; i <= 2; i++ {
+		res, err := m.Call(update, &object.Integer{Value: 10})
+		if err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		if want := strconv.Itoa(10 * i); res.Inspect() != want {
+			t.Fatalf("update call %d = %s, want %s", i, res.Inspect(), want)
+		}
+	}
+	frames, _ := m.Global("frames")
+	if frames.Inspect() != "2" {
+		t.Fatalf("frames = %s, want 2", frames.Inspect())
+	}
+
+	draw, _ := m.Global("draw")
+	if _, err := m.Call(draw); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("draw error = %v, want boom", err)
+	}
+	if _, ok := m.Global("missing"); ok {
+		t.Fatal("unexpected global missing")
+	}
+}
@@ -0,0 +1,94 @@
+package vm
+
+import (
+	"time"
+
+	"welle/internal/builtins"
+	"welle/internal/limits"
+	"welle/internal/object"
+	"welle/internal/trace"
+)
+
+// vmHost is the builtins.Host view of a VM, handed to host functions such as
+// map and locals while they run on it.
+type vmHost VM
+
+var _ builtins.Host = (*vmHost)(nil)
+
+// Call runs fn through the VM. A failure is already dispatched to the
+// nearest handler; a VM error that ends the run is kept in hostErr.
+func (h *vmHost) Call(fn object.Object, args ...object.Object) (object.Object, bool) {
+	m := (*VM)(h)
+	res, ok, err := m.runCallback(fn, args...)
+	if !ok {
+		m.hostErr = err
+	}
+	return res, ok
+}
+
+func (h *vmHost) Scope() (map[string]object.Object, map[string]object.Object, bool) {
+	m := (*VM)(h)
+	return m.localBindings(), m.globalBindings(), true
+}
+
+// IsMain reports whether the calling function was created by the entry
+// module, whichever module's VM is running it.
+func (h *vmHost) IsMain() (bool, bool) {
+	m := (*VM)(h)
+	return !m.currentFrame().cl.Module.Imported, true
+}
+
+func (h *vmHost) Tracer() *trace.Tracer { return h.tracer }
+
+func (h *vmHost) SetTracer(t *trace.Tracer) { h.tracer = t }
+
+func (h *vmHost) Deadline() time.Time { return h.deadline }
+
+func (h *vmHost) SetDeadline(t time.Time) { h.deadline = t }
+
+func (h *vmHost) Budget() *limits.Budget { return h.budget }
+
+func (h *vmHost) SetBudget(b *limits.Budget) { h.budget = b }
+
+func (h *vmHost) LimitSteps(n int64) func() {
+	m := (*VM)(h)
+	if n <= 0 || (m.maxSteps > 0 && m.stepsLeft <= n) {
+		return func() {}
+	}
+	max, left, graceUsed := m.maxSteps, m.stepsLeft, m.stepsGraceUsed
+	m.maxSteps, m.stepsLeft, m.stepsGraceUsed = n, n, false
+	return func() {
+		used := n - m.stepsLeft
+		if m.stepsGraceUsed {
+			used = n + limits.StepGrace(n) - m.stepsLeft
+		}
+		m.maxSteps, m.stepsLeft, m.stepsGraceUsed = max, left, graceUsed
+		if max > 0 {
+			m.stepsLeft -= used
+		}
+	}
+}
+
+// callHost runs b's host implementation if it has one. handled is false for
+// plain builtins; otherwise res is the result to push, or nil once a
+// callback failed, with err set if that failure ends the run.
+func (m *VM) callHost(b *object.Builtin, args []object.Object) (res object.Object, handled bool, err error) {
+	m.hostErr = nil
+	res, handled = builtins.CallHost((*vmHost)(m), b, args, m.chargeBuiltin)
+	if handled && res == nil {
+		err = m.hostErr
+		m.hostErr = nil
+	}
+	return res, handled, err
+}
+
+// callHostMethod is callHost for receiver methods such as seq's to_list.
+func (m *VM) callHostMethod(recv object.Object, name string, args []object.Object) (res object.Object, handled bool, err error) {
+	m.hostErr = nil
+	res, handled = builtins.CallHostMethod((*vmHost)(m), recv, name, args, m.chargeBuiltin)
+	if handled && res == nil {
+		err = m.hostErr
+		m.hostErr = nil
+	}
+	return res, handled, err
+}
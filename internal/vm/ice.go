@@ -0,0 +1,31 @@
+package vm
+
+import (
+	"welle/internal/ice"
+)
+
+// recoverInternal is deferred by Run and Call. It turns a panic, a bug in
+// the VM, into an internal error returned through err. A program may have
+// had side effects by then, so it is not run again to minimize it; the
+// report shows the Welle stack it was on instead.
+func (m *VM) recoverInternal(err *error) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	e := ice.New("vm", v)
+	e.Context = m.panicTrace()
+	ice.Report(e)
+	*err = e
+}
+
+// panicTrace is the Welle stack trace at a panic, or "" when the frames are
+// too broken to print.
+func (m *VM) panicTrace() (trace string) {
+	defer func() {
+		if recover() != nil {
+			trace = ""
+		}
+	}()
+	return m.formatStackTrace("internal error")
+}
@@ -0,0 +1,31 @@
+package vm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"welle/internal/code"
+	"welle/internal/compiler"
+	"welle/internal/ice"
+)
+
+func TestRunInternalError(t *testing.T) {
+	var reported []*ice.Error
+	defer ice.SetReporter(nil)
+	ice.SetReporter(func(e *ice.Error) { reported = append(reported, e) })
+
+	// Unverified bytecode loading a constant that does not exist.
+	bc := &compiler.Bytecode{Instructions: code.Make(code.OpConstant, 5)}
+	err := New(bc).Run()
+	var e *ice.Error
+	if !errors.As(err, &e) || e.Phase != "vm" {
+		t.Fatalf("err = %v, want an internal error in the VM", err)
+	}
+	if !strings.Contains(err.Error(), "internal error, please report, code WI0001") {
+		t.Fatalf("message = %q", err.Error())
+	}
+	if len(reported) != 1 || e.Repro != "" || !strings.Contains(e.Context, "internal error") {
+		t.Fatalf("reported = %d, repro = %q, context = %q", len(reported), e.Repro, e.Context)
+	}
+}
@@ -0,0 +1,100 @@
+package vm
+
+import (
+	"fmt"
+
+	"welle/internal/object"
+	"welle/internal/semantics"
+)
+
+// Operands an indexing error is blamed on, as raiseIndexError takes them.
+const (
+	blameNone  = -1
+	blameLeft  = 0
+	blameIndex = 1
+)
+
+// indexValue returns left[idx]. On failure it returns the error and which
+// operand it is blamed on.
+func (m *VM) indexValue(left, idx object.Object) (object.Object, *object.Error, int) {
+	switch l := left.(type) {
+	case *object.Array:
+		i, ok := idx.(*object.Integer)
+		if !ok {
+			return nil, &object.Error{Message: fmt.Sprintf("array index must be INTEGER, got %s", idx.Type())}, blameIndex
+		}
+		n := int(i.Value)
+		L := len(l.Elements)
+		if n < 0 {
+			n = L + n
+		}
+		if n < 0 || n >= L {
+			return nil, &object.Error{Message: "index out of range"}, blameIndex
+		}
+		return l.Elements[n], nil, blameNone
+
+	case *object.Range:
+		el, err := semantics.RangeIndex(l, idx)
+		if err != nil {
+			return nil, &object.Error{Message: err.Error()}, blameIndex
+		}
+		return el, nil, blameNone
+
+	case *object.Tuple:
+		i, ok := idx.(*object.Integer)
+		if !ok {
+			return nil, &object.Error{Message: fmt.Sprintf("tuple index must be INTEGER, got %s", idx.Type())}, blameIndex
+		}
+		n := int(i.Value)
+		L := len(l.Elements)
+		if n < 0 {
+			n = L + n
+		}
+		if n < 0 || n >= L {
+			return nil, &object.Error{Message: "index out of range"}, blameIndex
+		}
+		return l.Elements[n], nil, blameNone
+
+	case *object.String:
+		i, ok := idx.(*object.Integer)
+		if !ok {
+			return nil, &object.Error{Message: fmt.Sprintf("string index must be INTEGER, got %s", idx.Type())}, blameIndex
+		}
+		n := int(i.Value)
+		L := l.RuneCount()
+		if n < 0 {
+			n = L + n
+		}
+		if n < 0 || n >= L {
+			return nil, &object.Error{Message: "index out of range"}, blameIndex
+		}
+		out := &object.String{Value: l.RuneAt(n)}
+		if errObj := m.chargeAlloc("string", object.CostStringBytes(len(out.Value))); errObj != nil {
+			return nil, errObj, blameNone
+		}
+		return out, nil, blameNone
+
+	case *object.Dict:
+		hk, ok := object.HashKeyOf(idx)
+		if !ok {
+			return nil, &object.Error{Message: fmt.Sprintf("unusable as dict key: %s", idx.Type())}, blameIndex
+		}
+		pair, ok := l.Pairs[hk]
+		if !ok {
+			return nilObj, nil, blameNone
+		}
+		return pair.Value, nil, blameNone
+
+	default:
+		return nil, &object.Error{Message: fmt.Sprintf("indexing not supported on %s", left.Type())}, blameLeft
+	}
+}
+
+// raiseIndexError raises an error from indexValue with the range of the
+// operand it blames, offset by base for the levels of an OpIndexChain.
+func (m *VM) raiseIndexError(errObj *object.Error, blame, base int) error {
+	if blame == blameNone {
+		return m.raiseObj(errObj)
+	}
+	return m.raiseAt(base+blame, errObj)
+}
@@ -1,20 +1,63 @@
 package vm
 
-import "welle/internal/object"
+import (
+	"welle/internal/object"
+	"welle/internal/semantics"
+)
 
+// vmIterator walks the items of a for-in loop or comprehension. Over a seq
+// it holds a cursor instead, and pulls the pipeline one element per step;
+// over a generator it resumes the generator once per step.
 type vmIterator struct {
 	items []object.Object
 	idx   int
+	seq   *semantics.SeqCursor
+	gen   *vmGenerator
+	// rng is iterated without building its elements up front; pos is
+	// the index of the next one.
+	rng *object.Range
+	pos int64
 }
 
 func (*vmIterator) Type() object.Type { return object.Type("ITER") }
 func (*vmIterator) Inspect() string   { return "<iter>" }
 
-func (it *vmIterator) next() (object.Object, bool) {
+// next returns the iterator's next value and whether there was one. The
+// third result is set when a seq stage's callback raised an error, which m
+// has already dispatched; the error is set if that failure ends the run.
+// A generator's body failing is reported the same way.
+func (it *vmIterator) next(m *VM) (object.Object, bool, bool, error) {
+	if it.gen != nil {
+		return m.resume(it.gen)
+	}
+	if it.seq != nil {
+		m.hostErr = nil
+		val, ok, err := it.seq.Next((*vmHost)(m).Call)
+		if err != nil {
+			err = m.hostErr
+			m.hostErr = nil
+			return nil, false, true, err
+		}
+		if !ok {
+			return nilObj, false, false, nil
+		}
+		return val, true, false, nil
+	}
+	if it.rng != nil {
+		if it.pos >= it.rng.Len() {
+			return nilObj, false, false, nil
+		}
+		if errObj := m.chargeAlloc("int", object.CostRangeStep()); errObj != nil {
+			return nil, false, true, m.raiseObj(errObj)
+		}
+		val := &object.Integer{Value: it.rng.At(it.pos)}
+		it.pos++
+		return val, true, false, nil
+	}
 	if it.idx >= len(it.items) {
-		return nilObj, false
+		return nilObj, false, false, nil
 	}
 	val := it.items[it.idx]
 	it.idx++
-	return val, true
+	return val, true, false, nil
 }
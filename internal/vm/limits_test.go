@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"welle/internal/compiler"
 	"welle/internal/lexer"
+	"welle/internal/limits"
 	"welle/internal/object"
 	"welle/internal/parser"
 )
@@ -84,3 +86,148 @@ func TestVMStepLimitCatchable(t *testing.T) {
 		t.Fatalf("expected step limit error message, got %q", strObj.Value)
 	}
 }
+
+func TestVMStackOverflowCatchable(t *testing.T) {
+	input := `func f(self, n) { return self(self, n + 1) }
+try { f(f, 0) } catch (e) { export msg = e.message; export stack = e.stack }`
+
+	tests := []struct {
+		name      string
+		maxStack  int
+		maxFrames int
+		want      string
+	}{
+		{name: "frames", maxFrames: 40, want: "stack overflow: call depth exceeds 40 frames"},
+		{name: "value stack", maxStack: 100, want: "stack overflow: value stack exceeds 100 slots"},
+		{name: "defaults", want: "stack overflow: value stack exceeds 2048 slots"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := buildVMLimited(input, 0, 0)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			m.SetMaxStack(tt.maxStack)
+			m.SetMaxFrames(tt.maxFrames)
+			if err := m.Run(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			msgObj, ok := exportValue(m.Exports(), "msg")
+			if !ok {
+				t.Fatal("expected export msg")
+			}
+			if got := msgObj.(*object.String).Value; got != tt.want {
+				t.Fatalf("message = %q, want %q", got, tt.want)
+			}
+			stackObj, _ := exportValue(m.Exports(), "stack")
+			if s, ok := stackObj.(*object.String); !ok || !strings.Contains(s.Value, "at f (test.wll:1:") {
+				t.Fatalf("expected stack trace through f, got %v", stackObj)
+			}
+		})
+	}
+}
+
+func TestVMStackGrowsBeyondInitialSize(t *testing.T) {
+	input := `func f(self, n) { if (n == 0) { return 0 } return 1 + self(self, n - 1) }
+export depth = f(f, 200)`
+
+	m, err := buildVMLimited(input, 0, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := m.Run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	depth, _ := exportValue(m.Exports(), "depth")
+	if n, ok := depth.(*object.Integer); !ok || n.Value != 200 {
+		t.Fatalf("depth = %v, want 200", depth)
+	}
+}
+
+// Statements in a loop body must leave the stack as they found it, or a
+// long loop runs into the stack cap.
+func TestVMLoopBodiesKeepStackFlat(t *testing.T) {
+	input := `func run(n) {
+  a = [0]
+  d = #{"k": 0}
+  i = 0
+  while (i < n) {
+    a[0] = i
+    d.k += 1
+    if (i > 1) { i + 1 } else { nil }
+    try { i * 2 } catch (e) { e }
+    i = i + 1
+  }
+  return d.k
+}
+j = 0
+while (j < 5000) { j += 1 }
+export out = run(5000) + j`
+
+	m, err := buildVMLimited(input, 0, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m.SetMaxStack(64)
+	if err := m.Run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, ok := exportValue(m.Exports(), "out")
+	if !ok {
+		t.Fatal("expected export out")
+	}
+	if n, ok := out.(*object.Integer); !ok || n.Value != 10000 {
+		t.Fatalf("out = %v, want 10000", out)
+	}
+}
+
+func TestVMInterruptSkipsCatchAndRunsFinally(t *testing.T) {
+	input := `export state = 0
+func f() {
+  defer func() { state = state + 100 }()
+  try {
+    while (true) { state = 1 }
+  } catch (e) {
+    state = 2
+  } finally {
+    state = state + 10
+  }
+}
+f()`
+	m, err := buildVMLimited(input, 0, 0)
+	if err != nil {
+		t.Fatalf("build error: %v", err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		limits.Interrupt()
+	}()
+	err = m.Run()
+	if err == nil || !strings.HasPrefix(err.Error(), "error: interrupted") || !strings.Contains(err.Error(), "at f (") {
+		t.Fatalf("expected interrupt error through f, got %v", err)
+	}
+	state, ok := exportValue(m.Exports(), "state")
+	if !ok || state.Inspect() != "111" {
+		t.Fatalf("state = %v, want 111", state)
+	}
+}
+
+func TestVMFinallyRunsForUncaughtError(t *testing.T) {
+	input := `export log = []
+func f() {
+  try {
+    throw "boom"
+  } finally {
+    log = log.append("finally")
+  }
+}
+try { f() } catch (e) { log = log.append(e.message) }`
+	exports, err := runVM(input)
+	if err != nil {
+		t.Fatalf("vm error: %v", err)
+	}
+	log, _ := exportValue(exports, "log")
+	if log.Inspect() != "[finally, boom]" {
+		t.Fatalf("log = %s", log.Inspect())
+	}
+}
@@ -27,34 +27,23 @@ func (m *VM) chargeMemory(n int64) *object.Error {
 	return nil
 }
 
-func (m *VM) costOfObject(obj object.Object) int64 {
-	switch v := obj.(type) {
-	case *object.String:
-		return object.CostStringBytes(len(v.Value))
-	case *object.Array:
-		return object.CostArray(len(v.Elements))
-	case *object.Tuple:
-		return object.CostTuple(len(v.Elements))
-	case *object.Dict:
-		return object.CostDict(len(v.Pairs))
-	case *object.Image:
-		return object.CostImage(v.Width, v.Height)
-	case *object.Error:
-		return object.CostError()
-	case *object.Closure:
-		return object.CostClosure(len(v.Free))
-	case *object.Cell:
-		return object.CostCell()
-	default:
-		return 0
-	}
+// chargeAlloc charges n for one allocation of the given kind, counting it
+// when the run records stats.
+func (m *VM) chargeAlloc(kind string, n int64) *object.Error {
+	m.stats.CountAlloc(kind)
+	return m.chargeMemory(n)
+}
+
+// chargeBuiltin is the charge callback handed to builtins.Call.
+func (m *VM) chargeBuiltin(n int64) *object.Error {
+	return m.chargeAlloc("builtin", n)
 }
 
 func (m *VM) chargeObject(obj object.Object) *object.Error {
 	if obj == nil {
 		return nil
 	}
-	cost := m.costOfObject(obj)
+	cost := object.CostOf(obj)
 	if cost == 0 {
 		return nil
 	}
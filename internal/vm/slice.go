@@ -1,58 +1,15 @@
 package vm
 
-import "welle/internal/object"
+import (
+	"fmt"
 
-func sliceBounds(lowPtr *int64, highPtr *int64, stepVal int64, length int64) (int64, int64) {
-	norm := func(x, length int64) int64 {
-		if x < 0 {
-			return length + x
-		}
-		return x
-	}
-	clamp := func(x, lo, hi int64) int64 {
-		if x < lo {
-			return lo
-		}
-		if x > hi {
-			return hi
-		}
-		return x
-	}
-	if stepVal > 0 {
-		lo := int64(0)
-		hi := length
-		if lowPtr != nil {
-			lo = norm(*lowPtr, length)
-		}
-		if highPtr != nil {
-			hi = norm(*highPtr, length)
-		}
-		lo = clamp(lo, 0, length)
-		hi = clamp(hi, 0, length)
-		if lo > hi {
-			lo = hi
-		}
-		return lo, hi
-	}
-	lo := length - 1
-	hi := int64(-1)
-	if lowPtr != nil {
-		lo = norm(*lowPtr, length)
-	}
-	if highPtr != nil {
-		hi = norm(*highPtr, length)
-	}
-	lo = clamp(lo, -1, length-1)
-	hi = clamp(hi, -1, length-1)
-	if lo < hi {
-		lo = hi
-	}
-	return lo, hi
-}
+	"welle/internal/object"
+	"welle/internal/semantics"
+)
 
 func sliceElements(elements []object.Object, lowPtr *int64, highPtr *int64, stepVal int64) []object.Object {
 	length := int64(len(elements))
-	lo, hi := sliceBounds(lowPtr, highPtr, stepVal, length)
+	lo, hi := semantics.SliceBounds(lowPtr, highPtr, stepVal, length)
 	out := make([]object.Object, 0)
 	if stepVal > 0 {
 		for i := lo; i < hi; i += stepVal {
@@ -66,18 +23,45 @@ func sliceElements(elements []object.Object, lowPtr *int64, highPtr *int64, step
 	return out
 }
 
-func sliceRunes(rs []rune, lowPtr *int64, highPtr *int64, stepVal int64) []rune {
-	length := int64(len(rs))
-	lo, hi := sliceBounds(lowPtr, highPtr, stepVal, length)
-	out := make([]rune, 0)
-	if stepVal > 0 {
-		for i := lo; i < hi; i += stepVal {
-			out = append(out, rs[int(i)])
-		}
-	} else {
-		for i := lo; i > hi; i += stepVal {
-			out = append(out, rs[int(i)])
+func sliceString(s *object.String, lowPtr *int64, highPtr *int64, stepVal int64) string {
+	lo, hi := semantics.SliceBounds(lowPtr, highPtr, stepVal, int64(s.RuneCount()))
+	return s.SliceStep(int(lo), int(hi), int(stepVal))
+}
+
+// setSlice carries out `left[low:high:step] = val`, charging the memory
+// budget for any elements the array gains before it changes.
+func (m *VM) setSlice(left, low, high, step, val object.Object) *object.Error {
+	arr, ok := left.(*object.Array)
+	if !ok {
+		return &object.Error{Message: fmt.Sprintf("slice assignment not supported on type: %s", left.Type())}
+	}
+	lowPtr, highPtr, stepVal, err := semantics.SliceArgs(low, high, step)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	els, err := semantics.AssignSlice(arr.Elements, lowPtr, highPtr, stepVal, val)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	if grown := len(els) - len(arr.Elements); grown > 0 {
+		if errObj := m.chargeAlloc("array", object.CostArrayElements(grown)); errObj != nil {
+			return errObj
 		}
 	}
-	return out
+	arr.SetElements(els)
+	return nil
+}
+
+// delSlice carries out `del left[low:high:step]`.
+func (m *VM) delSlice(left, low, high, step object.Object) *object.Error {
+	arr, ok := left.(*object.Array)
+	if !ok {
+		return &object.Error{Message: fmt.Sprintf("del not supported on type: %s", left.Type())}
+	}
+	lowPtr, highPtr, stepVal, err := semantics.SliceArgs(low, high, step)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	arr.SetElements(semantics.DeleteSlice(arr.Elements, lowPtr, highPtr, stepVal))
+	return nil
 }
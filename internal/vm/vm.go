@@ -4,48 +4,86 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"welle/internal/builtins"
 	"welle/internal/code"
 	"welle/internal/compiler"
+	"welle/internal/errcode"
+	"welle/internal/heapdump"
 	"welle/internal/limits"
 	"welle/internal/object"
 	"welle/internal/semantics"
+	"welle/internal/trace"
 )
 
+// StackSize and MaxFrames are the default caps on the value stack and call
+// depth; SetMaxStack and SetMaxFrames change them per VM. Both grow on
+// demand from a small initial allocation. GlobalsSize is the number of
+// global slots a 2-byte operand can address. Each module's globals are sized
+// from its symbol table and grow on demand beyond that, as a REPL session's do.
 const StackSize = 2048
 const GlobalsSize = 65536
 const MaxFrames = 1024
 
+const (
+	initialStackSize  = 256
+	initialFrameCount = 16
+)
+
 var nilObj = &object.Nil{}
 
 type VM struct {
-	constants []object.Object
+	module *object.Module
 
 	stack []object.Object
 	sp    int
 
-	globals    []object.Object
 	lastPopped object.Object
 
 	frames      []*Frame
 	framesIndex int
 
+	maxStack  int
+	maxFrames int
+
 	traps    []trap
 	finallys []fin
 
 	entryPath string
 	importer  Importer
 	modules   map[string]*object.Dict
+	segments  map[string]*moduleSegment
 	exports   *object.Dict
-	imports   *importTracker
-
-	pendingErr *object.Error
+	// exportSlots maps names exported from globals to their slots; they are
+	// read into exports once the module has run.
+	exportSlots map[string]int
+	imports     *importTracker
 
 	maxRecursion int
 	maxSteps     int64
 	stepsLeft    int64
+	// stepsGraceUsed is set once the step limit has been hit; the run then
+	// has only the grace left (see limits.StepGrace).
+	stepsGraceUsed bool
 
 	budget *limits.Budget
+	stats  *limits.Stats
+	// moduleLimits tighten the limits of the VMs that run imported modules.
+	moduleLimits []limits.ModuleLimits
+	tracer       *trace.Tracer
+	// traceLocals adds each function's parameter values to stack traces.
+	traceLocals bool
+	// hostErr carries a run-ending error out of a host function callback.
+	hostErr error
+	// failedRoots holds the locals of the frames an uncaught error unwound,
+	// for a heap dump of the failed run.
+	failedRoots []heapdump.Root
+	// pollLeft counts instructions down to the next interrupt and deadline
+	// check.
+	pollLeft int
+	// deadline is set while a with_timeout call runs (see vmHost).
+	deadline time.Time
 }
 
 type trap struct {
@@ -59,6 +97,17 @@ type fin struct {
 	afterIP   int
 	sp        int
 	frameIdx  int
+	// traps counts the traps outside this finally's try. Once no more
+	// than that are left, as in its catch block, the finally is the
+	// innermost handler.
+	traps int
+}
+
+// moduleSegment is an imported module's globals together with the slots of
+// its exports, so from-imports read an export directly.
+type moduleSegment struct {
+	module *object.Module
+	slots  map[string]int
 }
 
 type Importer func(fromPath, spec string) (*compiler.Bytecode, string, error)
@@ -66,6 +115,9 @@ type Importer func(fromPath, spec string) (*compiler.Bytecode, string, error)
 type importTracker struct {
 	stack []string
 	index map[string]int
+	// deinits holds the __deinit hooks of the modules loaded so far, in the
+	// order they finished loading.
+	deinits []object.Object
 }
 
 func newImportTracker() *importTracker {
@@ -100,21 +152,25 @@ func New(bc *compiler.Bytecode) *VM {
 		File:         bc.Debug.File,
 		Pos:          bc.Debug.Pos,
 	}
-	mainCl := &object.Closure{Fn: mainFn}
+	mod := &object.Module{Constants: bc.Constants, Globals: make([]object.Object, bc.NumGlobals), GlobalNames: bc.GlobalNames}
+	mainCl := &object.Closure{Fn: mainFn, Module: mod}
 	mainFrame := NewFrame(mainCl, 0)
 
-	frames := make([]*Frame, MaxFrames)
+	frames := make([]*Frame, initialFrameCount)
 	frames[0] = mainFrame
 
 	return &VM{
-		constants:   bc.Constants,
-		stack:       make([]object.Object, StackSize),
-		globals:     make([]object.Object, GlobalsSize),
+		module:      mod,
+		stack:       make([]object.Object, initialStackSize),
 		sp:          0,
 		frames:      frames,
 		framesIndex: 1,
+		maxStack:    StackSize,
+		maxFrames:   MaxFrames,
 		modules:     map[string]*object.Dict{},
-		exports:     &object.Dict{Pairs: map[string]object.DictPair{}},
+		segments:    map[string]*moduleSegment{},
+		exports:     &object.Dict{Pairs: map[object.HashKey]object.DictPair{}},
+		exportSlots: bc.Exports,
 		imports:     newImportTracker(),
 	}
 }
@@ -130,27 +186,86 @@ func (m *VM) currentFrame() *Frame {
 	return m.frames[m.framesIndex-1]
 }
 
+// enterLocals reserves a new frame's local slots above its arguments.
+// The slots are cleared: a previous call may have left a captured Cell
+// there, and OpSetLocal would otherwise write through it.
+func (m *VM) enterLocals(basePointer, numArgs, numLocals int) {
+	m.sp = basePointer + numLocals
+	if start := basePointer + numArgs; start < m.sp {
+		clear(m.stack[start:m.sp])
+	}
+}
+
 func (m *VM) pushFrame(f *Frame) {
+	if m.framesIndex == len(m.frames) {
+		m.frames = append(m.frames, nil)
+		m.frames = m.frames[:cap(m.frames)]
+	}
 	m.frames[m.framesIndex] = f
 	m.framesIndex++
 }
 
+// popFrame also drops the handlers the frame left open: a return from
+// inside a try skips its OpEndTry and OpEndFinally.
 func (m *VM) popFrame() *Frame {
 	m.framesIndex--
 	f := m.frames[m.framesIndex]
 	m.frames[m.framesIndex] = nil
+	m.dropStaleHandlers()
 	return f
 }
 
+// dropStaleHandlers pops the traps and finally blocks of frames that are
+// no longer on the call stack.
+func (m *VM) dropStaleHandlers() {
+	for len(m.traps) > 0 && m.traps[len(m.traps)-1].frameIdx > m.framesIndex {
+		m.traps = m.traps[:len(m.traps)-1]
+	}
+	for len(m.finallys) > 0 && m.finallys[len(m.finallys)-1].frameIdx > m.framesIndex {
+		m.finallys = m.finallys[:len(m.finallys)-1]
+	}
+}
+
 func (m *VM) push(o object.Object) error {
-	if m.sp >= StackSize {
-		return fmt.Errorf("stack overflow")
+	if m.sp >= len(m.stack) && !m.growStack(m.sp+1) {
+		return fmt.Errorf("stack overflow: value stack exceeds %d slots", m.maxStack)
 	}
 	m.stack[m.sp] = o
 	m.sp++
 	return nil
 }
 
+// growStack makes room for n slots, doubling the stack up to maxStack.
+func (m *VM) growStack(n int) bool {
+	if n <= len(m.stack) {
+		return true
+	}
+	if n > m.maxStack {
+		return false
+	}
+	size := len(m.stack) * 2
+	for size < n {
+		size *= 2
+	}
+	size = min(size, m.maxStack)
+	stack := make([]object.Object, size)
+	copy(stack, m.stack)
+	m.stack = stack
+	return true
+}
+
+// callOverflow returns the error for a call that would need a new frame
+// and `slots` more stack slots beyond sp, or nil if it fits.
+func (m *VM) callOverflow(slots int) *object.Error {
+	if m.framesIndex >= m.maxFrames {
+		return &object.Error{Message: fmt.Sprintf("stack overflow: call depth exceeds %d frames", m.maxFrames)}
+	}
+	if !m.growStack(m.sp + max(slots, 0)) {
+		return &object.Error{Message: fmt.Sprintf("stack overflow: value stack exceeds %d slots", m.maxStack)}
+	}
+	return nil
+}
+
 func (m *VM) tryPush(o object.Object) error {
 	if err := m.push(o); err != nil {
 		return m.raiseObj(&object.Error{Message: err.Error()})
@@ -173,20 +288,153 @@ func cellValue(cell *object.Cell) object.Object {
 	return cell.Value
 }
 
+// Exports returns the module's exports, frozen so importers cannot assign
+// into them. Exported globals take the values their slots hold when it is
+// called, normally after Run.
 func (m *VM) Exports() *object.Dict {
+	for name, slot := range m.exportSlots {
+		val := m.module.Global(slot)
+		if val == nil {
+			continue
+		}
+		key := &object.String{Value: name}
+		hk, _ := object.HashKeyOf(key)
+		m.exports.Pairs[hk] = object.DictPair{Key: key, Value: val}
+	}
+	m.exports.Frozen = true
 	return m.exports
 }
 
+// Global returns the entry module's global called name, normally after Run.
+func (m *VM) Global(name string) (object.Object, bool) {
+	val, ok := semantics.SlotBindings(m.module.GlobalNames, m.module.Globals)[name]
+	return val, ok
+}
+
+// HeapRoots returns what a heap dump of the run starts from: the entry
+// module's globals and, when Run stopped on an error, the locals of the
+// frames it unwound, named fn.local.
+func (m *VM) HeapRoots() []heapdump.Root {
+	var roots []heapdump.Root
+	for name, val := range semantics.SlotBindings(m.module.GlobalNames, m.module.Globals) {
+		roots = append(roots, heapdump.Root{Name: name, Value: val})
+	}
+	return append(roots, m.failedRoots...)
+}
+
+// frameRoots returns the locals of the frames above the entry frame.
+func (m *VM) frameRoots() []heapdump.Root {
+	var roots []heapdump.Root
+	for i := 1; i < m.framesIndex; i++ {
+		f := m.frames[i]
+		if f == nil || f.cl == nil {
+			continue
+		}
+		fn := f.cl.Fn
+		name := fn.Name
+		if name == "" {
+			name = "<anon>"
+		}
+		end := min(f.basePointer+fn.NumLocals, len(m.stack))
+		for local, val := range semantics.SlotBindings(fn.LocalNames, m.stack[f.basePointer:end]) {
+			roots = append(roots, heapdump.Root{Name: name + "." + local, Value: val})
+		}
+	}
+	return roots
+}
+
+// Call applies fn to args after Run has finished, for embedders that drive
+// Welle callbacks from Go, such as the gfx loop and its timers. An error fn
+// raises and does not catch is returned as err.
+func (m *VM) Call(fn object.Object, args ...object.Object) (_ object.Object, err error) {
+	defer m.recoverInternal(&err)
+	res, err := m.applyFunction(fn, args)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nilObj, nil
+	}
+	return res, nil
+}
+
+// Shutdown runs the __deinit hooks of the modules the program imported,
+// once Run has finished, in the reverse of the order they finished loading.
+// It stops at the first hook that fails and returns its error.
+func (m *VM) Shutdown() error {
+	deinits := m.imports.deinits
+	m.imports.deinits = nil
+	for i := len(deinits) - 1; i >= 0; i-- {
+		if _, err := m.Call(deinits[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *VM) LastPoppedStackElem() object.Object {
 	return m.lastPopped
 }
 
 func (m *VM) SetGlobals(globals []object.Object) {
 	if globals != nil {
-		m.globals = globals
+		m.module.Globals = globals
 	}
 }
 
+// Globals returns the VM's globals. They may have grown past the slice given
+// to SetGlobals, so a REPL carries this one over to the next VM.
+func (m *VM) Globals() []object.Object {
+	return m.module.Globals
+}
+
+// runModule runs an imported module in its own VM, sharing this VM's limits
+// and caches, and records its exports and globals segment. A module with its
+// own limits runs on a child budget, so it cannot spend more than they allow.
+func (m *VM) runModule(bc *compiler.Bytecode, absPath string) (*object.Dict, error) {
+	modVM := NewWithImporter(bc, absPath, m.importer)
+	modVM.MarkImported()
+	modVM.SetMaxRecursion(m.maxRecursion)
+	modVM.SetMaxStack(m.maxStack)
+	modVM.SetMaxFrames(m.maxFrames)
+	modVM.SetMaxSteps(m.maxSteps)
+	modVM.SetBudget(m.budget)
+	if rule, ok := limits.ForModule(m.moduleLimits, absPath); ok {
+		modVM.SetMaxSteps(limits.Stricter(m.maxSteps, rule.MaxSteps))
+		if rule.MaxMem > 0 {
+			modVM.SetBudget(m.budget.Child(rule.MaxMem))
+		}
+	}
+	modVM.moduleLimits = m.moduleLimits
+	modVM.stats = m.stats
+	modVM.tracer = m.tracer
+	modVM.traceLocals = m.traceLocals
+	modVM.modules = m.modules
+	modVM.segments = m.segments
+	modVM.imports = m.imports
+	if err := modVM.Run(); err != nil {
+		return nil, err
+	}
+	mod := modVM.Exports()
+	if hook := semantics.ModuleHook(mod, semantics.InitHook); hook != nil {
+		if _, err := modVM.Call(hook); err != nil {
+			return nil, err
+		}
+	}
+	if hook := semantics.ModuleHook(mod, semantics.DeinitHook); hook != nil {
+		m.imports.deinits = append(m.imports.deinits, hook)
+	}
+	m.modules[absPath] = mod
+	m.segments[absPath] = &moduleSegment{module: modVM.module, slots: modVM.exportSlots}
+	return mod, nil
+}
+
+// MarkImported records that the VM runs an imported module rather than
+// the entry file, so is_main() is false in its code.
+func (m *VM) MarkImported() {
+	m.module.Imported = true
+}
+
 func (m *VM) SetModuleCache(cache map[string]*object.Dict) {
 	if cache != nil {
 		m.modules = cache
@@ -200,6 +448,22 @@ func (m *VM) SetMaxRecursion(max int) {
 	m.maxRecursion = max
 }
 
+// SetMaxStack caps the value stack at n slots (0 restores StackSize).
+func (m *VM) SetMaxStack(n int) {
+	if n <= 0 {
+		n = StackSize
+	}
+	m.maxStack = n
+}
+
+// SetMaxFrames caps the call depth at n frames (0 restores MaxFrames).
+func (m *VM) SetMaxFrames(n int) {
+	if n <= 0 {
+		n = MaxFrames
+	}
+	m.maxFrames = n
+}
+
 func (m *VM) SetMaxSteps(max int64) {
 	if max < 0 {
 		max = 0
@@ -218,7 +482,31 @@ func (m *VM) SetBudget(b *limits.Budget) {
 	m.budget = b
 }
 
-func (m *VM) Run() error {
+// SetModuleLimits installs stricter limits for the modules this run imports.
+func (m *VM) SetModuleLimits(rules []limits.ModuleLimits) {
+	m.moduleLimits = rules
+}
+
+// SetStats makes this run and the modules it imports record what they use
+// into s (nil stops recording).
+func (m *VM) SetStats(s *limits.Stats) {
+	m.stats = s
+}
+
+// SetTracer installs the execution tracer for this run and the modules it
+// imports; trace() toggles it.
+func (m *VM) SetTracer(t *trace.Tracer) {
+	m.tracer = t
+}
+
+// SetTraceLocals makes stack traces show the parameter values of each
+// function on them, in this run and the modules it imports.
+func (m *VM) SetTraceLocals(on bool) {
+	m.traceLocals = on
+}
+
+func (m *VM) Run() (err error) {
+	defer m.recoverInternal(&err)
 	if m.entryPath != "" {
 		if err := m.imports.enter(m.entryPath); err != nil {
 			return err
@@ -227,10 +515,15 @@ func (m *VM) Run() error {
 	}
 	if m.maxSteps > 0 {
 		m.stepsLeft = m.maxSteps
+		m.stepsGraceUsed = false
 	}
 	return m.run(-1)
 }
 
+// interruptPollInterval is how many instructions run between checks for a
+// pending interrupt or a passed deadline.
+const interruptPollInterval = 1024
+
 func (m *VM) run(stopFrames int) error {
 	for {
 		if stopFrames >= 0 && m.framesIndex <= stopFrames {
@@ -245,36 +538,52 @@ func (m *VM) run(stopFrames int) error {
 			if stopFrames >= 0 {
 				return errors.New(m.formatStackTrace("unexpected end of instructions"))
 			}
+			// The entry frame has no return instruction; run its defers
+			// as it falls off the end.
+			if err := m.runDefers(frame); err != nil {
+				return err
+			}
+			if m.currentFrame() != frame {
+				continue
+			}
 			return nil
 		}
 		frame.ip++
 		op := code.Opcode(ins[frame.ip])
+		if m.tracer.Enabled() {
+			m.traceOp(frame, op)
+		}
+		if m.stats != nil {
+			m.stats.Steps++
+		}
 		if m.maxSteps > 0 {
 			m.stepsLeft--
 			if m.stepsLeft < 0 {
-				errObj := &object.Error{Message: fmt.Sprintf("max instruction count exceeded (%d)", m.maxSteps)}
-				ip := frame.ip
-				if ip < 0 {
-					ip = 0
+				msg := fmt.Sprintf("max instruction count exceeded (%d)", m.maxSteps)
+				if m.stepsGraceUsed {
+					// The handler spent its grace too; nothing may catch this.
+					return errors.New(m.formatStackTrace(msg))
 				}
-				catchIP, ok := activeTryCatchIP(ins, ip)
-				if !ok {
-					catchIP, ok = firstTryCatchIP(ins)
+				m.stepsGraceUsed = true
+				m.stepsLeft = limits.StepGrace(m.maxSteps)
+				if err := m.raiseObj(&object.Error{Message: msg, Code: limits.StepsErrorCode}); err != nil {
+					return err
 				}
-				if ok {
-					if errObj.Stack == "" {
-						errObj.Stack = m.formatStackTrace(errObj.Message)
-					}
-					m.sp = frame.basePointer
-					m.maxSteps = 0
-					m.stepsLeft = 0
-					if err := m.push(errObj); err != nil {
-						return err
-					}
-					frame.ip = catchIP - 1
-					continue
+				continue
+			}
+		}
+		m.pollLeft--
+		if m.pollLeft < 0 {
+			m.pollLeft = interruptPollInterval
+			if limits.TakeInterrupt() {
+				if err := m.raiseObj(&object.Error{Message: limits.InterruptMessage, Code: limits.InterruptCode}); err != nil {
+					return err
 				}
-				if err := m.raiseObj(errObj); err != nil {
+				continue
+			}
+			if !m.deadline.IsZero() && !time.Now().Before(m.deadline) {
+				m.deadline = time.Time{}
+				if err := m.raiseObj(builtins.TimeoutError()); err != nil {
 					return err
 				}
 				continue
@@ -285,15 +594,15 @@ func (m *VM) run(stopFrames int) error {
 		case code.OpConstant:
 			idx := int(code.ReadUint16(ins[frame.ip+1:]))
 			frame.ip += 2
-			if s, ok := m.constants[idx].(*object.String); ok {
-				if errObj := m.chargeMemory(object.CostStringBytes(len(s.Value))); errObj != nil {
+			if s, ok := frame.cl.Module.Constants[idx].(*object.String); ok {
+				if errObj := m.chargeAlloc("string", object.CostStringBytes(len(s.Value))); errObj != nil {
 					if err := m.raiseObj(errObj); err != nil {
 						return err
 					}
 					continue
 				}
 			}
-			if err := m.tryPush(m.constants[idx]); err != nil {
+			if err := m.tryPush(frame.cl.Module.Constants[idx]); err != nil {
 				return err
 			}
 			continue
@@ -324,13 +633,15 @@ func (m *VM) run(stopFrames int) error {
 			for i := n - 1; i >= 0; i-- {
 				elems[i] = m.pop()
 			}
-			if errObj := m.chargeMemory(object.CostArray(len(elems))); errObj != nil {
+			if errObj := m.chargeAlloc("array", object.CostArray(len(elems))); errObj != nil {
 				if err := m.raiseObj(errObj); err != nil {
 					return err
 				}
 				continue
 			}
-			if err := m.tryPush(&object.Array{Elements: elems}); err != nil {
+			arr := &object.Array{Elements: elems}
+			m.noteSite(arr)
+			if err := m.tryPush(arr); err != nil {
 				return err
 			}
 			continue
@@ -345,12 +656,13 @@ func (m *VM) run(stopFrames int) error {
 				}
 				continue
 			}
-			if errObj := m.chargeMemory(object.CostArrayElements(1)); errObj != nil {
+			if errObj := m.chargeAlloc("array", object.CostArrayElements(1)); errObj != nil {
 				if err := m.raiseObj(errObj); err != nil {
 					return err
 				}
 				continue
 			}
+			arr.Own()
 			arr.Elements = append(arr.Elements, val)
 			if err := m.tryPush(arr); err != nil {
 				return err
@@ -365,7 +677,7 @@ func (m *VM) run(stopFrames int) error {
 			for i := n - 1; i >= 0; i-- {
 				elems[i] = m.pop()
 			}
-			if errObj := m.chargeMemory(object.CostTuple(len(elems))); errObj != nil {
+			if errObj := m.chargeAlloc("tuple", object.CostTuple(len(elems))); errObj != nil {
 				if err := m.raiseObj(errObj); err != nil {
 					return err
 				}
@@ -376,11 +688,56 @@ func (m *VM) run(stopFrames int) error {
 			}
 			continue
 
+		case code.OpNamedTuple:
+			n := int(code.ReadUint16(ins[frame.ip+1:]))
+			shape := frame.cl.Module.Constants[code.ReadUint16(ins[frame.ip+3:])].(*object.TupleShape)
+			frame.ip += 4
+
+			elems := make([]object.Object, n)
+			for i := n - 1; i >= 0; i-- {
+				elems[i] = m.pop()
+			}
+			if errObj := m.chargeAlloc("tuple", object.CostTuple(len(elems))); errObj != nil {
+				if err := m.raiseObj(errObj); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := m.tryPush(&object.Tuple{Elements: elems, Names: shape.Names}); err != nil {
+				return err
+			}
+			continue
+
+		case code.OpClass:
+			n := int(code.ReadUint16(ins[frame.ip+1:]))
+			shape := frame.cl.Module.Constants[code.ReadUint16(ins[frame.ip+3:])].(*object.ClassShape)
+			frame.ip += 4
+
+			vals := make([]object.Object, n)
+			for i := n - 1; i >= 0; i-- {
+				vals[i] = m.pop()
+			}
+			if errObj := m.chargeAlloc("class", object.CostClass(len(shape.Fields), len(shape.Methods))); errObj != nil {
+				if err := m.raiseObj(errObj); err != nil {
+					return err
+				}
+				continue
+			}
+			nf := len(shape.Fields)
+			methods := make(map[string]object.Object, len(shape.Methods))
+			for i, name := range shape.Methods {
+				methods[name] = vals[nf+i]
+			}
+			if err := m.tryPush(object.NewClass(shape.Name, shape.Fields, vals[:nf], methods)); err != nil {
+				return err
+			}
+			continue
+
 		case code.OpDict:
 			n := int(code.ReadUint16(ins[frame.ip+1:]))
 			frame.ip += 2
 
-			pairs := make(map[string]object.DictPair, n)
+			pairs := make(map[object.HashKey]object.DictPair, n)
 			raw := make([]object.DictPair, n)
 			for i := 0; i < n; i++ {
 				val := m.pop()
@@ -397,15 +754,94 @@ func (m *VM) run(stopFrames int) error {
 					}
 					continue
 				}
-				pairs[object.HashKeyString(hk)] = raw[i]
+				pairs[hk] = raw[i]
+			}
+			if errObj := m.chargeAlloc("dict", object.CostDict(len(pairs))); errObj != nil {
+				if err := m.raiseObj(errObj); err != nil {
+					return err
+				}
+				continue
+			}
+			dict := &object.Dict{Pairs: pairs}
+			m.noteSite(dict)
+			if err := m.tryPush(dict); err != nil {
+				return err
+			}
+			continue
+
+		case code.OpArraySpread:
+			n := int(code.ReadUint16(ins[frame.ip+1:]))
+			frame.ip += 2
+
+			raw := make([]object.Object, n)
+			for i := n - 1; i >= 0; i-- {
+				raw[i] = m.pop()
+			}
+			elems, errObj := m.expandSpreads(raw, "array literal")
+			if errObj == nil {
+				errObj = m.chargeAlloc("array", object.CostArray(len(elems)))
+			}
+			if errObj != nil {
+				if err := m.raiseObj(errObj); err != nil {
+					return err
+				}
+				continue
+			}
+			arr := &object.Array{Elements: elems}
+			m.noteSite(arr)
+			if err := m.tryPush(arr); err != nil {
+				return err
+			}
+			continue
+
+		case code.OpDictSpread:
+			n := int(code.ReadUint16(ins[frame.ip+1:]))
+			frame.ip += 2
+
+			raw := make([]object.DictPair, n)
+			for i := n - 1; i >= 0; i-- {
+				raw[i].Value = m.pop()
+				raw[i].Key = m.pop()
+			}
+			pairs, errObj := mergeDictEntries(raw)
+			if errObj == nil {
+				errObj = m.chargeAlloc("dict", object.CostDict(len(pairs)))
 			}
-			if errObj := m.chargeMemory(object.CostDict(len(pairs))); errObj != nil {
+			if errObj != nil {
 				if err := m.raiseObj(errObj); err != nil {
 					return err
 				}
 				continue
 			}
-			if err := m.tryPush(&object.Dict{Pairs: pairs}); err != nil {
+			dict := &object.Dict{Pairs: pairs}
+			m.noteSite(dict)
+			if err := m.tryPush(dict); err != nil {
+				return err
+			}
+			continue
+
+		case code.OpSet:
+			n := int(code.ReadUint16(ins[frame.ip+1:]))
+			frame.ip += 2
+
+			els := make([]object.Object, n)
+			for i := n - 1; i >= 0; i-- {
+				els[i] = m.pop()
+			}
+			set, err := semantics.SetOf(els)
+			var errObj *object.Error
+			if err != nil {
+				errObj = &object.Error{Message: err.Error()}
+			} else {
+				errObj = m.chargeAlloc("set", object.CostSet(len(set.Items)))
+			}
+			if errObj != nil {
+				if err := m.raiseObj(errObj); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := m.tryPush(set); err != nil {
 				return err
 			}
 			continue
@@ -431,7 +867,7 @@ func (m *VM) run(stopFrames int) error {
 				items := make([]object.Object, 0, len(rs))
 				for _, rch := range rs {
 					s := &object.String{Value: string(rch)}
-					if errObj := m.chargeMemory(object.CostStringBytes(len(s.Value))); errObj != nil {
+					if errObj := m.chargeAlloc("string", object.CostStringBytes(len(s.Value))); errObj != nil {
 						if err := m.raiseObj(errObj); err != nil {
 							return err
 						}
@@ -442,6 +878,22 @@ func (m *VM) run(stopFrames int) error {
 				if err := m.tryPush(&vmIterator{items: items}); err != nil {
 					return err
 				}
+			case *object.Set:
+				if err := m.tryPush(&vmIterator{items: object.SortedSetItems(v)}); err != nil {
+					return err
+				}
+			case *object.Seq:
+				if err := m.tryPush(&vmIterator{seq: semantics.NewSeqCursor(v)}); err != nil {
+					return err
+				}
+			case *object.Range:
+				if err := m.tryPush(&vmIterator{rng: v}); err != nil {
+					return err
+				}
+			case *vmGenerator:
+				if err := m.tryPush(&vmIterator{gen: v}); err != nil {
+					return err
+				}
 			default:
 				if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("cannot iterate over type: %s", iterable.Type())}); err != nil {
 					return err
@@ -470,7 +922,7 @@ func (m *VM) run(stopFrames int) error {
 				items := make([]object.Object, 0, len(rs))
 				for _, rch := range rs {
 					s := &object.String{Value: string(rch)}
-					if errObj := m.chargeMemory(object.CostStringBytes(len(s.Value))); errObj != nil {
+					if errObj := m.chargeAlloc("string", object.CostStringBytes(len(s.Value))); errObj != nil {
 						if err := m.raiseObj(errObj); err != nil {
 							return err
 						}
@@ -481,6 +933,22 @@ func (m *VM) run(stopFrames int) error {
 				if err := m.tryPush(&vmIterator{items: items}); err != nil {
 					return err
 				}
+			case *object.Set:
+				if err := m.tryPush(&vmIterator{items: object.SortedSetItems(v)}); err != nil {
+					return err
+				}
+			case *object.Seq:
+				if err := m.tryPush(&vmIterator{seq: semantics.NewSeqCursor(v)}); err != nil {
+					return err
+				}
+			case *object.Range:
+				if err := m.tryPush(&vmIterator{rng: v}); err != nil {
+					return err
+				}
+			case *vmGenerator:
+				if err := m.tryPush(&vmIterator{gen: v}); err != nil {
+					return err
+				}
 			default:
 				if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("cannot iterate %s in comprehension", iterable.Type())}); err != nil {
 					return err
@@ -516,132 +984,86 @@ func (m *VM) run(stopFrames int) error {
 				}
 				continue
 			}
-			val, ok := it.next()
+			val, ok, failed, err := it.next(m)
+			if failed {
+				if err != nil {
+					return err
+				}
+				continue
+			}
 			if err := m.tryPush(val); err != nil {
 				return err
 			}
 			if err := m.tryPush(nativeBool(ok)); err != nil {
-				return err
-			}
-			continue
-
-		case code.OpIndex:
-			idx := m.pop()
-			left := m.pop()
-
-			switch l := left.(type) {
-			case *object.Array:
-				i, ok := idx.(*object.Integer)
-				if !ok {
-					if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("array index must be INTEGER, got %s", idx.Type())}); err != nil {
-						return err
-					}
-					continue
-				}
-				n := int(i.Value)
-				L := len(l.Elements)
-				if n < 0 {
-					n = L + n
-				}
-				if n < 0 || n >= L {
-					if err := m.raiseObj(&object.Error{Message: "index out of range"}); err != nil {
-						return err
-					}
-					continue
-				}
-				if err := m.tryPush(l.Elements[n]); err != nil {
-					return err
-				}
-				continue
-
-			case *object.Tuple:
-				i, ok := idx.(*object.Integer)
-				if !ok {
-					if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("tuple index must be INTEGER, got %s", idx.Type())}); err != nil {
-						return err
-					}
-					continue
-				}
-				n := int(i.Value)
-				L := len(l.Elements)
-				if n < 0 {
-					n = L + n
-				}
-				if n < 0 || n >= L {
-					if err := m.raiseObj(&object.Error{Message: "index out of range"}); err != nil {
-						return err
-					}
-					continue
-				}
-				if err := m.tryPush(l.Elements[n]); err != nil {
-					return err
-				}
-				continue
-
-			case *object.String:
-				i, ok := idx.(*object.Integer)
-				if !ok {
-					if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("string index must be INTEGER, got %s", idx.Type())}); err != nil {
-						return err
-					}
-					continue
-				}
-				rs := []rune(l.Value)
-				n := int(i.Value)
-				L := len(rs)
-				if n < 0 {
-					n = L + n
-				}
-				if n < 0 || n >= L {
-					if err := m.raiseObj(&object.Error{Message: "index out of range"}); err != nil {
-						return err
-					}
-					continue
-				}
-				out := &object.String{Value: string(rs[n])}
-				if errObj := m.chargeMemory(object.CostStringBytes(len(out.Value))); errObj != nil {
-					if err := m.raiseObj(errObj); err != nil {
-						return err
-					}
-					continue
-				}
-				if err := m.tryPush(out); err != nil {
+				return err
+			}
+			continue
+
+		case code.OpIndex:
+			idx := m.pop()
+			left := m.pop()
+			val, errObj, blame := m.indexValue(left, idx)
+			if errObj != nil {
+				if err := m.raiseIndexError(errObj, blame, 0); err != nil {
 					return err
 				}
 				continue
+			}
+			if err := m.tryPush(val); err != nil {
+				return err
+			}
+			continue
 
-			case *object.Dict:
-				hk, ok := object.HashKeyOf(idx)
-				if !ok {
-					if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("unusable as dict key: %s", idx.Type())}); err != nil {
-						return err
-					}
-					continue
-				}
-				pair, ok := l.Pairs[object.HashKeyString(hk)]
-				if !ok {
-					if err := m.tryPush(nilObj); err != nil {
-						return err
-					}
-					continue
+		case code.OpIndexChain:
+			// Index left by each of the n indices above it in turn. Operand
+			// ranges come in pairs, one (left, index) pair per level.
+			n := int(ins[frame.ip+1])
+			frame.ip += 1
+			base := m.sp - n - 1
+			val := m.stack[base]
+			var errObj *object.Error
+			level, blame := 0, blameNone
+			for ; level < n; level++ {
+				val, errObj, blame = m.indexValue(val, m.stack[base+1+level])
+				if errObj != nil {
+					break
 				}
-				if err := m.tryPush(pair.Value); err != nil {
+			}
+			for i := base; i < m.sp; i++ {
+				m.stack[i] = nil
+			}
+			m.sp = base
+			if errObj != nil {
+				if err := m.raiseIndexError(errObj, blame, 2*level); err != nil {
 					return err
 				}
 				continue
+			}
+			if err := m.tryPush(val); err != nil {
+				return err
+			}
+			continue
 
-			default:
-				if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("indexing not supported on %s", left.Type())}); err != nil {
-					return err
-				}
-				continue
+		case code.OpGetLocalMember:
+			// Push the local, then continue as OpGetMember with the name
+			// operand that follows.
+			obj := m.stack[frame.basePointer+int(ins[frame.ip+1])]
+			frame.ip += 1
+			if cell, ok := obj.(*object.Cell); ok {
+				obj = cellValue(cell)
+			} else if obj == nil {
+				obj = nilObj
+			}
+			if err := m.tryPush(obj); err != nil {
+				return err
 			}
+			fallthrough
 
 		case code.OpGetMember:
 			nameIdx := int(code.ReadUint16(ins[frame.ip+1:]))
 			frame.ip += 2
 
-			nameObj, ok := m.constants[nameIdx].(*object.String)
+			nameObj, ok := frame.cl.Module.Constants[nameIdx].(*object.String)
 			if !ok {
 				if err := m.raiseObj(&object.Error{Message: "member name must be string constant"}); err != nil {
 					return err
@@ -659,9 +1081,9 @@ func (m *VM) run(stopFrames int) error {
 					}
 					continue
 				}
-				pair, ok := l.Pairs[object.HashKeyString(hk)]
+				pair, ok := l.Pairs[hk]
 				if !ok {
-					if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("unknown member: %s", nameObj.Value)}); err != nil {
+					if err := m.raiseAt(1, &object.Error{Message: fmt.Sprintf("unknown member: %s", nameObj.Value)}); err != nil {
 						return err
 					}
 					continue
@@ -683,12 +1105,12 @@ func (m *VM) run(stopFrames int) error {
 						}
 						continue
 					}
-					if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("unknown member on %s: %s", left.Type(), nameObj.Value)}); err != nil {
+					if err := m.raiseAt(1, &object.Error{Message: fmt.Sprintf("unknown member on %s: %s", left.Type(), nameObj.Value)}); err != nil {
 						return err
 					}
 					continue
 				}
-				if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("no member access on %s", left.Type())}); err != nil {
+				if err := m.raiseAt(0, &object.Error{Message: fmt.Sprintf("no member access on %s", left.Type())}); err != nil {
 					return err
 				}
 				continue
@@ -698,7 +1120,7 @@ func (m *VM) run(stopFrames int) error {
 			nameIdx := int(code.ReadUint16(ins[frame.ip+1:]))
 			frame.ip += 2
 
-			nameObj, ok := m.constants[nameIdx].(*object.String)
+			nameObj, ok := frame.cl.Module.Constants[nameIdx].(*object.String)
 			if !ok {
 				if err := m.raiseObj(&object.Error{Message: "member name must be string constant"}); err != nil {
 					return err
@@ -709,9 +1131,28 @@ func (m *VM) run(stopFrames int) error {
 			val := m.pop()
 			left := m.pop()
 
+			if setter, ok := left.(object.MemberSetter); ok {
+				if err := setter.SetMember(nameObj.Value, val); err != nil {
+					if err := m.raiseAt(0, &object.Error{Message: err.Error()}); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := m.tryPush(val); err != nil {
+					return err
+				}
+				continue
+			}
+
 			d, ok := left.(*object.Dict)
 			if !ok {
-				if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("member assignment not supported on %s", left.Type())}); err != nil {
+				if err := m.raiseAt(0, &object.Error{Message: fmt.Sprintf("member assignment not supported on %s", left.Type())}); err != nil {
+					return err
+				}
+				continue
+			}
+			if d.Frozen {
+				if err := m.raiseAt(0, &object.Error{Message: semantics.ReadOnlyExportsMessage}); err != nil {
 					return err
 				}
 				continue
@@ -725,18 +1166,17 @@ func (m *VM) run(stopFrames int) error {
 				continue
 			}
 			if d.Pairs == nil {
-				d.Pairs = map[string]object.DictPair{}
+				d.Pairs = map[object.HashKey]object.DictPair{}
 			}
-			keyStr := object.HashKeyString(hk)
-			if _, exists := d.Pairs[keyStr]; !exists {
-				if errObj := m.chargeMemory(object.CostDictEntry()); errObj != nil {
+			if _, exists := d.Pairs[hk]; !exists {
+				if errObj := m.chargeAlloc("dict", object.CostDictEntry()); errObj != nil {
 					if err := m.raiseObj(errObj); err != nil {
 						return err
 					}
 					continue
 				}
 			}
-			d.Pairs[keyStr] = object.DictPair{Key: nameObj, Value: val}
+			d.Pairs[hk] = object.DictPair{Key: nameObj, Value: val}
 			if err := m.tryPush(val); err != nil {
 				return err
 			}
@@ -751,7 +1191,7 @@ func (m *VM) run(stopFrames int) error {
 			case *object.Array:
 				i, ok := idx.(*object.Integer)
 				if !ok {
-					if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("array index must be INTEGER, got %s", idx.Type())}); err != nil {
+					if err := m.raiseAt(1, &object.Error{Message: fmt.Sprintf("array index must be INTEGER, got %s", idx.Type())}); err != nil {
 						return err
 					}
 					continue
@@ -762,11 +1202,12 @@ func (m *VM) run(stopFrames int) error {
 					n = L + n
 				}
 				if n < 0 || n >= L {
-					if err := m.raiseObj(&object.Error{Message: "index out of range"}); err != nil {
+					if err := m.raiseAt(1, &object.Error{Message: "index out of range"}); err != nil {
 						return err
 					}
 					continue
 				}
+				l.Own()
 				l.Elements[n] = val
 				if err := m.tryPush(val); err != nil {
 					return err
@@ -774,26 +1215,31 @@ func (m *VM) run(stopFrames int) error {
 				continue
 
 			case *object.Dict:
+				if l.Frozen {
+					if err := m.raiseAt(0, &object.Error{Message: semantics.ReadOnlyExportsMessage}); err != nil {
+						return err
+					}
+					continue
+				}
 				hk, ok := object.HashKeyOf(idx)
 				if !ok {
-					if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("unusable as dict key: %s", idx.Type())}); err != nil {
+					if err := m.raiseAt(1, &object.Error{Message: fmt.Sprintf("unusable as dict key: %s", idx.Type())}); err != nil {
 						return err
 					}
 					continue
 				}
 				if l.Pairs == nil {
-					l.Pairs = map[string]object.DictPair{}
+					l.Pairs = map[object.HashKey]object.DictPair{}
 				}
-				keyStr := object.HashKeyString(hk)
-				if _, exists := l.Pairs[keyStr]; !exists {
-					if errObj := m.chargeMemory(object.CostDictEntry()); errObj != nil {
+				if _, exists := l.Pairs[hk]; !exists {
+					if errObj := m.chargeAlloc("dict", object.CostDictEntry()); errObj != nil {
 						if err := m.raiseObj(errObj); err != nil {
 							return err
 						}
 						continue
 					}
 				}
-				l.Pairs[keyStr] = object.DictPair{Key: idx, Value: val}
+				l.Pairs[hk] = object.DictPair{Key: idx, Value: val}
 				if err := m.tryPush(val); err != nil {
 					return err
 				}
@@ -866,7 +1312,7 @@ func (m *VM) run(stopFrames int) error {
 			switch l := left.(type) {
 			case *object.Array:
 				out := sliceElements(l.Elements, lowPtr, highPtr, stepVal)
-				if errObj := m.chargeMemory(object.CostArray(len(out))); errObj != nil {
+				if errObj := m.chargeAlloc("array", object.CostArray(len(out))); errObj != nil {
 					if err := m.raiseObj(errObj); err != nil {
 						return err
 					}
@@ -878,9 +1324,21 @@ func (m *VM) run(stopFrames int) error {
 				continue
 
 			case *object.String:
-				rs := []rune(l.Value)
-				out := &object.String{Value: string(sliceRunes(rs, lowPtr, highPtr, stepVal))}
-				if errObj := m.chargeMemory(object.CostStringBytes(len(out.Value))); errObj != nil {
+				out := &object.String{Value: sliceString(l, lowPtr, highPtr, stepVal)}
+				if errObj := m.chargeAlloc("string", object.CostStringBytes(len(out.Value))); errObj != nil {
+					if err := m.raiseObj(errObj); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := m.tryPush(out); err != nil {
+					return err
+				}
+				continue
+
+			case *object.Range:
+				out := semantics.SliceRange(l, lowPtr, highPtr, stepVal)
+				if errObj := m.chargeAlloc("range", object.CostRange()); errObj != nil {
 					if err := m.raiseObj(errObj); err != nil {
 						return err
 					}
@@ -898,6 +1356,45 @@ func (m *VM) run(stopFrames int) error {
 				continue
 			}
 
+		case code.OpSetSlice:
+			val := m.pop()
+			stepObj := m.pop()
+			highObj := m.pop()
+			lowObj := m.pop()
+			left := m.pop()
+			if err := m.setSlice(left, lowObj, highObj, stepObj, val); err != nil {
+				if err := m.raiseObj(err); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := m.tryPush(val); err != nil {
+				return err
+			}
+			continue
+
+		case code.OpDelIndex:
+			idx := m.pop()
+			left := m.pop()
+			if err := semantics.Delete(left, idx); err != nil {
+				if err := m.raiseObj(&object.Error{Message: err.Error()}); err != nil {
+					return err
+				}
+			}
+			continue
+
+		case code.OpDelSlice:
+			stepObj := m.pop()
+			highObj := m.pop()
+			lowObj := m.pop()
+			left := m.pop()
+			if err := m.delSlice(left, lowObj, highObj, stepObj); err != nil {
+				if err := m.raiseObj(err); err != nil {
+					return err
+				}
+			}
+			continue
+
 		case code.OpUnpackTuple:
 			n := int(code.ReadUint16(ins[frame.ip+1:]))
 			frame.ip += 2
@@ -909,6 +1406,14 @@ func (m *VM) run(stopFrames int) error {
 				elems = seq.Elements
 			case *object.Array:
 				elems = seq.Elements
+			case *object.Range:
+				if errObj := m.chargeAlloc("array", object.CostArray(int(seq.Len()))); errObj != nil {
+					if err := m.raiseObj(errObj); err != nil {
+						return err
+					}
+					continue
+				}
+				elems = seq.Array().Elements
 			default:
 				if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("unpack expects tuple, got %s", val.Type())}); err != nil {
 					return err
@@ -931,6 +1436,28 @@ func (m *VM) run(stopFrames int) error {
 			}
 			continue
 
+		case code.OpUnpackNamed:
+			shape := frame.cl.Module.Constants[code.ReadUint16(ins[frame.ip+3:])].(*object.TupleShape)
+			frame.ip += 4
+
+			val := m.pop()
+			fields, err := semantics.UnpackFields(val, shape.Names)
+			if err != nil {
+				if err := m.raiseObj(&object.Error{Message: err.Error()}); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := m.tryPush(val); err != nil {
+				return err
+			}
+			for _, f := range fields {
+				if err := m.tryPush(f); err != nil {
+					return err
+				}
+			}
+			continue
+
 		case code.OpUnpackStar:
 			n := int(code.ReadUint16(ins[frame.ip+1:]))
 			starIdx := int(code.ReadUint16(ins[frame.ip+3:]))
@@ -943,6 +1470,14 @@ func (m *VM) run(stopFrames int) error {
 				elems = seq.Elements
 			case *object.Array:
 				elems = seq.Elements
+			case *object.Range:
+				if errObj := m.chargeAlloc("array", object.CostArray(int(seq.Len()))); errObj != nil {
+					if err := m.raiseObj(errObj); err != nil {
+						return err
+					}
+					continue
+				}
+				elems = seq.Array().Elements
 			default:
 				if err := m.raiseObj(&object.Error{Message: "cannot unpack non-sequence"}); err != nil {
 					return err
@@ -975,7 +1510,7 @@ func (m *VM) run(stopFrames int) error {
 			for i := midStart; i < midEnd; i++ {
 				mid = append(mid, elems[i])
 			}
-			if errObj := m.chargeMemory(object.CostArray(len(mid))); errObj != nil {
+			if errObj := m.chargeAlloc("array", object.CostArray(len(mid))); errObj != nil {
 				if err := m.raiseObj(errObj); err != nil {
 					return err
 				}
@@ -1006,7 +1541,7 @@ func (m *VM) run(stopFrames int) error {
 		case code.OpSetGlobal:
 			idx := int(code.ReadUint16(ins[frame.ip+1:]))
 			frame.ip += 2
-			m.globals[idx] = m.pop()
+			frame.cl.Module.SetGlobal(idx, m.pop())
 			continue
 
 		case code.OpDefineGlobal:
@@ -1014,9 +1549,9 @@ func (m *VM) run(stopFrames int) error {
 			nameIdx := int(code.ReadUint16(ins[frame.ip+3:]))
 			frame.ip += 4
 			val := m.pop()
-			if m.globals[idx] != nil {
+			if frame.cl.Module.Global(idx) != nil {
 				name := "<unknown>"
-				if nameObj, ok := m.constants[nameIdx].(*object.String); ok {
+				if nameObj, ok := frame.cl.Module.Constants[nameIdx].(*object.String); ok {
 					name = nameObj.Value
 				}
 				if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("cannot redeclare %q in this scope", name)}); err != nil {
@@ -1024,13 +1559,13 @@ func (m *VM) run(stopFrames int) error {
 				}
 				continue
 			}
-			m.globals[idx] = val
+			frame.cl.Module.SetGlobal(idx, val)
 			continue
 
 		case code.OpGetGlobal:
 			idx := int(code.ReadUint16(ins[frame.ip+1:]))
 			frame.ip += 2
-			val := m.globals[idx]
+			val := frame.cl.Module.Global(idx)
 			if val == nil {
 				if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("uninitialized global at %d", idx)}); err != nil {
 					return err
@@ -1053,7 +1588,7 @@ func (m *VM) run(stopFrames int) error {
 				continue
 			}
 
-			pathObj, ok := m.constants[pathIdx].(*object.String)
+			pathObj, ok := frame.cl.Module.Constants[pathIdx].(*object.String)
 			if !ok {
 				if err := m.raiseObj(&object.Error{Message: "import path must be string constant"}); err != nil {
 					return err
@@ -1080,20 +1615,13 @@ func (m *VM) run(stopFrames int) error {
 				continue
 			}
 
-			modVM := NewWithImporter(bc, absPath, m.importer)
-			modVM.SetMaxRecursion(m.maxRecursion)
-			modVM.SetMaxSteps(m.maxSteps)
-			modVM.SetBudget(m.budget)
-			modVM.modules = m.modules
-			modVM.imports = m.imports
-			if err := modVM.Run(); err != nil {
+			mod, err := m.runModule(bc, absPath)
+			if err != nil {
 				if err := m.raiseObj(&object.Error{Message: err.Error()}); err != nil {
 					return err
 				}
 				continue
 			}
-			mod := modVM.Exports()
-			m.modules[absPath] = mod
 			if err := m.tryPush(mod); err != nil {
 				return err
 			}
@@ -1111,14 +1639,14 @@ func (m *VM) run(stopFrames int) error {
 				continue
 			}
 
-			pathObj, ok := m.constants[pathIdx].(*object.String)
+			pathObj, ok := frame.cl.Module.Constants[pathIdx].(*object.String)
 			if !ok {
 				if err := m.raiseObj(&object.Error{Message: "import path must be string constant"}); err != nil {
 					return err
 				}
 				continue
 			}
-			nameObj, ok := m.constants[nameIdx].(*object.String)
+			nameObj, ok := frame.cl.Module.Constants[nameIdx].(*object.String)
 			if !ok {
 				if err := m.raiseObj(&object.Error{Message: "import name must be string constant"}); err != nil {
 					return err
@@ -1140,22 +1668,23 @@ func (m *VM) run(stopFrames int) error {
 
 			mod, ok := m.modules[absPath]
 			if !ok {
-				modVM := NewWithImporter(bc, absPath, m.importer)
-				modVM.SetMaxRecursion(m.maxRecursion)
-				modVM.SetMaxSteps(m.maxSteps)
-				modVM.SetBudget(m.budget)
-				modVM.modules = m.modules
-				modVM.imports = m.imports
-				if err := modVM.Run(); err != nil {
+				mod, err = m.runModule(bc, absPath)
+				if err != nil {
 					if err := m.raiseObj(&object.Error{Message: err.Error()}); err != nil {
 						return err
 					}
 					continue
 				}
-				mod = modVM.Exports()
-				m.modules[absPath] = mod
 			}
 
+			if seg, ok := m.segments[absPath]; ok {
+				if slot, ok := seg.slots[nameObj.Value]; ok && seg.module.Global(slot) != nil {
+					if err := m.tryPush(seg.module.Global(slot)); err != nil {
+						return err
+					}
+					continue
+				}
+			}
 			hk, ok := object.HashKeyOf(nameObj)
 			if !ok {
 				if err := m.raiseObj(&object.Error{Message: "invalid from-import name"}); err != nil {
@@ -1163,7 +1692,7 @@ func (m *VM) run(stopFrames int) error {
 				}
 				continue
 			}
-			pair, ok := mod.Pairs[object.HashKeyString(hk)]
+			pair, ok := mod.Pairs[hk]
 			if !ok {
 				if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("missing export %q in module %q", nameObj.Value, pathObj.Value)}); err != nil {
 					return err
@@ -1179,7 +1708,7 @@ func (m *VM) run(stopFrames int) error {
 			nameIdx := int(code.ReadUint16(ins[frame.ip+1:]))
 			frame.ip += 2
 
-			nameObj, ok := m.constants[nameIdx].(*object.String)
+			nameObj, ok := frame.cl.Module.Constants[nameIdx].(*object.String)
 			if !ok {
 				if err := m.raiseObj(&object.Error{Message: "export name must be string constant"}); err != nil {
 					return err
@@ -1195,13 +1724,13 @@ func (m *VM) run(stopFrames int) error {
 				}
 				continue
 			}
-			m.exports.Pairs[object.HashKeyString(hk)] = object.DictPair{Key: nameObj, Value: val}
+			m.exports.Pairs[hk] = object.DictPair{Key: nameObj, Value: val}
 			continue
 
 		case code.OpGetBuiltin:
 			idx := int(ins[frame.ip+1])
 			frame.ip += 1
-			if err := m.tryPush(builtins[idx]); err != nil {
+			if err := m.tryPush(builtins.At(idx)); err != nil {
 				return err
 			}
 			continue
@@ -1223,9 +1752,15 @@ func (m *VM) run(stopFrames int) error {
 				}
 				continue
 			}
+			if ld.Frozen {
+				if err := m.raiseAt(0, &object.Error{Message: semantics.ReadOnlyExportsMessage}); err != nil {
+					return err
+				}
+				continue
+			}
 			added := semantics.DictUpdateCount(ld, rd)
 			if added > 0 {
-				if errObj := m.chargeMemory(object.CostDictEntry() * int64(added)); errObj != nil {
+				if errObj := m.chargeAlloc("dict", object.CostDictEntry()*int64(added)); errObj != nil {
 					if err := m.raiseObj(errObj); err != nil {
 						return err
 					}
@@ -1241,7 +1776,7 @@ func (m *VM) run(stopFrames int) error {
 		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
 			code.OpBitOr, code.OpBitAnd, code.OpBitXor, code.OpShl, code.OpShr:
 			if err := m.execBinaryOp(op); err != nil {
-				if err := m.raiseObj(&object.Error{Message: err.Error()}); err != nil {
+				if err := m.raiseAt(binaryErrOperand(err), &object.Error{Message: err.Error()}); err != nil {
 					return err
 				}
 			}
@@ -1274,7 +1809,7 @@ func (m *VM) run(stopFrames int) error {
 					return err
 				}
 			default:
-				if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("unsupported operand for unary -: %s", right.Type())}); err != nil {
+				if err := m.raiseAt(0, &object.Error{Message: fmt.Sprintf("unsupported operand for unary -: %s", right.Type())}); err != nil {
 					return err
 				}
 			}
@@ -1309,6 +1844,56 @@ func (m *VM) run(stopFrames int) error {
 			}
 			continue
 
+		case code.OpJumpTable:
+			table := frame.cl.Module.Constants[code.ReadUint16(ins[frame.ip+1:])].(*object.JumpTable)
+			frame.ip += 2
+			if pos, ok := table.Lookup(m.pop()); ok {
+				frame.ip = pos - 1
+			}
+			continue
+
+		case code.OpMatchShape:
+			shape := int(ins[frame.ip+1])
+			n := int(code.ReadUint16(ins[frame.ip+2:]))
+			frame.ip += 3
+			if err := m.tryPush(nativeBool(semantics.MatchShape(m.pop(), shape, n))); err != nil {
+				return err
+			}
+			continue
+
+		case code.OpMatchEqual:
+			want := m.pop()
+			val := m.pop()
+			if err := m.tryPush(nativeBool(semantics.MatchEqual(val, want))); err != nil {
+				return err
+			}
+			continue
+
+		case code.OpCatchMatch:
+			filter := m.pop()
+			if err := m.tryPush(nativeBool(semantics.CatchMatches(m.stack[m.sp-1], filter))); err != nil {
+				return err
+			}
+			continue
+
+		case code.OpCompareJump:
+			cmp := code.Opcode(ins[frame.ip+1])
+			pos := int(code.ReadUint16(ins[frame.ip+2:]))
+			frame.ip += 3
+			right := m.pop()
+			left := m.pop()
+			b, err := semantics.Compare(opString(cmp), left, right)
+			if err != nil {
+				if err := m.raiseAt(-1, &object.Error{Message: err.Error()}); err != nil {
+					return err
+				}
+				continue
+			}
+			if !b {
+				frame.ip = pos - 1
+			}
+			continue
+
 		case code.OpJumpIfNil:
 			pos := int(code.ReadUint16(ins[frame.ip+1:]))
 			frame.ip += 2
@@ -1342,6 +1927,7 @@ func (m *VM) run(stopFrames int) error {
 				afterIP:   afterIP,
 				sp:        m.sp,
 				frameIdx:  m.framesIndex,
+				traps:     len(m.traps) - 1,
 			})
 			continue
 
@@ -1357,16 +1943,17 @@ func (m *VM) run(stopFrames int) error {
 				return errors.New(m.formatStackTrace("EndFinally with no active finally"))
 			}
 			m.finallys = m.finallys[:len(m.finallys)-1]
+			// A finally block entered normally has no pending error.
+			if err := m.tryPush(nilObj); err != nil {
+				return err
+			}
 			continue
 
 		case code.OpRethrowPending:
-			if m.pendingErr != nil {
-				errObj := m.pendingErr
-				m.pendingErr = nil
+			if errObj, ok := m.pop().(*object.Error); ok {
 				if err := m.raiseObj(errObj); err != nil {
 					return err
 				}
-				continue
 			}
 			continue
 
@@ -1380,6 +1967,8 @@ func (m *VM) run(stopFrames int) error {
 					errObj = &object.Error{
 						Message: errObj.Message,
 						Code:    errObj.Code,
+						Tag:     errObj.Tag,
+						Data:    errObj.Data,
 						Stack:   errObj.Stack,
 					}
 				}
@@ -1388,7 +1977,7 @@ func (m *VM) run(stopFrames int) error {
 			default:
 				errObj = &object.Error{Message: obj.Inspect()}
 			}
-			if err := m.raiseObj(errObj); err != nil {
+			if err := m.raise(errObj); err != nil {
 				return err
 			}
 			continue
@@ -1425,6 +2014,37 @@ func (m *VM) run(stopFrames int) error {
 			}
 			continue
 
+		case code.OpIncLocal:
+			localIndex := int(ins[frame.ip+1])
+			delta := frame.cl.Module.Constants[code.ReadUint16(ins[frame.ip+2:])]
+			frame.ip += 3
+			slot := &m.stack[frame.basePointer+localIndex]
+			cell, isCell := (*slot).(*object.Cell)
+			cur := *slot
+			if isCell {
+				cur = cellValue(cell)
+			} else if cur == nil {
+				cur = nilObj
+			}
+			var res object.Object
+			if l, ok := cur.(*object.Integer); ok {
+				res = &object.Integer{Value: l.Value + delta.(*object.Integer).Value}
+			} else {
+				var err error
+				if res, err = semantics.BinaryOp("+", cur, delta); err != nil {
+					if err := m.raiseAt(binaryErrOperand(err), &object.Error{Message: err.Error()}); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			if isCell {
+				cell.Value = res
+			} else {
+				*slot = res
+			}
+			continue
+
 		case code.OpDefineLocal:
 			localIndex := int(ins[frame.ip+1])
 			nameIdx := int(code.ReadUint16(ins[frame.ip+2:]))
@@ -1433,7 +2053,7 @@ func (m *VM) run(stopFrames int) error {
 			val := m.pop()
 			if m.stack[bp+localIndex] != nil {
 				name := "<unknown>"
-				if nameObj, ok := m.constants[nameIdx].(*object.String); ok {
+				if nameObj, ok := frame.cl.Module.Constants[nameIdx].(*object.String); ok {
 					name = nameObj.Value
 				}
 				if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("cannot redeclare %q in this scope", name)}); err != nil {
@@ -1449,7 +2069,7 @@ func (m *VM) run(stopFrames int) error {
 			numFree := int(ins[frame.ip+3])
 			frame.ip += 3
 
-			fnObj := m.constants[constIndex]
+			fnObj := frame.cl.Module.Constants[constIndex]
 			fn, ok := fnObj.(*object.CompiledFunction)
 			if !ok {
 				if err := m.raiseObj(&object.Error{Message: "constant is not CompiledFunction"}); err != nil {
@@ -1458,50 +2078,53 @@ func (m *VM) run(stopFrames int) error {
 				continue
 			}
 
-			free := make([]*object.Cell, numFree)
-			var memErr *object.Error
+			free := make([]object.Object, numFree)
 			for i := 0; i < numFree; i++ {
 				obj := m.stack[m.sp-numFree+i]
-				cell, ok := obj.(*object.Cell)
-				if !ok {
-					if obj == nil {
-						obj = nilObj
-					}
-					if memErr == nil {
-						if errObj := m.chargeMemory(object.CostCell()); errObj != nil {
-							memErr = errObj
-							break
-						}
-					}
-					cell = &object.Cell{Value: obj}
-				}
-				free[i] = cell
-			}
-			if memErr != nil {
-				if err := m.raiseObj(memErr); err != nil {
-					return err
+				if obj == nil {
+					obj = nilObj
 				}
-				continue
+				free[i] = obj
 			}
 			m.sp -= numFree
 
-			if errObj := m.chargeMemory(object.CostClosure(len(free))); errObj != nil {
+			if errObj := m.chargeAlloc("function", object.CostClosure(len(free))); errObj != nil {
 				if err := m.raiseObj(errObj); err != nil {
 					return err
 				}
 				continue
 			}
-			cl := &object.Closure{Fn: fn, Free: free}
+			cl := &object.Closure{Fn: fn, Free: free, Module: frame.cl.Module}
 			if err := m.tryPush(cl); err != nil {
 				return err
 			}
 			continue
 
+		case code.OpDefaults:
+			n := int(ins[frame.ip+1])
+			frame.ip += 1
+
+			defaults := make([]object.Object, n)
+			copy(defaults, m.stack[m.sp-n:m.sp])
+			m.sp -= n
+			cl, ok := m.stack[m.sp-1].(*object.Closure)
+			if !ok {
+				if err := m.raiseObj(&object.Error{Message: "OpDefaults expects a closure"}); err != nil {
+					return err
+				}
+				continue
+			}
+			cl.Defaults = defaults
+			continue
+
 		case code.OpGetFree:
 			freeIndex := int(ins[frame.ip+1])
 			frame.ip += 1
-			cl := m.currentFrame().cl
-			if err := m.tryPush(cellValue(cl.Free[freeIndex])); err != nil {
+			obj := m.currentFrame().cl.Free[freeIndex]
+			if cell, ok := obj.(*object.Cell); ok {
+				obj = cellValue(cell)
+			}
+			if err := m.tryPush(obj); err != nil {
 				return err
 			}
 			continue
@@ -1510,7 +2133,9 @@ func (m *VM) run(stopFrames int) error {
 			freeIndex := int(ins[frame.ip+1])
 			frame.ip += 1
 			cl := m.currentFrame().cl
-			cl.Free[freeIndex].Value = m.pop()
+			// The compiler captures a variable by value only when nothing
+			// assigns it after the closure exists, so this is always a Cell.
+			cl.Free[freeIndex].(*object.Cell).Value = m.pop()
 			continue
 
 		case code.OpGetFreeCell:
@@ -1532,7 +2157,7 @@ func (m *VM) run(stopFrames int) error {
 				if obj == nil {
 					obj = nilObj
 				}
-				if errObj := m.chargeMemory(object.CostCell()); errObj != nil {
+				if errObj := m.chargeAlloc("cell", object.CostCell()); errObj != nil {
 					if err := m.raiseObj(errObj); err != nil {
 						return err
 					}
@@ -1563,80 +2188,18 @@ func (m *VM) run(stopFrames int) error {
 					args[i] = m.pop()
 				}
 				m.pop() // callee
-
-				if b == builtins[builtinIndex["map"]] {
-					res, ok, err := m.runBuiltinMap(args)
-					if err != nil {
-						return err
-					}
-					if !ok {
-						continue
-					}
-					if errObj, ok := res.(*object.Error); ok {
-						if err := m.raiseObj(errObj); err != nil {
-							return err
-						}
-						continue
-					}
-					if memErr := m.chargeObject(res); memErr != nil {
-						if err := m.raiseObj(memErr); err != nil {
-							return err
-						}
-						continue
-					}
-					if err := m.tryPush(res); err != nil {
-						return err
-					}
-					continue
-				}
-
-				res := b.Fn(args...)
-				if errObj, ok := res.(*object.Error); ok {
-					if b == builtins[builtinIndex["error"]] {
-						if errObj.Stack == "" {
-							errObj.Stack = m.formatStackTrace(errObj.Message)
-						}
-						if memErr := m.chargeMemory(object.CostError()); memErr != nil {
-							if err := m.raiseObj(memErr); err != nil {
-								return err
-							}
-							continue
-						}
-						if err := m.tryPush(errObj); err != nil {
-							return err
-						}
-						continue
-					}
-					if err := m.raiseObj(errObj); err != nil {
-						return err
-					}
-					continue
-				}
-				if b == builtins[builtinIndex["sort"]] {
-					if arr, ok := res.(*object.Array); ok {
-						extra := int64(0)
-						for _, el := range arr.Elements {
-							if s, ok := el.(*object.String); ok {
-								extra += object.CostStringBytes(len(s.Value))
-							}
-						}
-						if extra > 0 {
-							if memErr := m.chargeMemory(extra); memErr != nil {
-								if err := m.raiseObj(memErr); err != nil {
-									return err
-								}
-								continue
-							}
-						}
-					}
-				}
-				if memErr := m.chargeObject(res); memErr != nil {
-					if err := m.raiseObj(memErr); err != nil {
-						return err
-					}
-					continue
+				if err := m.callBuiltin(b, args); err != nil {
+					return err
+				}
+				continue
+			}
+			if viaCallWithArgs(callee) {
+				args := make([]object.Object, numArgs)
+				for i := numArgs - 1; i >= 0; i-- {
+					args[i] = m.pop()
 				}
-				if err := m.tryPush(res); err != nil {
+				m.pop() // callee
+				if err := m.callWithArgs(callee, args); err != nil {
 					return err
 				}
 				continue
@@ -1648,17 +2211,25 @@ func (m *VM) run(stopFrames int) error {
 				if callee != nil {
 					typeName = string(callee.Type())
 				}
-				if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("attempted to call non-function: %s", typeName)}); err != nil {
+				if err := m.raiseAt(0, &object.Error{Message: fmt.Sprintf("attempted to call non-function: %s", typeName)}); err != nil {
 					return err
 				}
 				continue
 			}
 			fn := cl.Fn
 			if numArgs != fn.NumParameters {
-				if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("wrong number of arguments: expected %d, got %d", fn.NumParameters, numArgs)}); err != nil {
-					return err
+				if err := semantics.CheckArity(numArgs, fn.NumParameters, len(cl.Defaults)); err != nil {
+					if err := m.raiseObj(&object.Error{Message: err.Error()}); err != nil {
+						return err
+					}
+					continue
 				}
-				continue
+				for _, d := range semantics.MissingDefaults(numArgs, fn.NumParameters, cl.Defaults) {
+					if err := m.tryPush(d); err != nil {
+						return err
+					}
+				}
+				numArgs = fn.NumParameters
 			}
 			if m.maxRecursion > 0 && m.framesIndex >= m.maxRecursion+1 {
 				if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("max recursion depth exceeded (%d)", m.maxRecursion)}); err != nil {
@@ -1666,12 +2237,18 @@ func (m *VM) run(stopFrames int) error {
 				}
 				continue
 			}
+			if errObj := m.callOverflow(fn.NumLocals - numArgs); errObj != nil {
+				if err := m.raiseObj(errObj); err != nil {
+					return err
+				}
+				continue
+			}
 
 			basePointer := m.sp - numArgs
 			newFrame := NewFrame(cl, basePointer)
 			m.pushFrame(newFrame)
 
-			m.sp = basePointer + fn.NumLocals
+			m.enterLocals(basePointer, numArgs, fn.NumLocals)
 			continue
 
 		case code.OpCallSpread:
@@ -1684,7 +2261,7 @@ func (m *VM) run(stopFrames int) error {
 			}
 			callee := m.pop()
 
-			args, errObj := m.expandSpreadArgs(rawArgs)
+			args, errObj := m.expandSpreads(rawArgs, "call arguments")
 			if errObj != nil {
 				if err := m.raiseObj(errObj); err != nil {
 					return err
@@ -1692,80 +2269,8 @@ func (m *VM) run(stopFrames int) error {
 				continue
 			}
 
-			if b, ok := callee.(*object.Builtin); ok {
-				if b == builtins[builtinIndex["map"]] {
-					res, ok, err := m.runBuiltinMap(args)
-					if err != nil {
-						return err
-					}
-					if !ok {
-						continue
-					}
-					if errObj, ok := res.(*object.Error); ok {
-						if err := m.raiseObj(errObj); err != nil {
-							return err
-						}
-						continue
-					}
-					if memErr := m.chargeObject(res); memErr != nil {
-						if err := m.raiseObj(memErr); err != nil {
-							return err
-						}
-						continue
-					}
-					if err := m.tryPush(res); err != nil {
-						return err
-					}
-					continue
-				}
-
-				res := b.Fn(args...)
-				if errObj, ok := res.(*object.Error); ok {
-					if b == builtins[builtinIndex["error"]] {
-						if errObj.Stack == "" {
-							errObj.Stack = m.formatStackTrace(errObj.Message)
-						}
-						if memErr := m.chargeMemory(object.CostError()); memErr != nil {
-							if err := m.raiseObj(memErr); err != nil {
-								return err
-							}
-							continue
-						}
-						if err := m.tryPush(errObj); err != nil {
-							return err
-						}
-						continue
-					}
-					if err := m.raiseObj(errObj); err != nil {
-						return err
-					}
-					continue
-				}
-				if b == builtins[builtinIndex["sort"]] {
-					if arr, ok := res.(*object.Array); ok {
-						extra := int64(0)
-						for _, el := range arr.Elements {
-							if s, ok := el.(*object.String); ok {
-								extra += object.CostStringBytes(len(s.Value))
-							}
-						}
-						if extra > 0 {
-							if memErr := m.chargeMemory(extra); memErr != nil {
-								if err := m.raiseObj(memErr); err != nil {
-									return err
-								}
-								continue
-							}
-						}
-					}
-				}
-				if memErr := m.chargeObject(res); memErr != nil {
-					if err := m.raiseObj(memErr); err != nil {
-						return err
-					}
-					continue
-				}
-				if err := m.tryPush(res); err != nil {
+			if viaCallWithArgs(callee) {
+				if err := m.callWithArgs(callee, args); err != nil {
 					return err
 				}
 				continue
@@ -1777,14 +2282,15 @@ func (m *VM) run(stopFrames int) error {
 				if callee != nil {
 					typeName = string(callee.Type())
 				}
-				if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("attempted to call non-function: %s", typeName)}); err != nil {
+				if err := m.raiseAt(0, &object.Error{Message: fmt.Sprintf("attempted to call non-function: %s", typeName)}); err != nil {
 					return err
 				}
 				continue
 			}
 			fn := cl.Fn
-			if len(args) != fn.NumParameters {
-				if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("wrong number of arguments: expected %d, got %d", fn.NumParameters, len(args))}); err != nil {
+			args, err := semantics.BindArgs(args, fn.NumParameters, cl.Defaults)
+			if err != nil {
+				if err := m.raiseObj(&object.Error{Message: err.Error()}); err != nil {
 					return err
 				}
 				continue
@@ -1795,6 +2301,12 @@ func (m *VM) run(stopFrames int) error {
 				}
 				continue
 			}
+			if errObj := m.callOverflow(1 + fn.NumLocals); errObj != nil {
+				if err := m.raiseObj(errObj); err != nil {
+					return err
+				}
+				continue
+			}
 
 			if err := m.tryPush(callee); err != nil {
 				return err
@@ -1809,7 +2321,7 @@ func (m *VM) run(stopFrames int) error {
 			newFrame := NewFrame(cl, basePointer)
 			m.pushFrame(newFrame)
 
-			m.sp = basePointer + fn.NumLocals
+			m.enterLocals(basePointer, len(args), fn.NumLocals)
 			continue
 
 		case code.OpCallMethod:
@@ -1817,7 +2329,7 @@ func (m *VM) run(stopFrames int) error {
 			numArgs := int(ins[frame.ip+3])
 			frame.ip += 3
 
-			nameObj, ok := m.constants[nameIdx].(*object.String)
+			nameObj, ok := frame.cl.Module.Constants[nameIdx].(*object.String)
 			if !ok {
 				if err := m.raiseObj(&object.Error{Message: "member name must be string constant"}); err != nil {
 					return err
@@ -1831,6 +2343,12 @@ func (m *VM) run(stopFrames int) error {
 			}
 			recv := m.pop()
 
+			if inst, ok := recv.(*object.Instance); ok {
+				if err := m.callInstanceMember(inst, nameObj.Value, args); err != nil {
+					return err
+				}
+				continue
+			}
 			if d, ok := recv.(*object.Dict); ok {
 				hk, ok := object.HashKeyOf(nameObj)
 				if !ok {
@@ -1839,7 +2357,7 @@ func (m *VM) run(stopFrames int) error {
 					}
 					continue
 				}
-				if pair, exists := d.Pairs[object.HashKeyString(hk)]; exists {
+				if pair, exists := d.Pairs[hk]; exists {
 					if err := m.callWithArgs(pair.Value, args); err != nil {
 						return err
 					}
@@ -1847,20 +2365,7 @@ func (m *VM) run(stopFrames int) error {
 				}
 			}
 
-			res := applyMethod(nameObj.Value, recv, args)
-			if errObj, ok := res.(*object.Error); ok {
-				if err := m.raiseObj(errObj); err != nil {
-					return err
-				}
-				continue
-			}
-			if memErr := m.chargeObject(res); memErr != nil {
-				if err := m.raiseObj(memErr); err != nil {
-					return err
-				}
-				continue
-			}
-			if err := m.tryPush(res); err != nil {
+			if err := m.callMethod(nameObj.Value, recv, args); err != nil {
 				return err
 			}
 			continue
@@ -1870,7 +2375,7 @@ func (m *VM) run(stopFrames int) error {
 			numArgs := int(ins[frame.ip+3])
 			frame.ip += 3
 
-			nameObj, ok := m.constants[nameIdx].(*object.String)
+			nameObj, ok := frame.cl.Module.Constants[nameIdx].(*object.String)
 			if !ok {
 				if err := m.raiseObj(&object.Error{Message: "member name must be string constant"}); err != nil {
 					return err
@@ -1882,7 +2387,7 @@ func (m *VM) run(stopFrames int) error {
 			for i := numArgs - 1; i >= 0; i-- {
 				rawArgs[i] = m.pop()
 			}
-			args, errObj := m.expandSpreadArgs(rawArgs)
+			args, errObj := m.expandSpreads(rawArgs, "call arguments")
 			if errObj != nil {
 				if err := m.raiseObj(errObj); err != nil {
 					return err
@@ -1891,6 +2396,12 @@ func (m *VM) run(stopFrames int) error {
 			}
 			recv := m.pop()
 
+			if inst, ok := recv.(*object.Instance); ok {
+				if err := m.callInstanceMember(inst, nameObj.Value, args); err != nil {
+					return err
+				}
+				continue
+			}
 			if d, ok := recv.(*object.Dict); ok {
 				hk, ok := object.HashKeyOf(nameObj)
 				if !ok {
@@ -1899,7 +2410,7 @@ func (m *VM) run(stopFrames int) error {
 					}
 					continue
 				}
-				if pair, exists := d.Pairs[object.HashKeyString(hk)]; exists {
+				if pair, exists := d.Pairs[hk]; exists {
 					if err := m.callWithArgs(pair.Value, args); err != nil {
 						return err
 					}
@@ -1907,20 +2418,7 @@ func (m *VM) run(stopFrames int) error {
 				}
 			}
 
-			res := applyMethod(nameObj.Value, recv, args)
-			if errObj, ok := res.(*object.Error); ok {
-				if err := m.raiseObj(errObj); err != nil {
-					return err
-				}
-				continue
-			}
-			if memErr := m.chargeObject(res); memErr != nil {
-				if err := m.raiseObj(memErr); err != nil {
-					return err
-				}
-				continue
-			}
-			if err := m.tryPush(res); err != nil {
+			if err := m.callMethod(nameObj.Value, recv, args); err != nil {
 				return err
 			}
 			continue
@@ -1948,7 +2446,7 @@ func (m *VM) run(stopFrames int) error {
 			}
 			fn := m.pop()
 
-			args, errObj := m.expandSpreadArgs(rawArgs)
+			args, errObj := m.expandSpreads(rawArgs, "call arguments")
 			if errObj != nil {
 				if err := m.raiseObj(errObj); err != nil {
 					return err
@@ -1970,11 +2468,25 @@ func (m *VM) run(stopFrames int) error {
 			}
 			oldFrame = m.popFrame()
 			m.sp = oldFrame.basePointer - 1
+			if oldFrame.ctor != nil {
+				ret = oldFrame.ctor
+			}
 			if err := m.tryPush(ret); err != nil {
 				return err
 			}
 			continue
 
+		case code.OpYield:
+			val := m.pop()
+			if frame.gen == nil {
+				return errors.New(m.formatStackTrace("yield outside generator"))
+			}
+			m.suspend(frame.gen)
+			if err := m.tryPush(val); err != nil {
+				return err
+			}
+			continue
+
 		case code.OpReturn:
 			oldFrame := m.currentFrame()
 			if err := m.runDefers(oldFrame); err != nil {
@@ -1985,7 +2497,11 @@ func (m *VM) run(stopFrames int) error {
 			}
 			oldFrame = m.popFrame()
 			m.sp = oldFrame.basePointer - 1
-			if err := m.tryPush(nilObj); err != nil {
+			var ret object.Object = nilObj
+			if oldFrame.ctor != nil {
+				ret = oldFrame.ctor
+			}
+			if err := m.tryPush(ret); err != nil {
 				return err
 			}
 			continue
@@ -2015,7 +2531,9 @@ func (m *VM) runDefers(frame *Frame) error {
 	return nil
 }
 
-func (m *VM) expandSpreadArgs(rawArgs []object.Object) ([]object.Object, *object.Error) {
+// expandSpreads replaces each Spread in rawArgs with the elements of its
+// tuple, array or range. in names the construct for the error on anything else.
+func (m *VM) expandSpreads(rawArgs []object.Object, in string) ([]object.Object, *object.Error) {
 	if len(rawArgs) == 0 {
 		return nil, nil
 	}
@@ -2033,69 +2551,66 @@ func (m *VM) expandSpreadArgs(rawArgs []object.Object) ([]object.Object, *object
 			out = append(out, v.Elements...)
 		case *object.Array:
 			out = append(out, v.Elements...)
+		case *object.Range:
+			if errObj := m.chargeAlloc("array", object.CostArrayElements(int(v.Len()))); errObj != nil {
+				return nil, errObj
+			}
+			out = append(out, v.Array().Elements...)
 		default:
 			typeName := "<nil>"
 			if val != nil {
 				typeName = string(val.Type())
 			}
-			return nil, &object.Error{Message: fmt.Sprintf("cannot spread %s in call arguments", typeName)}
+			return nil, &object.Error{Message: fmt.Sprintf("cannot spread %s in %s", typeName, in)}
 		}
 	}
 	return out, nil
 }
 
-func (m *VM) callWithArgs(callee object.Object, args []object.Object) error {
-	if b, ok := callee.(*object.Builtin); ok {
-		res := b.Fn(args...)
-		if errObj, ok := res.(*object.Error); ok {
-			if b == builtins[builtinIndex["error"]] {
-				if errObj.Stack == "" {
-					errObj.Stack = m.formatStackTrace(errObj.Message)
-				}
-				if memErr := m.chargeMemory(object.CostError()); memErr != nil {
-					if err := m.raiseObj(memErr); err != nil {
-						return err
-					}
-					return nil
-				}
-				if err := m.tryPush(errObj); err != nil {
-					return err
-				}
-				return nil
-			}
-			if err := m.raiseObj(errObj); err != nil {
-				return err
-			}
-			return nil
-		}
-		if b == builtins[builtinIndex["sort"]] {
-			if arr, ok := res.(*object.Array); ok {
-				extra := int64(0)
-				for _, el := range arr.Elements {
-					if s, ok := el.(*object.String); ok {
-						extra += object.CostStringBytes(len(s.Value))
-					}
-				}
-				if extra > 0 {
-					if memErr := m.chargeMemory(extra); memErr != nil {
-						if err := m.raiseObj(memErr); err != nil {
-							return err
-						}
-						return nil
-					}
-				}
+// mergeDictEntries builds the pairs of a dict literal with spreads. An entry
+// whose key is a Spread copies in the pairs of its dict; later entries win.
+func mergeDictEntries(raw []object.DictPair) (map[object.HashKey]object.DictPair, *object.Error) {
+	pairs := make(map[object.HashKey]object.DictPair, len(raw))
+	for _, entry := range raw {
+		if spread, ok := entry.Key.(*object.Spread); ok {
+			src, ok := spread.Value.(*object.Dict)
+			if !ok {
+				return nil, &object.Error{Message: fmt.Sprintf("cannot spread %s in dict literal", spread.Value.Type())}
 			}
-		}
-		if memErr := m.chargeObject(res); memErr != nil {
-			if err := m.raiseObj(memErr); err != nil {
-				return err
+			for hk, p := range src.Pairs {
+				pairs[hk] = p
 			}
-			return nil
+			continue
 		}
-		if err := m.tryPush(res); err != nil {
-			return err
+		hk, ok := object.HashKeyOf(entry.Key)
+		if !ok {
+			return nil, &object.Error{Message: fmt.Sprintf("unusable as dict key: %s", entry.Key.Type())}
 		}
-		return nil
+		pairs[hk] = entry
+	}
+	return pairs, nil
+}
+
+// viaCallWithArgs reports whether the call opcodes hand callee to
+// callWithArgs rather than pushing a closure frame themselves.
+func viaCallWithArgs(callee object.Object) bool {
+	switch c := callee.(type) {
+	case *object.Builtin, *object.Class, *object.BoundMethod:
+		return true
+	case *object.Closure:
+		return c.Fn.Generator
+	}
+	return false
+}
+
+func (m *VM) callWithArgs(callee object.Object, args []object.Object) error {
+	switch c := callee.(type) {
+	case *object.Builtin:
+		return m.callBuiltin(c, args)
+	case *object.Class:
+		return m.construct(c, args)
+	case *object.BoundMethod:
+		return m.callBound(c.Receiver, c.Method, args, nil)
 	}
 
 	cl, ok := callee.(*object.Closure)
@@ -2109,12 +2624,16 @@ func (m *VM) callWithArgs(callee object.Object, args []object.Object) error {
 		}
 		return nil
 	}
+	return m.callClosure(cl, args, nil)
+}
+
+// callClosure pushes a frame that calls cl with args. ctor, when not nil,
+// is what the call returns in place of cl's result.
+func (m *VM) callClosure(cl *object.Closure, args []object.Object, ctor object.Object) error {
 	fn := cl.Fn
-	if len(args) != fn.NumParameters {
-		if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("wrong number of arguments: expected %d, got %d", fn.NumParameters, len(args))}); err != nil {
-			return err
-		}
-		return nil
+	args, err := semantics.BindArgs(args, fn.NumParameters, cl.Defaults)
+	if err != nil {
+		return m.raiseObj(&object.Error{Message: err.Error()})
 	}
 	if m.maxRecursion > 0 && m.framesIndex >= m.maxRecursion+1 {
 		if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("max recursion depth exceeded (%d)", m.maxRecursion)}); err != nil {
@@ -2122,8 +2641,21 @@ func (m *VM) callWithArgs(callee object.Object, args []object.Object) error {
 		}
 		return nil
 	}
+	if fn.Generator {
+		g, errObj := m.newGenerator(cl, args)
+		if errObj != nil {
+			return m.raiseObj(errObj)
+		}
+		if ctor != nil {
+			return m.tryPush(ctor)
+		}
+		return m.tryPush(g)
+	}
+	if errObj := m.callOverflow(1 + fn.NumLocals); errObj != nil {
+		return m.raiseObj(errObj)
+	}
 
-	if err := m.tryPush(callee); err != nil {
+	if err := m.tryPush(cl); err != nil {
 		return err
 	}
 	for _, arg := range args {
@@ -2134,61 +2666,104 @@ func (m *VM) callWithArgs(callee object.Object, args []object.Object) error {
 
 	basePointer := m.sp - len(args)
 	newFrame := NewFrame(cl, basePointer)
+	newFrame.ctor = ctor
 	m.pushFrame(newFrame)
-	m.sp = basePointer + fn.NumLocals
+	m.enterLocals(basePointer, len(args), fn.NumLocals)
 	return nil
 }
 
-func (m *VM) runBuiltinMap(args []object.Object) (object.Object, bool, error) {
-	if len(args) != 2 {
-		return &object.Error{Message: fmt.Sprintf("wrong number of arguments: expected 2, got %d", len(args))}, true, nil
+// callBuiltin calls b and pushes its result, raising the error it returns
+// unless that error is a value built by error().
+func (m *VM) callBuiltin(b *object.Builtin, args []object.Object) error {
+	res, handled, err := m.callHost(b, args)
+	if !handled {
+		res = builtins.Call(b, args, m.chargeBuiltin)
+	} else if res == nil {
+		return err
 	}
-	fn := args[0]
-	arr, ok := args[1].(*object.Array)
-	if !ok {
-		return &object.Error{Message: "map() second argument must be ARRAY"}, true, nil
+	if errObj, ok := res.(*object.Error); ok {
+		if !errObj.IsValue {
+			return m.raiseObj(errObj)
+		}
+		if errObj.Stack == "" {
+			errObj.Stack = m.formatStackTrace(errObj.Message)
+		}
 	}
-	switch fn.(type) {
-	case *object.Builtin, *object.Closure:
-	default:
-		return &object.Error{Message: "map() first argument must be FUNCTION"}, true, nil
+	m.noteSite(res)
+	return m.tryPush(res)
+}
+
+// traceOp logs the instruction at frame.ip with its source position.
+func (m *VM) traceOp(frame *Frame, op code.Opcode) {
+	fn := frame.cl.Fn
+	line, col := lookupPos(fn.Pos, frame.ip)
+	name := fn.Name
+	if name == "" {
+		name = "<anon>"
+	}
+	opName := fmt.Sprintf("op%d", op)
+	if def, ok := code.Lookup(op); ok {
+		opName = def.Name
+	}
+	m.tracer.Op(fn.File, name, line, col, frame.ip, opName)
+}
+
+// noteSite records the current instruction as where obj was made, for a
+// heap dump that tracks sites.
+func (m *VM) noteSite(obj object.Object) {
+	if !heapdump.Tracking() {
+		return
+	}
+	f := m.currentFrame()
+	line, col := lookupPos(f.cl.Fn.Pos, f.ip)
+	heapdump.Note(obj, f.cl.Fn.File, line, col)
+}
+
+// globalBindings returns the global bindings of the module the current
+// frame's code belongs to.
+func (m *VM) globalBindings() map[string]object.Object {
+	mod := m.currentFrame().cl.Module
+	return semantics.SlotBindings(mod.GlobalNames, mod.Globals)
+}
+
+// localBindings returns the parameters and locals of the current frame, or
+// the module's globals at the top level.
+func (m *VM) localBindings() map[string]object.Object {
+	if m.framesIndex == 1 {
+		return m.globalBindings()
 	}
+	f := m.currentFrame()
+	fn := f.cl.Fn
+	return semantics.SlotBindings(fn.LocalNames, m.stack[f.basePointer:f.basePointer+fn.NumLocals])
+}
 
-	out := make([]object.Object, len(arr.Elements))
-	for i, el := range arr.Elements {
-		res, err := m.applyFunction(fn, []object.Object{el})
-		if err != nil {
+// runCallback calls fn on behalf of a builtin such as map or sort. ok is
+// false when fn raised an error that the VM has already dispatched, or when
+// err reports a VM failure.
+func (m *VM) runCallback(fn object.Object, args ...object.Object) (object.Object, bool, error) {
+	res, err := m.applyFunction(fn, args)
+	if err != nil || res == nil {
+		return nil, false, err
+	}
+	if errObj, ok := res.(*object.Error); ok && !errObj.IsValue {
+		if err := m.raiseObj(errObj); err != nil {
 			return nil, false, err
 		}
-		if res == nil {
-			return nil, false, nil
-		}
-		if errObj, ok := res.(*object.Error); ok && !errObj.IsValue {
-			if err := m.raiseObj(errObj); err != nil {
-				return nil, false, err
-			}
-			return nil, false, nil
-		}
-		out[i] = res
+		return nil, false, nil
 	}
-	return &object.Array{Elements: out}, true, nil
+	return res, true, nil
 }
 
 func (m *VM) applyFunction(fn object.Object, args []object.Object) (object.Object, error) {
 	if b, ok := fn.(*object.Builtin); ok {
-		if b == builtins[builtinIndex["map"]] {
-			res, ok, err := m.runBuiltinMap(args)
-			if err != nil {
-				return nil, err
-			}
-			if !ok {
-				return nil, nil
-			}
-			return res, nil
+		res, handled, err := m.callHost(b, args)
+		if !handled {
+			res = builtins.Call(b, args, m.chargeBuiltin)
+		} else if res == nil {
+			return nil, err
 		}
-		res := b.Fn(args...)
 		if errObj, ok := res.(*object.Error); ok {
-			if b == builtins[builtinIndex["error"]] {
+			if errObj.IsValue {
 				if errObj.Stack == "" {
 					errObj.Stack = m.formatStackTrace(errObj.Message)
 				}
@@ -2201,6 +2776,15 @@ func (m *VM) applyFunction(fn object.Object, args []object.Object) (object.Objec
 		}
 		return res, nil
 	}
+	switch f := fn.(type) {
+	case *object.Class:
+		return m.applyConstruct(f, args)
+	case *object.BoundMethod:
+		if errObj := checkMethodArity(f.Method, len(args)); errObj != nil {
+			return nil, m.raiseObj(errObj)
+		}
+		return m.applyFunction(f.Method, append([]object.Object{f.Receiver}, args...))
+	}
 
 	cl, ok := fn.(*object.Closure)
 	if !ok {
@@ -2214,8 +2798,9 @@ func (m *VM) applyFunction(fn object.Object, args []object.Object) (object.Objec
 		return nil, nil
 	}
 
-	if len(args) != cl.Fn.NumParameters {
-		if err := m.raiseObj(&object.Error{Message: fmt.Sprintf("wrong number of arguments: expected %d, got %d", cl.Fn.NumParameters, len(args))}); err != nil {
+	args, err := semantics.BindArgs(args, cl.Fn.NumParameters, cl.Defaults)
+	if err != nil {
+		if err := m.raiseObj(&object.Error{Message: err.Error()}); err != nil {
 			return nil, err
 		}
 		return nil, nil
@@ -2226,6 +2811,19 @@ func (m *VM) applyFunction(fn object.Object, args []object.Object) (object.Objec
 		}
 		return nil, nil
 	}
+	if cl.Fn.Generator {
+		g, errObj := m.newGenerator(cl, args)
+		if errObj != nil {
+			return nil, m.raiseObj(errObj)
+		}
+		return g, nil
+	}
+	if errObj := m.callOverflow(1 + cl.Fn.NumLocals); errObj != nil {
+		if err := m.raiseObj(errObj); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
 
 	startSP := m.sp
 	if err := m.tryPush(cl); err != nil {
@@ -2240,12 +2838,19 @@ func (m *VM) applyFunction(fn object.Object, args []object.Object) (object.Objec
 	basePointer := m.sp - len(args)
 	newFrame := NewFrame(cl, basePointer)
 	stopFrames := m.framesIndex
+	traps, finallys := len(m.traps), len(m.finallys)
 	m.pushFrame(newFrame)
-	m.sp = basePointer + cl.Fn.NumLocals
+	m.enterLocals(basePointer, len(args), cl.Fn.NumLocals)
 
 	if err := m.run(stopFrames); err != nil {
 		return nil, err
 	}
+	// An error raised in fn and handled outside it unwound fn's frame and
+	// left execution at the handler, with the error on the stack where a
+	// result would be.
+	if len(m.traps) < traps || len(m.finallys) < finallys {
+		return nil, nil
+	}
 
 	if m.sp == startSP+1 {
 		return m.pop(), nil
@@ -2254,6 +2859,15 @@ func (m *VM) applyFunction(fn object.Object, args []object.Object) (object.Objec
 }
 
 func lookupPos(pos []compiler.SourcePos, ip int) (line, col int) {
+	p, ok := lookupSourcePos(pos, ip)
+	if !ok {
+		return 0, 0
+	}
+	return p.Line, p.Col
+}
+
+// lookupSourcePos returns the position entry of the instruction at ip.
+func lookupSourcePos(pos []compiler.SourcePos, ip int) (compiler.SourcePos, bool) {
 	l, r := 0, len(pos)-1
 	best := -1
 	for l <= r {
@@ -2266,12 +2880,19 @@ func lookupPos(pos []compiler.SourcePos, ip int) (line, col int) {
 		}
 	}
 	if best == -1 {
-		return 0, 0
+		return compiler.SourcePos{}, false
 	}
-	return pos[best].Line, pos[best].Col
+	return pos[best], true
 }
 
 func (m *VM) formatStackTrace(message string) string {
+	return m.stackTrace(message, code.Span{})
+}
+
+// stackTrace formats the frames for an error with message. A non-zero span
+// replaces the innermost frame's position, so the trace points at the
+// operand the error is about.
+func (m *VM) stackTrace(message string, span code.Span) string {
 	out := "error: " + message + "\nstack trace:\n"
 	for i := m.framesIndex - 1; i >= 0; i-- {
 		f := m.frames[i]
@@ -2280,6 +2901,9 @@ func (m *VM) formatStackTrace(message string) string {
 		}
 		fn := f.cl.Fn
 		line, col := lookupPos(fn.Pos, f.ip)
+		if i == m.framesIndex-1 && !span.IsZero() {
+			line, col = span.Line, span.Col
+		}
 		name := fn.Name
 		if name == "" {
 			name = "<anon>"
@@ -2288,26 +2912,107 @@ func (m *VM) formatStackTrace(message string) string {
 		if file == "" {
 			file = "<unknown>"
 		}
-		out += fmt.Sprintf("  at %s (%s:%d:%d)\n", name, file, line, col)
+		out += fmt.Sprintf("  at %s (%s:%d:%d)", name, file, line, col)
+		if m.traceLocals && fn.NumParameters > 0 {
+			values := make([]object.Object, fn.NumParameters)
+			for j := range values {
+				if slot := f.basePointer + j; slot < len(m.stack) {
+					values[j] = m.stack[slot]
+					if cell, ok := values[j].(*object.Cell); ok {
+						values[j] = cellValue(cell)
+					}
+				}
+			}
+			out += " " + semantics.FormatParams(fn.LocalNames, values)
+		}
+		out += "\n"
 	}
 	return out
 }
 
+// raiseAt raises errObj with its span set to the source range of operand i
+// of the current instruction (0 is the first value pushed), falling back to
+// the whole expression when the compiler recorded no operand ranges.
+func (m *VM) raiseAt(i int, errObj *object.Error) error {
+	if errObj.Stack == "" && errObj.Span.IsZero() {
+		errObj.Span = m.operandSpan(i)
+	}
+	return m.raiseObj(errObj)
+}
+
+// operandSpan returns the range of operand i of the instruction the current
+// frame is executing, or of the whole expression when i is negative or out
+// of range. It is zero when the instruction has no recorded ranges.
+func (m *VM) operandSpan(i int) code.Span {
+	if m.framesIndex == 0 {
+		return code.Span{}
+	}
+	f := m.currentFrame()
+	if f == nil || f.cl == nil || f.cl.Fn == nil {
+		return code.Span{}
+	}
+	p, ok := lookupSourcePos(f.cl.Fn.Pos, f.ip)
+	if !ok {
+		return code.Span{}
+	}
+	if i >= 0 && i < len(p.Operands) && !p.Operands[i].IsZero() {
+		return p.Operands[i]
+	}
+	return p.Span
+}
+
+// binaryErrOperand picks the operand a failed arithmetic operation is
+// blamed on: the divisor for a division by zero, otherwise the whole
+// expression.
+func binaryErrOperand(err error) int {
+	switch err.Error() {
+	case "division by zero", "modulo by zero":
+		return 1
+	}
+	return -1
+}
+
+// raiseObj raises an error the runtime detected, giving it the catalog code
+// its message maps to when it has none.
 func (m *VM) raiseObj(errObj *object.Error) error {
+	if errObj != nil && errObj.Code == 0 && errObj.Stack == "" {
+		errObj.Code = errcode.Classify(errObj.Message)
+	}
+	return m.raise(errObj)
+}
+
+// raise dispatches errObj to the innermost handler, or unwinds the run when
+// there is none. Thrown errors come here directly and keep their own code.
+func (m *VM) raise(errObj *object.Error) error {
 	if errObj == nil {
 		return nil
 	}
 	if errObj.Code != limits.MemoryErrorCode {
-		if memErr := m.chargeMemory(object.CostError()); memErr != nil {
+		if memErr := m.chargeAlloc("error", object.CostError()); memErr != nil {
 			errObj = memErr
 		}
 	}
 	if errObj.Stack == "" {
-		errObj.Stack = m.formatStackTrace(errObj.Message)
+		if errObj.Span.IsZero() {
+			errObj.Span = m.operandSpan(-1)
+		}
+		if !errObj.Span.IsZero() {
+			errObj.File = m.currentFrame().cl.Fn.File
+		}
+		errObj.Stack = m.stackTrace(errObj.Message, errObj.Span)
 	}
 	const noCatch = 0xFFFF
 
-	if len(m.traps) > 0 {
+	if errObj.Code == limits.InterruptCode {
+		// An interrupt is not catchable; only finally blocks and defers
+		// run on the way out.
+		m.traps = m.traps[:0]
+	}
+	// A handler whose frame is gone must not catch: its catch address and
+	// stack pointer belong to a call that already returned.
+	m.dropStaleHandlers()
+	innerFinally := len(m.finallys) > 0 && m.finallys[len(m.finallys)-1].traps >= len(m.traps)
+	if len(m.traps) > 0 && !innerFinally {
 		t := m.traps[len(m.traps)-1]
 		if t.catchIP != noCatch {
 			m.traps = m.traps[:len(m.traps)-1]
@@ -2340,7 +3045,6 @@ func (m *VM) raiseObj(errObj *object.Error) error {
 	if len(m.finallys) > 0 {
 		f := m.finallys[len(m.finallys)-1]
 		m.finallys = m.finallys[:len(m.finallys)-1]
-		m.pendingErr = errObj
 
 		for m.framesIndex > f.frameIdx {
 			frame := m.frames[m.framesIndex-1]
@@ -2357,11 +3061,21 @@ func (m *VM) raiseObj(errObj *object.Error) error {
 		}
 		m.sp = f.sp
 
+		// The finally block opens with the OpEndFinally that pops its
+		// entry and pushes nil on the normal path; here the entry is
+		// already popped and the error takes the place of the nil, for
+		// the OpRethrowPending at the end of the block.
+		if err := m.push(errObj); err != nil {
+			return errors.New(errObj.Stack)
+		}
 		cf := m.currentFrame()
-		cf.ip = f.finallyIP - 1
+		cf.ip = f.finallyIP
 		return nil
 	}
 
+	if heapdump.Tracking() {
+		m.failedRoots = m.frameRoots()
+	}
 	for m.framesIndex > 0 {
 		f := m.frames[m.framesIndex-1]
 		if f != nil {
@@ -2376,7 +3090,7 @@ func (m *VM) raiseObj(errObj *object.Error) error {
 		m.framesIndex--
 	}
 
-	return errors.New(errObj.Stack)
+	return &RuntimeError{Err: errObj}
 }
 
 func (m *VM) execBinaryOp(op code.Opcode) error {
@@ -2388,7 +3102,7 @@ func (m *VM) execBinaryOp(op code.Opcode) error {
 		return err
 	}
 	if s, ok := res.(*object.String); ok {
-		if errObj := m.chargeMemory(object.CostStringBytes(len(s.Value))); errObj != nil {
+		if errObj := m.chargeAlloc("string", object.CostStringBytes(len(s.Value))); errObj != nil {
 			if err := m.raiseObj(errObj); err != nil {
 				return err
 			}
@@ -2430,51 +3144,6 @@ func nativeBool(b bool) object.Object {
 	return &object.Boolean{Value: false}
 }
 
-func activeTryCatchIP(ins code.Instructions, ip int) (int, bool) {
-	stack := []int{}
-	for i := 0; i < len(ins) && i <= ip; {
-		op := code.Opcode(ins[i])
-		def, ok := code.Lookup(op)
-		if !ok {
-			i++
-			continue
-		}
-		operands, read := code.ReadOperands(def, ins[i+1:])
-		switch op {
-		case code.OpTry:
-			if len(operands) > 0 {
-				stack = append(stack, operands[0])
-			}
-		case code.OpEndTry:
-			if len(stack) > 0 {
-				stack = stack[:len(stack)-1]
-			}
-		}
-		i += 1 + read
-	}
-	if len(stack) == 0 {
-		return 0, false
-	}
-	return stack[len(stack)-1], true
-}
-
-func firstTryCatchIP(ins code.Instructions) (int, bool) {
-	for i := 0; i < len(ins); {
-		op := code.Opcode(ins[i])
-		def, ok := code.Lookup(op)
-		if !ok {
-			i++
-			continue
-		}
-		operands, read := code.ReadOperands(def, ins[i+1:])
-		if op == code.OpTry && len(operands) > 0 {
-			return operands[0], true
-		}
-		i += 1 + read
-	}
-	return 0, false
-}
-
 func opString(op code.Opcode) string {
 	switch op {
 	case code.OpAdd:
@@ -38,7 +38,7 @@ func exportValue(exports *object.Dict, name string) (object.Object, bool) {
 	if !ok {
 		return nil, false
 	}
-	pair, ok := exports.Pairs[object.HashKeyString(hk)]
+	pair, ok := exports.Pairs[hk]
 	if !ok {
 		return nil, false
 	}
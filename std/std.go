@@ -0,0 +1,19 @@
+// Package std embeds the standard library sources so a welle binary can
+// resolve std: imports without a checkout of this repository.
+package std
+
+import "embed"
+
+//go:generate go run ../internal/tools/genstd
+
+// FS holds the *.wll modules of this directory at their base names.
+//
+//go:embed *.wll
+var FS embed.FS
+
+// Compiled holds compiled/<name>.wbc, the bytecode of each module as
+// written by go generate. The loader only uses an entry when it was
+// compiled from the exact source being imported.
+//
+//go:embed compiled
+var Compiled embed.FS
@@ -0,0 +1,584 @@
+/**
+ * Tree-sitter grammar for welle.
+ *
+ * Node names and child order follow the native parser's AST
+ * (internal/ast): each Go node type appears here in snake_case, so
+ * `welle ast -sexp` and `tree-sitter parse` print the same tree for a valid
+ * program. Keep the two in step; test/corpus is checked against both.
+ *
+ * Newlines end statements, as in the native lexer, so they are tokens here
+ * rather than extras. Like the native parser, the grammar allows a newline
+ * before a block's `{`, before `else`, and before a closing bracket.
+ */
+
+const PREC = {
+  coalesce: 2,
+  ternary: 3,
+  or: 4,
+  and: 5,
+  bitor: 6,
+  bitxor: 7,
+  bitand: 8,
+  equals: 9,
+  compare: 10,
+  shift: 11,
+  sum: 12,
+  product: 13,
+  prefix: 14,
+  index: 15,
+  call: 16,
+};
+
+const ASSIGN_OPS = ['=', ':=', '+=', '-=', '*=', '/=', '%='];
+const STATEMENT_ASSIGN_OPS = ASSIGN_OPS.concat(['|=']);
+
+const commaSep1 = (rule) => seq(rule, repeat(seq(',', rule)));
+const commaSep = (rule) => optional(commaSep1(rule));
+
+module.exports = grammar({
+  name: 'welle',
+
+  word: ($) => $.identifier,
+
+  // The `#welle 0.2` edition pragma and `@deprecated` annotations are left
+  // out of the tree, as the native parser keeps them on the program and the
+  // function rather than as nodes.
+  extras: ($) => [/[ \t\r\f]/, $.comment, $._pragma, $._annotation],
+
+  conflicts: ($) => [
+    // `if (c) {}` then a newline: either the statement ends or `else`
+    // follows on the next line. This is left to the GLR parser rather than
+    // settled by precedence, which would always pick one reading; it also
+    // covers a dangling `else`, which the native parser gives to the
+    // innermost `if`.
+    [$.if_statement],
+    // `(a, b` starts a tuple or the targets of `(a, b) = value`.
+    [$._simple_expression, $.destructure_target],
+    // `(x: ` starts a named tuple or the by-name targets of
+    // `(x: px) = value`.
+    [$.named_tuple_field, $.destructure_target],
+    // `for (x in xs` starts a for-in loop or a C-style init expression.
+    [$._simple_expression, $.for_in_statement],
+  ],
+
+  rules: {
+    program: ($) => repeat(choice($._statement, $._separator)),
+
+    _separator: (_) => choice('\n', ';'),
+    _nl: (_) => repeat1('\n'),
+
+    _statement: ($) =>
+      choice(
+        $.func_statement,
+        $.class_statement,
+        $.return_statement,
+        $.yield_statement,
+        $.defer_statement,
+        $.del_statement,
+        $.throw_statement,
+        $.assert_statement,
+        $.break_statement,
+        $.continue_statement,
+        $.pass_statement,
+        $.if_statement,
+        $.while_statement,
+        $.for_statement,
+        $.for_in_statement,
+        $.switch_statement,
+        $.try_statement,
+        $.import_statement,
+        $.from_import_statement,
+        $.export_statement,
+        $.assign_statement,
+        $.index_assign_statement,
+        $.member_assign_statement,
+        $.destructure_assign_statement,
+        $.expression_statement,
+      ),
+
+    block_statement: ($) =>
+      seq('{', repeat(choice($._statement, $._separator)), '}'),
+
+    _block: ($) => seq(optional($._nl), $.block_statement),
+
+    expression_statement: ($) => $._simple_expression,
+
+    func_statement: ($) =>
+      seq('func', field('name', $.identifier), $._parameters, $._block),
+
+    _parameters: ($) =>
+      seq(
+        '(',
+        commaSep(seq(field('parameter', $.identifier), optional(seq('=', field('default', $._expression))))),
+        optional($._nl),
+        ')',
+      ),
+
+    class_statement: ($) =>
+      seq(
+        'class',
+        field('name', $.identifier),
+        '{',
+        repeat(choice(
+          seq(field('field', $.identifier), optional(seq('=', field('default', $._expression)))),
+          field('method', $.func_statement),
+          $._separator,
+        )),
+        '}',
+      ),
+
+    return_statement: ($) =>
+      prec.right(seq('return', optional(commaSep1($._expression)))),
+
+    yield_statement: ($) => prec.right(seq('yield', optional($._expression))),
+
+    defer_statement: ($) => seq('defer', $._expression),
+
+    del_statement: ($) =>
+      seq('del', field('target', choice($.index_expression, $.slice_expression))),
+
+    throw_statement: ($) => seq('throw', $._expression),
+
+    assert_statement: ($) =>
+      prec.right(seq(
+        'assert',
+        field('condition', $._expression),
+        optional(seq(',', field('message', $._expression))),
+      )),
+
+    break_statement: (_) => 'break',
+    continue_statement: (_) => 'continue',
+    pass_statement: (_) => 'pass',
+
+    if_statement: ($) =>
+      prec.dynamic(1, seq(
+        'if',
+        '(',
+        field('condition', $._expression),
+        ')',
+        optional($._nl),
+        field('consequence', choice($.block_statement, $._statement)),
+        optional(seq(
+          optional($._nl),
+          'else',
+          optional($._nl),
+          field('alternative', choice($.block_statement, $._statement)),
+        )),
+      )),
+
+    while_statement: ($) =>
+      seq('while', '(', field('condition', $._expression), ')', $._block),
+
+    for_statement: ($) =>
+      seq(
+        'for',
+        '(',
+        optional(field('init', alias($._expression, $.expression_statement))),
+        ';',
+        optional(field('condition', $._expression)),
+        ';',
+        optional(field('post', alias($._expression, $.expression_statement))),
+        ')',
+        $._block,
+      ),
+
+    for_in_statement: ($) =>
+      prec(1, choice(
+        seq('for', field('var', $.identifier), 'in', field('iterable', $._expression), $._block),
+        seq('for', '(', field('var', $.identifier), 'in', field('iterable', $._expression), ')', $._block),
+        seq(
+          'for', '(', field('key', $.identifier), ',', field('value', $.identifier), ')',
+          'in', field('iterable', $._expression), $._block,
+        ),
+      )),
+
+    switch_statement: ($) =>
+      seq(
+        'switch',
+        '(',
+        field('value', $._expression),
+        ')',
+        optional($._nl),
+        '{',
+        repeat(choice($.case_clause, $._switch_default, $._separator)),
+        '}',
+      ),
+
+    case_clause: ($) => seq('case', commaSep1($._expression), $._block),
+
+    _switch_default: ($) =>
+      seq('default', optional($._nl), field('default', $.block_statement)),
+
+    try_statement: ($) =>
+      seq(
+        'try',
+        $._block,
+        choice(
+          seq($._catch, optional($._finally)),
+          $._finally,
+        ),
+      ),
+
+    _catch: ($) =>
+      seq(
+        'catch',
+        '(',
+        field('catch_name', $.identifier),
+        optional(seq(':', field('catch_filter', $._expression))),
+        ')',
+        $._block,
+      ),
+
+    _finally: ($) => seq('finally', $._block),
+
+    import_statement: ($) =>
+      seq('import', field('path', $.string_literal), optional(seq('as', field('alias', $.identifier)))),
+
+    from_import_statement: ($) =>
+      seq('from', field('path', $.string_literal), 'import', commaSep1($.import_item)),
+
+    import_item: ($) =>
+      seq(field('name', $.identifier), optional(seq('as', field('alias', $.identifier)))),
+
+    export_statement: ($) => seq('export', $._statement),
+
+    assign_statement: ($) =>
+      seq(
+        field('name', $.identifier),
+        field('operator', choice(...STATEMENT_ASSIGN_OPS)),
+        field('value', $._expression),
+      ),
+
+    // A slice target takes only '=': `xs[1:3] = [9]` splices xs.
+    index_assign_statement: ($) =>
+      choice(
+        seq(
+          field('left', $.index_expression),
+          field('operator', choice(...STATEMENT_ASSIGN_OPS.filter((op) => op !== ':='))),
+          field('value', $._expression),
+        ),
+        seq(
+          field('left', $.slice_expression),
+          field('operator', '='),
+          field('value', $._expression),
+        ),
+      ),
+
+    member_assign_statement: ($) =>
+      seq(
+        field('object', $._simple_expression),
+        '.',
+        optional($._nl),
+        field('property', $.identifier),
+        field('operator', choice(...STATEMENT_ASSIGN_OPS.filter((op) => op !== ':='))),
+        field('value', $._expression),
+      ),
+
+    destructure_assign_statement: ($) =>
+      seq(
+        '(',
+        commaSep1($.destructure_target),
+        optional(','),
+        ')',
+        field('operator', choice(...STATEMENT_ASSIGN_OPS)),
+        field('value', $._expression),
+      ),
+
+    destructure_target: ($) =>
+      choice(
+        seq(optional('*'), $.identifier),
+        seq(field('field', $.identifier), ':', field('name', $.identifier)),
+      ),
+
+    // Expressions. An assignment is only an expression where the native
+    // parser starts a full expression (arguments, elements, conditions);
+    // at statement level it is one of the *_assign_statement nodes.
+
+    _expression: ($) => choice($.assign_expression, $._simple_expression),
+
+    assign_expression: ($) =>
+      prec.right(seq(
+        field('left', choice($.identifier, $.member_expression, $.index_expression, $.slice_expression)),
+        field('operator', choice(...ASSIGN_OPS)),
+        field('value', $._expression),
+      )),
+
+    _simple_expression: ($) =>
+      choice(
+        $.identifier,
+        $.integer_literal,
+        $.float_literal,
+        $.string_literal,
+        $.template_literal,
+        $.boolean_literal,
+        $.nil_literal,
+        $._parenthesized_expression,
+        $.tuple_literal,
+        $.named_tuple_literal,
+        $.list_literal,
+        $.list_comprehension,
+        $.dict_literal,
+        $.set_literal,
+        $.function_literal,
+        $.match_expression,
+        $.prefix_expression,
+        $.infix_expression,
+        $.conditional_expression,
+        $.cond_expr,
+        $.member_expression,
+        $.call_expression,
+        $.index_expression,
+        $.slice_expression,
+      ),
+
+    _parenthesized_expression: ($) => seq('(', $._expression, optional($._nl), ')'),
+
+    prefix_expression: ($) =>
+      prec(PREC.prefix, seq(
+        field('operator', choice('-', '!', 'not', '~')),
+        field('right', $._simple_expression),
+      )),
+
+    infix_expression: ($) => {
+      const table = [
+        [PREC.or, 'or'],
+        [PREC.and, 'and'],
+        [PREC.bitor, '|'],
+        [PREC.bitxor, '^'],
+        [PREC.bitand, '&'],
+        [PREC.equals, choice('==', '!=', 'is')],
+        [PREC.compare, choice('<', '<=', '>', '>=', 'in')],
+        [PREC.shift, choice('<<', '>>')],
+        [PREC.sum, choice('+', '-')],
+        [PREC.product, choice('*', '/', '%')],
+      ];
+      return choice(
+        ...table.map(([p, op]) => prec.left(p, seq(
+          field('left', $._simple_expression),
+          field('operator', op),
+          field('right', $._simple_expression),
+        ))),
+        // `??` groups to the right.
+        prec.right(PREC.coalesce, seq(
+          field('left', $._simple_expression),
+          field('operator', '??'),
+          field('right', $._simple_expression),
+        )),
+      );
+    },
+
+    conditional_expression: ($) =>
+      prec.right(PREC.ternary, seq(
+        field('condition', $._simple_expression),
+        '?',
+        field('then', $._simple_expression),
+        optional($._nl),
+        ':',
+        field('else', $._simple_expression),
+      )),
+
+    cond_expr: ($) =>
+      prec.right(PREC.ternary, seq(
+        field('then', $._simple_expression),
+        'if',
+        field('condition', $._simple_expression),
+        optional($._nl),
+        'else',
+        field('else', $._simple_expression),
+      )),
+
+    member_expression: ($) =>
+      prec(PREC.call, seq(
+        field('object', $._simple_expression),
+        '.',
+        optional($._nl),
+        field('property', $.identifier),
+      )),
+
+    call_expression: ($) =>
+      prec(PREC.call, seq(
+        field('function', $._simple_expression),
+        '(',
+        commaSep(choice($._expression, $.spread_expression)),
+        optional($._nl),
+        ')',
+      )),
+
+    spread_expression: ($) => seq('...', $._expression),
+
+    // `grid[y, x]` is one node here with an index per dimension; the native
+    // parser lowers it to nested index expressions, `grid[y][x]`.
+    index_expression: ($) =>
+      prec(PREC.index, seq(
+        field('left', $._simple_expression),
+        '[',
+        commaSep1(field('index', $._expression)),
+        optional($._nl),
+        ']',
+      )),
+
+    slice_expression: ($) =>
+      prec(PREC.index, seq(
+        field('left', $._simple_expression),
+        '[',
+        optional(field('low', $._expression)),
+        ':',
+        optional(field('high', $._expression)),
+        optional(seq(':', optional(field('step', $._expression)))),
+        optional($._nl),
+        ']',
+      )),
+
+    function_literal: ($) => seq('func', $._parameters, $._block),
+
+    match_expression: ($) =>
+      seq(
+        'match',
+        '(',
+        field('value', $._expression),
+        ')',
+        optional($._nl),
+        '{',
+        repeat(choice($.match_case, $._match_default, $._separator)),
+        '}',
+      ),
+
+    // A case value stops before `? :` and `x if c else y`, as in the
+    // native parser, so an `if` after it starts the guard.
+    match_case: ($) =>
+      seq(
+        'case',
+        commaSep1(choice($.match_pattern, $._simple_expression)),
+        optional(seq('if', field('guard', $._match_guard))),
+        $._match_body,
+      ),
+
+    // A tuple, list or dict literal that is a whole case value destructures
+    // the subject; `(1, 2)[0]` is still a value.
+    match_pattern: ($) =>
+      prec(1, choice($.tuple_literal, $.list_literal, $.dict_literal)),
+
+    // A newline after the guard comes before the case's `{`, not before the
+    // `else` of an `x if c else y` guard.
+    _match_guard: ($) =>
+      prec(PREC.ternary + 1, choice($.assign_expression, $._simple_expression)),
+
+    _match_default: ($) => seq('default', $._match_body),
+
+    _match_body: ($) =>
+      seq(
+        optional($._nl),
+        '{',
+        repeat($._separator),
+        field('result', $._expression),
+        optional($._nl),
+        '}',
+      ),
+
+    tuple_literal: ($) =>
+      choice(
+        seq('(', ')'),
+        seq(
+          '(',
+          $._expression,
+          ',',
+          optional(seq(commaSep1($._expression), optional(','))),
+          optional($._nl),
+          ')',
+        ),
+      ),
+
+    named_tuple_literal: ($) =>
+      seq('(', commaSep1($.named_tuple_field), optional(','), optional($._nl), ')'),
+
+    named_tuple_field: ($) =>
+      seq(field('name', $.identifier), ':', field('value', $._expression)),
+
+    list_literal: ($) =>
+      seq('[', commaSep(choice($._expression, $.spread_expression)), optional($._nl), ']'),
+
+    list_comprehension: ($) =>
+      seq(
+        '[',
+        field('element', $._expression),
+        'for',
+        field('var', $.identifier),
+        'in',
+        field('sequence', $._simple_expression),
+        optional(seq('if', field('filter', $._expression))),
+        optional($._nl),
+        ']',
+      ),
+
+    dict_literal: ($) =>
+      seq('#', optional($._nl), '{', commaSep($.dict_pair), optional($._nl), '}'),
+
+    set_literal: ($) => seq('#', '[', commaSep($._expression), optional($._nl), ']'),
+
+    dict_pair: ($) =>
+      choice(
+        seq(field('key', $._expression), optional($._nl), ':', field('value', $._expression)),
+        field('shorthand', $.identifier),
+        $.spread_expression,
+      ),
+
+    template_literal: ($) =>
+      choice(
+        $._template,
+        prec(PREC.call, seq(field('tag', $._simple_expression), $._template)),
+      ),
+
+    _template: ($) =>
+      seq(
+        't"',
+        repeat(choice(
+          $._template_chars,
+          $._template_escape,
+          token.immediate('$'),
+          seq(token.immediate('${'), $._expression, '}'),
+        )),
+        token.immediate('"'),
+      ),
+
+    _template_chars: (_) => token.immediate(prec(1, /[^"\\$\n]+/)),
+    _template_escape: (_) => token.immediate(/\\./),
+
+    identifier: (_) => /[A-Za-z_\u0080-\uFFFF][A-Za-z0-9_\u0080-\uFFFF]*/,
+
+    integer_literal: (_) =>
+      token(choice(
+        /0[xX][0-9a-fA-F_]+/,
+        /0[bB][01_]+/,
+        /0[oO][0-7_]+/,
+        /[0-9][0-9_]*/,
+      )),
+
+    float_literal: (_) =>
+      token(/[0-9][0-9_]*(\.[0-9_]+([eE][+-]?[0-9_]*)?|[eE][+-]?[0-9_]*)/),
+
+    string_literal: (_) =>
+      token(choice(
+        seq('"', repeat(choice(/[^"\\\n]/, /\\./)), '"'),
+        seq('"""', repeat(choice(/[^"]/, /"[^"]/, /""[^"]/)), '"""'),
+        seq('`', /[^`]*/, '`'),
+      )),
+
+    boolean_literal: (_) => choice('true', 'false'),
+
+    nil_literal: (_) => choice('nil', 'null'),
+
+    _pragma: (_) => token(seq('#welle', /[ \t]+/, /\d+\.\d+/)),
+
+    _annotation: (_) =>
+      token(seq(
+        '@deprecated',
+        optional(seq(/[ \t]*\([ \t]*/, /"([^"\\\n]|\\.)*"/, /[ \t]*\)/)),
+      )),
+
+    comment: (_) =>
+      token(choice(
+        seq('//', /[^\n]*/),
+        seq('/*', /[^*]*\*+([^/*][^*]*\*+)*/, '/'),
+      )),
+  },
+});
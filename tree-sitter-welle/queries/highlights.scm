@@ -0,0 +1,85 @@
+; Keywords
+
+[
+  "func"
+  "class"
+  "return"
+  "yield"
+  "defer"
+  "del"
+  "throw"
+  "assert"
+  "if"
+  "else"
+  "while"
+  "for"
+  "in"
+  "switch"
+  "match"
+  "case"
+  "default"
+  "try"
+  "catch"
+  "finally"
+  "import"
+  "from"
+  "as"
+  "export"
+  "and"
+  "or"
+  "not"
+  "is"
+] @keyword
+
+[
+  (break_statement)
+  (continue_statement)
+  (pass_statement)
+] @keyword
+
+; Functions
+
+(func_statement name: (identifier) @function)
+(class_statement name: (identifier) @type)
+
+(call_expression
+  function: (identifier) @function.call)
+
+(call_expression
+  function: (member_expression property: (identifier) @function.method.call))
+
+(func_statement parameter: (identifier) @variable.parameter)
+(function_literal parameter: (identifier) @variable.parameter)
+
+; Properties
+
+(member_expression property: (identifier) @property)
+(member_assign_statement property: (identifier) @property)
+(named_tuple_field name: (identifier) @property)
+(destructure_target field: (identifier) @property)
+(class_statement field: (identifier) @property)
+
+; Literals
+
+(string_literal) @string
+(template_literal) @string
+(integer_literal) @number
+(float_literal) @number.float
+(boolean_literal) @boolean
+(nil_literal) @constant.builtin
+(comment) @comment
+
+; Operators and punctuation
+
+[
+  "=" ":=" "+=" "-=" "*=" "/=" "%=" "|="
+  "+" "-" "*" "/" "%" "!" "~"
+  "==" "!=" "<" "<=" ">" ">="
+  "&" "|" "^" "<<" ">>" "??" "?"
+  "..."
+] @operator
+
+["(" ")" "[" "]" "{" "}" "#"] @punctuation.bracket
+["," "." ":" ";"] @punctuation.delimiter
+
+(identifier) @variable
@@ -0,0 +1,38 @@
+#!/bin/sh
+# Compares the tree-sitter grammar with the native parser on every .wll file
+# in the repository that the native parser accepts. Run from
+# tree-sitter-welle after `tree-sitter generate`.
+set -eu
+
+root=$(cd "$(dirname "$0")/../.." && pwd)
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+(cd "$root" && go build -o "$tmp/welle" ./cmd/welle)
+
+# tree-sitter prints ranges, field names and comments; the native tree has
+# none of them.
+normalize() {
+	sed -E -e 's/ \[[0-9]+, [0-9]+\] - \[[0-9]+, [0-9]+\]//g' \
+		-e 's/[a-z_]+: //g' \
+		-e 's/ ?\(comment\)//g' |
+		tr -s ' \n' ' ' | sed -e 's/^ //' -e 's/ $//'
+}
+
+checked=0
+failed=0
+for f in $(cd "$root" && find std examples tests -name '*.wll' | sort); do
+	if ! "$tmp/welle" ast -sexp "$root/$f" >"$tmp/native" 2>/dev/null; then
+		continue
+	fi
+	checked=$((checked + 1))
+	npx tree-sitter parse "$root/$f" 2>/dev/null | normalize >"$tmp/ts" || true
+	normalize <"$tmp/native" >"$tmp/want"
+	if ! cmp -s "$tmp/want" "$tmp/ts"; then
+		failed=$((failed + 1))
+		echo "MISMATCH $f"
+	fi
+done
+
+echo "$checked files checked, $failed mismatched"
+[ "$failed" -eq 0 ]
@@ -4,6 +4,7 @@ const path = require("path");
 const fs = require("fs");
 const vscode = require("vscode");
 const { LanguageClient, TransportKind } = require("vscode-languageclient/node");
+const { registerDebugger } = require("./debug");
 
 let client;
 
@@ -95,6 +96,8 @@ function activate(context) {
   output.appendLine("[welle] Extension activating...");
   context.subscriptions.push(output);
 
+  registerDebugger(context, output);
+
   const serverCommand = resolveServerCommand(context, output);
 
   const serverOptions = {